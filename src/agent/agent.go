@@ -0,0 +1,148 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+// Package agent lets Go tools observe the VM as it runs, in the manner of a JVMTI
+// agent, but in Go rather than through JVMTI's C interface. A tool implements Agent
+// (usually by embedding Hooks, whose methods do nothing, and overriding the events it
+// wants) and registers it with Register() in an init() function. The tool is compiled
+// into Jacobin by importing its package in a file of Jacobin's main package:
+//
+//	package main
+//
+//	import _ "example.com/tracer"
+//
+// The events are delivered synchronously, on the goroutine of the thread they occur
+// on, so an agent's methods must be safe to call from several goroutines at once, and
+// the time they take is added to the program's. Threads are identified by their IDs;
+// classes are named as in the class files (java/lang/String), and methods by their
+// names and descriptors.
+//
+// When no agent is registered, each place an event can occur costs an atomic load.
+package agent
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Agent receives the VM's events
+type Agent interface {
+	// OnVMInit is called once the VM is ready, just before main() is run
+	OnVMInit()
+	// OnVMDeath is called once as the VM exits; no events follow it
+	OnVMDeath()
+	// OnClassLoad is called when a class has been loaded, with the name of its loader
+	OnClassLoad(class, loader string)
+	// OnMethodEntry is called when a method (Java or native) is invoked
+	OnMethodEntry(thread int, class, method, desc string)
+	// OnMethodExit is called when a method returns, or is ended by an exception
+	OnMethodExit(thread int, class, method, desc string, byException bool)
+	// OnExceptionThrow is called when an exception is thrown, with the text of the
+	// exception (as in "java.lang.ArithmeticException: / by zero") and the method
+	// that threw it
+	OnExceptionThrow(thread int, exception, class, method, desc string)
+	// OnThreadStart is called when a thread starts, on the thread's own goroutine if
+	// it's not the main thread
+	OnThreadStart(thread int, name string)
+	// OnThreadEnd is called when a thread ends
+	OnThreadEnd(thread int, name string)
+}
+
+// Hooks is an Agent whose methods do nothing. Agents embed it, so that they need only
+// implement the events they want.
+type Hooks struct{}
+
+func (Hooks) OnVMInit()                                                             {}
+func (Hooks) OnVMDeath()                                                            {}
+func (Hooks) OnClassLoad(class, loader string)                                      {}
+func (Hooks) OnMethodEntry(thread int, class, method, desc string)                  {}
+func (Hooks) OnMethodExit(thread int, class, method, desc string, byException bool) {}
+func (Hooks) OnExceptionThrow(thread int, exception, class, method, desc string)    {}
+func (Hooks) OnThreadStart(thread int, name string)                                 {}
+func (Hooks) OnThreadEnd(thread int, name string)                                   {}
+
+var (
+	agents      []Agent
+	agentsMutex sync.RWMutex
+	active      int32 // 1 if any agent is registered; accessed via sync/atomic
+	deathOnce   sync.Once
+)
+
+// Register adds the agent, which receives the events from then on
+func Register(a Agent) {
+	agentsMutex.Lock()
+	agents = append(agents, a)
+	atomic.StoreInt32(&active, 1)
+	agentsMutex.Unlock()
+}
+
+// Unregister removes the agent
+func Unregister(a Agent) {
+	agentsMutex.Lock()
+	for i, registered := range agents {
+		if registered == a {
+			agents = append(agents[:i:i], agents[i+1:]...)
+			break
+		}
+	}
+	if len(agents) == 0 {
+		atomic.StoreInt32(&active, 0)
+	}
+	agentsMutex.Unlock()
+}
+
+// Active is whether any agent is registered. The VM checks it before preparing the
+// details of an event.
+func Active() bool {
+	return atomic.LoadInt32(&active) != 0
+}
+
+// each calls fn with each registered agent
+func each(fn func(a Agent)) {
+	if !Active() {
+		return
+	}
+	agentsMutex.RLock()
+	registered := agents
+	agentsMutex.RUnlock()
+	for _, a := range registered {
+		fn(a)
+	}
+}
+
+// The functions the VM calls when the events occur
+
+func VMInit() {
+	each(func(a Agent) { a.OnVMInit() })
+}
+
+func VMDeath() {
+	deathOnce.Do(func() { each(func(a Agent) { a.OnVMDeath() }) })
+}
+
+func ClassLoaded(class, loader string) {
+	each(func(a Agent) { a.OnClassLoad(class, loader) })
+}
+
+func MethodEntered(thread int, class, method, desc string) {
+	each(func(a Agent) { a.OnMethodEntry(thread, class, method, desc) })
+}
+
+func MethodExited(thread int, class, method, desc string, byException bool) {
+	each(func(a Agent) { a.OnMethodExit(thread, class, method, desc, byException) })
+}
+
+func ExceptionThrown(thread int, exception, class, method, desc string) {
+	each(func(a Agent) { a.OnExceptionThrow(thread, exception, class, method, desc) })
+}
+
+func ThreadStarted(thread int, name string) {
+	each(func(a Agent) { a.OnThreadStart(thread, name) })
+}
+
+func ThreadEnded(thread int, name string) {
+	each(func(a Agent) { a.OnThreadEnd(thread, name) })
+}
@@ -0,0 +1,50 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package agent
+
+import "testing"
+
+type classCounter struct {
+	Hooks
+	classes []string
+	deaths  int
+}
+
+func (c *classCounter) OnClassLoad(class, loader string) {
+	c.classes = append(c.classes, class+"@"+loader)
+}
+func (c *classCounter) OnVMDeath() { c.deaths++ }
+
+func TestRegisterAndUnregister(t *testing.T) {
+	if Active() {
+		t.Fatalf("Expected no agent to be registered")
+	}
+	ClassLoaded("test/Before", "app") // not delivered to anyone
+
+	c := &classCounter{}
+	Register(c)
+	if !Active() {
+		t.Errorf("Expected an agent to be registered")
+	}
+	ClassLoaded("test/During", "app")
+	MethodEntered(1, "test/During", "run", "()V") // Hooks ignores it
+	VMDeath()
+	VMDeath() // delivered once
+
+	Unregister(c)
+	if Active() {
+		t.Errorf("Expected no agent to be registered after Unregister()")
+	}
+	ClassLoaded("test/After", "app")
+
+	if len(c.classes) != 1 || c.classes[0] != "test/During@app" {
+		t.Errorf("Expected only the class loaded while registered, got: %v", c.classes)
+	}
+	if c.deaths != 1 {
+		t.Errorf("Expected OnVMDeath() once, got %d", c.deaths)
+	}
+}
@@ -0,0 +1,255 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/log"
+	"strconv"
+)
+
+// Attribute lengths. Every attribute declares its length, and the parser takes that
+// many bytes for it. If the length doesn't match the attribute's structure, as read from
+// its counts and tables, the attribute is misread, and so, when the length is too short,
+// are the attributes and members that follow it. So the length of each attribute whose
+// structure is defined by the JVMS is checked against the bytes its structure takes
+// (JVMS 4.7). Other attributes are skipped, as the JVMS requires, with a warning.
+
+// attributeWalker steps through the structure of an attribute. Reads past the end of
+// the data return 0 but still advance pos, so that pos is the size the structure takes.
+type attributeWalker struct {
+	data      []byte
+	pos       int
+	malformed bool // an item has a tag the JVMS doesn't define
+}
+
+func (w *attributeWalker) u1() int {
+	w.pos++
+	if w.pos > len(w.data) {
+		return 0
+	}
+	return int(w.data[w.pos-1])
+}
+
+func (w *attributeWalker) u2() int {
+	return w.u1()<<8 | w.u1()
+}
+
+func (w *attributeWalker) u4() int {
+	return w.u2()<<16 | w.u2()
+}
+
+func (w *attributeWalker) skip(n int) {
+	w.pos += n
+}
+
+// table skips a table of entries of the given size, preceded by its u2 length
+func (w *attributeWalker) table(entrySize int) {
+	w.skip(w.u2() * entrySize)
+}
+
+// attributes skips a table of attribute_info structures, preceded by its u2 length
+func (w *attributeWalker) attributes() {
+	for count := w.u2(); count > 0; count-- {
+		w.skip(2)
+		w.skip(w.u4())
+	}
+}
+
+// see: https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.16
+func (w *attributeWalker) annotation() {
+	w.skip(2) // type_index
+	for count := w.u2(); count > 0 && !w.malformed; count-- {
+		w.skip(2) // element_name_index
+		w.elementValue()
+	}
+}
+
+func (w *attributeWalker) elementValue() {
+	switch w.u1() {
+	case 'B', 'C', 'D', 'F', 'I', 'J', 'S', 'Z', 's', 'c':
+		w.skip(2)
+	case 'e':
+		w.skip(4)
+	case '@':
+		w.annotation()
+	case '[':
+		for count := w.u2(); count > 0 && !w.malformed; count-- {
+			w.elementValue()
+		}
+	default:
+		w.malformed = true
+	}
+}
+
+func (w *attributeWalker) annotations() {
+	for count := w.u2(); count > 0 && !w.malformed; count-- {
+		w.annotation()
+	}
+}
+
+// see: https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.20
+func (w *attributeWalker) typeAnnotation() {
+	switch targetType := w.u1(); {
+	case targetType == 0x00, targetType == 0x01, targetType == 0x16: // type parameter, formal parameter
+		w.skip(1)
+	case targetType == 0x10, targetType == 0x17, targetType == 0x42: // supertype, throws, catch
+		w.skip(2)
+	case targetType == 0x11, targetType == 0x12: // type parameter bound
+		w.skip(2)
+	case targetType >= 0x13 && targetType <= 0x15: // empty
+	case targetType == 0x40, targetType == 0x41: // local variable
+		w.table(6)
+	case targetType >= 0x43 && targetType <= 0x46: // offset
+		w.skip(2)
+	case targetType >= 0x47 && targetType <= 0x4B: // type argument
+		w.skip(3)
+	default:
+		w.malformed = true
+		return
+	}
+	w.skip(w.u1() * 2) // type_path
+	w.annotation()
+}
+
+// see: https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.4
+func (w *attributeWalker) stackMapFrame() {
+	frameType := w.u1()
+	switch {
+	case frameType <= 63: // same_frame
+	case frameType <= 127: // same_locals_1_stack_item_frame
+		w.verificationType()
+	case frameType <= 246:
+		w.malformed = true
+	case frameType == 247: // same_locals_1_stack_item_frame_extended
+		w.skip(2)
+		w.verificationType()
+	case frameType <= 251: // chop_frame and same_frame_extended
+		w.skip(2)
+	case frameType <= 254: // append_frame
+		w.skip(2)
+		for i := 0; i < frameType-251; i++ {
+			w.verificationType()
+		}
+	default: // full_frame
+		w.skip(2)
+		for locals := w.u2(); locals > 0 && !w.malformed; locals-- {
+			w.verificationType()
+		}
+		for stack := w.u2(); stack > 0 && !w.malformed; stack-- {
+			w.verificationType()
+		}
+	}
+}
+
+func (w *attributeWalker) verificationType() {
+	switch tag := w.u1(); {
+	case tag == 7, tag == 8: // Object_variable_info, Uninitialized_variable_info
+		w.skip(2)
+	case tag > 8:
+		w.malformed = true
+	}
+}
+
+// attributeSize returns the number of bytes the structure of the attribute with the
+// given name and contents takes, and whether the JVMS defines the attribute. The size
+// is -1 if the structure has an item with an undefined tag.
+func attributeSize(name string, content []byte) (int, bool) {
+	w := &attributeWalker{data: content}
+	switch name {
+	case "AnnotationDefault":
+		w.elementValue()
+	case "BootstrapMethods":
+		for count := w.u2(); count > 0 && !w.malformed; count-- {
+			w.skip(2) // bootstrap_method_ref
+			w.table(2)
+		}
+	case "Code":
+		w.skip(4) // max_stack and max_locals
+		w.skip(w.u4())
+		w.table(8) // exception_table
+		w.attributes()
+	case "ConstantValue", "ModuleMainClass", "NestHost", "Signature", "SourceFile":
+		w.skip(2)
+	case "Deprecated", "Synthetic":
+	case "EnclosingMethod":
+		w.skip(4)
+	case "Exceptions", "ModulePackages", "NestMembers", "PermittedSubclasses":
+		w.table(2)
+	case "InnerClasses":
+		w.table(8)
+	case "LineNumberTable":
+		w.table(4)
+	case "LocalVariableTable", "LocalVariableTypeTable":
+		w.table(10)
+	case "MethodParameters":
+		w.skip(w.u1() * 4)
+	case "Module":
+		w.skip(6)  // module_name_index, module_flags, module_version_index
+		w.table(6) // requires
+		for exports := w.u2(); exports > 0 && !w.malformed; exports-- {
+			w.skip(4) // exports_index and exports_flags
+			w.table(2)
+		}
+		for opens := w.u2(); opens > 0 && !w.malformed; opens-- {
+			w.skip(4) // opens_index and opens_flags
+			w.table(2)
+		}
+		w.table(2) // uses
+		for provides := w.u2(); provides > 0 && !w.malformed; provides-- {
+			w.skip(2)
+			w.table(2)
+		}
+	case "Record":
+		for count := w.u2(); count > 0 && !w.malformed; count-- {
+			w.skip(4) // name_index and descriptor_index
+			w.attributes()
+		}
+	case "RuntimeVisibleAnnotations", "RuntimeInvisibleAnnotations":
+		w.annotations()
+	case "RuntimeVisibleParameterAnnotations", "RuntimeInvisibleParameterAnnotations":
+		for count := w.u1(); count > 0 && !w.malformed; count-- {
+			w.annotations()
+		}
+	case "RuntimeVisibleTypeAnnotations", "RuntimeInvisibleTypeAnnotations":
+		for count := w.u2(); count > 0 && !w.malformed; count-- {
+			w.typeAnnotation()
+		}
+	case "SourceDebugExtension": // its contents are the whole attribute
+		w.skip(len(content))
+	case "StackMapTable":
+		for count := w.u2(); count > 0 && !w.malformed; count-- {
+			w.stackMapFrame()
+		}
+	default:
+		return 0, false
+	}
+
+	// a structure that runs past the attribute's length is reported as such, even if
+	// it found an undefined tag in the bytes that follow
+	if w.malformed && w.pos <= len(content) {
+		return -1, true
+	}
+	return w.pos, true
+}
+
+// checkAttributeLength checks that the length of the attribute matches the bytes its
+// structure takes. The owner is the class, field, or method the attribute belongs to,
+// as it's described in the error message. Attributes the JVMS doesn't define are
+// skipped with a warning.
+func checkAttributeLength(klass *ParsedClass, attrib attr, owner string) error {
+	name := klass.utf8Refs[attrib.attrName].content
+	size, known := attributeSize(name, attrib.attrContent)
+	switch {
+	case !known:
+		log.Log("Skipping unknown attribute "+name+" of "+owner, log.WARNING)
+	case size < 0:
+		return cfe("JACOBIN-CL-0166", name, owner)
+	case size != attrib.attrSize:
+		return cfe("JACOBIN-CL-0165", name, owner, strconv.Itoa(attrib.attrSize), strconv.Itoa(size))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io/ioutil"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAttributeSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		size    int
+	}{
+		{"SourceFile", []byte{0, 5}, 2},
+		{"Deprecated", []byte{}, 0},
+		{"NestMembers", []byte{0, 2, 0, 7, 0, 8}, 6},
+		{"NestMembers", []byte{0, 2, 0, 7}, 6},                 // the table is cut short
+		{"LineNumberTable", []byte{0, 1, 0, 0, 0, 3, 0, 0}, 6}, // two bytes too many
+		{"MethodParameters", []byte{1, 0, 9, 0, 0x10}, 5},
+		{"BootstrapMethods", []byte{0, 2, 0, 3, 0, 1, 0, 4, 0, 3, 0, 0}, 12},
+		// max_stack, max_locals, code_length, code, exception table, and one attribute
+		{"Code", []byte{0, 1, 0, 1, 0, 0, 0, 1, 0xB1, 0, 0, 0, 1, 0, 9, 0, 0, 0, 2, 0, 0}, 21},
+		// an annotation with an int element and an array of one enum constant
+		{"RuntimeVisibleAnnotations", []byte{0, 1, 0, 4, 0, 2, 0, 5, 'I', 0, 6, 0, 7, '[', 0, 1, 'e', 0, 8, 0, 9}, 21},
+		// same_frame, append_frame with an int local, and full_frame with an Object on the stack
+		{"StackMapTable", []byte{0, 3, 3, 252, 0, 4, 1, 255, 0, 9, 0, 0, 0, 1, 7, 0, 2}, 17},
+		{"StackMapTable", []byte{0, 1, 200}, -1},                                // reserved frame type
+		{"AnnotationDefault", []byte{'x', 0, 1}, -1},                            // undefined tag
+		{"RuntimeVisibleTypeAnnotations", []byte{0, 1, 0x13, 0, 0, 1, 0, 0}, 8}, // field type, empty path
+	}
+	for _, test := range tests {
+		size, known := attributeSize(test.name, test.content)
+		if !known || size != test.size {
+			t.Errorf("Expected %s attribute %v to take %d bytes, got %d (known: %v)",
+				test.name, test.content, test.size, size, known)
+		}
+	}
+	if _, known := attributeSize("ScalaSig", []byte{5, 0, 0}); known {
+		t.Error("Expected ScalaSig not to be a known attribute")
+	}
+}
+
+// parseTestClassAttribute parses the class attribute with the given name and
+// contents, and returns the error and what was written to stderr
+func parseTestClassAttribute(name string, length byte, content []byte) (error, string) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	klass := ParsedClass{className: "test/Lying"}
+	klass.cpIndex = append(klass.cpIndex, cpEntry{}, cpEntry{UTF8, 0})
+	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{name})
+	klass.cpCount = 2
+	klass.attribCount = 1
+
+	// the leading dummy byte is there b/c the fetch routine starts 1 byte after pos
+	bytes := append([]byte{00, 00, 01, 00, 00, 00, length}, content...)
+	bytes = append(bytes, 00) // fetchAttribute() wants a byte after the attribute
+	_, err := parseClassAttributes(bytes, 0, &klass)
+
+	_ = w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stderr = normalStderr
+	return err, string(out)
+}
+
+func TestLyingAttributeLength(t *testing.T) {
+	// a NestMembers attribute with two members but the length of one
+	err, msg := parseTestClassAttribute("NestMembers", 4, []byte{0, 2, 0, 1})
+	if err == nil || !strings.Contains(msg,
+		"NestMembers attribute of class test/Lying has length 4, but its contents take 6 bytes") {
+		t.Errorf("Expected a ClassFormatError for the attribute's length, got %v: %s", err, msg)
+	}
+
+	err, msg = parseTestClassAttribute("SourceFile", 3, []byte{0, 1, 0})
+	if err == nil || !strings.Contains(msg, "SourceFile attribute of class test/Lying has length 3") {
+		t.Errorf("Expected a ClassFormatError for the attribute's length, got %v: %s", err, msg)
+	}
+}
+
+func TestUnknownAttributeSkipped(t *testing.T) {
+	err, msg := parseTestClassAttribute("ScalaSig", 3, []byte{5, 0, 0})
+	if err != nil || !strings.Contains(msg, "Skipping unknown attribute ScalaSig of class test/Lying") {
+		t.Errorf("Expected the unknown attribute to be skipped with a warning, got %v: %s", err, msg)
+	}
+}
@@ -8,8 +8,12 @@ package classloader
 
 import (
 	"errors"
+	"jacobin/jfr"
 	"jacobin/log"
+	"jacobin/messages"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -51,6 +55,9 @@ type Static struct {
 	ValueStr  string  // string
 	ValueFunc func()  // function pointer
 	CP        *CPool  // the constant pool for the class
+	Volatile  bool    // was the field declared volatile? (see statics.go)
+
+	volatileVal *int64 // the value of a volatile field, accessed only atomically
 }
 
 var MethAreaMutex sync.RWMutex // All additions or updates to Classes map come through this mutex
@@ -68,6 +75,21 @@ type ClData struct {
 	Bootstraps []BootstrapMethod
 	CP         CPool
 	Access     AccessFlags
+
+	methodSymbols map[Symbol]int // the index in Methods of each method (see symbols.go)
+	initialized   uint32         // 1 once the class is initialized (see IsInitialized())
+}
+
+// IsInitialized reports whether the class has been initialized. It's read atomically,
+// without a lock, since the class of every getstatic, putstatic, invokestatic, and new
+// is checked.
+func (d *ClData) IsInitialized() bool {
+	return atomic.LoadUint32(&d.initialized) == 1
+}
+
+// SetInitialized records that the class has been initialized
+func (d *ClData) SetInitialized() {
+	atomic.StoreUint32(&d.initialized, 1)
 }
 
 type CPool struct {
@@ -87,6 +109,8 @@ type CPool struct {
 	NameAndTypes   []NameAndTypeEntry
 	//	StringRefs     []uint16 // all StringRefs are converted into utf8Refs
 	Utf8Refs []string
+
+	nameAndTypeSymbols []Symbol // the symbol of each NameAndType (see symbols.go)
 }
 
 type AccessFlags struct {
@@ -149,7 +173,7 @@ type Attr struct {
 // the exception-related data for each exception in the Code attribute of a given method
 type CodeException struct {
 	StartPc   int    // first instruction covered by this exception (pc = program counter)
-	EndPc     int    // the last instruction covered by this exception
+	EndPc     int    // the first instruction after the ones covered by this exception
 	HandlerPc int    // the place in the method code that has the exception instructions
 	CatchType uint16 // the type of exception, index to CP, which must point a ClassFref entry
 }
@@ -231,8 +255,15 @@ type InvokeDynamicEntry struct { // type 18 (invokedynamic data)
 // entry as the Method it's returning.
 // func FetchMethodAndCP(class, meth string, methType string) (Method, *CPool, error) {
 func FetchMethodAndCP(class, meth string, methType string) (MTentry, error) {
-	methFQN := class + "." + meth + methType // FQN = fully qualified name
-	methEntry := MTable[methFQN]
+	return FetchMethod(class, SymbolFor(meth, methType))
+}
+
+// FetchMethod is FetchMethodAndCP() for the method with the symbol, as resolved from a CP
+// entry
+func FetchMethod(class string, sym Symbol) (MTentry, error) {
+	key := MTkey{Class: class, Sym: sym}
+	methFQN := key.String() // FQN = fully qualified name
+	methEntry := MTable[key]
 	if methEntry.Meth == nil { // method is not in the MTable, so find it and put it there
 		k := Classes[class]
 		if k.Status == 'I' { // class is being initialized by a loader, so wait
@@ -242,34 +273,46 @@ func FetchMethodAndCP(class, meth string, methType string) (MTentry, error) {
 
 		if k.Loader == "" { // if class is not found, the zero value struct is returned
 			// TODO: check superclasses if method not found
-			_ = log.Log("Could not find class: "+class, log.SEVERE)
+			_ = log.Log(messages.Text("JACOBIN-CL-0007", class), log.SEVERE)
 			return MTentry{}, errors.New("class not found")
 		}
 
-		// the class has been found (k) so now go down the list of methods until
-		// we find one that matches the name we're looking for. Then return that
-		// method along with a pointer to the CP
-		for i := 0; i < len(k.Data.Methods); i++ {
-			if k.Data.CP.Utf8Refs[k.Data.Methods[i].Name] == meth &&
-				k.Data.CP.Utf8Refs[k.Data.Methods[i].Desc] == methType {
-				m := k.Data.Methods[i]
-				jme := JmEntry{
-					accessFlags: m.AccessFlags,
-					MaxStack:    m.CodeAttr.MaxStack,
-					MaxLocals:   m.CodeAttr.MaxLocals,
-					Code:        m.CodeAttr.Code,
-					exceptions:  m.CodeAttr.Exceptions,
-					attribs:     m.CodeAttr.Attributes,
-					params:      m.Parameters,
-					deprecated:  m.Deprecated,
-					Cp:          &k.Data.CP,
+		// the class has been found (k) so now look up the method with the name and
+		// type we're looking for. Then return that method along with a pointer to the CP
+		if m := k.Data.MethodFor(sym); m != nil {
+			// native methods are implemented by Go functions in the native registry
+			if m.AccessFlags&ACC_NATIVE != 0 {
+				gm, found := LookupNative(methFQN)
+				if !found { // it may be in a JNI library loaded by System.loadLibrary()
+					gm, found = ResolveJNI(methFQN, m.AccessFlags&ACC_STATIC != 0)
 				}
-				MTable[methFQN] = MTentry{
-					Meth:  jme,
-					MType: 'J',
+				if !found {
+					_ = log.Log("java.lang.UnsatisfiedLinkError: "+methFQN, log.SEVERE)
+					return MTentry{}, errors.New("java.lang.UnsatisfiedLinkError: " + methFQN)
 				}
-				return MTentry{Meth: jme, MType: 'J'}, nil
+				gme := GmEntry{ParamSlots: gm.ParamSlots, Fu: gm.GFunction}
+				addEntry(&MTable, key, MTentry{Meth: gme, MType: 'G'})
+				jfr.MethodLinked(methFQN, true)
+				return MTentry{Meth: gme, MType: 'G'}, nil
+			}
+
+			jme := &JmEntry{
+				accessFlags: m.AccessFlags,
+				MaxStack:    m.CodeAttr.MaxStack,
+				MaxLocals:   m.CodeAttr.MaxLocals,
+				Code:        m.CodeAttr.Code,
+				Exceptions:  m.CodeAttr.Exceptions,
+				attribs:     m.CodeAttr.Attributes,
+				params:      m.Parameters,
+				deprecated:  m.Deprecated,
+				Cp:          &k.Data.CP,
+			}
+			MTable[key] = MTentry{
+				Meth:  jme,
+				MType: 'J',
 			}
+			jfr.MethodLinked(methFQN, false)
+			return MTentry{Meth: jme, MType: 'J'}, nil
 		}
 	} else { // we found the entry in the MTable
 		if methEntry.MType == 'J' {
@@ -281,16 +324,80 @@ func FetchMethodAndCP(class, meth string, methType string) (MTentry, error) {
 
 	// if we got this far, the class was not found
 
-	if meth == "main" { // to be consistent withe the JDK, we print this peculiar error message when main() is missing
-		_ = log.Log("Error: Main method not found in class "+class+", please define the main method as:\n"+
-			"   public static void main(String[] args)", log.SEVERE)
+	if sym.Name() == "main" { // to be consistent withe the JDK, we print this peculiar error message when main() is missing
+		_ = log.Log(messages.Text("JACOBIN-CL-0008", class), log.SEVERE)
 	} else {
-		_ = log.Log("Found class: "+class+", but it did not contain method: "+meth, log.SEVERE)
+		_ = log.Log(messages.Text("JACOBIN-CL-0009", class, sym.Name()), log.SEVERE)
 	}
 
 	return MTentry{}, errors.New("method not found")
 }
 
+// FetchVirtualMethod finds the method that invokevirtual runs when the method with the
+// symbol is invoked on an object of the class: the method the class declares or, if it
+// declares none, the one that its nearest superclass declares (JVMS 5.4.6). At each
+// class, the MTable is checked first, so a Go method stands in for the Java one, as it
+// does in FetchMethodAndCP(). Only the classes that have been loaded are searched, along
+// with the JDK's exceptions in throwableSuperclasses; the superclass of an array class
+// is java/lang/Object. It returns the method and its declaring class.
+func FetchVirtualMethod(class string, sym Symbol) (MTentry, string, error) {
+	for c := class; c != ""; {
+		if mte := MTable[MTkey{Class: c, Sym: sym}]; mte.Meth != nil {
+			return mte, c, nil
+		}
+		if strings.HasPrefix(c, "[") {
+			c = "java/lang/Object"
+			continue
+		}
+
+		MethAreaMutex.RLock()
+		k, present := Classes[c]
+		MethAreaMutex.RUnlock()
+		if !present || k.Data == nil {
+			c = throwableSuperclasses[c] // a JDK exception's methods are those of Throwable
+			continue
+		}
+		if m := k.Data.MethodFor(sym); m != nil && m.AccessFlags&ACC_STATIC == 0 {
+			mte, err := FetchMethod(c, sym)
+			return mte, c, err
+		}
+		c = k.Data.Superclass
+	}
+	return MTentry{}, "", errors.New("method not found")
+}
+
+// CannotCast reports whether an object of the class is known not to be an instance of
+// the target class, which is so only if the target is a loaded class (not an interface)
+// and the superclasses of the object's class are all loaded and don't include it.
+// Casts that involve arrays, interfaces, or unloaded classes aren't checked.
+func CannotCast(class, target string) bool {
+	if class == target || target == "java/lang/Object" ||
+		strings.HasPrefix(class, "[") || strings.HasPrefix(target, "[") {
+		return false
+	}
+	MethAreaMutex.RLock()
+	defer MethAreaMutex.RUnlock()
+	if t, present := Classes[target]; !present || t.Data == nil || t.Data.Access.ClassIsInterface {
+		return false
+	}
+	for c := class; c != target; {
+		k, present := Classes[c]
+		if !present || k.Data == nil {
+			return c == "java/lang/Object"
+		}
+		if c = k.Data.Superclass; c == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassData returns the parsed class file of the named class, loading the class if it
+// hasn't been loaded yet. It returns nil if the class can't be loaded.
+func ClassData(className string) *ClData {
+	return classData(className)
+}
+
 // FetchUTF8stringFromCPEntryNumber fetches the UTF8 string using the CP entry number
 // for that string in the designated ClData.CP. Returns "" on error.
 func FetchUTF8stringFromCPEntryNumber(cp *CPool, entry uint16) string {
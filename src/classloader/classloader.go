@@ -10,12 +10,14 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/gob"
-	"errors"
 	"fmt"
+	"io"
+	"jacobin/agent"
 	"jacobin/globals"
+	"jacobin/jfr"
 	"jacobin/log"
+	"jacobin/messages"
 	"jacobin/util"
-	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -157,8 +159,9 @@ type bootstrapMethod struct {
 // cfe = class format error, which is the error thrown by the parser for most
 // of the errors arising from malformed bytecode. Prints out file and line# where
 // the call to cfe() occurred.
-func cfe(msg string) error {
-	errMsg := "Class Format Error: " + msg
+func cfe(code string, args ...interface{}) error {
+	e := messages.New(code, args...)
+	e.Msg = messages.Text("JACOBIN-CL-0001", e.Msg)
 
 	// get the filename and line# of the function where the error occurred
 	// implementation note: Caller(0) would be this function. (1) is the
@@ -169,23 +172,20 @@ func cfe(msg string) error {
 	if ok {
 		fn := runtime.FuncForPC(pc)
 		fileName, fileLine := fn.FileLine(pc)
-		errMsg = errMsg + "\n  detected by file: " + filepath.Base(fileName) +
-			", line: " + strconv.Itoa(fileLine)
+		e.Msg = e.Msg + "\n" + messages.Text("JACOBIN-CL-0002", filepath.Base(fileName), fileLine)
 	}
-	log.Log(errMsg, log.SEVERE)
-	return errors.New(errMsg)
+	log.Log(e.Msg, log.SEVERE)
+	return e
 }
 
 // LoadBaseClasses loads a basic set of classes that are specified in the file
-// classes\baseclasslist.txt, which is found in JACOBIN_HOME. It's similar to
+// classes/baseclasslist.txt, which is found in JACOBIN_HOME. It's similar to
 // classlist file in the JDK, except shorter (for the nonce)
 func LoadBaseClasses(global *globals.Globals) {
-	classList := global.JacobinHome + "classes\\baseclasslist.txt"
-	file, err := os.Open(classList)
+	classList := filepath.Join(global.JacobinHome, "classes", "baseclasslist.txt")
+	file, err := openFile(classList)
 	if err != nil {
-		log.Log("Did not find baseclasslist.txt in JACOBIN_HOME ("+classList+")",
-			log.WARNING)
-		file.Close()
+		log.Log(messages.Text("JACOBIN-CL-0006", classList), log.WARNING)
 	} else {
 		defer file.Close()
 
@@ -193,7 +193,7 @@ func LoadBaseClasses(global *globals.Globals) {
 		for scanner.Scan() {
 			rawName := scanner.Text()
 			name := util.ConvertInternalClassNameToFilename(rawName)
-			name = globals.JacobinHome() + "classes\\" + name
+			name = filepath.Join(globals.JacobinHome(), "classes", name)
 			LoadClassFromFile(BootstrapCL, name)
 			// LoadReferencedClasses(BootstrapCL, rawName)
 		}
@@ -242,10 +242,12 @@ func LoadFromLoaderChannel(LoaderChannel <-chan string) {
 		if strings.HasPrefix(name, "java/") || strings.HasPrefix(name, "jdk/") ||
 			strings.HasPrefix(name, "javax/") || strings.HasPrefix(name, "sun/") {
 			name = util.ConvertInternalClassNameToFilename(name)
-			name = globals.JacobinHome() + "classes\\" + name
+			name = filepath.Join(globals.JacobinHome(), "classes", name)
 			LoadClassFromFile(BootstrapCL, name)
-		} else {
-			LoadClassFromFile(AppCL, name)
+		} else if _, err := LoadClassFromFile(AppCL, appClassFile(name)); err != nil {
+			MethAreaMutex.Lock() // remove the entry, so that anything waiting for the load stops waiting
+			delete(Classes, name)
+			MethAreaMutex.Unlock()
 		}
 		// println("loading from channel: " + name)
 	}
@@ -266,38 +268,61 @@ func LoadClassFromNameOnly(name string) error {
 	}
 	err := insert(name, eKI)
 
+	className := name
 	if strings.HasPrefix(name, "java/") || strings.HasPrefix(name, "jdk/") ||
 		strings.HasPrefix(name, "javax/") || strings.HasPrefix(name, "sun/") {
 		name = util.ConvertInternalClassNameToFilename(name)
-		name = globals.JacobinHome() + "classes\\" + name
+		name = filepath.Join(globals.JacobinHome(), "classes", name)
 		_, err = LoadClassFromFile(BootstrapCL, name)
 	} else {
-		_, err = LoadClassFromFile(AppCL, name)
+		_, err = LoadClassFromFile(AppCL, appClassFile(name))
+	}
+	if err != nil { // remove the entry, so that anything waiting for the load stops waiting
+		MethAreaMutex.Lock()
+		delete(Classes, className)
+		MethAreaMutex.Unlock()
 	}
 	return err
 }
 
+// appClassFile returns the file of the named application class: for a nested class,
+// the file next to its outer class's, if it's there (see nestedClasses.go); otherwise,
+// the file on the class path or, if it isn't found there, the name itself, which is
+// then read as a file
+func appClassFile(name string) string {
+	if file, found := nestedClassFile(name); found {
+		return file
+	}
+	if file, found := ClassFileOnClassPath(name); found {
+		return file
+	}
+	return name
+}
+
 // LoadClassFromFile first canonicalizes the filename, checks whether
 // the class is already loaded, and if not, then parses the class and loads it.
 // Returns the class's internal name and error, if any.
 func LoadClassFromFile(cl Classloader, filename string) (string, error) {
-	rawBytes, err := os.ReadFile(filename)
+	rawBytes, err := readClassBytes(filename)
 	if err != nil {
-		log.Log("Error: could not find or load main class "+filename+". Exiting.", log.SEVERE)
+		log.Log(messages.Text("JACOBIN-CL-0003", filename, filename), log.SEVERE)
 		return "", fmt.Errorf("java.lang.classNotFoundException")
 	}
 
 	log.Log(filename+" read", log.FINE)
 
 	fullyParsedClass, err := parse(rawBytes)
+	if e, ok := err.(*messages.Error); ok && isClassVersionError(e) {
+		return "", err // the UnsupportedClassVersionError has been shown, as the JDK shows it
+	}
 	if err != nil {
-		log.Log("error parsing "+filename+". Exiting.", log.SEVERE)
+		log.Log(messages.Text("JACOBIN-CL-0004", filename), log.SEVERE)
 		return "", fmt.Errorf("parsing error")
 	}
 
-	// format check the class
-	if formatCheckClass(&fullyParsedClass) != nil {
-		log.Log("error format-checking "+filename+". Exiting.", log.SEVERE)
+	// format check the class, unless its bytes have passed the check before
+	if formatCheckOnce(&fullyParsedClass, rawBytes) != nil {
+		log.Log(messages.Text("JACOBIN-CL-0005", filename), log.SEVERE)
 		return "", fmt.Errorf("format-checking error")
 	}
 	log.Log("Class "+fullyParsedClass.className+" has been format-checked.", log.FINEST)
@@ -308,11 +333,51 @@ func LoadClassFromFile(cl Classloader, filename string) (string, error) {
 		Loader: cl.Name,
 		Data:   &classToPost,
 	}
+	classLoadedFrom(fullyParsedClass.className, filename)
 	insert(fullyParsedClass.className, eKF)
 
 	return fullyParsedClass.className, nil
 }
 
+// jarEntrySeparator separates a JAR from the name of an entry in it, as in
+// app.jar!/com/example/Main.class, which is how java.net.URL names JAR entries
+const jarEntrySeparator = "!/"
+
+// ClassNameInFile returns the name, such as com/example/Hello, of the class in the
+// class file
+func ClassNameInFile(filename string) (string, error) {
+	rawBytes, err := readClassBytes(filename)
+	if err != nil {
+		return "", err
+	}
+	parsed, err := parse(rawBytes)
+	if err != nil {
+		return "", err
+	}
+	return parsed.className, nil
+}
+
+// readClassBytes returns the contents of a class file, which is either a file or,
+// if its name has the form app.jar!/com/example/Main.class, an entry in a JAR
+func readClassBytes(filename string) ([]byte, error) {
+	sep := strings.Index(filename, jarEntrySeparator)
+	if sep < 0 {
+		return readFile(filename)
+	}
+
+	z, closer, err := openZip(filename[:sep])
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	f, err := z.Open(filename[sep+len(jarEntrySeparator):])
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
 // insert the fully parsed class into the method area (exec.Classes)
 func insert(name string, klass Klass) error {
 	MethAreaMutex.Lock()
@@ -321,10 +386,17 @@ func insert(name string, klass Klass) error {
 
 	if klass.Status == 'F' || klass.Status == 'V' || klass.Status == 'L' {
 		log.Log("Class: "+klass.Data.Name+", loader: "+klass.Loader, log.CLASS)
+		jfr.ClassLoaded(klass.Data.Name, klass.Loader)
+		agent.ClassLoaded(klass.Data.Name, klass.Loader)
+		ClassPrepared(name)
 	}
 	return nil
 }
 
+// ClassPrepared is called when a class has been loaded, on the goroutine that loaded it.
+// The debugging agent sets it, to tell the debugger (see debugger.go in main).
+var ClassPrepared = func(name string) {}
+
 // load the parse class into a form suitable for posting to the method area (which is
 // exec.Classes. This mostly involves copying the data, converting most indexes to uint16
 // and removing some fields we needed in parsing, but which are no longer required.
@@ -341,6 +413,7 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 	if len(fullyParsedClass.fields) > 0 {
 		for i := 0; i < len(fullyParsedClass.fields); i++ {
 			kdf := Field{}
+			kdf.AccessFlags = fullyParsedClass.fields[i].accessFlags
 			kdf.Name = uint16(fullyParsedClass.fields[i].name)
 			kdf.Desc = uint16(fullyParsedClass.fields[i].description)
 			if len(fullyParsedClass.fields[i].attributes) > 0 {
@@ -577,7 +650,7 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 
 	if len(fullyParsedClass.utf8Refs) > 0 {
 		for i := 0; i < len(fullyParsedClass.utf8Refs); i++ {
-			kd.CP.Utf8Refs = append(kd.CP.Utf8Refs, fullyParsedClass.utf8Refs[i].content)
+			kd.CP.Utf8Refs = append(kd.CP.Utf8Refs, internString(fullyParsedClass.utf8Refs[i].content))
 		}
 	}
 
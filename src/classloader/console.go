@@ -0,0 +1,32 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "unicode/utf8"
+
+// Console output. Strings are written to System.out and System.err in UTF-8, which
+// Unix terminals expect. A Windows console instead shows bytes in its code page (often
+// 437 or 1252), so non-ASCII text comes out garbled. On Windows, then, InitConsole()
+// switches the console's output code page to UTF-8 for the run, and System.out and
+// System.err write to a console with WriteConsoleW(), which takes UTF-16 and so doesn't
+// depend on the code page at all (see console_windows.go). Output that's redirected to
+// a file or a pipe is written as UTF-8 on all platforms.
+
+// completeUTF8 returns the length of the part of b that doesn't end in an incomplete
+// UTF-8 sequence. A write of a string's bytes can be split, as by PrintStream.write(int),
+// and the rest of the sequence then comes with the next write.
+func completeUTF8(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
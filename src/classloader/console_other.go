@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io"
+	"os"
+)
+
+// InitConsole prepares the console for output. Terminals here take UTF-8 as it is.
+func InitConsole() {}
+
+// RestoreConsole undoes InitConsole()
+func RestoreConsole() {}
+
+// consoleOutput returns the writer for the standard stream (1 or 2)
+func consoleOutput(fd int) io.Writer {
+	if fd == 2 {
+		return os.Stderr
+	}
+	return os.Stdout
+}
@@ -0,0 +1,30 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+func TestCompleteUTF8(t *testing.T) {
+	euro := []byte("€") // three bytes
+	for _, test := range []struct {
+		b    []byte
+		want int
+	}{
+		{[]byte{}, 0},
+		{[]byte("abc"), 3},
+		{append([]byte("a"), euro...), 4},
+		{append([]byte("a"), euro[:1]...), 1},
+		{append([]byte("a"), euro[:2]...), 1},
+		{[]byte{'a', 0xFF}, 2}, // an invalid byte isn't held back
+		{[]byte{'a', 0x82}, 2}, // nor is a stray continuation byte
+		{[]byte("é€")[:4], 2},  // é, then the start of €
+	} {
+		if got := completeUTF8(test.b); got != test.want {
+			t.Errorf("Expected %d for % x, got %d", test.want, test.b, got)
+		}
+	}
+}
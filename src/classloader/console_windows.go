@@ -0,0 +1,115 @@
+//go:build windows
+// +build windows
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"unicode/utf16"
+)
+
+// The Windows console. The code page functions aren't in the syscall package, so
+// they're called through kernel32.dll.
+
+const cpUTF8 = 65001
+
+var (
+	kernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procGetConsoleOutputCP = kernel32.NewProc("GetConsoleOutputCP")
+	procSetConsoleOutputCP = kernel32.NewProc("SetConsoleOutputCP")
+
+	savedOutputCP uintptr // the code page to restore, or 0 if it wasn't changed
+)
+
+// InitConsole sets the console's output code page to UTF-8, so that the output of
+// programs that write bytes directly, such as child processes, is shown correctly too
+func InitConsole() {
+	cp, _, _ := procGetConsoleOutputCP.Call()
+	if cp == 0 || cp == cpUTF8 { // 0 means there's no console
+		return
+	}
+	if ok, _, _ := procSetConsoleOutputCP.Call(cpUTF8); ok != 0 {
+		savedOutputCP = cp
+	}
+}
+
+// RestoreConsole puts back the console's original code page, which the console keeps
+// after the program ends
+func RestoreConsole() {
+	if savedOutputCP != 0 {
+		_, _, _ = procSetConsoleOutputCP.Call(savedOutputCP)
+		savedOutputCP = 0
+	}
+}
+
+// consoleWriter writes UTF-8 to a console as UTF-16, holding back an incomplete
+// UTF-8 sequence at the end of a write until the rest of it arrives
+type consoleWriter struct {
+	mutex   sync.Mutex
+	handle  syscall.Handle
+	pending []byte
+}
+
+var consoleWriters = struct {
+	sync.Mutex
+	byFd [3]*consoleWriter
+}{}
+
+// consoleOutput returns the writer for the standard stream (1 or 2): a consoleWriter
+// if the stream is a console, and otherwise the stream itself
+func consoleOutput(fd int) io.Writer {
+	f := os.Stdout
+	if fd == 2 {
+		f = os.Stderr
+	}
+	handle := syscall.Handle(f.Fd())
+	var mode uint32
+	if syscall.GetConsoleMode(handle, &mode) != nil {
+		return f // redirected to a file or a pipe
+	}
+
+	consoleWriters.Lock()
+	defer consoleWriters.Unlock()
+	if w := consoleWriters.byFd[fd]; w != nil && w.handle == handle {
+		return w
+	}
+	w := &consoleWriter{handle: handle}
+	consoleWriters.byFd[fd] = w
+	return w
+}
+
+func (w *consoleWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	b := append(w.pending, p...)
+	n := completeUTF8(b)
+	w.pending = append([]byte(nil), b[n:]...)
+
+	// invalid UTF-8 bytes become U+FFFD, as they do in a Java string
+	text := utf16.Encode([]rune(string(b[:n])))
+	for len(text) > 0 {
+		chunk := text
+		if len(chunk) > 8192 { // the console rejects very large writes
+			chunk = chunk[:8192]
+		}
+		var written uint32
+		if err := syscall.WriteConsole(w.handle, &chunk[0], uint32(len(chunk)), &written, nil); err != nil {
+			return 0, err
+		}
+		if written == 0 {
+			return 0, io.ErrShortWrite
+		}
+		text = text[written:]
+	}
+	return len(p), nil
+}
@@ -12,6 +12,7 @@ import (
 	"jacobin/log"
 	"math"
 	"os"
+	"strconv"
 )
 
 // this file contains the parser for the constant pool and the verifier.
@@ -47,6 +48,14 @@ type cpEntry struct {
 	slot      int
 }
 
+// the number of bytes that follow the tag of each type of CP entry, or that precede the
+// content of a UTF8 entry
+var cpEntrySizes = map[int]int{
+	UTF8: 2, IntConst: 4, FloatConst: 4, LongConst: 8, DoubleConst: 8, ClassRef: 2,
+	StringConst: 2, FieldRef: 4, MethodRef: 4, Interface: 4, NameAndType: 4,
+	MethodHandle: 3, MethodType: 2, Dynamic: 4, InvokeDynamic: 4, Module: 2, Package: 2,
+}
+
 // parse the CP entries in the class file and put references to their data in klass.cpIndex,
 // where appropriate. (Some entries, such as invokeDynamic, Module, etc. require other actions
 // performed here. Returns location through last parsed byte and any error.
@@ -72,12 +81,18 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 	var i int
 	for i = 1; i <= klass.cpCount-1; { // i starts at 1 due to the dummy entry at CP[0]
 		pos += 1
+		if pos >= len(rawBytes) || pos+cpEntrySizes[int(rawBytes[pos])] >= len(rawBytes) {
+			return pos, cfe("JACOBIN-CL-0140") // the class file ends in the middle of the CP
+		}
 		entryType := int(rawBytes[pos])
 		switch entryType {
 		case UTF8:
 			var content string
 			length, _ := intFrom2Bytes(rawBytes, pos+1)
 			pos += 2
+			if pos+length >= len(rawBytes) {
+				return pos, cfe("JACOBIN-CL-0140")
+			}
 			if length == 0 {
 				content = ""
 			} else {
@@ -111,6 +126,9 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 			pos += 8
 			longValue := int64((highBytes << 32) + lowBytes)
 			klass.longConsts = append(klass.longConsts, longValue)
+			if i == klass.cpCount-1 { // there's no room for the second slot
+				return pos, cfe("JACOBIN-CL-0147", strconv.Itoa(i+1))
+			}
 			klass.cpIndex[i] = cpEntry{LongConst, len(klass.longConsts) - 1}
 			i++
 			// long ints take up two slots in the CP, of which the second is just a dummy slot.
@@ -125,6 +143,9 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 			bits := binary.BigEndian.Uint64(bytes)
 			doubleValue := math.Float64frombits(bits)
 			klass.doubles = append(klass.doubles, doubleValue)
+			if i == klass.cpCount-1 { // there's no room for the second slot
+				return pos, cfe("JACOBIN-CL-0147", strconv.Itoa(i+1))
+			}
 			klass.cpIndex[i] = cpEntry{DoubleConst, len(klass.doubles) - 1}
 			i++
 			// doubles take up two slots in the CP, of which the second is just a dummy slot.
@@ -214,7 +235,7 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 			i += 1
 		case Module:
 			if klass.javaVersion < 53 {
-				return pos, cfe("Java module record requires Java 9 or later version")
+				return pos, cfe("JACOBIN-CL-0010")
 			}
 			nameIndex, _ := intFrom2Bytes(rawBytes, pos+1)
 			moduleName, err := fetchUTF8string(klass, nameIndex)
@@ -222,8 +243,7 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 				break // error message will already have been shown
 			}
 			if klass.moduleName != "" {
-				return pos + 2, cfe("Class " + klass.className + " has two module names: " + klass.moduleName +
-					" and " + moduleName)
+				return pos + 2, cfe("JACOBIN-CL-0011", klass.className, klass.moduleName, moduleName)
 			}
 			klass.moduleName = moduleName
 			klass.cpIndex[i] = cpEntry{Module, nameIndex}
@@ -231,7 +251,7 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 			i += 1
 		case Package:
 			if klass.javaVersion < 53 {
-				return pos, cfe("Java package entry requires Java 9 or later version")
+				return pos, cfe("JACOBIN-CL-0012")
 			}
 			nameIndex, _ := intFrom2Bytes(rawBytes, pos+1)
 			packageName, err := fetchUTF8string(klass, nameIndex)
@@ -239,8 +259,7 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 				break // error message will already have been shown
 			}
 			if klass.packageName != "" {
-				return pos + 2, cfe("Class " + klass.className + " has two package names: " + klass.packageName +
-					" and " + packageName)
+				return pos + 2, cfe("JACOBIN-CL-0013", klass.className, klass.packageName, packageName)
 			}
 			klass.packageName = packageName
 			klass.cpIndex[i] = cpEntry{Package, nameIndex}
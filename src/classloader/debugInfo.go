@@ -0,0 +1,82 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "encoding/binary"
+
+// The debugging information in a method's Code attribute: the LineNumberTable, which
+// maps bytecode positions to source lines, and the LocalVariableTable, which names the
+// local variables (javac writes it only with -g). They're kept as raw attributes when
+// the class is parsed and decoded here when a debugger asks for them. See:
+// https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.7.12
+
+// LineNumber is an entry of the LineNumberTable: the source line that starts at the pc
+type LineNumber struct {
+	StartPc int
+	Line    int
+}
+
+// LocalVariable is an entry of the LocalVariableTable: the variable in the local slot
+// while the pc is in [StartPc, StartPc+Length)
+type LocalVariable struct {
+	StartPc int
+	Length  int
+	Name    string
+	Desc    string
+	Slot    int
+}
+
+// LineNumbers returns the method's line number table, in the order of the entries in
+// its LineNumberTable attributes. The second return value is false if it has none.
+func LineNumbers(cp *CPool, code *CodeAttrib) ([]LineNumber, bool) {
+	var lines []LineNumber
+	entries, found := codeSubAttributes(cp, code, "LineNumberTable", 4)
+	for _, entry := range entries {
+		lines = append(lines, LineNumber{StartPc: int(binary.BigEndian.Uint16(entry)),
+			Line: int(binary.BigEndian.Uint16(entry[2:]))})
+	}
+	return lines, found
+}
+
+// LocalVariables returns the method's local variable table. The second return value
+// is false if it has none.
+func LocalVariables(cp *CPool, code *CodeAttrib) ([]LocalVariable, bool) {
+	var vars []LocalVariable
+	entries, found := codeSubAttributes(cp, code, "LocalVariableTable", 10)
+	for _, entry := range entries {
+		vars = append(vars, LocalVariable{
+			StartPc: int(binary.BigEndian.Uint16(entry)),
+			Length:  int(binary.BigEndian.Uint16(entry[2:])),
+			Name:    FetchUTF8stringFromCPEntryNumber(cp, binary.BigEndian.Uint16(entry[4:])),
+			Desc:    FetchUTF8stringFromCPEntryNumber(cp, binary.BigEndian.Uint16(entry[6:])),
+			Slot:    int(binary.BigEndian.Uint16(entry[8:])),
+		})
+	}
+	return vars, found
+}
+
+// codeSubAttributes returns the entries of the Code attribute's sub-attributes with the
+// name, and whether there are any such attributes. (A method can have several of each.)
+// The tables are a u2 count followed by entries of entrySize bytes. A table that is
+// shorter than its count says is cut off at its last whole entry.
+func codeSubAttributes(cp *CPool, code *CodeAttrib, name string, entrySize int) ([][]byte, bool) {
+	var entries [][]byte
+	found := false
+	for _, a := range code.Attributes {
+		if int(a.AttrName) >= len(cp.Utf8Refs) || cp.Utf8Refs[a.AttrName] != name || len(a.AttrContent) < 2 {
+			continue
+		}
+		found = true
+		count := int(binary.BigEndian.Uint16(a.AttrContent))
+		content := a.AttrContent[2:]
+		for i := 0; i < count && len(content) >= entrySize; i++ {
+			entries = append(entries, content[:entrySize])
+			content = content[entrySize:]
+		}
+	}
+	return entries, found
+}
@@ -0,0 +1,67 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+// the CP has the attribute names in Utf8Refs, and the variable names and types as
+// UTF8 entries 1 and 2
+func debugInfoCP() *CPool {
+	return &CPool{
+		CpIndex:  []CpEntry{{}, {Type: UTF8, Slot: 2}, {Type: UTF8, Slot: 3}},
+		Utf8Refs: []string{"LineNumberTable", "LocalVariableTable", "count", "I"},
+	}
+}
+
+func TestLineNumbers(t *testing.T) {
+	code := &CodeAttrib{Attributes: []Attr{
+		{AttrName: 0, AttrContent: []byte{0, 2, 0, 0, 0, 7, 0, 4, 0, 8}},
+		{AttrName: 0, AttrContent: []byte{0, 1, 0, 9, 0, 10}},
+	}}
+	lines, found := LineNumbers(debugInfoCP(), code)
+	if !found {
+		t.Fatal("expected the line numbers to be found")
+	}
+	want := []LineNumber{{0, 7}, {4, 8}, {9, 10}}
+	if len(lines) != len(want) {
+		t.Fatalf("expected %v, got %v", want, lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, lines)
+		}
+	}
+}
+
+func TestLineNumbersAbsentOrEmpty(t *testing.T) {
+	if _, found := LineNumbers(debugInfoCP(), &CodeAttrib{}); found {
+		t.Error("expected no line numbers in a method without a LineNumberTable")
+	}
+
+	// an empty table is present, and a truncated one is cut off at its last whole entry
+	code := &CodeAttrib{Attributes: []Attr{{AttrName: 0, AttrContent: []byte{0, 0}},
+		{AttrName: 0, AttrContent: []byte{0, 2, 0, 1, 0, 2, 0}}}}
+	lines, found := LineNumbers(debugInfoCP(), code)
+	if !found || len(lines) != 1 || lines[0] != (LineNumber{1, 2}) {
+		t.Errorf("expected one line, found; got %v, %t", lines, found)
+	}
+}
+
+func TestLocalVariables(t *testing.T) {
+	code := &CodeAttrib{Attributes: []Attr{
+		{AttrName: 0, AttrContent: []byte{0, 1, 0, 0, 0, 1}},
+		{AttrName: 1, AttrContent: []byte{0, 1, 0, 2, 0, 12, 0, 1, 0, 2, 0, 3}},
+	}}
+	vars, found := LocalVariables(debugInfoCP(), code)
+	if !found || len(vars) != 1 {
+		t.Fatalf("expected one variable, got %v", vars)
+	}
+	want := LocalVariable{StartPc: 2, Length: 12, Name: "count", Desc: "I", Slot: 3}
+	if vars[0] != want {
+		t.Errorf("expected %v, got %v", want, vars[0])
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The file system that the class loaders read from: the class files, JARs, and resources
+// on the class path, and the base class list. It's the host's file system unless
+// SetFileSystem() replaces it, which is how a VM without one (such as in a browser,
+// under js/wasm) or a sandboxed VM gets its classes. The files that Java code opens
+// itself, with java.io and java.nio, are always the host's.
+
+// classFiles is the replacement file system, or nil for the host's
+var classFiles fs.FS
+
+// SetFileSystem makes the class loaders read classes and resources from the file system,
+// in which the VM's paths (such as the entries of the class path) are looked up with
+// any leading / removed. A nil file system restores the host's.
+func SetFileSystem(fsys fs.FS) {
+	classFiles = fsys
+}
+
+// fsPath converts a VM path to the name of a file in classFiles
+func fsPath(name string) string {
+	p := strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+func openFile(name string) (fs.File, error) {
+	if classFiles == nil {
+		return os.Open(name)
+	}
+	return classFiles.Open(fsPath(name))
+}
+
+func statFile(name string) (fs.FileInfo, error) {
+	if classFiles == nil {
+		return os.Stat(name)
+	}
+	return fs.Stat(classFiles, fsPath(name))
+}
+
+func readFile(name string) ([]byte, error) {
+	if classFiles == nil {
+		return os.ReadFile(name)
+	}
+	return fs.ReadFile(classFiles, fsPath(name))
+}
+
+// openZip opens the JAR (or other zip file). The closer is closed when the caller is
+// done with the reader.
+func openZip(name string) (*zip.Reader, io.Closer, error) {
+	if classFiles == nil {
+		z, err := zip.OpenReader(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &z.Reader, z, nil
+	}
+	b, err := readFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	z, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, nil, err
+	}
+	return z, io.NopCloser(nil), nil
+}
+
+// MemoryFS is a file system held in memory, which maps the names of its files (as in
+// app/com/example/Main.class or lib/app.jar) to their contents. Its directories are
+// the ones the names imply.
+type MemoryFS map[string][]byte
+
+// Open implements fs.FS
+func (m MemoryFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if b, ok := m[name]; ok {
+		return &memFile{Reader: bytes.NewReader(b), info: memInfo{name: path.Base(name), size: int64(len(b))}}, nil
+	}
+	prefix := name + "/"
+	for file := range m {
+		if name == "." || strings.HasPrefix(file, prefix) {
+			return &memFile{Reader: bytes.NewReader(nil), info: memInfo{name: path.Base(name), dir: true}}, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// memFile is an open file (or directory) of a MemoryFS
+type memFile struct {
+	*bytes.Reader
+	info memInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+type memInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) ModTime() time.Time { return time.Time{} }
+func (i memInfo) IsDir() bool        { return i.dir }
+func (i memInfo) Sys() interface{}   { return nil }
+func (i memInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
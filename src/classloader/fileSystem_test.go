@@ -0,0 +1,69 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"jacobin/globals"
+	"os"
+	"testing"
+)
+
+func TestMemoryFS(t *testing.T) {
+	m := MemoryFS{"app/com/example/Main.class": []byte{0xCA, 0xFE}}
+	if b, err := fs.ReadFile(m, "app/com/example/Main.class"); err != nil || !bytes.Equal(b, []byte{0xCA, 0xFE}) {
+		t.Errorf("Expected the file's contents, got %v, %v", b, err)
+	}
+	for _, dir := range []string{".", "app", "app/com/example"} {
+		if info, err := fs.Stat(m, dir); err != nil || !info.IsDir() {
+			t.Errorf("Expected %s to be a directory, got %v", dir, err)
+		}
+	}
+	if _, err := m.Open("app/com/other"); err == nil {
+		t.Error("Expected a missing file not to be opened")
+	}
+	if _, err := m.Open("/app"); err == nil {
+		t.Error("Expected an invalid name not to be opened")
+	}
+}
+
+func TestClassPathInFileSystem(t *testing.T) {
+	var jar bytes.Buffer
+	z := zip.NewWriter(&jar)
+	w, _ := z.Create("com/example/Tool.class")
+	_, _ = w.Write([]byte{0xCA, 0xFE, 0xBA, 0xBE})
+	_ = z.Close()
+
+	SetFileSystem(MemoryFS{
+		"classes/com/example/Main.class": []byte{0xCA, 0xFE},
+		"lib/app.jar":                    jar.Bytes(),
+	})
+	defer SetFileSystem(nil)
+	saved, _ := globals.SystemProperties.Get("java.class.path")
+	defer globals.SystemProperties.Set("java.class.path", saved)
+	globals.SystemProperties.Set("java.class.path", "/classes"+string(os.PathListSeparator)+"lib/app.jar")
+
+	file, found := ClassFileOnClassPath("com/example/Main")
+	if !found {
+		t.Fatal("Expected the class file in the directory to be found")
+	}
+	if b, err := readClassBytes(file); err != nil || len(b) != 2 {
+		t.Errorf("Expected the class file's contents, got %v, %v", b, err)
+	}
+	file, found = ClassFileOnClassPath("com/example/Tool")
+	if !found || file != "lib/app.jar!/com/example/Tool.class" {
+		t.Fatalf("Expected the JAR entry, got %q", file)
+	}
+	if b, err := readClassBytes(file); err != nil || !bytes.Equal(b, []byte{0xCA, 0xFE, 0xBA, 0xBE}) {
+		t.Errorf("Expected the JAR entry's contents, got %v, %v", b, err)
+	}
+	if _, found := ClassFileOnClassPath("com/example/Missing"); found {
+		t.Error("Expected a missing class not to be found")
+	}
+}
@@ -7,6 +7,7 @@
 package classloader
 
 import (
+	"encoding/binary"
 	"errors"
 	"jacobin/log"
 	"strconv"
@@ -23,6 +24,8 @@ import (
 // 4) CP must fulfill all constraints. This is done in formatCheckConstantPool() below
 // 5) Fields must have valid names, classes, and descriptions. Partially done in
 //    the parsing, but entirely done in formatCheckFields() below
+// In addition, the invoke instructions must refer to the right kinds of method
+// references, which is checked in formatCheckInvokes() below
 func formatCheckClass(klass *ParsedClass) error {
 	if formatCheckConstantPool(klass) != nil {
 		return errors.New("") // whatever error occurs, the user will have been notified
@@ -36,9 +39,22 @@ func formatCheckClass(klass *ParsedClass) error {
 		return errors.New("") // whatever error occurs, the user will have been notified
 	}
 
+	if formatCheckInvokes(klass) != nil {
+		return errors.New("") // whatever error occurs, the user will have been notified
+	}
+
 	return formatCheckStructure(klass)
 }
 
+// cpEntryAt returns the CP entry at the index or, if the index is outside the CP, a dummy
+// entry, so that an invalid index fails the check of the entry's type
+func cpEntryAt(klass *ParsedClass, index int) cpEntry {
+	if index < 1 || index >= len(klass.cpIndex) {
+		return cpEntry{Dummy, 0}
+	}
+	return klass.cpIndex[index]
+}
+
 // validates that the CP fits all the requirements enumerated in:
 // https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.4
 // some of these checks were performed perforce in the parsing. Here, however,
@@ -47,12 +63,11 @@ func formatCheckClass(klass *ParsedClass) error {
 func formatCheckConstantPool(klass *ParsedClass) error {
 	cpSize := klass.cpCount
 	if len(klass.cpIndex) != cpSize {
-		return cfe("Error in size of constant pool discovered in format check." +
-			"Expected: " + strconv.Itoa(cpSize) + ", got: " + strconv.Itoa(len(klass.cpIndex)))
+		return cfe("JACOBIN-CL-0014", strconv.Itoa(cpSize), strconv.Itoa(len(klass.cpIndex)))
 	}
 
 	if klass.cpIndex[0].entryType != Dummy {
-		return cfe("Missing dummy entry in first slot of constant pool")
+		return cfe("JACOBIN-CL-0015")
 	}
 
 	for j := 1; j < cpSize; j++ {
@@ -64,15 +79,13 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// * No byte may lie in the range (byte)0xf0 to (byte)0xff
 			whichUtf8 := entry.slot
 			if whichUtf8 < 0 || whichUtf8 >= len(klass.utf8Refs) {
-				return cfe("CP entry #" + strconv.Itoa(j) + "points to invalid UTF8 entry: " +
-					strconv.Itoa(whichUtf8))
+				return cfe("JACOBIN-CL-0016", strconv.Itoa(j), strconv.Itoa(whichUtf8))
 			}
 			utf8string := klass.utf8Refs[whichUtf8].content
 			utf8bytes := []byte(utf8string)
 			for _, char := range utf8bytes {
 				if char == 0x00 || (char >= 0xf0 && char <= 0xff) {
-					return cfe("UTF8 string for CP entry #" + strconv.Itoa(j) +
-						" contains an invalid character")
+					return cfe("JACOBIN-CL-0017", strconv.Itoa(j))
 				}
 			}
 		case IntConst:
@@ -80,16 +93,14 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// that there is a valid entry pointed to in intConsts
 			whichInt := entry.slot
 			if whichInt < 0 || whichInt >= len(klass.intConsts) {
-				return cfe("Integer at CP entry #" + strconv.Itoa(j) +
-					" points to an invalid entry in CP intConsts")
+				return cfe("JACOBIN-CL-0018", strconv.Itoa(j))
 			}
 		case FloatConst:
 			// there are complex bit patterns that can be enforced for floats, but
 			// for the nonce, we'll just make sure that the float index points to an actual value
 			whichFloat := entry.slot
 			if whichFloat < 0 || whichFloat >= len(klass.floats) {
-				return cfe("Float at CP entry #" + strconv.Itoa(j) +
-					" points to an invalid entry in CP floats")
+				return cfe("JACOBIN-CL-0019", strconv.Itoa(j))
 			}
 		case LongConst:
 			// there are complex bit patterns that can be enforced for longs, but for the
@@ -98,28 +109,24 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.4.5
 			whichLong := entry.slot
 			if whichLong < 0 || whichLong >= len(klass.longConsts) {
-				return cfe("Long constant at CP entry #" + strconv.Itoa(j) +
-					" points to an invalid entry in CP longConsts")
+				return cfe("JACOBIN-CL-0020", strconv.Itoa(j))
 			}
 
-			nextEntry := klass.cpIndex[j+1]
+			nextEntry := cpEntryAt(klass, j+1)
 			if nextEntry.entryType != Dummy {
-				return cfe("Missing dummy entry after long constant at CP entry#" +
-					strconv.Itoa(j))
+				return cfe("JACOBIN-CL-0021", strconv.Itoa(j))
 			}
 			j += 1
 		case DoubleConst:
 			// see the comments on the LongConst. They apply exactly to the following code.
 			whichDouble := entry.slot
 			if whichDouble < 0 || whichDouble >= len(klass.doubles) {
-				return cfe("Double constant at CP entry #" + strconv.Itoa(j) +
-					" points to an invalid entry in CP doubless")
+				return cfe("JACOBIN-CL-0022", strconv.Itoa(j))
 			}
 
-			nextEntry := klass.cpIndex[j+1]
+			nextEntry := cpEntryAt(klass, j+1)
 			if nextEntry.entryType != Dummy {
-				return cfe("Missing dummy entry after double constant at CP entry#" +
-					strconv.Itoa(j))
+				return cfe("JACOBIN-CL-0023", strconv.Itoa(j))
 			}
 			j += 1
 		case ClassRef:
@@ -127,16 +134,14 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// in the case of arrays, the UTF8 entry will describe the type and dimensions of the array
 			whichClassRef := entry.slot
 			if whichClassRef < 0 || whichClassRef >= len(klass.utf8Refs) {
-				return cfe("ClassRef at CP entry #" + strconv.Itoa(j) +
-					" points to an invalid entry in CP utf8Refs")
+				return cfe("JACOBIN-CL-0024", strconv.Itoa(j))
 			}
 		case StringConst:
 			// a StringConst holds only an index into the utf8Refs. so we check this.
 			// https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.4.3
 			whichString := entry.slot
 			if whichString < 0 || whichString >= len(klass.utf8Refs) {
-				return cfe("Constant String at CP entry #" + strconv.Itoa(j) +
-					" points to an invalid entry in CP utf8Refs")
+				return cfe("JACOBIN-CL-0025", strconv.Itoa(j))
 			}
 		case FieldRef:
 			// the requirements are that the class index points to a valid Class entry
@@ -147,25 +152,20 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// picks them up going through the CP.
 			whichFieldRef := entry.slot
 			if whichFieldRef < 0 || whichFieldRef >= len(klass.fieldRefs) {
-				return cfe("Field Ref at CP entry #" + strconv.Itoa(j) +
-					" points to an invalid entry in CP fieldRefs")
+				return cfe("JACOBIN-CL-0026", strconv.Itoa(j))
 			}
 			fieldRef := klass.fieldRefs[whichFieldRef]
 			classIndex := fieldRef.classIndex
-			class := klass.cpIndex[classIndex]
+			class := cpEntryAt(klass, classIndex)
 			if class.entryType != ClassRef ||
 				class.slot < 0 || class.slot >= len(klass.classRefs) {
-				return cfe("Field Ref at CP entry #" + strconv.Itoa(j) +
-					" has a class index that points to an invalid entry in ClassRefs. " +
-					strconv.Itoa(classIndex))
+				return cfe("JACOBIN-CL-0027", strconv.Itoa(j), strconv.Itoa(classIndex))
 			}
 
-			nameAndType := klass.cpIndex[fieldRef.nameAndTypeIndex]
+			nameAndType := cpEntryAt(klass, fieldRef.nameAndTypeIndex)
 			if nameAndType.entryType != NameAndType ||
 				nameAndType.slot < 0 || nameAndType.slot >= len(klass.nameAndTypes) {
-				return cfe("Field Ref at CP entry #" + strconv.Itoa(j) +
-					" has a nameAndType index that points to an invalid entry in nameAndTypes. " +
-					strconv.Itoa(fieldRef.nameAndTypeIndex))
+				return cfe("JACOBIN-CL-0028", strconv.Itoa(j), strconv.Itoa(fieldRef.nameAndTypeIndex))
 			}
 		case MethodRef:
 			// the MethodRef must have a class index that points to a Class_info entry
@@ -177,36 +177,28 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			methodRef := klass.methodRefs[whichMethodRef]
 
 			classIndex := methodRef.classIndex
-			class := klass.cpIndex[classIndex]
+			class := cpEntryAt(klass, classIndex)
 			if class.entryType != ClassRef ||
 				class.slot < 0 || class.slot >= len(klass.classRefs) {
-				return cfe("Method Ref at CP entry #" + strconv.Itoa(j) +
-					" holds an invalid class index: " +
-					strconv.Itoa(class.slot))
+				return cfe("JACOBIN-CL-0029", strconv.Itoa(j), strconv.Itoa(class.slot))
 			}
 
 			nAndTIndex := methodRef.nameAndTypeIndex
-			nAndT := klass.cpIndex[nAndTIndex]
+			nAndT := cpEntryAt(klass, nAndTIndex)
 			if nAndT.entryType != NameAndType ||
 				nAndT.slot < 0 || nAndT.slot >= len(klass.nameAndTypes) {
-				return cfe("Method Ref at CP entry #" + strconv.Itoa(j) +
-					" holds an invalid NameAndType index: " +
-					strconv.Itoa(nAndT.slot))
+				return cfe("JACOBIN-CL-0030", strconv.Itoa(j), strconv.Itoa(nAndT.slot))
 			}
 
 			nAndTentry := klass.nameAndTypes[nAndT.slot]
 			methodNameIndex := nAndTentry.nameIndex
 			name, err := fetchUTF8string(klass, methodNameIndex)
 			if err != nil {
-				return cfe("Method Ref (at CP entry #" + strconv.Itoa(j) +
-					") has a Name and Type entry does not have a name that is a valid UTF8 entry")
+				return cfe("JACOBIN-CL-0031", strconv.Itoa(j))
 			}
 
-			nameBytes := []byte(name)
-			if nameBytes[0] == '<' && name != "<init>" {
-				return cfe("Method Ref at CP entry #" + strconv.Itoa(j) +
-					" holds an NameAndType index to an entry with an invalid method name " +
-					name)
+			if strings.HasPrefix(name, "<") && name != "<init>" {
+				return cfe("JACOBIN-CL-0032", strconv.Itoa(j), name)
 			}
 		case Interface:
 			// the Interface entries are almost identical to the class entries (see above),
@@ -216,20 +208,17 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			interfaceRef := klass.interfaceRefs[whichInterface]
 
 			classIndex := interfaceRef.classIndex
-			class := klass.cpIndex[classIndex]
+			class := cpEntryAt(klass, classIndex)
 			if class.entryType != ClassRef ||
 				class.slot < 0 || class.slot >= len(klass.classRefs) {
-				return cfe("Interface Ref at CP entry #" + strconv.Itoa(j) +
-					" holds an invalid class index: " + strconv.Itoa(class.slot))
+				return cfe("JACOBIN-CL-0033", strconv.Itoa(j), strconv.Itoa(class.slot))
 			}
 
 			clRef := klass.classRefs[class.slot]
 			// utfIndex, err := fetchUTF8slot(klass, clRef)
 			_, err := fetchUTF8slot(klass, clRef)
 			if err != nil {
-				return cfe("Interface Ref at CP entry #" + strconv.Itoa(j) +
-					" holds an invalid UTF8 index to the interface name: " +
-					strconv.Itoa(clRef))
+				return cfe("JACOBIN-CL-0034", strconv.Itoa(j), strconv.Itoa(clRef))
 			}
 
 			/* TODO: REVISIT: with java.lang.String the following code works OK
@@ -254,12 +243,10 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			*/
 
 			nAndTIndex := interfaceRef.nameAndTypeIndex
-			nAndT := klass.cpIndex[nAndTIndex]
+			nAndT := cpEntryAt(klass, nAndTIndex)
 			if nAndT.entryType != NameAndType ||
 				nAndT.slot < 0 || nAndT.slot >= len(klass.nameAndTypes) {
-				return cfe("Method Ref at CP entry #" + strconv.Itoa(j) +
-					" holds an invalid NameAndType index: " +
-					strconv.Itoa(nAndT.slot))
+				return cfe("JACOBIN-CL-0030", strconv.Itoa(j), strconv.Itoa(nAndT.slot))
 			}
 		case NameAndType:
 			// a NameAndType entry points to two UTF8 entries: name and description. Consult
@@ -269,29 +256,23 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.3.2-200
 			whichNandT := entry.slot
 			if whichNandT < 0 || whichNandT >= len(klass.nameAndTypes) {
-				return cfe("Name and Type at CP entry #" + strconv.Itoa(j) +
-					" points to an invalid entry in CP nameAndTypes")
+				return cfe("JACOBIN-CL-0035", strconv.Itoa(j))
 			}
 
 			nAndTentry := klass.nameAndTypes[whichNandT]
 			_, err := fetchUTF8string(klass, nAndTentry.nameIndex)
 			if err != nil {
-				return cfe("Name and Type at CP entry #" + strconv.Itoa(j) +
-					" has a name index that points to an invalid UTF8 entry: " +
-					strconv.Itoa(nAndTentry.nameIndex))
+				return cfe("JACOBIN-CL-0036", strconv.Itoa(j), strconv.Itoa(nAndTentry.nameIndex))
 			}
 
 			desc, err2 := fetchUTF8string(klass, nAndTentry.descriptorIndex)
 			if err2 != nil {
-				return cfe("Name and Type at CP entry #" + strconv.Itoa(j) +
-					" has a description index that points to an invalid UTF8 entry: " +
-					strconv.Itoa(nAndTentry.nameIndex))
+				return cfe("JACOBIN-CL-0037", strconv.Itoa(j), strconv.Itoa(nAndTentry.nameIndex))
 			}
 
 			err = validateFieldDesc(desc)
 			if err != nil {
-				return cfe("Name and Type at CP entry #" + strconv.Itoa(j) +
-					" has an invalid description string: " + desc)
+				return cfe("JACOBIN-CL-0038", strconv.Itoa(j), desc)
 			}
 		case MethodHandle:
 			// Method handles have complex validation logic. It's entirely enforced here. See:
@@ -304,50 +285,41 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			mhe := klass.methodHandles[whichMethHandle]
 			refKind := mhe.referenceKind
 			if refKind < 1 || refKind > 9 {
-				return cfe("MethodHandle at CP entry #" + strconv.Itoa(j) +
-					" has an invalid reference kind: " + strconv.Itoa(refKind))
+				return cfe("JACOBIN-CL-0039", strconv.Itoa(j), strconv.Itoa(refKind))
 			}
 			refIndex := mhe.referenceIndex
 
 			switch refKind {
 			// if refKind is 1-4, the reference_index must point to a fieldRef
 			case 1, 2, 3, 4:
-				if klass.cpIndex[refIndex].entryType != FieldRef {
-					return cfe("MethodHandle at CP entry #" + strconv.Itoa(j) +
-						" has an reference kind between 1-4 ( " + strconv.Itoa(refKind) +
-						") which does not point to a FieldRef")
+				if cpEntryAt(klass, refIndex).entryType != FieldRef {
+					return cfe("JACOBIN-CL-0040", strconv.Itoa(j), strconv.Itoa(refKind))
 				}
 			// if refKind is 5 or 8, the reference_index must point to a methodRef
 			case 5, 8:
-				if klass.cpIndex[refIndex].entryType != MethodRef {
-					return cfe("MethodHandle at CP entry #" + strconv.Itoa(j) +
-						" has an reference kind between of 5 or 8 ( " + strconv.Itoa(refKind) +
-						") which does not point to a MethodRef")
+				if cpEntryAt(klass, refIndex).entryType != MethodRef {
+					return cfe("JACOBIN-CL-0041", strconv.Itoa(j), strconv.Itoa(refKind))
 				}
 			case 6, 7:
 				// if refKind is 6 or 7, the reference_index must point to a methodRef or if the
 				// class version # is >= 52, it can point to an Interface. To make the logic readable,
 				// we test for the positive here, rather than the negative as in the other cases
-				if klass.cpIndex[refIndex].entryType == MethodRef ||
-					(klass.javaVersion >= 52 && klass.cpIndex[refIndex].entryType == Interface) {
+				if cpEntryAt(klass, refIndex).entryType == MethodRef ||
+					(klass.javaVersion >= 52 && cpEntryAt(klass, refIndex).entryType == Interface) {
 					break
 				} else {
-					return cfe("MethodHandle at CP entry #" + strconv.Itoa(j) +
-						" has an reference kind between of 6 or 7 ( " + strconv.Itoa(refKind) +
-						") which does not point to a MethodRef or in Java version 52 or later " +
-						"does not point to an Interface.")
+					return cfe("JACOBIN-CL-0042", strconv.Itoa(j), strconv.Itoa(refKind))
 				}
 			case 9:
-				if klass.cpIndex[refIndex].entryType != Interface {
-					return cfe("MethodHandle at CP entry #" + strconv.Itoa(j) +
-						" has an reference kind  of 9 which does not point to an interface")
+				if cpEntryAt(klass, refIndex).entryType != Interface {
+					return cfe("JACOBIN-CL-0043", strconv.Itoa(j))
 				}
 			}
 
 			// get the class name pointed to by the MethodRef pointed to by the MethodHandle
 			var methodName string
 			var err error
-			if klass.cpIndex[refIndex].entryType == MethodRef {
+			if cpEntryAt(klass, refIndex).entryType == MethodRef {
 				methodName, _, _, err = resolveCPmethodRef(refIndex, klass)
 				if err != nil {
 					return errors.New("") // the error messsage is already displayed
@@ -356,21 +328,18 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 
 			// if the reference_kind is 5-7 the name of the method pointed to
 			// by the nameAndType entry in the method handle cannot be <init> or <clinit>
-			if refKind >= 5 && refKind <= 7 && klass.cpIndex[refIndex].entryType == MethodRef {
-				methRefIndex := klass.cpIndex[refIndex].slot
+			if refKind >= 5 && refKind <= 7 && cpEntryAt(klass, refIndex).entryType == MethodRef {
+				methRefIndex := cpEntryAt(klass, refIndex).slot
 				if methRefIndex < 0 || methRefIndex >= len(klass.methodRefs) {
-					return cfe("Reference index for MethodHandle at CP entry #" + strconv.Itoa(j) +
-						" points to an invalid MethodRef: " + strconv.Itoa(methRefIndex))
+					return cfe("JACOBIN-CL-0044", strconv.Itoa(j), strconv.Itoa(methRefIndex))
 				}
 
 				if methodName == "<init>" || methodName == "<clinit>" {
-					return cfe("Invalid class name for MethodHandle at CP entry #" + strconv.Itoa(j) +
-						" : " + methodName)
+					return cfe("JACOBIN-CL-0045", strconv.Itoa(j), methodName)
 				}
 			} else if refKind == 8 {
 				if methodName != "<init>" {
-					return cfe("Class name for MethodHandle at CP entry #" + strconv.Itoa(j) +
-						" should be <init>, but is: " + methodName)
+					return cfe("JACOBIN-CL-0046", strconv.Itoa(j), methodName)
 				}
 			}
 
@@ -382,16 +351,13 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.4.9
 			whichMethType := entry.slot
 			mte := klass.methodTypes[whichMethType]
-			utf8 := klass.cpIndex[mte]
+			utf8 := cpEntryAt(klass, mte)
 			if utf8.entryType != UTF8 || utf8.slot < 0 || utf8.slot > len(klass.utf8Refs)-1 {
-				return cfe("MethodType at CP entry #" + strconv.Itoa(j) +
-					" has an invalid description index: " + strconv.Itoa(utf8.slot))
+				return cfe("JACOBIN-CL-0047", strconv.Itoa(j), strconv.Itoa(utf8.slot))
 			}
 			methType := klass.utf8Refs[utf8.slot]
 			if !strings.HasPrefix(methType.content, "(") {
-				return cfe("MethodType at CP entry #" + strconv.Itoa(j) +
-					" does not point to a type that starts with an open parenthesis. Got: " +
-					methType.content)
+				return cfe("JACOBIN-CL-0048", strconv.Itoa(j), methType.content)
 			}
 		case Dynamic:
 			// Like InvokeDynamic, Dynamic is a unique kind of entry. The first field,
@@ -404,45 +370,38 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// the descriptor in the nameAndType points to a field.
 			whichDyn := entry.slot
 			if whichDyn >= len(klass.dynamics) {
-				return cfe("The dynamic entry at CP[" + strconv.Itoa(j) + "] " +
-					"points to a non-existent dynamic slot: " + strconv.Itoa(entry.slot))
+				return cfe("JACOBIN-CL-0049", strconv.Itoa(j), strconv.Itoa(entry.slot))
 			}
 			dyn := klass.dynamics[whichDyn]
 
 			bootstrap := dyn.bootstrapIndex
-			if bootstrap >= klass.bootstrapCount {
-				return cfe("The boostrap index in dynamic at CP[" + strconv.Itoa(j) +
-					"] is invalid: " + strconv.Itoa(bootstrap))
+			if bootstrap >= klass.bootstrapCount || bootstrap >= len(klass.bootstraps) {
+				return cfe("JACOBIN-CL-0050", strconv.Itoa(j), strconv.Itoa(bootstrap))
 			}
 
 			// just trying to access it to make sure it's actually there and accessible.
 			bse := klass.bootstraps[bootstrap]
 			if !(bse.methodRef > 0) {
-				return cfe("Invalid methodRef in bootstrap method[" + strconv.Itoa(bootstrap) + "]")
+				return cfe("JACOBIN-CL-0051", strconv.Itoa(bootstrap))
 			}
 
 			nAndT := dyn.nameAndType
 			if nAndT < 1 || nAndT > len(klass.cpIndex)-1 {
-				return cfe("The entry number into klass.dynamics[] at CP entry #" +
-					strconv.Itoa(j) + " is invalid: " + strconv.Itoa(nAndT))
+				return cfe("JACOBIN-CL-0052", strconv.Itoa(j), strconv.Itoa(nAndT))
 			}
 			if klass.cpIndex[nAndT].entryType != NameAndType {
-				return cfe("NameAndType index at CP entry #" + strconv.Itoa(j) +
-					" (dynamic) points to an entry that's not NameAndType: " +
-					strconv.Itoa(klass.cpIndex[nAndT].entryType))
+				return cfe("JACOBIN-CL-0053", strconv.Itoa(j), strconv.Itoa(klass.cpIndex[nAndT].entryType))
 			}
 
 			natSlot := klass.cpIndex[nAndT].slot
 			nat := klass.nameAndTypes[natSlot] // gets the actual nameAndType entry
 			desc, err := fetchUTF8string(klass, nat.descriptorIndex)
 			if err != nil {
-				return cfe("Descriptor in nameAndType entry of dynamic CP entry #" +
-					strconv.Itoa(j) + " is invalid: " + strconv.Itoa(nat.descriptorIndex))
+				return cfe("JACOBIN-CL-0054", strconv.Itoa(j), strconv.Itoa(nat.descriptorIndex))
 			}
 
 			if validateFieldDesc(desc) != nil {
-				return cfe("Descriptor in nameAndType entry of dynamic CP entry #" +
-					strconv.Itoa(j) + " is an invalid field descriptor: " + desc)
+				return cfe("JACOBIN-CL-0055", strconv.Itoa(j), desc)
 			}
 
 		case InvokeDynamic:
@@ -455,45 +414,38 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// will be checked later/earlier in this format check.
 			whichInvDyn := entry.slot
 			if whichInvDyn >= len(klass.invokeDynamics) {
-				return cfe("The invokeDynamic entry at CP[" + strconv.Itoa(j) + "] " +
-					"points to a non-existent invokeDynamic slot: " + strconv.Itoa(entry.slot))
+				return cfe("JACOBIN-CL-0056", strconv.Itoa(j), strconv.Itoa(entry.slot))
 			}
 			invDyn := klass.invokeDynamics[whichInvDyn]
 
 			bootstrap := invDyn.bootstrapIndex
-			if bootstrap >= klass.bootstrapCount {
-				return cfe("The boostrap index in InvokeDynamic at CP[" + strconv.Itoa(j) +
-					"] is invalid: " + strconv.Itoa(bootstrap))
+			if bootstrap >= klass.bootstrapCount || bootstrap >= len(klass.bootstraps) {
+				return cfe("JACOBIN-CL-0057", strconv.Itoa(j), strconv.Itoa(bootstrap))
 			}
 
 			// just trying to access it to make sure it's actually there and accessible.
 			bse := klass.bootstraps[bootstrap]
 			if !(bse.methodRef > 0) {
-				return cfe("Invalid methodRef in bootstrap method[" + strconv.Itoa(bootstrap) + "]")
+				return cfe("JACOBIN-CL-0051", strconv.Itoa(bootstrap))
 			}
 
 			nAndTslot := invDyn.nameAndType
 			if nAndTslot < 1 || nAndTslot > len(klass.cpIndex)-1 {
-				return cfe("The entry number into klass.InvokeDynamics[] at CP entry #" +
-					strconv.Itoa(j) + " is invalid: " + strconv.Itoa(nAndTslot))
+				return cfe("JACOBIN-CL-0058", strconv.Itoa(j), strconv.Itoa(nAndTslot))
 			}
 			if klass.cpIndex[nAndTslot].entryType != NameAndType {
-				return cfe("NameAndType index at CP entry #" + strconv.Itoa(j) +
-					" (InvokeDynamic) points to an entry that's not NameAndType: " +
-					strconv.Itoa(klass.cpIndex[nAndTslot].entryType))
+				return cfe("JACOBIN-CL-0059", strconv.Itoa(j), strconv.Itoa(klass.cpIndex[nAndTslot].entryType))
 			}
 
 			natSlot := klass.cpIndex[nAndTslot].slot
 			nat := klass.nameAndTypes[natSlot] // gets the actual nameAndType entry
 			desc, err := fetchUTF8string(klass, nat.descriptorIndex)
 			if err != nil {
-				return cfe("Descriptor in nameAndType entry of dynamic CP entry #" +
-					strconv.Itoa(j) + " is invalid: " + strconv.Itoa(nat.descriptorIndex))
+				return cfe("JACOBIN-CL-0054", strconv.Itoa(j), strconv.Itoa(nat.descriptorIndex))
 			}
 
 			if validateMethodDesc(desc) != nil {
-				return cfe("Descriptor in nameAndType entry of dynamic CP entry #" +
-					strconv.Itoa(j) + " is an invalid method descriptor: " + desc)
+				return cfe("JACOBIN-CL-0060", strconv.Itoa(j), desc)
 			}
 		case Module:
 			// if there's a module entry, the module name has already been fetched and
@@ -503,7 +455,7 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// Note: the test for minimum Java 9 version and the limit of at most one
 			// Module entry is enforced in the original CP parsing (see cpParser.go)
 			if !klass.classIsModule {
-				return cfe("Module CP entry must appear only in class with ACC_MODULE set.")
+				return cfe("JACOBIN-CL-0061")
 			}
 			if checkModuleName(klass.moduleName) != nil {
 				return errors.New("") // the error message will already have been displayed
@@ -516,7 +468,7 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// Note: the test for minimum Java 9 version and the limit of at most one
 			// Package entry is enforced in the original CP parsing (see cpParser.go)
 			if !klass.classIsModule {
-				return cfe("Package CP entry must appear only in class with ACC_MODULE set.")
+				return cfe("JACOBIN-CL-0062")
 			}
 
 			// packages have the same restrictions on the names as modules.
@@ -533,47 +485,34 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 
 // field entries consist of two string indexes, one of which points to the name, the other
 // to a string containing a description of the type. Here we grab the strings and check that
-// they fulfill the requirements: name doesn't start with a digit or contain a space, and the
-// type begins with one of the required letters/symbols
+// they fulfill the requirements: name is a valid unqualified name (see validateUnqualifiedName),
+// and the type begins with one of the required letters/symbols
 func formatCheckFields(klass *ParsedClass) error {
 	for i, f := range klass.fields {
 		// f.name points to a UTF8 entry in klass.utf8refs, so check it's in a valid range
 		if f.name < 0 || f.name >= len(klass.utf8Refs) {
-			return cfe("Invalid index to UTF8 string for field name in field #" + strconv.Itoa(i))
+			return cfe("JACOBIN-CL-0063", strconv.Itoa(i))
 		}
 		fName := klass.utf8Refs[f.name].content
 
 		// f.description points to a UTF8 entry in klass.utf8refs, so check it's in a valid range
 		if f.description < 0 || f.description >= len(klass.utf8Refs) {
-			return cfe("Invalid index for UTF8 string containing description of field " + fName)
+			return cfe("JACOBIN-CL-0064", fName)
 		}
 		fDesc := klass.utf8Refs[f.description].content
 
-		fNameBytes := []byte(fName)
-		if fNameBytes[0] >= '0' && fNameBytes[0] <= '9' {
-			return cfe("Invalid field name in format check (starts with a digit): " + fName)
+		// the name is checked against the rules of the JVM, not those of Java: it can begin
+		// with a digit and it can contain whitespace, $, and -, as the names generated by
+		// kotlinc and scalac do. It cannot contain . ; [ / or (
+		if len(fName) == 0 {
+			return cfe("JACOBIN-CL-0167", fName)
 		}
-
-		// check that there is no leading, trailing, or embedded whitespace
-		for _, c := range fNameBytes {
-			switch c {
-			case
-				'\u0009', // horizontal tab
-				'\u000A', // line feed
-				'\u000B', // vertical tab
-				'\u000C', // form feed
-				'\u000D', // carriage return
-				'\u0020', // space
-				'\u0085', // next line
-				'\u00A0': // no-break space
-				return cfe("Invalid field name in format check (contains whitespace): " + fName)
-			default:
-				continue
-			}
+		if !validateUnqualifiedName(fName, false) {
+			return cfe("JACOBIN-CL-0168", fName)
 		}
 
 		if validateFieldDesc(fDesc) != nil {
-			return cfe("Field " + fName + " has an invalid description string: " + fDesc)
+			return cfe("JACOBIN-CL-0067", fName, fDesc)
 		}
 	}
 	return nil
@@ -648,12 +587,12 @@ func validateUnqualifiedName(name string, method bool) bool {
 // see checkPackageName() for the same check (but different error messages)
 func checkModuleName(name string) error {
 	if name == "" {
-		return cfe("Expected a module/package name, but none was found.")
+		return cfe("JACOBIN-CL-0068")
 	}
 
 	bArr := []byte(name)
 	if bArr[0] == '@' || bArr[0] == ':' { // a @ or : must be escaped, so can't start name
-		return cfe("Module/Package name " + name + " contains an illegal character")
+		return cfe("JACOBIN-CL-0069", name)
 	}
 
 	invalidName := false
@@ -678,7 +617,7 @@ func checkModuleName(name string) error {
 			}
 		}
 		if invalidName {
-			return cfe("Module name " + name + " contains an illegal character")
+			return cfe("JACOBIN-CL-0070", name)
 		}
 	}
 	return nil
@@ -690,12 +629,12 @@ func checkModuleName(name string) error {
 // see checkModuleName() for the same check (but different error messages)
 func checkPackageName(name string) error {
 	if name == "" {
-		return cfe("Expected a package name, but none was found.")
+		return cfe("JACOBIN-CL-0071")
 	}
 
 	bArr := []byte(name)
 	if bArr[0] == '@' || bArr[0] == ':' { // a @ or : must be escaped, so can't start name
-		return cfe("Package name " + name + " contains an illegal character")
+		return cfe("JACOBIN-CL-0072", name)
 	}
 
 	invalidName := false
@@ -720,7 +659,7 @@ func checkPackageName(name string) error {
 			}
 		}
 		if invalidName {
-			return cfe("Package name " + name + " contains an illegal character")
+			return cfe("JACOBIN-CL-0072", name)
 		}
 	}
 	return nil
@@ -735,17 +674,14 @@ func formatCheckClassAttributes(klass *ParsedClass) error {
 	if len(klass.bootstraps) > 0 {
 		for i := 0; i < len(klass.bootstraps); i++ {
 			bsm := klass.bootstraps[i]
-			if klass.cpIndex[bsm.methodRef].entryType != MethodHandle {
-				return cfe("MethodRef in bootstrapMethod[" + strconv.Itoa(i) + "] in class " +
-					klass.className + "should but does not point to a MethodHandle")
+			if cpEntryAt(klass, bsm.methodRef).entryType != MethodHandle {
+				return cfe("JACOBIN-CL-0073", strconv.Itoa(i), klass.className)
 			}
 
 			if len(bsm.args) > 0 {
 				for j := 0; j < len(bsm.args); j++ {
 					if !validateItemIsLodable(klass, bsm.args[j]) {
-						return cfe("Boostrap method argument[" + strconv.Itoa(j) + "] in class " +
-							klass.className + " bootstrap method #[" + strconv.Itoa(i) + "] " +
-							"should be but is not a loadable constant")
+						return cfe("JACOBIN-CL-0074", strconv.Itoa(j), klass.className, strconv.Itoa(i))
 					}
 				}
 			}
@@ -772,32 +708,134 @@ func validateItemIsLodable(klass *ParsedClass, arg int) bool {
 	return true
 }
 
+// the invoke instructions and the kinds of CP entries they can refer to (JVMS 4.9.1).
+// invokestatic and invokespecial can refer to an interface method, as for the static
+// and private methods of interfaces, only in classes of Java 8 (version 52) or later.
+const (
+	opInvokevirtual   = 0xB6
+	opInvokespecial   = 0xB7
+	opInvokestatic    = 0xB8
+	opInvokeinterface = 0xB9
+)
+
+// checks that the invoke instructions in the methods' code refer to method references:
+// invokevirtual to a MethodRef, invokeinterface to an Interface(MethodRef), and
+// invokestatic and invokespecial to either, subject to the class's version. Whether
+// the referenced class is in fact a class or an interface is checked when the
+// reference is resolved (see the interpreter).
+func formatCheckInvokes(klass *ParsedClass) error {
+	for _, m := range klass.methods {
+		code := m.codeAttr.code
+		for pc := 0; pc < len(code); {
+			length := instructionLength(code, pc)
+			if length == 0 || pc+length > len(code) {
+				break // a truncated instruction is caught by the verifier or interpreter
+			}
+			op := code[pc]
+			if op >= opInvokevirtual && op <= opInvokeinterface {
+				index := int(code[pc+1])<<8 | int(code[pc+2])
+				entryType := cpEntryAt(klass, index).entryType
+				var ok bool
+				switch op {
+				case opInvokevirtual:
+					ok = entryType == MethodRef
+				case opInvokeinterface:
+					ok = entryType == Interface
+				default:
+					ok = entryType == MethodRef || (klass.javaVersion >= 52 && entryType == Interface)
+				}
+				if !ok {
+					name, _ := fetchUTF8string(klass, m.name)
+					return cfe("JACOBIN-CL-0164", strconv.Itoa(index), klass.className, name, strconv.Itoa(pc))
+				}
+			}
+			pc += length
+		}
+	}
+	return nil
+}
+
+// instructionLengths are the lengths of the instructions, by opcode, that aren't one
+// byte long. tableswitch, lookupswitch, and wide have variable lengths.
+var instructionLengths = func() [256]int {
+	var lengths [256]int
+	for op := range lengths {
+		lengths[op] = 1
+	}
+	for _, op := range []int{0x10, 0x12, 0x15, 0x16, 0x17, 0x18, 0x19, 0x36, 0x37, 0x38, 0x39, 0x3A, 0xA9, 0xBC} {
+		lengths[op] = 2 // bipush, ldc, the loads and stores, ret, and newarray
+	}
+	for _, op := range []int{0x11, 0x13, 0x14, 0x84, 0xBB, 0xBD, 0xC0, 0xC1, 0xC6, 0xC7} {
+		lengths[op] = 3 // sipush, ldc_w, ldc2_w, iinc, new, anewarray, checkcast, instanceof, ifnull, and ifnonnull
+	}
+	for op := 0x99; op <= 0xA8; op++ {
+		lengths[op] = 3 // the ifs, gotos, and jsr
+	}
+	for op := 0xB2; op <= 0xB8; op++ {
+		lengths[op] = 3 // the field instructions and invokevirtual, invokespecial, and invokestatic
+	}
+	lengths[0xC5] = 4 // multianewarray
+	for _, op := range []int{0xB9, 0xBA, 0xC8, 0xC9} {
+		lengths[op] = 5 // invokeinterface, invokedynamic, goto_w, and jsr_w
+	}
+	return lengths
+}()
+
+// instructionLength returns the length in bytes of the instruction at pc in the code,
+// or 0 if the instruction runs past the end of the code
+func instructionLength(code []byte, pc int) int {
+	u4 := func(at int) (int, bool) {
+		if at+4 > len(code) {
+			return 0, false
+		}
+		return int(int32(binary.BigEndian.Uint32(code[at:]))), true
+	}
+	switch code[pc] {
+	case 0xAA: // tableswitch: padding, then default, low, high, and the offsets
+		at := pc + 4 - pc%4
+		low, okLow := u4(at + 4)
+		high, okHigh := u4(at + 8)
+		if !okLow || !okHigh || high < low {
+			return 0
+		}
+		return at + 12 + (high-low+1)*4 - pc
+	case 0xAB: // lookupswitch: padding, then default, the number of pairs, and the pairs
+		at := pc + 4 - pc%4
+		pairs, ok := u4(at + 4)
+		if !ok || pairs < 0 {
+			return 0
+		}
+		return at + 8 + pairs*8 - pc
+	case 0xC4: // wide
+		if pc+1 < len(code) && code[pc+1] == 0x84 {
+			return 6 // wide iinc
+		}
+		return 4
+	}
+	return instructionLengths[code[pc]]
+}
+
 // format checks of structural elements outside of CP and fields. For example,
 // checking that a count field holds the correct number, etc.
 func formatCheckStructure(klass *ParsedClass) error {
 	if klass.cpCount != len(klass.cpIndex) {
-		return cfe("CP count: " + strconv.Itoa(klass.cpCount) +
-			" is not equal to actual size of CP: " + strconv.Itoa(len(klass.cpIndex)))
+		return cfe("JACOBIN-CL-0075", strconv.Itoa(klass.cpCount), strconv.Itoa(len(klass.cpIndex)))
 	}
 
 	if klass.interfaceCount != len(klass.interfaces) {
-		return cfe("Expected " + strconv.Itoa(klass.interfaceCount) + " interfaces. Got: " +
-			strconv.Itoa(len(klass.interfaces)))
+		return cfe("JACOBIN-CL-0076", strconv.Itoa(klass.interfaceCount), strconv.Itoa(len(klass.interfaces)))
 	}
 
 	if klass.methodCount != len(klass.methods) {
-		return cfe("Expected " + strconv.Itoa(klass.methodCount) + " methods. Got: " +
-			strconv.Itoa(len(klass.methods)))
+		return cfe("JACOBIN-CL-0077", strconv.Itoa(klass.methodCount), strconv.Itoa(len(klass.methods)))
 	}
 
 	if klass.attribCount != len(klass.attributes) {
-		return cfe("Expected " + strconv.Itoa(klass.attribCount) + " class attributes. Got: " +
-			strconv.Itoa(len(klass.attributes)))
+		return cfe("JACOBIN-CL-0078", strconv.Itoa(klass.attribCount), strconv.Itoa(len(klass.attributes)))
 	}
 
 	if klass.bootstrapCount != len(klass.bootstraps) {
-		return cfe("Expected " + strconv.Itoa(klass.bootstrapCount) + " bootstrap methods. Got: " +
-			strconv.Itoa(len(klass.bootstraps)))
+		return cfe("JACOBIN-CL-0079", strconv.Itoa(klass.bootstrapCount), strconv.Itoa(len(klass.bootstraps)))
 	}
 
 	return nil
@@ -1573,8 +1573,8 @@ func TestModuleName(t *testing.T) {
 	os.Stdout = normalStdout
 }
 
-// field names in Java cannot begin with a digit and they cannot contain
-// whitespace. We check for both here.
+// field names in the JVM cannot be empty and they cannot contain . ; [ or /
+// (unlike Java, which also forbids an initial digit and whitespace). We check both here.
 func TestInvalidFieldNames(t *testing.T) {
 
 	globals.InitGlobals("test")
@@ -1596,7 +1596,7 @@ func TestInvalidFieldNames(t *testing.T) {
 	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 0})
 	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 1})
 
-	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"bad name"})
+	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"bad.name"})
 	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"B"})
 
 	klass.cpCount = 3
@@ -1611,14 +1611,23 @@ func TestInvalidFieldNames(t *testing.T) {
 
 	err := formatCheckFields(&klass)
 	if err == nil {
-		t.Error("Did not get expected error for field name with embedded space.")
+		t.Error("Did not get expected error for field name with embedded period.")
 	}
 
-	// now test a field name that begins with a digit
-	klass.utf8Refs[0] = utf8Entry{"99bottlesOfBeer"}
-	err = formatCheckFields(&klass)
-	if err == nil {
-		t.Error("Did not get expected error for field name starting with digit")
+	for _, name := range []string{"", "a;b", "a[]", "java/lang"} {
+		klass.utf8Refs[0] = utf8Entry{name}
+		if formatCheckFields(&klass) == nil {
+			t.Errorf("Did not get expected error for field name '%s'", name)
+		}
+	}
+
+	// names that Java doesn't allow, but the JVM does, and kotlinc and scalac generate
+	for _, name := range []string{"99bottlesOfBeer", "is red", "$$delegatedProperties",
+		"MODULE$", "$i$a$-let-Greeter$1"} {
+		klass.utf8Refs[0] = utf8Entry{name}
+		if err = formatCheckFields(&klass); err != nil {
+			t.Errorf("Got unexpected error for field name '%s': %v", name, err)
+		}
 	}
 
 	// restore stderr and stdout to what they were before
@@ -1874,3 +1883,60 @@ func TestLoadableItem(t *testing.T) {
 		t.Error("Valid index for loadable item returned an error")
 	}
 }
+
+// invokestatic can refer to an interface method only from Java 8 on, and
+// invokevirtual never can
+func TestCheckInvokes(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	// redirect stderr to avoid noisy output
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() {
+		_ = w.Close()
+		os.Stderr = normalStderr
+	}()
+
+	klass := ParsedClass{javaVersion: 52}
+	klass.cpIndex = []cpEntry{{}, {MethodRef, 0}, {Interface, 0}}
+	klass.utf8Refs = []utf8Entry{{"run"}}
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 0})
+	// a tableswitch and a wide iinc come before the invokes, to check their lengths
+	code := []byte{0x03, 0xAA, 0, 0, 0, 0, 0, 16, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 16,
+		0xC4, 0x84, 0, 1, 0, 1, 0xB6, 0, 1, 0xB8, 0, 2, 0xB9, 0, 2, 1, 0}
+	klass.methods = []method{{name: 3, codeAttr: codeAttrib{code: code}}}
+	if err := formatCheckInvokes(&klass); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	klass.javaVersion = 51
+	if formatCheckInvokes(&klass) == nil {
+		t.Error("Expected an error for invokestatic of an interface method in a Java 7 class")
+	}
+
+	code[27], code[28] = 0, 2 // invokevirtual of an interface method
+	klass.javaVersion = 52
+	if formatCheckInvokes(&klass) == nil {
+		t.Error("Expected an error for invokevirtual of an interface method")
+	}
+}
+
+func BenchmarkFormatCheck(b *testing.B) {
+	globals.InitGlobals("test")
+	log.Init()
+	for _, class := range benchCorpus(b) {
+		klass, err := parse(class.bytes)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(class.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := formatCheckClass(&klass); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
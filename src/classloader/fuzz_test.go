@@ -0,0 +1,78 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io/ioutil"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The fuzz targets of the parser and the format checker, which go test -fuzz feeds
+// with mutations of well-formed class files. A malformed class file must be refused
+// with a ClassFormatError, never with a panic. For example:
+//
+//	go test ./classloader -run XXX -fuzz FuzzParse -fuzztime 5m
+//
+// Without -fuzz, go test runs the targets on the seed corpus--the test classes in
+// testdata, HaveInterface.class, and a class with a large CP--and on the inputs in
+// testdata/fuzz, where go test -fuzz records those that failed. (Native fuzzing needs
+// Go 1.18.)
+
+func fuzzSeeds(f *testing.F) {
+	f.Add(haveInterfaceClass)
+	f.Add(bigCPClass(20))
+	for _, name := range []string{"Hello", "Hello2", "Hello3", "NanoPrint"} {
+		class, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", name+".class"))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(class)
+	}
+}
+
+// quietLogging sends the log, in which the ClassFormatErrors are reported, to nowhere
+// until the returned function is called
+func quietLogging(f *testing.F) func() {
+	globals.InitGlobals("test")
+	log.Init()
+	normalStderr := os.Stderr
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		f.Fatal(err)
+	}
+	os.Stderr = devNull
+	return func() {
+		os.Stderr = normalStderr
+		_ = devNull.Close()
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	fuzzSeeds(f)
+	defer quietLogging(f)()
+	f.Fuzz(func(t *testing.T, class []byte) {
+		_, _ = parse(class)
+	})
+}
+
+func FuzzFormatCheck(f *testing.F) {
+	fuzzSeeds(f)
+	defer quietLogging(f)()
+	f.Fuzz(func(t *testing.T, class []byte) {
+		klass, err := parse(class)
+		if err == nil {
+			_ = formatCheckClass(&klass)
+		}
+	})
+}
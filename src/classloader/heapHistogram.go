@@ -0,0 +1,81 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "sort"
+
+// ClassCount is a line of a class histogram: the number of objects of a class on the
+// heap and the bytes they take up
+type ClassCount struct {
+	Class     string // in java/lang/Object format
+	Instances int64
+	Bytes     int64
+}
+
+// objectHeaderSize is what each object is counted as taking up beyond its fields,
+// as with a 64-bit JVM's object header
+const objectHeaderSize = 16
+
+// ClassHistogram counts the objects on the heap by class, most bytes first. Since
+// the heap isn't collected, it counts every object that's been allocated. The sizes
+// are estimates: the header, 8 bytes per field, and the elements of arrays and the
+// characters of strings.
+func ClassHistogram() []ClassCount {
+	counts := make(map[string]*ClassCount)
+	for h, n := handle(1), heapObjects(); int(h) <= n; h++ {
+		obj := objectAt(h)
+		if obj == nil {
+			continue
+		}
+		c, present := counts[obj.Klass]
+		if !present {
+			c = &ClassCount{Class: obj.Klass}
+			counts[obj.Klass] = c
+		}
+		c.Instances++
+		c.Bytes += objectSize(obj)
+	}
+
+	histogram := make([]ClassCount, 0, len(counts))
+	for _, c := range counts {
+		histogram = append(histogram, *c)
+	}
+	sort.Slice(histogram, func(i, j int) bool {
+		if histogram[i].Bytes != histogram[j].Bytes {
+			return histogram[i].Bytes > histogram[j].Bytes
+		}
+		return histogram[i].Class < histogram[j].Class
+	})
+	return histogram
+}
+
+// HeapUsage returns the number of objects on the heap and the bytes they take up,
+// estimated as for ClassHistogram()
+func HeapUsage() (objects, bytes int64) {
+	for h, n := handle(1), heapObjects(); int(h) <= n; h++ {
+		if obj := objectAt(h); obj != nil {
+			objects++
+			bytes += objectSize(obj)
+		}
+	}
+	return objects, bytes
+}
+
+func objectSize(obj *Object) int64 {
+	size := int64(objectHeaderSize + 8*len(obj.Fields))
+	switch native := obj.Native.(type) {
+	case []byte:
+		size += int64(len(native))
+	case []uint16:
+		size += int64(2 * len(native))
+	case []int64:
+		size += int64(8 * len(native))
+	case string:
+		size += int64(len(native))
+	}
+	return size
+}
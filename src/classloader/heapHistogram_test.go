@@ -0,0 +1,51 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+func TestClassHistogram(t *testing.T) {
+	count := func(class string) ClassCount {
+		for _, c := range ClassHistogram() {
+			if c.Class == class {
+				return c
+			}
+		}
+		return ClassCount{Class: class}
+	}
+
+	before := count("test/Histogram")
+	NewObject("test/Histogram", 2)
+	NewObject("test/Histogram", 2)
+	after := count("test/Histogram")
+	if after.Instances-before.Instances != 2 || after.Bytes-before.Bytes != 2*(objectHeaderSize+16) {
+		t.Errorf("expected 2 more objects of %d bytes each, got %+v then %+v", objectHeaderSize+16, before, after)
+	}
+
+	before = count("[B")
+	NewByteArray(make([]byte, 100))
+	if after = count("[B"); after.Bytes-before.Bytes != objectHeaderSize+100 {
+		t.Errorf("expected the array's elements to be counted, got %+v then %+v", before, after)
+	}
+
+	histogram := ClassHistogram()
+	for i := 1; i < len(histogram); i++ {
+		if histogram[i].Bytes > histogram[i-1].Bytes {
+			t.Errorf("expected the histogram to be sorted by size, got %+v", histogram)
+		}
+	}
+}
+
+func TestHeapUsage(t *testing.T) {
+	objects, bytes := HeapUsage()
+	NewObject("test/Usage", 1)
+	moreObjects, moreBytes := HeapUsage()
+	if moreObjects-objects != 1 || moreBytes-bytes != objectHeaderSize+8 {
+		t.Errorf("expected 1 more object of %d bytes, got %d, %d then %d, %d", objectHeaderSize+8,
+			objects, bytes, moreObjects, moreBytes)
+	}
+}
@@ -0,0 +1,178 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"jacobin/globals"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// JAR manifests. A JAR's manifest (META-INF/MANIFEST.MF) can name other JARs and
+// directories in its Class-Path attribute, which is a space-separated list of URLs,
+// usually relative to the directory the JAR is in. As with the JDK, those entries are
+// part of the application class path: they're searched right after the JAR that names
+// them, their own manifests' Class-Path entries are followed in turn, and entries that
+// don't exist, or that are already on the class path, are skipped. Launchers for
+// large applications often rely on this, with -jar naming a small JAR whose manifest
+// lists the rest.
+
+// ReadManifest returns the main attributes of the JAR's manifest. A JAR without a
+// manifest has no attributes, which is not an error.
+func ReadManifest(jarPath string) (map[string]string, error) {
+	z, closer, err := openZip(jarPath)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	for _, f := range z.File {
+		if strings.EqualFold(f.Name, "META-INF/MANIFEST.MF") {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return parseManifest(rc)
+		}
+	}
+	return map[string]string{}, nil
+}
+
+// parseManifest reads the main section of a manifest, which ends at the first blank
+// line. Each attribute is on a line of the form Name: value, and a line that starts
+// with a space continues the previous line's value.
+func parseManifest(r io.Reader) (map[string]string, error) {
+	attrs := make(map[string]string)
+	lastName := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, " ") {
+			if lastName == "" {
+				return nil, errors.New("invalid manifest: continuation line without an attribute")
+			}
+			attrs[lastName] += line[1:]
+			continue
+		}
+		colon := strings.Index(line, ": ")
+		if colon <= 0 {
+			return nil, errors.New("invalid manifest line: " + line)
+		}
+		lastName = line[:colon]
+		attrs[lastName] = line[colon+2:]
+	}
+	return attrs, scanner.Err()
+}
+
+// manifestClassPath returns the paths of the existing files and directories named by
+// the JAR's Class-Path attribute
+func manifestClassPath(jarPath string) []string {
+	attrs, err := ReadManifest(jarPath)
+	if err != nil {
+		return nil
+	}
+	base := &url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Dir(jarPath)) + "/"}
+
+	var paths []string
+	for _, entry := range strings.Fields(attrs["Class-Path"]) {
+		ref, err := url.Parse(entry)
+		if err != nil {
+			continue
+		}
+		u := base.ResolveReference(ref)
+		if u.Scheme != "file" {
+			continue
+		}
+		path := filepath.FromSlash(u.Path)
+		if _, err := statFile(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// EffectiveClassPath returns the entries of the class path with the Class-Path
+// entries of the JARs on it added after the JARs that name them
+func EffectiveClassPath(classPath string) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	var add func(path string)
+	add = func(path string) {
+		key := path
+		if abs, err := filepath.Abs(path); err == nil {
+			key = abs
+		}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		paths = append(paths, path)
+		if strings.HasSuffix(strings.ToLower(path), ".jar") {
+			for _, entry := range manifestClassPath(path) {
+				add(entry)
+			}
+		}
+	}
+	for _, path := range filepath.SplitList(classPath) {
+		if path != "" {
+			add(path)
+		}
+	}
+	return paths
+}
+
+// AppClassPath returns the application class path: the modules resolved from the module
+// path, if any, then java.class.path, extended with the Class-Path entries of the JARs on it
+func AppClassPath() []string {
+	classPath, _ := globals.SystemProperties.Get("java.class.path")
+	return append(append([]string(nil), resolvedModules...), EffectiveClassPath(classPath)...)
+}
+
+// ClassFileOnClassPath returns the file of the named class (as in com/example/Main) on
+// the application class path, in the form that LoadClassFromFile() takes: a file, or an
+// entry in a JAR, as in app.jar!/com/example/Main.class. The first one found is returned.
+func ClassFileOnClassPath(name string) (string, bool) {
+	entry := name + ".class"
+	for _, path := range AppClassPath() {
+		info, err := statFile(path)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			file := filepath.Join(path, filepath.FromSlash(entry))
+			if info, err := statFile(file); err == nil && !info.IsDir() {
+				return file, true
+			}
+		} else if jarHasEntry(path, entry) {
+			return path + jarEntrySeparator + entry, true
+		}
+	}
+	return "", false
+}
+
+// jarHasEntry reports whether the JAR has an entry with the name
+func jarHasEntry(jarPath, name string) bool {
+	z, closer, err := openZip(jarPath)
+	if err != nil {
+		return false
+	}
+	defer closer.Close()
+	for _, f := range z.File {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeJar creates a JAR whose manifest has the given contents
+func writeJar(t *testing.T, path, manifest string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	z := zip.NewWriter(f)
+	w, _ := z.Create("META-INF/MANIFEST.MF")
+	_, _ = w.Write([]byte(manifest))
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	attrs, err := parseManifest(strings.NewReader("Manifest-Version: 1.0\r\n" +
+		"Main-Class: com.example.Main\r\n" +
+		"Class-Path: lib/first.jar lib/sec\r\n" +
+		" ond.jar\r\n" +
+		"\r\n" +
+		"Name: com/example/\r\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if attrs["Main-Class"] != "com.example.Main" || attrs["Class-Path"] != "lib/first.jar lib/second.jar" {
+		t.Errorf("Unexpected manifest attributes: %v", attrs)
+	}
+	if _, present := attrs["Name"]; present {
+		t.Error("Expected only the main section's attributes")
+	}
+}
+
+func TestEffectiveClassPath(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "jacobin-jars")
+	defer os.RemoveAll(dir)
+	_ = os.MkdirAll(filepath.Join(dir, "lib", "my classes"), 0755)
+	app := filepath.Join(dir, "app.jar")
+	first := filepath.Join(dir, "lib", "first.jar")
+	second := filepath.Join(dir, "lib", "second.jar")
+	other := filepath.Join(dir, "other.jar")
+
+	writeJar(t, app, "Manifest-Version: 1.0\nClass-Path: lib/first.jar lib/my%20classes/ lib/missing.jar\n")
+	writeJar(t, first, "Manifest-Version: 1.0\nClass-Path: second.jar ../app.jar\n")
+	writeJar(t, second, "Manifest-Version: 1.0\n")
+	writeJar(t, other, "Manifest-Version: 1.0\n")
+
+	paths := EffectiveClassPath(app + string(os.PathListSeparator) + other)
+	want := []string{app, first, second, filepath.Join(dir, "lib", "my classes"), other}
+	if len(paths) != len(want) {
+		t.Fatalf("Expected class path %v, got %v", want, paths)
+	}
+	for i := range want {
+		if filepath.Clean(paths[i]) != want[i] {
+			t.Errorf("Expected class path %v, got %v", want, paths)
+			break
+		}
+	}
+}
+
+func TestClassFileOnClassPath(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "jacobin-jars")
+	defer os.RemoveAll(dir)
+	classes := filepath.Join(dir, "classes")
+	_ = os.MkdirAll(filepath.Join(classes, "com", "example"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(classes, "com", "example", "Main.class"), []byte{0xCA, 0xFE}, 0644)
+	app := filepath.Join(dir, "app.jar")
+	f, _ := os.Create(app)
+	z := zip.NewWriter(f)
+	_, _ = z.Create("com/example/Tool.class")
+	_ = z.Close()
+	_ = f.Close()
+
+	saved, _ := globals.SystemProperties.Get("java.class.path")
+	defer globals.SystemProperties.Set("java.class.path", saved)
+	globals.SystemProperties.Set("java.class.path", classes+string(os.PathListSeparator)+app)
+
+	if file, found := ClassFileOnClassPath("com/example/Main"); !found ||
+		file != filepath.Join(classes, "com", "example", "Main.class") {
+		t.Errorf("Expected the class file in the directory, got %q", file)
+	}
+	if file, found := ClassFileOnClassPath("com/example/Tool"); !found || file != app+"!/com/example/Tool.class" {
+		t.Errorf("Expected the JAR entry, got %q", file)
+	}
+	if _, found := ClassFileOnClassPath("com/example/Missing"); found {
+		t.Error("Expected a missing class not to be found")
+	}
+}
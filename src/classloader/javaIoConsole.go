@@ -0,0 +1,191 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io"
+	"jacobin/globals"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+// java.io.Console. As in the JDK, System.console() returns the console only when the
+// VM is interactive (when both standard input and standard output are terminals);
+// otherwise it returns null. readPassword() turns off echoing on the terminal while
+// the password is typed (see terminal_unix.go).
+
+type javaConsole struct {
+	in    io.Reader
+	out   io.Writer
+	inFd  int // the file descriptor of the terminal input, for turning echoing off
+	mutex sync.Mutex
+}
+
+var consoleRef int64
+var consoleOnce sync.Once
+
+// consoleAvailable reports whether the VM has a console, which it doesn't if System.in
+// or System.out has been redirected by the program that embeds it
+var consoleAvailable = func() bool {
+	return stdinSource == nil && stdOut == nil && isTerminal(0) && isTerminal(1)
+}
+
+func newConsoleObject(c *javaConsole) int64 {
+	ref := NewObject("java/io/Console", 0)
+	GetObject(ref).Native = c
+	return ref
+}
+
+func consoleOf(ref int64) *javaConsole {
+	if obj := GetObject(ref); obj != nil {
+		if c, ok := obj.Native.(*javaConsole); ok {
+			return c
+		}
+	}
+	return nil
+}
+
+// readLine reads a line, without its line terminator. At end of file with nothing
+// read, the second return value is false. The input is read a byte at a time so
+// that nothing past the line is consumed, as System.in shares the input.
+func (c *javaConsole) readLine() (string, bool) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := c.in.Read(buf)
+		if n == 1 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+			continue
+		}
+		if err != nil {
+			if len(line) == 0 {
+				return "", false
+			}
+			break
+		}
+	}
+	return strings.TrimSuffix(string(line), "\r"), true
+}
+
+// prompt writes the formatted prompt, if there is one
+func (c *javaConsole) prompt(format, args int64) {
+	if format != 0 {
+		_, _ = io.WriteString(c.out, javaFormat(javaString(format), args))
+	}
+}
+
+func (c *javaConsole) readLineRef(format, args int64) int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.prompt(format, args)
+	line, ok := c.readLine()
+	if !ok {
+		return 0
+	}
+	return NewStringObject(line)
+}
+
+func (c *javaConsole) readPasswordRef(format, args int64) int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.prompt(format, args)
+	var line string
+	var ok bool
+	withEchoOff(c.inFd, func() { line, ok = c.readLine() })
+	_, _ = io.WriteString(c.out, "\n") // the user's newline wasn't echoed
+	if !ok {
+		return 0
+	}
+	return NewCharArray(utf16.Encode([]rune(line)))
+}
+
+// javaFormat does the formatting of Console.printf(), format(), readLine(), and
+// readPassword(). Only the %s, %n, and %% conversions are supported so far; any
+// argument is formatted with its toString().
+// TODO: implement the rest of java.util.Formatter
+func javaFormat(format string, argsRef int64) string {
+	args, _ := RefArrayFromRef(argsRef)
+	var sb strings.Builder
+	next := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			sb.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'n':
+			sep, _ := globals.SystemProperties.Get("line.separator")
+			sb.WriteString(sep)
+		case '%':
+			sb.WriteByte('%')
+		case 's', 'S', 'd':
+			arg := "null"
+			if next < len(args) {
+				arg = ObjectToString(args[next])
+			}
+			next++
+			if format[i] == 'S' {
+				arg = strings.ToUpper(arg)
+			}
+			sb.WriteString(arg)
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(format[i])
+		}
+	}
+	return sb.String()
+}
+
+func Load_Io_Console() map[string]GMeth {
+	cn := "java/io/Console"
+	fmtArgs := "(Ljava/lang/String;[Ljava/lang/Object;)"
+	addNative("java/lang/System.console()L"+cn+";", true, func() int64 {
+		if !consoleAvailable() {
+			return 0
+		}
+		consoleOnce.Do(func() {
+			consoleRef = newConsoleObject(&javaConsole{in: os.Stdin, out: os.Stdout, inFd: 0})
+		})
+		return consoleRef
+	})
+	addNative(cn+".readLine()Ljava/lang/String;", false, func(this int64) int64 {
+		return consoleOf(this).readLineRef(0, 0)
+	})
+	addNative(cn+".readLine"+fmtArgs+"Ljava/lang/String;", false, func(this, format, args int64) int64 {
+		return consoleOf(this).readLineRef(format, args)
+	})
+	addNative(cn+".readPassword()[C", false, func(this int64) int64 {
+		return consoleOf(this).readPasswordRef(0, 0)
+	})
+	addNative(cn+".readPassword"+fmtArgs+"[C", false, func(this, format, args int64) int64 {
+		return consoleOf(this).readPasswordRef(format, args)
+	})
+	for _, method := range []string{"printf", "format"} {
+		addNative(cn+"."+method+fmtArgs+"L"+cn+";", false, func(this, format, args int64) int64 {
+			_, _ = io.WriteString(consoleOf(this).out, javaFormat(javaString(format), args))
+			return this
+		})
+	}
+	addNative(cn+".flush()V", false, func(this int64) {
+		if f, ok := consoleOf(this).out.(*os.File); ok {
+			_ = f.Sync()
+		}
+	})
+	addNative(cn+".isTerminal()Z", false, func(this int64) bool {
+		return isTerminal(consoleOf(this).inFd)
+	})
+	addNative(cn+".charset()Ljava/nio/charset/Charset;", false, func(this int64) int64 {
+		return charsetObject(defaultCharset())
+	})
+	return MethodSignatures
+}
@@ -0,0 +1,93 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bytes"
+	"jacobin/globals"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf16"
+)
+
+func testConsole(input string) (int64, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return newConsoleObject(&javaConsole{in: strings.NewReader(input), out: out, inFd: -1}), out
+}
+
+func TestConsoleReadLine(t *testing.T) {
+	Load_Io_Console()
+	console, out := testConsole("first\r\nsecond\nlast")
+	for _, want := range []string{"first", "second", "last"} {
+		line := callNative(t, "java/io/Console.readLine()Ljava/lang/String;", console).(int64)
+		if javaString(line) != want {
+			t.Errorf("Expected %q, got %q", want, javaString(line))
+		}
+	}
+	if callNative(t, "java/io/Console.readLine()Ljava/lang/String;", console) != int64(0) {
+		t.Error("Expected null at end of input")
+	}
+
+	console, out = testConsole("Ada\n")
+	args := NewRefArray("java/lang/Object", []int64{NewStringObject("name")})
+	line := callNative(t, "java/io/Console.readLine(Ljava/lang/String;[Ljava/lang/Object;)Ljava/lang/String;",
+		console, NewStringObject("Your %s: "), args).(int64)
+	if javaString(line) != "Ada" || out.String() != "Your name: " {
+		t.Errorf("Unexpected line %q or prompt %q", javaString(line), out.String())
+	}
+}
+
+func TestConsoleReadPassword(t *testing.T) {
+	Load_Io_Console()
+	console, out := testConsole("s3cr€t\n")
+	pw := callNative(t, "java/io/Console.readPassword(Ljava/lang/String;[Ljava/lang/Object;)[C",
+		console, NewStringObject("Password: "), int64(0)).(int64)
+	chars, ok := CharArrayFromRef(pw)
+	if !ok || string(utf16.Decode(chars)) != "s3cr€t" {
+		t.Errorf("Expected the password as a char[], got %v", chars)
+	}
+	if out.String() != "Password: \n" {
+		t.Errorf("Expected the prompt and a newline, got %q", out.String())
+	}
+}
+
+func TestJavaFormat(t *testing.T) {
+	sep, _ := globals.SystemProperties.Get("line.separator")
+	args := NewRefArray("java/lang/Object", []int64{NewStringObject("a"), NewStringObject("b")})
+	if got := javaFormat("%s-%S 100%%%n", args); got != "a-B 100%"+sep {
+		t.Errorf("Unexpected formatting: %q", got)
+	}
+	if got := javaFormat("%s %s %s", args); got != "a b null" {
+		t.Errorf("Expected missing arguments to format as null, got %q", got)
+	}
+}
+
+func TestSystemConsoleNotInteractive(t *testing.T) {
+	Load_Io_Console()
+	saved := consoleAvailable
+	consoleAvailable = func() bool { return false }
+	defer func() { consoleAvailable = saved }()
+	if callNative(t, "java/lang/System.console()Ljava/io/Console;") != int64(0) {
+		t.Error("Expected System.console() to be null when not interactive")
+	}
+}
+
+func TestStdinFromEmbedder(t *testing.T) {
+	SetStdin(strings.NewReader("typed\n"))
+	stdinOnce = sync.Once{}
+	defer func() {
+		SetStdin(nil)
+		stdinOnce = sync.Once{}
+	}()
+	if consoleAvailable() {
+		t.Error("Expected no console when System.in is redirected")
+	}
+	if line, _ := stdinReader().ReadString('\n'); line != "typed\n" {
+		t.Errorf("Expected System.in to read the embedder's input, got %q", line)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// java.io.File objects are implemented in Go. As in the JDK, a File is just an
+// abstract pathname: it's held (unresolved and uncleaned) in the object's Native
+// field, and the file system is consulted only when a method asks about the file.
+
+type javaFile struct {
+	path string
+}
+
+// NewFileObject creates a java.io.File object for the path and returns the reference to it
+func NewFileObject(path string) int64 {
+	ref := NewObject("java/io/File", 0)
+	GetObject(ref).Native = &javaFile{path: path}
+	return ref
+}
+
+// filePath returns the pathname of the referenced File
+func filePath(ref int64) string {
+	if obj := GetObject(ref); obj != nil {
+		if f, ok := obj.Native.(*javaFile); ok {
+			return f.path
+		}
+	}
+	return ""
+}
+
+func fileInit(this int64, path string) {
+	if obj := GetObject(this); obj != nil {
+		obj.Native = &javaFile{path: path}
+	}
+}
+
+// childPath is the path of the File(parent, child) constructors
+func childPath(parent, child string) string {
+	if parent == "" {
+		return child
+	}
+	return strings.TrimRight(parent, string(os.PathSeparator)) + string(os.PathSeparator) + child
+}
+
+func Load_Io_File() map[string]GMeth {
+	addNative("java/io/File.<init>(Ljava/lang/String;)V", false, func(this, path int64) {
+		fileInit(this, javaString(path))
+	})
+	addNative("java/io/File.<init>(Ljava/lang/String;Ljava/lang/String;)V", false,
+		func(this, parent, child int64) {
+			if parent == 0 {
+				fileInit(this, javaString(child))
+			} else {
+				fileInit(this, childPath(javaString(parent), javaString(child)))
+			}
+		})
+	addNative("java/io/File.<init>(Ljava/io/File;Ljava/lang/String;)V", false,
+		func(this, parent, child int64) {
+			fileInit(this, childPath(filePath(parent), javaString(child)))
+		})
+
+	// pathname methods, which don't touch the file system (except getAbsolutePath())
+	addNative("java/io/File.getPath()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(filePath(this))
+	})
+	addNative("java/io/File.toString()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(filePath(this))
+	})
+	addNative("java/io/File.getName()Ljava/lang/String;", false, func(this int64) int64 {
+		path := filePath(this)
+		return NewStringObject(path[strings.LastIndex(path, string(os.PathSeparator))+1:])
+	})
+	addNative("java/io/File.getParent()Ljava/lang/String;", false, func(this int64) int64 {
+		path := filePath(this)
+		sep := strings.LastIndex(path, string(os.PathSeparator))
+		if sep < 0 {
+			return 0
+		}
+		if sep == 0 { // the parent is the root
+			sep = 1
+		}
+		return NewStringObject(path[:sep])
+	})
+	addNative("java/io/File.getAbsolutePath()Ljava/lang/String;", false, func(this int64) int64 {
+		abs, err := filepath.Abs(filePath(this))
+		if err != nil {
+			return NewStringObject(filePath(this))
+		}
+		return NewStringObject(abs)
+	})
+
+	// metadata methods. As in the JDK, these return false or 0 rather than throw
+	// when the file doesn't exist or can't be accessed.
+	addNative("java/io/File.exists()Z", false, func(this int64) bool {
+		_, err := os.Stat(filePath(this))
+		return err == nil
+	})
+	addNative("java/io/File.isFile()Z", false, func(this int64) bool {
+		info, err := os.Stat(filePath(this))
+		return err == nil && info.Mode().IsRegular()
+	})
+	addNative("java/io/File.isDirectory()Z", false, func(this int64) bool {
+		info, err := os.Stat(filePath(this))
+		return err == nil && info.IsDir()
+	})
+	addNative("java/io/File.canRead()Z", false, func(this int64) bool {
+		f, err := os.Open(filePath(this))
+		if err != nil {
+			return false
+		}
+		_ = f.Close()
+		return true
+	})
+	addNative("java/io/File.canWrite()Z", false, func(this int64) bool {
+		info, err := os.Stat(filePath(this))
+		return err == nil && info.Mode().Perm()&0222 != 0
+	})
+	addNative("java/io/File.length()J", false, func(this int64) int64 {
+		info, err := os.Stat(filePath(this))
+		if err != nil || info.IsDir() {
+			return 0
+		}
+		return info.Size()
+	})
+	addNative("java/io/File.lastModified()J", false, func(this int64) int64 {
+		info, err := os.Stat(filePath(this))
+		if err != nil {
+			return 0
+		}
+		return info.ModTime().UnixNano() / 1_000_000
+	})
+
+	// file system operations, which report failure by returning false (but createNewFile()
+	// throws an IOException for a failure other than the file's existing)
+	addNative("java/io/File.delete()Z", false, func(this int64) bool {
+		return os.Remove(filePath(this)) == nil
+	})
+	addNative("java/io/File.mkdir()Z", false, func(this int64) bool {
+		return os.Mkdir(filePath(this), 0777) == nil
+	})
+	addNative("java/io/File.mkdirs()Z", false, func(this int64) bool {
+		if _, err := os.Stat(filePath(this)); err == nil {
+			return false // as in the JDK, false if the directory already exists
+		}
+		return os.MkdirAll(filePath(this), 0777) == nil
+	})
+	addNative("java/io/File.createNewFile()Z", false, func(this int64) (bool, error) {
+		f, err := os.OpenFile(filePath(this), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+		if os.IsExist(err) {
+			return false, nil
+		} else if err != nil {
+			return false, ioException(err)
+		}
+		_ = f.Close()
+		return true, nil
+	})
+	addNative("java/io/File.renameTo(Ljava/io/File;)Z", false, func(this, dest int64) bool {
+		return os.Rename(filePath(this), filePath(dest)) == nil
+	})
+	addNative("java/io/File.list()[Ljava/lang/String;", false, func(this int64) int64 {
+		entries, err := ioutil.ReadDir(filePath(this))
+		if err != nil {
+			return 0 // null, if the File isn't a directory or can't be read
+		}
+		names := make([]int64, len(entries))
+		for i, entry := range entries {
+			names[i] = NewStringObject(entry.Name())
+		}
+		return NewRefArray("java/lang/String", names)
+	})
+	return MethodSignatures
+}
@@ -0,0 +1,319 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// FileInputStream, FileOutputStream, and RandomAccessFile objects are implemented in
+// Go: the object's Native field holds the open *os.File. A stream whose file could
+// not be opened throws a FileNotFoundException from its constructor; once it's
+// closed, it has no file, and its reads and writes throw an IOException, as do the
+// operations that fail in the OS.
+
+// errStreamClosed is the exception of a read or write of a closed stream
+var errStreamClosed = errors.New("java.io.IOException: Stream Closed")
+
+// ioException returns the IOException for the error of an OS file operation
+func ioException(err error) error {
+	return errors.New("java.io.IOException: " + osErrorText(err))
+}
+
+// osErrorText returns the text of the error of an OS file operation as the JDK gives
+// it, without the operation and path, as in "No such file or directory"
+func osErrorText(err error) string {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	text := err.Error()
+	if text == "" {
+		return text
+	}
+	return strings.ToUpper(text[:1]) + text[1:]
+}
+
+// openFileObject opens the file with the given flags and attaches it to the object
+func openFileObject(this int64, path string, flags int) error {
+	obj := GetObject(this)
+	if obj == nil {
+		return errors.New("java.lang.NullPointerException")
+	}
+	if flags == os.O_RDONLY {
+		path = substituteResource(path) // a JDK file Jacobin provides, such as tzdb.dat
+	}
+	f, err := os.OpenFile(path, flags, 0666)
+	if err != nil {
+		obj.Native = nil
+		return fmt.Errorf("java.io.FileNotFoundException: %s (%s)", path, osErrorText(err))
+	}
+	obj.Native = f
+	return nil
+}
+
+// osFileOf returns the open file of the referenced stream, or nil if there's none
+func osFileOf(ref int64) *os.File {
+	if obj := GetObject(ref); obj != nil {
+		if f, ok := obj.Native.(*os.File); ok {
+			return f
+		}
+	}
+	return nil
+}
+
+func closeFileObject(this int64) {
+	if f := osFileOf(this); f != nil {
+		_ = f.Close()
+		GetObject(this).Native = nil
+	}
+}
+
+// readByte implements read(): the next byte as an int from 0-255, or -1 at EOF
+func readByte(this int64) (int32, error) {
+	f := osFileOf(this)
+	if f == nil {
+		return 0, errStreamClosed
+	}
+	var b [1]byte
+	if _, err := io.ReadFull(f, b[:]); err == io.EOF {
+		return -1, nil
+	} else if err != nil {
+		return 0, ioException(err)
+	}
+	return int32(b[0]), nil
+}
+
+// checkRange returns the exception of a read or write of length bytes at offset in the
+// array, if they're not all in it
+func checkRange(array int64, offset, length int32) ([]byte, error) {
+	buf, ok := ByteArrayFromRef(array)
+	if !ok {
+		return nil, errors.New("java.lang.NullPointerException")
+	}
+	if offset < 0 || length < 0 || int(offset)+int(length) > len(buf) {
+		return nil, fmt.Errorf("java.lang.IndexOutOfBoundsException: Range [%d, %d + %d) out of bounds for length %d",
+			offset, offset, length, len(buf))
+	}
+	return buf, nil
+}
+
+// readBytes implements read(byte[], int, int): it reads up to length bytes into the
+// array at offset and returns the number read, or -1 at EOF.
+func readBytes(this, array int64, offset, length int32) (int32, error) {
+	buf, err := checkRange(array, offset, length)
+	if err != nil {
+		return 0, err
+	}
+	f := osFileOf(this)
+	if f == nil {
+		return 0, errStreamClosed
+	}
+	if length == 0 {
+		return 0, nil
+	}
+	n, err := f.Read(buf[offset : offset+length])
+	if n == 0 && err == io.EOF {
+		return -1, nil
+	} else if n == 0 && err != nil {
+		return 0, ioException(err)
+	}
+	return int32(n), nil
+}
+
+func writeByte(this int64, b int32) error {
+	f := osFileOf(this)
+	if f == nil {
+		return errStreamClosed
+	}
+	if _, err := f.Write([]byte{byte(b)}); err != nil {
+		return ioException(err)
+	}
+	return nil
+}
+
+func writeBytes(this, array int64, offset, length int32) error {
+	buf, err := checkRange(array, offset, length)
+	if err != nil {
+		return err
+	}
+	f := osFileOf(this)
+	if f == nil {
+		return errStreamClosed
+	}
+	if _, err = f.Write(buf[offset : offset+length]); err != nil {
+		return ioException(err)
+	}
+	return nil
+}
+
+// randomAccessFlags converts a RandomAccessFile mode to os.OpenFile flags. The rws
+// and rwd modes require each write to reach the storage device, hence O_SYNC.
+func randomAccessFlags(mode string) (int, error) {
+	switch mode {
+	case "r":
+		return os.O_RDONLY, nil
+	case "rw":
+		return os.O_RDWR | os.O_CREATE, nil
+	case "rws", "rwd":
+		return os.O_RDWR | os.O_CREATE | os.O_SYNC, nil
+	}
+	return 0, errors.New(`java.lang.IllegalArgumentException: Illegal mode "` + mode +
+		`" must be one of "r", "rw", "rws", or "rwd"`)
+}
+
+// openRandomAccessFile opens the RandomAccessFile in the mode
+func openRandomAccessFile(this int64, path, mode string) error {
+	flags, err := randomAccessFlags(mode)
+	if err != nil {
+		return err
+	}
+	return openFileObject(this, path, flags)
+}
+
+func Load_Io_FileStreams() map[string]GMeth {
+	// java.io.FileInputStream
+	fis := "java/io/FileInputStream"
+	addNative(fis+".<init>(Ljava/lang/String;)V", false, func(this, name int64) error {
+		return openFileObject(this, javaString(name), os.O_RDONLY)
+	})
+	addNative(fis+".<init>(Ljava/io/File;)V", false, func(this, file int64) error {
+		return openFileObject(this, filePath(file), os.O_RDONLY)
+	})
+	addNative(fis+".read()I", false, readByte)
+	addNative(fis+".read([B)I", false, func(this, array int64) (int32, error) {
+		buf, _ := ByteArrayFromRef(array)
+		return readBytes(this, array, 0, int32(len(buf)))
+	})
+	addNative(fis+".read([BII)I", false, readBytes)
+	addNative(fis+".skip(J)J", false, func(this, n int64) (int64, error) {
+		f := osFileOf(this)
+		if f == nil {
+			return 0, errStreamClosed
+		}
+		if n <= 0 {
+			return 0, nil
+		}
+		skipped, err := io.CopyN(io.Discard, f, n)
+		if err != nil && err != io.EOF {
+			return skipped, ioException(err)
+		}
+		return skipped, nil
+	})
+	addNative(fis+".available()I", false, func(this int64) (int32, error) {
+		f := osFileOf(this)
+		if f == nil {
+			return 0, errStreamClosed
+		}
+		pos, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, ioException(err)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			return 0, ioException(err)
+		}
+		if info.Size() < pos {
+			return 0, nil
+		}
+		return int32(info.Size() - pos), nil
+	})
+	addNative(fis+".close()V", false, closeFileObject)
+
+	// java.io.FileOutputStream
+	fos := "java/io/FileOutputStream"
+	outFlags := func(appending bool) int {
+		if appending {
+			return os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+		return os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	addNative(fos+".<init>(Ljava/lang/String;)V", false, func(this, name int64) error {
+		return openFileObject(this, javaString(name), outFlags(false))
+	})
+	addNative(fos+".<init>(Ljava/lang/String;Z)V", false, func(this, name int64, appending bool) error {
+		return openFileObject(this, javaString(name), outFlags(appending))
+	})
+	addNative(fos+".<init>(Ljava/io/File;)V", false, func(this, file int64) error {
+		return openFileObject(this, filePath(file), outFlags(false))
+	})
+	addNative(fos+".<init>(Ljava/io/File;Z)V", false, func(this, file int64, appending bool) error {
+		return openFileObject(this, filePath(file), outFlags(appending))
+	})
+	addNative(fos+".write(I)V", false, writeByte)
+	addNative(fos+".write([B)V", false, func(this, array int64) error {
+		buf, _ := ByteArrayFromRef(array)
+		return writeBytes(this, array, 0, int32(len(buf)))
+	})
+	addNative(fos+".write([BII)V", false, writeBytes)
+	addNative(fos+".flush()V", false, func(this int64) {}) // writes are unbuffered
+	addNative(fos+".close()V", false, closeFileObject)
+
+	// java.io.RandomAccessFile
+	raf := "java/io/RandomAccessFile"
+	addNative(raf+".<init>(Ljava/lang/String;Ljava/lang/String;)V", false, func(this, name, mode int64) error {
+		return openRandomAccessFile(this, javaString(name), javaString(mode))
+	})
+	addNative(raf+".<init>(Ljava/io/File;Ljava/lang/String;)V", false, func(this, file, mode int64) error {
+		return openRandomAccessFile(this, filePath(file), javaString(mode))
+	})
+	addNative(raf+".read()I", false, readByte)
+	addNative(raf+".read([BII)I", false, readBytes)
+	addNative(raf+".write(I)V", false, writeByte)
+	addNative(raf+".write([BII)V", false, writeBytes)
+	addNative(raf+".seek(J)V", false, func(this, pos int64) error {
+		f := osFileOf(this)
+		if f == nil {
+			return errStreamClosed
+		}
+		if pos < 0 {
+			return errors.New("java.io.IOException: Negative seek offset")
+		}
+		if _, err := f.Seek(pos, io.SeekStart); err != nil {
+			return ioException(err)
+		}
+		return nil
+	})
+	addNative(raf+".getFilePointer()J", false, func(this int64) (int64, error) {
+		f := osFileOf(this)
+		if f == nil {
+			return 0, errStreamClosed
+		}
+		pos, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, ioException(err)
+		}
+		return pos, nil
+	})
+	addNative(raf+".length()J", false, func(this int64) (int64, error) {
+		f := osFileOf(this)
+		if f == nil {
+			return 0, errStreamClosed
+		}
+		info, err := f.Stat()
+		if err != nil {
+			return 0, ioException(err)
+		}
+		return info.Size(), nil
+	})
+	addNative(raf+".setLength(J)V", false, func(this, length int64) error {
+		f := osFileOf(this)
+		if f == nil {
+			return errStreamClosed
+		}
+		if err := f.Truncate(length); err != nil {
+			return ioException(err)
+		}
+		return nil
+	})
+	addNative(raf+".close()V", false, closeFileObject)
+	return MethodSignatures
+}
@@ -0,0 +1,124 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileOutputThenInputStream(t *testing.T) {
+	Load_Io_FileStreams()
+	dir, err := ioutil.TempDir("", "jacobin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := NewStringObject(filepath.Join(dir, "out.bin"))
+
+	out := NewObject("java/io/FileOutputStream", 0)
+	callNative(t, "java/io/FileOutputStream.<init>(Ljava/lang/String;)V", out, path)
+	callNative(t, "java/io/FileOutputStream.write(I)V", out, int64('J'))
+	callNative(t, "java/io/FileOutputStream.write([BII)V", out, NewByteArray([]byte("xacobin")), int64(1), int64(6))
+	callNative(t, "java/io/FileOutputStream.close()V", out)
+
+	in := NewObject("java/io/FileInputStream", 0)
+	callNative(t, "java/io/FileInputStream.<init>(Ljava/lang/String;)V", in, path)
+	if callNative(t, "java/io/FileInputStream.available()I", in).(int64) != 7 {
+		t.Errorf("Expected 7 bytes available")
+	}
+	if callNative(t, "java/io/FileInputStream.read()I", in).(int64) != 'J' {
+		t.Errorf("Expected first byte to be J")
+	}
+	buf := NewByteArray(make([]byte, 10))
+	n := callNative(t, "java/io/FileInputStream.read([B)I", in, buf).(int64)
+	contents, _ := ByteArrayFromRef(buf)
+	if n != 6 || string(contents[:n]) != "acobin" {
+		t.Errorf("Expected to read \"acobin\", got %d bytes: %q", n, contents[:n])
+	}
+	if callNative(t, "java/io/FileInputStream.read()I", in).(int64) != -1 {
+		t.Errorf("Expected -1 at end of file")
+	}
+	callNative(t, "java/io/FileInputStream.close()V", in)
+	if err, _ := callNative(t, "java/io/FileInputStream.read()I", in).(error); err == nil ||
+		err.Error() != "java.io.IOException: Stream Closed" {
+		t.Errorf("Expected an IOException from closed stream, got %v", err)
+	}
+}
+
+func TestFileStreamExceptions(t *testing.T) {
+	Load_Io_FileStreams()
+	Load_Io_File()
+	dir, err := ioutil.TempDir("", "jacobin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	missing := filepath.Join(dir, "missing", "in.bin")
+
+	in := NewObject("java/io/FileInputStream", 0)
+	err, _ = callNative(t, "java/io/FileInputStream.<init>(Ljava/lang/String;)V", in, NewStringObject(missing)).(error)
+	if err == nil || err.Error() != "java.io.FileNotFoundException: "+missing+" (No such file or directory)" {
+		t.Errorf("Expected a FileNotFoundException, got %v", err)
+	}
+
+	raf := NewObject("java/io/RandomAccessFile", 0)
+	err, _ = callNative(t, "java/io/RandomAccessFile.<init>(Ljava/lang/String;Ljava/lang/String;)V",
+		raf, NewStringObject(filepath.Join(dir, "raf.bin")), NewStringObject("w")).(error)
+	if err == nil || !strings.HasPrefix(err.Error(), `java.lang.IllegalArgumentException: Illegal mode "w"`) {
+		t.Errorf("Expected an IllegalArgumentException for mode w, got %v", err)
+	}
+
+	out := NewObject("java/io/FileOutputStream", 0)
+	callNative(t, "java/io/FileOutputStream.<init>(Ljava/lang/String;)V", out, NewStringObject(filepath.Join(dir, "out.bin")))
+	defer callNative(t, "java/io/FileOutputStream.close()V", out)
+	err, _ = callNative(t, "java/io/FileOutputStream.write([BII)V", out, NewByteArray([]byte("abc")),
+		int64(2), int64(2)).(error)
+	if err == nil || !strings.HasPrefix(err.Error(), "java.lang.IndexOutOfBoundsException") {
+		t.Errorf("Expected an IndexOutOfBoundsException, got %v", err)
+	}
+
+	file := NewFileObject(missing)
+	err, _ = callNative(t, "java/io/File.createNewFile()Z", file).(error)
+	if err == nil || err.Error() != "java.io.IOException: No such file or directory" {
+		t.Errorf("Expected an IOException creating a file in a missing directory, got %v", err)
+	}
+	file = NewFileObject(filepath.Join(dir, "out.bin"))
+	if callNative(t, "java/io/File.createNewFile()Z", file) != int64(0) {
+		t.Error("Expected createNewFile() of an existing file to return false")
+	}
+}
+
+func TestRandomAccessFileSeek(t *testing.T) {
+	Load_Io_FileStreams()
+	dir, err := ioutil.TempDir("", "jacobin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	raf := NewObject("java/io/RandomAccessFile", 0)
+	callNative(t, "java/io/RandomAccessFile.<init>(Ljava/lang/String;Ljava/lang/String;)V",
+		raf, NewStringObject(filepath.Join(dir, "raf.bin")), NewStringObject("rw"))
+	callNative(t, "java/io/RandomAccessFile.write([BII)V", raf, NewByteArray([]byte("0123456789")),
+		int64(0), int64(10))
+	callNative(t, "java/io/RandomAccessFile.seek(J)V", raf, int64(4))
+	if callNative(t, "java/io/RandomAccessFile.read()I", raf).(int64) != '4' {
+		t.Errorf("Expected to read 4 after seek")
+	}
+	if callNative(t, "java/io/RandomAccessFile.getFilePointer()J", raf).(int64) != 5 {
+		t.Errorf("Expected file pointer of 5")
+	}
+	callNative(t, "java/io/RandomAccessFile.setLength(J)V", raf, int64(3))
+	if callNative(t, "java/io/RandomAccessFile.length()J", raf).(int64) != 3 {
+		t.Errorf("Expected length of 3 after setLength()")
+	}
+	callNative(t, "java/io/RandomAccessFile.close()V", raf)
+}
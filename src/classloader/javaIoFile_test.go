@@ -0,0 +1,64 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileMetadataAndListing(t *testing.T) {
+	Load_Io_File()
+	dir, err := ioutil.TempDir("", "jacobin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewObject("java/io/File", 0)
+	callNative(t, "java/io/File.<init>(Ljava/lang/String;Ljava/lang/String;)V",
+		f, NewStringObject(dir), NewStringObject("a.txt"))
+	if callNative(t, "java/io/File.exists()Z", f).(int64) != 1 {
+		t.Errorf("Expected file to exist")
+	}
+	if callNative(t, "java/io/File.isDirectory()Z", f).(int64) != 0 {
+		t.Errorf("Expected file not to be a directory")
+	}
+	if callNative(t, "java/io/File.length()J", f).(int64) != 5 {
+		t.Errorf("Expected file length of 5")
+	}
+	name := callNative(t, "java/io/File.getName()Ljava/lang/String;", f).(int64)
+	if s, _ := GoStringFromRef(name); s != "a.txt" {
+		t.Errorf("Expected name a.txt, got: %q", s)
+	}
+
+	sub := NewFileObject(filepath.Join(dir, "sub"))
+	if callNative(t, "java/io/File.mkdir()Z", sub).(int64) != 1 {
+		t.Errorf("Expected mkdir() to succeed")
+	}
+	list := callNative(t, "java/io/File.list()[Ljava/lang/String;", NewFileObject(dir)).(int64)
+	names, ok := RefArrayFromRef(list)
+	if !ok || len(names) != 2 {
+		t.Fatalf("Expected directory listing of 2 entries, got: %v", names)
+	}
+	if s, _ := GoStringFromRef(names[0]); s != "a.txt" {
+		t.Errorf("Expected first entry a.txt, got: %q", s)
+	}
+
+	if callNative(t, "java/io/File.delete()Z", f).(int64) != 1 ||
+		callNative(t, "java/io/File.exists()Z", f).(int64) != 0 {
+		t.Errorf("Expected delete() to remove the file")
+	}
+	if callNative(t, "java/io/File.list()[Ljava/lang/String;", f).(int64) != 0 {
+		t.Errorf("Expected list() of a non-directory to return null")
+	}
+}
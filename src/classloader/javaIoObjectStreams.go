@@ -0,0 +1,280 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+)
+
+// The natives that Java serialization (ObjectOutputStream and ObjectInputStream) relies
+// on. ObjectStreamClass finds a class's serializable fields and its readObject(),
+// writeObject(), etc., methods via reflection (see javaLangReflect.go); reads and writes
+// the fields via the Unsafe field accessors here; and creates objects during
+// deserialization with a constructor from ReflectionFactory that runs only the no-arg
+// constructor of the first superclass that isn't serializable.
+//
+// The default serializable fields are computed here rather than in Java, since sorting
+// them in Java requires invokeinterface: as in the JDK, they are the non-static,
+// non-transient fields, with the primitive fields first, each group sorted by name.
+
+// serialConstructor is a Constructor from ReflectionFactory.newConstructorForSerialization()
+type serialConstructor struct {
+	class     string // the class to instantiate
+	ctorClass string // the class whose no-arg constructor is run
+}
+
+// serialField is an ObjectStreamField
+type serialField struct {
+	field  reflectField
+	offset int // the field's offset in the stream's primitive data, set by ObjectStreamClass
+}
+
+// allocateInstance creates an object of the class without running any constructor
+func allocateInstance(className string) (int64, error) {
+	if GoClasses[className] {
+		return NewObject(className, 0), nil
+	}
+	data := classData(className)
+	if data == nil {
+		return 0, errors.New("java.lang.InstantiationException: " + strings.ReplaceAll(className, "/", "."))
+	}
+	return NewObject(className, InstanceSize(className)), nil
+}
+
+// implements reports whether the class or any of its supertypes implements the interface
+func implements(className, iface string) bool {
+	for c := className; c != ""; c = superclassOf(c) {
+		if c == iface {
+			return true
+		}
+		data := classData(c)
+		if data == nil {
+			return false
+		}
+		for _, i := range data.Interfaces {
+			if implements(data.CP.Utf8Refs[i], iface) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// defaultSerialFields returns the fields that default serialization reads and writes,
+// in the order they appear in the stream
+func defaultSerialFields(className string) []reflectField {
+	var fields []reflectField
+	for _, f := range declaredFields(className) {
+		if f.modifiers&(ACC_STATIC|0x0080) == 0 { // not static or transient
+			fields = append(fields, f)
+		}
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		iPrim, jPrim := len(fields[i].desc) == 1, len(fields[j].desc) == 1
+		if iPrim != jPrim {
+			return iPrim
+		}
+		return fields[i].name < fields[j].name
+	})
+	return fields
+}
+
+func newSerialFieldObject(f reflectField) int64 {
+	ref := NewObject("java/io/ObjectStreamField", 0)
+	GetObject(ref).Native = &serialField{field: f}
+	return ref
+}
+
+func serialFieldOf(ref int64) *serialField {
+	if obj := GetObject(ref); obj != nil {
+		if f, ok := obj.Native.(*serialField); ok {
+			return f
+		}
+	}
+	return &serialField{field: reflectField{slot: -1}}
+}
+
+func Load_Io_ObjectStreams() map[string]GMeth {
+	unsafe := "jdk/internal/misc/Unsafe"
+	addNative(unsafe+".allocateInstance(Ljava/lang/Class;)Ljava/lang/Object;", false, func(this, classRef int64) (int64, error) {
+		name, _ := ClassNameOf(classRef)
+		return allocateInstance(name)
+	})
+
+	// the accessors for object fields. Each field occupies one slot (see object.go), so
+	// offsets are slot indexes, and a value is stored in the slot the way it would be on
+	// the operand stack. A null object or an invalid offset throws (see withSlot()).
+	get := func(obj, offset int64) (int64, error) {
+		var v int64
+		err := withSlot(obj, offset, func(slot *int64) { v = *slot })
+		return v, err
+	}
+	put := func(obj, offset, v int64) error {
+		return withSlot(obj, offset, func(slot *int64) { *slot = v })
+	}
+	addNative(unsafe+".getInt(Ljava/lang/Object;J)I", false, func(this, obj, offset int64) (int32, error) {
+		v, err := get(obj, offset)
+		return int32(v), err
+	})
+	addNative(unsafe+".putInt(Ljava/lang/Object;JI)V", false, func(this, obj, offset int64, v int32) error {
+		return put(obj, offset, int64(v))
+	})
+	addNative(unsafe+".getLong(Ljava/lang/Object;J)J", false, func(this, obj, offset int64) (int64, error) {
+		return get(obj, offset)
+	})
+	addNative(unsafe+".putLong(Ljava/lang/Object;JJ)V", false, func(this, obj, offset int64, v int64) error {
+		return put(obj, offset, v)
+	})
+	addNative(unsafe+".getShort(Ljava/lang/Object;J)S", false, func(this, obj, offset int64) (int16, error) {
+		v, err := get(obj, offset)
+		return int16(v), err
+	})
+	addNative(unsafe+".putShort(Ljava/lang/Object;JS)V", false, func(this, obj, offset int64, v int16) error {
+		return put(obj, offset, int64(v))
+	})
+	addNative(unsafe+".getChar(Ljava/lang/Object;J)C", false, func(this, obj, offset int64) (uint16, error) {
+		v, err := get(obj, offset)
+		return uint16(v), err
+	})
+	addNative(unsafe+".putChar(Ljava/lang/Object;JC)V", false, func(this, obj, offset int64, v uint16) error {
+		return put(obj, offset, int64(v))
+	})
+	addNative(unsafe+".getByte(Ljava/lang/Object;J)B", false, func(this, obj, offset int64) (int8, error) {
+		v, err := get(obj, offset)
+		return int8(v), err
+	})
+	addNative(unsafe+".putByte(Ljava/lang/Object;JB)V", false, func(this, obj, offset int64, v int8) error {
+		return put(obj, offset, int64(v))
+	})
+	addNative(unsafe+".getBoolean(Ljava/lang/Object;J)Z", false, func(this, obj, offset int64) (bool, error) {
+		v, err := get(obj, offset)
+		return v != 0, err
+	})
+	addNative(unsafe+".putBoolean(Ljava/lang/Object;JZ)V", false, func(this, obj, offset int64, v bool) error {
+		if v {
+			return put(obj, offset, 1)
+		}
+		return put(obj, offset, 0)
+	})
+	addNative(unsafe+".getFloat(Ljava/lang/Object;J)F", false, func(this, obj, offset int64) (float32, error) {
+		v, err := get(obj, offset)
+		return float32(math.Float64frombits(uint64(v))), err
+	})
+	addNative(unsafe+".putFloat(Ljava/lang/Object;JF)V", false, func(this, obj, offset int64, v float32) error {
+		return put(obj, offset, int64(math.Float64bits(float64(v))))
+	})
+	addNative(unsafe+".getDouble(Ljava/lang/Object;J)D", false, func(this, obj, offset int64) (float64, error) {
+		v, err := get(obj, offset)
+		return math.Float64frombits(uint64(v)), err
+	})
+	addNative(unsafe+".putDouble(Ljava/lang/Object;JD)V", false, func(this, obj, offset int64, v float64) error {
+		return put(obj, offset, int64(math.Float64bits(v)))
+	})
+	addNative(unsafe+".getReference(Ljava/lang/Object;J)Ljava/lang/Object;", false,
+		func(this, obj, offset int64) (int64, error) {
+			return get(obj, offset)
+		})
+	addNative(unsafe+".putReference(Ljava/lang/Object;JLjava/lang/Object;)V", false,
+		func(this, obj, offset, v int64) error {
+			return put(obj, offset, v)
+		})
+
+	osc := "java/io/ObjectStreamClass"
+	addNative(osc+".initNative()V", true, func() {})
+	addNative(osc+".hasStaticInitializer(Ljava/lang/Class;)Z", true, func(classRef int64) bool {
+		name, _ := ClassNameOf(classRef)
+		return hasMethod(name, "<clinit>", "()V")
+	})
+	addNative(osc+".getDefaultSerialFields(Ljava/lang/Class;)[Ljava/io/ObjectStreamField;", true,
+		func(classRef int64) int64 {
+			name, _ := ClassNameOf(classRef)
+			fields := defaultSerialFields(name)
+			refs := make([]int64, len(fields))
+			for i, f := range fields {
+				refs[i] = newSerialFieldObject(f)
+			}
+			return NewRefArray("java/io/ObjectStreamField", refs)
+		})
+
+	osf := "java/io/ObjectStreamField"
+	addNative(osf+".getName()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(serialFieldOf(this).field.name)
+	})
+	addNative(osf+".getType()Ljava/lang/Class;", false, func(this int64) int64 {
+		return descriptorClass(serialFieldOf(this).field.desc)
+	})
+	addNative(osf+".getTypeCode()C", false, func(this int64) uint16 {
+		return uint16(serialFieldOf(this).field.desc[0])
+	})
+	addNative(osf+".getTypeString()Ljava/lang/String;", false, func(this int64) int64 {
+		if desc := serialFieldOf(this).field.desc; len(desc) > 1 {
+			return NewStringObject(desc)
+		}
+		return 0 // primitive fields have no type string
+	})
+	addNative(osf+".getSignature()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(serialFieldOf(this).field.desc)
+	})
+	addNative(osf+".isPrimitive()Z", false, func(this int64) bool {
+		return len(serialFieldOf(this).field.desc) == 1
+	})
+	addNative(osf+".isUnshared()Z", false, func(this int64) bool {
+		return false
+	})
+	addNative(osf+".getField()Ljava/lang/reflect/Field;", false, func(this int64) int64 {
+		return newFieldObject(serialFieldOf(this).field)
+	})
+	addNative(osf+".getOffset()I", false, func(this int64) int32 {
+		return int32(serialFieldOf(this).offset)
+	})
+	addNative(osf+".setOffset(I)V", false, func(this int64, offset int32) {
+		serialFieldOf(this).offset = int(offset)
+	})
+
+	// the constructor that deserialization uses to create objects
+	for _, rf := range []string{"jdk/internal/reflect/ReflectionFactory", "sun/reflect/ReflectionFactory"} {
+		addNative(rf+".newConstructorForSerialization(Ljava/lang/Class;)Ljava/lang/reflect/Constructor;", false,
+			func(this, classRef int64) int64 {
+				name, _ := ClassNameOf(classRef)
+				ctorClass := name
+				for ctorClass != "" && implements(ctorClass, "java/io/Serializable") {
+					ctorClass = superclassOf(ctorClass)
+				}
+				if ctorClass == "" || !hasMethod(ctorClass, "<init>", "()V") {
+					return 0 // the first non-serializable superclass must have a no-arg constructor
+				}
+				ref := NewObject("java/lang/reflect/Constructor", 0)
+				GetObject(ref).Native = &serialConstructor{class: name, ctorClass: ctorClass}
+				return ref
+			})
+	}
+	addNative("java/lang/reflect/Constructor.newInstance([Ljava/lang/Object;)Ljava/lang/Object;", false,
+		func(this, args int64) (int64, error) {
+			obj := GetObject(this)
+			if obj == nil {
+				return 0, nil
+			}
+			ctor, ok := obj.Native.(*serialConstructor)
+			if !ok {
+				return 0, nil // TODO: support other constructors along with the rest of reflection
+			}
+			ref, err := allocateInstance(ctor.class)
+			if err != nil {
+				return 0, err
+			}
+			if ctor.ctorClass != "java/lang/Object" {
+				if _, err := InvokeMethod(ctor.ctorClass, "<init>", "()V", []int64{ref}); err != nil {
+					return 0, &invocationTargetError{target: err}
+				}
+			}
+			return ref, nil
+		})
+	return MethodSignatures
+}
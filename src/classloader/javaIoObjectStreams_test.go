@@ -0,0 +1,88 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+func TestDefaultSerialFields(t *testing.T) {
+	Load_Io_ObjectStreams()
+	addTestClass("test/Record", "", []string{"java/io/Serializable"}, []testMember{
+		{0x0002, "name", "Ljava/lang/String;"},
+		{0x0002, "size", "I"},
+		{0x001A, "serialVersionUID", "J"},       // private static final
+		{0x0082, "cache", "Ljava/lang/Object;"}, // private transient
+		{0x0002, "age", "J"},
+		{0x0002, "alias", "Ljava/lang/String;"},
+	}, []testMember{{0x0008, "<clinit>", "()V"}})
+	class := ClassObject("test/Record")
+
+	fields, _ := RefArrayFromRef(callNative(t,
+		"java/io/ObjectStreamClass.getDefaultSerialFields(Ljava/lang/Class;)[Ljava/io/ObjectStreamField;", class).(int64))
+	var names []string
+	for _, f := range fields {
+		names = append(names, javaString(callNative(t, "java/io/ObjectStreamField.getName()Ljava/lang/String;", f).(int64)))
+	}
+	if len(names) != 4 || names[0] != "age" || names[1] != "size" || names[2] != "alias" || names[3] != "name" {
+		t.Errorf("Expected primitive fields first, each group sorted by name, got %v", names)
+	}
+	if code := callNative(t, "java/io/ObjectStreamField.getTypeCode()C", fields[0]); code != int64('J') {
+		t.Errorf("Expected type code J, got %v", code)
+	}
+	if callNative(t, "java/io/ObjectStreamClass.hasStaticInitializer(Ljava/lang/Class;)Z", class) != int64(1) {
+		t.Error("Expected a static initializer to be found")
+	}
+}
+
+func TestAllocateInstanceAndFieldAccess(t *testing.T) {
+	Load_Io_ObjectStreams()
+	addTestClass("test/Pair", "", nil, []testMember{{0, "a", "I"}, {0, "b", "D"}}, nil)
+	unsafe := "jdk/internal/misc/Unsafe"
+
+	obj := callNative(t, unsafe+".allocateInstance(Ljava/lang/Class;)Ljava/lang/Object;", int64(0), ClassObject("test/Pair")).(int64)
+	if o := GetObject(obj); o == nil || o.Klass != "test/Pair" || len(o.Fields) != 2 {
+		t.Fatal("Expected an uninitialized test/Pair with 2 fields")
+	}
+	missing := callNative(t, unsafe+".allocateInstance(Ljava/lang/Class;)Ljava/lang/Object;", int64(0), ClassObject("test/NoSuchClass"))
+	if err, _ := missing.(error); err == nil || err.Error() != "java.lang.InstantiationException: test.NoSuchClass" {
+		t.Errorf("Expected InstantiationException for a class that isn't loaded, got %v", missing)
+	}
+	callNative(t, unsafe+".putInt(Ljava/lang/Object;JI)V", int64(0), obj, int64(0), int64(-7))
+	callNative(t, unsafe+".putDouble(Ljava/lang/Object;JD)V", int64(0), obj, int64(1), SlotFromFloat(2.5))
+	if v := callNative(t, unsafe+".getInt(Ljava/lang/Object;J)I", int64(0), obj, int64(0)); v != int64(-7) {
+		t.Errorf("Expected -7, got %v", v)
+	}
+	if v := callNative(t, unsafe+".getDouble(Ljava/lang/Object;J)D", int64(0), obj, int64(1)).(int64); FloatFromSlot(v) != 2.5 {
+		t.Errorf("Expected 2.5, got %v", FloatFromSlot(v))
+	}
+}
+
+func TestSerializationConstructor(t *testing.T) {
+	Load_Io_ObjectStreams()
+	addTestClass("test/Base", "", nil, nil, []testMember{{0x0001, "<init>", "()V"}})
+	addTestClass("test/Middle", "test/Base", []string{"java/io/Serializable"}, nil, nil)
+	addTestClass("test/Leaf", "test/Middle", nil, []testMember{{0, "x", "I"}}, nil)
+
+	var invoked string
+	InvokeMethod = func(className, methodName, methodType string, args []int64) (int64, error) {
+		invoked = className + "." + methodName + methodType
+		return 0, nil
+	}
+	defer func() {
+		InvokeMethod = func(string, string, string, []int64) (int64, error) { return 0, nil }
+	}()
+
+	ctor := callNative(t, "jdk/internal/reflect/ReflectionFactory.newConstructorForSerialization(Ljava/lang/Class;)Ljava/lang/reflect/Constructor;",
+		int64(0), ClassObject("test/Leaf")).(int64)
+	obj := callNative(t, "java/lang/reflect/Constructor.newInstance([Ljava/lang/Object;)Ljava/lang/Object;",
+		ctor, NewRefArray("java/lang/Object", nil)).(int64)
+	if o := GetObject(obj); o == nil || o.Klass != "test/Leaf" {
+		t.Fatal("Expected newInstance() to create a test/Leaf")
+	}
+	if invoked != "test/Base.<init>()V" {
+		t.Errorf("Expected only the non-serializable superclass's constructor to run, got %s", invoked)
+	}
+}
@@ -8,6 +8,9 @@ package classloader
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 )
 
 /*
@@ -40,7 +43,7 @@ func Load_Io_PrintStream() map[string]GMeth {
 	MethodSignatures["java/io/PrintStream.println(Ljava/lang/String;)V"] = // println string
 		GMeth{
 			ParamSlots: 2, // [0] = PrintStream.out object,
-			// [1] = index to StringConst to print
+			// [1] = reference to the String to print
 			GFunction: Println,
 		}
 	MethodSignatures["java/io/PrintStream.println(I)V"] = // println int
@@ -53,32 +56,73 @@ func Load_Io_PrintStream() map[string]GMeth {
 			ParamSlots: 2,
 			GFunction:  PrintlnLong,
 		}
+	MethodSignatures["java/io/PrintStream.println()V"] = // println with no args: just a newline
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  PrintlnNone,
+		}
+	MethodSignatures["java/io/PrintStream.println(Z)V"] = // println boolean
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  PrintlnBoolean,
+		}
+	MethodSignatures["java/io/PrintStream.println(C)V"] = // println char
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  PrintlnChar,
+		}
+	MethodSignatures["java/io/PrintStream.println(D)V"] = // println double
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  PrintlnDouble,
+		}
+	MethodSignatures["java/io/PrintStream.println(F)V"] = // println float
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  PrintlnFloat,
+		}
+	MethodSignatures["java/io/PrintStream.print(Ljava/lang/String;)V"] = // print string
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  PrintString,
+		}
+	MethodSignatures["java/io/PrintStream.print(I)V"] = // print int
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  PrintI,
+		}
+	MethodSignatures["java/io/PrintStream.print(J)V"] = // print long
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  PrintI, // ints and longs are both int64s in the slot
+		}
+	MethodSignatures["java/io/PrintStream.print(C)V"] = // print char
+		GMeth{
+			ParamSlots: 2,
+			GFunction:  PrintChar,
+		}
+	MethodSignatures["java/io/PrintStream.flush()V"] = // flush (writes are unbuffered, so a no-op)
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  func([]interface{}) interface{} { return nil },
+		}
 	return MethodSignatures
 }
 
-// Println is the go equivalent of System.out.println(). It accepts two args,
+// Println is the go equivalent of System.out.println(String). It accepts two args,
 // which are passed in a two-entry slice of type interface{}. The first arg is an
-// index in the CP to a StringConst entry; the second arg is an index into the
-// array of static fields, Statics. The entry there includes a pointer to the CP
-// for this class. The first arg then gets the StringConst ref, which is an index
-// into the UTF8 entries of the CP. This string is then printed to stdout. There
-// is no return value.
+// index into the array of static fields, Statics, for the PrintStream (System.out or
+// System.err), which determines the stream that's written to; the second arg is a
+// reference to the String object to print. There is no return value.
 func Println(i []interface{}) interface{} {
-	sIndex := i[1].(int64) // points to a String constant entry in the CP
-	cpi := i[0].(int64)    // int64 which is an index into Statics array
-	cp := StaticsArray[cpi].CP
-	s := FetchUTF8stringFromCPEntryNumber(cp, uint16(sIndex))
-	fmt.Println(s)
+	fmt.Fprintln(streamFor(i[0]), stringArg(i[1]))
 	return nil
 }
 
-// PrintlnI = java/io/Prinstream.println(int) TODO: equivalent (verify that this grabs the right param to print)
+// PrintlnI = java/io/Prinstream.println(int)
 func PrintlnI(i []interface{}) interface{} {
 	intToPrint := i[1].(int64) // contains an int
-	// cpi := i[0].(int64)    // int64 which is an index into Statics array
-	// cp := StaticsArray[cpi].CP
-	// s := FetchUTF8stringFromCPEntryNumber(cp, uint16(sIndex))
-	fmt.Println(intToPrint)
+	fmt.Fprintln(streamFor(i[0]), intToPrint)
 	return nil
 }
 
@@ -86,6 +130,102 @@ func PrintlnI(i []interface{}) interface{} {
 // Long in Java are 64-bit ints, so we just duplicated the logic for println(int)
 func PrintlnLong(l []interface{}) interface{} {
 	intToPrint := l[1].(int64) // contains to an int64--the equivalent of a Java long
-	fmt.Println(intToPrint)
+	fmt.Fprintln(streamFor(l[0]), intToPrint)
 	return nil
 }
+
+// PrintlnNone = java/io/Prinstream.println()
+func PrintlnNone(i []interface{}) interface{} {
+	fmt.Fprintln(streamFor(i[0]))
+	return nil
+}
+
+// PrintlnBoolean = java/io/Prinstream.println(boolean)
+func PrintlnBoolean(i []interface{}) interface{} {
+	fmt.Fprintln(streamFor(i[0]), i[1].(int64) != 0)
+	return nil
+}
+
+// PrintlnChar = java/io/Prinstream.println(char)
+func PrintlnChar(i []interface{}) interface{} {
+	fmt.Fprintln(streamFor(i[0]), string(rune(i[1].(int64))))
+	return nil
+}
+
+// PrintlnDouble = java/io/Prinstream.println(double). Doubles are held in the slot
+// as the bits of a float64. The formatting is that of Double.toString().
+func PrintlnDouble(i []interface{}) interface{} {
+	fmt.Fprintln(streamFor(i[0]), formatDouble(FloatFromSlot(i[1].(int64))))
+	return nil
+}
+
+// PrintlnFloat = java/io/Prinstream.println(float). Floats, too, are held in the slot
+// as the bits of a float64. The formatting is that of Float.toString().
+func PrintlnFloat(i []interface{}) interface{} {
+	fmt.Fprintln(streamFor(i[0]), formatFloating(FloatFromSlot(i[1].(int64)), 32))
+	return nil
+}
+
+// PrintString = java/io/Prinstream.print(String)
+func PrintString(i []interface{}) interface{} {
+	fmt.Fprint(streamFor(i[0]), stringArg(i[1]))
+	return nil
+}
+
+// PrintI = java/io/Prinstream.print(int) and print(long)
+func PrintI(i []interface{}) interface{} {
+	fmt.Fprint(streamFor(i[0]), i[1].(int64))
+	return nil
+}
+
+// PrintChar = java/io/Prinstream.print(char)
+func PrintChar(i []interface{}) interface{} {
+	fmt.Fprint(streamFor(i[0]), string(rune(i[1].(int64))))
+	return nil
+}
+
+// stringArg returns the value of a String argument, printing null references as
+// "null", as Java does.
+func stringArg(arg interface{}) string {
+	ref := arg.(int64)
+	if ref == 0 {
+		return "null"
+	}
+	s, _ := GoStringFromRef(ref)
+	return s
+}
+
+// formatDouble formats a double the way Double.toString() does
+func formatDouble(d float64) string {
+	return formatFloating(d, 64)
+}
+
+// formatFloating formats a double (bitSize 64) or a float (bitSize 32) the way
+// Double.toString() and Float.toString() do: with the fewest digits that identify the
+// value, and at least one after the decimal point. Values from 10^-3 up to 10^7 are
+// written out, as in 100.0 or 0.001; others are in scientific notation, as in 1.0E7
+// or 1.5E-5.
+func formatFloating(d float64, bitSize int) string {
+	switch {
+	case math.IsNaN(d):
+		return "NaN"
+	case math.IsInf(d, 1):
+		return "Infinity"
+	case math.IsInf(d, -1):
+		return "-Infinity"
+	}
+	if abs := math.Abs(d); abs == 0 || abs >= 1e-3 && abs < 1e7 {
+		s := strconv.FormatFloat(d, 'f', -1, bitSize)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+	s := strconv.FormatFloat(d, 'e', -1, bitSize) // as in 1e+07 or 1.5e-05
+	mantissa, exponent := s[:strings.IndexByte(s, 'e')], s[strings.IndexByte(s, 'e')+1:]
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	exp, _ := strconv.Atoi(exponent)
+	return mantissa + "E" + strconv.Itoa(exp)
+}
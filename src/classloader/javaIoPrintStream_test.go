@@ -0,0 +1,114 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+)
+
+// captures what the function writes to stdout and stderr
+func captureOutput(fn func()) (string, string) {
+	normalStdout, normalStderr := os.Stdout, os.Stderr
+	rout, wout, _ := os.Pipe()
+	rerr, werr, _ := os.Pipe()
+	os.Stdout, os.Stderr = wout, werr
+
+	fn()
+
+	_ = wout.Close()
+	_ = werr.Close()
+	out, _ := ioutil.ReadAll(rout)
+	errOut, _ := ioutil.ReadAll(rerr)
+	os.Stdout, os.Stderr = normalStdout, normalStderr
+	return string(out), string(errOut)
+}
+
+// staticRef returns the value of the named static reference field, as getstatic does
+func staticRef(name string) int64 {
+	index, _ := FindStatic(name)
+	return LoadStaticInt(index)
+}
+
+func TestPrintlnToOutAndErr(t *testing.T) {
+	InitStdStreams()
+	out := staticRef("java/lang/System.out")
+	errStream := staticRef("java/lang/System.err")
+	str := NewStringObject("hello")
+
+	stdout, stderr := captureOutput(func() {
+		Println([]interface{}{out, str})
+		PrintlnI([]interface{}{errStream, int64(42)})
+	})
+	if stdout != "hello\n" {
+		t.Errorf("Expected 'hello' on stdout, got: %q", stdout)
+	}
+	if stderr != "42\n" {
+		t.Errorf("Expected '42' on stderr, got: %q", stderr)
+	}
+}
+
+func TestPrintlnToStdStreams(t *testing.T) {
+	InitStdStreams()
+	var out, errOut bytes.Buffer
+	SetStdStreams(&out, &errOut)
+	defer SetStdStreams(nil, nil)
+
+	stdout, stderr := captureOutput(func() {
+		Println([]interface{}{staticRef("java/lang/System.out"), NewStringObject("hello")})
+		PrintlnI([]interface{}{staticRef("java/lang/System.err"), int64(42)})
+	})
+	if out.String() != "hello\n" || errOut.String() != "42\n" {
+		t.Errorf("Expected the output in the writers, got: %q and %q", out.String(), errOut.String())
+	}
+	if stdout != "" || stderr != "" {
+		t.Errorf("Expected nothing on the console, got: %q and %q", stdout, stderr)
+	}
+}
+
+func TestPrintVariants(t *testing.T) {
+	InitStdStreams()
+	out := staticRef("java/lang/System.out")
+
+	stdout, _ := captureOutput(func() {
+		PrintString([]interface{}{out, NewStringObject("a")})
+		PrintChar([]interface{}{out, int64('b')})
+		PrintI([]interface{}{out, int64(3)})
+		PrintlnNone([]interface{}{out})
+		PrintlnBoolean([]interface{}{out, int64(1)})
+		PrintlnDouble([]interface{}{out, SlotFromFloat(2.0)})
+		Println([]interface{}{out, int64(0)})
+	})
+	if stdout != "ab3\ntrue\n2.0\nnull\n" {
+		t.Errorf("Unexpected output from print functions: %q", stdout)
+	}
+}
+
+func TestFormatFloating(t *testing.T) {
+	for d, expected := range map[float64]string{
+		2.0: "2.0", -0.5: "-0.5", 1e7: "1.0E7", 1.5e-5: "1.5E-5", 0.001: "0.001", 9999999.0: "9999999.0",
+		123456789.125: "1.23456789125E8", 1e-300: "1.0E-300", math.Inf(-1): "-Infinity", math.NaN(): "NaN",
+		math.Copysign(0, -1): "-0.0",
+	} {
+		if s := formatDouble(d); s != expected {
+			t.Errorf("Expected Double.toString(%g) to be %s, got %s", d, expected, s)
+		}
+	}
+
+	InitStdStreams()
+	out := staticRef("java/lang/System.out")
+	stdout, _ := captureOutput(func() {
+		PrintlnFloat([]interface{}{out, SlotFromFloat(float64(float32(0.1)))})
+		PrintlnFloat([]interface{}{out, SlotFromFloat(float64(float32(3e10)))})
+	})
+	if stdout != "0.1\n3.0E10\n" {
+		t.Errorf("Expected println(float) to print as Float.toString() does, got: %q", stdout)
+	}
+}
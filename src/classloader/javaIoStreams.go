@@ -0,0 +1,183 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// The methods of java.io.InputStream and OutputStream. A call through a variable whose
+// static type is InputStream or OutputStream (e.g., the stream returned by
+// Socket.getInputStream()) resolves to these natives, which dispatch on the Go-side
+// state of the actual stream object: the standard streams, open files, and sockets.
+
+// readerFor returns the Go reader behind the referenced input stream, or nil
+func readerFor(ref int64) io.Reader {
+	obj := GetObject(ref)
+	if obj == nil {
+		return nil
+	}
+	switch v := obj.Native.(type) {
+	case *stdStream:
+		if v.fd == 0 {
+			return stdinReader()
+		}
+	case io.Reader:
+		return v
+	}
+	return nil
+}
+
+// writerFor returns the Go writer behind the referenced output stream (or PrintStream), or nil
+func writerFor(ref int64) io.Writer {
+	obj := GetObject(ref)
+	if obj == nil {
+		return nil
+	}
+	switch v := obj.Native.(type) {
+	case *stdStream:
+		if v.fd == 1 || v.fd == 2 {
+			return stdOutput(v.fd)
+		}
+	case io.Writer:
+		return v
+	}
+	return nil
+}
+
+// streamException returns the exception for the error of a read or write of the
+// stream. The errors of the socket streams already are exceptions (see socketException()).
+func streamException(err error) error {
+	switch {
+	case strings.HasPrefix(err.Error(), "java."):
+		return err
+	case errors.Is(err, os.ErrClosed):
+		return errStreamClosed
+	}
+	return ioException(err)
+}
+
+// streamReadByte reads the next byte from the stream, returning -1 at end of stream.
+// A stream that has no Go reader, such as a closed file stream, can't be read.
+func streamReadByte(this int64) (int32, error) {
+	r := readerFor(this)
+	if r == nil {
+		return 0, errStreamClosed
+	}
+	var b [1]byte
+	var err error
+	if br, ok := r.(io.ByteReader); ok {
+		b[0], err = br.ReadByte()
+	} else {
+		_, err = io.ReadFull(r, b[:])
+	}
+	switch {
+	case err == io.EOF:
+		return -1, nil
+	case err != nil:
+		return 0, streamException(err)
+	}
+	return int32(b[0]), nil
+}
+
+// streamRead reads up to length bytes into the array at offset; -1 at end of stream
+func streamRead(this, array int64, offset, length int32) (int32, error) {
+	buf, err := checkRange(array, offset, length)
+	if err != nil {
+		return 0, err
+	}
+	r := readerFor(this)
+	if r == nil {
+		return 0, errStreamClosed
+	}
+	if length == 0 {
+		return 0, nil
+	}
+	n, err := r.Read(buf[offset : offset+length])
+	if n == 0 && err == io.EOF {
+		return -1, nil
+	} else if n == 0 && err != nil {
+		return 0, streamException(err)
+	}
+	return int32(n), nil
+}
+
+func streamWrite(this, array int64, offset, length int32) error {
+	buf, err := checkRange(array, offset, length)
+	if err != nil {
+		return err
+	}
+	w := writerFor(this)
+	if w == nil {
+		return errStreamClosed
+	}
+	if _, err = w.Write(buf[offset : offset+length]); err != nil {
+		return streamException(err)
+	}
+	return nil
+}
+
+// streamClose closes the stream, unless it's one of the standard streams
+func streamClose(this int64) {
+	if obj := GetObject(this); obj != nil {
+		if c, ok := obj.Native.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+}
+
+func Load_Io_Streams() map[string]GMeth {
+	inStream := "java/io/InputStream"
+	addNative(inStream+".read()I", false, streamReadByte) // from System.in, a socket, etc.
+	addNative(inStream+".read([BII)I", false, streamRead)
+	addNative(inStream+".read([B)I", false, func(this, array int64) (int32, error) {
+		buf, _ := ByteArrayFromRef(array)
+		return streamRead(this, array, 0, int32(len(buf)))
+	})
+	addNative(inStream+".available()I", false, func(this int64) int32 {
+		if br, ok := readerFor(this).(interface{ Buffered() int }); ok {
+			return int32(br.Buffered())
+		}
+		return 0
+	})
+	addNative(inStream+".close()V", false, streamClose)
+
+	outStream := "java/io/OutputStream"
+	addNative(outStream+".write(I)V", false, func(this int64, b int32) error {
+		w := writerFor(this)
+		if w == nil {
+			return errStreamClosed
+		}
+		if _, err := w.Write([]byte{byte(b)}); err != nil {
+			return streamException(err)
+		}
+		return nil
+	})
+	addNative(outStream+".write([B)V", false, func(this, array int64) error {
+		buf, _ := ByteArrayFromRef(array)
+		return streamWrite(this, array, 0, int32(len(buf)))
+	})
+	addNative(outStream+".write([BII)V", false, streamWrite)
+	addNative(outStream+".flush()V", false, func(this int64) {}) // writes are unbuffered
+	addNative(outStream+".close()V", false, streamClose)
+
+	// a PrintStream wrapped around another stream writes to that stream's Go writer
+	ps := "java/io/PrintStream"
+	psInit := func(this, out int64) {
+		if obj := GetObject(this); obj != nil {
+			obj.Native = writerFor(out)
+		}
+	}
+	addNative(ps+".<init>(Ljava/io/OutputStream;)V", false, psInit)
+	addNative(ps+".<init>(Ljava/io/OutputStream;Z)V", false, func(this, out int64, autoFlush bool) {
+		psInit(this, out)
+	})
+	return MethodSignatures
+}
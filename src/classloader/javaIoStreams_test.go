@@ -0,0 +1,61 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// a FileInputStream read via a variable of static type InputStream uses the generic natives
+func TestInputStreamDispatchesToFile(t *testing.T) {
+	Load_Io_Streams()
+	Load_Io_FileStreams()
+	dir, err := ioutil.TempDir("", "jacobin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "in.txt")
+	if err = ioutil.WriteFile(path, []byte("xyz"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	in := NewObject("java/io/FileInputStream", 0)
+	callNative(t, "java/io/FileInputStream.<init>(Ljava/lang/String;)V", in, NewStringObject(path))
+	if callNative(t, "java/io/InputStream.read()I", in) != int64('x') {
+		t.Errorf("Expected InputStream.read() to read from the file")
+	}
+	buf := NewByteArray(make([]byte, 4))
+	if n := callNative(t, "java/io/InputStream.read([B)I", in, buf).(int64); n != 2 {
+		t.Errorf("Expected to read the remaining 2 bytes, got: %d", n)
+	}
+	callNative(t, "java/io/InputStream.close()V", in)
+	if err := callNative(t, "java/io/InputStream.read()I", in); err != errStreamClosed {
+		t.Errorf("Expected reading a closed stream to throw IOException, got: %v", err)
+	}
+	ret := callNative(t, "java/io/InputStream.read([BII)I", in, buf, int64(2), int64(3))
+	if err, _ := ret.(error); err == nil ||
+		err.Error() != "java.lang.IndexOutOfBoundsException: Range [2, 2 + 3) out of bounds for length 4" {
+		t.Errorf("Expected a read past the end of the array to throw, got: %v", ret)
+	}
+}
+
+func TestOutputStreamToStdout(t *testing.T) {
+	Load_Io_Streams()
+	InitStdStreams()
+	out := staticRef("java/lang/System.out")
+	stdout, _ := captureOutput(func() {
+		callNative(t, "java/io/OutputStream.write(I)V", out, int64('!'))
+		callNative(t, "java/io/OutputStream.write([B)V", out, NewByteArray([]byte("ok")))
+	})
+	if stdout != "!ok" {
+		t.Errorf("Expected \"!ok\" on stdout, got: %q", stdout)
+	}
+}
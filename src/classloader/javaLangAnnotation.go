@@ -0,0 +1,536 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Runtime annotations: the RuntimeVisibleAnnotations attributes of classes, fields, and
+// methods, as returned by Class.getAnnotation(), Method.getAnnotation(), etc. (JVMS 4.7.16)
+//
+// As in the JDK, an annotation instance is a proxy that implements the annotation
+// interface: its element methods return the values in the class file or, for elements
+// that aren't given, the defaults in the AnnotationDefault attributes of the annotation
+// interface's methods (JVMS 4.7.22). Each annotated element's annotations are created
+// once, so repeated calls of getAnnotation() return the same instance.
+//
+// Enum-valued elements return the enum constant only if the enum class's static
+// initializer has run. Elements of primitive types can be read via the element methods,
+// but getDefaultValue() returns null for them until boxing is supported.
+
+type annotation struct {
+	typeName string // the annotation interface, e.g., java/lang/Deprecated
+	elements []annotationElement
+}
+
+type annotationElement struct {
+	name  string
+	value elementValue
+}
+
+// elementValue is an element_value structure (JVMS 4.7.16.1). Which fields are used
+// depends on the tag: num for the integral types (B, C, I, J, S, Z), fp for F and D,
+// str for strings (s), class descriptors (c) and enum constant names (e), enumType for
+// enums, nested for annotations (@), and array for arrays ([).
+type elementValue struct {
+	tag      byte
+	num      int64
+	fp       float64
+	str      string
+	enumType string
+	nested   *annotation
+	array    []elementValue
+}
+
+var errBadAnnotation = errors.New("malformed annotation attribute")
+
+// annotationReader reads annotations and element values from an attribute, and the
+// CP entries that other attributes refer to
+type annotationReader struct {
+	data []byte
+	pos  int
+	cp   *CPool
+	err  error
+}
+
+func (r *annotationReader) u1() int {
+	if r.pos+1 > len(r.data) {
+		r.err = errBadAnnotation
+		return 0
+	}
+	r.pos++
+	return int(r.data[r.pos-1])
+}
+
+func (r *annotationReader) u2() int {
+	if r.pos+2 > len(r.data) {
+		r.err = errBadAnnotation
+		return 0
+	}
+	r.pos += 2
+	return int(r.data[r.pos-2])<<8 | int(r.data[r.pos-1])
+}
+
+// constant returns the CP entry at the index, if it's of the given type
+func (r *annotationReader) constant(index int, cpType uint16) CpEntry {
+	if index <= 0 || index >= len(r.cp.CpIndex) || r.cp.CpIndex[index].Type != cpType {
+		r.err = errBadAnnotation
+		return CpEntry{}
+	}
+	return r.cp.CpIndex[index]
+}
+
+func (r *annotationReader) utf8() string {
+	return r.utf8At(r.u2())
+}
+
+// utf8At returns the string of the UTF8 entry at the index in the CP
+func (r *annotationReader) utf8At(index int) string {
+	e := r.constant(index, UTF8)
+	if r.err != nil || int(e.Slot) >= len(r.cp.Utf8Refs) {
+		r.err = errBadAnnotation
+		return ""
+	}
+	return r.cp.Utf8Refs[e.Slot]
+}
+
+// class returns the name of the class of the ClassRef entry at the index in the CP
+func (r *annotationReader) class(index int) string {
+	e := r.constant(index, ClassRef)
+	if r.err != nil || int(e.Slot) >= len(r.cp.ClassRefs) {
+		r.err = errBadAnnotation
+		return ""
+	}
+	return r.utf8At(int(r.cp.ClassRefs[e.Slot]))
+}
+
+func (r *annotationReader) annotation() *annotation {
+	desc := r.utf8()
+	a := &annotation{typeName: strings.TrimSuffix(strings.TrimPrefix(desc, "L"), ";")}
+	count := r.u2()
+	for i := 0; i < count && r.err == nil; i++ {
+		name := r.utf8()
+		a.elements = append(a.elements, annotationElement{name: name, value: r.elementValue()})
+	}
+	return a
+}
+
+func (r *annotationReader) elementValue() elementValue {
+	ev := elementValue{tag: byte(r.u1())}
+	switch ev.tag {
+	case 'B', 'C', 'I', 'S', 'Z':
+		if e := r.constant(r.u2(), IntConst); r.err == nil && int(e.Slot) < len(r.cp.IntConsts) {
+			ev.num = int64(r.cp.IntConsts[e.Slot])
+		}
+	case 'J':
+		if e := r.constant(r.u2(), LongConst); r.err == nil && int(e.Slot) < len(r.cp.LongConsts) {
+			ev.num = r.cp.LongConsts[e.Slot]
+		}
+	case 'F':
+		if e := r.constant(r.u2(), FloatConst); r.err == nil && int(e.Slot) < len(r.cp.Floats) {
+			ev.fp = float64(r.cp.Floats[e.Slot])
+		}
+	case 'D':
+		if e := r.constant(r.u2(), DoubleConst); r.err == nil && int(e.Slot) < len(r.cp.Doubles) {
+			ev.fp = r.cp.Doubles[e.Slot]
+		}
+	case 's', 'c':
+		ev.str = r.utf8()
+	case 'e':
+		ev.enumType = r.utf8()
+		ev.str = r.utf8()
+	case '@':
+		ev.nested = r.annotation()
+	case '[':
+		count := r.u2()
+		for i := 0; i < count && r.err == nil; i++ {
+			ev.array = append(ev.array, r.elementValue())
+		}
+	default:
+		r.err = errBadAnnotation
+	}
+	return ev
+}
+
+// parseAnnotations parses a RuntimeVisibleAnnotations attribute
+func parseAnnotations(cp *CPool, content []byte) ([]*annotation, error) {
+	r := &annotationReader{data: content, cp: cp}
+	count := r.u2()
+	var annotations []*annotation
+	for i := 0; i < count && r.err == nil; i++ {
+		annotations = append(annotations, r.annotation())
+	}
+	return annotations, r.err
+}
+
+// findAttribute returns the content of the named attribute, or nil if there's none
+func findAttribute(cp *CPool, attrs []Attr, name string) []byte {
+	for _, a := range attrs {
+		if int(a.AttrName) < len(cp.Utf8Refs) && cp.Utf8Refs[a.AttrName] == name {
+			return a.AttrContent
+		}
+	}
+	return nil
+}
+
+// visibleAnnotations returns the runtime-visible annotations in the attributes.
+// Malformed annotations are ignored, as the JDK ignores them.
+func visibleAnnotations(cp *CPool, attrs []Attr) []*annotation {
+	content := findAttribute(cp, attrs, "RuntimeVisibleAnnotations")
+	if content == nil {
+		return nil
+	}
+	annotations, err := parseAnnotations(cp, content)
+	if err != nil {
+		return nil
+	}
+	return annotations
+}
+
+// annotationDefault returns the default value of the annotation interface's element
+func annotationDefault(typeName, element string) (elementValue, bool) {
+	data := classData(typeName)
+	if data == nil {
+		return elementValue{}, false
+	}
+	for _, m := range data.Methods {
+		if data.CP.Utf8Refs[m.Name] != element {
+			continue
+		}
+		content := findAttribute(&data.CP, m.Attributes, "AnnotationDefault")
+		if content == nil {
+			return elementValue{}, false
+		}
+		r := &annotationReader{data: content, cp: &data.CP}
+		ev := r.elementValue()
+		return ev, r.err == nil
+	}
+	return elementValue{}, false
+}
+
+// value returns the value of the element: the one given in the annotation or the default
+func (a *annotation) value(element string) (elementValue, bool) {
+	for _, e := range a.elements {
+		if e.name == element {
+			return e.value, true
+		}
+	}
+	return annotationDefault(a.typeName, element)
+}
+
+// toSlot converts the element value to an operand-stack slot, given the descriptor of
+// the element's type
+func (ev elementValue) toSlot(desc string) int64 {
+	switch ev.tag {
+	case 'B', 'C', 'I', 'J', 'S', 'Z':
+		return ev.num
+	case 'F', 'D':
+		return SlotFromFloat(ev.fp)
+	case 's':
+		return NewStringObject(ev.str)
+	case 'c':
+		return descriptorClass(ev.str)
+	case 'e':
+		enumClass := strings.TrimSuffix(strings.TrimPrefix(ev.enumType, "L"), ";")
+		if index, present := FindStatic(enumClass + "." + ev.str); present {
+			return LoadStaticInt(index)
+		}
+		return 0 // TODO: initialize the enum class once class initialization is supported
+	case '@':
+		return newAnnotationObject(ev.nested)
+	case '[':
+		elemDesc := strings.TrimPrefix(desc, "[")
+		slots := make([]int64, len(ev.array))
+		for i, e := range ev.array {
+			slots[i] = e.toSlot(elemDesc)
+		}
+		switch elemDesc {
+		case "B":
+			b := make([]byte, len(slots))
+			for i, s := range slots {
+				b[i] = byte(s)
+			}
+			return NewByteArray(b)
+		case "C":
+			chars := make([]uint16, len(slots))
+			for i, s := range slots {
+				chars[i] = uint16(s)
+			}
+			return NewCharArray(chars)
+		}
+		if len(elemDesc) == 1 {
+			return NewPrimitiveArray(elemDesc, slots)
+		}
+		return NewRefArray(strings.TrimSuffix(strings.TrimPrefix(elemDesc, "L"), ";"), slots)
+	}
+	return 0
+}
+
+// String formats the element value as the JDK does in an annotation's toString()
+func (ev elementValue) String() string {
+	switch ev.tag {
+	case 'B', 'I', 'S':
+		return strconv.FormatInt(ev.num, 10)
+	case 'J':
+		return strconv.FormatInt(ev.num, 10) + "L"
+	case 'Z':
+		return strconv.FormatBool(ev.num != 0)
+	case 'C':
+		return "'" + string(rune(ev.num)) + "'"
+	case 'F':
+		return strconv.FormatFloat(ev.fp, 'g', -1, 32) + "f"
+	case 'D':
+		return strconv.FormatFloat(ev.fp, 'g', -1, 64)
+	case 's':
+		return strconv.Quote(ev.str)
+	case 'e':
+		return ev.str
+	case 'c':
+		if c := descriptorClass(ev.str); c != 0 {
+			name, _ := ClassNameOf(c)
+			return strings.ReplaceAll(name, "/", ".") + ".class"
+		}
+	case '@':
+		return ev.nested.String()
+	case '[':
+		elems := make([]string, len(ev.array))
+		for i, e := range ev.array {
+			elems[i] = e.String()
+		}
+		return "{" + strings.Join(elems, ", ") + "}"
+	}
+	return ""
+}
+
+// String formats the annotation as the JDK's annotations' toString() does: the given
+// elements, in order, followed by the defaulted ones
+func (a *annotation) String() string {
+	var elems []string
+	given := make(map[string]bool)
+	for _, e := range a.elements {
+		elems = append(elems, e.name+"="+e.value.String())
+		given[e.name] = true
+	}
+	if data := classData(a.typeName); data != nil {
+		for _, m := range data.Methods {
+			name := data.CP.Utf8Refs[m.Name]
+			if ev, ok := annotationDefault(a.typeName, name); ok && !given[name] {
+				elems = append(elems, name+"="+ev.String())
+			}
+		}
+	}
+	return "@" + strings.ReplaceAll(a.typeName, "/", ".") + "(" + strings.Join(elems, ", ") + ")"
+}
+
+// annotationInstance is the Go-side state of an annotation object
+type annotationInstance struct {
+	ann *annotation
+}
+
+func newAnnotationObject(a *annotation) int64 {
+	ref := NewObject(a.typeName, 0)
+	GetObject(ref).Native = &annotationInstance{ann: a}
+	return ref
+}
+
+// invoke implements the annotation interface's methods and those of Annotation.
+// hashCode() is computed from the toString() form rather than as Annotation specifies.
+func (ai *annotationInstance) invoke(methodName, methodType string, args []int64) interface{} {
+	switch methodName + methodType {
+	case "annotationType()Ljava/lang/Class;":
+		return ClassObject(ai.ann.typeName)
+	case "toString()Ljava/lang/String;":
+		return NewStringObject(ai.ann.String())
+	case "hashCode()I":
+		h := int32(0)
+		for _, c := range ai.ann.String() {
+			h = 31*h + int32(c)
+		}
+		return int64(h)
+	case "equals(Ljava/lang/Object;)Z":
+		if other := GetObject(args[0]); other != nil {
+			if o, ok := other.Native.(*annotationInstance); ok && o.ann.String() == ai.ann.String() {
+				return int64(1)
+			}
+		}
+		return int64(0)
+	}
+	if !strings.HasPrefix(methodType, "()") {
+		return errors.New("java.lang.AssertionError: Too many parameters for an annotation method")
+	}
+	if ev, ok := ai.ann.value(methodName); ok {
+		return ev.toSlot(methodType[2:])
+	}
+	return errors.New("java.lang.annotation.IncompleteAnnotationException: " +
+		strings.ReplaceAll(ai.ann.typeName, "/", ".") + " missing element " + methodName)
+}
+
+// the annotation objects of each annotated element, keyed by the element: the class
+// name, or the class name followed by "." and the name (and descriptor) of the member
+var annotationObjects = make(map[string][]int64)
+var annotationsMutex sync.Mutex
+
+func annotationObjectsFor(key string, parse func() []*annotation) []int64 {
+	annotationsMutex.Lock()
+	refs, present := annotationObjects[key]
+	annotationsMutex.Unlock()
+	if present {
+		return refs
+	}
+
+	refs = []int64{}
+	for _, a := range parse() {
+		refs = append(refs, newAnnotationObject(a))
+	}
+	annotationsMutex.Lock()
+	defer annotationsMutex.Unlock()
+	if existing, present := annotationObjects[key]; present { // another thread got there first
+		return existing
+	}
+	annotationObjects[key] = refs
+	return refs
+}
+
+// classAnnotations returns the annotations declared on the class
+func classAnnotations(className string) []int64 {
+	return annotationObjectsFor(className, func() []*annotation {
+		if data := classData(className); data != nil {
+			return visibleAnnotations(&data.CP, data.Attributes)
+		}
+		return nil
+	})
+}
+
+// inheritedClassAnnotations returns the annotations declared on the class plus those
+// its superclasses declare whose types are annotated @Inherited, as getAnnotations()
+// reports them
+func inheritedClassAnnotations(className string) []int64 {
+	refs := append([]int64(nil), classAnnotations(className)...)
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		seen[GetObject(ref).Klass] = true
+	}
+	for c := superclassOf(className); c != ""; c = superclassOf(c) {
+		for _, ref := range classAnnotations(c) {
+			typeName := GetObject(ref).Klass
+			if !seen[typeName] && isInherited(typeName) {
+				refs = append(refs, ref)
+				seen[typeName] = true
+			}
+		}
+	}
+	return refs
+}
+
+// isInherited reports whether the annotation interface is annotated @Inherited
+func isInherited(typeName string) bool {
+	for _, ref := range classAnnotations(typeName) {
+		if GetObject(ref).Klass == "java/lang/annotation/Inherited" {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldAnnotations(f *reflectField) []int64 {
+	return annotationObjectsFor(f.class+"."+f.name, func() []*annotation {
+		if data := classData(f.class); data != nil && f.slot >= 0 && f.slot < len(data.Fields) {
+			return visibleAnnotations(&data.CP, data.Fields[f.slot].Attributes)
+		}
+		return nil
+	})
+}
+
+func methodAnnotations(m *reflectMethod) []int64 {
+	return annotationObjectsFor(m.class+"."+m.name+m.desc, func() []*annotation {
+		if data := classData(m.class); data != nil {
+			if meth := data.MethodFor(SymbolFor(m.name, m.desc)); meth != nil {
+				return visibleAnnotations(&data.CP, meth.Attributes)
+			}
+		}
+		return nil
+	})
+}
+
+// annotationOfType returns the annotation of the type the Class object refers to, or 0
+func annotationOfType(refs []int64, classRef int64) int64 {
+	typeName, _ := ClassNameOf(classRef)
+	for _, ref := range refs {
+		if GetObject(ref).Klass == typeName {
+			return ref
+		}
+	}
+	return 0
+}
+
+func Load_Lang_Annotation() map[string]GMeth {
+	ann := "Ljava/lang/annotation/Annotation;"
+	annArray := func(refs []int64) int64 {
+		return NewRefArray("java/lang/annotation/Annotation", append([]int64(nil), refs...))
+	}
+
+	class := "java/lang/Class"
+	className := func(ref int64) string {
+		name, _ := ClassNameOf(ref)
+		return name
+	}
+	addNative(class+".getAnnotation(Ljava/lang/Class;)"+ann, false, func(this, annClass int64) int64 {
+		return annotationOfType(inheritedClassAnnotations(className(this)), annClass)
+	})
+	addNative(class+".getDeclaredAnnotation(Ljava/lang/Class;)"+ann, false, func(this, annClass int64) int64 {
+		return annotationOfType(classAnnotations(className(this)), annClass)
+	})
+	addNative(class+".isAnnotationPresent(Ljava/lang/Class;)Z", false, func(this, annClass int64) bool {
+		return annotationOfType(inheritedClassAnnotations(className(this)), annClass) != 0
+	})
+	addNative(class+".getAnnotations()["+ann, false, func(this int64) int64 {
+		return annArray(inheritedClassAnnotations(className(this)))
+	})
+	addNative(class+".getDeclaredAnnotations()["+ann, false, func(this int64) int64 {
+		return annArray(classAnnotations(className(this)))
+	})
+	addNative(class+".isAnnotation()Z", false, func(this int64) bool {
+		data := classData(className(this))
+		return data != nil && data.Access.ClassIsAnnotation
+	})
+
+	// fields and methods don't inherit annotations, so their getAnnotations() and
+	// getDeclaredAnnotations() are the same
+	for _, member := range []string{"java/lang/reflect/Field", "java/lang/reflect/Method"} {
+		member := member
+		annotationsOf := func(ref int64) []int64 {
+			if strings.HasSuffix(member, "Field") {
+				return fieldAnnotations(fieldOf(ref))
+			}
+			return methodAnnotations(methodOf(ref))
+		}
+		addNative(member+".getAnnotation(Ljava/lang/Class;)"+ann, false, func(this, annClass int64) int64 {
+			return annotationOfType(annotationsOf(this), annClass)
+		})
+		addNative(member+".isAnnotationPresent(Ljava/lang/Class;)Z", false, func(this, annClass int64) bool {
+			return annotationOfType(annotationsOf(this), annClass) != 0
+		})
+		for _, method := range []string{"getAnnotations", "getDeclaredAnnotations"} {
+			addNative(member+"."+method+"()["+ann, false, func(this int64) int64 {
+				return annArray(annotationsOf(this))
+			})
+		}
+	}
+	addNative("java/lang/reflect/Method.getDefaultValue()Ljava/lang/Object;", false, func(this int64) int64 {
+		m := methodOf(this)
+		ev, ok := annotationDefault(m.class, m.name)
+		if !ok || len(m.desc) == 3 { // ()X: a primitive type
+			return 0 // TODO: box primitive defaults once boxing is supported
+		}
+		return ev.toSlot(strings.TrimPrefix(m.desc, "()"))
+	})
+	return MethodSignatures
+}
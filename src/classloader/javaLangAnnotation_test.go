@@ -0,0 +1,204 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+// testConstants adds entries to a test class's CP and returns their CP indexes
+type testConstants struct {
+	cp *CPool
+}
+
+func (tc testConstants) add(cpType uint16, slot int) []byte {
+	if len(tc.cp.CpIndex) == 0 {
+		tc.cp.CpIndex = append(tc.cp.CpIndex, CpEntry{}) // entry 0 is unused
+	}
+	tc.cp.CpIndex = append(tc.cp.CpIndex, CpEntry{Type: cpType, Slot: uint16(slot)})
+	i := len(tc.cp.CpIndex) - 1
+	return []byte{byte(i >> 8), byte(i)}
+}
+
+func (tc testConstants) utf8(s string) []byte {
+	tc.cp.Utf8Refs = append(tc.cp.Utf8Refs, s)
+	return tc.add(UTF8, len(tc.cp.Utf8Refs)-1)
+}
+
+func (tc testConstants) int(i int32) []byte {
+	tc.cp.IntConsts = append(tc.cp.IntConsts, i)
+	return tc.add(IntConst, len(tc.cp.IntConsts)-1)
+}
+
+// attr returns an attribute with the given name and content
+func (tc testConstants) attr(name string, content ...[]byte) Attr {
+	tc.cp.Utf8Refs = append(tc.cp.Utf8Refs, name)
+	var b []byte
+	for _, c := range content {
+		b = append(b, c...)
+	}
+	return Attr{AttrName: uint16(len(tc.cp.Utf8Refs) - 1), AttrSize: len(b), AttrContent: b}
+}
+
+func u2(i int) []byte { return []byte{byte(i >> 8), byte(i)} }
+
+// addAnnotationTypes adds the annotation interfaces @test/Tag(String name, int count,
+// int level() default 7) and @test/Marker, which is @Inherited
+func addAnnotationTypes() {
+	addTestClass("test/Tag", "java/lang/Object", []string{"java/lang/annotation/Annotation"}, nil,
+		[]testMember{{0x0401, "name", "()Ljava/lang/String;"}, {0x0401, "count", "()I"},
+			{0x0401, "level", "()I"}})
+	tag := classData("test/Tag")
+	tag.Access.ClassIsAnnotation = true
+	tc := testConstants{&tag.CP}
+	tag.Methods[2].Attributes = []Attr{tc.attr("AnnotationDefault", []byte{'I'}, tc.int(7))}
+
+	addTestClass("test/Marker", "java/lang/Object", []string{"java/lang/annotation/Annotation"}, nil, nil)
+	marker := classData("test/Marker")
+	tc = testConstants{&marker.CP}
+	marker.Attributes = []Attr{tc.attr("RuntimeVisibleAnnotations",
+		u2(1), tc.utf8("Ljava/lang/annotation/Inherited;"), u2(0))}
+	addTestClass("java/lang/annotation/Inherited", "java/lang/Object", nil, nil, nil)
+}
+
+func TestClassAnnotationValuesAndDefaults(t *testing.T) {
+	Load_Lang_Reflect()
+	Load_Lang_Annotation()
+	addAnnotationTypes()
+	addTestClass("test/Tagged", "java/lang/Object", nil, nil, nil)
+	data := classData("test/Tagged")
+	tc := testConstants{&data.CP}
+	data.Attributes = []Attr{tc.attr("RuntimeVisibleAnnotations", u2(1),
+		tc.utf8("Ltest/Tag;"), u2(2),
+		tc.utf8("name"), []byte{'s'}, tc.utf8("gold"),
+		tc.utf8("count"), []byte{'I'}, tc.int(3))}
+
+	class := ClassObject("test/Tagged")
+	ann := callNative(t, "java/lang/Class.getAnnotation(Ljava/lang/Class;)Ljava/lang/annotation/Annotation;",
+		class, ClassObject("test/Tag")).(int64)
+	if ann == 0 {
+		t.Fatal("Expected test/Tagged to be annotated @Tag")
+	}
+	call := func(name, desc string) interface{} {
+		mt, ok := ProxyMethod(ann, name, desc)
+		if !ok {
+			t.Fatalf("Expected the annotation to be a proxy")
+		}
+		return mt.Meth.(GmEntry).Fu([]interface{}{ann})
+	}
+	if name := javaString(call("name", "()Ljava/lang/String;").(int64)); name != "gold" {
+		t.Errorf("Expected name() to be gold, got %s", name)
+	}
+	if count := call("count", "()I"); count != int64(3) {
+		t.Errorf("Expected count() to be 3, got %v", count)
+	}
+	if level := call("level", "()I"); level != int64(7) {
+		t.Errorf("Expected level() to default to 7, got %v", level)
+	}
+	if call("annotationType", "()Ljava/lang/Class;") != ClassObject("test/Tag") {
+		t.Error("Expected annotationType() to be test.Tag")
+	}
+	want := `@test.Tag(name="gold", count=3, level=7)`
+	if s := javaString(call("toString", "()Ljava/lang/String;").(int64)); s != want {
+		t.Errorf("Expected %s, got %s", want, s)
+	}
+
+	again := callNative(t, "java/lang/Class.getAnnotation(Ljava/lang/Class;)Ljava/lang/annotation/Annotation;",
+		class, ClassObject("test/Tag"))
+	if again != ann {
+		t.Error("Expected getAnnotation() to return the same instance each time")
+	}
+	if callNative(t, "java/lang/Class.isAnnotationPresent(Ljava/lang/Class;)Z", class, ClassObject("test/Marker")) != int64(0) {
+		t.Error("Expected test/Tagged not to be annotated @Marker")
+	}
+	if callNative(t, "java/lang/Class.isAnnotation()Z", ClassObject("test/Tag")) != int64(1) {
+		t.Error("Expected test/Tag to be an annotation interface")
+	}
+}
+
+// an element that has neither a value nor a default is incomplete
+func TestIncompleteAnnotation(t *testing.T) {
+	Load_Lang_Reflect()
+	Load_Lang_Annotation()
+	addAnnotationTypes()
+	addTestClass("test/Untagged", "java/lang/Object", nil, nil, nil)
+	data := classData("test/Untagged")
+	tc := testConstants{&data.CP}
+	data.Attributes = []Attr{tc.attr("RuntimeVisibleAnnotations", u2(1),
+		tc.utf8("Ltest/Tag;"), u2(1), tc.utf8("count"), []byte{'I'}, tc.int(1))}
+
+	ann := callNative(t, "java/lang/Class.getAnnotation(Ljava/lang/Class;)Ljava/lang/annotation/Annotation;",
+		ClassObject("test/Untagged"), ClassObject("test/Tag")).(int64)
+	mt, ok := ProxyMethod(ann, "name", "()Ljava/lang/String;")
+	if !ok {
+		t.Fatal("Expected the annotation to be a proxy")
+	}
+	err, _ := mt.Meth.(GmEntry).Fu([]interface{}{ann}).(error)
+	if err == nil || err.Error() != "java.lang.annotation.IncompleteAnnotationException: test.Tag missing element name" {
+		t.Errorf("Expected an IncompleteAnnotationException, got %v", err)
+	}
+}
+
+func TestInheritedClassAnnotations(t *testing.T) {
+	Load_Lang_Annotation()
+	addAnnotationTypes()
+	addTestClass("test/MarkedBase", "java/lang/Object", nil, nil, nil)
+	data := classData("test/MarkedBase")
+	tc := testConstants{&data.CP}
+	data.Attributes = []Attr{tc.attr("RuntimeVisibleAnnotations", u2(1), tc.utf8("Ltest/Marker;"), u2(0))}
+	addTestClass("test/MarkedSub", "test/MarkedBase", nil, nil, nil)
+
+	sub := ClassObject("test/MarkedSub")
+	if callNative(t, "java/lang/Class.isAnnotationPresent(Ljava/lang/Class;)Z", sub, ClassObject("test/Marker")) != int64(1) {
+		t.Error("Expected the @Inherited annotation to be present on the subclass")
+	}
+	declared, _ := RefArrayFromRef(callNative(t,
+		"java/lang/Class.getDeclaredAnnotations()[Ljava/lang/annotation/Annotation;", sub).(int64))
+	if len(declared) != 0 {
+		t.Errorf("Expected no declared annotations on the subclass, got %d", len(declared))
+	}
+}
+
+func TestMemberAnnotations(t *testing.T) {
+	Load_Lang_Reflect()
+	Load_Lang_Annotation()
+	addAnnotationTypes()
+	addTestClass("test/Members", "java/lang/Object", nil,
+		[]testMember{{0x0001, "plain", "I"}, {0x0001, "marked", "I"}},
+		[]testMember{{0x0001, "run", "()V"}})
+	data := classData("test/Members")
+	tc := testConstants{&data.CP}
+	data.Fields[1].Attributes = []Attr{tc.attr("RuntimeVisibleAnnotations", u2(1), tc.utf8("Ltest/Marker;"), u2(0))}
+	data.Methods[0].Attributes = []Attr{tc.attr("RuntimeVisibleAnnotations", u2(1),
+		tc.utf8("Ltest/Tag;"), u2(1), tc.utf8("count"), []byte{'I'}, tc.int(-1))}
+
+	class := ClassObject("test/Members")
+	fields, _ := RefArrayFromRef(callNative(t, "java/lang/Class.getDeclaredFields()[Ljava/lang/reflect/Field;", class).(int64))
+	isPresent := "java/lang/reflect/Field.isAnnotationPresent(Ljava/lang/Class;)Z"
+	if callNative(t, isPresent, fields[0], ClassObject("test/Marker")) != int64(0) ||
+		callNative(t, isPresent, fields[1], ClassObject("test/Marker")) != int64(1) {
+		t.Error("Expected only the field marked to be annotated @Marker")
+	}
+
+	methods, _ := RefArrayFromRef(callNative(t, "java/lang/Class.getDeclaredMethods()[Ljava/lang/reflect/Method;", class).(int64))
+	anns, _ := RefArrayFromRef(callNative(t,
+		"java/lang/reflect/Method.getAnnotations()[Ljava/lang/annotation/Annotation;", methods[0]).(int64))
+	if len(anns) != 1 {
+		t.Fatalf("Expected 1 annotation on run(), got %d", len(anns))
+	}
+	mt, _ := ProxyMethod(anns[0], "count", "()I")
+	if count := mt.Meth.(GmEntry).Fu([]interface{}{anns[0]}); count != int64(-1) {
+		t.Errorf("Expected count() to be -1, got %v", count)
+	}
+}
+
+func TestMalformedAnnotationsAreIgnored(t *testing.T) {
+	cp := &CPool{Utf8Refs: []string{""}}
+	tc := testConstants{cp}
+	attrs := []Attr{tc.attr("RuntimeVisibleAnnotations", u2(1), tc.utf8("Ltest/Tag;"), u2(1), tc.utf8("count"), []byte{'I'})}
+	if anns := visibleAnnotations(cp, attrs); anns != nil {
+		t.Errorf("Expected a truncated attribute to yield no annotations, got %d", len(anns))
+	}
+}
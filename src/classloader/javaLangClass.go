@@ -0,0 +1,68 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"strings"
+	"sync"
+)
+
+// java.lang.Class objects. Until reflection is implemented, a Class object is simply
+// a handle for a class: its Native field holds the class name (in java/lang/Object
+// format). There's one Class object per class, as the JLS requires, so Class objects
+// can be compared by reference.
+
+type classMirror struct {
+	name string
+}
+
+var classObjects = make(map[string]int64)
+var classObjectsMutex sync.Mutex
+
+// ClassObject returns the reference to the Class object for the named class,
+// creating it the first time it's requested.
+func ClassObject(className string) int64 {
+	classObjectsMutex.Lock()
+	defer classObjectsMutex.Unlock()
+	ref, present := classObjects[className]
+	if !present {
+		ref = NewObject("java/lang/Class", 0)
+		GetObject(ref).Native = &classMirror{name: className}
+		classObjects[className] = ref
+	}
+	return ref
+}
+
+// ClassNameOf returns the name of the class the Class object refers to. If the
+// reference is not to a Class object, the second return value is false.
+func ClassNameOf(ref int64) (string, bool) {
+	obj := GetObject(ref)
+	if obj == nil {
+		return "", false
+	}
+	mirror, ok := obj.Native.(*classMirror)
+	if !ok {
+		return "", false
+	}
+	return mirror.name, true
+}
+
+func Load_Lang_Class() map[string]GMeth {
+	addNative("java/lang/Class.getName()Ljava/lang/String;", false, func(this int64) int64 {
+		name, _ := ClassNameOf(this)
+		return NewStringObject(strings.ReplaceAll(name, "/", "."))
+	})
+	addNative("java/lang/Object.getClass()Ljava/lang/Class;", false, func(this int64) (int64, error) {
+		obj := GetObject(this)
+		if obj == nil {
+			return 0, errNPE
+		}
+		return ClassObject(obj.Klass), nil
+	})
+	loadNestedClassNatives()
+	return MethodSignatures
+}
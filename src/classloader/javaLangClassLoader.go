@@ -0,0 +1,355 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/globals"
+	"jacobin/util"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// java.lang.ClassLoader objects stand for Jacobin's classloaders (see classloader.go).
+// As in the JDK, the bootstrap loader is represented by null; the system (application)
+// loader is an AppClassLoader whose parent is the PlatformClassLoader, which stands for
+// the extension loader. Each ClassLoader object's Native field points to its loader.
+//
+// A loader finds resources in its roots after its parent has looked in its own, as
+// the JDK's loaders delegate: the bootstrap loader's root is JACOBIN_HOME's classes
+// directory, and the application loader's roots are the entries of java.class.path
+// and the Class-Path entries of the JARs' manifests (see jarManifest.go).
+// Resources are returned as file: URLs, which are implemented in Go, and their
+// contents are read through FileInputStreams.
+
+var classLoaderObjects = make(map[string]int64)
+var classLoaderMutex sync.Mutex
+
+// the JDK classes of the ClassLoader objects for each loader
+var classLoaderClasses = map[string]string{
+	"extension": "jdk/internal/loader/ClassLoaders$PlatformClassLoader",
+	"app":       "jdk/internal/loader/ClassLoaders$AppClassLoader",
+}
+
+// loaderNamed returns the classloader with the given name, or nil if there's none
+func loaderNamed(name string) *Classloader {
+	for _, cl := range []*Classloader{&BootstrapCL, &ExtensionCL, &AppCL} {
+		if cl.Name == name {
+			return cl
+		}
+	}
+	return nil
+}
+
+// ClassLoaderObject returns the ClassLoader object for the named loader, creating it
+// the first time it's requested. The bootstrap loader (and any unknown loader) is null.
+func ClassLoaderObject(loaderName string) int64 {
+	class, present := classLoaderClasses[loaderName]
+	if !present {
+		return 0
+	}
+	classLoaderMutex.Lock()
+	defer classLoaderMutex.Unlock()
+	ref, present := classLoaderObjects[loaderName]
+	if !present {
+		ref = NewObject(class, 0)
+		GetObject(ref).Native = loaderNamed(loaderName)
+		classLoaderObjects[loaderName] = ref
+	}
+	return ref
+}
+
+// loaderOf returns the classloader the ClassLoader object stands for. Null is the
+// bootstrap loader.
+func loaderOf(ref int64) *Classloader {
+	if obj := GetObject(ref); obj != nil {
+		if cl, ok := obj.Native.(*Classloader); ok {
+			return cl
+		}
+	}
+	return &BootstrapCL
+}
+
+// delegatesTo reports whether the loader is, or delegates to, the named loader
+func (cl *Classloader) delegatesTo(name string) bool {
+	for l := cl; l != nil; l = loaderNamed(l.Parent) {
+		if l.Name == name {
+			return true
+		}
+		if l.Parent == "" {
+			break
+		}
+	}
+	return false
+}
+
+// findLoadedClass returns the Class object of the named class if it has been loaded by
+// the loader or one it delegates to, or 0 if it hasn't. The name is in binary format
+// (java.lang.String).
+func (cl *Classloader) findLoadedClass(binaryName string) int64 {
+	name := strings.ReplaceAll(binaryName, ".", "/")
+	MethAreaMutex.RLock()
+	k, present := Classes[name]
+	MethAreaMutex.RUnlock()
+	if !present || k.Status == 'I' || !cl.delegatesTo(k.Loader) {
+		return 0
+	}
+	return ClassObject(name)
+}
+
+// resourceRoots returns the directories in which the loader itself looks for resources
+func (cl *Classloader) resourceRoots() []string {
+	switch cl.Name {
+	case "bootstrap":
+		return []string{globals.JacobinHome() + "classes"}
+	case "app":
+		return AppClassPath()
+	}
+	return nil
+}
+
+// findResources returns the paths of the files with the resource name, searching the
+// loader's parents' roots before its own, as the JDK's getResources() does
+func (cl *Classloader) findResources(name string) []string {
+	var paths []string
+	if parent := loaderNamed(cl.Parent); parent != nil && cl.Parent != "" {
+		paths = parent.findResources(name)
+	}
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		return paths
+	}
+	for _, root := range cl.resourceRoots() {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if info, err := statFile(path); err == nil && !info.IsDir() {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// NewURLObject returns a java.net.URL for the URL, or 0 if it can't be parsed. It's
+// given the file: URLs of resources, which always parse.
+func NewURLObject(rawURL string) int64 {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0
+	}
+	ref := NewObject("java/net/URL", 0)
+	GetObject(ref).Native = u
+	return ref
+}
+
+func urlOf(ref int64) *url.URL {
+	if obj := GetObject(ref); obj != nil {
+		if u, ok := obj.Native.(*url.URL); ok {
+			return u
+		}
+	}
+	return &url.URL{}
+}
+
+// fileURL returns the file: URL of the path. A path in a replacement file system
+// (see SetFileSystem()) is already relative to its root.
+func fileURL(path string) int64 {
+	abs := "/" + fsPath(path)
+	if classFiles == nil {
+		var err error
+		if abs, err = filepath.Abs(path); err != nil {
+			abs = path
+		}
+	}
+	return NewURLObject((&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String())
+}
+
+// openResource returns a FileInputStream over the file, or 0 if it can't be opened. A
+// file in a replacement file system is read by a plain InputStream.
+func openResource(path string) int64 {
+	f, err := openFile(path)
+	if err != nil {
+		return 0
+	}
+	ref := NewObject("java/io/FileInputStream", 0)
+	if classFiles != nil {
+		ref = NewObject("java/io/InputStream", 0)
+	}
+	GetObject(ref).Native = f
+	return ref
+}
+
+// refEnumeration is an Enumeration over object references, such as the one returned
+// by ClassLoader.getResources()
+type refEnumeration struct {
+	elems []int64
+	next  int
+}
+
+// NewEnumerationObject returns an Enumeration of the referenced objects
+func NewEnumerationObject(elems []int64) int64 {
+	ref := NewObject("java/util/Enumeration", 0)
+	GetObject(ref).Native = &refEnumeration{elems: elems}
+	return ref
+}
+
+func enumerationOf(ref int64) *refEnumeration {
+	if obj := GetObject(ref); obj != nil {
+		if e, ok := obj.Native.(*refEnumeration); ok {
+			return e
+		}
+	}
+	return &refEnumeration{}
+}
+
+func Load_Lang_ClassLoader() map[string]GMeth {
+	cl := "java/lang/ClassLoader"
+	addNative(cl+".getSystemClassLoader()Ljava/lang/ClassLoader;", true, func() int64 {
+		return ClassLoaderObject(AppCL.Name)
+	})
+	addNative(cl+".getPlatformClassLoader()Ljava/lang/ClassLoader;", true, func() int64 {
+		return ClassLoaderObject(ExtensionCL.Name)
+	})
+	addNative(cl+".getParent()Ljava/lang/ClassLoader;", false, func(this int64) int64 {
+		return ClassLoaderObject(loaderOf(this).Parent)
+	})
+	addNative(cl+".getName()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(loaderOf(this).Name)
+	})
+	addNative(cl+".loadClass(Ljava/lang/String;)Ljava/lang/Class;", false, func(this, name int64) (int64, error) {
+		className := strings.ReplaceAll(javaString(name), ".", "/")
+		if LoadClassFromNameOnly(className) != nil || classData(className) == nil {
+			return 0, errors.New("java.lang.ClassNotFoundException: " + javaString(name))
+		}
+		return ClassObject(className), nil
+	})
+	for _, method := range []string{"findLoadedClass", "findLoadedClass0"} {
+		addNative(cl+"."+method+"(Ljava/lang/String;)Ljava/lang/Class;", false, func(this, name int64) int64 {
+			return loaderOf(this).findLoadedClass(javaString(name))
+		})
+	}
+
+	// findBootstrapClass() loads the class with the bootstrap loader, which finds only
+	// the classes in JACOBIN_HOME
+	for _, method := range []string{"findBootstrapClass", "findBootstrapClassOrNull"} {
+		addNative(cl+"."+method+"(Ljava/lang/String;)Ljava/lang/Class;", true, func(name int64) int64 {
+			if ref := BootstrapCL.findLoadedClass(javaString(name)); ref != 0 {
+				return ref
+			}
+			className := strings.ReplaceAll(javaString(name), ".", "/")
+			MethAreaMutex.RLock()
+			_, present := Classes[className]
+			MethAreaMutex.RUnlock()
+			if present { // loaded, or being loaded, by another loader
+				return 0
+			}
+			path := filepath.Join(globals.JacobinHome(), "classes", util.ConvertInternalClassNameToFilename(className))
+			if _, err := LoadClassFromFile(BootstrapCL, path); err != nil {
+				return 0
+			}
+			return ClassObject(className)
+		})
+	}
+
+	// resources
+	getResource := func(loader *Classloader, name int64) int64 {
+		if paths := loader.findResources(javaString(name)); len(paths) > 0 {
+			return fileURL(paths[0])
+		}
+		return 0
+	}
+	getResources := func(loader *Classloader, name int64) int64 {
+		var urls []int64
+		for _, path := range loader.findResources(javaString(name)) {
+			urls = append(urls, fileURL(path))
+		}
+		return NewEnumerationObject(urls)
+	}
+	getResourceAsStream := func(loader *Classloader, name int64) int64 {
+		if paths := loader.findResources(javaString(name)); len(paths) > 0 {
+			return openResource(paths[0])
+		}
+		return 0
+	}
+	addNative(cl+".getResource(Ljava/lang/String;)Ljava/net/URL;", false, func(this, name int64) int64 {
+		return getResource(loaderOf(this), name)
+	})
+	addNative(cl+".getResources(Ljava/lang/String;)Ljava/util/Enumeration;", false, func(this, name int64) int64 {
+		return getResources(loaderOf(this), name)
+	})
+	addNative(cl+".getResourceAsStream(Ljava/lang/String;)Ljava/io/InputStream;", false, func(this, name int64) int64 {
+		return getResourceAsStream(loaderOf(this), name)
+	})
+	addNative(cl+".getSystemResource(Ljava/lang/String;)Ljava/net/URL;", true, func(name int64) int64 {
+		return getResource(&AppCL, name)
+	})
+	addNative(cl+".getSystemResources(Ljava/lang/String;)Ljava/util/Enumeration;", true, func(name int64) int64 {
+		return getResources(&AppCL, name)
+	})
+	addNative(cl+".getSystemResourceAsStream(Ljava/lang/String;)Ljava/io/InputStream;", true, func(name int64) int64 {
+		return getResourceAsStream(&AppCL, name)
+	})
+
+	// Class.getClassLoader() returns the loader that defined the class
+	for _, method := range []string{"getClassLoader", "getClassLoader0"} {
+		addNative("java/lang/Class."+method+"()Ljava/lang/ClassLoader;", false, func(this int64) int64 {
+			name, _ := ClassNameOf(this)
+			MethAreaMutex.RLock()
+			k := Classes[name]
+			MethAreaMutex.RUnlock()
+			return ClassLoaderObject(k.Loader)
+		})
+	}
+
+	enum := "java/util/Enumeration"
+	addNative(enum+".hasMoreElements()Z", false, func(this int64) bool {
+		e := enumerationOf(this)
+		return e.next < len(e.elems)
+	})
+	addNative(enum+".nextElement()Ljava/lang/Object;", false, func(this int64) (int64, error) {
+		e := enumerationOf(this)
+		if e.next >= len(e.elems) {
+			return 0, errors.New("java.util.NoSuchElementException")
+		}
+		e.next++
+		return e.elems[e.next-1], nil
+	})
+
+	u := "java/net/URL"
+	addNative(u+".<init>(Ljava/lang/String;)V", false, func(this, spec int64) error {
+		parsed, err := url.Parse(javaString(spec))
+		if err != nil {
+			return errors.New("java.net.MalformedURLException: " + err.Error())
+		}
+		if parsed.Scheme == "" {
+			return errors.New("java.net.MalformedURLException: no protocol: " + javaString(spec))
+		}
+		GetObject(this).Native = parsed
+		return nil
+	})
+	for _, method := range []string{"toString", "toExternalForm"} {
+		addNative(u+"."+method+"()Ljava/lang/String;", false, func(this int64) int64 {
+			return NewStringObject(urlOf(this).String())
+		})
+	}
+	addNative(u+".getProtocol()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(urlOf(this).Scheme)
+	})
+	addNative(u+".getPath()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(urlOf(this).EscapedPath())
+	})
+	addNative(u+".getFile()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(urlOf(this).RequestURI())
+	})
+	addNative(u+".openStream()Ljava/io/InputStream;", false, func(this int64) int64 {
+		if parsed := urlOf(this); parsed.Scheme == "file" {
+			return openResource(filepath.FromSlash(parsed.Path))
+		}
+		return 0 // TODO: support other protocols along with java.net
+	})
+	return MethodSignatures
+}
@@ -0,0 +1,133 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io/ioutil"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassLoaderHierarchy(t *testing.T) {
+	globals.InitGlobals("test")
+	_ = Init()
+	Load_Lang_ClassLoader()
+
+	system := callNative(t, "java/lang/ClassLoader.getSystemClassLoader()Ljava/lang/ClassLoader;").(int64)
+	if system == 0 || GetObject(system).Klass != "jdk/internal/loader/ClassLoaders$AppClassLoader" {
+		t.Fatal("Expected the system class loader to be an AppClassLoader")
+	}
+	platform := callNative(t, "java/lang/ClassLoader.getParent()Ljava/lang/ClassLoader;", system)
+	if platform != callNative(t, "java/lang/ClassLoader.getPlatformClassLoader()Ljava/lang/ClassLoader;") {
+		t.Error("Expected the system class loader's parent to be the platform class loader")
+	}
+	if callNative(t, "java/lang/ClassLoader.getParent()Ljava/lang/ClassLoader;", platform) != int64(0) {
+		t.Error("Expected the platform class loader's parent to be the bootstrap loader (null)")
+	}
+}
+
+func TestFindLoadedClass(t *testing.T) {
+	globals.InitGlobals("test")
+	_ = Init()
+	Load_Lang_ClassLoader()
+	_ = insert("test/AppLoaded", Klass{Status: 'L', Loader: "app", Data: &ClData{Name: "test/AppLoaded"}})
+	_ = insert("test/BootLoaded", Klass{Status: 'L', Loader: "bootstrap", Data: &ClData{Name: "test/BootLoaded"}})
+
+	system := ClassLoaderObject("app")
+	platform := ClassLoaderObject("extension")
+	find := "java/lang/ClassLoader.findLoadedClass(Ljava/lang/String;)Ljava/lang/Class;"
+	if callNative(t, find, system, NewStringObject("test.AppLoaded")) != ClassObject("test/AppLoaded") {
+		t.Error("Expected the system loader to find the class it loaded")
+	}
+	if callNative(t, find, system, NewStringObject("test.BootLoaded")) != ClassObject("test/BootLoaded") {
+		t.Error("Expected the system loader to find a class loaded by the bootstrap loader")
+	}
+	if callNative(t, find, platform, NewStringObject("test.AppLoaded")) != int64(0) {
+		t.Error("Expected the platform loader not to find a class loaded by the system loader")
+	}
+	if callNative(t, find, system, NewStringObject("test.NotLoaded")) != int64(0) {
+		t.Error("Expected a class that isn't loaded not to be found")
+	}
+
+	loader := "java/lang/Class.getClassLoader()Ljava/lang/ClassLoader;"
+	if callNative(t, loader, ClassObject("test/AppLoaded")) != system ||
+		callNative(t, loader, ClassObject("test/BootLoaded")) != int64(0) {
+		t.Error("Expected getClassLoader() to return the defining loader")
+	}
+}
+
+func TestGetResources(t *testing.T) {
+	globals.InitGlobals("test")
+	_ = Init()
+	Load_Lang_ClassLoader()
+	Load_Lang_String()
+	dir1, _ := ioutil.TempDir("", "jacobin-cp1")
+	dir2, _ := ioutil.TempDir("", "jacobin-cp2")
+	defer os.RemoveAll(dir1)
+	defer os.RemoveAll(dir2)
+	for _, dir := range []string{dir1, dir2} {
+		_ = os.MkdirAll(filepath.Join(dir, "conf"), 0755)
+		_ = ioutil.WriteFile(filepath.Join(dir, "conf", "app.properties"), []byte(dir), 0644)
+	}
+	globals.SystemProperties.Set("java.class.path", dir1+string(os.PathListSeparator)+dir2)
+
+	enum := callNative(t, "java/lang/ClassLoader.getSystemResources(Ljava/lang/String;)Ljava/util/Enumeration;",
+		NewStringObject("conf/app.properties")).(int64)
+	var urls []string
+	for callNative(t, "java/util/Enumeration.hasMoreElements()Z", enum) == int64(1) {
+		u := callNative(t, "java/util/Enumeration.nextElement()Ljava/lang/Object;", enum).(int64)
+		urls = append(urls, javaString(callNative(t, "java/net/URL.toString()Ljava/lang/String;", u).(int64)))
+	}
+	if len(urls) != 2 || !strings.HasPrefix(urls[0], "file:") || !strings.HasSuffix(urls[1], "/conf/app.properties") {
+		t.Errorf("Expected two file: URLs, got %v", urls)
+	}
+
+	stream := callNative(t, "java/lang/ClassLoader.getResourceAsStream(Ljava/lang/String;)Ljava/io/InputStream;",
+		ClassLoaderObject("app"), NewStringObject("/conf/app.properties")).(int64)
+	f := osFileOf(stream)
+	if f == nil {
+		t.Fatal("Expected a stream over the resource")
+	}
+	defer f.Close()
+	if content, _ := ioutil.ReadAll(f); string(content) != dir1 {
+		t.Errorf("Expected the resource in the first class path entry, got %q", content)
+	}
+
+	if callNative(t, "java/lang/ClassLoader.getSystemResource(Ljava/lang/String;)Ljava/net/URL;",
+		NewStringObject("conf/missing.properties")) != int64(0) {
+		t.Error("Expected a missing resource to be null")
+	}
+}
+
+func TestClassLoaderExceptions(t *testing.T) {
+	globals.InitGlobals("test")
+	_ = Init()
+	Load_Lang_ClassLoader()
+
+	loader := ClassLoaderObject("app")
+	err, _ := callNative(t, "java/lang/ClassLoader.loadClass(Ljava/lang/String;)Ljava/lang/Class;",
+		loader, NewStringObject("test.NoSuchClass")).(error)
+	if err == nil || err.Error() != "java.lang.ClassNotFoundException: test.NoSuchClass" {
+		t.Errorf("Expected a ClassNotFoundException, got %v", err)
+	}
+
+	u := NewObject("java/net/URL", 0)
+	err, _ = callNative(t, "java/net/URL.<init>(Ljava/lang/String;)V", u, NewStringObject("conf/app.properties")).(error)
+	if err == nil || err.Error() != "java.net.MalformedURLException: no protocol: conf/app.properties" {
+		t.Errorf("Expected a MalformedURLException, got %v", err)
+	}
+
+	enum := callNative(t, "java/lang/ClassLoader.getSystemResources(Ljava/lang/String;)Ljava/util/Enumeration;",
+		NewStringObject("conf/missing.properties")).(int64)
+	err, _ = callNative(t, "java/util/Enumeration.nextElement()Ljava/lang/Object;", enum).(error)
+	if err == nil || err.Error() != "java.util.NoSuchElementException" {
+		t.Errorf("Expected a NoSuchElementException, got %v", err)
+	}
+}
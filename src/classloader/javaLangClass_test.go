@@ -0,0 +1,36 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+func TestClassObjectsAreCanonical(t *testing.T) {
+	Load_Lang_Class()
+	c := ClassObject("java/util/ArrayList")
+	if ClassObject("java/util/ArrayList") != c {
+		t.Error("Expected one Class object per class")
+	}
+	if name, ok := ClassNameOf(c); !ok || name != "java/util/ArrayList" {
+		t.Errorf("Expected java/util/ArrayList, got %q", name)
+	}
+	if _, ok := ClassNameOf(NewStringObject("x")); ok {
+		t.Error("A String is not a Class object")
+	}
+
+	ret := callNative(t, "java/lang/Class.getName()Ljava/lang/String;", c)
+	if javaString(ret.(int64)) != "java.util.ArrayList" {
+		t.Errorf("Expected java.util.ArrayList, got %s", javaString(ret.(int64)))
+	}
+	ret = callNative(t, "java/lang/Object.getClass()Ljava/lang/Class;", NewStringObject("s"))
+	if ret.(int64) != ClassObject("java/lang/String") {
+		t.Error("Expected getClass() of a String to be String's Class object")
+	}
+	err, _ := callNative(t, "java/lang/Object.getClass()Ljava/lang/Class;", int64(0)).(error)
+	if err == nil || err.Error() != "java.lang.NullPointerException" {
+		t.Errorf("Expected getClass() of null to throw NullPointerException, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"strings"
+)
+
+// java.lang.Enum. The constants of an enum are objects of the enum class (or of the
+// anonymous subclass that a constant with a body has), which the enum's <clinit>
+// creates, passing each constant's name and ordinal to the Enum constructor. The name
+// and ordinal are kept in Go, in Object.Native, which leaves the object's field slots
+// to the fields the enum itself declares. values() and valueOf(String) are generated
+// by javac; the latter calls Enum.valueOf(), which finds the constant among the enum's
+// static fields.
+
+// ACC_ENUM is the access flag that marks a field as an enum constant (JVMS 4.5)
+const ACC_ENUM = 0x4000
+
+// enumConstant is the Go-side state of an enum constant
+type enumConstant struct {
+	name    int64 // the String that is the constant's name
+	ordinal int32
+}
+
+// enumOf returns the state of the referenced enum constant, or nil if it's not one
+func enumOf(ref int64) *enumConstant {
+	if obj := GetObject(ref); obj != nil {
+		if e, ok := obj.Native.(*enumConstant); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// enumClass returns the enum class of the constant: the class of the object, unless
+// the constant has a body, in which case it's the superclass of the object's class
+func enumClass(ref int64) string {
+	obj := GetObject(ref)
+	if obj == nil {
+		return ""
+	}
+	if super := superclassOf(obj.Klass); super != "" && super != "java/lang/Enum" {
+		return super
+	}
+	return obj.Klass
+}
+
+// EnumConstant returns the constant of the enum class with the given name, or false
+// if the class has no such constant. The enum class must have been initialized.
+func EnumConstant(className, name string) (int64, bool) {
+	data := classData(className)
+	if data == nil {
+		return 0, false
+	}
+	for _, f := range data.Fields {
+		if f.AccessFlags&ACC_ENUM != 0 && data.CP.Utf8Refs[f.Name] == name {
+			if index, present := FindStatic(className + "." + name); present {
+				return LoadStaticInt(index), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func Load_Lang_Enum() map[string]GMeth {
+	enum := "java/lang/Enum"
+	addNative(enum+".<init>(Ljava/lang/String;I)V", false, func(this, name int64, ordinal int32) {
+		if obj := GetObject(this); obj != nil {
+			obj.Native = &enumConstant{name: name, ordinal: ordinal}
+		}
+	})
+	name := func(this int64) int64 {
+		if e := enumOf(this); e != nil {
+			return e.name
+		}
+		return 0
+	}
+	addNative(enum+".name()Ljava/lang/String;", false, name)
+	addNative(enum+".toString()Ljava/lang/String;", false, name)
+	addNative(enum+".ordinal()I", false, func(this int64) int32 {
+		if e := enumOf(this); e != nil {
+			return e.ordinal
+		}
+		return 0
+	})
+	addNative(enum+".compareTo(Ljava/lang/Enum;)I", false, func(this, other int64) (int32, error) {
+		e, o := enumOf(this), enumOf(other)
+		switch {
+		case e == nil || o == nil:
+			return 0, errNPE
+		case enumClass(this) != enumClass(other): // constants of different enums
+			return 0, errors.New("java.lang.ClassCastException")
+		}
+		return e.ordinal - o.ordinal, nil
+	})
+	addNative(enum+".equals(Ljava/lang/Object;)Z", false, func(this, other int64) bool {
+		return this == other
+	})
+	addNative(enum+".getDeclaringClass()Ljava/lang/Class;", false, func(this int64) int64 {
+		return ClassObject(enumClass(this))
+	})
+
+	// valueOf() throws, so it's registered as a raw GMeth, which can return an error
+	MethodSignatures[enum+".valueOf(Ljava/lang/Class;Ljava/lang/String;)Ljava/lang/Enum;"] = GMeth{
+		ParamSlots: 2,
+		GFunction: func(params []interface{}) interface{} {
+			className, isClass := ClassNameOf(params[0].(int64))
+			name, ok := GoStringFromRef(params[1].(int64))
+			if !isClass {
+				return errNPE
+			}
+			if !ok {
+				return errors.New("java.lang.NullPointerException: Name is null")
+			}
+			if data := classData(className); data != nil && superclassOf(className) != "java/lang/Enum" {
+				return errors.New("java.lang.IllegalArgumentException: " +
+					strings.ReplaceAll(className, "/", ".") + " is not an enum class")
+			}
+			if constant, found := EnumConstant(className, name); found {
+				return constant
+			}
+			return errors.New("java.lang.IllegalArgumentException: No enum constant " +
+				strings.ReplaceAll(strings.ReplaceAll(className, "/", "."), "$", ".") + "." + name)
+		},
+	}
+	return MethodSignatures
+}
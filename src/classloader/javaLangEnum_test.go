@@ -0,0 +1,91 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"strings"
+	"testing"
+)
+
+// newTestEnum creates the constants of the enum class, as its <clinit> does
+func newTestEnum(t *testing.T, class string, names ...string) []int64 {
+	var fields []testMember
+	for _, name := range names {
+		fields = append(fields, testMember{0x4019, name, "L" + class + ";"})
+	}
+	addTestClass(class, "java/lang/Enum", nil, fields, nil)
+
+	var constants []int64
+	for i, name := range names {
+		c := NewObject(class, 0)
+		callNative(t, "java/lang/Enum.<init>(Ljava/lang/String;I)V", c, NewStringObject(name), int64(i))
+		index := AddStatic(class+"."+name, Static{Class: 'L', Type: "L" + class + ";"})
+		StoreStaticInt(index, c)
+		constants = append(constants, c)
+	}
+	return constants
+}
+
+func TestEnumConstants(t *testing.T) {
+	Load_Lang_Enum()
+	suits := newTestEnum(t, "test/Suit", "CLUBS", "HEARTS")
+
+	if javaString(callNative(t, "java/lang/Enum.name()Ljava/lang/String;", suits[1]).(int64)) != "HEARTS" {
+		t.Error("Expected the name of the second constant to be HEARTS")
+	}
+	if callNative(t, "java/lang/Enum.ordinal()I", suits[1]) != int64(1) {
+		t.Error("Expected the ordinal of HEARTS to be 1")
+	}
+	if callNative(t, "java/lang/Enum.compareTo(Ljava/lang/Enum;)I", suits[0], suits[1]) != int64(-1) {
+		t.Error("Expected CLUBS to come before HEARTS")
+	}
+	if err := callNative(t, "java/lang/Enum.compareTo(Ljava/lang/Enum;)I", suits[0], int64(0)); err != errNPE {
+		t.Errorf("Expected comparing with null to throw NullPointerException, got %v", err)
+	}
+	planets := newTestEnum(t, "test/Planet", "MERCURY")
+	ret := callNative(t, "java/lang/Enum.compareTo(Ljava/lang/Enum;)I", suits[0], planets[0])
+	if err, _ := ret.(error); err == nil || err.Error() != "java.lang.ClassCastException" {
+		t.Errorf("Expected comparing constants of different enums to throw ClassCastException, got %v", ret)
+	}
+	if callNative(t, "java/lang/Enum.getDeclaringClass()Ljava/lang/Class;", suits[0]) != ClassObject("test/Suit") {
+		t.Error("Expected the declaring class of CLUBS to be test/Suit")
+	}
+
+	valueOf := "java/lang/Enum.valueOf(Ljava/lang/Class;Ljava/lang/String;)Ljava/lang/Enum;"
+	if callNative(t, valueOf, ClassObject("test/Suit"), NewStringObject("CLUBS")) != suits[0] {
+		t.Error("Expected valueOf(CLUBS) to return the constant")
+	}
+	err, _ := callNative(t, valueOf, ClassObject("test/Suit"), NewStringObject("SPADES")).(error)
+	if err == nil || !strings.HasPrefix(err.Error(), "java.lang.IllegalArgumentException: No enum constant test.Suit.SPADES") {
+		t.Errorf("Expected valueOf(SPADES) to throw IllegalArgumentException, got %v", err)
+	}
+	if err, _ = callNative(t, valueOf, ClassObject("test/Suit"), int64(0)).(error); err == nil ||
+		!strings.HasPrefix(err.Error(), "java.lang.NullPointerException") {
+		t.Errorf("Expected valueOf(null) to throw NullPointerException, got %v", err)
+	}
+	addTestClass("test/NotAnEnum", "", nil, nil, nil)
+	if err, _ = callNative(t, valueOf, ClassObject("test/NotAnEnum"), NewStringObject("CLUBS")).(error); err == nil ||
+		err.Error() != "java.lang.IllegalArgumentException: test.NotAnEnum is not an enum class" {
+		t.Errorf("Expected valueOf() of a class that isn't an enum to throw IllegalArgumentException, got %v", err)
+	}
+}
+
+// values() returns a clone of the enum's array of constants
+func TestCloneArray(t *testing.T) {
+	Load_Lang_Object()
+	values := NewRefArray("test/Suit", []int64{11, 12})
+	clone := callNative(t, "java/lang/Object.clone()Ljava/lang/Object;", values).(int64)
+
+	elements, _ := RefArrayFromRef(clone)
+	if clone == values || len(elements) != 2 || elements[1] != 12 || GetObject(clone).Klass != "[Ltest/Suit;" {
+		t.Fatalf("Expected a new [Ltest/Suit; with the same elements, got %v", elements)
+	}
+	elements[0] = 13
+	if original, _ := RefArrayFromRef(values); original[0] != 11 {
+		t.Error("Expected the clone's elements to be separate from the original's")
+	}
+}
@@ -0,0 +1,816 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+)
+
+// The Foreign Function & Memory API (java.lang.foreign), which lets Java code call C
+// functions and work with native memory without writing JNI glue. Arenas, memory
+// segments, value layouts, function descriptors, symbol lookups, and downcall method
+// handles are all objects implemented in Go.
+//
+// The parts that touch real native memory and code are behind the NativeLinker
+// interface. The default linker allocates segments with Unsafe's allocator (see
+// nativeMemory.go) and can't call native code; when Jacobin is built with cgo, the
+// foreign package installs a linker that uses malloc() and calls C functions using
+// the platform's calling convention.
+//
+// Not yet supported: struct, sequence, and padding layouts; upcalls; variadic C
+// functions; and byte orders other than the native order.
+
+const foreignPkg = "java/lang/foreign/"
+
+// NativeLinker is the interface to native memory and code that the API is built on
+type NativeLinker interface {
+	// Allocate allocates size bytes of zeroed native memory and returns the memory
+	// and its address.
+	Allocate(size int64) ([]byte, int64)
+	// Free releases memory allocated by Allocate
+	Free(address int64)
+	// Segment returns the native memory at address as a slice, for reinterpret()
+	Segment(address, size int64) ([]byte, bool)
+	// OpenLibrary loads the named shared library and returns a handle for Lookup
+	OpenLibrary(name string) (int64, error)
+	// Lookup returns the address of the named symbol in the library, or 0 if it's
+	// not there. The library is a handle from OpenLibrary, or one of defaultLookup
+	// and loaderLookup.
+	Lookup(library int64, name string) int64
+	// Call calls the C function at fn. The argument and return types are Java type
+	// letters, with A for addresses; floats and doubles are passed and returned in
+	// the slot format (as the bits of a float64).
+	Call(fn int64, argTypes []byte, retType byte, args []int64) int64
+}
+
+// the pseudo-handles of the libraries searched by Linker.defaultLookup() (the C
+// library and the rest of the process) and SymbolLookup.loaderLookup() (libraries
+// loaded by System.loadLibrary())
+const (
+	defaultLookup int64 = 0
+	loaderLookup  int64 = -1
+)
+
+// ForeignLinker is the NativeLinker in use
+var ForeignLinker NativeLinker = unsafeLinker{}
+
+// unsafeLinker is the default NativeLinker, which can't call native code
+type unsafeLinker struct{}
+
+func (unsafeLinker) Allocate(size int64) ([]byte, int64) {
+	if size == 0 {
+		size = 1 // so every allocation has a distinct address
+	}
+	address := AllocateMemory(size)
+	data, _ := NativeSlice(address, size)
+	return data, address
+}
+
+func (unsafeLinker) Free(address int64) { FreeMemory(address) }
+
+func (unsafeLinker) Segment(address, size int64) ([]byte, bool) {
+	if size == 0 {
+		return []byte{}, true
+	}
+	return NativeSlice(address, size)
+}
+
+func (unsafeLinker) OpenLibrary(name string) (int64, error) {
+	return 0, errors.New("native libraries can't be loaded by this build of Jacobin")
+}
+
+func (unsafeLinker) Lookup(library int64, name string) int64 { return 0 }
+
+func (unsafeLinker) Call(fn int64, argTypes []byte, retType byte, args []int64) int64 {
+	return 0
+}
+
+// === value layouts ===
+
+type valueLayout struct {
+	name    string // the name of the ValueLayout constant, e.g. JAVA_INT
+	class   string // the layout's class, e.g. java/lang/foreign/ValueLayout$OfInt
+	carrier byte   // the Java type the layout holds; A for addresses (MemorySegments)
+	size    int64
+}
+
+var valueLayouts []*valueLayout
+
+func init() {
+	addressSize := int64(strconv.IntSize / 8)
+	for _, l := range []struct {
+		name    string
+		class   string
+		carrier byte
+		size    int64
+	}{
+		{"JAVA_BYTE", "ValueLayout$OfByte", 'B', 1},
+		{"JAVA_BOOLEAN", "ValueLayout$OfBoolean", 'Z', 1},
+		{"JAVA_CHAR", "ValueLayout$OfChar", 'C', 2},
+		{"JAVA_SHORT", "ValueLayout$OfShort", 'S', 2},
+		{"JAVA_INT", "ValueLayout$OfInt", 'I', 4},
+		{"JAVA_LONG", "ValueLayout$OfLong", 'J', 8},
+		{"JAVA_FLOAT", "ValueLayout$OfFloat", 'F', 4},
+		{"JAVA_DOUBLE", "ValueLayout$OfDouble", 'D', 8},
+		{"ADDRESS", "AddressLayout", 'A', addressSize},
+	} {
+		valueLayouts = append(valueLayouts, &valueLayout{l.name, foreignPkg + l.class, l.carrier, l.size})
+		if l.size > 1 { // alignment isn't enforced, so the unaligned layouts are the same
+			valueLayouts = append(valueLayouts,
+				&valueLayout{l.name + "_UNALIGNED", foreignPkg + l.class, l.carrier, l.size})
+		}
+	}
+}
+
+var layoutRefs = make(map[*valueLayout]int64)
+var foreignMutex sync.Mutex
+
+// layoutObject returns the singleton object for the value layout
+func layoutObject(l *valueLayout) int64 {
+	foreignMutex.Lock()
+	defer foreignMutex.Unlock()
+	ref, present := layoutRefs[l]
+	if !present {
+		ref = NewObject(l.class, 0)
+		GetObject(ref).Native = l
+		layoutRefs[l] = ref
+	}
+	return ref
+}
+
+// layoutOf returns the value layout of the referenced layout object, or nil
+func layoutOf(ref int64) *valueLayout {
+	if obj := GetObject(ref); obj != nil {
+		if l, ok := obj.Native.(*valueLayout); ok {
+			return l
+		}
+	}
+	return nil
+}
+
+// javaType returns the type of the layout's carrier as it appears in descriptors
+func (l *valueLayout) javaType() string {
+	if l.carrier == 'A' {
+		return "L" + foreignPkg + "MemorySegment;"
+	}
+	return string(l.carrier)
+}
+
+// load reads a value of the layout from b, returning it in the slot format
+func (l *valueLayout) load(b []byte) int64 {
+	switch l.carrier {
+	case 'B':
+		return int64(int8(b[0]))
+	case 'Z':
+		if b[0] != 0 {
+			return 1
+		}
+		return 0
+	case 'C':
+		return int64(nativeOrder.Uint16(b))
+	case 'S':
+		return int64(int16(nativeOrder.Uint16(b)))
+	case 'I':
+		return int64(int32(nativeOrder.Uint32(b)))
+	case 'F':
+		return SlotFromFloat(float64(math.Float32frombits(nativeOrder.Uint32(b))))
+	case 'A':
+		if l.size == 4 {
+			return int64(nativeOrder.Uint32(b))
+		}
+		return int64(nativeOrder.Uint64(b))
+	default: // J, and D, whose slot format is the bits of the double
+		return int64(nativeOrder.Uint64(b))
+	}
+}
+
+// store writes a value of the layout, given in the slot format, to b
+func (l *valueLayout) store(b []byte, value int64) {
+	switch {
+	case l.carrier == 'Z':
+		b[0] = 0
+		if value != 0 {
+			b[0] = 1
+		}
+	case l.carrier == 'F':
+		nativeOrder.PutUint32(b, math.Float32bits(float32(FloatFromSlot(value))))
+	case l.size == 1:
+		b[0] = byte(value)
+	case l.size == 2:
+		nativeOrder.PutUint16(b, uint16(value))
+	case l.size == 4:
+		nativeOrder.PutUint32(b, uint32(value))
+	default:
+		nativeOrder.PutUint64(b, uint64(value))
+	}
+}
+
+// InitForeignStatics adds the ValueLayout constants and MemorySegment.NULL to the
+// statics, as InitCharsetStatics does for the StandardCharsets.
+func InitForeignStatics() {
+	for _, l := range valueLayouts {
+		name := foreignPkg + "ValueLayout." + l.name
+		if _, present := FindStatic(name); !present {
+			AddStatic(name, Static{Class: 'L', Type: "L" + l.class + ";", ValueInt: layoutObject(l)})
+		}
+	}
+	if _, present := FindStatic(foreignPkg + "MemorySegment.NULL"); !present {
+		AddStatic(foreignPkg+"MemorySegment.NULL", Static{Class: 'L',
+			Type: "L" + foreignPkg + "MemorySegment;", ValueInt: addressSegment(0)})
+	}
+}
+
+// === arenas and memory segments ===
+
+type arena struct {
+	kind   string // confined, shared, auto, or global
+	mutex  sync.Mutex
+	blocks []int64 // the native memory to free when the arena is closed
+	closed bool
+}
+
+type memorySegment struct {
+	data     []byte
+	address  int64  // the native address of data[0]; 0 for heap segments
+	native   bool   // heap segments (over Java arrays) aren't native
+	arena    *arena // the arena whose lifetime the segment has; nil if always alive
+	readOnly bool
+}
+
+var globalArena = &arena{kind: "global"}
+
+func newArenaObject(a *arena) int64 {
+	ref := NewObject(foreignPkg+"Arena", 0)
+	GetObject(ref).Native = a
+	return ref
+}
+
+func arenaOf(ref int64) *arena {
+	if obj := GetObject(ref); obj != nil {
+		if a, ok := obj.Native.(*arena); ok {
+			return a
+		}
+	}
+	return nil
+}
+
+func newSegmentObject(seg *memorySegment) int64 {
+	ref := NewObject(foreignPkg+"MemorySegment", 0)
+	GetObject(ref).Native = seg
+	return ref
+}
+
+func segmentOf(ref int64) *memorySegment {
+	if obj := GetObject(ref); obj != nil {
+		if seg, ok := obj.Native.(*memorySegment); ok {
+			return seg
+		}
+	}
+	return nil
+}
+
+// addressSegment returns a zero-length native segment at the address, which is how
+// the API represents C pointers
+func addressSegment(address int64) int64 {
+	return newSegmentObject(&memorySegment{data: []byte{}, address: address, native: true})
+}
+
+// errAlreadyClosed is the exception of a use of a closed arena or of its segments
+var errAlreadyClosed = errors.New("java.lang.IllegalStateException: Already closed")
+
+// allocate allocates a segment of native memory in the arena. Memory from the linker
+// is aligned to at least 16 bytes; stricter alignments are met by allocating extra.
+func (a *arena) allocate(size, align int64) (*memorySegment, error) {
+	if size < 0 {
+		return nil, errors.New("java.lang.IllegalArgumentException: Invalid allocation size : " +
+			strconv.FormatInt(size, 10))
+	}
+	if align <= 0 || align&(align-1) != 0 {
+		return nil, errors.New("java.lang.IllegalArgumentException: Invalid alignment constraint : " +
+			strconv.FormatInt(align, 10))
+	}
+	total := size
+	if align > 16 {
+		total += align - 1
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return nil, errAlreadyClosed
+	}
+	data, base := ForeignLinker.Allocate(total)
+	if data == nil {
+		return nil, errors.New("java.lang.OutOfMemoryError: Unable to allocate " +
+			strconv.FormatInt(total, 10) + " bytes")
+	}
+	offset := (align - base%align) % align
+	a.blocks = append(a.blocks, base)
+	return &memorySegment{data: data[offset : offset+size], address: base + offset, native: true, arena: a}, nil
+}
+
+// close frees the arena's memory, after which its segments can no longer be accessed.
+// The global and automatic arenas can't be closed.
+func (a *arena) close() error {
+	if a.kind == "global" || a.kind == "auto" {
+		return errors.New("java.lang.UnsupportedOperationException: Attempted to close a non-closeable session")
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return errAlreadyClosed
+	}
+	a.closed = true
+	for _, block := range a.blocks {
+		ForeignLinker.Free(block)
+	}
+	a.blocks = nil
+	return nil
+}
+
+func (s *memorySegment) alive() bool {
+	if s.arena == nil {
+		return true
+	}
+	s.arena.mutex.Lock()
+	defer s.arena.mutex.Unlock()
+	return !s.arena.closed
+}
+
+// region returns the bytes at offset..offset+size, or the exception of an access that
+// isn't valid
+func (s *memorySegment) region(offset, size int64, write bool) ([]byte, error) {
+	if s == nil {
+		return nil, errors.New("java.lang.NullPointerException")
+	}
+	if offset < 0 || size < 0 || offset+size > int64(len(s.data)) {
+		return nil, fmt.Errorf("java.lang.IndexOutOfBoundsException: Out of bound access on segment "+
+			"of size %d; new offset = %d; new length = %d", len(s.data), offset, size)
+	}
+	if write && s.readOnly {
+		return nil, errors.New("java.lang.UnsupportedOperationException: Attempt to write a read-only segment")
+	}
+	if !s.alive() {
+		return nil, errAlreadyClosed
+	}
+	return s.data[offset : offset+size], nil
+}
+
+// slice returns a segment over part of this one, with the same lifetime
+func (s *memorySegment) slice(offset, size int64) (*memorySegment, error) {
+	if _, err := s.region(offset, size, false); err != nil {
+		return nil, err
+	}
+	sub := *s
+	sub.data = s.data[offset : offset+size : offset+size]
+	if s.native {
+		sub.address = s.address + offset
+	}
+	return &sub, nil
+}
+
+// === function descriptors, symbol lookups, and downcalls ===
+
+type functionDescriptor struct {
+	ret  *valueLayout // nil for void functions
+	args []*valueLayout
+}
+
+type symbolLookup struct {
+	library int64 // a handle from the linker, or defaultLookup or loaderLookup
+}
+
+// downcallHandle is the target of a MethodHandle that calls a C function
+type downcallHandle struct {
+	address int64 // the function's address; if 0, it's passed as the first argument
+	desc    *functionDescriptor
+}
+
+func (h *downcallHandle) invoke(args []int64, ret byte) interface{} {
+	fn := h.address
+	if fn == 0 { // the address is the first argument
+		if len(args) != len(h.desc.args)+1 {
+			return wrongArgCount(len(h.desc.args)+1, len(args))
+		}
+		target := segmentOf(args[0])
+		if target == nil {
+			return errors.New("java.lang.NullPointerException")
+		}
+		if fn = target.address; fn == 0 {
+			return errors.New("java.lang.IllegalArgumentException: Symbol is NULL: " + target.String())
+		}
+		args = args[1:]
+	}
+	if len(args) != len(h.desc.args) {
+		return wrongArgCount(len(h.desc.args), len(args))
+	}
+
+	argTypes := make([]byte, len(args))
+	values := make([]int64, len(args))
+	for i, l := range h.desc.args {
+		argTypes[i] = l.carrier
+		values[i] = args[i]
+		if l.carrier == 'A' { // a null is passed as NULL
+			values[i] = 0
+			if seg := segmentOf(args[i]); seg != nil {
+				if !seg.native {
+					return errors.New("java.lang.IllegalArgumentException: Heap segment not allowed: " + seg.String())
+				}
+				if !seg.alive() {
+					return errAlreadyClosed
+				}
+				values[i] = seg.address
+			}
+		}
+	}
+
+	if h.desc.ret == nil {
+		ForeignLinker.Call(fn, argTypes, 'V', values)
+		if ret != 'V' {
+			return int64(0)
+		}
+		return nil
+	}
+	r := ForeignLinker.Call(fn, argTypes, h.desc.ret.carrier, values)
+	switch h.desc.ret.carrier { // C only defines the low-order bits of narrow results
+	case 'A':
+		return addressSegment(r)
+	case 'Z':
+		if uint8(r) != 0 {
+			return int64(1)
+		}
+		return int64(0)
+	case 'B':
+		return int64(int8(r))
+	case 'C':
+		return int64(uint16(r))
+	case 'S':
+		return int64(int16(r))
+	case 'I':
+		return int64(int32(r))
+	default:
+		return r
+	}
+}
+
+// layoutsFrom returns the value layouts in the referenced MemoryLayout[]; the second
+// return is false if any of them isn't a supported layout
+func layoutsFrom(arrayRef int64) ([]*valueLayout, bool) {
+	refs, _ := RefArrayFromRef(arrayRef)
+	layouts := make([]*valueLayout, len(refs))
+	for i, ref := range refs {
+		if layouts[i] = layoutOf(ref); layouts[i] == nil {
+			return nil, false
+		}
+	}
+	return layouts, true
+}
+
+func newDescriptorObject(ret *valueLayout, args []*valueLayout) int64 {
+	ref := NewObject(foreignPkg+"FunctionDescriptor", 0)
+	GetObject(ref).Native = &functionDescriptor{ret: ret, args: args}
+	return ref
+}
+
+func descriptorOf(ref int64) *functionDescriptor {
+	if obj := GetObject(ref); obj != nil {
+		if fd, ok := obj.Native.(*functionDescriptor); ok {
+			return fd
+		}
+	}
+	return nil
+}
+
+func newLookupObject(library int64) int64 {
+	ref := NewObject(foreignPkg+"SymbolLookup", 0)
+	GetObject(ref).Native = &symbolLookup{library: library}
+	return ref
+}
+
+// wrongArgCount returns the exception of a call of a downcall handle with the wrong
+// number of arguments
+func wrongArgCount(expected, got int) error {
+	return fmt.Errorf("java.lang.invoke.WrongMethodTypeException: expected %d arguments, got %d", expected, got)
+}
+
+// String describes the segment, as in MemorySegment{ address: 0x7f2c4c000b70, byteSize: 16 }
+func (s *memorySegment) String() string {
+	return fmt.Sprintf("MemorySegment{ address: 0x%x, byteSize: %d }", s.address, len(s.data))
+}
+
+func newDowncallObject(address int64, fd *functionDescriptor) (int64, error) {
+	if fd == nil {
+		return 0, errors.New("java.lang.NullPointerException")
+	}
+	ref := NewObject("java/lang/invoke/MethodHandle", 0)
+	GetObject(ref).Native = &downcallHandle{address: address, desc: fd}
+	return ref, nil
+}
+
+func Load_Lang_Foreign() map[string]GMeth {
+	ms := "L" + foreignPkg + "MemorySegment;"
+	ar := foreignPkg + "Arena"
+
+	// Arena
+	for kind, method := range map[string]string{"confined": "ofConfined", "shared": "ofShared", "auto": "ofAuto"} {
+		kind := kind
+		addNative(ar+"."+method+"()L"+ar+";", true, func() int64 {
+			return newArenaObject(&arena{kind: kind})
+		})
+	}
+	addNative(ar+".global()L"+ar+";", true, func() int64 {
+		return newArenaObject(globalArena)
+	})
+	allocate := func(this, size, align int64) (int64, error) {
+		seg, err := arenaOf(this).allocate(size, align)
+		if err != nil {
+			return 0, err
+		}
+		return newSegmentObject(seg), nil
+	}
+	addNative(ar+".allocate(J)"+ms, false, func(this, size int64) (int64, error) {
+		return allocate(this, size, 1)
+	})
+	addNative(ar+".allocate(JJ)"+ms, false, allocate)
+	addNative(ar+".allocate(L"+foreignPkg+"MemoryLayout;)"+ms, false, func(this, layout int64) (int64, error) {
+		if l := layoutOf(layout); l != nil {
+			return allocate(this, l.size, l.size)
+		}
+		return 0, nil // TODO: support struct and sequence layouts
+	})
+	for _, method := range []string{"allocateFrom", "allocateUtf8String"} { // JDK 22 and JDK 21 names
+		addNative(ar+"."+method+"(Ljava/lang/String;)"+ms, false, func(this, str int64) (int64, error) {
+			b := append([]byte(javaString(str)), 0)
+			seg, err := arenaOf(this).allocate(int64(len(b)), 1)
+			if err != nil {
+				return 0, err
+			}
+			copy(seg.data, b)
+			return newSegmentObject(seg), nil
+		})
+	}
+	addNative(ar+".close()V", false, func(this int64) error {
+		return arenaOf(this).close()
+	})
+
+	// MemorySegment
+	seg := foreignPkg + "MemorySegment"
+	addNative(seg+".address()J", false, func(this int64) int64 {
+		return segmentOf(this).address
+	})
+	addNative(seg+".byteSize()J", false, func(this int64) int64 {
+		return int64(len(segmentOf(this).data))
+	})
+	addNative(seg+".isNative()Z", false, func(this int64) bool {
+		return segmentOf(this).native
+	})
+	addNative(seg+".isReadOnly()Z", false, func(this int64) bool {
+		return segmentOf(this).readOnly
+	})
+	addNative(seg+".asReadOnly()"+ms, false, func(this int64) int64 {
+		ro := *segmentOf(this)
+		ro.readOnly = true
+		return newSegmentObject(&ro)
+	})
+	addNative(seg+".asSlice(J)"+ms, false, func(this, offset int64) (int64, error) {
+		s := segmentOf(this)
+		sub, err := s.slice(offset, int64(len(s.data))-offset)
+		if err != nil {
+			return 0, err
+		}
+		return newSegmentObject(sub), nil
+	})
+	addNative(seg+".asSlice(JJ)"+ms, false, func(this, offset, size int64) (int64, error) {
+		sub, err := segmentOf(this).slice(offset, size)
+		if err != nil {
+			return 0, err
+		}
+		return newSegmentObject(sub), nil
+	})
+	addNative(seg+".reinterpret(J)"+ms, false, func(this, size int64) (int64, error) {
+		s := segmentOf(this)
+		if !s.native {
+			return 0, errors.New("java.lang.UnsupportedOperationException: Not a native segment")
+		}
+		if size < 0 {
+			return 0, errors.New("java.lang.IllegalArgumentException: Invalid size : " +
+				strconv.FormatInt(size, 10))
+		}
+		data, ok := ForeignLinker.Segment(s.address, size)
+		if !ok {
+			return 0, errors.New("java.lang.UnsupportedOperationException: " +
+				"reinterpret() isn't supported without native access")
+		}
+		wider := *s
+		wider.data = data
+		return newSegmentObject(&wider), nil
+	})
+	addNative(seg+".fill(B)"+ms, false, func(this int64, value int8) (int64, error) {
+		s := segmentOf(this)
+		b, err := s.region(0, int64(len(s.data)), true)
+		if err != nil {
+			return 0, err
+		}
+		for i := range b {
+			b[i] = byte(value)
+		}
+		return this, nil
+	})
+	addNative(seg+".copyFrom("+ms+")"+ms, false, func(this, src int64) (int64, error) {
+		from := segmentOf(src)
+		if from == nil {
+			return 0, errors.New("java.lang.NullPointerException")
+		}
+		b, err := segmentOf(this).region(0, int64(len(from.data)), true)
+		if err != nil {
+			return 0, err
+		}
+		if !from.alive() {
+			return 0, errAlreadyClosed
+		}
+		copy(b, from.data)
+		return this, nil
+	})
+	addNative(seg+".copy("+ms+"J"+ms+"JJ)V", true, func(src, srcOffset, dst, dstOffset, size int64) error {
+		from, err := segmentOf(src).region(srcOffset, size, false)
+		if err != nil {
+			return err
+		}
+		to, err := segmentOf(dst).region(dstOffset, size, true)
+		if err != nil {
+			return err
+		}
+		copy(to, from)
+		return nil
+	})
+	addNative(seg+".toArray(L"+foreignPkg+"ValueLayout$OfByte;)[B", false, func(this, layout int64) (int64, error) {
+		s := segmentOf(this)
+		b, err := s.region(0, int64(len(s.data)), false)
+		if err != nil {
+			return 0, err
+		}
+		return NewByteArray(append([]byte(nil), b...)), nil
+	})
+	addNative(seg+".ofArray([B)"+ms, true, func(array int64) (int64, error) {
+		b, ok := ByteArrayFromRef(array)
+		if !ok {
+			return 0, errors.New("java.lang.NullPointerException")
+		}
+		return newSegmentObject(&memorySegment{data: b}), nil
+	})
+	addNative(seg+".ofAddress(J)"+ms, true, addressSegment)
+	for _, method := range []string{"getString", "getUtf8String"} { // JDK 22 and JDK 21 names
+		addNative(seg+"."+method+"(J)Ljava/lang/String;", false, func(this, offset int64) (int64, error) {
+			s := segmentOf(this)
+			b, err := s.region(offset, int64(len(s.data))-offset, false)
+			if err != nil {
+				return 0, err
+			}
+			end := bytes.IndexByte(b, 0)
+			if end == -1 {
+				return 0, errors.New("java.lang.IndexOutOfBoundsException: No string terminator found in segment " +
+					s.String())
+			}
+			return NewStringObject(string(b[:end])), nil
+		})
+	}
+	for _, method := range []string{"setString", "setUtf8String"} {
+		addNative(seg+"."+method+"(JLjava/lang/String;)V", false, func(this, offset, str int64) error {
+			src := append([]byte(javaString(str)), 0)
+			b, err := segmentOf(this).region(offset, int64(len(src)), true)
+			if err != nil {
+				return err
+			}
+			copy(b, src)
+			return nil
+		})
+	}
+
+	// get(), set(), getAtIndex(), and setAtIndex() for each kind of value layout. These
+	// are registered directly, since the Java type of the value varies with the layout.
+	registered := make(map[string]bool)
+	for _, l := range valueLayouts {
+		if registered[l.class] {
+			continue
+		}
+		registered[l.class] = true
+		l := l
+		for _, indexed := range []bool{false, true} {
+			indexed := indexed
+			scale := int64(1)
+			suffix := ""
+			if indexed {
+				scale = l.size
+				suffix = "AtIndex"
+			}
+			MethodSignatures[seg+".get"+suffix+"(L"+l.class+";J)"+l.javaType()] = GMeth{
+				ParamSlots: 3,
+				GFunction: func(slots []interface{}) interface{} {
+					s := segmentOf(slots[0].(int64))
+					b, err := s.region(slots[2].(int64)*scale, l.size, false)
+					if err != nil {
+						return err
+					}
+					if l.carrier == 'A' {
+						return addressSegment(l.load(b))
+					}
+					return l.load(b)
+				},
+			}
+			MethodSignatures[seg+".set"+suffix+"(L"+l.class+";J"+l.javaType()+")V"] = GMeth{
+				ParamSlots: 4,
+				GFunction: func(slots []interface{}) interface{} {
+					s := segmentOf(slots[0].(int64))
+					b, err := s.region(slots[2].(int64)*scale, l.size, true)
+					if err != nil {
+						return err
+					}
+					value := slots[3].(int64)
+					if l.carrier == 'A' { // store the address of the segment
+						value = 0
+						if target := segmentOf(slots[3].(int64)); target != nil {
+							value = target.address
+						}
+					}
+					l.store(b, value)
+					return nil
+				},
+			}
+		}
+		addNative(l.class+".byteSize()J", false, func(this int64) int64 {
+			return layoutOf(this).size
+		})
+	}
+
+	// FunctionDescriptor
+	fd := foreignPkg + "FunctionDescriptor"
+	ml := "L" + foreignPkg + "MemoryLayout;"
+	addNative(fd+".of("+ml+"["+ml+")L"+fd+";", true, func(ret, args int64) int64 {
+		retLayout := layoutOf(ret)
+		argLayouts, ok := layoutsFrom(args)
+		if retLayout == nil || !ok {
+			return 0 // TODO: support struct layouts (by-value structs)
+		}
+		return newDescriptorObject(retLayout, argLayouts)
+	})
+	addNative(fd+".ofVoid(["+ml+")L"+fd+";", true, func(args int64) int64 {
+		argLayouts, ok := layoutsFrom(args)
+		if !ok {
+			return 0
+		}
+		return newDescriptorObject(nil, argLayouts)
+	})
+
+	// Linker and SymbolLookup
+	lk := foreignPkg + "Linker"
+	sl := foreignPkg + "SymbolLookup"
+	mh := "Ljava/lang/invoke/MethodHandle;"
+	addNative(lk+".nativeLinker()L"+lk+";", true, func() int64 {
+		return NewObject(lk, 0)
+	})
+	addNative(lk+".defaultLookup()L"+sl+";", false, func(this int64) int64 {
+		return newLookupObject(defaultLookup)
+	})
+	addNative(sl+".loaderLookup()L"+sl+";", true, func() int64 {
+		return newLookupObject(loaderLookup)
+	})
+	addNative(sl+".libraryLookup(Ljava/lang/String;L"+ar+";)L"+sl+";", true, func(name, arena int64) (int64, error) {
+		handle, err := ForeignLinker.OpenLibrary(javaString(name))
+		if err != nil {
+			return 0, errors.New("java.lang.IllegalArgumentException: Cannot open library: " + javaString(name))
+		}
+		return newLookupObject(handle), nil
+	})
+	addNative(sl+".find(Ljava/lang/String;)Ljava/util/Optional;", false, func(this, name int64) int64 {
+		lookup := GetObject(this).Native.(*symbolLookup)
+		if address := ForeignLinker.Lookup(lookup.library, javaString(name)); address != 0 {
+			return NewOptional(addressSegment(address))
+		}
+		return NewOptional(0)
+	})
+	addNative(lk+".downcallHandle("+ms+"L"+fd+";[L"+lk+"$Option;)"+mh, false,
+		func(this, target, desc, options int64) (int64, error) {
+			s := segmentOf(target)
+			if s == nil {
+				return 0, errors.New("java.lang.NullPointerException")
+			}
+			if s.address == 0 {
+				return 0, errors.New("java.lang.IllegalArgumentException: Symbol is NULL: " + s.String())
+			}
+			return newDowncallObject(s.address, descriptorOf(desc))
+		})
+	addNative(lk+".downcallHandle(L"+fd+";[L"+lk+"$Option;)"+mh, false,
+		func(this, desc, options int64) (int64, error) {
+			return newDowncallObject(0, descriptorOf(desc))
+		})
+	return MethodSignatures
+}
@@ -0,0 +1,231 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	msDesc  = "Ljava/lang/foreign/MemorySegment;"
+	ofInt   = "Ljava/lang/foreign/ValueLayout$OfInt;"
+	ofLong  = "Ljava/lang/foreign/ValueLayout$OfLong;"
+	ofFloat = "Ljava/lang/foreign/ValueLayout$OfFloat;"
+)
+
+func layoutNamed(t *testing.T, name string) int64 {
+	for _, l := range valueLayouts {
+		if l.name == name {
+			return layoutObject(l)
+		}
+	}
+	t.Fatalf("No value layout %s", name)
+	return 0
+}
+
+func TestArenaAllocateAndAccess(t *testing.T) {
+	Load_Lang_Foreign()
+	arena := callNative(t, "java/lang/foreign/Arena.ofConfined()Ljava/lang/foreign/Arena;").(int64)
+	seg := callNative(t, "java/lang/foreign/Arena.allocate(JJ)"+msDesc, arena, int64(16), int64(64)).(int64)
+
+	if callNative(t, "java/lang/foreign/MemorySegment.byteSize()J", seg) != int64(16) {
+		t.Error("Expected a 16-byte segment")
+	}
+	if segmentOf(seg).address%64 != 0 {
+		t.Errorf("Expected 64-byte alignment, got address 0x%x", segmentOf(seg).address)
+	}
+
+	jint, jlong, jfloat := layoutNamed(t, "JAVA_INT"), layoutNamed(t, "JAVA_LONG"), layoutNamed(t, "JAVA_FLOAT")
+	callNative(t, "java/lang/foreign/MemorySegment.set("+ofInt+"JI)V", seg, jint, int64(4), int64(-5))
+	if v := callNative(t, "java/lang/foreign/MemorySegment.get("+ofInt+"J)I", seg, jint, int64(4)); v != int64(-5) {
+		t.Errorf("Expected -5, got %v", v)
+	}
+	callNative(t, "java/lang/foreign/MemorySegment.setAtIndex("+ofLong+"JJ)V", seg, jlong, int64(1), int64(1)<<40)
+	if v := callNative(t, "java/lang/foreign/MemorySegment.get("+ofLong+"J)J", seg, jlong, int64(8)); v != int64(1)<<40 {
+		t.Errorf("Expected 2^40 at offset 8, got %v", v)
+	}
+	callNative(t, "java/lang/foreign/MemorySegment.set("+ofFloat+"JF)V", seg, jfloat, int64(0), SlotFromFloat(1.5))
+	if v := callNative(t, "java/lang/foreign/MemorySegment.get("+ofFloat+"J)F", seg, jfloat, int64(0)); FloatFromSlot(v.(int64)) != 1.5 {
+		t.Errorf("Expected 1.5, got %v", FloatFromSlot(v.(int64)))
+	}
+
+	// out of bounds
+	err, _ := callNative(t, "java/lang/foreign/MemorySegment.get("+ofLong+"J)J", seg, jlong, int64(12)).(error)
+	if err == nil || !strings.HasPrefix(err.Error(), "java.lang.IndexOutOfBoundsException") {
+		t.Errorf("Expected an out-of-bounds read to throw IndexOutOfBoundsException, got %v", err)
+	}
+
+	slice := callNative(t, "java/lang/foreign/MemorySegment.asSlice(JJ)"+msDesc, seg, int64(4), int64(4)).(int64)
+	if v := callNative(t, "java/lang/foreign/MemorySegment.get("+ofInt+"J)I", slice, jint, int64(0)); v != int64(-5) {
+		t.Errorf("Expected the slice to share memory with the segment, got %v", v)
+	}
+
+	callNative(t, "java/lang/foreign/Arena.close()V", arena)
+	if segmentOf(seg).alive() || segmentOf(slice).alive() {
+		t.Error("Expected the segments to be dead after the arena is closed")
+	}
+	err, _ = callNative(t, "java/lang/foreign/MemorySegment.get("+ofInt+"J)I", seg, jint, int64(4)).(error)
+	if err == nil || err.Error() != "java.lang.IllegalStateException: Already closed" {
+		t.Errorf("Expected access to a closed arena's segment to throw IllegalStateException, got %v", err)
+	}
+	err, _ = callNative(t, "java/lang/foreign/Arena.close()V", arena).(error)
+	if err == nil || err.Error() != "java.lang.IllegalStateException: Already closed" {
+		t.Errorf("Expected closing the arena again to throw IllegalStateException, got %v", err)
+	}
+	err, _ = callNative(t, "java/lang/foreign/Arena.allocate(JJ)"+msDesc, arena, int64(8), int64(3)).(error)
+	if err == nil || err.Error() != "java.lang.IllegalArgumentException: Invalid alignment constraint : 3" {
+		t.Errorf("Expected an alignment of 3 to throw IllegalArgumentException, got %v", err)
+	}
+	global := callNative(t, "java/lang/foreign/Arena.global()Ljava/lang/foreign/Arena;").(int64)
+	err, _ = callNative(t, "java/lang/foreign/Arena.close()V", global).(error)
+	if err == nil || !strings.HasPrefix(err.Error(), "java.lang.UnsupportedOperationException") {
+		t.Errorf("Expected closing the global arena to throw UnsupportedOperationException, got %v", err)
+	}
+}
+
+func TestSegmentStrings(t *testing.T) {
+	Load_Lang_Foreign()
+	arena := callNative(t, "java/lang/foreign/Arena.ofShared()Ljava/lang/foreign/Arena;").(int64)
+	seg := callNative(t, "java/lang/foreign/Arena.allocateFrom(Ljava/lang/String;)"+msDesc,
+		arena, NewStringObject("héllo")).(int64)
+	if callNative(t, "java/lang/foreign/MemorySegment.byteSize()J", seg) != int64(7) {
+		t.Error("Expected the UTF-8 bytes plus a terminating NUL")
+	}
+	str := callNative(t, "java/lang/foreign/MemorySegment.getString(J)Ljava/lang/String;", seg, int64(0)).(int64)
+	if javaString(str) != "héllo" {
+		t.Errorf("Expected héllo, got %q", javaString(str))
+	}
+	callNative(t, "java/lang/foreign/MemorySegment.setString(JLjava/lang/String;)V", seg, int64(1), NewStringObject("ey"))
+	str = callNative(t, "java/lang/foreign/MemorySegment.getString(J)Ljava/lang/String;", seg, int64(0)).(int64)
+	if javaString(str) != "hey" {
+		t.Errorf("Expected hey, got %q", javaString(str))
+	}
+}
+
+func TestHeapSegment(t *testing.T) {
+	Load_Lang_Foreign()
+	array := NewByteArray([]byte{1, 2, 3, 4})
+	seg := callNative(t, "java/lang/foreign/MemorySegment.ofArray([B)"+msDesc, array).(int64)
+	callNative(t, "java/lang/foreign/MemorySegment.fill(B)"+msDesc, seg, int64(9))
+	if b, _ := ByteArrayFromRef(array); b[3] != 9 {
+		t.Error("Expected a heap segment to write through to its array")
+	}
+	if callNative(t, "java/lang/foreign/MemorySegment.isNative()Z", seg) != int64(0) {
+		t.Error("A heap segment is not native")
+	}
+	ro := callNative(t, "java/lang/foreign/MemorySegment.asReadOnly()"+msDesc, seg).(int64)
+	err, _ := callNative(t, "java/lang/foreign/MemorySegment.fill(B)"+msDesc, ro, int64(0)).(error)
+	if b, _ := ByteArrayFromRef(array); b[0] != 9 || err == nil ||
+		err.Error() != "java.lang.UnsupportedOperationException: Attempt to write a read-only segment" {
+		t.Errorf("Expected a read-only segment not to be writable, got %v", err)
+	}
+	err, _ = callNative(t, "java/lang/foreign/MemorySegment.getString(J)Ljava/lang/String;", seg, int64(0)).(error)
+	if err == nil || !strings.HasPrefix(err.Error(), "java.lang.IndexOutOfBoundsException: No string terminator") {
+		t.Errorf("Expected a string without a terminator to throw IndexOutOfBoundsException, got %v", err)
+	}
+}
+
+// fakeLinker records downcalls rather than making them
+type fakeLinker struct {
+	unsafeLinker
+	fn       int64
+	argTypes []byte
+	args     []int64
+}
+
+func (f *fakeLinker) Lookup(library int64, name string) int64 {
+	if name == "twice" {
+		return 0x1234
+	}
+	return 0
+}
+
+func (f *fakeLinker) Call(fn int64, argTypes []byte, retType byte, args []int64) int64 {
+	f.fn, f.argTypes, f.args = fn, argTypes, args
+	return 0x1_0000_0000 - 2 // -2 in the low-order 32 bits
+}
+
+func TestDowncallHandle(t *testing.T) {
+	Load_Lang_Foreign()
+	Load_Util_Optional()
+	MTable = make(MT)
+	fake := &fakeLinker{}
+	saved := ForeignLinker
+	ForeignLinker = fake
+	defer func() { ForeignLinker = saved }()
+
+	linker := callNative(t, "java/lang/foreign/Linker.nativeLinker()Ljava/lang/foreign/Linker;").(int64)
+	lookup := callNative(t, "java/lang/foreign/Linker.defaultLookup()Ljava/lang/foreign/SymbolLookup;", linker).(int64)
+	found := callNative(t, "java/lang/foreign/SymbolLookup.find(Ljava/lang/String;)Ljava/util/Optional;",
+		lookup, NewStringObject("twice")).(int64)
+	target := optionalValue(found)
+	if segmentOf(target) == nil || segmentOf(target).address != 0x1234 {
+		t.Fatal("Expected find() to return the symbol's address")
+	}
+	missing := callNative(t, "java/lang/foreign/SymbolLookup.find(Ljava/lang/String;)Ljava/util/Optional;",
+		lookup, NewStringObject("nope")).(int64)
+	if optionalValue(missing) != 0 {
+		t.Error("Expected find() of an unknown symbol to be empty")
+	}
+
+	args := NewRefArray("java/lang/foreign/MemoryLayout",
+		[]int64{layoutNamed(t, "ADDRESS"), layoutNamed(t, "JAVA_DOUBLE")})
+	fd := callNative(t, "java/lang/foreign/FunctionDescriptor.of(Ljava/lang/foreign/MemoryLayout;"+
+		"[Ljava/lang/foreign/MemoryLayout;)Ljava/lang/foreign/FunctionDescriptor;", layoutNamed(t, "JAVA_INT"), args).(int64)
+	handle := callNative(t, "java/lang/foreign/Linker.downcallHandle("+msDesc+
+		"Ljava/lang/foreign/FunctionDescriptor;[Ljava/lang/foreign/Linker$Option;)Ljava/lang/invoke/MethodHandle;",
+		linker, target, fd, int64(0)).(int64)
+
+	entry, ok := SignaturePolymorphic("java/lang/invoke/MethodHandle.invokeExact(" + msDesc + "D)I")
+	if !ok {
+		t.Fatal("Expected invokeExact to be signature polymorphic")
+	}
+	gme := entry.Meth.(GmEntry)
+	if gme.ParamSlots != 3 {
+		t.Errorf("Expected 3 param slots (handle plus 2 args), got %d", gme.ParamSlots)
+	}
+	pointer := addressSegment(0x5000)
+	ret := gme.Fu([]interface{}{handle, pointer, SlotFromFloat(2.5)})
+	if ret != int64(-2) {
+		t.Errorf("Expected the int result to be narrowed to -2, got %v", ret)
+	}
+	if fake.fn != 0x1234 || string(fake.argTypes) != "AD" ||
+		fake.args[0] != 0x5000 || FloatFromSlot(fake.args[1]) != 2.5 {
+		t.Errorf("Unexpected downcall: fn 0x%x, types %s, args %v", fake.fn, fake.argTypes, fake.args)
+	}
+	if _, present := MTable[MethodKey("java/lang/invoke/MethodHandle.invokeExact("+msDesc+"D)I")]; !present {
+		t.Error("Expected the polymorphic entry to be added to the MTable")
+	}
+	if _, ok := SignaturePolymorphic("java/lang/invoke/MethodHandle.bindTo(Ljava/lang/Object;)" +
+		"Ljava/lang/invoke/MethodHandle;"); ok {
+		t.Error("bindTo() is not signature polymorphic")
+	}
+
+	// a heap segment can't be passed as an address, and a handle needs a target
+	heap := NewObject("java/lang/foreign/MemorySegment", 0)
+	GetObject(heap).Native = &memorySegment{data: []byte{1}}
+	if err, _ := gme.Fu([]interface{}{handle, heap, SlotFromFloat(2.5)}).(error); err == nil ||
+		!strings.HasPrefix(err.Error(), "java.lang.IllegalArgumentException: Heap segment not allowed") {
+		t.Errorf("Expected a heap segment argument to throw IllegalArgumentException, got %v", err)
+	}
+	if err, _ := gme.Fu([]interface{}{int64(0), pointer, SlotFromFloat(2.5)}).(error); err == nil ||
+		err.Error() != "java.lang.NullPointerException" {
+		t.Errorf("Expected invokeExact() of null to throw NullPointerException, got %v", err)
+	}
+	if err, _ := gme.Fu([]interface{}{NewObject("java/lang/invoke/MethodHandle", 0), pointer,
+		SlotFromFloat(2.5)}).(error); err == nil ||
+		!strings.HasPrefix(err.Error(), "java.lang.invoke.WrongMethodTypeException") {
+		t.Errorf("Expected invokeExact() of a handle with no target to throw WrongMethodTypeException, got %v", err)
+	}
+	if err, _ := callNative(t, "java/lang/foreign/Linker.downcallHandle("+msDesc+
+		"Ljava/lang/foreign/FunctionDescriptor;[Ljava/lang/foreign/Linker$Option;)Ljava/lang/invoke/MethodHandle;",
+		linker, addressSegment(0), fd, int64(0)).(error); err == nil ||
+		!strings.HasPrefix(err.Error(), "java.lang.IllegalArgumentException: Symbol is NULL") {
+		t.Errorf("Expected a downcall handle of NULL to throw IllegalArgumentException, got %v", err)
+	}
+}
@@ -0,0 +1,381 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// MethodHandle.invoke() and invokeExact() are signature polymorphic (JVMS 2.9.3):
+// they take whatever arguments, and return whatever type, the call site's descriptor
+// says. So they can't be registered in advance like other natives. Instead, the
+// interpreter asks SignaturePolymorphic() for an MTable entry for each descriptor
+// it encounters, which passes the arguments to the handle's target.
+//
+// The handles for Java methods come from MethodHandles.Lookup: findStatic(),
+// findVirtual(), and unreflect(). A handle for a variable arity method (ACC_VARARGS) is
+// itself of variable arity, as is one made by asVarargsCollector(): invoke() and
+// invokeWithArguments() pack the trailing arguments into the array that the method's
+// last parameter takes, as javac does at the call sites of such methods, unless the
+// call passes the array itself. invokeExact() never packs them.
+
+// methodHandleTarget is the Go-side state of a MethodHandle object: what the handle
+// invokes. The arguments are the operand-stack slots, and the result is returned as
+// a slot (or nil for a void method).
+type methodHandleTarget interface {
+	invoke(args []int64, ret byte) interface{}
+}
+
+// methodTypeDesc is the Go-side state of a MethodType object: its method descriptor
+type methodTypeDesc struct {
+	desc string
+}
+
+// javaMethodHandle is the target of a MethodHandle that invokes a Java method. The
+// method of a virtual handle is looked up in the class of the receiver, which is the
+// handle's first argument.
+type javaMethodHandle struct {
+	class   string
+	name    string
+	desc    string
+	static  bool
+	varargs bool // the handle collects its trailing arguments into an array
+}
+
+// handleType returns the descriptor of the handle's type, which begins with the
+// receiver for a virtual handle
+func (h *javaMethodHandle) handleType() string {
+	if h.static {
+		return h.desc
+	}
+	return "(L" + h.class + ";" + h.desc[1:]
+}
+
+func (h *javaMethodHandle) invoke(args []int64, ret byte) interface{} {
+	class := h.class
+	if !h.static {
+		if len(args) == 0 || GetObject(args[0]) == nil {
+			return errors.New("java.lang.NullPointerException")
+		}
+		class = overridingClass(args[0], h.class, h.name, h.desc)
+	}
+	params, _ := descriptorTypes(h.handleType())
+	if len(args) != len(params) {
+		return errors.New("java.lang.invoke.WrongMethodTypeException: cannot convert MethodHandle" +
+			typeString(h.handleType()) + " to " + strconv.Itoa(len(args)) + " arguments")
+	}
+	value, err := InvokeMethod(class, h.name, h.desc, args)
+	if err != nil {
+		return err
+	}
+	if ret == 'V' {
+		return nil
+	}
+	return value
+}
+
+// collectArgs packs the trailing arguments of a call of the variable arity handle,
+// whose parameters have the call site's descriptors, into an array for the handle's
+// last parameter. A call that passes the array in that position is left as it is.
+func (h *javaMethodHandle) collectArgs(args []int64, callParams []string) []int64 {
+	params, _ := descriptorTypes(h.handleType())
+	fixed := len(params) - 1
+	if fixed < 0 || len(args) < fixed {
+		return args
+	}
+	arrayType := params[fixed]
+	if len(callParams) == len(params) && (callParams[fixed] == arrayType ||
+		arrayType == "[Ljava/lang/Object;" && strings.HasPrefix(callParams[fixed], "[L")) {
+		return args
+	}
+
+	array := NewArray(arrayType, len(args)-fixed)
+	switch elements := GetObject(array).Native.(type) {
+	case []int64:
+		copy(elements, args[fixed:])
+	case []byte:
+		for i, arg := range args[fixed:] {
+			elements[i] = byte(arg)
+		}
+	case []uint16:
+		for i, arg := range args[fixed:] {
+			elements[i] = uint16(arg)
+		}
+	}
+	return append(append([]int64(nil), args[:fixed]...), array)
+}
+
+// newMethodHandle creates a MethodHandle object for the target
+func newMethodHandle(target methodHandleTarget) int64 {
+	ref := NewObject("java/lang/invoke/MethodHandle", 0)
+	GetObject(ref).Native = target
+	return ref
+}
+
+// javaHandleOf returns the target of the referenced MethodHandle, or nil if it's not
+// a handle for a Java method
+func javaHandleOf(ref int64) *javaMethodHandle {
+	if obj := GetObject(ref); obj != nil {
+		if h, ok := obj.Native.(*javaMethodHandle); ok {
+			return h
+		}
+	}
+	return nil
+}
+
+func newMethodType(desc string) int64 {
+	ref := NewObject("java/lang/invoke/MethodType", 0)
+	GetObject(ref).Native = &methodTypeDesc{desc: desc}
+	return ref
+}
+
+func methodTypeOf(ref int64) string {
+	if obj := GetObject(ref); obj != nil {
+		if t, ok := obj.Native.(*methodTypeDesc); ok {
+			return t.desc
+		}
+	}
+	return "()V"
+}
+
+// typeString returns what MethodType.toString() does for the descriptor, such as
+// (int,String[])void
+func typeString(desc string) string {
+	params, ret := descriptorTypes(desc)
+	names := make([]string, len(params))
+	for i, p := range params {
+		names[i] = simpleTypeName(p)
+	}
+	return "(" + strings.Join(names, ",") + ")" + simpleTypeName(ret)
+}
+
+// simpleTypeName returns the simple name of the type with the field descriptor
+func simpleTypeName(desc string) string {
+	if strings.HasPrefix(desc, "[") {
+		return simpleTypeName(desc[1:]) + "[]"
+	}
+	if strings.HasPrefix(desc, "L") {
+		name := strings.TrimSuffix(desc[1:], ";")
+		return name[strings.LastIndexAny(name, "/$")+1:]
+	}
+	for name, d := range primitiveDescriptors {
+		if desc == string(d) {
+			return name
+		}
+	}
+	return desc
+}
+
+// findMethod looks up a method for a Lookup. A virtual method may be declared by a
+// superclass of the class.
+func findMethod(className, name, desc string, static bool) (*javaMethodHandle, error) {
+	for c := className; c != ""; c = superclassOf(c) {
+		for _, m := range declaredMethods(c) {
+			if m.name == name && m.desc == desc && (m.modifiers&ACC_STATIC != 0) == static {
+				return &javaMethodHandle{class: c, name: name, desc: desc, static: static, varargs: m.varargs}, nil
+			}
+		}
+		if static {
+			break
+		}
+	}
+	kind := "/invokeVirtual"
+	if static {
+		kind = "/invokeStatic"
+	}
+	return nil, errors.New("java.lang.NoSuchMethodException: no such method: " +
+		strings.ReplaceAll(className, "/", ".") + "." + name + typeString(desc) + kind)
+}
+
+var polymorphicMethods = []string{
+	"java/lang/invoke/MethodHandle.invokeExact(",
+	"java/lang/invoke/MethodHandle.invoke(",
+}
+
+// SignaturePolymorphic returns the MTable entry for a call of a signature-polymorphic
+// method with the given descriptor, adding it to the MTable. If the method is not
+// signature polymorphic, the second return value is false.
+func SignaturePolymorphic(methFQN string) (MTentry, bool) {
+	for _, name := range polymorphicMethods {
+		if !strings.HasPrefix(methFQN, name) {
+			continue
+		}
+		params, ret, err := ParseDescriptor(methFQN[len(name)-1:])
+		if err != nil {
+			return MTentry{}, false
+		}
+		gme := GmEntry{
+			ParamSlots: len(params) + 1, // the handle itself, then the arguments
+			Fu: func(slots []interface{}) interface{} {
+				obj := GetObject(slots[0].(int64))
+				if obj == nil {
+					return errors.New("java.lang.NullPointerException")
+				}
+				target, ok := obj.Native.(methodHandleTarget)
+				if !ok { // a handle that wasn't created by the natives, which has no target
+					return errors.New("java.lang.invoke.WrongMethodTypeException: cannot invoke " +
+						strings.ReplaceAll(obj.Klass, "/", ".") + " as " + typeString(methFQN[len(name)-1:]))
+				}
+				args := make([]int64, len(params))
+				for i := range params {
+					args[i] = slots[i+1].(int64)
+				}
+				if h, ok := target.(*javaMethodHandle); ok && h.varargs && name == polymorphicMethods[1] {
+					callParams, _ := descriptorTypes(methFQN[len(name)-1:])
+					args = h.collectArgs(args, callParams)
+				}
+				return target.invoke(args, ret)
+			},
+		}
+		entry := MTentry{Meth: gme, MType: 'G'}
+		addEntry(&MTable, MethodKey(methFQN), entry)
+		return entry, true
+	}
+	return MTentry{}, false
+}
+
+func Load_Lang_Invoke() map[string]GMeth {
+	mt := "java/lang/invoke/MethodType"
+	mtDesc := "Ljava/lang/invoke/MethodType;"
+	typeOf := func(ret int64, params ...int64) int64 {
+		desc := "("
+		for _, p := range params {
+			desc += classDescriptor(p)
+		}
+		return newMethodType(desc + ")" + classDescriptor(ret))
+	}
+	addNative(mt+".methodType(Ljava/lang/Class;)"+mtDesc, true, func(ret int64) int64 {
+		return typeOf(ret)
+	})
+	addNative(mt+".methodType(Ljava/lang/Class;Ljava/lang/Class;)"+mtDesc, true, func(ret, param int64) int64 {
+		return typeOf(ret, param)
+	})
+	addNative(mt+".methodType(Ljava/lang/Class;[Ljava/lang/Class;)"+mtDesc, true, func(ret, params int64) int64 {
+		types, _ := RefArrayFromRef(params)
+		return typeOf(ret, types...)
+	})
+	addNative(mt+".methodType(Ljava/lang/Class;Ljava/lang/Class;[Ljava/lang/Class;)"+mtDesc, true,
+		func(ret, param0, params int64) int64 {
+			types, _ := RefArrayFromRef(params)
+			return typeOf(ret, append([]int64{param0}, types...)...)
+		})
+	addNative(mt+".parameterCount()I", false, func(this int64) int32 {
+		params, _ := descriptorTypes(methodTypeOf(this))
+		return int32(len(params))
+	})
+	addNative(mt+".toMethodDescriptorString()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(methodTypeOf(this))
+	})
+	addNative(mt+".toString()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(typeString(methodTypeOf(this)))
+	})
+
+	lookup := "java/lang/invoke/MethodHandles$Lookup"
+	mh := "Ljava/lang/invoke/MethodHandle;"
+	addNative("java/lang/invoke/MethodHandles.lookup()L"+lookup+";", true, func() int64 {
+		return NewObject(lookup, 0)
+	})
+	addNative("java/lang/invoke/MethodHandles.publicLookup()L"+lookup+";", true, func() int64 {
+		return NewObject(lookup, 0)
+	})
+
+	// the lookups and asVarargsCollector() throw, so they're registered as raw GMeths,
+	// which can return an error
+	find := func(static bool) GMeth {
+		return GMeth{
+			ParamSlots: 4,
+			GFunction: func(params []interface{}) interface{} {
+				className, _ := ClassNameOf(params[1].(int64))
+				name, ok := GoStringFromRef(params[2].(int64))
+				if !ok || GetObject(params[3].(int64)) == nil {
+					return errors.New("java.lang.NullPointerException")
+				}
+				h, err := findMethod(className, name, methodTypeOf(params[3].(int64)), static)
+				if err != nil {
+					return err
+				}
+				return newMethodHandle(h)
+			},
+		}
+	}
+	MethodSignatures[lookup+".findStatic(Ljava/lang/Class;Ljava/lang/String;"+mtDesc+")"+mh] = find(true)
+	MethodSignatures[lookup+".findVirtual(Ljava/lang/Class;Ljava/lang/String;"+mtDesc+")"+mh] = find(false)
+	addNative(lookup+".unreflect(Ljava/lang/reflect/Method;)"+mh, false, func(this, method int64) int64 {
+		m := methodOf(method)
+		return newMethodHandle(&javaMethodHandle{class: m.class, name: m.name, desc: m.desc,
+			static: m.modifiers&ACC_STATIC != 0, varargs: m.varargs})
+	})
+
+	handle := "java/lang/invoke/MethodHandle"
+	addNative(handle+".type()"+mtDesc, false, func(this int64) int64 {
+		if h := javaHandleOf(this); h != nil {
+			return newMethodType(h.handleType())
+		}
+		return 0
+	})
+	addNative(handle+".isVarargsCollector()Z", false, func(this int64) bool {
+		h := javaHandleOf(this)
+		return h != nil && h.varargs
+	})
+	addNative(handle+".asFixedArity()"+mh, false, func(this int64) int64 {
+		h := javaHandleOf(this)
+		if h == nil || !h.varargs {
+			return this
+		}
+		fixed := *h
+		fixed.varargs = false
+		return newMethodHandle(&fixed)
+	})
+	MethodSignatures[handle+".asVarargsCollector(Ljava/lang/Class;)"+mh] = GMeth{
+		ParamSlots: 2,
+		GFunction: func(params []interface{}) interface{} {
+			this, arrayClass := params[0].(int64), params[1].(int64)
+			h := javaHandleOf(this)
+			if h == nil {
+				return this
+			}
+			arrayType := classDescriptor(arrayClass)
+			if !strings.HasPrefix(arrayType, "[") {
+				return errors.New("java.lang.IllegalArgumentException: not an array class: " +
+					strings.ReplaceAll(strings.Trim(arrayType, "L;"), "/", "."))
+			}
+			types, _ := descriptorTypes(h.handleType())
+			if len(types) == 0 || types[len(types)-1] != arrayType {
+				return errors.New("java.lang.IllegalArgumentException: array type not assignable to trailing argument: " +
+					typeString(h.handleType()) + ", " + simpleTypeName(arrayType))
+			}
+			collector := *h
+			collector.varargs = true
+			return newMethodHandle(&collector)
+		},
+	}
+	MethodSignatures[handle+".invokeWithArguments([Ljava/lang/Object;)Ljava/lang/Object;"] = GMeth{
+		ParamSlots: 2,
+		GFunction: func(params []interface{}) interface{} {
+			h := javaHandleOf(params[0].(int64))
+			if h == nil {
+				return errors.New("java.lang.UnsupportedOperationException: invokeWithArguments")
+			}
+			args, _ := RefArrayFromRef(params[1].(int64))
+			if h.varargs { // the arguments are Objects, so the trailing ones are packed
+				callParams := make([]string, len(args))
+				for i := range callParams {
+					callParams[i] = "Ljava/lang/Object;"
+				}
+				args = h.collectArgs(args, callParams)
+			}
+			value := h.invoke(args, 'L')
+			if value == nil {
+				return int64(0)
+			}
+			return value
+		},
+	}
+	return MethodSignatures
+}
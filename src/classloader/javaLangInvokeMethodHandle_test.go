@@ -0,0 +1,124 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"strings"
+	"testing"
+)
+
+// invokeHandle calls the handle with the signature-polymorphic method, as from a call
+// site whose descriptor is desc
+func invokeHandle(t *testing.T, method, desc string, handle int64, args ...int64) interface{} {
+	entry, ok := SignaturePolymorphic("java/lang/invoke/MethodHandle." + method + desc)
+	if !ok {
+		t.Fatalf("Expected %s%s to be signature polymorphic", method, desc)
+	}
+	slots := []interface{}{handle}
+	for _, a := range args {
+		slots = append(slots, a)
+	}
+	return entry.Meth.(GmEntry).Fu(slots)
+}
+
+// a handle for static String join(String sep, String... parts) collects the parts
+func TestVarargsCollector(t *testing.T) {
+	MTable = make(MT)
+	Load_Lang_Invoke()
+	Load_Lang_Reflect()
+	joinDesc := "(Ljava/lang/String;[Ljava/lang/String;)Ljava/lang/String;"
+	addTestClass("test/Joiner", "", nil, nil, []testMember{{0x0089, "join", joinDesc}})
+
+	var invokedArgs []int64
+	InvokeMethod = func(className, methodName, methodType string, args []int64) (int64, error) {
+		invokedArgs = args
+		return NewStringObject("joined"), nil
+	}
+	defer func() {
+		InvokeMethod = func(string, string, string, []int64) (int64, error) { return 0, nil }
+	}()
+
+	handle := callNative(t, "java/lang/invoke/MethodHandles$Lookup.findStatic(Ljava/lang/Class;Ljava/lang/String;"+
+		"Ljava/lang/invoke/MethodType;)Ljava/lang/invoke/MethodHandle;",
+		NewObject("java/lang/invoke/MethodHandles$Lookup", 0), ClassObject("test/Joiner"),
+		NewStringObject("join"), newMethodType(joinDesc)).(int64)
+	if callNative(t, "java/lang/invoke/MethodHandle.isVarargsCollector()Z", handle) != int64(1) {
+		t.Fatal("Expected the handle for a variable arity method to be a varargs collector")
+	}
+
+	sep, a, b := NewStringObject(","), NewStringObject("a"), NewStringObject("b")
+	s := "Ljava/lang/String;"
+	ret := invokeHandle(t, "invoke", "("+s+s+s+")"+s, handle, sep, a, b)
+	if javaString(ret.(int64)) != "joined" {
+		t.Errorf("Expected invoke() to return the method's return value")
+	}
+	parts, _ := RefArrayFromRef(invokedArgs[len(invokedArgs)-1])
+	if len(invokedArgs) != 2 || invokedArgs[0] != sep || len(parts) != 2 || parts[0] != a || parts[1] != b ||
+		GetObject(invokedArgs[1]).Klass != "[Ljava/lang/String;" {
+		t.Errorf("Expected the trailing arguments to be packed into a String[], got %v", invokedArgs)
+	}
+
+	// an array in the trailing position is passed as it is
+	array := NewRefArray("java/lang/String", []int64{a})
+	invokeHandle(t, "invoke", joinDesc, handle, sep, array)
+	if len(invokedArgs) != 2 || invokedArgs[1] != array {
+		t.Errorf("Expected the String[] to be passed as it is, got %v", invokedArgs)
+	}
+
+	// with no trailing arguments, the array is empty
+	invokeHandle(t, "invoke", "("+s+")"+s, handle, sep)
+	if parts, _ = RefArrayFromRef(invokedArgs[1]); len(invokedArgs) != 2 || len(parts) != 0 {
+		t.Errorf("Expected an empty String[], got %v", invokedArgs)
+	}
+
+	// a fixed arity handle can't be invoked with the trailing arguments
+	fixed := callNative(t, "java/lang/invoke/MethodHandle.asFixedArity()Ljava/lang/invoke/MethodHandle;", handle).(int64)
+	err, _ := invokeHandle(t, "invoke", "("+s+s+s+")"+s, fixed, sep, a, b).(error)
+	if err == nil || !strings.HasPrefix(err.Error(), "java.lang.invoke.WrongMethodTypeException") {
+		t.Errorf("Expected WrongMethodTypeException from the fixed arity handle, got %v", err)
+	}
+}
+
+func TestAsVarargsCollector(t *testing.T) {
+	Load_Lang_Invoke()
+	handle := newMethodHandle(&javaMethodHandle{class: "test/Sum", name: "sum", desc: "([I)I", static: true})
+	asCollector := "java/lang/invoke/MethodHandle.asVarargsCollector(Ljava/lang/Class;)Ljava/lang/invoke/MethodHandle;"
+
+	collector := callNative(t, asCollector, handle, ClassObject("[I")).(int64)
+	if !javaHandleOf(collector).varargs || javaHandleOf(handle).varargs {
+		t.Fatal("Expected asVarargsCollector() to return a new, variable arity handle")
+	}
+	args := javaHandleOf(collector).collectArgs([]int64{3, 4, 5}, []string{"I", "I", "I"})
+	ints := GetObject(args[0])
+	if len(args) != 1 || ints.Klass != "[I" || len(ints.Native.([]int64)) != 3 || ints.Native.([]int64)[2] != 5 {
+		t.Errorf("Expected the ints to be packed into an int[], got %v", args)
+	}
+
+	err, _ := callNative(t, asCollector, handle, ClassObject("java/lang/String")).(error)
+	if err == nil || err.Error() != "java.lang.IllegalArgumentException: not an array class: java.lang.String" {
+		t.Errorf("Expected IllegalArgumentException for a class that isn't an array, got %v", err)
+	}
+}
+
+func TestMethodIsVarArgs(t *testing.T) {
+	Load_Lang_Reflect()
+	addTestClass("test/Formatter", "", nil, nil, []testMember{
+		{0x0089, "format", "([Ljava/lang/Object;)V"}, {0x0009, "print", "(Ljava/lang/Object;)V"}})
+	methods := declaredMethods("test/Formatter")
+	if callNative(t, "java/lang/reflect/Method.isVarArgs()Z", newMethodObject(methods[0])) != int64(1) ||
+		callNative(t, "java/lang/reflect/Method.isVarArgs()Z", newMethodObject(methods[1])) != int64(0) {
+		t.Error("Expected only format() to have variable arity")
+	}
+
+	// invoke() spreads its arguments, so format()'s array is a single argument
+	invoke := "java/lang/reflect/Method.invoke(Ljava/lang/Object;[Ljava/lang/Object;)Ljava/lang/Object;"
+	err, _ := callNative(t, invoke, newMethodObject(methods[0]), int64(0),
+		NewRefArray("java/lang/Object", []int64{NewStringObject("a"), NewStringObject("b")})).(error)
+	if err == nil || err.Error() != "java.lang.IllegalArgumentException: wrong number of arguments: 2 expected: 1" {
+		t.Errorf("Expected IllegalArgumentException for the unpacked arguments, got %v", err)
+	}
+}
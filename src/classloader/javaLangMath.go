@@ -0,0 +1,111 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "math"
+
+/*
+ The natives of java.lang.Math and java.lang.StrictMath, which delegate to Go's math
+ package. They're registered through the native registry (see natives.go), which
+ handles the conversion of doubles and floats to and from the operand stack.
+
+ StrictMath requires results that are bit-for-bit identical to those of fdlibm. The
+ functions whose results are exactly rounded (sqrt, floor, ceil, rint, IEEEremainder,
+ abs, etc.) are identical in Go and fdlibm, so they're registered for both classes.
+ Go's transcendental functions (sin, log, pow, etc.) are accurate to within an ulp or
+ so, as Math requires, but are not guaranteed to match fdlibm exactly. So they're
+ registered for Math only; StrictMath uses them only where the JDK's own StrictMath
+ is native, because there is no alternative, and relies on FdLibm.java otherwise.
+
+ Where Java's special-case results differ from Go's, the Java results are produced.
+*/
+
+func Load_Lang_Math() map[string]GMeth {
+	// exactly rounded functions: identical in Math and StrictMath
+	for _, class := range []string{"java/lang/Math", "java/lang/StrictMath"} {
+		addNative(class+".sqrt(D)D", true, math.Sqrt)
+		addNative(class+".floor(D)D", true, math.Floor)
+		addNative(class+".ceil(D)D", true, math.Ceil)
+		addNative(class+".rint(D)D", true, math.RoundToEven)
+		addNative(class+".IEEEremainder(DD)D", true, math.Remainder)
+		addNative(class+".abs(D)D", true, math.Abs)
+		addNative(class+".abs(F)F", true, func(f float32) float32 {
+			return float32(math.Abs(float64(f)))
+		})
+		addNative(class+".abs(I)I", true, func(i int32) int32 {
+			if i < 0 {
+				return -i // as in Java, abs(Integer.MIN_VALUE) is MIN_VALUE
+			}
+			return i
+		})
+		addNative(class+".abs(J)J", true, func(l int64) int64 {
+			if l < 0 {
+				return -l
+			}
+			return l
+		})
+		addNative(class+".max(DD)D", true, math.Max)
+		addNative(class+".min(DD)D", true, math.Min)
+		addNative(class+".max(II)I", true, func(a, b int32) int32 {
+			if a > b {
+				return a
+			}
+			return b
+		})
+		addNative(class+".min(II)I", true, func(a, b int32) int32 {
+			if a < b {
+				return a
+			}
+			return b
+		})
+		addNative(class+".max(JJ)J", true, func(a, b int64) int64 {
+			if a > b {
+				return a
+			}
+			return b
+		})
+		addNative(class+".min(JJ)J", true, func(a, b int64) int64 {
+			if a < b {
+				return a
+			}
+			return b
+		})
+	}
+
+	// transcendental functions. The JDK 11 StrictMath declares sin through tanh (other
+	// than cbrt, pow, hypot, exp) as native, so they're registered for it too.
+	transcendentals := map[string]func(float64) float64{
+		"sin": math.Sin, "cos": math.Cos, "tan": math.Tan,
+		"asin": math.Asin, "acos": math.Acos, "atan": math.Atan,
+		"log": math.Log, "log10": math.Log10, "sinh": math.Sinh,
+		"cosh": math.Cosh, "tanh": math.Tanh, "expm1": math.Expm1, "log1p": math.Log1p,
+	}
+	for name, fn := range transcendentals {
+		addNative("java/lang/Math."+name+"(D)D", true, fn)
+		addNative("java/lang/StrictMath."+name+"(D)D", true, fn)
+	}
+	addNative("java/lang/Math.exp(D)D", true, math.Exp)
+	addNative("java/lang/Math.cbrt(D)D", true, math.Cbrt)
+	addNative("java/lang/Math.atan2(DD)D", true, math.Atan2)
+	addNative("java/lang/StrictMath.atan2(DD)D", true, math.Atan2)
+	addNative("java/lang/Math.hypot(DD)D", true, math.Hypot)
+	addNative("java/lang/Math.pow(DD)D", true, javaPow)
+	return MethodSignatures
+}
+
+// javaPow is Math.pow(). Go's math.Pow differs from Java in two special cases:
+// Java returns NaN when the exponent is NaN (Go returns 1 if the base is 1), and
+// when the base is -1 and the exponent is infinite (Go returns 1).
+func javaPow(x, y float64) float64 {
+	if math.IsNaN(y) {
+		return math.NaN()
+	}
+	if math.Abs(x) == 1 && math.IsInf(y, 0) {
+		return math.NaN()
+	}
+	return math.Pow(x, y)
+}
@@ -0,0 +1,65 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"math"
+	"testing"
+)
+
+func callDouble(t *testing.T, fqn string, args ...float64) float64 {
+	var slots []interface{}
+	for _, a := range args {
+		slots = append(slots, SlotFromFloat(a))
+	}
+	return FloatFromSlot(callNative(t, fqn, slots...).(int64))
+}
+
+func TestMathNatives(t *testing.T) {
+	Load_Lang_Math()
+	if callDouble(t, "java/lang/Math.sqrt(D)D", 2.25) != 1.5 {
+		t.Errorf("Expected Math.sqrt(2.25) to be 1.5")
+	}
+	if callDouble(t, "java/lang/StrictMath.floor(D)D", -1.5) != -2 {
+		t.Errorf("Expected StrictMath.floor(-1.5) to be -2.0")
+	}
+	if callDouble(t, "java/lang/Math.rint(D)D", 2.5) != 2 {
+		t.Errorf("Expected Math.rint(2.5) to round to even (2.0)")
+	}
+	if callDouble(t, "java/lang/Math.IEEEremainder(DD)D", 5, 3) != -1 {
+		t.Errorf("Expected Math.IEEEremainder(5, 3) to be -1.0")
+	}
+	if math.Abs(callDouble(t, "java/lang/Math.sin(D)D", math.Pi/2)-1) > 1e-15 {
+		t.Errorf("Expected Math.sin(pi/2) to be 1.0")
+	}
+}
+
+func TestMathPowSpecialCases(t *testing.T) {
+	Load_Lang_Math()
+	if !math.IsNaN(callDouble(t, "java/lang/Math.pow(DD)D", 1, math.NaN())) {
+		t.Errorf("Expected Math.pow(1, NaN) to be NaN")
+	}
+	if !math.IsNaN(callDouble(t, "java/lang/Math.pow(DD)D", -1, math.Inf(1))) {
+		t.Errorf("Expected Math.pow(-1, Infinity) to be NaN")
+	}
+	if callDouble(t, "java/lang/Math.pow(DD)D", 2, 10) != 1024 {
+		t.Errorf("Expected Math.pow(2, 10) to be 1024")
+	}
+}
+
+func TestMathIntegerNatives(t *testing.T) {
+	Load_Lang_Math()
+	if callNative(t, "java/lang/Math.abs(I)I", int64(math.MinInt32)).(int64) != math.MinInt32 {
+		t.Errorf("Expected Math.abs(Integer.MIN_VALUE) to be Integer.MIN_VALUE")
+	}
+	if callNative(t, "java/lang/Math.max(JJ)J", int64(-5), int64(3)).(int64) != 3 {
+		t.Errorf("Expected Math.max(-5L, 3L) to be 3")
+	}
+	if callDouble(t, "java/lang/Math.abs(F)F", -2.5) != 2.5 {
+		t.Errorf("Expected Math.abs(-2.5f) to be 2.5")
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// System.load(), System.loadLibrary(), and their Runtime equivalents, which load
+// shared libraries containing JNI native methods. Calling into a native library
+// requires cgo, so it's done by the jni package, which the interpreter hooks in
+// by setting LoadJNILibrary and ResolveJNI when execution begins. Without those
+// hooks, loading a library fails with an UnsatisfiedLinkError.
+
+// LoadJNILibrary loads the shared library at the given absolute path and runs its
+// JNI_OnLoad function, if it has one.
+var LoadJNILibrary = func(path string) error {
+	return errors.New("JNI native libraries are not supported by this build of Jacobin")
+}
+
+// ResolveJNI looks for an implementation of the named native method in the loaded
+// JNI libraries, either registered by RegisterNatives() or exported under its JNI
+// symbol name (Java_<class>_<method>).
+var ResolveJNI = func(methFQN string, isStatic bool) (GMeth, bool) {
+	return GMeth{}, false
+}
+
+var loadedLibraries = make(map[string]bool) // keyed by absolute path
+var librariesMutex sync.Mutex
+
+// MapLibraryName maps a library name to the platform-specific file name of the
+// library, as System.mapLibraryName() does: "foo" is libfoo.so on Linux, etc.
+func MapLibraryName(name string) string {
+	switch runtime.GOOS {
+	case "windows":
+		return name + ".dll"
+	case "darwin":
+		return "lib" + name + ".dylib"
+	default:
+		return "lib" + name + ".so"
+	}
+}
+
+// FindLibrary searches the directories in java.library.path for the named library
+// and returns the absolute path of the first match.
+func FindLibrary(name string) (string, bool) {
+	libPath, _ := globals.SystemProperties.Get("java.library.path")
+	for _, dir := range filepath.SplitList(libPath) {
+		if dir == "" {
+			continue
+		}
+		candidate, err := filepath.Abs(filepath.Join(dir, MapLibraryName(name)))
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// LoadLibraryFile loads the library at the absolute path. As in the JDK, loading
+// the same library more than once has no effect.
+func LoadLibraryFile(path string) error {
+	if !filepath.IsAbs(path) {
+		return errors.New("java.lang.UnsatisfiedLinkError: Expecting an absolute path of the library: " + path)
+	}
+	librariesMutex.Lock()
+	defer librariesMutex.Unlock()
+	if loadedLibraries[path] {
+		return nil
+	}
+	if err := LoadJNILibrary(path); err != nil {
+		return errors.New("java.lang.UnsatisfiedLinkError: " + path + ": " + err.Error())
+	}
+	loadedLibraries[path] = true
+	return nil
+}
+
+// LoadLibrary finds the named library on java.library.path and loads it
+func LoadLibrary(name string) error {
+	if strings.ContainsAny(name, "/\\") {
+		return errors.New("java.lang.UnsatisfiedLinkError: Directory separator should not appear in library name: " + name)
+	}
+	path, found := FindLibrary(name)
+	if !found {
+		libPath, _ := globals.SystemProperties.Get("java.library.path")
+		return errors.New("java.lang.UnsatisfiedLinkError: no " + name + " in java.library.path: " + libPath)
+	}
+	return LoadLibraryFile(path)
+}
+
+// loadNamed loads a library for the natives below. A null name throws NPE, and a
+// library that can't be found or loaded throws UnsatisfiedLinkError.
+func loadNamed(load func(string) error, nameRef int64) error {
+	name, ok := GoStringFromRef(nameRef)
+	if !ok {
+		return errNPE
+	}
+	return load(name)
+}
+
+func Load_Lang_NativeLibraries() map[string]GMeth {
+	addNative("java/lang/System.load(Ljava/lang/String;)V", true, func(path int64) error {
+		return loadNamed(LoadLibraryFile, path)
+	})
+	addNative("java/lang/System.loadLibrary(Ljava/lang/String;)V", true, func(name int64) error {
+		return loadNamed(LoadLibrary, name)
+	})
+	addNative("java/lang/Runtime.load(Ljava/lang/String;)V", false, func(this, path int64) error {
+		return loadNamed(LoadLibraryFile, path)
+	})
+	addNative("java/lang/Runtime.loadLibrary(Ljava/lang/String;)V", false, func(this, name int64) error {
+		return loadNamed(LoadLibrary, name)
+	})
+	addNative("java/lang/System.mapLibraryName(Ljava/lang/String;)Ljava/lang/String;", true,
+		func(name int64) int64 {
+			return NewStringObject(MapLibraryName(javaString(name)))
+		})
+	return MethodSignatures
+}
@@ -0,0 +1,98 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// withLoader substitutes a fake JNI library loader for the duration of a test
+func withLoader(t *testing.T, load func(string) error) {
+	saved := LoadJNILibrary
+	LoadJNILibrary = load
+	t.Cleanup(func() { LoadJNILibrary = saved })
+}
+
+func TestMapLibraryName(t *testing.T) {
+	Load_Lang_NativeLibraries()
+	want := map[string]string{"windows": "foo.dll", "darwin": "libfoo.dylib"}[runtime.GOOS]
+	if want == "" {
+		want = "libfoo.so"
+	}
+	if got := MapLibraryName("foo"); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	ret := callNative(t, "java/lang/System.mapLibraryName(Ljava/lang/String;)Ljava/lang/String;",
+		NewStringObject("foo"))
+	if javaString(ret.(int64)) != want {
+		t.Errorf("System.mapLibraryName: expected %s, got %s", want, javaString(ret.(int64)))
+	}
+}
+
+func TestLoadLibrarySearchesLibraryPath(t *testing.T) {
+	empty, dir := t.TempDir(), t.TempDir()
+	lib := filepath.Join(dir, MapLibraryName("found"))
+	if err := os.WriteFile(lib, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	globals.SystemProperties.Set("java.library.path", empty+string(os.PathListSeparator)+dir)
+	defer globals.SystemProperties.Clear("java.library.path")
+
+	var loaded []string
+	withLoader(t, func(path string) error {
+		loaded = append(loaded, path)
+		return nil
+	})
+
+	if err := LoadLibrary("found"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := LoadLibrary("found"); err != nil { // a second load is a no-op
+		t.Fatalf("Unexpected error on reload: %v", err)
+	}
+	if len(loaded) != 1 || loaded[0] != lib {
+		t.Errorf("Expected %s to be loaded exactly once, got %v", lib, loaded)
+	}
+
+	err := LoadLibrary("missing")
+	if err == nil || !strings.Contains(err.Error(), "no missing in java.library.path") {
+		t.Errorf("Expected UnsatisfiedLinkError for missing library, got %v", err)
+	}
+	if err := LoadLibrary("sub/dir"); err == nil {
+		t.Error("Expected an error for a library name containing a separator")
+	}
+}
+
+func TestLoadLibraryFileErrors(t *testing.T) {
+	if err := LoadLibraryFile("relative.so"); err == nil {
+		t.Error("Expected an error for a relative path")
+	}
+
+	withLoader(t, func(path string) error { return errors.New("bad ELF header") })
+	path := filepath.Join(t.TempDir(), "libbad.so")
+	err := LoadLibraryFile(path)
+	if err == nil || !strings.HasPrefix(err.Error(), "java.lang.UnsatisfiedLinkError") {
+		t.Errorf("Expected an UnsatisfiedLinkError, got %v", err)
+	}
+
+	// the natives throw the error, rather than just logging it
+	Load_Lang_NativeLibraries()
+	err, _ = callNative(t, "java/lang/System.load(Ljava/lang/String;)V", NewStringObject(path)).(error)
+	if err == nil || !strings.HasPrefix(err.Error(), "java.lang.UnsatisfiedLinkError") {
+		t.Errorf("Expected System.load() to throw UnsatisfiedLinkError, got %v", err)
+	}
+	err, _ = callNative(t, "java/lang/System.loadLibrary(Ljava/lang/String;)V", int64(0)).(error)
+	if err == nil || err.Error() != "java.lang.NullPointerException" {
+		t.Errorf("Expected System.loadLibrary(null) to throw NullPointerException, got %v", err)
+	}
+}
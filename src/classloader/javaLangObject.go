@@ -0,0 +1,57 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+// java.lang.Object.clone(). javac calls it on arrays (as in the values() method of
+// every enum, which returns a copy of the array of the enum's constants), so it's
+// invoked on array classes, such as [LColor;, whose superclass is Object.
+
+// CloneObject returns a shallow copy of the referenced object: its field slots and
+// its Go-side state are copied, and an array's elements are copied into a new array.
+func CloneObject(ref int64) int64 {
+	obj := GetObject(ref)
+	if obj == nil {
+		return 0
+	}
+	clone := NewObject(obj.Klass, len(obj.Fields))
+	cloned := GetObject(clone)
+	copy(cloned.Fields, obj.Fields)
+	switch elements := obj.Native.(type) {
+	case []int64:
+		cloned.Native = append([]int64(nil), elements...)
+	case []byte:
+		cloned.Native = append([]byte(nil), elements...)
+	case []uint16:
+		cloned.Native = append([]uint16(nil), elements...)
+	default:
+		cloned.Native = obj.Native
+	}
+	return clone
+}
+
+// ObjectWait waits on the object's monitor, which the current thread must own, until
+// it's notified or interrupted or, if millis isn't 0, millis milliseconds have passed.
+// ObjectNotify wakes one of the threads waiting on the monitor, or all of them. The
+// interpreter sets them when execution begins (see jvm/monitor.go).
+var ObjectWait = func(ref, millis int64) error { return nil }
+var ObjectNotify = func(ref int64, all bool) error { return nil }
+
+func Load_Lang_Object() map[string]GMeth {
+	addNative("java/lang/Object.clone()Ljava/lang/Object;", false, func(this int64) int64 {
+		return CloneObject(this) // TODO: throw CloneNotSupportedException for non-Cloneables
+	})
+	addNative("java/lang/Object.wait(J)V", false, func(this, millis int64) error {
+		return ObjectWait(this, millis)
+	})
+	addNative("java/lang/Object.notify()V", false, func(this int64) error {
+		return ObjectNotify(this, false)
+	})
+	addNative("java/lang/Object.notifyAll()V", false, func(this int64) error {
+		return ObjectNotify(this, true)
+	})
+	return MethodSignatures
+}
@@ -0,0 +1,417 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Process creation, via ProcessBuilder and Runtime.exec(), over os/exec. A ProcessBuilder's
+// Native field is a *processBuilder; a Process's is a *javaProcess. Each of the child's
+// standard streams is either a pipe to the parent (the default), inherited from the VM,
+// or redirected to or from a file, as set by a ProcessBuilder.Redirect.
+//
+// The pipes are created here (rather than by os/exec) so that the Process's streams
+// stay readable after the child has exited and been waited for. If the process can't
+// be started, start() and exec() throw an IOException, as in the JDK.
+
+type redirectKind int
+
+const (
+	redirectPipe redirectKind = iota
+	redirectInherit
+	redirectDiscard
+	redirectRead   // from a file
+	redirectWrite  // to a file
+	redirectAppend // to the end of a file
+)
+
+type redirect struct {
+	kind redirectKind
+	path string
+}
+
+type processBuilder struct {
+	command     []string
+	dir         string       // empty = the VM's working directory
+	env         *environment // nil = the VM's environment
+	redirects   [3]redirect  // stdin, stdout, stderr
+	mergeStderr bool         // redirectErrorStream(true)
+}
+
+type javaProcess struct {
+	cmd      *exec.Cmd
+	stdin    *os.File // the parent's ends of the pipes; nil if not piped
+	stdout   *os.File
+	stderr   *os.File
+	done     chan struct{} // closed when the process has exited
+	exitCode int
+}
+
+func newRedirectObject(r redirect) int64 {
+	ref := NewObject("java/lang/ProcessBuilder$Redirect", 0)
+	GetObject(ref).Native = &r
+	return ref
+}
+
+// InitProcessStatics adds the ProcessBuilder.Redirect constants to the statics, so that
+// getstatic finds them already resolved (as InitStdStreams does for System.out, etc.)
+func InitProcessStatics() {
+	for name, kind := range map[string]redirectKind{
+		"PIPE": redirectPipe, "INHERIT": redirectInherit, "DISCARD": redirectDiscard} {
+		fullName := "java/lang/ProcessBuilder$Redirect." + name
+		if _, present := FindStatic(fullName); !present {
+			AddStatic(fullName, Static{Class: 'L', Type: "Ljava/lang/ProcessBuilder$Redirect;",
+				ValueInt: newRedirectObject(redirect{kind: kind})})
+		}
+	}
+}
+
+func builderOf(ref int64) *processBuilder {
+	if obj := GetObject(ref); obj != nil {
+		if pb, ok := obj.Native.(*processBuilder); ok {
+			return pb
+		}
+		pb := &processBuilder{}
+		obj.Native = pb
+		return pb
+	}
+	return &processBuilder{}
+}
+
+func processOf(ref int64) *javaProcess {
+	if obj := GetObject(ref); obj != nil {
+		if p, ok := obj.Native.(*javaProcess); ok {
+			return p
+		}
+	}
+	return nil
+}
+
+func redirectOf(ref int64) redirect {
+	if obj := GetObject(ref); obj != nil {
+		if r, ok := obj.Native.(*redirect); ok {
+			return *r
+		}
+	}
+	return redirect{kind: redirectPipe}
+}
+
+// stringArray returns the values of the Strings in the referenced String[]
+func stringArray(ref int64) []string {
+	refs, _ := RefArrayFromRef(ref)
+	strs := make([]string, len(refs))
+	for i, r := range refs {
+		strs[i] = javaString(r)
+	}
+	return strs
+}
+
+// childStream sets up one of the child's standard streams. It returns the file the
+// child uses and, for pipes, the parent's end of the pipe.
+func childStream(r redirect, fd int) (child, parent *os.File, err error) {
+	std := []*os.File{os.Stdin, os.Stdout, os.Stderr}[fd]
+	switch r.kind {
+	case redirectInherit:
+		return std, nil, nil
+	case redirectDiscard:
+		f, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+		return f, nil, err
+	case redirectRead:
+		f, err := os.Open(r.path)
+		return f, nil, err
+	case redirectWrite:
+		f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		return f, nil, err
+	case redirectAppend:
+		f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		return f, nil, err
+	default: // a pipe
+		pr, pw, err := os.Pipe()
+		if fd == 0 {
+			return pr, pw, err
+		}
+		return pw, pr, err
+	}
+}
+
+// startProcess starts the process described by the builder and returns the Process
+// object. An empty command throws IndexOutOfBoundsException, as in the JDK.
+func startProcess(pb *processBuilder) (int64, error) {
+	if len(pb.command) == 0 {
+		return 0, errors.New("java.lang.IndexOutOfBoundsException: Index 0 out of bounds for length 0")
+	}
+	cmd := exec.Command(pb.command[0], pb.command[1:]...)
+	cmd.Dir = pb.dir
+	if pb.env != nil {
+		cmd.Env = pb.env.entries()
+	}
+
+	p := &javaProcess{cmd: cmd, done: make(chan struct{})}
+	var childEnds []*os.File // closed in the parent once the child has them
+	defer func() {
+		for _, f := range childEnds {
+			if f != os.Stdin && f != os.Stdout && f != os.Stderr {
+				_ = f.Close()
+			}
+		}
+	}()
+	closeParentEnds := func() {
+		for _, f := range []*os.File{p.stdin, p.stdout, p.stderr} {
+			if f != nil {
+				_ = f.Close()
+			}
+		}
+	}
+
+	for fd := 0; fd < 3; fd++ {
+		if fd == 2 && pb.mergeStderr {
+			cmd.Stderr = cmd.Stdout
+			break
+		}
+		child, parent, err := childStream(pb.redirects[fd], fd)
+		if err != nil {
+			closeParentEnds()
+			if path := pb.redirects[fd].path; path != "" {
+				return 0, fmt.Errorf("java.io.FileNotFoundException: %s (%s)", path, osErrorText(err))
+			}
+			return 0, ioException(err)
+		}
+		childEnds = append(childEnds, child)
+		switch fd {
+		case 0:
+			cmd.Stdin, p.stdin = child, parent
+		case 1:
+			cmd.Stdout, p.stdout = child, parent
+		case 2:
+			cmd.Stderr, p.stderr = child, parent
+		}
+	}
+
+	if err := cmd.Start(); err != nil {
+		closeParentEnds()
+		return 0, cannotRun(pb, err)
+	}
+	registerChild(p)
+
+	go func() {
+		_ = cmd.Wait()
+		p.exitCode = exitCode(cmd.ProcessState)
+		close(p.done)
+		childExited(p)
+	}()
+
+	ref := NewObject("java/lang/ProcessImpl", 0)
+	GetObject(ref).Native = p
+	return ref, nil
+}
+
+// cannotRun returns the IOException of a process that couldn't be started, which gives
+// the OS's error number and text as the JDK does, as in: Cannot run program "x": error=2,
+// No such file or directory
+func cannotRun(pb *processBuilder, err error) error {
+	msg := fmt.Sprintf("java.io.IOException: Cannot run program %q", pb.command[0])
+	if pb.dir != "" {
+		msg += fmt.Sprintf(" (in directory %q)", pb.dir)
+	}
+	var errno syscall.Errno
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		errno = syscall.ENOENT // a command that isn't on the PATH
+	case !errors.As(err, &errno):
+		return errors.New(msg + ": " + osErrorText(err))
+	}
+	return fmt.Errorf("%s: error=%d, %s", msg, int(errno), osErrorText(errno))
+}
+
+// exitCode returns the exit code of the process as the JDK reports it. On Unix, a
+// process killed by a signal has an exit code of 128 plus the signal number.
+func exitCode(state *os.ProcessState) int {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return state.ExitCode()
+}
+
+// processStream creates the object for one of the Process's streams. As in the JDK, a
+// stream that isn't piped is a null stream: reads return -1, and writes throw an
+// IOException, as for a closed stream.
+func processStream(class string, f *os.File) int64 {
+	ref := NewObject(class, 0)
+	switch {
+	case f != nil:
+		GetObject(ref).Native = f
+	case strings.HasSuffix(class, "InputStream"):
+		GetObject(ref).Native = strings.NewReader("")
+	}
+	return ref
+}
+
+var runtimeRef int64
+var runtimeOnce sync.Once
+
+func Load_Lang_Process() map[string]GMeth {
+	pbClass := "java/lang/ProcessBuilder"
+	pbDesc := "L" + pbClass + ";"
+
+	addNative(pbClass+".<init>([Ljava/lang/String;)V", false, func(this, cmd int64) {
+		builderOf(this).command = stringArray(cmd)
+	})
+	addNative(pbClass+".command([Ljava/lang/String;)"+pbDesc, false, func(this, cmd int64) int64 {
+		builderOf(this).command = stringArray(cmd)
+		return this
+	})
+	addNative(pbClass+".directory(Ljava/io/File;)"+pbDesc, false, func(this, dir int64) int64 {
+		builderOf(this).dir = filePath(dir) // a null File means the VM's working directory
+		return this
+	})
+	addNative(pbClass+".directory()Ljava/io/File;", false, func(this int64) int64 {
+		if dir := builderOf(this).dir; dir != "" {
+			return NewFileObject(dir)
+		}
+		return 0
+	})
+	addNative(pbClass+".environment()Ljava/util/Map;", false, func(this int64) int64 {
+		pb := builderOf(this)
+		if pb.env == nil {
+			pb.env = processEnvironment().modifiableCopy()
+		}
+		ref := NewObject(envMapClass, 0)
+		GetObject(ref).Native = pb.env
+		return ref
+	})
+	addNative(pbClass+".redirectErrorStream(Z)"+pbDesc, false, func(this int64, merge bool) int64 {
+		builderOf(this).mergeStderr = merge
+		return this
+	})
+	addNative(pbClass+".redirectErrorStream()Z", false, func(this int64) bool {
+		return builderOf(this).mergeStderr
+	})
+	addNative(pbClass+".inheritIO()"+pbDesc, false, func(this int64) int64 {
+		pb := builderOf(this)
+		for fd := range pb.redirects {
+			pb.redirects[fd] = redirect{kind: redirectInherit}
+		}
+		return this
+	})
+	for fd, name := range []string{"Input", "Output", "Error"} {
+		fd := fd
+		fileKind := redirectWrite
+		if fd == 0 {
+			fileKind = redirectRead
+		}
+		addNative(pbClass+".redirect"+name+"(Ljava/lang/ProcessBuilder$Redirect;)"+pbDesc, false,
+			func(this, r int64) int64 {
+				builderOf(this).redirects[fd] = redirectOf(r)
+				return this
+			})
+		addNative(pbClass+".redirect"+name+"(Ljava/io/File;)"+pbDesc, false, func(this, file int64) int64 {
+			builderOf(this).redirects[fd] = redirect{kind: fileKind, path: filePath(file)}
+			return this
+		})
+	}
+	addNative(pbClass+".start()Ljava/lang/Process;", false, func(this int64) (int64, error) {
+		return startProcess(builderOf(this))
+	})
+
+	// the ProcessBuilder.Redirect factory methods
+	redir := "java/lang/ProcessBuilder$Redirect"
+	addNative(redir+".from(Ljava/io/File;)L"+redir+";", true, func(file int64) int64 {
+		return newRedirectObject(redirect{kind: redirectRead, path: filePath(file)})
+	})
+	addNative(redir+".to(Ljava/io/File;)L"+redir+";", true, func(file int64) int64 {
+		return newRedirectObject(redirect{kind: redirectWrite, path: filePath(file)})
+	})
+	addNative(redir+".appendTo(Ljava/io/File;)L"+redir+";", true, func(file int64) int64 {
+		return newRedirectObject(redirect{kind: redirectAppend, path: filePath(file)})
+	})
+
+	// java.lang.Runtime.exec(). As in the JDK, a command string is split into arguments
+	// at whitespace, with no quoting; an environment of null inherits the VM's.
+	rt := "java/lang/Runtime"
+	addNative(rt+".getRuntime()Ljava/lang/Runtime;", true, func() int64 {
+		runtimeOnce.Do(func() { runtimeRef = NewObject(rt, 0) })
+		return runtimeRef
+	})
+	runtimeExec := func(cmd []string, envp, dir int64) (int64, error) {
+		pb := &processBuilder{command: cmd, dir: filePath(dir)}
+		if envp != 0 {
+			pb.env = newEnvironment(stringArray(envp), processEnvironment().foldCase)
+		}
+		return startProcess(pb)
+	}
+	addNative(rt+".exec(Ljava/lang/String;)Ljava/lang/Process;", false, func(this, cmd int64) (int64, error) {
+		return runtimeExec(strings.Fields(javaString(cmd)), 0, 0)
+	})
+	addNative(rt+".exec([Ljava/lang/String;)Ljava/lang/Process;", false, func(this, cmd int64) (int64, error) {
+		return runtimeExec(stringArray(cmd), 0, 0)
+	})
+	addNative(rt+".exec([Ljava/lang/String;[Ljava/lang/String;)Ljava/lang/Process;", false,
+		func(this, cmd, envp int64) (int64, error) {
+			return runtimeExec(stringArray(cmd), envp, 0)
+		})
+	addNative(rt+".exec([Ljava/lang/String;[Ljava/lang/String;Ljava/io/File;)Ljava/lang/Process;", false,
+		func(this, cmd, envp, dir int64) (int64, error) {
+			return runtimeExec(stringArray(cmd), envp, dir)
+		})
+
+	// java.lang.Process. The input stream is the child's stdout; the output stream, its stdin.
+	proc := "java/lang/Process"
+	addNative(proc+".getInputStream()Ljava/io/InputStream;", false, func(this int64) int64 {
+		return processStream("java/lang/ProcessPipeInputStream", processOf(this).stdout)
+	})
+	addNative(proc+".getErrorStream()Ljava/io/InputStream;", false, func(this int64) int64 {
+		return processStream("java/lang/ProcessPipeInputStream", processOf(this).stderr)
+	})
+	addNative(proc+".getOutputStream()Ljava/io/OutputStream;", false, func(this int64) int64 {
+		return processStream("java/lang/ProcessPipeOutputStream", processOf(this).stdin)
+	})
+	addNative(proc+".waitFor()I", false, func(this int64) int32 {
+		p := processOf(this)
+		<-p.done
+		return int32(p.exitCode)
+	})
+	addNative(proc+".exitValue()I", false, func(this int64) (int32, error) {
+		p := processOf(this)
+		select {
+		case <-p.done:
+			return int32(p.exitCode), nil
+		default:
+			return 0, errors.New("java.lang.IllegalThreadStateException: process hasn't exited")
+		}
+	})
+	addNative(proc+".isAlive()Z", false, func(this int64) bool {
+		select {
+		case <-processOf(this).done:
+			return false
+		default:
+			return true
+		}
+	})
+	addNative(proc+".pid()J", false, func(this int64) int64 {
+		return int64(processOf(this).cmd.Process.Pid)
+	})
+	// destroy() asks the process to terminate (SIGTERM, on Unix); destroyForcibly() kills it.
+	// Where SIGTERM isn't supported (on Windows), destroy() kills the process too.
+	addNative(proc+".destroy()V", false, func(this int64) {
+		p := processOf(this).cmd.Process
+		if p.Signal(syscall.SIGTERM) != nil {
+			_ = p.Kill()
+		}
+	})
+	addNative(proc+".destroyForcibly()Ljava/lang/Process;", false, func(this int64) int64 {
+		_ = processOf(this).cmd.Process.Kill()
+		return this
+	})
+	return MethodSignatures
+}
@@ -0,0 +1,168 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// System.getenv() returns an unmodifiable map of the process environment. As in the
+// JDK, the environment is captured once, on first use, and does not reflect later
+// changes made by the VM itself. On Windows, environment variable names are case
+// insensitive, so lookups fold case there; on other platforms, names are case sensitive.
+//
+// ProcessBuilder.environment() returns a modifiable copy of the environment, which
+// becomes the environment of the processes the ProcessBuilder starts.
+
+const envMapClass = "java/lang/ProcessEnvironment$StringEnvironment"
+
+// errUnmodifiableEnv is thrown by attempts to change the map System.getenv() returns
+var errUnmodifiableEnv = errors.New("java.lang.UnsupportedOperationException")
+
+type environment struct {
+	vars       map[string]string // keyed by name, or upper-cased name if foldCase
+	foldCase   bool
+	modifiable bool
+}
+
+// newEnvironment builds an environment from entries of the form name=value, which is
+// the format of os.Environ(). Entries whose names begin with '=' are hidden variables
+// Windows uses for per-drive working directories (e.g., =C:=C:\tmp); the JDK skips them.
+func newEnvironment(entries []string, foldCase bool) *environment {
+	env := &environment{
+		vars:     make(map[string]string),
+		foldCase: foldCase,
+	}
+	for _, entry := range entries {
+		eq := strings.Index(entry, "=")
+		if eq <= 0 {
+			continue
+		}
+		env.vars[env.key(entry[:eq])] = entry[eq+1:]
+	}
+	return env
+}
+
+func (env *environment) key(name string) string {
+	if env.foldCase {
+		return strings.ToUpper(name)
+	}
+	return name
+}
+
+// lookup returns the value of the named variable, if present
+func (env *environment) lookup(name string) (string, bool) {
+	val, present := env.vars[env.key(name)]
+	return val, present
+}
+
+var processEnv *environment
+var processEnvOnce sync.Once
+
+func processEnvironment() *environment {
+	processEnvOnce.Do(func() {
+		processEnv = newEnvironment(os.Environ(), runtime.GOOS == "windows")
+	})
+	return processEnv
+}
+
+// modifiableCopy returns a copy of the environment that can be changed
+func (env *environment) modifiableCopy() *environment {
+	dup := &environment{vars: make(map[string]string), foldCase: env.foldCase, modifiable: true}
+	for k, v := range env.vars {
+		dup.vars[k] = v
+	}
+	return dup
+}
+
+// entries returns the environment in the name=value format of os/exec
+func (env *environment) entries() []string {
+	entries := make([]string, 0, len(env.vars))
+	for k, v := range env.vars {
+		entries = append(entries, k+"="+v)
+	}
+	return entries
+}
+
+// envOf returns the environment behind a getenv() map object
+func envOf(ref int64) *environment {
+	if obj := GetObject(ref); obj != nil {
+		if env, ok := obj.Native.(*environment); ok {
+			return env
+		}
+	}
+	return newEnvironment(nil, false)
+}
+
+func Load_Lang_ProcessEnvironment() map[string]GMeth {
+	addNative("java/lang/System.getenv(Ljava/lang/String;)Ljava/lang/String;", true,
+		func(name int64) int64 {
+			return propertyRef(processEnvironment().lookup(javaString(name)))
+		})
+	addNative("java/lang/System.getenv()Ljava/util/Map;", true, func() int64 {
+		ref := NewObject(envMapClass, 0)
+		GetObject(ref).Native = processEnvironment()
+		return ref
+	})
+
+	// the java.util.Map methods of the environment map. Keys and values that aren't
+	// Strings are never present, as in the JDK.
+	addNative(envMapClass+".get(Ljava/lang/Object;)Ljava/lang/Object;", false,
+		func(this, name int64) int64 {
+			s, ok := GoStringFromRef(name)
+			if !ok {
+				return 0
+			}
+			return propertyRef(envOf(this).lookup(s))
+		})
+	addNative(envMapClass+".containsKey(Ljava/lang/Object;)Z", false, func(this, name int64) bool {
+		s, ok := GoStringFromRef(name)
+		if !ok {
+			return false
+		}
+		_, present := envOf(this).lookup(s)
+		return present
+	})
+	addNative(envMapClass+".size()I", false, func(this int64) int32 {
+		return int32(len(envOf(this).vars))
+	})
+	addNative(envMapClass+".isEmpty()Z", false, func(this int64) bool {
+		return len(envOf(this).vars) == 0
+	})
+	addNative(envMapClass+".put(Ljava/lang/Object;Ljava/lang/Object;)Ljava/lang/Object;", false,
+		func(this, name, val int64) (int64, error) {
+			env := envOf(this)
+			if !env.modifiable {
+				return 0, errUnmodifiableEnv
+			}
+			if name == 0 || val == 0 {
+				return 0, errNPE
+			}
+			old, present := env.lookup(javaString(name))
+			env.vars[env.key(javaString(name))] = javaString(val)
+			return propertyRef(old, present), nil
+		})
+	addNative(envMapClass+".remove(Ljava/lang/Object;)Ljava/lang/Object;", false,
+		func(this, name int64) (int64, error) {
+			env := envOf(this)
+			s, ok := GoStringFromRef(name)
+			if !env.modifiable {
+				return 0, errUnmodifiableEnv
+			}
+			if !ok {
+				return 0, errNPE
+			}
+			old, present := env.lookup(s)
+			delete(env.vars, env.key(s))
+			return propertyRef(old, present), nil
+		})
+	return MethodSignatures
+}
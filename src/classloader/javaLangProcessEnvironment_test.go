@@ -0,0 +1,69 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestEnvironmentCaseSemantics(t *testing.T) {
+	entries := []string{"Path=/bin", "=C:=C:\\tmp", "EMPTY=", "A=b=c"}
+
+	windows := newEnvironment(entries, true)
+	if val, present := windows.lookup("PATH"); !present || val != "/bin" {
+		t.Errorf("Expected case-insensitive lookup of PATH to find /bin, got: %q", val)
+	}
+	if len(windows.vars) != 3 {
+		t.Errorf("Expected hidden =C: variable to be skipped, got %d vars", len(windows.vars))
+	}
+	if val, _ := windows.lookup("a"); val != "b=c" {
+		t.Errorf("Expected value to be everything after the first =, got: %q", val)
+	}
+
+	unix := newEnvironment(entries, false)
+	if _, present := unix.lookup("PATH"); present {
+		t.Errorf("Expected case-sensitive lookup of PATH to fail")
+	}
+	if val, present := unix.lookup("EMPTY"); !present || val != "" {
+		t.Errorf("Expected empty variable to be present")
+	}
+}
+
+func TestGetenvNatives(t *testing.T) {
+	os.Setenv("JACOBIN_GETENV_TEST", "xyz")
+	processEnvOnce = sync.Once{}
+	Load_Lang_ProcessEnvironment()
+
+	ref := callNative(t, "java/lang/System.getenv(Ljava/lang/String;)Ljava/lang/String;",
+		NewStringObject("JACOBIN_GETENV_TEST")).(int64)
+	if s, _ := GoStringFromRef(ref); s != "xyz" {
+		t.Errorf("Expected getenv() to return xyz, got: %q", s)
+	}
+
+	env := callNative(t, "java/lang/System.getenv()Ljava/util/Map;").(int64)
+	ref = callNative(t, envMapClass+".get(Ljava/lang/Object;)Ljava/lang/Object;",
+		env, NewStringObject("JACOBIN_GETENV_TEST")).(int64)
+	if s, _ := GoStringFromRef(ref); s != "xyz" {
+		t.Errorf("Expected map get() to return xyz, got: %q", s)
+	}
+	if callNative(t, envMapClass+".containsKey(Ljava/lang/Object;)Z", env, int64(0)).(int64) != 0 {
+		t.Errorf("Expected null key not to be present")
+	}
+	if err := callNative(t, envMapClass+".put(Ljava/lang/Object;Ljava/lang/Object;)Ljava/lang/Object;",
+		env, NewStringObject("JACOBIN_GETENV_TEST"), NewStringObject("changed")); err != errUnmodifiableEnv {
+		t.Errorf("Expected put() to throw UnsupportedOperationException, got: %v", err)
+	}
+	if err := callNative(t, envMapClass+".remove(Ljava/lang/Object;)Ljava/lang/Object;",
+		env, NewStringObject("JACOBIN_GETENV_TEST")); err != errUnmodifiableEnv {
+		t.Errorf("Expected remove() to throw UnsupportedOperationException, got: %v", err)
+	}
+	if val, _ := processEnvironment().lookup("JACOBIN_GETENV_TEST"); val != "xyz" {
+		t.Errorf("Expected environment map to be unmodifiable, got: %q", val)
+	}
+}
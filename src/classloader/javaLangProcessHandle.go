@@ -0,0 +1,295 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ProcessHandle: the current process, its parent and children, and any other process
+// on the system, identified by pid. The OS queries are in processes_linux.go (which
+// reads /proc) and processes_other.go (which runs ps). There's one ProcessHandle
+// object per pid, so handles can be compared by reference.
+//
+// ProcessHandle.Info's startInstant() and totalCpuDuration() return empty Optionals
+// for now, as Instant and Duration objects can't yet be created by natives.
+
+type processHandle struct {
+	pid int
+}
+
+// procInfo is what ProcessHandle.Info reports
+type procInfo struct {
+	command string
+	args    []string
+	hasArgs bool // the arguments are known (even if there are none)
+	user    string
+}
+
+var handleRefs = make(map[int]int64)
+var handlesMutex sync.Mutex
+
+// childProcesses are the running processes started by ProcessBuilder and Runtime.exec(),
+// keyed by pid. Their exits are known without polling.
+var childProcesses = make(map[int]*javaProcess)
+
+// how often onExit() checks whether a process that isn't a child is still alive
+var exitPollInterval = 100 * time.Millisecond
+
+func registerChild(p *javaProcess) {
+	handlesMutex.Lock()
+	childProcesses[p.cmd.Process.Pid] = p
+	handlesMutex.Unlock()
+}
+
+func childExited(p *javaProcess) {
+	handlesMutex.Lock()
+	delete(childProcesses, p.cmd.Process.Pid)
+	handlesMutex.Unlock()
+}
+
+// processHandleObject returns the ProcessHandle object for the pid
+func processHandleObject(pid int) int64 {
+	handlesMutex.Lock()
+	defer handlesMutex.Unlock()
+	ref, present := handleRefs[pid]
+	if !present {
+		ref = NewObject("java/lang/ProcessHandleImpl", 0)
+		GetObject(ref).Native = &processHandle{pid: pid}
+		handleRefs[pid] = ref
+	}
+	return ref
+}
+
+func handleOf(ref int64) *processHandle {
+	if obj := GetObject(ref); obj != nil {
+		if h, ok := obj.Native.(*processHandle); ok {
+			return h
+		}
+	}
+	return &processHandle{pid: -1}
+}
+
+// currentProcessInfo returns the information about this process, which Go knows
+// without asking the OS
+func currentProcessInfo() procInfo {
+	info := procInfo{args: os.Args[1:], hasArgs: true}
+	if exe, err := os.Executable(); err == nil {
+		info.command = exe
+	}
+	if u, err := user.Current(); err == nil {
+		info.user = u.Username
+	}
+	return info
+}
+
+// descendants returns the pids of the process's children or, if all is true, all its
+// descendants, in order of pid
+func descendants(pid int, all bool) []int {
+	children := make(map[int][]int)
+	for child, parent := range processParents() {
+		if child != parent {
+			children[parent] = append(children[parent], child)
+		}
+	}
+	var found []int
+	queue := []int{pid}
+	for len(queue) > 0 {
+		next := children[queue[0]]
+		queue = queue[1:]
+		found = append(found, next...)
+		if all {
+			queue = append(queue, next...)
+		}
+	}
+	sort.Ints(found)
+	return found
+}
+
+func handleStream(pids []int) int64 {
+	refs := make([]int64, len(pids))
+	for i, pid := range pids {
+		refs[i] = processHandleObject(pid)
+	}
+	return NewStreamObject(refs)
+}
+
+// onExit returns a CompletableFuture that's completed with value when the process exits
+func onExit(pid int, value int64) int64 {
+	ref, future := NewFutureObject()
+	handlesMutex.Lock()
+	child, isChild := childProcesses[pid]
+	handlesMutex.Unlock()
+	go func() {
+		if isChild {
+			<-child.done
+		} else {
+			for pidAlive(pid) {
+				time.Sleep(exitPollInterval)
+			}
+		}
+		future.complete(value)
+	}()
+	return ref
+}
+
+// destroyPid sends the process SIGTERM or, if forcibly is true, SIGKILL. As in the JDK,
+// the current process can't be destroyed this way.
+func destroyPid(pid int, forcibly bool) (bool, error) {
+	if pid == os.Getpid() {
+		return false, errors.New("java.lang.IllegalStateException: destroy of current process not allowed")
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false, nil
+	}
+	if forcibly {
+		return p.Kill() == nil, nil
+	}
+	if p.Signal(syscall.SIGTERM) != nil {
+		return p.Kill() == nil, nil
+	}
+	return true, nil
+}
+
+func optionalString(s string) int64 {
+	if s == "" {
+		return NewOptional(0)
+	}
+	return NewOptional(NewStringObject(s))
+}
+
+func newInfoObject(info procInfo) int64 {
+	ref := NewObject("java/lang/ProcessHandleImpl$Info", 0)
+	GetObject(ref).Native = &info
+	return ref
+}
+
+func infoOf(ref int64) *procInfo {
+	if obj := GetObject(ref); obj != nil {
+		if info, ok := obj.Native.(*procInfo); ok {
+			return info
+		}
+	}
+	return &procInfo{}
+}
+
+func Load_Lang_ProcessHandle() map[string]GMeth {
+	ph := "java/lang/ProcessHandle"
+	addNative(ph+".current()L"+ph+";", true, func() int64 {
+		return processHandleObject(os.Getpid())
+	})
+	addNative(ph+".of(J)Ljava/util/Optional;", true, func(pid int64) int64 {
+		if pid > 0 && pidAlive(int(pid)) {
+			return NewOptional(processHandleObject(int(pid)))
+		}
+		return NewOptional(0)
+	})
+	addNative(ph+".allProcesses()Ljava/util/stream/Stream;", true, func() int64 {
+		var pids []int
+		for pid := range processParents() {
+			pids = append(pids, pid)
+		}
+		sort.Ints(pids)
+		return handleStream(pids)
+	})
+	addNative(ph+".pid()J", false, func(this int64) int64 {
+		return int64(handleOf(this).pid)
+	})
+	addNative(ph+".isAlive()Z", false, func(this int64) bool {
+		return pidAlive(handleOf(this).pid)
+	})
+	addNative(ph+".parent()Ljava/util/Optional;", false, func(this int64) int64 {
+		if ppid, ok := parentPid(handleOf(this).pid); ok {
+			return NewOptional(processHandleObject(ppid))
+		}
+		return NewOptional(0)
+	})
+	addNative(ph+".children()Ljava/util/stream/Stream;", false, func(this int64) int64 {
+		return handleStream(descendants(handleOf(this).pid, false))
+	})
+	addNative(ph+".descendants()Ljava/util/stream/Stream;", false, func(this int64) int64 {
+		return handleStream(descendants(handleOf(this).pid, true))
+	})
+	addNative(ph+".info()L"+ph+"$Info;", false, func(this int64) int64 {
+		pid := handleOf(this).pid
+		if pid == os.Getpid() {
+			return newInfoObject(currentProcessInfo())
+		}
+		return newInfoObject(processInfo(pid))
+	})
+	addNative(ph+".onExit()Ljava/util/concurrent/CompletableFuture;", false, func(this int64) int64 {
+		return onExit(handleOf(this).pid, this)
+	})
+	addNative(ph+".supportsNormalTermination()Z", false, func(this int64) bool {
+		return os.PathSeparator == '/' // SIGTERM isn't available on Windows
+	})
+	addNative(ph+".destroy()Z", false, func(this int64) (bool, error) {
+		return destroyPid(handleOf(this).pid, false)
+	})
+	addNative(ph+".destroyForcibly()Z", false, func(this int64) (bool, error) {
+		return destroyPid(handleOf(this).pid, true)
+	})
+	addNative(ph+".toString()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(strconv.Itoa(handleOf(this).pid))
+	})
+
+	info := ph + "$Info"
+	addNative(info+".command()Ljava/util/Optional;", false, func(this int64) int64 {
+		return optionalString(infoOf(this).command)
+	})
+	addNative(info+".user()Ljava/util/Optional;", false, func(this int64) int64 {
+		return optionalString(infoOf(this).user)
+	})
+	addNative(info+".arguments()Ljava/util/Optional;", false, func(this int64) int64 {
+		i := infoOf(this)
+		if !i.hasArgs {
+			return NewOptional(0)
+		}
+		refs := make([]int64, len(i.args))
+		for j, arg := range i.args {
+			refs[j] = NewStringObject(arg)
+		}
+		return NewOptional(NewRefArray("java/lang/String", refs))
+	})
+	addNative(info+".commandLine()Ljava/util/Optional;", false, func(this int64) int64 {
+		i := infoOf(this)
+		if i.command == "" || !i.hasArgs {
+			return NewOptional(0)
+		}
+		return optionalString(strings.Join(append([]string{i.command}, i.args...), " "))
+	})
+	for _, method := range []string{"startInstant", "totalCpuDuration"} {
+		addNative(info+"."+method+"()Ljava/util/Optional;", false, func(this int64) int64 {
+			return NewOptional(0) // TODO: return an Instant/Duration once natives can create them
+		})
+	}
+
+	// the Process methods that deal with handles
+	proc := "java/lang/Process"
+	addNative(proc+".toHandle()L"+ph+";", false, func(this int64) int64 {
+		return processHandleObject(processOf(this).cmd.Process.Pid)
+	})
+	addNative(proc+".onExit()Ljava/util/concurrent/CompletableFuture;", false, func(this int64) int64 {
+		return onExit(processOf(this).cmd.Process.Pid, this)
+	})
+	addNative(proc+".children()Ljava/util/stream/Stream;", false, func(this int64) int64 {
+		return handleStream(descendants(processOf(this).cmd.Process.Pid, false))
+	})
+	addNative(proc+".descendants()Ljava/util/stream/Stream;", false, func(this int64) int64 {
+		return handleStream(descendants(processOf(this).cmd.Process.Pid, true))
+	})
+	return MethodSignatures
+}
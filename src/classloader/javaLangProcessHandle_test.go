@@ -0,0 +1,97 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCurrentProcessHandle(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process queries aren't supported on Windows")
+	}
+	Load_Lang_ProcessHandle()
+	Load_Util_Optional()
+	ph := "java/lang/ProcessHandle"
+
+	current := callNative(t, ph+".current()Ljava/lang/ProcessHandle;").(int64)
+	if current != callNative(t, ph+".current()Ljava/lang/ProcessHandle;") {
+		t.Error("Expected one ProcessHandle per process")
+	}
+	if pid := callNative(t, ph+".pid()J", current); pid != int64(os.Getpid()) {
+		t.Errorf("Expected pid %d, got %v", os.Getpid(), pid)
+	}
+	if callNative(t, ph+".isAlive()Z", current) != int64(1) {
+		t.Error("Expected the current process to be alive")
+	}
+
+	parent := optionalValue(callNative(t, ph+".parent()Ljava/util/Optional;", current).(int64))
+	if pid := callNative(t, ph+".pid()J", parent); pid != int64(os.Getppid()) {
+		t.Errorf("Expected parent pid %d, got %v", os.Getppid(), pid)
+	}
+
+	info := callNative(t, ph+".info()Ljava/lang/ProcessHandle$Info;", current).(int64)
+	command := optionalValue(callNative(t, ph+"$Info.command()Ljava/util/Optional;", info).(int64))
+	if exe, _ := os.Executable(); javaString(command) != exe {
+		t.Errorf("Expected command %s, got %s", exe, javaString(command))
+	}
+	ret := callNative(t, ph+".destroy()Z", current)
+	if err, _ := ret.(error); err == nil ||
+		err.Error() != "java.lang.IllegalStateException: destroy of current process not allowed" {
+		t.Errorf("Expected the current process not to be destroyable, got %v", ret)
+	}
+}
+
+func TestChildProcessHandle(t *testing.T) {
+	pb := newProcessBuilder(t, "read line")
+	Load_Lang_ProcessHandle()
+	Load_Util_Optional()
+	Load_Util_Stream()
+	Load_Util_CompletableFuture()
+	ph := "java/lang/ProcessHandle"
+
+	p := callNative(t, "java/lang/ProcessBuilder.start()Ljava/lang/Process;", pb).(int64)
+	child := callNative(t, "java/lang/Process.toHandle()Ljava/lang/ProcessHandle;", p).(int64)
+	pid := callNative(t, ph+".pid()J", child).(int64)
+
+	current := callNative(t, ph+".current()Ljava/lang/ProcessHandle;").(int64)
+	children := callNative(t, ph+".children()Ljava/util/stream/Stream;", current).(int64)
+	elems, _ := streamElems(children)
+	found := false
+	for _, ref := range elems {
+		found = found || ref == child
+	}
+	if !found {
+		t.Errorf("Expected children() to include the child %d", pid)
+	}
+
+	info := callNative(t, ph+".info()Ljava/lang/ProcessHandle$Info;", child).(int64)
+	if javaString(optionalValue(callNative(t, ph+"$Info.command()Ljava/util/Optional;", info).(int64))) == "" {
+		t.Error("Expected the child's command to be known")
+	}
+
+	future := callNative(t, ph+".onExit()Ljava/util/concurrent/CompletableFuture;", child).(int64)
+	if futureOf(future).isDone() {
+		t.Error("Expected onExit() not to complete while the child is running")
+	}
+	in := callNative(t, "java/lang/Process.getOutputStream()Ljava/io/OutputStream;", p).(int64)
+	callNative(t, "java/io/OutputStream.close()V", in)
+	select {
+	case <-futureOf(future).done:
+		if futureOf(future).value != child {
+			t.Error("Expected onExit() to complete with the handle")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected onExit() to complete when the child exits")
+	}
+	if callNative(t, ph+".isAlive()Z", child) != int64(0) {
+		t.Error("Expected the child not to be alive after it exits")
+	}
+}
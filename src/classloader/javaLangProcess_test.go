@@ -0,0 +1,153 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// newProcessBuilder creates a ProcessBuilder for a shell command
+func newProcessBuilder(t *testing.T, script string) int64 {
+	if runtime.GOOS == "windows" {
+		t.Skip("process tests use sh")
+	}
+	Load_Lang_Process()
+	pb := NewObject("java/lang/ProcessBuilder", 0)
+	cmd := NewRefArray("java/lang/String", []int64{
+		NewStringObject("sh"), NewStringObject("-c"), NewStringObject(script)})
+	callNative(t, "java/lang/ProcessBuilder.<init>([Ljava/lang/String;)V", pb, cmd)
+	return pb
+}
+
+// readAll reads the stream to the end, via the InputStream natives
+func readAll(t *testing.T, stream int64) string {
+	Load_Io_Streams()
+	var out []byte
+	for {
+		b := callNative(t, "java/io/InputStream.read()I", stream).(int64)
+		if b == -1 {
+			return string(out)
+		}
+		out = append(out, byte(b))
+	}
+}
+
+func TestProcessPipesAndExitCode(t *testing.T) {
+	pb := newProcessBuilder(t, "read line; echo \"got $line\"; echo oops >&2; exit 3")
+	p := callNative(t, "java/lang/ProcessBuilder.start()Ljava/lang/Process;", pb).(int64)
+	if p == 0 {
+		t.Fatalf("Expected process to start")
+	}
+
+	in := callNative(t, "java/lang/Process.getOutputStream()Ljava/io/OutputStream;", p).(int64)
+	callNative(t, "java/io/OutputStream.write([B)V", in, NewByteArray([]byte("hi\n")))
+	callNative(t, "java/io/OutputStream.close()V", in)
+
+	out := callNative(t, "java/lang/Process.getInputStream()Ljava/io/InputStream;", p).(int64)
+	if s := readAll(t, out); s != "got hi\n" {
+		t.Errorf("Expected \"got hi\\n\" from the child, got: %q", s)
+	}
+	errStream := callNative(t, "java/lang/Process.getErrorStream()Ljava/io/InputStream;", p).(int64)
+	if s := readAll(t, errStream); s != "oops\n" {
+		t.Errorf("Expected \"oops\\n\" on the child's stderr, got: %q", s)
+	}
+	if code := callNative(t, "java/lang/Process.waitFor()I", p).(int64); code != 3 {
+		t.Errorf("Expected exit code 3, got: %d", code)
+	}
+	if callNative(t, "java/lang/Process.isAlive()Z", p).(int64) != 0 {
+		t.Errorf("Expected process not to be alive after waitFor()")
+	}
+}
+
+func TestProcessEnvironmentDirectoryAndRedirect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jacobin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pb := newProcessBuilder(t, "echo \"$JACOBIN_CHILD\" $(basename \"$PWD\")")
+	env := callNative(t, "java/lang/ProcessBuilder.environment()Ljava/util/Map;", pb).(int64)
+	callNative(t, envMapClass+".put(Ljava/lang/Object;Ljava/lang/Object;)Ljava/lang/Object;",
+		env, NewStringObject("JACOBIN_CHILD"), NewStringObject("set"))
+	callNative(t, "java/lang/ProcessBuilder.directory(Ljava/io/File;)Ljava/lang/ProcessBuilder;",
+		pb, NewFileObject(dir))
+	outFile := filepath.Join(dir, "out.txt")
+	callNative(t, "java/lang/ProcessBuilder.redirectOutput(Ljava/io/File;)Ljava/lang/ProcessBuilder;",
+		pb, NewFileObject(outFile))
+
+	p := callNative(t, "java/lang/ProcessBuilder.start()Ljava/lang/Process;", pb).(int64)
+	callNative(t, "java/lang/Process.waitFor()I", p)
+	out := callNative(t, "java/lang/Process.getInputStream()Ljava/io/InputStream;", p).(int64)
+	if s := readAll(t, out); s != "" {
+		t.Errorf("Expected redirected output not to be piped, got: %q", s)
+	}
+	contents, _ := ioutil.ReadFile(outFile)
+	if string(contents) != "set "+filepath.Base(dir)+"\n" {
+		t.Errorf("Expected child's environment and directory in output, got: %q", contents)
+	}
+	if _, present := processEnvironment().lookup("JACOBIN_CHILD"); present {
+		t.Errorf("Expected the child's environment not to change the VM's")
+	}
+}
+
+func TestProcessDestroy(t *testing.T) {
+	pb := newProcessBuilder(t, "sleep 30")
+	p := callNative(t, "java/lang/ProcessBuilder.start()Ljava/lang/Process;", pb).(int64)
+	callNative(t, "java/lang/Process.destroy()V", p)
+	if code := callNative(t, "java/lang/Process.waitFor()I", p).(int64); code != 128+15 {
+		t.Errorf("Expected exit code of 143 (SIGTERM), got: %d", code)
+	}
+}
+
+func TestRuntimeExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process tests use sh")
+	}
+	Load_Lang_Process()
+	rt := callNative(t, "java/lang/Runtime.getRuntime()Ljava/lang/Runtime;").(int64)
+	if callNative(t, "java/lang/Runtime.getRuntime()Ljava/lang/Runtime;").(int64) != rt {
+		t.Errorf("Expected getRuntime() to return the same object each time")
+	}
+	p := callNative(t, "java/lang/Runtime.exec(Ljava/lang/String;)Ljava/lang/Process;",
+		rt, NewStringObject("echo  a   b")).(int64)
+	out := callNative(t, "java/lang/Process.getInputStream()Ljava/io/InputStream;", p).(int64)
+	if s := readAll(t, out); s != "a b\n" {
+		t.Errorf("Expected command string split at whitespace, got: %q", s)
+	}
+	callNative(t, "java/lang/Process.waitFor()I", p)
+}
+
+func TestProcessExceptions(t *testing.T) {
+	pb := newProcessBuilder(t, "sleep 30")
+	p := callNative(t, "java/lang/ProcessBuilder.start()Ljava/lang/Process;", pb).(int64)
+	err, _ := callNative(t, "java/lang/Process.exitValue()I", p).(error)
+	if err == nil || err.Error() != "java.lang.IllegalThreadStateException: process hasn't exited" {
+		t.Errorf("Expected exitValue() of a running process to throw IllegalThreadStateException, got %v", err)
+	}
+	callNative(t, "java/lang/Process.destroyForcibly()Ljava/lang/Process;", p)
+	callNative(t, "java/lang/Process.waitFor()I", p)
+
+	callNative(t, "java/lang/ProcessBuilder.command([Ljava/lang/String;)Ljava/lang/ProcessBuilder;", pb,
+		NewRefArray("java/lang/String", []int64{NewStringObject("no-such-jacobin-command")}))
+	err, _ = callNative(t, "java/lang/ProcessBuilder.start()Ljava/lang/Process;", pb).(error)
+	if err == nil || err.Error() !=
+		`java.io.IOException: Cannot run program "no-such-jacobin-command": error=2, No such file or directory` {
+		t.Errorf("Expected start() of a missing command to throw IOException, got %v", err)
+	}
+
+	callNative(t, "java/lang/ProcessBuilder.command([Ljava/lang/String;)Ljava/lang/ProcessBuilder;", pb,
+		NewRefArray("java/lang/String", nil))
+	err, _ = callNative(t, "java/lang/ProcessBuilder.start()Ljava/lang/Process;", pb).(error)
+	if err == nil || err.Error() != "java.lang.IndexOutOfBoundsException: Index 0 out of bounds for length 0" {
+		t.Errorf("Expected start() of an empty command to throw IndexOutOfBoundsException, got %v", err)
+	}
+}
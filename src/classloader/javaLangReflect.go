@@ -0,0 +1,438 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Core reflection: Field and Method objects for the fields and methods declared in a
+// loaded class. These are implemented in Go: a Field or Method object's Native field
+// holds what the class file says about the member. Class.getDeclaredFields(), etc., are
+// implemented directly, rather than via the JDK's reflection caches, which depend on
+// much of the class library.
+//
+// Method.invoke() runs the method via InvokeMethod, which the interpreter sets. Boxing
+// isn't supported yet, so only methods whose parameters and return value are references
+// (or which return void) can be invoked. invoke() spreads its array of arguments over
+// the method's parameters; the array a variable arity method takes is one of them, as
+// the caller of invoke() packs it, not invoke().
+
+// InvokeMethod runs the method to completion on behalf of a native and returns its
+// return value (0 for void methods). For instance methods, args begins with the object.
+// The interpreter sets this when execution begins.
+var InvokeMethod = func(className, methodName, methodType string, args []int64) (int64, error) {
+	return 0, errors.New("no interpreter to run " + className + "." + methodName + methodType)
+}
+
+// the modifiers that Field.getModifiers() and Method.getModifiers() report
+const (
+	fieldModifiers  = 0x00DF // public, private, protected, static, final, volatile, transient
+	methodModifiers = 0x0D3F // public, private, protected, static, final, synchronized, native, abstract, strict
+)
+
+// ACC_VARARGS is the access flag of a variable arity method (JVMS 4.6), which
+// Method.isVarArgs() reports and the method handles for the method respect
+const ACC_VARARGS = 0x0080
+
+type reflectField struct {
+	class     string // the declaring class
+	name      string
+	desc      string
+	modifiers int
+	slot      int // the index of the field in Object.Fields (its "offset")
+}
+
+type reflectMethod struct {
+	class     string // the declaring class
+	name      string
+	desc      string
+	modifiers int
+	varargs   bool // the method has variable arity (ACC_VARARGS), which isn't a modifier
+}
+
+// classData returns the parsed class file of the named class, loading the class if
+// it hasn't been loaded yet. It returns nil if the class can't be loaded.
+func classData(className string) *ClData {
+	if strings.HasPrefix(className, "[") || primitiveDescriptors[className] != 0 {
+		return nil
+	}
+	MethAreaMutex.RLock()
+	_, present := Classes[className]
+	MethAreaMutex.RUnlock()
+	if !present && LoadClassFromNameOnly(className) != nil {
+		return nil
+	}
+	for {
+		MethAreaMutex.RLock()
+		k := Classes[className]
+		MethAreaMutex.RUnlock()
+		if k.Status != 'I' { // the class isn't still being loaded
+			return k.Data
+		}
+		time.Sleep(15 * time.Millisecond)
+	}
+}
+
+// primitiveDescriptors maps the names of the primitive types to their descriptors
+var primitiveDescriptors = map[string]byte{
+	"boolean": 'Z', "byte": 'B', "char": 'C', "short": 'S', "int": 'I',
+	"long": 'J', "float": 'F', "double": 'D', "void": 'V',
+}
+
+// descriptorClass returns the Class object for a field descriptor, such as I,
+// Ljava/lang/String; or [I
+func descriptorClass(desc string) int64 {
+	switch {
+	case strings.HasPrefix(desc, "L"):
+		return ClassObject(strings.TrimSuffix(desc[1:], ";"))
+	case strings.HasPrefix(desc, "["):
+		return ClassObject(desc) // array classes are named by their descriptors
+	}
+	for name, d := range primitiveDescriptors {
+		if len(desc) == 1 && desc[0] == d {
+			return ClassObject(name)
+		}
+	}
+	return 0
+}
+
+// classDescriptor returns the field descriptor for the class the Class object refers to
+func classDescriptor(classRef int64) string {
+	name, _ := ClassNameOf(classRef)
+	if d := primitiveDescriptors[name]; d != 0 {
+		return string(d)
+	}
+	if strings.HasPrefix(name, "[") {
+		return name
+	}
+	return "L" + name + ";"
+}
+
+// descriptorTypes splits a method descriptor into the descriptors of its parameters
+// and of its return value
+func descriptorTypes(desc string) ([]string, string) {
+	var params []string
+	i := 1
+	for i < len(desc) && desc[i] != ')' {
+		start := i
+		for desc[i] == '[' {
+			i++
+		}
+		if desc[i] == 'L' {
+			i += strings.IndexByte(desc[i:], ';')
+		}
+		i++
+		params = append(params, desc[start:i])
+	}
+	return params, desc[i+1:]
+}
+
+// declaredFields returns the fields declared in the class, in the order of the class file.
+// Their slots follow those of the fields the class inherits (see ObjectLayout()).
+func declaredFields(className string) []reflectField {
+	data := classData(className)
+	if data == nil {
+		return nil
+	}
+	base := InstanceSize(data.Superclass)
+	fields := make([]reflectField, len(data.Fields))
+	for i, f := range data.Fields {
+		fields[i] = reflectField{class: className, name: data.CP.Utf8Refs[f.Name],
+			desc: data.CP.Utf8Refs[f.Desc], modifiers: f.AccessFlags & fieldModifiers, slot: base + i}
+	}
+	return fields
+}
+
+// declaredMethods returns the methods declared in the class, not including the
+// constructors and static initializer
+func declaredMethods(className string) []reflectMethod {
+	data := classData(className)
+	if data == nil {
+		return nil
+	}
+	var methods []reflectMethod
+	for _, m := range data.Methods {
+		name := data.CP.Utf8Refs[m.Name]
+		if name == "<init>" || name == "<clinit>" {
+			continue
+		}
+		methods = append(methods, reflectMethod{class: className, name: name,
+			desc: data.CP.Utf8Refs[m.Desc], modifiers: m.AccessFlags & methodModifiers,
+			varargs: m.AccessFlags&ACC_VARARGS != 0})
+	}
+	return methods
+}
+
+// hasMethod reports whether the class itself declares the method (including
+// constructors and static initializers)
+func hasMethod(className, name, desc string) bool {
+	data := classData(className)
+	return data != nil && data.MethodFor(SymbolFor(name, desc)) != nil
+}
+
+// superclassOf returns the name of the class's superclass, or "" for java.lang.Object
+// and for classes that can't be loaded
+func superclassOf(className string) string {
+	if data := classData(className); data != nil {
+		return data.Superclass
+	}
+	return ""
+}
+
+// classModifiers returns the modifiers that Class.getModifiers() reports
+func classModifiers(access AccessFlags) int {
+	modifiers := 0
+	for _, flag := range []struct {
+		set bool
+		bit int
+	}{{access.ClassIsPublic, 0x0001}, {access.ClassIsFinal, 0x0010},
+		{access.ClassIsInterface, 0x0200}, {access.ClassIsAbstract, 0x0400}} {
+		if flag.set {
+			modifiers |= flag.bit
+		}
+	}
+	return modifiers
+}
+
+func newFieldObject(f reflectField) int64 {
+	ref := NewObject("java/lang/reflect/Field", 0)
+	GetObject(ref).Native = &f
+	return ref
+}
+
+func newMethodObject(m reflectMethod) int64 {
+	ref := NewObject("java/lang/reflect/Method", 0)
+	GetObject(ref).Native = &m
+	return ref
+}
+
+func fieldOf(ref int64) *reflectField {
+	if obj := GetObject(ref); obj != nil {
+		if f, ok := obj.Native.(*reflectField); ok {
+			return f
+		}
+	}
+	return &reflectField{slot: -1}
+}
+
+func methodOf(ref int64) *reflectMethod {
+	if obj := GetObject(ref); obj != nil {
+		if m, ok := obj.Native.(*reflectMethod); ok {
+			return m
+		}
+	}
+	return &reflectMethod{}
+}
+
+// invocationTargetError is the InvocationTargetException that Method.invoke() throws
+// when the method throws. The method's exception is its cause (see errors.Unwrap()).
+type invocationTargetError struct {
+	target error
+}
+
+func (e *invocationTargetError) Error() string {
+	return "java.lang.reflect.InvocationTargetException"
+}
+
+func (e *invocationTargetError) Unwrap() error {
+	return e.target
+}
+
+// invokeReflectively implements Method.invoke(). An instance method is looked up
+// starting with the class of the object, so overriding methods are invoked. A null
+// array of arguments is taken to be empty, as for a method without parameters. An
+// exception the method throws is wrapped in an InvocationTargetException.
+func invokeReflectively(m *reflectMethod, obj int64, argsArray int64) (int64, error) {
+	params, ret := descriptorTypes(m.desc)
+	for _, p := range params {
+		if len(p) == 1 {
+			return 0, nil // TODO: unbox primitive arguments once boxing is supported
+		}
+	}
+	args, _ := RefArrayFromRef(argsArray)
+	if len(args) != len(params) {
+		return 0, fmt.Errorf("java.lang.IllegalArgumentException: wrong number of arguments: %d expected: %d",
+			len(args), len(params))
+	}
+
+	class := m.class
+	if m.modifiers&ACC_STATIC == 0 {
+		if GetObject(obj) == nil {
+			return 0, errors.New("java.lang.NullPointerException")
+		}
+		if m.modifiers&0x0002 == 0 { // a private method isn't overridden
+			class = overridingClass(obj, m.class, m.name, m.desc)
+		}
+		args = append([]int64{obj}, args...)
+	}
+	value, err := InvokeMethod(class, m.name, m.desc, args)
+	if err != nil {
+		return 0, &invocationTargetError{target: err}
+	}
+	if len(ret) == 1 { // TODO: box primitive return values
+		return 0, nil
+	}
+	return value, nil
+}
+
+// overridingClass returns the class whose method is invoked when the method declared
+// by the class is invoked on the object: the nearest class, starting with the object's
+// own, that declares a method with the same name and descriptor
+func overridingClass(obj int64, class, name, desc string) string {
+	if o := GetObject(obj); o != nil {
+		for c := o.Klass; c != ""; c = superclassOf(c) {
+			if hasMethod(c, name, desc) {
+				return c
+			}
+		}
+	}
+	return class
+}
+
+func Load_Lang_Reflect() map[string]GMeth {
+	fieldArray := func(classRef int64, publicOnly bool) int64 {
+		name, _ := ClassNameOf(classRef)
+		var refs []int64
+		for _, f := range declaredFields(name) {
+			if !publicOnly || f.modifiers&0x0001 != 0 {
+				refs = append(refs, newFieldObject(f))
+			}
+		}
+		return NewRefArray("java/lang/reflect/Field", refs)
+	}
+	methodArray := func(classRef int64, publicOnly bool) int64 {
+		name, _ := ClassNameOf(classRef)
+		var refs []int64
+		for _, m := range declaredMethods(name) {
+			if !publicOnly || m.modifiers&0x0001 != 0 {
+				refs = append(refs, newMethodObject(m))
+			}
+		}
+		return NewRefArray("java/lang/reflect/Method", refs)
+	}
+
+	class := "java/lang/Class"
+	addNative(class+".getDeclaredFields0(Z)[Ljava/lang/reflect/Field;", false, func(this int64, publicOnly bool) int64 {
+		return fieldArray(this, publicOnly)
+	})
+	addNative(class+".getDeclaredFields()[Ljava/lang/reflect/Field;", false, func(this int64) int64 {
+		return fieldArray(this, false)
+	})
+	addNative(class+".getDeclaredMethods0(Z)[Ljava/lang/reflect/Method;", false, func(this int64, publicOnly bool) int64 {
+		return methodArray(this, publicOnly)
+	})
+	addNative(class+".getDeclaredMethods()[Ljava/lang/reflect/Method;", false, func(this int64) int64 {
+		return methodArray(this, false)
+	})
+	addNative(class+".getDeclaredMethod(Ljava/lang/String;[Ljava/lang/Class;)Ljava/lang/reflect/Method;", false,
+		func(this, name, paramTypes int64) (int64, error) {
+			if name == 0 {
+				return 0, errNPE
+			}
+			className, _ := ClassNameOf(this)
+			types, _ := RefArrayFromRef(paramTypes)
+			prefix := "("
+			var typeNames []string
+			for _, t := range types {
+				prefix += classDescriptor(t)
+				typeName, _ := ClassNameOf(t)
+				typeNames = append(typeNames, strings.ReplaceAll(typeName, "/", "."))
+			}
+			prefix += ")"
+			for _, m := range declaredMethods(className) {
+				if m.name == javaString(name) && strings.HasPrefix(m.desc, prefix) {
+					return newMethodObject(m), nil
+				}
+			}
+			// as in the JDK, the message names the method as Java source would
+			return 0, fmt.Errorf("java.lang.NoSuchMethodException: %s.%s(%s)",
+				strings.ReplaceAll(className, "/", "."), javaString(name), strings.Join(typeNames, ", "))
+		})
+	addNative(class+".getSuperclass()Ljava/lang/Class;", false, func(this int64) int64 {
+		name, _ := ClassNameOf(this)
+		if super := superclassOf(name); super != "" {
+			return ClassObject(super)
+		}
+		return 0
+	})
+	addNative(class+".getModifiers()I", false, func(this int64) int32 {
+		name, _ := ClassNameOf(this)
+		if data := classData(name); data != nil {
+			return int32(classModifiers(data.Access))
+		}
+		return 0x0411 // public, final, abstract: the modifiers of primitive and array classes
+	})
+
+	field := "java/lang/reflect/Field"
+	addNative(field+".getName()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(fieldOf(this).name)
+	})
+	addNative(field+".getType()Ljava/lang/Class;", false, func(this int64) int64 {
+		return descriptorClass(fieldOf(this).desc)
+	})
+	addNative(field+".getModifiers()I", false, func(this int64) int32 {
+		return int32(fieldOf(this).modifiers)
+	})
+	addNative(field+".getDeclaringClass()Ljava/lang/Class;", false, func(this int64) int64 {
+		return ClassObject(fieldOf(this).class)
+	})
+	addNative(field+".toString()Ljava/lang/String;", false, func(this int64) int64 {
+		f := fieldOf(this)
+		return NewStringObject(strings.ReplaceAll(f.class+"."+f.name, "/", "."))
+	})
+
+	method := "java/lang/reflect/Method"
+	addNative(method+".getName()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(methodOf(this).name)
+	})
+	addNative(method+".getReturnType()Ljava/lang/Class;", false, func(this int64) int64 {
+		_, ret := descriptorTypes(methodOf(this).desc)
+		return descriptorClass(ret)
+	})
+	addNative(method+".getParameterTypes()[Ljava/lang/Class;", false, func(this int64) int64 {
+		params, _ := descriptorTypes(methodOf(this).desc)
+		refs := make([]int64, len(params))
+		for i, p := range params {
+			refs[i] = descriptorClass(p)
+		}
+		return NewRefArray("java/lang/Class", refs)
+	})
+	addNative(method+".getParameterCount()I", false, func(this int64) int32 {
+		params, _ := descriptorTypes(methodOf(this).desc)
+		return int32(len(params))
+	})
+	addNative(method+".getModifiers()I", false, func(this int64) int32 {
+		return int32(methodOf(this).modifiers)
+	})
+	addNative(method+".getDeclaringClass()Ljava/lang/Class;", false, func(this int64) int64 {
+		return ClassObject(methodOf(this).class)
+	})
+	addNative(method+".isVarArgs()Z", false, func(this int64) bool {
+		return methodOf(this).varargs
+	})
+
+	// invoke() throws, so it's registered as a raw GMeth, which can return an error
+	MethodSignatures[method+".invoke(Ljava/lang/Object;[Ljava/lang/Object;)Ljava/lang/Object;"] = GMeth{
+		ParamSlots: 3,
+		GFunction: func(params []interface{}) interface{} {
+			value, err := invokeReflectively(methodOf(params[0].(int64)), params[1].(int64), params[2].(int64))
+			if err != nil {
+				return err
+			}
+			return value
+		},
+	}
+
+	// access checks aren't enforced, so setAccessible() has nothing to do
+	addNative("java/lang/reflect/AccessibleObject.setAccessible(Z)V", false, func(this int64, flag bool) {})
+
+	return MethodSignatures
+}
@@ -0,0 +1,49 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+// Proxy objects: objects whose methods are all handled by one Go function, as
+// java.lang.reflect.Proxy instances are handled by their InvocationHandler. The object's
+// class is the interface it implements, which has no Go natives registered for it, so
+// when the interpreter can't find a method in the MTable, it asks ProxyMethod() for an
+// entry that passes the call to the object's handler. Annotation instances are proxies
+// (see javaLangAnnotation.go).
+
+// proxyHandler is the Go-side state of a proxy object. The arguments are the
+// operand-stack slots, and the result is returned as a slot (or nil for a void method).
+type proxyHandler interface {
+	invoke(methodName, methodType string, args []int64) interface{}
+}
+
+// ProxyMethod returns an MTable entry that calls the named method on the proxy object.
+// The entry isn't added to the MTable, since another object of the same class may not
+// be a proxy. If the object isn't a proxy, the second return value is false.
+func ProxyMethod(ref int64, methodName, methodType string) (MTentry, bool) {
+	obj := GetObject(ref)
+	if obj == nil {
+		return MTentry{}, false
+	}
+	handler, ok := obj.Native.(proxyHandler)
+	if !ok {
+		return MTentry{}, false
+	}
+	params, _, err := ParseDescriptor(methodType)
+	if err != nil {
+		return MTentry{}, false
+	}
+	gme := GmEntry{
+		ParamSlots: len(params) + 1, // the proxy itself, then the arguments
+		Fu: func(slots []interface{}) interface{} {
+			args := make([]int64, len(params))
+			for i := range params {
+				args[i] = slots[i+1].(int64)
+			}
+			return handler.invoke(methodName, methodType, args)
+		},
+	}
+	return MTentry{Meth: gme, MType: 'G'}, true
+}
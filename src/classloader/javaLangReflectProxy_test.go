@@ -0,0 +1,51 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+type recordingHandler struct {
+	calls []string
+}
+
+func (h *recordingHandler) invoke(methodName, methodType string, args []int64) interface{} {
+	h.calls = append(h.calls, methodName+methodType)
+	if len(args) > 0 {
+		return args[0] + 1
+	}
+	return nil
+}
+
+func TestProxyMethodPassesArgumentsToHandler(t *testing.T) {
+	h := &recordingHandler{}
+	ref := NewObject("test/Iface", 0)
+	GetObject(ref).Native = h
+
+	mt, ok := ProxyMethod(ref, "next", "(J)J")
+	if !ok || mt.MType != 'G' {
+		t.Fatal("Expected a G entry for the proxy")
+	}
+	gme := mt.Meth.(GmEntry)
+	if gme.ParamSlots != 2 {
+		t.Errorf("Expected 2 param slots, got %d", gme.ParamSlots)
+	}
+	if ret := gme.Fu([]interface{}{ref, int64(41)}); ret != int64(42) {
+		t.Errorf("Expected 42, got %v", ret)
+	}
+	if len(h.calls) != 1 || h.calls[0] != "next(J)J" {
+		t.Errorf("Unexpected calls: %v", h.calls)
+	}
+}
+
+func TestProxyMethodRejectsOrdinaryObjects(t *testing.T) {
+	if _, ok := ProxyMethod(NewObject("test/Plain", 0), "run", "()V"); ok {
+		t.Error("Expected an ordinary object not to be a proxy")
+	}
+	if _, ok := ProxyMethod(0, "run", "()V"); ok {
+		t.Error("Expected null not to be a proxy")
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testMember is a field or method of a class created by addTestClass()
+type testMember struct {
+	flags      int
+	name, desc string
+}
+
+// addTestClass puts a class with the given members in the method area, as though
+// it had been loaded
+func addTestClass(name, super string, interfaces []string, fields, methods []testMember) {
+	data := ClData{Name: name, Superclass: super, CP: CPool{Utf8Refs: []string{""}}}
+	utf8 := func(s string) uint16 {
+		data.CP.Utf8Refs = append(data.CP.Utf8Refs, s)
+		return uint16(len(data.CP.Utf8Refs) - 1)
+	}
+	for _, i := range interfaces {
+		data.Interfaces = append(data.Interfaces, utf8(i))
+	}
+	for _, f := range fields {
+		data.Fields = append(data.Fields, Field{AccessFlags: f.flags, Name: utf8(f.name), Desc: utf8(f.desc)})
+	}
+	for _, m := range methods {
+		data.Methods = append(data.Methods, Method{AccessFlags: m.flags, Name: utf8(m.name), Desc: utf8(m.desc)})
+	}
+	_ = insert(name, Klass{Status: 'L', Loader: "test", Data: &data})
+}
+
+func TestReflectFieldsAndMethods(t *testing.T) {
+	Load_Lang_Reflect()
+	Load_Misc_Unsafe()
+	addTestClass("test/Point", "", nil,
+		[]testMember{{0x0002, "x", "I"}, {0x0001, "label", "Ljava/lang/String;"}},
+		[]testMember{{0x0001, "<init>", "()V"}, {0x0001, "describe", "(Ljava/lang/String;)Ljava/lang/String;"}})
+	class := ClassObject("test/Point")
+
+	fields, _ := RefArrayFromRef(callNative(t, "java/lang/Class.getDeclaredFields()[Ljava/lang/reflect/Field;", class).(int64))
+	if len(fields) != 2 {
+		t.Fatalf("Expected 2 fields, got %d", len(fields))
+	}
+	if name := callNative(t, "java/lang/reflect/Field.getName()Ljava/lang/String;", fields[1]).(int64); javaString(name) != "label" {
+		t.Errorf("Expected the second field to be label, got %s", javaString(name))
+	}
+	if callNative(t, "java/lang/reflect/Field.getType()Ljava/lang/Class;", fields[0]) != ClassObject("int") {
+		t.Error("Expected the type of x to be int")
+	}
+	if callNative(t, "java/lang/reflect/Field.getModifiers()I", fields[0]) != int64(0x0002) {
+		t.Error("Expected x to be private")
+	}
+	offset := callNative(t, "jdk/internal/misc/Unsafe.objectFieldOffset1(Ljava/lang/Class;Ljava/lang/String;)J",
+		int64(0), class, NewStringObject("label"))
+	if offset != int64(1) || callNative(t, "jdk/internal/misc/Unsafe.objectFieldOffset(Ljava/lang/reflect/Field;)J",
+		int64(0), fields[1]) != offset {
+		t.Errorf("Expected the offset of label to be 1, got %v", offset)
+	}
+
+	publicOnly, _ := RefArrayFromRef(callNative(t, "java/lang/Class.getDeclaredFields0(Z)[Ljava/lang/reflect/Field;", class, int64(1)).(int64))
+	if len(publicOnly) != 1 {
+		t.Errorf("Expected 1 public field, got %d", len(publicOnly))
+	}
+
+	methods, _ := RefArrayFromRef(callNative(t, "java/lang/Class.getDeclaredMethods()[Ljava/lang/reflect/Method;", class).(int64))
+	if len(methods) != 1 {
+		t.Fatalf("Expected 1 method (constructors aren't methods), got %d", len(methods))
+	}
+	params := NewRefArray("java/lang/Class", []int64{ClassObject("java/lang/String")})
+	m := callNative(t, "java/lang/Class.getDeclaredMethod(Ljava/lang/String;[Ljava/lang/Class;)Ljava/lang/reflect/Method;",
+		class, NewStringObject("describe"), params).(int64)
+	if m == 0 {
+		t.Fatal("Expected getDeclaredMethod() to find describe(String)")
+	}
+	if callNative(t, "java/lang/reflect/Method.getReturnType()Ljava/lang/Class;", m) != ClassObject("java/lang/String") {
+		t.Error("Expected describe() to return a String")
+	}
+	params = NewRefArray("java/lang/Class", []int64{ClassObject("java/lang/String"), ClassObject("java/lang/Object")})
+	missing := callNative(t, "java/lang/Class.getDeclaredMethod(Ljava/lang/String;[Ljava/lang/Class;)Ljava/lang/reflect/Method;",
+		class, NewStringObject("describe"), params)
+	if err, _ := missing.(error); err == nil ||
+		err.Error() != "java.lang.NoSuchMethodException: test.Point.describe(java.lang.String, java.lang.Object)" {
+		t.Errorf("Expected NoSuchMethodException for a method that isn't declared, got %v", missing)
+	}
+}
+
+func TestMethodInvoke(t *testing.T) {
+	Load_Lang_Reflect()
+	addTestClass("test/Greeter", "", nil, nil,
+		[]testMember{{0x0001, "greet", "(Ljava/lang/String;)Ljava/lang/String;"}})
+	addTestClass("test/LoudGreeter", "test/Greeter", nil, nil,
+		[]testMember{{0x0001, "greet", "(Ljava/lang/String;)Ljava/lang/String;"}})
+
+	var invoked string
+	var invokedArgs []int64
+	InvokeMethod = func(className, methodName, methodType string, args []int64) (int64, error) {
+		invoked, invokedArgs = className+"."+methodName+methodType, args
+		return NewStringObject("HI"), nil
+	}
+	defer func() {
+		InvokeMethod = func(string, string, string, []int64) (int64, error) { return 0, nil }
+	}()
+
+	// the method is Greeter's, but the object's class overrides it
+	m := newMethodObject(declaredMethods("test/Greeter")[0])
+	obj := NewObject("test/LoudGreeter", 0)
+	arg := NewStringObject("hi")
+	ret := callNative(t, "java/lang/reflect/Method.invoke(Ljava/lang/Object;[Ljava/lang/Object;)Ljava/lang/Object;",
+		m, obj, NewRefArray("java/lang/Object", []int64{arg})).(int64)
+	if !strings.HasPrefix(invoked, "test/LoudGreeter.greet") {
+		t.Errorf("Expected the overriding method to be invoked, got %s", invoked)
+	}
+	if len(invokedArgs) != 2 || invokedArgs[0] != obj || invokedArgs[1] != arg {
+		t.Errorf("Expected the object and argument to be passed, got %v", invokedArgs)
+	}
+	if javaString(ret) != "HI" {
+		t.Errorf("Expected invoke() to return the method's return value, got %s", javaString(ret))
+	}
+	// an exception the method throws is the cause of an InvocationTargetException
+	thrown := errors.New("java.lang.IllegalStateException: too loud")
+	InvokeMethod = func(string, string, string, []int64) (int64, error) { return 0, thrown }
+	err, _ := callNative(t, "java/lang/reflect/Method.invoke(Ljava/lang/Object;[Ljava/lang/Object;)Ljava/lang/Object;",
+		m, obj, NewRefArray("java/lang/Object", []int64{arg})).(error)
+	if err == nil || err.Error() != "java.lang.reflect.InvocationTargetException" || errors.Unwrap(err) != thrown {
+		t.Errorf("Expected an InvocationTargetException caused by the method's exception, got %v", err)
+	}
+}
+
+func TestObjectLayoutInheritsFields(t *testing.T) {
+	addTestClass("test/Base", "", nil, []testMember{{0x0002, "id", "J"}, {0x0008, "count", "I"}}, nil)
+	addTestClass("test/Derived", "test/Base", nil, []testMember{{0x0042, "name", "Ljava/lang/String;"}}, nil)
+
+	layout, slots, ok := ObjectLayout("test/Derived")
+	if !ok || slots != 3 || len(layout) != 3 {
+		t.Fatalf("Expected 3 slots, got %d (%v)", slots, layout)
+	}
+	for i, want := range []string{"id", "count", "name"} {
+		if layout[i].Name != want || layout[i].Slot != i {
+			t.Errorf("Expected %s in slot %d, got %s in slot %d", want, i, layout[i].Name, layout[i].Slot)
+		}
+	}
+	if InstanceSize("test/Derived") != 3 || InstanceSize("test/Base") != 2 {
+		t.Errorf("Expected instance sizes 3 and 2, got %d and %d",
+			InstanceSize("test/Derived"), InstanceSize("test/Base"))
+	}
+
+	if slot, _, ok := FieldSlot("test/Derived", "id"); !ok || slot != 0 {
+		t.Errorf("Expected the inherited field id in slot 0, got %d (found: %v)", slot, ok)
+	}
+	if slot, volatile, ok := FieldSlot("test/Derived", "name"); !ok || slot != 2 || !volatile {
+		t.Errorf("Expected the volatile field name in slot 2, got %d (volatile: %v, found: %v)", slot, volatile, ok)
+	}
+	if _, _, ok := FieldSlot("test/Base", "name"); ok {
+		t.Error("Expected the subclass's field not to be found in the superclass")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// System.exit(), Runtime.exit(), Runtime.halt(), and the registry of shutdown hooks.
+// Running the hooks requires the interpreter, so the natives call VMExit and VMHalt,
+// which the interpreter sets when execution begins. (The defaults simply exit.)
+
+// VMExit runs the shutdown hooks and then terminates the VM with the given status
+var VMExit = func(status int) { os.Exit(status) }
+
+// VMHalt terminates the VM with the given status without running the shutdown hooks
+var VMHalt = func(status int) { os.Exit(status) }
+
+var shutdownHooks []int64 // the Thread objects, in order of registration
+var shutdownStarted bool
+var hooksMutex sync.Mutex
+
+var errShutdownInProgress = errors.New("java.lang.IllegalStateException: Shutdown in progress")
+
+// AddShutdownHook registers the Thread as a shutdown hook. As in the JDK, a hook can't
+// be registered twice, nor after the shutdown sequence has begun.
+func AddShutdownHook(thread int64) error {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	if thread == 0 {
+		return errNPE
+	}
+	if shutdownStarted {
+		return errShutdownInProgress
+	}
+	for _, hook := range shutdownHooks {
+		if hook == thread {
+			return errors.New("java.lang.IllegalArgumentException: Hook previously registered")
+		}
+	}
+	shutdownHooks = append(shutdownHooks, thread)
+	return nil
+}
+
+// RemoveShutdownHook deregisters the hook and reports whether it was registered
+func RemoveShutdownHook(thread int64) (bool, error) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	if thread == 0 {
+		return false, errNPE
+	}
+	if shutdownStarted {
+		return false, errShutdownInProgress
+	}
+	for i, hook := range shutdownHooks {
+		if hook == thread {
+			shutdownHooks = append(shutdownHooks[:i], shutdownHooks[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// BeginShutdown marks the start of the shutdown sequence, after which hooks can no
+// longer be added or removed, and returns the hooks to run.
+func BeginShutdown() []int64 {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	shutdownStarted = true
+	return append([]int64(nil), shutdownHooks...)
+}
+
+func Load_Lang_Shutdown() map[string]GMeth {
+	addNative("java/lang/System.exit(I)V", true, func(status int32) {
+		VMExit(int(status))
+	})
+	addNative("java/lang/Runtime.exit(I)V", false, func(this int64, status int32) {
+		VMExit(int(status))
+	})
+	addNative("java/lang/Runtime.halt(I)V", false, func(this int64, status int32) {
+		VMHalt(int(status))
+	})
+	addNative("java/lang/Runtime.addShutdownHook(Ljava/lang/Thread;)V", false, func(this, thread int64) error {
+		return AddShutdownHook(thread)
+	})
+	addNative("java/lang/Runtime.removeShutdownHook(Ljava/lang/Thread;)Z", false, func(this, thread int64) (bool, error) {
+		return RemoveShutdownHook(thread)
+	})
+	return MethodSignatures
+}
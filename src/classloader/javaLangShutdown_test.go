@@ -0,0 +1,48 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+func TestShutdownHookRegistry(t *testing.T) {
+	Load_Lang_Shutdown()
+	hook1 := NewObject("test/Hook", 0)
+	hook2 := NewObject("test/Hook", 0)
+	if err := AddShutdownHook(hook1); err != nil {
+		t.Fatalf("Expected hook to be registered, got: %v", err)
+	}
+	callNative(t, "java/lang/Runtime.addShutdownHook(Ljava/lang/Thread;)V", int64(0), hook2)
+
+	if callNative(t, "java/lang/Runtime.removeShutdownHook(Ljava/lang/Thread;)Z", int64(0), hook1).(int64) != 1 {
+		t.Errorf("Expected registered hook to be removed")
+	}
+	if removed, err := RemoveShutdownHook(hook1); removed || err != nil {
+		t.Errorf("Expected removal of an unregistered hook to fail, got: %v, %v", removed, err)
+	}
+	if err, _ := callNative(t, "java/lang/Runtime.addShutdownHook(Ljava/lang/Thread;)V", int64(0), hook2).(error); err == nil ||
+		err.Error() != "java.lang.IllegalArgumentException: Hook previously registered" {
+		t.Errorf("Expected a second registration to throw IllegalArgumentException, got: %v", err)
+	}
+	if err, _ := callNative(t, "java/lang/Runtime.addShutdownHook(Ljava/lang/Thread;)V", int64(0), int64(0)).(error); err != errNPE {
+		t.Errorf("Expected a null hook to throw NullPointerException, got: %v", err)
+	}
+
+	var exitStatus int
+	VMExit = func(status int) { exitStatus = status }
+	callNative(t, "java/lang/System.exit(I)V", int64(7))
+	if exitStatus != 7 {
+		t.Errorf("Expected System.exit(7) to call VMExit with 7, got: %d", exitStatus)
+	}
+
+	hooks := BeginShutdown()
+	if len(hooks) != 1 || hooks[0] != hook2 {
+		t.Errorf("Expected only the remaining hook to be run, got: %v", hooks)
+	}
+	if _, err := RemoveShutdownHook(hook2); err != errShutdownInProgress {
+		t.Errorf("Expected hooks not to be removable once shutdown has begun, got: %v", err)
+	}
+}
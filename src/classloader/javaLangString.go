@@ -0,0 +1,203 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"unicode/utf16"
+)
+
+// java.lang.String objects are implemented in Go: the value of the string is held
+// as a Go string in the object's Native field. String constants (loaded by ldc) are
+// interned, so that every occurrence of the same literal is the same object, as the
+// JLS requires.
+
+var internedStrings = make(map[string]int64)
+var internMutex sync.Mutex
+
+// NewStringObject creates a new java.lang.String object with the given value and
+// returns the reference to it.
+func NewStringObject(s string) int64 {
+	ref := NewObject("java/lang/String", 0)
+	GetObject(ref).Native = s
+	return ref
+}
+
+// InternString returns the reference to the canonical String object for the value,
+// creating it if need be. This is the behavior of String.intern().
+func InternString(s string) int64 {
+	internMutex.Lock()
+	defer internMutex.Unlock()
+	ref, present := internedStrings[s]
+	if !present {
+		ref = NewStringObject(s)
+		internedStrings[s] = ref
+	}
+	return ref
+}
+
+// GoStringFromRef returns the value of the referenced String object. If the reference
+// is not to a String, the second return value is false.
+func GoStringFromRef(ref int64) (string, bool) {
+	obj := GetObject(ref)
+	if obj == nil {
+		return "", false
+	}
+	s, ok := obj.Native.(string)
+	return s, ok
+}
+
+// StringHashCode returns the hash code that String.hashCode() specifies, which is
+// computed from the string's UTF-16 chars: s[0]*31^(n-1) + s[1]*31^(n-2) + ... + s[n-1].
+// A switch on a String is compiled into a switch on this value, so it must be exact.
+func StringHashCode(s string) int32 {
+	var h int32
+	for _, c := range utf16.Encode([]rune(s)) {
+		h = 31*h + int32(c)
+	}
+	return h
+}
+
+func Load_Lang_String() map[string]GMeth {
+	addNative("java/lang/String.length()I", false, func(this int64) int32 {
+		return int32(len(utf16.Encode([]rune(javaString(this)))))
+	})
+	addNative("java/lang/String.charAt(I)C", false, func(this int64, index int32) (uint16, error) {
+		chars := utf16.Encode([]rune(javaString(this)))
+		if index < 0 || int(index) >= len(chars) {
+			return 0, fmt.Errorf("java.lang.StringIndexOutOfBoundsException: Index %d out of bounds for length %d",
+				index, len(chars))
+		}
+		return chars[index], nil
+	})
+	addNative("java/lang/String.concat(Ljava/lang/String;)Ljava/lang/String;", false,
+		func(this, other int64) int64 {
+			return NewStringObject(javaString(this) + javaString(other))
+		})
+	addNative("java/lang/String.equals(Ljava/lang/Object;)Z", false, func(this, other int64) bool {
+		s, ok := GoStringFromRef(other)
+		return ok && s == javaString(this)
+	})
+	addNative("java/lang/String.hashCode()I", false, func(this int64) int32 {
+		return StringHashCode(javaString(this))
+	})
+	addNative("java/lang/String.toString()Ljava/lang/String;", false, func(this int64) int64 {
+		return this
+	})
+	addNative("java/lang/String.intern()Ljava/lang/String;", false, func(this int64) int64 {
+		return InternString(javaString(this))
+	})
+	addNative("java/lang/String.valueOf(I)Ljava/lang/String;", true, func(i int32) int64 {
+		return NewStringObject(strconv.Itoa(int(i)))
+	})
+	addNative("java/lang/String.valueOf(J)Ljava/lang/String;", true, func(l int64) int64 {
+		return NewStringObject(strconv.FormatInt(l, 10))
+	})
+	addNative("java/lang/String.valueOf(C)Ljava/lang/String;", true, func(c uint16) int64 {
+		return NewStringObject(string(utf16.Decode([]uint16{c})))
+	})
+	addNative("java/lang/String.valueOf(Z)Ljava/lang/String;", true, func(z bool) int64 {
+		return NewStringObject(strconv.FormatBool(z))
+	})
+	addNative("java/lang/String.valueOf(D)Ljava/lang/String;", true, func(d float64) int64 {
+		return NewStringObject(formatDouble(d))
+	})
+	addNative("java/lang/String.valueOf(Ljava/lang/Object;)Ljava/lang/String;", true, func(o int64) int64 {
+		return NewStringObject(ObjectToString(o))
+	})
+
+	// conversions between Strings and bytes (see javaNioCharset.go). A charset given by
+	// name that isn't supported throws UnsupportedEncodingException, as in the JDK.
+	stringInit := func(this int64, cs *charset, array int64, offset, length int32) error {
+		b, ok := ByteArrayFromRef(array)
+		obj := GetObject(this)
+		switch {
+		case obj == nil || cs == nil || !ok:
+			return errNPE
+		case offset < 0 || length < 0 || int(offset)+int(length) > len(b):
+			return fmt.Errorf("java.lang.StringIndexOutOfBoundsException: offset %d, count %d, length %d",
+				offset, length, len(b))
+		}
+		obj.Native = cs.decode(b[offset : offset+length])
+		return nil
+	}
+	byteCount := func(array int64) int32 {
+		b, _ := ByteArrayFromRef(array)
+		return int32(len(b))
+	}
+	addNative("java/lang/String.<init>()V", false, func(this int64) {
+		GetObject(this).Native = ""
+	})
+	addNative("java/lang/String.<init>(Ljava/lang/String;)V", false, func(this, s int64) {
+		GetObject(this).Native = javaString(s)
+	})
+	addNative("java/lang/String.<init>([B)V", false, func(this, array int64) error {
+		return stringInit(this, defaultCharset(), array, 0, byteCount(array))
+	})
+	addNative("java/lang/String.<init>([BII)V", false, func(this, array int64, offset, length int32) error {
+		return stringInit(this, defaultCharset(), array, offset, length)
+	})
+	addNative("java/lang/String.<init>([BLjava/lang/String;)V", false, func(this, array, name int64) error {
+		cs, err := namedCharset(name)
+		if err != nil {
+			return err
+		}
+		return stringInit(this, cs, array, 0, byteCount(array))
+	})
+	addNative("java/lang/String.<init>([BIILjava/lang/String;)V", false,
+		func(this, array int64, offset, length int32, name int64) error {
+			cs, err := namedCharset(name)
+			if err != nil {
+				return err
+			}
+			return stringInit(this, cs, array, offset, length)
+		})
+	addNative("java/lang/String.<init>([BLjava/nio/charset/Charset;)V", false, func(this, array, cs int64) error {
+		return stringInit(this, charsetOf(cs), array, 0, byteCount(array))
+	})
+	addNative("java/lang/String.<init>([BIILjava/nio/charset/Charset;)V", false,
+		func(this, array int64, offset, length int32, cs int64) error {
+			return stringInit(this, charsetOf(cs), array, offset, length)
+		})
+	addNative("java/lang/String.getBytes()[B", false, func(this int64) int64 {
+		return NewByteArray(defaultCharset().encode(javaString(this)))
+	})
+	addNative("java/lang/String.getBytes(Ljava/lang/String;)[B", false, func(this, name int64) (int64, error) {
+		cs, err := namedCharset(name)
+		if err != nil {
+			return 0, err
+		}
+		return NewByteArray(cs.encode(javaString(this))), nil
+	})
+	addNative("java/lang/String.getBytes(Ljava/nio/charset/Charset;)[B", false, func(this, cs int64) (int64, error) {
+		c := charsetOf(cs)
+		if c == nil {
+			return 0, errNPE
+		}
+		return NewByteArray(c.encode(javaString(this))), nil
+	})
+	return MethodSignatures
+}
+
+// errNPE is the NullPointerException of a null argument
+var errNPE = errors.New("java.lang.NullPointerException")
+
+// namedCharset returns the charset with the referenced name, or the exception of a
+// null or unsupported name
+func namedCharset(name int64) (*charset, error) {
+	s, ok := GoStringFromRef(name)
+	if !ok {
+		return nil, errNPE
+	}
+	if cs := lookupCharset(s); cs != nil {
+		return cs, nil
+	}
+	return nil, errors.New("java.io.UnsupportedEncodingException: " + s)
+}
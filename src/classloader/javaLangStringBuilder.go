@@ -0,0 +1,224 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"unicode/utf16"
+)
+
+/*
+ Intrinsics for java.lang.StringBuilder and java.lang.StringBuffer. String concatenation
+ in classes compiled before Java 9, and most formatting code, is built on these classes,
+ so rather than run the JDK's implementation (which depends on byte-array natives,
+ Arrays.copyOf, and String's internal coders), the whole class is implemented in Go.
+ The characters are held as UTF-16 code units, as in Java, so that length() and
+ charAt() behave exactly as they do in the JDK.
+
+ StringBuffer is the synchronized variant of StringBuilder; its methods take the
+ mutex in the builder state. The methods are registered for both classes.
+*/
+
+// the Go-side state of a StringBuilder or StringBuffer (held in Object.Native)
+type stringBuilder struct {
+	mutex sync.Mutex
+	chars []uint16
+}
+
+// GoClasses are the classes whose objects are implemented entirely in Go. The new
+// bytecode creates objects of these classes without loading their class files.
+var GoClasses = map[string]bool{
+	"java/lang/String":           true,
+	"java/lang/StringBuilder":    true,
+	"java/lang/StringBuffer":     true,
+	"java/util/Properties":       true,
+	"java/io/File":               true,
+	"java/io/FileInputStream":    true,
+	"java/io/FileOutputStream":   true,
+	"java/io/RandomAccessFile":   true,
+	"java/nio/HeapByteBuffer":    true,
+	"java/nio/DirectByteBuffer":  true,
+	"java/io/PrintStream":        true,
+	"java/net/Socket":            true,
+	"java/net/ServerSocket":      true,
+	"java/net/InetSocketAddress": true,
+	"java/net/URL":               true,
+	"java/lang/ProcessBuilder":   true,
+	"java/lang/Runtime":          true,
+	"java/util/Random":           true,
+	"java/util/SplittableRandom": true,
+	"java/security/SecureRandom": true,
+	"java/util/UUID":             true,
+	"sun/misc/Signal":            true,
+	"jdk/internal/misc/Signal":   true,
+
+	"java/util/concurrent/CompletableFuture": true,
+}
+
+func Load_Lang_StringBuilder() map[string]GMeth {
+	for _, class := range []string{"java/lang/StringBuilder", "java/lang/StringBuffer"} {
+		desc := "L" + class + ";"
+		addNative(class+".<init>()V", false, func(this int64) { sbInit(this, "") })
+		addNative(class+".<init>(I)V", false, func(this int64, capacity int32) { sbInit(this, "") })
+		addNative(class+".<init>(Ljava/lang/String;)V", false, func(this, s int64) {
+			sbInit(this, javaString(s))
+		})
+		addNative(class+".append(Ljava/lang/String;)"+desc, false, func(this, s int64) int64 {
+			return sbAppend(this, javaString(s))
+		})
+		addNative(class+".append(Ljava/lang/Object;)"+desc, false, func(this, o int64) int64 {
+			return sbAppend(this, ObjectToString(o))
+		})
+		addNative(class+".append(Ljava/lang/CharSequence;)"+desc, false, func(this, o int64) int64 {
+			return sbAppend(this, ObjectToString(o))
+		})
+		addNative(class+".append(I)"+desc, false, func(this int64, i int32) int64 {
+			return sbAppend(this, strconv.Itoa(int(i)))
+		})
+		addNative(class+".append(J)"+desc, false, func(this int64, l int64) int64 {
+			return sbAppend(this, strconv.FormatInt(l, 10))
+		})
+		addNative(class+".append(C)"+desc, false, func(this int64, c uint16) int64 {
+			return sbAppendChars(this, []uint16{c})
+		})
+		addNative(class+".append(Z)"+desc, false, func(this int64, z bool) int64 {
+			return sbAppend(this, strconv.FormatBool(z))
+		})
+		addNative(class+".append(D)"+desc, false, func(this int64, d float64) int64 {
+			return sbAppend(this, formatDouble(d))
+		})
+		addNative(class+".toString()Ljava/lang/String;", false, func(this int64) int64 {
+			return NewStringObject(sbString(this))
+		})
+		addNative(class+".length()I", false, func(this int64) int32 {
+			sb := getBuilder(this)
+			sb.mutex.Lock()
+			defer sb.mutex.Unlock()
+			return int32(len(sb.chars))
+		})
+		addNative(class+".charAt(I)C", false, func(this int64, index int32) (uint16, error) {
+			sb := getBuilder(this)
+			sb.mutex.Lock()
+			defer sb.mutex.Unlock()
+			if index < 0 || int(index) >= len(sb.chars) {
+				return 0, fmt.Errorf("java.lang.StringIndexOutOfBoundsException: index %d,length %d",
+					index, len(sb.chars))
+			}
+			return sb.chars[index], nil
+		})
+		addNative(class+".setLength(I)V", false, func(this int64, newLength int32) error {
+			if newLength < 0 {
+				return fmt.Errorf("java.lang.StringIndexOutOfBoundsException: String index out of range: %d",
+					newLength)
+			}
+			sb := getBuilder(this)
+			sb.mutex.Lock()
+			defer sb.mutex.Unlock()
+			for len(sb.chars) < int(newLength) {
+				sb.chars = append(sb.chars, 0)
+			}
+			sb.chars = sb.chars[:newLength]
+			return nil
+		})
+		addNative(class+".reverse()"+desc, false, func(this int64) int64 {
+			// reversal is by code point, so that surrogate pairs stay in order
+			s := []rune(sbString(this))
+			for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+				s[i], s[j] = s[j], s[i]
+			}
+			sb := getBuilder(this)
+			sb.mutex.Lock()
+			sb.chars = utf16.Encode(s)
+			sb.mutex.Unlock()
+			return this
+		})
+	}
+	return MethodSignatures
+}
+
+// getBuilder returns the builder state of the referenced StringBuilder/StringBuffer.
+// Objects created by new but not yet initialized get an empty builder.
+func getBuilder(ref int64) *stringBuilder {
+	obj := GetObject(ref)
+	if obj == nil {
+		return &stringBuilder{}
+	}
+	sb, ok := obj.Native.(*stringBuilder)
+	if !ok {
+		sb = &stringBuilder{}
+		obj.Native = sb
+	}
+	return sb
+}
+
+func sbInit(this int64, s string) {
+	obj := GetObject(this)
+	if obj != nil {
+		obj.Native = &stringBuilder{chars: utf16.Encode([]rune(s))}
+	}
+}
+
+// sbAppend appends the string and returns the builder, as the append() methods do
+func sbAppend(this int64, s string) int64 {
+	return sbAppendChars(this, utf16.Encode([]rune(s)))
+}
+
+func sbAppendChars(this int64, chars []uint16) int64 {
+	sb := getBuilder(this)
+	sb.mutex.Lock()
+	sb.chars = append(sb.chars, chars...)
+	sb.mutex.Unlock()
+	return this
+}
+
+func sbString(this int64) string {
+	sb := getBuilder(this)
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return string(utf16.Decode(sb.chars))
+}
+
+// javaString returns the value of a String reference, with null as "null", which is
+// how append() and valueOf() treat a null String.
+func javaString(ref int64) string {
+	return stringArg(ref)
+}
+
+// ObjectToString returns the result of calling toString() on the referenced object,
+// for the classes implemented in Go. Other objects get the default Object.toString()
+// format: the class name, @, and the identity hash code in hex.
+func ObjectToString(ref int64) string {
+	if ref == 0 {
+		return "null"
+	}
+	obj := GetObject(ref)
+	if obj == nil {
+		return "null"
+	}
+	switch v := obj.Native.(type) {
+	case string:
+		return v
+	case *stringBuilder:
+		return sbString(ref)
+	case *javaFile:
+		return v.path
+	}
+	return javaClassName(obj.Klass) + "@" + strconv.FormatInt(ref, 16)
+}
+
+// javaClassName converts a class name from java/lang/Object format to java.lang.Object
+func javaClassName(internal string) string {
+	b := []byte(internal)
+	for i := range b {
+		if b[i] == '/' {
+			b[i] = '.'
+		}
+	}
+	return string(b)
+}
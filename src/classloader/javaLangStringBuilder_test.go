@@ -0,0 +1,98 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+// calls the registered native with the given slots
+func callNative(t *testing.T, fqn string, slots ...interface{}) interface{} {
+	gm, ok := LookupNative(fqn)
+	if !ok {
+		t.Fatalf("Native not registered: %s", fqn)
+	}
+	return gm.GFunction(slots)
+}
+
+func TestStringBuilderAppendAndToString(t *testing.T) {
+	Load_Lang_StringBuilder()
+	sb := NewObject("java/lang/StringBuilder", 0)
+	callNative(t, "java/lang/StringBuilder.<init>()V", sb)
+
+	callNative(t, "java/lang/StringBuilder.append(Ljava/lang/String;)Ljava/lang/StringBuilder;",
+		sb, NewStringObject("x = "))
+	callNative(t, "java/lang/StringBuilder.append(I)Ljava/lang/StringBuilder;", sb, int64(-7))
+	callNative(t, "java/lang/StringBuilder.append(C)Ljava/lang/StringBuilder;", sb, int64(','))
+	callNative(t, "java/lang/StringBuilder.append(Z)Ljava/lang/StringBuilder;", sb, int64(1))
+	ret := callNative(t, "java/lang/StringBuilder.append(Ljava/lang/String;)Ljava/lang/StringBuilder;",
+		sb, int64(0))
+	if ret.(int64) != sb {
+		t.Errorf("Expected append() to return the builder itself")
+	}
+
+	strRef := callNative(t, "java/lang/StringBuilder.toString()Ljava/lang/String;", sb).(int64)
+	if s, _ := GoStringFromRef(strRef); s != "x = -7,truenull" {
+		t.Errorf("Expected \"x = -7,truenull\", got: %q", s)
+	}
+	if callNative(t, "java/lang/StringBuilder.length()I", sb).(int64) != 15 {
+		t.Errorf("Expected length of 15")
+	}
+	if callNative(t, "java/lang/StringBuilder.charAt(I)C", sb, int64(4)).(int64) != '-' {
+		t.Errorf("Expected charAt(4) to be '-'")
+	}
+	err, _ := callNative(t, "java/lang/StringBuilder.charAt(I)C", sb, int64(15)).(error)
+	if err == nil || err.Error() != "java.lang.StringIndexOutOfBoundsException: index 15,length 15" {
+		t.Errorf("Expected StringIndexOutOfBoundsException from charAt(15), got %v", err)
+	}
+}
+
+func TestStringBufferReverseWithSurrogates(t *testing.T) {
+	Load_Lang_StringBuilder()
+	sb := NewObject("java/lang/StringBuffer", 0)
+	callNative(t, "java/lang/StringBuffer.<init>(Ljava/lang/String;)V", sb, NewStringObject("a\U0001F600b"))
+	if callNative(t, "java/lang/StringBuffer.length()I", sb).(int64) != 4 {
+		t.Errorf("Expected a supplementary character to count as two chars")
+	}
+	callNative(t, "java/lang/StringBuffer.reverse()Ljava/lang/StringBuffer;", sb)
+	if ObjectToString(sb) != "b\U0001F600a" {
+		t.Errorf("Expected reversed string with intact surrogate pair, got: %q", ObjectToString(sb))
+	}
+}
+
+func TestStringNatives(t *testing.T) {
+	Load_Lang_String()
+	s := NewStringObject("abc")
+	if callNative(t, "java/lang/String.length()I", s).(int64) != 3 {
+		t.Errorf("Expected String length of 3")
+	}
+	concat := callNative(t, "java/lang/String.concat(Ljava/lang/String;)Ljava/lang/String;",
+		s, NewStringObject("def")).(int64)
+	if ObjectToString(concat) != "abcdef" {
+		t.Errorf("Expected \"abcdef\", got: %q", ObjectToString(concat))
+	}
+	if callNative(t, "java/lang/String.equals(Ljava/lang/Object;)Z", s, NewStringObject("abc")).(int64) != 1 {
+		t.Errorf("Expected equal strings to be equal")
+	}
+	d := callNative(t, "java/lang/String.valueOf(D)Ljava/lang/String;", SlotFromFloat(1.0)).(int64)
+	if ObjectToString(d) != "1.0" {
+		t.Errorf("Expected String.valueOf(1.0) to be \"1.0\", got: %q", ObjectToString(d))
+	}
+	if InternString("abc") != InternString("abc") {
+		t.Errorf("Expected interned strings to be the same object")
+	}
+}
+
+// the hash codes are those of the JDK, including overflow and chars outside the BMP,
+// and the colliding "Aa" and "BB", which a switch on Strings tells apart with equals()
+func TestStringHashCode(t *testing.T) {
+	Load_Lang_String()
+	for s, expected := range map[string]int64{"": 0, "abc": 96354, "apple": 93029210,
+		"cherry": -1361513063, "Aa": 2112, "BB": 2112, "\U0001F600": 1772899} {
+		if h := callNative(t, "java/lang/String.hashCode()I", NewStringObject(s)); h != expected {
+			t.Errorf("Expected the hash code of %q to be %d, got %v", s, expected, h)
+		}
+	}
+}
@@ -7,6 +7,11 @@
 package classloader
 
 import (
+	"bufio"
+	"io"
+	"jacobin/globals"
+	"os"
+	"sync"
 	"time"
 )
 
@@ -28,28 +33,157 @@ import (
 
 func Load_Lang_System() map[string]GMeth {
 
-	// MethodSignatures["java/lang/System.currentTimeMillis()J"] = // get time in ms since Jan 1, 1970, returned as long
-	// 	GMeth{
-	// 		ParamSlots: 0,
-	// 		GFunction:  currentTimeMillis,
-	// 	}
+	MethodSignatures["java/lang/System.currentTimeMillis()J"] = // get time in ms since Jan 1, 1970, returned as long
+		GMeth{
+			ParamSlots: 0,
+			GFunction:  currentTimeMillis,
+		}
 	MethodSignatures["java/lang/System.nanoTime()J"] = // get nanoseconds time, returned as long
 		GMeth{
 			ParamSlots: 0,
 			GFunction:  nanoTime,
 		}
 
+	// the system properties are held in globals.SystemProperties, which is populated
+	// with the standard defaults at start-up. Absent properties are returned as null.
+	addNative("java/lang/System.getProperty(Ljava/lang/String;)Ljava/lang/String;", true,
+		func(key int64) int64 {
+			return propertyRef(globals.SystemProperties.Get(javaString(key)))
+		})
+	addNative("java/lang/System.getProperty(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/String;", true,
+		func(key, def int64) int64 {
+			if val, present := globals.SystemProperties.Get(javaString(key)); present {
+				return NewStringObject(val)
+			}
+			return def
+		})
+	addNative("java/lang/System.setProperty(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/String;", true,
+		func(key, val int64) int64 {
+			return propertyRef(globals.SystemProperties.Set(javaString(key), javaString(val)))
+		})
+	addNative("java/lang/System.clearProperty(Ljava/lang/String;)Ljava/lang/String;", true,
+		func(key int64) int64 {
+			return propertyRef(globals.SystemProperties.Clear(javaString(key)))
+		})
+	addNative("java/lang/System.getProperties()Ljava/util/Properties;", true, func() int64 {
+		return NewPropertiesObject(globals.SystemProperties)
+	})
+	addNative("java/lang/System.lineSeparator()Ljava/lang/String;", true, func() int64 {
+		return propertyRef(globals.SystemProperties.Get("line.separator"))
+	})
+
+	// System.arraycopy() is also an intrinsic (see intrinsics.go in package jvm), so this
+	// is called only with -XX:-UseIntrinsics or through reflection
+	addNative("java/lang/System.arraycopy(Ljava/lang/Object;ILjava/lang/Object;II)V", true,
+		func(src int64, srcPos int32, dest int64, destPos int32, length int32) error {
+			return ArrayCopy(src, int(srcPos), dest, int(destPos), int(length))
+		})
+
 	return MethodSignatures
 }
 
-// ** Need to uncomment when I upgrade to go 1.17
-// // Return time in milliseconds, measured since midnight of Jan 1, 1970
-// func currentTimeMillis([]interface{}) interface{} {
-// 	return int64(time.Now().UnixMilli())
-// }
+// Return time in milliseconds, measured since midnight of Jan 1, 1970
+// (time.UnixMilli() would do, but it requires go 1.17)
+func currentTimeMillis([]interface{}) interface{} {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
 
 // Return time in nanoseconds. Note that in golang this function has a lower (that is, less good)
 // resolution than Java: two successive calls often return the same value.
 func nanoTime([]interface{}) interface{} {
 	return int64(time.Now().UnixNano())
 }
+
+// System.in, System.out, and System.err are static fields that refer to stream objects
+// whose Native field is a stdStream, which holds the file descriptor of the stream (0, 1,
+// or 2). InitStdStreams adds them to the statics before execution begins, so getstatic
+// finds them already resolved. The Go streams are looked up when they're used (rather
+// than saved here) so that a redirection of os.Stdout or os.Stderr is always honored.
+type stdStream struct {
+	fd int
+}
+
+func InitStdStreams() {
+	for _, s := range []struct {
+		name, class string
+		fd          int
+	}{
+		{"in", "java/io/InputStream", 0},
+		{"out", "java/io/PrintStream", 1},
+		{"err", "java/io/PrintStream", 2},
+	} {
+		if _, present := FindStatic("java/lang/System." + s.name); present {
+			continue
+		}
+		ref := NewObject(s.class, 0)
+		GetObject(ref).Native = &stdStream{fd: s.fd}
+		AddStatic("java/lang/System."+s.name, Static{Class: 'L', Type: "L" + s.class + ";", ValueInt: ref})
+	}
+}
+
+// streamFor returns the output stream for the referenced PrintStream: the Go
+// stream for System.out or System.err, or the writer that a PrintStream wraps.
+// Anything else writes to stdout.
+func streamFor(printStream interface{}) io.Writer {
+	if w := writerFor(printStream.(int64)); w != nil {
+		return w
+	}
+	return stdOutput(1)
+}
+
+// the writers that System.out and System.err write to instead of the console, if set
+var stdOut, stdErr io.Writer
+
+// SetStdStreams directs System.out and System.err to the writers rather than to the
+// process's stdout and stderr, so that a program that embeds the VM can capture what
+// Java code prints. A nil writer leaves the stream on the console. It's called before
+// the VM starts.
+func SetStdStreams(out, err io.Writer) {
+	stdOut, stdErr = out, err
+}
+
+// stdOutput returns the writer for the standard output stream (1 or 2)
+func stdOutput(fd int) io.Writer {
+	if fd == 1 && stdOut != nil {
+		return stdOut
+	}
+	if fd == 2 && stdErr != nil {
+		return stdErr
+	}
+	return consoleOutput(fd)
+}
+
+// System.in is buffered, so that reading a byte at a time doesn't mean a system call
+// for each byte. The reader is created on first use.
+var stdin *bufio.Reader
+var stdinOnce sync.Once
+
+// the reader that System.in reads from instead of the console, if set
+var stdinSource io.Reader
+
+// SetStdin directs System.in to read from the reader rather than from the process's
+// stdin, as SetStdStreams() does for System.out and System.err. It's called before the
+// VM starts.
+func SetStdin(r io.Reader) {
+	stdinSource = r
+}
+
+func stdinReader() *bufio.Reader {
+	stdinOnce.Do(func() {
+		if stdinSource != nil {
+			stdin = bufio.NewReader(stdinSource)
+		} else {
+			stdin = bufio.NewReader(os.Stdin)
+		}
+	})
+	return stdin
+}
+
+// propertyRef converts a property lookup into a String reference, or null if
+// the property is not set.
+func propertyRef(val string, present bool) int64 {
+	if !present {
+		return 0
+	}
+	return NewStringObject(val)
+}
@@ -0,0 +1,123 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "sync"
+
+// The natives that act on the VM's threads call these, which the interpreter sets when
+// execution begins. A thread is passed as its Thread object.
+
+// CurrentThread returns the Thread object of the thread that calls it
+var CurrentThread = func() int64 { return 0 }
+
+// StartThread starts a thread that runs the Thread's run() method, as Thread.start() does
+var StartThread = func(thread int64) error { return nil }
+
+// JoinThread waits for the thread to end, or for millis milliseconds if millis isn't 0
+var JoinThread = func(thread, millis int64) error { return nil }
+
+// SetThreadDaemon marks the thread as a daemon thread or a user thread, which must be
+// done before it's started, and ThreadIsDaemon reports which it is
+var SetThreadDaemon = func(thread int64, on bool) error { return nil }
+var ThreadIsDaemon = func(thread int64) bool { return false }
+
+// InterruptThread sets the interrupt status of the thread, waking it if it's blocked in
+// join(), wait(), or park(); ThreadIsInterrupted reports the status; and
+// ClearInterrupt clears the status of the current thread, returning what it was
+var InterruptThread = func(thread int64) {}
+var ThreadIsInterrupted = func(thread int64) bool { return false }
+var ClearInterrupt = func() bool { return false }
+
+// SetThreadPriority sets the priority of the thread, which Thread.setPriority() has
+// checked is from 1 to 10, and ThreadPriority returns it
+var SetThreadPriority = func(thread int64, priority int32) error { return nil }
+var ThreadPriority = func(thread int64) int32 { return 5 }
+
+// YieldThread gives up the processor, as Thread.yield() does
+var YieldThread = func() {}
+
+// The handling of uncaught exceptions. When an exception ends a thread, the interpreter
+// calls uncaughtException() on the handler registered with
+// Thread.setDefaultUncaughtExceptionHandler(), passing it the Thread object of the
+// thread and the exception; if there is no handler, it prints the stack trace to
+// System.err. (Handlers can't yet be set for single threads.)
+
+var defaultHandler int64 // the default UncaughtExceptionHandler, or 0
+var handlerMutex sync.Mutex
+
+// DefaultUncaughtExceptionHandler returns the handler set by
+// Thread.setDefaultUncaughtExceptionHandler(), or 0 if there's none
+func DefaultUncaughtExceptionHandler() int64 {
+	handlerMutex.Lock()
+	defer handlerMutex.Unlock()
+	return defaultHandler
+}
+
+// NewThreadObject creates a Thread object that stands for the named thread
+func NewThreadObject(name string) int64 {
+	ref := NewObject("java/lang/Thread", 0)
+	GetObject(ref).Native = name
+	return ref
+}
+
+func Load_Lang_Thread() map[string]GMeth {
+	addNative("java/lang/Thread.setDefaultUncaughtExceptionHandler(Ljava/lang/Thread$UncaughtExceptionHandler;)V",
+		true, func(handler int64) {
+			handlerMutex.Lock()
+			defaultHandler = handler
+			handlerMutex.Unlock()
+		})
+	addNative("java/lang/Thread.getDefaultUncaughtExceptionHandler()Ljava/lang/Thread$UncaughtExceptionHandler;",
+		true, func() int64 {
+			return DefaultUncaughtExceptionHandler()
+		})
+	addNative("java/lang/Thread.getName()Ljava/lang/String;", false, func(this int64) int64 {
+		if obj := GetObject(this); obj != nil {
+			if name, ok := obj.Native.(string); ok {
+				return NewStringObject(name)
+			}
+		}
+		return 0 // the name of a Thread the VM didn't create isn't known
+	})
+	addNative("java/lang/Thread.currentThread()Ljava/lang/Thread;", true, func() int64 {
+		return CurrentThread()
+	})
+	addNative("java/lang/Thread.start0()V", false, func(this int64) error {
+		return StartThread(this)
+	})
+	addNative("java/lang/Thread.setDaemon(Z)V", false, func(this int64, on bool) error {
+		return SetThreadDaemon(this, on)
+	})
+	addNative("java/lang/Thread.isDaemon()Z", false, func(this int64) bool {
+		return ThreadIsDaemon(this)
+	})
+	addNative("java/lang/Thread.join()V", false, func(this int64) error {
+		return JoinThread(this, 0)
+	})
+	addNative("java/lang/Thread.join(J)V", false, func(this, millis int64) error {
+		return JoinThread(this, millis)
+	})
+	addNative("java/lang/Thread.interrupt0()V", false, func(this int64) {
+		InterruptThread(this)
+	})
+	addNative("java/lang/Thread.isInterrupted()Z", false, func(this int64) bool {
+		return ThreadIsInterrupted(this)
+	})
+	addNative("java/lang/Thread.interrupted()Z", true, func() bool {
+		return ClearInterrupt()
+	})
+	addNative("java/lang/Thread.setPriority0(I)V", false, func(this int64, priority int32) error {
+		return SetThreadPriority(this, priority)
+	})
+	addNative("java/lang/Thread.getPriority()I", false, func(this int64) int32 {
+		return ThreadPriority(this)
+	})
+	addNative("java/lang/Thread.yield()V", true, func() {
+		YieldThread()
+	})
+	return MethodSignatures
+}
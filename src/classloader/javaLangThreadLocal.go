@@ -0,0 +1,42 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+// ThreadLocal and InheritableThreadLocal. The values are kept by the interpreter, in
+// the maps of each thread (see jvm/threadLocal.go), so the natives call these, which
+// it sets when execution begins. A ThreadLocal is passed as its reference.
+
+// ThreadLocalGet returns the value of the thread local in the current thread, which
+// is its initialValue() if no value was set
+var ThreadLocalGet = func(local int64) (int64, error) { return 0, nil }
+
+// ThreadLocalSet sets the value of the thread local in the current thread
+var ThreadLocalSet = func(local, value int64) {}
+
+// ThreadLocalRemove removes the value of the thread local in the current thread, so
+// the next get() calls initialValue() again
+var ThreadLocalRemove = func(local int64) {}
+
+func Load_Lang_ThreadLocal() map[string]GMeth {
+	for _, class := range []string{"java/lang/ThreadLocal", "java/lang/InheritableThreadLocal"} {
+		// the value isn't kept in the ThreadLocal, so there's nothing to initialize
+		addNative(class+".<init>()V", false, func(this int64) {})
+	}
+	addNative("java/lang/ThreadLocal.initialValue()Ljava/lang/Object;", false, func(this int64) int64 {
+		return 0 // null, unless a subclass overrides it
+	})
+	addNative("java/lang/ThreadLocal.get()Ljava/lang/Object;", false, func(this int64) (int64, error) {
+		return ThreadLocalGet(this)
+	})
+	addNative("java/lang/ThreadLocal.set(Ljava/lang/Object;)V", false, func(this, value int64) {
+		ThreadLocalSet(this, value)
+	})
+	addNative("java/lang/ThreadLocal.remove()V", false, func(this int64) {
+		ThreadLocalRemove(this)
+	})
+	return MethodSignatures
+}
@@ -0,0 +1,28 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+func TestDefaultUncaughtExceptionHandler(t *testing.T) {
+	Load_Lang_Thread()
+	handler := NewObject("test/Handler", 0)
+	callNative(t, "java/lang/Thread.setDefaultUncaughtExceptionHandler(Ljava/lang/Thread$UncaughtExceptionHandler;)V", handler)
+	defer callNative(t, "java/lang/Thread.setDefaultUncaughtExceptionHandler(Ljava/lang/Thread$UncaughtExceptionHandler;)V", int64(0))
+
+	if DefaultUncaughtExceptionHandler() != handler {
+		t.Errorf("Expected the handler to be the default")
+	}
+	if callNative(t, "java/lang/Thread.getDefaultUncaughtExceptionHandler()Ljava/lang/Thread$UncaughtExceptionHandler;").(int64) != handler {
+		t.Errorf("Expected getDefaultUncaughtExceptionHandler() to return the handler")
+	}
+
+	name, _ := GoStringFromRef(callNative(t, "java/lang/Thread.getName()Ljava/lang/String;", NewThreadObject("worker")).(int64))
+	if name != "worker" {
+		t.Errorf("Expected the thread's name to be 'worker', got: %s", name)
+	}
+}
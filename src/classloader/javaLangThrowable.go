@@ -0,0 +1,304 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Throwables created by the VM, such as the exception that ends a thread, along with
+// the Throwable methods that print them. Their state is kept in Go, in Object.Native.
+// The stack trace is the frames as Java prints them, such as
+// "com.example.Hello.main(Hello.java:5)", from the frame that threw the exception down.
+//
+// Throwables created by Java code are constructed by the natives of the constructors of
+// Throwable and of the JDK's exceptions (see throwableSuperclasses), which the
+// constructors of an application's exceptions call in turn, so they have the same state.
+
+// Throwable is the Go-side state of a Throwable created by the VM
+type Throwable struct {
+	Message    string
+	HasMessage bool  // false if the message is null
+	Cause      int64 // the Throwable that caused this one, or 0
+	StackTrace []string
+	Suppressed []int64 // the exceptions added by addSuppressed(), as by try-with-resources
+}
+
+// throwableSuperclasses maps the JDK's commonly used Throwable classes to their
+// superclasses. Their objects are created without loading their class files (see
+// GoClasses), and a catch block's class is matched with the exception's superclasses
+// from this table when they haven't been loaded (see KnownSuperclass()).
+var throwableSuperclasses = map[string]string{
+	"java/lang/Throwable":                       "java/lang/Object",
+	"java/lang/Exception":                       "java/lang/Throwable",
+	"java/lang/Error":                           "java/lang/Throwable",
+	"java/lang/RuntimeException":                "java/lang/Exception",
+	"java/lang/ArithmeticException":             "java/lang/RuntimeException",
+	"java/lang/ArrayStoreException":             "java/lang/RuntimeException",
+	"java/lang/ClassCastException":              "java/lang/RuntimeException",
+	"java/lang/IllegalArgumentException":        "java/lang/RuntimeException",
+	"java/lang/IllegalMonitorStateException":    "java/lang/RuntimeException",
+	"java/lang/IllegalStateException":           "java/lang/RuntimeException",
+	"java/lang/IndexOutOfBoundsException":       "java/lang/RuntimeException",
+	"java/lang/NegativeArraySizeException":      "java/lang/RuntimeException",
+	"java/lang/NullPointerException":            "java/lang/RuntimeException",
+	"java/lang/UnsupportedOperationException":   "java/lang/RuntimeException",
+	"java/lang/invoke/WrongMethodTypeException": "java/lang/RuntimeException",
+	"java/lang/ArrayIndexOutOfBoundsException":  "java/lang/IndexOutOfBoundsException",
+	"java/lang/StringIndexOutOfBoundsException": "java/lang/IndexOutOfBoundsException",
+	"java/lang/NumberFormatException":           "java/lang/IllegalArgumentException",
+	"java/lang/CloneNotSupportedException":      "java/lang/Exception",
+	"java/lang/InterruptedException":            "java/lang/Exception",
+	"java/lang/ReflectiveOperationException":    "java/lang/Exception",
+	"java/lang/ClassNotFoundException":          "java/lang/ReflectiveOperationException",
+	"java/io/IOException":                       "java/lang/Exception",
+	"java/io/FileNotFoundException":             "java/io/IOException",
+	"java/io/UncheckedIOException":              "java/lang/RuntimeException",
+	"java/net/MalformedURLException":            "java/io/IOException",
+	"java/util/NoSuchElementException":          "java/lang/RuntimeException",
+	"java/lang/AssertionError":                  "java/lang/Error",
+	"java/lang/LinkageError":                    "java/lang/Error",
+	"java/lang/ClassFormatError":                "java/lang/LinkageError",
+	"java/lang/UnsupportedClassVersionError":    "java/lang/ClassFormatError",
+	"java/lang/ExceptionInInitializerError":     "java/lang/LinkageError",
+	"java/lang/IncompatibleClassChangeError":    "java/lang/LinkageError",
+	"java/lang/NoSuchFieldError":                "java/lang/IncompatibleClassChangeError",
+	"java/lang/NoSuchMethodError":               "java/lang/IncompatibleClassChangeError",
+	"java/lang/NoClassDefFoundError":            "java/lang/LinkageError",
+	"java/lang/UnsatisfiedLinkError":            "java/lang/LinkageError",
+	"java/lang/VirtualMachineError":             "java/lang/Error",
+	"java/lang/InternalError":                   "java/lang/VirtualMachineError",
+	"java/lang/OutOfMemoryError":                "java/lang/VirtualMachineError",
+	"java/lang/StackOverflowError":              "java/lang/VirtualMachineError",
+
+	"java/lang/annotation/IncompleteAnnotationException": "java/lang/RuntimeException",
+	"java/lang/IllegalThreadStateException":              "java/lang/IllegalArgumentException",
+	"java/io/UnsupportedEncodingException":               "java/io/IOException",
+	"java/nio/BufferOverflowException":                   "java/lang/RuntimeException",
+	"java/nio/BufferUnderflowException":                  "java/lang/RuntimeException",
+	"java/nio/InvalidMarkException":                      "java/lang/IllegalStateException",
+	"java/nio/ReadOnlyBufferException":                   "java/lang/UnsupportedOperationException",
+	"java/nio/channels/ClosedChannelException":           "java/io/IOException",
+	"java/nio/channels/NonWritableChannelException":      "java/lang/IllegalStateException",
+	"java/lang/InstantiationException":                   "java/lang/ReflectiveOperationException",
+	"java/lang/NoSuchMethodException":                    "java/lang/ReflectiveOperationException",
+	"java/lang/reflect/InvocationTargetException":        "java/lang/ReflectiveOperationException",
+	"java/net/SocketException":                           "java/io/IOException",
+	"java/net/BindException":                             "java/net/SocketException",
+	"java/net/ConnectException":                          "java/net/SocketException",
+	"java/io/InterruptedIOException":                     "java/io/IOException",
+	"java/net/SocketTimeoutException":                    "java/io/InterruptedIOException",
+	"java/net/UnknownHostException":                      "java/io/IOException",
+	"java/nio/charset/IllegalCharsetNameException":       "java/lang/IllegalArgumentException",
+	"java/nio/charset/UnsupportedCharsetException":       "java/lang/IllegalArgumentException",
+	"jdk/crac/CheckpointException":                       "java/lang/Exception",
+	"org/crac/CheckpointException":                       "java/lang/Exception",
+}
+
+// KnownSuperclass returns the superclass of the class if the class has been loaded or
+// is in throwableSuperclasses, without loading any class. Otherwise, it returns "".
+func KnownSuperclass(className string) string {
+	MethAreaMutex.RLock()
+	k, present := Classes[className]
+	MethAreaMutex.RUnlock()
+	if present && k.Data != nil {
+		return k.Data.Superclass
+	}
+	return throwableSuperclasses[className]
+}
+
+// IsSubclassOf reports whether the class is the other class or one of its known
+// subclasses (see KnownSuperclass()), as when the class of a catch block is matched
+func IsSubclassOf(className, super string) bool {
+	for c := className; c != ""; c = KnownSuperclass(c) {
+		if c == super {
+			return true
+		}
+	}
+	return false
+}
+
+// FillInStackTrace gives the referenced Throwable the stack trace, unless it already
+// has one. A Throwable created by Java code gets its stack trace when it's thrown.
+func FillInStackTrace(ref int64, trace []string) {
+	obj := GetObject(ref)
+	if obj == nil {
+		return
+	}
+	t, ok := obj.Native.(*Throwable)
+	if !ok {
+		t = &Throwable{}
+		obj.Native = t
+	}
+	if t.StackTrace == nil {
+		t.StackTrace = trace
+	}
+}
+
+// NewThrowable creates a Throwable of the class (as in java/lang/NullPointerException)
+// and returns the reference to it
+func NewThrowable(className string, t *Throwable) int64 {
+	ref := NewObject(className, 0)
+	GetObject(ref).Native = t
+	return ref
+}
+
+// throwableOf returns the state of the referenced Throwable, which is empty if it
+// wasn't created by the VM
+func throwableOf(ref int64) *Throwable {
+	if obj := GetObject(ref); obj != nil {
+		if t, ok := obj.Native.(*Throwable); ok {
+			return t
+		}
+	}
+	return &Throwable{}
+}
+
+// ThrowableString returns what Throwable.toString() does: the class name, followed by
+// a colon and the message if there is one
+func ThrowableString(ref int64) string {
+	obj := GetObject(ref)
+	if obj == nil {
+		return "null"
+	}
+	s := strings.ReplaceAll(obj.Klass, "/", ".")
+	if t := throwableOf(ref); t.HasMessage {
+		s += ": " + t.Message
+	}
+	return s
+}
+
+// WriteStackTrace writes the Throwable and its stack trace, followed by its suppressed
+// exceptions and its causes, as Throwable.printStackTrace() does. The suppressed
+// exceptions are indented by a tab, and the frames that one has in common with the
+// Throwable that encloses it are shown as "... n more".
+func WriteStackTrace(w io.Writer, ref int64) {
+	writeEnclosedTrace(w, ref, nil, "", "", map[int64]bool{})
+}
+
+// writeEnclosedTrace writes the stack trace of a Throwable that's the suppressed
+// exception or the cause of the one whose stack trace is enclosing
+func writeEnclosedTrace(w io.Writer, ref int64, enclosing []string, caption, prefix string,
+	seen map[int64]bool) {
+	if seen[ref] {
+		fmt.Fprintln(w, prefix+caption+"[CIRCULAR REFERENCE: "+ThrowableString(ref)+"]")
+		return
+	}
+	seen[ref] = true
+	t := throwableOf(ref)
+	fmt.Fprintln(w, prefix+caption+ThrowableString(ref))
+
+	// the frames in common are at the bottom of both traces
+	common := 0
+	for common < len(t.StackTrace) && common < len(enclosing) &&
+		t.StackTrace[len(t.StackTrace)-1-common] == enclosing[len(enclosing)-1-common] {
+		common++
+	}
+	for _, frame := range t.StackTrace[:len(t.StackTrace)-common] {
+		fmt.Fprintln(w, prefix+"\tat "+frame)
+	}
+	if common > 0 {
+		fmt.Fprintf(w, "%s\t... %d more\n", prefix, common)
+	}
+	for _, s := range t.Suppressed {
+		writeEnclosedTrace(w, s, t.StackTrace, "Suppressed: ", prefix+"\t", seen)
+	}
+	if t.Cause != 0 {
+		writeEnclosedTrace(w, t.Cause, t.StackTrace, "Caused by: ", prefix, seen)
+	}
+}
+
+// SystemErr returns the stream that System.err writes to
+func SystemErr() io.Writer {
+	if index, present := FindStatic("java/lang/System.err"); present {
+		if w := writerFor(LoadStaticInt(index)); w != nil {
+			return w
+		}
+	}
+	return stdOutput(2)
+}
+
+func Load_Lang_Throwable() map[string]GMeth {
+	message := func(this int64) int64 {
+		if t := throwableOf(this); t.HasMessage {
+			return NewStringObject(t.Message)
+		}
+		return 0
+	}
+	addNative("java/lang/Throwable.getMessage()Ljava/lang/String;", false, message)
+	addNative("java/lang/Throwable.getLocalizedMessage()Ljava/lang/String;", false, message)
+	addNative("java/lang/Throwable.toString()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(ThrowableString(this))
+	})
+	addNative("java/lang/Throwable.getCause()Ljava/lang/Throwable;", false, func(this int64) int64 {
+		return throwableOf(this).Cause
+	})
+	addNative("java/lang/reflect/InvocationTargetException.getTargetException()Ljava/lang/Throwable;", false,
+		func(this int64) int64 {
+			return throwableOf(this).Cause
+		})
+	addNative("java/lang/Throwable.printStackTrace()V", false, func(this int64) {
+		WriteStackTrace(SystemErr(), this)
+	})
+	addNative("java/lang/Throwable.getSuppressed()[Ljava/lang/Throwable;", false, func(this int64) int64 {
+		return NewRefArray("java/lang/Throwable", append([]int64{}, throwableOf(this).Suppressed...))
+	})
+
+	// addSuppressed() throws, so it's registered as a raw GMeth, which can return an error
+	MethodSignatures["java/lang/Throwable.addSuppressed(Ljava/lang/Throwable;)V"] = GMeth{
+		ParamSlots: 2,
+		GFunction: func(params []interface{}) interface{} {
+			this, exception := params[0].(int64), params[1].(int64)
+			if exception == this {
+				return errors.New("java.lang.IllegalArgumentException: Self-suppression not permitted")
+			}
+			if exception == 0 {
+				return errors.New("java.lang.NullPointerException: Cannot suppress a null exception.")
+			}
+			if obj := GetObject(this); obj != nil {
+				if t, ok := obj.Native.(*Throwable); ok {
+					t.Suppressed = append(t.Suppressed, exception)
+				} else {
+					obj.Native = &Throwable{Suppressed: []int64{exception}}
+				}
+			}
+			return nil
+		},
+	}
+
+	// the constructors, which the constructors of an application's exceptions call
+	for class := range throwableSuperclasses {
+		GoClasses[class] = true
+		addNative(class+".<init>()V", false, func(this int64) {
+			initThrowable(this, &Throwable{})
+		})
+		addNative(class+".<init>(Ljava/lang/String;)V", false, func(this, message int64) {
+			s, ok := GoStringFromRef(message)
+			initThrowable(this, &Throwable{Message: s, HasMessage: ok})
+		})
+		addNative(class+".<init>(Ljava/lang/String;Ljava/lang/Throwable;)V", false,
+			func(this, message, cause int64) {
+				s, ok := GoStringFromRef(message)
+				initThrowable(this, &Throwable{Message: s, HasMessage: ok, Cause: cause})
+			})
+		addNative(class+".<init>(Ljava/lang/Throwable;)V", false, func(this, cause int64) {
+			// as in the JDK, the message is the cause's toString()
+			initThrowable(this, &Throwable{Message: ThrowableString(cause), HasMessage: cause != 0, Cause: cause})
+		})
+	}
+	return MethodSignatures
+}
+
+// initThrowable gives the newly constructed Throwable its state
+func initThrowable(this int64, t *Throwable) {
+	if obj := GetObject(this); obj != nil {
+		obj.Native = t
+	}
+}
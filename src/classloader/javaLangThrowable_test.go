@@ -0,0 +1,98 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestThrowableNatives(t *testing.T) {
+	Load_Lang_Throwable()
+	cause := NewThrowable("java/io/IOException", &Throwable{})
+	ref := NewThrowable("java/lang/RuntimeException",
+		&Throwable{Message: "failed", HasMessage: true, Cause: cause})
+
+	msg, _ := GoStringFromRef(callNative(t, "java/lang/Throwable.getMessage()Ljava/lang/String;", ref).(int64))
+	if msg != "failed" {
+		t.Errorf("Expected the message 'failed', got: %s", msg)
+	}
+	if callNative(t, "java/lang/Throwable.getMessage()Ljava/lang/String;", cause).(int64) != 0 {
+		t.Errorf("Expected a null message for an exception without one")
+	}
+	s, _ := GoStringFromRef(callNative(t, "java/lang/Throwable.toString()Ljava/lang/String;", ref).(int64))
+	if s != "java.lang.RuntimeException: failed" {
+		t.Errorf("Expected toString() to give the class and message, got: %s", s)
+	}
+	if callNative(t, "java/lang/Throwable.getCause()Ljava/lang/Throwable;", ref).(int64) != cause {
+		t.Errorf("Expected getCause() to return the cause")
+	}
+}
+
+func TestWriteStackTrace(t *testing.T) {
+	cause := NewThrowable("java/io/IOException", &Throwable{Message: "no file", HasMessage: true,
+		StackTrace: []string{"Hello.open(Hello.java:20)", "Hello.run(Hello.java:10)", "Hello.main(Hello.java:5)"}})
+	ref := NewThrowable("java/lang/RuntimeException", &Throwable{Cause: cause,
+		StackTrace: []string{"Hello.run(Hello.java:12)", "Hello.main(Hello.java:5)"}})
+
+	var out bytes.Buffer
+	WriteStackTrace(&out, ref)
+	expected := "java.lang.RuntimeException\n" +
+		"\tat Hello.run(Hello.java:12)\n" +
+		"\tat Hello.main(Hello.java:5)\n" +
+		"Caused by: java.io.IOException: no file\n" +
+		"\tat Hello.open(Hello.java:20)\n" +
+		"\tat Hello.run(Hello.java:10)\n" +
+		"\t... 1 more\n"
+	if out.String() != expected {
+		t.Errorf("Expected the stack trace:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+// try-with-resources adds the exception that close() throws to the one the body threw
+func TestAddSuppressed(t *testing.T) {
+	Load_Lang_Throwable()
+	body := NewObject("java/lang/IllegalStateException", 0)
+	callNative(t, "java/lang/IllegalStateException.<init>(Ljava/lang/String;)V", body, NewStringObject("body"))
+	closing := NewObject("java/io/IOException", 0)
+	callNative(t, "java/io/IOException.<init>(Ljava/lang/String;)V", closing, NewStringObject("close"))
+	FillInStackTrace(body, []string{"Hello.main(Hello.java:5)"})
+	FillInStackTrace(closing, []string{"Hello.close(Hello.java:20)", "Hello.main(Hello.java:5)"})
+
+	addSuppressed := "java/lang/Throwable.addSuppressed(Ljava/lang/Throwable;)V"
+	if err := callNative(t, addSuppressed, body, closing); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	suppressed, _ := RefArrayFromRef(callNative(t, "java/lang/Throwable.getSuppressed()[Ljava/lang/Throwable;", body).(int64))
+	if len(suppressed) != 1 || suppressed[0] != closing {
+		t.Errorf("Expected getSuppressed() to return the IOException, got %v", suppressed)
+	}
+	if err, _ := callNative(t, addSuppressed, body, body).(error); err == nil ||
+		err.Error() != "java.lang.IllegalArgumentException: Self-suppression not permitted" {
+		t.Errorf("Expected self-suppression to throw IllegalArgumentException, got %v", err)
+	}
+
+	var out bytes.Buffer
+	WriteStackTrace(&out, body)
+	expected := "java.lang.IllegalStateException: body\n" +
+		"\tat Hello.main(Hello.java:5)\n" +
+		"\tSuppressed: java.io.IOException: close\n" +
+		"\t\tat Hello.close(Hello.java:20)\n" +
+		"\t\t... 1 more\n"
+	if out.String() != expected {
+		t.Errorf("Expected the stack trace:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestIsSubclassOf(t *testing.T) {
+	if !IsSubclassOf("java/lang/ArrayIndexOutOfBoundsException", "java/lang/RuntimeException") {
+		t.Error("Expected ArrayIndexOutOfBoundsException to be a RuntimeException")
+	}
+	if IsSubclassOf("java/io/IOException", "java/lang/RuntimeException") {
+		t.Error("Expected IOException not to be a RuntimeException")
+	}
+}
@@ -0,0 +1,470 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// java.net.Socket, ServerSocket, and InetSocketAddress are implemented in Go over the
+// net package. A Socket's Native field is a *javaSocket. Its input and output streams
+// are objects whose Native field is the same *javaSocket, which implements io.Reader and
+// io.Writer, so the generic InputStream/OutputStream natives (see javaIoStreams.go)
+// can read and write it. As in the JDK, closing either stream closes the socket.
+//
+// Failures to connect, bind, etc. leave the socket unconnected (or unbound) and throw
+// the exception the JDK does: ConnectException, BindException, SocketTimeoutException,
+// etc. (see socketException()).
+
+type javaSocket struct {
+	mutex   sync.Mutex
+	conn    net.Conn
+	timeout time.Duration // SO_TIMEOUT, for reads; 0 = none
+	closed  bool
+}
+
+type javaServerSocket struct {
+	mutex    sync.Mutex
+	listener *net.TCPListener
+	timeout  time.Duration // SO_TIMEOUT, for accept(); 0 = none
+	closed   bool
+}
+
+type socketAddress struct {
+	host string // empty for the wildcard address
+	port int
+}
+
+var (
+	errSocketIsClosed     = errors.New("java.net.SocketException: Socket is closed")
+	errSocketNotConnected = errors.New("java.net.SocketException: Socket is not connected")
+	errSocketClosed       = errors.New("java.net.SocketException: Socket closed") // by a read or write
+)
+
+// socketException returns the exception for the error of a socket operation. The
+// operation is named in the message of a SocketTimeoutException, as in "Read timed out".
+func socketException(err error, op string) error {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var errno syscall.Errno
+	switch {
+	case errors.As(err, &dnsErr):
+		return errors.New("java.net.UnknownHostException: " + dnsErr.Name)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return errors.New("java.net.SocketTimeoutException: " + op + " timed out")
+	case errors.Is(err, net.ErrClosed):
+		return errSocketClosed
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errors.New("java.net.ConnectException: Connection refused")
+	case errors.Is(err, syscall.EADDRINUSE):
+		return errors.New("java.net.BindException: Address already in use")
+	case errors.As(err, &errno):
+		return errors.New("java.net.SocketException: " + osErrorText(errno))
+	}
+	return errors.New("java.net.SocketException: " + osErrorText(err))
+}
+
+func (s *javaSocket) Read(b []byte) (int, error) {
+	s.mutex.Lock()
+	conn, timeout := s.conn, s.timeout
+	s.mutex.Unlock()
+	if conn == nil {
+		return 0, errSocketClosed
+	}
+	if timeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	} else {
+		_ = conn.SetReadDeadline(time.Time{})
+	}
+	n, err := conn.Read(b)
+	if err != nil && err != io.EOF {
+		err = socketException(err, "Read")
+	}
+	return n, err
+}
+
+func (s *javaSocket) Write(b []byte) (int, error) {
+	s.mutex.Lock()
+	conn := s.conn
+	s.mutex.Unlock()
+	if conn == nil {
+		return 0, errSocketClosed
+	}
+	n, err := conn.Write(b)
+	if err != nil {
+		err = socketException(err, "Write")
+	}
+	return n, err
+}
+
+func (s *javaSocket) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// tcp returns the socket's TCP connection, for setting socket options, or nil
+func (s *javaSocket) tcp() *net.TCPConn {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tc, _ := s.conn.(*net.TCPConn)
+	return tc
+}
+
+func socketOf(ref int64) *javaSocket {
+	if obj := GetObject(ref); obj != nil {
+		if s, ok := obj.Native.(*javaSocket); ok {
+			return s
+		}
+		s := &javaSocket{} // created by new, but not yet initialized
+		obj.Native = s
+		return s
+	}
+	return &javaSocket{}
+}
+
+func serverSocketOf(ref int64) *javaServerSocket {
+	if obj := GetObject(ref); obj != nil {
+		if s, ok := obj.Native.(*javaServerSocket); ok {
+			return s
+		}
+		s := &javaServerSocket{}
+		obj.Native = s
+		return s
+	}
+	return &javaServerSocket{}
+}
+
+func addressOf(ref int64) socketAddress {
+	if obj := GetObject(ref); obj != nil {
+		if a, ok := obj.Native.(*socketAddress); ok {
+			return *a
+		}
+	}
+	return socketAddress{}
+}
+
+func (a socketAddress) String() string {
+	return net.JoinHostPort(a.host, strconv.Itoa(a.port))
+}
+
+// connectSocket connects the socket to the address; a timeout of 0 means none
+func connectSocket(this int64, addr socketAddress, timeoutMillis int32) error {
+	if timeoutMillis < 0 {
+		return errors.New("java.lang.IllegalArgumentException: connect: timeout can't be negative")
+	}
+	s := socketOf(this)
+	s.mutex.Lock()
+	closed, connected := s.closed, s.conn != nil
+	s.mutex.Unlock()
+	switch {
+	case closed:
+		return errSocketIsClosed
+	case connected:
+		return errors.New("java.net.SocketException: already connected")
+	}
+
+	conn, err := net.DialTimeout("tcp", addr.String(), time.Duration(timeoutMillis)*time.Millisecond)
+	if err != nil {
+		return socketException(err, "Connect")
+	}
+	s.mutex.Lock()
+	s.conn = conn
+	s.mutex.Unlock()
+	return nil
+}
+
+// bindServerSocket starts listening on the address. Go sets SO_REUSEADDR on listening
+// sockets, which is what the JDK does by default too. The backlog is left to the OS.
+func bindServerSocket(this int64, addr socketAddress) error {
+	s := serverSocketOf(this)
+	s.mutex.Lock()
+	closed, bound := s.closed, s.listener != nil
+	s.mutex.Unlock()
+	switch {
+	case closed:
+		return errSocketIsClosed
+	case bound:
+		return errors.New("java.net.SocketException: Already bound")
+	}
+
+	tcpAddr, err := net.ResolveTCPAddr("tcp", addr.String())
+	if err != nil {
+		return socketException(err, "Bind")
+	}
+	ln, err := net.ListenTCP("tcp", tcpAddr)
+	if err != nil {
+		return socketException(err, "Bind")
+	}
+	s.mutex.Lock()
+	s.listener = ln
+	s.mutex.Unlock()
+	return nil
+}
+
+// socketStream returns the input or output stream of the connected socket
+func socketStream(this int64, class string) (int64, error) {
+	s := socketOf(this)
+	s.mutex.Lock()
+	closed, connected := s.closed, s.conn != nil
+	s.mutex.Unlock()
+	switch {
+	case closed:
+		return 0, errSocketIsClosed
+	case !connected:
+		return 0, errSocketNotConnected
+	}
+	return newSocketStream(class, s), nil
+}
+
+// soTimeout converts the value of SO_TIMEOUT, which can't be negative
+func soTimeout(millis int32) (time.Duration, error) {
+	if millis < 0 {
+		return 0, errors.New("java.lang.IllegalArgumentException: timeout can't be negative")
+	}
+	return time.Duration(millis) * time.Millisecond, nil
+}
+
+// port returns the port of the address, or 0 if there's none (e.g., unconnected)
+func port(addr net.Addr) int32 {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return int32(tcpAddr.Port)
+	}
+	return 0
+}
+
+func newSocketStream(class string, s *javaSocket) int64 {
+	ref := NewObject(class, 0)
+	GetObject(ref).Native = s
+	return ref
+}
+
+func Load_Net_Socket() map[string]GMeth {
+	// java.net.InetSocketAddress
+	isa := "java/net/InetSocketAddress"
+	addNative(isa+".<init>(Ljava/lang/String;I)V", false, func(this, host int64, p int32) {
+		GetObject(this).Native = &socketAddress{host: javaString(host), port: int(p)}
+	})
+	addNative(isa+".<init>(I)V", false, func(this int64, p int32) {
+		GetObject(this).Native = &socketAddress{port: int(p)}
+	})
+	addNative(isa+".getPort()I", false, func(this int64) int32 {
+		return int32(addressOf(this).port)
+	})
+	addNative(isa+".getHostString()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(addressOf(this).host)
+	})
+
+	// java.net.Socket
+	sock := "java/net/Socket"
+	addNative(sock+".<init>()V", false, func(this int64) {
+		GetObject(this).Native = &javaSocket{}
+	})
+	addNative(sock+".<init>(Ljava/lang/String;I)V", false, func(this, host int64, p int32) error {
+		GetObject(this).Native = &javaSocket{}
+		return connectSocket(this, socketAddress{host: javaString(host), port: int(p)}, 0)
+	})
+	connect := func(this, addr int64, timeout int32) error {
+		if addr == 0 {
+			return errors.New("java.lang.IllegalArgumentException: connect: The address can't be null")
+		}
+		return connectSocket(this, addressOf(addr), timeout)
+	}
+	addNative(sock+".connect(Ljava/net/SocketAddress;)V", false, func(this, addr int64) error {
+		return connect(this, addr, 0)
+	})
+	addNative(sock+".connect(Ljava/net/SocketAddress;I)V", false, connect)
+	addNative(sock+".getInputStream()Ljava/io/InputStream;", false, func(this int64) (int64, error) {
+		return socketStream(this, "java/net/SocketInputStream")
+	})
+	addNative(sock+".getOutputStream()Ljava/io/OutputStream;", false, func(this int64) (int64, error) {
+		return socketStream(this, "java/net/SocketOutputStream")
+	})
+	addNative(sock+".isConnected()Z", false, func(this int64) bool {
+		s := socketOf(this)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return s.conn != nil
+	})
+	addNative(sock+".isClosed()Z", false, func(this int64) bool {
+		s := socketOf(this)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return s.closed
+	})
+	addNative(sock+".close()V", false, func(this int64) {
+		_ = socketOf(this).Close()
+	})
+	addNative(sock+".getPort()I", false, func(this int64) int32 {
+		if tc := socketOf(this).tcp(); tc != nil {
+			return port(tc.RemoteAddr())
+		}
+		return 0
+	})
+	addNative(sock+".getLocalPort()I", false, func(this int64) int32 {
+		if tc := socketOf(this).tcp(); tc != nil {
+			return port(tc.LocalAddr())
+		}
+		return -1 // as in the JDK, for an unbound socket
+	})
+	addNative(sock+".shutdownOutput()V", false, func(this int64) {
+		if tc := socketOf(this).tcp(); tc != nil {
+			_ = tc.CloseWrite()
+		}
+	})
+	addNative(sock+".shutdownInput()V", false, func(this int64) {
+		if tc := socketOf(this).tcp(); tc != nil {
+			_ = tc.CloseRead()
+		}
+	})
+
+	// socket options
+	addNative(sock+".setSoTimeout(I)V", false, func(this int64, millis int32) error {
+		timeout, err := soTimeout(millis)
+		if err != nil {
+			return err
+		}
+		s := socketOf(this)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.closed {
+			return errSocketIsClosed
+		}
+		s.timeout = timeout
+		return nil
+	})
+	addNative(sock+".getSoTimeout()I", false, func(this int64) int32 {
+		s := socketOf(this)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return int32(s.timeout / time.Millisecond)
+	})
+	addNative(sock+".setTcpNoDelay(Z)V", false, func(this int64, on bool) {
+		if tc := socketOf(this).tcp(); tc != nil {
+			_ = tc.SetNoDelay(on)
+		}
+	})
+	addNative(sock+".setKeepAlive(Z)V", false, func(this int64, on bool) {
+		if tc := socketOf(this).tcp(); tc != nil {
+			_ = tc.SetKeepAlive(on)
+		}
+	})
+	addNative(sock+".setSoLinger(ZI)V", false, func(this int64, on bool, secs int32) {
+		if tc := socketOf(this).tcp(); tc != nil {
+			if !on {
+				secs = -1
+			}
+			_ = tc.SetLinger(int(secs))
+		}
+	})
+	addNative(sock+".setReceiveBufferSize(I)V", false, func(this int64, size int32) {
+		if tc := socketOf(this).tcp(); tc != nil {
+			_ = tc.SetReadBuffer(int(size))
+		}
+	})
+	addNative(sock+".setSendBufferSize(I)V", false, func(this int64, size int32) {
+		if tc := socketOf(this).tcp(); tc != nil {
+			_ = tc.SetWriteBuffer(int(size))
+		}
+	})
+
+	// java.net.ServerSocket
+	server := "java/net/ServerSocket"
+	addNative(server+".<init>()V", false, func(this int64) {
+		GetObject(this).Native = &javaServerSocket{}
+	})
+	addNative(server+".<init>(I)V", false, func(this int64, p int32) error {
+		GetObject(this).Native = &javaServerSocket{}
+		return bindServerSocket(this, socketAddress{port: int(p)})
+	})
+	addNative(server+".<init>(II)V", false, func(this int64, p, backlog int32) error {
+		GetObject(this).Native = &javaServerSocket{}
+		return bindServerSocket(this, socketAddress{port: int(p)})
+	})
+	addNative(server+".bind(Ljava/net/SocketAddress;)V", false, func(this, addr int64) error {
+		return bindServerSocket(this, addressOf(addr))
+	})
+	addNative(server+".bind(Ljava/net/SocketAddress;I)V", false, func(this, addr int64, backlog int32) error {
+		return bindServerSocket(this, addressOf(addr))
+	})
+	addNative(server+".accept()Ljava/net/Socket;", false, func(this int64) (int64, error) {
+		s := serverSocketOf(this)
+		s.mutex.Lock()
+		ln, timeout, closed := s.listener, s.timeout, s.closed
+		s.mutex.Unlock()
+		switch {
+		case closed:
+			return 0, errSocketIsClosed
+		case ln == nil:
+			return 0, errors.New("java.net.SocketException: Socket is not bound yet")
+		}
+		if timeout > 0 {
+			_ = ln.SetDeadline(time.Now().Add(timeout))
+		} else {
+			_ = ln.SetDeadline(time.Time{})
+		}
+		conn, err := ln.Accept()
+		if err != nil {
+			return 0, socketException(err, "Accept")
+		}
+		ref := NewObject(sock, 0)
+		GetObject(ref).Native = &javaSocket{conn: conn}
+		return ref, nil
+	})
+	addNative(server+".getLocalPort()I", false, func(this int64) int32 {
+		s := serverSocketOf(this)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.listener == nil {
+			return -1
+		}
+		return port(s.listener.Addr())
+	})
+	addNative(server+".setSoTimeout(I)V", false, func(this int64, millis int32) error {
+		timeout, err := soTimeout(millis)
+		if err != nil {
+			return err
+		}
+		s := serverSocketOf(this)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.closed {
+			return errSocketIsClosed
+		}
+		s.timeout = timeout
+		return nil
+	})
+	addNative(server+".setReuseAddress(Z)V", false, func(this int64, on bool) {
+		// SO_REUSEADDR is always set on listening sockets (see bindServerSocket())
+	})
+	addNative(server+".isClosed()Z", false, func(this int64) bool {
+		s := serverSocketOf(this)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		return s.closed
+	})
+	addNative(server+".close()V", false, func(this int64) {
+		s := serverSocketOf(this)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.closed = true
+		if s.listener != nil {
+			_ = s.listener.Close()
+		}
+	})
+	return MethodSignatures
+}
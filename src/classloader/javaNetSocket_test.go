@@ -0,0 +1,123 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSocketClientServerEcho(t *testing.T) {
+	Load_Net_Socket()
+	Load_Io_Streams()
+	Load_Io_PrintStream()
+
+	server := NewObject("java/net/ServerSocket", 0)
+	callNative(t, "java/net/ServerSocket.<init>(I)V", server, int64(0)) // any free port
+	port := callNative(t, "java/net/ServerSocket.getLocalPort()I", server).(int64)
+	if port <= 0 {
+		t.Fatalf("Expected server socket to be bound, got port %d", port)
+	}
+
+	// the server side echoes one line back to the client, then closes
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn := callNative(t, "java/net/ServerSocket.accept()Ljava/net/Socket;", server).(int64)
+		in := callNative(t, "java/net/Socket.getInputStream()Ljava/io/InputStream;", conn).(int64)
+		out := callNative(t, "java/net/Socket.getOutputStream()Ljava/io/OutputStream;", conn).(int64)
+		buf := NewByteArray(make([]byte, 64))
+		n := callNative(t, "java/io/InputStream.read([B)I", in, buf).(int64)
+		callNative(t, "java/io/OutputStream.write([BII)V", out, buf, int64(0), n)
+		callNative(t, "java/net/Socket.close()V", conn)
+	}()
+
+	addr := NewObject("java/net/InetSocketAddress", 0)
+	callNative(t, "java/net/InetSocketAddress.<init>(Ljava/lang/String;I)V", addr,
+		NewStringObject("127.0.0.1"), port)
+	client := NewObject("java/net/Socket", 0)
+	callNative(t, "java/net/Socket.<init>()V", client)
+	callNative(t, "java/net/Socket.connect(Ljava/net/SocketAddress;I)V", client, addr, int64(5000))
+	if callNative(t, "java/net/Socket.isConnected()Z", client).(int64) != 1 {
+		t.Fatalf("Expected client socket to connect")
+	}
+	callNative(t, "java/net/Socket.setSoTimeout(I)V", client, int64(5000))
+	callNative(t, "java/net/Socket.setTcpNoDelay(Z)V", client, int64(1))
+
+	// write through a PrintStream wrapped around the socket's output stream
+	out := callNative(t, "java/net/Socket.getOutputStream()Ljava/io/OutputStream;", client).(int64)
+	ps := NewObject("java/io/PrintStream", 0)
+	callNative(t, "java/io/PrintStream.<init>(Ljava/io/OutputStream;)V", ps, out)
+	Println([]interface{}{ps, NewStringObject("ping")})
+
+	in := callNative(t, "java/net/Socket.getInputStream()Ljava/io/InputStream;", client).(int64)
+	var reply []byte
+	for {
+		b := callNative(t, "java/io/InputStream.read()I", in).(int64)
+		if b == -1 {
+			break
+		}
+		reply = append(reply, byte(b))
+	}
+	<-done
+	if string(reply) != "ping\n" {
+		t.Errorf("Expected echo of \"ping\\n\", got: %q", reply)
+	}
+
+	callNative(t, "java/net/Socket.close()V", client)
+	if callNative(t, "java/net/Socket.isClosed()Z", client).(int64) != 1 {
+		t.Errorf("Expected client socket to be closed")
+	}
+	callNative(t, "java/net/ServerSocket.close()V", server)
+}
+
+func TestAcceptTimesOut(t *testing.T) {
+	Load_Net_Socket()
+	server := NewObject("java/net/ServerSocket", 0)
+	callNative(t, "java/net/ServerSocket.<init>(I)V", server, int64(0))
+	callNative(t, "java/net/ServerSocket.setSoTimeout(I)V", server, int64(20))
+	ret := callNative(t, "java/net/ServerSocket.accept()Ljava/net/Socket;", server)
+	if err, _ := ret.(error); err == nil || err.Error() != "java.net.SocketTimeoutException: Accept timed out" {
+		t.Errorf("Expected accept() with no client to time out, got: %v", ret)
+	}
+	callNative(t, "java/net/ServerSocket.close()V", server)
+	if err := callNative(t, "java/net/ServerSocket.accept()Ljava/net/Socket;", server); err != errSocketIsClosed {
+		t.Errorf("Expected accept() on a closed server socket to throw, got: %v", err)
+	}
+}
+
+func TestSocketExceptions(t *testing.T) {
+	Load_Net_Socket()
+
+	// a port that was just free has no listener
+	server := NewObject("java/net/ServerSocket", 0)
+	callNative(t, "java/net/ServerSocket.<init>(I)V", server, int64(0))
+	port := callNative(t, "java/net/ServerSocket.getLocalPort()I", server).(int64)
+	again := NewObject("java/net/ServerSocket", 0)
+	ret := callNative(t, "java/net/ServerSocket.<init>(I)V", again, port)
+	if err, _ := ret.(error); err == nil || !strings.HasPrefix(err.Error(), "java.net.BindException") {
+		t.Errorf("Expected binding a port in use to throw BindException, got: %v", ret)
+	}
+	callNative(t, "java/net/ServerSocket.close()V", server)
+
+	client := NewObject("java/net/Socket", 0)
+	ret = callNative(t, "java/net/Socket.<init>(Ljava/lang/String;I)V", client, NewStringObject("127.0.0.1"), port)
+	if err, _ := ret.(error); err == nil || err.Error() != "java.net.ConnectException: Connection refused" {
+		t.Errorf("Expected connecting to a port without a listener to be refused, got: %v", ret)
+	}
+	if err := callNative(t, "java/net/Socket.getInputStream()Ljava/io/InputStream;", client); err != errSocketNotConnected {
+		t.Errorf("Expected the stream of an unconnected socket to throw, got: %v", err)
+	}
+	ret = callNative(t, "java/net/Socket.setSoTimeout(I)V", client, int64(-1))
+	if err, _ := ret.(error); err == nil || err.Error() != "java.lang.IllegalArgumentException: timeout can't be negative" {
+		t.Errorf("Expected a negative timeout to throw, got: %v", ret)
+	}
+	callNative(t, "java/net/Socket.close()V", client)
+	if err := callNative(t, "java/net/Socket.getOutputStream()Ljava/io/OutputStream;", client); err != errSocketIsClosed {
+		t.Errorf("Expected the stream of a closed socket to throw, got: %v", err)
+	}
+}
@@ -0,0 +1,296 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// java.nio.ByteBuffer objects are implemented in Go. A heap buffer's contents are the
+// storage of a byte[] (its own, or the one passed to wrap()); a direct buffer's contents
+// are a block of native memory (see nativeMemory.go), whose address Unsafe can use.
+// As in the JDK, buffers are big-endian.
+//
+// The methods are registered for java/nio/ByteBuffer and, for the methods ByteBuffer
+// inherits, java/nio/Buffer, since the class named in a methodref is the static type
+// of the receiver.
+
+type byteBuffer struct {
+	data     []byte
+	position int
+	limit    int
+	mark     int   // -1 if no mark is set
+	address  int64 // the native memory address of a direct buffer; 0 for heap buffers
+	array    int64 // the backing byte[] of a heap buffer
+	readOnly bool
+}
+
+func newBufferObject(class string, bb *byteBuffer) int64 {
+	ref := NewObject(class, 0)
+	GetObject(ref).Native = bb
+	return ref
+}
+
+// NewHeapByteBuffer creates a ByteBuffer backed by the given byte[]
+func NewHeapByteBuffer(array int64) int64 {
+	data, _ := ByteArrayFromRef(array)
+	return newBufferObject("java/nio/HeapByteBuffer",
+		&byteBuffer{data: data, limit: len(data), mark: -1, array: array})
+}
+
+// NewDirectByteBuffer creates a ByteBuffer backed by newly allocated native memory
+func NewDirectByteBuffer(capacity int) int64 {
+	address := AllocateMemory(int64(capacity))
+	data, _ := NativeSlice(address, int64(capacity))
+	return newBufferObject("java/nio/DirectByteBuffer",
+		&byteBuffer{data: data, limit: capacity, mark: -1, address: address})
+}
+
+// bufferOf returns the state of the referenced ByteBuffer
+func bufferOf(ref int64) *byteBuffer {
+	if obj := GetObject(ref); obj != nil {
+		if bb, ok := obj.Native.(*byteBuffer); ok {
+			return bb
+		}
+	}
+	return &byteBuffer{mark: -1}
+}
+
+// the exceptions of the relative gets and puts, and of a change to a read-only buffer
+var (
+	errBufferUnderflow = errors.New("java.nio.BufferUnderflowException")
+	errBufferOverflow  = errors.New("java.nio.BufferOverflowException")
+	errReadOnlyBuffer  = errors.New("java.nio.ReadOnlyBufferException")
+)
+
+// next returns the n bytes at the position and advances the position past them. If
+// fewer than n bytes remain, the position doesn't change, and a get throws
+// BufferUnderflowException and a put BufferOverflowException.
+func (bb *byteBuffer) next(n int, put bool) ([]byte, error) {
+	if put && bb.readOnly {
+		return nil, errReadOnlyBuffer
+	}
+	if bb.limit-bb.position < n {
+		if put {
+			return nil, errBufferOverflow
+		}
+		return nil, errBufferUnderflow
+	}
+	b := bb.data[bb.position : bb.position+n]
+	bb.position += n
+	return b, nil
+}
+
+// at returns the n bytes at the index, without moving the position
+func (bb *byteBuffer) at(index int32, n int, put bool) ([]byte, error) {
+	if put && bb.readOnly {
+		return nil, errReadOnlyBuffer
+	}
+	if index < 0 || int(index)+n > bb.limit {
+		return nil, fmt.Errorf("java.lang.IndexOutOfBoundsException: Index %d out of bounds for length %d",
+			index, bb.limit)
+	}
+	return bb.data[index : int(index)+n], nil
+}
+
+func (bb *byteBuffer) remaining() int {
+	if bb.position > bb.limit {
+		return 0
+	}
+	return bb.limit - bb.position
+}
+
+func Load_Nio_ByteBuffer() map[string]GMeth {
+	bbClass := "java/nio/ByteBuffer"
+	bbDesc := "Ljava/nio/ByteBuffer;"
+
+	addNative(bbClass+".allocate(I)"+bbDesc, true, func(capacity int32) (int64, error) {
+		if capacity < 0 {
+			return 0, negativeCapacity(capacity)
+		}
+		return NewHeapByteBuffer(NewByteArray(make([]byte, capacity))), nil
+	})
+	addNative(bbClass+".allocateDirect(I)"+bbDesc, true, func(capacity int32) (int64, error) {
+		if capacity < 0 {
+			return 0, negativeCapacity(capacity)
+		}
+		return NewDirectByteBuffer(int(capacity)), nil
+	})
+	addNative(bbClass+".wrap([B)"+bbDesc, true, NewHeapByteBuffer)
+
+	// relative and absolute get/put. The relative puts return the buffer itself.
+	addNative(bbClass+".get()B", false, func(this int64) (int8, error) {
+		b, err := bufferOf(this).next(1, false)
+		if err != nil {
+			return 0, err
+		}
+		return int8(b[0]), nil
+	})
+	addNative(bbClass+".get(I)B", false, func(this int64, index int32) (int8, error) {
+		b, err := bufferOf(this).at(index, 1, false)
+		if err != nil {
+			return 0, err
+		}
+		return int8(b[0]), nil
+	})
+	addNative(bbClass+".put(B)"+bbDesc, false, func(this int64, value int8) (int64, error) {
+		b, err := bufferOf(this).next(1, true)
+		if err != nil {
+			return 0, err
+		}
+		b[0] = byte(value)
+		return this, nil
+	})
+	addNative(bbClass+".put(IB)"+bbDesc, false, func(this int64, index int32, value int8) (int64, error) {
+		b, err := bufferOf(this).at(index, 1, true)
+		if err != nil {
+			return 0, err
+		}
+		b[0] = byte(value)
+		return this, nil
+	})
+	addNative(bbClass+".getInt()I", false, func(this int64) (int32, error) {
+		b, err := bufferOf(this).next(4, false)
+		if err != nil {
+			return 0, err
+		}
+		return int32(binary.BigEndian.Uint32(b)), nil
+	})
+	addNative(bbClass+".putInt(I)"+bbDesc, false, func(this int64, value int32) (int64, error) {
+		b, err := bufferOf(this).next(4, true)
+		if err != nil {
+			return 0, err
+		}
+		binary.BigEndian.PutUint32(b, uint32(value))
+		return this, nil
+	})
+	addNative(bbClass+".getLong()J", false, func(this int64) (int64, error) {
+		b, err := bufferOf(this).next(8, false)
+		if err != nil {
+			return 0, err
+		}
+		return int64(binary.BigEndian.Uint64(b)), nil
+	})
+	addNative(bbClass+".putLong(J)"+bbDesc, false, func(this, value int64) (int64, error) {
+		b, err := bufferOf(this).next(8, true)
+		if err != nil {
+			return 0, err
+		}
+		binary.BigEndian.PutUint64(b, uint64(value))
+		return this, nil
+	})
+
+	addNative(bbClass+".isDirect()Z", false, func(this int64) bool {
+		return bufferOf(this).address != 0
+	})
+	addNative(bbClass+".isReadOnly()Z", false, func(this int64) bool {
+		return bufferOf(this).readOnly
+	})
+	addNative(bbClass+".hasArray()Z", false, func(this int64) bool {
+		bb := bufferOf(this)
+		return bb.array != 0 && !bb.readOnly
+	})
+	addNative(bbClass+".array()[B", false, func(this int64) (int64, error) {
+		bb := bufferOf(this)
+		switch {
+		case bb.readOnly:
+			return 0, errReadOnlyBuffer
+		case bb.array == 0:
+			return 0, errors.New("java.lang.UnsupportedOperationException")
+		}
+		return bb.array, nil
+	})
+
+	// the Buffer methods, which ByteBuffer overrides with covariant return types
+	for _, class := range []string{"java/nio/Buffer", bbClass} {
+		desc := "L" + class + ";"
+		addNative(class+".capacity()I", false, func(this int64) int32 {
+			return int32(len(bufferOf(this).data))
+		})
+		addNative(class+".position()I", false, func(this int64) int32 {
+			return int32(bufferOf(this).position)
+		})
+		addNative(class+".position(I)"+desc, false, func(this int64, pos int32) (int64, error) {
+			bb := bufferOf(this)
+			switch {
+			case pos < 0:
+				return 0, fmt.Errorf("java.lang.IllegalArgumentException: newPosition < 0: (%d < 0)", pos)
+			case int(pos) > bb.limit:
+				return 0, fmt.Errorf("java.lang.IllegalArgumentException: newPosition > limit: (%d > %d)",
+					pos, bb.limit)
+			}
+			bb.position = int(pos)
+			if bb.mark > bb.position {
+				bb.mark = -1
+			}
+			return this, nil
+		})
+		addNative(class+".limit()I", false, func(this int64) int32 {
+			return int32(bufferOf(this).limit)
+		})
+		addNative(class+".limit(I)"+desc, false, func(this int64, lim int32) (int64, error) {
+			bb := bufferOf(this)
+			switch {
+			case lim < 0:
+				return 0, fmt.Errorf("java.lang.IllegalArgumentException: newLimit < 0: (%d < 0)", lim)
+			case int(lim) > len(bb.data):
+				return 0, fmt.Errorf("java.lang.IllegalArgumentException: newLimit > capacity: (%d > %d)",
+					lim, len(bb.data))
+			}
+			bb.limit = int(lim)
+			if bb.position > bb.limit {
+				bb.position = bb.limit
+			}
+			if bb.mark > bb.limit {
+				bb.mark = -1
+			}
+			return this, nil
+		})
+		addNative(class+".remaining()I", false, func(this int64) int32 {
+			return int32(bufferOf(this).remaining())
+		})
+		addNative(class+".hasRemaining()Z", false, func(this int64) bool {
+			return bufferOf(this).remaining() > 0
+		})
+		addNative(class+".mark()"+desc, false, func(this int64) int64 {
+			bb := bufferOf(this)
+			bb.mark = bb.position
+			return this
+		})
+		addNative(class+".reset()"+desc, false, func(this int64) (int64, error) {
+			bb := bufferOf(this)
+			if bb.mark < 0 {
+				return 0, errors.New("java.nio.InvalidMarkException")
+			}
+			bb.position = bb.mark
+			return this, nil
+		})
+		addNative(class+".clear()"+desc, false, func(this int64) int64 {
+			bb := bufferOf(this)
+			bb.position, bb.limit, bb.mark = 0, len(bb.data), -1
+			return this
+		})
+		addNative(class+".flip()"+desc, false, func(this int64) int64 {
+			bb := bufferOf(this)
+			bb.limit, bb.position, bb.mark = bb.position, 0, -1
+			return this
+		})
+		addNative(class+".rewind()"+desc, false, func(this int64) int64 {
+			bb := bufferOf(this)
+			bb.position, bb.mark = 0, -1
+			return this
+		})
+	}
+	return MethodSignatures
+}
+
+// negativeCapacity returns the exception of allocate() for a negative capacity
+func negativeCapacity(capacity int32) error {
+	return fmt.Errorf("java.lang.IllegalArgumentException: capacity < 0: (%d < 0)", capacity)
+}
@@ -0,0 +1,85 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+func TestByteBufferPutFlipGet(t *testing.T) {
+	Load_Nio_ByteBuffer()
+	bb := "java/nio/ByteBuffer"
+	buf := callNative(t, bb+".allocate(I)Ljava/nio/ByteBuffer;", int64(16)).(int64)
+	callNative(t, bb+".putInt(I)Ljava/nio/ByteBuffer;", buf, int64(0x01020304))
+	callNative(t, bb+".put(B)Ljava/nio/ByteBuffer;", buf, int64(-1))
+	if callNative(t, bb+".position()I", buf).(int64) != 5 {
+		t.Errorf("Expected position of 5 after puts")
+	}
+
+	callNative(t, "java/nio/Buffer.flip()Ljava/nio/Buffer;", buf)
+	if callNative(t, bb+".remaining()I", buf).(int64) != 5 {
+		t.Errorf("Expected 5 bytes remaining after flip()")
+	}
+	if callNative(t, bb+".get()B", buf).(int64) != 1 {
+		t.Errorf("Expected big-endian int, with high byte first")
+	}
+	if callNative(t, bb+".get(I)B", buf, int64(4)).(int64) != -1 {
+		t.Errorf("Expected absolute get() to return -1")
+	}
+	if err, _ := callNative(t, bb+".getLong()J", buf).(error); err == nil ||
+		err.Error() != "java.nio.BufferUnderflowException" {
+		t.Errorf("Expected underflowing getLong() to throw BufferUnderflowException, got %v", err)
+	}
+	if callNative(t, bb+".position()I", buf).(int64) != 1 {
+		t.Errorf("Expected underflowing get not to move the position")
+	}
+
+	array := callNative(t, bb+".array()[B", buf).(int64)
+	if b, _ := ByteArrayFromRef(array); b[3] != 4 {
+		t.Errorf("Expected backing array to hold the buffer's contents")
+	}
+}
+
+func TestByteBufferExceptions(t *testing.T) {
+	Load_Nio_ByteBuffer()
+	bb := "java/nio/ByteBuffer"
+	expect := func(what string, result interface{}, exception string) {
+		if err, _ := result.(error); err == nil || err.Error() != exception {
+			t.Errorf("Expected %s to throw %s, got %v", what, exception, result)
+		}
+	}
+	expect("allocate(-1)", callNative(t, bb+".allocate(I)Ljava/nio/ByteBuffer;", int64(-1)),
+		"java.lang.IllegalArgumentException: capacity < 0: (-1 < 0)")
+
+	buf := callNative(t, bb+".allocate(I)Ljava/nio/ByteBuffer;", int64(4)).(int64)
+	callNative(t, bb+".putInt(I)Ljava/nio/ByteBuffer;", buf, int64(7))
+	expect("an overflowing put()", callNative(t, bb+".put(B)Ljava/nio/ByteBuffer;", buf, int64(1)),
+		"java.nio.BufferOverflowException")
+	expect("get(4)", callNative(t, bb+".get(I)B", buf, int64(4)),
+		"java.lang.IndexOutOfBoundsException: Index 4 out of bounds for length 4")
+	expect("position(5)", callNative(t, bb+".position(I)Ljava/nio/ByteBuffer;", buf, int64(5)),
+		"java.lang.IllegalArgumentException: newPosition > limit: (5 > 4)")
+	expect("limit(-1)", callNative(t, bb+".limit(I)Ljava/nio/ByteBuffer;", buf, int64(-1)),
+		"java.lang.IllegalArgumentException: newLimit < 0: (-1 < 0)")
+	expect("reset() without a mark", callNative(t, bb+".reset()Ljava/nio/ByteBuffer;", buf),
+		"java.nio.InvalidMarkException")
+
+	direct := callNative(t, bb+".allocateDirect(I)Ljava/nio/ByteBuffer;", int64(4)).(int64)
+	expect("array() of a direct buffer", callNative(t, bb+".array()[B", direct),
+		"java.lang.UnsupportedOperationException")
+}
+
+func TestDirectByteBufferSharesNativeMemory(t *testing.T) {
+	Load_Nio_ByteBuffer()
+	buf := callNative(t, "java/nio/ByteBuffer.allocateDirect(I)Ljava/nio/ByteBuffer;", int64(8)).(int64)
+	if callNative(t, "java/nio/ByteBuffer.isDirect()Z", buf).(int64) != 1 {
+		t.Errorf("Expected allocateDirect() to create a direct buffer")
+	}
+	callNative(t, "java/nio/ByteBuffer.putLong(J)Ljava/nio/ByteBuffer;", buf, int64(42))
+	mem, ok := NativeSlice(bufferOf(buf).address, 8)
+	if !ok || mem[7] != 42 {
+		t.Errorf("Expected direct buffer's contents in native memory, got: %v", mem)
+	}
+}
@@ -0,0 +1,254 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"encoding/binary"
+	"errors"
+	"jacobin/globals"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Charsets for converting between Strings and bytes: new String(byte[], charset),
+// String.getBytes(charset), and java.nio.charset.Charset. The standard charsets that
+// every JVM must support (JDK's StandardCharsets) are implemented here, using Go's
+// unicode packages. As in the JDK's String methods, malformed input is decoded as the
+// replacement character U+FFFD, and characters that a charset can't encode become '?'.
+//
+// Each charset has a single Charset object, so that (as in the JDK) charsets can be
+// compared by identity.
+
+type charset struct {
+	name    string
+	aliases []string
+	encode  func(s string) []byte
+	decode  func(b []byte) string
+}
+
+var charsets = []*charset{
+	{name: "UTF-8", aliases: []string{"UTF8", "unicode-1-1-utf-8"},
+		encode: encodeUTF8, decode: decodeUTF8},
+	{name: "ISO-8859-1", aliases: []string{"ISO8859_1", "ISO8859-1", "ISO-LATIN-1", "latin1", "l1",
+		"8859_1", "cp819", "IBM819", "csISOLatin1", "ISO_8859-1"},
+		encode: func(s string) []byte { return encodeSingleByte(s, 0xFF) }, decode: decodeLatin1},
+	{name: "US-ASCII", aliases: []string{"ASCII", "ascii7", "646", "iso-ir-6", "us", "csASCII",
+		"ISO646-US", "default"},
+		encode: func(s string) []byte { return encodeSingleByte(s, 0x7F) }, decode: decodeASCII},
+	{name: "UTF-16", aliases: []string{"UTF_16", "utf16", "unicode", "UnicodeBig"},
+		encode: func(s string) []byte { return encodeUTF16(s, binary.BigEndian, true) },
+		decode: decodeUTF16WithBOM},
+	{name: "UTF-16BE", aliases: []string{"UTF_16BE", "ISO-10646-UCS-2", "X-UTF-16BE", "UnicodeBigUnmarked"},
+		encode: func(s string) []byte { return encodeUTF16(s, binary.BigEndian, false) },
+		decode: func(b []byte) string { return decodeUTF16(b, binary.BigEndian) }},
+	{name: "UTF-16LE", aliases: []string{"UTF_16LE", "X-UTF-16LE", "UnicodeLittleUnmarked"},
+		encode: func(s string) []byte { return encodeUTF16(s, binary.LittleEndian, false) },
+		decode: func(b []byte) string { return decodeUTF16(b, binary.LittleEndian) }},
+}
+
+// lookupCharset finds the charset by its name or any of its aliases, ignoring case
+func lookupCharset(name string) *charset {
+	for _, cs := range charsets {
+		if strings.EqualFold(name, cs.name) {
+			return cs
+		}
+		for _, alias := range cs.aliases {
+			if strings.EqualFold(name, alias) {
+				return cs
+			}
+		}
+	}
+	return nil
+}
+
+// checkCharsetName throws the exception the JDK does for a null or illegal charset
+// name. A legal name begins with a letter or digit, which can be followed by letters,
+// digits, and the characters - + : _ .
+func checkCharsetName(ref int64) (string, error) {
+	name, ok := GoStringFromRef(ref)
+	if !ok {
+		return "", errors.New("java.lang.IllegalArgumentException: Null charset name")
+	}
+	illegal := name == ""
+	for i, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			i > 0 && strings.ContainsRune("-+:_.", c)) {
+			illegal = true
+		}
+	}
+	if illegal {
+		return "", errors.New("java.nio.charset.IllegalCharsetNameException: " + name)
+	}
+	return name, nil
+}
+
+// defaultCharset is the charset named by the file.encoding property, or UTF-8
+func defaultCharset() *charset {
+	if name, present := globals.SystemProperties.Get("file.encoding"); present {
+		if cs := lookupCharset(name); cs != nil {
+			return cs
+		}
+	}
+	return charsets[0]
+}
+
+func encodeUTF8(s string) []byte {
+	return []byte(strings.ToValidUTF8(s, "?"))
+}
+
+func decodeUTF8(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	var sb strings.Builder
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b) // RuneError, size 1, for each malformed byte
+		sb.WriteRune(r)
+		b = b[size:]
+	}
+	return sb.String()
+}
+
+// encodeSingleByte encodes the characters up to max as themselves, and others as '?'
+func encodeSingleByte(s string, max rune) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > max {
+			r = '?'
+		}
+		b = append(b, byte(r))
+	}
+	return b
+}
+
+func decodeLatin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func decodeASCII(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		if c > 0x7F {
+			runes[i] = utf8.RuneError
+		} else {
+			runes[i] = rune(c)
+		}
+	}
+	return string(runes)
+}
+
+// encodeUTF16 encodes the string as UTF-16 in the given byte order, preceded by a
+// byte-order mark if bom is set (as the JDK's UTF-16 encoder does).
+func encodeUTF16(s string, order binary.ByteOrder, bom bool) []byte {
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	b := make([]byte, 2*len(units))
+	for i, u := range units {
+		order.PutUint16(b[2*i:], u)
+	}
+	return b
+}
+
+// decodeUTF16 decodes UTF-16 in the given byte order. A trailing odd byte is malformed.
+func decodeUTF16(b []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = order.Uint16(b[2*i:])
+	}
+	s := string(utf16.Decode(units))
+	if len(b)%2 != 0 {
+		s += string(utf8.RuneError)
+	}
+	return s
+}
+
+// decodeUTF16WithBOM decodes UTF-16 in the byte order given by the byte-order mark,
+// which is dropped. Without a mark, the bytes are big-endian.
+func decodeUTF16WithBOM(b []byte) string {
+	if len(b) >= 2 {
+		switch {
+		case b[0] == 0xFE && b[1] == 0xFF:
+			return decodeUTF16(b[2:], binary.BigEndian)
+		case b[0] == 0xFF && b[1] == 0xFE:
+			return decodeUTF16(b[2:], binary.LittleEndian)
+		}
+	}
+	return decodeUTF16(b, binary.BigEndian)
+}
+
+var charsetRefs = make(map[*charset]int64)
+var charsetMutex sync.Mutex
+
+// charsetObject returns the Charset object for the charset
+func charsetObject(cs *charset) int64 {
+	charsetMutex.Lock()
+	defer charsetMutex.Unlock()
+	ref, present := charsetRefs[cs]
+	if !present {
+		ref = NewObject("java/nio/charset/Charset", 0)
+		GetObject(ref).Native = cs
+		charsetRefs[cs] = ref
+	}
+	return ref
+}
+
+// charsetOf returns the charset of the referenced Charset object, or nil
+func charsetOf(ref int64) *charset {
+	if obj := GetObject(ref); obj != nil {
+		if cs, ok := obj.Native.(*charset); ok {
+			return cs
+		}
+	}
+	return nil
+}
+
+// InitCharsetStatics adds the StandardCharsets constants to the statics, so that
+// getstatic finds them already resolved (as InitStdStreams does for System.out, etc.)
+func InitCharsetStatics() {
+	for _, cs := range charsets {
+		name := "java/nio/charset/StandardCharsets." + strings.ReplaceAll(cs.name, "-", "_")
+		if _, present := FindStatic(name); !present {
+			AddStatic(name, Static{Class: 'L', Type: "Ljava/nio/charset/Charset;",
+				ValueInt: charsetObject(cs)})
+		}
+	}
+}
+
+func Load_Nio_Charset() map[string]GMeth {
+	cs := "java/nio/charset/Charset"
+	addNative(cs+".forName(Ljava/lang/String;)L"+cs+";", true, func(nameRef int64) (int64, error) {
+		name, err := checkCharsetName(nameRef)
+		if err != nil {
+			return 0, err
+		}
+		if c := lookupCharset(name); c != nil {
+			return charsetObject(c), nil
+		}
+		return 0, errors.New("java.nio.charset.UnsupportedCharsetException: " + name)
+	})
+	addNative(cs+".isSupported(Ljava/lang/String;)Z", true, func(nameRef int64) (bool, error) {
+		name, err := checkCharsetName(nameRef)
+		return err == nil && lookupCharset(name) != nil, err
+	})
+	addNative(cs+".defaultCharset()L"+cs+";", true, func() int64 {
+		return charsetObject(defaultCharset())
+	})
+	for _, method := range []string{"name", "displayName", "toString"} {
+		addNative(cs+"."+method+"()Ljava/lang/String;", false, func(this int64) int64 {
+			return NewStringObject(charsetOf(this).name)
+		})
+	}
+	return MethodSignatures
+}
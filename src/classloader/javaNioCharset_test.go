@@ -0,0 +1,108 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCharsetRoundTrips(t *testing.T) {
+	text := "héllo, wörld €"
+	for _, name := range []string{"UTF-8", "UTF-16", "UTF-16BE", "UTF-16LE"} {
+		cs := lookupCharset(name)
+		if got := cs.decode(cs.encode(text)); got != text {
+			t.Errorf("Expected %s round trip of %q, got: %q", name, text, got)
+		}
+	}
+
+	latin1 := lookupCharset("latin1")
+	if latin1 == nil || latin1.name != "ISO-8859-1" {
+		t.Fatalf("Expected latin1 to be an alias of ISO-8859-1")
+	}
+	if b := latin1.encode(text); !bytes.Equal(b[:2], []byte{'h', 0xE9}) || b[len(b)-1] != '?' {
+		t.Errorf("Expected é as 0xE9 and € as '?' in ISO-8859-1, got: %v", b)
+	}
+	if s := lookupCharset("ascii").decode([]byte{'a', 0xE9}); s != "a�" {
+		t.Errorf("Expected non-ASCII byte to decode as U+FFFD, got: %q", s)
+	}
+	if s := lookupCharset("utf-8").decode([]byte{'a', 0xFF, 'b'}); s != "a�b" {
+		t.Errorf("Expected malformed UTF-8 to decode as U+FFFD, got: %q", s)
+	}
+	if s := lookupCharset("UTF-16").decode([]byte{0xFF, 0xFE, 'a', 0}); s != "a" {
+		t.Errorf("Expected little-endian BOM to be honored, got: %q", s)
+	}
+	if lookupCharset("EBCDIC-XYZ") != nil {
+		t.Errorf("Expected unknown charset not to be found")
+	}
+}
+
+func TestStringBytesNatives(t *testing.T) {
+	Load_Lang_String()
+	Load_Nio_Charset()
+	InitCharsetStatics()
+
+	index, _ := FindStatic("java/nio/charset/StandardCharsets.UTF_16LE")
+	utf16le := LoadStaticInt(index)
+	forName := callNative(t, "java/nio/charset/Charset.forName(Ljava/lang/String;)Ljava/nio/charset/Charset;",
+		NewStringObject("utf-16le")).(int64)
+	if forName != utf16le {
+		t.Errorf("Expected Charset.forName() to return the StandardCharsets object")
+	}
+	for name, expected := range map[string]string{
+		"EBCDIC-999": "java.nio.charset.UnsupportedCharsetException: EBCDIC-999",
+		"-utf8":      "java.nio.charset.IllegalCharsetNameException: -utf8",
+		"":           "java.nio.charset.IllegalCharsetNameException: ",
+	} {
+		ret := callNative(t, "java/nio/charset/Charset.forName(Ljava/lang/String;)Ljava/nio/charset/Charset;",
+			NewStringObject(name))
+		if err, _ := ret.(error); err == nil || err.Error() != expected {
+			t.Errorf("Expected Charset.forName(%q) to throw %s, got: %v", name, expected, ret)
+		}
+	}
+	if callNative(t, "java/nio/charset/Charset.isSupported(Ljava/lang/String;)Z", NewStringObject("EBCDIC-999")) != int64(0) {
+		t.Errorf("Expected an unknown charset not to be supported")
+	}
+
+	array := callNative(t, "java/lang/String.getBytes(Ljava/nio/charset/Charset;)[B",
+		NewStringObject("ab"), utf16le).(int64)
+	if b, _ := ByteArrayFromRef(array); !bytes.Equal(b, []byte{'a', 0, 'b', 0}) {
+		t.Errorf("Expected UTF-16LE bytes, got: %v", b)
+	}
+
+	str := NewObject("java/lang/String", 0)
+	callNative(t, "java/lang/String.<init>([BIILjava/lang/String;)V", str,
+		NewByteArray([]byte{'x', 0xE9, 'y'}), int64(1), int64(2), NewStringObject("ISO-8859-1"))
+	if s, _ := GoStringFromRef(str); s != "éy" {
+		t.Errorf("Expected \"éy\" from ISO-8859-1 bytes, got: %q", s)
+	}
+	err, _ := callNative(t, "java/lang/String.getBytes(Ljava/lang/String;)[B",
+		NewStringObject("ab"), NewStringObject("EBCDIC-X")).(error)
+	if err == nil || err.Error() != "java.io.UnsupportedEncodingException: EBCDIC-X" {
+		t.Errorf("Expected UnsupportedEncodingException for an unknown charset, got %v", err)
+	}
+	err, _ = callNative(t, "java/lang/String.<init>([BIILjava/lang/String;)V", str,
+		NewByteArray([]byte{'x'}), int64(1), int64(2), NewStringObject("UTF-8")).(error)
+	if err == nil || err.Error() != "java.lang.StringIndexOutOfBoundsException: offset 1, count 2, length 1" {
+		t.Errorf("Expected StringIndexOutOfBoundsException for bytes out of bounds, got %v", err)
+	}
+	err, _ = callNative(t, "java/lang/String.getBytes(Ljava/nio/charset/Charset;)[B",
+		NewStringObject("ab"), int64(0)).(error)
+	if err == nil || err.Error() != "java.lang.NullPointerException" {
+		t.Errorf("Expected NullPointerException for a null charset, got %v", err)
+	}
+	err, _ = callNative(t, "java/lang/String.charAt(I)C", NewStringObject("ab"), int64(2)).(error)
+	if err == nil || err.Error() != "java.lang.StringIndexOutOfBoundsException: Index 2 out of bounds for length 2" {
+		t.Errorf("Expected StringIndexOutOfBoundsException from charAt(2), got %v", err)
+	}
+
+	name := callNative(t, "java/nio/charset/Charset.name()Ljava/lang/String;",
+		callNative(t, "java/nio/charset/Charset.defaultCharset()Ljava/nio/charset/Charset;")).(int64)
+	if s, _ := GoStringFromRef(name); s != "UTF-8" {
+		t.Errorf("Expected default charset of UTF-8, got: %q", s)
+	}
+}
@@ -0,0 +1,280 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileChannels are obtained from FileInputStream, FileOutputStream, and RandomAccessFile
+// via getChannel(). The channel shares the stream's *os.File, so that (as in the JDK)
+// they share the file position, and closing either one closes the file.
+//
+// map() copies the region of the file into a direct buffer. A READ_WRITE mapping keeps
+// its own handle to the file, and the buffer's contents are written back to the file
+// by MappedByteBuffer.force(), and at the latest when the VM shuts down. A PRIVATE
+// mapping is never written back, and a READ_ONLY mapping's buffer is read-only.
+
+const channelClass = "java/nio/channels/FileChannel"
+
+// errChannelClosed is the exception of an operation on a closed channel
+var errChannelClosed = errors.New("java.nio.channels.ClosedChannelException")
+
+// errNegativePosition is the exception of a read, write, or map at a negative position
+var errNegativePosition = errors.New("java.lang.IllegalArgumentException: Negative position")
+
+// the FileChannel.MapMode constants. Each is an object whose Native field is its mapMode.
+type mapMode int
+
+const (
+	mapReadOnly mapMode = iota
+	mapReadWrite
+	mapPrivate
+)
+
+// InitNioStatics adds the static fields of the nio classes implemented in Go, so that
+// getstatic finds them already resolved (as InitStdStreams does for System.out, etc.)
+func InitNioStatics() {
+	modeClass := channelClass + "$MapMode"
+	for name, mode := range map[string]mapMode{
+		"READ_ONLY": mapReadOnly, "READ_WRITE": mapReadWrite, "PRIVATE": mapPrivate} {
+		if _, present := FindStatic(modeClass + "." + name); present {
+			continue
+		}
+		ref := NewObject(modeClass, 0)
+		GetObject(ref).Native = mode
+		AddStatic(modeClass+"."+name, Static{Class: 'L', Type: "L" + modeClass + ";", ValueInt: ref})
+	}
+}
+
+type mappedRegion struct {
+	file   *os.File
+	offset int64
+	buffer *byteBuffer
+}
+
+var mappedRegions []*mappedRegion
+var mappedMutex sync.Mutex
+
+func (m *mappedRegion) writeBack() {
+	_, _ = m.file.WriteAt(m.buffer.data, m.offset)
+}
+
+// FlushMappedBuffers writes the contents of all READ_WRITE mapped buffers back to their files
+func FlushMappedBuffers() {
+	mappedMutex.Lock()
+	defer mappedMutex.Unlock()
+	for _, m := range mappedRegions {
+		m.writeBack()
+	}
+}
+
+// newChannelObject creates a FileChannel that shares the open file of the stream
+func newChannelObject(stream int64) int64 {
+	ref := NewObject("sun/nio/ch/FileChannelImpl", 0)
+	if f := osFileOf(stream); f != nil {
+		GetObject(ref).Native = f
+	}
+	return ref
+}
+
+// channelIO performs a read or write between the file and the remaining bytes of the buffer,
+// at the channel's position (if pos is negative) or at pos, and advances the buffer's position.
+func channelIO(this, buffer int64, pos int64, writing bool) (int32, error) {
+	f := osFileOf(this)
+	if f == nil {
+		return 0, errChannelClosed
+	}
+	if GetObject(buffer) == nil {
+		return 0, errors.New("java.lang.NullPointerException")
+	}
+	bb := bufferOf(buffer)
+	if !writing && bb.readOnly {
+		return 0, errors.New("java.lang.IllegalArgumentException: Read-only buffer")
+	}
+	b := bb.data[bb.position:bb.limit]
+	var n int
+	var err error
+	switch {
+	case writing && pos < 0:
+		n, err = f.Write(b)
+	case writing:
+		n, err = f.WriteAt(b, pos)
+	case pos < 0:
+		n, err = f.Read(b)
+	default:
+		n, err = f.ReadAt(b, pos)
+	}
+	bb.position += n
+	switch {
+	case !writing && n == 0 && err == io.EOF && len(b) > 0:
+		return -1, nil
+	case err != nil && err != io.EOF:
+		return int32(n), ioException(err)
+	}
+	return int32(n), nil
+}
+
+func mapRegion(this, mode, position, size int64) (int64, error) {
+	f := osFileOf(this)
+	modeObj := GetObject(mode)
+	switch {
+	case modeObj == nil:
+		return 0, errors.New("java.lang.NullPointerException: Mode is null")
+	case position < 0:
+		return 0, errNegativePosition
+	case size < 0:
+		return 0, errors.New("java.lang.IllegalArgumentException: Negative size")
+	case f == nil:
+		return 0, errChannelClosed
+	}
+	modeVal, _ := modeObj.Native.(mapMode)
+
+	// as in the JDK, a writable mapping beyond the end of the file extends the file
+	if info, err := f.Stat(); err == nil && modeVal != mapReadOnly && info.Size() < position+size {
+		if err := f.Truncate(position + size); err != nil {
+			return 0, ioException(err)
+		}
+	}
+
+	ref := NewDirectByteBuffer(int(size))
+	obj := GetObject(ref)
+	obj.Klass = "java/nio/DirectByteBuffer" // which is a MappedByteBuffer
+	bb := obj.Native.(*byteBuffer)
+	if _, err := f.ReadAt(bb.data, position); err != nil && err != io.EOF {
+		return 0, ioException(err)
+	}
+
+	switch modeVal {
+	case mapReadOnly:
+		bb.readOnly = true
+	case mapReadWrite:
+		own, err := os.OpenFile(f.Name(), os.O_RDWR, 0)
+		if err != nil {
+			return 0, errors.New("java.nio.channels.NonWritableChannelException")
+		}
+		mappedMutex.Lock()
+		mappedRegions = append(mappedRegions, &mappedRegion{file: own, offset: position, buffer: bb})
+		mappedMutex.Unlock()
+	}
+	return ref, nil
+}
+
+func Load_Nio_FileChannel() map[string]GMeth {
+	desc := "L" + channelClass + ";"
+	for _, stream := range []string{"java/io/FileInputStream", "java/io/FileOutputStream",
+		"java/io/RandomAccessFile"} {
+		addNative(stream+".getChannel()"+desc, false, newChannelObject)
+	}
+
+	addNative(channelClass+".read(Ljava/nio/ByteBuffer;)I", false, func(this, buffer int64) (int32, error) {
+		return channelIO(this, buffer, -1, false)
+	})
+	addNative(channelClass+".read(Ljava/nio/ByteBuffer;J)I", false, func(this, buffer, pos int64) (int32, error) {
+		if pos < 0 {
+			return 0, errNegativePosition
+		}
+		return channelIO(this, buffer, pos, false)
+	})
+	addNative(channelClass+".write(Ljava/nio/ByteBuffer;)I", false, func(this, buffer int64) (int32, error) {
+		return channelIO(this, buffer, -1, true)
+	})
+	addNative(channelClass+".write(Ljava/nio/ByteBuffer;J)I", false, func(this, buffer, pos int64) (int32, error) {
+		if pos < 0 {
+			return 0, errNegativePosition
+		}
+		return channelIO(this, buffer, pos, true)
+	})
+	addNative(channelClass+".position()J", false, func(this int64) (int64, error) {
+		f := osFileOf(this)
+		if f == nil {
+			return 0, errChannelClosed
+		}
+		pos, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, ioException(err)
+		}
+		return pos, nil
+	})
+	addNative(channelClass+".position(J)"+desc, false, func(this, pos int64) (int64, error) {
+		f := osFileOf(this)
+		switch {
+		case pos < 0:
+			return 0, errors.New("java.lang.IllegalArgumentException")
+		case f == nil:
+			return 0, errChannelClosed
+		}
+		if _, err := f.Seek(pos, io.SeekStart); err != nil {
+			return 0, ioException(err)
+		}
+		return this, nil
+	})
+	addNative(channelClass+".size()J", false, func(this int64) (int64, error) {
+		f := osFileOf(this)
+		if f == nil {
+			return 0, errChannelClosed
+		}
+		info, err := f.Stat()
+		if err != nil {
+			return 0, ioException(err)
+		}
+		return info.Size(), nil
+	})
+	addNative(channelClass+".truncate(J)"+desc, false, func(this, size int64) (int64, error) {
+		f := osFileOf(this)
+		switch {
+		case size < 0:
+			return 0, errors.New("java.lang.IllegalArgumentException: Negative size")
+		case f == nil:
+			return 0, errChannelClosed
+		}
+		if info, err := f.Stat(); err == nil && size < info.Size() {
+			if err = f.Truncate(size); err != nil {
+				return 0, ioException(err)
+			}
+		}
+		if pos, _ := f.Seek(0, io.SeekCurrent); pos > size {
+			_, _ = f.Seek(size, io.SeekStart)
+		}
+		return this, nil
+	})
+	addNative(channelClass+".force(Z)V", false, func(this int64, metaData bool) error {
+		f := osFileOf(this)
+		if f == nil {
+			return errChannelClosed
+		}
+		if err := f.Sync(); err != nil {
+			return ioException(err)
+		}
+		return nil
+	})
+	addNative(channelClass+".isOpen()Z", false, func(this int64) bool {
+		return osFileOf(this) != nil
+	})
+	addNative(channelClass+".close()V", false, closeFileObject)
+	addNative(channelClass+".map(L"+channelClass+"$MapMode;JJ)Ljava/nio/MappedByteBuffer;", false, mapRegion)
+
+	addNative("java/nio/MappedByteBuffer.force()Ljava/nio/MappedByteBuffer;", false, func(this int64) int64 {
+		bb := bufferOf(this)
+		mappedMutex.Lock()
+		defer mappedMutex.Unlock()
+		for _, m := range mappedRegions {
+			if m.buffer == bb {
+				m.writeBack()
+				_ = m.file.Sync()
+			}
+		}
+		return this
+	})
+	addNative("java/nio/MappedByteBuffer.isLoaded()Z", false, func(this int64) bool {
+		return true // the contents are always in memory
+	})
+	return MethodSignatures
+}
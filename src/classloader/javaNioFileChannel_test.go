@@ -0,0 +1,78 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileChannelReadWriteAndMap(t *testing.T) {
+	Load_Io_FileStreams()
+	Load_Nio_ByteBuffer()
+	Load_Nio_FileChannel()
+	InitNioStatics()
+	dir, err := ioutil.TempDir("", "jacobin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "chan.bin")
+
+	raf := NewObject("java/io/RandomAccessFile", 0)
+	callNative(t, "java/io/RandomAccessFile.<init>(Ljava/lang/String;Ljava/lang/String;)V",
+		raf, NewStringObject(path), NewStringObject("rw"))
+	ch := callNative(t, "java/io/RandomAccessFile.getChannel()Ljava/nio/channels/FileChannel;", raf).(int64)
+
+	buf := NewHeapByteBuffer(NewByteArray([]byte("hello, world")))
+	if callNative(t, channelClass+".write(Ljava/nio/ByteBuffer;)I", ch, buf).(int64) != 12 {
+		t.Errorf("Expected to write 12 bytes")
+	}
+	if callNative(t, channelClass+".size()J", ch).(int64) != 12 {
+		t.Errorf("Expected channel size of 12")
+	}
+
+	in := NewHeapByteBuffer(NewByteArray(make([]byte, 5)))
+	callNative(t, channelClass+".read(Ljava/nio/ByteBuffer;J)I", ch, in, int64(7))
+	if string(bufferOf(in).data) != "world" {
+		t.Errorf("Expected positional read of \"world\", got: %q", bufferOf(in).data)
+	}
+	if callNative(t, channelClass+".read(Ljava/nio/ByteBuffer;)I", ch, NewHeapByteBuffer(NewByteArray(make([]byte, 1)))).(int64) != -1 {
+		t.Errorf("Expected -1 reading at end of file")
+	}
+
+	index, _ := FindStatic(channelClass + "$MapMode.READ_WRITE")
+	readWrite := LoadStaticInt(index)
+	mapped := callNative(t, channelClass+".map(L"+channelClass+"$MapMode;JJ)Ljava/nio/MappedByteBuffer;",
+		ch, readWrite, int64(0), int64(5)).(int64)
+	bufferOf(mapped).data[0] = 'J'
+	callNative(t, "java/nio/MappedByteBuffer.force()Ljava/nio/MappedByteBuffer;", mapped)
+	callNative(t, channelClass+".close()V", ch)
+
+	contents, _ := ioutil.ReadFile(path)
+	if string(contents) != "Jello, world" {
+		t.Errorf("Expected forced mapping to update the file, got: %q", contents)
+	}
+
+	for method, args := range map[string][]interface{}{
+		".write(Ljava/nio/ByteBuffer;)I":     {ch, buf},
+		".size()J":                           {ch},
+		".position(J)L" + channelClass + ";": {ch, int64(0)},
+		".map(L" + channelClass + "$MapMode;JJ)Ljava/nio/MappedByteBuffer;": {ch, readWrite, int64(0), int64(1)},
+	} {
+		err, _ := callNative(t, channelClass+method, args...).(error)
+		if err == nil || err.Error() != "java.nio.channels.ClosedChannelException" {
+			t.Errorf("Expected %s of a closed channel to throw ClosedChannelException, got %v", method, err)
+		}
+	}
+	err, _ = callNative(t, channelClass+".read(Ljava/nio/ByteBuffer;J)I", ch, in, int64(-1)).(error)
+	if err == nil || err.Error() != "java.lang.IllegalArgumentException: Negative position" {
+		t.Errorf("Expected a read at a negative position to throw IllegalArgumentException, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "sync"
+
+// java.util.concurrent.CompletableFuture, implemented in Go so that natives (such as
+// ProcessHandle.onExit()) can return futures that Go code completes. Waiting for and
+// completing a future are supported; the methods that take callbacks (thenApply(),
+// etc.) need invokeinterface and aren't implemented yet.
+
+type completableFuture struct {
+	done  chan struct{} // closed when the future is completed
+	once  sync.Once
+	value int64
+}
+
+// NewFutureObject creates an incomplete CompletableFuture and returns the reference to
+// it and its Go-side state, which is completed with complete()
+func NewFutureObject() (int64, *completableFuture) {
+	ref := NewObject("java/util/concurrent/CompletableFuture", 0)
+	f := &completableFuture{done: make(chan struct{})}
+	GetObject(ref).Native = f
+	return ref, f
+}
+
+// complete sets the future's value and wakes up any threads waiting for it. It
+// returns false if the future was already complete.
+func (f *completableFuture) complete(value int64) bool {
+	completed := false
+	f.once.Do(func() {
+		f.value = value
+		close(f.done)
+		completed = true
+	})
+	return completed
+}
+
+func (f *completableFuture) isDone() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func futureOf(ref int64) *completableFuture {
+	if obj := GetObject(ref); obj != nil {
+		if f, ok := obj.Native.(*completableFuture); ok {
+			return f
+		}
+	}
+	return nil
+}
+
+func Load_Util_CompletableFuture() map[string]GMeth {
+	cf := "java/util/concurrent/CompletableFuture"
+	addNative(cf+".<init>()V", false, func(this int64) {
+		GetObject(this).Native = &completableFuture{done: make(chan struct{})}
+	})
+	addNative(cf+".completedFuture(Ljava/lang/Object;)L"+cf+";", true, func(value int64) int64 {
+		ref, f := NewFutureObject()
+		f.complete(value)
+		return ref
+	})
+	for _, method := range []string{"get", "join"} {
+		addNative(cf+"."+method+"()Ljava/lang/Object;", false, func(this int64) int64 {
+			f := futureOf(this)
+			<-f.done
+			return f.value
+		})
+	}
+	addNative(cf+".getNow(Ljava/lang/Object;)Ljava/lang/Object;", false, func(this, absent int64) int64 {
+		if f := futureOf(this); f.isDone() {
+			return f.value
+		}
+		return absent
+	})
+	addNative(cf+".isDone()Z", false, func(this int64) bool {
+		return futureOf(this).isDone()
+	})
+	addNative(cf+".complete(Ljava/lang/Object;)Z", false, func(this, value int64) bool {
+		return futureOf(this).complete(value)
+	})
+	return MethodSignatures
+}
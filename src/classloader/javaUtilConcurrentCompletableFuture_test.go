@@ -0,0 +1,39 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+func TestCompletableFuture(t *testing.T) {
+	Load_Util_CompletableFuture()
+	cf := "java/util/concurrent/CompletableFuture"
+	value, absent := NewStringObject("done"), NewStringObject("absent")
+
+	ref, f := NewFutureObject()
+	if callNative(t, cf+".isDone()Z", ref) != int64(0) {
+		t.Error("Expected a new future not to be done")
+	}
+	if callNative(t, cf+".getNow(Ljava/lang/Object;)Ljava/lang/Object;", ref, absent) != absent {
+		t.Error("Expected getNow() of an incomplete future to return the absent value")
+	}
+
+	go f.complete(value)
+	if callNative(t, cf+".get()Ljava/lang/Object;", ref) != value {
+		t.Error("Expected get() to wait for the value")
+	}
+	if callNative(t, cf+".complete(Ljava/lang/Object;)Z", ref, absent) != int64(0) {
+		t.Error("Expected complete() of a completed future to return false")
+	}
+	if callNative(t, cf+".join()Ljava/lang/Object;", ref) != value {
+		t.Error("Expected a future's value not to change once completed")
+	}
+
+	done := callNative(t, cf+".completedFuture(Ljava/lang/Object;)L"+cf+";", value).(int64)
+	if callNative(t, cf+".isDone()Z", done) != int64(1) {
+		t.Error("Expected completedFuture() to return a completed future")
+	}
+}
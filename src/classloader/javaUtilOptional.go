@@ -0,0 +1,93 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"sync"
+)
+
+// java.util.Optional, implemented in Go so that natives can return Optionals
+// (as SymbolLookup.find() does) without loading and initializing the class. The
+// object's Native field holds the reference to the value; 0 means empty. Methods
+// that take functional interfaces (map(), ifPresent(), etc.) require invokeinterface
+// and aren't implemented yet.
+
+type optional struct {
+	value int64
+}
+
+var emptyOptional int64
+var emptyOnce sync.Once
+
+// NewOptional returns an Optional holding the referenced value, or the empty
+// Optional if the reference is null.
+func NewOptional(value int64) int64 {
+	if value == 0 {
+		emptyOnce.Do(func() {
+			emptyOptional = NewObject("java/util/Optional", 0)
+			GetObject(emptyOptional).Native = &optional{}
+		})
+		return emptyOptional
+	}
+	ref := NewObject("java/util/Optional", 0)
+	GetObject(ref).Native = &optional{value: value}
+	return ref
+}
+
+// optionalValue returns the value held by the referenced Optional, or 0 if it's empty
+func optionalValue(ref int64) int64 {
+	if obj := GetObject(ref); obj != nil {
+		if opt, ok := obj.Native.(*optional); ok {
+			return opt.value
+		}
+	}
+	return 0
+}
+
+func Load_Util_Optional() map[string]GMeth {
+	opt := "java/util/Optional"
+	addNative(opt+".empty()L"+opt+";", true, func() int64 {
+		return NewOptional(0)
+	})
+	addNative(opt+".of(Ljava/lang/Object;)L"+opt+";", true, func(value int64) (int64, error) {
+		if value == 0 {
+			return 0, errors.New("java.lang.NullPointerException")
+		}
+		return NewOptional(value), nil
+	})
+	addNative(opt+".ofNullable(Ljava/lang/Object;)L"+opt+";", true, func(value int64) int64 {
+		return NewOptional(value)
+	})
+	addNative(opt+".isPresent()Z", false, func(this int64) bool {
+		return optionalValue(this) != 0
+	})
+	addNative(opt+".isEmpty()Z", false, func(this int64) bool {
+		return optionalValue(this) == 0
+	})
+	for _, method := range []string{"get", "orElseThrow"} {
+		addNative(opt+"."+method+"()Ljava/lang/Object;", false, func(this int64) (int64, error) {
+			if value := optionalValue(this); value != 0 {
+				return value, nil
+			}
+			return 0, errors.New("java.util.NoSuchElementException: No value present")
+		})
+	}
+	addNative(opt+".orElse(Ljava/lang/Object;)Ljava/lang/Object;", false, func(this, other int64) int64 {
+		if value := optionalValue(this); value != 0 {
+			return value
+		}
+		return other
+	})
+	addNative(opt+".toString()Ljava/lang/String;", false, func(this int64) int64 {
+		if value := optionalValue(this); value != 0 {
+			return NewStringObject("Optional[" + ObjectToString(value) + "]")
+		}
+		return NewStringObject("Optional.empty")
+	})
+	return MethodSignatures
+}
@@ -0,0 +1,45 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+func TestOptional(t *testing.T) {
+	Load_Util_Optional()
+	s := NewStringObject("x")
+	full := callNative(t, "java/util/Optional.of(Ljava/lang/Object;)Ljava/util/Optional;", s).(int64)
+	empty := callNative(t, "java/util/Optional.ofNullable(Ljava/lang/Object;)Ljava/util/Optional;", int64(0)).(int64)
+
+	if empty != callNative(t, "java/util/Optional.empty()Ljava/util/Optional;").(int64) {
+		t.Error("Expected a single empty Optional")
+	}
+	if callNative(t, "java/util/Optional.isPresent()Z", full) != int64(1) ||
+		callNative(t, "java/util/Optional.isEmpty()Z", empty) != int64(1) {
+		t.Error("Unexpected isPresent()/isEmpty() results")
+	}
+	if callNative(t, "java/util/Optional.get()Ljava/lang/Object;", full) != s {
+		t.Error("Expected get() to return the value")
+	}
+	for _, method := range []string{"get", "orElseThrow"} {
+		err, _ := callNative(t, "java/util/Optional."+method+"()Ljava/lang/Object;", empty).(error)
+		if err == nil || err.Error() != "java.util.NoSuchElementException: No value present" {
+			t.Errorf("Expected %s() of an empty Optional to throw NoSuchElementException, got %v", method, err)
+		}
+	}
+	err, _ := callNative(t, "java/util/Optional.of(Ljava/lang/Object;)Ljava/util/Optional;", int64(0)).(error)
+	if err == nil || err.Error() != "java.lang.NullPointerException" {
+		t.Errorf("Expected of(null) to throw NullPointerException, got %v", err)
+	}
+	other := NewStringObject("y")
+	if callNative(t, "java/util/Optional.orElse(Ljava/lang/Object;)Ljava/lang/Object;", empty, other) != other {
+		t.Error("Expected orElse() of an empty Optional to return the other value")
+	}
+	str := callNative(t, "java/util/Optional.toString()Ljava/lang/String;", full).(int64)
+	if javaString(str) != "Optional[x]" {
+		t.Errorf("Expected Optional[x], got %s", javaString(str))
+	}
+}
@@ -0,0 +1,61 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "jacobin/globals"
+
+// java.util.Properties objects are implemented in Go: the object's Native field holds
+// a *globals.Properties. The object returned by System.getProperties() shares the
+// system property store, so changes made through it are seen by System.getProperty(),
+// as in the JDK.
+
+// NewPropertiesObject creates a java.util.Properties object backed by the given store
+func NewPropertiesObject(store *globals.Properties) int64 {
+	ref := NewObject("java/util/Properties", 0)
+	GetObject(ref).Native = store
+	return ref
+}
+
+// propertiesOf returns the store behind a Properties object, creating it if the
+// object was allocated by new but not yet initialized.
+func propertiesOf(ref int64) *globals.Properties {
+	obj := GetObject(ref)
+	if obj == nil {
+		return globals.NewProperties()
+	}
+	store, ok := obj.Native.(*globals.Properties)
+	if !ok {
+		store = globals.NewProperties()
+		obj.Native = store
+	}
+	return store
+}
+
+func Load_Util_Properties() map[string]GMeth {
+	addNative("java/util/Properties.<init>()V", false, func(this int64) {
+		GetObject(this).Native = globals.NewProperties()
+	})
+	addNative("java/util/Properties.getProperty(Ljava/lang/String;)Ljava/lang/String;", false,
+		func(this, key int64) int64 {
+			return propertyRef(propertiesOf(this).Get(javaString(key)))
+		})
+	addNative("java/util/Properties.getProperty(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/String;", false,
+		func(this, key, def int64) int64 {
+			if val, present := propertiesOf(this).Get(javaString(key)); present {
+				return NewStringObject(val)
+			}
+			return def
+		})
+	addNative("java/util/Properties.setProperty(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/Object;", false,
+		func(this, key, val int64) int64 {
+			return propertyRef(propertiesOf(this).Set(javaString(key), javaString(val)))
+		})
+	addNative("java/util/Properties.size()I", false, func(this int64) int32 {
+		return int32(len(propertiesOf(this).Keys()))
+	})
+	return MethodSignatures
+}
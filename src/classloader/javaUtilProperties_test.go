@@ -0,0 +1,66 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/globals"
+	"testing"
+)
+
+func TestSystemGetAndSetProperty(t *testing.T) {
+	globals.InitGlobals("test")
+	Load_Lang_System()
+
+	ref := callNative(t, "java/lang/System.getProperty(Ljava/lang/String;)Ljava/lang/String;",
+		NewStringObject("java.version")).(int64)
+	if s, _ := GoStringFromRef(ref); s != "11.0.10" {
+		t.Errorf("Expected java.version of 11.0.10, got: %q", s)
+	}
+
+	ref = callNative(t, "java/lang/System.getProperty(Ljava/lang/String;)Ljava/lang/String;",
+		NewStringObject("no.such.property")).(int64)
+	if ref != 0 {
+		t.Errorf("Expected null for missing property, got ref: %d", ref)
+	}
+
+	def := NewStringObject("fallback")
+	ref = callNative(t, "java/lang/System.getProperty(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/String;",
+		NewStringObject("no.such.property"), def).(int64)
+	if ref != def {
+		t.Errorf("Expected default value to be returned for missing property")
+	}
+
+	callNative(t, "java/lang/System.setProperty(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/String;",
+		NewStringObject("jacobin.test"), NewStringObject("yes"))
+	if val, _ := globals.SystemProperties.Get("jacobin.test"); val != "yes" {
+		t.Errorf("Expected setProperty() to update system properties, got: %q", val)
+	}
+}
+
+func TestSystemPropertiesObjectIsLive(t *testing.T) {
+	globals.InitGlobals("test")
+	Load_Lang_System()
+	Load_Util_Properties()
+
+	props := callNative(t, "java/lang/System.getProperties()Ljava/util/Properties;").(int64)
+	callNative(t, "java/util/Properties.setProperty(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/Object;",
+		props, NewStringObject("jacobin.live"), NewStringObject("1"))
+	if val, _ := globals.SystemProperties.Get("jacobin.live"); val != "1" {
+		t.Errorf("Expected change via getProperties() to be visible, got: %q", val)
+	}
+
+	local := NewObject("java/util/Properties", 0)
+	callNative(t, "java/util/Properties.<init>()V", local)
+	callNative(t, "java/util/Properties.setProperty(Ljava/lang/String;Ljava/lang/String;)Ljava/lang/Object;",
+		local, NewStringObject("k"), NewStringObject("v"))
+	if callNative(t, "java/util/Properties.size()I", local).(int64) != 1 {
+		t.Errorf("Expected new Properties object to hold one property")
+	}
+	if _, present := globals.SystemProperties.Get("k"); present {
+		t.Errorf("Expected new Properties object not to share the system properties")
+	}
+}
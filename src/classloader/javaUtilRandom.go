@@ -0,0 +1,450 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"jacobin/globals"
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// java.util.Random, SecureRandom, SplittableRandom, and UUID.randomUUID(). Random and
+// SplittableRandom use exactly the JDK's algorithms, so that a given seed produces the
+// same sequence of values as on any other JVM. The seeds of unseeded instances are
+// derived as the JDK derives them. SecureRandom draws from the OS's entropy source, via
+// crypto/rand.
+//
+// Random's methods are all defined in terms of next(bits), so SecureRandom uses the same
+// methods with a different source of bits, as it does in the JDK.
+
+type randomSource interface {
+	next(bits uint) int32
+	nextBytes(b []byte)
+}
+
+// ---- java.util.Random: a 48-bit linear congruential generator ----
+
+const (
+	lcgMultiplier = 0x5DEECE66D
+	lcgAddend     = 0xB
+	lcgMask       = (1 << 48) - 1
+)
+
+type javaRandom struct {
+	mutex                sync.Mutex
+	seed                 int64
+	nextNextGaussian     float64
+	haveNextNextGaussian bool
+}
+
+// seedUniquifier makes the seeds of Randoms created at the same time differ
+var seedUniquifier int64 = 8682522807148012
+
+func newSeedUniquifier() int64 {
+	for {
+		current := atomic.LoadInt64(&seedUniquifier)
+		next := current * 1181783497276652981
+		if atomic.CompareAndSwapInt64(&seedUniquifier, current, next) {
+			return next
+		}
+	}
+}
+
+func newJavaRandom(seed int64) *javaRandom {
+	r := &javaRandom{}
+	r.setSeed(seed)
+	return r
+}
+
+func (r *javaRandom) setSeed(seed int64) {
+	r.mutex.Lock()
+	r.seed = (seed ^ lcgMultiplier) & lcgMask
+	r.haveNextNextGaussian = false
+	r.mutex.Unlock()
+}
+
+func (r *javaRandom) next(bits uint) int32 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.seed = (r.seed*lcgMultiplier + lcgAddend) & lcgMask
+	return int32(uint64(r.seed) >> (48 - bits))
+}
+
+func (r *javaRandom) nextBytes(b []byte) {
+	for i := 0; i < len(b); {
+		rnd := r.next(32)
+		for n := 0; n < 4 && i < len(b); n++ {
+			b[i] = byte(rnd)
+			rnd >>= 8
+			i++
+		}
+	}
+}
+
+// ---- java.security.SecureRandom ----
+
+type secureRandom struct{}
+
+func (secureRandom) nextBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic("cannot read from the OS entropy source: " + err.Error())
+	}
+}
+
+// next returns the given number of random bits, as SecureRandom.next() does
+func (s secureRandom) next(numBits uint) int32 {
+	numBytes := (numBits + 7) / 8
+	b := make([]byte, numBytes)
+	s.nextBytes(b)
+	var next int32
+	for _, c := range b {
+		next = next<<8 + int32(c)
+	}
+	return int32(uint32(next) >> (numBytes*8 - numBits))
+}
+
+// the algorithms of Random's methods, in terms of next(bits)
+
+func randomNextInt(r randomSource) int32 {
+	return r.next(32)
+}
+
+// errBadBound is the exception of nextInt(bound) for a bound that isn't positive
+var errBadBound = errors.New("java.lang.IllegalArgumentException: bound must be positive")
+
+func randomNextIntBounded(r randomSource, bound int32) (int32, error) {
+	if bound <= 0 {
+		return 0, errBadBound
+	}
+	rnd := r.next(31)
+	m := bound - 1
+	if bound&m == 0 { // a power of 2
+		return int32((int64(bound) * int64(rnd)) >> 31), nil
+	}
+	for u := rnd; ; u = r.next(31) {
+		rnd = u % bound
+		if u-rnd+m >= 0 { // overflow means u fell in the incomplete last range
+			return rnd, nil
+		}
+	}
+}
+
+func randomNextLong(r randomSource) int64 {
+	return int64(r.next(32))<<32 + int64(r.next(32))
+}
+
+func randomNextDouble(r randomSource) float64 {
+	return float64(int64(r.next(26))<<27+int64(r.next(27))) * 0x1.0p-53
+}
+
+func randomNextFloat(r randomSource) float32 {
+	return float32(r.next(24)) / float32(1<<24)
+}
+
+func randomNextGaussian(r randomSource, jr *javaRandom) float64 {
+	if jr != nil {
+		jr.mutex.Lock()
+		if jr.haveNextNextGaussian {
+			jr.haveNextNextGaussian = false
+			jr.mutex.Unlock()
+			return jr.nextNextGaussian
+		}
+		jr.mutex.Unlock()
+	}
+	var v1, v2, s float64
+	for {
+		v1 = 2*randomNextDouble(r) - 1
+		v2 = 2*randomNextDouble(r) - 1
+		s = v1*v1 + v2*v2
+		if s < 1 && s != 0 {
+			break
+		}
+	}
+	multiplier := math.Sqrt(-2 * math.Log(s) / s)
+	if jr != nil {
+		jr.mutex.Lock()
+		jr.nextNextGaussian = v2 * multiplier
+		jr.haveNextNextGaussian = true
+		jr.mutex.Unlock()
+	}
+	return v1 * multiplier
+}
+
+// ---- java.util.SplittableRandom ----
+
+// goldenGamma is 0x9e3779b97f4a7c15. It's a variable, so that arithmetic on it wraps
+// around as Java's does, rather than overflowing at compile time.
+var goldenGamma int64 = -0x61c8864680b583eb
+
+type splittableRandom struct {
+	mutex sync.Mutex
+	seed  int64
+	gamma int64 // always odd
+}
+
+func mix64(z int64) int64 {
+	u := uint64(z)
+	u = (u ^ (u >> 30)) * 0xbf58476d1ce4e5b9
+	u = (u ^ (u >> 27)) * 0x94d049bb133111eb
+	return int64(u ^ (u >> 31))
+}
+
+func mix32(z int64) int32 {
+	u := uint64(z)
+	u = (u ^ (u >> 33)) * 0x62a9d9ed799705f5
+	return int32(((u ^ (u >> 28)) * 0xcb24d0a5c88c35b3) >> 32)
+}
+
+func mixGamma(z int64) int64 {
+	u := uint64(z)
+	u = (u ^ (u >> 33)) * 0xff51afd7ed558ccd
+	u = (u ^ (u >> 33)) * 0xc4ceb9fe1a85ec53
+	u = (u ^ (u >> 33)) | 1
+	if bits.OnesCount64(u^(u>>1)) < 24 { // ensure enough bit transitions
+		u ^= 0xaaaaaaaaaaaaaaaa
+	}
+	return int64(u)
+}
+
+// splittableDefaultGen is the source of the seeds of unseeded SplittableRandoms. As in
+// the JDK, it's seeded from the time, or from SecureRandom if the system property
+// java.util.secureRandomSeed is true.
+var splittableDefaultGen int64
+var splittableGenOnce sync.Once
+
+func nextDefaultSplittableSeed() int64 {
+	splittableGenOnce.Do(func() {
+		if val, _ := globals.SystemProperties.Get("java.util.secureRandomSeed"); val == "true" {
+			b := make([]byte, 8)
+			secureRandom{}.nextBytes(b)
+			splittableDefaultGen = int64(binary.BigEndian.Uint64(b))
+		} else {
+			now := time.Now()
+			splittableDefaultGen = mix64(now.UnixNano()/1_000_000) ^ mix64(nanoTime(nil).(int64))
+		}
+	})
+	return atomic.AddInt64(&splittableDefaultGen, 2*goldenGamma) - 2*goldenGamma
+}
+
+func newDefaultSplittableRandom() *splittableRandom {
+	s := nextDefaultSplittableSeed()
+	return &splittableRandom{seed: mix64(s), gamma: mixGamma(s + goldenGamma)}
+}
+
+func (sr *splittableRandom) nextSeed() int64 {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	sr.seed += sr.gamma
+	return sr.seed
+}
+
+func (sr *splittableRandom) nextIntBounded(bound int32) (int32, error) {
+	if bound <= 0 {
+		return 0, errBadBound
+	}
+	r := mix32(sr.nextSeed())
+	m := bound - 1
+	if bound&m == 0 {
+		return r & m, nil
+	}
+	for u := int32(uint32(r) >> 1); ; u = int32(uint32(mix32(sr.nextSeed())) >> 1) {
+		r = u % bound
+		if u+m-r >= 0 {
+			return r, nil
+		}
+	}
+}
+
+// ---- object access ----
+
+func randomOf(ref int64) randomSource {
+	if obj := GetObject(ref); obj != nil {
+		if r, ok := obj.Native.(randomSource); ok {
+			return r
+		}
+	}
+	return secureRandom{}
+}
+
+func splittableOf(ref int64) *splittableRandom {
+	if obj := GetObject(ref); obj != nil {
+		if sr, ok := obj.Native.(*splittableRandom); ok {
+			return sr
+		}
+	}
+	return newDefaultSplittableRandom()
+}
+
+func setNative(ref int64, state interface{}) {
+	if obj := GetObject(ref); obj != nil {
+		obj.Native = state
+	}
+}
+
+// mathRandom is the generator behind Math.random(), created on first use
+var mathRandom *javaRandom
+var mathRandomOnce sync.Once
+
+func Load_Util_Random() map[string]GMeth {
+	for _, class := range []string{"java/util/Random", "java/security/SecureRandom"} {
+		addNative(class+".nextInt()I", false, func(this int64) int32 {
+			return randomNextInt(randomOf(this))
+		})
+		addNative(class+".nextInt(I)I", false, func(this int64, bound int32) (int32, error) {
+			return randomNextIntBounded(randomOf(this), bound)
+		})
+		addNative(class+".nextLong()J", false, func(this int64) int64 {
+			return randomNextLong(randomOf(this))
+		})
+		addNative(class+".nextBoolean()Z", false, func(this int64) bool {
+			return randomOf(this).next(1) != 0
+		})
+		addNative(class+".nextFloat()F", false, func(this int64) float32 {
+			return randomNextFloat(randomOf(this))
+		})
+		addNative(class+".nextDouble()D", false, func(this int64) float64 {
+			return randomNextDouble(randomOf(this))
+		})
+		addNative(class+".nextGaussian()D", false, func(this int64) float64 {
+			r := randomOf(this)
+			jr, _ := r.(*javaRandom)
+			return randomNextGaussian(r, jr)
+		})
+		addNative(class+".nextBytes([B)V", false, func(this, array int64) {
+			if b, ok := ByteArrayFromRef(array); ok {
+				randomOf(this).nextBytes(b)
+			}
+		})
+	}
+
+	rnd := "java/util/Random"
+	addNative(rnd+".<init>()V", false, func(this int64) {
+		setNative(this, newJavaRandom(newSeedUniquifier()^nanoTime(nil).(int64)))
+	})
+	addNative(rnd+".<init>(J)V", false, func(this, seed int64) {
+		setNative(this, newJavaRandom(seed))
+	})
+	addNative(rnd+".setSeed(J)V", false, func(this, seed int64) {
+		if jr, ok := randomOf(this).(*javaRandom); ok {
+			jr.setSeed(seed)
+		}
+	})
+
+	// SecureRandom. Seeds supplied by the program only add to the OS's entropy, so
+	// setSeed() and the seed constructor leave the source unchanged.
+	sec := "java/security/SecureRandom"
+	addNative(sec+".<init>()V", false, func(this int64) { setNative(this, secureRandom{}) })
+	addNative(sec+".<init>([B)V", false, func(this, seed int64) { setNative(this, secureRandom{}) })
+	addNative(sec+".setSeed([B)V", false, func(this, seed int64) {})
+	addNative(sec+".setSeed(J)V", false, func(this, seed int64) {})
+	seedBytes := func(n int32) (int64, error) {
+		if n < 0 {
+			return 0, errors.New("java.lang.IllegalArgumentException: numBytes cannot be negative")
+		}
+		b := make([]byte, n)
+		secureRandom{}.nextBytes(b)
+		return NewByteArray(b), nil
+	}
+	addNative(sec+".generateSeed(I)[B", false, func(this int64, n int32) (int64, error) {
+		return seedBytes(n)
+	})
+	addNative(sec+".getSeed(I)[B", true, seedBytes)
+
+	// java.util.SplittableRandom
+	spl := "java/util/SplittableRandom"
+	addNative(spl+".<init>()V", false, func(this int64) {
+		setNative(this, newDefaultSplittableRandom())
+	})
+	addNative(spl+".<init>(J)V", false, func(this, seed int64) {
+		setNative(this, &splittableRandom{seed: seed, gamma: goldenGamma})
+	})
+	addNative(spl+".nextInt()I", false, func(this int64) int32 {
+		return mix32(splittableOf(this).nextSeed())
+	})
+	addNative(spl+".nextInt(I)I", false, func(this int64, bound int32) (int32, error) {
+		return splittableOf(this).nextIntBounded(bound)
+	})
+	addNative(spl+".nextLong()J", false, func(this int64) int64 {
+		return mix64(splittableOf(this).nextSeed())
+	})
+	addNative(spl+".nextDouble()D", false, func(this int64) float64 {
+		return float64(uint64(mix64(splittableOf(this).nextSeed()))>>11) * 0x1.0p-53
+	})
+	addNative(spl+".nextBoolean()Z", false, func(this int64) bool {
+		return mix32(splittableOf(this).nextSeed()) < 0
+	})
+	addNative(spl+".split()L"+spl+";", false, func(this int64) int64 {
+		sr := splittableOf(this)
+		child := &splittableRandom{seed: mix64(sr.nextSeed())}
+		child.gamma = mixGamma(sr.nextSeed())
+		ref := NewObject(spl, 0)
+		setNative(ref, child)
+		return ref
+	})
+
+	// java.util.UUID.randomUUID(): a version 4 (random) UUID from SecureRandom
+	uuid := "java/util/UUID"
+	addNative(uuid+".randomUUID()L"+uuid+";", true, func() int64 {
+		b := make([]byte, 16)
+		secureRandom{}.nextBytes(b)
+		b[6] = b[6]&0x0f | 0x40 // version 4
+		b[8] = b[8]&0x3f | 0x80 // IETF variant
+		ref := NewObject(uuid, 0)
+		setNative(ref, &javaUUID{msb: int64(binary.BigEndian.Uint64(b[:8])),
+			lsb: int64(binary.BigEndian.Uint64(b[8:]))})
+		return ref
+	})
+	addNative(uuid+".<init>(JJ)V", false, func(this, msb, lsb int64) {
+		setNative(this, &javaUUID{msb: msb, lsb: lsb})
+	})
+	addNative(uuid+".getMostSignificantBits()J", false, func(this int64) int64 {
+		return uuidOf(this).msb
+	})
+	addNative(uuid+".getLeastSignificantBits()J", false, func(this int64) int64 {
+		return uuidOf(this).lsb
+	})
+	addNative(uuid+".version()I", false, func(this int64) int32 {
+		return int32((uuidOf(this).msb >> 12) & 0x0f)
+	})
+	addNative(uuid+".toString()Ljava/lang/String;", false, func(this int64) int64 {
+		return NewStringObject(uuidOf(this).String())
+	})
+
+	// Math.random() and StrictMath.random() share one generator
+	for _, class := range []string{"java/lang/Math", "java/lang/StrictMath"} {
+		addNative(class+".random()D", true, func() float64 {
+			mathRandomOnce.Do(func() { mathRandom = newJavaRandom(newSeedUniquifier() ^ nanoTime(nil).(int64)) })
+			return randomNextDouble(mathRandom)
+		})
+	}
+	return MethodSignatures
+}
+
+type javaUUID struct {
+	msb, lsb int64
+}
+
+func uuidOf(ref int64) *javaUUID {
+	if obj := GetObject(ref); obj != nil {
+		if u, ok := obj.Native.(*javaUUID); ok {
+			return u
+		}
+	}
+	return &javaUUID{}
+}
+
+func (u *javaUUID) String() string {
+	m, l := uint64(u.msb), uint64(u.lsb)
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", m>>32, (m>>16)&0xffff, m&0xffff, l>>48, l&0xffffffffffff)
+}
@@ -0,0 +1,100 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// the expected values are those produced by the JDK for the same seeds
+func TestRandomMatchesJDKSequence(t *testing.T) {
+	Load_Util_Random()
+	r := NewObject("java/util/Random", 0)
+	callNative(t, "java/util/Random.<init>(J)V", r, int64(42))
+	if i := callNative(t, "java/util/Random.nextInt()I", r).(int64); i != -1170105035 {
+		t.Errorf("Expected first nextInt() for seed 42 to be -1170105035, got: %d", i)
+	}
+	if i := callNative(t, "java/util/Random.nextInt()I", r).(int64); i != 234785527 {
+		t.Errorf("Expected second nextInt() for seed 42 to be 234785527, got: %d", i)
+	}
+
+	callNative(t, "java/util/Random.setSeed(J)V", r, int64(42))
+	g := FloatFromSlot(callNative(t, "java/util/Random.nextGaussian()D", r).(int64))
+	if g != 1.1419053154730547 {
+		t.Errorf("Expected nextGaussian() for seed 42 to be 1.1419053154730547, got: %v", g)
+	}
+
+	for i := 0; i < 100; i++ {
+		if n := callNative(t, "java/util/Random.nextInt(I)I", r, int64(7)).(int64); n < 0 || n >= 7 {
+			t.Fatalf("Expected nextInt(7) to be in [0, 7), got: %d", n)
+		}
+	}
+}
+
+func TestSplittableRandomMatchesSplitMix64(t *testing.T) {
+	Load_Util_Random()
+	sr := NewObject("java/util/SplittableRandom", 0)
+	callNative(t, "java/util/SplittableRandom.<init>(J)V", sr, int64(0))
+	// the first output of the SplitMix64 reference generator for seed 0
+	if l := callNative(t, "java/util/SplittableRandom.nextLong()J", sr).(int64); uint64(l) != 0xe220a8397b1dcdaf {
+		t.Errorf("Expected nextLong() for seed 0 to be 0xe220a8397b1dcdaf, got: %x", uint64(l))
+	}
+
+	a := NewObject("java/util/SplittableRandom", 0)
+	b := NewObject("java/util/SplittableRandom", 0)
+	callNative(t, "java/util/SplittableRandom.<init>()V", a)
+	callNative(t, "java/util/SplittableRandom.<init>()V", b)
+	if splittableOf(a).seed == splittableOf(b).seed {
+		t.Errorf("Expected unseeded SplittableRandoms to get different seeds")
+	}
+}
+
+func TestSecureRandomAndUUID(t *testing.T) {
+	Load_Util_Random()
+	s := NewObject("java/security/SecureRandom", 0)
+	callNative(t, "java/security/SecureRandom.<init>()V", s)
+	seed, _ := ByteArrayFromRef(callNative(t, "java/security/SecureRandom.generateSeed(I)[B", s, int64(32)).(int64))
+	if len(seed) != 32 {
+		t.Errorf("Expected 32-byte seed, got %d bytes", len(seed))
+	}
+	if n := callNative(t, "java/security/SecureRandom.nextInt(I)I", s, int64(10)).(int64); n < 0 || n >= 10 {
+		t.Errorf("Expected nextInt(10) to be in [0, 10), got: %d", n)
+	}
+
+	uuid := callNative(t, "java/util/UUID.randomUUID()Ljava/util/UUID;").(int64)
+	str, _ := GoStringFromRef(callNative(t, "java/util/UUID.toString()Ljava/lang/String;", uuid).(int64))
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(str) {
+		t.Errorf("Expected a version 4 UUID, got: %s", str)
+	}
+	if callNative(t, "java/util/UUID.version()I", uuid).(int64) != 4 {
+		t.Errorf("Expected UUID version 4")
+	}
+}
+
+func TestRandomBadBounds(t *testing.T) {
+	Load_Util_Random()
+	r := NewObject("java/util/Random", 0)
+	callNative(t, "java/util/Random.<init>(J)V", r, int64(42))
+	sr := NewObject("java/util/SplittableRandom", 0)
+	callNative(t, "java/util/SplittableRandom.<init>(J)V", sr, int64(0))
+	s := NewObject("java/security/SecureRandom", 0)
+	callNative(t, "java/security/SecureRandom.<init>()V", s)
+
+	for what, result := range map[string]interface{}{
+		"Random.nextInt(0)":            callNative(t, "java/util/Random.nextInt(I)I", r, int64(0)),
+		"SplittableRandom.nextInt(-1)": callNative(t, "java/util/SplittableRandom.nextInt(I)I", sr, int64(-1)),
+		"SecureRandom.generateSeed(-1)": callNative(t, "java/security/SecureRandom.generateSeed(I)[B",
+			s, int64(-1)),
+	} {
+		if err, _ := result.(error); err == nil ||
+			!strings.HasPrefix(err.Error(), "java.lang.IllegalArgumentException: ") {
+			t.Errorf("Expected %s to throw IllegalArgumentException, got %v", what, result)
+		}
+	}
+}
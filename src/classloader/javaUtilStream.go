@@ -0,0 +1,102 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Streams returned by natives (such as ProcessHandle.children()) are implemented
+// in Go, over a slice of object references. Only the operations that don't take
+// functional interfaces are supported so far, since calling a lambda requires
+// invokeinterface. As in Java, a stream can be operated on only once.
+
+type refStream struct {
+	elems    []int64
+	consumed bool
+}
+
+// NewStreamObject returns a Stream of the referenced objects
+func NewStreamObject(elems []int64) int64 {
+	ref := NewObject("java/util/stream/Stream", 0)
+	GetObject(ref).Native = &refStream{elems: elems}
+	return ref
+}
+
+// errStreamConsumed is thrown, as in the JDK, by an operation on a stream that has
+// already been operated on
+var errStreamConsumed = errors.New("java.lang.IllegalStateException: stream has already been operated upon or closed")
+
+// streamElems consumes the referenced stream and returns its elements
+func streamElems(ref int64) ([]int64, error) {
+	obj := GetObject(ref)
+	if obj == nil {
+		return nil, errNPE
+	}
+	s, ok := obj.Native.(*refStream)
+	if !ok || s.consumed {
+		return nil, errStreamConsumed
+	}
+	s.consumed = true
+	return s.elems, nil
+}
+
+func Load_Util_Stream() map[string]GMeth {
+	st := "java/util/stream/Stream"
+	addNative(st+".count()J", false, func(this int64) (int64, error) {
+		elems, err := streamElems(this)
+		return int64(len(elems)), err
+	})
+	addNative(st+".toArray()[Ljava/lang/Object;", false, func(this int64) (int64, error) {
+		elems, err := streamElems(this)
+		if err != nil {
+			return 0, err
+		}
+		return NewRefArray("java/lang/Object", append([]int64(nil), elems...)), nil
+	})
+	for _, method := range []string{"findFirst", "findAny"} {
+		addNative(st+"."+method+"()Ljava/util/Optional;", false, func(this int64) (int64, error) {
+			elems, err := streamElems(this)
+			switch {
+			case err != nil:
+				return 0, err
+			case len(elems) == 0:
+				return NewOptional(0), nil
+			}
+			return NewOptional(elems[0]), nil
+		})
+	}
+	// as in the JDK, the message of the exception for a negative count is the count
+	addNative(st+".limit(J)L"+st+";", false, func(this, max int64) (int64, error) {
+		if max < 0 {
+			return 0, fmt.Errorf("java.lang.IllegalArgumentException: %d", max)
+		}
+		elems, err := streamElems(this)
+		if err != nil {
+			return 0, err
+		}
+		if int64(len(elems)) > max {
+			elems = elems[:max]
+		}
+		return NewStreamObject(elems), nil
+	})
+	addNative(st+".skip(J)L"+st+";", false, func(this, n int64) (int64, error) {
+		if n < 0 {
+			return 0, fmt.Errorf("java.lang.IllegalArgumentException: %d", n)
+		}
+		elems, err := streamElems(this)
+		if err != nil {
+			return 0, err
+		}
+		if n >= int64(len(elems)) {
+			return NewStreamObject(nil), nil
+		}
+		return NewStreamObject(elems[n:]), nil
+	})
+	return MethodSignatures
+}
@@ -0,0 +1,40 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+func TestStream(t *testing.T) {
+	Load_Util_Stream()
+	Load_Util_Optional()
+	elems := []int64{NewStringObject("a"), NewStringObject("b"), NewStringObject("c")}
+
+	s := NewStreamObject(elems)
+	if n := callNative(t, "java/util/stream/Stream.count()J", s); n != int64(3) {
+		t.Errorf("Expected count() of 3, got %v", n)
+	}
+	if err := callNative(t, "java/util/stream/Stream.count()J", s); err != errStreamConsumed {
+		t.Errorf("Expected a consumed stream to throw IllegalStateException, got %v", err)
+	}
+	ret := callNative(t, "java/util/stream/Stream.limit(J)Ljava/util/stream/Stream;", NewStreamObject(elems), int64(-1))
+	if err, _ := ret.(error); err == nil || err.Error() != "java.lang.IllegalArgumentException: -1" {
+		t.Errorf("Expected a negative limit to throw IllegalArgumentException, got %v", ret)
+	}
+
+	s = NewStreamObject(elems)
+	s = callNative(t, "java/util/stream/Stream.skip(J)Ljava/util/stream/Stream;", s, int64(1)).(int64)
+	s = callNative(t, "java/util/stream/Stream.limit(J)Ljava/util/stream/Stream;", s, int64(1)).(int64)
+	first := callNative(t, "java/util/stream/Stream.findFirst()Ljava/util/Optional;", s).(int64)
+	if optionalValue(first) != elems[1] {
+		t.Error("Expected skip(1).limit(1).findFirst() to return the second element")
+	}
+
+	empty := callNative(t, "java/util/stream/Stream.findAny()Ljava/util/Optional;", NewStreamObject(nil)).(int64)
+	if optionalValue(empty) != 0 {
+		t.Error("Expected findAny() of an empty stream to return an empty Optional")
+	}
+}
@@ -0,0 +1,339 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The clock and time zone natives that java.time and java.util.TimeZone depend on.
+//
+// Both java.time's TzdbZoneRulesProvider and java.util.TimeZone read the time zone
+// rules from the JDK's lib/tzdb.dat. When that file isn't there (if JAVA_HOME points
+// to a JRE image without it, say), Jacobin generates an equivalent file from Go's
+// zoneinfo database and opens it instead (see substituteResource()). The generated
+// rules list each zone's transitions explicitly from 1850 to 2100, rather than as
+// recurring rules, and take every daylight saving period to be one hour.
+
+// === clocks ===
+
+// the range of VM.getNanoTimeAdjustment(), as in HotSpot: +/- 2^32 seconds
+const maxAdjustmentSecs = int64(1) << 32
+
+// nanoTimeAdjustment returns the current time in nanoseconds relative to the offset
+// (in seconds from the epoch), or -1 if the difference is too large for a long
+func nanoTimeAdjustment(now time.Time, offsetSecs int64) int64 {
+	diff := now.Unix() - offsetSecs
+	if diff >= maxAdjustmentSecs || diff <= -maxAdjustmentSecs {
+		return -1
+	}
+	return diff*int64(time.Second) + int64(now.Nanosecond())
+}
+
+// === the system time zone ===
+
+// zoneinfoDirs are the directories where Unix systems keep their zoneinfo files
+var zoneinfoDirs = []string{"/usr/share/zoneinfo", "/usr/share/lib/zoneinfo", "/usr/lib/zoneinfo"}
+
+// systemTimeZoneID returns the ID of the platform's time zone (see globals/locale.go).
+// The second return value is false if the zone can't be determined.
+func systemTimeZoneID() (string, bool) {
+	return globals.HostTimeZoneID()
+}
+
+// gmtOffsetID returns the ID of the custom time zone for the offset, e.g. GMT+05:30
+func gmtOffsetID(offsetSecs int) string {
+	if offsetSecs == 0 {
+		return "GMT"
+	}
+	sign := '+'
+	if offsetSecs < 0 {
+		sign = '-'
+		offsetSecs = -offsetSecs
+	}
+	return fmt.Sprintf("GMT%c%02d:%02d", sign, offsetSecs/3600, offsetSecs%3600/60)
+}
+
+// === tzdb.dat from Go's zoneinfo ===
+
+// tzdbFromYear and tzdbToYear bound the transitions listed in the generated file
+const (
+	tzdbFromYear = 1850
+	tzdbToYear   = 2100
+)
+
+var generatedTzdb string // the path of the generated file, once it's been written
+var tzdbOnce sync.Once
+
+// substituteResource returns the path of the file to open in place of the JDK file
+// at path. Only lib/tzdb.dat is substituted, and only when it doesn't exist.
+func substituteResource(path string) string {
+	javaHome, _ := globals.SystemProperties.Get("java.home")
+	if javaHome == "" || filepath.Clean(path) != filepath.Join(javaHome, "lib", "tzdb.dat") {
+		return path
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	tzdbOnce.Do(func() {
+		data := buildTzdb(zoneinfoRegions())
+		f, err := ioutil.TempFile("", "jacobin-tzdb-*.dat")
+		if err != nil {
+			_ = log.Log("Could not write the time zone database: "+err.Error(), log.WARNING)
+			return
+		}
+		_, err = f.Write(data)
+		_ = f.Close()
+		if err == nil {
+			generatedTzdb = f.Name()
+		}
+	})
+	if generatedTzdb == "" {
+		return path
+	}
+	return generatedTzdb
+}
+
+// zoneinfoRegions lists the zone IDs in the zoneinfo database that Go uses: the
+// system's zoneinfo directory or, failing that, Go's own zoneinfo.zip.
+func zoneinfoRegions() []string {
+	var ids []string
+	add := func(name string) {
+		// skip the files that aren't zones (zone.tab, posixrules, etc.) and the
+		// duplicate posix/ and right/ trees
+		if strings.HasPrefix(name, "posix/") || strings.HasPrefix(name, "right/") ||
+			strings.ContainsAny(name, ".") || name == "posixrules" || name == "localtime" ||
+			name == "Factory" || name[0] < 'A' || name[0] > 'Z' {
+			return
+		}
+		if _, err := time.LoadLocation(name); err == nil {
+			ids = append(ids, name)
+		}
+	}
+
+	dirs := zoneinfoDirs
+	if zi := os.Getenv("ZONEINFO"); zi != "" {
+		dirs = append([]string{zi}, dirs...)
+	}
+	for _, dir := range dirs {
+		_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				rel, _ := filepath.Rel(dir, path)
+				add(filepath.ToSlash(rel))
+			}
+			return nil
+		})
+		if len(ids) > 0 {
+			break
+		}
+	}
+	if len(ids) == 0 {
+		if z, err := zip.OpenReader(filepath.Join(runtime.GOROOT(), "lib", "time", "zoneinfo.zip")); err == nil {
+			for _, f := range z.File {
+				add(f.Name)
+			}
+			_ = z.Close()
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// zoneState is what java.time needs to know about a zone at an instant
+type zoneState struct {
+	wall int // the offset from UTC, in seconds
+	std  int // the standard offset (the offset without daylight saving)
+}
+
+func zoneStateAt(loc *time.Location, epochSec int64) zoneState {
+	t := time.Unix(epochSec, 0).In(loc)
+	_, offset := t.Zone()
+	if t.IsDST() {
+		return zoneState{wall: offset, std: offset - 3600}
+	}
+	return zoneState{wall: offset, std: offset}
+}
+
+type zoneTransition struct {
+	epochSec int64
+	state    zoneState // the state from this instant on
+}
+
+// zoneTransitions finds the zone's transitions by probing it a week at a time and
+// then bisecting each week in which the state changes. (No zone has had two
+// transitions within a week.)
+func zoneTransitions(loc *time.Location) (zoneState, []zoneTransition) {
+	from := time.Date(tzdbFromYear, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	to := time.Date(tzdbToYear, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	const week = 7 * 24 * 3600
+
+	initial := zoneStateAt(loc, from)
+	var transitions []zoneTransition
+	prev := initial
+	for t := from + week; t < to+week; t += week {
+		state := zoneStateAt(loc, t)
+		if state == prev {
+			continue
+		}
+		lo, hi := t-week, t // the state at lo is prev; at hi, it's state
+		for hi-lo > 1 {
+			mid := lo + (hi-lo)/2
+			if zoneStateAt(loc, mid) == prev {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		transitions = append(transitions, zoneTransition{hi, state})
+		prev = state
+	}
+	return initial, transitions
+}
+
+// serializeZoneRules writes the zone's rules the way java.time.zone.Ser writes a
+// ZoneRules object: the standard offset transitions, then the wall offset transitions,
+// then the (here, empty) list of recurring rules.
+func serializeZoneRules(initial zoneState, transitions []zoneTransition) []byte {
+	var stdTrans, wallTrans []int64
+	stdOffsets, wallOffsets := []int{initial.std}, []int{initial.wall}
+	for _, tr := range transitions {
+		if tr.state.std != stdOffsets[len(stdOffsets)-1] {
+			stdTrans = append(stdTrans, tr.epochSec)
+			stdOffsets = append(stdOffsets, tr.state.std)
+		}
+		if tr.state.wall != wallOffsets[len(wallOffsets)-1] {
+			wallTrans = append(wallTrans, tr.epochSec)
+			wallOffsets = append(wallOffsets, tr.state.wall)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(1) // Ser.ZRULES
+	for _, list := range []struct {
+		trans   []int64
+		offsets []int
+	}{{stdTrans, stdOffsets}, {wallTrans, wallOffsets}} {
+		_ = binary.Write(&buf, binary.BigEndian, int32(len(list.trans)))
+		for _, t := range list.trans {
+			writeEpochSec(&buf, t)
+		}
+		for _, o := range list.offsets {
+			writeOffset(&buf, o)
+		}
+	}
+	buf.WriteByte(0) // no recurring rules
+	return buf.Bytes()
+}
+
+// writeEpochSec is Ser.writeEpochSec(): quarter hours between 1825 and 2300 take
+// three bytes; other instants take a marker byte and a long.
+func writeEpochSec(buf *bytes.Buffer, epochSec int64) {
+	if epochSec >= -4575744000 && epochSec < 10413792000 && epochSec%900 == 0 {
+		store := (epochSec + 4575744000) / 900
+		buf.Write([]byte{byte(store >> 16), byte(store >> 8), byte(store)})
+		return
+	}
+	buf.WriteByte(255)
+	_ = binary.Write(buf, binary.BigEndian, epochSec)
+}
+
+// writeOffset is Ser.writeOffset(): offsets that are a whole number of quarter hours
+// take one byte; others take a marker byte and an int.
+func writeOffset(buf *bytes.Buffer, offsetSecs int) {
+	if offsetSecs%900 == 0 {
+		buf.WriteByte(byte(int8(offsetSecs / 900)))
+		return
+	}
+	buf.WriteByte(127)
+	_ = binary.Write(buf, binary.BigEndian, int32(offsetSecs))
+}
+
+// writeUTF writes a string the way DataOutputStream.writeUTF() does. Zone IDs are
+// ASCII, so there's no difference between UTF-8 and modified UTF-8 here.
+func writeUTF(buf *bytes.Buffer, s string) {
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+// buildTzdb creates the contents of a tzdb.dat file holding the rules of the zones,
+// in the format TzdbZoneRulesProvider reads: a header, the region IDs, the distinct
+// serialized rules, the mapping of regions to rules (for a single version), and an
+// empty list of aliases.
+func buildTzdb(regions []string) []byte {
+	var ids []string
+	var rules [][]byte
+	ruleIndex := make(map[string]int)
+	var mapping [][2]int
+
+	for _, id := range regions {
+		loc, err := time.LoadLocation(id)
+		if err != nil {
+			continue
+		}
+		rule := serializeZoneRules(zoneTransitions(loc))
+		i, present := ruleIndex[string(rule)]
+		if !present {
+			i = len(rules)
+			ruleIndex[string(rule)] = i
+			rules = append(rules, rule)
+		}
+		mapping = append(mapping, [2]int{len(ids), i})
+		ids = append(ids, id)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(1) // the file format version
+	writeUTF(&buf, "TZDB")
+	_ = binary.Write(&buf, binary.BigEndian, int16(1)) // one version of the rules
+	writeUTF(&buf, "jacobin")
+	_ = binary.Write(&buf, binary.BigEndian, int16(len(ids)))
+	for _, id := range ids {
+		writeUTF(&buf, id)
+	}
+	_ = binary.Write(&buf, binary.BigEndian, int16(len(rules)))
+	for _, rule := range rules {
+		_ = binary.Write(&buf, binary.BigEndian, int16(len(rule)))
+		buf.Write(rule)
+	}
+	_ = binary.Write(&buf, binary.BigEndian, int16(len(mapping)))
+	for _, m := range mapping {
+		_ = binary.Write(&buf, binary.BigEndian, [2]int16{int16(m[0]), int16(m[1])})
+	}
+	_ = binary.Write(&buf, binary.BigEndian, int16(0)) // no aliases
+	return buf.Bytes()
+}
+
+func Load_Util_TimeZone() map[string]GMeth {
+	addNative("jdk/internal/misc/VM.getNanoTimeAdjustment(J)J", true, func(offsetSecs int64) int64 {
+		return nanoTimeAdjustment(time.Now(), offsetSecs)
+	})
+	addNative("java/util/TimeZone.getSystemTimeZoneID(Ljava/lang/String;)Ljava/lang/String;", true,
+		func(javaHome int64) int64 {
+			if id, ok := systemTimeZoneID(); ok {
+				return NewStringObject(id)
+			}
+			return 0
+		})
+	addNative("java/util/TimeZone.getSystemGMTOffsetID()Ljava/lang/String;", true, func() int64 {
+		_, offset := time.Now().Zone()
+		return NewStringObject(gmtOffsetID(offset))
+	})
+	return MethodSignatures
+}
@@ -0,0 +1,149 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNanoTimeAdjustment(t *testing.T) {
+	now := time.Unix(1_600_000_000, 123)
+	if got := nanoTimeAdjustment(now, 1_600_000_000-5); got != 5_000_000_123 {
+		t.Errorf("Expected 5000000123, got %d", got)
+	}
+	if got := nanoTimeAdjustment(now, 1_600_000_000-maxAdjustmentSecs); got != -1 {
+		t.Errorf("Expected -1 for an offset 2^32 seconds away, got %d", got)
+	}
+
+	Load_Util_TimeZone()
+	offset := time.Now().Unix() - 1024
+	adj := callNative(t, "jdk/internal/misc/VM.getNanoTimeAdjustment(J)J", offset).(int64)
+	if adj < 1024*int64(time.Second) || adj > 1100*int64(time.Second) {
+		t.Errorf("Unexpected adjustment: %d", adj)
+	}
+}
+
+func TestSystemTimeZoneIDFromTZ(t *testing.T) {
+	saved, present := os.LookupEnv("TZ")
+	defer func() {
+		if present {
+			os.Setenv("TZ", saved)
+		} else {
+			os.Unsetenv("TZ")
+		}
+	}()
+
+	for tz, want := range map[string]string{
+		"Europe/Paris":                     "Europe/Paris",
+		":America/New_York":                "America/New_York",
+		"/usr/share/zoneinfo/Asia/Kolkata": "Asia/Kolkata",
+	} {
+		os.Setenv("TZ", tz)
+		if id, ok := systemTimeZoneID(); !ok || id != want {
+			t.Errorf("TZ=%s: expected %s, got %q", tz, want, id)
+		}
+	}
+}
+
+func TestGMTOffsetID(t *testing.T) {
+	for offset, want := range map[int]string{0: "GMT", 19800: "GMT+05:30", -12600: "GMT-03:30"} {
+		if got := gmtOffsetID(offset); got != want {
+			t.Errorf("Offset %d: expected %s, got %s", offset, want, got)
+		}
+	}
+}
+
+func TestZoneTransitions(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no zoneinfo database available")
+	}
+	_, transitions := zoneTransitions(loc)
+	spring := time.Date(2021, 3, 14, 7, 0, 0, 0, time.UTC).Unix() // 2 a.m. EST
+	found := false
+	for _, tr := range transitions {
+		if tr.epochSec == spring {
+			found = true
+			if tr.state != (zoneState{wall: -4 * 3600, std: -5 * 3600}) {
+				t.Errorf("Unexpected state after the 2021 spring transition: %+v", tr.state)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected a transition at 2021-03-14T07:00Z")
+	}
+}
+
+func TestSerializeZoneRules(t *testing.T) {
+	initial := zoneState{wall: 3600, std: 3600}
+	transitions := []zoneTransition{
+		{epochSec: 900, state: zoneState{wall: 7200, std: 3600}}, // daylight saving starts
+		{epochSec: 1001, state: zoneState{wall: 5400, std: 5400}},
+	}
+	got := serializeZoneRules(initial, transitions)
+	want := []byte{1, // ZRULES
+		0, 0, 0, 1, // one standard transition...
+		255, 0, 0, 0, 0, 0, 0, 3, 233, // ...at 1001 (not a quarter hour, so a long)
+		4, 6, // standard offsets +1:00, +1:30
+		0, 0, 0, 2, // two wall transitions
+		0, 0, 0, // 900, as quarter hours since 1825 (filled in below)
+		255, 0, 0, 0, 0, 0, 0, 3, 233,
+		4, 8, 6, // wall offsets +1:00, +2:00, +1:30
+		0} // no recurring rules
+	store := (900 + 4575744000) / 900
+	want[20], want[21], want[22] = byte(store>>16), byte(store>>8), byte(store)
+	if !bytes.Equal(got, want) {
+		t.Errorf("Expected % x\ngot      % x", want, got)
+	}
+}
+
+func TestBuildTzdbHeader(t *testing.T) {
+	data := buildTzdb([]string{"UTC", "Etc/GMT+1"})
+	r := bytes.NewReader(data)
+	var version byte
+	var groupLen int16
+	_ = binary.Read(r, binary.BigEndian, &version)
+	_ = binary.Read(r, binary.BigEndian, &groupLen)
+	group := make([]byte, groupLen)
+	_, _ = r.Read(group)
+	if version != 1 || string(group) != "TZDB" {
+		t.Errorf("Unexpected header: version %d, group %q", version, group)
+	}
+	if !bytes.HasSuffix(data, []byte{0, 0}) {
+		t.Error("Expected the file to end with an empty alias list")
+	}
+}
+
+func TestSubstituteTzdb(t *testing.T) {
+	javaHome := t.TempDir()
+	saved, _ := globals.SystemProperties.Get("java.home")
+	globals.SystemProperties.Set("java.home", javaHome)
+	defer globals.SystemProperties.Set("java.home", saved)
+
+	other := filepath.Join(javaHome, "lib", "other.dat")
+	if substituteResource(other) != other {
+		t.Error("Expected only tzdb.dat to be substituted")
+	}
+
+	tzdb := filepath.Join(javaHome, "lib", "tzdb.dat")
+	generated := substituteResource(tzdb)
+	if generated == tzdb {
+		t.Fatal("Expected a missing tzdb.dat to be substituted")
+	}
+	defer os.Remove(generated)
+	data, err := ioutil.ReadFile(generated)
+	if err != nil || len(data) < 10 || data[0] != 1 {
+		t.Errorf("Expected a generated tzdb.dat, got %d bytes (%v)", len(data), err)
+	}
+}
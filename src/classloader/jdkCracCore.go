@@ -0,0 +1,36 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"strings"
+)
+
+// jdk.crac.Core (and org.crac.Core, the library that applications use to call it
+// portably), through which a program takes a checkpoint of itself. The checkpoint is
+// taken by the interpreter (see checkpoint.go in package jvm). The VM that takes it
+// ends, and checkpointRestore() returns in the VM that restores it. Resources, which
+// are told of checkpoints and restores, aren't supported yet.
+
+// Checkpoint takes a checkpoint of the VM, which ends it. If the checkpoint can't be
+// taken, it returns why, and checkpointRestore() throws a CheckpointException (of the
+// package of the Core class that was called). The interpreter sets it.
+var Checkpoint = func() error { return errors.New("no interpreter to take the checkpoint") }
+
+func Load_Crac_Core() map[string]GMeth {
+	for _, pkg := range []string{"jdk/crac/", "org/crac/"} {
+		exception := strings.ReplaceAll(pkg, "/", ".") + "CheckpointException: "
+		addNative(pkg+"Core.checkpointRestore()V", true, func() error {
+			if err := Checkpoint(); err != nil {
+				return errors.New(exception + err.Error())
+			}
+			return nil
+		})
+	}
+	return MethodSignatures
+}
@@ -0,0 +1,33 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckpointException(t *testing.T) {
+	Load_Crac_Core()
+	defer func(checkpoint func() error) { Checkpoint = checkpoint }(Checkpoint)
+
+	Checkpoint = func() error { return errors.New("other threads are running") }
+	for class, exception := range map[string]string{
+		"jdk/crac/Core": "jdk.crac.CheckpointException",
+		"org/crac/Core": "org.crac.CheckpointException",
+	} {
+		ret := callNative(t, class+".checkpointRestore()V")
+		if err, _ := ret.(error); err == nil || err.Error() != exception+": other threads are running" {
+			t.Errorf("Expected a failed checkpoint to throw %s, got %v", exception, ret)
+		}
+	}
+
+	Checkpoint = func() error { return nil }
+	if ret := callNative(t, "jdk/crac/Core.checkpointRestore()V"); ret != nil {
+		t.Errorf("Expected a restored checkpoint to return normally, got %v", ret)
+	}
+}
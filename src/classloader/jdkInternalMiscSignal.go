@@ -0,0 +1,247 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// OS signals: sun.misc.Signal and jdk.internal.misc.Signal, which let Java code handle
+// signals, and the VM's own handling of the shutdown signals (SIGHUP, SIGINT, SIGTERM).
+// As in the JDK, a shutdown signal that Java code hasn't taken over starts the shutdown
+// sequence, and the VM then exits with a status of 128 plus the signal number.
+//
+// Handlers run on their own threads, which the interpreter starts via RunSignalHandler.
+// The signals the Go runtime uses itself (SIGSEGV, etc.) can't be handled from Java.
+
+// RunSignalHandler starts a thread that calls handle() on the Java handler object.
+// methodType is the descriptor of handle(), which differs between the sun.misc and
+// jdk.internal.misc APIs. The interpreter sets this when execution begins.
+var RunSignalHandler = func(handler int64, methodType string, sig int64) {}
+
+// ReduceSignalUsage leaves the shutdown signals to the process, as -Xrs does in the JDK,
+// rather than the VM taking them over. A program that embeds the VM sets it, so that
+// its own handling of, say, Ctrl-C isn't preempted. Handlers that Java code installs
+// still work.
+var ReduceSignalUsage = false
+
+type signalHandler struct {
+	ref        int64  // the handler object, or sigDefault or sigIgnore
+	methodType string // the descriptor of the object's handle() method
+}
+
+var sigDefault, sigIgnore int64 // the SIG_DFL and SIG_IGN objects
+var signalHandlers = make(map[syscall.Signal]signalHandler)
+var signalsMutex sync.Mutex
+var signalChan = make(chan os.Signal, 8)
+var signalsOnce sync.Once
+
+// shutdownSignals start the shutdown sequence unless Java code handles them
+var shutdownSignals = signalsNamed("HUP", "INT", "TERM")
+
+// reservedSignals are used by the Go runtime (or can't be caught at all)
+var reservedSignals = signalsNamed("KILL", "SEGV", "BUS", "FPE", "ILL")
+
+// signalsNamed returns the signals with the names that this platform has
+func signalsNamed(names ...string) []syscall.Signal {
+	var sigs []syscall.Signal
+	for _, name := range names {
+		if sig, present := signalNumbers[name]; present {
+			sigs = append(sigs, sig)
+		}
+	}
+	return sigs
+}
+
+func isShutdownSignal(sig syscall.Signal) bool {
+	for _, s := range shutdownSignals {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// signalNumber returns the number of the named signal (e.g., "INT"), or -1 if there's
+// no such signal on this platform
+func signalNumber(name string) int {
+	if num, present := signalNumbers[strings.TrimPrefix(name, "SIG")]; present {
+		return int(num)
+	}
+	return -1
+}
+
+// signalName returns the name of the signal as Java reports it, without "SIG"
+func signalName(sig syscall.Signal) string {
+	for name, num := range signalNumbers {
+		if num == sig {
+			return name
+		}
+	}
+	return ""
+}
+
+type javaSignal struct {
+	name   string
+	number int
+}
+
+func newSignalObject(class string, name string) int64 {
+	ref := NewObject(class, 0)
+	GetObject(ref).Native = &javaSignal{name: name, number: signalNumber(name)}
+	return ref
+}
+
+func signalOf(ref int64) *javaSignal {
+	if obj := GetObject(ref); obj != nil {
+		if s, ok := obj.Native.(*javaSignal); ok {
+			return s
+		}
+	}
+	return &javaSignal{number: -1}
+}
+
+// InitSignalStatics creates SIG_DFL and SIG_IGN for both signal APIs and begins
+// handling the shutdown signals
+func InitSignalStatics() {
+	signalsOnce.Do(func() {
+		sigDefault = NewObject("jdk/internal/misc/Signal$NativeHandler", 0)
+		sigIgnore = NewObject("jdk/internal/misc/Signal$NativeHandler", 0)
+		if !ReduceSignalUsage {
+			signal.Notify(signalChan, toOSSignals(shutdownSignals)...)
+		}
+		go dispatchSignals()
+	})
+	for _, class := range []string{"sun/misc/SignalHandler", "jdk/internal/misc/Signal$Handler"} {
+		for name, ref := range map[string]int64{"SIG_DFL": sigDefault, "SIG_IGN": sigIgnore} {
+			fullName := class + "." + name
+			if _, present := FindStatic(fullName); !present {
+				AddStatic(fullName, Static{Class: 'L', Type: "L" + class + ";", ValueInt: ref})
+			}
+		}
+	}
+}
+
+func toOSSignals(sigs []syscall.Signal) []os.Signal {
+	osSigs := make([]os.Signal, len(sigs))
+	for i, sig := range sigs {
+		osSigs[i] = sig
+	}
+	return osSigs
+}
+
+func dispatchSignals() {
+	for s := range signalChan {
+		if sig, ok := s.(syscall.Signal); ok {
+			dispatchSignal(sig)
+		}
+	}
+}
+
+// dispatchSignal runs the Java handler for the signal or, for a shutdown signal
+// without one, exits the VM
+func dispatchSignal(sig syscall.Signal) {
+	signalsMutex.Lock()
+	h, present := signalHandlers[sig]
+	signalsMutex.Unlock()
+	switch {
+	case present && h.ref == sigIgnore:
+	case present && h.ref != sigDefault:
+		class := "sun/misc/Signal"
+		if strings.HasPrefix(h.methodType, "(Ljdk/") {
+			class = "jdk/internal/misc/Signal"
+		}
+		RunSignalHandler(h.ref, h.methodType, newSignalObject(class, signalName(sig)))
+	case isShutdownSignal(sig):
+		go VMExit(128 + int(sig))
+	}
+}
+
+// setSignalHandler installs the handler for the signal and returns the previous one.
+// As in the JDK, a signal the VM or OS reserves can't be handled.
+func setSignalHandler(sig syscall.Signal, handler int64, methodType string) (int64, error) {
+	if handler == 0 {
+		return 0, errors.New("java.lang.NullPointerException: handler")
+	}
+	for _, reserved := range reservedSignals {
+		if sig == reserved {
+			return 0, fmt.Errorf("java.lang.IllegalArgumentException: Signal already used by VM or OS: SIG%s",
+				signalName(sig))
+		}
+	}
+
+	signalsMutex.Lock()
+	defer signalsMutex.Unlock()
+	previous := sigDefault
+	if h, present := signalHandlers[sig]; present {
+		previous = h.ref
+	}
+	signalHandlers[sig] = signalHandler{ref: handler, methodType: methodType}
+	switch {
+	case handler == sigIgnore:
+		signal.Ignore(sig)
+	case handler != sigDefault || isShutdownSignal(sig):
+		signal.Notify(signalChan, sig)
+	default:
+		signal.Reset(sig)
+	}
+	return previous, nil
+}
+
+func Load_Misc_Signal() map[string]GMeth {
+	for _, class := range []string{"sun/misc/Signal", "jdk/internal/misc/Signal"} {
+		class := class
+		handlerClass := "sun/misc/SignalHandler"
+		if strings.HasPrefix(class, "jdk/") {
+			handlerClass = "jdk/internal/misc/Signal$Handler"
+		}
+		addNative(class+".<init>(Ljava/lang/String;)V", false, func(this, name int64) error {
+			if name == 0 {
+				return errors.New("java.lang.NullPointerException: name")
+			}
+			n := strings.TrimPrefix(javaString(name), "SIG")
+			number := signalNumber(n)
+			if number < 0 {
+				return errors.New("java.lang.IllegalArgumentException: Unknown signal: " + javaString(name))
+			}
+			GetObject(this).Native = &javaSignal{name: n, number: number}
+			return nil
+		})
+		addNative(class+".getName()Ljava/lang/String;", false, func(this int64) int64 {
+			return NewStringObject(signalOf(this).name)
+		})
+		addNative(class+".getNumber()I", false, func(this int64) int32 {
+			return int32(signalOf(this).number)
+		})
+		addNative(class+".toString()Ljava/lang/String;", false, func(this int64) int64 {
+			return NewStringObject("SIG" + signalOf(this).name)
+		})
+		addNative(class+".equals(Ljava/lang/Object;)Z", false, func(this, other int64) bool {
+			return *signalOf(this) == *signalOf(other)
+		})
+		addNative(class+".hashCode()I", false, func(this int64) int32 {
+			return int32(signalOf(this).number)
+		})
+		addNative(class+".handle(L"+class+";L"+handlerClass+";)L"+handlerClass+";", true,
+			func(sig, handler int64) (int64, error) {
+				if sig == 0 {
+					return 0, errors.New("java.lang.NullPointerException: sig")
+				}
+				return setSignalHandler(syscall.Signal(signalOf(sig).number), handler, "(L"+class+";)V")
+			})
+		addNative(class+".raise(L"+class+";)V", true, func(sig int64) {
+			raiseSignal(syscall.Signal(signalOf(sig).number))
+		})
+	}
+	return MethodSignatures
+}
@@ -0,0 +1,104 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func newSignal(t *testing.T, name string) int64 {
+	sig := NewObject("sun/misc/Signal", 0)
+	callNative(t, "sun/misc/Signal.<init>(Ljava/lang/String;)V", sig, NewStringObject(name))
+	return sig
+}
+
+func TestSignalNames(t *testing.T) {
+	Load_Misc_Signal()
+	sig := newSignal(t, "INT")
+	if n := callNative(t, "sun/misc/Signal.getNumber()I", sig); n != int64(2) {
+		t.Errorf("Expected SIGINT to be 2, got %v", n)
+	}
+	name := callNative(t, "sun/misc/Signal.toString()Ljava/lang/String;", sig).(int64)
+	if javaString(name) != "SIGINT" {
+		t.Errorf("Expected SIGINT, got %s", javaString(name))
+	}
+	if callNative(t, "sun/misc/Signal.equals(Ljava/lang/Object;)Z", sig, newSignal(t, "INT")) != int64(1) {
+		t.Error("Expected signals with the same name to be equal")
+	}
+	unknown := callNative(t, "sun/misc/Signal.<init>(Ljava/lang/String;)V",
+		NewObject("sun/misc/Signal", 0), NewStringObject("NOSUCH"))
+	if err, _ := unknown.(error); err == nil || err.Error() != "java.lang.IllegalArgumentException: Unknown signal: NOSUCH" {
+		t.Errorf("Expected an unknown signal to throw IllegalArgumentException, got %v", unknown)
+	}
+}
+
+func TestSignalHandlers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals can't be sent on Windows")
+	}
+	Load_Misc_Signal()
+	InitSignalStatics()
+	handle := "sun/misc/Signal.handle(Lsun/misc/Signal;Lsun/misc/SignalHandler;)Lsun/misc/SignalHandler;"
+	raise := "sun/misc/Signal.raise(Lsun/misc/Signal;)V"
+
+	handled := make(chan int64, 1)
+	RunSignalHandler = func(handler int64, methodType string, sig int64) { handled <- sig }
+	exited := make(chan int, 1)
+	VMExit = func(status int) { exited <- status }
+	defer func() {
+		RunSignalHandler = func(handler int64, methodType string, sig int64) {}
+		VMExit = func(status int) { os.Exit(status) }
+	}()
+
+	// a Java handler receives the signal, and replacing it returns it
+	usr1 := newSignal(t, "USR1")
+	handler := NewObject("test/Handler", 0)
+	if callNative(t, handle, usr1, handler) != sigDefault {
+		t.Error("Expected the previous handler to be SIG_DFL")
+	}
+	callNative(t, raise, usr1)
+	select {
+	case sig := <-handled:
+		if signalOf(sig).name != "USR1" {
+			t.Errorf("Expected the handler to receive USR1, got %s", signalOf(sig).name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the handler to run")
+	}
+	if callNative(t, handle, usr1, sigIgnore) != handler {
+		t.Error("Expected the previous handler to be the Java handler")
+	}
+	callNative(t, raise, usr1) // ignored
+
+	// an unhandled SIGTERM shuts the VM down
+	callNative(t, raise, newSignal(t, "TERM"))
+	select {
+	case status := <-exited:
+		if status != 128+15 {
+			t.Errorf("Expected exit status 143, got %d", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected SIGTERM to exit the VM")
+	}
+	select {
+	case <-handled:
+		t.Error("Expected an ignored signal not to be handled")
+	default:
+	}
+
+	if err, _ := callNative(t, handle, newSignal(t, "KILL"), handler).(error); err == nil ||
+		err.Error() != "java.lang.IllegalArgumentException: Signal already used by VM or OS: SIGKILL" {
+		t.Errorf("Expected SIGKILL not to be handleable, got %v", err)
+	}
+	if err, _ := callNative(t, handle, usr1, int64(0)).(error); err == nil ||
+		err.Error() != "java.lang.NullPointerException: handler" {
+		t.Errorf("Expected a null handler to throw NullPointerException, got %v", err)
+	}
+}
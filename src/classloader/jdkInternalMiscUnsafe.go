@@ -0,0 +1,215 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+/*
+ The natives of jdk.internal.misc.Unsafe: the compare-and-swap natives, which
+ java.util.concurrent.atomic, the VarHandle implementations, and the AQS-based locks
+ ultimately call, the offsets of the fields and array elements they're called on, and
+ park() and unpark(). The word is accessed via a base object and an offset:
+   - for a field of an object, the object and the index of the field's slot in
+     Object.Fields (see object.go)
+   - for an element of an array, the array and the element's index, since arrays have
+     a base offset of 0 and an index scale of 1
+   - for a static field, the Class object of its class (see staticFieldBase()) and the
+     index of the field in the StaticsArray (see statics.go)
+ All access to the word is via sync/atomic.
+
+ They're instance methods, so the first parameter of each is the Unsafe instance
+ (unused), followed by the base object and the offset.
+*/
+
+func Load_Misc_Unsafe() map[string]GMeth {
+	unsafe := "jdk/internal/misc/Unsafe"
+	addNative(unsafe+".compareAndSetInt(Ljava/lang/Object;JII)Z", false, compareAndSetInt)
+	addNative(unsafe+".compareAndSetLong(Ljava/lang/Object;JJJ)Z", false, compareAndSetLong)
+	addNative(unsafe+".compareAndSetReference(Ljava/lang/Object;JLjava/lang/Object;Ljava/lang/Object;)Z",
+		false, compareAndSetLong) // references are int64s, so same logic as longs
+	addNative(unsafe+".getAndAddInt(Ljava/lang/Object;JI)I", false, getAndAddInt)
+	addNative(unsafe+".getAndAddLong(Ljava/lang/Object;JJ)J", false, getAndAddLong)
+
+	// the offsets, which are the slots of the fields (see ObjectLayout())
+	addNative(unsafe+".objectFieldOffset(Ljava/lang/reflect/Field;)J", false, func(this, f int64) int64 {
+		return int64(fieldOf(f).slot)
+	})
+	addNative(unsafe+".objectFieldOffset1(Ljava/lang/Class;Ljava/lang/String;)J", false,
+		func(this, classRef int64, name string) (int64, error) {
+			className, _ := ClassNameOf(classRef)
+			if slot, _, ok := FieldSlot(className, name); ok {
+				return int64(slot), nil
+			}
+			return 0, errors.New("java.lang.InternalError: " + name)
+		})
+
+	// static fields, whose base is the Class object and whose offset is the field's
+	// index in the StaticsArray, which is added if the field hasn't been accessed yet
+	addNative(unsafe+".staticFieldBase(Ljava/lang/reflect/Field;)Ljava/lang/Object;", false,
+		func(this, f int64) (int64, error) {
+			field, err := staticField(f)
+			if err != nil {
+				return 0, err
+			}
+			return ClassObject(field.class), nil
+		})
+	addNative(unsafe+".staticFieldOffset(Ljava/lang/reflect/Field;)J", false,
+		func(this, f int64) (int64, error) {
+			field, err := staticField(f)
+			if err != nil {
+				return 0, err
+			}
+			name := field.class + "." + field.name
+			if index, present := FindStatic(name); present {
+				return index, nil
+			}
+			return AddStatic(name, Static{Class: 'L', Type: field.desc,
+				Volatile: field.modifiers&ACC_VOLATILE != 0}), nil
+		})
+
+	// arrays: each element is in its own slot, so the offset of an element is its index
+	addNative(unsafe+".arrayBaseOffset(Ljava/lang/Class;)I", false, func(this, class int64) (int32, error) {
+		return 0, checkArrayClass(class)
+	})
+	addNative(unsafe+".arrayIndexScale(Ljava/lang/Class;)I", false, func(this, class int64) (int32, error) {
+		return 1, checkArrayClass(class)
+	})
+
+	// LockSupport.park() and unpark(), through which the locks block their threads
+	addNative(unsafe+".park(ZJ)V", false, func(this int64, isAbsolute bool, time int64) {
+		Park(isAbsolute, time)
+	})
+	addNative(unsafe+".unpark(Ljava/lang/Object;)V", false, func(this, thread int64) {
+		Unpark(thread)
+	})
+
+	return MethodSignatures
+}
+
+// Park blocks the current thread until it's unparked, interrupted, or the time is up,
+// and Unpark unblocks the thread, which is passed as its Thread object. (See
+// jvm/park.go.) The interpreter sets them when execution begins.
+var Park = func(isAbsolute bool, time int64) {}
+var Unpark = func(thread int64) {}
+
+// staticField returns the static field the Field object refers to
+func staticField(f int64) (*reflectField, error) {
+	if GetObject(f) == nil {
+		return nil, errors.New("java.lang.NullPointerException")
+	}
+	field := fieldOf(f)
+	if field.slot < 0 || field.modifiers&ACC_STATIC == 0 {
+		return nil, errors.New("java.lang.IllegalArgumentException: not a static field")
+	}
+	return field, nil
+}
+
+// checkArrayClass returns an error unless the Class object is that of an array class
+// whose elements each have a slot (i.e., other than byte[] and char[])
+func checkArrayClass(class int64) error {
+	name, ok := ClassNameOf(class)
+	switch {
+	case !ok:
+		return errors.New("java.lang.NullPointerException")
+	case !strings.HasPrefix(name, "["):
+		return errors.New("java.lang.IllegalArgumentException: not an array class: " + name)
+	case name == "[B" || name == "[C":
+		return errors.New("java.lang.InternalError: unsupported array class: " + name)
+	}
+	return nil
+}
+
+// withSlot calls fn with the word at the offset from the base object: a field of an
+// object, an element of an array, or, if the base is a Class object, a static field.
+// A null base throws a NullPointerException and an offset that's out of range, an
+// InternalError.
+func withSlot(base, offset int64, fn func(slot *int64)) error {
+	obj := GetObject(base)
+	if obj == nil {
+		return errors.New("java.lang.NullPointerException")
+	}
+	badOffset := errors.New("java.lang.InternalError: bad offset " + strconv.FormatInt(offset, 10) +
+		" in " + obj.Klass)
+
+	if _, isClass := ClassNameOf(base); isClass {
+		StaticsMutex.RLock() // the StaticsArray can't be reallocated while it's held
+		defer StaticsMutex.RUnlock()
+		if offset < 0 || offset >= int64(len(StaticsArray)) {
+			return badOffset
+		}
+		if s := &StaticsArray[offset]; s.Volatile {
+			fn(s.volatileVal)
+		} else {
+			fn(&s.ValueInt)
+		}
+		return nil
+	}
+
+	slots := obj.Fields
+	if strings.HasPrefix(obj.Klass, "[") {
+		elems, ok := obj.Native.([]int64) // byte[] and char[] have no slots
+		if !ok {
+			return badOffset
+		}
+		slots = elems
+	}
+	if offset < 0 || offset >= int64(len(slots)) {
+		return badOffset
+	}
+	fn(&slots[offset])
+	return nil
+}
+
+// Unsafe.compareAndSetInt(Object o, long offset, int expected, int x). Ints are
+// stored sign-extended in their 64-bit slot, so they're compared as int64s.
+func compareAndSetInt(this, o, offset int64, expected, x int32) (bool, error) {
+	var swapped bool
+	err := withSlot(o, offset, func(slot *int64) {
+		swapped = atomic.CompareAndSwapInt64(slot, int64(expected), int64(x))
+	})
+	return swapped, err
+}
+
+// Unsafe.compareAndSetLong(Object o, long offset, long expected, long x) and
+// Unsafe.compareAndSetReference(Object o, long offset, Object expected, Object x)
+func compareAndSetLong(this, o, offset, expected, x int64) (bool, error) {
+	var swapped bool
+	err := withSlot(o, offset, func(slot *int64) {
+		swapped = atomic.CompareAndSwapInt64(slot, expected, x)
+	})
+	return swapped, err
+}
+
+// Unsafe.getAndAddInt(Object o, long offset, int delta) returns the previous value.
+// The addition must wrap around at 32 bits, so it's done as a CAS loop rather than
+// with atomic.AddInt64.
+func getAndAddInt(this, o, offset int64, delta int32) (int32, error) {
+	var prev int64
+	err := withSlot(o, offset, func(slot *int64) {
+		for {
+			prev = atomic.LoadInt64(slot)
+			if atomic.CompareAndSwapInt64(slot, prev, int64(int32(prev)+delta)) {
+				return
+			}
+		}
+	})
+	return int32(prev), err
+}
+
+// Unsafe.getAndAddLong(Object o, long offset, long delta) returns the previous value.
+func getAndAddLong(this, o, offset, delta int64) (int64, error) {
+	var prev int64
+	err := withSlot(o, offset, func(slot *int64) {
+		prev = atomic.AddInt64(slot, delta) - delta
+	})
+	return prev, err
+}
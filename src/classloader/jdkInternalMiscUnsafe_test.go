@@ -0,0 +1,154 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCompareAndSetInt(t *testing.T) {
+	ref := NewObject("test/Counter", 2)
+	GetObject(ref).Fields[1] = 10
+
+	if swapped, _ := compareAndSetInt(0, ref, 1, 9, 11); swapped {
+		t.Errorf("compareAndSetInt succeeded with wrong expected value")
+	}
+	if swapped, _ := compareAndSetInt(0, ref, 1, 10, 11); !swapped {
+		t.Errorf("compareAndSetInt failed with correct expected value")
+	}
+	if GetObject(ref).Fields[1] != 11 {
+		t.Errorf("Expected field value of 11 after CAS, got: %d", GetObject(ref).Fields[1])
+	}
+}
+
+func TestCompareAndSetOnInvalidObject(t *testing.T) {
+	if _, err := compareAndSetLong(0, 0, 0, 0, 1); err == nil || err.Error() != "java.lang.NullPointerException" {
+		t.Errorf("Expected a NullPointerException for a null reference, got %v", err)
+	}
+	ref := NewObject("test/Counter", 1)
+	if _, err := compareAndSetLong(0, ref, 5, 0, 1); err == nil ||
+		err.Error() != "java.lang.InternalError: bad offset 5 in test/Counter" {
+		t.Errorf("Expected an InternalError for an out-of-range offset, got %v", err)
+	}
+	if _, err := getAndAddInt(0, ref, -1, 1); err == nil {
+		t.Errorf("Expected an InternalError for a negative offset")
+	}
+}
+
+func TestGetAndAddIntWrapsAt32Bits(t *testing.T) {
+	ref := NewObject("test/Counter", 1)
+	GetObject(ref).Fields[0] = 0x7FFFFFFF
+
+	prev, _ := getAndAddInt(0, ref, 0, 1)
+	if prev != 0x7FFFFFFF {
+		t.Errorf("Expected previous value of 0x7FFFFFFF, got: 0x%x", prev)
+	}
+	if GetObject(ref).Fields[0] != -0x80000000 {
+		t.Errorf("Expected int overflow to wrap to MIN_VALUE, got: %d", GetObject(ref).Fields[0])
+	}
+}
+
+func TestGetAndAddLongConcurrent(t *testing.T) {
+	ref := NewObject("test/Counter", 1)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				getAndAddLong(0, ref, 0, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if GetObject(ref).Fields[0] != 8000 {
+		t.Errorf("Expected 8000 after concurrent getAndAddLong, got: %d", GetObject(ref).Fields[0])
+	}
+}
+
+// the natives are registered with the signatures of their descriptors, and reached
+// through the registry with the slots of the operand stack
+func TestUnsafeNativesRegistered(t *testing.T) {
+	Load_Lang_Reflect()
+	Load_Misc_Unsafe()
+	addTestClass("test/Counter", "", nil, []testMember{{0x0002, "count", "I"}}, nil)
+	ref := NewObject("test/Counter", 1)
+
+	if callNative(t, "jdk/internal/misc/Unsafe.compareAndSetInt(Ljava/lang/Object;JII)Z",
+		int64(0), ref, int64(0), int64(0), int64(-1)) != int64(1) || GetObject(ref).Fields[0] != -1 {
+		t.Errorf("Expected compareAndSetInt to set the field to -1, got %d", GetObject(ref).Fields[0])
+	}
+	if prev := callNative(t, "jdk/internal/misc/Unsafe.getAndAddInt(Ljava/lang/Object;JI)I",
+		int64(0), ref, int64(0), int64(2)); prev != int64(-1) || GetObject(ref).Fields[0] != 1 {
+		t.Errorf("Expected getAndAddInt to return -1 and leave 1, got %v and %d", prev, GetObject(ref).Fields[0])
+	}
+	for _, fqn := range []string{"compareAndSetLong(Ljava/lang/Object;JJJ)Z",
+		"compareAndSetReference(Ljava/lang/Object;JLjava/lang/Object;Ljava/lang/Object;)Z",
+		"getAndAddLong(Ljava/lang/Object;JJ)J"} {
+		if _, ok := LookupNative("jdk/internal/misc/Unsafe." + fqn); !ok {
+			t.Errorf("Expected Unsafe.%s to be registered", fqn)
+		}
+	}
+
+	class := ClassObject("test/Counter")
+	offset1 := "jdk/internal/misc/Unsafe.objectFieldOffset1(Ljava/lang/Class;Ljava/lang/String;)J"
+	if offset := callNative(t, offset1, int64(0), class, NewStringObject("count")); offset != int64(0) {
+		t.Errorf("Expected the offset of count to be 0, got %v", offset)
+	}
+	err, _ := callNative(t, offset1, int64(0), class, NewStringObject("missing")).(error)
+	if err == nil || err.Error() != "java.lang.InternalError: missing" {
+		t.Errorf("Expected an InternalError for a field that isn't there, got %v", err)
+	}
+}
+
+func TestCompareAndSetArrayElementsAndStatics(t *testing.T) {
+	Load_Lang_Reflect()
+	Load_Misc_Unsafe()
+	unsafe := "jdk/internal/misc/Unsafe."
+
+	// array elements, at offset base + index * scale
+	base := callNative(t, unsafe+"arrayBaseOffset(Ljava/lang/Class;)I", int64(0), ClassObject("[J"))
+	scale := callNative(t, unsafe+"arrayIndexScale(Ljava/lang/Class;)I", int64(0), ClassObject("[J"))
+	if base != int64(0) || scale != int64(1) {
+		t.Fatalf("Expected a base offset of 0 and a scale of 1, got %v and %v", base, scale)
+	}
+	array := NewPrimitiveArray("J", []int64{1, 2, 3})
+	if swapped, err := compareAndSetLong(0, array, 2, 3, 30); !swapped || err != nil {
+		t.Errorf("Expected the CAS of element 2 to succeed, got %v (%v)", swapped, err)
+	}
+	if elems, _ := RefArrayFromRef(array); elems[2] != 30 {
+		t.Errorf("Expected element 2 to be 30, got %d", elems[2])
+	}
+	if _, err := compareAndSetLong(0, array, 3, 0, 1); err == nil {
+		t.Errorf("Expected an InternalError for an offset past the end of the array")
+	}
+	err, _ := callNative(t, unsafe+"arrayIndexScale(Ljava/lang/Class;)I", int64(0), ClassObject("test/Counter")).(error)
+	if err == nil || err.Error() != "java.lang.IllegalArgumentException: not an array class: test/Counter" {
+		t.Errorf("Expected an IllegalArgumentException for a class that isn't an array, got %v", err)
+	}
+
+	// static fields, whose base is the Class object
+	addTestClass("test/Sequence", "", nil, []testMember{{0x0048, "next", "J"}, {0x0002, "own", "I"}}, nil)
+	fields, _ := RefArrayFromRef(callNative(t, "java/lang/Class.getDeclaredFields()[Ljava/lang/reflect/Field;",
+		ClassObject("test/Sequence")).(int64))
+	staticBase := callNative(t, unsafe+"staticFieldBase(Ljava/lang/reflect/Field;)Ljava/lang/Object;", int64(0), fields[0])
+	offset := callNative(t, unsafe+"staticFieldOffset(Ljava/lang/reflect/Field;)J", int64(0), fields[0])
+	if staticBase != ClassObject("test/Sequence") {
+		t.Fatalf("Expected the static field base to be the Class object, got %v", staticBase)
+	}
+	if prev, err := getAndAddLong(0, staticBase.(int64), offset.(int64), 5); prev != 0 || err != nil {
+		t.Errorf("Expected getAndAddLong on the static to return 0, got %d (%v)", prev, err)
+	}
+	if v := LoadStaticInt(offset.(int64)); v != 5 {
+		t.Errorf("Expected the static to be 5, got %d", v)
+	}
+	err, _ = callNative(t, unsafe+"staticFieldOffset(Ljava/lang/reflect/Field;)J", int64(0), fields[1]).(error)
+	if err == nil || err.Error() != "java.lang.IllegalArgumentException: not a static field" {
+		t.Errorf("Expected an IllegalArgumentException for an instance field, got %v", err)
+	}
+}
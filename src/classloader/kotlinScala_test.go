@@ -0,0 +1,353 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// These tests load whole classes with the idioms that the Kotlin and Scala compilers
+// emit, and that javac doesn't: names with $ and spaces, synthetic and bridge methods,
+// MethodParameters flags other than final, and invokedynamic for string concatenation,
+// for lambdas, and for lambda deserialization. The classes in the arrays below weren't
+// produced by kotlinc and scalac: they were assembled by hand, with those idioms, after
+// the classes that the compilers produce, and are held in arrays, as HaveInterface.class
+// is in parsingFullClass_test.go. The classes that kotlinc and scalac do produce from
+// the same sources, which are in testdata/kotlin and testdata/scala, are loaded by
+// TestLoadCompiledKotlinAndScala once they've been compiled there.
+
+// kotlinGreeterClass is a hand-assembled Greeter.class, after the one that kotlinc
+// (with -Xlambdas=indy and a JVM target of 11) compiles from this source:
+//
+//	class Greeter(private val name: String) : (String) -> String {
+//	    override fun invoke(greeting: String) = "$greeting, $name"
+//	    fun `greets the world`() = { invoke("Hello") }
+//	}
+//
+// Besides the two invokedynamics, it has the bridge method invoke(Object), the synthetic
+// methods `greets the world$lambda$0` and access$getName$p, and the kotlin.Metadata
+// annotation. (Its Metadata has no elements, since the loader doesn't read them.)
+var kotlinGreeterClass = []byte{
+	0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x00, 0x00, 0x37, 0x00, 0x49, 0x01, 0x00, 0x24, 0x6A, 0x61, 0x76,
+	0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x53, 0x74,
+	0x72, 0x69, 0x6E, 0x67, 0x43, 0x6F, 0x6E, 0x63, 0x61, 0x74, 0x46, 0x61, 0x63, 0x74, 0x6F, 0x72,
+	0x79, 0x07, 0x00, 0x01, 0x01, 0x00, 0x17, 0x6D, 0x61, 0x6B, 0x65, 0x43, 0x6F, 0x6E, 0x63, 0x61,
+	0x74, 0x57, 0x69, 0x74, 0x68, 0x43, 0x6F, 0x6E, 0x73, 0x74, 0x61, 0x6E, 0x74, 0x73, 0x01, 0x00,
+	0x98, 0x28, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76,
+	0x6F, 0x6B, 0x65, 0x2F, 0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64, 0x48, 0x61, 0x6E, 0x64, 0x6C, 0x65,
+	0x73, 0x24, 0x4C, 0x6F, 0x6F, 0x6B, 0x75, 0x70, 0x3B, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C,
+	0x61, 0x6E, 0x67, 0x2F, 0x53, 0x74, 0x72, 0x69, 0x6E, 0x67, 0x3B, 0x4C, 0x6A, 0x61, 0x76, 0x61,
+	0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x4D, 0x65, 0x74,
+	0x68, 0x6F, 0x64, 0x54, 0x79, 0x70, 0x65, 0x3B, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61,
+	0x6E, 0x67, 0x2F, 0x53, 0x74, 0x72, 0x69, 0x6E, 0x67, 0x3B, 0x5B, 0x4C, 0x6A, 0x61, 0x76, 0x61,
+	0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x4F, 0x62, 0x6A, 0x65, 0x63, 0x74, 0x3B, 0x29, 0x4C, 0x6A,
+	0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F,
+	0x43, 0x61, 0x6C, 0x6C, 0x53, 0x69, 0x74, 0x65, 0x3B, 0x0C, 0x00, 0x03, 0x00, 0x04, 0x0A, 0x00,
+	0x02, 0x00, 0x05, 0x0F, 0x06, 0x00, 0x06, 0x01, 0x00, 0x22, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C,
+	0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x4C, 0x61, 0x6D, 0x62, 0x64,
+	0x61, 0x4D, 0x65, 0x74, 0x61, 0x66, 0x61, 0x63, 0x74, 0x6F, 0x72, 0x79, 0x07, 0x00, 0x08, 0x01,
+	0x00, 0x0B, 0x6D, 0x65, 0x74, 0x61, 0x66, 0x61, 0x63, 0x74, 0x6F, 0x72, 0x79, 0x01, 0x00, 0xCC,
+	0x28, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F,
+	0x6B, 0x65, 0x2F, 0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64, 0x48, 0x61, 0x6E, 0x64, 0x6C, 0x65, 0x73,
+	0x24, 0x4C, 0x6F, 0x6F, 0x6B, 0x75, 0x70, 0x3B, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61,
+	0x6E, 0x67, 0x2F, 0x53, 0x74, 0x72, 0x69, 0x6E, 0x67, 0x3B, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F,
+	0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x4D, 0x65, 0x74, 0x68,
+	0x6F, 0x64, 0x54, 0x79, 0x70, 0x65, 0x3B, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E,
+	0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64, 0x54,
+	0x79, 0x70, 0x65, 0x3B, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69,
+	0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64, 0x48, 0x61, 0x6E, 0x64,
+	0x6C, 0x65, 0x3B, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E,
+	0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64, 0x54, 0x79, 0x70, 0x65, 0x3B,
+	0x29, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F,
+	0x6B, 0x65, 0x2F, 0x43, 0x61, 0x6C, 0x6C, 0x53, 0x69, 0x74, 0x65, 0x3B, 0x0C, 0x00, 0x0A, 0x00,
+	0x0B, 0x0A, 0x00, 0x09, 0x00, 0x0C, 0x0F, 0x06, 0x00, 0x0D, 0x01, 0x00, 0x04, 0x01, 0x2C, 0x20,
+	0x01, 0x08, 0x00, 0x0F, 0x01, 0x00, 0x14, 0x28, 0x29, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C,
+	0x61, 0x6E, 0x67, 0x2F, 0x4F, 0x62, 0x6A, 0x65, 0x63, 0x74, 0x3B, 0x10, 0x00, 0x11, 0x01, 0x00,
+	0x07, 0x47, 0x72, 0x65, 0x65, 0x74, 0x65, 0x72, 0x07, 0x00, 0x13, 0x01, 0x00, 0x19, 0x67, 0x72,
+	0x65, 0x65, 0x74, 0x73, 0x20, 0x74, 0x68, 0x65, 0x20, 0x77, 0x6F, 0x72, 0x6C, 0x64, 0x24, 0x6C,
+	0x61, 0x6D, 0x62, 0x64, 0x61, 0x24, 0x30, 0x01, 0x00, 0x1D, 0x28, 0x4C, 0x47, 0x72, 0x65, 0x65,
+	0x74, 0x65, 0x72, 0x3B, 0x29, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F,
+	0x4F, 0x62, 0x6A, 0x65, 0x63, 0x74, 0x3B, 0x0C, 0x00, 0x15, 0x00, 0x16, 0x0A, 0x00, 0x14, 0x00,
+	0x17, 0x0F, 0x06, 0x00, 0x18, 0x01, 0x00, 0x10, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E,
+	0x67, 0x2F, 0x4F, 0x62, 0x6A, 0x65, 0x63, 0x74, 0x07, 0x00, 0x1A, 0x01, 0x00, 0x1E, 0x6B, 0x6F,
+	0x74, 0x6C, 0x69, 0x6E, 0x2F, 0x6A, 0x76, 0x6D, 0x2F, 0x66, 0x75, 0x6E, 0x63, 0x74, 0x69, 0x6F,
+	0x6E, 0x73, 0x2F, 0x46, 0x75, 0x6E, 0x63, 0x74, 0x69, 0x6F, 0x6E, 0x31, 0x07, 0x00, 0x1C, 0x01,
+	0x00, 0x04, 0x6E, 0x61, 0x6D, 0x65, 0x01, 0x00, 0x12, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C,
+	0x61, 0x6E, 0x67, 0x2F, 0x53, 0x74, 0x72, 0x69, 0x6E, 0x67, 0x3B, 0x01, 0x00, 0x06, 0x3C, 0x69,
+	0x6E, 0x69, 0x74, 0x3E, 0x01, 0x00, 0x03, 0x28, 0x29, 0x56, 0x0C, 0x00, 0x20, 0x00, 0x21, 0x0A,
+	0x00, 0x1B, 0x00, 0x22, 0x0C, 0x00, 0x1E, 0x00, 0x1F, 0x09, 0x00, 0x14, 0x00, 0x24, 0x01, 0x00,
+	0x04, 0x43, 0x6F, 0x64, 0x65, 0x01, 0x00, 0x10, 0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64, 0x50, 0x61,
+	0x72, 0x61, 0x6D, 0x65, 0x74, 0x65, 0x72, 0x73, 0x01, 0x00, 0x15, 0x28, 0x4C, 0x6A, 0x61, 0x76,
+	0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x53, 0x74, 0x72, 0x69, 0x6E, 0x67, 0x3B, 0x29, 0x56,
+	0x01, 0x00, 0x38, 0x28, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x53,
+	0x74, 0x72, 0x69, 0x6E, 0x67, 0x3B, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67,
+	0x2F, 0x53, 0x74, 0x72, 0x69, 0x6E, 0x67, 0x3B, 0x29, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C,
+	0x61, 0x6E, 0x67, 0x2F, 0x53, 0x74, 0x72, 0x69, 0x6E, 0x67, 0x3B, 0x0C, 0x00, 0x03, 0x00, 0x29,
+	0x12, 0x00, 0x00, 0x00, 0x2A, 0x01, 0x00, 0x06, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x01, 0x00,
+	0x26, 0x28, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x53, 0x74, 0x72,
+	0x69, 0x6E, 0x67, 0x3B, 0x29, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F,
+	0x53, 0x74, 0x72, 0x69, 0x6E, 0x67, 0x3B, 0x01, 0x00, 0x10, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C,
+	0x61, 0x6E, 0x67, 0x2F, 0x53, 0x74, 0x72, 0x69, 0x6E, 0x67, 0x07, 0x00, 0x2E, 0x0C, 0x00, 0x2C,
+	0x00, 0x2D, 0x0A, 0x00, 0x14, 0x00, 0x30, 0x01, 0x00, 0x26, 0x28, 0x4C, 0x6A, 0x61, 0x76, 0x61,
+	0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x4F, 0x62, 0x6A, 0x65, 0x63, 0x74, 0x3B, 0x29, 0x4C, 0x6A,
+	0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x4F, 0x62, 0x6A, 0x65, 0x63, 0x74, 0x3B,
+	0x01, 0x00, 0x2B, 0x28, 0x4C, 0x47, 0x72, 0x65, 0x65, 0x74, 0x65, 0x72, 0x3B, 0x29, 0x4C, 0x6B,
+	0x6F, 0x74, 0x6C, 0x69, 0x6E, 0x2F, 0x6A, 0x76, 0x6D, 0x2F, 0x66, 0x75, 0x6E, 0x63, 0x74, 0x69,
+	0x6F, 0x6E, 0x73, 0x2F, 0x46, 0x75, 0x6E, 0x63, 0x74, 0x69, 0x6F, 0x6E, 0x30, 0x3B, 0x0C, 0x00,
+	0x2C, 0x00, 0x33, 0x12, 0x00, 0x01, 0x00, 0x34, 0x01, 0x00, 0x10, 0x67, 0x72, 0x65, 0x65, 0x74,
+	0x73, 0x20, 0x74, 0x68, 0x65, 0x20, 0x77, 0x6F, 0x72, 0x6C, 0x64, 0x01, 0x00, 0x22, 0x28, 0x29,
+	0x4C, 0x6B, 0x6F, 0x74, 0x6C, 0x69, 0x6E, 0x2F, 0x6A, 0x76, 0x6D, 0x2F, 0x66, 0x75, 0x6E, 0x63,
+	0x74, 0x69, 0x6F, 0x6E, 0x73, 0x2F, 0x46, 0x75, 0x6E, 0x63, 0x74, 0x69, 0x6F, 0x6E, 0x30, 0x3B,
+	0x01, 0x00, 0x05, 0x48, 0x65, 0x6C, 0x6C, 0x6F, 0x08, 0x00, 0x38, 0x01, 0x00, 0x06, 0x74, 0x68,
+	0x69, 0x73, 0x24, 0x30, 0x01, 0x00, 0x05, 0x24, 0x74, 0x68, 0x69, 0x73, 0x01, 0x00, 0x10, 0x61,
+	0x63, 0x63, 0x65, 0x73, 0x73, 0x24, 0x67, 0x65, 0x74, 0x4E, 0x61, 0x6D, 0x65, 0x24, 0x70, 0x01,
+	0x00, 0x1D, 0x28, 0x4C, 0x47, 0x72, 0x65, 0x65, 0x74, 0x65, 0x72, 0x3B, 0x29, 0x4C, 0x6A, 0x61,
+	0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x53, 0x74, 0x72, 0x69, 0x6E, 0x67, 0x3B, 0x01,
+	0x00, 0x0A, 0x47, 0x72, 0x65, 0x65, 0x74, 0x65, 0x72, 0x2E, 0x6B, 0x74, 0x01, 0x00, 0x0A, 0x53,
+	0x6F, 0x75, 0x72, 0x63, 0x65, 0x46, 0x69, 0x6C, 0x65, 0x01, 0x00, 0x11, 0x4C, 0x6B, 0x6F, 0x74,
+	0x6C, 0x69, 0x6E, 0x2F, 0x4D, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x3B, 0x01, 0x00, 0x19,
+	0x52, 0x75, 0x6E, 0x74, 0x69, 0x6D, 0x65, 0x56, 0x69, 0x73, 0x69, 0x62, 0x6C, 0x65, 0x41, 0x6E,
+	0x6E, 0x6F, 0x74, 0x61, 0x74, 0x69, 0x6F, 0x6E, 0x73, 0x01, 0x00, 0x25, 0x6A, 0x61, 0x76, 0x61,
+	0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x4D, 0x65, 0x74,
+	0x68, 0x6F, 0x64, 0x48, 0x61, 0x6E, 0x64, 0x6C, 0x65, 0x73, 0x24, 0x4C, 0x6F, 0x6F, 0x6B, 0x75,
+	0x70, 0x07, 0x00, 0x42, 0x01, 0x00, 0x1E, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67,
+	0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64, 0x48, 0x61,
+	0x6E, 0x64, 0x6C, 0x65, 0x73, 0x07, 0x00, 0x44, 0x01, 0x00, 0x06, 0x4C, 0x6F, 0x6F, 0x6B, 0x75,
+	0x70, 0x01, 0x00, 0x0C, 0x49, 0x6E, 0x6E, 0x65, 0x72, 0x43, 0x6C, 0x61, 0x73, 0x73, 0x65, 0x73,
+	0x01, 0x00, 0x10, 0x42, 0x6F, 0x6F, 0x74, 0x73, 0x74, 0x72, 0x61, 0x70, 0x4D, 0x65, 0x74, 0x68,
+	0x6F, 0x64, 0x73, 0x00, 0x31, 0x00, 0x14, 0x00, 0x1B, 0x00, 0x01, 0x00, 0x1D, 0x00, 0x01, 0x00,
+	0x12, 0x00, 0x1E, 0x00, 0x1F, 0x00, 0x00, 0x00, 0x06, 0x00, 0x01, 0x00, 0x20, 0x00, 0x28, 0x00,
+	0x02, 0x00, 0x26, 0x00, 0x00, 0x00, 0x16, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00, 0x0A, 0x2A,
+	0xB7, 0x00, 0x23, 0x2A, 0x2B, 0xB5, 0x00, 0x25, 0xB1, 0x00, 0x00, 0x00, 0x00, 0x00, 0x27, 0x00,
+	0x00, 0x00, 0x05, 0x01, 0x00, 0x1E, 0x00, 0x00, 0x00, 0x11, 0x00, 0x2C, 0x00, 0x2D, 0x00, 0x01,
+	0x00, 0x26, 0x00, 0x00, 0x00, 0x17, 0x00, 0x02, 0x00, 0x02, 0x00, 0x00, 0x00, 0x0B, 0x2B, 0x2A,
+	0xB4, 0x00, 0x25, 0xBA, 0x00, 0x2B, 0x00, 0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x10, 0x41, 0x00,
+	0x2C, 0x00, 0x32, 0x00, 0x01, 0x00, 0x26, 0x00, 0x00, 0x00, 0x15, 0x00, 0x02, 0x00, 0x02, 0x00,
+	0x00, 0x00, 0x09, 0x2A, 0x2B, 0xC0, 0x00, 0x2F, 0xB6, 0x00, 0x31, 0xB0, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x11, 0x00, 0x36, 0x00, 0x37, 0x00, 0x01, 0x00, 0x26, 0x00, 0x00, 0x00, 0x13, 0x00, 0x01,
+	0x00, 0x01, 0x00, 0x00, 0x00, 0x07, 0x2A, 0xBA, 0x00, 0x35, 0x00, 0x00, 0xB0, 0x00, 0x00, 0x00,
+	0x00, 0x10, 0x1A, 0x00, 0x15, 0x00, 0x16, 0x00, 0x02, 0x00, 0x26, 0x00, 0x00, 0x00, 0x13, 0x00,
+	0x02, 0x00, 0x01, 0x00, 0x00, 0x00, 0x07, 0x2A, 0x12, 0x39, 0xB6, 0x00, 0x31, 0xB0, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x27, 0x00, 0x00, 0x00, 0x05, 0x01, 0x00, 0x3A, 0x10, 0x10, 0x10, 0x19, 0x00,
+	0x3C, 0x00, 0x3D, 0x00, 0x02, 0x00, 0x26, 0x00, 0x00, 0x00, 0x11, 0x00, 0x01, 0x00, 0x01, 0x00,
+	0x00, 0x00, 0x05, 0x2A, 0xB4, 0x00, 0x25, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x27, 0x00, 0x00,
+	0x00, 0x05, 0x01, 0x00, 0x3B, 0x10, 0x00, 0x00, 0x04, 0x00, 0x3F, 0x00, 0x00, 0x00, 0x02, 0x00,
+	0x3E, 0x00, 0x41, 0x00, 0x00, 0x00, 0x06, 0x00, 0x01, 0x00, 0x40, 0x00, 0x00, 0x00, 0x47, 0x00,
+	0x00, 0x00, 0x0A, 0x00, 0x01, 0x00, 0x43, 0x00, 0x45, 0x00, 0x46, 0x00, 0x19, 0x00, 0x48, 0x00,
+	0x00, 0x00, 0x12, 0x00, 0x02, 0x00, 0x07, 0x00, 0x01, 0x00, 0x10, 0x00, 0x0E, 0x00, 0x03, 0x00,
+	0x12, 0x00, 0x19, 0x00, 0x12,
+}
+
+// scalaScoresClass is a hand-assembled Scores$.class, after the module class of this
+// Scala object that scalac (2.12 or later) compiles:
+//
+//	object Scores {
+//	    def ++(a: Int, b: Int): Int = a + b
+//	    def adder: (Int, Int) => Int = _ + _
+//	}
+//
+// It has the MODULE$ field, the mangled $plus$plus, the lambda body $anonfun$adder$1,
+// and $deserializeLambda$, whose invokedynamic is bootstrapped by LambdaDeserialize. The
+// lambda is made by LambdaMetafactory.altMetafactory, with an Integer of flags among its
+// bootstrap arguments. (Its ScalaInlineInfo attribute, which the loader skips, is a stub.)
+var scalaScoresClass = []byte{
+	0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x00, 0x00, 0x34, 0x00, 0x37, 0x01, 0x00, 0x22, 0x6A, 0x61, 0x76,
+	0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x4C, 0x61,
+	0x6D, 0x62, 0x64, 0x61, 0x4D, 0x65, 0x74, 0x61, 0x66, 0x61, 0x63, 0x74, 0x6F, 0x72, 0x79, 0x07,
+	0x00, 0x01, 0x01, 0x00, 0x0E, 0x61, 0x6C, 0x74, 0x4D, 0x65, 0x74, 0x61, 0x66, 0x61, 0x63, 0x74,
+	0x6F, 0x72, 0x79, 0x01, 0x00, 0x86, 0x28, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E,
+	0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64, 0x48,
+	0x61, 0x6E, 0x64, 0x6C, 0x65, 0x73, 0x24, 0x4C, 0x6F, 0x6F, 0x6B, 0x75, 0x70, 0x3B, 0x4C, 0x6A,
+	0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x53, 0x74, 0x72, 0x69, 0x6E, 0x67, 0x3B,
+	0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B,
+	0x65, 0x2F, 0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64, 0x54, 0x79, 0x70, 0x65, 0x3B, 0x5B, 0x4C, 0x6A,
+	0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x4F, 0x62, 0x6A, 0x65, 0x63, 0x74, 0x3B,
+	0x29, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F,
+	0x6B, 0x65, 0x2F, 0x43, 0x61, 0x6C, 0x6C, 0x53, 0x69, 0x74, 0x65, 0x3B, 0x0C, 0x00, 0x03, 0x00,
+	0x04, 0x0A, 0x00, 0x02, 0x00, 0x05, 0x0F, 0x06, 0x00, 0x06, 0x01, 0x00, 0x1F, 0x73, 0x63, 0x61,
+	0x6C, 0x61, 0x2F, 0x72, 0x75, 0x6E, 0x74, 0x69, 0x6D, 0x65, 0x2F, 0x4C, 0x61, 0x6D, 0x62, 0x64,
+	0x61, 0x44, 0x65, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6C, 0x69, 0x7A, 0x65, 0x07, 0x00, 0x08, 0x01,
+	0x00, 0x09, 0x62, 0x6F, 0x6F, 0x74, 0x73, 0x74, 0x72, 0x61, 0x70, 0x01, 0x00, 0x93, 0x28, 0x4C,
+	0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65,
+	0x2F, 0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64, 0x48, 0x61, 0x6E, 0x64, 0x6C, 0x65, 0x73, 0x24, 0x4C,
+	0x6F, 0x6F, 0x6B, 0x75, 0x70, 0x3B, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67,
+	0x2F, 0x53, 0x74, 0x72, 0x69, 0x6E, 0x67, 0x3B, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61,
+	0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64,
+	0x54, 0x79, 0x70, 0x65, 0x3B, 0x5B, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67,
+	0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64, 0x48, 0x61,
+	0x6E, 0x64, 0x6C, 0x65, 0x3B, 0x29, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67,
+	0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B, 0x65, 0x2F, 0x43, 0x61, 0x6C, 0x6C, 0x53, 0x69, 0x74, 0x65,
+	0x3B, 0x0C, 0x00, 0x0A, 0x00, 0x0B, 0x0A, 0x00, 0x09, 0x00, 0x0C, 0x0F, 0x06, 0x00, 0x0D, 0x01,
+	0x00, 0x07, 0x53, 0x63, 0x6F, 0x72, 0x65, 0x73, 0x24, 0x07, 0x00, 0x0F, 0x01, 0x00, 0x10, 0x24,
+	0x61, 0x6E, 0x6F, 0x6E, 0x66, 0x75, 0x6E, 0x24, 0x61, 0x64, 0x64, 0x65, 0x72, 0x24, 0x31, 0x01,
+	0x00, 0x05, 0x28, 0x49, 0x49, 0x29, 0x49, 0x0C, 0x00, 0x11, 0x00, 0x12, 0x0A, 0x00, 0x10, 0x00,
+	0x13, 0x0F, 0x06, 0x00, 0x14, 0x10, 0x00, 0x12, 0x03, 0x00, 0x00, 0x00, 0x01, 0x01, 0x00, 0x10,
+	0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x4F, 0x62, 0x6A, 0x65, 0x63, 0x74,
+	0x07, 0x00, 0x18, 0x01, 0x00, 0x07, 0x4D, 0x4F, 0x44, 0x55, 0x4C, 0x45, 0x24, 0x01, 0x00, 0x09,
+	0x4C, 0x53, 0x63, 0x6F, 0x72, 0x65, 0x73, 0x24, 0x3B, 0x01, 0x00, 0x06, 0x3C, 0x69, 0x6E, 0x69,
+	0x74, 0x3E, 0x01, 0x00, 0x03, 0x28, 0x29, 0x56, 0x0C, 0x00, 0x1C, 0x00, 0x1D, 0x0A, 0x00, 0x10,
+	0x00, 0x1E, 0x0C, 0x00, 0x1A, 0x00, 0x1B, 0x09, 0x00, 0x10, 0x00, 0x20, 0x01, 0x00, 0x04, 0x43,
+	0x6F, 0x64, 0x65, 0x01, 0x00, 0x08, 0x3C, 0x63, 0x6C, 0x69, 0x6E, 0x69, 0x74, 0x3E, 0x0A, 0x00,
+	0x19, 0x00, 0x1E, 0x01, 0x00, 0x0A, 0x24, 0x70, 0x6C, 0x75, 0x73, 0x24, 0x70, 0x6C, 0x75, 0x73,
+	0x01, 0x00, 0x0E, 0x61, 0x70, 0x70, 0x6C, 0x79, 0x24, 0x6D, 0x63, 0x49, 0x49, 0x49, 0x24, 0x73,
+	0x70, 0x01, 0x00, 0x13, 0x28, 0x29, 0x4C, 0x73, 0x63, 0x61, 0x6C, 0x61, 0x2F, 0x46, 0x75, 0x6E,
+	0x63, 0x74, 0x69, 0x6F, 0x6E, 0x32, 0x3B, 0x0C, 0x00, 0x26, 0x00, 0x27, 0x12, 0x00, 0x00, 0x00,
+	0x28, 0x01, 0x00, 0x05, 0x61, 0x64, 0x64, 0x65, 0x72, 0x01, 0x00, 0x03, 0x78, 0x24, 0x31, 0x01,
+	0x00, 0x03, 0x78, 0x24, 0x32, 0x01, 0x00, 0x10, 0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64, 0x50, 0x61,
+	0x72, 0x61, 0x6D, 0x65, 0x74, 0x65, 0x72, 0x73, 0x01, 0x00, 0x11, 0x6C, 0x61, 0x6D, 0x62, 0x64,
+	0x61, 0x44, 0x65, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6C, 0x69, 0x7A, 0x65, 0x01, 0x00, 0x37, 0x28,
+	0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x69, 0x6E, 0x76, 0x6F, 0x6B,
+	0x65, 0x2F, 0x53, 0x65, 0x72, 0x69, 0x61, 0x6C, 0x69, 0x7A, 0x65, 0x64, 0x4C, 0x61, 0x6D, 0x62,
+	0x64, 0x61, 0x3B, 0x29, 0x4C, 0x6A, 0x61, 0x76, 0x61, 0x2F, 0x6C, 0x61, 0x6E, 0x67, 0x2F, 0x4F,
+	0x62, 0x6A, 0x65, 0x63, 0x74, 0x3B, 0x0C, 0x00, 0x2E, 0x00, 0x2F, 0x12, 0x00, 0x01, 0x00, 0x30,
+	0x01, 0x00, 0x13, 0x24, 0x64, 0x65, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6C, 0x69, 0x7A, 0x65, 0x4C,
+	0x61, 0x6D, 0x62, 0x64, 0x61, 0x24, 0x01, 0x00, 0x0C, 0x53, 0x63, 0x6F, 0x72, 0x65, 0x73, 0x2E,
+	0x73, 0x63, 0x61, 0x6C, 0x61, 0x01, 0x00, 0x0A, 0x53, 0x6F, 0x75, 0x72, 0x63, 0x65, 0x46, 0x69,
+	0x6C, 0x65, 0x01, 0x00, 0x0F, 0x53, 0x63, 0x61, 0x6C, 0x61, 0x49, 0x6E, 0x6C, 0x69, 0x6E, 0x65,
+	0x49, 0x6E, 0x66, 0x6F, 0x01, 0x00, 0x10, 0x42, 0x6F, 0x6F, 0x74, 0x73, 0x74, 0x72, 0x61, 0x70,
+	0x4D, 0x65, 0x74, 0x68, 0x6F, 0x64, 0x73, 0x00, 0x31, 0x00, 0x10, 0x00, 0x19, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x19, 0x00, 0x1A, 0x00, 0x1B, 0x00, 0x00, 0x00, 0x06, 0x00, 0x08, 0x00, 0x23, 0x00,
+	0x1D, 0x00, 0x01, 0x00, 0x22, 0x00, 0x00, 0x00, 0x17, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x0B, 0xBB, 0x00, 0x10, 0x59, 0xB7, 0x00, 0x1F, 0xB3, 0x00, 0x21, 0xB1, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x02, 0x00, 0x1C, 0x00, 0x1D, 0x00, 0x01, 0x00, 0x22, 0x00, 0x00, 0x00, 0x11, 0x00, 0x01,
+	0x00, 0x01, 0x00, 0x00, 0x00, 0x05, 0x2A, 0xB7, 0x00, 0x24, 0xB1, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x25, 0x00, 0x12, 0x00, 0x01, 0x00, 0x22, 0x00, 0x00, 0x00, 0x10, 0x00, 0x02, 0x00,
+	0x03, 0x00, 0x00, 0x00, 0x04, 0x1B, 0x1C, 0x60, 0xAC, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00,
+	0x2A, 0x00, 0x27, 0x00, 0x01, 0x00, 0x22, 0x00, 0x00, 0x00, 0x12, 0x00, 0x01, 0x00, 0x01, 0x00,
+	0x00, 0x00, 0x06, 0xBA, 0x00, 0x29, 0x00, 0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x10, 0x19, 0x00,
+	0x11, 0x00, 0x12, 0x00, 0x02, 0x00, 0x22, 0x00, 0x00, 0x00, 0x10, 0x00, 0x02, 0x00, 0x02, 0x00,
+	0x00, 0x00, 0x04, 0x1A, 0x1B, 0x60, 0xAC, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2D, 0x00, 0x00, 0x00,
+	0x09, 0x02, 0x00, 0x2B, 0x10, 0x10, 0x00, 0x2C, 0x10, 0x10, 0x10, 0x0A, 0x00, 0x32, 0x00, 0x2F,
+	0x00, 0x01, 0x00, 0x22, 0x00, 0x00, 0x00, 0x13, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x07,
+	0x2A, 0xBA, 0x00, 0x31, 0x00, 0x00, 0xB0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0x00, 0x34, 0x00,
+	0x00, 0x00, 0x02, 0x00, 0x33, 0x00, 0x35, 0x00, 0x00, 0x00, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00,
+	0x36, 0x00, 0x00, 0x00, 0x14, 0x00, 0x02, 0x00, 0x07, 0x00, 0x04, 0x00, 0x16, 0x00, 0x15, 0x00,
+	0x16, 0x00, 0x17, 0x00, 0x0E, 0x00, 0x01, 0x00, 0x15,
+}
+
+// loadKotlinOrScalaClass parses and format checks one of the classes above, and then
+// converts it to the form in which it's posted to the method area, as the loader does
+func loadKotlinOrScalaClass(t *testing.T, name string, bytes []byte) ParsedClass {
+	t.Helper()
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass, err := parse(bytes)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing %s: %v", name, err)
+	}
+	if err = formatCheckClass(&klass); err != nil {
+		t.Fatalf("%s failed format check: %v", name, err)
+	}
+	if kd := convertToPostableClass(&klass); kd.Name != name {
+		t.Errorf("Expected the posted class to be named %s. Got: %s", name, kd.Name)
+	}
+	return klass
+}
+
+// methodNamed returns the method of the class with the name and descriptor, or nil
+func methodNamed(klass *ParsedClass, name, desc string) *method {
+	for i := range klass.methods {
+		m := &klass.methods[i]
+		if klass.utf8Refs[m.name].content == name && klass.utf8Refs[m.description].content == desc {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestLoadKotlinClass(t *testing.T) {
+	klass := loadKotlinOrScalaClass(t, "Greeter", kotlinGreeterClass)
+
+	if klass.sourceFile != "Greeter.kt" {
+		t.Errorf("Expected a source file of Greeter.kt. Got: %s", klass.sourceFile)
+	}
+
+	if len(klass.bootstraps) != 2 || len(klass.invokeDynamics) != 2 {
+		t.Errorf("Expected 2 bootstrap methods and 2 invokedynamic entries. Got: %d and %d",
+			len(klass.bootstraps), len(klass.invokeDynamics))
+	}
+
+	bridge := methodNamed(&klass, "invoke", "(Ljava/lang/Object;)Ljava/lang/Object;")
+	if bridge == nil || bridge.accessFlags != 0x1041 {
+		t.Error("Expected the bridge method invoke(Object), which is public, bridge, and synthetic")
+	}
+
+	for _, name := range []string{"greets the world", "greets the world$lambda$0", "access$getName$p"} {
+		found := false
+		for _, m := range klass.methods {
+			if klass.utf8Refs[m.name].content == name {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected a method named '%s', but there's none", name)
+		}
+	}
+
+	lambda := methodNamed(&klass, "greets the world$lambda$0", "(LGreeter;)Ljava/lang/Object;")
+	if lambda == nil || len(lambda.parameters) != 1 || lambda.parameters[0].accessFlags != 0x1010 {
+		t.Error("Expected the lambda's parameter this$0 to be final and synthetic")
+	}
+
+	ctor := methodNamed(&klass, "<init>", "(Ljava/lang/String;)V")
+	if ctor == nil || len(ctor.parameters) != 1 || ctor.parameters[0].accessFlags != 0 {
+		t.Error("Expected the constructor's parameter name to have no access flags")
+	}
+}
+
+func TestLoadScalaClass(t *testing.T) {
+	klass := loadKotlinOrScalaClass(t, "Scores$", scalaScoresClass)
+
+	if klass.javaVersion != 52 {
+		t.Errorf("Expected Java version # of 52. Got: %d", klass.javaVersion)
+	}
+
+	if len(klass.fields) != 1 || klass.utf8Refs[klass.fields[0].name].content != "MODULE$" {
+		t.Error("Expected the single field MODULE$")
+	}
+
+	if methodNamed(&klass, "$plus$plus", "(II)I") == nil {
+		t.Error("Expected the method $plus$plus, which is ++ mangled")
+	}
+
+	deserialize := methodNamed(&klass, "$deserializeLambda$",
+		"(Ljava/lang/invoke/SerializedLambda;)Ljava/lang/Object;")
+	if deserialize == nil || deserialize.accessFlags != 0x100A {
+		t.Error("Expected the private, static, synthetic method $deserializeLambda$")
+	}
+
+	if len(klass.bootstraps) != 2 || len(klass.bootstraps[0].args) != 4 {
+		t.Error("Expected 2 bootstrap methods, the first of which (altMetafactory) has 4 arguments")
+	}
+}
+
+// TestLoadCompiledKotlinAndScala loads the classes that kotlinc and scalac compile from
+// the sources in testdata/kotlin and testdata/scala (see the comments there). The class
+// files aren't checked in, since the compilers aren't part of the build, so the test is
+// skipped for a class that hasn't been compiled.
+func TestLoadCompiledKotlinAndScala(t *testing.T) {
+	for _, c := range []struct{ dir, name string }{{"kotlin", "Greeter"}, {"scala", "Scores$"}} {
+		t.Run(c.name, func(t *testing.T) {
+			bytes, err := os.ReadFile(filepath.Join("..", "..", "testdata", c.dir, c.name+".class"))
+			if err != nil {
+				t.Skipf("testdata/%s/%s.class hasn't been compiled", c.dir, c.name)
+			}
+			klass := loadKotlinOrScalaClass(t, c.name, bytes)
+			if c.name == "Greeter" && methodNamed(&klass, "greets the world", "()Lkotlin/jvm/functions/Function0;") == nil {
+				t.Error("Expected the method `greets the world`")
+			}
+			if c.name == "Scores$" && methodNamed(&klass, "$plus$plus", "(II)I") == nil {
+				t.Error("Expected the method $plus$plus, which is ++ mangled")
+			}
+		})
+	}
+}
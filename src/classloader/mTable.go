@@ -7,6 +7,7 @@
 package classloader
 
 import (
+	"strings"
 	"sync"
 )
 
@@ -25,10 +26,41 @@ import (
 // the search goes to the class and faiing that to the superclass, etc. Once the
 // method is located it's added to the MTable so that all future invocations will
 // result in fast look-ups in the MTable.
+//
+// A method is keyed by its class and the Symbol of its name and descriptor (see
+// symbols.go), so the interpreter looks it up with the Symbol it resolved from the CP
+// rather than by building the string of its fully qualified name.
 var MTable = make(MT)
 
 // MT is a type alias for the MTable. It's simply syntactic sugar in context.
-type MT = map[string]MTentry
+type MT = map[MTkey]MTentry
+
+// MTkey is the key of a method in the MTable
+type MTkey struct {
+	Class string
+	Sym   Symbol
+}
+
+// MethodKey returns the MTable key of the method with the fully qualified name, as in
+// java/lang/String.length()I
+func MethodKey(methFQN string) MTkey {
+	paren := strings.IndexByte(methFQN, '(')
+	if paren < 0 {
+		paren = len(methFQN)
+	}
+	key := MTkey{}
+	dot := strings.LastIndexByte(methFQN[:paren], '.')
+	if dot >= 0 {
+		key.Class = methFQN[:dot]
+	}
+	key.Sym = SymbolFor(methFQN[dot+1:paren], methFQN[paren:])
+	return key
+}
+
+// String returns the fully qualified name of the method, as in java/lang/String.length()I
+func (k MTkey) String() string {
+	return k.Class + "." + k.Sym.String()
+}
 
 // MTentry is described in detail in the comments to MTable
 type MTentry struct {
@@ -36,7 +68,9 @@ type MTentry struct {
 	MType byte  // method type, G = Go method, J = Java method
 }
 
-// mData can be a GmEntry or a JmEntry (method in Go or Java, respectively)
+// mData can be a GmEntry or a *JmEntry (method in Go or Java, respectively). A Java
+// method is held by pointer, so the VM can keep what it derives from the method (such
+// as its decoded code) by the method.
 type mData interface{}
 
 // GmEntry is the entry in the MTable for Go functions. See MTable comments for details.
@@ -53,7 +87,7 @@ type JmEntry struct {
 	MaxStack    int
 	MaxLocals   int
 	Code        []byte
-	exceptions  []CodeException
+	Exceptions  []CodeException // the exception table, which locates the catch blocks
 	attribs     []Attr
 	params      []ParamAttrib
 	deprecated  bool
@@ -73,8 +107,46 @@ var MTmutex sync.Mutex
 // by calling the Load_* function in each of those files to load whatever Go functions
 // they make available.
 func MTableLoadNatives() {
-	loadlib(&MTable, Load_Io_PrintStream()) // load the java.io.prinstream golang functions
-	loadlib(&MTable, Load_Lang_System())    // load the java.lang.system golang functions
+	loadlib(&MTable, Load_Io_PrintStream())          // load the java.io.prinstream golang functions
+	loadlib(&MTable, Load_Lang_System())             // load the java.lang.system golang functions
+	loadlib(&MTable, Load_Misc_Unsafe())             // load the jdk.internal.misc.Unsafe CAS functions
+	loadlib(&MTable, Load_Lang_String())             // load the java.lang.String golang functions
+	loadlib(&MTable, Load_Lang_StringBuilder())      // load StringBuilder and StringBuffer functions
+	loadlib(&MTable, Load_Lang_Math())               // load the Math and StrictMath functions
+	loadlib(&MTable, Load_Util_Properties())         // load the java.util.Properties functions
+	loadlib(&MTable, Load_Lang_ProcessEnvironment()) // load the System.getenv() functions
+	loadlib(&MTable, Load_Io_File())                 // load the java.io.File functions
+	loadlib(&MTable, Load_Io_FileStreams())          // load the file stream and RandomAccessFile functions
+	loadlib(&MTable, Load_Misc_UnsafeMemory())       // load the Unsafe native memory functions
+	loadlib(&MTable, Load_Nio_ByteBuffer())          // load the java.nio.ByteBuffer functions
+	loadlib(&MTable, Load_Nio_FileChannel())         // load the FileChannel functions
+	loadlib(&MTable, Load_Io_Streams())              // load the InputStream and OutputStream functions
+	loadlib(&MTable, Load_Net_Socket())              // load the java.net socket functions
+	loadlib(&MTable, Load_Lang_Process())            // load the process creation functions
+	loadlib(&MTable, Load_Lang_Shutdown())           // load System.exit() and the shutdown hook functions
+	loadlib(&MTable, Load_Nio_Charset())             // load the java.nio.charset functions
+	loadlib(&MTable, Load_Util_Random())             // load the random number functions
+	loadlib(&MTable, Load_Lang_Class())              // load the java.lang.Class functions
+	loadlib(&MTable, Load_Lang_NativeLibraries())    // load System.load(), loadLibrary(), etc.
+	loadlib(&MTable, Load_Util_Optional())           // load the java.util.Optional functions
+	loadlib(&MTable, Load_Lang_Foreign())            // load the java.lang.foreign (FFM API) functions
+	loadlib(&MTable, Load_Io_Console())              // load the java.io.Console functions
+	loadlib(&MTable, Load_Util_TimeZone())           // load the clock and time zone functions
+	loadlib(&MTable, Load_Util_Stream())             // load the Go-backed Stream functions
+	loadlib(&MTable, Load_Util_CompletableFuture())  // load the CompletableFuture functions
+	loadlib(&MTable, Load_Lang_ProcessHandle())      // load the ProcessHandle functions
+	loadlib(&MTable, Load_Misc_Signal())             // load the OS signal handling functions
+	loadlib(&MTable, Load_Lang_Reflect())            // load the Field and Method functions
+	loadlib(&MTable, Load_Io_ObjectStreams())        // load the functions serialization relies on
+	loadlib(&MTable, Load_Lang_Annotation())         // load the runtime annotation functions
+	loadlib(&MTable, Load_Lang_ClassLoader())        // load the ClassLoader and resource functions
+	loadlib(&MTable, Load_Lang_Throwable())          // load the Throwable functions
+	loadlib(&MTable, Load_Lang_Thread())             // load the Thread functions
+	loadlib(&MTable, Load_Lang_ThreadLocal())        // load the ThreadLocal functions
+	loadlib(&MTable, Load_Crac_Core())               // load the checkpoint/restore functions
+	loadlib(&MTable, Load_Lang_Object())             // load Object.clone(), wait(), and notify()
+	loadlib(&MTable, Load_Lang_Enum())               // load the java.lang.Enum functions
+	loadlib(&MTable, Load_Lang_Invoke())             // load the MethodType, Lookup, and MethodHandle functions
 }
 
 func loadlib(tbl *MT, libMeths map[string]GMeth) {
@@ -88,12 +160,12 @@ func loadlib(tbl *MT, libMeths map[string]GMeth) {
 			Meth:  gme,
 		}
 
-		addEntry(tbl, key, tableEntry)
+		addEntry(tbl, MethodKey(key), tableEntry)
 	}
 }
 
 // adds an entry to the MTable, using a mutex
-func addEntry(tbl *MT, key string, mte MTentry) {
+func addEntry(tbl *MT, key MTkey, mte MTentry) {
 	mt := *tbl
 
 	MTmutex.Lock()
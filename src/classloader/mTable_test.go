@@ -12,7 +12,7 @@ import (
 
 func TestMTableAdd(t *testing.T) {
 	mtbl := make(MT)
-	addEntry(&mtbl, "test1", MTentry{
+	addEntry(&mtbl, MethodKey("test/Test.test1()V"), MTentry{
 		Meth:  nil,
 		MType: 'G',
 	})
@@ -21,9 +21,9 @@ func TestMTableAdd(t *testing.T) {
 		t.Errorf("Expecting MTable size of 1, got: %d", len(mtbl))
 	}
 
-	if mtbl["test1"].MType != 'G' {
+	if mtbl[MethodKey("test/Test.test1()V")].MType != 'G' {
 		t.Errorf("Expecting fetch of a 'G' MTable rec, but got type: %c",
-			mtbl["test1"].MType)
+			mtbl[MethodKey("test/Test.test1()V")].MType)
 	}
 }
 
@@ -43,3 +43,16 @@ func TestMTableLoadLib(t *testing.T) {
 			mte.ParamSlots)
 	}
 }
+
+func TestMethodKey(t *testing.T) {
+	key := MethodKey("java/lang/String.valueOf(D)Ljava/lang/String;")
+	if key.Class != "java/lang/String" || key.Sym != SymbolFor("valueOf", "(D)Ljava/lang/String;") {
+		t.Errorf("Expecting the class and symbol of String.valueOf(D), got: %s", key)
+	}
+	if key.String() != "java/lang/String.valueOf(D)Ljava/lang/String;" {
+		t.Errorf("Expecting the key to print as the method's FQN, got: %s", key)
+	}
+	if MethodKey("java/lang/String.length()I") == MethodKey("java/lang/StringBuilder.length()I") {
+		t.Error("Expecting methods of different classes to have different keys")
+	}
+}
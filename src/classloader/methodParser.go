@@ -31,35 +31,33 @@ func parseMethods(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 		accessFlags, err := intFrom2Bytes(bytes, pos+1)
 		pos += 2
 		if err != nil {
-			return pos, cfe("Invalid fetch of method access flags in class: " +
-				klass.className)
+			return pos, cfe("JACOBIN-CL-0080", klass.className)
 		}
 
 		nameIndex, err := intFrom2Bytes(bytes, pos+1)
 		pos += 2
 		if err != nil {
-			return pos, cfe("Invalid fetch of method name index in class: " +
-				klass.className)
+			return pos, cfe("JACOBIN-CL-0081", klass.className)
 		}
 		nameSlot, err2 := fetchUTF8slot(klass, nameIndex)
+		if err2 != nil {
+			return pos, cfe("JACOBIN-CL-0081", klass.className)
+		}
 
 		descIndex, err3 := intFrom2Bytes(bytes, pos+1)
 		pos += 2
-		if err2 != nil || err3 != nil {
-			return pos, cfe("Invalid fetch of method description index in method: " +
-				klass.utf8Refs[nameSlot].content)
+		if err3 != nil {
+			return pos, cfe("JACOBIN-CL-0082", klass.utf8Refs[nameSlot].content)
 		}
 		descSlot, err4 := fetchUTF8slot(klass, descIndex)
 		if err4 != nil {
-			return pos, cfe("Invalid fetch of method description slot in method: " +
-				klass.utf8Refs[nameSlot].content)
+			return pos, cfe("JACOBIN-CL-0083", klass.utf8Refs[nameSlot].content)
 		}
 
 		attrCount, err := intFrom2Bytes(bytes, pos+1)
 		pos += 2
 		if err != nil {
-			return pos, cfe("Invalid fetch of method attribute count in method: " +
-				klass.utf8Refs[nameSlot].content)
+			return pos, cfe("JACOBIN-CL-0084", klass.utf8Refs[nameSlot].content)
 		}
 
 		meth.accessFlags = accessFlags
@@ -80,6 +78,11 @@ func parseMethods(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 			attrib, location, err5 := fetchAttribute(klass, bytes, pos)
 			pos = location
 			if err5 == nil {
+				owner := "method " + klass.utf8Refs[nameSlot].content + klass.utf8Refs[descSlot].content +
+					" in class " + klass.className
+				if err := checkAttributeLength(klass, attrib, owner); err != nil {
+					return pos, err
+				}
 				meth.attributes = append(meth.attributes, attrib)
 				// switch on the name of the attribute (listed here in alpha order)
 				switch klass.utf8Refs[attrib.attrName].content {
@@ -92,7 +95,7 @@ func parseMethods(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 							" attribute: Code", log.FINEST)
 					}
 					if parseCodeAttribute(attrib, &meth, klass) != nil {
-						return pos, cfe("") // error msg will already have been shown to user
+						return pos, cfe("JACOBIN-CL-0085") // error msg will already have been shown to user
 					}
 				case "Deprecated":
 					meth.deprecated = true
@@ -100,20 +103,19 @@ func parseMethods(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 				case "Exceptions":
 					log.Log("    Attribute: Exceptions", log.FINEST)
 					if parseExceptionsMethodAttribute(attrib, &meth, klass) != nil {
-						return pos, cfe("") // error msg will already have been shown to user
+						return pos, cfe("JACOBIN-CL-0085") // error msg will already have been shown to user
 					}
 				case "MethodParameters":
 					log.Log("    Attribute: MethodParameters", log.FINEST)
 					if parseMethodParametersAttribute(attrib, &meth, klass) != nil {
-						return pos, cfe("") // error msg will already have been shown to user
+						return pos, cfe("JACOBIN-CL-0085") // error msg will already have been shown to user
 					}
 				default:
 					log.Log("    Attribute: "+klass.utf8Refs[attrib.attrName].content, log.FINEST)
 				}
 
 			} else {
-				return pos, cfe("Error fetching method attribute in method: " +
-					klass.utf8Refs[nameSlot].content)
+				return pos, cfe("JACOBIN-CL-0086", klass.utf8Refs[nameSlot].content)
 			}
 		}
 		klass.methods = append(klass.methods, meth)
@@ -132,21 +134,24 @@ func parseCodeAttribute(att attr, meth *method, klass *ParsedClass) error {
 	maxStack, err := intFrom2Bytes(att.attrContent, pos+1)
 	pos += 2
 	if err != nil {
-		return cfe("Error getting maxStack value in Code attribute in " + klass.className)
+		return cfe("JACOBIN-CL-0087", klass.className)
 	}
 
 	maxLocals, err := intFrom2Bytes(att.attrContent, pos+1)
 	pos += 2
 	if err != nil {
-		return cfe("Error getting maxLocals value in Code attribute in " + klass.className)
+		return cfe("JACOBIN-CL-0088", klass.className)
 	}
 
 	codeLength, err := intFrom4Bytes(att.attrContent, pos+1)
 	pos += 4
 	if err != nil {
-		return cfe("Error getting code length in Code attribute in " + klass.className)
+		return cfe("JACOBIN-CL-0089", klass.className)
 	}
 
+	if codeLength < 0 || pos+codeLength >= len(att.attrContent) {
+		return cfe("JACOBIN-CL-0089", klass.className)
+	}
 	var code []byte
 	for i := 0; i < codeLength; i++ {
 		code = append(code, att.attrContent[pos+1+i])
@@ -156,7 +161,7 @@ func parseCodeAttribute(att attr, meth *method, klass *ParsedClass) error {
 	exceptionCount, err := intFrom2Bytes(att.attrContent, pos+1)
 	pos += 2
 	if err != nil {
-		return cfe("Error getting count of exceptions in Code attribute in " + klass.className)
+		return cfe("JACOBIN-CL-0090", klass.className)
 	}
 
 	if exceptionCount > 0 {
@@ -171,19 +176,20 @@ func parseCodeAttribute(att attr, meth *method, klass *ParsedClass) error {
 			pos += 8
 
 			if err != nil {
-				return cfe("Error getting catch type for exception in " + methodName +
-					"() of " + klass.className + "\n at position: " + strconv.Itoa(pos) +
-					" in the method (after parse of start/endPC, handlerPc, and catch type)")
+				return cfe("JACOBIN-CL-0091", methodName, klass.className, strconv.Itoa(pos))
 			}
 
 			if ex.catchType != 0 {
+				if ex.catchType >= len(klass.cpIndex) {
+					return cfe("JACOBIN-CL-0092", methodName, klass.className)
+				}
 				catchType := klass.cpIndex[ex.catchType]
 				if catchType.entryType != ClassRef {
-					return cfe("Invalid catchType in method " + methodName +
-						" in " + klass.className)
+					return cfe("JACOBIN-CL-0092", methodName, klass.className)
 				} else {
+					catchName, _ := fetchUTF8string(klass, klass.classRefs[catchType.slot])
 					log.Log("        Method: "+methodName+
-						" throws exception: "+klass.utf8Refs[catchType.slot].content,
+						" throws exception: "+catchName,
 						log.FINEST)
 				}
 			}
@@ -195,8 +201,7 @@ func parseCodeAttribute(att attr, meth *method, klass *ParsedClass) error {
 	attrCount, err := intFrom2Bytes(att.attrContent, pos+1)
 	pos += 2
 	if err != nil {
-		return cfe("Error getting attributes in Code attribute of " + methodName +
-			"() of " + klass.className)
+		return cfe("JACOBIN-CL-0093", methodName, klass.className)
 	}
 
 	if attrCount > 0 {
@@ -205,11 +210,14 @@ func parseCodeAttribute(att attr, meth *method, klass *ParsedClass) error {
 		for m := 0; m < attrCount; m++ {
 			cat, loc, err2 := fetchAttribute(klass, att.attrContent, pos)
 			if err2 != nil {
-				return cfe("Error retrieving attributes in Code attribute of " + methodName +
-					"() of " + klass.className)
+				return cfe("JACOBIN-CL-0094", methodName, klass.className)
 			}
 			pos = loc
 			log.Log("        "+klass.utf8Refs[cat.attrName].content, log.FINEST)
+			owner := "the code of method " + methodName + " in class " + klass.className
+			if err := checkAttributeLength(klass, cat, owner); err != nil {
+				return err
+			}
 			ca.attributes = append(ca.attributes, cat)
 		}
 	}
@@ -238,18 +246,15 @@ func parseExceptionsMethodAttribute(attrib attr, meth *method, klass *ParsedClas
 	exceptionCount, err := intFrom2Bytes(attrib.attrContent, loc+1)
 	loc += 2
 	if err != nil {
-		return cfe("Error retrieving exception count in method " +
-			klass.utf8Refs[meth.name].content)
+		return cfe("JACOBIN-CL-0095", klass.utf8Refs[meth.name].content)
 	}
 
 	for ex := 0; ex < exceptionCount; ex++ {
 		// exception is an index into CP that points to a classRef
-		cRefIndex, _ := intFrom2Bytes(attrib.attrContent, loc+1)
+		cRefIndex, err := intFrom2Bytes(attrib.attrContent, loc+1)
 		loc += 2
-		if klass.cpIndex[cRefIndex].entryType != ClassRef {
-			return cfe("Exception attribute #" + strconv.Itoa(ex+1) +
-				" in method " + klass.utf8Refs[meth.name].content +
-				" does not point to a ClassRef CP entry")
+		if err != nil || cRefIndex >= len(klass.cpIndex) || klass.cpIndex[cRefIndex].entryType != ClassRef {
+			return cfe("JACOBIN-CL-0096", strconv.Itoa(ex+1), klass.utf8Refs[meth.name].content)
 		}
 
 		// whichClassRef is the entry # in the classRefs array
@@ -260,9 +265,7 @@ func parseExceptionsMethodAttribute(attrib attr, meth *method, klass *ParsedClas
 		// the classRef should point to a UTF8 record with the name of the exception class
 		exceptionName, err2 := fetchUTF8string(klass, classRef)
 		if err2 != nil {
-			return cfe("Exception attribute #" + strconv.Itoa(ex+1) +
-				" in method " + klass.utf8Refs[meth.name].content +
-				" has a ClassRef CP entry that does not point to a UTF8 string")
+			return cfe("JACOBIN-CL-0097", strconv.Itoa(ex+1), klass.utf8Refs[meth.name].content)
 		}
 
 		// if the previous fetch of the UTF8 record succeeded, this one shouldn't fail
@@ -286,22 +289,19 @@ func parseExceptionsMethodAttribute(attrib attr, meth *method, klass *ParsedClas
 //    } parameters[parameters_count];
 // }
 func parseMethodParametersAttribute(att attr, meth *method, klass *ParsedClass) error {
-	var err error
 	pos := 0
+	if len(att.attrContent) == 0 {
+		return cfe("JACOBIN-CL-0098", klass.utf8Refs[meth.name].content)
+	}
 	parametersCount := int(att.attrContent[pos])
 	pos += 1
-	if err != nil {
-		return cfe("Error getting number of Parameter attributes in method: " +
-			klass.utf8Refs[meth.name].content)
-	}
 
 	for k := 0; k < parametersCount; k++ {
 		mpAttrib := paramAttrib{}
 		paramNameIndex, err := intFrom2Bytes(att.attrContent, pos)
 		pos += 2
 		if err != nil {
-			return cfe("Error getting name index for MethodParameters attribute #" +
-				strconv.Itoa(k+1) + " in " + klass.utf8Refs[meth.name].content)
+			return cfe("JACOBIN-CL-0099", strconv.Itoa(k+1), klass.utf8Refs[meth.name].content)
 		}
 		if paramNameIndex == 0 {
 			mpAttrib.name = ""
@@ -309,8 +309,7 @@ func parseMethodParametersAttribute(att attr, meth *method, klass *ParsedClass)
 			mpAttrib.name, err = fetchUTF8string(klass, paramNameIndex)
 		}
 		if err != nil {
-			return cfe("Error getting name of MethodParameters attribute #" +
-				strconv.Itoa(k+1) + " in " + klass.utf8Refs[meth.name].content)
+			return cfe("JACOBIN-CL-0100", strconv.Itoa(k+1), klass.utf8Refs[meth.name].content)
 		}
 
 		logName := "{none}"
@@ -320,14 +319,15 @@ func parseMethodParametersAttribute(att attr, meth *method, klass *ParsedClass)
 		log.Log("        "+logName, log.FINEST)
 
 		accessFlags, err := intFrom2Bytes(att.attrContent, pos)
+		pos += 2
 		if err != nil {
-			return cfe("Error getting access flags of MethodParameters attribute #" +
-				strconv.Itoa(k+1) + " in " + klass.utf8Refs[meth.name].content)
+			return cfe("JACOBIN-CL-0101", strconv.Itoa(k+1), klass.utf8Refs[meth.name].content)
 		}
-		// do format check on the access flags here
-		if accessFlags != 0x10 && accessFlags != 0x1000 && accessFlags != 0x8000 {
-			return cfe("Invalid access flags of MethodParameters attribute #" +
-				strconv.Itoa(k+1) + " in " + klass.utf8Refs[meth.name].content)
+		// do format check on the access flags here: they can be any combination of
+		// ACC_FINAL, ACC_SYNTHETIC, and ACC_MANDATED, including none of them (as javac
+		// -parameters emits for most parameters, and kotlinc and scalac for synthetic ones)
+		if accessFlags&^(0x10|0x1000|0x8000) != 0 {
+			return cfe("JACOBIN-CL-0102", strconv.Itoa(k+1), klass.utf8Refs[meth.name].content)
 		}
 
 		mpAttrib.accessFlags = accessFlags
@@ -0,0 +1,202 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"archive/zip"
+	"io"
+	"jacobin/messages"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The module path (-p or --module-path) is a list of modules, and of directories of
+// modules. A module is an exploded module, which is a directory with a module-info.class
+// at its root, or a JAR. A modular JAR has a module-info.class; any other JAR is an
+// automatic module, whose name is its manifest's Automatic-Module-Name or is derived
+// from the JAR's name, and which requires nothing. As with java, the first module with
+// a given name is the one that's used.
+//
+// When the program is launched with -m, its module and the modules it requires, directly
+// or indirectly, are resolved from the module path. The modules of the runtime image
+// (java.* and jdk.*) are assumed to be present. The resolved modules are searched for
+// the application's classes and resources before the class path. Module boundaries
+// aren't enforced yet.
+
+// ModuleRef is a module found on the module path
+type ModuleRef struct {
+	Descriptor *ModuleDescriptor
+	Location   string // the exploded module's directory, or the JAR
+}
+
+// the locations of the resolved modules, which go before the class path
+var resolvedModules []string
+
+// FindModules returns the modules on the module path, by name
+func FindModules(modulePath string) (map[string]*ModuleRef, error) {
+	modules := make(map[string]*ModuleRef)
+	add := func(location string) error {
+		md, err := readModuleDescriptor(location)
+		if err != nil || md == nil {
+			return err
+		}
+		if _, present := modules[md.Name]; !present {
+			modules[md.Name] = &ModuleRef{Descriptor: md, Location: location}
+		}
+		return nil
+	}
+
+	for _, entry := range filepath.SplitList(modulePath) {
+		if entry == "" {
+			continue
+		}
+		info, err := os.Stat(entry)
+		if err != nil {
+			continue // as with java, entries that don't exist are ignored
+		}
+		if !info.IsDir() || fileExists(filepath.Join(entry, "module-info.class")) {
+			if err = add(entry); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		children, err := os.ReadDir(entry) // a directory of modules
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			if err = add(filepath.Join(entry, child.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return modules, nil
+}
+
+// ResolveModules resolves the root module and the modules it requires from the module
+// path, and returns them in the order they were resolved, starting with the root. The
+// modules are then searched for classes and resources before the class path.
+func ResolveModules(modulePath, root string) ([]*ModuleRef, error) {
+	available, err := FindModules(modulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var resolved []*ModuleRef
+	seen := make(map[string]bool)
+	var resolve func(name, requiredBy string) error
+	resolve = func(name, requiredBy string) error {
+		if seen[name] {
+			return nil
+		}
+		seen[name] = true
+		ref, present := available[name]
+		if !present {
+			if strings.HasPrefix(name, "java.") || strings.HasPrefix(name, "jdk.") {
+				return nil // in the runtime image
+			}
+			if requiredBy == "" {
+				return messages.New("JACOBIN-CL-0158", name)
+			}
+			return messages.New("JACOBIN-CL-0159", name, requiredBy)
+		}
+		resolved = append(resolved, ref)
+		for _, req := range ref.Descriptor.Requires {
+			if err := resolve(req.Name, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err = resolve(root, ""); err != nil {
+		return nil, err
+	}
+
+	resolvedModules = nil
+	for _, ref := range resolved {
+		resolvedModules = append(resolvedModules, ref.Location)
+	}
+	return resolved, nil
+}
+
+// ClassFileInModule returns the name of the class file of the class in the module, as
+// LoadClassFromFile() expects it. The class name is in binary form (com.example.Main).
+func ClassFileInModule(ref *ModuleRef, className string) string {
+	entry := strings.ReplaceAll(className, ".", "/") + ".class"
+	if isJar(ref.Location) {
+		return ref.Location + jarEntrySeparator + entry
+	}
+	return filepath.Join(ref.Location, filepath.FromSlash(entry))
+}
+
+// readModuleDescriptor returns the descriptor of the module at the location, or nil if
+// the location isn't a module
+func readModuleDescriptor(location string) (*ModuleDescriptor, error) {
+	if !isJar(location) {
+		b, err := os.ReadFile(filepath.Join(location, "module-info.class"))
+		if err != nil {
+			return nil, nil
+		}
+		return ParseModuleInfo(b)
+	}
+
+	z, err := zip.OpenReader(location)
+	if err != nil {
+		return nil, messages.New("JACOBIN-CL-0160", location, err.Error())
+	}
+	defer z.Close()
+	for _, f := range z.File {
+		if f.Name == "module-info.class" {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, messages.New("JACOBIN-CL-0160", location, err.Error())
+			}
+			defer rc.Close()
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				return nil, messages.New("JACOBIN-CL-0160", location, err.Error())
+			}
+			return ParseModuleInfo(b)
+		}
+	}
+
+	// an automatic module
+	md := &ModuleDescriptor{Name: automaticModuleName(filepath.Base(location))}
+	if attrs, err := ReadManifest(location); err == nil {
+		if attrs["Automatic-Module-Name"] != "" {
+			md.Name = attrs["Automatic-Module-Name"]
+		}
+		md.MainClass = attrs["Main-Class"]
+	}
+	return md, nil
+}
+
+var (
+	jarVersionRE  = regexp.MustCompile(`-(\d+(\.|$)).*$`)
+	nonAlphaNumRE = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// automaticModuleName derives the name of an automatic module from the name of its JAR,
+// as java does: the .jar and any version (as in -1.2.3) are removed, and each run of
+// characters other than letters and digits becomes a dot
+func automaticModuleName(jarName string) string {
+	name := strings.TrimSuffix(jarName, filepath.Ext(jarName))
+	name = jarVersionRE.ReplaceAllString(name, "")
+	name = nonAlphaNumRE.ReplaceAllString(name, ".")
+	return strings.Trim(name, ".")
+}
+
+func isJar(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".jar")
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,190 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"jacobin/messages"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testAppModuleInfo builds the module-info class of an application module that
+// requires the given modules and, if mainClass isn't "", has a main class
+func testAppModuleInfo(name, mainClass string, requires ...string) []byte {
+	var cp bytes.Buffer
+	count := 1
+	u2 := func(b *bytes.Buffer, v int) { _ = binary.Write(b, binary.BigEndian, uint16(v)) }
+	utf8 := func(s string) int {
+		cp.WriteByte(UTF8)
+		u2(&cp, len(s))
+		cp.WriteString(s)
+		count++
+		return count - 1
+	}
+	ref := func(tag byte, name string) int {
+		n := utf8(name)
+		cp.WriteByte(tag)
+		u2(&cp, n)
+		count++
+		return count - 1
+	}
+
+	this := ref(ClassRef, "module-info")
+	moduleAttr, mainAttr := utf8("Module"), utf8("ModuleMainClass")
+	module := []int{ref(Module, name), 0, 0, len(requires)}
+	for _, req := range requires {
+		module = append(module, ref(Module, req), 0, 0)
+	}
+	module = append(module, 0, 0, 0, 0) // exports, opens, uses, and provides
+	var moduleBody bytes.Buffer
+	for _, v := range module {
+		u2(&moduleBody, v)
+	}
+	attrs := []struct {
+		name int
+		body []byte
+	}{{moduleAttr, moduleBody.Bytes()}}
+	if mainClass != "" {
+		var mainBody bytes.Buffer
+		u2(&mainBody, ref(ClassRef, mainClass))
+		attrs = append(attrs, struct {
+			name int
+			body []byte
+		}{mainAttr, mainBody.Bytes()})
+	}
+
+	var class bytes.Buffer
+	_ = binary.Write(&class, binary.BigEndian, uint32(0xCAFEBABE))
+	for _, v := range []int{0, 55, count} {
+		u2(&class, v)
+	}
+	class.Write(cp.Bytes())
+	for _, v := range []int{0x8000, this, 0, 0, 0, 0, len(attrs)} {
+		u2(&class, v)
+	}
+	for _, attr := range attrs {
+		u2(&class, attr.name)
+		_ = binary.Write(&class, binary.BigEndian, uint32(len(attr.body)))
+		class.Write(attr.body)
+	}
+	return class.Bytes()
+}
+
+// writeTestJar writes a JAR with the given entries
+func writeTestJar(path string, entries map[string][]byte) {
+	var jar bytes.Buffer
+	z := zip.NewWriter(&jar)
+	for name, content := range entries {
+		w, _ := z.Create(name)
+		_, _ = w.Write(content)
+	}
+	_ = z.Close()
+	_ = ioutil.WriteFile(path, jar.Bytes(), 0644)
+}
+
+func TestParseAppModuleInfo(t *testing.T) {
+	md, err := ParseModuleInfo(testAppModuleInfo("com.example.app", "com/example/app/Main", "com.example.util"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if md.Name != "com.example.app" || md.MainClass != "com.example.app.Main" ||
+		len(md.Requires) != 1 || md.Requires[0].Name != "com.example.util" {
+		t.Errorf("Unexpected descriptor: %+v", md)
+	}
+}
+
+func TestResolveModules(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "jacobin-modules")
+	defer os.RemoveAll(dir)
+	defer func() { resolvedModules = nil }()
+
+	// an exploded module, a modular JAR in a directory of modules, and an automatic module
+	app := filepath.Join(dir, "app")
+	_ = os.Mkdir(app, 0755)
+	_ = ioutil.WriteFile(filepath.Join(app, "module-info.class"),
+		testAppModuleInfo("com.example.app", "com/example/app/Main", "com.example.util", "java.logging"), 0644)
+	libs := filepath.Join(dir, "libs")
+	_ = os.Mkdir(libs, 0755)
+	writeTestJar(filepath.Join(libs, "util.jar"), map[string][]byte{
+		"module-info.class": testAppModuleInfo("com.example.util", "", "commons.text")})
+	writeTestJar(filepath.Join(libs, "commons-text-1.9.jar"), map[string][]byte{
+		"META-INF/MANIFEST.MF": []byte("Manifest-Version: 1.0\r\n\r\n")})
+	_ = ioutil.WriteFile(filepath.Join(libs, "README"), []byte("not a module"), 0644)
+
+	modulePath := app + string(os.PathListSeparator) + libs
+	modules, err := ResolveModules(modulePath, "com.example.app")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var names []string
+	for _, m := range modules {
+		names = append(names, m.Descriptor.Name)
+	}
+	if len(names) != 3 || names[0] != "com.example.app" || names[1] != "com.example.util" ||
+		names[2] != "commons.text" {
+		t.Errorf("Expected the app, util, and commons.text modules, got %v", names)
+	}
+	if cp := AppClassPath(); len(cp) < 3 || cp[0] != app {
+		t.Errorf("Expected the modules to go first on the class path, got %v", cp)
+	}
+
+	want := filepath.Join(app, "com", "example", "app", "Main.class")
+	if got := ClassFileInModule(modules[0], "com.example.app.Main"); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	want = filepath.Join(libs, "util.jar") + "!/com/example/util/Strings.class"
+	if got := ClassFileInModule(modules[1], "com.example.util.Strings"); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	if _, err = ResolveModules(libs, "com.example.app"); messages.CodeOf(err) != "JACOBIN-CL-0158" {
+		t.Errorf("Expected a module not found error, got: %v", err)
+	}
+	_ = os.Remove(filepath.Join(libs, "commons-text-1.9.jar"))
+	if _, err = ResolveModules(modulePath, "com.example.app"); messages.CodeOf(err) != "JACOBIN-CL-0159" {
+		t.Errorf("Expected a required module not found error, got: %v", err)
+	}
+}
+
+func TestAutomaticModuleName(t *testing.T) {
+	for jar, want := range map[string]string{
+		"commons-text-1.9.jar":    "commons.text",
+		"guava-31.1-jre.jar":      "guava",
+		"foo_bar.jar":             "foo.bar",
+		"my-lib.jar":              "my.lib",
+		"-odd--name-.jar":         "odd.name",
+		"jackson-core-2.13.3.jar": "jackson.core",
+		"slf4j-api-1.7.36.jar":    "slf4j.api",
+		"simple.jar":              "simple",
+		"version-in-name-2x.jar":  "version.in.name.2x",
+		"lib-10.jar":              "lib",
+	} {
+		if got := automaticModuleName(jar); got != want {
+			t.Errorf("Expected %s to be module %s, got %s", jar, want, got)
+		}
+	}
+}
+
+func TestReadClassBytesFromJar(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "jacobin-jar")
+	defer os.RemoveAll(dir)
+	jar := filepath.Join(dir, "app.jar")
+	writeTestJar(jar, map[string][]byte{"com/example/Main.class": {0xCA, 0xFE}})
+
+	b, err := readClassBytes(jar + jarEntrySeparator + "com/example/Main.class")
+	if err != nil || len(b) != 2 || b[0] != 0xCA {
+		t.Errorf("Expected the class's bytes, got %v (%v)", b, err)
+	}
+	if _, err = readClassBytes(jar + jarEntrySeparator + "com/example/Other.class"); err == nil {
+		t.Error("Expected an error for a class that isn't in the JAR")
+	}
+}
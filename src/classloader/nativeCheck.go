@@ -0,0 +1,104 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The checks of the native registry that -Xcheck:native runs at start-up. A native is
+// registered under the name and descriptor its method had when it was written, so if
+// the JDK's class changes (a parameter is added, or the method becomes static), the
+// native is no longer found, or it takes the wrong number of slots. Comparing the
+// registry with the classes catches that before the method is called.
+
+// NativeClasses returns the classes that have natives in the registry, in order
+func NativeClasses() []string {
+	seen := make(map[string]bool)
+	var classes []string
+	for methFQN := range MethodSignatures {
+		if class, _ := splitNativeName(methFQN); class != "" && !seen[class] {
+			seen[class] = true
+			classes = append(classes, class)
+		}
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+// splitNativeName splits the fully qualified name of a native into its class and its
+// method name and descriptor, as in java/lang/Math and sqrt(D)D
+func splitNativeName(methFQN string) (class, method string) {
+	paren := strings.Index(methFQN, "(")
+	if paren < 0 {
+		return "", ""
+	}
+	dot := strings.LastIndex(methFQN[:paren], ".")
+	if dot < 0 {
+		return "", ""
+	}
+	return methFQN[:dot], methFQN[dot+1:]
+}
+
+// CheckNativeClass compares the natives registered for the class with the methods the
+// loaded class declares, and returns the problems it finds. A class that isn't loaded
+// has none.
+func CheckNativeClass(className string) []string {
+	MethAreaMutex.RLock()
+	k, present := Classes[className]
+	MethAreaMutex.RUnlock()
+	if !present || k.Data == nil {
+		return nil
+	}
+	declared := make(map[string]int) // the access flags of each method (name+descriptor)
+	for _, m := range k.Data.Methods {
+		declared[k.Data.CP.Utf8Refs[m.Name]+k.Data.CP.Utf8Refs[m.Desc]] = m.AccessFlags
+	}
+
+	var problems []string
+	for methFQN, gm := range MethodSignatures {
+		class, method := splitNativeName(methFQN)
+		if class != className {
+			continue
+		}
+		flags, found := declared[method]
+		if !found {
+			problem := fmt.Sprintf("%s is registered, but %s declares no such method", methFQN, className)
+			name := method[:strings.Index(method, "(")]
+			var others []string
+			for m := range declared {
+				if strings.HasPrefix(m, name+"(") {
+					others = append(others, m)
+				}
+			}
+			if len(others) > 0 {
+				sort.Strings(others)
+				problem += " (it declares " + strings.Join(others, ", ") + ")"
+			}
+			problems = append(problems, problem)
+			continue
+		}
+
+		params, _, err := ParseDescriptor(method[strings.Index(method, "("):])
+		if err != nil {
+			problems = append(problems, methFQN+": "+err.Error())
+			continue
+		}
+		slots := len(params)
+		if flags&ACC_STATIC == 0 {
+			slots++ // for 'this'
+		}
+		if gm.ParamSlots != slots {
+			problems = append(problems, fmt.Sprintf("%s is registered with %d parameter slots, but the method takes %d",
+				methFQN, gm.ParamSlots, slots))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
@@ -0,0 +1,52 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckNativeClass(t *testing.T) {
+	MethodSignatures = make(map[string]GMeth)
+	MethodSignatures["test/Natives.add(II)I"] = GMeth{ParamSlots: 2}                 // static: correct
+	MethodSignatures["test/Natives.name()Ljava/lang/String;"] = GMeth{ParamSlots: 0} // lacks 'this'
+	MethodSignatures["test/Natives.scale(D)D"] = GMeth{ParamSlots: 1}                // the descriptor changed
+	MethodSignatures["test/Other.run()V"] = GMeth{ParamSlots: 1}
+
+	MethAreaMutex.Lock()
+	Classes["test/Natives"] = Klass{Status: 'N', Data: &ClData{Name: "test/Natives",
+		CP: CPool{Utf8Refs: []string{"add", "(II)I", "name", "()Ljava/lang/String;", "scale", "(DD)D"}},
+		Methods: []Method{{AccessFlags: ACC_STATIC | ACC_NATIVE, Name: 0, Desc: 1},
+			{AccessFlags: ACC_PUBLIC, Name: 2, Desc: 3},
+			{AccessFlags: ACC_STATIC, Name: 4, Desc: 5}},
+	}}
+	MethAreaMutex.Unlock()
+	defer func() {
+		MethAreaMutex.Lock()
+		delete(Classes, "test/Natives")
+		MethAreaMutex.Unlock()
+	}()
+
+	if classes := NativeClasses(); len(classes) != 2 || classes[0] != "test/Natives" || classes[1] != "test/Other" {
+		t.Errorf("Expected the two classes with natives, got: %v", classes)
+	}
+
+	problems := CheckNativeClass("test/Natives")
+	if len(problems) != 2 {
+		t.Fatalf("Expected two problems, got: %v", problems)
+	}
+	if !strings.Contains(problems[0], "name()Ljava/lang/String; is registered with 0 parameter slots, but the method takes 1") {
+		t.Errorf("Expected the wrong slot count to be found, got: %s", problems[0])
+	}
+	if !strings.Contains(problems[1], "scale(D)D is registered, but test/Natives declares no such method (it declares scale(DD)D)") {
+		t.Errorf("Expected the changed descriptor to be found, got: %s", problems[1])
+	}
+	if problems := CheckNativeClass("test/Other"); problems != nil {
+		t.Errorf("Expected no problems for a class that isn't loaded, got: %v", problems)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"encoding/binary"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Off-heap ("native") memory, which Unsafe.allocateMemory() and direct ByteBuffers use.
+// Java code sees a block of native memory as a long address. Rather than hand out real
+// machine addresses (which would let a buggy or malicious program read or write any of
+// the VM's memory), each block is a Go byte slice and the addresses are synthetic: every
+// block gets its own range of addresses, which are never reused, and every access is
+// checked against the bounds of the block it falls in.
+
+type memBlock struct {
+	base int64
+	data []byte
+}
+
+var nativeBlocks []*memBlock // sorted by base address
+var nativeMemMutex sync.Mutex
+var nextAddress int64 = 0x10000000
+
+// nativeOrder is the byte order Unsafe uses to read and write multibyte values,
+// which is the byte order of the platform.
+var nativeOrder binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	switch runtime.GOARCH {
+	case "s390x", "ppc64", "mips", "mips64":
+		nativeOrder = binary.BigEndian
+	}
+}
+
+// AllocateMemory allocates a block of native memory of the given size, initialized
+// to zero, and returns its address. As in the JDK, a size of 0 returns address 0.
+func AllocateMemory(size int64) int64 {
+	if size <= 0 {
+		return 0
+	}
+	nativeMemMutex.Lock()
+	defer nativeMemMutex.Unlock()
+	block := &memBlock{base: nextAddress, data: make([]byte, size)}
+	nextAddress += (size+15)&^15 + 16          // leave a gap, so overruns don't land in the next block
+	nativeBlocks = append(nativeBlocks, block) // addresses only increase, so this stays sorted
+	return block.base
+}
+
+// FreeMemory releases the block at the address. Freeing 0 does nothing.
+func FreeMemory(address int64) {
+	nativeMemMutex.Lock()
+	defer nativeMemMutex.Unlock()
+	i := blockIndex(address)
+	if i >= 0 && nativeBlocks[i].base == address {
+		nativeBlocks = append(nativeBlocks[:i], nativeBlocks[i+1:]...)
+	}
+}
+
+// ReallocateMemory resizes the block at the address, copying its contents into a new
+// block, and returns the address of the new block.
+func ReallocateMemory(address, size int64) int64 {
+	old, _ := NativeSlice(address, 0)
+	newAddress := AllocateMemory(size)
+	if buf, ok := NativeSlice(newAddress, size); ok {
+		copy(buf, old)
+	}
+	FreeMemory(address)
+	return newAddress
+}
+
+// blockIndex returns the index of the block containing the address, or -1.
+// The caller must hold nativeMemMutex.
+func blockIndex(address int64) int {
+	i := sort.Search(len(nativeBlocks), func(i int) bool {
+		return nativeBlocks[i].base > address
+	}) - 1
+	if i < 0 || address >= nativeBlocks[i].base+int64(len(nativeBlocks[i].data)) {
+		return -1
+	}
+	return i
+}
+
+// NativeSlice returns the native memory from address to address+length as a byte slice
+// that shares the block's storage. If length is 0, the slice runs to the end of the block.
+// If the range is not entirely within one allocated block, the second return is false.
+func NativeSlice(address, length int64) ([]byte, bool) {
+	nativeMemMutex.Lock()
+	defer nativeMemMutex.Unlock()
+	i := blockIndex(address)
+	if i < 0 || length < 0 {
+		return nil, false
+	}
+	block := nativeBlocks[i]
+	start := address - block.base
+	if length == 0 {
+		return block.data[start:], true
+	}
+	if start+length > int64(len(block.data)) {
+		return nil, false
+	}
+	return block.data[start : start+length], true
+}
+
+// Load_Misc_UnsafeMemory loads the native memory-access methods of Unsafe, which the
+// java.nio buffers (via java.nio.Bits and DirectByteBuffer) depend on.
+// TODO: accesses outside allocated memory should crash the VM, as they would in HotSpot;
+// for now, reads return 0 and writes are ignored.
+func Load_Misc_UnsafeMemory() map[string]GMeth {
+	unsafe := "jdk/internal/misc/Unsafe"
+	addNative(unsafe+".allocateMemory(J)J", false, func(this, size int64) int64 {
+		return AllocateMemory(size)
+	})
+	addNative(unsafe+".reallocateMemory(JJ)J", false, func(this, address, size int64) int64 {
+		return ReallocateMemory(address, size)
+	})
+	addNative(unsafe+".freeMemory(J)V", false, func(this, address int64) {
+		FreeMemory(address)
+	})
+	addNative(unsafe+".setMemory(JJB)V", false, func(this, address, length int64, value int8) {
+		if buf, ok := NativeSlice(address, length); ok {
+			for i := range buf {
+				buf[i] = byte(value)
+			}
+		}
+	})
+	addNative(unsafe+".copyMemory(JJJ)V", false, func(this, src, dest, length int64) {
+		from, ok1 := NativeSlice(src, length)
+		to, ok2 := NativeSlice(dest, length)
+		if ok1 && ok2 {
+			copy(to, from)
+		}
+	})
+	addNative(unsafe+".getByte(J)B", false, func(this, address int64) int8 {
+		if buf, ok := NativeSlice(address, 1); ok {
+			return int8(buf[0])
+		}
+		return 0
+	})
+	addNative(unsafe+".putByte(JB)V", false, func(this, address int64, value int8) {
+		if buf, ok := NativeSlice(address, 1); ok {
+			buf[0] = byte(value)
+		}
+	})
+	addNative(unsafe+".getInt(J)I", false, func(this, address int64) int32 {
+		if buf, ok := NativeSlice(address, 4); ok {
+			return int32(nativeOrder.Uint32(buf))
+		}
+		return 0
+	})
+	addNative(unsafe+".putInt(JI)V", false, func(this, address int64, value int32) {
+		if buf, ok := NativeSlice(address, 4); ok {
+			nativeOrder.PutUint32(buf, uint32(value))
+		}
+	})
+	addNative(unsafe+".getLong(J)J", false, func(this, address int64) int64 {
+		if buf, ok := NativeSlice(address, 8); ok {
+			return int64(nativeOrder.Uint64(buf))
+		}
+		return 0
+	})
+	addNative(unsafe+".putLong(JJ)V", false, func(this, address, value int64) {
+		if buf, ok := NativeSlice(address, 8); ok {
+			nativeOrder.PutUint64(buf, uint64(value))
+		}
+	})
+	addNative(unsafe+".pageSize()I", false, func(this int64) int32 {
+		return int32(os.Getpagesize())
+	})
+	addNative(unsafe+".addressSize()I", false, func(this int64) int32 {
+		return 8 // addresses are always longs (see above)
+	})
+	return MethodSignatures
+}
@@ -0,0 +1,64 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+func TestNativeMemoryBounds(t *testing.T) {
+	a := AllocateMemory(10)
+	b := AllocateMemory(10)
+	if a == 0 || b <= a+10 {
+		t.Errorf("Expected distinct, separated blocks, got addresses %x and %x", a, b)
+	}
+	if _, ok := NativeSlice(a+8, 4); ok {
+		t.Errorf("Expected access running off the end of a block to fail")
+	}
+	if _, ok := NativeSlice(a+10, 1); ok {
+		t.Errorf("Expected access just past a block to fail")
+	}
+	if AllocateMemory(0) != 0 {
+		t.Errorf("Expected zero-length allocation to return 0")
+	}
+
+	buf, _ := NativeSlice(a, 3)
+	copy(buf, "abc")
+	c := ReallocateMemory(a, 20)
+	if moved, _ := NativeSlice(c, 3); string(moved) != "abc" {
+		t.Errorf("Expected reallocation to keep contents, got: %q", moved)
+	}
+	if _, ok := NativeSlice(a, 1); ok {
+		t.Errorf("Expected old block to be freed by reallocation")
+	}
+	FreeMemory(b)
+	if _, ok := NativeSlice(b, 1); ok {
+		t.Errorf("Expected freed block to be inaccessible")
+	}
+}
+
+func TestUnsafeMemoryAccess(t *testing.T) {
+	Load_Misc_UnsafeMemory()
+	u := "jdk/internal/misc/Unsafe"
+	addr := callNative(t, u+".allocateMemory(J)J", int64(0), int64(16)).(int64)
+	callNative(t, u+".setMemory(JJB)V", int64(0), addr, int64(16), int64(0x7f))
+	callNative(t, u+".putInt(JI)V", int64(0), addr, int64(-2))
+	if callNative(t, u+".getInt(J)I", int64(0), addr).(int64) != -2 {
+		t.Errorf("Expected getInt() to return value put")
+	}
+	if callNative(t, u+".getByte(J)B", int64(0), addr+4).(int64) != 0x7f {
+		t.Errorf("Expected setMemory() to fill the block")
+	}
+	callNative(t, u+".putLong(JJ)V", int64(0), addr+8, int64(1)<<40)
+	dest := callNative(t, u+".allocateMemory(J)J", int64(0), int64(8)).(int64)
+	callNative(t, u+".copyMemory(JJJ)V", int64(0), addr+8, dest, int64(8))
+	if callNative(t, u+".getLong(J)J", int64(0), dest).(int64) != int64(1)<<40 {
+		t.Errorf("Expected copyMemory() to copy the long")
+	}
+	callNative(t, u+".freeMemory(J)V", int64(0), addr)
+	if callNative(t, u+".getInt(J)I", int64(0), addr).(int64) != 0 {
+		t.Errorf("Expected read of freed memory to return 0")
+	}
+}
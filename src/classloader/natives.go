@@ -0,0 +1,347 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/log"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// The native method registry. Every JDK method that Jacobin implements in Go, whether
+// declared native in the JDK (ACC_NATIVE) or simply replaced by a Go intrinsic, is
+// registered here, keyed by its fully qualified name: class name, method name, and
+// descriptor, e.g. "java/lang/Math.sqrt(D)D". The registry is the MethodSignatures
+// map; MTableLoadNatives() copies its entries into the MTable at start-up.
+//
+// There are two ways to register a native:
+//
+//  * Add a GMeth to MethodSignatures directly, in a Load_* function (as in
+//    javaIoPrintStream.go). The Go function receives the raw operand-stack slots
+//    as a []interface{} of int64s and returns an int64 (or nil for void methods).
+//
+//  * Call RegisterNative() with an ordinary typed Go function. The registry then does
+//    the marshaling between operand-stack slots and Go types using the descriptor:
+//        B -> int8, C -> uint16, S -> int16, I -> int32, J -> int64, Z -> bool,
+//        F -> float32, D -> float64, L...; and [... (references) -> int64
+//    For instance methods, the first Go parameter is the reference to 'this' (int64).
+//    Floats and doubles are kept in their slots as the IEEE 754 bits of a float64,
+//    the same way static fields store them.
+//    A String, or an array of primitives or of Strings, can instead be passed and
+//    returned as its Go counterpart, which spares the function looking up the object:
+//        Ljava/lang/String; -> string, [B -> []byte, [C -> []uint16, [S -> []int16,
+//        [I -> []int32, [J -> []int64, [F -> []float32, [D -> []float64, [Z -> []bool,
+//        [Ljava/lang/String; -> []string
+//    These are copies: changing a slice doesn't change the Java array. A null is
+//    passed as "" or a nil slice, and a nil slice is returned as null.
+//    The function can also return an error after its result (or as its only result,
+//    for a void method), which throws the exception it names, as a GMeth's does:
+//        func(path string) (int64, error) { ... errors.New("java.io.IOException: ...") }
+//
+// When an ACC_NATIVE method is invoked that has no entry in the registry, the
+// invocation fails with an UnsatisfiedLinkError (see FetchMethodAndCP()).
+
+// ACC_NATIVE is the access flag that marks a method as native (JVMS 4.6)
+const ACC_NATIVE = 0x0100
+
+// ACC_STATIC is the access flag that marks a method as static (JVMS 4.6)
+const ACC_STATIC = 0x0008
+
+// ACC_PUBLIC is the access flag that marks a method as public (JVMS 4.6)
+const ACC_PUBLIC = 0x0001
+
+// RegisterNative adds a typed Go function to the registry as the implementation of the
+// named method. It returns an error if the Go function's signature doesn't match the
+// method's descriptor.
+func RegisterNative(methFQN string, isStatic bool, fn interface{}) error {
+	paren := strings.Index(methFQN, "(")
+	if paren == -1 {
+		return errors.New("invalid native method name (no descriptor): " + methFQN)
+	}
+	params, ret, err := ParseDescriptor(methFQN[paren:])
+	if err != nil {
+		return err
+	}
+	paramDescs, retDesc := descriptorTypes(methFQN[paren:])
+	if !isStatic {
+		params = append([]byte{'L'}, params...)
+		paramDescs = append([]string{"Ljava/lang/Object;"}, paramDescs...)
+	}
+
+	fv := reflect.ValueOf(fn)
+	ft := fv.Type()
+	if ft.Kind() != reflect.Func || ft.NumIn() != len(params) {
+		return fmt.Errorf("native function for %s has wrong number of parameters", methFQN)
+	}
+	marshaled := make([]bool, len(params)) // whether the parameter is a String or array as a Go value
+	for i, p := range params {
+		if mt, ok := marshaledType(paramDescs[i]); ok && ft.In(i) == mt {
+			marshaled[i] = true
+		} else if ft.In(i) != goTypeFor(p) {
+			return fmt.Errorf("native function for %s: parameter %d should be %s, not %s",
+				methFQN, i, goTypeFor(p), ft.In(i))
+		}
+	}
+	results := ft.NumOut()
+	throws := results > 0 && ft.Out(results-1) == errorType // the function can throw an exception
+	if throws {
+		results--
+	}
+	retMarshaled := false
+	if ret != 'V' && results == 1 {
+		mt, ok := marshaledType(retDesc)
+		retMarshaled = ok && ft.Out(0) == mt
+	}
+	if ret == 'V' && results != 0 ||
+		ret != 'V' && (results != 1 || ft.Out(0) != goTypeFor(ret) && !retMarshaled) {
+		return fmt.Errorf("native function for %s has the wrong return type", methFQN)
+	}
+
+	MethodSignatures[methFQN] = GMeth{
+		ParamSlots: len(params),
+		GFunction: func(slots []interface{}) interface{} {
+			args := make([]reflect.Value, len(params))
+			for i, p := range params {
+				if marshaled[i] {
+					args[i] = refToGo(slots[i].(int64), paramDescs[i], ft.In(i))
+				} else {
+					args[i] = slotToValue(slots[i].(int64), p)
+				}
+			}
+			out := fv.Call(args)
+			if throws {
+				if err, _ := out[len(out)-1].Interface().(error); err != nil {
+					return err
+				}
+			}
+			switch {
+			case ret == 'V':
+				return nil
+			case retMarshaled:
+				return goToRef(out[0], retDesc)
+			}
+			return valueToSlot(out[0], ret)
+		},
+	}
+	return nil
+}
+
+// addNative registers a native whose signature is fixed in the source, so a mismatch
+// is a bug in Jacobin. It's logged rather than returned.
+func addNative(methFQN string, isStatic bool, fn interface{}) {
+	if err := RegisterNative(methFQN, isStatic, fn); err != nil {
+		_ = log.Log("Error registering native: "+err.Error(), log.SEVERE)
+	}
+}
+
+// LookupNative returns the registered implementation of the named method, if any
+func LookupNative(methFQN string) (GMeth, bool) {
+	gm, present := MethodSignatures[methFQN]
+	return gm, present
+}
+
+// ParseDescriptor reduces a method descriptor to one type letter per parameter
+// (L for all references, including arrays) plus the type letter of the return value.
+func ParseDescriptor(desc string) ([]byte, byte, error) {
+	var params []byte
+	if !strings.HasPrefix(desc, "(") {
+		return nil, 0, errors.New("invalid method descriptor: " + desc)
+	}
+	i := 1
+	for i < len(desc) && desc[i] != ')' {
+		isArray := false
+		for i < len(desc) && desc[i] == '[' {
+			isArray = true
+			i++
+		}
+		if i >= len(desc) {
+			return nil, 0, errors.New("invalid method descriptor: " + desc)
+		}
+
+		c := desc[i]
+		if c == 'L' { // skip over the class name
+			end := strings.Index(desc[i:], ";")
+			if end == -1 {
+				return nil, 0, errors.New("invalid method descriptor: " + desc)
+			}
+			i += end
+		} else if !strings.ContainsRune("BCDFIJSZ", rune(c)) {
+			return nil, 0, errors.New("invalid method descriptor: " + desc)
+		}
+
+		if isArray { // arrays are references, whatever the element type
+			c = 'L'
+		}
+		params = append(params, c)
+		i++
+	}
+	if i+1 >= len(desc) {
+		return nil, 0, errors.New("invalid method descriptor: " + desc)
+	}
+	ret := desc[i+1]
+	if ret == '[' {
+		ret = 'L'
+	}
+	return params, ret, nil
+}
+
+// errorType is the type of the error a native can return to throw an exception
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// goTypeFor returns the Go type used for a Java type letter when marshaling
+func goTypeFor(javaType byte) reflect.Type {
+	switch javaType {
+	case 'B':
+		return reflect.TypeOf(int8(0))
+	case 'C':
+		return reflect.TypeOf(uint16(0))
+	case 'S':
+		return reflect.TypeOf(int16(0))
+	case 'I':
+		return reflect.TypeOf(int32(0))
+	case 'Z':
+		return reflect.TypeOf(false)
+	case 'F':
+		return reflect.TypeOf(float32(0))
+	case 'D':
+		return reflect.TypeOf(float64(0))
+	default: // J, and L for references
+		return reflect.TypeOf(int64(0))
+	}
+}
+
+// slotToValue converts an operand-stack slot to a Go value of the Java type
+func slotToValue(slot int64, javaType byte) reflect.Value {
+	switch javaType {
+	case 'B':
+		return reflect.ValueOf(int8(slot))
+	case 'C':
+		return reflect.ValueOf(uint16(slot))
+	case 'S':
+		return reflect.ValueOf(int16(slot))
+	case 'I':
+		return reflect.ValueOf(int32(slot))
+	case 'Z':
+		return reflect.ValueOf(slot != 0)
+	case 'F':
+		return reflect.ValueOf(float32(math.Float64frombits(uint64(slot))))
+	case 'D':
+		return reflect.ValueOf(math.Float64frombits(uint64(slot)))
+	default:
+		return reflect.ValueOf(slot)
+	}
+}
+
+// valueToSlot converts a Go value of the Java type to an operand-stack slot
+func valueToSlot(v reflect.Value, javaType byte) int64 {
+	switch javaType {
+	case 'B', 'S', 'I', 'J', 'L':
+		return v.Int()
+	case 'C':
+		return int64(v.Uint())
+	case 'Z':
+		if v.Bool() {
+			return 1
+		}
+		return 0
+	case 'F', 'D':
+		return int64(math.Float64bits(v.Float()))
+	default:
+		return v.Int()
+	}
+}
+
+// marshaledTypes are the Go types that Strings and arrays can be passed as, by descriptor
+var marshaledTypes = map[string]reflect.Type{
+	"Ljava/lang/String;":  reflect.TypeOf(""),
+	"[B":                  reflect.TypeOf([]byte(nil)),
+	"[C":                  reflect.TypeOf([]uint16(nil)),
+	"[S":                  reflect.TypeOf([]int16(nil)),
+	"[I":                  reflect.TypeOf([]int32(nil)),
+	"[J":                  reflect.TypeOf([]int64(nil)),
+	"[F":                  reflect.TypeOf([]float32(nil)),
+	"[D":                  reflect.TypeOf([]float64(nil)),
+	"[Z":                  reflect.TypeOf([]bool(nil)),
+	"[Ljava/lang/String;": reflect.TypeOf([]string(nil)),
+}
+
+// marshaledType returns the Go type that a value of the descriptor can be passed as
+// instead of a reference, if there's one
+func marshaledType(desc string) (reflect.Type, bool) {
+	t, present := marshaledTypes[desc]
+	return t, present
+}
+
+// refToGo converts the referenced String or array to a Go value of the type
+func refToGo(ref int64, desc string, t reflect.Type) reflect.Value {
+	switch desc {
+	case "Ljava/lang/String;":
+		s, _ := GoStringFromRef(ref)
+		return reflect.ValueOf(s)
+	case "[B":
+		b, ok := ByteArrayFromRef(ref)
+		if !ok {
+			return reflect.Zero(t)
+		}
+		return reflect.ValueOf(append([]byte(nil), b...))
+	case "[C":
+		chars, ok := CharArrayFromRef(ref)
+		if !ok {
+			return reflect.Zero(t)
+		}
+		return reflect.ValueOf(append([]uint16(nil), chars...))
+	}
+	elems, ok := RefArrayFromRef(ref) // the references, or the slots of the primitives
+	if !ok {
+		return reflect.Zero(t)
+	}
+	v := reflect.MakeSlice(t, len(elems), len(elems))
+	for i, elem := range elems {
+		if desc == "[Ljava/lang/String;" {
+			s, _ := GoStringFromRef(elem)
+			v.Index(i).SetString(s)
+		} else {
+			v.Index(i).Set(slotToValue(elem, desc[1]))
+		}
+	}
+	return v
+}
+
+// goToRef converts a Go value of the marshaled type for the descriptor to a new
+// String or array, and returns the reference to it
+func goToRef(v reflect.Value, desc string) int64 {
+	switch {
+	case desc == "Ljava/lang/String;":
+		return NewStringObject(v.String())
+	case v.IsNil():
+		return 0
+	case desc == "[B":
+		return NewByteArray(append([]byte(nil), v.Bytes()...))
+	case desc == "[C":
+		return NewCharArray(append([]uint16(nil), v.Interface().([]uint16)...))
+	}
+	elems := make([]int64, v.Len())
+	for i := range elems {
+		if desc == "[Ljava/lang/String;" {
+			elems[i] = NewStringObject(v.Index(i).String())
+		} else {
+			elems[i] = valueToSlot(v.Index(i), desc[1])
+		}
+	}
+	if desc == "[Ljava/lang/String;" {
+		return NewRefArray("java/lang/String", elems)
+	}
+	return NewPrimitiveArray(desc[1:], elems)
+}
+
+// SlotFromFloat and FloatFromSlot convert between float64 values and the way
+// floats and doubles are kept in operand-stack slots
+func SlotFromFloat(f float64) int64 { return int64(math.Float64bits(f)) }
+func FloatFromSlot(s int64) float64 { return math.Float64frombits(uint64(s)) }
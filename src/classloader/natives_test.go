@@ -0,0 +1,175 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/globals"
+	"jacobin/log"
+	"strings"
+	"testing"
+)
+
+func TestParseDescriptor(t *testing.T) {
+	params, ret, err := ParseDescriptor("(I[JLjava/lang/String;[[Ljava/lang/Object;DZ)[B")
+	if err != nil {
+		t.Fatalf("Unexpected error parsing descriptor: %s", err.Error())
+	}
+	if string(params) != "ILLLDZ" || ret != 'L' {
+		t.Errorf("Expected params ILLLDZ returning L, got: %s returning %c", string(params), ret)
+	}
+
+	for _, bad := range []string{"I)V", "(Ljava/lang/String)V", "(Q)V", "(I"} {
+		if _, _, err := ParseDescriptor(bad); err == nil {
+			t.Errorf("Expected error for invalid descriptor: %s", bad)
+		}
+	}
+}
+
+func TestRegisterNativeMarshaling(t *testing.T) {
+	err := RegisterNative("test/Natives.scale(DI)D", true, func(d float64, i int32) float64 {
+		return d * float64(i)
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error registering native: %s", err.Error())
+	}
+
+	gm, ok := LookupNative("test/Natives.scale(DI)D")
+	if !ok || gm.ParamSlots != 2 {
+		t.Fatalf("Expected registered native with 2 param slots")
+	}
+	ret := gm.GFunction([]interface{}{SlotFromFloat(1.5), int64(4)})
+	if FloatFromSlot(ret.(int64)) != 6.0 {
+		t.Errorf("Expected 6.0 from native, got: %f", FloatFromSlot(ret.(int64)))
+	}
+}
+
+func TestRegisterInstanceNative(t *testing.T) {
+	err := RegisterNative("test/Natives.isSelf(Ljava/lang/Object;)Z", false, func(this, o int64) bool {
+		return this == o
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error registering native: %s", err.Error())
+	}
+	gm, _ := LookupNative("test/Natives.isSelf(Ljava/lang/Object;)Z")
+	if gm.ParamSlots != 2 {
+		t.Errorf("Expected 2 param slots (this + 1 arg), got: %d", gm.ParamSlots)
+	}
+	if gm.GFunction([]interface{}{int64(7), int64(7)}).(int64) != 1 {
+		t.Errorf("Expected true (1) from native")
+	}
+}
+
+func TestRegisterNativeStringsAndArrays(t *testing.T) {
+	err := RegisterNative("test/Natives.join([Ljava/lang/String;Ljava/lang/String;)Ljava/lang/String;", true,
+		func(parts []string, sep string) string { return strings.Join(parts, sep) })
+	if err != nil {
+		t.Fatalf("Unexpected error registering native: %s", err.Error())
+	}
+	gm, _ := LookupNative("test/Natives.join([Ljava/lang/String;Ljava/lang/String;)Ljava/lang/String;")
+	parts := NewRefArray("java/lang/String", []int64{NewStringObject("a"), NewStringObject("b")})
+	ret := gm.GFunction([]interface{}{parts, NewStringObject("-")})
+	if s, _ := GoStringFromRef(ret.(int64)); s != "a-b" {
+		t.Errorf("Expected \"a-b\" from native, got: %q", s)
+	}
+
+	err = RegisterNative("test/Natives.doubled([I[B)[D", true, func(ints []int32, b []byte) []float64 {
+		if b != nil {
+			return nil
+		}
+		out := make([]float64, len(ints))
+		for i, n := range ints {
+			out[i] = 2 * float64(n)
+		}
+		return out
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error registering native: %s", err.Error())
+	}
+	gm, _ = LookupNative("test/Natives.doubled([I[B)[D")
+	ints := NewPrimitiveArray("I", []int64{1, -3})
+	ret = gm.GFunction([]interface{}{ints, int64(0)}) // a null byte[] is a nil slice
+	doubles, _ := RefArrayFromRef(ret.(int64))
+	if GetObject(ret.(int64)).Klass != "[D" || len(doubles) != 2 ||
+		FloatFromSlot(doubles[0]) != 2 || FloatFromSlot(doubles[1]) != -6 {
+		t.Errorf("Expected double[] {2, -6} from native, got: %v", doubles)
+	}
+	if ret := gm.GFunction([]interface{}{ints, NewByteArray([]byte{1})}); ret.(int64) != 0 {
+		t.Errorf("Expected null for a nil slice, got: %d", ret.(int64))
+	}
+}
+
+func TestRegisterNativeThatThrows(t *testing.T) {
+	err := RegisterNative("test/Natives.half(I)I", true, func(i int32) (int32, error) {
+		if i%2 != 0 {
+			return 0, errors.New("java.lang.IllegalArgumentException: odd")
+		}
+		return i / 2, nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error registering native: %s", err.Error())
+	}
+	gm, _ := LookupNative("test/Natives.half(I)I")
+	if ret := gm.GFunction([]interface{}{int64(8)}); ret != int64(4) {
+		t.Errorf("Expected 4 from native, got: %v", ret)
+	}
+	if ret, ok := gm.GFunction([]interface{}{int64(7)}).(error); !ok ||
+		ret.Error() != "java.lang.IllegalArgumentException: odd" {
+		t.Errorf("Expected the native to throw IllegalArgumentException, got: %v", ret)
+	}
+
+	err = RegisterNative("test/Natives.check(Z)V", true, func(ok bool) error {
+		if !ok {
+			return errors.New("java.lang.IllegalStateException")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error registering native: %s", err.Error())
+	}
+	gm, _ = LookupNative("test/Natives.check(Z)V")
+	if ret := gm.GFunction([]interface{}{int64(1)}); ret != nil {
+		t.Errorf("Expected nil from a void native that doesn't throw, got: %v", ret)
+	}
+	if _, ok := gm.GFunction([]interface{}{int64(0)}).(error); !ok {
+		t.Errorf("Expected the void native to throw")
+	}
+}
+
+func TestRegisterNativeSignatureMismatch(t *testing.T) {
+	if RegisterNative("test/Natives.f(I)I", true, func(i int64) int32 { return 0 }) == nil {
+		t.Errorf("Expected error on parameter type mismatch")
+	}
+	if RegisterNative("test/Natives.g(I)V", true, func(i int32) int32 { return 0 }) == nil {
+		t.Errorf("Expected error on return type mismatch")
+	}
+	if RegisterNative("test/Natives.h(II)V", true, func(i int32) {}) == nil {
+		t.Errorf("Expected error on parameter count mismatch")
+	}
+}
+
+// invoking a native method that's not registered fails with UnsatisfiedLinkError
+func TestUnregisteredNativeIsUnsatisfiedLink(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	k := Klass{Status: 'F', Loader: "bootstrap", Data: &ClData{Name: "test/NoNative"}}
+	k.Data.CP.Utf8Refs = []string{"doIt", "()V"}
+	k.Data.Methods = []Method{{AccessFlags: ACC_NATIVE, Name: 0, Desc: 1}}
+	Classes["test/NoNative"] = k
+
+	_, err := FetchMethodAndCP("test/NoNative", "doIt", "()V")
+	if err == nil || err.Error() != "java.lang.UnsatisfiedLinkError: test/NoNative.doIt()V" {
+		t.Errorf("Expected UnsatisfiedLinkError, got: %v", err)
+	}
+
+	_ = RegisterNative("test/NoNative.doIt()V", true, func() {})
+	mte, err := FetchMethodAndCP("test/NoNative", "doIt", "()V")
+	if err != nil || mte.MType != 'G' {
+		t.Errorf("Expected registered native to be found as a Go method, got error: %v", err)
+	}
+}
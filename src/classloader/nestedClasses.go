@@ -0,0 +1,251 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"path"
+	"strings"
+	"sync"
+)
+
+// Nested classes. javac compiles each nested class, such as Outer.Inner or the
+// anonymous class Outer$1, to a class file of its own, which is loaded when it's first
+// used. It's looked for first where the class file of its outer class was found, in
+// the same directory or JAR, and then on the class path, so that the nested classes of
+// a main class given as a file, or of a class in a JAR, are found with it.
+//
+// The attributes that tie a nested class to its outer class are read when Class's
+// methods ask for them: InnerClasses, which gives the outer class and the simple name
+// of a member class (JVMS 4.7.6), EnclosingMethod, which gives the class and method of
+// a local or anonymous class (4.7.7), and NestHost and NestMembers, which make up the
+// nest whose members can use each other's private members (4.7.28 and 4.7.29).
+
+// the files the application classes were loaded from, in the form LoadClassFromFile()
+// takes: a file, or an entry in a JAR, as in app.jar!/com/example/Main.class
+var (
+	loadedFrom      = make(map[string]string)
+	loadedFromMutex sync.Mutex
+)
+
+// classLoadedFrom records the file the class was loaded from
+func classLoadedFrom(className, file string) {
+	loadedFromMutex.Lock()
+	loadedFrom[className] = file
+	loadedFromMutex.Unlock()
+}
+
+// nestedClassFile returns the file of the nested class (as in com/example/Outer$Inner)
+// next to the file of an enclosing class that's been loaded, if it exists
+func nestedClassFile(name string) (string, bool) {
+	for i := strings.LastIndex(name, "$"); i > 0; i = strings.LastIndex(name[:i], "$") {
+		outer := name[:i]
+		loadedFromMutex.Lock()
+		file, loaded := loadedFrom[outer]
+		loadedFromMutex.Unlock()
+		outerFile := path.Base(outer) + ".class"
+		if !loaded || !strings.HasSuffix(file, outerFile) {
+			continue
+		}
+		nested := strings.TrimSuffix(file, outerFile) + path.Base(name) + ".class"
+		if classFileExists(nested) {
+			return nested, true
+		}
+	}
+	return "", false
+}
+
+// classFileExists reports whether the class file, which can be an entry in a JAR,
+// exists
+func classFileExists(file string) bool {
+	if sep := strings.Index(file, jarEntrySeparator); sep >= 0 {
+		return jarHasEntry(file[:sep], file[sep+len(jarEntrySeparator):])
+	}
+	info, err := statFile(file)
+	return err == nil && !info.IsDir()
+}
+
+// innerClass is the entry of the InnerClasses attribute that describes a nested class
+type innerClass struct {
+	outer       string // the class that declares the class, or "" for a local or anonymous class
+	simpleName  string // "" for an anonymous class
+	accessFlags int
+}
+
+// classAttribute returns a reader of the class's attribute, or nil if the class
+// doesn't have it
+func classAttribute(className, name string) *annotationReader {
+	data := classData(className)
+	if data == nil {
+		return nil
+	}
+	content := findAttribute(&data.CP, data.Attributes, name)
+	if content == nil {
+		return nil
+	}
+	return &annotationReader{data: content, cp: &data.CP}
+}
+
+// innerClassOf returns the entry of the class's InnerClasses attribute that describes
+// the class itself, which it has if it's a nested class
+func innerClassOf(className string) (innerClass, bool) {
+	r := classAttribute(className, "InnerClasses")
+	if r == nil {
+		return innerClass{}, false
+	}
+	for n := r.u2(); n > 0 && r.err == nil; n-- {
+		inner, outer, name, flags := r.u2(), r.u2(), r.u2(), r.u2()
+		if r.err != nil || r.class(inner) != className {
+			continue
+		}
+		ic := innerClass{accessFlags: flags}
+		if outer != 0 {
+			ic.outer = r.class(outer)
+		}
+		if name != 0 {
+			ic.simpleName = r.utf8At(name)
+		}
+		return ic, r.err == nil
+	}
+	return innerClass{}, false
+}
+
+// enclosingClassOf returns the class that a local or anonymous class is declared in,
+// from its EnclosingMethod attribute
+func enclosingClassOf(className string) (string, bool) {
+	r := classAttribute(className, "EnclosingMethod")
+	if r == nil {
+		return "", false
+	}
+	class := r.class(r.u2())
+	return class, r.err == nil
+}
+
+// nestMembersOf returns the classes that the NestMembers attribute of the nest host
+// lists
+func nestMembersOf(host string) []string {
+	r := classAttribute(host, "NestMembers")
+	if r == nil {
+		return nil
+	}
+	var members []string
+	for n := r.u2(); n > 0 && r.err == nil; n-- {
+		if member := r.class(r.u2()); r.err == nil {
+			members = append(members, member)
+		}
+	}
+	return members
+}
+
+// nestHostOf returns the nest host of the class. As in HotSpot, a class whose NestHost
+// attribute names a class in another package, or one that doesn't list the class as a
+// member, is its own nest host.
+func nestHostOf(className string) string {
+	r := classAttribute(className, "NestHost")
+	if r == nil {
+		return className
+	}
+	host := r.class(r.u2())
+	if r.err != nil || path.Dir(host) != path.Dir(className) {
+		return className
+	}
+	for _, member := range nestMembersOf(host) {
+		if member == className {
+			return host
+		}
+	}
+	return className
+}
+
+// simpleNameOf returns what Class.getSimpleName() does for the class: the name it's
+// declared with, which is "" for an anonymous class, and String[] for an array of Strings
+func simpleNameOf(className string) string {
+	if strings.HasPrefix(className, "[") {
+		component := className[1:]
+		switch {
+		case strings.HasPrefix(component, "L"):
+			component = strings.TrimSuffix(component[1:], ";")
+		case !strings.HasPrefix(component, "["):
+			for name, desc := range primitiveDescriptors {
+				if component == string(desc) {
+					component = name
+				}
+			}
+		}
+		return simpleNameOf(component) + "[]"
+	}
+	if _, isLocal := enclosingClassOf(className); isLocal {
+		if ic, ok := innerClassOf(className); ok {
+			return ic.simpleName
+		}
+		return ""
+	}
+	if ic, ok := innerClassOf(className); ok && ic.simpleName != "" {
+		return ic.simpleName
+	}
+	return path.Base(className)
+}
+
+func loadNestedClassNatives() {
+	class := "java/lang/Class"
+	addNative(class+".getSimpleName()Ljava/lang/String;", false, func(this int64) int64 {
+		name, _ := ClassNameOf(this)
+		return NewStringObject(simpleNameOf(name))
+	})
+	addNative(class+".getEnclosingClass()Ljava/lang/Class;", false, func(this int64) int64 {
+		name, _ := ClassNameOf(this)
+		if enclosing, ok := enclosingClassOf(name); ok {
+			return ClassObject(enclosing)
+		}
+		if ic, ok := innerClassOf(name); ok && ic.outer != "" {
+			return ClassObject(ic.outer)
+		}
+		return 0
+	})
+	addNative(class+".getDeclaringClass()Ljava/lang/Class;", false, func(this int64) int64 {
+		name, _ := ClassNameOf(this)
+		if _, isLocal := enclosingClassOf(name); !isLocal {
+			if ic, ok := innerClassOf(name); ok && ic.outer != "" {
+				return ClassObject(ic.outer)
+			}
+		}
+		return 0
+	})
+	addNative(class+".isAnonymousClass()Z", false, func(this int64) bool {
+		name, _ := ClassNameOf(this)
+		_, isLocal := enclosingClassOf(name)
+		return isLocal && simpleNameOf(name) == ""
+	})
+	addNative(class+".isLocalClass()Z", false, func(this int64) bool {
+		name, _ := ClassNameOf(this)
+		_, isLocal := enclosingClassOf(name)
+		return isLocal && simpleNameOf(name) != ""
+	})
+	addNative(class+".isMemberClass()Z", false, func(this int64) bool {
+		name, _ := ClassNameOf(this)
+		_, isLocal := enclosingClassOf(name)
+		ic, ok := innerClassOf(name)
+		return !isLocal && ok && ic.outer != ""
+	})
+	addNative(class+".getNestHost()Ljava/lang/Class;", false, func(this int64) int64 {
+		name, _ := ClassNameOf(this)
+		return ClassObject(nestHostOf(name))
+	})
+	addNative(class+".isNestmateOf(Ljava/lang/Class;)Z", false, func(this, other int64) bool {
+		name, _ := ClassNameOf(this)
+		otherName, _ := ClassNameOf(other)
+		return nestHostOf(name) == nestHostOf(otherName)
+	})
+	addNative(class+".getNestMembers()[Ljava/lang/Class;", false, func(this int64) int64 {
+		name, _ := ClassNameOf(this)
+		host := nestHostOf(name)
+		refs := []int64{ClassObject(host)}
+		for _, member := range nestMembersOf(host) {
+			refs = append(refs, ClassObject(member))
+		}
+		return NewRefArray("java/lang/Class", refs)
+	})
+}
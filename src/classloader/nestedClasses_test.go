@@ -0,0 +1,125 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+// a nested class is found next to its outer class, in a directory or a JAR, even if
+// neither is on the class path
+func TestNestedClassFile(t *testing.T) {
+	var jar bytes.Buffer
+	z := zip.NewWriter(&jar)
+	for _, name := range []string{"com/example/Tool.class", "com/example/Tool$1.class"} {
+		_, _ = z.Create(name)
+	}
+	_ = z.Close()
+	SetFileSystem(MemoryFS{
+		"work/Main.class":              []byte{0xCA, 0xFE},
+		"work/Main$Inner.class":        []byte{0xCA, 0xFE},
+		"work/Main$Inner$Deeper.class": []byte{0xCA, 0xFE},
+		"lib/app.jar":                  jar.Bytes(),
+	})
+	defer SetFileSystem(nil)
+
+	classLoadedFrom("Main", "work/Main.class")
+	classLoadedFrom("com/example/Tool", "lib/app.jar!/com/example/Tool.class")
+	for name, expected := range map[string]string{
+		"Main$Inner":          "work/Main$Inner.class",
+		"Main$Inner$Deeper":   "work/Main$Inner$Deeper.class", // Main$Inner isn't loaded yet
+		"com/example/Tool$1":  "lib/app.jar!/com/example/Tool$1.class",
+		"com/example/Tool$2":  "",
+		"com/example/Other$1": "",
+	} {
+		if file, found := nestedClassFile(name); file != expected || found != (expected != "") {
+			t.Errorf("Expected the file of %s to be %q, got %q", name, expected, file)
+		}
+	}
+}
+
+// addNestTestClass puts the class in the method area with the attributes, whose
+// contents are the u2s that attrs returns, given the functions that add ClassRef and
+// UTF8 entries to the CP
+func addNestTestClass(name string, attrs func(class, utf8 func(string) int) map[string][]int) {
+	data := ClData{Name: name, CP: CPool{CpIndex: []CpEntry{{}}}}
+	utf8 := func(s string) int {
+		data.CP.Utf8Refs = append(data.CP.Utf8Refs, s)
+		data.CP.CpIndex = append(data.CP.CpIndex, CpEntry{Type: UTF8, Slot: uint16(len(data.CP.Utf8Refs) - 1)})
+		return len(data.CP.CpIndex) - 1
+	}
+	class := func(s string) int {
+		data.CP.ClassRefs = append(data.CP.ClassRefs, uint16(utf8(s)))
+		data.CP.CpIndex = append(data.CP.CpIndex, CpEntry{Type: ClassRef, Slot: uint16(len(data.CP.ClassRefs) - 1)})
+		return len(data.CP.CpIndex) - 1
+	}
+	for attrName, u2s := range attrs(class, utf8) {
+		var content []byte
+		for _, u := range u2s {
+			content = append(content, byte(u>>8), byte(u))
+		}
+		data.CP.Utf8Refs = append(data.CP.Utf8Refs, attrName)
+		data.Attributes = append(data.Attributes,
+			Attr{AttrName: uint16(len(data.CP.Utf8Refs) - 1), AttrSize: len(content), AttrContent: content})
+	}
+	_ = insert(name, Klass{Status: 'L', Loader: "test", Data: &data})
+}
+
+// test/Nest declares the member class Inner and the anonymous class test/Nest$1 in a
+// method. test/Rogue claims test/Nest as its nest host, which doesn't list it.
+func TestNestedClassNatives(t *testing.T) {
+	Load_Lang_Class()
+	outer, inner, anon := "test/Nest", "test/Nest$Inner", "test/Nest$1"
+	innerClasses := func(class, utf8 func(string) int) []int {
+		return []int{2, class(inner), class(outer), utf8("Inner"), 0x0009, class(anon), 0, 0, 0}
+	}
+	addNestTestClass(outer, func(class, utf8 func(string) int) map[string][]int {
+		return map[string][]int{"NestMembers": {2, class(inner), class(anon)}, "InnerClasses": innerClasses(class, utf8)}
+	})
+	addNestTestClass(inner, func(class, utf8 func(string) int) map[string][]int {
+		return map[string][]int{"NestHost": {class(outer)}, "InnerClasses": innerClasses(class, utf8)}
+	})
+	addNestTestClass(anon, func(class, utf8 func(string) int) map[string][]int {
+		return map[string][]int{"NestHost": {class(outer)}, "EnclosingMethod": {class(outer), 0},
+			"InnerClasses": innerClasses(class, utf8)}
+	})
+	addNestTestClass("test/Rogue", func(class, utf8 func(string) int) map[string][]int {
+		return map[string][]int{"NestHost": {class(outer)}}
+	})
+
+	class := "java/lang/Class."
+	for name, simpleName := range map[string]string{outer: "Nest", inner: "Inner", anon: "",
+		"[Ltest/Nest$Inner;": "Inner[]", "[[I": "int[][]"} {
+		if s := javaString(callNative(t, class+"getSimpleName()Ljava/lang/String;", ClassObject(name)).(int64)); s != simpleName {
+			t.Errorf("Expected the simple name of %s to be %q, got %q", name, simpleName, s)
+		}
+	}
+	if callNative(t, class+"getDeclaringClass()Ljava/lang/Class;", ClassObject(inner)) != ClassObject(outer) ||
+		callNative(t, class+"getDeclaringClass()Ljava/lang/Class;", ClassObject(anon)) != int64(0) ||
+		callNative(t, class+"getEnclosingClass()Ljava/lang/Class;", ClassObject(anon)) != ClassObject(outer) {
+		t.Error("Expected test/Nest to declare Inner and to enclose the anonymous class")
+	}
+	if callNative(t, class+"isMemberClass()Z", ClassObject(inner)) != int64(1) ||
+		callNative(t, class+"isAnonymousClass()Z", ClassObject(anon)) != int64(1) ||
+		callNative(t, class+"isAnonymousClass()Z", ClassObject(inner)) != int64(0) {
+		t.Error("Expected Inner to be a member class and test/Nest$1 to be anonymous")
+	}
+
+	if callNative(t, class+"getNestHost()Ljava/lang/Class;", ClassObject(inner)) != ClassObject(outer) ||
+		callNative(t, class+"isNestmateOf(Ljava/lang/Class;)Z", ClassObject(inner), ClassObject(anon)) != int64(1) {
+		t.Error("Expected the nested classes to be in test/Nest's nest")
+	}
+	if callNative(t, class+"getNestHost()Ljava/lang/Class;", ClassObject("test/Rogue")) != ClassObject("test/Rogue") {
+		t.Error("Expected a class the nest host doesn't list to be its own nest host")
+	}
+	members, _ := RefArrayFromRef(callNative(t, class+"getNestMembers()[Ljava/lang/Class;", ClassObject(anon)).(int64))
+	if len(members) != 3 || members[0] != ClassObject(outer) {
+		t.Errorf("Expected the nest host and its two members, got %v", members)
+	}
+}
@@ -0,0 +1,381 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/jfr"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Object is the in-memory layout of a Java object. Each field occupies one 64-bit slot
+// in Fields, in the order the fields are declared in the class. Longs and doubles fit
+// in a single slot; doubles and floats are stored as their IEEE 754 bit patterns, and
+// references are stored as object references (see below). The index of a field's slot
+// is its "offset," which is what Unsafe.objectFieldOffset() returns.
+//
+// Mark is the object header's lock word, which is used for thin locking (see monitor.go
+// in the main package). Because it's the first word of an allocated struct, and Fields
+// is a separately allocated array of int64s, Mark and every field slot are 64-bit
+// aligned, so they can be accessed with sync/atomic on all platforms.
+type Object struct {
+	Mark   int64       // the lock word; accessed only via sync/atomic
+	Klass  string      // the name of the object's class, in java/lang/Object format
+	Fields []int64     // the field values
+	Native interface{} // Go-side state of objects implemented in Go (the value of a String, etc.)
+}
+
+// Objects are referred to by a 32-bit handle, which is the index of the object's entry
+// in the handle table. Handle 0 is null, so the table's first entry is never used.
+// Operand stacks, locals, and fields hold a reference as its handle's value in a 64-bit
+// slot, so that every slot has the same size, but a valid reference always fits in 32
+// bits. Because a reference is a handle rather than a Go pointer, an object could be
+// moved by changing its entry in the table without touching the references to it, and
+// the heap can be walked by handle (see heapHistogram.go and snapshot.go). The table
+// only grows for now: there's no garbage collection yet.
+type handle uint32
+
+// The handle table is split into chunks, which are allocated as the table grows. A
+// chunk never moves once it's allocated, so GetObject() reads the table with sync/atomic
+// rather than taking a lock. heapMutex serializes the goroutines that add entries.
+const (
+	handleChunkBits = 16
+	handleChunkSize = 1 << handleChunkBits
+	maxHandle       = 1<<32 - 1 // never handed out, so handleCount can't wrap
+)
+
+type handleChunk [handleChunkSize]unsafe.Pointer // each entry is an *Object
+
+var handleTable [1 << (32 - handleChunkBits)]unsafe.Pointer // each entry is a *handleChunk
+var handleCount uint32 = 1                                  // the next handle to hand out
+var heapMutex sync.Mutex
+
+// NewObject allocates an object of the named class with the given number of field
+// slots, all set to zero, and returns the reference to it.
+func NewObject(className string, fieldCount int) int64 {
+	obj := &Object{
+		Klass:  className,
+		Fields: make([]int64, fieldCount),
+	}
+	heapMutex.Lock()
+	h := addObject(obj)
+	heapMutex.Unlock()
+	jfr.Allocated(className)
+	ObjectAllocated(className, fieldCount)
+	return int64(h)
+}
+
+// ObjectAllocated is called when an object is allocated, on the goroutine that allocated
+// it. The Java heap profile sets it (see profiling.go in main).
+var ObjectAllocated = func(className string, fieldCount int) {}
+
+// GetObject returns the object pointed to by ref, or nil if ref is null or invalid.
+func GetObject(ref int64) *Object {
+	if ref <= 0 || ref >= int64(atomic.LoadUint32(&handleCount)) {
+		return nil
+	}
+	return objectAt(handle(ref))
+}
+
+// addObject puts obj in the next entry of the handle table and returns its handle. The
+// caller holds heapMutex.
+func addObject(obj *Object) handle {
+	h := handle(handleCount)
+	if h == maxHandle {
+		panic("the heap has run out of object handles")
+	}
+	chunk := &handleTable[h>>handleChunkBits]
+	if atomic.LoadPointer(chunk) == nil {
+		atomic.StorePointer(chunk, unsafe.Pointer(new(handleChunk)))
+	}
+	entries := (*handleChunk)(atomic.LoadPointer(chunk))
+	atomic.StorePointer(&entries[h&(handleChunkSize-1)], unsafe.Pointer(obj))
+	atomic.StoreUint32(&handleCount, uint32(h)+1) // publishes the entry
+	return h
+}
+
+// objectAt returns the object in the entry for a handle that's been handed out
+func objectAt(h handle) *Object {
+	entries := (*handleChunk)(atomic.LoadPointer(&handleTable[h>>handleChunkBits]))
+	return (*Object)(atomic.LoadPointer(&entries[h&(handleChunkSize-1)]))
+}
+
+// heapObjects returns the number of handles that have been handed out, less one for
+// null. Objects can be allocated while the heap is walked, so a walk runs over the
+// handles up to this count, taken once beforehand.
+func heapObjects() int {
+	return int(atomic.LoadUint32(&handleCount)) - 1
+}
+
+// Arrays are objects too. Until the array bytecodes are implemented, the arrays
+// that natives create or accept keep their elements in the Native field: a []byte
+// for byte arrays (class [B), a []uint16 for char arrays (class [C), a []int64 of
+// references for object arrays, and a []int64 of operand-stack slots for arrays of
+// the other primitive types.
+
+// NewByteArray creates a byte[] holding the given bytes and returns the reference to it
+func NewByteArray(b []byte) int64 {
+	ref := NewObject("[B", 0)
+	GetObject(ref).Native = b
+	return ref
+}
+
+// ByteArrayFromRef returns the elements of the referenced byte[]. The slice is the
+// array's storage, so changes to it are changes to the array. If the reference is
+// not to a byte array, the second return value is false.
+func ByteArrayFromRef(ref int64) ([]byte, bool) {
+	obj := GetObject(ref)
+	if obj == nil {
+		return nil, false
+	}
+	b, ok := obj.Native.([]byte)
+	return b, ok
+}
+
+// NewCharArray creates a char[] holding the given UTF-16 code units and returns the
+// reference to it
+func NewCharArray(chars []uint16) int64 {
+	ref := NewObject("[C", 0)
+	GetObject(ref).Native = chars
+	return ref
+}
+
+// CharArrayFromRef returns the elements of the referenced char[]
+func CharArrayFromRef(ref int64) ([]uint16, bool) {
+	obj := GetObject(ref)
+	if obj == nil {
+		return nil, false
+	}
+	chars, ok := obj.Native.([]uint16)
+	return chars, ok
+}
+
+// NewRefArray creates an array of the given element class (e.g., java/lang/String)
+// holding the references and returns the reference to the array.
+func NewRefArray(elementClass string, refs []int64) int64 {
+	ref := NewObject("[L"+elementClass+";", 0)
+	GetObject(ref).Native = refs
+	return ref
+}
+
+// NewPrimitiveArray creates an array of the primitive type with the given descriptor
+// (e.g., I for int[]) other than byte and char, holding the slots, and returns the
+// reference to it. Floats and doubles are stored as float64 bits, as on the operand stack.
+func NewPrimitiveArray(elementType string, slots []int64) int64 {
+	ref := NewObject("["+elementType, 0)
+	GetObject(ref).Native = slots
+	return ref
+}
+
+// NewArray creates an array of the class (e.g., [I or [Ljava/lang/String;) with the
+// given number of elements, all zero, null, or false, and returns the reference to it
+func NewArray(arrayClass string, length int) int64 {
+	switch arrayClass {
+	case "[B":
+		return NewByteArray(make([]byte, length))
+	case "[C":
+		return NewCharArray(make([]uint16, length))
+	}
+	ref := NewObject(arrayClass, 0)
+	GetObject(ref).Native = make([]int64, length)
+	return ref
+}
+
+// ArrayLength returns the number of elements of the referenced array. If the reference
+// is not to an array, the second return value is false.
+func ArrayLength(ref int64) (int, bool) {
+	obj := GetObject(ref)
+	if obj == nil {
+		return 0, false
+	}
+	switch elements := obj.Native.(type) {
+	case []int64:
+		return len(elements), true
+	case []byte:
+		return len(elements), true
+	case []uint16:
+		return len(elements), true
+	}
+	return 0, false
+}
+
+// RefArrayFromRef returns the elements of the referenced object array
+func RefArrayFromRef(ref int64) ([]int64, bool) {
+	obj := GetObject(ref)
+	if obj == nil {
+		return nil, false
+	}
+	refs, ok := obj.Native.([]int64)
+	return refs, ok
+}
+
+// ArrayCopy implements System.arraycopy(), as both the native and the intrinsic (see
+// intrinsics.go in package jvm). The arrays may be the same, in which case the elements
+// are copied as though through a temporary array, as Go's copy() does. When references
+// are copied into an array of a narrower type, each one is checked as it's stored, so
+// the elements before one that doesn't fit are copied when ArrayStoreException is thrown.
+func ArrayCopy(src int64, srcPos int, dest int64, destPos int, length int) error {
+	srcObj, destObj := GetObject(src), GetObject(dest)
+	if srcObj == nil || destObj == nil {
+		return errors.New("java.lang.NullPointerException")
+	}
+	srcLen, ok := ArrayLength(src)
+	if !ok {
+		return errors.New("java.lang.ArrayStoreException: arraycopy: source type " +
+			strings.ReplaceAll(srcObj.Klass, "/", ".") + " is not an array")
+	}
+	destLen, ok := ArrayLength(dest)
+	if !ok {
+		return errors.New("java.lang.ArrayStoreException: arraycopy: destination type " +
+			strings.ReplaceAll(destObj.Klass, "/", ".") + " is not an array")
+	}
+	if srcObj.Klass != destObj.Klass && !(isRefArray(srcObj.Klass) && isRefArray(destObj.Klass)) {
+		return errors.New("java.lang.ArrayStoreException: arraycopy: type mismatch: can not copy " +
+			arrayTypeName(srcObj.Klass) + " into " + arrayTypeName(destObj.Klass))
+	}
+
+	outOfBounds := func(what string, index int, class string, arrayLen int) error {
+		return fmt.Errorf("java.lang.ArrayIndexOutOfBoundsException: arraycopy: %s %d out of bounds for %s",
+			what, index, strings.Replace(arrayTypeName(class), "[]", fmt.Sprintf("[%d]", arrayLen), 1))
+	}
+	switch {
+	case srcPos < 0:
+		return outOfBounds("source index", srcPos, srcObj.Klass, srcLen)
+	case destPos < 0:
+		return outOfBounds("destination index", destPos, destObj.Klass, destLen)
+	case length < 0:
+		return fmt.Errorf("java.lang.ArrayIndexOutOfBoundsException: arraycopy: length %d is negative", length)
+	case srcPos+length > srcLen:
+		return outOfBounds("last source index", srcPos+length, srcObj.Klass, srcLen)
+	case destPos+length > destLen:
+		return outOfBounds("last destination index", destPos+length, destObj.Klass, destLen)
+	}
+
+	// the arrays are of the same kind, so their elements are of the same Go type
+	switch elements := srcObj.Native.(type) {
+	case []int64:
+		destElements := destObj.Native.([]int64)
+		if srcObj.Klass == destObj.Klass || !isRefArray(srcObj.Klass) {
+			copy(destElements[destPos:], elements[srcPos:srcPos+length])
+			return nil
+		}
+		elementType := arrayElementType(destObj.Klass)
+		for i := 0; i < length; i++ {
+			if obj := GetObject(elements[srcPos+i]); obj != nil && cannotStore(obj.Klass, elementType) {
+				return errors.New("java.lang.ArrayStoreException: arraycopy: element type mismatch: " +
+					"can not cast one of the elements of " + arrayTypeName(srcObj.Klass) +
+					" to the type of the destination array, " + strings.ReplaceAll(elementType, "/", "."))
+			}
+			destElements[destPos+i] = elements[srcPos+i]
+		}
+	case []byte:
+		copy(destObj.Native.([]byte)[destPos:], elements[srcPos:srcPos+length])
+	case []uint16:
+		copy(destObj.Native.([]uint16)[destPos:], elements[srcPos:srcPos+length])
+	}
+	return nil
+}
+
+// isRefArray reports whether the array class is an array of references
+func isRefArray(class string) bool {
+	return strings.HasPrefix(class, "[L") || strings.HasPrefix(class, "[[")
+}
+
+// arrayElementType returns the class of the elements of an array of references: the
+// element type of [Ljava/lang/String; is java/lang/String, and that of [[I is [I
+func arrayElementType(class string) string {
+	if strings.HasPrefix(class, "[L") {
+		return strings.TrimSuffix(class[2:], ";")
+	}
+	return class[1:]
+}
+
+// cannotStore reports whether an object of the class is known not to be storable in an
+// array whose elements are of the type. Only arrays can be stored in arrays of arrays;
+// otherwise, it's as for a cast (see CannotCast()).
+func cannotStore(class, elementType string) bool {
+	if strings.HasPrefix(elementType, "[") {
+		return !strings.HasPrefix(class, "[") ||
+			isRefArray(elementType) && !isRefArray(class) ||
+			!isRefArray(elementType) && class != elementType
+	}
+	return CannotCast(class, elementType)
+}
+
+// PrimitiveNames maps the descriptors of the primitive types to their names
+var PrimitiveNames = map[byte]string{'B': "byte", 'C': "char", 'D': "double", 'F': "float",
+	'I': "int", 'J': "long", 'S': "short", 'Z': "boolean"}
+
+// arrayTypeName returns the name of the array type as the messages of arraycopy()
+// give it: int[] for [I, or object array[] for an array of references
+func arrayTypeName(class string) string {
+	if isRefArray(class) || len(class) != 2 {
+		return "object array[]"
+	}
+	return PrimitiveNames[class[1]] + "[]"
+}
+
+// ObjectField is a field of a class and its slot in the class's objects
+type ObjectField struct {
+	Name   string
+	Desc   string
+	Slot   int  // the index of the field's slot in Object.Fields
+	Static bool // static fields also have a slot, which isn't used
+}
+
+// ObjectLayout returns the fields of the class, loading the class if need be, and the
+// number of slots its objects have. The fields inherited from the superclasses come
+// first, so a class's objects share the slots of its superclass's fields. If the class
+// can't be loaded, the last return value is false.
+func ObjectLayout(className string) ([]ObjectField, int, bool) {
+	if classData(className) == nil {
+		return nil, 0, false
+	}
+	var chain []string
+	for c := className; c != ""; c = superclassOf(c) {
+		chain = append(chain, c)
+	}
+	var layout []ObjectField
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, f := range declaredFields(chain[i]) {
+			layout = append(layout, ObjectField{Name: f.name, Desc: f.desc, Slot: f.slot,
+				Static: f.modifiers&ACC_STATIC != 0})
+		}
+	}
+	return layout, len(layout), true
+}
+
+// FieldSlot returns the slot of the named field in the objects of the class, which is
+// its slot in ObjectLayout(), and whether the field is volatile. The field is looked up
+// in the class and then in its superclasses. If the class can't be loaded or no class
+// in the chain declares the field, the last return value is false.
+func FieldSlot(className, fieldName string) (int, bool, bool) {
+	for c := className; c != ""; c = superclassOf(c) {
+		for _, f := range declaredFields(c) {
+			if f.name == fieldName {
+				return f.slot, f.modifiers&ACC_VOLATILE != 0, true
+			}
+		}
+	}
+	return 0, false, false
+}
+
+// InstanceSize returns the number of slots in the objects of the class: one for each
+// field it declares and for each field declared in its superclasses
+func InstanceSize(className string) int {
+	count := 0
+	for c := className; c != ""; c = superclassOf(c) {
+		if data := classData(c); data != nil {
+			count += len(data.Fields)
+		}
+	}
+	return count
+}
@@ -0,0 +1,56 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestObjectHandles(t *testing.T) {
+	if GetObject(0) != nil || GetObject(-1) != nil || GetObject(1<<32+1) != nil {
+		t.Error("Expected null, negative, and wider-than-32-bit references to have no object")
+	}
+	if GetObject(int64(HeapSize()+1)) != nil {
+		t.Error("Expected the reference the next object will get to have no object yet")
+	}
+
+	// allocate past the end of a chunk of the handle table, from several goroutines
+	// at once, and check that every reference leads back to its own object
+	const goroutines = 4
+	perGoroutine := handleChunkSize/goroutines + 1
+	refs := make([][]int64, goroutines)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				ref := NewObject("java/lang/Object", 1)
+				GetObject(ref).Fields[0] = int64(g*perGoroutine + i)
+				refs[g] = append(refs[g], ref)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	seen := make(map[int64]bool)
+	for g := range refs {
+		for i, ref := range refs[g] {
+			if ref != int64(handle(ref)) || seen[ref] {
+				t.Fatalf("Expected a new 32-bit handle, got %d", ref)
+			}
+			seen[ref] = true
+			if obj := GetObject(ref); obj == nil || obj.Fields[0] != int64(g*perGoroutine+i) {
+				t.Fatalf("Expected reference %d to lead to its own object, got %v", ref, obj)
+			}
+		}
+	}
+	if GetObject(int64(HeapSize())) == nil {
+		t.Error("Expected the last reference handed out to have an object")
+	}
+}
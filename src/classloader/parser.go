@@ -12,8 +12,10 @@ import (
 	"fmt"
 	"jacobin/globals"
 	"jacobin/log"
+	"jacobin/messages"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // reads in a class file, parses it, and puts the values into the fields of the
@@ -110,7 +112,7 @@ func parse(rawBytes []byte) (ParsedClass, error) {
 	}
 
 	if pos != len(rawBytes)-1 {
-		return pClass, cfe("Unexpected bytes found at end of class file: " + pClass.className)
+		return pClass, cfe("JACOBIN-CL-0103", pClass.className)
 	}
 	return pClass, nil
 }
@@ -119,26 +121,48 @@ func parse(rawBytes []byte) (ParsedClass, error) {
 // this checks for that.
 func parseMagicNumber(bytes []byte) error {
 	if len(bytes) < 4 {
-		return cfe("invalid magic number")
+		return cfe("JACOBIN-CL-0104")
 	} else if (bytes[0] != 0xCA) || (bytes[1] != 0xFE) || (bytes[2] != 0xBA) || (bytes[3] != 0xBE) {
-		return cfe("invalid magic number")
+		return cfe("JACOBIN-CL-0104")
 	} else {
 		return nil
 	}
 }
 
-// get the Java version number used in creating this class file. If it's higher than the
-// version Jacobin presently supports, report an error.
+// get the Java version number used in creating this class file. If it's outside the range
+// of versions Jacobin presently supports, report an UnsupportedClassVersionError with the
+// message that the JDK gives. From Java 12 (version 56) on, the minor version is 0, or
+// 65535 for classes that use preview features, which Jacobin doesn't support.
 func parseJavaVersionNumber(bytes []byte, klass *ParsedClass) error {
+	minor, err := intFrom2Bytes(bytes, 4)
+	if err != nil {
+		return err
+	}
 	version, err := intFrom2Bytes(bytes, 6)
 	if err != nil {
 		return err
 	}
 
-	if version > globals.GetGlobalRef().MaxJavaVersionRaw {
-		errMsg := "Jacobin supports only Java versions through Java " +
-			strconv.Itoa(globals.GetGlobalRef().MaxJavaVersion)
-		return cfe(errMsg)
+	global := globals.GetGlobalRef()
+	code := ""
+	switch {
+	case version > global.MaxJavaVersionRaw:
+		code = "JACOBIN-CL-0105"
+	case version < global.MinJavaVersionRaw:
+		code = "JACOBIN-CL-0161"
+	case version >= 56 && minor == 0xFFFF:
+		code = "JACOBIN-CL-0163"
+	case version >= 56 && minor != 0:
+		code = "JACOBIN-CL-0162"
+	}
+	if code != "" {
+		args := []interface{}{classNameForError(bytes), fmt.Sprintf("%d.%d", version, minor)}
+		if code == "JACOBIN-CL-0105" {
+			args = append(args, fmt.Sprintf("%d.0", global.MaxJavaVersionRaw))
+		}
+		err := messages.New(code, args...)
+		_ = log.Log(err.Msg, log.SEVERE)
+		return err
 	}
 
 	klass.javaVersion = version
@@ -146,6 +170,62 @@ func parseJavaVersionNumber(bytes []byte, klass *ParsedClass) error {
 	return nil
 }
 
+// isClassVersionError says whether the error is an UnsupportedClassVersionError
+func isClassVersionError(e *messages.Error) bool {
+	switch e.Code {
+	case "JACOBIN-CL-0105", "JACOBIN-CL-0161", "JACOBIN-CL-0162", "JACOBIN-CL-0163":
+		return true
+	}
+	return false
+}
+
+// classNameForError returns the name of the class in the class file, as in com.example.Main,
+// for an error that's found before the class is parsed. It walks the CP without checking
+// it, so if the name can't be found, it returns "the class".
+func classNameForError(bytes []byte) string {
+	u2 := func(pos int) int {
+		if pos+1 >= len(bytes) {
+			return -1
+		}
+		return int(bytes[pos])<<8 | int(bytes[pos+1])
+	}
+	count := u2(8)
+	offsets := make([]int, count+1) // the offset of each entry, after its tag
+	pos := 10
+	for i := 1; i < count; i++ {
+		if pos >= len(bytes) {
+			return "the class"
+		}
+		tag := int(bytes[pos])
+		size, known := cpEntrySizes[tag]
+		if !known {
+			return "the class"
+		}
+		offsets[i] = pos + 1
+		if tag == UTF8 {
+			size += u2(pos + 1)
+		}
+		pos += 1 + size
+		if tag == LongConst || tag == DoubleConst {
+			i++ // they take two slots
+		}
+	}
+	// the access flags come after the CP, and then the index of this class's ClassRef
+	thisClass := u2(pos + 2)
+	if thisClass < 1 || thisClass >= count || offsets[thisClass] == 0 || bytes[offsets[thisClass]-1] != ClassRef {
+		return "the class"
+	}
+	name := u2(offsets[thisClass])
+	if name < 1 || name >= count || offsets[name] == 0 || bytes[offsets[name]-1] != UTF8 {
+		return "the class"
+	}
+	start, length := offsets[name]+2, u2(offsets[name])
+	if start+length > len(bytes) {
+		return "the class"
+	}
+	return strings.ReplaceAll(string(bytes[start:start+length]), "/", ".")
+}
+
 // get the number of entries in the constant pool. This number will
 // be used later on to verify that the number of entries we fetch is
 // correct. Note that this number is technically 1 greater than the
@@ -154,8 +234,7 @@ func parseJavaVersionNumber(bytes []byte, klass *ParsedClass) error {
 func getConstantPoolCount(bytes []byte, klass *ParsedClass) error {
 	cpEntryCount, err := intFrom2Bytes(bytes, 8)
 	if err != nil || cpEntryCount <= 2 {
-		return cfe("Invalid number of entries in constant pool: " +
-			strconv.Itoa(cpEntryCount))
+		return cfe("JACOBIN-CL-0106", strconv.Itoa(cpEntryCount))
 	}
 
 	klass.cpCount = cpEntryCount
@@ -171,7 +250,7 @@ func parseAccessFlags(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 	accessFlags, err := intFrom2Bytes(bytes, pos+1)
 	pos += 2
 	if err != nil {
-		return pos, cfe("Invalid get of class access flags")
+		return pos, cfe("JACOBIN-CL-0107")
 	} else {
 		klass.accessFlags = accessFlags
 		if accessFlags&0x0001 > 0 {
@@ -248,17 +327,16 @@ func parseClassName(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 	var classNameIndex int
 	pos += 2
 	if err != nil {
-		return pos, cfe("error obtaining index for class name")
+		return pos, cfe("JACOBIN-CL-0108")
 	}
 
 	if index < 1 || index > (len(klass.cpIndex)-1) {
-		return pos, cfe("invalid index into CP for class name: " +
-			strconv.Itoa(index))
+		return pos, cfe("JACOBIN-CL-0109", strconv.Itoa(index))
 	}
 
 	pointedToClassRef := klass.cpIndex[index]
 	if pointedToClassRef.entryType != ClassRef {
-		return pos, cfe("invalid entry for class name")
+		return pos, cfe("JACOBIN-CL-0110")
 	}
 
 	// the entry pointed to by pointedToClassRef holds an index to
@@ -272,7 +350,7 @@ func parseClassName(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 	log.Log("class name: "+className, log.FINEST)
 
 	if len(klass.className) > 0 {
-		return pos, cfe("Class appears to have two names: " + klass.className + " and: " + className)
+		return pos, cfe("JACOBIN-CL-0111", klass.className, className)
 	}
 
 	klass.className = className
@@ -287,13 +365,12 @@ func parseSuperClassName(bytes []byte, loc int, klass *ParsedClass) (int, error)
 	var classNameIndex int
 	pos += 2
 	if err != nil {
-		return pos, cfe("error obtaining index for superclass name")
+		return pos, cfe("JACOBIN-CL-0112")
 	}
 
 	if index == 0 {
 		if klass.className != "java/lang/Object" {
-			return pos, cfe("invaild index for superclass name. Got: 0," +
-				" but class is not java/lang/Object")
+			return pos, cfe("JACOBIN-CL-0113")
 		} else {
 			log.Log("superclass name: [none]", log.FINEST)
 			klass.superClass = ""
@@ -302,12 +379,12 @@ func parseSuperClassName(bytes []byte, loc int, klass *ParsedClass) (int, error)
 	}
 
 	if index < 1 || index > (len(klass.cpIndex)-1) {
-		return pos, cfe("invalid index into CP for superclass name")
+		return pos, cfe("JACOBIN-CL-0114")
 	}
 
 	pointedToClassRef := klass.cpIndex[index]
 	if pointedToClassRef.entryType != ClassRef {
-		return pos, cfe("invalid entry for superclass name")
+		return pos, cfe("JACOBIN-CL-0115")
 	}
 
 	// the entry pointed to by pointedToClassRef holds an index to
@@ -320,12 +397,12 @@ func parseSuperClassName(bytes []byte, loc int, klass *ParsedClass) (int, error)
 	}
 
 	if superClassName == "" { // only Object.class can have an empty superclass and it's handled above
-		return pos, cfe("invalid empty string for superclass name")
+		return pos, cfe("JACOBIN-CL-0116")
 	}
 
 	log.Log("superclass name: "+superClassName, log.FINEST)
 	if len(klass.superClass) > 0 {
-		return pos, cfe("Class can only have 1 superclass, found two: " + klass.superClass + " and: " + superClassName)
+		return pos, cfe("JACOBIN-CL-0117", klass.superClass, superClassName)
 	}
 
 	klass.superClass = superClassName
@@ -338,7 +415,7 @@ func parseInterfaceCount(bytes []byte, loc int, klass *ParsedClass) (int, error)
 	interfaceCount, err := intFrom2Bytes(bytes, pos+1)
 	pos += 2
 	if err != nil {
-		return pos, cfe("Invalid fetch of interface count")
+		return pos, cfe("JACOBIN-CL-0118")
 	}
 
 	log.Log("interface count: "+strconv.Itoa(interfaceCount), log.FINEST)
@@ -355,19 +432,18 @@ func parseInterfaces(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 		interfaceIndex, err := intFrom2Bytes(bytes, pos+1)
 		pos += 2
 		if err != nil {
-			return pos, cfe("Invalid fetch of interface index")
+			return pos, cfe("JACOBIN-CL-0119")
 		}
 
 		if interfaceIndex < 1 || interfaceIndex > klass.cpCount-1 {
-			return pos, cfe("Interface index is out of range: " + strconv.Itoa(interfaceIndex))
+			return pos, cfe("JACOBIN-CL-0120", strconv.Itoa(interfaceIndex))
 		}
 
 		// get the entry in the CP that the interface index points to,
 		// which is a class reference entry that then points to a UTF-8 entry
 		classref := klass.cpIndex[interfaceIndex]
 		if classref.entryType != ClassRef {
-			return pos, cfe("Interface index does not point to a class type. Got: " +
-				strconv.Itoa(classref.entryType))
+			return pos, cfe("JACOBIN-CL-0121", strconv.Itoa(classref.entryType))
 		}
 
 		// get the class entry from classRefs slice
@@ -396,7 +472,7 @@ func parseFieldCount(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 	fieldCount, err := intFrom2Bytes(bytes, pos+1)
 	pos += 2
 	if err != nil {
-		return pos, cfe("Invalid fetch of field count")
+		return pos, cfe("JACOBIN-CL-0122")
 	}
 
 	log.Log("field count: "+strconv.Itoa(fieldCount), log.FINEST)
@@ -424,38 +500,35 @@ func parseFields(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 		accessFlags, err := intFrom2Bytes(bytes, pos+1)
 		pos += 2
 		if err != nil {
-			return pos, cfe("error retrieving access flags for field " + strconv.Itoa(i))
+			return pos, cfe("JACOBIN-CL-0123", strconv.Itoa(i))
 		}
 		f.accessFlags = accessFlags
 
 		nameIndex, err := intFrom2Bytes(bytes, pos+1)
 		pos += 2
 		if err != nil || nameIndex < 1 || nameIndex > klass.cpCount-1 {
-			return pos, cfe("error retrieving name index for field")
+			return pos, cfe("JACOBIN-CL-0124")
 		}
 
 		f.name, err = fetchUTF8slot(klass, nameIndex)
 		if err != nil {
-			return pos, cfe("error fetching UTF-8 string for name of field")
+			return pos, cfe("JACOBIN-CL-0125")
 		}
 
 		descIndex, err := intFrom2Bytes(bytes, pos+1)
 		pos += 2
 		if err != nil || descIndex < 1 || descIndex > klass.cpCount-1 {
-			return pos, cfe("error retrieving description index for field: " +
-				klass.utf8Refs[f.name].content)
+			return pos, cfe("JACOBIN-CL-0126", klass.utf8Refs[f.name].content)
 		}
 		f.description, err = fetchUTF8slot(klass, descIndex)
 		if err != nil {
-			return pos, cfe("error retrieving UTF8 slot for description of field: " +
-				klass.utf8Refs[f.name].content)
+			return pos, cfe("JACOBIN-CL-0127", klass.utf8Refs[f.name].content)
 		}
 
 		attrCount, err := intFrom2Bytes(bytes, pos+1)
 		pos += 2
 		if err != nil {
-			return pos, cfe("error retrieving attribute count for field: " +
-				klass.utf8Refs[f.name].content)
+			return pos, cfe("JACOBIN-CL-0128", klass.utf8Refs[f.name].content)
 		}
 
 		for j := 0; j < attrCount; j++ {
@@ -463,6 +536,10 @@ func parseFields(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 			if err != nil {
 				return pos, errors.New("") // error message will already have been displayed
 			}
+			owner := "field " + klass.utf8Refs[f.name].content + " in class " + klass.className
+			if err := checkAttributeLength(klass, attribute, owner); err != nil {
+				return pos, err
+			}
 			attrName := klass.utf8Refs[attribute.attrName].content
 			// if the attribute is a constant value (for initializing the field)
 			// then stick the value into the field struct. That value is a pointer
@@ -478,8 +555,7 @@ func parseFields(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 						int(attribute.attrContent[1])
 					entryInCp := klass.cpIndex[indexIntoCP]
 					if entryInCp.entryType != IntConst {
-						return pos, cfe("error: wrong type of constant value for byte " +
-							klass.utf8Refs[f.name].content)
+						return pos, cfe("JACOBIN-CL-0129", klass.utf8Refs[f.name].content)
 					}
 					f.constValue = klass.intConsts[entryInCp.slot]
 				case "C": // char--same logic as for "I", only error message is different
@@ -487,8 +563,7 @@ func parseFields(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 						int(attribute.attrContent[1])
 					entryInCp := klass.cpIndex[indexIntoCP]
 					if entryInCp.entryType != IntConst {
-						return pos, cfe("error: wrong type of constant value for char " +
-							klass.utf8Refs[f.name].content)
+						return pos, cfe("JACOBIN-CL-0130", klass.utf8Refs[f.name].content)
 					}
 					f.constValue = klass.intConsts[entryInCp.slot]
 				case "D": // double
@@ -496,8 +571,7 @@ func parseFields(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 						int(attribute.attrContent[1])
 					entryInCp := klass.cpIndex[indexIntoCP]
 					if entryInCp.entryType != DoubleConst {
-						return pos, cfe("error: wrong type of constant value for double " +
-							klass.utf8Refs[f.name].content)
+						return pos, cfe("JACOBIN-CL-0131", klass.utf8Refs[f.name].content)
 					}
 					f.constValue = klass.doubles[entryInCp.slot]
 				case "F": // float
@@ -505,8 +579,7 @@ func parseFields(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 						int(attribute.attrContent[1])
 					entryInCp := klass.cpIndex[indexIntoCP]
 					if entryInCp.entryType != FloatConst {
-						return pos, cfe("error: wrong type of constant value for float " +
-							klass.utf8Refs[f.name].content)
+						return pos, cfe("JACOBIN-CL-0132", klass.utf8Refs[f.name].content)
 					}
 					f.constValue = klass.floats[entryInCp.slot]
 				case "I": // integer
@@ -514,8 +587,7 @@ func parseFields(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 						int(attribute.attrContent[1])
 					entryInCp := klass.cpIndex[indexIntoCP]
 					if entryInCp.entryType != IntConst {
-						return pos, cfe("error: wrong type of constant value for integer " +
-							klass.utf8Refs[f.name].content)
+						return pos, cfe("JACOBIN-CL-0133", klass.utf8Refs[f.name].content)
 					}
 					f.constValue = klass.intConsts[entryInCp.slot]
 				case "J": // long
@@ -523,8 +595,7 @@ func parseFields(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 						int(attribute.attrContent[1])
 					entryInCp := klass.cpIndex[indexIntoCP]
 					if entryInCp.entryType != LongConst {
-						return pos, cfe("error: wrong type of constant value for long " +
-							klass.utf8Refs[f.name].content)
+						return pos, cfe("JACOBIN-CL-0134", klass.utf8Refs[f.name].content)
 					}
 					f.constValue = klass.longConsts[entryInCp.slot]
 				case "S": // short--same logic as int, only message is different
@@ -532,8 +603,7 @@ func parseFields(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 						int(attribute.attrContent[1])
 					entryInCp := klass.cpIndex[indexIntoCP]
 					if entryInCp.entryType != IntConst {
-						return pos, cfe("error: wrong type of constant value for short " +
-							klass.utf8Refs[f.name].content)
+						return pos, cfe("JACOBIN-CL-0135", klass.utf8Refs[f.name].content)
 					}
 					f.constValue = klass.intConsts[entryInCp.slot]
 				}
@@ -566,7 +636,7 @@ func parseMethodCount(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 	methodCount, err := intFrom2Bytes(bytes, pos+1)
 	pos += 2
 	if err != nil {
-		return pos, cfe("Invalid fetch of method count")
+		return pos, cfe("JACOBIN-CL-0136")
 	}
 
 	log.Log("method count: "+strconv.Itoa(methodCount), log.FINEST)
@@ -581,7 +651,7 @@ func parseClassAttributeCount(bytes []byte, loc int, klass *ParsedClass) (int, e
 	attributeCount, err := intFrom2Bytes(bytes, pos+1)
 	pos += 2
 	if err != nil {
-		return pos, cfe("Invalid fetch of class attribute count")
+		return pos, cfe("JACOBIN-CL-0137")
 	}
 
 	log.Log("Class attribute count: "+strconv.Itoa(attributeCount), log.FINEST)
@@ -597,8 +667,10 @@ func parseClassAttributes(bytes []byte, loc int, klass *ParsedClass) (int, error
 		if err == nil {
 			klass.attributes = append(klass.attributes, attrib)
 		} else {
-			return pos, cfe("Error fetching class attribute in class: " +
-				klass.className)
+			return pos, cfe("JACOBIN-CL-0138", klass.className)
+		}
+		if err := checkAttributeLength(klass, attrib, "class "+klass.className); err != nil {
+			return pos, err
 		}
 
 		log.Log("Class: "+klass.className+", attribute: "+klass.utf8Refs[attrib.attrName].content,
@@ -620,7 +692,7 @@ func parseClassAttributes(bytes []byte, loc int, klass *ParsedClass) (int, error
 				methodRef, err2 := u16From2bytes(attrib.attrContent, loc)
 				loc += 2
 				if err2 != nil || klass.cpIndex[methodRef].entryType != MethodHandle {
-					return pos, cfe("Invalid method reference in Boostrap method #" + strconv.Itoa(m))
+					return pos, cfe("JACOBIN-CL-0139", strconv.Itoa(m))
 				} else {
 					bsm.methodRef = int(methodRef)
 				}
@@ -16,7 +16,7 @@ import (
 // read two bytes in big endian order and convert to an int
 func intFrom2Bytes(bytes []byte, pos int) (int, error) {
 	if len(bytes) < pos+2 {
-		return 0, cfe("invalid offset into file")
+		return 0, cfe("JACOBIN-CL-0140")
 	}
 
 	value := (uint16(bytes[pos]) << 8) + uint16(bytes[pos+1])
@@ -36,7 +36,7 @@ func u16From2bytes(bytes []byte, pos int) (uint16, error) {
 // read four bytes in big endian order and convert to an int
 func intFrom4Bytes(bytes []byte, pos int) (int, error) {
 	if len(bytes) < pos+4 {
-		return 0, cfe("invalid offset into file")
+		return 0, cfe("JACOBIN-CL-0140")
 	}
 
 	value1 := (uint32(bytes[pos]) << 8) + uint32(bytes[pos+1])
@@ -49,16 +49,16 @@ func intFrom4Bytes(bytes []byte, pos int) (int, error) {
 // to a UTF8 entry. Does extensive checking of values.
 func fetchUTF8string(klass *ParsedClass, index int) (string, error) {
 	if index < 1 || index > klass.cpCount-1 {
-		return "", cfe("attempt to fetch invalid UTF8 at CP entry #" + strconv.Itoa(index))
+		return "", cfe("JACOBIN-CL-0141", strconv.Itoa(index))
 	}
 
 	if klass.cpIndex[index].entryType != UTF8 {
-		return "", cfe("attempt to fetch UTF8 string from non-UTF8 CP entry #" + strconv.Itoa(index))
+		return "", cfe("JACOBIN-CL-0142", strconv.Itoa(index))
 	}
 
 	i := klass.cpIndex[index].slot
 	if i < 0 || i > len(klass.utf8Refs)-1 {
-		return "", cfe("invalid index into UTF8 array of CP: " + strconv.Itoa(i))
+		return "", cfe("JACOBIN-CL-0143", strconv.Itoa(i))
 	}
 
 	return klass.utf8Refs[i].content, nil
@@ -68,16 +68,16 @@ func fetchUTF8string(klass *ParsedClass, index int) (string, error) {
 // rather than the string that's in that slot.
 func fetchUTF8slot(klass *ParsedClass, index int) (int, error) {
 	if index < 1 || index > klass.cpCount-1 {
-		return -1, cfe("attempt to fetch invalid UTF8 at CP entry #" + strconv.Itoa(index))
+		return -1, cfe("JACOBIN-CL-0141", strconv.Itoa(index))
 	}
 
 	if klass.cpIndex[index].entryType != UTF8 {
-		return -1, cfe("attempt to fetch UTF8 string from non-UTF8 CP entry #" + strconv.Itoa(index))
+		return -1, cfe("JACOBIN-CL-0142", strconv.Itoa(index))
 	}
 
 	slot := klass.cpIndex[index].slot
 	if slot < 0 || slot > len(klass.utf8Refs)-1 {
-		return -1, cfe("invalid index into UTF8 array of CP: " + strconv.Itoa(slot))
+		return -1, cfe("JACOBIN-CL-0143", strconv.Itoa(slot))
 	}
 	return slot, nil
 }
@@ -97,11 +97,11 @@ func fetchAttribute(klass *ParsedClass, bytes []byte, loc int) (attr, int, error
 	nameIndex, err := intFrom2Bytes(bytes, pos+1)
 	pos += 2
 	if err != nil {
-		return attribute, pos, cfe("error fetching field attribute")
+		return attribute, pos, cfe("JACOBIN-CL-0144")
 	}
 	nameSlot, err := fetchUTF8slot(klass, nameIndex)
 	if err != nil {
-		return attribute, pos, cfe("error fetching name of field attribute")
+		return attribute, pos, cfe("JACOBIN-CL-0145")
 	}
 
 	attribute.attrName = nameSlot // slot in UTF-8 slice of CP
@@ -109,9 +109,12 @@ func fetchAttribute(klass *ParsedClass, bytes []byte, loc int) (attr, int, error
 	length, err := intFrom4Bytes(bytes, pos+1)
 	pos += 4
 	if err != nil {
-		return attribute, pos, cfe("error fetching length of field attribute")
+		return attribute, pos, cfe("JACOBIN-CL-0146")
 	}
 	attribute.attrSize = length
+	if pos+length >= len(bytes) {
+		return attribute, pos, cfe("JACOBIN-CL-0146")
+	}
 
 	b := make([]byte, length)
 	for i := 0; i < length; i++ {
@@ -128,21 +131,22 @@ func fetchAttribute(klass *ParsedClass, bytes []byte, loc int) (attr, int, error
 //	nameAndTypeIndex int
 func resolveCPmethodRef(index int, klass *ParsedClass) (string, string, string, error) {
 	if index < 1 || index >= len(klass.cpIndex) {
-		return "", "", "", cfe("Invalid index into CP: " + strconv.Itoa(index))
+		return "", "", "", cfe("JACOBIN-CL-0147", strconv.Itoa(index))
 	}
 	cpEnt := klass.cpIndex[index]
 	if cpEnt.entryType != MethodRef {
-		return "", "", "", cfe("Expecting MethodRef (10) at CP entry #" + strconv.Itoa(index) +
-			" but instead got CP type: " + strconv.Itoa(cpEnt.entryType))
+		return "", "", "", cfe("JACOBIN-CL-0148", strconv.Itoa(index), strconv.Itoa(cpEnt.entryType))
 	}
 
 	methRef := klass.methodRefs[cpEnt.slot]
-	pointedToClassRef := klass.cpIndex[methRef.classIndex]
+	pointedToClassRef := cpEntryAt(klass, methRef.classIndex)
+	if pointedToClassRef.entryType != ClassRef {
+		return "", "", "", cfe("JACOBIN-CL-0149", strconv.Itoa(index))
+	}
 	nameIndex := klass.classRefs[pointedToClassRef.slot]
 	className, err := fetchUTF8string(klass, nameIndex)
 	if err != nil {
-		return "", "", "", cfe("ClassRef entry in MethodRef CP entry #" + strconv.Itoa(index) +
-			" does not point to a valid string")
+		return "", "", "", cfe("JACOBIN-CL-0149", strconv.Itoa(index))
 	}
 
 	// pointedToNandT := klass.cpIndex[methRef.nameAndTypeIndex]
@@ -157,25 +161,25 @@ func resolveCPmethodRef(index int, klass *ParsedClass) (string, string, string,
 
 func resolveCPnameAndType(klass *ParsedClass, index int) (string, string, error) {
 	if index < 1 || index >= len(klass.cpIndex) {
-		return "", "", cfe("Invalid nameAndType index into CP: " +
-			strconv.Itoa(index))
+		return "", "", cfe("JACOBIN-CL-0150", strconv.Itoa(index))
 	}
 
 	nAndTindex := klass.cpIndex[index]
+	if nAndTindex.entryType != NameAndType {
+		return "", "", cfe("JACOBIN-CL-0150", strconv.Itoa(index))
+	}
 	nAndT := klass.nameAndTypes[nAndTindex.slot]
 	nameIndex := nAndT.nameIndex
 	descIndex := nAndT.descriptorIndex
 
-	if klass.cpIndex[nameIndex].entryType != UTF8 {
-		return "", "", cfe("Name index in nameAndType entry (CP #" + strconv.Itoa(index) +
-			") does not point to a UTF8 entry.")
+	if cpEntryAt(klass, nameIndex).entryType != UTF8 {
+		return "", "", cfe("JACOBIN-CL-0151", strconv.Itoa(index))
 	}
 
 	name := klass.utf8Refs[klass.cpIndex[nameIndex].slot]
 
-	if klass.cpIndex[descIndex].entryType != UTF8 {
-		return "", "", cfe("Desc index in nameAndType entry (CP #" + strconv.Itoa(index) +
-			") does not point to a UTF8 entry.")
+	if cpEntryAt(klass, descIndex).entryType != UTF8 {
+		return "", "", cfe("JACOBIN-CL-0152", strconv.Itoa(index))
 	}
 
 	desc := klass.utf8Refs[klass.cpIndex[descIndex].slot]
@@ -7,10 +7,14 @@
 package classloader
 
 import (
+	"bytes"
+	"encoding/binary"
 	"io/ioutil"
 	"jacobin/globals"
 	"jacobin/log"
+	"jacobin/messages"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -124,11 +128,65 @@ func TestParseOfInvalidJavaVersionNumber(t *testing.T) {
 		t.Error("Invalid Java version number did not generate an error")
 	}
 
-	if !strings.Contains(msg, "supports only Java versions") {
+	if !strings.Contains(msg, "java.lang.UnsupportedClassVersionError: the class has been compiled by a more recent version") {
 		t.Error("Did not get expected error msg for invalid Java version. Got: " + msg)
 	}
 }
 
+// the messages for versions outside the range are the JDK's, and name the class
+func TestUnsupportedClassVersions(t *testing.T) {
+	globals.InitGlobals("test")
+	global := globals.GetGlobalRef()
+	log.Init()
+	normalStderr := os.Stderr
+	defer func() { os.Stderr = normalStderr }()
+
+	withVersion := func(minor, major int) []byte {
+		class := append([]byte{}, haveInterfaceClass...)
+		class[4], class[5], class[6], class[7] = byte(minor>>8), byte(minor), byte(major>>8), byte(major)
+		return class
+	}
+	for _, c := range []struct {
+		class    []byte
+		expected string
+	}{
+		{withVersion(0, 61), "java.lang.UnsupportedClassVersionError: HaveInterface has been compiled by a more recent " +
+			"version of the Java Runtime (class file version 61.0), this version of the Java Runtime only " +
+			"recognizes class file versions up to 55.0"},
+		{withVersion(3, 44), "java.lang.UnsupportedClassVersionError: HaveInterface (class file version 44.3) " +
+			"was compiled with an invalid major version"},
+	} {
+		r, w, _ := os.Pipe()
+		os.Stderr = w
+		_, err := parse(c.class)
+		_ = w.Close()
+		out, _ := ioutil.ReadAll(r)
+		if err == nil || strings.TrimSpace(string(out)) != c.expected {
+			t.Errorf("Expected %q, got %q (%v)", c.expected, string(out), err)
+		}
+	}
+
+	for _, version := range [][2]int{{0, 45}, {3, 45}, {0xFFFF, 50}, {0, 55}} {
+		if _, err := parse(withVersion(version[0], version[1])); err != nil {
+			t.Errorf("Expected version %d.%d to be loaded, got %v", version[1], version[0], err)
+		}
+	}
+
+	// from Java 12 on, the minor version is 0 but for preview features
+	global.MaxJavaVersion, global.MaxJavaVersionRaw = 17, 61
+	defer func() { global.MaxJavaVersion, global.MaxJavaVersionRaw = 11, 55 }()
+	os.Stderr, _ = os.Open(os.DevNull)
+	if _, err := parse(withVersion(0, 61)); err != nil {
+		t.Errorf("Expected version 61.0 to be loaded when it's the maximum, got %v", err)
+	}
+	if _, err := parse(withVersion(1, 61)); err == nil || !strings.Contains(err.Error(), "invalid non-zero minor version") {
+		t.Errorf("Expected version 61.1 to be refused, got %v", err)
+	}
+	if _, err := parse(withVersion(0xFFFF, 61)); err == nil || !strings.Contains(err.Error(), "Preview features are not enabled") {
+		t.Errorf("Expected version 61.65535 to be refused, got %v", err)
+	}
+}
+
 func TestParseValidJavaVersion(t *testing.T) {
 	globals.InitGlobals("test")
 	log.Init()
@@ -351,6 +409,9 @@ func TestClassNameWhenDoesNotPointToClassRef(t *testing.T) {
 	if !strings.HasPrefix(err.Error(), "Class Format Error: invalid entry for class name") {
 		t.Error("Expected error msg about invalid entry for class name. Got: " + err.Error())
 	}
+	if messages.CodeOf(err) != "JACOBIN-CL-0110" {
+		t.Errorf("Expected message JACOBIN-CL-0110 for invalid class name, got: %s", messages.CodeOf(err))
+	}
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
@@ -1134,3 +1195,82 @@ func TestDeprecatedClassAttribute(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 }
+
+// ---- benchmarks ----
+
+// benchCorpus is the fixed corpus of the parser's and format checker's benchmarks: the
+// test classes in testdata and a class with a large CP (see bigCPClass())
+func benchCorpus(b *testing.B) []benchClass {
+	var corpus []benchClass
+	for _, name := range []string{"Hello", "Hello2", "Hello3", "NanoPrint"} {
+		class, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", name+".class"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		corpus = append(corpus, benchClass{name, class})
+	}
+	return append(corpus, benchClass{"BigCP", bigCPClass(2000)})
+}
+
+type benchClass struct {
+	name  string
+	bytes []byte
+}
+
+// bigCPClass returns the class file of a class with no members whose CP has, for each
+// of n methods, a name, a NameAndType, a Methodref, an Integer, and a String: 5n + 5
+// entries in all
+func bigCPClass(n int) []byte {
+	var class bytes.Buffer
+	u2 := func(v int) { _ = binary.Write(&class, binary.BigEndian, uint16(v)) }
+	utf8 := func(s string) {
+		class.WriteByte(UTF8)
+		u2(len(s))
+		class.WriteString(s)
+	}
+	_ = binary.Write(&class, binary.BigEndian, uint32(0xCAFEBABE))
+	u2(0)
+	u2(55)
+	u2(6 + 5*n)
+	utf8("bench/BigCP") // 1
+	class.WriteByte(ClassRef)
+	u2(1)
+	utf8("java/lang/Object") // 3
+	class.WriteByte(ClassRef)
+	u2(3)
+	utf8("(I)I") // 5
+	for i := 0; i < n; i++ {
+		name := 6 + 5*i
+		utf8("method" + strconv.Itoa(i))
+		class.WriteByte(NameAndType)
+		u2(name)
+		u2(5)
+		class.WriteByte(MethodRef)
+		u2(2)
+		u2(name + 1)
+		class.WriteByte(IntConst)
+		_ = binary.Write(&class, binary.BigEndian, int32(i))
+		class.WriteByte(StringConst)
+		u2(name)
+	}
+	for _, v := range []int{0x21, 2, 4, 0, 0, 0, 0} {
+		u2(v)
+	}
+	return class.Bytes()
+}
+
+func BenchmarkParse(b *testing.B) {
+	globals.InitGlobals("test")
+	log.Init()
+	for _, class := range benchCorpus(b) {
+		class := class
+		b.Run(class.name, func(b *testing.B) {
+			b.SetBytes(int64(len(class.bytes)))
+			for i := 0; i < b.N; i++ {
+				if _, err := parse(class.bytes); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// The OS queries behind ProcessHandle, which on Linux read /proc
+
+// procStat returns the fields of /proc/<pid>/stat that follow the command name
+// (which is in parentheses and may itself contain spaces and parentheses)
+func procStat(pid int) ([]string, bool) {
+	stat, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return nil, false
+	}
+	end := strings.LastIndexByte(string(stat), ')')
+	if end == -1 {
+		return nil, false
+	}
+	return strings.Fields(string(stat[end+1:])), true
+}
+
+// pidAlive reports whether the process exists and hasn't terminated. Zombies (which
+// have exited but not been reaped) are not alive.
+func pidAlive(pid int) bool {
+	fields, ok := procStat(pid)
+	return ok && len(fields) > 0 && fields[0] != "Z" && fields[0] != "X"
+}
+
+// parentPid returns the pid of the process's parent
+func parentPid(pid int) (int, bool) {
+	fields, ok := procStat(pid)
+	if !ok || len(fields) < 2 {
+		return 0, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	return ppid, err == nil && ppid > 0
+}
+
+// processParents returns the parent of every process, keyed by pid
+func processParents() map[int]int {
+	parents := make(map[int]int)
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return parents
+	}
+	for _, e := range entries {
+		if pid, err := strconv.Atoi(e.Name()); err == nil {
+			if ppid, ok := parentPid(pid); ok {
+				parents[pid] = ppid
+			}
+		}
+	}
+	return parents
+}
+
+// processInfo returns what can be found out about the process. Fields that can't be
+// determined (for lack of permission, say) are left empty.
+func processInfo(pid int) procInfo {
+	var info procInfo
+	dir := "/proc/" + strconv.Itoa(pid) + "/"
+	if exe, err := os.Readlink(dir + "exe"); err == nil {
+		info.command = exe
+	}
+	if cmdline, err := ioutil.ReadFile(dir + "cmdline"); err == nil && len(cmdline) > 0 {
+		args := strings.Split(strings.TrimSuffix(string(cmdline), "\x00"), "\x00")
+		info.args = args[1:]
+		info.hasArgs = true
+	}
+	if status, err := ioutil.ReadFile(dir + "status"); err == nil {
+		for _, line := range strings.Split(string(status), "\n") {
+			if fields := strings.Fields(line); len(fields) > 1 && fields[0] == "Uid:" {
+				if u, err := user.LookupId(fields[1]); err == nil {
+					info.user = u.Username
+				} else {
+					info.user = fields[1]
+				}
+			}
+		}
+	}
+	return info
+}
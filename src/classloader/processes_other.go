@@ -0,0 +1,87 @@
+//go:build !linux
+// +build !linux
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// The OS queries behind ProcessHandle, which on platforms without /proc run ps. Where
+// there's no ps (on Windows), only the current process and its parent are known.
+// TODO: use the Toolhelp32 API on Windows
+
+// ps runs ps with the arguments and returns its output lines
+func ps(args ...string) ([]string, bool) {
+	out, err := exec.Command("ps", args...).Output()
+	if err != nil {
+		return nil, false
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), true
+}
+
+func pidAlive(pid int) bool {
+	if pid == os.Getpid() {
+		return true
+	}
+	lines, ok := ps("-p", strconv.Itoa(pid), "-o", "state=")
+	return ok && len(lines) == 1 && !strings.HasPrefix(strings.TrimSpace(lines[0]), "Z")
+}
+
+func parentPid(pid int) (int, bool) {
+	if pid == os.Getpid() {
+		return os.Getppid(), os.Getppid() > 0
+	}
+	lines, ok := ps("-p", strconv.Itoa(pid), "-o", "ppid=")
+	if !ok || len(lines) != 1 {
+		return 0, false
+	}
+	ppid, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	return ppid, err == nil && ppid > 0
+}
+
+func processParents() map[int]int {
+	parents := make(map[int]int)
+	lines, ok := ps("-A", "-o", "pid=,ppid=")
+	if !ok {
+		parents[os.Getpid()] = os.Getppid()
+		return parents
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			pid, err1 := strconv.Atoi(fields[0])
+			ppid, err2 := strconv.Atoi(fields[1])
+			if err1 == nil && err2 == nil {
+				parents[pid] = ppid
+			}
+		}
+	}
+	return parents
+}
+
+// processInfo gets the user and command line from ps. The arguments are split on
+// spaces, since ps doesn't preserve the boundaries between them.
+func processInfo(pid int) procInfo {
+	var info procInfo
+	if lines, ok := ps("-p", strconv.Itoa(pid), "-o", "user="); ok && len(lines) == 1 {
+		info.user = strings.TrimSpace(lines[0])
+	}
+	if lines, ok := ps("-p", strconv.Itoa(pid), "-o", "args="); ok && len(lines) == 1 {
+		if fields := strings.Fields(lines[0]); len(fields) > 0 {
+			info.command = fields[0]
+			info.args = fields[1:]
+			info.hasArgs = true
+		}
+	}
+	return info
+}
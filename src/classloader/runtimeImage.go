@@ -0,0 +1,341 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"jacobin/messages"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The modules of the Java runtime image in JAVA_HOME. A JDK has a jmods directory with a
+// .jmod file for each module: a 4-byte header (JM, then the version 1.0) followed by a
+// ZIP file, whose classes/module-info.class has the module's descriptor. The runtime
+// image itself (lib/modules, in the jimage format) isn't read yet, so without jmods the
+// modules can be listed, from the MODULES line of JAVA_HOME's release file, but not
+// described.
+
+// ModuleDescriptor is what a module-info class says about a module, as described in
+// https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.7.25
+// The package and class names are in their Java form, as in java.util.logging.
+type ModuleDescriptor struct {
+	Name      string
+	Version   string
+	Open      bool
+	Requires  []ModuleRequires
+	Exports   []ModulePackage
+	Opens     []ModulePackage
+	Uses      []string
+	Provides  []ModuleProvides
+	Packages  []string // all the module's packages (from the ModulePackages attribute)
+	MainClass string   // the main class (from the ModuleMainClass attribute), if any
+}
+
+// ModuleRequires is a module that a module depends on
+type ModuleRequires struct {
+	Name      string
+	Modifiers []string // transitive, static, synthetic, and mandated, as they apply
+}
+
+// ModulePackage is an exported or opened package, and the modules it's exported or
+// opened to (none if it's exported or opened to all modules)
+type ModulePackage struct {
+	Name string
+	To   []string
+}
+
+// ModuleProvides is a service and the classes that a module provides it with
+type ModuleProvides struct {
+	Service string
+	With    []string
+}
+
+// module flags, which are in the JVM spec
+const (
+	accModuleOpen         = 0x0020
+	accRequiresTransitive = 0x0020
+	accRequiresStatic     = 0x0040
+	accModuleSynthetic    = 0x1000
+	accModuleMandated     = 0x8000
+)
+
+var jmodMagic = []byte{'J', 'M', 1, 0}
+
+// RuntimeModules returns the names and versions of the modules in the runtime image
+// in the Java home, sorted by name. The version is "" if it's not known.
+func RuntimeModules(javaHome string) ([]ModuleDescriptor, error) {
+	jmods, _ := filepath.Glob(filepath.Join(javaHome, "jmods", "*.jmod"))
+	if len(jmods) > 0 {
+		var modules []ModuleDescriptor
+		for _, jmod := range jmods {
+			md, err := ReadJmodDescriptor(jmod)
+			if err != nil {
+				return nil, err
+			}
+			modules = append(modules, *md)
+		}
+		sort.Slice(modules, func(i, j int) bool { return modules[i].Name < modules[j].Name })
+		return modules, nil
+	}
+
+	release, err := readReleaseFile(filepath.Join(javaHome, "release"))
+	if err != nil || release["MODULES"] == "" {
+		return nil, messages.New("JACOBIN-CL-0153", javaHome)
+	}
+	var modules []ModuleDescriptor
+	for _, name := range strings.Fields(release["MODULES"]) {
+		modules = append(modules, ModuleDescriptor{Name: name, Version: release["JAVA_VERSION"]})
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Name < modules[j].Name })
+	return modules, nil
+}
+
+// RuntimeModule returns the descriptor of the named module in the runtime image in
+// the Java home
+func RuntimeModule(javaHome, name string) (*ModuleDescriptor, error) {
+	jmod := filepath.Join(javaHome, "jmods", name+".jmod")
+	if _, err := os.Stat(jmod); err == nil {
+		return ReadJmodDescriptor(jmod)
+	}
+	if _, err := os.Stat(filepath.Join(javaHome, "jmods")); err != nil {
+		return nil, messages.New("JACOBIN-CL-0154", name, javaHome)
+	}
+	return nil, messages.New("JACOBIN-CL-0155", name)
+}
+
+// ReadJmodDescriptor returns the descriptor of the module in a .jmod file
+func ReadJmodDescriptor(path string) (*ModuleDescriptor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, len(jmodMagic))
+	if _, err = io.ReadFull(f, header); err != nil || string(header) != string(jmodMagic) {
+		return nil, messages.New("JACOBIN-CL-0156", path)
+	}
+	z, err := zip.NewReader(io.NewSectionReader(f, 4, info.Size()-4), info.Size()-4)
+	if err != nil {
+		return nil, messages.New("JACOBIN-CL-0156", path)
+	}
+	for _, entry := range z.File {
+		if entry.Name == "classes/module-info.class" {
+			rc, err := entry.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				return nil, err
+			}
+			return ParseModuleInfo(b)
+		}
+	}
+	return nil, messages.New("JACOBIN-CL-0156", path)
+}
+
+// readReleaseFile reads the release file in a Java home, which has lines of the form
+// NAME="value"
+func readReleaseFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if eq := strings.Index(line, "="); eq > 0 {
+			values[line[:eq]] = strings.Trim(line[eq+1:], "\"")
+		}
+	}
+	return values, scanner.Err()
+}
+
+// moduleInfoReader reads the big-endian values of a module-info class, remembering
+// the first error, so that the reading code needn't check each value
+type moduleInfoReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+var errModuleInfoTruncated = errors.New("truncated")
+
+func (r *moduleInfoReader) u1() int {
+	if r.err != nil || r.pos+1 > len(r.b) {
+		r.err = errModuleInfoTruncated
+		return 0
+	}
+	r.pos++
+	return int(r.b[r.pos-1])
+}
+
+func (r *moduleInfoReader) u2() int {
+	if r.err != nil || r.pos+2 > len(r.b) {
+		r.err = errModuleInfoTruncated
+		return 0
+	}
+	r.pos += 2
+	return int(binary.BigEndian.Uint16(r.b[r.pos-2:]))
+}
+
+func (r *moduleInfoReader) u4() int {
+	if r.err != nil || r.pos+4 > len(r.b) {
+		r.err = errModuleInfoTruncated
+		return 0
+	}
+	r.pos += 4
+	return int(binary.BigEndian.Uint32(r.b[r.pos-4:]))
+}
+
+func (r *moduleInfoReader) skip(n int) {
+	if r.err != nil || r.pos+n > len(r.b) {
+		r.err = errModuleInfoTruncated
+		return
+	}
+	r.pos += n
+}
+
+// ParseModuleInfo reads the descriptor in a module-info class. The class is read here
+// rather than by parse(), which takes the Module and Package entries of a class's constant
+// pool to name the class's own module and package, whereas module-info refers to many.
+func ParseModuleInfo(b []byte) (*ModuleDescriptor, error) {
+	r := &moduleInfoReader{b: b}
+	if r.u4() != 0xCAFEBABE {
+		return nil, messages.New("JACOBIN-CL-0157", "invalid magic number")
+	}
+	r.skip(4) // the minor and major versions
+
+	// the constant pool. Only the UTF8 entries and the entries that point to them are kept.
+	count := r.u2()
+	utf8s := make(map[int]string)
+	refs := make(map[int]int) // the Class, Module, and Package entries, to their names
+	for i := 1; i < count && r.err == nil; i++ {
+		switch r.u1() {
+		case UTF8:
+			length := r.u2()
+			start := r.pos
+			r.skip(length)
+			if r.err == nil {
+				utf8s[i] = string(b[start : start+length])
+			}
+		case ClassRef, Module, Package:
+			refs[i] = r.u2()
+		case StringConst, MethodType:
+			r.skip(2)
+		case MethodHandle:
+			r.skip(3)
+		case IntConst, FloatConst, FieldRef, MethodRef, Interface, NameAndType, Dynamic, InvokeDynamic:
+			r.skip(4)
+		case LongConst, DoubleConst:
+			r.skip(8)
+			i++ // these take two entries
+		default:
+			return nil, messages.New("JACOBIN-CL-0157", "invalid constant pool entry")
+		}
+	}
+	name := func(index int) string { // the Java form of the name that a CP entry refers to
+		return strings.ReplaceAll(utf8s[refs[index]], "/", ".")
+	}
+	names := func() []string { // a count followed by that many entries
+		n := r.u2()
+		list := make([]string, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			list = append(list, name(r.u2()))
+		}
+		return list
+	}
+
+	r.skip(6) // the access flags, this class, and the superclass
+	if r.u2() != 0 || r.u2() != 0 || r.u2() != 0 {
+		return nil, messages.New("JACOBIN-CL-0157", "a module-info class can't have interfaces, fields, or methods")
+	}
+
+	var md *ModuleDescriptor
+	var packages []string
+	mainClass := ""
+	attributes := r.u2()
+	for a := 0; a < attributes && r.err == nil; a++ {
+		attrName := utf8s[r.u2()]
+		length := r.u4()
+		end := r.pos + length
+		switch attrName {
+		case "Module":
+			md = &ModuleDescriptor{Name: name(r.u2())}
+			md.Open = r.u2()&accModuleOpen != 0
+			md.Version = utf8s[r.u2()]
+
+			requires := r.u2()
+			for i := 0; i < requires && r.err == nil; i++ {
+				req := ModuleRequires{Name: name(r.u2())}
+				flags := r.u2()
+				r.skip(2) // the version it was compiled against
+				for _, m := range []struct {
+					flag int
+					name string
+				}{{accRequiresTransitive, "transitive"}, {accRequiresStatic, "static"},
+					{accModuleSynthetic, "synthetic"}, {accModuleMandated, "mandated"}} {
+					if flags&m.flag != 0 {
+						req.Modifiers = append(req.Modifiers, m.name)
+					}
+				}
+				md.Requires = append(md.Requires, req)
+			}
+			for _, list := range []*[]ModulePackage{&md.Exports, &md.Opens} {
+				n := r.u2()
+				for i := 0; i < n && r.err == nil; i++ {
+					pkg := ModulePackage{Name: name(r.u2())}
+					r.skip(2) // the flags
+					pkg.To = names()
+					*list = append(*list, pkg)
+				}
+			}
+			md.Uses = names()
+			provides := r.u2()
+			for i := 0; i < provides && r.err == nil; i++ {
+				service := name(r.u2())
+				md.Provides = append(md.Provides, ModuleProvides{Service: service, With: names()})
+			}
+		case "ModulePackages":
+			packages = names()
+		case "ModuleMainClass":
+			mainClass = name(r.u2())
+		}
+		if r.err == nil && r.pos != end {
+			if r.pos > end || end > len(b) {
+				return nil, messages.New("JACOBIN-CL-0157", "invalid length of attribute "+attrName)
+			}
+			r.pos = end
+		}
+	}
+
+	if r.err != nil {
+		return nil, messages.New("JACOBIN-CL-0157", r.err.Error())
+	}
+	if md == nil {
+		return nil, messages.New("JACOBIN-CL-0157", "no Module attribute")
+	}
+	md.Packages = packages
+	md.MainClass = mainClass
+	return md, nil
+}
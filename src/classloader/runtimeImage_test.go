@@ -0,0 +1,167 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"jacobin/messages"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testModuleInfo builds the module-info class of a module like java.logging
+func testModuleInfo() []byte {
+	var cp bytes.Buffer
+	count := 1
+	u2 := func(b *bytes.Buffer, v int) { _ = binary.Write(b, binary.BigEndian, uint16(v)) }
+	utf8 := func(s string) int {
+		cp.WriteByte(UTF8)
+		u2(&cp, len(s))
+		cp.WriteString(s)
+		count++
+		return count - 1
+	}
+	ref := func(tag byte, name string) int {
+		n := utf8(name)
+		cp.WriteByte(tag)
+		u2(&cp, n)
+		count++
+		return count - 1
+	}
+
+	this := ref(ClassRef, "module-info")
+	moduleAttr, packagesAttr := utf8("Module"), utf8("ModulePackages")
+	logging, base := ref(Module, "java.logging"), ref(Module, "java.base")
+	version := utf8("11.0.10")
+	api, internal := ref(Package, "java/util/logging"), ref(Package, "sun/util/logging/internal")
+	finder := ref(ClassRef, "jdk/internal/logger/DefaultLoggerFinder")
+	impl := ref(ClassRef, "sun/util/logging/internal/LoggingProviderImpl")
+	cp.WriteByte(LongConst) // a long constant, which takes two entries
+	cp.Write(make([]byte, 8))
+	count += 2
+
+	var module bytes.Buffer
+	for _, v := range []int{logging, 0, version,
+		1, base, 0x8000, 0, // requires java.base mandated
+		1, api, 0, 0, // exports java.util.logging
+		1, internal, 0, 1, base, // opens sun.util.logging.internal to java.base
+		0,                    // uses
+		1, finder, 1, impl} { // provides
+		u2(&module, v)
+	}
+	var packages bytes.Buffer
+	for _, v := range []int{2, api, internal} {
+		u2(&packages, v)
+	}
+
+	var class bytes.Buffer
+	_ = binary.Write(&class, binary.BigEndian, uint32(0xCAFEBABE))
+	for _, v := range []int{0, 55, count} {
+		u2(&class, v)
+	}
+	class.Write(cp.Bytes())
+	for _, v := range []int{0x8000, this, 0, 0, 0, 0, 2} {
+		u2(&class, v)
+	}
+	for _, attr := range []struct {
+		name int
+		body []byte
+	}{{moduleAttr, module.Bytes()}, {packagesAttr, packages.Bytes()}} {
+		u2(&class, attr.name)
+		_ = binary.Write(&class, binary.BigEndian, uint32(len(attr.body)))
+		class.Write(attr.body)
+	}
+	return class.Bytes()
+}
+
+func TestParseModuleInfo(t *testing.T) {
+	md, err := ParseModuleInfo(testModuleInfo())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := &ModuleDescriptor{
+		Name:     "java.logging",
+		Version:  "11.0.10",
+		Requires: []ModuleRequires{{Name: "java.base", Modifiers: []string{"mandated"}}},
+		Exports:  []ModulePackage{{Name: "java.util.logging", To: []string{}}},
+		Opens:    []ModulePackage{{Name: "sun.util.logging.internal", To: []string{"java.base"}}},
+		Uses:     []string{},
+		Provides: []ModuleProvides{{Service: "jdk.internal.logger.DefaultLoggerFinder",
+			With: []string{"sun.util.logging.internal.LoggingProviderImpl"}}},
+		Packages: []string{"java.util.logging", "sun.util.logging.internal"},
+	}
+	if !reflect.DeepEqual(md, want) {
+		t.Errorf("Expected %+v, got %+v", want, md)
+	}
+}
+
+func TestParseTruncatedModuleInfo(t *testing.T) {
+	b := testModuleInfo()
+	_, err := ParseModuleInfo(b[:len(b)-3])
+	if messages.CodeOf(err) != "JACOBIN-CL-0157" {
+		t.Errorf("Expected an invalid module-info error, got: %v", err)
+	}
+}
+
+func TestRuntimeModulesFromJmods(t *testing.T) {
+	home, _ := ioutil.TempDir("", "jacobin-jdk")
+	defer os.RemoveAll(home)
+	_ = os.Mkdir(filepath.Join(home, "jmods"), 0755)
+
+	var jmod bytes.Buffer
+	jmod.Write(jmodMagic)
+	z := zip.NewWriter(&jmod)
+	w, _ := z.Create("classes/module-info.class")
+	_, _ = w.Write(testModuleInfo())
+	_ = z.Close()
+	_ = ioutil.WriteFile(filepath.Join(home, "jmods", "java.logging.jmod"), jmod.Bytes(), 0644)
+
+	modules, err := RuntimeModules(home)
+	if err != nil || len(modules) != 1 || modules[0].Name != "java.logging" || modules[0].Version != "11.0.10" {
+		t.Errorf("Expected the java.logging module, got %v (%v)", modules, err)
+	}
+	if md, err := RuntimeModule(home, "java.logging"); err != nil || len(md.Packages) != 2 {
+		t.Errorf("Expected the descriptor of java.logging, got %v (%v)", md, err)
+	}
+	if _, err := RuntimeModule(home, "java.nothing"); messages.CodeOf(err) != "JACOBIN-CL-0155" {
+		t.Errorf("Expected a module not found error, got: %v", err)
+	}
+}
+
+// without jmods, the modules are listed from the release file, but can't be described
+func TestRuntimeModulesFromReleaseFile(t *testing.T) {
+	home, _ := ioutil.TempDir("", "jacobin-jre")
+	defer os.RemoveAll(home)
+	_ = ioutil.WriteFile(filepath.Join(home, "release"),
+		[]byte("JAVA_VERSION=\"11.0.10\"\nMODULES=\"java.base java.logging java.desktop\"\n"), 0644)
+
+	modules, err := RuntimeModules(home)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var names []string
+	for _, md := range modules {
+		names = append(names, md.Name+"@"+md.Version)
+	}
+	want := []string{"java.base@11.0.10", "java.desktop@11.0.10", "java.logging@11.0.10"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected %v, got %v", want, names)
+	}
+	if _, err := RuntimeModule(home, "java.base"); messages.CodeOf(err) != "JACOBIN-CL-0154" {
+		t.Errorf("Expected an error for describing a module without jmods, got: %v", err)
+	}
+
+	_ = os.Remove(filepath.Join(home, "release"))
+	if _, err := RuntimeModules(home); messages.CodeOf(err) != "JACOBIN-CL-0153" {
+		t.Errorf("Expected an error for a home without a runtime image, got: %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build js
+// +build js
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "syscall"
+
+// the signals Go defines for js/wasm. None is ever delivered, since there's no process
+// to send them to, but Java code can raise them itself.
+var signalNumbers = map[string]syscall.Signal{
+	"CHLD": syscall.SIGCHLD, "INT": syscall.SIGINT, "KILL": syscall.SIGKILL,
+	"QUIT": syscall.SIGQUIT, "TERM": syscall.SIGTERM, "TRAP": syscall.SIGTRAP,
+}
+
+// raiseSignal delivers the signal in-process
+func raiseSignal(sig syscall.Signal) {
+	if sig > 0 {
+		dispatchSignal(sig)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !linux && !darwin && !freebsd && !js
+// +build !linux,!darwin,!freebsd,!js
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "syscall"
+
+// the signals Go defines on Windows. Only SIGINT and SIGTERM (which are console
+// control events) are ever delivered.
+var signalNumbers = map[string]syscall.Signal{
+	"ABRT": syscall.SIGABRT, "ALRM": syscall.SIGALRM, "BUS": syscall.SIGBUS,
+	"FPE": syscall.SIGFPE, "HUP": syscall.SIGHUP, "ILL": syscall.SIGILL,
+	"INT": syscall.SIGINT, "KILL": syscall.SIGKILL, "PIPE": syscall.SIGPIPE,
+	"QUIT": syscall.SIGQUIT, "SEGV": syscall.SIGSEGV, "TERM": syscall.SIGTERM,
+	"TRAP": syscall.SIGTRAP,
+}
+
+// raiseSignal delivers the signal in-process, since Windows can't send signals to
+// a process
+func raiseSignal(sig syscall.Signal) {
+	if sig > 0 {
+		dispatchSignal(sig)
+	}
+}
@@ -0,0 +1,361 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/globals"
+	"math"
+	"net/url"
+	"strings"
+)
+
+// Snapshots of the state the class loaders keep, for checkpoint/restore (see
+// checkpoint.go in package jvm): the loaded classes, the statics, the heap, the system
+// properties, and the tables that map Go-side values to objects (the interned strings,
+// the Class objects, and so on).
+//
+// The objects the VM creates as it starts, before main() runs (System.out, the charsets,
+// etc.), are created again, in the same order, by the VM that restores the snapshot, so
+// only their fields are saved. The objects created after them are saved whole. That
+// works for the objects whose Go-side state is a value, such as a String, an array, or
+// a StringBuilder. An object whose Go-side state belongs to the process (an open file or
+// socket, a child process, native memory, and the like) can't be saved, so a checkpoint
+// fails while there's one on the heap.
+
+// Snapshot is the saved state of the class loaders and the heap
+type Snapshot struct {
+	Classes       map[string]Klass
+	Statics       map[string]int64
+	StaticsArray  []Static // without their CPs, which are the classes'
+	BootObjects   int      // the number of objects the VM created as it started
+	Objects       []SavedObject
+	Properties    map[string]string
+	Interned      map[string]int64
+	ClassObjects  map[string]int64
+	LoaderObjects map[string]int64
+	ShutdownHooks []int64
+	Handler       int64 // the default UncaughtExceptionHandler
+	EmptyOptional int64
+	Runtime       int64
+}
+
+// SavedObject is an object in a snapshot. The Native state of the boot objects isn't saved.
+type SavedObject struct {
+	Null   bool // a slot of the heap without an object
+	Mark   int64
+	Klass  string
+	Fields []int64
+	Native SavedNative
+}
+
+// SavedNative is the Go-side state of an object, which is one of the kinds below
+type SavedNative struct {
+	Kind  byte
+	Str   string
+	Bytes []byte
+	Chars []uint16
+	Slots []int64
+	Int   int64
+	Props map[string]string
+}
+
+const (
+	savedNone = iota
+	savedString
+	savedBytes
+	savedChars
+	savedSlots
+	savedStringBuilder
+	savedFile
+	savedClass
+	savedLoader
+	savedOptional
+	savedProperties
+	savedURL
+	savedStdStream
+	savedSignal
+)
+
+// TakeSnapshot returns the state of the class loaders and the heap. The first
+// bootObjects objects on the heap are the ones the VM created as it started.
+func TakeSnapshot(bootObjects int) (*Snapshot, error) {
+	s := &Snapshot{BootObjects: bootObjects, Properties: make(map[string]string)}
+
+	for i, n := 0, heapObjects(); i < n; i++ {
+		obj := objectAt(handle(i + 1))
+		if obj == nil {
+			s.Objects = append(s.Objects, SavedObject{Null: true})
+			continue
+		}
+		saved := SavedObject{Mark: obj.Mark, Klass: obj.Klass, Fields: obj.Fields}
+		if i >= bootObjects {
+			native, ok := saveNative(obj.Native)
+			if !ok {
+				return nil, fmt.Errorf("an object of class %s holds state that can't be saved (%T)",
+					strings.ReplaceAll(obj.Klass, "/", "."), obj.Native)
+			}
+			saved.Native = native
+		}
+		s.Objects = append(s.Objects, saved)
+	}
+
+	MethAreaMutex.RLock()
+	s.Classes = make(map[string]Klass, len(Classes))
+	for name, k := range Classes {
+		if k.Data != nil {
+			s.Classes[name] = k
+		}
+	}
+	MethAreaMutex.RUnlock()
+
+	StaticsMutex.RLock()
+	s.Statics = make(map[string]int64, len(Statics))
+	for name, index := range Statics {
+		s.Statics[name] = index
+	}
+	for _, st := range StaticsArray {
+		if st.Volatile {
+			st.ValueInt = *st.volatileVal
+			if st.Type == "D" || st.Type == "F" {
+				st.ValueFP = math.Float64frombits(uint64(st.ValueInt))
+			}
+		}
+		st.CP = nil
+		s.StaticsArray = append(s.StaticsArray, st)
+	}
+	StaticsMutex.RUnlock()
+
+	for _, key := range globals.SystemProperties.Keys() {
+		s.Properties[key], _ = globals.SystemProperties.Get(key)
+	}
+	internMutex.Lock()
+	s.Interned = copyRefMap(internedStrings)
+	internMutex.Unlock()
+	classObjectsMutex.Lock()
+	s.ClassObjects = copyRefMap(classObjects)
+	classObjectsMutex.Unlock()
+	classLoaderMutex.Lock()
+	s.LoaderObjects = copyRefMap(classLoaderObjects)
+	classLoaderMutex.Unlock()
+	hooksMutex.Lock()
+	s.ShutdownHooks = append([]int64(nil), shutdownHooks...)
+	hooksMutex.Unlock()
+	s.Handler = DefaultUncaughtExceptionHandler()
+	s.EmptyOptional = emptyOptional
+	s.Runtime = runtimeRef
+	return s, nil
+}
+
+// RestoreSnapshot replaces the state of the class loaders and the heap with the
+// snapshot's. It's called after the VM has started, before any Java code has run, when
+// the heap holds just the boot objects.
+func RestoreSnapshot(s *Snapshot) error {
+	heapMutex.Lock()
+	if heapObjects() != s.BootObjects || len(s.Objects) < s.BootObjects {
+		heapMutex.Unlock()
+		return errors.New("the VM that took the snapshot started differently")
+	}
+	for i, saved := range s.Objects {
+		if i < s.BootObjects {
+			if obj := objectAt(handle(i + 1)); obj != nil && !saved.Null {
+				obj.Mark = saved.Mark
+				obj.Fields = saved.Fields
+			}
+			continue
+		}
+		var obj *Object
+		if !saved.Null {
+			obj = &Object{Mark: saved.Mark, Klass: saved.Klass, Fields: saved.Fields,
+				Native: restoreNative(saved.Native)}
+			if obj.Fields == nil {
+				obj.Fields = []int64{}
+			}
+		}
+		addObject(obj)
+	}
+	heapMutex.Unlock()
+
+	MethAreaMutex.Lock()
+	for name, k := range s.Classes {
+		Classes[name] = k
+	}
+	MethAreaMutex.Unlock()
+
+	// the methods in the MTable point to the CPs of the classes that were replaced
+	MTmutex.Lock()
+	for key, entry := range MTable {
+		if entry.MType == 'J' {
+			delete(MTable, key)
+		}
+	}
+	MTmutex.Unlock()
+
+	StaticsMutex.Lock()
+	funcs := make(map[string]func())
+	for name, index := range Statics {
+		if f := StaticsArray[index].ValueFunc; f != nil {
+			funcs[name] = f
+		}
+	}
+	Statics = s.Statics
+	if Statics == nil {
+		Statics = make(map[string]int64)
+	}
+	StaticsArray = s.StaticsArray
+	for name, index := range Statics {
+		st := &StaticsArray[index]
+		st.ValueFunc = funcs[name]
+		if dot := strings.LastIndex(name, "."); dot > 0 {
+			if k, ok := s.Classes[name[:dot]]; ok {
+				st.CP = &k.Data.CP
+			}
+		}
+		if st.Volatile {
+			st.volatileVal = new(int64)
+			*st.volatileVal = st.ValueInt
+			if st.Type == "D" || st.Type == "F" {
+				*st.volatileVal = int64(math.Float64bits(st.ValueFP))
+			}
+		}
+	}
+	StaticsMutex.Unlock()
+
+	for _, key := range globals.SystemProperties.Keys() {
+		if _, present := s.Properties[key]; !present {
+			globals.SystemProperties.Clear(key)
+		}
+	}
+	for key, value := range s.Properties {
+		globals.SystemProperties.Set(key, value)
+	}
+	internMutex.Lock()
+	internedStrings = copyRefMap(s.Interned)
+	internMutex.Unlock()
+	classObjectsMutex.Lock()
+	classObjects = copyRefMap(s.ClassObjects)
+	classObjectsMutex.Unlock()
+	classLoaderMutex.Lock()
+	classLoaderObjects = copyRefMap(s.LoaderObjects)
+	classLoaderMutex.Unlock()
+	hooksMutex.Lock()
+	shutdownHooks = append([]int64(nil), s.ShutdownHooks...)
+	hooksMutex.Unlock()
+	handlerMutex.Lock()
+	defaultHandler = s.Handler
+	handlerMutex.Unlock()
+	if s.EmptyOptional != 0 {
+		emptyOnce.Do(func() { emptyOptional = s.EmptyOptional })
+	}
+	if s.Runtime != 0 {
+		runtimeOnce.Do(func() { runtimeRef = s.Runtime })
+	}
+	return nil
+}
+
+// HeapSize returns the number of handles in use on the heap, which is the reference the
+// next object will have, less one
+func HeapSize() int {
+	return heapObjects()
+}
+
+func copyRefMap(m map[string]int64) map[string]int64 {
+	c := make(map[string]int64, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// saveNative returns the saved form of an object's Go-side state, if it can be saved
+func saveNative(native interface{}) (SavedNative, bool) {
+	switch v := native.(type) {
+	case nil:
+		return SavedNative{Kind: savedNone}, true
+	case string:
+		return SavedNative{Kind: savedString, Str: v}, true
+	case []byte:
+		return SavedNative{Kind: savedBytes, Bytes: v}, true
+	case []uint16:
+		return SavedNative{Kind: savedChars, Chars: v}, true
+	case []int64:
+		return SavedNative{Kind: savedSlots, Slots: v}, true
+	case *stringBuilder:
+		v.mutex.Lock()
+		defer v.mutex.Unlock()
+		return SavedNative{Kind: savedStringBuilder, Chars: append([]uint16(nil), v.chars...)}, true
+	case *javaFile:
+		return SavedNative{Kind: savedFile, Str: v.path}, true
+	case *classMirror:
+		return SavedNative{Kind: savedClass, Str: v.name}, true
+	case *Classloader:
+		return SavedNative{Kind: savedLoader, Str: v.Name}, true
+	case *optional:
+		return SavedNative{Kind: savedOptional, Int: v.value}, true
+	case *globals.Properties:
+		props := make(map[string]string)
+		for _, key := range v.Keys() {
+			props[key], _ = v.Get(key)
+		}
+		return SavedNative{Kind: savedProperties, Props: props}, true
+	case *url.URL:
+		return SavedNative{Kind: savedURL, Str: v.String()}, true
+	case *stdStream:
+		return SavedNative{Kind: savedStdStream, Int: int64(v.fd)}, true
+	case *javaSignal:
+		return SavedNative{Kind: savedSignal, Str: v.name, Int: int64(v.number)}, true
+	}
+	return SavedNative{}, false
+}
+
+// restoreNative returns the Go-side state of an object from its saved form
+func restoreNative(s SavedNative) interface{} {
+	switch s.Kind {
+	case savedString:
+		return s.Str
+	case savedBytes:
+		if s.Bytes == nil {
+			return []byte{}
+		}
+		return s.Bytes
+	case savedChars:
+		if s.Chars == nil {
+			return []uint16{}
+		}
+		return s.Chars
+	case savedSlots:
+		if s.Slots == nil {
+			return []int64{}
+		}
+		return s.Slots
+	case savedStringBuilder:
+		return &stringBuilder{chars: s.Chars}
+	case savedFile:
+		return &javaFile{path: s.Str}
+	case savedClass:
+		return &classMirror{name: s.Str}
+	case savedLoader:
+		return loaderNamed(s.Str)
+	case savedOptional:
+		return &optional{value: s.Int}
+	case savedProperties:
+		props := globals.NewProperties()
+		for key, value := range s.Props {
+			props.Set(key, value)
+		}
+		return props
+	case savedURL:
+		u, _ := url.Parse(s.Str)
+		return u
+	case savedStdStream:
+		return &stdStream{fd: int(s.Int)}
+	case savedSignal:
+		return &javaSignal{name: s.Str, number: int(s.Int)}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSaveNative(t *testing.T) {
+	for _, native := range []interface{}{nil, "text", []byte{1, 2}, []uint16{'a'}, []int64{3, 4},
+		&javaFile{path: "/tmp/x"}, &optional{value: 7}, &classMirror{name: "java/lang/String"}} {
+		saved, ok := saveNative(native)
+		if !ok {
+			t.Errorf("Expected %T to be saved", native)
+			continue
+		}
+		if restored := restoreNative(saved); !reflect.DeepEqual(restored, native) {
+			t.Errorf("Expected %v to be restored, got %v", native, restored)
+		}
+	}
+	saved, _ := saveNative(&stringBuilder{chars: []uint16{'h', 'i'}})
+	if sb, ok := restoreNative(saved).(*stringBuilder); !ok || !reflect.DeepEqual(sb.chars, []uint16{'h', 'i'}) {
+		t.Errorf("Expected the StringBuilder to be restored, got %v", restoreNative(saved))
+	}
+	if _, ok := saveNative(os.Stdin); ok {
+		t.Error("Expected an open file not to be saved")
+	}
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	boot := HeapSize()
+	ref := NewObject("java/io/FileInputStream", 0)
+	GetObject(ref).Native = os.Stdin
+	defer func() { GetObject(ref).Native = nil }()
+
+	_, err := TakeSnapshot(boot)
+	if err == nil || !strings.Contains(err.Error(), "java.io.FileInputStream") {
+		t.Errorf("Expected the open file to stop the snapshot, got %v", err)
+	}
+	s, err := TakeSnapshot(boot + 1) // as if the object were created as the VM started
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(s.Objects) != boot+1 || s.Objects[ref-1].Klass != "java/io/FileInputStream" {
+		t.Errorf("Expected the heap's objects in the snapshot, got %d", len(s.Objects))
+	}
+
+	s.BootObjects++
+	if err := RestoreSnapshot(s); err == nil {
+		t.Error("Expected a snapshot of a VM that started differently not to be restored")
+	}
+}
@@ -0,0 +1,177 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// Access to static fields. Once multiple threads are running, plain reads and writes
+// of the Statics are unsafe: the StaticsArray can be reallocated while another thread
+// is reading it, and fields declared volatile must be read and written atomically
+// (including longs and doubles) with acquire/release ordering.
+//
+// The array itself is protected by StaticsMutex. The value of a volatile field is
+// kept in its own 64-bit word (volatileVal), which is read and written only via
+// sync/atomic. The fields of objects are each in a 64-bit slot of Object.Fields, so
+// LoadField() and StoreField() read and write the slot of a volatile one atomically.
+// Go's atomics are sequentially consistent, which is stronger than the acquire/release
+// ordering that the JMM requires for volatiles. Floats and doubles are stored in the
+// word as their IEEE 754 bit patterns.
+
+// ACC_VOLATILE is the access flag that marks a field as volatile (JVMS 4.5)
+const ACC_VOLATILE = 0x0040
+
+// StaticsMutex protects the StaticsArray and the Statics map
+var StaticsMutex sync.RWMutex
+
+// AddStatic adds a static field to the StaticsArray and the Statics lookup map, and
+// returns its index in the array. If the field is volatile, it gets its own atomically
+// accessed word. If the field was already added (by another thread, say), the index
+// of the existing entry is returned.
+func AddStatic(name string, s Static) int64 {
+	StaticsMutex.Lock()
+	defer StaticsMutex.Unlock()
+
+	if index, present := Statics[name]; present {
+		return index
+	}
+	if s.Volatile {
+		s.volatileVal = new(int64) // allocated 64-bit words are 64-bit aligned
+		*s.volatileVal = s.ValueInt
+		if s.Type == "D" || s.Type == "F" {
+			*s.volatileVal = int64(math.Float64bits(s.ValueFP))
+		}
+	}
+	StaticsArray = append(StaticsArray, s)
+	index := int64(len(StaticsArray) - 1)
+	Statics[name] = index
+	return index
+}
+
+// FindStatic returns the index of the named static in the StaticsArray, if present
+func FindStatic(name string) (int64, bool) {
+	StaticsMutex.RLock()
+	index, present := Statics[name]
+	StaticsMutex.RUnlock()
+	return index, present
+}
+
+// LoadStaticInt returns the value of an integral static field (int, long, char, etc.)
+func LoadStaticInt(index int64) int64 {
+	StaticsMutex.RLock()
+	defer StaticsMutex.RUnlock()
+	s := &StaticsArray[index]
+	if s.Volatile {
+		return atomic.LoadInt64(s.volatileVal)
+	}
+	return s.ValueInt
+}
+
+// StoreStaticInt sets the value of an integral static field (int, long, char, etc.)
+func StoreStaticInt(index int64, value int64) {
+	StaticsMutex.RLock() // only the slice header is read; the write is to the element
+	defer StaticsMutex.RUnlock()
+	s := &StaticsArray[index]
+	if s.Volatile {
+		atomic.StoreInt64(s.volatileVal, value)
+		return
+	}
+	s.ValueInt = value
+}
+
+// LoadStaticFP returns the value of a float or double static field
+func LoadStaticFP(index int64) float64 {
+	StaticsMutex.RLock()
+	defer StaticsMutex.RUnlock()
+	s := &StaticsArray[index]
+	if s.Volatile {
+		return math.Float64frombits(uint64(atomic.LoadInt64(s.volatileVal)))
+	}
+	return s.ValueFP
+}
+
+// StoreStaticFP sets the value of a float or double static field
+func StoreStaticFP(index int64, value float64) {
+	StaticsMutex.RLock()
+	defer StaticsMutex.RUnlock()
+	s := &StaticsArray[index]
+	if s.Volatile {
+		atomic.StoreInt64(s.volatileVal, int64(math.Float64bits(value)))
+		return
+	}
+	s.ValueFP = value
+}
+
+// LoadField returns the value in the slot of an object's field (see FieldSlot()),
+// which is read atomically if the field is volatile
+func LoadField(slot *int64, volatile bool) int64 {
+	if volatile {
+		return atomic.LoadInt64(slot)
+	}
+	return *slot
+}
+
+// StoreField sets the value in the slot of an object's field, which is written
+// atomically if the field is volatile
+func StoreField(slot *int64, value int64, volatile bool) {
+	if volatile {
+		atomic.StoreInt64(slot, value)
+		return
+	}
+	*slot = value
+}
+
+// FieldIsVolatile looks up the named field in the named class, or in the class it
+// inherits the field from (see FieldDeclarer()), and reports whether it was declared
+// volatile. If the class is not loaded or has no such field, it's false.
+func FieldIsVolatile(className, fieldName string) bool {
+	declarer, ok := FieldDeclarer(className, fieldName)
+	if !ok {
+		return false
+	}
+	MethAreaMutex.RLock()
+	k := Classes[declarer]
+	MethAreaMutex.RUnlock()
+
+	for _, f := range k.Data.Fields {
+		if int(f.Name) < len(k.Data.CP.Utf8Refs) && k.Data.CP.Utf8Refs[f.Name] == fieldName {
+			return f.AccessFlags&ACC_VOLATILE != 0
+		}
+	}
+	return false
+}
+
+// FieldDeclarer returns the class that declares the field a reference to the named
+// field of the named class resolves to: the class itself, or else one of its
+// superinterfaces, or else one of its superclasses, searched in the order of field
+// resolution (JVMS 5.4.3.2). Only the classes that have been loaded are searched. If
+// none of them declares the field, the last return value is false.
+func FieldDeclarer(className, fieldName string) (string, bool) {
+	for c := className; c != ""; {
+		MethAreaMutex.RLock()
+		k, present := Classes[c]
+		MethAreaMutex.RUnlock()
+		if !present || k.Data == nil {
+			return "", false
+		}
+		for _, f := range k.Data.Fields {
+			if int(f.Name) < len(k.Data.CP.Utf8Refs) && k.Data.CP.Utf8Refs[f.Name] == fieldName {
+				return c, true
+			}
+		}
+		for _, i := range k.Data.Interfaces {
+			if declarer, ok := FieldDeclarer(k.Data.CP.Utf8Refs[i], fieldName); ok {
+				return declarer, true
+			}
+		}
+		c = k.Data.Superclass
+	}
+	return "", false
+}
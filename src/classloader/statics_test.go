@@ -0,0 +1,77 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAddStaticReturnsExistingIndex(t *testing.T) {
+	i1 := AddStatic("test/Statics.a", Static{Type: "I"})
+	i2 := AddStatic("test/Statics.a", Static{Type: "I"})
+	if i1 != i2 {
+		t.Errorf("Expected adding the same static twice to return index %d, got: %d", i1, i2)
+	}
+	if idx, ok := FindStatic("test/Statics.a"); !ok || idx != i1 {
+		t.Errorf("Expected FindStatic() to return index %d, got: %d (found: %t)", i1, idx, ok)
+	}
+}
+
+func TestVolatileStaticLoadStore(t *testing.T) {
+	li := AddStatic("test/Statics.vlong", Static{Type: "J", Volatile: true, ValueInt: 5})
+	if LoadStaticInt(li) != 5 {
+		t.Errorf("Expected initial volatile long value of 5, got: %d", LoadStaticInt(li))
+	}
+	StoreStaticInt(li, 0x7FFFFFFFFFFFFFFF)
+	if LoadStaticInt(li) != 0x7FFFFFFFFFFFFFFF {
+		t.Errorf("Expected volatile long of 0x7FFFFFFFFFFFFFFF, got: 0x%x", LoadStaticInt(li))
+	}
+
+	di := AddStatic("test/Statics.vdouble", Static{Type: "D", Volatile: true})
+	StoreStaticFP(di, 3.25)
+	if LoadStaticFP(di) != 3.25 {
+		t.Errorf("Expected volatile double of 3.25, got: %f", LoadStaticFP(di))
+	}
+
+	pi := AddStatic("test/Statics.plain", Static{Type: "I"})
+	StoreStaticInt(pi, 12)
+	if LoadStaticInt(pi) != 12 {
+		t.Errorf("Expected non-volatile int of 12, got: %d", LoadStaticInt(pi))
+	}
+}
+
+func TestVolatileFieldLoadStore(t *testing.T) {
+	fields := make([]int64, 2)
+	StoreField(&fields[0], 0x7FFFFFFFFFFFFFFF, true)
+	StoreField(&fields[1], 12, false)
+	if LoadField(&fields[0], true) != 0x7FFFFFFFFFFFFFFF || LoadField(&fields[1], false) != 12 {
+		t.Errorf("Expected the fields to hold 0x7FFFFFFFFFFFFFFF and 12, got: %v", fields)
+	}
+}
+
+// concurrent stores to a volatile long must never produce a torn value
+func TestVolatileStaticConcurrentAccess(t *testing.T) {
+	idx := AddStatic("test/Statics.concurrent", Static{Type: "J", Volatile: true})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(v int64) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				StoreStaticInt(idx, v)
+				AddStatic("test/Statics.grow"+string(rune('a'+v)), Static{})
+			}
+		}(int64(i) * 0x0101010101010101)
+	}
+	wg.Wait()
+
+	val := LoadStaticInt(idx)
+	if val%0x0101010101010101 != 0 {
+		t.Errorf("Torn read of volatile long: 0x%x", val)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "sync"
+
+// Symbols. A method or field is identified by its name and descriptor: a method reference
+// resolves to the method with the name and descriptor it gives, and a method overrides
+// the method of its superclass that has the same ones. So each (name, descriptor) pair
+// is given a Symbol, which is the same VM-wide, and the methods are looked up by
+// comparing Symbols rather than pairs of strings. The strings of the CPs of the
+// loaded classes are interned as well, so that the names and descriptors many classes
+// share, such as <init> and ()V, are held once.
+//
+// A Symbol points to its name and descriptor, which never change once it's created, so
+// they're read without a lock. Symbols differ from run to run, so they're never saved,
+// as in a checkpoint: the tables that map them to a class's methods and to its CP
+// entries are built when they're first used.
+
+// Symbol is the VM-wide identity of a (name, descriptor) pair. The zero Symbol is no
+// symbol.
+type Symbol struct {
+	key *symbolKey
+}
+
+type symbolKey struct {
+	name string
+	desc string
+}
+
+var (
+	symbolMutex   sync.RWMutex
+	symbolIDs     = make(map[symbolKey]Symbol)
+	symbolStrings = make(map[string]string)
+)
+
+// SymbolFor returns the symbol of the name and descriptor, which it creates if there's
+// none yet
+func SymbolFor(name, desc string) Symbol {
+	key := symbolKey{name, desc}
+	symbolMutex.RLock()
+	sym, present := symbolIDs[key]
+	symbolMutex.RUnlock()
+	if present {
+		return sym
+	}
+
+	symbolMutex.Lock()
+	defer symbolMutex.Unlock()
+	if sym, present = symbolIDs[key]; present { // another thread created it
+		return sym
+	}
+	key = symbolKey{internLocked(name), internLocked(desc)}
+	sym = Symbol{&key}
+	symbolIDs[key] = sym
+	return sym
+}
+
+// Name returns the name of the symbol's method or field
+func (s Symbol) Name() string {
+	if s.key == nil {
+		return ""
+	}
+	return s.key.name
+}
+
+// Desc returns the descriptor of the symbol's method or field
+func (s Symbol) Desc() string {
+	if s.key == nil {
+		return ""
+	}
+	return s.key.desc
+}
+
+// String returns the name and descriptor, as in main([Ljava/lang/String;)V
+func (s Symbol) String() string {
+	return s.Name() + s.Desc()
+}
+
+// internString returns the VM-wide copy of the string
+func internString(s string) string {
+	symbolMutex.RLock()
+	interned, present := symbolStrings[s]
+	symbolMutex.RUnlock()
+	if present {
+		return interned
+	}
+	symbolMutex.Lock()
+	defer symbolMutex.Unlock()
+	return internLocked(s)
+}
+
+// internLocked interns the string. The symbolMutex must be held.
+func internLocked(s string) string {
+	if interned, present := symbolStrings[s]; present {
+		return interned
+	}
+	symbolStrings[s] = s
+	return s
+}
+
+// the tables of Symbols of the classes' methods and CP entries are built under this mutex
+var symbolTablesMutex sync.RWMutex
+
+// MethodFor returns the method the class declares with the symbol's name and descriptor,
+// or nil if it declares none
+func (d *ClData) MethodFor(sym Symbol) *Method {
+	symbolTablesMutex.RLock()
+	methods := d.methodSymbols
+	symbolTablesMutex.RUnlock()
+	if methods == nil {
+		methods = make(map[Symbol]int, len(d.Methods))
+		for i := len(d.Methods) - 1; i >= 0; i-- { // so the first one wins
+			m := &d.Methods[i]
+			methods[SymbolFor(d.CP.Utf8Refs[m.Name], d.CP.Utf8Refs[m.Desc])] = i
+		}
+		symbolTablesMutex.Lock()
+		d.methodSymbols = methods
+		symbolTablesMutex.Unlock()
+	}
+	if i, present := methods[sym]; present {
+		return &d.Methods[i]
+	}
+	return nil
+}
+
+// NameAndTypeSymbol returns the symbol of the name and descriptor of the NameAndType
+// entry in the slot of NameAndTypes
+func (cp *CPool) NameAndTypeSymbol(slot int) Symbol {
+	symbolTablesMutex.RLock()
+	symbols := cp.nameAndTypeSymbols
+	symbolTablesMutex.RUnlock()
+	if symbols == nil {
+		symbols = make([]Symbol, len(cp.NameAndTypes))
+		for i, nat := range cp.NameAndTypes {
+			symbols[i] = SymbolFor(FetchUTF8stringFromCPEntryNumber(cp, nat.NameIndex),
+				FetchUTF8stringFromCPEntryNumber(cp, nat.DescIndex))
+		}
+		symbolTablesMutex.Lock()
+		cp.nameAndTypeSymbols = symbols
+		symbolTablesMutex.Unlock()
+	}
+	return symbols[slot]
+}
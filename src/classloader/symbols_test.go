@@ -0,0 +1,52 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "testing"
+
+func TestSymbolFor(t *testing.T) {
+	toString := SymbolFor("toString", "()Ljava/lang/String;")
+	if toString == (Symbol{}) || SymbolFor("toString", "()Ljava/lang/String;") != toString {
+		t.Fatal("Expected the same name and descriptor to have the same symbol")
+	}
+	if SymbolFor("toString", "(I)Ljava/lang/String;") == toString || SymbolFor("hashCode", "()I") == toString {
+		t.Error("Expected another name or descriptor to have another symbol")
+	}
+	if toString.Name() != "toString" || toString.Desc() != "()Ljava/lang/String;" ||
+		toString.String() != "toString()Ljava/lang/String;" {
+		t.Errorf("Expected the symbol's name and descriptor, got %s", toString)
+	}
+	if (Symbol{}).String() != "" {
+		t.Error("Expected no symbol to have no name or descriptor")
+	}
+}
+
+func TestMethodFor(t *testing.T) {
+	addTestClass("test/Shape", "", nil, nil, []testMember{
+		{0x0001, "area", "()D"}, {0x0001, "scale", "(D)V"}, {0x0001, "area", "()D"}})
+	data := classData("test/Shape")
+	if m := data.MethodFor(SymbolFor("scale", "(D)V")); m != &data.Methods[1] {
+		t.Errorf("Expected scale(D)V to be the second method, got %v", m)
+	}
+	if m := data.MethodFor(SymbolFor("area", "()D")); m != &data.Methods[0] {
+		t.Errorf("Expected the first of the duplicate methods, got %v", m)
+	}
+	if data.MethodFor(SymbolFor("scale", "(F)V")) != nil {
+		t.Error("Expected no method for a descriptor the class doesn't declare")
+	}
+}
+
+func TestNameAndTypeSymbol(t *testing.T) {
+	cp := CPool{
+		CpIndex:      []CpEntry{{}, {UTF8, 0}, {UTF8, 1}, {NameAndType, 0}},
+		NameAndTypes: []NameAndTypeEntry{{NameIndex: 1, DescIndex: 2}},
+		Utf8Refs:     []string{"<init>", "()V"},
+	}
+	if sym := cp.NameAndTypeSymbol(0); sym != SymbolFor("<init>", "()V") {
+		t.Errorf("Expected the symbol of <init>()V, got %s", sym)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import "os"
+
+// isTerminal reports whether the standard stream (0, 1, or 2) is a character device,
+// which is the best that can be done without platform-specific calls
+func isTerminal(fd int) bool {
+	if fd < 0 || fd > 2 {
+		return false
+	}
+	info, err := []*os.File{os.Stdin, os.Stdout, os.Stderr}[fd].Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// withEchoOff runs fn. Turning off echoing isn't supported on this platform yet.
+// TODO: use SetConsoleMode() on Windows
+func withEchoOff(fd int, fn func()) {
+	fn()
+}
@@ -0,0 +1,60 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Terminal handling for java.io.Console, using the termios ioctls directly
+
+func getTermios(fd int) (syscall.Termios, error) {
+	var t syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), ioctlGetTermios, uintptr(unsafe.Pointer(&t)))
+	if errno != 0 {
+		return t, errno
+	}
+	return t, nil
+}
+
+func setTermios(fd int, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), ioctlSetTermios, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// isTerminal reports whether the file descriptor refers to a terminal
+func isTerminal(fd int) bool {
+	_, err := getTermios(fd)
+	return err == nil
+}
+
+// withEchoOff runs fn with echoing turned off on the terminal, as for reading a
+// password, and then restores the terminal's settings. If fd isn't a terminal, fn
+// is simply run.
+func withEchoOff(fd int, fn func()) {
+	saved, err := getTermios(fd)
+	if err != nil {
+		fn()
+		return
+	}
+	noEcho := saved
+	noEcho.Lflag &^= syscall.ECHO
+	noEcho.Lflag |= syscall.ICANON | syscall.ISIG
+	if err := setTermios(fd, &noEcho); err != nil {
+		fn()
+		return
+	}
+	defer setTermios(fd, &saved)
+	fn()
+}
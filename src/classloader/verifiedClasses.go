@@ -0,0 +1,106 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"jacobin/log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Verified classes. The format check of a class (see formatCheck.go) depends only on
+// the bytes of its class file, so once a class file has passed it, it needn't be checked
+// again when the same bytes are loaded again, as when another loader loads the class.
+// The classes that pass are remembered by the SHA-256 hash of their bytes. With
+// -XX:VerifiedClassesFile=<file>, the hashes are read from the file as the VM starts
+// and written to it at exit, so that later runs, like those with the JDK's class data
+// sharing archive, skip the check of the classes that haven't changed. The file is
+// ignored if another version of the VM wrote it. Classes that fail the check aren't
+// remembered, so their errors are shown every time they're loaded.
+
+type classHash [sha256.Size]byte
+
+var (
+	verifiedClasses = make(map[classHash]bool)
+	verifiedMutex   sync.RWMutex
+)
+
+// verifiedClassesHeader is the first line of the file, followed by the VM's version
+const verifiedClassesHeader = "jacobin verified classes "
+
+// formatCheckOnce format checks the class, whose class file has the bytes, unless
+// the same bytes have passed the check before
+func formatCheckOnce(klass *ParsedClass, rawBytes []byte) error {
+	hash := classHash(sha256.Sum256(rawBytes))
+	verifiedMutex.RLock()
+	verified := verifiedClasses[hash]
+	verifiedMutex.RUnlock()
+	if verified {
+		log.Log("Class "+klass.className+" was format-checked before", log.FINEST)
+		return nil
+	}
+
+	if err := formatCheckClass(klass); err != nil {
+		return err
+	}
+	verifiedMutex.Lock()
+	verifiedClasses[hash] = true
+	verifiedMutex.Unlock()
+	return nil
+}
+
+// LoadVerifiedClasses reads the hashes of the classes that passed the format check
+// from the file, if it exists and this version of the VM wrote it
+func LoadVerifiedClasses(path, version string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil // the first run creates it
+	}
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	if lines[0] != verifiedClassesHeader+version {
+		return nil // the format check may have changed since
+	}
+
+	verifiedMutex.Lock()
+	defer verifiedMutex.Unlock()
+	for _, line := range lines[1:] {
+		var hash classHash
+		if len(line) != 2*len(hash) {
+			continue
+		}
+		if _, err = hex.Decode(hash[:], []byte(line)); err == nil {
+			verifiedClasses[hash] = true
+		}
+	}
+	return nil
+}
+
+// SaveVerifiedClasses writes the hashes of the classes that passed the format check
+// to the file. It's written to a temporary file first, so that a VM that reads it as
+// it's written doesn't see part of it.
+func SaveVerifiedClasses(path, version string) error {
+	verifiedMutex.RLock()
+	hashes := make([]string, 0, len(verifiedClasses))
+	for hash := range verifiedClasses {
+		hashes = append(hashes, hex.EncodeToString(hash[:]))
+	}
+	verifiedMutex.RUnlock()
+	sort.Strings(hashes)
+
+	content := verifiedClassesHeader + version + "\n" + strings.Join(hashes, "\n") + "\n"
+	if err := os.WriteFile(path+".tmp", []byte(content), 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,68 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// a class whose bytes passed the format check isn't checked again, so a parsed class
+// that would fail the check passes it if it comes from the same bytes
+func TestFormatCheckOnce(t *testing.T) {
+	verifiedClasses = make(map[classHash]bool)
+	klass := loadKotlinOrScalaClass(t, "Greeter", kotlinGreeterClass)
+	if err := formatCheckOnce(&klass, kotlinGreeterClass); err != nil || len(verifiedClasses) != 1 {
+		t.Fatalf("Expected the class to pass the check and be remembered, got %v", err)
+	}
+
+	klass.cpIndex[0] = cpEntry{UTF8, 0} // the first entry must be a dummy
+	if err := formatCheckOnce(&klass, kotlinGreeterClass); err != nil {
+		t.Errorf("Expected the check of the same bytes to be skipped, got %v", err)
+	}
+
+	other := append([]byte{}, kotlinGreeterClass...)
+	other[len(other)-1] ^= 1
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	err := formatCheckOnce(&klass, other)
+	_ = w.Close()
+	os.Stderr = normalStderr
+	if err == nil || len(verifiedClasses) != 1 {
+		t.Errorf("Expected other bytes to be checked, and not be remembered when they fail")
+	}
+}
+
+func TestSaveVerifiedClasses(t *testing.T) {
+	verifiedClasses = make(map[classHash]bool)
+	verifiedClasses[classHash{1, 2, 3}] = true
+	verifiedClasses[classHash{4, 5, 6}] = true
+	path := filepath.Join(t.TempDir(), "verified")
+	if err := SaveVerifiedClasses(path, "0.1.0"); err != nil {
+		t.Fatalf("Unexpected error writing the file: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "jacobin verified classes 0.1.0\n0102030000") {
+		t.Errorf("Expected the version and the sorted hashes, got:\n%s", data)
+	}
+
+	verifiedClasses = make(map[classHash]bool)
+	if err := LoadVerifiedClasses(path, "0.2.0"); err != nil || len(verifiedClasses) != 0 {
+		t.Errorf("Expected the file of another version to be ignored, got %d classes, %v",
+			len(verifiedClasses), err)
+	}
+	if err := LoadVerifiedClasses(path, "0.1.0"); err != nil || !verifiedClasses[classHash{4, 5, 6}] ||
+		len(verifiedClasses) != 2 {
+		t.Errorf("Expected the classes in the file to be read, got %d classes, %v", len(verifiedClasses), err)
+	}
+	if err := LoadVerifiedClasses(path+".missing", "0.1.0"); err != nil {
+		t.Errorf("Expected a missing file to be read as empty, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+//go:build cgo && (linux || darwin || freebsd)
+// +build cgo
+// +build linux darwin freebsd
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+// Package foreign implements the native side of the Foreign Function & Memory API
+// (java.lang.foreign): memory segments in malloc()ed memory, symbol lookup with
+// dlopen()/dlsym(), and downcalls to C functions.
+//
+// A downcall passes its arguments through a single function pointer type that takes
+// six integer arguments followed by eight doubles. On the supported ABIs (System V
+// x86-64 and AArch64), integer and floating-point arguments are assigned to their own
+// register sequences, so a C function with up to six integer or pointer parameters
+// and up to eight float or double parameters, in any order, finds each argument in
+// the register it expects. (Floats go in the low-order half of their register, which
+// is why they're passed as doubles holding the float's bits.) Functions with more
+// arguments than that, variadic functions, and structs passed by value aren't
+// supported yet.
+package foreign
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+static void *toPointer(int64_t address) { return (void *)(intptr_t) address; }
+
+static int64_t allocate(int64_t size) { return (int64_t)(intptr_t) calloc(1, size); }
+static void release(int64_t address) { free(toPointer(address)); }
+
+static int64_t openLibrary(const char *name, char **err) {
+    void *handle = dlopen(name, RTLD_NOW | RTLD_GLOBAL);
+    if (handle == NULL) {
+        *err = dlerror();
+    }
+    return (int64_t)(intptr_t) handle;
+}
+
+static int64_t lookup(int64_t handle, const char *name) {
+    return (int64_t)(intptr_t) dlsym(toPointer(handle), name);
+}
+
+#define ARGS i[0], i[1], i[2], i[3], i[4], i[5], d[0], d[1], d[2], d[3], d[4], d[5], d[6], d[7]
+#define PARAMS int64_t, int64_t, int64_t, int64_t, int64_t, int64_t, \
+    double, double, double, double, double, double, double, double
+
+static int64_t callLong(int64_t fn, int64_t *i, double *d) {
+    return ((int64_t (*)(PARAMS)) toPointer(fn))(ARGS);
+}
+static double callDouble(int64_t fn, int64_t *i, double *d) {
+    return ((double (*)(PARAMS)) toPointer(fn))(ARGS);
+}
+static float callFloat(int64_t fn, int64_t *i, double *d) {
+    return ((float (*)(PARAMS)) toPointer(fn))(ARGS);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"jacobin/classloader"
+	"jacobin/jni"
+	"jacobin/log"
+	"math"
+	"sync"
+	"unsafe"
+)
+
+const (
+	maxIntArgs   = 6
+	maxFloatArgs = 8
+	maxSegment   = 1 << 30 // the largest segment that can be viewed as a Go slice
+)
+
+// cLinker is the classloader.NativeLinker that uses the C library
+type cLinker struct{}
+
+// Install makes the FFM API use native memory and allow downcalls
+func Install() {
+	classloader.ForeignLinker = cLinker{}
+}
+
+func (cLinker) Allocate(size int64) ([]byte, int64) {
+	if size == 0 {
+		size = 1 // malloc(0) may return NULL
+	}
+	address := int64(C.allocate(C.int64_t(size)))
+	if address == 0 {
+		return nil, 0 // the arena throws an OutOfMemoryError
+	}
+	data, _ := cLinker{}.Segment(address, size)
+	return data, address
+}
+
+func (cLinker) Free(address int64) {
+	C.release(C.int64_t(address))
+}
+
+func (cLinker) Segment(address, size int64) ([]byte, bool) {
+	if size == 0 {
+		return []byte{}, true
+	}
+	if address == 0 || size < 0 || size > maxSegment {
+		return nil, false
+	}
+	return (*[maxSegment]byte)(C.toPointer(C.int64_t(address)))[:size:size], true
+}
+
+var processHandle int64
+var processOnce sync.Once
+
+func (cLinker) OpenLibrary(name string) (int64, error) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	var cerr *C.char
+	handle := int64(C.openLibrary(cname, &cerr))
+	if handle == 0 {
+		return 0, errors.New(C.GoString(cerr))
+	}
+	return handle, nil
+}
+
+func (cLinker) Lookup(library int64, name string) int64 {
+	switch library {
+	case -1: // loaderLookup
+		return int64(jni.FindSymbol(name))
+	case 0: // defaultLookup: the C library and everything else already in the process
+		processOnce.Do(func() {
+			var cerr *C.char
+			processHandle = int64(C.openLibrary(nil, &cerr))
+		})
+		library = processHandle
+	}
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	return int64(C.lookup(C.int64_t(library), cname))
+}
+
+func (cLinker) Call(fn int64, argTypes []byte, retType byte, args []int64) int64 {
+	var ints [maxIntArgs]C.int64_t
+	var floats [maxFloatArgs]C.double
+	nInts, nFloats := 0, 0
+	for i, t := range argTypes {
+		switch t {
+		case 'F', 'D':
+			if nFloats == maxFloatArgs {
+				_ = log.Log("Downcalls with more than 8 float or double arguments are not yet supported", log.SEVERE)
+				return 0
+			}
+			f := classloader.FloatFromSlot(args[i])
+			if t == 'F' {
+				f = math.Float64frombits(uint64(math.Float32bits(float32(f))))
+			}
+			floats[nFloats] = C.double(f)
+			nFloats++
+		default:
+			if nInts == maxIntArgs {
+				_ = log.Log("Downcalls with more than 6 integer or pointer arguments are not yet supported", log.SEVERE)
+				return 0
+			}
+			ints[nInts] = C.int64_t(args[i])
+			nInts++
+		}
+	}
+
+	switch retType {
+	case 'D':
+		return classloader.SlotFromFloat(float64(C.callDouble(C.int64_t(fn), &ints[0], &floats[0])))
+	case 'F':
+		return classloader.SlotFromFloat(float64(C.callFloat(C.int64_t(fn), &ints[0], &floats[0])))
+	default:
+		return int64(C.callLong(C.int64_t(fn), &ints[0], &floats[0]))
+	}
+}
@@ -0,0 +1,101 @@
+//go:build cgo && (linux || darwin || freebsd)
+// +build cgo
+// +build linux darwin freebsd
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package foreign
+
+import (
+	"jacobin/classloader"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestAllocateAndView(t *testing.T) {
+	l := cLinker{}
+	data, address := l.Allocate(32)
+	if address == 0 || len(data) != 32 {
+		t.Fatalf("Allocation failed: address 0x%x, %d bytes", address, len(data))
+	}
+	data[5] = 42
+	view, ok := l.Segment(address+5, 1)
+	if !ok || view[0] != 42 {
+		t.Error("Expected a view of the same memory to see the write")
+	}
+	l.Free(address)
+}
+
+func TestDefaultLookupAndCall(t *testing.T) {
+	l := cLinker{}
+	strlen := l.Lookup(0, "strlen")
+	if strlen == 0 {
+		t.Fatal("Expected to find strlen in the default lookup")
+	}
+	data, address := l.Allocate(8)
+	copy(data, "jacobin\x00")
+	if n := l.Call(strlen, []byte{'A'}, 'J', []int64{address}); n != 7 {
+		t.Errorf("strlen: expected 7, got %d", n)
+	}
+	l.Free(address)
+
+	if l.Lookup(0, "no_such_symbol_anywhere") != 0 {
+		t.Error("Expected an unknown symbol not to be found")
+	}
+}
+
+// mixed has integer and floating-point parameters interleaved, to check that each
+// argument lands in the register the C function expects
+const testLibrary = `
+double mixed(int a, double b, long c, float d, char e, double f) {
+    return a + b + c + d + e + f;
+}
+float halve(float x) { return x / 2; }
+`
+
+func TestMixedArguments(t *testing.T) {
+	cc := os.Getenv("CC")
+	if cc == "" {
+		cc = "cc"
+	}
+	if _, err := exec.LookPath(cc); err != nil {
+		t.Skip("no C compiler available to build the test library")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "mixed.c")
+	lib := filepath.Join(dir, "libmixed.so")
+	if err := os.WriteFile(src, []byte(testLibrary), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(cc, "-shared", "-fPIC", "-o", lib, src).CombinedOutput(); err != nil {
+		t.Fatalf("Building test library failed: %v\n%s", err, out)
+	}
+
+	l := cLinker{}
+	handle, err := l.OpenLibrary(lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mixed := l.Lookup(handle, "mixed")
+	ret := l.Call(mixed, []byte("IDJFBD"), 'D', []int64{1, classloader.SlotFromFloat(0.5), -10,
+		classloader.SlotFromFloat(0.25), 3, classloader.SlotFromFloat(100)})
+	if got := classloader.FloatFromSlot(ret); got != 94.75 {
+		t.Errorf("mixed: expected 94.75, got %v", got)
+	}
+
+	halve := l.Lookup(handle, "halve")
+	ret = l.Call(halve, []byte("F"), 'F', []int64{classloader.SlotFromFloat(3)})
+	if got := classloader.FloatFromSlot(ret); got != 1.5 {
+		t.Errorf("halve: expected 1.5, got %v", got)
+	}
+
+	if _, err := l.OpenLibrary(filepath.Join(dir, "missing.so")); err == nil {
+		t.Error("Expected an error opening a missing library")
+	}
+}
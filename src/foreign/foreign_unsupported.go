@@ -0,0 +1,16 @@
+//go:build !cgo || !(linux || darwin || freebsd)
+// +build !cgo !linux,!darwin,!freebsd
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+// Package foreign implements the native side of the Foreign Function & Memory API.
+// Calling native code requires cgo, so in this build the class loader's default
+// linker stays in place: memory segments work, but downcalls and symbol lookups don't.
+package foreign
+
+// Install is a no-op in builds without cgo
+func Install() {}
@@ -0,0 +1,236 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package globals
+
+import (
+	"errors"
+	"jacobin/messages"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The -XX flags are the VM's tuning knobs. As in HotSpot, each flag has a name, a
+// type, and a default value, and is set on the command line in one of these forms:
+//     -XX:+Name or -XX:-Name   for boolean flags
+//     -XX:Name=value           for numeric flags, where the value can have a size
+//                              suffix of k, m, g, or t (in either case), as in 64m
+//     -XX:Name=value           for string flags
+// Every flag is registered in InitFlags(), so that -XX:+PrintFlagsFinal can list all
+// of them with their effective values.
+
+// FlagType is the type of a flag's value
+type FlagType int
+
+const (
+	BoolFlag FlagType = iota
+	IntFlag
+	StringFlag
+)
+
+var flagTypeNames = map[FlagType]string{BoolFlag: "bool", IntFlag: "int", StringFlag: "ccstr"}
+
+func (ft FlagType) String() string { return flagTypeNames[ft] }
+
+// Flag is a single -XX flag. Only the field for the flag's type is used.
+type Flag struct {
+	Name        string
+	Type        FlagType
+	Bool        bool
+	Int         int64
+	Str         string
+	Origin      string // where the value came from: "default" or "command line"
+	Description string
+}
+
+// Value returns the flag's value as it's shown by -XX:+PrintFlagsFinal
+func (f Flag) Value() string {
+	switch f.Type {
+	case BoolFlag:
+		return strconv.FormatBool(f.Bool)
+	case IntFlag:
+		return strconv.FormatInt(f.Int, 10)
+	default:
+		return f.Str
+	}
+}
+
+// Flags is a thread-safe registry of flags
+type Flags struct {
+	mutex sync.RWMutex
+	flags map[string]*Flag
+}
+
+// NewFlags returns an empty flag registry
+func NewFlags() *Flags {
+	return &Flags{flags: make(map[string]*Flag)}
+}
+
+// AddBool registers a boolean flag with its default value
+func (f *Flags) AddBool(name string, value bool, description string) {
+	f.add(&Flag{Name: name, Type: BoolFlag, Bool: value, Description: description})
+}
+
+// AddInt registers a numeric flag with its default value
+func (f *Flags) AddInt(name string, value int64, description string) {
+	f.add(&Flag{Name: name, Type: IntFlag, Int: value, Description: description})
+}
+
+// AddString registers a string flag with its default value
+func (f *Flags) AddString(name string, value string, description string) {
+	f.add(&Flag{Name: name, Type: StringFlag, Str: value, Description: description})
+}
+
+func (f *Flags) add(flag *Flag) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	flag.Origin = "default"
+	f.flags[flag.Name] = flag
+}
+
+// Lookup returns a copy of the named flag and whether it's registered
+func (f *Flags) Lookup(name string) (Flag, bool) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	flag, present := f.flags[name]
+	if !present {
+		return Flag{}, false
+	}
+	return *flag, true
+}
+
+// Bool returns the value of a boolean flag (false if there's no such flag)
+func (f *Flags) Bool(name string) bool {
+	flag, _ := f.Lookup(name)
+	return flag.Bool
+}
+
+// Int returns the value of a numeric flag (0 if there's no such flag)
+func (f *Flags) Int(name string) int64 {
+	flag, _ := f.Lookup(name)
+	return flag.Int
+}
+
+// String returns the value of a string flag ("" if there's no such flag)
+func (f *Flags) String(name string) string {
+	flag, _ := f.Lookup(name)
+	return flag.Str
+}
+
+// Names returns the names of all the flags, sorted
+func (f *Flags) Names() []string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	names := make([]string, 0, len(f.flags))
+	for name := range f.flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set parses a setting as it follows -XX: on the command line (such as +Name or
+// Name=value) and sets the flag. The errors are worded as HotSpot's are.
+func (f *Flags) Set(setting string) error {
+	name, value, hasValue := setting, "", false
+	if eq := strings.Index(setting, "="); eq >= 0 {
+		name, value, hasValue = setting[:eq], setting[eq+1:], true
+	}
+	sign := byte(0)
+	if strings.HasPrefix(name, "+") || strings.HasPrefix(name, "-") {
+		sign, name = name[0], name[1:]
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	flag, present := f.flags[name]
+	switch {
+	case !present:
+		return messages.New("JACOBIN-LA-0015", setting)
+	case flag.Type == BoolFlag && (sign == 0 || hasValue):
+		return messages.New("JACOBIN-LA-0016", name)
+	case flag.Type != BoolFlag && sign != 0:
+		return messages.New("JACOBIN-LA-0017", name)
+	case flag.Type != BoolFlag && !hasValue:
+		return messages.New("JACOBIN-LA-0018", setting)
+	}
+
+	switch flag.Type {
+	case BoolFlag:
+		flag.Bool = sign == '+'
+	case IntFlag:
+		n, err := ParseSize(value)
+		if err != nil {
+			return messages.New("JACOBIN-LA-0018", setting)
+		}
+		flag.Int = n
+	case StringFlag:
+		flag.Str = value
+	}
+	flag.Origin = "command line"
+	return nil
+}
+
+// ParseSize parses a number, such as a numeric flag value, that can have a size suffix:
+// k or K multiplies it by 1024, m or M by 1024*1024, and so on for g/G and t/T
+func ParseSize(value string) (int64, error) {
+	multiplier := int64(1)
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'k', 'K':
+			multiplier = 1 << 10
+		case 'm', 'M':
+			multiplier = 1 << 20
+		case 'g', 'G':
+			multiplier = 1 << 30
+		case 't', 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier != 1 {
+			value = value[:len(value)-1]
+		}
+	}
+	n, err := strconv.ParseInt(value, 0, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 && n > (1<<63-1)/multiplier || n < 0 && n < -(1<<63-1)/multiplier {
+		return 0, errors.New("value out of range: " + value)
+	}
+	return n * multiplier, nil
+}
+
+// InitFlags registers the flags with their default values
+func InitFlags(gl *Globals) {
+	gl.Flags = NewFlags()
+	f := gl.Flags
+	f.AddString("CPUProfile", "", "write a CPU profile, labeled with the Java methods, to this file")
+	f.AddString("CRaCCheckpointTo", "", "allow checkpoints of the program, which are written to this directory")
+	f.AddString("CRaCRestoreFrom", "", "restore the program from the checkpoint in this directory")
+	f.AddString("CoverageFile", "", "write the bytecode coverage of the application's classes to this file in lcov format at exit")
+	f.AddInt("DeadlockDetectionInterval", 0, "check for deadlocks among the monitors every this many milliseconds and log them (0: don't)")
+	f.AddBool("DecodeAtLink", false, "decode the bytecode of a class's methods when it's linked, not when each is first run")
+	f.AddBool("DisableAttachMechanism", false, "don't accept diagnostic commands from jacobin cmd")
+	f.AddBool("EnablePprof", false, "serve the profiles at /debug/pprof/ on the metrics port")
+	f.AddString("ErrorFile", "", "write the report of a crash to this file (empty: hs_err_pid<pid>.log)")
+	f.AddString("HeapProfile", "", "write a profile of the Java allocations to this file at exit")
+	f.AddString("LogFormat", "text", "the format of log messages: text or json")
+	f.AddInt("MetricsPort", 0, "serve the VM's metrics over HTTP on this localhost port (0: don't)")
+	f.AddBool("PrintFlagsFinal", false, "print all VM flags after argument and ergonomic processing")
+	f.AddBool("PrintMethodStatistics", false, "print the invocations, bytecodes, and self time of each method at exit")
+	f.AddBool("PrintSuperinstructions", false, "print the superinstructions formed with -XX:+UseSuperinstructions")
+	f.AddBool("PrintVMSummary", false, "print the time, classes, bytecodes, allocations, and threads of the run at exit")
+	f.AddBool("SampleProfiler", false, "sample the Java stacks and write them in the collapsed-stack format at exit")
+	f.AddString("SampleProfilerFile", "", "the file of the samples (empty: jacobin-pid<pid>.collapsed)")
+	f.AddInt("SampleProfilerInterval", 10, "the milliseconds between samples")
+	f.AddString("StartFlightRecording", "", "record VM events to a file, with these options (empty: don't)")
+	f.AddBool("UseIntrinsics", true, "do the calls of some hot JDK methods, such as Math.max(), in Go")
+	f.AddBool("UseSuperinstructions", false, "run the most frequent sequences of instructions, found as the program warms up, in one step")
+	f.AddBool("UseThreadPriorities", true, "treat thread priorities as scheduling hints")
+	f.AddString("VerifiedClassesFile", "", "remember the classes that passed the format check in this file, so later runs skip the check")
+}
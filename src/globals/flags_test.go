@@ -0,0 +1,69 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package globals
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetFlagsOfEachType(t *testing.T) {
+	f := NewFlags()
+	f.AddBool("Checked", false, "a boolean flag")
+	f.AddInt("BufferSize", 4096, "a numeric flag")
+	f.AddString("LogFile", "", "a string flag")
+
+	for _, setting := range []string{"+Checked", "BufferSize=64m", "LogFile=/tmp/vm.log"} {
+		if err := f.Set(setting); err != nil {
+			t.Errorf("Unexpected error setting %s: %s", setting, err.Error())
+		}
+	}
+	if !f.Bool("Checked") || f.Int("BufferSize") != 64*1024*1024 || f.String("LogFile") != "/tmp/vm.log" {
+		t.Errorf("Flags not set correctly: %v, %d, %s", f.Bool("Checked"), f.Int("BufferSize"), f.String("LogFile"))
+	}
+	if flag, _ := f.Lookup("BufferSize"); flag.Origin != "command line" {
+		t.Errorf("Expected the flag's origin to be the command line, got: %s", flag.Origin)
+	}
+
+	_ = f.Set("-Checked")
+	_ = f.Set("BufferSize=0x10K")
+	if f.Bool("Checked") || f.Int("BufferSize") != 16*1024 {
+		t.Errorf("Flags not reset correctly: %v, %d", f.Bool("Checked"), f.Int("BufferSize"))
+	}
+}
+
+func TestBadFlagSettings(t *testing.T) {
+	f := NewFlags()
+	f.AddBool("Checked", false, "a boolean flag")
+	f.AddInt("BufferSize", 4096, "a numeric flag")
+
+	tests := map[string]string{
+		"+NoSuchFlag":          "Unrecognized VM option '+NoSuchFlag'",
+		"Checked":              "Missing +/- setting for VM option 'Checked'",
+		"Checked=true":         "Missing +/- setting for VM option 'Checked'",
+		"+BufferSize":          "Unexpected +/- setting in VM option 'BufferSize'",
+		"BufferSize=lots":      "Improperly specified VM option 'BufferSize=lots'",
+		"BufferSize=99999999T": "Improperly specified VM option 'BufferSize=99999999T'",
+	}
+	for setting, msg := range tests {
+		err := f.Set(setting)
+		if err == nil || err.Error() != msg {
+			t.Errorf("Expected error %q for %s, got: %v", msg, setting, err)
+		}
+	}
+	if f.Int("BufferSize") != 4096 {
+		t.Errorf("Expected bad settings to leave the value unchanged, got: %d", f.Int("BufferSize"))
+	}
+}
+
+func TestStandardFlagsAreRegistered(t *testing.T) {
+	gl := InitGlobals("test")
+	names := strings.Join(gl.Flags.Names(), " ")
+	if !strings.Contains(names, "PrintFlagsFinal") || !gl.Flags.Bool("UseThreadPriorities") {
+		t.Errorf("Expected the standard flags with their defaults, got: %s", names)
+	}
+}
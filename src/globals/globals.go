@@ -8,41 +8,110 @@ package globals
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Globals contains variables that need to be globally accessible,
 // such as VM and program args, etc.
 // Note: globals cannot depend on exec package to avoid circularity.
 // As a result, exec contains its own globals
+//
+// Globals has two sections. The exported fields are the start-up configuration:
+// they're set by InitGlobals and the processing of the command line, before the
+// classloading and execution goroutines start, and are only read after that, so they
+// need no locking. Everything that can change once the program is running is in the
+// second section, which is read and written through the methods below it. Copies of
+// Globals share that section.
 type Globals struct {
 	// ---- jacobin version number ----
 	// note: all references to version number must come from this literal
 	Version string
 	VmModel string // "client" or "server" (both the same acc. to JVM docs)
 
+	StartTime time.Time // when the VM started, from which its uptime is measured
+
 	// ---- processing stoppage? ----
-	ExitNow bool
+	DryRun bool // load the main class but don't run it (--dry-run)
+	Diff   bool // run the program with both Jacobin and java, and compare (--diff)
+
+	// ---- checks ----
+	CheckNatives bool // check the Go natives against the JDK and at each call (-Xcheck:native)
 
 	// ---- command-line items ----
 	JacobinName string // name of the executing Jacobin executable
 	Args        []string
 	CommandLine string
 
-	StartingClass string
-	StartingJar   string
-	AppArgs       []string
-	Options       map[string]Option
+	StartingClass  string
+	StartingJar    string
+	StartingModule string // the module to run, and optionally its main class: -m <module>[/<mainclass>]
+	StartingSource string // a source file to compile and run, as in: jacobin Hello.java
+	AppArgs        []string
+	Options        map[string]Option
 
 	// ---- classloading items ----
 	MaxJavaVersion    int // the Java version as commonly known, i.e. Java 11
 	MaxJavaVersionRaw int // the Java version as it appears in bytecode i.e., 55 (= Java 11)
+	MinJavaVersionRaw int // the oldest class file version that's loaded: 45 (= Java 1.1)
 	VerifyLevel       int
 
+	// ---- VM flags (set with -XX options) ----
+	Flags *Flags
+
+	// ---- the debugging agent (-agentlib:jdwp), whose options are checked by jdwp.ParseOptions ----
+	JDWPOptions string
+
+	// ---- module options (--add-exports, --add-opens, and --add-modules) ----
+	Modules    *ModuleOptions
+	ModulePath string // the module path (-p), with the host's separators
+
 	// ---- paths for finding the base classes to load ----
 	JavaHome    string
 	JacobinHome string
+
+	// ---- the mutable state, which is synchronized ----
+	state *state
+}
+
+// state is the part of Globals that can change while the program runs
+type state struct {
+	mutex      sync.RWMutex
+	exitNow    bool            // should the VM exit once the command line is processed?
+	optionsSet map[string]bool // the options that have been specified on the command line
+}
+
+// ExitNow reports whether an option (such as -version) has asked the VM to exit once
+// the command line has been processed
+func (gl *Globals) ExitNow() bool {
+	gl.state.mutex.RLock()
+	defer gl.state.mutex.RUnlock()
+	return gl.state.exitNow
+}
+
+// SetExitNow sets whether the VM exits once the command line has been processed
+func (gl *Globals) SetExitNow(exit bool) {
+	gl.state.mutex.Lock()
+	defer gl.state.mutex.Unlock()
+	gl.state.exitNow = exit
+}
+
+// OptionSet reports whether the option, identified by its key in Options, has been
+// specified on the command line
+func (gl *Globals) OptionSet(key string) bool {
+	gl.state.mutex.RLock()
+	defer gl.state.mutex.RUnlock()
+	return gl.state.optionsSet[key]
+}
+
+// MarkOptionSet records that the option, identified by its key in Options, has been
+// specified on the command line
+func (gl *Globals) MarkOptionSet(key string) {
+	gl.state.mutex.Lock()
+	defer gl.state.mutex.Unlock()
+	gl.state.optionsSet[key] = true
 }
 
 // Wait group for various channels used for parallel loading of classes.
@@ -54,8 +123,8 @@ var global Globals
 func InitGlobals(progName string) Globals {
 	global = Globals{
 		Version:           "0.1.0",
+		StartTime:         time.Now(),
 		VmModel:           "server",
-		ExitNow:           false,
 		JacobinName:       progName,
 		JacobinHome:       "",
 		JavaHome:          "",
@@ -64,9 +133,14 @@ func InitGlobals(progName string) Globals {
 		StartingJar:       "",
 		MaxJavaVersion:    11, // this value and MaxJavaVersionRaw must *always* be in sync
 		MaxJavaVersionRaw: 55, // this value and MaxJavaVersion must *always* be in sync
+		MinJavaVersionRaw: 45,
+		Modules:           &ModuleOptions{},
+		state:             &state{optionsSet: make(map[string]bool)},
 	}
+	InitFlags(&global)
 	InitJavaHome()
 	InitJacobinHome()
+	InitSystemProperties(&global)
 	return global
 }
 
@@ -78,42 +152,39 @@ func GetGlobalRef() *Globals {
 // Option is the value portion of the globals.options table. This table is described in
 // more detail in option_table_loader.go introductory comments
 type Option struct {
-	Supported bool
-	Set       bool
-	ArgStyle  int16
-	Action    func(position int, name string, gl *Globals) (int, error)
+	Supported   bool
+	ArgStyle    int16
+	Action      func(position int, name string, gl *Globals) (int, error)
+	Extra       bool   // a nonstandard option, which -X lists rather than the usage message
+	Syntax      string // the option as the help listings show it, e.g., -verbose:[class|info]
+	Description string // the description in the help listings. Lines are separated by \n
+	Default     string // the default, if any, which the help listings show
 }
 
 // InitJacobinHome gets JACOBIN_HOME and formats it as expected
 func InitJacobinHome() {
-	jacobinHome := os.Getenv("JACOBIN_HOME")
-	if jacobinHome != "" {
-		// if the JacobinHome doesn't end in a backward slash, add one.
-		if !(strings.HasSuffix(jacobinHome, "\\") ||
-			strings.HasSuffix(jacobinHome, "/")) {
-			jacobinHome = jacobinHome + "\\"
-		}
-		// replace forward slashes in JacobinHome with backward slashes
-		jacobinHome = strings.ReplaceAll(jacobinHome, "/", "\\")
-	}
-	global.JacobinHome = jacobinHome
+	global.JacobinHome = homeDir(os.Getenv("JACOBIN_HOME"))
 }
 
 func JacobinHome() string { return global.JacobinHome }
 
 // InitJavaHome gets JAVA_HOME and formats it as expected
 func InitJavaHome() {
+	global.JavaHome = homeDir(os.Getenv("JAVA_HOME"))
+}
 
-	javaHome := os.Getenv("JAVA_HOME")
-	if javaHome != "" {
-		// if the JacobinHome doesn't end in a backward slash, add one.
-		if !(strings.HasSuffix(javaHome, "\\") ||
-			strings.HasSuffix(javaHome, "/")) {
-			javaHome = javaHome + "\\"
-		}
-		// replace forward slashes in JacobinHome with backward slashes
-		javaHome = strings.ReplaceAll(javaHome, "/", "\\")
+// homeDir formats a home directory, such as JAVA_HOME, as it's expected: with the
+// host's separators, and ending in one, so that file names can be appended to it.
+// (Forward slashes are accepted on Windows, as they are by the OS.)
+func homeDir(dir string) string {
+	if dir == "" {
+		return ""
 	}
-	global.JavaHome = javaHome
+	dir = filepath.FromSlash(dir)
+	if !strings.HasSuffix(dir, string(os.PathSeparator)) {
+		dir += string(os.PathSeparator)
+	}
+	return dir
 }
+
 func JavaHome() string { return global.JavaHome }
@@ -8,6 +8,8 @@ package globals
 
 import (
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -29,8 +31,8 @@ func TestJavaHomeFormat(t *testing.T) {
 	_ = os.Setenv("JAVA_HOME", "foo/bar")
 	InitJavaHome()
 	ret := JavaHome()
-	if ret != "foo\\bar\\" {
-		t.Errorf("Expecting a JAVA_HOME of 'foo\\bar\\', got: %s", ret)
+	if want := filepath.FromSlash("foo/bar/"); ret != want {
+		t.Errorf("Expecting a JAVA_HOME of '%s', got: %s", want, ret)
 	}
 	_ = os.Setenv("JAVA_HOME", origJavaHome)
 }
@@ -41,8 +43,34 @@ func TestJacobinHomeFormat(t *testing.T) {
 	_ = os.Setenv("JACOBIN_HOME", "foo/bar")
 	InitJacobinHome()
 	ret := JacobinHome()
-	if ret != "foo\\bar\\" {
-		t.Errorf("Expecting a JACOBIN_HOME of 'foo\\bar\\', got: %s", ret)
+	if want := filepath.FromSlash("foo/bar/"); ret != want {
+		t.Errorf("Expecting a JACOBIN_HOME of '%s', got: %s", want, ret)
 	}
 	_ = os.Setenv("JACOBIN_HOME", origJavaHome)
 }
+
+// copies of Globals share the mutable state, which can be used from several goroutines
+func TestMutableStateIsShared(t *testing.T) {
+	g := InitGlobals("test")
+	ref := GetGlobalRef()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ref.MarkOptionSet("-verbose")
+			_ = g.OptionSet("-trace")
+			_ = g.ExitNow()
+		}()
+	}
+	g.SetExitNow(true)
+	wg.Wait()
+
+	if !ref.ExitNow() {
+		t.Error("Expected SetExitNow on a copy of Globals to be seen through GetGlobalRef")
+	}
+	if !g.OptionSet("-verbose") || g.OptionSet("-trace") {
+		t.Error("Expected only -verbose to be marked as set")
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package globals
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// The host's locale and time zone, which give the defaults of user.language,
+// user.country, user.variant, user.timezone, and file.encoding, so that locale-sensitive
+// formatting matches what the JDK does on the same host. As with any system property,
+// -D options override them (-Duser.language=fr, -Duser.timezone=UTC, etc.).
+//
+// On Unix systems, the locale comes from the environment, as in the JDK: LC_ALL, then
+// LC_MESSAGES (for the language and country) or LC_CTYPE (for the encoding), then LANG.
+// A locale has the form language[_COUNTRY][.codeset][@modifier]. The C and POSIX
+// locales, and no locale at all, are taken as en_US. On Windows, the locale is the
+// user's default locale. file.encoding is the locale's codeset if it's one of the
+// charsets that Jacobin supports (see classloader/javaNioCharset.go), and otherwise
+// UTF-8.
+
+// setLocaleProperties sets the properties for the host's locale and time zone
+func setLocaleProperties(sp *Properties) {
+	display, format := hostLocales()
+	language, country, variant, _ := parseLocale(display)
+	sp.Set("user.language", language)
+	sp.Set("user.country", country)
+	if variant != "" {
+		sp.Set("user.variant", variant)
+	}
+	_, _, _, codeset := parseLocale(format)
+	sp.Set("file.encoding", javaEncoding(codeset))
+
+	// as in the JDK, user.timezone is empty if the zone can't be determined, which
+	// leaves java.util.TimeZone to fall back to the GMT offset
+	zone, _ := HostTimeZoneID()
+	sp.Set("user.timezone", zone)
+}
+
+// parseLocale splits a POSIX locale name, such as en_US.UTF-8 or de_DE@euro, into its
+// language, country, variant, and codeset. Windows names, such as en-US, are accepted too.
+func parseLocale(locale string) (language, country, variant, codeset string) {
+	if at := strings.Index(locale, "@"); at >= 0 {
+		locale = locale[:at] // modifiers such as @euro aren't variants in Java
+	}
+	if dot := strings.Index(locale, "."); dot >= 0 {
+		locale, codeset = locale[:dot], locale[dot+1:]
+	}
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return "en", "US", "", codeset
+	}
+
+	parts := strings.Split(strings.ReplaceAll(locale, "-", "_"), "_")
+	language = strings.ToLower(parts[0])
+	if len(parts) > 1 && len(parts[1]) == 4 { // a script, as in Windows's sr-Latn-RS
+		parts = append(parts[:1], parts[2:]...)
+	}
+	if len(parts) > 1 {
+		country = strings.ToUpper(parts[1])
+	}
+	if len(parts) > 2 {
+		variant = strings.Join(parts[2:], "_")
+	}
+	return language, country, variant, codeset
+}
+
+// javaEncoding returns the Java name of the codeset, if Jacobin supports it, or UTF-8
+func javaEncoding(codeset string) string {
+	switch strings.NewReplacer("-", "", "_", "").Replace(strings.ToUpper(codeset)) {
+	case "ISO88591", "LATIN1":
+		return "ISO-8859-1"
+	case "ANSIX3.41968", "ASCII", "USASCII", "646":
+		return "US-ASCII"
+	}
+	return "UTF-8"
+}
+
+// HostTimeZoneID returns the ID of the host's time zone, found the way the JDK finds it
+// on Unix systems: from the TZ environment variable, then /etc/timezone, then the
+// zoneinfo file that /etc/localtime links to. The second return value is false if the
+// zone can't be determined.
+func HostTimeZoneID() (string, bool) {
+	if tz, present := os.LookupEnv("TZ"); present && tz != "" {
+		return zoneIDFromPath(strings.TrimPrefix(tz, ":"))
+	}
+	if runtime.GOOS == "windows" {
+		return "", false // TODO: map the Windows time zone name, as the JDK's tzmappings does
+	}
+	if content, err := ioutil.ReadFile("/etc/timezone"); err == nil {
+		if id := strings.TrimSpace(string(content)); id != "" {
+			return id, true
+		}
+	}
+	if target, err := filepath.EvalSymlinks("/etc/localtime"); err == nil {
+		return zoneIDFromPath(target)
+	}
+	return "", false
+}
+
+// zoneIDFromPath turns a path to a zoneinfo file into a zone ID: the part of the path
+// after the zoneinfo directory. IDs that aren't paths are returned as they are.
+func zoneIDFromPath(path string) (string, bool) {
+	path = filepath.ToSlash(path)
+	if i := strings.LastIndex(path, "zoneinfo/"); i != -1 {
+		path = path[i+len("zoneinfo/"):]
+	}
+	path = strings.TrimPrefix(path, "posix/")
+	if path == "" || strings.HasPrefix(path, "/") {
+		return "", false
+	}
+	return path, true
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package globals
+
+import "os"
+
+// hostLocales returns the locales for messages (the language and country) and for
+// character types (the encoding), from the environment as the C library finds them
+func hostLocales() (display, format string) {
+	return localeFromEnv("LC_MESSAGES"), localeFromEnv("LC_CTYPE")
+}
+
+// localeFromEnv returns the locale for the category: LC_ALL overrides it, and LANG
+// is the default
+func localeFromEnv(category string) string {
+	for _, name := range []string{"LC_ALL", category, "LANG"} {
+		if locale := os.Getenv(name); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package globals
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestParseLocale(t *testing.T) {
+	for locale, want := range map[string][4]string{
+		"":                 {"en", "US", "", ""},
+		"C":                {"en", "US", "", ""},
+		"POSIX":            {"en", "US", "", ""},
+		"C.UTF-8":          {"en", "US", "", "UTF-8"},
+		"en_GB.UTF-8":      {"en", "GB", "", "UTF-8"},
+		"de_DE@euro":       {"de", "DE", "", ""},
+		"fr_CA.ISO-8859-1": {"fr", "CA", "", "ISO-8859-1"},
+		"ja":               {"ja", "", "", ""},
+		"en-US":            {"en", "US", "", ""},
+		"sr-Latn-RS":       {"sr", "RS", "", ""},
+		"no_NO_NY":         {"no", "NO", "NY", ""},
+	} {
+		language, country, variant, codeset := parseLocale(locale)
+		if got := [4]string{language, country, variant, codeset}; got != want {
+			t.Errorf("%q: expected %v, got %v", locale, want, got)
+		}
+	}
+}
+
+func TestJavaEncoding(t *testing.T) {
+	for codeset, want := range map[string]string{
+		"UTF-8": "UTF-8", "utf8": "UTF-8", "ISO-8859-1": "ISO-8859-1", "ISO8859-1": "ISO-8859-1",
+		"ANSI_X3.4-1968": "US-ASCII", "": "UTF-8", "EUC-JP": "UTF-8",
+	} {
+		if got := javaEncoding(codeset); got != want {
+			t.Errorf("%q: expected %s, got %s", codeset, want, got)
+		}
+	}
+}
+
+// the locale and time zone properties come from the environment
+func TestLocaleProperties(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the locale doesn't come from the environment on Windows")
+	}
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LC_CTYPE", "LANG", "TZ"} {
+		if saved, present := os.LookupEnv(name); present {
+			defer os.Setenv(name, saved)
+		} else {
+			defer os.Unsetenv(name)
+		}
+		os.Unsetenv(name)
+	}
+	os.Setenv("LANG", "de_DE.UTF-8")
+	os.Setenv("LC_CTYPE", "fr_CA.ISO-8859-1")
+	os.Setenv("TZ", "Europe/Berlin")
+
+	sp := NewProperties()
+	setLocaleProperties(sp)
+	for key, want := range map[string]string{"user.language": "de", "user.country": "DE",
+		"file.encoding": "ISO-8859-1", "user.timezone": "Europe/Berlin"} {
+		if val, _ := sp.Get(key); val != want {
+			t.Errorf("Expected %s to be %q, got %q", key, want, val)
+		}
+	}
+
+	os.Setenv("LC_ALL", "ja_JP.UTF-8")
+	sp = NewProperties()
+	setLocaleProperties(sp)
+	if language, _ := sp.Get("user.language"); language != "ja" {
+		t.Errorf("Expected LC_ALL to override LANG, got user.language %q", language)
+	}
+	if encoding, _ := sp.Get("file.encoding"); encoding != "UTF-8" {
+		t.Errorf("Expected LC_ALL to override LC_CTYPE, got file.encoding %q", encoding)
+	}
+}
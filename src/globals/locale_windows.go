@@ -0,0 +1,30 @@
+//go:build windows
+// +build windows
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package globals
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetUserDefaultLocaleName = syscall.NewLazyDLL("kernel32.dll").NewProc("GetUserDefaultLocaleName")
+
+// hostLocales returns the user's default locale, such as en-US, for both messages and
+// character types. Windows locale names have no codeset, so the encoding is UTF-8.
+func hostLocales() (display, format string) {
+	const localeNameMaxLength = 85
+	buf := make([]uint16, localeNameMaxLength)
+	n, _, _ := procGetUserDefaultLocaleName.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	if n == 0 {
+		return "", ""
+	}
+	name := syscall.UTF16ToString(buf)
+	return name, name
+}
@@ -0,0 +1,49 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package globals
+
+import (
+	"errors"
+	"strings"
+)
+
+// ModuleOptions are the module-related options from the command line: --add-exports,
+// --add-opens, and --add-modules. Build tools and frameworks pass them routinely, so
+// they're accepted and recorded here, although Jacobin doesn't enforce module boundaries
+// yet. Like the rest of the start-up configuration, they're only read once the command
+// line has been processed. Copies of Globals share them.
+type ModuleOptions struct {
+	AddExports []ModuleAccess
+	AddOpens   []ModuleAccess
+	AddModules []string // the root modules to add: module names, ALL-DEFAULT, ALL-SYSTEM, or ALL-MODULE-PATH
+}
+
+// ModuleAccess is a package of a module that's exported or opened to other modules,
+// as specified by <module>/<package>=<target-module>(,<target-module>)*, where a target
+// module can be ALL-UNNAMED, meaning all the code on the class path
+type ModuleAccess struct {
+	Module  string
+	Package string
+	Targets []string
+}
+
+// ParseModuleAccess parses the value of an --add-exports or --add-opens option
+func ParseModuleAccess(value string) (ModuleAccess, error) {
+	eq := strings.Index(value, "=")
+	slash := strings.Index(value, "/")
+	if eq < 0 || slash <= 0 || slash > eq || slash == eq-1 {
+		return ModuleAccess{}, errors.New("expected <module>/<package>=<target-module>(,<target-module>)*")
+	}
+	access := ModuleAccess{Module: value[:slash], Package: value[slash+1 : eq]}
+	for _, target := range strings.Split(value[eq+1:], ",") {
+		if target == "" {
+			return ModuleAccess{}, errors.New("empty target module")
+		}
+		access.Targets = append(access.Targets, target)
+	}
+	return access, nil
+}
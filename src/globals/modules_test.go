@@ -0,0 +1,27 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package globals
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseModuleAccess(t *testing.T) {
+	access, err := ParseModuleAccess("java.base/java.lang=ALL-UNNAMED,my.module")
+	want := ModuleAccess{Module: "java.base", Package: "java.lang", Targets: []string{"ALL-UNNAMED", "my.module"}}
+	if err != nil || !reflect.DeepEqual(access, want) {
+		t.Errorf("Expected %+v, got %+v (%v)", want, access, err)
+	}
+
+	for _, invalid := range []string{"java.base", "java.base/java.lang", "/java.lang=ALL-UNNAMED",
+		"java.base/=ALL-UNNAMED", "java.base=x/y", "java.base/java.lang=", "java.base/java.lang=a,,b"} {
+		if _, err := ParseModuleAccess(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package globals
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Properties is a thread-safe store of string properties. It's used for the system
+// properties (see SystemProperties), which back System.getProperty(), setProperty(),
+// getProperties(), etc., and for java.util.Properties objects.
+type Properties struct {
+	mutex sync.RWMutex
+	props map[string]string
+}
+
+// NewProperties returns an empty property store
+func NewProperties() *Properties {
+	return &Properties{props: make(map[string]string)}
+}
+
+// Get returns the value of the property and whether it's set
+func (p *Properties) Get(key string) (string, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	val, present := p.props[key]
+	return val, present
+}
+
+// Set sets the property and returns its previous value, if any
+func (p *Properties) Set(key, value string) (string, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	old, present := p.props[key]
+	p.props[key] = value
+	return old, present
+}
+
+// Clear removes the property and returns its previous value, if any
+func (p *Properties) Clear(key string) (string, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	old, present := p.props[key]
+	delete(p.props, key)
+	return old, present
+}
+
+// Keys returns the names of all the properties, sorted
+func (p *Properties) Keys() []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	keys := make([]string, 0, len(p.props))
+	for k := range p.props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SystemProperties are the Java system properties, which are populated with the
+// standard defaults when the globals are initialized.
+var SystemProperties = NewProperties()
+
+// InitSystemProperties sets the standard system properties to their default values
+// for the platform Jacobin is running on.
+func InitSystemProperties(gl *Globals) {
+	SystemProperties = NewProperties()
+	sp := SystemProperties
+
+	javaVersion := "11.0.10"
+	sp.Set("java.version", javaVersion)
+	sp.Set("java.version.date", "2021-01-19")
+	sp.Set("java.specification.version", "11")
+	sp.Set("java.vm.specification.version", "11")
+	sp.Set("java.class.version", "55.0")
+	sp.Set("java.vendor", "Jacobin")
+	sp.Set("java.vm.vendor", "Jacobin")
+	sp.Set("java.vm.name", "Jacobin VM")
+	sp.Set("java.vm.version", gl.Version)
+	sp.Set("java.runtime.version", javaVersion)
+	sp.Set("java.home", strings.TrimSuffix(gl.JavaHome, string(os.PathSeparator)))
+	// as with java, the CLASSPATH environment variable is the default class path
+	if classPath := os.Getenv("CLASSPATH"); classPath != "" {
+		sp.Set("java.class.path", NormalizePathList(classPath))
+	} else {
+		sp.Set("java.class.path", ".")
+	}
+
+	sp.Set("os.name", osName())
+	sp.Set("os.arch", osArch())
+	sp.Set("os.version", osVersion())
+
+	sp.Set("file.separator", string(os.PathSeparator))
+	sp.Set("path.separator", string(os.PathListSeparator))
+	if runtime.GOOS == "windows" {
+		sp.Set("line.separator", "\r\n")
+	} else {
+		sp.Set("line.separator", "\n")
+	}
+	sp.Set("java.io.tmpdir", os.TempDir())
+	sp.Set("java.library.path", libraryPath())
+
+	if dir, err := os.Getwd(); err == nil {
+		sp.Set("user.dir", dir)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		sp.Set("user.home", home)
+	}
+	if u, err := user.Current(); err == nil {
+		name := u.Username
+		if runtime.GOOS == "windows" { // Windows usernames are DOMAIN\user
+			name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+		}
+		sp.Set("user.name", name)
+	}
+	setLocaleProperties(sp)
+}
+
+// libraryPath returns the default java.library.path, which, as in the JDK, is the
+// platform's library search path followed by the standard library directories.
+func libraryPath() string {
+	var dirs []string
+	switch runtime.GOOS {
+	case "windows":
+		dirs = []string{os.Getenv("PATH"), "."}
+	case "darwin":
+		dirs = []string{os.Getenv("DYLD_LIBRARY_PATH"), "/Library/Java/Extensions", "/usr/lib/java", "."}
+	default:
+		dirs = []string{os.Getenv("LD_LIBRARY_PATH"), "/usr/java/packages/lib", "/usr/lib64",
+			"/lib64", "/lib", "/usr/lib"}
+	}
+	var nonEmpty []string
+	for _, d := range dirs {
+		if d != "" {
+			nonEmpty = append(nonEmpty, d)
+		}
+	}
+	return strings.Join(nonEmpty, string(os.PathListSeparator))
+}
+
+// NormalizePathList formats a list of paths, such as a class path, for the host: the
+// entries are separated by its list separator (; on Windows and : elsewhere) and use
+// its file separator. Empty entries are dropped.
+func NormalizePathList(paths string) string {
+	var entries []string
+	for _, entry := range filepath.SplitList(paths) {
+		if entry != "" {
+			entries = append(entries, filepath.FromSlash(entry))
+		}
+	}
+	return strings.Join(entries, string(os.PathListSeparator))
+}
+
+// osName returns the name of the OS the way the JDK reports it in os.name
+func osName() string {
+	switch runtime.GOOS {
+	case "linux":
+		return "Linux"
+	case "darwin":
+		return "Mac OS X"
+	case "windows":
+		return "Windows"
+	case "freebsd":
+		return "FreeBSD"
+	default:
+		return runtime.GOOS
+	}
+}
+
+// osArch returns the architecture the way the JDK reports it in os.arch
+func osArch() string {
+	switch runtime.GOARCH {
+	case "386":
+		return "x86"
+	case "arm64":
+		return "aarch64"
+	default:
+		return runtime.GOARCH // amd64, arm, ppc64le, s390x, etc.
+	}
+}
+
+// osVersion returns the version of the OS kernel, where it can be determined
+// without platform-specific system calls.
+func osVersion() string {
+	if runtime.GOOS == "linux" {
+		release, err := ioutil.ReadFile("/proc/sys/kernel/osrelease")
+		if err == nil {
+			return strings.TrimSpace(string(release))
+		}
+	}
+	return "unknown"
+}
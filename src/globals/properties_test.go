@@ -0,0 +1,85 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package globals
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStandardSystemProperties(t *testing.T) {
+	InitGlobals("test")
+	for _, key := range []string{"java.version", "java.home", "os.name", "os.arch", "os.version",
+		"file.separator", "path.separator", "line.separator", "user.dir", "java.io.tmpdir"} {
+		if _, present := SystemProperties.Get(key); !present {
+			t.Errorf("Expected system property %s to be set", key)
+		}
+	}
+
+	sep, _ := SystemProperties.Get("file.separator")
+	if sep != string(os.PathSeparator) {
+		t.Errorf("Expected file.separator of %q, got: %q", string(os.PathSeparator), sep)
+	}
+	ver, _ := SystemProperties.Get("java.vm.version")
+	if ver != GetGlobalRef().Version {
+		t.Errorf("Expected java.vm.version to be Jacobin's version, got: %s", ver)
+	}
+}
+
+func TestPropertiesSetAndClear(t *testing.T) {
+	p := NewProperties()
+	if _, existed := p.Set("a", "1"); existed {
+		t.Errorf("Expected no previous value for new property")
+	}
+	if old, existed := p.Set("a", "2"); !existed || old != "1" {
+		t.Errorf("Expected previous value of 1, got: %s", old)
+	}
+	p.Set("b", "3")
+	if keys := p.Keys(); len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("Expected keys [a b], got: %v", keys)
+	}
+	if old, _ := p.Clear("a"); old != "2" {
+		t.Errorf("Expected cleared value of 2, got: %s", old)
+	}
+	if _, present := p.Get("a"); present {
+		t.Errorf("Expected cleared property to be gone")
+	}
+}
+
+func TestNormalizePathList(t *testing.T) {
+	sep := string(os.PathListSeparator)
+	paths := NormalizePathList("lib/a.jar" + sep + sep + "classes")
+	want := filepath.FromSlash("lib/a.jar") + sep + "classes"
+	if paths != want {
+		t.Errorf("Expected path list %q, got: %q", want, paths)
+	}
+}
+
+func TestClassPathFromEnvironment(t *testing.T) {
+	orig, wasSet := os.LookupEnv("CLASSPATH")
+	defer func() {
+		if wasSet {
+			_ = os.Setenv("CLASSPATH", orig)
+		} else {
+			_ = os.Unsetenv("CLASSPATH")
+		}
+		InitGlobals("test")
+	}()
+
+	_ = os.Setenv("CLASSPATH", "lib/a.jar")
+	InitGlobals("test")
+	if cp, _ := SystemProperties.Get("java.class.path"); cp != filepath.FromSlash("lib/a.jar") {
+		t.Errorf("Expected the class path from CLASSPATH, got: %s", cp)
+	}
+
+	_ = os.Unsetenv("CLASSPATH")
+	InitGlobals("test")
+	if cp, _ := SystemProperties.Get("java.class.path"); cp != "." {
+		t.Errorf("Expected the default class path of ., got: %s", cp)
+	}
+}
@@ -0,0 +1,602 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jdwp
+
+// command sets
+const (
+	setVirtualMachine       = 1
+	setReferenceType        = 2
+	setClassType            = 3
+	setMethod               = 6
+	setObjectReference      = 9
+	setStringReference      = 10
+	setThreadReference      = 11
+	setThreadGroupReference = 12
+	setEventRequest         = 15
+	setStackFrame           = 16
+)
+
+// the version of the protocol that's implemented
+const (
+	jdwpMajor = 11
+	jdwpMinor = 0
+)
+
+// handle carries out a command and returns the reply, and whether the debugger has
+// disposed of the connection. The mutex is held.
+func (s *Server) handle(p *packet) (*packet, bool) {
+	r := &reader{b: p.data}
+	w := &writer{}
+	var err error
+	disposed := false
+
+	switch p.commandSet {
+	case setVirtualMachine:
+		disposed, err = s.virtualMachine(p.command, r, w)
+	case setReferenceType:
+		err = s.referenceType(p.command, r, w)
+	case setClassType:
+		err = s.classType(p.command, r, w)
+	case setMethod:
+		err = s.method(p.command, r, w)
+	case setObjectReference:
+		err = s.objectReference(p.command, r, w)
+	case setStringReference:
+		err = s.stringReference(p.command, r, w)
+	case setThreadReference:
+		err = s.threadReference(p.command, r, w)
+	case setThreadGroupReference:
+		err = s.threadGroupReference(p.command, r, w)
+	case setEventRequest:
+		err = s.eventRequest(p.command, r, w)
+	case setStackFrame:
+		err = s.stackFrame(p.command, r, w)
+	default:
+		err = ErrNotImplemented
+	}
+
+	reply := &packet{id: p.id, flags: flagReply}
+	switch {
+	case r.err != nil:
+		reply.errorCode = ErrInvalidLength
+	case err != nil:
+		reply.errorCode = errorCodeOf(err)
+	default:
+		reply.data = w.b
+	}
+	return reply, disposed
+}
+
+// ---- VirtualMachine ----
+
+func (s *Server) virtualMachine(command byte, r *reader, w *writer) (bool, error) {
+	switch command {
+	case 1: // Version
+		name, version := s.vm.Name()
+		w.str(name + " " + version)
+		w.u4(jdwpMajor)
+		w.u4(jdwpMinor)
+		w.str(version)
+		w.str(name)
+	case 2: // ClassesBySignature
+		signature := r.str()
+		var matches []Class
+		for _, c := range s.vm.Classes() {
+			if c.Signature == signature {
+				matches = append(matches, c)
+			}
+		}
+		w.u4(int32(len(matches)))
+		for _, c := range matches {
+			w.u1(c.Tag)
+			w.id(c.ID)
+			w.u4(c.Status)
+		}
+	case 3, 20: // AllClasses, AllClassesWithGeneric
+		classes := s.vm.Classes()
+		w.u4(int32(len(classes)))
+		for _, c := range classes {
+			w.u1(c.Tag)
+			w.id(c.ID)
+			w.str(c.Signature)
+			if command == 20 {
+				w.str("")
+			}
+			w.u4(c.Status)
+		}
+	case 4: // AllThreads
+		threads := s.vm.Threads()
+		w.u4(int32(len(threads)))
+		for _, t := range threads {
+			w.id(t.ID)
+		}
+	case 5: // TopLevelThreadGroups
+		w.u4(1)
+		w.id(ThreadGroupID)
+	case 6: // Dispose
+		return true, nil
+	case 7: // IDSizes: field, method, object, reference type, and frame IDs
+		for i := 0; i < 5; i++ {
+			w.u4(idSize)
+		}
+	case 8: // Suspend
+		s.suspendAll()
+	case 9: // Resume
+		s.resumeAll()
+	case 10: // Exit
+		status := r.u4()
+		if r.err == nil {
+			go s.vm.Exit(int(status)) // exiting tells the debugger, which needs the mutex
+		}
+	case 11: // CreateString
+		w.id(s.vm.CreateString(r.str()))
+	case 12, 17: // Capabilities, CapabilitiesNew
+		capabilities := make([]bool, 7)
+		if command == 17 {
+			capabilities = make([]bool, 32)
+			capabilities[13] = true // canRequestVMDeathEvent
+			capabilities[14] = true // canSetDefaultStratum
+		}
+		capabilities[2] = true // canGetBytecodes
+		for _, c := range capabilities {
+			w.boolean(c)
+		}
+	case 13: // ClassPaths
+		baseDir, classPath := s.vm.ClassPaths()
+		w.str(baseDir)
+		w.u4(int32(len(classPath)))
+		for _, path := range classPath {
+			w.str(path)
+		}
+		w.u4(0) // the boot class path, which isn't used since Java 9
+	case 14, 15, 16, 19: // DisposeObjects, HoldEvents, ReleaseEvents, SetDefaultStratum
+		// objects aren't collected while they're referred to, and events aren't held
+	default:
+		return false, ErrNotImplemented
+	}
+	return false, nil
+}
+
+// ---- ReferenceType and ClassType ----
+
+func (s *Server) referenceType(command byte, r *reader, w *writer) error {
+	class, err := s.vm.Class(r.id())
+	if r.err != nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	switch command {
+	case 1, 13: // Signature, SignatureWithGeneric
+		w.str(class.Signature)
+		if command == 13 {
+			w.str("")
+		}
+	case 2: // ClassLoader: the bootstrap loader, as far as the debugger's concerned
+		w.id(0)
+	case 3: // Modifiers
+		w.u4(class.Modifiers)
+	case 4, 14: // Fields, FieldsWithGeneric
+		fields, err := s.vm.Fields(class.ID)
+		if err != nil {
+			return err
+		}
+		w.u4(int32(len(fields)))
+		for _, f := range fields {
+			w.id(f.ID)
+			w.str(f.Name)
+			w.str(f.Signature)
+			if command == 14 {
+				w.str("")
+			}
+			w.u4(f.Modifiers)
+		}
+	case 5, 15: // Methods, MethodsWithGeneric
+		methods, err := s.vm.Methods(class.ID)
+		if err != nil {
+			return err
+		}
+		w.u4(int32(len(methods)))
+		for _, m := range methods {
+			w.id(m.ID)
+			w.str(m.Name)
+			w.str(m.Signature)
+			if command == 15 {
+				w.str("")
+			}
+			w.u4(m.Modifiers)
+		}
+	case 7: // SourceFile
+		file, err := s.vm.SourceFile(class.ID)
+		if err != nil {
+			return err
+		}
+		w.str(file)
+	case 8: // NestedTypes
+		w.u4(0)
+	case 9: // Status
+		w.u4(class.Status)
+	case 10: // Interfaces
+		interfaces, err := s.vm.Interfaces(class.ID)
+		if err != nil {
+			return err
+		}
+		w.u4(int32(len(interfaces)))
+		for _, id := range interfaces {
+			w.id(id)
+		}
+	case 12: // SourceDebugExtension
+		return ErrAbsentInformation
+	default:
+		return ErrNotImplemented
+	}
+	return nil
+}
+
+func (s *Server) classType(command byte, r *reader, w *writer) error {
+	if command != 1 { // Superclass
+		return ErrNotImplemented
+	}
+	super, err := s.vm.Superclass(r.id())
+	if err != nil {
+		return err
+	}
+	w.id(super)
+	return nil
+}
+
+// ---- Method ----
+
+func (s *Server) method(command byte, r *reader, w *writer) error {
+	class, method := r.id(), r.id()
+	if r.err != nil {
+		return nil
+	}
+	switch command {
+	case 1: // LineTable
+		table, err := s.vm.LineTable(class, method)
+		if err != nil {
+			return err
+		}
+		w.u8(table.Start)
+		w.u8(table.End)
+		w.u4(int32(len(table.Lines)))
+		for _, line := range table.Lines {
+			w.u8(line.Index)
+			w.u4(line.Number)
+		}
+	case 2, 5: // VariableTable, VariableTableWithGeneric
+		table, err := s.vm.VariableTable(class, method)
+		if err != nil {
+			return err
+		}
+		w.u4(table.ArgCount)
+		w.u4(int32(len(table.Variables)))
+		for _, v := range table.Variables {
+			w.u8(v.Start)
+			w.str(v.Name)
+			w.str(v.Signature)
+			if command == 5 {
+				w.str("")
+			}
+			w.u4(v.Length)
+			w.u4(v.Slot)
+		}
+	case 3: // Bytecodes
+		code, err := s.vm.Bytecodes(class, method)
+		if err != nil {
+			return err
+		}
+		w.u4(int32(len(code)))
+		w.b = append(w.b, code...)
+	case 4: // IsObsolete: classes aren't redefined
+		w.boolean(false)
+	default:
+		return ErrNotImplemented
+	}
+	return nil
+}
+
+// ---- ObjectReference and StringReference ----
+
+func (s *Server) objectReference(command byte, r *reader, w *writer) error {
+	object := r.id()
+	if r.err != nil {
+		return nil
+	}
+	switch command {
+	case 1: // ReferenceType
+		class, err := s.vm.ObjectClass(object)
+		if err != nil {
+			return err
+		}
+		w.u1(class.Tag)
+		w.id(class.ID)
+	case 7, 8: // DisableCollection, EnableCollection
+	case 9: // IsCollected
+		w.boolean(false)
+	default:
+		return ErrNotImplemented
+	}
+	return nil
+}
+
+func (s *Server) stringReference(command byte, r *reader, w *writer) error {
+	if command != 1 { // Value
+		return ErrNotImplemented
+	}
+	str, err := s.vm.StringValue(r.id())
+	if err != nil {
+		return err
+	}
+	w.str(str)
+	return nil
+}
+
+// ---- ThreadReference and ThreadGroupReference ----
+
+// findThread returns the live thread with the ID
+func (s *Server) findThread(id int64) (Thread, error) {
+	for _, t := range s.vm.Threads() {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+	return Thread{}, ErrInvalidThread
+}
+
+func (s *Server) threadReference(command byte, r *reader, w *writer) error {
+	thread, err := s.findThread(r.id())
+	if r.err != nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	switch command {
+	case 1: // Name
+		w.str(thread.Name)
+	case 2: // Suspend
+		s.suspend(thread.ID)
+	case 3: // Resume
+		s.resume(thread.ID)
+	case 4: // Status
+		w.u4(thread.Status)
+		if s.thread(thread.ID).suspendCount > 0 {
+			w.u4(1)
+		} else {
+			w.u4(0)
+		}
+	case 5: // ThreadGroup
+		w.id(ThreadGroupID)
+	case 6, 7: // Frames, FrameCount
+		if err := s.awaitStopped(thread.ID); err != nil {
+			return err
+		}
+		frames, err := s.vm.Frames(thread.ID)
+		if err != nil {
+			return err
+		}
+		if command == 7 {
+			w.u4(int32(len(frames)))
+			return nil
+		}
+		start, length := int(r.u4()), int(r.u4())
+		if length == -1 && start >= 0 && start <= len(frames) {
+			length = len(frames) - start
+		}
+		if start < 0 || length < 0 || start+length > len(frames) {
+			return ErrInvalidLength
+		}
+		w.u4(int32(length))
+		for _, f := range frames[start : start+length] {
+			w.id(f.ID)
+			w.location(f.Location)
+		}
+	case 12: // SuspendCount
+		w.u4(int32(s.thread(thread.ID).suspendCount))
+	default:
+		return ErrNotImplemented
+	}
+	return nil
+}
+
+func (s *Server) threadGroupReference(command byte, r *reader, w *writer) error {
+	if r.id() != ThreadGroupID {
+		if r.err != nil {
+			return nil
+		}
+		return ErrInvalidThreadGroup
+	}
+	switch command {
+	case 1: // Name
+		w.str("main")
+	case 2: // Parent
+		w.id(0)
+	case 3: // Children: the threads and the child groups
+		threads := s.vm.Threads()
+		w.u4(int32(len(threads)))
+		for _, t := range threads {
+			w.id(t.ID)
+		}
+		w.u4(0)
+	default:
+		return ErrNotImplemented
+	}
+	return nil
+}
+
+// ---- EventRequest ----
+
+func (s *Server) eventRequest(command byte, r *reader, w *writer) error {
+	switch command {
+	case 1: // Set
+		req, err := s.readEventRequest(r)
+		if r.err != nil || err != nil {
+			return err
+		}
+		s.nextRequestID++
+		req.id = s.nextRequestID
+		s.requests = append(s.requests, req)
+		s.updateActive()
+		w.u4(req.id)
+	case 2: // Clear
+		kind, id := r.u1(), r.u4()
+		kept := s.requests[:0]
+		for _, req := range s.requests {
+			if req.kind != kind || req.id != id {
+				kept = append(kept, req)
+			}
+		}
+		s.requests = kept
+		s.updateActive()
+	case 3: // ClearAllBreakpoints
+		kept := s.requests[:0]
+		for _, req := range s.requests {
+			if req.kind != eventBreakpoint {
+				kept = append(kept, req)
+			}
+		}
+		s.requests = kept
+		s.updateActive()
+	default:
+		return ErrNotImplemented
+	}
+	return nil
+}
+
+// readEventRequest reads the event kind, suspend policy, and modifiers of a request.
+// Modifiers that don't apply to the events that are reported are accepted and ignored.
+func (s *Server) readEventRequest(r *reader) (*eventRequest, error) {
+	req := &eventRequest{kind: r.u1(), policy: r.u1()}
+	switch req.kind {
+	case 1, 2, 3, 4, 5, 6, 7, 8, 9, 20, 21, 30, 40, 41, 42, 43, 44, 45, 46, 99:
+	default:
+		return nil, ErrInvalidEventType
+	}
+	if req.policy > suspendAll {
+		return nil, ErrIllegalArgument
+	}
+
+	modifiers := r.u4()
+	for i := int32(0); i < modifiers && r.err == nil; i++ {
+		switch r.u1() {
+		case modCount:
+			req.count = r.u4()
+		case modConditional:
+			r.u4()
+		case modThreadOnly:
+			req.thread = r.id()
+		case modClassOnly:
+			req.classOnly = r.id()
+		case modClassMatch:
+			req.classMatch = append(req.classMatch, r.str())
+		case modClassExclude:
+			req.classExclude = append(req.classExclude, r.str())
+		case modLocationOnly:
+			loc := r.location()
+			req.location = &loc
+		case modException:
+			r.id()
+			r.boolean()
+			r.boolean()
+		case modFieldOnly:
+			r.id()
+			r.id()
+		case modStep:
+			req.step = &step{thread: r.id(), size: r.u4(), depth: r.u4()}
+		case modInstanceOnly:
+			r.id()
+		case modSourceName:
+			r.str()
+		case modPlatformOnly:
+		default:
+			return nil, ErrIllegalArgument
+		}
+	}
+	if r.err != nil {
+		return nil, nil
+	}
+
+	switch req.kind {
+	case eventBreakpoint:
+		if req.location == nil {
+			return nil, ErrInvalidLocation
+		}
+	case eventSingleStep:
+		if req.step == nil || req.step.size > stepLine || req.step.depth > stepOut {
+			return nil, ErrIllegalArgument
+		}
+		if _, err := s.findThread(req.step.thread); err != nil {
+			return nil, err
+		}
+		if err := s.awaitStopped(req.step.thread); err != nil {
+			return nil, err
+		}
+		if err := s.startStep(req.step, req.step.thread); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// ---- StackFrame ----
+
+func (s *Server) stackFrame(command byte, r *reader, w *writer) error {
+	thread, frame := r.id(), r.id()
+	if r.err != nil {
+		return nil
+	}
+	if _, err := s.findThread(thread); err != nil {
+		return err
+	}
+	if err := s.awaitStopped(thread); err != nil {
+		return err
+	}
+
+	switch command {
+	case 1: // GetValues
+		count := r.u4()
+		var slots []Slot
+		for i := int32(0); i < count && r.err == nil; i++ {
+			slots = append(slots, Slot{Index: r.u4(), Tag: r.u1()})
+		}
+		if r.err != nil {
+			return nil
+		}
+		values, err := s.vm.FrameValues(thread, frame, slots)
+		if err != nil {
+			return err
+		}
+		w.u4(int32(len(values)))
+		for _, v := range values {
+			w.value(v)
+		}
+	case 2: // SetValues
+		count := r.u4()
+		var slots []int32
+		var values []Value
+		for i := int32(0); i < count && r.err == nil; i++ {
+			slots = append(slots, r.u4())
+			values = append(values, r.value())
+		}
+		if r.err != nil {
+			return nil
+		}
+		return s.vm.SetFrameValues(thread, frame, slots, values)
+	case 3: // ThisObject
+		this, err := s.vm.ThisObject(thread, frame)
+		if err != nil {
+			return err
+		}
+		w.value(this)
+	default:
+		return ErrNotImplemented
+	}
+	return nil
+}
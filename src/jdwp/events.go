@@ -0,0 +1,296 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jdwp
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// event kinds
+const (
+	eventSingleStep   = 1
+	eventBreakpoint   = 2
+	eventThreadStart  = 6
+	eventThreadDeath  = 7
+	eventClassPrepare = 8
+	eventVMStart      = 90
+	eventVMDeath      = 99
+)
+
+// suspend policies
+const (
+	suspendNone        = 0
+	suspendEventThread = 1
+	suspendAll         = 2
+)
+
+// modifier kinds of event requests
+const (
+	modCount        = 1
+	modConditional  = 2
+	modThreadOnly   = 3
+	modClassOnly    = 4
+	modClassMatch   = 5
+	modClassExclude = 6
+	modLocationOnly = 7
+	modException    = 8
+	modFieldOnly    = 9
+	modStep         = 10
+	modInstanceOnly = 11
+	modSourceName   = 12
+	modPlatformOnly = 13
+)
+
+// step sizes and depths
+const (
+	stepMin  = 0
+	stepLine = 1
+
+	stepInto = 0
+	stepOver = 1
+	stepOut  = 2
+)
+
+// eventRequest is an event that the debugger asked for, with its modifiers
+type eventRequest struct {
+	id           int32
+	kind         byte
+	policy       byte
+	count        int32 // if > 0, the event occurs on the count'th time, and the request then expires
+	thread       int64 // if != 0, only on this thread
+	classOnly    int64 // if != 0, only for this class
+	classMatch   []string
+	classExclude []string
+	location     *Location
+	step         *step
+}
+
+// step is a step in progress: where it started, and how far it goes
+type step struct {
+	thread      int64
+	size, depth int32
+	frames      int // the depth of the stack where the step started
+	start       Location
+	line        int32
+}
+
+// event is an event that occurred
+type event struct {
+	kind     byte
+	request  int32
+	thread   int64
+	class    Class
+	location Location
+}
+
+func (req *eventRequest) threadMatches(thread int64) bool {
+	return req.thread == 0 || req.thread == thread
+}
+
+func (req *eventRequest) classMatches(class Class) bool {
+	if req.classOnly != 0 && req.classOnly != class.ID {
+		return false
+	}
+	name := className(class.Signature)
+	for _, pattern := range req.classMatch {
+		if !classPatternMatches(pattern, name) {
+			return false
+		}
+	}
+	for _, pattern := range req.classExclude {
+		if classPatternMatches(pattern, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// className returns the Java name of the class with the signature, as in java.lang.String
+func className(signature string) string {
+	if strings.HasPrefix(signature, "L") && strings.HasSuffix(signature, ";") {
+		signature = signature[1 : len(signature)-1]
+	}
+	return strings.ReplaceAll(signature, "/", ".")
+}
+
+// classPatternMatches reports whether the class name matches a pattern, which is a
+// class name that may begin or end with *
+func classPatternMatches(pattern, name string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*"):
+		return strings.HasSuffix(name, pattern[1:])
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(name, pattern[:len(pattern)-1])
+	default:
+		return pattern == name
+	}
+}
+
+// matching returns the requests for the kind of event that the filter accepts, counting
+// down the requests that have counts. Those whose count reaches 0 occur and expire.
+func (s *Server) matching(kind byte, filter func(*eventRequest) bool) []*eventRequest {
+	var matched []*eventRequest
+	kept := s.requests[:0]
+	for _, req := range s.requests {
+		if req.kind != kind || !filter(req) {
+			kept = append(kept, req)
+			continue
+		}
+		if req.count > 0 {
+			req.count--
+			if req.count > 0 {
+				kept = append(kept, req)
+				continue
+			}
+			matched = append(matched, req) // and it expires
+			continue
+		}
+		matched = append(matched, req)
+		kept = append(kept, req)
+	}
+	s.requests = kept
+	return matched
+}
+
+// reportLocation reports the breakpoints at the location and the steps that end there
+func (s *Server) reportLocation(thread int64, loc Location) {
+	if s.conn == nil {
+		return
+	}
+	var events []event
+	policy := byte(suspendNone)
+	for _, req := range s.matching(eventBreakpoint, func(req *eventRequest) bool {
+		return req.location != nil && *req.location == loc && req.threadMatches(thread)
+	}) {
+		events = append(events, event{kind: eventBreakpoint, request: req.id, thread: thread, location: loc})
+		policy = maxPolicy(policy, req.policy)
+	}
+	for _, req := range s.matching(eventSingleStep, func(req *eventRequest) bool {
+		return req.step != nil && req.step.thread == thread && s.stepEnds(req.step, loc)
+	}) {
+		events = append(events, event{kind: eventSingleStep, request: req.id, thread: thread, location: loc})
+		policy = maxPolicy(policy, req.policy)
+		s.startStep(req.step, thread) // the next step starts from here
+	}
+	s.report(thread, policy, events)
+}
+
+// startStep notes where a step starts from: the thread's current frame
+func (s *Server) startStep(st *step, thread int64) error {
+	frames, err := s.vm.Frames(thread)
+	if err != nil {
+		return err
+	}
+	if len(frames) == 0 {
+		return ErrInvalidThread
+	}
+	st.frames = len(frames)
+	st.start = frames[0].Location
+	st.line, _ = s.line(st.start)
+	return nil
+}
+
+// stepEnds reports whether a step ends at the location, the next instruction of its
+// thread. Going into a called method ends a step into; returning to the caller ends any
+// step; and otherwise moving to another instruction or to the start of another line ends
+// a step into or over, depending on the step size.
+func (s *Server) stepEnds(st *step, loc Location) bool {
+	frames, err := s.vm.Frames(st.thread)
+	if err != nil {
+		return false
+	}
+	depth := len(frames)
+	switch {
+	case depth < st.frames:
+		return true
+	case st.depth == stepOut:
+		return false
+	case depth > st.frames:
+		return st.depth == stepInto
+	case loc.Class != st.start.Class || loc.Method != st.start.Method:
+		return true
+	case st.size == stepMin:
+		return loc.Index != st.start.Index
+	}
+	line, lineStart := s.line(loc)
+	if !lineStart {
+		return false
+	}
+	return line != st.line || loc.Index <= st.start.Index // a new line, or a loop back to this one
+}
+
+// line returns the source line of a location, and whether the location is where the
+// line's code starts. It's -1 if the method has no line numbers.
+func (s *Server) line(loc Location) (int32, bool) {
+	key := [2]int64{loc.Class, loc.Method}
+	table, present := s.lineTables[key]
+	if !present {
+		table, _ = s.vm.LineTable(loc.Class, loc.Method)
+		s.lineTables[key] = table
+	}
+	line, best := int32(-1), int64(-1)
+	for _, entry := range table.Lines {
+		if entry.Index <= loc.Index && entry.Index > best {
+			line, best = entry.Number, entry.Index
+		}
+	}
+	return line, best == loc.Index
+}
+
+func maxPolicy(a, b byte) byte {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// report sends the events that occurred on the thread, and suspends as the policy says.
+// The thread then waits in waitWhileSuspended().
+func (s *Server) report(thread int64, policy byte, events []event) {
+	if len(events) == 0 {
+		return
+	}
+	switch policy {
+	case suspendAll:
+		s.suspendAll()
+	case suspendEventThread:
+		s.suspend(thread)
+	}
+	s.sendEvents(policy, events)
+}
+
+// sendEvents sends a composite event to the debugger
+func (s *Server) sendEvents(policy byte, events []event) {
+	if s.conn == nil {
+		return
+	}
+	w := &writer{}
+	w.u1(policy)
+	w.u4(int32(len(events)))
+	for _, e := range events {
+		w.u1(e.kind)
+		w.u4(e.request)
+		switch e.kind {
+		case eventVMStart, eventThreadStart, eventThreadDeath:
+			w.id(e.thread)
+		case eventBreakpoint, eventSingleStep:
+			w.id(e.thread)
+			w.location(e.location)
+		case eventClassPrepare:
+			w.id(e.thread)
+			w.u1(e.class.Tag)
+			w.id(e.class.ID)
+			w.str(e.class.Signature)
+			w.u4(e.class.Status)
+		}
+	}
+	id := atomic.AddUint32(&s.nextEventID, 1) | 1<<31 // distinct from the debugger's IDs
+	s.write(s.conn, &packet{id: id, commandSet: 64, command: 100, data: w.b})
+}
@@ -0,0 +1,293 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+// Package jdwp implements the Java Debug Wire Protocol, so that debuggers such as
+// IntelliJ IDEA, VS Code, Eclipse, and jdb can attach to Jacobin. It's enabled as in
+// the JDK, with -agentlib:jdwp=transport=dt_socket,server=y,suspend=n,address=5005.
+// See https://docs.oracle.com/javase/specs/jpda/
+//
+// The package handles the connection, the packets, the event requests, and thread
+// suspension. It sees the program through the VM interface, which the interpreter
+// implements, and the interpreter calls Safepoint() before each instruction while a
+// debugger needs to see them. That's where breakpoints and steps are detected and where
+// suspended threads wait to be resumed. A thread running native code is also stopped as
+// far as the debugger is concerned, since its Java frames can't change, and it waits at
+// its next safepoint if it's been suspended in the meantime.
+//
+// What's supported: the VirtualMachine, ReferenceType, ClassType, Method,
+// ObjectReference, StringReference, ThreadReference, ThreadGroupReference,
+// EventRequest, and StackFrame commands that debuggers use to set breakpoints, step,
+// and inspect threads, frames, and local variables; and the VM start and death,
+// thread start and death, class prepare, breakpoint, and single-step events. Requests
+// for other events (exceptions, method entry, field watches, etc.) are accepted but
+// the events never occur. Commands that aren't supported, such as redefining classes,
+// reply with NOT_IMPLEMENTED.
+package jdwp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Options are the options of -agentlib:jdwp
+type Options struct {
+	Transport string // only dt_socket is supported
+	Server    bool   // listen for the debugger (y) or attach to it (n)
+	Suspend   bool   // wait for the debugger before running the program
+	Address   string // [host:]port
+}
+
+// ParseOptions parses the comma-separated options of -agentlib:jdwp, such as
+// transport=dt_socket,server=y,suspend=n,address=*:5005. As in the JDK, suspend is y
+// and server is n unless they're given.
+func ParseOptions(options string) (Options, error) {
+	opts := Options{Suspend: true}
+	for _, option := range strings.Split(options, ",") {
+		if option == "" {
+			continue
+		}
+		eq := strings.Index(option, "=")
+		if eq < 0 {
+			return opts, fmt.Errorf("%s must have the form name=value", option)
+		}
+		name, value := option[:eq], option[eq+1:]
+		switch name {
+		case "transport":
+			opts.Transport = value
+		case "address":
+			opts.Address = value
+		case "server", "suspend":
+			if value != "y" && value != "n" {
+				return opts, fmt.Errorf("%s must be y or n", name)
+			}
+			if name == "server" {
+				opts.Server = value == "y"
+			} else {
+				opts.Suspend = value == "y"
+			}
+		case "quiet", "timeout", "onthrow", "onuncaught", "launch", "strict", "includevirtualthreads":
+			// accepted, as the JDK does, but they don't affect anything here
+		default:
+			return opts, fmt.Errorf("%s is not a jdwp option", name)
+		}
+	}
+
+	if opts.Transport == "" {
+		return opts, errors.New("transport must be specified")
+	}
+	if opts.Transport != "dt_socket" {
+		return opts, fmt.Errorf("transport %s is not supported; use dt_socket", opts.Transport)
+	}
+	if opts.Address == "" && !opts.Server {
+		return opts, errors.New("address must be specified when server=n")
+	}
+	if opts.Address != "" {
+		port := opts.Address[strings.LastIndex(opts.Address, ":")+1:]
+		if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+			return opts, fmt.Errorf("%s is not a valid address", opts.Address)
+		}
+	}
+	return opts, nil
+}
+
+// networkAddress returns the address to listen on or to dial. As in the JDK, a port
+// alone means localhost, and * means every interface.
+func (opts Options) networkAddress() string {
+	address := opts.Address
+	if address == "" {
+		return "localhost:0" // a port of the system's choosing
+	}
+	if !strings.Contains(address, ":") {
+		return "localhost:" + address
+	}
+	return strings.TrimPrefix(address, "*")
+}
+
+// ---- what the protocol says about the program ----
+
+// Location is a position in the bytecode of a method
+type Location struct {
+	Tag    byte  // TagClass or TagInterface
+	Class  int64 // the ID of the class
+	Method int64 // the ID of the method
+	Index  int64 // the index of the instruction in the bytecode
+}
+
+// Thread describes a live thread
+type Thread struct {
+	ID     int64
+	Name   string
+	Status int32 // ThreadRunning, ThreadSleeping, etc.
+}
+
+// Class describes a loaded class or interface
+type Class struct {
+	ID        int64
+	Tag       byte   // TagClass, TagInterface, or TagArray
+	Signature string // the type descriptor, as in Ljava/lang/String;
+	Modifiers int32  // the access flags
+	Status    int32  // ClassVerified, etc.
+}
+
+// Method describes a method of a class. Method IDs are only unique within a class.
+type Method struct {
+	ID        int64
+	Name      string
+	Signature string // the method descriptor
+	Modifiers int32  // the access flags
+}
+
+// Field describes a field of a class. Field IDs are only unique within a class.
+type Field struct {
+	ID        int64
+	Name      string
+	Signature string
+	Modifiers int32
+}
+
+// Frame is a frame of a thread's stack. Frame IDs are only unique within a thread,
+// and are valid only while the thread is suspended.
+type Frame struct {
+	ID       int64
+	Location Location
+}
+
+// Line is an entry of a method's line table: the source line that starts at Index
+type Line struct {
+	Index  int64
+	Number int32
+}
+
+// LineTable is a method's range of bytecode indexes and its line number table
+type LineTable struct {
+	Start, End int64
+	Lines      []Line
+}
+
+// Variable is a local variable of a method, which is in its slot for Length bytes of
+// bytecode from Start
+type Variable struct {
+	Start     int64
+	Name      string
+	Signature string
+	Length    int32
+	Slot      int32
+}
+
+// VariableTable is a method's local variables and the number of slots taken by its
+// arguments (including this)
+type VariableTable struct {
+	ArgCount  int32
+	Variables []Variable
+}
+
+// Slot is the index and type (the first character of its descriptor) of a local variable
+type Slot struct {
+	Index int32
+	Tag   byte
+}
+
+// Value is a tagged value. Floats and doubles are their IEEE 754 bits, and objects are
+// their IDs.
+type Value struct {
+	Tag   byte
+	Value int64
+}
+
+// VM is the debugger's view of the program. IDs of classes, objects, and threads are
+// chosen by the VM, and are all distinct. 0 is null, and ThreadGroupID is reserved.
+// Methods that return an error return an ErrorCode when the error is one the protocol
+// defines, such as ErrInvalidThread.
+type VM interface {
+	Name() (name, version string)
+	Threads() []Thread
+	Frames(thread int64) ([]Frame, error) // the thread's Java frames, most recent first
+	Classes() []Class
+	Class(id int64) (Class, error)
+	Superclass(class int64) (int64, error)
+	Interfaces(class int64) ([]int64, error)
+	Methods(class int64) ([]Method, error)
+	Fields(class int64) ([]Field, error)
+	SourceFile(class int64) (string, error)
+	LineTable(class, method int64) (LineTable, error)
+	VariableTable(class, method int64) (VariableTable, error)
+	Bytecodes(class, method int64) ([]byte, error)
+	FrameValues(thread, frame int64, slots []Slot) ([]Value, error)
+	SetFrameValues(thread, frame int64, slots []int32, values []Value) error
+	ThisObject(thread, frame int64) (Value, error)
+	ObjectClass(object int64) (Class, error)
+	StringValue(object int64) (string, error)
+	CreateString(s string) int64
+	ClassPaths() (baseDir string, classPath []string)
+	Exit(status int)
+}
+
+// ThreadGroupID is the ID of the one thread group, to which every thread belongs
+const ThreadGroupID = int64(1) << 62
+
+// type tags
+const (
+	TagClass     = 1
+	TagInterface = 2
+	TagArray     = 3
+)
+
+// thread statuses
+const (
+	ThreadZombie   = 0
+	ThreadRunning  = 1
+	ThreadSleeping = 2
+	ThreadMonitor  = 3
+	ThreadWait     = 4
+)
+
+// class statuses
+const (
+	ClassVerified    = 1
+	ClassPrepared    = 2
+	ClassInitialized = 4
+	ClassError       = 8
+)
+
+// ErrorCode is an error that the protocol defines
+type ErrorCode uint16
+
+const (
+	ErrInvalidThread      ErrorCode = 10
+	ErrInvalidThreadGroup ErrorCode = 11
+	ErrThreadNotSuspended ErrorCode = 13
+	ErrInvalidObject      ErrorCode = 20
+	ErrInvalidClass       ErrorCode = 21
+	ErrInvalidMethodID    ErrorCode = 23
+	ErrInvalidLocation    ErrorCode = 24
+	ErrInvalidFieldID     ErrorCode = 25
+	ErrInvalidFrameID     ErrorCode = 30
+	ErrTypeMismatch       ErrorCode = 34
+	ErrInvalidSlot        ErrorCode = 35
+	ErrNotImplemented     ErrorCode = 99
+	ErrAbsentInformation  ErrorCode = 101
+	ErrInvalidEventType   ErrorCode = 102
+	ErrIllegalArgument    ErrorCode = 103
+	ErrVMDead             ErrorCode = 112
+	ErrInternal           ErrorCode = 113
+	ErrInvalidLength      ErrorCode = 504
+	ErrInvalidString      ErrorCode = 506
+)
+
+func (e ErrorCode) Error() string {
+	return "JDWP error " + strconv.Itoa(int(e))
+}
+
+// errorCodeOf returns the protocol's code for the error
+func errorCodeOf(err error) ErrorCode {
+	var code ErrorCode
+	if errors.As(err, &code) {
+		return code
+	}
+	return ErrInternal
+}
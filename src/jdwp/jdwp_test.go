@@ -0,0 +1,130 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jdwp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	opts, err := ParseOptions("transport=dt_socket,server=y,suspend=n,address=*:5005")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	want := Options{Transport: "dt_socket", Server: true, Suspend: false, Address: "*:5005"}
+	if opts != want {
+		t.Errorf("expected %+v, got %+v", want, opts)
+	}
+	if opts.networkAddress() != ":5005" {
+		t.Errorf("expected * to listen on every interface, got %s", opts.networkAddress())
+	}
+
+	opts, err = ParseOptions("transport=dt_socket,server=y")
+	if err != nil || !opts.Suspend || opts.networkAddress() != "localhost:0" {
+		t.Errorf("expected suspend=y and an address of the system's choosing, got %+v, %v", opts, err)
+	}
+	opts, _ = ParseOptions("transport=dt_socket,server=y,address=8000")
+	if opts.networkAddress() != "localhost:8000" {
+		t.Errorf("expected a port alone to mean localhost, got %s", opts.networkAddress())
+	}
+}
+
+func TestParseOptionsErrors(t *testing.T) {
+	for _, options := range []string{
+		"server=y",                                  // no transport
+		"transport=dt_shmem,server=y",               // unsupported transport
+		"transport=dt_socket",                       // server=n with no address
+		"transport=dt_socket,server=maybe",          // not y or n
+		"transport=dt_socket,server=y,address=port", // not a port
+		"transport=dt_socket,server=y,verbose=y",    // not an option
+		"transport=dt_socket,server",                // not name=value
+	} {
+		if _, err := ParseOptions(options); err == nil {
+			t.Errorf("expected an error for %s", options)
+		}
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	w := &writer{}
+	w.u1(7)
+	w.boolean(true)
+	w.u2(0xBEEF)
+	w.u4(-2)
+	w.u8(1 << 40)
+	w.str("héllo")
+	w.location(Location{Tag: TagClass, Class: 3, Method: 4, Index: 5})
+	w.value(Value{Tag: 'S', Value: -3})
+	w.value(Value{Tag: 'D', Value: 1 << 62})
+
+	p := &packet{id: 42, commandSet: 15, command: 1, data: w.b}
+	read, err := readPacket(bytes.NewReader(p.bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if read.id != 42 || read.commandSet != 15 || read.command != 1 || read.flags != 0 {
+		t.Errorf("unexpected header: %+v", read)
+	}
+
+	r := &reader{b: read.data}
+	if r.u1() != 7 || !r.boolean() || r.u2() != 0xBEEF || r.u4() != -2 || r.u8() != 1<<40 ||
+		r.str() != "héllo" {
+		t.Error("unexpected values")
+	}
+	if loc := r.location(); loc != (Location{Tag: TagClass, Class: 3, Method: 4, Index: 5}) {
+		t.Errorf("unexpected location: %+v", loc)
+	}
+	if v := r.value(); v != (Value{Tag: 'S', Value: -3}) {
+		t.Errorf("unexpected value: %+v", v)
+	}
+	if v := r.value(); v != (Value{Tag: 'D', Value: 1 << 62}) {
+		t.Errorf("unexpected value: %+v", v)
+	}
+	if r.err != nil || len(r.b) != 0 {
+		t.Errorf("expected the whole packet to be read, got %v", r.err)
+	}
+	r.u4()
+	if r.err != errShortPacket {
+		t.Error("expected reading past the end to be an error")
+	}
+}
+
+func TestReplyPacket(t *testing.T) {
+	p := &packet{id: 9, flags: flagReply, errorCode: ErrInvalidThread}
+	read, err := readPacket(bytes.NewReader(p.bytes()))
+	if err != nil || read.errorCode != ErrInvalidThread || read.flags != flagReply {
+		t.Errorf("unexpected reply: %+v, %v", read, err)
+	}
+
+	bad := p.bytes()
+	bad[3] = 3 // a length shorter than the header
+	if _, err := readPacket(bytes.NewReader(bad)); err == nil {
+		t.Error("expected an invalid length to be an error")
+	}
+}
+
+func TestClassPatterns(t *testing.T) {
+	for _, test := range []struct {
+		pattern, name string
+		matches       bool
+	}{
+		{"*", "a.B", true},
+		{"java.*", "java.lang.String", true},
+		{"java.*", "javax.swing.JFrame", false},
+		{"*.Main", "com.example.Main", true},
+		{"com.example.Main", "com.example.Main", true},
+		{"com.example.Main", "com.example.Main2", false},
+	} {
+		if classPatternMatches(test.pattern, test.name) != test.matches {
+			t.Errorf("pattern %s, class %s: expected %t", test.pattern, test.name, test.matches)
+		}
+	}
+	if className("Ljava/lang/String;") != "java.lang.String" {
+		t.Error("expected the signature's class name")
+	}
+}
@@ -0,0 +1,224 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jdwp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// JDWP packets. Each packet has an 11-byte header: the length of the whole packet (u4),
+// an ID (u4), and flags (u1), followed by the command set and command (u1 each) of a
+// command, or the error code (u2) of a reply. All values are big-endian, and all IDs
+// here are 8 bytes. Strings are a u4 length followed by that many bytes of UTF-8.
+
+const (
+	headerLength = 11
+	flagReply    = 0x80
+	idSize       = 8
+)
+
+const handshake = "JDWP-Handshake"
+
+// packet is a command or a reply
+type packet struct {
+	id         uint32
+	flags      byte
+	commandSet byte
+	command    byte
+	errorCode  ErrorCode
+	data       []byte
+}
+
+// maxPacketLength bounds the packets that are accepted, so that a bad length can't
+// exhaust memory
+const maxPacketLength = 16 << 20
+
+// readPacket reads the next packet
+func readPacket(r io.Reader) (*packet, error) {
+	header := make([]byte, headerLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length < headerLength || length > maxPacketLength {
+		return nil, errors.New("invalid JDWP packet length")
+	}
+	p := &packet{id: binary.BigEndian.Uint32(header[4:]), flags: header[8]}
+	if p.flags&flagReply != 0 {
+		p.errorCode = ErrorCode(binary.BigEndian.Uint16(header[9:]))
+	} else {
+		p.commandSet, p.command = header[9], header[10]
+	}
+	p.data = make([]byte, length-headerLength)
+	if _, err := io.ReadFull(r, p.data); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// bytes returns the packet in its wire format
+func (p *packet) bytes() []byte {
+	b := make([]byte, headerLength, headerLength+len(p.data))
+	binary.BigEndian.PutUint32(b, uint32(headerLength+len(p.data)))
+	binary.BigEndian.PutUint32(b[4:], p.id)
+	b[8] = p.flags
+	if p.flags&flagReply != 0 {
+		binary.BigEndian.PutUint16(b[9:], uint16(p.errorCode))
+	} else {
+		b[9], b[10] = p.commandSet, p.command
+	}
+	return append(b, p.data...)
+}
+
+// writer builds the data of a packet
+type writer struct {
+	b []byte
+}
+
+func (w *writer) u1(v byte) {
+	w.b = append(w.b, v)
+}
+
+func (w *writer) boolean(v bool) {
+	if v {
+		w.u1(1)
+	} else {
+		w.u1(0)
+	}
+}
+
+func (w *writer) u2(v uint16) {
+	w.b = append(w.b, byte(v>>8), byte(v))
+}
+
+func (w *writer) u4(v int32) {
+	w.b = append(w.b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (w *writer) u8(v int64) {
+	w.u4(int32(v >> 32))
+	w.u4(int32(v))
+}
+
+func (w *writer) id(v int64) {
+	w.u8(v)
+}
+
+func (w *writer) str(s string) {
+	w.u4(int32(len(s)))
+	w.b = append(w.b, s...)
+}
+
+func (w *writer) location(loc Location) {
+	w.u1(loc.Tag)
+	w.id(loc.Class)
+	w.id(loc.Method)
+	w.u8(loc.Index)
+}
+
+// value writes a tagged value. Its size depends on the tag.
+func (w *writer) value(v Value) {
+	w.u1(v.Tag)
+	w.untaggedValue(v)
+}
+
+func (w *writer) untaggedValue(v Value) {
+	switch v.Tag {
+	case 'V':
+	case 'Z', 'B':
+		w.u1(byte(v.Value))
+	case 'C', 'S':
+		w.u2(uint16(v.Value))
+	case 'I', 'F':
+		w.u4(int32(v.Value))
+	default: // J, D, and the object tags
+		w.u8(v.Value)
+	}
+}
+
+// reader reads the data of a packet. The first error is remembered, and reading
+// after an error returns zero values, so that commands needn't check each value.
+type reader struct {
+	b   []byte
+	err error
+}
+
+var errShortPacket = errors.New("packet too short")
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil || len(r.b) < n {
+		r.err = errShortPacket
+		return make([]byte, n)
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *reader) u1() byte {
+	return r.next(1)[0]
+}
+
+func (r *reader) boolean() bool {
+	return r.u1() != 0
+}
+
+func (r *reader) u2() uint16 {
+	return binary.BigEndian.Uint16(r.next(2))
+}
+
+func (r *reader) u4() int32 {
+	return int32(binary.BigEndian.Uint32(r.next(4)))
+}
+
+func (r *reader) u8() int64 {
+	return int64(binary.BigEndian.Uint64(r.next(8)))
+}
+
+func (r *reader) id() int64 {
+	return r.u8()
+}
+
+func (r *reader) str() string {
+	n := r.u4()
+	if n < 0 {
+		r.err = errShortPacket
+		return ""
+	}
+	return string(r.next(int(n)))
+}
+
+func (r *reader) location() Location {
+	return Location{Tag: r.u1(), Class: r.id(), Method: r.id(), Index: r.u8()}
+}
+
+func (r *reader) value() Value {
+	tag := r.u1()
+	return r.untaggedValue(tag)
+}
+
+func (r *reader) untaggedValue(tag byte) Value {
+	v := Value{Tag: tag}
+	switch tag {
+	case 'V':
+	case 'Z':
+		v.Value = int64(r.u1())
+	case 'B':
+		v.Value = int64(int8(r.u1()))
+	case 'C':
+		v.Value = int64(r.u2())
+	case 'S':
+		v.Value = int64(int16(r.u2()))
+	case 'I', 'F':
+		v.Value = int64(r.u4())
+	default:
+		v.Value = r.u8()
+	}
+	return v
+}
@@ -0,0 +1,395 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jdwp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Server is the debugging agent. There's one connection to a debugger at a time. With
+// server=y, the agent listens for a debugger, and accepts another one when the first
+// detaches; with server=n, it attaches to the debugger at the address at start-up.
+type Server struct {
+	vm       VM
+	opts     Options
+	listener net.Listener
+
+	// mutex guards the rest of the struct, and cond is signalled when threads are
+	// resumed. Commands are handled with the mutex held.
+	mutex         sync.Mutex
+	cond          *sync.Cond
+	conn          net.Conn
+	connections   int // the number of debuggers that have attached
+	requests      []*eventRequest
+	nextRequestID int32
+	threads       map[int64]*threadState
+	lineTables    map[[2]int64]LineTable
+	dead          bool
+
+	active      int32 // accessed atomically: 1 if Safepoint() has anything to do
+	writeMutex  sync.Mutex
+	nextEventID uint32
+}
+
+// threadState is what the agent knows about a thread
+type threadState struct {
+	suspendCount int
+	parked       bool // waiting at a safepoint, so its frames can be read
+	inNative     bool // running native code, so its Java frames can be read
+}
+
+// Start starts the agent: it listens for a debugger or attaches to one, as the options
+// say. It returns the address it's listening on, if it's listening.
+func Start(opts Options, vm VM) (*Server, string, error) {
+	s := &Server{vm: vm, opts: opts, threads: make(map[int64]*threadState),
+		lineTables: make(map[[2]int64]LineTable)}
+	s.cond = sync.NewCond(&s.mutex)
+	if threads := vm.Threads(); opts.Suspend && len(threads) > 0 {
+		s.thread(threads[0].ID).suspendCount++ // the main thread, until the debugger resumes it
+		s.updateActive()
+	}
+
+	if !opts.Server {
+		conn, err := net.Dial("tcp", opts.networkAddress())
+		if err != nil {
+			return nil, "", err
+		}
+		if err = s.handshake(conn, true); err != nil {
+			_ = conn.Close()
+			return nil, "", err
+		}
+		go s.serve(conn)
+		return s, "", nil
+	}
+
+	listener, err := net.Listen("tcp", opts.networkAddress())
+	if err != nil {
+		return nil, "", err
+	}
+	s.listener = listener
+	go s.acceptDebuggers()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	address := port
+	if opts.Address != "" && opts.Address != port {
+		host, _, _ := net.SplitHostPort(opts.networkAddress())
+		if host == "" {
+			host = "*"
+		}
+		address = host + ":" + port
+	}
+	return s, address, nil
+}
+
+// acceptDebuggers accepts debuggers, one after another, until the VM dies
+func (s *Server) acceptDebuggers() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return // the listener was closed
+		}
+		if s.handshake(conn, false) != nil {
+			_ = conn.Close()
+			continue
+		}
+		s.serve(conn)
+	}
+}
+
+// handshake exchanges the handshake string with the debugger. The side that opened
+// the connection sends it first.
+func (s *Server) handshake(conn net.Conn, first bool) error {
+	_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+	reply := make([]byte, len(handshake))
+	if first {
+		if _, err := conn.Write([]byte(handshake)); err != nil {
+			return err
+		}
+	}
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if string(reply) != handshake {
+		return errors.New("invalid JDWP handshake")
+	}
+	if !first {
+		if _, err := conn.Write([]byte(handshake)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serve handles the commands of a debugger until it detaches
+func (s *Server) serve(conn net.Conn) {
+	s.mutex.Lock()
+	if s.dead {
+		s.mutex.Unlock()
+		_ = conn.Close()
+		return
+	}
+	s.conn = conn
+	s.connections++
+	policy := byte(suspendNone)
+	if s.opts.Suspend && s.connections == 1 {
+		policy = suspendAll // the program is waiting for the debugger (see WaitForDebugger())
+	}
+	var main int64
+	if threads := s.vm.Threads(); len(threads) > 0 {
+		main = threads[0].ID
+	}
+	s.sendEvents(policy, []event{{kind: eventVMStart, thread: main}})
+	s.mutex.Unlock()
+
+	for {
+		p, err := readPacket(conn)
+		if err != nil {
+			break
+		}
+		if p.flags&flagReply != 0 {
+			continue // debuggers don't reply to events
+		}
+		s.mutex.Lock()
+		reply, disposed := s.handle(p)
+		s.mutex.Unlock()
+		s.write(conn, reply)
+		if disposed {
+			break
+		}
+	}
+	s.detach(conn)
+}
+
+// detach forgets the debugger: as in the JDK, its event requests are cleared and the
+// threads it suspended are resumed
+func (s *Server) detach(conn net.Conn) {
+	_ = conn.Close()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn == conn {
+		s.conn = nil
+	}
+	s.requests = nil
+	for _, ts := range s.threads {
+		ts.suspendCount = 0
+	}
+	s.updateActive()
+	s.cond.Broadcast()
+}
+
+// write sends a packet to the debugger
+func (s *Server) write(conn net.Conn, p *packet) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	_, _ = conn.Write(p.bytes())
+}
+
+// ---- the interpreter's calls ----
+
+// Active reports whether Safepoint() has anything to do. The interpreter checks it
+// before working out the location.
+func (s *Server) Active() bool {
+	return atomic.LoadInt32(&s.active) != 0
+}
+
+// Safepoint is called by the interpreter before it executes the instruction at loc on
+// the thread. It reports breakpoints and steps, and waits while the thread is suspended.
+func (s *Server) Safepoint(thread int64, loc Location) {
+	if atomic.LoadInt32(&s.active) == 0 {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.reportLocation(thread, loc)
+	s.waitWhileSuspended(thread)
+}
+
+// EnterNative is called when the thread starts running native code
+func (s *Server) EnterNative(thread int64) {
+	s.mutex.Lock()
+	s.thread(thread).inNative = true
+	s.mutex.Unlock()
+}
+
+// ExitNative is called when the native code returns. If the thread was suspended in
+// the meantime, it waits until it's resumed.
+func (s *Server) ExitNative(thread int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.thread(thread).inNative = false
+	s.waitWhileSuspended(thread)
+}
+
+// WaitForDebugger is called on the main thread before the program starts. With
+// suspend=y, the thread was suspended by Start(), so it waits until a debugger has
+// attached and resumed it.
+func (s *Server) WaitForDebugger(thread int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.waitWhileSuspended(thread)
+}
+
+// ThreadStarted is called on a thread when it starts
+func (s *Server) ThreadStarted(thread int64) {
+	s.reportThreadEvent(eventThreadStart, thread)
+}
+
+// ThreadEnded is called on a thread when it ends
+func (s *Server) ThreadEnded(thread int64) {
+	s.reportThreadEvent(eventThreadDeath, thread)
+	s.mutex.Lock()
+	delete(s.threads, thread)
+	s.mutex.Unlock()
+}
+
+func (s *Server) reportThreadEvent(kind byte, thread int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn == nil {
+		return
+	}
+	var events []event
+	policy := byte(suspendNone)
+	for _, req := range s.matching(kind, func(req *eventRequest) bool { return req.threadMatches(thread) }) {
+		events = append(events, event{kind: kind, request: req.id, thread: thread})
+		policy = maxPolicy(policy, req.policy)
+	}
+	s.report(thread, policy, events)
+	s.waitWhileSuspended(thread)
+}
+
+// ClassPrepared is called when a class has been loaded, on the thread that loaded it
+func (s *Server) ClassPrepared(thread int64, class Class) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn == nil {
+		return
+	}
+	var events []event
+	policy := byte(suspendNone)
+	for _, req := range s.matching(eventClassPrepare, func(req *eventRequest) bool {
+		return req.threadMatches(thread) && req.classMatches(class)
+	}) {
+		events = append(events, event{kind: eventClassPrepare, request: req.id, thread: thread, class: class})
+		policy = maxPolicy(policy, req.policy)
+	}
+	s.report(thread, policy, events)
+	s.waitWhileSuspended(thread)
+}
+
+// VMDeath is called when the VM exits. The debugger is told, and detached.
+func (s *Server) VMDeath() {
+	s.mutex.Lock()
+	s.dead = true
+	conn := s.conn
+	if conn != nil {
+		events := []event{{kind: eventVMDeath}}
+		for _, req := range s.matching(eventVMDeath, func(*eventRequest) bool { return true }) {
+			events = append(events, event{kind: eventVMDeath, request: req.id})
+		}
+		s.sendEvents(suspendNone, events)
+	}
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+	s.mutex.Unlock()
+	if conn != nil {
+		s.detach(conn)
+	}
+}
+
+// ---- suspension ----
+
+// thread returns the state of the thread, creating it if need be
+func (s *Server) thread(id int64) *threadState {
+	ts, present := s.threads[id]
+	if !present {
+		ts = &threadState{}
+		s.threads[id] = ts
+	}
+	return ts
+}
+
+// waitWhileSuspended blocks the calling thread while it's suspended
+func (s *Server) waitWhileSuspended(thread int64) {
+	ts := s.thread(thread)
+	for ts.suspendCount > 0 && !s.dead {
+		ts.parked = true
+		s.cond.Wait()
+	}
+	ts.parked = false
+}
+
+func (s *Server) suspend(thread int64) {
+	s.thread(thread).suspendCount++
+	s.updateActive()
+}
+
+func (s *Server) resume(thread int64) {
+	if ts := s.thread(thread); ts.suspendCount > 0 {
+		ts.suspendCount--
+	}
+	s.updateActive()
+	s.cond.Broadcast()
+}
+
+func (s *Server) suspendAll() {
+	for _, t := range s.vm.Threads() {
+		s.thread(t.ID).suspendCount++
+	}
+	s.updateActive()
+}
+
+func (s *Server) resumeAll() {
+	for _, ts := range s.threads {
+		if ts.suspendCount > 0 {
+			ts.suspendCount--
+		}
+	}
+	s.updateActive()
+	s.cond.Broadcast()
+}
+
+// awaitStopped waits briefly for a suspended thread to reach a safepoint or native code,
+// and reports whether it has, so that its frames can be read
+func (s *Server) awaitStopped(thread int64) error {
+	ts := s.thread(thread)
+	if ts.suspendCount == 0 {
+		return ErrThreadNotSuspended
+	}
+	for i := 0; i < 100 && !ts.parked && !ts.inNative; i++ {
+		s.mutex.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		s.mutex.Lock()
+	}
+	if !ts.parked && !ts.inNative {
+		return ErrThreadNotSuspended
+	}
+	return nil
+}
+
+// updateActive notes whether Safepoint() has anything to do: breakpoints or steps to
+// look for, or threads to suspend
+func (s *Server) updateActive() {
+	active := int32(0)
+	for _, req := range s.requests {
+		if req.kind == eventBreakpoint || req.kind == eventSingleStep {
+			active = 1
+		}
+	}
+	for _, ts := range s.threads {
+		if ts.suspendCount > 0 {
+			active = 1
+		}
+	}
+	atomic.StoreInt32(&s.active, active)
+}
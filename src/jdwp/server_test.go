@@ -0,0 +1,313 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jdwp
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVM is a program with one thread running one method of one class. The thread's
+// depth and position are set by the test as it "executes".
+type fakeVM struct {
+	mutex sync.Mutex
+	depth int
+	index int64
+}
+
+const (
+	fakeThread = 100
+	fakeClass  = 10
+	fakeMethod = 1
+)
+
+func (vm *fakeVM) at(depth int, index int64) {
+	vm.mutex.Lock()
+	vm.depth, vm.index = depth, index
+	vm.mutex.Unlock()
+}
+
+func (vm *fakeVM) Name() (string, string) { return "Fake VM", "1.0" }
+func (vm *fakeVM) Threads() []Thread {
+	return []Thread{{ID: fakeThread, Name: "main", Status: ThreadRunning}}
+}
+func (vm *fakeVM) Frames(thread int64) ([]Frame, error) {
+	if thread != fakeThread {
+		return nil, ErrInvalidThread
+	}
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	var frames []Frame
+	for i := vm.depth; i > 0; i-- {
+		frames = append(frames, Frame{ID: int64(i), Location: Location{Tag: TagClass, Class: fakeClass,
+			Method: fakeMethod, Index: vm.index}})
+	}
+	return frames, nil
+}
+func (vm *fakeVM) Classes() []Class {
+	c, _ := vm.Class(fakeClass)
+	return []Class{c}
+}
+func (vm *fakeVM) Class(id int64) (Class, error) {
+	if id != fakeClass {
+		return Class{}, ErrInvalidClass
+	}
+	return Class{ID: fakeClass, Tag: TagClass, Signature: "LHello;", Status: ClassVerified}, nil
+}
+func (vm *fakeVM) Superclass(int64) (int64, error)   { return 0, nil }
+func (vm *fakeVM) Interfaces(int64) ([]int64, error) { return nil, nil }
+func (vm *fakeVM) Methods(int64) ([]Method, error) {
+	return []Method{{ID: fakeMethod, Name: "main", Signature: "([Ljava/lang/String;)V", Modifiers: 9}}, nil
+}
+func (vm *fakeVM) Fields(int64) ([]Field, error)    { return nil, nil }
+func (vm *fakeVM) SourceFile(int64) (string, error) { return "Hello.java", nil }
+func (vm *fakeVM) LineTable(int64, int64) (LineTable, error) {
+	return LineTable{Start: 0, End: 11, Lines: []Line{{0, 3}, {4, 4}, {8, 5}}}, nil
+}
+func (vm *fakeVM) VariableTable(int64, int64) (VariableTable, error) {
+	return VariableTable{}, ErrAbsentInformation
+}
+func (vm *fakeVM) Bytecodes(int64, int64) ([]byte, error) { return []byte{0xB1}, nil }
+func (vm *fakeVM) FrameValues(thread, frame int64, slots []Slot) ([]Value, error) {
+	var values []Value
+	for _, slot := range slots {
+		values = append(values, Value{Tag: slot.Tag, Value: int64(slot.Index) * 10})
+	}
+	return values, nil
+}
+func (vm *fakeVM) SetFrameValues(int64, int64, []int32, []Value) error { return nil }
+func (vm *fakeVM) ThisObject(int64, int64) (Value, error)              { return Value{Tag: 'L'}, nil }
+func (vm *fakeVM) ObjectClass(int64) (Class, error)                    { return Class{}, ErrInvalidObject }
+func (vm *fakeVM) StringValue(int64) (string, error)                   { return "", ErrInvalidObject }
+func (vm *fakeVM) CreateString(string) int64                           { return 1 }
+func (vm *fakeVM) ClassPaths() (string, []string)                      { return ".", []string{"."} }
+func (vm *fakeVM) Exit(int)                                            {}
+
+// testDebugger is the debugger's end of a connection
+type testDebugger struct {
+	t      *testing.T
+	conn   net.Conn
+	nextID uint32
+}
+
+// attach starts the agent with a fake VM and attaches to it
+func attach(t *testing.T, suspend bool) (*Server, *fakeVM, *testDebugger) {
+	vm := &fakeVM{depth: 1}
+	s, address, err := Start(Options{Transport: "dt_socket", Server: true, Suspend: suspend,
+		Address: "0"}, vm)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	_, _ = conn.Write([]byte(handshake))
+	reply := make([]byte, len(handshake))
+	if _, err = io.ReadFull(conn, reply); err != nil || string(reply) != handshake {
+		t.Fatalf("expected the handshake, got %q, %v", reply, err)
+	}
+	return s, vm, &testDebugger{t: t, conn: conn}
+}
+
+// command sends a command and returns the reply
+func (d *testDebugger) command(commandSet, command byte, w *writer) *packet {
+	d.nextID++
+	if w == nil {
+		w = &writer{}
+	}
+	p := &packet{id: d.nextID, commandSet: commandSet, command: command, data: w.b}
+	_, _ = d.conn.Write(p.bytes())
+	reply, err := readPacket(d.conn)
+	if err != nil {
+		d.t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if reply.flags != flagReply || reply.id != d.nextID {
+		d.t.Fatalf("expected the reply to command %d, got %+v", d.nextID, reply)
+	}
+	return reply
+}
+
+// event reads a composite event and returns its suspend policy and the reader of its events
+func (d *testDebugger) event() (byte, int32, *reader) {
+	p, err := readPacket(d.conn)
+	if err != nil {
+		d.t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if p.commandSet != 64 || p.command != 100 {
+		d.t.Fatalf("expected an event, got %+v", p)
+	}
+	r := &reader{b: p.data}
+	return r.u1(), r.u4(), r
+}
+
+func TestVMStartAndIDSizes(t *testing.T) {
+	s, _, d := attach(t, false)
+	defer s.VMDeath()
+
+	policy, count, r := d.event()
+	if policy != suspendNone || count != 1 || r.u1() != eventVMStart || r.u4() != 0 || r.id() != fakeThread {
+		t.Error("expected a VM_START event for the main thread")
+	}
+
+	r = &reader{b: d.command(setVirtualMachine, 7, nil).data}
+	for i := 0; i < 5; i++ {
+		if r.u4() != idSize {
+			t.Errorf("expected IDs of %d bytes", idSize)
+		}
+	}
+
+	r = &reader{b: d.command(setVirtualMachine, 1, nil).data}
+	if r.str() != "Fake VM 1.0" || r.u4() != jdwpMajor {
+		t.Error("unexpected version")
+	}
+
+	w := &writer{}
+	w.str("LHello;")
+	r = &reader{b: d.command(setVirtualMachine, 2, w).data}
+	if r.u4() != 1 || r.u1() != TagClass || r.id() != fakeClass {
+		t.Error("expected the class to be found by its signature")
+	}
+
+	if reply := d.command(setVirtualMachine, 18, nil); reply.errorCode != ErrNotImplemented {
+		t.Errorf("expected RedefineClasses not to be implemented, got %d", reply.errorCode)
+	}
+	w = &writer{}
+	w.id(999)
+	if reply := d.command(setThreadReference, 1, w); reply.errorCode != ErrInvalidThread {
+		t.Errorf("expected an invalid thread, got %d", reply.errorCode)
+	}
+}
+
+func TestBreakpointAndStep(t *testing.T) {
+	s, vm, d := attach(t, false)
+	defer s.VMDeath()
+	d.event() // VM_START
+
+	// a breakpoint at index 4 of the method, suspending the thread
+	w := &writer{}
+	w.u1(eventBreakpoint)
+	w.u1(suspendEventThread)
+	w.u4(1)
+	w.u1(modLocationOnly)
+	w.location(Location{Tag: TagClass, Class: fakeClass, Method: fakeMethod, Index: 4})
+	breakpoint := (&reader{b: d.command(setEventRequest, 1, w).data}).u4()
+
+	// the thread runs instructions 0 to 9
+	done := make(chan int64)
+	go func() {
+		for _, index := range []int64{0, 2, 4, 6, 8, 9} {
+			vm.at(1, index)
+			s.Safepoint(fakeThread, Location{Tag: TagClass, Class: fakeClass, Method: fakeMethod, Index: index})
+		}
+		close(done)
+	}()
+
+	policy, count, r := d.event()
+	if policy != suspendEventThread || count != 1 || r.u1() != eventBreakpoint || r.u4() != breakpoint ||
+		r.id() != fakeThread || r.location().Index != 4 {
+		t.Fatal("expected the breakpoint")
+	}
+
+	w = &writer{}
+	w.id(fakeThread)
+	if (&reader{b: d.command(setThreadReference, 12, w).data}).u4() != 1 {
+		t.Error("expected the thread to be suspended once")
+	}
+	w = &writer{}
+	w.id(fakeThread)
+	w.u4(0)
+	w.u4(-1)
+	r = &reader{b: d.command(setThreadReference, 6, w).data}
+	if r.u4() != 1 || r.id() != 1 || r.location().Index != 4 {
+		t.Error("expected the frame at the breakpoint")
+	}
+
+	// stepping over line 4 ends at the start of line 5
+	w = &writer{}
+	w.u1(eventSingleStep)
+	w.u1(suspendEventThread)
+	w.u4(2)
+	w.u1(modStep)
+	w.id(fakeThread)
+	w.u4(stepLine)
+	w.u4(stepOver)
+	w.u1(modCount)
+	w.u4(1)
+	step := (&reader{b: d.command(setEventRequest, 1, w).data}).u4()
+	d.command(setVirtualMachine, 9, nil) // Resume
+
+	_, _, r = d.event()
+	if r.u1() != eventSingleStep || r.u4() != step || r.id() != fakeThread || r.location().Index != 8 {
+		t.Fatal("expected the step to end at index 8")
+	}
+	select {
+	case <-done:
+		t.Fatal("expected the thread to be suspended after the step")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the step request has expired, so the thread runs to the end once it's resumed
+	w = &writer{}
+	w.id(fakeThread)
+	d.command(setThreadReference, 3, w)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the thread to be resumed")
+	}
+}
+
+func TestSuspendUntilResumed(t *testing.T) {
+	s, _, d := attach(t, true)
+	defer s.VMDeath()
+
+	resumed := make(chan bool)
+	go func() {
+		s.WaitForDebugger(fakeThread)
+		close(resumed)
+	}()
+
+	policy, _, _ := d.event()
+	if policy != suspendAll {
+		t.Errorf("expected VM_START to say the VM is suspended, got %d", policy)
+	}
+	select {
+	case <-resumed:
+		t.Fatal("expected the program to wait for the debugger")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	d.command(setVirtualMachine, 9, nil) // Resume
+	select {
+	case <-resumed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the program to be resumed")
+	}
+}
+
+func TestDetachResumes(t *testing.T) {
+	s, _, d := attach(t, true)
+	defer s.VMDeath()
+	d.event()
+
+	resumed := make(chan bool)
+	go func() {
+		s.WaitForDebugger(fakeThread)
+		close(resumed)
+	}()
+	d.command(setVirtualMachine, 6, nil) // Dispose
+	select {
+	case <-resumed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the program to be resumed when the debugger detaches")
+	}
+}
@@ -0,0 +1,258 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jfr
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The format of a recording. It begins with a header:
+//     magic            the bytes of "JACOBIN-JFR"
+//     version          u2, big-endian
+//     start time       8 bytes, big-endian: the Unix time in nanoseconds
+// which is followed by the events. Each event is its kind (one byte), the time since
+// the start in nanoseconds (a uvarint), and the thread ID (a varint), followed by the
+// fields of the kind of event, in the order they're listed below. A string is its
+// length in bytes (a uvarint) followed by the bytes of its UTF-8, an int64 is a varint,
+// a duration or count is a uvarint, and a bool is one byte.
+//     ClassLoad          class, loader
+//     MethodLink         method, native
+//     MonitorContention  class, object, owner, duration
+//     Exception          exception, method
+//     AllocationSample   class, weight
+
+const (
+	magic   = "JACOBIN-JFR"
+	version = 1
+)
+
+// EventKind is the kind of an event
+type EventKind byte
+
+const (
+	ClassLoad EventKind = iota + 1
+	MethodLink
+	MonitorContention
+	Exception
+	AllocationSample
+)
+
+var eventKindNames = map[EventKind]string{
+	ClassLoad:         "jacobin.ClassLoad",
+	MethodLink:        "jacobin.MethodLink",
+	MonitorContention: "jacobin.MonitorContention",
+	Exception:         "jacobin.Exception",
+	AllocationSample:  "jacobin.AllocationSample",
+}
+
+func (k EventKind) String() string {
+	if name, present := eventKindNames[k]; present {
+		return name
+	}
+	return fmt.Sprintf("unknown event kind %d", byte(k))
+}
+
+// MarshalText names the kind in the JSON of an event
+func (k EventKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Event is a recorded event. Only the fields of its kind are used.
+type Event struct {
+	Kind      EventKind     `json:"type"`
+	Time      time.Duration `json:"timeNanos"` // since the recording started
+	Thread    int           `json:"thread"`    // -1 if it's not a Java thread
+	Class     string        `json:"class,omitempty"`
+	Loader    string        `json:"loader,omitempty"`
+	Method    string        `json:"method,omitempty"`
+	Native    bool          `json:"native,omitempty"`
+	Object    int64         `json:"object,omitempty"`
+	Owner     int           `json:"owner,omitempty"`
+	Duration  time.Duration `json:"durationNanos,omitempty"`
+	Exception string        `json:"exception,omitempty"`
+	Weight    int64         `json:"weight,omitempty"` // the allocations that a sample stands for
+}
+
+// appendHeader appends the header of a recording that started at the given time
+func appendHeader(b []byte, start time.Time) []byte {
+	b = append(b, magic...)
+	b = append(b, 0, version)
+	var nanos [8]byte
+	binary.BigEndian.PutUint64(nanos[:], uint64(start.UnixNano()))
+	return append(b, nanos[:]...)
+}
+
+func appendUvarint(b []byte, n uint64) []byte {
+	var encoded [binary.MaxVarintLen64]byte
+	return append(b, encoded[:binary.PutUvarint(encoded[:], n)]...)
+}
+
+func appendVarint(b []byte, n int64) []byte {
+	var encoded [binary.MaxVarintLen64]byte
+	return append(b, encoded[:binary.PutVarint(encoded[:], n)]...)
+}
+
+// appendEvent appends the encoded event
+func appendEvent(b []byte, e Event) []byte {
+	str := func(s string) {
+		b = appendUvarint(b, uint64(len(s)))
+		b = append(b, s...)
+	}
+	b = append(b, byte(e.Kind))
+	b = appendUvarint(b, uint64(e.Time))
+	b = appendVarint(b, int64(e.Thread))
+	switch e.Kind {
+	case ClassLoad:
+		str(e.Class)
+		str(e.Loader)
+	case MethodLink:
+		str(e.Method)
+		if e.Native {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+	case MonitorContention:
+		str(e.Class)
+		b = appendVarint(b, e.Object)
+		b = appendVarint(b, int64(e.Owner))
+		b = appendUvarint(b, uint64(e.Duration))
+	case Exception:
+		str(e.Exception)
+		str(e.Method)
+	case AllocationSample:
+		str(e.Class)
+		b = appendUvarint(b, uint64(e.Weight))
+	}
+	return b
+}
+
+// Reader reads the events of a recording
+type Reader struct {
+	r     *bufio.Reader
+	Start time.Time // when the recording started
+}
+
+// NewReader reads the header of the recording and returns the reader of its events
+func NewReader(r io.Reader) (*Reader, error) {
+	header := make([]byte, len(magic)+2+8)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(magic)]) != magic {
+		return nil, errors.New("not a Jacobin flight recording")
+	}
+	if v := binary.BigEndian.Uint16(header[len(magic):]); v != version {
+		return nil, fmt.Errorf("the recording is in version %d of the format, not %d", v, version)
+	}
+	nanos := int64(binary.BigEndian.Uint64(header[len(magic)+2:]))
+	return &Reader{r: bufio.NewReader(r), Start: time.Unix(0, nanos)}, nil
+}
+
+// Next returns the next event. At the end of the recording, the error is io.EOF; if the
+// recording ends partway through an event (because the VM was killed while it was
+// being written, say), the error is io.ErrUnexpectedEOF.
+func (rd *Reader) Next() (Event, error) {
+	var e Event
+	kind, err := rd.r.ReadByte()
+	if err != nil {
+		return e, err // io.EOF at the end of the last event
+	}
+	e.Kind = EventKind(kind)
+	if _, known := eventKindNames[e.Kind]; !known {
+		return e, fmt.Errorf("unknown event kind %d", kind)
+	}
+
+	uvarint := func() uint64 {
+		if err != nil {
+			return 0
+		}
+		var n uint64
+		n, err = binary.ReadUvarint(rd.r)
+		return n
+	}
+	varint := func() int64 {
+		if err != nil {
+			return 0
+		}
+		var n int64
+		n, err = binary.ReadVarint(rd.r)
+		return n
+	}
+	str := func() string {
+		n := uvarint()
+		if err != nil {
+			return ""
+		}
+		s := make([]byte, n)
+		_, err = io.ReadFull(rd.r, s)
+		return string(s)
+	}
+
+	e.Time = time.Duration(uvarint())
+	e.Thread = int(varint())
+	switch e.Kind {
+	case ClassLoad:
+		e.Class = str()
+		e.Loader = str()
+	case MethodLink:
+		e.Method = str()
+		if err == nil {
+			var native byte
+			native, err = rd.r.ReadByte()
+			e.Native = native != 0
+		}
+	case MonitorContention:
+		e.Class = str()
+		e.Object = varint()
+		e.Owner = int(varint())
+		e.Duration = time.Duration(uvarint())
+	case Exception:
+		e.Exception = str()
+		e.Method = str()
+	case AllocationSample:
+		e.Class = str()
+		e.Weight = int64(uvarint())
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return e, err
+}
+
+// Convert writes the recording as JSON: an object with the start time and an array of
+// the events. If the recording is truncated, the events before the truncated one are
+// written, as valid JSON, and the error is returned.
+func Convert(in io.Reader, out io.Writer) error {
+	rd, err := NewReader(in)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "{\n  \"startTime\": %q,\n  \"events\": [", rd.Start.Format(time.RFC3339Nano))
+	separator := "\n"
+	for {
+		var e Event
+		if e, err = rd.Next(); err != nil {
+			break
+		}
+		event, _ := json.Marshal(e)
+		fmt.Fprintf(w, "%s    %s", separator, event)
+		separator = ",\n"
+	}
+	fmt.Fprint(w, "\n  ]\n}\n")
+	if flushErr := w.Flush(); flushErr != nil {
+		return flushErr
+	}
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
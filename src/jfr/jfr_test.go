@@ -0,0 +1,146 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jfr
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOptions(t *testing.T) {
+	opts, err := ParseOptions("")
+	if err != nil || !strings.HasPrefix(opts.Filename, "jacobin-pid") || opts.AllocationInterval != 1024 {
+		t.Errorf("unexpected defaults: %+v, %v", opts, err)
+	}
+	opts, err = ParseOptions("filename=app.jfr,allocation-interval=8")
+	if err != nil || opts.Filename != "app.jfr" || opts.AllocationInterval != 8 {
+		t.Errorf("unexpected options: %+v, %v", opts, err)
+	}
+	for _, bad := range []string{"filename", "filename=", "allocation-interval=0",
+		"allocation-interval=x", "settings=profile"} {
+		if _, err = ParseOptions(bad); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+}
+
+func TestRecording(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.jfr")
+	CurrentThread = func() int { return 3 }
+	defer func() { CurrentThread = func() int { return -1 } }()
+
+	ClassLoaded("Ignored", "bootstrap") // nothing's being recorded yet
+	if err := Start(Options{Filename: filename, AllocationInterval: 2}); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if Start(Options{Filename: filename}) == nil {
+		t.Error("expected only one recording at a time")
+	}
+	ClassLoaded("Hello", "bootstrap")
+	MethodLinked("Hello.main([Ljava/lang/String;)V", false)
+	MethodLinked("java/lang/System.nanoTime()J", true)
+	MonitorContended("java/lang/Object", 42, 1, 5*time.Millisecond)
+	ExceptionThrown("java.lang.NullPointerException", "Hello.main([Ljava/lang/String;)V")
+	for i := 0; i < 5; i++ {
+		Allocated("java/lang/String")
+	}
+	if err := Stop(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	Allocated("java/lang/String") // nor after
+
+	f, _ := os.Open(filename)
+	defer f.Close()
+	rd, err := NewReader(f)
+	if err != nil || time.Since(rd.Start) > time.Minute {
+		t.Fatalf("unexpected header: %v, %v", rd, err)
+	}
+	var events []Event
+	for {
+		e, err := rd.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		if e.Thread != 3 {
+			t.Errorf("expected the events to be on thread 3, got %d", e.Thread)
+		}
+		e.Time, e.Thread = 0, 0
+		events = append(events, e)
+	}
+
+	expected := []Event{
+		{Kind: ClassLoad, Class: "Hello", Loader: "bootstrap"},
+		{Kind: MethodLink, Method: "Hello.main([Ljava/lang/String;)V"},
+		{Kind: MethodLink, Method: "java/lang/System.nanoTime()J", Native: true},
+		{Kind: MonitorContention, Class: "java/lang/Object", Object: 42, Owner: 1, Duration: 5 * time.Millisecond},
+		{Kind: Exception, Exception: "java.lang.NullPointerException", Method: "Hello.main([Ljava/lang/String;)V"},
+		{Kind: AllocationSample, Class: "java/lang/String", Weight: 2},
+		{Kind: AllocationSample, Class: "java/lang/String", Weight: 2},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %+v", len(expected), events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], events[i])
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	recording := appendHeader(nil, start)
+	recording = appendEvent(recording, Event{Kind: ClassLoad, Time: 1500, Thread: 0, Class: "Hello",
+		Loader: "bootstrap"})
+	recording = appendEvent(recording, Event{Kind: AllocationSample, Time: 2500, Thread: -1,
+		Class: "[B", Weight: 1024})
+
+	var out bytes.Buffer
+	if err := Convert(bytes.NewReader(recording), &out); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	var converted struct {
+		StartTime string
+		Events    []map[string]interface{}
+	}
+	if err := json.Unmarshal(out.Bytes(), &converted); err != nil {
+		t.Fatalf("expected JSON, got %s", out.String())
+	}
+	if !strings.HasPrefix(converted.StartTime, "2022-06-01T12:00:00") || len(converted.Events) != 2 {
+		t.Fatalf("unexpected JSON: %s", out.String())
+	}
+	if e := converted.Events[0]; e["type"] != "jacobin.ClassLoad" || e["timeNanos"] != 1500.0 ||
+		e["class"] != "Hello" || e["loader"] != "bootstrap" {
+		t.Errorf("unexpected class load event: %v", e)
+	}
+	if e := converted.Events[1]; e["type"] != "jacobin.AllocationSample" || e["thread"] != -1.0 ||
+		e["weight"] != 1024.0 {
+		t.Errorf("unexpected allocation sample: %v", e)
+	}
+
+	// a truncated recording is converted up to the truncated event
+	out.Reset()
+	err := Convert(bytes.NewReader(recording[:len(recording)-2]), &out)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected the recording to be truncated, got %v", err)
+	}
+	if json.Unmarshal(out.Bytes(), &converted) != nil || len(converted.Events) != 1 {
+		t.Errorf("expected the first event, got %s", out.String())
+	}
+
+	if Convert(strings.NewReader("not a recording"), &out) == nil {
+		t.Error("expected an error for a file that's not a recording")
+	}
+}
@@ -0,0 +1,201 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+// Package jfr records VM events to a file as they happen, in the manner of the JDK's
+// Flight Recorder. It's enabled with -XX:StartFlightRecording=<options>, where the
+// options are a comma-separated list of:
+//
+//	filename=<path>          where the recording is written (jacobin-pid<pid>.jfr
+//	                         in the current directory by default)
+//	allocation-interval=<n>  one of every n allocations is recorded (1024 by default)
+//
+// The recording is in a compact binary format of Jacobin's own (not the JDK's), which
+// jacobin jfr <recording> prints as JSON.
+//
+// The events are: classes being loaded; methods being linked into the method table
+// when they're first called (which is where a JIT would compile them or an interpreter
+// would quicken their bytecodes; Jacobin does neither yet); threads waiting to enter
+// a contended monitor; exceptions being thrown; and samples of the allocations.
+// Since exceptions can't yet be caught, an exception is recorded when it ends its
+// thread.
+//
+// When nothing's being recorded, each place an event can occur costs an atomic load.
+package jfr
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Options are the options of -XX:StartFlightRecording
+type Options struct {
+	Filename           string
+	AllocationInterval int64
+}
+
+// ParseOptions parses the comma-separated options of -XX:StartFlightRecording, such
+// as filename=app.jfr,allocation-interval=64
+func ParseOptions(options string) (Options, error) {
+	opts := Options{Filename: fmt.Sprintf("jacobin-pid%d.jfr", os.Getpid()), AllocationInterval: 1024}
+	for _, option := range strings.Split(options, ",") {
+		if option == "" {
+			continue
+		}
+		eq := strings.Index(option, "=")
+		if eq < 0 {
+			return opts, fmt.Errorf("%s must have the form name=value", option)
+		}
+		name, value := option[:eq], option[eq+1:]
+		switch name {
+		case "filename":
+			if value == "" {
+				return opts, errors.New("the filename is empty")
+			}
+			opts.Filename = value
+		case "allocation-interval":
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < 1 {
+				return opts, fmt.Errorf("the allocation interval must be a positive number, not %s", value)
+			}
+			opts.AllocationInterval = n
+		default:
+			return opts, fmt.Errorf("unknown option %s", name)
+		}
+	}
+	return opts, nil
+}
+
+// CurrentThread returns the ID of the Java thread running on the calling goroutine,
+// or -1 if there's none. The interpreter sets it.
+var CurrentThread = func() int { return -1 }
+
+// the recording. recording is 1 while events are being recorded; it's read without
+// the mutex, which guards the rest.
+var (
+	recording   int32
+	mutex       sync.Mutex
+	file        *os.File
+	out         *bufio.Writer
+	start       time.Time
+	interval    int64 // accessed only via sync/atomic
+	allocations int64 // accessed only via sync/atomic
+	buf         []byte
+	writeErr    error // the first error writing the recording
+)
+
+// Start begins recording to the file named in the options, which is replaced if it exists
+func Start(opts Options) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if file != nil {
+		return errors.New("a recording is already in progress")
+	}
+	f, err := os.Create(opts.Filename)
+	if err != nil {
+		return err
+	}
+	file, out, start, writeErr = f, bufio.NewWriter(f), time.Now(), nil
+	atomic.StoreInt64(&interval, opts.AllocationInterval)
+	if opts.AllocationInterval < 1 {
+		atomic.StoreInt64(&interval, 1)
+	}
+	atomic.StoreInt64(&allocations, 0)
+	if _, err = out.Write(appendHeader(nil, start)); err != nil {
+		_ = f.Close()
+		file, out = nil, nil
+		return err
+	}
+	atomic.StoreInt32(&recording, 1)
+	return nil
+}
+
+// Stop ends the recording and closes the file. It returns the first error that
+// occurred in writing the recording, if any.
+func Stop() error {
+	atomic.StoreInt32(&recording, 0)
+	mutex.Lock()
+	defer mutex.Unlock()
+	if file == nil {
+		return nil
+	}
+	err := writeErr
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	file, out = nil, nil
+	return err
+}
+
+// Recording returns whether events are being recorded
+func Recording() bool {
+	return atomic.LoadInt32(&recording) != 0
+}
+
+// record writes the event, with its time and thread, to the recording
+func record(e Event) {
+	e.Thread = CurrentThread()
+	mutex.Lock()
+	defer mutex.Unlock()
+	if out == nil || writeErr != nil {
+		return
+	}
+	e.Time = time.Since(start)
+	buf = appendEvent(buf[:0], e)
+	_, writeErr = out.Write(buf)
+}
+
+// ClassLoaded records that the class was loaded by the loader
+func ClassLoaded(class, loader string) {
+	if Recording() {
+		record(Event{Kind: ClassLoad, Class: class, Loader: loader})
+	}
+}
+
+// MethodLinked records that the method (whose name includes its class and descriptor)
+// was linked into the method table. A native method is linked to its Go implementation.
+func MethodLinked(method string, native bool) {
+	if Recording() {
+		record(Event{Kind: MethodLink, Method: method, Native: native})
+	}
+}
+
+// MonitorContended records that the current thread waited for the given time to enter
+// the monitor of the object, which was owned by another thread when it began to wait
+func MonitorContended(class string, object int64, owner int, waited time.Duration) {
+	if Recording() {
+		record(Event{Kind: MonitorContention, Class: class, Object: object, Owner: owner,
+			Duration: waited})
+	}
+}
+
+// ExceptionThrown records that the current thread threw the exception in the method
+func ExceptionThrown(exception, method string) {
+	if Recording() {
+		record(Event{Kind: Exception, Exception: exception, Method: method})
+	}
+}
+
+// Allocated counts an allocation of an object of the class, and records a sample of
+// one in every allocation-interval allocations
+func Allocated(class string) {
+	if !Recording() {
+		return
+	}
+	n := atomic.LoadInt64(&interval)
+	if atomic.AddInt64(&allocations, 1)%n == 0 {
+		record(Event{Kind: AllocationSample, Class: class, Weight: n})
+	}
+}
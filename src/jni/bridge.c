@@ -0,0 +1,224 @@
+//go:build cgo && (linux || darwin || freebsd)
+// +build cgo
+// +build linux darwin freebsd
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+#include <dlfcn.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include "bridge.h"
+#include "_cgo_export.h"
+
+// The JNIEnv function table. Its layout is fixed by the JNI specification: the
+// index of each function is given in the comments below. Functions that Jacobin
+// doesn't implement yet point to unsupported(), which reports the call and aborts,
+// rather than letting native code jump through a null pointer.
+
+#define jniFunctionCount 234
+
+static void *functions[jniFunctionCount];
+static void **env = functions;         // a JNIEnv is a pointer to the function table
+static void *invokeFunctions[8];
+static void **vm = invokeFunctions;    // as is a JavaVM
+
+static void unsupported(void) {
+    fprintf(stderr, "Jacobin: native code called a JNI function that is not yet supported\n");
+    abort();
+}
+
+// JNIEnv functions
+
+static jint GetVersion(void *e) { return 0x000a0000; } // JNI_VERSION_10, as in JDK 11
+
+static jobject FindClass(void *e, const char *name) {
+    return (jobject)(intptr_t) goFindClass((char *) name);
+}
+
+// The exception that a JNI function has thrown, which is pending until the native
+// method returns, when it's thrown to its caller. A native method runs on one thread
+// from start to finish, and so do the Go functions it calls, so it's thread-local.
+static __thread jlong pendingException;
+
+void setPendingException(jlong throwable) { pendingException = throwable; }
+
+jlong takePendingException(void) {
+    jlong throwable = pendingException;
+    pendingException = 0;
+    return throwable;
+}
+
+static jobject ExceptionOccurred(void *e) { return (jobject)(intptr_t) pendingException; }
+static void ExceptionClear(void *e) { pendingException = 0; }
+static jboolean ExceptionCheck(void *e) { return pendingException != 0; }
+
+// There's no garbage collector yet, so every reference is effectively global
+static jobject NewGlobalRef(void *e, jobject obj) { return obj; }
+static void DeleteGlobalRef(void *e, jobject obj) { }
+static void DeleteLocalRef(void *e, jobject obj) { }
+static jobject NewLocalRef(void *e, jobject obj) { return obj; }
+static jint EnsureLocalCapacity(void *e, jint capacity) { return 0; }
+static jboolean IsSameObject(void *e, jobject a, jobject b) { return a == b; }
+
+static jobject GetObjectClass(void *e, jobject obj) {
+    return (jobject)(intptr_t) goGetObjectClass((jlong)(intptr_t) obj);
+}
+
+static jobject NewStringUTF(void *e, const char *chars) {
+    return (jobject)(intptr_t) goNewStringUTF((char *) chars);
+}
+
+static jint GetStringLength(void *e, jobject str) {
+    return goGetStringLength((jlong)(intptr_t) str);
+}
+
+static jint GetStringUTFLength(void *e, jobject str) {
+    return goGetStringUTFLength((jlong)(intptr_t) str);
+}
+
+static const char *GetStringUTFChars(void *e, jobject str, jboolean *isCopy) {
+    if (isCopy != NULL) {
+        *isCopy = 1;
+    }
+    return goGetStringUTFChars((jlong)(intptr_t) str); // allocated with malloc()
+}
+
+static void ReleaseStringUTFChars(void *e, jobject str, const char *chars) {
+    free((void *) chars);
+}
+
+static jint GetArrayLength(void *e, jobject array) {
+    return goGetArrayLength((jlong)(intptr_t) array);
+}
+
+static jobject NewByteArray(void *e, jint length) {
+    return (jobject)(intptr_t) goNewByteArray(length);
+}
+
+static void GetByteArrayRegion(void *e, jobject array, jint start, jint len, void *buf) {
+    goGetByteArrayRegion((jlong)(intptr_t) array, start, len, buf);
+}
+
+static void SetByteArrayRegion(void *e, jobject array, jint start, jint len, const void *buf) {
+    goSetByteArrayRegion((jlong)(intptr_t) array, start, len, (void *) buf);
+}
+
+static jint RegisterNatives(void *e, jobject clazz, const nativeMethod *methods, jint count) {
+    return goRegisterNatives((jlong)(intptr_t) clazz, (void *) methods, count);
+}
+
+static jint UnregisterNatives(void *e, jobject clazz) {
+    return goUnregisterNatives((jlong)(intptr_t) clazz);
+}
+
+static jint GetJavaVM(void *e, void **result) {
+    *result = &vm;
+    return 0;
+}
+
+// JavaVM functions. Every thread shares the one JNIEnv, which is thread-safe
+// because its functions are implemented by thread-safe Go functions.
+
+static jint DestroyJavaVM(void *v) { return -1; } // JNI_ERR: the VM can't be unloaded
+static jint AttachCurrentThread(void *v, void **penv, void *args) { *penv = &env; return 0; }
+static jint DetachCurrentThread(void *v) { return 0; }
+static jint GetEnv(void *v, void **penv, jint version) { *penv = &env; return 0; }
+
+static void initTables(void) {
+    static int initialized = 0;
+    if (initialized) {
+        return;
+    }
+    for (int i = 0; i < jniFunctionCount; i++) {
+        functions[i] = (void *) unsupported;
+    }
+    functions[4] = (void *) GetVersion;
+    functions[6] = (void *) FindClass;
+    functions[15] = (void *) ExceptionOccurred;
+    functions[17] = (void *) ExceptionClear;
+    functions[21] = (void *) NewGlobalRef;
+    functions[22] = (void *) DeleteGlobalRef;
+    functions[23] = (void *) DeleteLocalRef;
+    functions[24] = (void *) IsSameObject;
+    functions[25] = (void *) NewLocalRef;
+    functions[26] = (void *) EnsureLocalCapacity;
+    functions[31] = (void *) GetObjectClass;
+    functions[164] = (void *) GetStringLength;
+    functions[167] = (void *) NewStringUTF;
+    functions[168] = (void *) GetStringUTFLength;
+    functions[169] = (void *) GetStringUTFChars;
+    functions[170] = (void *) ReleaseStringUTFChars;
+    functions[171] = (void *) GetArrayLength;
+    functions[176] = (void *) NewByteArray;
+    functions[200] = (void *) GetByteArrayRegion;
+    functions[208] = (void *) SetByteArrayRegion;
+    functions[215] = (void *) RegisterNatives;
+    functions[216] = (void *) UnregisterNatives;
+    functions[219] = (void *) GetJavaVM;
+    functions[228] = (void *) ExceptionCheck;
+
+    for (int i = 0; i < 8; i++) {
+        invokeFunctions[i] = (void *) unsupported;
+    }
+    invokeFunctions[3] = (void *) DestroyJavaVM;
+    invokeFunctions[4] = (void *) AttachCurrentThread;
+    invokeFunctions[5] = (void *) DetachCurrentThread;
+    invokeFunctions[6] = (void *) GetEnv;
+    invokeFunctions[7] = (void *) AttachCurrentThread; // AttachCurrentThreadAsDaemon
+    initialized = 1;
+}
+
+void *jniEnv(void) { initTables(); return &env; }
+void *jniVM(void) { initTables(); return &vm; }
+
+void *openLibrary(const char *path, char **err) {
+    void *handle = dlopen(path, RTLD_NOW | RTLD_GLOBAL);
+    if (handle == NULL) {
+        *err = dlerror();
+    }
+    return handle;
+}
+
+void *findSymbol(void *handle, const char *name) {
+    return dlsym(handle, name);
+}
+
+jint callOnLoad(void *onLoad) {
+    return ((jint (*)(void *, void *)) onLoad)(jniVM(), NULL);
+}
+
+// Native methods are called through a function pointer of the right arity. Every
+// argument is passed as a 64-bit integer; on the supported ABIs, a callee that
+// declares a narrower integral parameter reads the low-order bits of the same
+// register (or, past the register arguments, of the same 8-byte stack slot).
+
+#define ARGS0 jniEnv(), a[0]
+#define ARGS1 ARGS0, a[1]
+#define ARGS2 ARGS1, a[2]
+#define ARGS3 ARGS2, a[3]
+#define ARGS4 ARGS3, a[4]
+#define ARGS5 ARGS4, a[5]
+#define ARGS6 ARGS5, a[6]
+#define ARGS7 ARGS6, a[7]
+#define ARGS8 ARGS7, a[8]
+
+#define DISPATCH(T)                                                                                        \
+    switch (nargs) {                                                                                       \
+    case 1: return ((T (*)(void *, jlong)) fn)(ARGS0);                                                     \
+    case 2: return ((T (*)(void *, jlong, jlong)) fn)(ARGS1);                                              \
+    case 3: return ((T (*)(void *, jlong, jlong, jlong)) fn)(ARGS2);                                       \
+    case 4: return ((T (*)(void *, jlong, jlong, jlong, jlong)) fn)(ARGS3);                                \
+    case 5: return ((T (*)(void *, jlong, jlong, jlong, jlong, jlong)) fn)(ARGS4);                         \
+    case 6: return ((T (*)(void *, jlong, jlong, jlong, jlong, jlong, jlong)) fn)(ARGS5);                  \
+    case 7: return ((T (*)(void *, jlong, jlong, jlong, jlong, jlong, jlong, jlong)) fn)(ARGS6);           \
+    case 8: return ((T (*)(void *, jlong, jlong, jlong, jlong, jlong, jlong, jlong, jlong)) fn)(ARGS7);    \
+    default: return ((T (*)(void *, jlong, jlong, jlong, jlong, jlong, jlong, jlong, jlong, jlong)) fn)(ARGS8); \
+    }
+
+jlong callLong(void *fn, jlong *a, int nargs) { DISPATCH(jlong) }
+double callDouble(void *fn, jlong *a, int nargs) { DISPATCH(double) }
+float callFloat(void *fn, jlong *a, int nargs) { DISPATCH(float) }
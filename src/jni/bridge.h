@@ -0,0 +1,48 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+// The C side of the JNI bridge. Jacobin doesn't depend on the JDK's jni.h, so the
+// few JNI types the bridge needs are declared here. They have the same sizes and
+// layouts as the jni.h types on all the platforms that Jacobin supports.
+
+#ifndef JACOBIN_BRIDGE_H
+#define JACOBIN_BRIDGE_H
+
+#include <stdint.h>
+
+typedef int32_t jint;
+typedef int64_t jlong;
+typedef uint8_t jboolean;
+typedef void *jobject;
+
+// A JNINativeMethod, as passed to RegisterNatives()
+typedef struct {
+    char *name;
+    char *signature;
+    void *fnPtr;
+} nativeMethod;
+
+// the JNIEnv * and JavaVM * that are passed to native code
+void *jniEnv(void);
+void *jniVM(void);
+
+// library loading
+void *openLibrary(const char *path, char **err);
+void *findSymbol(void *handle, const char *name);
+jint callOnLoad(void *onLoad);
+
+// the exception thrown by a JNI function, which is pending until the native method returns
+void setPendingException(jlong throwable);
+jlong takePendingException(void);
+
+// calling native methods. The arguments are the receiver (or class) followed by
+// up to maxJNIArgs integral or reference arguments, each widened to 64 bits.
+#define maxJNIArgs 8
+jlong callLong(void *fn, jlong *args, int nargs);
+double callDouble(void *fn, jlong *args, int nargs);
+float callFloat(void *fn, jlong *args, int nargs);
+
+#endif
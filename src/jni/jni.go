@@ -0,0 +1,348 @@
+//go:build cgo && (linux || darwin || freebsd)
+// +build cgo
+// +build linux darwin freebsd
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+// Package jni lets Java programs call native methods in their own shared libraries,
+// loaded with System.loadLibrary() or System.load(), using the Java Native Interface.
+//
+// Native code is given a JNIEnv whose function table is implemented in C (bridge.c)
+// on top of Go functions exported from this file. So far, the table covers library
+// initialization (GetVersion, GetJavaVM, RegisterNatives, FindClass), references,
+// strings, byte arrays, and the checks for exceptions; the other functions abort with a
+// message when called. An exception that a JNI function throws, such as the
+// ArrayIndexOutOfBoundsException of a byte-array region that's out of bounds, is
+// pending until the native method returns, and then it's thrown to the method's caller.
+// Native methods can take and return any type except that parameters can't yet be
+// floats or doubles, and can take at most 8 parameters.
+package jni
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <stdlib.h>
+#include <string.h>
+#include "bridge.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/log"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unsafe"
+)
+
+// the JNI versions a library's JNI_OnLoad may require, as in JDK 11
+var supportedVersions = map[C.jint]bool{
+	0x00010002: true, 0x00010004: true, 0x00010006: true,
+	0x00010008: true, 0x00090000: true, 0x000a0000: true,
+}
+
+var handles []unsafe.Pointer                     // the loaded libraries, in load order
+var registered = make(map[string]unsafe.Pointer) // functions bound by RegisterNatives, by method FQN
+var mutex sync.Mutex
+
+// Install hooks the JNI bridge into the class loader's native method resolution
+func Install() {
+	classloader.LoadJNILibrary = LoadLibrary
+	classloader.ResolveJNI = Resolve
+}
+
+// LoadLibrary loads the shared library at path and calls its JNI_OnLoad function,
+// if it exports one.
+func LoadLibrary(path string) error {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	var cerr *C.char
+	handle := C.openLibrary(cpath, &cerr)
+	if handle == nil {
+		return errors.New(C.GoString(cerr))
+	}
+	mutex.Lock()
+	handles = append(handles, handle)
+	mutex.Unlock()
+
+	onLoadName := C.CString("JNI_OnLoad")
+	defer C.free(unsafe.Pointer(onLoadName))
+	if onLoad := C.findSymbol(handle, onLoadName); onLoad != nil {
+		runtime.LockOSThread()
+		version := C.callOnLoad(onLoad)
+		throwable := int64(C.takePendingException())
+		runtime.UnlockOSThread()
+		if throwable != 0 {
+			return errors.New(classloader.ThrowableString(throwable))
+		}
+		if !supportedVersions[version] {
+			return fmt.Errorf("unsupported JNI version 0x%x required by %s", int32(version), path)
+		}
+	}
+	return nil
+}
+
+// FindSymbol returns the address of the named symbol in the libraries loaded so far,
+// or 0 if none of them defines it. This is SymbolLookup.loaderLookup() in the FFM API.
+func FindSymbol(name string) uintptr {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, handle := range handles {
+		if sym := C.findSymbol(handle, cname); sym != nil {
+			return uintptr(sym)
+		}
+	}
+	return 0
+}
+
+// Resolve finds the native function for the method, first among those registered
+// by RegisterNatives() and then by its JNI symbol names in the loaded libraries,
+// and returns a GMeth that calls it.
+func Resolve(methFQN string, isStatic bool) (classloader.GMeth, bool) {
+	dot := strings.LastIndex(methFQN[:strings.Index(methFQN, "(")], ".")
+	className := methFQN[:dot]
+	methodName := methFQN[dot+1 : strings.Index(methFQN, "(")]
+	desc := methFQN[strings.Index(methFQN, "("):]
+
+	mutex.Lock()
+	fn, found := registered[methFQN]
+	if !found {
+		short, long := symbolNames(className, methodName, desc)
+		for _, handle := range handles {
+			for _, name := range []string{short, long} {
+				cname := C.CString(name)
+				fn = C.findSymbol(handle, cname)
+				C.free(unsafe.Pointer(cname))
+				if fn != nil {
+					break
+				}
+			}
+			if fn != nil {
+				break
+			}
+		}
+	}
+	mutex.Unlock()
+	if fn == nil {
+		return classloader.GMeth{}, false
+	}
+
+	gm, err := nativeMethod(fn, className, desc, isStatic)
+	if err != nil {
+		_ = log.Log("JNI method "+methFQN+": "+err.Error(), log.SEVERE)
+		return classloader.GMeth{}, false
+	}
+	_ = log.LogChannel(log.JNI, "[Dynamic-linking native method "+
+		strings.ReplaceAll(className, "/", ".")+"."+methodName+" ... JNI]")
+	return gm, true
+}
+
+// nativeMethod wraps the native function in a GMeth. The function is passed the
+// JNIEnv, then the receiver (for static methods, the Class object of the method's
+// class), then the arguments.
+func nativeMethod(fn unsafe.Pointer, className, desc string, isStatic bool) (classloader.GMeth, error) {
+	params, ret, err := classloader.ParseDescriptor(desc)
+	if err != nil {
+		return classloader.GMeth{}, err
+	}
+	if len(params) > C.maxJNIArgs {
+		return classloader.GMeth{}, fmt.Errorf("more than %d parameters are not yet supported", C.maxJNIArgs)
+	}
+	for _, p := range params {
+		if p == 'F' || p == 'D' {
+			return classloader.GMeth{}, errors.New("float and double parameters are not yet supported")
+		}
+	}
+
+	paramSlots := len(params)
+	if !isStatic {
+		paramSlots++
+	}
+	return classloader.GMeth{
+		ParamSlots: paramSlots,
+		GFunction: func(slots []interface{}) interface{} {
+			args := make([]C.jlong, 0, len(params)+1)
+			if isStatic {
+				args = append(args, C.jlong(classloader.ClassObject(className)))
+			}
+			for _, s := range slots {
+				args = append(args, C.jlong(s.(int64)))
+			}
+			nargs := C.int(len(args))
+
+			// the call and the check for a pending exception are made on the same thread
+			runtime.LockOSThread()
+			defer runtime.UnlockOSThread()
+			var r int64
+			switch ret {
+			case 'D':
+				r = classloader.SlotFromFloat(float64(C.callDouble(fn, &args[0], nargs)))
+			case 'F':
+				r = classloader.SlotFromFloat(float64(C.callFloat(fn, &args[0], nargs)))
+			default:
+				r = int64(C.callLong(fn, &args[0], nargs))
+			}
+			if throwable := int64(C.takePendingException()); throwable != 0 {
+				return errors.New(classloader.ThrowableString(throwable))
+			}
+			switch ret { // only the low-order bits of the result are defined
+			case 'V':
+				return nil
+			case 'D', 'F':
+				return r
+			case 'Z':
+				if uint8(r) != 0 {
+					return int64(1)
+				}
+				return int64(0)
+			case 'B':
+				return int64(int8(r))
+			case 'C':
+				return int64(uint16(r))
+			case 'S':
+				return int64(int16(r))
+			case 'I':
+				return int64(int32(r))
+			default: // J, and references
+				return r
+			}
+		},
+	}, nil
+}
+
+// The JNIEnv functions implemented in Go, which bridge.c calls
+
+//export goFindClass
+func goFindClass(name *C.char) C.jlong {
+	return C.jlong(classloader.ClassObject(C.GoString(name)))
+}
+
+//export goGetObjectClass
+func goGetObjectClass(obj C.jlong) C.jlong {
+	o := classloader.GetObject(int64(obj))
+	if o == nil {
+		return 0
+	}
+	return C.jlong(classloader.ClassObject(o.Klass))
+}
+
+//export goNewStringUTF
+func goNewStringUTF(chars *C.char) C.jlong {
+	if chars == nil {
+		return 0
+	}
+	b := C.GoBytes(unsafe.Pointer(chars), C.int(C.strlen(chars)))
+	return C.jlong(classloader.NewStringObject(fromModifiedUTF8(b)))
+}
+
+//export goGetStringLength
+func goGetStringLength(str C.jlong) C.jint {
+	s, _ := classloader.GoStringFromRef(int64(str))
+	return C.jint(len(utf16.Encode([]rune(s))))
+}
+
+//export goGetStringUTFLength
+func goGetStringUTFLength(str C.jlong) C.jint {
+	s, _ := classloader.GoStringFromRef(int64(str))
+	return C.jint(len(toModifiedUTF8(s)))
+}
+
+//export goGetStringUTFChars
+func goGetStringUTFChars(str C.jlong) *C.char {
+	s, _ := classloader.GoStringFromRef(int64(str))
+	return (*C.char)(C.CBytes(append(toModifiedUTF8(s), 0))) // the caller frees it
+}
+
+//export goGetArrayLength
+func goGetArrayLength(array C.jlong) C.jint {
+	if b, ok := classloader.ByteArrayFromRef(int64(array)); ok {
+		return C.jint(len(b))
+	}
+	refs, _ := classloader.RefArrayFromRef(int64(array))
+	return C.jint(len(refs))
+}
+
+//export goNewByteArray
+func goNewByteArray(length C.jint) C.jlong {
+	return C.jlong(classloader.NewByteArray(make([]byte, int(length))))
+}
+
+// byteRegion returns the part of the byte array that a Get/SetByteArrayRegion
+// call refers to. If the region is out of bounds, it returns nil and leaves an
+// ArrayIndexOutOfBoundsException pending, as does a null array.
+func byteRegion(array C.jlong, start, length C.jint) []byte {
+	b, ok := classloader.ByteArrayFromRef(int64(array))
+	switch {
+	case !ok:
+		throw("java/lang/NullPointerException", "")
+		return nil
+	case start < 0 || length < 0 || int(start)+int(length) > len(b):
+		throw("java/lang/ArrayIndexOutOfBoundsException", fmt.Sprintf(
+			"Array region %d..%d out of bounds for length %d", start, int64(start)+int64(length), len(b)))
+		return nil
+	}
+	return b[start : start+length]
+}
+
+// throw leaves an exception of the class pending, with the message unless it's empty
+func throw(className, message string) {
+	t := &classloader.Throwable{Message: message, HasMessage: message != ""}
+	C.setPendingException(C.jlong(classloader.NewThrowable(className, t)))
+}
+
+//export goGetByteArrayRegion
+func goGetByteArrayRegion(array C.jlong, start, length C.jint, buf unsafe.Pointer) {
+	if region := byteRegion(array, start, length); region != nil && length > 0 {
+		copy((*[1 << 30]byte)(buf)[:length:length], region)
+	}
+}
+
+//export goSetByteArrayRegion
+func goSetByteArrayRegion(array C.jlong, start, length C.jint, buf unsafe.Pointer) {
+	if region := byteRegion(array, start, length); region != nil && length > 0 {
+		copy(region, (*[1 << 30]byte)(buf)[:length:length])
+	}
+}
+
+//export goRegisterNatives
+func goRegisterNatives(clazz C.jlong, methods unsafe.Pointer, count C.jint) C.jint {
+	className, ok := classloader.ClassNameOf(int64(clazz))
+	if !ok || count < 0 {
+		return -1 // JNI_ERR
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, m := range (*[1 << 20]C.nativeMethod)(methods)[:count:count] {
+		methFQN := className + "." + C.GoString(m.name) + C.GoString(m.signature)
+		registered[methFQN] = m.fnPtr
+		_ = log.LogChannel(log.JNI, "[Registering JNI native method "+
+			strings.ReplaceAll(className, "/", ".")+"."+C.GoString(m.name)+"]")
+	}
+	return 0 // JNI_OK
+}
+
+//export goUnregisterNatives
+func goUnregisterNatives(clazz C.jlong) C.jint {
+	className, ok := classloader.ClassNameOf(int64(clazz))
+	if !ok {
+		return -1
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	for methFQN := range registered {
+		if strings.HasPrefix(methFQN, className+".") {
+			delete(registered, methFQN)
+		}
+	}
+	return 0
+}
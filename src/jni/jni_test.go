@@ -0,0 +1,168 @@
+//go:build cgo && (linux || darwin || freebsd)
+// +build cgo
+// +build linux darwin freebsd
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jni
+
+import (
+	"jacobin/classloader"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// A small JNI library. It declares the JNI functions it uses by their indexes in
+// the JNIEnv and JavaVM function tables, since the tests can't rely on jni.h.
+const testLibrary = `
+#include <stdint.h>
+#include <string.h>
+typedef int32_t jint;
+typedef int64_t jlong;
+typedef void *jobject;
+typedef void **JNIEnv;
+typedef void **JavaVM;
+typedef struct { char *name; char *signature; void *fnPtr; } JNINativeMethod;
+
+#define FN(env, i, T) ((T) (*(env))[i])
+
+static jint twice(JNIEnv *env, jobject cls, jint n) { return 2 * n; }
+
+jint JNI_OnLoad(JavaVM *vm, void *reserved) {
+    JNIEnv *env;
+    FN(vm, 6, jint (*)(JavaVM *, void **, jint))(vm, (void **) &env, 0x00010008);
+    jobject cls = FN(env, 6, jobject (*)(JNIEnv *, const char *))(env, "test/Native");
+    JNINativeMethod methods[] = {{"twice", "(I)I", (void *) twice}};
+    FN(env, 215, jint (*)(JNIEnv *, jobject, JNINativeMethod *, jint))(env, cls, methods, 1);
+    return 0x00010008;
+}
+
+jint Java_test_Native_add(JNIEnv *env, jobject cls, jint a, jint b) { return a + b; }
+
+jobject Java_test_Native_greet(JNIEnv *env, jobject cls, jobject name) {
+    const char *s = FN(env, 169, const char *(*)(JNIEnv *, jobject, void *))(env, name, 0);
+    char buf[100] = "hello, ";
+    strncat(buf, s, 90);
+    FN(env, 170, void (*)(JNIEnv *, jobject, const char *))(env, name, s);
+    return FN(env, 167, jobject (*)(JNIEnv *, const char *))(env, buf);
+}
+
+jint Java_test_Native_sum(JNIEnv *env, jobject this, jobject array) {
+    jint len = FN(env, 171, jint (*)(JNIEnv *, jobject))(env, array);
+    signed char buf[64];
+    FN(env, 200, void (*)(JNIEnv *, jobject, jint, jint, signed char *))(env, array, 0, len, buf);
+    jint sum = 0;
+    for (int i = 0; i < len; i++) sum += buf[i];
+    return sum;
+}
+
+jint Java_test_Native_first(JNIEnv *env, jobject this, jobject array, jint n) {
+    signed char buf[64];
+    FN(env, 200, void (*)(JNIEnv *, jobject, jint, jint, signed char *))(env, array, 0, n, buf);
+    if (FN(env, 228, unsigned char (*)(JNIEnv *))(env)) return -1; // ExceptionCheck
+    return buf[0];
+}
+
+double Java_test_Native_half__J(JNIEnv *env, jobject this, jlong n) { return n / 2.0; }
+`
+
+// buildTestLibrary compiles the test library, skipping the test if there's no C compiler
+func buildTestLibrary(t *testing.T) string {
+	cc := os.Getenv("CC")
+	if cc == "" {
+		cc = "cc"
+	}
+	if _, err := exec.LookPath(cc); err != nil {
+		t.Skip("no C compiler available to build the test library")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "native.c")
+	if err := os.WriteFile(src, []byte(testLibrary), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lib := filepath.Join(dir, classloader.MapLibraryName("native"))
+	out, err := exec.Command(cc, "-shared", "-fPIC", "-o", lib, src).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Building test library failed: %v\n%s", err, out)
+	}
+	return lib
+}
+
+func call(t *testing.T, methFQN string, isStatic bool, slots ...int64) interface{} {
+	gm, found := Resolve(methFQN, isStatic)
+	if !found {
+		t.Fatalf("Native method %s not found", methFQN)
+	}
+	if gm.ParamSlots != len(slots) {
+		t.Fatalf("%s: expected %d param slots, got %d", methFQN, len(slots), gm.ParamSlots)
+	}
+	args := make([]interface{}, len(slots))
+	for i, s := range slots {
+		args[i] = s
+	}
+	return gm.GFunction(args)
+}
+
+func TestLoadLibraryAndCallNatives(t *testing.T) {
+	lib := buildTestLibrary(t)
+	Install()
+	if err := classloader.LoadLibraryFile(lib); err != nil {
+		t.Fatalf("Loading library failed: %v", err)
+	}
+
+	if ret := call(t, "test/Native.add(II)I", true, 40, 2); ret != int64(42) {
+		t.Errorf("add: expected 42, got %v", ret)
+	}
+	if ret := call(t, "test/Native.add(II)I", true, -3, 1); ret != int64(-2) {
+		t.Errorf("add: expected -2, got %v", ret)
+	}
+
+	// registered by JNI_OnLoad rather than exported
+	if ret := call(t, "test/Native.twice(I)I", true, 21); ret != int64(42) {
+		t.Errorf("twice: expected 42, got %v", ret)
+	}
+
+	greeting := call(t, "test/Native.greet(Ljava/lang/String;)Ljava/lang/String;", true,
+		classloader.NewStringObject("café")).(int64)
+	if s, _ := classloader.GoStringFromRef(greeting); s != "hello, café" {
+		t.Errorf("greet: expected \"hello, café\", got %q", s)
+	}
+
+	this := classloader.NewObject("test/Native", 0)
+	bytes := classloader.NewByteArray([]byte{1, 2, 3, 0xFF})
+	if ret := call(t, "test/Native.sum([B)I", false, this, bytes); ret != int64(5) {
+		t.Errorf("sum: expected 5, got %v", ret)
+	}
+
+	err, _ := call(t, "test/Native.first([BI)I", false, this, bytes, 10).(error)
+	if err == nil || err.Error() !=
+		"java.lang.ArrayIndexOutOfBoundsException: Array region 0..10 out of bounds for length 4" {
+		t.Errorf("first: expected ArrayIndexOutOfBoundsException, got %v", err)
+	}
+	if ret := call(t, "test/Native.first([BI)I", false, this, bytes, 1); ret != int64(1) {
+		t.Errorf("first: expected 1 once the exception was thrown, got %v", ret)
+	}
+
+	half := call(t, "test/Native.half(J)D", false, this, 5).(int64)
+	if classloader.FloatFromSlot(half) != 2.5 {
+		t.Errorf("half: expected 2.5, got %v", classloader.FloatFromSlot(half))
+	}
+}
+
+func TestResolveUnknownMethod(t *testing.T) {
+	if _, found := Resolve("test/Native.missing()V", true); found {
+		t.Error("Expected an unexported, unregistered method not to be found")
+	}
+}
+
+func TestLoadLibraryBadPath(t *testing.T) {
+	if err := LoadLibrary(filepath.Join(t.TempDir(), "nonexistent.so")); err == nil {
+		t.Error("Expected an error loading a nonexistent library")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !cgo || !(linux || darwin || freebsd)
+// +build !cgo !linux,!darwin,!freebsd
+
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+// Package jni lets Java programs call native methods in their own shared libraries.
+// Loading native code requires cgo, so in this build JNI isn't available: the class
+// loader's defaults remain in place, and System.loadLibrary() fails with an
+// UnsatisfiedLinkError.
+package jni
+
+// Install is a no-op in builds without JNI support
+func Install() {}
+
+// FindSymbol finds nothing, since no libraries can be loaded
+func FindSymbol(name string) uintptr { return 0 }
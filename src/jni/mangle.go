@@ -0,0 +1,55 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jni
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Native methods that aren't registered with RegisterNatives() are found by the
+// names under which they're exported from the library. The JNI specification
+// defines two names for each method: the short name, Java_<class>_<method>, and the
+// long name, which appends __<argument types> to tell overloaded methods apart.
+
+// symbolNames returns the short and long JNI symbol names for the method
+func symbolNames(className, methodName, desc string) (string, string) {
+	short := "Java_" + mangle(className) + "_" + mangle(methodName)
+	args := desc
+	if end := strings.Index(desc, ")"); strings.HasPrefix(desc, "(") && end != -1 {
+		args = desc[1:end]
+	}
+	return short, short + "__" + mangle(args)
+}
+
+// mangle escapes a class name, method name, or descriptor as the JNI specification
+// requires: / becomes _, the characters _ ; and [ become _1 _2 and _3, and any other
+// character that's not an ASCII letter or digit becomes _0 followed by its UTF-16
+// code unit(s) as four lowercase hex digits.
+func mangle(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '/':
+			sb.WriteByte('_')
+		case r == '_':
+			sb.WriteString("_1")
+		case r == ';':
+			sb.WriteString("_2")
+		case r == '[':
+			sb.WriteString("_3")
+		case r < 0x80 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'):
+			sb.WriteRune(r)
+		default:
+			for _, unit := range utf16.Encode([]rune{r}) {
+				sb.WriteString(fmt.Sprintf("_0%04x", unit))
+			}
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,39 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jni
+
+import "testing"
+
+func TestMangle(t *testing.T) {
+	tests := map[string]string{
+		"com/example/Native":   "com_example_Native",
+		"do_it":                "do_1it",
+		"Ljava/lang/String;[I": "Ljava_lang_String_2_3I",
+		"café":                 "caf_000e9",
+		"a$b":                  "a_00024b",
+	}
+	for in, want := range tests {
+		if got := mangle(in); got != want {
+			t.Errorf("mangle(%q): expected %q, got %q", in, want, got)
+		}
+	}
+}
+
+func TestSymbolNames(t *testing.T) {
+	short, long := symbolNames("com/example/Native", "add", "(II)I")
+	if short != "Java_com_example_Native_add" {
+		t.Errorf("Unexpected short name: %s", short)
+	}
+	if long != "Java_com_example_Native_add__II" {
+		t.Errorf("Unexpected long name: %s", long)
+	}
+
+	_, long = symbolNames("p/Q", "f", "(Ljava/lang/String;)V")
+	if long != "Java_p_Q_f__Ljava_lang_String_2" {
+		t.Errorf("Unexpected long name for reference parameter: %s", long)
+	}
+}
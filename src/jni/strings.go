@@ -0,0 +1,59 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jni
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// JNI passes strings to and from native code in "modified UTF-8" (JVMS 4.4.7), which
+// differs from standard UTF-8 in two ways: the NUL character is encoded in two bytes
+// (0xC0 0x80), so that the encoded string contains no zero bytes, and characters
+// outside the Basic Multilingual Plane are encoded as surrogate pairs, with each
+// surrogate encoded separately in three bytes.
+
+// toModifiedUTF8 encodes a Go string in modified UTF-8
+func toModifiedUTF8(s string) []byte {
+	var out []byte
+	for _, unit := range utf16.Encode([]rune(s)) {
+		switch {
+		case unit != 0 && unit < 0x80:
+			out = append(out, byte(unit))
+		case unit < 0x800: // including NUL
+			out = append(out, byte(0xC0|unit>>6), byte(0x80|unit&0x3F))
+		default:
+			out = append(out, byte(0xE0|unit>>12), byte(0x80|(unit>>6)&0x3F), byte(0x80|unit&0x3F))
+		}
+	}
+	return out
+}
+
+// fromModifiedUTF8 decodes modified UTF-8. It also accepts standard UTF-8, which
+// native code often passes instead. Malformed bytes decode to U+FFFD.
+func fromModifiedUTF8(b []byte) string {
+	var units []uint16
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c < 0x80:
+			units = append(units, uint16(c))
+			i++
+		case c&0xE0 == 0xC0 && i+1 < len(b):
+			units = append(units, uint16(c&0x1F)<<6|uint16(b[i+1]&0x3F))
+			i += 2
+		case c&0xF0 == 0xE0 && i+2 < len(b):
+			units = append(units, uint16(c&0x0F)<<12|uint16(b[i+1]&0x3F)<<6|uint16(b[i+2]&0x3F))
+			i += 3
+		default: // four-byte standard UTF-8, or malformed
+			r, size := utf8.DecodeRune(b[i:])
+			units = append(units, utf16.Encode([]rune{r})...)
+			i += size
+		}
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,34 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jni
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestModifiedUTF8(t *testing.T) {
+	s := "a\x00é€\U0001F600"
+	enc := toModifiedUTF8(s)
+	want := []byte{'a', 0xC0, 0x80, 0xC3, 0xA9, 0xE2, 0x82, 0xAC,
+		0xED, 0xA0, 0xBD, 0xED, 0xB8, 0x80} // the emoji is a surrogate pair
+	if !bytes.Equal(enc, want) {
+		t.Errorf("Expected % x, got % x", want, enc)
+	}
+	if bytes.IndexByte(enc, 0) != -1 {
+		t.Error("Modified UTF-8 should contain no zero bytes")
+	}
+	if got := fromModifiedUTF8(enc); got != s {
+		t.Errorf("Round trip: expected %q, got %q", s, got)
+	}
+}
+
+func TestFromModifiedUTF8AcceptsStandardUTF8(t *testing.T) {
+	if got := fromModifiedUTF8([]byte("smile \U0001F600")); got != "smile \U0001F600" {
+		t.Errorf("Expected the four-byte sequence to decode, got %q", got)
+	}
+}
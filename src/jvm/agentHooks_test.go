@@ -0,0 +1,93 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"jacobin/agent"
+	"jacobin/classloader"
+	"reflect"
+	"testing"
+)
+
+// eventRecorder is an agent that records the method, exception, and thread events
+type eventRecorder struct {
+	agent.Hooks
+	events []string
+}
+
+func (r *eventRecorder) OnMethodEntry(thread int, class, method, desc string) {
+	r.events = append(r.events, "entry "+class+"."+method+desc)
+}
+
+func (r *eventRecorder) OnMethodExit(thread int, class, method, desc string, byException bool) {
+	r.events = append(r.events, fmt.Sprintf("exit %s.%s%s %v", class, method, desc, byException))
+}
+
+func (r *eventRecorder) OnExceptionThrow(thread int, exception, class, method, desc string) {
+	r.events = append(r.events, "throw "+exception+" in "+class+"."+method+desc)
+}
+
+func (r *eventRecorder) OnThreadStart(thread int, name string) {
+	r.events = append(r.events, "start "+name)
+}
+
+func (r *eventRecorder) OnThreadEnd(thread int, name string) {
+	r.events = append(r.events, "end "+name)
+}
+
+func TestAgentMethodEvents(t *testing.T) {
+	classloader.MTable = make(classloader.MT)
+	classloader.MTable[classloader.MethodKey("test/Hooked.ok()V")] = classloader.MTentry{
+		Meth:  &classloader.JmEntry{MaxStack: 1, MaxLocals: 0, Code: []byte{RETURN}},
+		MType: 'J',
+	}
+	classloader.MTable[classloader.MethodKey("test/Hooked.fail()V")] = classloader.MTentry{
+		Meth:  &classloader.JmEntry{MaxStack: 1, MaxLocals: 0, Code: []byte{ACONST_NULL, MONITORENTER, RETURN}},
+		MType: 'J',
+	}
+	r := &eventRecorder{}
+	agent.Register(r)
+	defer agent.Unregister(r)
+
+	if _, err := invokeMethod("test/Hooked", "ok", "()V", nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := invokeMethod("test/Hooked", "fail", "()V", nil); err == nil {
+		t.Fatalf("Expected fail() to throw")
+	}
+	expected := []string{
+		"entry test/Hooked.ok()V",
+		"exit test/Hooked.ok()V false",
+		"entry test/Hooked.fail()V",
+		"throw java.lang.NullPointerException in test/Hooked.fail()V",
+		"exit test/Hooked.fail()V true",
+	}
+	if !reflect.DeepEqual(r.events, expected) {
+		t.Errorf("Expected the events %v, got %v", expected, r.events)
+	}
+}
+
+func TestAgentThreadEvents(t *testing.T) {
+	r := &eventRecorder{}
+	agent.Register(r)
+	defer agent.Unregister(r)
+
+	th := CreateThread(newThreadID())
+	f := createFrame(1)
+	f.clName, f.methName, f.methType, f.ftype = "test/Hooked", "run", "()V", 'J'
+	f.thread, f.meth = th.id, []byte{RETURN}
+	th.stack.PushFront(f)
+	registerThread(&th)
+	_ = runThread(&th)
+	threadEnded(&th)
+
+	name := threadName(&th)
+	expected := []string{"start " + name, "entry test/Hooked.run()V", "exit test/Hooked.run()V false", "end " + name}
+	if !reflect.DeepEqual(r.events, expected) {
+		t.Errorf("Expected the events %v, got %v", expected, r.events)
+	}
+}
@@ -0,0 +1,139 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/messages"
+	"os"
+	"strings"
+)
+
+// Argument files: as with the java launcher, an argument of the form @filename is
+// replaced by the arguments in the file, so command lines too long for the shell
+// (typically because of long class paths) can be passed in a file. The rules are
+// the launcher's:
+//   - arguments are separated by whitespace (spaces, tabs, newlines, form feeds)
+//   - a # at the start of an argument starts a comment that runs to the end of the line
+//   - single or double quotes enclose text that can contain whitespace. Within them,
+//     a backslash escapes the next character (\n, \r, \t, and \f are the usual
+//     control characters), and a backslash at the end of a line continues the quoted
+//     text on the next line, minus the next line's leading whitespace
+//   - an argument file can't name another argument file
+//
+// An argument that starts with @@ is passed on with the first @ removed, rather than
+// expanded. Expansion stops at the main class (or after -jar and its JAR file, or -m
+// and its module), since later arguments belong to the application, and after
+// --disable-@files. The value of an option that's given in the next argument, such as
+// the class path after -cp, isn't the main class (see mainArgIndex()).
+
+// expandArgFiles returns the args with the argument files expanded
+func expandArgFiles(args []string) ([]string, error) {
+	var expanded []string
+	isValue := false // is the arg the value of the option before it?
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--disable-@files":
+			return append(expanded, args[i:]...), nil
+		case arg == "-jar" || arg == "-m" || arg == "--module":
+			return append(expanded, args[i:]...), nil
+		case strings.HasPrefix(arg, "@@"):
+			expanded = append(expanded, arg[1:])
+		case strings.HasPrefix(arg, "@") && len(arg) > 1:
+			fileArgs, err := readArgFile(arg[1:])
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, fileArgs...)
+		case !strings.HasPrefix(arg, "-") && !isValue: // the main class
+			return append(expanded, args[i:]...), nil
+		default:
+			expanded = append(expanded, arg)
+		}
+		// an argument file can end with an option whose value is the next argument
+		isValue = !isValue && len(expanded) > 0 && valueFollows(expanded[len(expanded)-1])
+	}
+	return expanded, nil
+}
+
+// readArgFile reads the arguments in the named argument file
+func readArgFile(filename string) ([]string, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0001", filename)
+		return nil, err
+	}
+	return parseArgFile(string(content))
+}
+
+// parseArgFile splits the contents of an argument file into arguments
+func parseArgFile(content string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false   // has the current argument started?
+	quote := rune(0) // the quote character, if in a quoted section
+
+	chars := []rune(content)
+	for i := 0; i < len(chars); i++ {
+		c := chars[i]
+		if quote != 0 {
+			switch {
+			case c == quote:
+				quote = 0
+			case c == '\\' && i+1 < len(chars):
+				i++
+				switch chars[i] {
+				case 'n':
+					arg.WriteRune('\n')
+				case 'r':
+					arg.WriteRune('\r')
+				case 't':
+					arg.WriteRune('\t')
+				case 'f':
+					arg.WriteRune('\f')
+				case '\r', '\n': // a continuation line: skip the line end and leading whitespace
+					if chars[i] == '\r' && i+1 < len(chars) && chars[i+1] == '\n' {
+						i++
+					}
+					for i+1 < len(chars) && strings.ContainsRune(" \t\f", chars[i+1]) {
+						i++
+					}
+				default:
+					arg.WriteRune(chars[i])
+				}
+			default:
+				arg.WriteRune(c)
+			}
+			continue
+		}
+
+		switch {
+		case strings.ContainsRune(" \t\r\n\f", c):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case c == '#' && !inArg: // a comment
+			for i+1 < len(chars) && chars[i+1] != '\n' && chars[i+1] != '\r' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, messages.Print("JACOBIN-LA-0002")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,90 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"io/ioutil"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseArgFile(t *testing.T) {
+	content := "# the class path\n" +
+		"-Dclass.path=\"lib/a b.jar:lib/c.jar\"   -verbose:class\n" +
+		"\t-Dmsg='it''s\\tfine' -Dhash=a#b # trailing comment\r\n" +
+		"-Dlong=\"first \\\n     second\"\n" +
+		"''\n"
+	args, err := parseArgFile(content)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []string{"-Dclass.path=lib/a b.jar:lib/c.jar", "-verbose:class",
+		"-Dmsg=its\tfine", "-Dhash=a#b", "-Dlong=first second", ""}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Expected %q, got %q", want, args)
+	}
+}
+
+func TestParseArgFileUnmatchedQuote(t *testing.T) {
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	_, err := parseArgFile("-Dname=\"unfinished")
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if err == nil {
+		t.Error("Expected an error for an unmatched quote")
+	}
+}
+
+func TestExpandArgFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "jacobin-argfiles")
+	defer os.RemoveAll(dir)
+	argFile := filepath.Join(dir, "opts")
+	_ = ioutil.WriteFile(argFile, []byte("-verbose:class\n-Dx=1 @nested"), 0644)
+
+	args, err := expandArgFiles([]string{"-client", "@" + argFile, "@@literal", "Main.class", "@" + argFile})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// argument files within argument files, and arguments after the main class, aren't expanded
+	want := []string{"-client", "-verbose:class", "-Dx=1", "@nested", "@literal", "Main.class", "@" + argFile}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Expected %q, got %q", want, args)
+	}
+
+	// the value of -cp isn't the main class, so expansion continues after it
+	Global = globals.InitGlobals("test")
+	LoadOptionsTable(Global)
+	args, _ = expandArgFiles([]string{"-cp", "lib", "@" + argFile, "Main.class"})
+	want = []string{"-cp", "lib", "-verbose:class", "-Dx=1", "@nested", "Main.class"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Expected %q, got %q", want, args)
+	}
+
+	args, _ = expandArgFiles([]string{"--disable-@files", "@" + argFile})
+	if !reflect.DeepEqual(args, []string{"--disable-@files", "@" + argFile}) {
+		t.Errorf("Expected no expansion after --disable-@files, got %q", args)
+	}
+}
+
+func TestExpandMissingArgFile(t *testing.T) {
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	_, err := expandArgFiles([]string{"@no-such-argfile"})
+	_ = w.Close()
+	os.Stderr = normalStderr
+	msg, _ := ioutil.ReadAll(r)
+
+	if err == nil || string(msg) != "Error: could not open `no-such-argfile'\n" {
+		t.Errorf("Expected an error for a missing argument file, got %v (%q)", err, msg)
+	}
+}
@@ -0,0 +1,305 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/messages"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// Checkpoint/restore, as with CRaC in the JDK. With -XX:CRaCCheckpointTo=<dir>, a
+// checkpoint of the program can be taken, either by the program itself, which calls
+// jdk.crac.Core.checkpointRestore(), or from outside, with jacobin cmd <pid>
+// JDK.checkpoint. The loaded classes, the heap, and the thread's stack are written to
+// <dir>/vm.snapshot, and the VM ends. Then jacobin -XX:CRaCRestoreFrom=<dir> starts a
+// VM from the snapshot, which carries on where the program left off: the call to
+// checkpointRestore() returns, or the method that was interrupted resumes. That skips
+// the loading of the classes and whatever else the program did to get there, which is
+// usually most of its startup.
+//
+// The checkpoint is taken at a safepoint: between two bytecodes, or in the call to
+// checkpointRestore(). The thread that takes it must be the only live thread, so that
+// nothing changes while it's taken, and its frames must all be Java methods (not, say, a
+// method called by Method.invoke()). Also, no object on the heap may hold state that
+// belongs to the process, such as an open file (see classloader/snapshot.go). If any
+// of this isn't so, no checkpoint is taken, and the program continues: the call to
+// checkpointRestore() throws CheckpointException, as in the JDK.
+
+// snapshotFile is the file in the checkpoint directory that holds the snapshot
+const snapshotFile = "vm.snapshot"
+
+// vmSnapshot is what's written to the snapshot file
+type vmSnapshot struct {
+	Version  string // the Jacobin version that took the checkpoint
+	State    *classloader.Snapshot
+	Thread   savedThread
+	Monitors []savedMonitor // the inflated monitors the thread owns
+}
+
+type savedThread struct {
+	ID          int
+	LastID      int32           // the last thread ID assigned
+	Frames      []savedFrame    // from main() up
+	Locals      map[int64]int64 // the ThreadLocal values
+	Inheritable map[int64]int64
+}
+
+// savedFrame is a frame of a Java method. The frame at the top is resumed at PC. The
+// others are in the middle of a call, and carry on after it when the call returns.
+type savedFrame struct {
+	Class   string
+	Method  string
+	Desc    string
+	Locals  []int64
+	OpStack []int64
+	Tos     int
+	PC      int
+}
+
+type savedMonitor struct {
+	Ref   int64
+	Owner int
+	Count int
+}
+
+// bootObjects is the number of objects the VM creates before any Java code runs (see
+// initExec()). A VM that restores a checkpoint creates them again, rather than reading them.
+var bootObjects int
+
+// checkpointRequested is set to 1 (atomically) by JDK.checkpoint. The next thread to
+// reach a safepoint takes the checkpoint.
+var checkpointRequested int32
+
+// requestCheckpoint implements JDK.checkpoint
+func requestCheckpoint(w io.Writer, gl *globals.Globals) {
+	if gl.Flags.String("CRaCCheckpointTo") == "" {
+		fmt.Fprintln(w, messages.Text("JACOBIN-LA-0056", errNoCheckpoints.Error()))
+		return
+	}
+	fmt.Fprintln(w, "Checkpoint requested")
+	atomic.StoreInt32(&checkpointRequested, 1)
+}
+
+var errNoCheckpoints = errors.New("checkpoints aren't enabled (use -XX:CRaCCheckpointTo=<dir>)")
+
+// checkpointAtSafepoint is called by the interpreter before it executes the next
+// bytecode of the thread, when a checkpoint has been requested
+func checkpointAtSafepoint(t *execThread) {
+	if !atomic.CompareAndSwapInt32(&checkpointRequested, 1, 0) {
+		return // another thread got there first
+	}
+	if err := checkpoint(t, false); err != nil {
+		_ = messages.Print("JACOBIN-LA-0056", err.Error())
+	}
+}
+
+// checkpointFromJava implements jdk.crac.Core.checkpointRestore(). It returns why the
+// checkpoint couldn't be taken, which the native throws as a CheckpointException.
+func checkpointFromJava() error {
+	threadsMutex.Lock()
+	t := threads[currentThreadID()]
+	threadsMutex.Unlock()
+	if t == nil {
+		return errors.New("the thread isn't a Java thread")
+	}
+	return checkpoint(t, true)
+}
+
+// checkpoint takes the checkpoint on the thread and ends the VM. If the checkpoint
+// can't be taken, it returns why.
+func checkpoint(t *execThread, inCall bool) error {
+	dir := Global.Flags.String("CRaCCheckpointTo")
+	if dir == "" {
+		return errNoCheckpoints
+	}
+	snap, err := takeSnapshot(t, inCall)
+	if err == nil {
+		err = writeSnapshot(dir, snap)
+	}
+	if err != nil {
+		return err
+	}
+	haltVM(exitOK) // the program carries on in the VM that restores the checkpoint
+	return nil
+}
+
+// takeSnapshot returns the snapshot of the VM, which is taken on the thread. If inCall
+// is set, the thread is in the call to checkpointRestore(), whose frame is at the top.
+func takeSnapshot(t *execThread, inCall bool) (*vmSnapshot, error) {
+	threadsMutex.Lock()
+	live := len(threads)
+	_, registered := threads[t.id]
+	threadsMutex.Unlock()
+	if !registered || live > 1 {
+		return nil, errors.New("other threads are running")
+	}
+
+	saved := savedThread{ID: t.id, LastID: atomic.LoadInt32(&lastThreadID),
+		Locals: t.threadLocals, Inheritable: t.inheritableLocals}
+	top := t.stack.Front()
+	if inCall {
+		if top == nil || top.Value.(*frame).ftype != 'G' ||
+			!strings.HasSuffix(top.Value.(*frame).methName, ".checkpointRestore()V") {
+			return nil, errors.New("checkpointRestore() wasn't called from a Java method")
+		}
+		top = top.Next()
+	}
+	for e := t.stack.Back(); e != nil; e = e.Prev() {
+		if e == t.stack.Front() && inCall {
+			break
+		}
+		f := e.Value.(*frame)
+		if f.ftype == 'G' {
+			return nil, errors.New("the thread is running " + f.methName + ", which is native")
+		}
+		sf := savedFrame{Class: f.clName, Method: f.methName, Desc: f.methType,
+			Locals: f.locals, OpStack: f.opStack, Tos: f.tos, PC: f.pc}
+		if e == top && inCall {
+			sf.PC++ // so the method carries on after the call
+		}
+		saved.Frames = append(saved.Frames, sf)
+	}
+	if len(saved.Frames) == 0 {
+		return nil, errors.New("the thread has no frames")
+	}
+
+	state, err := classloader.TakeSnapshot(bootObjects)
+	if err != nil {
+		return nil, err
+	}
+	snap := &vmSnapshot{Version: Global.Version, State: state, Thread: saved}
+	monitorsMutex.Lock()
+	for ref, m := range monitors {
+		m.mutex.Lock()
+		if m.owner >= 0 {
+			snap.Monitors = append(snap.Monitors, savedMonitor{Ref: ref, Owner: m.owner, Count: m.count})
+		}
+		m.mutex.Unlock()
+	}
+	monitorsMutex.Unlock()
+	return snap, nil
+}
+
+// writeSnapshot writes the snapshot to the checkpoint directory. It's written to a
+// temporary file first, so that a failed checkpoint doesn't replace an earlier one.
+func writeSnapshot(dir string, snap *vmSnapshot) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, snapshotFile)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(f).Encode(snap)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path + ".tmp")
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// readSnapshot reads the snapshot in the checkpoint directory
+func readSnapshot(dir string, gl *globals.Globals) (*vmSnapshot, error) {
+	f, err := os.Open(filepath.Join(dir, snapshotFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	snap := &vmSnapshot{}
+	if err = gob.NewDecoder(f).Decode(snap); err != nil {
+		return nil, err
+	}
+	if snap.Version != gl.Version {
+		return nil, errors.New("the checkpoint was taken by Jacobin " + snap.Version)
+	}
+	if snap.State == nil || len(snap.Thread.Frames) == 0 {
+		return nil, errors.New("the snapshot is incomplete")
+	}
+	return snap, nil
+}
+
+// restoreVM starts the VM from the checkpoint named by -XX:CRaCRestoreFrom and runs the
+// program's thread until it ends. It returns the exit status, as StartExec() does.
+func restoreVM(gl *globals.Globals) int {
+	dir := gl.Flags.String("CRaCRestoreFrom")
+	snap, err := readSnapshot(dir, gl)
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0057", dir, err.Error())
+		return exitUsageError
+	}
+	classloader.Init()
+	initExec(gl)
+	if err = classloader.RestoreSnapshot(snap.State); err != nil {
+		_ = messages.Print("JACOBIN-LA-0057", dir, err.Error())
+		return exitUsageError
+	}
+	for _, sm := range snap.Monitors {
+		m := getMonitor(sm.Ref)
+		m.owner, m.count = sm.Owner, sm.Count
+	}
+
+	t, err := restoredThread(snap.Thread)
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0057", dir, err.Error())
+		return exitUsageError
+	}
+	MainThread = *t
+	MainThread.trace = gl.OptionSet("-trace")
+	registerThread(&MainThread)
+	err = runThread(&MainThread)
+	threadEnded(&MainThread)
+	if err != nil {
+		return exitUncaughtException
+	}
+	return exitOK
+}
+
+// restoredThread returns the thread with the saved frames, ready to run
+func restoredThread(saved savedThread) (*execThread, error) {
+	t := CreateThread(saved.ID)
+	atomic.StoreInt32(&lastThreadID, saved.LastID)
+	if saved.Locals != nil {
+		t.threadLocals = saved.Locals
+	}
+	if saved.Inheritable != nil {
+		t.inheritableLocals = saved.Inheritable
+	}
+	for _, sf := range saved.Frames {
+		me, err := classloader.FetchMethodAndCP(sf.Class, sf.Method, sf.Desc)
+		if err != nil || me.MType != 'J' {
+			return nil, errors.New("no code for " + sf.Class + "." + sf.Method + sf.Desc)
+		}
+		m := me.Meth.(*classloader.JmEntry)
+		if len(sf.Locals) > m.MaxLocals || len(sf.OpStack) > m.MaxStack {
+			return nil, errors.New("the code of " + sf.Class + "." + sf.Method + sf.Desc + " has changed")
+		}
+		f := createFrame(m.MaxStack)
+		f.thread = t.id
+		f.clName, f.methName, f.methType = sf.Class, sf.Method, sf.Desc
+		f.cp = m.Cp
+		f.meth = append([]byte(nil), m.Code...)
+		f.method = m
+		f.locals = make([]int64, m.MaxLocals)
+		copy(f.locals, sf.Locals)
+		copy(f.opStack, sf.OpStack)
+		f.tos, f.pc = sf.Tos, sf.PC
+		_ = pushFrame(t.stack, f)
+	}
+	return &t, nil
+}
@@ -0,0 +1,151 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"io/ioutil"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCP returns a CP whose entries 1, 2, ... are Methodrefs to the methods, which are
+// named as in java/lang/Object.toString()Ljava/lang/String;
+func testCP(methods ...string) *classloader.CPool {
+	cp := &classloader.CPool{CpIndex: make([]classloader.CpEntry, 1+len(methods))}
+	add := func(e classloader.CpEntry) uint16 {
+		cp.CpIndex = append(cp.CpIndex, e)
+		return uint16(len(cp.CpIndex) - 1)
+	}
+	utf8 := func(s string) uint16 {
+		cp.Utf8Refs = append(cp.Utf8Refs, s)
+		return add(classloader.CpEntry{Type: classloader.UTF8, Slot: uint16(len(cp.Utf8Refs) - 1)})
+	}
+	for i, m := range methods {
+		paren := strings.Index(m, "(")
+		dot := strings.LastIndex(m[:paren], ".")
+		cp.ClassRefs = append(cp.ClassRefs, utf8(m[:dot]))
+		class := add(classloader.CpEntry{Type: classloader.ClassRef, Slot: uint16(len(cp.ClassRefs) - 1)})
+		cp.NameAndTypes = append(cp.NameAndTypes,
+			classloader.NameAndTypeEntry{NameIndex: utf8(m[dot+1 : paren]), DescIndex: utf8(m[paren:])})
+		nat := add(classloader.CpEntry{Type: classloader.NameAndType, Slot: uint16(len(cp.NameAndTypes) - 1)})
+		cp.MethodRefs = append(cp.MethodRefs, classloader.MethodRefEntry{ClassIndex: class, NameAndType: nat})
+		cp.CpIndex[1+i] = classloader.CpEntry{Type: classloader.MethodRef, Slot: uint16(len(cp.MethodRefs) - 1)}
+	}
+	return cp
+}
+
+// addTestMethod adds a static method with the code to the class's data
+func addTestMethod(data *classloader.ClData, name, desc string, maxStack int, code []byte) {
+	data.CP.Utf8Refs = append(data.CP.Utf8Refs, name, desc)
+	n := uint16(len(data.CP.Utf8Refs))
+	data.Methods = append(data.Methods, classloader.Method{AccessFlags: 0x0008, Name: n - 2, Desc: n - 1,
+		CodeAttr: classloader.CodeAttrib{MaxStack: maxStack, Code: code}})
+}
+
+func TestCheckpointAndRestore(t *testing.T) {
+	// main() calls run(), which takes the checkpoint with 2 on its operand stack. Then
+	// run() records 2+3, and main() records 4.
+	Global = globals.InitGlobals("test")
+	dir, _ := ioutil.TempDir("", "jacobin-crac")
+	defer os.RemoveAll(dir)
+	_ = Global.Flags.Set("CRaCCheckpointTo=" + dir)
+
+	class := "test/Restartable"
+	data := &classloader.ClData{Name: class, CP: *testCP("jdk/crac/Core.checkpointRestore()V",
+		class+".run()V", class+".record(I)V")}
+	addTestMethod(data, "main", "()V", 1, []byte{INVOKESTATIC, 0, 2, ICONST_4, INVOKESTATIC, 0, 3, RETURN})
+	addTestMethod(data, "run", "()V", 2,
+		[]byte{ICONST_2, INVOKESTATIC, 0, 1, ICONST_3, IADD, INVOKESTATIC, 0, 3, RETURN})
+	classloader.Classes[class] = classloader.Klass{Status: 'L', Loader: "app", Data: data}
+	defer delete(classloader.Classes, class)
+
+	classloader.MTable = make(classloader.MT)
+	classloader.MTableLoadNatives()
+	classloader.Checkpoint = checkpointFromJava
+	var recorded []int64
+	classloader.MTable[classloader.MethodKey(class+".record(I)V")] = classloader.MTentry{MType: 'G',
+		Meth: classloader.GmEntry{ParamSlots: 1, Fu: func(p []interface{}) interface{} {
+			recorded = append(recorded, p[0].(int64))
+			return nil
+		}}}
+	bootObjects = classloader.HeapSize()
+	exitStatus := -1
+	osExit = func(status int) { exitStatus = status }
+	defer func() { osExit = os.Exit }()
+
+	// the VM ends after the checkpoint, which here it doesn't, so main() carries on
+	saved := savedThread{ID: newThreadID(), Frames: []savedFrame{{Class: class, Method: "main", Desc: "()V", Tos: -1}}}
+	th, err := restoredThread(saved)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	registerThread(th)
+	_ = runThread(th)
+	threadEnded(th)
+	if exitStatus != exitOK {
+		t.Errorf("Expected the VM to halt after the checkpoint, got exit status %d", exitStatus)
+	}
+	if len(recorded) != 2 || recorded[0] != 5 || recorded[1] != 4 {
+		t.Errorf("Expected 5 and 4 to be recorded, got %v", recorded)
+	}
+
+	snap, err := readSnapshot(dir, &Global)
+	if err != nil {
+		t.Fatalf("Unexpected error reading the snapshot: %v", err)
+	}
+	frames := snap.Thread.Frames
+	if len(frames) != 2 || frames[0].Method != "main" || frames[1].Method != "run" {
+		t.Fatalf("Expected the frames of main() and run(), got %+v", frames)
+	}
+	if frames[1].PC != 4 || frames[1].Tos != 0 || frames[1].OpStack[0] != 2 {
+		t.Errorf("Expected run() to resume after the call with 2 on its stack, got %+v", frames[1])
+	}
+
+	// the restored thread carries on from the checkpoint
+	recorded = nil
+	th, err = restoredThread(snap.Thread)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	registerThread(th)
+	if err = runThread(th); err != nil {
+		t.Errorf("Unexpected error running the restored thread: %v", err)
+	}
+	threadEnded(th)
+	if len(recorded) != 2 || recorded[0] != 5 || recorded[1] != 4 {
+		t.Errorf("Expected the restored thread to record 5 and 4, got %v", recorded)
+	}
+}
+
+func TestCheckpointNotEnabled(t *testing.T) {
+	Global = globals.InitGlobals("test")
+	dir, _ := ioutil.TempDir("", "jacobin-crac")
+	defer os.RemoveAll(dir)
+
+	var out strings.Builder
+	requestCheckpoint(&out, &Global)
+	if !strings.Contains(out.String(), "-XX:CRaCCheckpointTo") {
+		t.Errorf("Expected JDK.checkpoint to say checkpoints aren't enabled, got %q", out.String())
+	}
+	if _, err := readSnapshot(dir, &Global); err == nil {
+		t.Errorf("Expected no snapshot")
+	}
+
+	_ = Global.Flags.Set("CRaCCheckpointTo=" + dir)
+	out.Reset()
+	requestCheckpoint(&out, &Global)
+	if checkpointRequested != 1 {
+		t.Errorf("Expected JDK.checkpoint to request a checkpoint, got: %q", out.String())
+	}
+	checkpointRequested = 0
+	if _, err := os.Stat(filepath.Join(dir, snapshotFile)); err == nil {
+		t.Errorf("Expected no snapshot to be written before a safepoint")
+	}
+}
@@ -0,0 +1,213 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/log"
+	"strings"
+	"sync"
+)
+
+// Class initialization (JVMS 5.5): a class's static initializer, <clinit>, is run the
+// first time that getstatic, putstatic, invokestatic, or new refers to the class, and
+// for the main class, before main() is invoked. This is what creates the constants of
+// an enum, for instance, and fills in the $SwitchMap arrays that javac generates for a
+// switch over an enum.
+//
+// The procedure follows JVMS 5.5. A class's superclass is initialized before the class.
+// While a thread runs a class's <clinit>, the class is in progress: a reference to the
+// class from the same thread, as from the <clinit> itself or from a class it
+// initializes in turn, doesn't wait for the initialization (so the reference sees the
+// class's statics as they are so far), while another thread that refers to the class
+// waits until the initialization is complete. So when two threads race to initialize
+// a class, one runs its <clinit> and the other waits for it.
+//
+// If <clinit> throws an exception, the class is erroneous and is never initialized.
+// As in HotSpot, an exception that isn't an Error is thrown as the cause of an
+// ExceptionInInitializerError, and every later use of the class, from any thread,
+// throws a NoClassDefFoundError whose cause names the original exception:
+//     java.lang.NoClassDefFoundError: Could not initialize class Config
+//     Caused by: java.lang.ExceptionInInitializerError: Exception
+//         java.lang.IllegalStateException: no config file [in thread "main"]
+// A class whose superclass can't be initialized is erroneous too.
+
+// the states of a class's initialization
+const (
+	initInProgress = iota // a thread is running the class's <clinit>
+	initDone              // the class is initialized
+	initErroneous         // the initialization failed
+)
+
+// classInit is the initialization of a class
+type classInit struct {
+	state      int
+	thread     int           // the thread that initializes the class
+	done       chan struct{} // closed when the initialization ends
+	exception  int64         // if erroneous, the Throwable the initialization threw
+	threadName string        // and the name of the thread it was thrown in
+}
+
+// the initializations of the classes, which are recorded when they begin
+var (
+	classInits = make(map[string]*classInit)
+	initMutex  sync.Mutex
+)
+
+// jdkPrefixes are the packages of the JDK's classes, whose static state is set up in
+// Go (see initExec()) rather than by their <clinit>s
+var jdkPrefixes = []string{"java/", "javax/", "jdk/", "sun/"}
+
+// isJDKClass reports whether the class is one of the JDK's, an array class, or a class
+// implemented in Go
+func isJDKClass(className string) bool {
+	if classloader.GoClasses[className] || strings.HasPrefix(className, "[") {
+		return true
+	}
+	for _, prefix := range jdkPrefixes {
+		if strings.HasPrefix(className, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// initializeClass initializes the class, if it hasn't been initialized yet, on the
+// frame stack fs: it initializes the superclass and then runs the class's <clinit>, if
+// it has one. If another thread is initializing the class, it waits for it to finish.
+// The error is the exception the initialization throws. The frame stack is empty when
+// the main class is initialized before main() is invoked. A class that's initialized
+// is marked so in its ClData, which is checked before the initMutex is taken.
+func initializeClass(className string, fs *list.List) error {
+	if isJDKClass(className) {
+		return nil
+	}
+	data := loadedClass(className)
+	if data != nil && data.IsInitialized() {
+		return nil
+	}
+	caller := &frame{thread: MainThread.id}
+	if fs.Len() > 0 {
+		caller = fs.Front().Value.(*frame)
+	}
+
+	initMutex.Lock()
+	for {
+		ci := classInits[className]
+		if ci == nil { // this thread initializes the class
+			classInits[className] = &classInit{state: initInProgress, thread: caller.thread, done: make(chan struct{})}
+			break
+		}
+		if ci.state == initErroneous {
+			initMutex.Unlock()
+			return noClassDefFound(className, ci)
+		}
+		if ci.state == initDone || ci.thread == caller.thread { // a recursive request ends at once
+			if ci.state == initDone && data != nil {
+				data.SetInitialized() // as when the class was restored from a checkpoint
+			}
+			initMutex.Unlock()
+			return nil
+		}
+		initMutex.Unlock() // another thread is initializing the class, so wait for it
+		<-ci.done
+		initMutex.Lock()
+	}
+	initMutex.Unlock()
+
+	err := runInitializers(className, fs, caller)
+
+	initMutex.Lock()
+	ci := classInits[className]
+	if err == nil {
+		ci.state = initDone
+		if data = loadedClass(className); data != nil {
+			data.SetInitialized()
+		}
+	} else {
+		ci.state = initErroneous
+		ci.exception = throwableFromError(err, stackTrace(fs))
+		ci.threadName = threadName(threadOfFrame(caller))
+
+		// the frames the exception passed through are discarded, so that the stack
+		// trace of the ExceptionInInitializerError begins where the class was used
+		for fs.Len() > 0 && fs.Front().Value.(*frame) != caller {
+			fs.Remove(fs.Front())
+		}
+		err = &javaException{ci.exception}
+		if !classloader.IsSubclassOf(exceptionClass(err), "java/lang/Error") {
+			err = &javaException{classloader.NewThrowable("java/lang/ExceptionInInitializerError",
+				&classloader.Throwable{Cause: ci.exception, StackTrace: stackTrace(fs)})}
+		}
+	}
+	close(ci.done)
+	initMutex.Unlock()
+	return err
+}
+
+// loadedClass returns the parsed class file of the class if it has been loaded, or nil
+func loadedClass(className string) *classloader.ClData {
+	classloader.MethAreaMutex.RLock()
+	defer classloader.MethAreaMutex.RUnlock()
+	return classloader.Classes[className].Data
+}
+
+// noClassDefFound returns the NoClassDefFoundError for a use of the erroneous class
+func noClassDefFound(className string, ci *classInit) error {
+	var trace []string
+	if obj := classloader.GetObject(ci.exception); obj != nil {
+		if t, ok := obj.Native.(*classloader.Throwable); ok {
+			trace = t.StackTrace
+		}
+	}
+	cause := classloader.NewThrowable("java/lang/ExceptionInInitializerError", &classloader.Throwable{
+		Message: fmt.Sprintf("Exception %s [in thread \"%s\"]",
+			classloader.ThrowableString(ci.exception), ci.threadName),
+		HasMessage: true, StackTrace: trace})
+	return &javaException{classloader.NewThrowable("java/lang/NoClassDefFoundError", &classloader.Throwable{
+		Message: "Could not initialize class " + javaName(className), HasMessage: true, Cause: cause})}
+}
+
+// runInitializers initializes the superclass of the class and runs the class's
+// <clinit>, whose frame's caller is the frame that uses the class
+func runInitializers(className string, fs *list.List, caller *frame) error {
+	data := classloader.ClassData(className)
+	if data == nil {
+		return nil
+	}
+	if data.Superclass != "" {
+		if err := initializeClass(data.Superclass, fs); err != nil {
+			return err
+		}
+	}
+	if err := decodeClass(data); err != nil { // the class is linked before it's initialized
+		return err
+	}
+	if !declaresMethod(data, "<clinit>", "()V") {
+		return nil
+	}
+	mte, err := classloader.FetchMethodAndCP(className, "<clinit>", "()V")
+	if err != nil || mte.MType != 'J' {
+		return nil
+	}
+
+	_ = log.Log("Initializing class: "+className, log.FINEST)
+	fram := createJavaFrame(caller, mte.Meth.(*classloader.JmEntry), className, "<clinit>", "()V", false)
+	fs.PushFront(fram)
+	if err = runFrame(fs); err != nil {
+		return err
+	}
+	fs.Remove(fs.Front())
+	return nil
+}
+
+// declaresMethod reports whether the class declares the method
+func declaresMethod(data *classloader.ClData, name, desc string) bool {
+	return data.MethodFor(classloader.SymbolFor(name, desc)) != nil
+}
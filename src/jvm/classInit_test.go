@@ -0,0 +1,217 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"jacobin/classloader"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// the <clinit> of a class runs once, when invokestatic first refers to the class
+func TestClassInitializedOnce(t *testing.T) {
+	class := "test/Config"
+	data := &classloader.ClData{Name: class, CP: *testCP(class + ".record(I)V")}
+	addTestMethod(data, "<clinit>", "()V", 1, []byte{ICONST_5, INVOKESTATIC, 0, 1, RETURN})
+	classloader.Classes[class] = classloader.Klass{Status: 'L', Loader: "app", Data: data}
+	defer delete(classloader.Classes, class)
+
+	classloader.MTable = make(classloader.MT)
+	var recorded []int64
+	classloader.MTable[classloader.MethodKey(class+".record(I)V")] = classloader.MTentry{MType: 'G',
+		Meth: classloader.GmEntry{ParamSlots: 1, Fu: func(p []interface{}) interface{} {
+			recorded = append(recorded, p[0].(int64))
+			return nil
+		}}}
+
+	f := newFrame(ICONST_1)
+	f.meth = append(f.meth, INVOKESTATIC, 0, 1, ICONST_2, INVOKESTATIC, 0, 1)
+	f.cp = testCP(class + ".record(I)V")
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(recorded) != 3 || recorded[0] != 5 || recorded[1] != 1 || recorded[2] != 2 {
+		t.Errorf("Expected <clinit> to record 5 before the calls record 1 and 2, got %v", recorded)
+	}
+	if fs.Len() != 1 {
+		t.Errorf("Expected the frame of <clinit> to be popped, got %d frames", fs.Len())
+	}
+}
+
+// addInitTestClass puts a class with the superclass and a <clinit> with the code in
+// the method area, along with rec(I)V, implemented in Go by record
+func addInitTestClass(t *testing.T, class, super string, clinit []byte, record func(int64), methods ...string) {
+	data := &classloader.ClData{Name: class, Superclass: super, CP: *testCP(methods...)}
+	addTestMethod(data, "<clinit>", "()V", 1, clinit)
+	classloader.Classes[class] = classloader.Klass{Status: 'L', Loader: "app", Data: data}
+	t.Cleanup(func() {
+		delete(classloader.Classes, class)
+		delete(classInits, class)
+	})
+	classloader.MTable[classloader.MethodKey(class+".rec(I)V")] = classloader.MTentry{MType: 'G',
+		Meth: classloader.GmEntry{ParamSlots: 1, Fu: func(p []interface{}) interface{} {
+			record(p[0].(int64))
+			return nil
+		}}}
+}
+
+// runInit runs invokestatic of the method in CP entry 1 with 0 on the stack, on a
+// frame of the thread
+func runInit(t *testing.T, method string, thread int) {
+	f := newFrame(ICONST_0)
+	f.meth = append(f.meth, INVOKESTATIC, 0, 1)
+	f.cp = testCP(method)
+	f.thread = thread
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+// a superclass is initialized before its subclass, and the <clinit>s of two classes
+// that refer to each other run once each: the reference to the class whose
+// initialization is in progress in the same thread doesn't wait for it
+func TestClassInitOrderAndCircularity(t *testing.T) {
+	classloader.MTable = make(classloader.MT)
+	var recorded []string
+	recorder := func(class string) func(int64) {
+		return func(v int64) { recorded = append(recorded, class+string(rune('0'+v))) }
+	}
+	addInitTestClass(t, "test/Base", "", []byte{ICONST_3, INVOKESTATIC, 0, 1, RETURN},
+		recorder("Base"), "test/Base.rec(I)V")
+	addInitTestClass(t, "test/A", "test/Base", []byte{ICONST_1, INVOKESTATIC, 0, 1, RETURN},
+		recorder("A"), "test/B.rec(I)V")
+	addInitTestClass(t, "test/B", "", []byte{ICONST_2, INVOKESTATIC, 0, 1, RETURN},
+		recorder("B"), "test/A.rec(I)V")
+
+	runInit(t, "test/A.rec(I)V", 1)
+	expected := "[Base3 A2 B1 A0]"
+	if fmt.Sprint(recorded) != expected {
+		t.Errorf("Expected the records %s, got %v", expected, recorded)
+	}
+}
+
+// when two threads refer to a class at the same time, one runs its <clinit>, and the
+// other waits until it's done
+func TestClassInitRace(t *testing.T) {
+	classloader.MTable = make(classloader.MT)
+	started, release := make(chan struct{}), make(chan struct{})
+	var runs int32
+	addInitTestClass(t, "test/Slow", "", []byte{ICONST_1, INVOKESTATIC, 0, 1, RETURN}, func(v int64) {
+		if v == 1 { // <clinit> waits, holding up the initialization
+			atomic.AddInt32(&runs, 1)
+			close(started)
+			<-release
+		}
+	}, "test/Slow.rec(I)V")
+
+	first, second := make(chan struct{}), make(chan struct{})
+	go func() { runInit(t, "test/Slow.rec(I)V", 101); close(first) }()
+	<-started
+	go func() { runInit(t, "test/Slow.rec(I)V", 102); close(second) }()
+	select {
+	case <-second:
+		t.Fatal("Expected the second thread to wait for the initialization")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-first
+	<-second
+	if atomic.LoadInt32(&runs) != 1 {
+		t.Errorf("Expected <clinit> to run once, ran %d times", runs)
+	}
+}
+
+// useClass runs invokestatic of the method in CP entry 1 with 0 on the stack, and
+// returns the Throwable of the exception it throws
+func useClass(t *testing.T, method string) int64 {
+	f := newFrame(ICONST_0)
+	f.meth = append(f.meth, INVOKESTATIC, 0, 1)
+	f.cp = testCP(method)
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+	if err == nil {
+		t.Fatalf("Expected %s to throw an exception", method)
+	}
+	if fs.Len() != 1 {
+		t.Errorf("Expected the frames of <clinit> to be discarded, got %d frames", fs.Len())
+	}
+	return throwableFromError(err, nil)
+}
+
+// an exception in <clinit> is the cause of an ExceptionInInitializerError, and every
+// later use of the class, or of a subclass, throws a NoClassDefFoundError
+func TestClassInitError(t *testing.T) {
+	classloader.MTable = make(classloader.MT)
+	childRan := false
+	addInitTestClass(t, "test/Broken", "", []byte{ACONST_NULL, ATHROW}, nil)
+	addInitTestClass(t, "test/BrokenChild", "test/Broken", []byte{ICONST_0, INVOKESTATIC, 0, 1, RETURN},
+		func(int64) { childRan = true }, "test/BrokenChild.rec(I)V")
+
+	cause := func(ref int64) int64 {
+		return classloader.GetObject(ref).Native.(*classloader.Throwable).Cause
+	}
+	exception := useClass(t, "test/Broken.rec(I)V")
+	if s := classloader.ThrowableString(exception); s != "java.lang.ExceptionInInitializerError" {
+		t.Fatalf("Expected ExceptionInInitializerError, got %s", s)
+	}
+	if s := classloader.ThrowableString(cause(exception)); s != errNPE {
+		t.Errorf("Expected the NullPointerException to be the cause, got %s", s)
+	}
+
+	exception = useClass(t, "test/Broken.rec(I)V")
+	if s := classloader.ThrowableString(exception); s !=
+		"java.lang.NoClassDefFoundError: Could not initialize class test.Broken" {
+		t.Errorf("Expected NoClassDefFoundError on the second use, got %s", s)
+	}
+	if s := classloader.ThrowableString(cause(exception)); s !=
+		"java.lang.ExceptionInInitializerError: Exception "+errNPE+" [in thread \"main\"]" {
+		t.Errorf("Expected the cause to name the original exception, got %s", s)
+	}
+
+	// the subclass is erroneous too, once its superclass fails
+	for _, class := range []string{"test.Broken", "test.BrokenChild"} {
+		exception = useClass(t, "test/BrokenChild.rec(I)V")
+		if s := classloader.ThrowableString(exception); childRan || s !=
+			"java.lang.NoClassDefFoundError: Could not initialize class "+class {
+			t.Errorf("Expected NoClassDefFoundError for %s, got %s", class, s)
+		}
+	}
+}
+
+// once a class is initialized, a use of it doesn't take the initMutex
+func TestInitializedClassSkipsLock(t *testing.T) {
+	classloader.MTable = make(classloader.MT)
+	addInitTestClass(t, "test/Ready", "", []byte{RETURN}, func(int64) {}, "test/Ready.rec(I)V")
+	data := classloader.Classes["test/Ready"].Data
+	if data.IsInitialized() {
+		t.Fatal("Expected the class not to be initialized before it's used")
+	}
+	runInit(t, "test/Ready.rec(I)V", 1)
+	if !data.IsInitialized() {
+		t.Fatal("Expected the class to be marked initialized")
+	}
+
+	initMutex.Lock()
+	defer initMutex.Unlock()
+	done := make(chan error)
+	go func() { done <- initializeClass("test/Ready", createFrameStack()) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the initialized class not to wait for the initMutex")
+	}
+}
@@ -0,0 +1,389 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2021-2 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/messages"
+	"os"
+	"sort"
+	"strings"
+)
+
+// HandleCli handles all args from the command line, including those from environment
+// variables that the JVM recognizes and prepends to the list of command-line options
+// func HandleCli(osArgs []string, Global *globals.Globals) (err error) {
+func HandleCli(osArgs []string, Global *globals.Globals) (err error) {
+	var javaEnvOptions = getEnvArgs()
+	log.Log("Java environment variables: "+javaEnvOptions, log.FINE)
+
+	// JAVA_HOME and JACOBIN_HOME were obtained in the init of globals.go. Here we just log them.
+	showJavaHomeArgs(Global)
+
+	// add command-line args to those extracted from the environment (if any)
+	cliArgs := javaEnvOptions + " "
+	for _, v := range osArgs[1:] {
+		//		fmt.Printf("\t%q\n", v)
+		cliArgs += v + " "
+	}
+	Global.CommandLine = strings.TrimSpace(cliArgs)
+	log.Log("Commandline: "+Global.CommandLine, log.FINE)
+
+	// replace any @argfiles with the arguments in them, then add the options from the
+	// environment variables
+	args, err := expandArgFiles(osArgs[1:])
+	if err != nil {
+		return err
+	}
+	args, err = addEnvOptions(args)
+	if err != nil {
+		return err
+	}
+	args = append(confOptions(Global), args...) // the configuration files' defaults go first
+	Global.Args = args
+	showCopyright()
+
+	for i := 0; i < len(args); i++ {
+		var option, arg string
+		// if it's a JVM option (so, it begins with a hyphen)
+		// break the option into the option and any embedded arg values, if any
+		if strings.HasPrefix(args[i], "-") {
+			if root, ok := getPrefixOptionRoot(args[i], Global); ok {
+				option, arg = root, args[i][len(root):]
+			} else {
+				option, arg, err = getOptionRootAndArgs(args[i])
+			}
+		} else {
+			option = args[i]
+		}
+
+		if err != nil {
+			continue // skip the arg if there was a problem. (Might want to revisit this.)
+		}
+
+		// if the option is the name of the class to execute, note that then get
+		// all successive arguments and store them as app args in Global
+		if strings.HasSuffix(option, ".class") || strings.HasSuffix(option, ".java") {
+			if strings.HasSuffix(option, ".java") {
+				Global.StartingSource = option
+			} else {
+				Global.StartingClass = option
+			}
+			for i = i + 1; i < len(args); i++ {
+				Global.AppArgs = append(Global.AppArgs, args[i])
+			}
+			break
+		}
+
+		opt, ok := Global.Options[option]
+		if ok {
+			i, _ = opt.Action(i, arg, Global)
+		} else {
+			_ = messages.Print("JACOBIN-LA-0003", args[i])
+		}
+
+		// TODO: check for JAR specified and process the JAR. At present, it will
+		// recognize the JAR file and insert it into Global, and copy all succeeding args
+		// to app args. However, it does not recognize the JAR file as an executable.
+
+		// if len(arg) > 0 {
+		// 	fmt.Printf("Option %s has argument value: %s\n", option, arg)
+		// }
+	}
+	return nil
+}
+
+// pass in the option potentially with embedded arguments and get back
+// the option name and the embedded argument(s), if any
+func getOptionRootAndArgs(option string) (string, string, error) {
+	if len(option) == 0 {
+		return "", "", errors.New("empty option error")
+	}
+
+	// if the option has an embedded arg value, it'll come after a : or an =
+	argMarker := strings.Index(option, ":")
+	if argMarker == -1 {
+		argMarker = strings.Index(option, "=")
+	}
+
+	// if there's no embedded : or = then the option doesn't contain an arg value
+	if argMarker == -1 {
+		return option, "", nil
+	}
+
+	return option[:argMarker], option[argMarker+1:], nil
+
+}
+
+// getPrefixOptionRoot returns the option in the table whose value immediately follows
+// it on the command line (ArgStyle 16), such as -D in -Dkey=value, if the arg is one.
+// Since the value can contain : and =, such options can't be split at those.
+func getPrefixOptionRoot(option string, Global *globals.Globals) (string, bool) {
+	root := ""
+	for key, opt := range Global.Options {
+		if opt.ArgStyle == 16 && strings.HasPrefix(option, key) && len(key) > len(root) {
+			root = key
+		}
+	}
+	return root, root != ""
+}
+
+// you can can set JVM options using the three environment variables that are
+// inspected in this function. Note: order is important because later options
+// can override earlier ones. These are checked before any of the command-line
+// options are processed.
+func getEnvArgs() string {
+	envArgs := ""
+	javaEnvKeys := [3]string{"JAVA_TOOL_OPTIONS", "_JAVA_OPTIONS", "JDK_JAVA_OPTIONS"}
+
+	for i := 0; i < 3; i++ { // if a string is found copy it and a trailing space
+		envString := os.Getenv(javaEnvKeys[i])
+		if len(envString) > 0 {
+			envArgs += envString
+			if !strings.HasSuffix(envArgs, " ") {
+				envArgs += " "
+			}
+		}
+	}
+	return strings.TrimSpace(envArgs)
+}
+
+// addEnvOptions adds the options in the environment variables to the command-line args,
+// as the java launcher and the JVM do: the options in JAVA_TOOL_OPTIONS and then those in
+// JDK_JAVA_OPTIONS go before the command-line args, and those in _JAVA_OPTIONS go after
+// the command-line options (but before the main class), so they override them. Each
+// variable that's set is reported on stderr. The values are split into args using the
+// quoting rules of argument files, and JDK_JAVA_OPTIONS can also contain @argfiles, but
+// not -jar or a main class.
+func addEnvOptions(cmdArgs []string) ([]string, error) {
+	var args []string
+	for _, name := range []string{"JAVA_TOOL_OPTIONS", "JDK_JAVA_OPTIONS"} {
+		envArgs, err := envOptions(name)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, envArgs...)
+	}
+
+	overrides, err := envOptions("_JAVA_OPTIONS")
+	if err != nil {
+		return nil, err
+	}
+	mainPos := mainArgIndex(cmdArgs) // the position of the main class, -jar, or -m, if any
+	args = append(args, cmdArgs[:mainPos]...)
+	args = append(args, overrides...)
+	return append(args, cmdArgs[mainPos:]...), nil
+}
+
+// envOptions returns the args in the named environment variable
+func envOptions(name string) ([]string, error) {
+	value := os.Getenv(name)
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	if name == "JDK_JAVA_OPTIONS" { // the launcher's variable, so its note is the launcher's
+		_ = messages.Print("JACOBIN-LA-0004", name, value)
+	} else {
+		_ = messages.Print("JACOBIN-LA-0005", name, value)
+	}
+
+	args, err := parseArgFile(value)
+	if err != nil || name != "JDK_JAVA_OPTIONS" {
+		return args, err
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-jar" || arg == "-m" || arg == "--module":
+			return nil, messages.Print("JACOBIN-LA-0006", arg, name)
+		case valueFollows(arg):
+			i++ // the option's value, such as the class path after -cp
+		case !strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "@"):
+			return nil, messages.Print("JACOBIN-LA-0007", name)
+		}
+	}
+	return expandArgFiles(args)
+}
+
+// mainArgIndex returns the index of the arg that ends the options: the main class, or
+// -jar or -m, which name what to run in the args after them. If there's none, it's
+// len(args). The value of an option that's given in the next arg, such as the class
+// path after -cp, is stepped over rather than taken for the main class.
+func mainArgIndex(args []string) int {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-jar" || arg == "-m" || arg == "--module" || !strings.HasPrefix(arg, "-"):
+			return i
+		case valueFollows(arg):
+			i++
+		}
+	}
+	return len(args)
+}
+
+// valueFollows reports whether the arg is an option whose value is the next arg on the
+// command line (ArgStyle 4 or 8 in the options table), such as -cp or --add-modules
+func valueFollows(arg string) bool {
+	opt, ok := Global.Options[arg]
+	return ok && (opt.ArgStyle == 4 || opt.ArgStyle == 8)
+}
+
+// log the two environmental variables from which we'll load base classes, if log level allows.
+func showJavaHomeArgs(Global *globals.Globals) {
+	if Global.JavaHome != "" {
+		log.Log("JAVA_HOME: "+Global.JavaHome, log.FINE)
+	}
+	if Global.JacobinHome != "" {
+		log.Log("JACOBIN_HOME: "+Global.JacobinHome, log.FINE)
+	}
+}
+
+// show the usage info to the user (in response to errors or java -help and
+// similar command-line options). The options it lists are generated from the
+// options table, so the message always shows exactly the standard options that
+// Jacobin supports. (The nonstandard options are listed by -X; see showExtraOptions().)
+func showUsage(outStream *os.File, global *globals.Globals) {
+	userMessage :=
+		`
+Usage: jacobin [options] <mainclass> [args...]
+	        (to execute a class)
+   or jacobin [options] -jar <jarfile> [args...]
+	        (to execute a jar file)
+   or jacobin [options] -m <module>[/<mainclass>] [args...]
+	        (to execute the main class in a module)
+   or jacobin [options] <sourcefile> [args...]
+	        (to execute a single source-file program)
+   or jacobin cmd [<pid> <command>|help]
+	        (to send a diagnostic command to a running VM)
+   or jacobin stack <pid>
+	        (to print the thread dump of a running VM)
+   or jacobin jfr <recording>
+	        (to print a flight recording as JSON)
+   or jacobin serve [--allow-remote] [<address>]
+	        (to run the programs sent to it over HTTP, from this machine only
+	        unless --allow-remote is given)
+Arguments following the main class, source file, -jar <jarfile>,
+-m or --module <module>/<mainclass> are passed as the arguments to
+main class.
+
+where options include:`
+
+	fmt.Fprintln(outStream, userMessage)
+	showOptions(outStream, global, false)
+	fmt.Fprintln(outStream, "    @argument files")
+	fmt.Fprintf(outStream, "%*sone or more argument files containing options\n", optionsWidth, "")
+}
+
+// showExtraOptions lists the nonstandard options, as -X and --help-extra do.
+func showExtraOptions(outStream *os.File, global *globals.Globals) {
+	showOptions(outStream, global, true)
+	fmt.Fprintln(outStream, "\nThese extra options are subject to change without notice.")
+}
+
+// the column in which the descriptions start in the listings of options
+const optionsWidth = 30
+
+// showOptions lists the supported standard or extra options, in alphabetical order,
+// with their syntax, descriptions, and defaults. Options with aliases share an entry
+// in the table, so each is listed once. A description can run to several lines,
+// separated by \n.
+func showOptions(outStream *os.File, global *globals.Globals, extra bool) {
+	var opts []globals.Option
+	listed := make(map[string]bool)
+	for _, opt := range global.Options {
+		if !opt.Supported || opt.Extra != extra || opt.Syntax == "" || listed[opt.Syntax] {
+			continue
+		}
+		listed[opt.Syntax] = true
+		opts = append(opts, opt)
+	}
+	sortKey := func(opt globals.Option) string {
+		return strings.ToLower(strings.TrimLeft(opt.Syntax, "-"))
+	}
+	sort.Slice(opts, func(i, j int) bool { return sortKey(opts[i]) < sortKey(opts[j]) })
+
+	indent := strings.Repeat(" ", optionsWidth)
+	for _, opt := range opts {
+		description := opt.Description
+		if opt.Default != "" {
+			description += " (default: " + opt.Default + ")"
+		}
+		description = strings.ReplaceAll(description, "\n", "\n"+indent)
+		if len(opt.Syntax) < optionsWidth-5 {
+			fmt.Fprintf(outStream, "    %-*s %s\n", optionsWidth-5, opt.Syntax, description)
+		} else {
+			fmt.Fprintf(outStream, "    %s\n%s%s\n", opt.Syntax, indent, description)
+		}
+	}
+}
+
+// printFlagsFinal lists the -XX flags with their types, effective values, and where
+// the values came from, as -XX:+PrintFlagsFinal does.
+func printFlagsFinal(outStream io.Writer, global *globals.Globals) {
+	fmt.Fprintln(outStream, "[Global flags]")
+	for _, name := range global.Flags.Names() {
+		flag, _ := global.Flags.Lookup(name)
+		fmt.Fprintf(outStream, "%9s %-40s = %-40s {%s}\n", flag.Type, flag.Name, flag.Value(), flag.Origin)
+	}
+}
+
+// show the version info in the format of the java launcher, which scripts parse. As
+// with the launcher, the first line differs between the options that print to stderr
+// (-version and -showversion), which print openjdk version "11.0.10" 2021-01-19, and
+// those that print to stdout (--version and --show-version), which print the version
+// unquoted: openjdk 11.0.10 2021-01-19. The next lines give the runtime's and the VM's
+// names and builds.
+func showVersion(outStream *os.File, global *globals.Globals, toStderr bool) {
+	javaVersion, _ := globals.SystemProperties.Get("java.version")
+	versionDate, _ := globals.SystemProperties.Get("java.version.date")
+	if toStderr {
+		fmt.Fprintf(outStream, "openjdk version \"%s\" %s\n", javaVersion, versionDate)
+	} else {
+		fmt.Fprintf(outStream, "openjdk %s %s\n", javaVersion, versionDate)
+	}
+
+	// the build date is that of the presently executing Jacobin executable
+	build := global.Version
+	exe, err := os.Executable()
+	if err != nil {
+		exe = global.JacobinName
+	}
+	if file, err := os.Stat(exe); err == nil {
+		date := file.ModTime()
+		build += fmt.Sprintf(" %d-%02d-%02d", date.Year(), date.Month(), date.Day())
+	}
+	model := "Server"
+	if global.VmModel == "client" {
+		model = "Client"
+	}
+	fmt.Fprintf(outStream, "Jacobin Runtime Environment (build %s+jacobin-%s)\n", javaVersion, global.Version)
+	fmt.Fprintf(outStream, "Jacobin VM v. %s 64-Bit %s VM (build %s, interpreted mode)\n",
+		global.Version, model, build)
+}
+
+// show the copyright. Because the various -version commands show much the
+// same data, rather than printing it twice, we skip showing the copyright
+// info when the -version option variants are specified, and an embedded VM
+// (see embed.go) doesn't show it at all
+func showCopyright() {
+	if !strings.Contains(Global.CommandLine, "-showversion") &&
+		!strings.Contains(Global.CommandLine, "--show-version") &&
+		!strings.Contains(Global.CommandLine, "-version") &&
+		!strings.Contains(Global.CommandLine, "--version") && !embedded {
+		fmt.Println("Jacobin VM v. " + Global.Version +
+			", © 2021-2 by Andrew Binstock. All rights reserved. MPL 2.0 License.")
+	}
+}
+
+// isBanner reports whether a line of output is the banner that showCopyright() prints
+func isBanner(line string) bool {
+	return strings.HasPrefix(line, "Jacobin VM v. ")
+}
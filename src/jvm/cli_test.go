@@ -0,0 +1,1010 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2021 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"io/ioutil"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/messages"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unset all of the JVM environment variables and make sure
+// collecting them results in an empty string
+func TestGetJVMenvVariablesWhenAbsent(t *testing.T) {
+	_ = os.Unsetenv("JAVA_TOOL_OPTIONS")
+	_ = os.Unsetenv("_JAVA_OPTIONS")
+	_ = os.Unsetenv("JDK_JAVA_OPTIONS")
+
+	javaEnvVars := getEnvArgs()
+	if javaEnvVars != "" {
+		t.Error("getting non-existent Java environment options failed")
+	}
+}
+
+// set two of the JVM environment variables and make sure
+// they are fetched correctly and a space is inserted between them
+func TestGetJVMenvVariablesWhenTwoArePresent(t *testing.T) {
+	_ = os.Unsetenv("JAVA_TOOL_OPTIONS")
+	_ = os.Setenv("_JAVA_OPTIONS", "Hello,")
+	_ = os.Setenv("JDK_JAVA_OPTIONS", "Jacobin!")
+
+	javaEnvVars := getEnvArgs()
+	if javaEnvVars != "Hello, Jacobin!" {
+		t.Error("getting two set Java environment options failed: " + javaEnvVars)
+	}
+
+	// clean up the environment
+	_ = os.Unsetenv("_JAVA_OPTIONS")
+	_ = os.Unsetenv("JDK_JAVA_OPTIONS")
+}
+
+// verify the output to stderr -help option is used
+func TestHandleUsageMessage(t *testing.T) {
+	// set the logger to low granularity, so that logging messages are not also captured in this test
+	global := globals.InitGlobals("test")
+	_ = log.SetLogLevel(log.WARNING)
+	LoadOptionsTable(global)
+
+	// to avoid cluttering the test results, redirect stdout
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	// to inspect usage message, redirect stderr
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	args := []string{"jacobin", "-help"}
+	_ = HandleCli(args, &global)
+
+	// restore stderr to what it was before
+	_ = w.Close()
+	out, _ := ioutil.ReadAll(r)
+
+	_ = wout.Close()
+	os.Stdout = normalStdout
+	os.Stderr = normalStderr
+
+	msg := string(out[:])
+
+	if !strings.Contains(msg, "Usage:") ||
+		!strings.Contains(msg, "where options include") {
+		t.Error("jacobin -help did not generate the usage message to stderr. msg was: " + msg)
+	}
+
+	if !global.ExitNow() {
+		t.Error("'jacobin -help' should have set Global.exitNow to true to signal end of processing")
+	}
+}
+
+func TestShowUsageMessageExitsProperlyWith__Help(t *testing.T) {
+	// set the logger to low granularity, so that logging messages are not also captured in this test
+	global := globals.InitGlobals("test")
+	_ = log.SetLogLevel(log.WARNING)
+	LoadOptionsTable(global)
+
+	// to avoid cluttering the test results, redirect stdout and stderr
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_, _ = showHelpStdoutAndExit(0, "--help", &global)
+
+	_ = wout.Close()
+	os.Stdout = normalStdout
+	os.Stderr = normalStderr
+
+	if !global.ExitNow() {
+		t.Error("'jacobin --help' should set Global.exitNow to true but did not")
+	}
+}
+
+func TestShowVersionMessage(t *testing.T) {
+	// set the logger to low granularity, so that logging messages are not also captured in this test
+	global := globals.InitGlobals("test")
+	_ = log.SetLogLevel(log.WARNING)
+
+	// to avoid cluttering the test results, redirect stdout
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	// redirect stderr to capture writing to it
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	LoadOptionsTable(global)
+	args := []string{"jacobin", "-showversion", "main.clas"}
+
+	_ = HandleCli(args, &global)
+
+	// restore stderr to what it was before
+	_ = w.Close()
+	out, _ := ioutil.ReadAll(r)
+
+	_ = wout.Close()
+	os.Stdout = normalStdout
+	os.Stderr = normalStderr
+
+	msg := string(out[:])
+
+	if !strings.Contains(msg, "Jacobin VM v.") {
+		t.Error("jacobin -version did not generate the correct message to stderr. msg was: " + msg)
+	}
+}
+
+func TestShow__VersionUsingOptionTable(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	normalStdout := os.Stdout
+	r, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	_, _ = versionStdoutThenExit(0, "--version", &global)
+
+	_ = wout.Close()
+	os.Stdout = normalStdout
+	out, _ := ioutil.ReadAll(r)
+
+	os.Stdout = normalStdout
+	msg := string(out[:])
+
+	if !strings.Contains(msg, "Jacobin VM v.") {
+		t.Error("jacobin --version did not generate the correct msg to stdout. msg was: " + msg)
+	}
+
+	if !global.ExitNow() {
+		t.Error("--version did not set exitNow value to exit. Should be set.")
+	}
+}
+
+func TestChangeLoggingLevels(t *testing.T) {
+	global := globals.InitGlobals("test")
+	_ = log.SetLogLevel(log.WARNING)
+	LoadOptionsTable(global)
+
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	// to avoid cluttering the test results, redirect stdout and stderr
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	args := []string{"jacobin", "-verbose:info", "main.class"}
+	_ = HandleCli(args, &global)
+
+	// reset stdout and stderr to what they were before redirection
+	_ = w.Close()
+	_ = wout.Close()
+	os.Stdout = normalStdout
+	os.Stderr = normalStderr
+
+	if log.Level != log.INFO {
+		t.Error("Setting log level to INFO via command line failed")
+	}
+
+	// --- now test with FINE
+
+	_ = log.SetLogLevel(log.WARNING)
+
+	normalStdout = os.Stdout
+	_, wout, _ = os.Pipe()
+	os.Stdout = wout
+
+	normalStderr = os.Stderr
+	_, w, _ = os.Pipe()
+	os.Stderr = w
+
+	LoadOptionsTable(global)
+	args = []string{"jacobin", "-verbose:fine", "main.class"}
+	_ = HandleCli(args, &global)
+
+	_ = w.Close()
+	_ = wout.Close()
+	os.Stdout = normalStdout
+	os.Stderr = normalStderr
+
+	if log.Level != log.FINE {
+		t.Error("Setting log level to FINE via command line failed")
+	}
+
+	// --- now try with FINEST
+
+	_ = log.SetLogLevel(log.WARNING)
+
+	normalStdout = os.Stdout
+	_, wout, _ = os.Pipe()
+	os.Stdout = wout
+
+	normalStderr = os.Stderr
+	_, w, _ = os.Pipe()
+	os.Stderr = w
+
+	LoadOptionsTable(global)
+	args = []string{"jacobin", "-verbose:finest", "main.class"}
+	_ = HandleCli(args, &global)
+
+	_ = w.Close()
+	_ = wout.Close()
+	os.Stdout = normalStdout
+	os.Stderr = normalStderr
+
+	if log.Level != log.FINEST {
+		t.Error("Setting log level to FINEST via command line failed")
+	}
+
+	// --- finally test with CLASS
+
+	_ = log.SetLogLevel(log.WARNING)
+
+	normalStdout = os.Stdout
+	_, wout, _ = os.Pipe()
+	os.Stdout = wout
+
+	normalStderr = os.Stderr
+	_, w, _ = os.Pipe()
+	os.Stderr = w
+
+	LoadOptionsTable(global)
+	args = []string{"jacobin", "-verbose:class", "main.class"}
+	_ = HandleCli(args, &global)
+
+	_ = w.Close()
+	_ = wout.Close()
+	os.Stdout = normalStdout
+	os.Stderr = normalStderr
+
+	if log.Level != log.CLASS {
+		t.Error("Setting log level to CLASS via command line failed")
+	}
+}
+
+func TestInvalidLoggingLevel(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	_ = log.SetLogLevel(log.WARNING)
+
+	// to avoid cluttering the test results, redirect stdout and stderr
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_, err := verbosityLevel(0, "severe", &global)
+
+	_ = w.Close()
+	_ = wout.Close()
+	os.Stdout = normalStdout
+	os.Stderr = normalStderr
+
+	if err == nil {
+		t.Error("Setting log level to SEVERE via command line did not generate expected error")
+	}
+}
+
+func TestSpecifyClientVM(t *testing.T) {
+
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	if global.VmModel != "server" {
+		t.Error("Initialization of Global.vmModel was not set to 'server' Got: " +
+			global.VmModel)
+	}
+
+	normalStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+
+	args := []string{"jacobin", "-client"}
+	_ = HandleCli(args, &global)
+
+	// restore stdout to what it was before
+	_ = w.Close()
+	os.Stdout = normalStdout
+
+	if global.VmModel != "client" {
+		t.Error("global.vmModel should be set to 'client'. Instead got: " +
+			global.VmModel)
+	}
+}
+
+func TestSpecifyValidButUnsupportedOption(t *testing.T) {
+
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	// all the options in the table are supported now, so add one that isn't
+	global.Options["--not-yet"] = globals.Option{Supported: false, Action: notSupported}
+
+	// redirect stdout to avoid cluttering test results with copyright notice
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	// redirect stderr to inspect output
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	args := []string{"jacobin", "--not-yet", "main.class"}
+	_ = HandleCli(args, &global)
+
+	// restore stderr to what it was before
+	_ = w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stderr = normalStderr
+
+	msg := string(out[:])
+
+	_ = wout.Close()
+	os.Stdout = normalStdout
+
+	if !strings.Contains(msg, "not currently supported") {
+		t.Error("Unsupported but valid option not identified. Instead got" + msg)
+	}
+}
+
+func TestShowCopyright(t *testing.T) {
+	globals.InitGlobals("test")
+	_ = log.SetLogLevel(log.WARNING)
+
+	normalStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	showCopyright()
+
+	_ = w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stdout = normalStdout
+
+	msg := string(out[:])
+
+	if !strings.Contains(msg, "All rights reserved.") ||
+		!strings.Contains(msg, "2021") {
+		t.Error("Copyright does not contain expected terms")
+	}
+}
+
+func TestFoundClassFileWithNoArgs(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	// redirecting stdout to avoid clutter in the test results
+	normalStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+
+	args := []string{"jacobin", "main.class"}
+	_ = HandleCli(args, &global)
+
+	_ = w.Close()
+	os.Stdout = normalStdout
+
+	if global.StartingClass != "main.class" {
+		t.Error("main.class not identified as starting class. Got: " +
+			global.StartingClass)
+	}
+
+	if len(global.AppArgs) != 0 {
+		t.Error("app arg to main.class should be empty, but got: " +
+			global.AppArgs[0])
+	}
+}
+
+// make sure that if a file path to the executable has an embedded :
+// (as it might under Windows), that it's not mistaken for an option
+// with an embedded argument (JACOBIN-2)
+func TestClassFileColonIFilePath(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	// redirecting stdout to avoid clutter in the test results
+	normalStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+
+	args := []string{"jacobin", "d:main.class"}
+	_ = HandleCli(args, &global)
+
+	_ = w.Close()
+	os.Stdout = normalStdout
+
+	if global.StartingClass != "d:main.class" {
+		t.Error("d:main.class not identified as starting class. Got: " +
+			global.StartingClass)
+	}
+
+	if len(global.AppArgs) != 0 {
+		t.Error("app arg to main.class should be empty, but got: " +
+			global.AppArgs[0])
+	}
+}
+
+func TestFoundClassFileWithArgs(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	// redirecting stdout to avoid clutter in the test results
+	normalStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+
+	args := []string{"jacobin", "main.class", "appArg1"}
+	_ = HandleCli(args, &global)
+
+	_ = w.Close()
+	os.Stdout = normalStdout
+
+	if global.StartingClass != "main.class" {
+		t.Error("main.class not identified as starting class. Got: " +
+			global.StartingClass)
+	}
+
+	if global.AppArgs[0] != "appArg1" {
+		t.Error("app arg to main.class not correct. Got: " +
+			global.AppArgs[0])
+	}
+}
+
+func TestGetJarFilename(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	args := []string{"jacobin", "-jar", "pinkle.jar", "appArg1"}
+
+	_ = HandleCli(args, &global)
+
+	_ = w.Close()
+	_ = wout.Close()
+	os.Stdout = normalStdout
+	os.Stderr = normalStderr
+
+	if global.StartingJar != "pinkle.jar" {
+		t.Error("Name of JAR file not correctly extracted from CLI")
+	}
+	if cp, _ := globals.SystemProperties.Get("java.class.path"); cp != "pinkle.jar" {
+		t.Error("Expected the JAR file to be the class path, got: " + cp)
+	}
+
+	if global.AppArgs[0] != "appArg1" {
+		t.Error("JAR file arg not correctly extracted from CLI. Expected: appArg1, got: " +
+			global.AppArgs[0])
+	}
+}
+
+func TestMissingJARfilename(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	global.Args = []string{"jacobin", "-jar"}
+
+	_, err := getJarFilename(1, "-jar", &global)
+	if err != os.ErrInvalid {
+		t.Error("Missing JAR filename after -jar did not trigger the right error")
+	}
+}
+
+func TestEmptyOptionForEmbeddedArg(t *testing.T) {
+	_, _, err := getOptionRootAndArgs("")
+	if err == nil {
+		t.Error("Empty option should fail test for embedded args, but did not.")
+	}
+}
+
+// -D options define system properties, whose values can contain : and = and can be empty
+func TestDefineSystemProperties(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	normalStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+
+	args := []string{"jacobin", "-Dapp.name=demo", "-Dproxy=http://host:8080/?a=b", "-Dempty=",
+		"-Dflag", "Main.class", "-Dnot.a.property=1"}
+	_ = HandleCli(args, &global)
+
+	_ = w.Close()
+	os.Stdout = normalStdout
+
+	for key, want := range map[string]string{
+		"app.name": "demo", "proxy": "http://host:8080/?a=b", "empty": "", "flag": ""} {
+		if val, present := globals.SystemProperties.Get(key); !present || val != want {
+			t.Errorf("Expected property %s to be %q, got %q (set: %t)", key, want, val, present)
+		}
+	}
+	if _, present := globals.SystemProperties.Get("not.a.property"); present {
+		t.Error("Expected a -D after the main class to be an app arg, not a property")
+	}
+	if len(global.AppArgs) != 1 || global.AppArgs[0] != "-Dnot.a.property=1" {
+		t.Errorf("Expected the -D after the main class to be an app arg, got %v", global.AppArgs)
+	}
+}
+
+func TestDefineSystemPropertyWithoutName(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	global.Args = []string{"-D=value"}
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	_, err := defineSystemProperty(0, "=value", &global)
+	_ = w.Close()
+	os.Stderr = normalStderr
+	msg, _ := ioutil.ReadAll(r)
+
+	if err == nil || !strings.Contains(string(msg), "requires a property name") {
+		t.Errorf("Expected an error for a -D without a name, got %v (%s)", err, msg)
+	}
+}
+
+// the options in JAVA_TOOL_OPTIONS and JDK_JAVA_OPTIONS go before the command-line
+// args and those in _JAVA_OPTIONS go after the options, and each variable is reported
+func TestEnvOptionsOrder(t *testing.T) {
+	_ = os.Setenv("JAVA_TOOL_OPTIONS", "-Dtool=1")
+	_ = os.Setenv("JDK_JAVA_OPTIONS", "-Dname=\"a b\"")
+	_ = os.Setenv("_JAVA_OPTIONS", "-Dlast=1")
+	defer func() {
+		_ = os.Unsetenv("JAVA_TOOL_OPTIONS")
+		_ = os.Unsetenv("JDK_JAVA_OPTIONS")
+		_ = os.Unsetenv("_JAVA_OPTIONS")
+	}()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	args, err := addEnvOptions([]string{"-client", "Main.class", "appArg"})
+	_ = w.Close()
+	os.Stderr = normalStderr
+	msg, _ := ioutil.ReadAll(r)
+
+	want := []string{"-Dtool=1", "-Dname=a b", "-client", "-Dlast=1", "Main.class", "appArg"}
+	if err != nil || strings.Join(args, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %q, got %q (%v)", want, args, err)
+	}
+	for _, note := range []string{"Picked up JAVA_TOOL_OPTIONS: -Dtool=1\n",
+		"NOTE: Picked up JDK_JAVA_OPTIONS: -Dname=\"a b\"\n", "Picked up _JAVA_OPTIONS: -Dlast=1\n"} {
+		if !strings.Contains(string(msg), note) {
+			t.Errorf("Expected stderr to contain %q, got %q", note, msg)
+		}
+	}
+}
+
+// the value of an option such as -cp isn't taken for the main class, so _JAVA_OPTIONS
+// goes after it, and JDK_JAVA_OPTIONS can set the class path
+func TestEnvOptionsStepOverOptionValues(t *testing.T) {
+	Global = globals.InitGlobals("test")
+	LoadOptionsTable(Global)
+	_ = os.Setenv("JDK_JAVA_OPTIONS", "-cp lib")
+	_ = os.Setenv("_JAVA_OPTIONS", "-Xint")
+	defer func() {
+		_ = os.Unsetenv("JDK_JAVA_OPTIONS")
+		_ = os.Unsetenv("_JAVA_OPTIONS")
+	}()
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() {
+		_ = w.Close()
+		os.Stderr = normalStderr
+	}()
+
+	for _, c := range []struct{ args, want []string }{
+		{[]string{"-cp", "app", "Main.class", "x"}, []string{"-cp", "lib", "-cp", "app", "-Xint", "Main.class", "x"}},
+		{[]string{"--add-modules", "m", "-m", "m/Main"}, []string{"-cp", "lib", "--add-modules", "m", "-Xint", "-m", "m/Main"}},
+	} {
+		args, err := addEnvOptions(c.args)
+		if err != nil || strings.Join(args, "|") != strings.Join(c.want, "|") {
+			t.Errorf("Expected %q, got %q (%v)", c.want, args, err)
+		}
+	}
+}
+
+func TestJdkJavaOptionsRejectsMainClass(t *testing.T) {
+	defer func() { _ = os.Unsetenv("JDK_JAVA_OPTIONS") }()
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	defer func() {
+		_ = w.Close()
+		os.Stderr = normalStderr
+	}()
+
+	for _, value := range []string{"-client Main.class", "-jar app.jar", "-cp lib Main.class", "-m mod/Main"} {
+		_ = os.Setenv("JDK_JAVA_OPTIONS", value)
+		if _, err := addEnvOptions(nil); err == nil {
+			t.Errorf("Expected JDK_JAVA_OPTIONS=%q to be rejected", value)
+		}
+	}
+}
+
+// the first line of the version info is in the format scripts parse: quoted on stderr
+// (-version), unquoted on stdout (--version). -showversion continues rather than exiting.
+func TestVersionFormats(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	capture := func(stream **os.File, action func(int, string, *globals.Globals) (int, error)) string {
+		normal := *stream
+		r, w, _ := os.Pipe()
+		*stream = w
+		_, _ = action(0, "", &global)
+		_ = w.Close()
+		*stream = normal
+		out, _ := ioutil.ReadAll(r)
+		return string(out)
+	}
+
+	errOut := capture(&os.Stderr, versionStderrThenExit)
+	if !strings.HasPrefix(errOut, "openjdk version \"11.0.10\" 2021-01-19\n") {
+		t.Errorf("Unexpected -version output: %s", errOut)
+	}
+	stdOut := capture(&os.Stdout, versionStdoutThenExit)
+	if !strings.HasPrefix(stdOut, "openjdk 11.0.10 2021-01-19\n") || strings.Count(stdOut, "\n") != 3 {
+		t.Errorf("Unexpected --version output: %s", stdOut)
+	}
+
+	global.SetExitNow(false)
+	showOut := capture(&os.Stderr, showVersionStderr)
+	if !strings.HasPrefix(showOut, "openjdk version \"11.0.10\"") || global.ExitNow() {
+		t.Errorf("Expected -showversion to print the version and continue, got: %s", showOut)
+	}
+}
+
+// the -X listing is generated from the options table: it shows every supported extra
+// option (once, even if it has aliases) and none of the standard ones
+func TestExtraOptionsListing(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	testOpt := globals.Option{Supported: true, Action: notSupported, Extra: true,
+		Syntax: "-Xtest", Description: "an option added for this test"}
+	global.Options["-Xtest"] = testOpt
+	global.Options["-Xtest2"] = testOpt
+	global.Options["-Xnot-yet"] = globals.Option{Supported: false, Action: notSupported, Extra: true,
+		Syntax: "-Xnot-yet", Description: "an unsupported option"}
+
+	normalStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	_, _ = showExtraHelpStdoutAndExit(0, "--help-extra", &global)
+	_ = w.Close()
+	os.Stdout = normalStdout
+	out, _ := ioutil.ReadAll(r)
+	msg := string(out)
+
+	if !strings.Contains(msg, "-trace:inst") || !strings.Contains(msg, "-XX:[+|-]<flag>") {
+		t.Errorf("Expected the extra options to be listed, got: %s", msg)
+	}
+	if strings.Count(msg, "an option added for this test") != 1 {
+		t.Errorf("Expected an option with aliases to be listed once, got: %s", msg)
+	}
+	if strings.Contains(msg, "-Xnot-yet") || strings.Contains(msg, "-verbose") {
+		t.Errorf("Expected unsupported and standard options not to be listed, got: %s", msg)
+	}
+	if !global.ExitNow() {
+		t.Error("Expected --help-extra to set ExitNow")
+	}
+}
+
+func TestPrintFlagsFinal(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	_ = HandleCli([]string{"jacobin", "-XX:+PrintFlagsFinal", "-XX:-UseThreadPriorities"}, &global)
+	if !global.Flags.Bool("PrintFlagsFinal") {
+		t.Fatal("Expected -XX:+PrintFlagsFinal to be set")
+	}
+
+	normalStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	printFlagsFinal(os.Stdout, &global)
+	_ = w.Close()
+	os.Stdout = normalStdout
+	out, _ := ioutil.ReadAll(r)
+	msg := string(out)
+
+	if !strings.HasPrefix(msg, "[Global flags]\n") {
+		t.Errorf("Expected the flags listing to start with [Global flags], got: %s", msg)
+	}
+	for _, line := range strings.Split(msg, "\n") {
+		if strings.Contains(line, "UseThreadPriorities") &&
+			(!strings.Contains(line, "= false") || !strings.HasSuffix(line, "{command line}")) {
+			t.Errorf("Expected UseThreadPriorities to be false, set on the command line, got: %s", line)
+		}
+	}
+}
+
+// the usage message's list of options is generated from the options table, with
+// each option's syntax, description, and default
+func TestUsageListsStandardOptionsFromTable(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	global.Options["--test-opt"] = globals.Option{Supported: true, Action: notSupported,
+		Syntax: "--test-opt <n>", Description: "an option added for this test", Default: "42"}
+
+	normalStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	_, _ = showHelpStdoutAndExit(0, "--help", &global)
+	_ = w.Close()
+	os.Stdout = normalStdout
+	out, _ := ioutil.ReadAll(r)
+	msg := string(out)
+
+	if !strings.Contains(msg, "--test-opt <n>") || !strings.Contains(msg, "an option added for this test (default: 42)") {
+		t.Errorf("Expected the option added to the table to be listed with its default, got: %s", msg)
+	}
+	if strings.Count(msg, "print this help message to the error stream") != 1 {
+		t.Errorf("Expected -? -h -help to be listed once, got: %s", msg)
+	}
+	if strings.Contains(msg, "-trace") {
+		t.Errorf("Expected the extra options not to be in the usage message, got: %s", msg)
+	}
+	if !global.ExitNow() {
+		t.Error("Expected --help to set ExitNow")
+	}
+}
+
+func TestVerboseChannels(t *testing.T) {
+	global := globals.InitGlobals("test")
+	log.Init()
+	LoadOptionsTable(global)
+	_ = HandleCli([]string{"jacobin", "-verbose:jni"}, &global)
+	if !log.ChannelEnabled(log.JNI) || log.ChannelEnabled(log.MODULE) || log.Level != log.WARNING {
+		t.Error("Expected -verbose:jni to select only the JNI channel, leaving the logging level unchanged")
+	}
+	_ = HandleCli([]string{"jacobin", "-verbose:module"}, &global)
+	if !log.ChannelEnabled(log.MODULE) {
+		t.Error("Expected -verbose:module to select the module channel")
+	}
+	log.Init()
+}
+
+func TestXlogToFile(t *testing.T) {
+	global := globals.InitGlobals("test")
+	log.Init()
+	LoadOptionsTable(global)
+	dir, _ := ioutil.TempDir("", "jacobin-xlog")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "vm.log")
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	_, err := logToFile(0, "log,jni:file="+path+":filesize=1k:filecount=3", &global)
+	_ = log.Log("to the file", log.WARNING)
+	_, badErr := logToFile(0, "file="+path+":filesize=lots", &global)
+	_ = w.Close()
+	os.Stderr = normalStderr
+	stderr, _ := ioutil.ReadAll(r)
+	log.Init()
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "to the file\n" {
+		t.Errorf("Expected the message in the log file, got: %q", content)
+	}
+	if badErr == nil || !strings.Contains(string(stderr), "Invalid -Xlog option") {
+		t.Errorf("Expected an invalid file size to be reported, got: %s", stderr)
+	}
+}
+
+// the class path options take the next arg, whose entries are separated by the
+// platform's separator
+func TestClassPathOptions(t *testing.T) {
+	sep := string(os.PathListSeparator)
+	for _, option := range []string{"-cp", "-classpath", "--class-path"} {
+		global := globals.InitGlobals("test")
+		LoadOptionsTable(global)
+
+		normalStdout := os.Stdout
+		_, w, _ := os.Pipe()
+		os.Stdout = w
+
+		args := []string{"jacobin", option, "lib/a.jar" + sep + "classes", "Main.class", "appArg"}
+		_ = HandleCli(args, &global)
+
+		_ = w.Close()
+		os.Stdout = normalStdout
+
+		want := filepath.FromSlash("lib/a.jar") + sep + "classes"
+		if cp, _ := globals.SystemProperties.Get("java.class.path"); cp != want {
+			t.Errorf("%s: expected class path %q, got %q", option, want, cp)
+		}
+		if global.StartingClass != "Main.class" || len(global.AppArgs) != 1 {
+			t.Errorf("%s: expected Main.class with one app arg, got %q and %v",
+				option, global.StartingClass, global.AppArgs)
+		}
+	}
+}
+
+func TestClassPathOptionWithoutPath(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	global.Args = []string{"-cp"}
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	_, err := setClassPath(0, "", &global)
+	_ = w.Close()
+	os.Stderr = normalStderr
+	msg, _ := ioutil.ReadAll(r)
+
+	if messages.CodeOf(err) != "JACOBIN-LA-0025" || !strings.Contains(string(msg), "-cp requires") {
+		t.Errorf("Expected an error for -cp without a path, got %v (%s)", err, msg)
+	}
+}
+
+// the module options are recorded, in both their space and = forms
+func TestModuleOptions(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	args := []string{"jacobin", "--add-opens", "java.base/java.lang=ALL-UNNAMED",
+		"--add-exports=jdk.compiler/com.sun.tools.javac.api=ALL-UNNAMED",
+		"--add-modules", "java.sql,ALL-MODULE-PATH", "--add-opens", "java.base", "Main.class"}
+	_ = HandleCli(args, &global)
+
+	_ = w.Close()
+	_ = wout.Close()
+	os.Stdout = normalStdout
+	os.Stderr = normalStderr
+	msg, _ := ioutil.ReadAll(r)
+
+	modules := globals.GetGlobalRef().Modules
+	if len(modules.AddOpens) != 1 || modules.AddOpens[0].Package != "java.lang" {
+		t.Errorf("Expected --add-opens of java.lang, got %+v", modules.AddOpens)
+	}
+	if len(modules.AddExports) != 1 || modules.AddExports[0].Module != "jdk.compiler" {
+		t.Errorf("Expected --add-exports of jdk.compiler, got %+v", modules.AddExports)
+	}
+	if len(modules.AddModules) != 2 || modules.AddModules[1] != "ALL-MODULE-PATH" {
+		t.Errorf("Expected two added modules, got %v", modules.AddModules)
+	}
+	if !strings.Contains(string(msg), "Invalid --add-opens value 'java.base'") {
+		t.Errorf("Expected an error for the invalid --add-opens, got: %s", msg)
+	}
+	if global.StartingClass != "Main.class" {
+		t.Errorf("Expected Main.class to be the starting class, got %q", global.StartingClass)
+	}
+}
+
+func TestModulePathOptions(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	args := []string{"jacobin", "-p", "mods/a/b", "-m", "com.example.app/com.example.app.Main",
+		"first", "-verbose:class"}
+	_ = HandleCli(args, &global)
+
+	_ = w.Close()
+	_ = wout.Close()
+	os.Stderr = normalStderr
+	os.Stdout = normalStdout
+
+	if global.ModulePath != filepath.FromSlash("mods/a/b") {
+		t.Errorf("Expected the module path mods/a/b, got %q", global.ModulePath)
+	}
+	if global.StartingModule != "com.example.app/com.example.app.Main" {
+		t.Errorf("Expected the starting module com.example.app/com.example.app.Main, got %q",
+			global.StartingModule)
+	}
+	if len(global.AppArgs) != 2 || global.AppArgs[1] != "-verbose:class" {
+		t.Errorf("Expected the args after -m to be app args, got %v", global.AppArgs)
+	}
+	if main, _ := globals.SystemProperties.Get("jdk.module.main"); main != "com.example.app" {
+		t.Errorf("Expected jdk.module.main to be com.example.app, got %q", main)
+	}
+}
+
+func TestModuleOptionWithoutModule(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	_ = HandleCli([]string{"jacobin", "--module-path"}, &global)
+	_ = HandleCli([]string{"jacobin", "--module"}, &global)
+
+	_ = w.Close()
+	_ = wout.Close()
+	os.Stderr = normalStderr
+	os.Stdout = normalStdout
+	msg, _ := ioutil.ReadAll(r)
+
+	for _, want := range []string{"--module-path requires module path specification",
+		"--module requires module name"} {
+		if !strings.Contains(string(msg), want) {
+			t.Errorf("Expected the error %q, got: %s", want, msg)
+		}
+	}
+}
+
+func TestAgentLibOption(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	args := []string{"jacobin", "-agentlib:jdwp=transport=dt_socket,server=y,suspend=n,address=5005", "Hello"}
+	_ = HandleCli(args, &global)
+
+	if global.JDWPOptions != "transport=dt_socket,server=y,suspend=n,address=5005" {
+		t.Errorf("Expected the jdwp options to be recorded, got %q", global.JDWPOptions)
+	}
+	if !global.OptionSet("-agentlib") {
+		t.Error("Expected -agentlib to be marked as seen")
+	}
+}
+
+func TestAgentLibOptionErrors(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_ = HandleCli([]string{"jacobin", "-agentlib:hprof=cpu=samples", "Hello"}, &global)
+	_ = HandleCli([]string{"jacobin", "-agentlib:jdwp=transport=dt_shmem", "Hello"}, &global)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+	msg, _ := ioutil.ReadAll(r)
+
+	for _, want := range []string{"agent library hprof is not supported",
+		"invalid -agentlib:jdwp option: transport dt_shmem is not supported"} {
+		if !strings.Contains(string(msg), want) {
+			t.Errorf("Expected the error %q, got: %s", want, msg)
+		}
+	}
+	if global.JDWPOptions != "" {
+		t.Errorf("Expected no jdwp options, got %q", global.JDWPOptions)
+	}
+}
@@ -0,0 +1,134 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bufio"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/messages"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Configuration files. Default options can be kept in files named jacobin.conf: one in
+// JACOBIN_HOME, for the installation, and one in the current directory, for the project.
+// A configuration file has one setting per line, in the form key = value (or key: value),
+// and lines starting with # or ! are comments, as in a Java properties file:
+//
+//	classpath = lib/app.jar:lib/util.jar
+//	verbose = class
+//	XX.PrintFlagsFinal = true
+//	XX.LogFormat = json
+//	options = -Dapp.mode=test --no-color
+//
+// The keys are:
+//
+//	classpath   the class path, as with -cp
+//	verbose     what to log, as with -verbose:
+//	XX.<flag>   a VM flag: true or false for a boolean flag, as with -XX:+<flag>, and
+//	            otherwise its value, as with -XX:<flag>=<value>
+//	options     any other options, written as they are on the command line
+//
+// The settings are turned into options that go before those from the environment
+// variables and the command line, so that those override them. The project's file is
+// read after the installation's, so it overrides it in turn.
+
+const confFileName = "jacobin.conf"
+
+// confOptions returns the options from the configuration files that exist
+func confOptions(gl *globals.Globals) []string {
+	var paths []string
+	if gl.JacobinHome != "" {
+		paths = append(paths, filepath.Join(gl.JacobinHome, confFileName))
+	}
+	paths = append(paths, confFileName)
+
+	var args []string
+	seen := make(map[string]bool) // so a file isn't read twice when JACOBIN_HOME is the current directory
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil || seen[abs] {
+			continue
+		}
+		seen[abs] = true
+		fileArgs, err := readConfFile(path, gl)
+		if err != nil {
+			continue // a missing file is the usual case; other errors have been shown
+		}
+		log.Log("Configuration file: "+abs, log.FINE)
+		args = append(args, fileArgs...)
+	}
+	return args
+}
+
+// readConfFile returns the options for the settings in a configuration file. Invalid
+// settings are reported and skipped.
+func readConfFile(path string, gl *globals.Globals) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var args []string
+	lineNum := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		sep := strings.IndexAny(line, "=:")
+		if sep <= 0 {
+			_ = messages.Print("JACOBIN-LA-0026", path, lineNum)
+			continue
+		}
+		key := strings.TrimSpace(line[:sep])
+		value := strings.TrimSpace(line[sep+1:])
+
+		switch {
+		case key == "classpath":
+			args = append(args, "-cp", value)
+		case key == "verbose":
+			args = append(args, "-verbose:"+value)
+		case strings.HasPrefix(key, "XX.") && len(key) > len("XX."):
+			name := key[len("XX."):]
+			flag, present := gl.Flags.Lookup(name)
+			if !present || flag.Type != globals.BoolFlag {
+				args = append(args, "-XX:"+name+"="+value)
+			} else if value == "true" {
+				args = append(args, "-XX:+"+name)
+			} else if value == "false" {
+				args = append(args, "-XX:-"+name)
+			} else {
+				_ = messages.Print("JACOBIN-LA-0027", path, lineNum, key)
+			}
+		case key == "options":
+			options, err := parseArgFile(value)
+			if err != nil {
+				continue
+			}
+			if !confOptionsValid(options) {
+				_ = messages.Print("JACOBIN-LA-0029", path, lineNum)
+				continue
+			}
+			args = append(args, options...)
+		default:
+			_ = messages.Print("JACOBIN-LA-0028", path, lineNum, key)
+		}
+	}
+	return args, scanner.Err()
+}
+
+// confOptionsValid reports whether the args of an options setting are only options and
+// their values. As with JDK_JAVA_OPTIONS, a configuration file can't say what program
+// to run.
+func confOptionsValid(options []string) bool {
+	return mainArgIndex(options) == len(options)
+}
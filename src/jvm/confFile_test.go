@@ -0,0 +1,106 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"io/ioutil"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadConfFile(t *testing.T) {
+	global := globals.InitGlobals("test")
+	Global = globals.InitGlobals("test")
+	LoadOptionsTable(Global)
+	dir, _ := ioutil.TempDir("", "jacobin-conf")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, confFileName)
+	content := "# defaults for the project\n" +
+		"! also a comment\n" +
+		"classpath = lib/a.jar\n" +
+		"verbose: class\n" +
+		"XX.PrintFlagsFinal = true\n" +
+		"XX.UseThreadPriorities = false\n" +
+		"XX.LogFormat = json\n" +
+		"options = -Dmode=\"a test\" --no-color\n" +
+		"XX.UseThreadPriorities = maybe\n" +
+		"colour = blue\n" +
+		"not a setting\n" +
+		"options = -Dx=1 Main.class\n" +
+		"options = -p mods --add-modules m\n"
+	_ = ioutil.WriteFile(path, []byte(content), 0644)
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	args, err := readConfFile(path, &global)
+	_ = w.Close()
+	os.Stderr = normalStderr
+	msg, _ := ioutil.ReadAll(r)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []string{"-cp", "lib/a.jar", "-verbose:class", "-XX:+PrintFlagsFinal",
+		"-XX:-UseThreadPriorities", "-XX:LogFormat=json", "-Dmode=a test", "--no-color", "-p", "mods", "--add-modules", "m"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Expected %q, got %q", want, args)
+	}
+	for _, expected := range []string{"line 9: XX.UseThreadPriorities must be true or false",
+		"line 10: colour is not a recognized setting", "line 11: expected a setting",
+		"line 12: options can't include -jar or a main class"} {
+		if !strings.Contains(string(msg), expected) {
+			t.Errorf("Expected the error %q, got: %s", expected, msg)
+		}
+	}
+}
+
+// the project's file overrides the installation's, and the environment and the command
+// line override both
+func TestConfFilesOrder(t *testing.T) {
+	home, _ := ioutil.TempDir("", "jacobin-home")
+	defer os.RemoveAll(home)
+	project, _ := ioutil.TempDir("", "jacobin-project")
+	defer os.RemoveAll(project)
+	_ = ioutil.WriteFile(filepath.Join(home, confFileName),
+		[]byte("options = -Dwho=home -Dhome=1 -Dcli=home\n"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(project, confFileName),
+		[]byte("options = -Dwho=project -Denv=project\n"), 0644)
+
+	wd, _ := os.Getwd()
+	_ = os.Chdir(project)
+	defer os.Chdir(wd)
+	_ = os.Setenv("JDK_JAVA_OPTIONS", "-Denv=env")
+	defer os.Unsetenv("JDK_JAVA_OPTIONS")
+
+	global := globals.InitGlobals("test")
+	global.JacobinHome = home
+	LoadOptionsTable(global)
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	_ = HandleCli([]string{"jacobin", "-Dcli=cli", "Main.class"}, &global)
+
+	_ = w.Close()
+	_ = wout.Close()
+	os.Stderr = normalStderr
+	os.Stdout = normalStdout
+
+	for key, want := range map[string]string{"who": "project", "home": "1", "env": "env", "cli": "cli"} {
+		if val, _ := globals.SystemProperties.Get(key); val != want {
+			t.Errorf("Expected property %s to be %q, got %q", key, want, val)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/messages"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Bytecode coverage. With -XX:CoverageFile=<file>, the number of times each bytecode
+// of each method is executed is recorded, and at exit, the coverage of the classes
+// of the application (those that aren't part of the JDK) is written to the file in
+// the lcov format, which genhtml, Codecov, SonarQube, and IDEs read. The lines of a
+// class are those in the LineNumberTables of its methods (so classes compiled
+// without them, with javac -g:none, aren't reported), and a line is counted as many
+// times as its most executed bytecode. A method's count is its number of invocations.
+//
+// JaCoCo's .exec files aren't written, since they record the probes that JaCoCo's
+// instrumentation adds to the classes, which have no equivalent here; but the tools
+// that read JaCoCo's reports generally read lcov as well.
+
+// coverage is whether the coverage is recorded. It's set before the program starts.
+var coverage bool
+
+var (
+	coveragePath  string
+	coverageCount = make(map[string][]uint32) // the count of each bytecode of each method
+	coverageMutex sync.Mutex
+)
+
+// startCoverage starts recording the coverage if -XX:CoverageFile was given
+func startCoverage(gl *globals.Globals) {
+	coveragePath = gl.Flags.String("CoverageFile")
+	coverage = coveragePath != ""
+}
+
+// stopCoverage writes the coverage report, if the coverage was recorded
+func stopCoverage() {
+	if !coverage {
+		return
+	}
+	coverage = false
+	f, err := os.Create(coveragePath)
+	if err == nil {
+		err = writeLcov(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0051", coveragePath, err.Error())
+	}
+}
+
+// coverageOf returns the counts of the bytecodes of the frame's method, which the
+// frame adds to as it executes them
+func coverageOf(f *frame) []uint32 {
+	method := f.clName + "." + f.methName + f.methType
+	coverageMutex.Lock()
+	defer coverageMutex.Unlock()
+	counts := coverageCount[method]
+	if counts == nil {
+		counts = make([]uint32, len(f.meth))
+		coverageCount[method] = counts
+	}
+	return counts
+}
+
+// coverageHit returns the number of times the bytecode at pc was executed
+func coverageHit(counts []uint32, pc int) uint32 {
+	if pc < len(counts) {
+		return atomic.LoadUint32(&counts[pc])
+	}
+	return 0
+}
+
+// jdkClass is whether the class is part of the JDK, as the class loader decides it
+func jdkClass(name string) bool {
+	return strings.HasPrefix(name, "java/") || strings.HasPrefix(name, "jdk/") ||
+		strings.HasPrefix(name, "javax/") || strings.HasPrefix(name, "sun/")
+}
+
+// sourceCoverage is the coverage of a source file
+type sourceCoverage struct {
+	lines     map[int]uint32 // the count of each line
+	functions []functionCoverage
+}
+
+type functionCoverage struct {
+	name  string // as in com.example.Hello.main([Ljava/lang/String;)V
+	line  int    // the first line
+	count uint32 // the invocations
+}
+
+// writeLcov writes the coverage of the application's classes, as lcov records
+func writeLcov(out io.Writer) error {
+	sources := make(map[string]*sourceCoverage)
+	classloader.MethAreaMutex.RLock()
+	for className, k := range classloader.Classes {
+		if k.Data == nil || jdkClass(className) {
+			continue
+		}
+		file := path.Join(path.Dir(className), k.Data.SourceFile)
+		if k.Data.SourceFile == "" {
+			file = className + ".java"
+		}
+		for i := range k.Data.Methods {
+			m := &k.Data.Methods[i]
+			lines, present := classloader.LineNumbers(&k.Data.CP, &m.CodeAttr)
+			if !present || len(lines) == 0 {
+				continue
+			}
+			name := k.Data.CP.Utf8Refs[m.Name]
+			method := className + "." + name + k.Data.CP.Utf8Refs[m.Desc]
+			coverageMutex.Lock()
+			counts := coverageCount[method]
+			coverageMutex.Unlock()
+
+			source := sources[file]
+			if source == nil {
+				source = &sourceCoverage{lines: make(map[int]uint32)}
+				sources[file] = source
+			}
+			// each entry covers the bytecodes up to the next one (they aren't
+			// necessarily in order)
+			sort.Slice(lines, func(a, b int) bool { return lines[a].StartPc < lines[b].StartPc })
+			first := lines[0].Line
+			for j, l := range lines {
+				end := len(m.CodeAttr.Code)
+				if j+1 < len(lines) {
+					end = lines[j+1].StartPc
+				}
+				hits := source.lines[l.Line]
+				for pc := l.StartPc; pc < end; pc++ {
+					if c := coverageHit(counts, pc); c > hits {
+						hits = c
+					}
+				}
+				source.lines[l.Line] = hits
+				if l.Line < first {
+					first = l.Line
+				}
+			}
+			source.functions = append(source.functions, functionCoverage{
+				name: strings.ReplaceAll(className, "/", ".") + "." + name + k.Data.CP.Utf8Refs[m.Desc],
+				line: first, count: coverageHit(counts, 0)})
+		}
+	}
+	classloader.MethAreaMutex.RUnlock()
+
+	files := make([]string, 0, len(sources))
+	for file := range sources {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	w := bufio.NewWriter(out)
+	for _, file := range files {
+		source := sources[file]
+		fmt.Fprintf(w, "TN:\nSF:%s\n", file)
+		sort.Slice(source.functions, func(i, j int) bool {
+			if source.functions[i].line != source.functions[j].line {
+				return source.functions[i].line < source.functions[j].line
+			}
+			return source.functions[i].name < source.functions[j].name
+		})
+		functionsHit := 0
+		for _, fn := range source.functions {
+			fmt.Fprintf(w, "FN:%d,%s\n", fn.line, fn.name)
+		}
+		for _, fn := range source.functions {
+			fmt.Fprintf(w, "FNDA:%d,%s\n", fn.count, fn.name)
+			if fn.count > 0 {
+				functionsHit++
+			}
+		}
+		fmt.Fprintf(w, "FNF:%d\nFNH:%d\n", len(source.functions), functionsHit)
+
+		lines := make([]int, 0, len(source.lines))
+		for line := range source.lines {
+			lines = append(lines, line)
+		}
+		sort.Ints(lines)
+		linesHit := 0
+		for _, line := range lines {
+			fmt.Fprintf(w, "DA:%d,%d\n", line, source.lines[line])
+			if source.lines[line] > 0 {
+				linesHit++
+			}
+		}
+		fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n", len(lines), linesHit)
+	}
+	return w.Flush()
+}
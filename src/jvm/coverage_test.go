@@ -0,0 +1,72 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"jacobin/classloader"
+	"testing"
+)
+
+// coverageClass adds a class whose run() has lines 5 (bytecodes 0 and 1) and 6 (2)
+func coverageClass(name string) {
+	code := classloader.CodeAttrib{Code: []byte{ICONST_1, POP, RETURN}, Attributes: []classloader.Attr{
+		{AttrName: 0, AttrContent: []byte{0, 2, 0, 0, 0, 5, 0, 2, 0, 6}},
+	}}
+	classloader.MethAreaMutex.Lock()
+	classloader.Classes[name] = classloader.Klass{Status: 'N', Data: &classloader.ClData{
+		Name: name, SourceFile: "Cover.java",
+		CP:      classloader.CPool{Utf8Refs: []string{"LineNumberTable", "run", "()V"}},
+		Methods: []classloader.Method{{Name: 1, Desc: 2, CodeAttr: code}},
+	}}
+	classloader.MethAreaMutex.Unlock()
+}
+
+func TestCoverageRecorded(t *testing.T) {
+	classloader.MTable = make(classloader.MT)
+	classloader.MTable[classloader.MethodKey("test/Cover.run()V")] = classloader.MTentry{
+		Meth:  &classloader.JmEntry{MaxStack: 1, MaxLocals: 1, Code: []byte{ICONST_1, POP, RETURN}},
+		MType: 'J',
+	}
+	coverageCount = make(map[string][]uint32)
+	coverage = true
+	defer func() { coverage = false }()
+	for i := 0; i < 2; i++ {
+		if _, err := invokeMethod("test/Cover", "run", "()V", nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	counts := coverageCount["test/Cover.run()V"]
+	if len(counts) != 3 || counts[0] != 2 || counts[1] != 2 || counts[2] != 2 {
+		t.Errorf("Expected each bytecode to be executed twice, got: %v", counts)
+	}
+}
+
+func TestWriteLcov(t *testing.T) {
+	coverageClass("test/Cover")
+	coverageClass("java/lang/Covered") // the JDK's classes aren't reported
+	defer func() {
+		classloader.MethAreaMutex.Lock()
+		delete(classloader.Classes, "test/Cover")
+		delete(classloader.Classes, "java/lang/Covered")
+		classloader.MethAreaMutex.Unlock()
+	}()
+	coverageCount = map[string][]uint32{
+		"test/Cover.run()V":        {3, 3, 0}, // returned by an exception, say
+		"java/lang/Covered.run()V": {1, 1, 1},
+	}
+
+	var out bytes.Buffer
+	if err := writeLcov(&out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "TN:\nSF:test/Cover.java\n" +
+		"FN:5,test.Cover.run()V\nFNDA:3,test.Cover.run()V\nFNF:1\nFNH:1\n" +
+		"DA:5,3\nDA:6,0\nLF:2\nLH:1\nend_of_record\n"
+	if out.String() != expected {
+		t.Errorf("Expected the lcov report:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
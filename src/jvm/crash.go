@@ -0,0 +1,180 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Crash reports. A Go panic in the VM (a runtime error such as a nil dereference, which
+// is also what a segmentation fault in Go code becomes) ends the program with a report,
+// modeled on the JDK's hs_err files, rather than with the bare panic trace. The report
+// is written to -XX:ErrorFile, or else to hs_err_pid<pid>.log in the current
+// directory, or if that can't be written, in the temp directory. It has the panic, the
+// Java frame that was executing and its current instruction, the Java stack of every
+// thread, a summary of the loaded classes, the VM flags, and the Go stacks, which are
+// what a bug report needs. A brief notice on stderr says where the report is.
+//
+// Each goroutine that runs Java code defers reportCrash(), since a panic can only be
+// recovered on the goroutine that panics. Crashes in native code that isn't Go (in a
+// JNI library, say) end the process without a report.
+
+// reportCrash writes the crash report and ends the VM if the goroutine is panicking. The
+// thread is the Java thread that runs on the goroutine, or nil if there's none.
+func reportCrash(t *execThread) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	goStack := debug.Stack() // the stack of the panic, which is still in place
+	path, err := writeCrashFile(r, goStack, t)
+
+	fmt.Fprintf(os.Stderr, "#\n# A fatal error has been detected by the Jacobin VM:\n#\n#  %v\n#\n", r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "# The error report could not be written: %s\n#\n", err.Error())
+		os.Stderr.Write(goStack)
+	} else {
+		fmt.Fprintf(os.Stderr, "# An error report file with more information is saved as:\n# %s\n#\n", path)
+	}
+	osExit(exitVMError)
+}
+
+// writeCrashFile writes the crash report to the error file, and returns its path
+func writeCrashFile(r interface{}, goStack []byte, t *execThread) (string, error) {
+	gl := &Global
+	path := ""
+	if gl.Flags != nil {
+		path = gl.Flags.String("ErrorFile")
+	}
+	var candidates []string
+	if path != "" {
+		candidates = []string{path}
+	} else {
+		name := fmt.Sprintf("hs_err_pid%d.log", os.Getpid())
+		candidates = []string{name, filepath.Join(os.TempDir(), name)}
+	}
+
+	var err error
+	for _, candidate := range candidates {
+		var f *os.File
+		if f, err = os.Create(candidate); err != nil {
+			continue
+		}
+		writeCrashReport(f, r, goStack, t, gl)
+		if err = f.Close(); err == nil {
+			if abs, absErr := filepath.Abs(candidate); absErr == nil {
+				candidate = abs
+			}
+			return candidate, nil
+		}
+	}
+	return "", err
+}
+
+// writeCrashReport writes the sections of the report
+func writeCrashReport(out io.Writer, r interface{}, goStack []byte, t *execThread, gl *globals.Globals) {
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "#\n# A fatal error has been detected by the Jacobin VM:\n#\n#  %v\n#\n", r)
+	fmt.Fprintf(w, "# Jacobin VM version %s (%s/%s, %s)\n", gl.Version, runtime.GOOS, runtime.GOARCH, runtime.Version())
+	fmt.Fprintf(w, "# Time: %s, elapsed time: %s\n#\n", time.Now().Format(time.RFC1123),
+		time.Since(gl.StartTime).Round(time.Millisecond))
+	fmt.Fprintf(w, "Command Line: %s\n\n", strings.Join(gl.Args, " "))
+
+	fmt.Fprintln(w, "---------------  T H R E A D  ---------------")
+	fmt.Fprintln(w)
+	if t == nil || t.stack == nil || t.stack.Len() == 0 {
+		fmt.Fprintln(w, "Current thread: none (the crash wasn't in Java code)")
+	} else {
+		fmt.Fprintf(w, "Current thread: \"%s\" #%d\n\n", threadName(t), t.id)
+		f := t.stack.Front().Value.(*frame)
+		class, name, desc := frameMethod(f)
+		fmt.Fprintf(w, "Failing frame: %s.%s%s", strings.ReplaceAll(class, "/", "."), name, desc)
+		switch {
+		case f.ftype == 'G':
+			fmt.Fprintln(w, " (Native Method)")
+		case f.pc < len(f.meth):
+			fmt.Fprintf(w, " at pc %d: %s (0x%02X)\n", f.pc, BytecodeNames[f.meth[f.pc]], f.meth[f.pc])
+			if f.tos >= 0 && f.tos < len(f.opStack) {
+				fmt.Fprintf(w, "Operand stack: %v\n", f.opStack[:f.tos+1])
+			}
+			fmt.Fprintf(w, "Locals: %v\n", f.locals)
+		default:
+			fmt.Fprintf(w, " at pc %d\n", f.pc)
+		}
+		fmt.Fprintln(w, "\nJava frames:")
+		for _, frame := range stackTrace(t.stack) {
+			fmt.Fprintln(w, "\tat "+frame)
+		}
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "---------------  P R O C E S S  ---------------")
+	fmt.Fprintln(w)
+	dumpThreads(w)
+	fmt.Fprintln(w)
+	writeLoadedClassSummary(w)
+	fmt.Fprintln(w)
+	if gl.Flags != nil {
+		printFlagsFinal(w, gl)
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "---------------  G O   S T A C K S  ---------------")
+	fmt.Fprintln(w)
+	w.Write(goStack)
+	fmt.Fprintln(w)
+	all := make([]byte, 1<<20)
+	w.Write(all[:runtime.Stack(all, true)])
+	fmt.Fprintln(w)
+}
+
+// writeLoadedClassSummary writes the number of classes each loader loaded, and the
+// classes that aren't the JDK's
+func writeLoadedClassSummary(w io.Writer) {
+	byLoader := make(map[string]int)
+	var appClasses []string
+	classloader.MethAreaMutex.RLock()
+	for name, k := range classloader.Classes {
+		loader := k.Loader
+		if loader == "" {
+			loader = "(loading)"
+		}
+		byLoader[loader]++
+		if !jdkClass(name) {
+			appClasses = append(appClasses, name)
+		}
+	}
+	total := len(classloader.Classes)
+	classloader.MethAreaMutex.RUnlock()
+
+	loaders := make([]string, 0, len(byLoader))
+	for loader := range byLoader {
+		loaders = append(loaders, loader)
+	}
+	sort.Strings(loaders)
+	fmt.Fprintf(w, "Loaded classes: %d\n", total)
+	for _, loader := range loaders {
+		fmt.Fprintf(w, "  %-12s %d\n", loader, byLoader[loader])
+	}
+	sort.Strings(appClasses)
+	fmt.Fprintln(w, "Application classes:")
+	for _, name := range appClasses {
+		fmt.Fprintln(w, "  "+strings.ReplaceAll(name, "/", "."))
+	}
+}
@@ -0,0 +1,68 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"io/ioutil"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCrashReport(t *testing.T) {
+	savedGlobal := Global
+	defer func() { Global = savedGlobal }()
+	Global = globals.InitGlobals("test")
+	Global.Args = []string{"jacobin", "Hello"}
+	path := filepath.Join(t.TempDir(), "crash.log")
+	if err := Global.Flags.Set("ErrorFile=" + path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	status := 0
+	osExit = func(s int) { status = s }
+	defer func() { osExit = os.Exit }()
+
+	th := CreateThread(7)
+	f := createFrame(2)
+	f.clName, f.methName, f.methType, f.ftype = "Hello", "main", "([Ljava/lang/String;)V", 'J'
+	f.meth, f.pc = []byte{ICONST_0, IDIV}, 1
+	th.stack.PushFront(f)
+
+	stderr := captureStderr(func() {
+		func() {
+			defer reportCrash(&th)
+			panic("boom")
+		}()
+	})
+	if status != exitVMError {
+		t.Errorf("Expected the VM to exit with %d, got %d", exitVMError, status)
+	}
+	if !strings.Contains(stderr, "#  boom") || !strings.Contains(stderr, path) {
+		t.Errorf("Expected the notice to give the panic and the report's path, got:\n%s", stderr)
+	}
+
+	report, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected the report to be written: %v", err)
+	}
+	for _, want := range []string{
+		"Command Line: jacobin Hello",
+		"Current thread: \"Thread-7\" #7",
+		"Failing frame: Hello.main([Ljava/lang/String;)V at pc 1: IDIV (0x6C)",
+		"\tat Hello.main(Unknown Source)",
+		"Full thread dump",
+		"Loaded classes:",
+		"ErrorFile",
+		"G O   S T A C K S",
+		"TestCrashReport",
+	} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("Expected the report to contain %q, got:\n%s", want, report)
+		}
+	}
+}
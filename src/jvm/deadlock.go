@@ -0,0 +1,158 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"io"
+	"jacobin/globals"
+	"jacobin/log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Detection of deadlocks among object monitors. The owner/waiter graph has an edge
+// from each blocked thread to the thread that owns the monitor it's waiting on. A
+// cycle in this graph is a deadlock: none of the threads in it can ever proceed.
+// The detector is run on demand when the threads are dumped (see dumpThreads()), and
+// periodically in the background with -XX:DeadlockDetectionInterval.
+
+// findDeadlocks returns each cycle in the owner/waiter graph as a list of thread IDs,
+// in the order in which each thread waits on the next. The graph is a snapshot, so a
+// thread that acquires its monitor while the snapshot is taken can't show up in a cycle.
+func findDeadlocks() [][]int {
+	// take a snapshot of the edges: blocked thread -> owner of the monitor it waits on
+	threadsMutex.Lock()
+	blockedOn := make(map[int]int64)
+	for id, t := range threads {
+		if t.blockedOn != 0 {
+			blockedOn[id] = t.blockedOn
+		}
+	}
+	threadsMutex.Unlock()
+
+	waitsFor := make(map[int]int)
+	for id, ref := range blockedOn {
+		owner := monitorOwner(ref)
+		if owner != -1 && owner != id {
+			waitsFor[id] = owner
+		}
+	}
+
+	// walk the graph from each blocked thread, in ID order so results are repeatable.
+	// Each thread has at most one outgoing edge, so a walk either ends or loops.
+	var ids []int
+	for id := range waitsFor {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var cycles [][]int
+	inCycle := make(map[int]bool)
+	for _, start := range ids {
+		if inCycle[start] {
+			continue
+		}
+		position := make(map[int]int)
+		var path []int
+		id := start
+		for {
+			if pos, seen := position[id]; seen {
+				cycle := path[pos:]
+				for _, c := range cycle {
+					inCycle[c] = true
+				}
+				cycles = append(cycles, cycle)
+				break
+			}
+			next, blocked := waitsFor[id]
+			if !blocked || inCycle[id] {
+				break
+			}
+			position[id] = len(path)
+			path = append(path, id)
+			id = next
+		}
+	}
+	return cycles
+}
+
+// reportDeadlocks writes a description of each deadlock, including the stack traces
+// of the threads involved, to w. It returns the number of deadlocks found.
+func reportDeadlocks(w io.Writer) int {
+	cycles := findDeadlocks()
+	for _, cycle := range cycles {
+		var sb strings.Builder
+		sb.WriteString("Found one Java-level deadlock:\n=============================\n")
+		for i, id := range cycle {
+			next := cycle[(i+1)%len(cycle)]
+			threadsMutex.Lock()
+			var ref int64
+			if t := threads[id]; t != nil { // the thread can't have ended, being deadlocked
+				ref = t.blockedOn
+			}
+			threadsMutex.Unlock()
+			fmt.Fprintf(&sb, "\"Thread-%d\":\n  waiting to lock monitor 0x%x,\n  which is held by \"Thread-%d\"\n",
+				id, ref, next)
+		}
+		sb.WriteString("\nJava stack information for the threads listed above:\n")
+		sb.WriteString("===================================================\n")
+		var ts []*execThread
+		threadsMutex.Lock()
+		for _, id := range cycle {
+			if t := threads[id]; t != nil {
+				ts = append(ts, t)
+			}
+		}
+		threadsMutex.Unlock()
+		stacks := threadStacks(ts) // without threadsMutex, as in dumpThreads()
+		threadsMutex.Lock()
+		for i, t := range ts {
+			sb.WriteString(formatThread(t, stacks[i]))
+		}
+		threadsMutex.Unlock()
+		fmt.Fprintln(w, sb.String())
+	}
+	return len(cycles)
+}
+
+// startDeadlockDetection starts the deadlock monitor if -XX:DeadlockDetectionInterval
+// was given
+func startDeadlockDetection(gl *globals.Globals) {
+	interval := time.Duration(gl.Flags.Int("DeadlockDetectionInterval")) * time.Millisecond
+	if interval <= 0 {
+		return
+	}
+	startDeadlockMonitor(interval)
+	log.Log("Checking for deadlocks every "+interval.String(), log.INFO)
+}
+
+// startDeadlockMonitor runs the detector every interval on a background goroutine,
+// logging any deadlocks it finds. A set of deadlocks is logged only when it differs
+// from the last one logged. Returns a channel that stops the monitor when closed.
+func startDeadlockMonitor(interval time.Duration) chan struct{} {
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		lastReported := ""
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				var sb strings.Builder
+				if reportDeadlocks(&sb) > 0 && sb.String() != lastReported {
+					lastReported = sb.String()
+					_ = log.Log(lastReported, log.WARNING)
+				}
+			}
+		}
+	}()
+	return stop
+}
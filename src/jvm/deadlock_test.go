@@ -0,0 +1,90 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeadlockDetection(t *testing.T) {
+	th1 := CreateThread(150)
+	th2 := CreateThread(151)
+	registerThread(&th1)
+	registerThread(&th2)
+	defer threadEnded(&th1)
+	defer threadEnded(&th2)
+
+	f := createFrame(2)
+	f.clName = "test/Deadlock"
+	f.methName = "run"
+	_ = pushFrame(th1.stack, f)
+
+	// th1 holds A and wants B; th2 holds B and wants A. (The locks are objects of their
+	// own, since they stay locked after the test.)
+	lockA := classloader.NewObject("test/Lock", 0)
+	lockB := classloader.NewObject("test/Lock", 0)
+	_ = monitorEnter(&th1, lockA)
+	_ = monitorEnter(&th2, lockB)
+	go func() { // each blocks as its thread, so that its stack can be dumped
+		goroutineThreads.Store(goroutineID(), th1.id)
+		_ = monitorEnter(&th1, lockB)
+	}()
+	go func() {
+		goroutineThreads.Store(goroutineID(), th2.id)
+		_ = monitorEnter(&th2, lockA)
+	}()
+
+	var cycles [][]int
+	for i := 0; i < 200 && len(cycles) == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+		cycles = findDeadlocks()
+	}
+	if len(cycles) != 1 || len(cycles[0]) != 2 {
+		t.Fatalf("Expected one deadlock involving two threads, got: %v", cycles)
+	}
+
+	var sb strings.Builder
+	if reportDeadlocks(&sb) != 1 {
+		t.Errorf("Expected reportDeadlocks() to report one deadlock")
+	}
+	report := sb.String()
+	if !strings.Contains(report, "Found one Java-level deadlock") ||
+		!strings.Contains(report, "\"Thread-150\"") ||
+		!strings.Contains(report, "at test.Deadlock.run") {
+		t.Errorf("Deadlock report is missing expected content: %s", report)
+	}
+
+	// and a thread dump (Thread.print, jacobin stack) ends with the report
+	sb.Reset()
+	dumpThreads(&sb)
+	if dump := sb.String(); !strings.HasPrefix(dump, "Full thread dump") ||
+		!strings.Contains(dump, "Found one Java-level deadlock") {
+		t.Errorf("Expected the thread dump to report the deadlock, got: %s", dump)
+	}
+}
+
+func TestNoDeadlockWhenJustBlocked(t *testing.T) {
+	th1 := CreateThread(152)
+	th2 := CreateThread(153)
+	registerThread(&th1)
+	registerThread(&th2)
+
+	lock := classloader.NewObject("test/Lock", 0)
+	_ = monitorEnter(&th1, lock)
+	go func() { _ = monitorEnter(&th2, lock) }()
+	time.Sleep(10 * time.Millisecond)
+
+	if cycles := findDeadlocks(); len(cycles) != 0 {
+		t.Errorf("Expected no deadlock when a thread is merely blocked, got: %v", cycles)
+	}
+	_ = monitorExit(&th1, lock)
+	threadEnded(&th1)
+	threadEnded(&th2)
+}
@@ -0,0 +1,535 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/jdwp"
+	"jacobin/messages"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+// The debugger's view of the program, for the JDWP agent that's started by
+// -agentlib:jdwp (see jdwp/jdwp.go). The interpreter calls the agent's Safepoint()
+// before each instruction while the debugger needs it to, and tells the agent when
+// threads start and end, when classes are loaded, and when native code is running.
+
+// debugger is the JDWP agent, or nil if there's no debugging, and debuggee is the
+// program as the agent sees it
+var debugger *jdwp.Server
+var debuggee *debugVM
+
+// The IDs that the debugger sees. Objects are identified by their references, which
+// are well below the class IDs; and threads by their thread IDs, offset so they're
+// above the class IDs.
+const (
+	classIDBase  = int64(1) << 40
+	threadIDBase = int64(1) << 48
+)
+
+// startDebugger starts the JDWP agent if -agentlib:jdwp was given and, with
+// suspend=y, waits until a debugger has attached and resumed the program
+func startDebugger(gl *globals.Globals) error {
+	if gl.JDWPOptions == "" {
+		return nil
+	}
+	opts, err := jdwp.ParseOptions(gl.JDWPOptions) // already checked by the CLI
+	if err != nil {
+		return messages.Print("JACOBIN-LA-0037", err.Error())
+	}
+	debuggee = &debugVM{gl: gl, classIDs: make(map[string]int64)}
+	server, address, err := jdwp.Start(opts, debuggee)
+	if err != nil {
+		return messages.Print("JACOBIN-LA-0038", err.Error())
+	}
+	if address != "" {
+		fmt.Fprintln(os.Stdout, messages.Text("JACOBIN-LA-0039", address))
+	}
+	debugger = server
+	classloader.ClassPrepared = debugClassPrepared
+	server.WaitForDebugger(threadIDBase)
+	return nil
+}
+
+// stopDebugger tells the debugger that the VM is exiting
+func stopDebugger() {
+	if debugger != nil {
+		debugger.VMDeath()
+	}
+}
+
+// debugSafepoint is called before the instruction at f.pc is executed, while the
+// debugger needs to see each instruction
+func debugSafepoint(f *frame) {
+	loc := jdwp.Location{Tag: jdwp.TagClass, Class: debuggee.classID(f.clName),
+		Method: debuggee.methodID(f), Index: int64(f.pc)}
+	debugger.Safepoint(threadIDBase+int64(f.thread), loc)
+}
+
+// debugClassPrepared tells the debugger that a class has been loaded
+func debugClassPrepared(name string) {
+	class, err := debuggee.Class(debuggee.classID(name))
+	if err != nil {
+		return
+	}
+	thread := currentThreadID()
+	if thread < 0 {
+		thread = 0 // classes loaded before the program starts are loaded for main
+	}
+	debugger.ClassPrepared(threadIDBase+int64(thread), class)
+}
+
+// debugVM implements jdwp.VM
+type debugVM struct {
+	gl         *globals.Globals
+	mutex      sync.Mutex // guards the class IDs
+	classIDs   map[string]int64
+	classNames []string
+}
+
+func (vm *debugVM) Name() (string, string) {
+	return "Jacobin VM", vm.gl.Version
+}
+
+// classID returns the ID of the class, which is assigned when it's first needed
+func (vm *debugVM) classID(name string) int64 {
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	id, present := vm.classIDs[name]
+	if !present {
+		id = classIDBase + int64(len(vm.classNames))
+		vm.classIDs[name] = id
+		vm.classNames = append(vm.classNames, name)
+	}
+	return id
+}
+
+// className returns the name of the class with the ID
+func (vm *debugVM) className(id int64) (string, error) {
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	if id < classIDBase || id >= classIDBase+int64(len(vm.classNames)) {
+		return "", jdwp.ErrInvalidClass
+	}
+	return vm.classNames[id-classIDBase], nil
+}
+
+// classData returns the loaded class with the ID
+func (vm *debugVM) classData(id int64) (*classloader.ClData, error) {
+	name, err := vm.className(id)
+	if err != nil {
+		return nil, err
+	}
+	classloader.MethAreaMutex.RLock()
+	k, present := classloader.Classes[name]
+	classloader.MethAreaMutex.RUnlock()
+	if !present || k.Data == nil {
+		return nil, jdwp.ErrInvalidClass
+	}
+	return k.Data, nil
+}
+
+func (vm *debugVM) describeClass(name string, data *classloader.ClData) jdwp.Class {
+	c := jdwp.Class{ID: vm.classID(name), Tag: jdwp.TagClass, Signature: "L" + name + ";",
+		Status: jdwp.ClassVerified | jdwp.ClassPrepared}
+	if data == nil { // an array class
+		c.Tag, c.Signature, c.Modifiers = jdwp.TagArray, name, 0x0011 // public final
+		return c
+	}
+	if data.Access.ClassIsInterface {
+		c.Tag = jdwp.TagInterface
+	}
+	for _, flag := range []struct {
+		set  bool
+		mask int32
+	}{{data.Access.ClassIsPublic, 0x0001}, {data.Access.ClassIsFinal, 0x0010},
+		{data.Access.ClassIsSuper, 0x0020}, {data.Access.ClassIsInterface, 0x0200},
+		{data.Access.ClassIsAbstract, 0x0400}, {data.Access.ClassIsSynthetic, 0x1000},
+		{data.Access.ClassIsAnnotation, 0x2000}, {data.Access.ClassIsEnum, 0x4000}} {
+		if flag.set {
+			c.Modifiers |= flag.mask
+		}
+	}
+	return c
+}
+
+// ---- threads and frames ----
+
+func (vm *debugVM) Threads() []jdwp.Thread {
+	threadsMutex.Lock()
+	defer threadsMutex.Unlock()
+	var list []jdwp.Thread
+	if _, present := threads[0]; !present && !MainThread.started {
+		list = append(list, jdwp.Thread{ID: threadIDBase, Name: "main", Status: jdwp.ThreadRunning})
+	}
+	for id, t := range threads {
+		thread := jdwp.Thread{ID: threadIDBase + int64(id), Name: fmt.Sprintf("Thread-%d", id),
+			Status: jdwp.ThreadRunning}
+		if id == 0 {
+			thread.Name = "main"
+		}
+		if t.blockedOn != 0 {
+			thread.Status = jdwp.ThreadMonitor
+		}
+		list = append(list, thread)
+	}
+	return list
+}
+
+// javaFrames returns the Java frames of the thread, most recent first
+func (vm *debugVM) javaFrames(thread int64) ([]*frame, error) {
+	id := int(thread - threadIDBase)
+	threadsMutex.Lock()
+	t, present := threads[id]
+	threadsMutex.Unlock()
+	if !present {
+		if id != 0 {
+			return nil, jdwp.ErrInvalidThread
+		}
+		t = &MainThread
+	}
+	var frames []*frame
+	if t.stack != nil {
+		for e := t.stack.Front(); e != nil; e = e.Next() {
+			if f := e.Value.(*frame); f.ftype != 'G' {
+				frames = append(frames, f)
+			}
+		}
+	}
+	return frames, nil
+}
+
+// A frame's ID is its depth from the bottom of the stack, which doesn't change while
+// frames are pushed above it
+func (vm *debugVM) Frames(thread int64) ([]jdwp.Frame, error) {
+	frames, err := vm.javaFrames(thread)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]jdwp.Frame, len(frames))
+	for i, f := range frames {
+		list[i] = jdwp.Frame{ID: int64(len(frames) - i), Location: jdwp.Location{Tag: jdwp.TagClass,
+			Class: vm.classID(f.clName), Method: vm.methodID(f), Index: int64(f.pc)}}
+	}
+	return list, nil
+}
+
+func (vm *debugVM) findFrame(thread, id int64) (*frame, error) {
+	frames, err := vm.javaFrames(thread)
+	if err != nil {
+		return nil, err
+	}
+	if id < 1 || id > int64(len(frames)) {
+		return nil, jdwp.ErrInvalidFrameID
+	}
+	return frames[int64(len(frames))-id], nil
+}
+
+// ---- methods and fields ----
+
+// A method's ID is its index in the class's methods, plus 1
+
+// methodID returns the ID of the frame's method, or 0 if it's not found
+func (vm *debugVM) methodID(f *frame) int64 {
+	classloader.MethAreaMutex.RLock()
+	k, present := classloader.Classes[f.clName]
+	classloader.MethAreaMutex.RUnlock()
+	if !present || k.Data == nil {
+		return 0
+	}
+	for i, m := range k.Data.Methods {
+		if k.Data.CP.Utf8Refs[m.Name] == f.methName &&
+			(f.methType == "" || k.Data.CP.Utf8Refs[m.Desc] == f.methType) {
+			return int64(i + 1)
+		}
+	}
+	return 0
+}
+
+func (vm *debugVM) method(class, method int64) (*classloader.ClData, *classloader.Method, error) {
+	data, err := vm.classData(class)
+	if err != nil {
+		return nil, nil, err
+	}
+	if method < 1 || method > int64(len(data.Methods)) {
+		return nil, nil, jdwp.ErrInvalidMethodID
+	}
+	return data, &data.Methods[method-1], nil
+}
+
+func (vm *debugVM) Methods(class int64) ([]jdwp.Method, error) {
+	data, err := vm.classData(class)
+	if err != nil {
+		return nil, err
+	}
+	var list []jdwp.Method
+	for i, m := range data.Methods {
+		list = append(list, jdwp.Method{ID: int64(i + 1), Name: data.CP.Utf8Refs[m.Name],
+			Signature: data.CP.Utf8Refs[m.Desc], Modifiers: int32(m.AccessFlags)})
+	}
+	return list, nil
+}
+
+func (vm *debugVM) Fields(class int64) ([]jdwp.Field, error) {
+	data, err := vm.classData(class)
+	if err != nil {
+		return nil, err
+	}
+	var list []jdwp.Field
+	for i, f := range data.Fields {
+		list = append(list, jdwp.Field{ID: int64(i + 1), Name: data.CP.Utf8Refs[f.Name],
+			Signature: data.CP.Utf8Refs[f.Desc], Modifiers: int32(f.AccessFlags)})
+	}
+	return list, nil
+}
+
+func (vm *debugVM) LineTable(class, method int64) (jdwp.LineTable, error) {
+	data, m, err := vm.method(class, method)
+	if err != nil {
+		return jdwp.LineTable{}, err
+	}
+	if len(m.CodeAttr.Code) == 0 { // a native or abstract method
+		return jdwp.LineTable{Start: -1, End: -1}, nil
+	}
+	lines, found := classloader.LineNumbers(&data.CP, &m.CodeAttr)
+	if !found {
+		return jdwp.LineTable{}, jdwp.ErrAbsentInformation
+	}
+	table := jdwp.LineTable{End: int64(len(m.CodeAttr.Code) - 1)}
+	for _, line := range lines {
+		table.Lines = append(table.Lines, jdwp.Line{Index: int64(line.StartPc), Number: int32(line.Line)})
+	}
+	return table, nil
+}
+
+func (vm *debugVM) VariableTable(class, method int64) (jdwp.VariableTable, error) {
+	data, m, err := vm.method(class, method)
+	if err != nil {
+		return jdwp.VariableTable{}, err
+	}
+	vars, found := classloader.LocalVariables(&data.CP, &m.CodeAttr)
+	if !found {
+		return jdwp.VariableTable{}, jdwp.ErrAbsentInformation
+	}
+	table := jdwp.VariableTable{}
+	for _, param := range ParseIncomingParamsFromMethTypeString(data.CP.Utf8Refs[m.Desc]) {
+		table.ArgCount++
+		if param == 'D' || param == 'J' {
+			table.ArgCount++
+		}
+	}
+	if m.AccessFlags&0x0008 == 0 { // this, for an instance method
+		table.ArgCount++
+	}
+	for _, v := range vars {
+		table.Variables = append(table.Variables, jdwp.Variable{Start: int64(v.StartPc), Name: v.Name,
+			Signature: v.Desc, Length: int32(v.Length), Slot: int32(v.Slot)})
+	}
+	return table, nil
+}
+
+func (vm *debugVM) Bytecodes(class, method int64) ([]byte, error) {
+	_, m, err := vm.method(class, method)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), m.CodeAttr.Code...), nil
+}
+
+// ---- classes ----
+
+func (vm *debugVM) Classes() []jdwp.Class {
+	classloader.MethAreaMutex.RLock()
+	loaded := make(map[string]*classloader.ClData, len(classloader.Classes))
+	for name, k := range classloader.Classes {
+		if k.Data != nil {
+			loaded[name] = k.Data
+		}
+	}
+	classloader.MethAreaMutex.RUnlock()
+
+	var list []jdwp.Class
+	for name, data := range loaded {
+		list = append(list, vm.describeClass(name, data))
+	}
+	return list
+}
+
+func (vm *debugVM) Class(id int64) (jdwp.Class, error) {
+	name, err := vm.className(id)
+	if err != nil {
+		return jdwp.Class{}, err
+	}
+	if strings.HasPrefix(name, "[") {
+		return vm.describeClass(name, nil), nil
+	}
+	data, err := vm.classData(id)
+	if err != nil {
+		return jdwp.Class{}, err
+	}
+	return vm.describeClass(name, data), nil
+}
+
+// loadedClassID returns the ID of the class if it's loaded, else 0
+func (vm *debugVM) loadedClassID(name string) int64 {
+	classloader.MethAreaMutex.RLock()
+	k, present := classloader.Classes[name]
+	classloader.MethAreaMutex.RUnlock()
+	if !present || k.Data == nil {
+		return 0
+	}
+	return vm.classID(name)
+}
+
+func (vm *debugVM) Superclass(class int64) (int64, error) {
+	name, err := vm.className(class)
+	if err != nil {
+		return 0, err
+	}
+	if strings.HasPrefix(name, "[") {
+		return vm.loadedClassID("java/lang/Object"), nil
+	}
+	data, err := vm.classData(class)
+	if err != nil || data.Access.ClassIsInterface || data.Superclass == "" {
+		return 0, err
+	}
+	return vm.loadedClassID(data.Superclass), nil
+}
+
+func (vm *debugVM) Interfaces(class int64) ([]int64, error) {
+	name, err := vm.className(class)
+	if err != nil || strings.HasPrefix(name, "[") {
+		return nil, err
+	}
+	data, err := vm.classData(class)
+	if err != nil {
+		return nil, err
+	}
+	var list []int64
+	for _, index := range data.Interfaces {
+		if id := vm.loadedClassID(data.CP.Utf8Refs[index]); id != 0 {
+			list = append(list, id)
+		}
+	}
+	return list, nil
+}
+
+func (vm *debugVM) SourceFile(class int64) (string, error) {
+	data, err := vm.classData(class)
+	if err != nil {
+		return "", err
+	}
+	if data.SourceFile == "" {
+		return "", jdwp.ErrAbsentInformation
+	}
+	return data.SourceFile, nil
+}
+
+// ---- values ----
+
+// objectValue returns the tagged value of a reference
+func (vm *debugVM) objectValue(ref int64) jdwp.Value {
+	obj := classloader.GetObject(ref)
+	switch {
+	case obj == nil:
+		return jdwp.Value{Tag: 'L'}
+	case obj.Klass == "java/lang/String":
+		return jdwp.Value{Tag: 's', Value: ref}
+	case strings.HasPrefix(obj.Klass, "["):
+		return jdwp.Value{Tag: '[', Value: ref}
+	default:
+		return jdwp.Value{Tag: 'L', Value: ref}
+	}
+}
+
+// Locals hold floats and doubles as float64 bits; JDWP has floats as float32 bits
+func (vm *debugVM) FrameValues(thread, frameID int64, slots []jdwp.Slot) ([]jdwp.Value, error) {
+	f, err := vm.findFrame(thread, frameID)
+	if err != nil {
+		return nil, err
+	}
+	var values []jdwp.Value
+	for _, slot := range slots {
+		if slot.Index < 0 || int(slot.Index) >= len(f.locals) {
+			return nil, jdwp.ErrInvalidSlot
+		}
+		local := f.locals[slot.Index]
+		switch slot.Tag {
+		case 'F':
+			local = int64(math.Float32bits(float32(math.Float64frombits(uint64(local)))))
+			values = append(values, jdwp.Value{Tag: 'F', Value: local})
+		case 'L', '[':
+			values = append(values, vm.objectValue(local))
+		default:
+			values = append(values, jdwp.Value{Tag: slot.Tag, Value: local})
+		}
+	}
+	return values, nil
+}
+
+func (vm *debugVM) SetFrameValues(thread, frameID int64, slots []int32, values []jdwp.Value) error {
+	f, err := vm.findFrame(thread, frameID)
+	if err != nil {
+		return err
+	}
+	for i, slot := range slots {
+		if slot < 0 || int(slot) >= len(f.locals) {
+			return jdwp.ErrInvalidSlot
+		}
+		v := values[i].Value
+		if values[i].Tag == 'F' {
+			v = int64(math.Float64bits(float64(math.Float32frombits(uint32(v)))))
+		}
+		f.locals[slot] = v
+	}
+	return nil
+}
+
+func (vm *debugVM) ThisObject(thread, frameID int64) (jdwp.Value, error) {
+	f, err := vm.findFrame(thread, frameID)
+	if err != nil {
+		return jdwp.Value{}, err
+	}
+	_, m, err := vm.method(vm.classID(f.clName), vm.methodID(f))
+	if err != nil || m.AccessFlags&0x0008 != 0 || len(f.locals) == 0 { // static
+		return jdwp.Value{Tag: 'L'}, nil
+	}
+	return vm.objectValue(f.locals[0]), nil
+}
+
+func (vm *debugVM) ObjectClass(object int64) (jdwp.Class, error) {
+	obj := classloader.GetObject(object)
+	if obj == nil {
+		return jdwp.Class{}, jdwp.ErrInvalidObject
+	}
+	return vm.Class(vm.classID(obj.Klass))
+}
+
+func (vm *debugVM) StringValue(object int64) (string, error) {
+	s, ok := classloader.GoStringFromRef(object)
+	if !ok {
+		return "", jdwp.ErrInvalidObject
+	}
+	return s, nil
+}
+
+func (vm *debugVM) CreateString(s string) int64 {
+	return classloader.NewStringObject(s)
+}
+
+func (vm *debugVM) ClassPaths() (string, []string) {
+	dir, _ := os.Getwd()
+	return dir, classloader.AppClassPath()
+}
+
+func (vm *debugVM) Exit(status int) {
+	exitVM(status)
+}
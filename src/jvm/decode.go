@@ -0,0 +1,309 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"sync"
+)
+
+// Decoded bytecode. The bytecode of a method is decoded once, when the method is first
+// executed, into an array of instructions with their operands already read: the
+// constants, local variables, and branch offsets, and the CP indexes, along with the
+// classes, fields, and methods that the CP entries refer to, which are resolved at the
+// same time. So the interpreter doesn't read the bytes that follow an opcode and resolve
+// its CP entry every time it executes it. The array is indexed by pc, like the bytecode,
+// so an instruction is found at the pc of its opcode (the entries for the bytes of its
+// operands are unused), and the branch offsets and exception tables work as before.
+//
+// Code that can't be decoded, which a well-formed class file doesn't hold, is rejected
+// with a VerifyError or ClassFormatError, before any of it is executed.
+//
+// With -XX:+DecodeAtLink, the methods of a class are decoded when it's linked, before
+// it's initialized, rather than when each is first executed.
+
+// instruction is a decoded instruction
+type instruction struct {
+	operand  int32      // the constant, local variable, CP index, or branch offset after the opcode
+	constant int32      // the constant that iinc adds
+	ref      *memberRef // what the CP entry refers to, if the instruction has one that resolves
+}
+
+// memberRef is a class, or a field or method of one, as resolved from a CP entry. The
+// sym, the symbol of the field's or method's name and descriptor, is the zero Symbol for
+// a class.
+type memberRef struct {
+	class       string
+	sym         classloader.Symbol
+	isInterface bool // the method is referred to by an interface method reference
+}
+
+// classOf returns the class of the instruction's class reference, which is resolved
+// now if it wasn't when the method was decoded, or "" if the CP entry isn't one
+func (in instruction) classOf(cp *classloader.CPool) string {
+	if in.ref != nil {
+		return in.ref.class
+	}
+	if !classInCP(cp, uint16(in.operand)) {
+		return ""
+	}
+	return resolveClassRef(cp, int(in.operand))
+}
+
+// decodeAtLink is whether the methods are decoded when their class is linked. It's
+// set before the program starts.
+var decodeAtLink bool
+
+// startDecoding decodes the methods at link time if -XX:+DecodeAtLink was given
+func startDecoding(gl *globals.Globals) {
+	decodeAtLink = gl.Flags.Bool("DecodeAtLink")
+}
+
+// the decoded methods are keyed by their MTable entries, which all the frames of a
+// method share
+var decodedMethods sync.Map // *classloader.JmEntry -> []instruction
+
+// decodedCode returns the decoded code of the method, which it decodes the first time,
+// or the VerifyError or ClassFormatError of code that can't be decoded
+func decodedCode(m *classloader.JmEntry) ([]instruction, error) {
+	if len(m.Code) == 0 {
+		return nil, nil
+	}
+	if decoded, present := decodedMethods.Load(m); present {
+		return decoded.([]instruction), nil
+	}
+	code, err := decode(m.Code, m.Cp)
+	if err != nil {
+		return nil, err
+	}
+	decoded, _ := decodedMethods.LoadOrStore(m, code)
+	return decoded.([]instruction), nil
+}
+
+// frameCode returns the decoded code of the frame: that of its method or, for a frame
+// that wasn't created for a method in the MTable, its own
+func frameCode(f *frame) ([]instruction, error) {
+	var code []instruction
+	var err error
+	if f.method != nil {
+		code, err = decodedCode(f.method)
+	} else {
+		code, err = decode(f.meth, f.cp)
+	}
+	if err != nil {
+		err = fmt.Errorf("%w in method %s%s of class %s", err, f.methName, f.methType, f.clName)
+		_ = log.Log(err.Error(), log.SEVERE)
+	}
+	return code, err
+}
+
+// decodeClass decodes the methods of the class as it's linked, if -XX:+DecodeAtLink
+// was given. The class isn't linked if the code of one of its methods can't be decoded.
+func decodeClass(data *classloader.ClData) error {
+	if !decodeAtLink {
+		return nil
+	}
+	for i := range data.Methods {
+		meth := &data.Methods[i]
+		if len(meth.CodeAttr.Code) == 0 {
+			continue
+		}
+		name, desc := data.CP.Utf8Refs[meth.Name], data.CP.Utf8Refs[meth.Desc]
+		mte, err := classloader.FetchMethodAndCP(data.Name, name, desc)
+		if err != nil || mte.MType != 'J' {
+			continue
+		}
+		if _, err = decodedCode(mte.Meth.(*classloader.JmEntry)); err != nil {
+			return fmt.Errorf("%w in method %s%s of class %s", err, name, desc, data.Name)
+		}
+	}
+	return nil
+}
+
+// decode decodes the code of a method whose class has the CP. Code that a class file
+// can't hold is rejected: an unknown opcode, an instruction whose operands run past the
+// end of the code, or a CP index outside the CP is a VerifyError, and a CP entry that
+// refers to entries outside the CP is a ClassFormatError.
+func decode(code []byte, cp *classloader.CPool) ([]instruction, error) {
+	decoded := make([]instruction, len(code))
+	for pc := 0; pc < len(code); {
+		op := code[pc]
+		if op > JSR_W { // breakpoint and the opcodes after it don't appear in class files
+			return nil, fmt.Errorf("java.lang.VerifyError: Invalid bytecode found: %d at location %d", op, pc)
+		}
+		length := instructionLength(code, pc)
+		if length <= 0 || pc+length > len(code) {
+			return nil, fmt.Errorf("java.lang.VerifyError: Truncated %s at location %d", BytecodeNames[op], pc)
+		}
+		in := &decoded[pc]
+		switch {
+		case op == BIPUSH:
+			in.operand = int32(int8(code[pc+1]))
+		case op == SIPUSH || (op >= IFEQ && op <= GOTO) || op == IFNULL || op == IFNONNULL:
+			in.operand = int32(int16(uint16(code[pc+1])<<8 | uint16(code[pc+2])))
+		case op == IINC:
+			in.operand = int32(code[pc+1])
+			in.constant = int32(int8(code[pc+2]))
+		case op == LDC:
+			in.operand = int32(code[pc+1])
+			if !inCP(cp, in.operand) {
+				return nil, badCPIndex(op, pc, in.operand)
+			}
+		case length == 2: // newarray, and the loads and stores of local variables
+			in.operand = int32(code[pc+1])
+		case op == LDC_W || op == LDC2_W || (op >= GETSTATIC && op <= INVOKEDYNAMIC) ||
+			op == NEW || op == ANEWARRAY || op == CHECKCAST || op == INSTANCEOF || op == MULTINEWARRAY:
+			in.operand = int32(code[pc+1])<<8 | int32(code[pc+2])
+			if !inCP(cp, in.operand) {
+				return nil, badCPIndex(op, pc, in.operand)
+			}
+			ref, err := resolveRef(cp, op, int(in.operand))
+			if err != nil {
+				return nil, err
+			}
+			in.ref = ref
+		}
+		pc += length
+	}
+	return decoded, nil
+}
+
+// inCP returns whether the index is that of an entry in the CP
+func inCP(cp *classloader.CPool, index int32) bool {
+	return cp != nil && index > 0 && int(index) < len(cp.CpIndex)
+}
+
+// badCPIndex returns the VerifyError of an instruction whose CP index is outside the CP
+func badCPIndex(op byte, pc int, index int32) error {
+	return fmt.Errorf("java.lang.VerifyError: Illegal constant pool index %d for %s at location %d",
+		index, BytecodeNames[op], pc)
+}
+
+// resolveRef returns what the CP entry at the index, as used by the opcode, refers to,
+// or nil if it's not the kind of entry the opcode uses (which is reported when the
+// instruction is executed). The index is in the CP; the entries the entry refers to
+// must be too, or the class is malformed.
+func resolveRef(cp *classloader.CPool, op byte, index int) (*memberRef, error) {
+	entry := cp.CpIndex[index]
+	slot := int(entry.Slot)
+	switch op {
+	case GETSTATIC, PUTSTATIC, GETFIELD, PUTFIELD:
+		if entry.Type == classloader.FieldRef {
+			if slot >= len(cp.FieldRefs) ||
+				!memberInCP(cp, cp.FieldRefs[slot].ClassIndex, cp.FieldRefs[slot].NameAndType) {
+				return nil, malformedCPEntry(index)
+			}
+			class, name, desc := resolveFieldRef(cp, entry)
+			return &memberRef{class: class, sym: classloader.SymbolFor(name, desc)}, nil
+		}
+	case INVOKEVIRTUAL, INVOKESPECIAL, INVOKESTATIC, INVOKEINTERFACE:
+		switch entry.Type {
+		case classloader.MethodRef:
+			if slot >= len(cp.MethodRefs) ||
+				!memberInCP(cp, cp.MethodRefs[slot].ClassIndex, cp.MethodRefs[slot].NameAndType) {
+				return nil, malformedCPEntry(index)
+			}
+			class, name, desc := resolveMethodRef(cp, entry)
+			return &memberRef{class: class, sym: classloader.SymbolFor(name, desc)}, nil
+		case classloader.Interface:
+			if slot >= len(cp.InterfaceRefs) ||
+				!memberInCP(cp, cp.InterfaceRefs[slot].ClassIndex, cp.InterfaceRefs[slot].NameAndType) {
+				return nil, malformedCPEntry(index)
+			}
+			class, name, desc := resolveInterfaceMethodRef(cp, entry)
+			return &memberRef{class: class, sym: classloader.SymbolFor(name, desc), isInterface: true}, nil
+		}
+	case NEW, ANEWARRAY, CHECKCAST, INSTANCEOF:
+		if entry.Type == classloader.ClassRef {
+			if !classInCP(cp, uint16(index)) {
+				return nil, malformedCPEntry(index)
+			}
+			return &memberRef{class: resolveClassRef(cp, index)}, nil
+		}
+	}
+	return nil, nil
+}
+
+// malformedCPEntry returns the ClassFormatError of a CP entry that refers to entries
+// outside the CP
+func malformedCPEntry(index int) error {
+	return fmt.Errorf("java.lang.ClassFormatError: Invalid constant pool reference in entry %d", index)
+}
+
+// utf8InCP returns whether the CP entry at the index is a UTF8 entry
+func utf8InCP(cp *classloader.CPool, index uint16) bool {
+	return inCP(cp, int32(index)) && cp.CpIndex[index].Type == classloader.UTF8 &&
+		int(cp.CpIndex[index].Slot) < len(cp.Utf8Refs)
+}
+
+// classInCP returns whether the CP entry at the index is a class reference whose name
+// is in the CP
+func classInCP(cp *classloader.CPool, index uint16) bool {
+	if !inCP(cp, int32(index)) || cp.CpIndex[index].Type != classloader.ClassRef {
+		return false
+	}
+	slot := int(cp.CpIndex[index].Slot)
+	return slot < len(cp.ClassRefs) && utf8InCP(cp, cp.ClassRefs[slot])
+}
+
+// memberInCP returns whether the class reference and NameAndType entry of a field or
+// method reference, and the names they refer to, are in the CP
+func memberInCP(cp *classloader.CPool, class, nameAndType uint16) bool {
+	if !classInCP(cp, class) || !inCP(cp, int32(nameAndType)) ||
+		cp.CpIndex[nameAndType].Type != classloader.NameAndType {
+		return false
+	}
+	slot := int(cp.CpIndex[nameAndType].Slot)
+	return slot < len(cp.NameAndTypes) && utf8InCP(cp, cp.NameAndTypes[slot].NameIndex) &&
+		utf8InCP(cp, cp.NameAndTypes[slot].DescIndex)
+}
+
+// instructionLength returns the length of the instruction at pc, with its operands,
+// or 0 if its operands run past the end of the code
+func instructionLength(code []byte, pc int) int {
+	switch op := code[pc]; op {
+	case BIPUSH, LDC, ILOAD, LLOAD, FLOAD, DLOAD, ALOAD, ISTORE, LSTORE, FSTORE, DSTORE, ASTORE,
+		RET, NEWARRAY:
+		return 2
+	case SIPUSH, LDC_W, LDC2_W, IINC, GETSTATIC, PUTSTATIC, GETFIELD, PUTFIELD, INVOKEVIRTUAL,
+		INVOKESPECIAL, INVOKESTATIC, NEW, ANEWARRAY, CHECKCAST, INSTANCEOF, IFNULL, IFNONNULL:
+		return 3
+	case MULTINEWARRAY:
+		return 4
+	case INVOKEINTERFACE, INVOKEDYNAMIC, GOTO_W, JSR_W:
+		return 5
+	case WIDE: // the instruction it widens
+		if pc+1 < len(code) && code[pc+1] == IINC {
+			return 6
+		}
+		return 4
+	case TABLESWITCH, LOOKUPSWITCH: // the operands begin at the next multiple of 4
+		at := (pc + 4) &^ 3
+		if at+12 > len(code) {
+			return 0
+		}
+		if op == TABLESWITCH {
+			low, high := switchOperand(code, at+4), switchOperand(code, at+8)
+			if high < low {
+				return 0
+			}
+			return at + 12 + 4*(int(high)-int(low)+1) - pc
+		}
+		pairs := switchOperand(code, at+4)
+		if pairs < 0 {
+			return 0
+		}
+		return at + 8 + 8*int(pairs) - pc
+	default:
+		if op >= IFEQ && op <= JSR {
+			return 3
+		}
+		return 1
+	}
+}
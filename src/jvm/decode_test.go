@@ -0,0 +1,145 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"strings"
+	"testing"
+)
+
+func TestDecodeOperands(t *testing.T) {
+	code := []byte{
+		BIPUSH, 0xFE, // 0: -2
+		SIPUSH, 0xFF, 0x38, // 2: -200
+		IINC, 3, 0xFF, // 5: local 3 -= 1
+		ALOAD, 200, // 8
+		TABLESWITCH, 0, // 10, padded to 12
+		0, 0, 0, 20, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 10, 0, 0, 0, 14, // 12: default, low, high, 2 offsets
+		GOTO, 0xFF, 0xEF, // 32: back to 15
+		INVOKESTATIC, 0, 1, // 35
+	}
+	cp := testCP("test/Decoded.run(I)V")
+	decoded, err := decode(code, cp)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[int]int32{0: -2, 2: -200, 5: 3, 8: 200, 32: -17, 35: 1}
+	for pc, operand := range expected {
+		if decoded[pc].operand != operand {
+			t.Errorf("Expected the operand of %s at %d to be %d, got %d",
+				BytecodeNames[code[pc]], pc, operand, decoded[pc].operand)
+		}
+	}
+	if decoded[5].constant != -1 {
+		t.Errorf("Expected the iinc to add -1, got %d", decoded[5].constant)
+	}
+	ref := decoded[35].ref
+	if ref == nil || ref.class != "test/Decoded" || ref.sym != classloader.SymbolFor("run", "(I)V") || ref.isInterface {
+		t.Errorf("Expected the invokestatic's method to be resolved, got %+v", ref)
+	}
+	if length := instructionLength(code, 10); length != 22 {
+		t.Errorf("Expected the tableswitch to take 22 bytes, got %d", length)
+	}
+}
+
+func TestDecodeLeavesMismatchedRefUnresolved(t *testing.T) {
+	// a getfield whose CP entry is a method reference
+	decoded, err := decode([]byte{GETFIELD, 0, 1, INVOKEVIRTUAL, 0, 1}, testCP("test/Decoded.run()V"))
+	if err != nil || decoded[0].ref != nil || decoded[3].ref == nil {
+		t.Errorf("Expected only the method reference of invokevirtual to be resolved")
+	}
+
+	f := newFrame(ALOAD_0)
+	f.meth = append(f.meth, GETFIELD, 0, 1)
+	f.cp = testCP("test/Decoded.run()V")
+	f.locals = append(f.locals, 0)
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err == nil {
+		t.Error("Expected getfield of a method reference to fail")
+	}
+}
+
+func TestDecodedCodeIsShared(t *testing.T) {
+	m := &classloader.JmEntry{Code: []byte{ICONST_1, IRETURN}}
+	first, _ := decodedCode(m)
+	if second, _ := decodedCode(m); &first[0] != &second[0] {
+		t.Error("Expected the code of a method to be decoded once")
+	}
+	other, _ := decodedCode(&classloader.JmEntry{Code: m.Code})
+	if &other[0] == &first[0] {
+		t.Error("Expected the code to be kept by method, not by its bytes")
+	}
+}
+
+func TestDecodeRejectsMalformedCode(t *testing.T) {
+	cp := testCP("test/Decoded.run()V")
+	malformed := classloader.CPool{CpIndex: append([]classloader.CpEntry(nil), cp.CpIndex...),
+		MethodRefs: []classloader.MethodRefEntry{{ClassIndex: 200, NameAndType: 3}}}
+	tests := []struct {
+		code      []byte
+		cp        *classloader.CPool
+		exception string
+	}{
+		{[]byte{ICONST_1, 0xCB, RETURN}, cp, "java.lang.VerifyError: Invalid bytecode found: 203 at location 1"},
+		{[]byte{ICONST_1, SIPUSH, 1}, cp, "java.lang.VerifyError: Truncated SIPUSH at location 1"},
+		{[]byte{INVOKESTATIC, 0, 99, RETURN}, cp, "java.lang.VerifyError: Illegal constant pool index 99"},
+		{[]byte{LDC, 0, RETURN}, cp, "java.lang.VerifyError: Illegal constant pool index 0"},
+		{[]byte{GETSTATIC, 0, 1}, nil, "java.lang.VerifyError: Illegal constant pool index 1"},
+		{[]byte{INVOKESTATIC, 0, 1}, &malformed, "java.lang.ClassFormatError: Invalid constant pool reference in entry 1"},
+	}
+	for _, test := range tests {
+		if _, err := decode(test.code, test.cp); err == nil || !strings.HasPrefix(err.Error(), test.exception) {
+			t.Errorf("Expected %s decoding % X, got %v", test.exception, test.code, err)
+		}
+	}
+}
+
+func TestUndecodableMethodIsNotRun(t *testing.T) {
+	f := newFrame(ICONST_1)
+	f.meth = append(f.meth, GOTO, 0) // truncated
+	f.methName, f.methType, f.clName = "run", "()V", "test/Decoded"
+	f.method = &classloader.JmEntry{Code: f.meth}
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+	if err == nil || err.Error() != "java.lang.VerifyError: Truncated GOTO at location 1 in method run()V of class test/Decoded" {
+		t.Errorf("Expected a VerifyError, got %v", err)
+	}
+	if f.tos != -1 {
+		t.Error("Expected none of the method to be executed")
+	}
+}
+
+func TestRefErrorOutsideCP(t *testing.T) {
+	f := newFrame(GETFIELD)
+	f.meth = append(f.meth, 0, 9)
+	f.methName, f.methType, f.clName = "run", "()V", "test/Decoded"
+	f.cp = testCP("test/Decoded.run()V")
+	err := refError(&f, instruction{operand: 9}, "JACOBIN-IN-0003")
+	if err == nil || !strings.HasPrefix(err.Error(), "java.lang.VerifyError: Illegal constant pool index 9") {
+		t.Errorf("Expected a VerifyError, got %v", err)
+	}
+}
+
+func TestDecodeAtLinkRefusesMalformedClass(t *testing.T) {
+	class := "test/Malformed"
+	data := &classloader.ClData{Name: class, CP: *testCP(class + ".run()V")}
+	addTestMethod(data, "ok", "()V", 1, []byte{RETURN})
+	addTestMethod(data, "run", "()V", 1, []byte{ICONST_1, 0xFE, RETURN})
+	classloader.Classes[class] = classloader.Klass{Status: 'L', Loader: "app", Data: data}
+	defer delete(classloader.Classes, class)
+	decodeAtLink = true
+	defer func() { decodeAtLink = false }()
+
+	err := decodeClass(data)
+	if err == nil || err.Error() !=
+		"java.lang.VerifyError: Invalid bytecode found: 254 at location 1 in method run()V of class test/Malformed" {
+		t.Errorf("Expected the class not to be linked, got %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/messages"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Diagnostic commands, as with the JDK's jcmd. A running VM listens on a local socket,
+// named after its process ID in the temp directory, and
+//     jacobin cmd <pid> <command>
+// sends it a command and prints the result. jacobin cmd by itself lists the VMs that
+// are running, and jacobin stack <pid> prints the VM's thread dump, as jstack does.
+// A command is one line of text; the reply is the text of the result, after which
+// the VM closes the connection. -XX:+DisableAttachMechanism turns this off.
+
+// diagnosticSocketPrefix is the start of the name of the socket of each VM
+const diagnosticSocketPrefix = ".jacobin_pid"
+
+// diagnosticCommand is a command and what it shows
+type diagnosticCommand struct {
+	description string
+	run         func(w io.Writer, gl *globals.Globals)
+}
+
+var diagnosticCommands = map[string]diagnosticCommand{
+	"GC.class_histogram": {"the number and size of the objects of each class", classHistogram},
+	"JDK.checkpoint":     {"take a checkpoint of the program, which ends it", requestCheckpoint},
+	"Thread.print":       {"the stack trace of every thread", func(w io.Writer, gl *globals.Globals) { dumpThreads(w) }},
+	"VM.command_line":    {"the command line that started the VM", commandLine},
+	"VM.flags":           {"the -XX flags and their values", func(w io.Writer, gl *globals.Globals) { printFlagsFinal(w, gl) }},
+	"VM.uptime":          {"how long the VM has been running", uptime},
+	"VM.version":         {"the VM and JDK versions", vmVersion},
+}
+
+var diagnosticListener net.Listener
+
+// diagnosticSocket returns the path of the socket of the VM with the process ID
+func diagnosticSocket(pid int) string {
+	return filepath.Join(os.TempDir(), diagnosticSocketPrefix+strconv.Itoa(pid))
+}
+
+// startDiagnosticServer starts listening for diagnostic commands. Failing to is logged,
+// but the program runs anyway.
+func startDiagnosticServer(gl *globals.Globals) {
+	if gl.Flags.Bool("DisableAttachMechanism") {
+		return
+	}
+	path := diagnosticSocket(os.Getpid())
+	_ = os.Remove(path) // left behind by an earlier process with the same ID
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		log.Log("Diagnostic commands are unavailable: "+err.Error(), log.FINE)
+		return
+	}
+	diagnosticListener = listener
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return // the listener was closed
+			}
+			go serveDiagnosticCommand(conn, gl)
+		}
+	}()
+}
+
+// stopDiagnosticServer stops listening and removes the socket
+func stopDiagnosticServer() {
+	if diagnosticListener != nil {
+		_ = diagnosticListener.Close() // which removes the socket
+		diagnosticListener = nil
+	}
+}
+
+// serveDiagnosticCommand reads a command and writes its result
+func serveDiagnosticCommand(conn net.Conn, gl *globals.Globals) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(time.Minute))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	w := bufio.NewWriter(conn)
+	runDiagnosticCommand(w, strings.TrimSpace(line), gl)
+	_ = w.Flush()
+}
+
+// runDiagnosticCommand writes the result of the command
+func runDiagnosticCommand(w io.Writer, name string, gl *globals.Globals) {
+	if name == "help" || name == "" {
+		var names []string
+		for name := range diagnosticCommands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintln(w, "The following commands are available:")
+		for _, name := range names {
+			fmt.Fprintf(w, "%-20s %s\n", name, diagnosticCommands[name].description)
+		}
+		fmt.Fprintf(w, "%-20s %s\n", "help", "this list")
+		return
+	}
+	cmd, present := diagnosticCommands[name]
+	if !present {
+		fmt.Fprintln(w, messages.Text("JACOBIN-LA-0040", name))
+		return
+	}
+	cmd.run(w, gl)
+}
+
+// classHistogram shows the objects by class, in the format of jcmd's
+func classHistogram(w io.Writer, gl *globals.Globals) {
+	fmt.Fprintln(w, " num     #instances         #bytes  class name")
+	fmt.Fprintln(w, "----------------------------------------------")
+	var instances, bytes int64
+	for i, c := range classloader.ClassHistogram() {
+		fmt.Fprintf(w, "%4d: %14d %14d  %s\n", i+1, c.Instances, c.Bytes, strings.ReplaceAll(c.Class, "/", "."))
+		instances += c.Instances
+		bytes += c.Bytes
+	}
+	fmt.Fprintf(w, "Total %14d %14d\n", instances, bytes)
+}
+
+func commandLine(w io.Writer, gl *globals.Globals) {
+	fmt.Fprintln(w, strings.Join(gl.Args, " "))
+}
+
+func uptime(w io.Writer, gl *globals.Globals) {
+	fmt.Fprintf(w, "%.3f s\n", time.Since(gl.StartTime).Seconds())
+}
+
+func vmVersion(w io.Writer, gl *globals.Globals) {
+	javaVersion, _ := globals.SystemProperties.Get("java.version")
+	fmt.Fprintf(w, "Jacobin VM version %s\nJDK %s\n", gl.Version, javaVersion)
+}
+
+// ---- the clients: jacobin cmd and jacobin stack ----
+
+// diagnosticClient carries out jacobin cmd [<pid> <command>] and returns the exit status
+func diagnosticClient(out io.Writer, args []string) int {
+	if len(args) == 0 {
+		listVMs(out)
+		return exitOK
+	}
+	reply, ok := commandToVM(args[0], strings.Join(args[1:], " "))
+	if !ok {
+		return exitUsageError
+	}
+	fmt.Fprintf(out, "%s:\n%s", args[0], reply)
+	return exitOK
+}
+
+// threadDumpClient carries out jacobin stack <pid>, which prints the stack trace of
+// every thread of the VM, as the JDK's jstack does, and returns the exit status
+func threadDumpClient(out io.Writer, args []string) int {
+	if len(args) != 1 {
+		_ = messages.Print("JACOBIN-LA-0055")
+		return exitUsageError
+	}
+	reply, ok := commandToVM(args[0], "Thread.print")
+	if !ok {
+		return exitUsageError
+	}
+	fmt.Fprintf(out, "%s\n%s", time.Now().Format("2006-01-02 15:04:05"), reply)
+	return exitOK
+}
+
+// commandToVM sends the command to the VM whose process ID is given, and returns the
+// reply. If it can't, it shows why and returns false.
+func commandToVM(pidArg, command string) (string, bool) {
+	pid, err := strconv.Atoi(pidArg)
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0041", pidArg)
+		return "", false
+	}
+	reply, err := sendDiagnosticCommand(pid, command)
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0042", pidArg, err.Error())
+		return "", false
+	}
+	return reply, true
+}
+
+// sendDiagnosticCommand sends the command to the VM and returns the reply
+func sendDiagnosticCommand(pid int, command string) (string, error) {
+	conn, err := net.DialTimeout("unix", diagnosticSocket(pid), 5*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if _, err = io.WriteString(conn, command+"\n"); err != nil {
+		return "", err
+	}
+	reply, err := ioutil.ReadAll(conn)
+	return string(reply), err
+}
+
+// listVMs shows the process ID and command line of each VM that accepts commands
+func listVMs(out io.Writer) {
+	paths, _ := filepath.Glob(filepath.Join(os.TempDir(), diagnosticSocketPrefix+"*"))
+	for _, path := range paths {
+		pid, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(path), diagnosticSocketPrefix))
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		if reply, err := sendDiagnosticCommand(pid, "VM.command_line"); err == nil {
+			fmt.Fprintf(out, "%d %s", pid, reply)
+		}
+	}
+}
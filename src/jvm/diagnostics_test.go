@@ -0,0 +1,94 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"jacobin/globals"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticCommands(t *testing.T) {
+	gl := globals.InitGlobals("test")
+	gl.Args = []string{"jacobin", "-cp", "classes", "Hello"}
+	startDiagnosticServer(&gl)
+	defer stopDiagnosticServer()
+	if diagnosticListener == nil {
+		t.Skip("the diagnostic socket couldn't be created here")
+	}
+
+	for command, want := range map[string]string{
+		"help":               "Thread.print",
+		"VM.uptime":          " s\n",
+		"VM.flags":           "DisableAttachMechanism",
+		"VM.version":         "Jacobin VM version " + gl.Version,
+		"VM.command_line":    "jacobin -cp classes Hello\n",
+		"GC.class_histogram": "Total",
+		"Thread.print":       "Full thread dump",
+		"GC.run":             "Unknown diagnostic command: GC.run",
+	} {
+		reply, err := sendDiagnosticCommand(os.Getpid(), command)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		if !strings.Contains(reply, want) {
+			t.Errorf("%s: expected %q, got: %s", command, want, reply)
+		}
+	}
+}
+
+func TestDiagnosticClientErrors(t *testing.T) {
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	var out bytes.Buffer
+	notANumber := diagnosticClient(&out, []string{"notapid", "help"})
+	notAVM := diagnosticClient(&out, []string{"0", "help"}) // no VM has process ID 0
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+	msg, _ := ioutil.ReadAll(r)
+
+	if notANumber == exitOK || !strings.Contains(string(msg), "notapid is not a process ID") {
+		t.Errorf("expected an error for a pid that isn't a number, got: %s", msg)
+	}
+	if notAVM == exitOK || !strings.Contains(string(msg), "could not send the command to process 0") {
+		t.Errorf("expected an error for a process that isn't a VM, got: %s", msg)
+	}
+}
+
+func TestThreadDumpClient(t *testing.T) {
+	gl := globals.InitGlobals("test")
+	startDiagnosticServer(&gl)
+	defer stopDiagnosticServer()
+	if diagnosticListener == nil {
+		t.Skip("the diagnostic socket couldn't be created here")
+	}
+
+	var out bytes.Buffer
+	if status := threadDumpClient(&out, []string{strconv.Itoa(os.Getpid())}); status != exitOK {
+		t.Fatalf("expected jacobin stack to succeed, got status %d", status)
+	}
+	if !strings.Contains(out.String(), "Full thread dump") {
+		t.Errorf("expected the thread dump, got: %s", out.String())
+	}
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	status := threadDumpClient(&out, nil)
+	_ = w.Close()
+	os.Stderr = normalStderr
+	msg, _ := ioutil.ReadAll(r)
+	if status == exitOK || !strings.Contains(string(msg), "Usage: jacobin stack <pid>") {
+		t.Errorf("expected the usage message without a pid, got: %s", msg)
+	}
+}
@@ -0,0 +1,251 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/messages"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// --diff runs the program twice, with Jacobin and with the java in JAVA_HOME (or on the
+// PATH), and reports where their stdout, stderr, and exit statuses differ. It's the
+// quickest way to find, and report, where Jacobin isn't compatible with the JDK:
+//
+//	jacobin --diff -cp classes com/example/Main.class arg1 arg2
+//
+// Jacobin gets the command line as given, less --diff. java gets the options it shares
+// with Jacobin; the options only Jacobin has, such as -XX flags and -trace, are left
+// out. A class file is run as java runs a class: from the directory its package is in.
+// Neither program gets any input. Before the outputs are compared, Jacobin's banner
+// is removed, line endings are made the same, and the source positions in stack traces
+// (which Jacobin doesn't always know) are dropped.
+
+// jacobinOnlyOptions are the options that aren't passed to java
+var jacobinOnlyOptions = map[string]bool{
+	"--diff": true, "--dry-run": true, "--no-color": true, "-agentlib": true, "-showversion": true,
+	"--show-version": true, "-trace": true, "-verbose": true, "-Xcheck": true, "-XX": true,
+}
+
+// runResult is what a run of the program produced
+type runResult struct {
+	stdout string
+	stderr string
+	status int
+}
+
+// runProgram runs the executable with the args; tests replace it
+var runProgram = defaultRunProgram
+
+// defaultRunProgram runs the executable with the args, without input
+func defaultRunProgram(executable string, args []string) (runResult, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return runResult{stdout.String(), stderr.String(), exitErr.ExitCode()}, nil
+	}
+	return runResult{stdout.String(), stderr.String(), 0}, err
+}
+
+// runDiff runs the program with Jacobin and java and writes the differences to out.
+// args are the command-line args, of which args[0] is the name of the executable. It
+// returns the exit status of --diff, which is exitOK only if there were no differences.
+func runDiff(args []string, gl *globals.Globals, out io.Writer) int {
+	if gl.StartingClass == "" && gl.StartingSource == "" && gl.StartingJar == "" &&
+		gl.StartingModule == "" {
+		_ = messages.Print("JACOBIN-LA-0024")
+		return exitUsageError
+	}
+	java, err := findJDKTool("java", gl)
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0058")
+		return exitUsageError
+	}
+	expanded, err := expandArgFiles(args[1:])
+	if err != nil {
+		return exitUsageError
+	}
+	javaArgs, err := javaArgsFor(expanded, gl)
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0059", java, err.Error())
+		return exitClassNotFound
+	}
+	jacobin, err := os.Executable()
+	if err != nil {
+		jacobin = args[0]
+	}
+	var jacobinArgs []string
+	for _, arg := range args[1:] {
+		if arg != "--diff" {
+			jacobinArgs = append(jacobinArgs, arg)
+		}
+	}
+
+	fromJacobin, err := runProgram(jacobin, jacobinArgs)
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0059", jacobin, err.Error())
+		return exitUsageError
+	}
+	fromJava, err := runProgram(java, javaArgs)
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0059", java, err.Error())
+		return exitUsageError
+	}
+
+	differences := diffOutput(out, "stdout",
+		normalizeOutput(fromJacobin.stdout, true), normalizeOutput(fromJava.stdout, false))
+	differences += diffOutput(out, "stderr",
+		normalizeOutput(fromJacobin.stderr, true), normalizeOutput(fromJava.stderr, false))
+	if !sameStatus(fromJacobin.status, fromJava.status) {
+		fmt.Fprintf(out, "exit status differs: Jacobin %d, java %d\n", fromJacobin.status, fromJava.status)
+		differences++
+	}
+	if differences == 0 {
+		fmt.Fprintln(out, "No differences from java")
+		return exitOK
+	}
+	fmt.Fprintf(out, "%d difference(s) from java (%s)\n", differences, java)
+	return 1
+}
+
+// javaArgsFor returns the args with which java runs the program that Jacobin runs with
+// the args
+func javaArgsFor(args []string, gl *globals.Globals) ([]string, error) {
+	var javaArgs []string
+	classPath, hasClassPath := "", false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case strings.HasSuffix(arg, ".class"):
+			className, err := classloader.ClassNameInFile(arg)
+			if err != nil {
+				return nil, err
+			}
+			root, err := classRoot(arg, className)
+			if err != nil {
+				return nil, err
+			}
+			if hasClassPath {
+				root += string(os.PathListSeparator) + classPath
+			}
+			javaArgs = append(javaArgs, "-cp", root, strings.ReplaceAll(className, "/", "."))
+			return append(javaArgs, args[i+1:]...), nil
+		case !strings.HasPrefix(arg, "-") || arg == "-jar" || arg == "-m" || arg == "--module":
+			// a source file, which java runs too, or a JAR or module, which are on the class path
+			if hasClassPath {
+				javaArgs = append(javaArgs, "-cp", classPath)
+			}
+			return append(javaArgs, args[i:]...), nil
+		}
+
+		option := arg
+		if root, ok := getPrefixOptionRoot(arg, gl); ok {
+			option = root
+		} else {
+			option, _, _ = getOptionRootAndArgs(arg)
+		}
+		opt := gl.Options[option]
+		value := ""
+		if (opt.ArgStyle == 4 || opt.ArgStyle == 8) && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		switch {
+		case jacobinOnlyOptions[option]:
+		case opt.ArgStyle == 8: // the class path, which the class file's directory is added to
+			classPath, hasClassPath = value, true
+		case opt.ArgStyle == 4:
+			javaArgs = append(javaArgs, arg, value)
+		default:
+			javaArgs = append(javaArgs, arg)
+		}
+	}
+	return javaArgs, nil
+}
+
+// classRoot returns the directory that the package of the class in the class file is
+// in, which is the directory java finds the class in
+func classRoot(classFile, className string) (string, error) {
+	path, err := filepath.Abs(classFile)
+	if err != nil {
+		return "", err
+	}
+	suffix := string(os.PathSeparator) + filepath.FromSlash(className) + ".class"
+	if !strings.HasSuffix(path, suffix) {
+		return "", errors.New(classFile + " isn't in the directory of package " +
+			strings.ReplaceAll(className, "/", "."))
+	}
+	root := strings.TrimSuffix(path, suffix)
+	if root == "" {
+		root = string(os.PathSeparator)
+	}
+	return root, nil
+}
+
+// sourcePositionRE matches the source position at the end of a line of a stack trace
+var sourcePositionRE = regexp.MustCompile(`^(\s+at [^\s(]+)\(.*\)$`)
+
+// normalizeOutput returns the output as it's compared
+func normalizeOutput(output string, fromJacobin bool) []string {
+	output = strings.ReplaceAll(output, "\r\n", "\n")
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if fromJacobin && isBanner(line) {
+			continue
+		}
+		line = strings.TrimRight(line, " \t")
+		lines = append(lines, sourcePositionRE.ReplaceAllString(line, "$1"))
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffOutput writes the first line at which the two outputs of the stream differ, if
+// they do, and returns the number of differences: 0 or 1
+func diffOutput(out io.Writer, stream string, jacobin, java []string) int {
+	for i := 0; i < len(jacobin) || i < len(java); i++ {
+		if i < len(jacobin) && i < len(java) && jacobin[i] == java[i] {
+			continue
+		}
+		fmt.Fprintf(out, "%s differs at line %d (Jacobin has %d lines, java %d):\n",
+			stream, i+1, len(jacobin), len(java))
+		fmt.Fprintf(out, "  Jacobin: %s\n", lineOrEnd(jacobin, i))
+		fmt.Fprintf(out, "  java:    %s\n", lineOrEnd(java, i))
+		return 1
+	}
+	return 0
+}
+
+func lineOrEnd(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return "<end of output>"
+}
+
+// sameStatus reports whether Jacobin's exit status is the one java gave. The statuses
+// Jacobin has for failures before the program starts (see exit.go) are all java's 1.
+func sameStatus(jacobin, java int) bool {
+	switch jacobin {
+	case exitUsageError, exitClassNotFound, exitCompilationFailed:
+		return java == 1 || java == jacobin
+	}
+	return jacobin == java
+}
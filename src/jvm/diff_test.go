@@ -0,0 +1,143 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// emptyClassFile returns a class file for the class, which has no members
+func emptyClassFile(name string) []byte {
+	var class bytes.Buffer
+	u2 := func(v int) { _ = binary.Write(&class, binary.BigEndian, uint16(v)) }
+	utf8 := func(s string) {
+		class.WriteByte(1)
+		u2(len(s))
+		class.WriteString(s)
+	}
+	_ = binary.Write(&class, binary.BigEndian, uint32(0xCAFEBABE))
+	u2(0)
+	u2(52)
+	u2(5)
+	class.WriteByte(7)
+	u2(2)
+	utf8(name)
+	class.WriteByte(7)
+	u2(4)
+	utf8("java/lang/Object")
+	for _, v := range []int{0x21, 1, 3, 0, 0, 0, 0} {
+		u2(v)
+	}
+	return class.Bytes()
+}
+
+func TestJavaArgsFor(t *testing.T) {
+	Global = globals.InitGlobals("test")
+	LoadOptionsTable(Global)
+	dir, _ := ioutil.TempDir("", "jacobin-diff")
+	defer os.RemoveAll(dir)
+	classFile := filepath.Join(dir, "com", "example", "Main.class")
+	_ = os.MkdirAll(filepath.Dir(classFile), 0755)
+	_ = ioutil.WriteFile(classFile, emptyClassFile("com/example/Main"), 0644)
+
+	args, err := javaArgsFor([]string{"-XX:+PrintVMSummary", "-Dx=y", "-cp", "lib.jar", "-trace",
+		"--add-modules", "java.sql", classFile, "a1", "--diff"}, &Global)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"-Dx=y", "--add-modules", "java.sql",
+		"-cp", dir + string(os.PathListSeparator) + "lib.jar", "com.example.Main", "a1", "--diff"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected java args %q, got %q", expected, args)
+	}
+
+	args, _ = javaArgsFor([]string{"-cp", "lib", "Hello.java", "a1"}, &Global)
+	if !reflect.DeepEqual(args, []string{"-cp", "lib", "Hello.java", "a1"}) {
+		t.Errorf("Expected a source file to be passed to java, got %q", args)
+	}
+
+	misplaced := filepath.Join(dir, "Main.class")
+	_ = ioutil.WriteFile(misplaced, emptyClassFile("com/example/Main"), 0644)
+	if _, err = javaArgsFor([]string{misplaced}, &Global); err == nil {
+		t.Error("Expected a class file outside its package's directory to be refused")
+	}
+}
+
+func TestNormalizeOutput(t *testing.T) {
+	jacobin := normalizeOutput("Jacobin VM v. 0.1.0, © 2021-2 by Andrew Binstock.\r\n"+
+		"Exception in thread \"main\" java.lang.ArithmeticException: / by zero\r\n"+
+		"\tat Main.main(Unknown Source)  \r\n\r\n", true)
+	java := normalizeOutput("Exception in thread \"main\" java.lang.ArithmeticException: / by zero\n"+
+		"\tat Main.main(Main.java:3)\n", false)
+	if !reflect.DeepEqual(jacobin, java) {
+		t.Errorf("Expected the outputs to be the same, got %q and %q", jacobin, java)
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	Global = globals.InitGlobals("test")
+	Global.StartingSource = "Hello.java"
+	Global.JavaHome, _ = ioutil.TempDir("", "jacobin-jdk")
+	defer os.RemoveAll(Global.JavaHome)
+	java := filepath.Join(Global.JavaHome, "bin", "java")
+	if runtime.GOOS == "windows" {
+		java += ".exe"
+	}
+	_ = os.MkdirAll(filepath.Dir(java), 0755)
+	_ = ioutil.WriteFile(java, nil, 0755)
+
+	results := map[string]runResult{}
+	var ran [][]string
+	runProgram = func(executable string, args []string) (runResult, error) {
+		ran = append(ran, append([]string{executable}, args...))
+		if executable == java {
+			return results["java"], nil
+		}
+		return results["jacobin"], nil
+	}
+	defer func() { runProgram = defaultRunProgram }()
+
+	results["jacobin"] = runResult{stdout: "Jacobin VM v. 0.1.0\nHello\n"}
+	results["java"] = runResult{stdout: "Hello\n"}
+	var out strings.Builder
+	if status := runDiff([]string{"jacobin", "--diff", "Hello.java"}, &Global, &out); status != exitOK {
+		t.Errorf("Expected no differences, got %q", out.String())
+	}
+	if len(ran) != 2 || !reflect.DeepEqual(ran[0][1:], []string{"Hello.java"}) ||
+		!reflect.DeepEqual(ran[1], []string{java, "Hello.java"}) {
+		t.Errorf("Unexpected commands: %q", ran)
+	}
+
+	results["jacobin"] = runResult{stdout: "Hello\nWorld\n", status: 1}
+	out.Reset()
+	if status := runDiff([]string{"jacobin", "--diff", "Hello.java"}, &Global, &out); status == exitOK {
+		t.Error("Expected the differences to be reported")
+	}
+	for _, s := range []string{"stdout differs at line 2", "Jacobin: World", "java:    <end of output>",
+		"exit status differs: Jacobin 1, java 0", "2 difference(s)"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("Expected the report to contain %q, got %q", s, out.String())
+		}
+	}
+
+	// Jacobin's own status for a class that can't be found is java's 1
+	results["jacobin"] = runResult{stderr: "Error: could not find or load main class Hello\n",
+		status: exitClassNotFound}
+	results["java"] = runResult{stderr: "Error: could not find or load main class Hello\n", status: 1}
+	out.Reset()
+	if status := runDiff([]string{"jacobin", "--diff", "Hello.java"}, &Global, &out); status != exitOK {
+		t.Errorf("Expected no differences, got %q", out.String())
+	}
+}
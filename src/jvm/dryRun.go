@@ -0,0 +1,58 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"io"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/messages"
+	"os"
+	"strings"
+)
+
+// --dry-run does everything needed to run the program--processes the options, loads
+// and links the main class and the classes it references, and finds main()--and then,
+// rather than running main(), prints a summary of what would have run and exits. It's
+// meant for debugging launch configurations.
+
+// dryRun checks the main class's main() method and writes the summary to out. It
+// returns an error (after telling the user) if the program could not have been run.
+func dryRun(mainClass string, gl *globals.Globals, out io.Writer) error {
+	globals.LoaderWg.Wait() // let the referenced classes finish loading
+
+	classloader.MethAreaMutex.RLock()
+	k, present := classloader.Classes[mainClass]
+	classCount := len(classloader.Classes)
+	classloader.MethAreaMutex.RUnlock()
+
+	hasMain := false
+	if present && k.Data != nil {
+		for _, m := range k.Data.Methods {
+			if k.Data.CP.Utf8Refs[m.Name] == "main" &&
+				k.Data.CP.Utf8Refs[m.Desc] == "([Ljava/lang/String;)V" &&
+				m.AccessFlags&(classloader.ACC_PUBLIC|classloader.ACC_STATIC) ==
+					classloader.ACC_PUBLIC|classloader.ACC_STATIC {
+				hasMain = true
+			}
+		}
+	}
+	className := strings.ReplaceAll(mainClass, "/", ".")
+	if !hasMain {
+		return messages.Print("JACOBIN-LA-0008", className)
+	}
+
+	classPath := strings.Join(classloader.AppClassPath(), string(os.PathListSeparator))
+	fmt.Fprintln(out, "Dry run: the program was not run. It would have run:")
+	fmt.Fprintf(out, "  main class:     %s (from %s)\n", className, gl.StartingClass)
+	fmt.Fprintln(out, "  main method:    public static void main(String[] args)")
+	fmt.Fprintf(out, "  arguments:      [%s]\n", strings.Join(gl.AppArgs, ", "))
+	fmt.Fprintf(out, "  class path:     %s\n", classPath)
+	fmt.Fprintf(out, "  JACOBIN_HOME:   %s\n", gl.JacobinHome)
+	fmt.Fprintf(out, "  classes loaded: %d\n", classCount)
+	return nil
+}
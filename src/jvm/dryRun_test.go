@@ -0,0 +1,80 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"os"
+	"strings"
+	"testing"
+)
+
+// addMainClass adds a loaded class with a method of the given name, type, and flags
+func addMainClass(name, methName, methType string, flags int) {
+	data := &classloader.ClData{Name: name,
+		CP:      classloader.CPool{Utf8Refs: []string{methName, methType}},
+		Methods: []classloader.Method{{AccessFlags: flags, Name: 0, Desc: 1}}}
+	classloader.MethAreaMutex.Lock()
+	classloader.Classes[name] = classloader.Klass{Status: 'F', Loader: "app", Data: data}
+	classloader.MethAreaMutex.Unlock()
+}
+
+func TestDryRunSummary(t *testing.T) {
+	gl := globals.InitGlobals("test")
+	gl.StartingClass = "demo/Main.class"
+	gl.AppArgs = []string{"one", "two"}
+	addMainClass("demo/Main", "main", "([Ljava/lang/String;)V", 0x0009)
+
+	var out bytes.Buffer
+	if err := dryRun("demo/Main", &gl, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{"demo.Main (from demo/Main.class)", "arguments:      [one, two]"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected the summary to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestDryRunWithoutMain(t *testing.T) {
+	gl := globals.InitGlobals("test")
+	addMainClass("demo/NoMain", "main", "([Ljava/lang/String;)V", 0x0001) // not static
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	var out bytes.Buffer
+	err := dryRun("demo/NoMain", &gl, &out)
+	_ = w.Close()
+	os.Stderr = normalStderr
+	msg, _ := ioutil.ReadAll(r)
+
+	if err == nil || !strings.Contains(string(msg), "Main method not found in class demo.NoMain") {
+		t.Errorf("Expected a missing main() to be reported, got %v (%s)", err, msg)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no summary, got:\n%s", out.String())
+	}
+}
+
+func TestDryRunOption(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	normalStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+	_ = HandleCli([]string{"jacobin", "--dry-run", "Main.class"}, &global)
+	_ = w.Close()
+	os.Stdout = normalStdout
+
+	if !global.DryRun {
+		t.Error("Expected --dry-run to be set")
+	}
+}
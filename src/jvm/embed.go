@@ -0,0 +1,214 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/messages"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Embedding. A Go program can run Java code with the VM as a library, rather than by
+// running the jacobin executable:
+//
+//	vm := jvm.New(jvm.Options{ClassPath: "app.jar", Stdout: &out})
+//	res, err := vm.Run("com/example/Main", []string{"arg"})
+//
+// The run ends when the program does: when main() and the other non-daemon threads
+// end, when Java code calls System.exit(), or when the host calls Exit(). The host
+// process keeps running, and the exit status is returned in the Result. Java threads
+// that are still running then stop at their next bytecode; threads blocked in a wait or
+// in I/O stay blocked.
+//
+// The host can also supply System.in, and follow the run's events, such as exceptions
+// and the end of the run, over channels (see embedEvents.go).
+//
+// The VM's state (the loaded classes, the heap, the threads, and the globals) belongs to
+// the process, so, as with JNI_CreateJavaVM() in the JDK, a process can run only one VM,
+// and only once. An embedded VM also leaves the shutdown signals (such as Ctrl-C) to the
+// host, as -Xrs does.
+
+// Options configures an embedded VM
+type Options struct {
+	Options   []string  // command-line options, such as -Dkey=value or -verbose:class
+	ClassPath string    // the class path, as -cp sets it (empty: CLASSPATH, or .)
+	Stdin     io.Reader // what System.in reads (nil: the process's stdin)
+	Stdout    io.Writer // where System.out writes (nil: the process's stdout)
+	Stderr    io.Writer // where System.err and the VM's messages write (nil: stderr)
+
+	// Files, if set, is the file system that classes and resources are read from, in
+	// which the class path's entries are found. An in-memory one, such as a
+	// classloader.MemoryFS, runs the VM where there's no file system, as in a browser.
+	Files fs.FS
+}
+
+// Result is the outcome of a run
+type Result struct {
+	// ExitStatus is the status the java launcher would exit with: 0 if the program
+	// completed normally, the status passed to System.exit(), or 1 if main() threw an
+	// exception or the program couldn't be started.
+	ExitStatus int
+}
+
+// VM is an embedded VM
+type VM struct {
+	opts   Options
+	done   chan struct{} // closed when the run ends
+	once   sync.Once
+	status int
+	err    error // why the main class couldn't be run, if it couldn't
+}
+
+// ErrVMExists is returned by Start() if the process has already started a VM
+var ErrVMExists = errors.New("jacobin: a VM has already been started in this process")
+
+// ErrVMStarted is returned by RegisterNative() once the VM has been started
+var ErrVMStarted = errors.New("jacobin: natives must be registered before the VM is started")
+
+// the VM the process has started, if any
+var embeddedVM *VM
+var embeddedMutex sync.Mutex
+
+// embedded is set when the VM is started by a Go program. Its threads end once halted
+// is set, since its process doesn't.
+var embedded bool
+var halted int32
+
+// New returns a VM with the options, which is started by Start() or Run()
+func New(opts Options) *VM {
+	return &VM{opts: opts, done: make(chan struct{})}
+}
+
+// RegisterNative registers the Go function as the implementation of a native method of
+// an application class, which is named with its class and descriptor, as in
+// com/example/Calc.add(II)I. This is how Java code calls into the host program. The
+// function takes and returns the method's values as the Go types listed in
+// classloader/natives.go: a primitive as the Go type of its size, and a String or an
+// array as either a reference (an int64) or a Go string or slice. For an instance
+// method, the function's first parameter is the reference to this, which is how it's
+// told from a static one. Other objects can be converted with package marshal. Natives
+// are registered before the VM is started.
+func (vm *VM) RegisterNative(method string, fn interface{}) error {
+	embeddedMutex.Lock()
+	defer embeddedMutex.Unlock()
+	if embeddedVM != nil {
+		return ErrVMStarted
+	}
+	paren := strings.Index(method, "(")
+	if paren < 0 {
+		return errors.New("jacobin: invalid native method name (no descriptor): " + method)
+	}
+	params, _, err := classloader.ParseDescriptor(method[paren:])
+	if err != nil {
+		return err
+	}
+	ft := reflect.TypeOf(fn)
+	isStatic := ft == nil || ft.Kind() != reflect.Func || ft.NumIn() != len(params)+1
+	return classloader.RegisterNative(method, isStatic, fn)
+}
+
+// Start starts running the main class, whose name can be either in the internal form
+// (com/example/Main) or the binary form (com.example.Main), with the args. It returns
+// once the VM has begun starting up.
+func (vm *VM) Start(mainClass string, args []string) error {
+	embeddedMutex.Lock()
+	defer embeddedMutex.Unlock()
+	if embeddedVM != nil {
+		return ErrVMExists
+	}
+	embeddedVM = vm
+	embedded = true
+	osExit = vm.end
+
+	cmdLine := append([]string{"jacobin"}, vm.opts.Options...)
+	if vm.opts.ClassPath != "" {
+		cmdLine = append(cmdLine, "-cp", vm.opts.ClassPath)
+	}
+	go vm.boot(cmdLine, strings.ReplaceAll(mainClass, ".", "/"), args)
+	return nil
+}
+
+// boot starts the VM as Main() does, on the goroutine that calls it
+func (vm *VM) boot(cmdLine []string, mainClass string, args []string) {
+	defer reportCrash(nil)
+	Global = globals.InitGlobals(cmdLine[0])
+	log.Init()
+	if vm.opts.Stderr != nil {
+		_ = log.SetOutput(log.Tags(), vm.opts.Stderr)
+		messages.SetOutput(vm.opts.Stderr)
+	}
+	classloader.SetStdStreams(vm.opts.Stdout, vm.opts.Stderr)
+	if vm.opts.Stdin != nil {
+		classloader.SetStdin(vm.opts.Stdin)
+	}
+	if vm.opts.Files != nil {
+		classloader.SetFileSystem(vm.opts.Files)
+	}
+	classloader.ReduceSignalUsage = true
+	classloader.InitConsole()
+	startVM(cmdLine, mainClass, args)
+}
+
+// Wait waits for the run to end and returns its result. The error is non-nil if the
+// main class couldn't be found.
+func (vm *VM) Wait() (Result, error) {
+	<-vm.done
+	return Result{ExitStatus: vm.status}, vm.err
+}
+
+// Run runs the main class with the args, as Start() does, and waits for it to end
+func (vm *VM) Run(mainClass string, args []string) (Result, error) {
+	if err := vm.Start(mainClass, args); err != nil {
+		return Result{}, err
+	}
+	return vm.Wait()
+}
+
+// Exit ends the run with the status, as System.exit() does: the shutdown hooks are run,
+// and then the VM stops. It returns once the run has ended.
+func (vm *VM) Exit(status int) {
+	embeddedMutex.Lock()
+	started := embeddedVM == vm
+	embeddedMutex.Unlock()
+	if !started {
+		return
+	}
+	go exitVM(status)
+	<-vm.done
+}
+
+// end is osExit for an embedded VM. It records the status and ends the calling
+// goroutine, which is one of the VM's, rather than the process.
+func (vm *VM) end(status int) {
+	vm.once.Do(func() {
+		vm.status = status
+		atomic.StoreInt32(&halted, 1)
+		sendEvent(Event{Kind: VMExited, Status: status})
+		close(vm.done)
+	})
+	runtime.Goexit()
+}
+
+// classOnClassPath returns the file of the main class of an embedded VM, which is found
+// on the class path. If there's none, the error is also the run's.
+func classOnClassPath(name string) (string, error) {
+	file, found := classloader.ClassFileOnClassPath(name)
+	if !found {
+		err := messages.Print("JACOBIN-CL-0003", name, strings.ReplaceAll(name, "/", "."))
+		embeddedVM.err = err
+		return "", err
+	}
+	return file, nil
+}
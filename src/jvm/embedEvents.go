@@ -0,0 +1,126 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/agent"
+	"sync"
+)
+
+// Events for embedders. The host program can follow an embedded VM's run over
+// channels, as it follows signals with os/signal:
+//
+//	events := make(chan jvm.Event, 16)
+//	vm.Notify(events, jvm.VMStarted, jvm.UncaughtException, jvm.VMExited)
+//
+// As with signal.Notify(), the VM doesn't wait for a channel to be read: an event that
+// doesn't fit in the channel's buffer is dropped, so that a slow host can't stall the
+// program. The events come from an agent (see agent/agent.go) that the VM registers
+// once there's a subscriber, so, as with any agent, the program runs a little slower.
+
+// EventKind is the kind of an Event
+type EventKind int
+
+const (
+	VMStarted         EventKind = iota + 1 // the VM is ready, and main() is about to run
+	VMExited                               // the run has ended, with the exit status in Status
+	ThreadStarted                          // a Java thread has started
+	ThreadEnded                            // a Java thread has ended
+	ExceptionThrown                        // an exception was thrown, caught or not
+	UncaughtException                      // an exception ended a thread
+)
+
+// Event is something that happened in the VM
+type Event struct {
+	Kind       EventKind
+	Thread     int    // the ID of the thread it happened on, if any
+	ThreadName string // for ThreadStarted, ThreadEnded, and UncaughtException
+	Exception  string // the exception, as in "java.lang.ArithmeticException: / by zero"
+	Class      string // the class and method that threw the exception, as in
+	Method     string // java/lang/Integer and parseInt(Ljava/lang/String;)I
+	Status     int    // for VMExited
+}
+
+// subscription is a channel and the kinds of event it gets
+type subscription struct {
+	c     chan<- Event
+	kinds map[EventKind]bool // all of them, if empty
+}
+
+var (
+	subscriptions      []subscription
+	subscriptionsMutex sync.RWMutex
+	eventAgentOnce     sync.Once
+)
+
+// Notify sends the events of the kinds, or of every kind if none are given, to the
+// channel. It can be called before or while the VM runs.
+func (vm *VM) Notify(c chan<- Event, kinds ...EventKind) {
+	sub := subscription{c: c, kinds: make(map[EventKind]bool)}
+	for _, kind := range kinds {
+		sub.kinds[kind] = true
+	}
+	subscriptionsMutex.Lock()
+	subscriptions = append(subscriptions, sub)
+	subscriptionsMutex.Unlock()
+	eventAgentOnce.Do(func() { agent.Register(eventAgent{}) })
+}
+
+// Stop stops sending events to the channel
+func (vm *VM) Stop(c chan<- Event) {
+	subscriptionsMutex.Lock()
+	kept := subscriptions[:0:0]
+	for _, sub := range subscriptions {
+		if sub.c != c {
+			kept = append(kept, sub)
+		}
+	}
+	subscriptions = kept
+	subscriptionsMutex.Unlock()
+}
+
+// sendEvent sends the event to the channels that want it, without blocking
+func sendEvent(e Event) {
+	subscriptionsMutex.RLock()
+	defer subscriptionsMutex.RUnlock()
+	for _, sub := range subscriptions {
+		if len(sub.kinds) > 0 && !sub.kinds[e.Kind] {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+		}
+	}
+}
+
+// notifyUncaught sends the UncaughtException event
+func notifyUncaught(thread int, name string, err error) {
+	sendEvent(Event{Kind: UncaughtException, Thread: thread, ThreadName: name, Exception: err.Error()})
+}
+
+// eventAgent turns the agent events into Events. VMExited is sent by the VM as it
+// ends, since the agents aren't told the exit status.
+type eventAgent struct {
+	agent.Hooks
+}
+
+func (eventAgent) OnVMInit() {
+	sendEvent(Event{Kind: VMStarted})
+}
+
+func (eventAgent) OnThreadStart(thread int, name string) {
+	sendEvent(Event{Kind: ThreadStarted, Thread: thread, ThreadName: name})
+}
+
+func (eventAgent) OnThreadEnd(thread int, name string) {
+	sendEvent(Event{Kind: ThreadEnded, Thread: thread, ThreadName: name})
+}
+
+func (eventAgent) OnExceptionThrow(thread int, exception, class, method, desc string) {
+	sendEvent(Event{Kind: ExceptionThrown, Thread: thread, Exception: exception,
+		Class: class, Method: method + desc})
+}
@@ -0,0 +1,195 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"jacobin/agent"
+	"jacobin/classloader"
+	"jacobin/messages"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// inChildProcess runs the test in a child process, since a process can start only one
+// embedded VM, and the VM's state would also be left behind for the other tests. The
+// child is the test binary, running just the test. It returns true in the child.
+func inChildProcess(t *testing.T) bool {
+	if os.Getenv("JACOBIN_EMBED_TEST") == t.Name() {
+		return true
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$", "-test.count=1")
+	cmd.Env = append(os.Environ(), "JACOBIN_EMBED_TEST="+t.Name())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("%s in the child process:\n%s", err.Error(), out)
+	}
+	return false
+}
+
+func TestEmbeddedRun(t *testing.T) {
+	if !inChildProcess(t) {
+		return
+	}
+	var out, errOut bytes.Buffer
+	vm := New(Options{ClassPath: filepath.Join("..", "..", "testdata"), Stdout: &out, Stderr: &errOut})
+	res, err := vm.Run("Hello2", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if res.ExitStatus != exitOK {
+		t.Errorf("Expected exit status 0, got %d", res.ExitStatus)
+	}
+	if !strings.HasPrefix(out.String(), "-1\n1\n3\n") {
+		t.Errorf("Expected the program's output to be captured, got: %q", out.String())
+	}
+	if strings.Contains(out.String(), "Jacobin VM v.") {
+		t.Errorf("Expected no copyright banner, got: %q", out.String())
+	}
+
+	if _, err := New(Options{}).Run("Hello2", nil); err != ErrVMExists {
+		t.Errorf("Expected ErrVMExists for a second VM, got: %v", err)
+	}
+}
+
+func TestEmbeddedRunFromMemory(t *testing.T) {
+	if !inChildProcess(t) {
+		return
+	}
+	class, err := os.ReadFile(filepath.Join("..", "..", "testdata", "Hello2.class"))
+	if err != nil {
+		t.Skip("testdata/Hello2.class not found")
+	}
+	var out bytes.Buffer
+	files := classloader.MemoryFS{"app/Hello2.class": class}
+	vm := New(Options{ClassPath: "app", Stdout: &out, Files: files})
+	res, err := vm.Run("Hello2", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if res.ExitStatus != exitOK {
+		t.Errorf("Expected exit status 0, got %d", res.ExitStatus)
+	}
+	if !strings.HasPrefix(out.String(), "-1\n1\n3\n") {
+		t.Errorf("Expected the program's output, got: %q", out.String())
+	}
+}
+
+func TestEmbeddedRunEvents(t *testing.T) {
+	if !inChildProcess(t) {
+		return
+	}
+	events := make(chan Event, 64)
+	vm := New(Options{ClassPath: filepath.Join("..", "..", "testdata"), Stdin: strings.NewReader(""),
+		Stdout: ioutil.Discard})
+	vm.Notify(events, VMStarted, VMExited)
+	res, err := vm.Run("Hello2", nil)
+	if err != nil || res.ExitStatus != exitOK {
+		t.Fatalf("Expected the run to succeed, got %d, %v", res.ExitStatus, err)
+	}
+	if e := <-events; e.Kind != VMStarted {
+		t.Errorf("Expected VMStarted first, got %+v", e)
+	}
+	if e := <-events; e.Kind != VMExited || e.Status != exitOK {
+		t.Errorf("Expected VMExited with status 0 last, got %+v", e)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("Expected only the events subscribed to, got %+v", e)
+	default:
+	}
+}
+
+func TestEventNotification(t *testing.T) {
+	vm := New(Options{})
+	all, exceptions, full := make(chan Event, 4), make(chan Event, 4), make(chan Event)
+	vm.Notify(all)
+	vm.Notify(exceptions, ExceptionThrown, UncaughtException)
+	vm.Notify(full)
+	defer vm.Stop(all)
+	defer vm.Stop(exceptions)
+	defer vm.Stop(full)
+	defer func() { // the agent would slow the other tests
+		agent.Unregister(eventAgent{})
+		eventAgentOnce = sync.Once{}
+	}()
+
+	eventAgent{}.OnThreadStart(3, "worker")
+	notifyUncaught(3, "worker", errors.New("java.lang.IllegalStateException: no"))
+	if e := <-all; e.Kind != ThreadStarted || e.ThreadName != "worker" {
+		t.Errorf("Expected ThreadStarted, got %+v", e)
+	}
+	if e := <-all; e.Kind != UncaughtException {
+		t.Errorf("Expected UncaughtException, got %+v", e)
+	}
+	if e := <-exceptions; e.Kind != UncaughtException || e.Exception != "java.lang.IllegalStateException: no" {
+		t.Errorf("Expected only the exception, got %+v", e)
+	}
+
+	vm.Stop(all)
+	sendEvent(Event{Kind: VMExited})
+	if len(all) != 0 {
+		t.Error("Expected no events after Stop()")
+	}
+}
+
+func TestEmbeddedRunMissingClass(t *testing.T) {
+	if !inChildProcess(t) {
+		return
+	}
+	var errOut bytes.Buffer
+	vm := New(Options{ClassPath: filepath.Join("..", "..", "testdata"), Stderr: &errOut})
+	res, err := vm.Run("com.example.Missing", nil)
+	if messages.CodeOf(err) != "JACOBIN-CL-0003" {
+		t.Errorf("Expected JACOBIN-CL-0003, got: %v", err)
+	}
+	if res.ExitStatus != exitClassNotFound {
+		t.Errorf("Expected exit status %d, got %d", exitClassNotFound, res.ExitStatus)
+	}
+	if !strings.Contains(errOut.String(), "com.example.Missing") {
+		t.Errorf("Expected the error on the VM's stderr, got: %q", errOut.String())
+	}
+}
+
+func TestEmbedderNatives(t *testing.T) {
+	vm := New(Options{})
+	err := vm.RegisterNative("test/Scripted.greet(Ljava/lang/String;)Ljava/lang/String;",
+		func(name string) string { return "Hello, " + name })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = vm.RegisterNative("test/Scripted.sum([I)I", func(this int64, ints []int32) int32 {
+		sum := int32(0)
+		for _, n := range ints {
+			sum += n
+		}
+		return sum
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if vm.RegisterNative("test/Scripted.bad(I)V", func(s string) {}) == nil {
+		t.Error("Expected an error for a function that doesn't match the descriptor")
+	}
+	classloader.MTable = make(classloader.MT)
+	classloader.MTableLoadNatives()
+
+	ret, err := invokeMethod("test/Scripted", "greet", "(Ljava/lang/String;)Ljava/lang/String;",
+		[]int64{classloader.NewStringObject("Java")})
+	if s, _ := classloader.GoStringFromRef(ret); err != nil || s != "Hello, Java" {
+		t.Errorf("Expected \"Hello, Java\", got %q (error: %v)", s, err)
+	}
+	this := classloader.NewObject("test/Scripted", 0)
+	ints := classloader.NewPrimitiveArray("I", []int64{1, 2, 39})
+	if ret, err := invokeMethod("test/Scripted", "sum", "([I)I", []int64{this, ints}); err != nil || ret != 42 {
+		t.Errorf("Expected 42, got %d (error: %v)", ret, err)
+	}
+}
@@ -0,0 +1,76 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"jacobin/classloader"
+	"strings"
+)
+
+// Caught exceptions. When an instruction throws an exception, the exception table of
+// the frame's method is searched for a handler whose range of bytecodes includes the
+// instruction and whose class is the exception's class or one of its superclasses
+// (JVMS 2.10). If one is found, the frames above the frame are discarded, the operand
+// stack is emptied, and the Throwable is pushed for the handler, which then runs.
+// Otherwise, the exception passes to the frame's caller. This is how catch and finally
+// blocks work, as do the blocks javac generates for try-with-resources, which call
+// close() on all paths and addSuppressed() when both the body and close() throw.
+
+// javaException is an exception whose Throwable exists as an object, as when athrow
+// throws an exception created by Java code or rethrows one that was caught
+type javaException struct {
+	ref int64 // the Throwable
+}
+
+func (e *javaException) Error() string {
+	return classloader.ThrowableString(e.ref)
+}
+
+// exceptionClass returns the internal name of the exception's class
+func exceptionClass(err error) string {
+	// an error that wraps a javaException is a different exception, whose cause it is
+	if je, ok := err.(*javaException); ok {
+		if obj := classloader.GetObject(je.ref); obj != nil {
+			return obj.Klass
+		}
+	}
+	if name := exceptionName.FindString(err.Error()); name != "" {
+		return strings.ReplaceAll(name, ".", "/")
+	}
+	return "java/lang/InternalError"
+}
+
+// catchException finds the handler in the method of frame f for the exception and,
+// if there is one, prepares f to run it. The frames the exception passed through are
+// still above f on the frame stack fs. It reports whether the exception was caught.
+func catchException(fs *list.List, f *frame, err error) bool {
+	mte, fetchErr := classloader.FetchMethodAndCP(f.clName, f.methName, f.methType)
+	if fetchErr != nil || mte.MType != 'J' {
+		return false
+	}
+	class := exceptionClass(err)
+	for _, handler := range mte.Meth.(*classloader.JmEntry).Exceptions {
+		if f.pc < handler.StartPc || f.pc >= handler.EndPc {
+			continue
+		}
+		// a catch type of 0 catches everything, as for a finally block
+		if handler.CatchType != 0 &&
+			!classloader.IsSubclassOf(class, resolveClassRef(f.cp, int(handler.CatchType))) {
+			continue
+		}
+
+		ref := throwableFromError(err, stackTrace(fs))
+		for fs.Front().Value.(*frame) != f {
+			fs.Remove(fs.Front())
+		}
+		f.tos = -1
+		push(f, ref)
+		f.pc = handler.HandlerPc
+		return true
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"strings"
+	"testing"
+)
+
+// runCatcher runs test/Catcher.run()I, which calls fail(), which throws a
+// NullPointerException with athrow. run() returns 0, or 1 if its handler, which
+// covers the call and catches the class, runs. A class of "" is a finally block.
+func runCatcher(t *testing.T, catchClass string) (int64, error) {
+	class := "test/Catcher"
+	data := &classloader.ClData{Name: class, CP: *testCP(class+".fail()V", catchClass+".x()V")}
+	addTestMethod(data, "run", "()I", 1, []byte{INVOKESTATIC, 0, 1, ICONST_0, IRETURN, POP, ICONST_1, IRETURN})
+	addTestMethod(data, "fail", "()V", 1, []byte{ACONST_NULL, ATHROW})
+
+	catchType := 0
+	for i, e := range data.CP.CpIndex { // the class of the method reference
+		if catchClass != "" && e.Type == classloader.ClassRef && resolveClassRef(&data.CP, i) == catchClass {
+			catchType = i
+		}
+	}
+	data.Methods[0].CodeAttr.Exceptions = []classloader.CodeException{
+		{StartPc: 0, EndPc: 3, HandlerPc: 5, CatchType: uint16(catchType)}}
+	classloader.Classes[class] = classloader.Klass{Status: 'L', Loader: "app", Data: data}
+	defer delete(classloader.Classes, class)
+	classloader.MTable = make(classloader.MT)
+
+	f := newFrame(INVOKESTATIC)
+	f.meth = append(f.meth, 0x00, 0x01)
+	f.cp = testCP(class + ".run()I")
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		return 0, err
+	}
+	return pop(&f), nil
+}
+
+func TestCatchException(t *testing.T) {
+	for _, catchClass := range []string{"java/lang/NullPointerException", "java/lang/RuntimeException", ""} {
+		if result, err := runCatcher(t, catchClass); err != nil || result != 1 {
+			t.Errorf("Expected a handler for %q to catch the exception, got %d, %v", catchClass, result, err)
+		}
+	}
+}
+
+func TestUncaughtByHandler(t *testing.T) {
+	_, err := runCatcher(t, "java/lang/IllegalStateException")
+	if err == nil || !strings.HasPrefix(err.Error(), "java.lang.NullPointerException") {
+		t.Errorf("Expected the NullPointerException to pass the handler, got %v", err)
+	}
+}
+
+// athrow throws the Throwable itself, which is what the handler is given
+func TestAthrowThrowable(t *testing.T) {
+	ref := classloader.NewThrowable("java/io/IOException", &classloader.Throwable{})
+	f := newFrame(ATHROW)
+	push(&f, ref)
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+	if err == nil || err.Error() != "java.io.IOException" || exceptionClass(err) != "java/io/IOException" {
+		t.Fatalf("Expected athrow to throw the IOException, got %v", err)
+	}
+	if throwableFromError(err, nil) != ref {
+		t.Errorf("Expected the error's Throwable to be the one thrown")
+	}
+}
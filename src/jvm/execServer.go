@@ -0,0 +1,318 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"jacobin/messages"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The exec server. jacobin serve [--allow-remote] [<address>] listens on the address
+// (by default, 127.0.0.1:7070) for programs to run, as a grading system would send them.
+// A program is POSTed to /run as JSON:
+//
+//	{"mainClass": "com.example.Main", "args": ["a1"], "stdin": "input",
+//	 "classes": {"com/example/Main.class": "<base64>"}, "jars": {"lib.jar": "<base64>"},
+//	 "timeout": 5}
+//
+// and the reply streams its output as it's written, one JSON event per line, ending
+// with its exit status:
+//
+//	{"stream": "stdout", "text": "Hello\n"}
+//	{"exit": 0}
+//
+// or with {"error": "..."} if the program couldn't be run or ran too long. Each program
+// runs in a VM of its own--a new Jacobin process, so nothing it loads or changes is
+// seen by any other--in a temporary directory that holds its classes and JARs and that
+// is removed when it ends. At most one program per CPU runs at a time; the others wait.
+//
+// The programs aren't sandboxed: they run with the privileges of the user who started
+// the server, and can read and write the files, and open the connections, that the
+// user can. So the server only listens on a loopback address, unless --allow-remote is
+// given, which should be done only where whoever can reach the server is trusted.
+
+// defaultServerAddress is the address jacobin serve listens on if none is given
+const defaultServerAddress = "127.0.0.1:7070"
+
+// maxRunTime is the longest a program can run, and maxRequestSize the largest request
+const (
+	maxRunTime     = time.Minute
+	maxRequestSize = 64 << 20
+)
+
+// execRequest is a program to run
+type execRequest struct {
+	MainClass string            `json:"mainClass"` // such as com.example.Main
+	Args      []string          `json:"args"`
+	Stdin     string            `json:"stdin"`
+	Classes   map[string][]byte `json:"classes"` // the class files by path, such as com/example/Main.class
+	Jars      map[string][]byte `json:"jars"`    // the JARs on the class path, by file name
+	Timeout   float64           `json:"timeout"` // in seconds; 0 is maxRunTime
+}
+
+// execEvent is a line of the reply
+type execEvent struct {
+	Stream string `json:"stream,omitempty"` // stdout or stderr
+	Text   string `json:"text,omitempty"`
+	Exit   *int   `json:"exit,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// jacobinCommand returns the command that runs Jacobin with the args; tests replace it
+var jacobinCommand = func(ctx context.Context, args ...string) *exec.Cmd {
+	self, err := os.Executable()
+	if err != nil {
+		self = os.Args[0]
+	}
+	return exec.CommandContext(ctx, self, args...)
+}
+
+// execServer carries out jacobin serve [--allow-remote] [<address>] and returns the
+// exit status. It serves until the process is ended.
+func execServer(out io.Writer, args []string) int {
+	allowRemote := len(args) > 0 && args[0] == "--allow-remote"
+	if allowRemote {
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		_ = messages.Print("JACOBIN-LA-0061")
+		return exitUsageError
+	}
+	address := defaultServerAddress
+	if len(args) == 1 {
+		address = args[0]
+	}
+	if !allowRemote && !isLoopback(address) {
+		_ = messages.Print("JACOBIN-LA-0064", address)
+		return exitUsageError
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0060", address, err.Error())
+		return exitUsageError
+	}
+	fmt.Fprintf(out, "Running programs POSTed to http://%s/run\n", listener.Addr().String())
+	_ = http.Serve(listener, execHandler(runtime.NumCPU()))
+	return exitOK
+}
+
+// isLoopback returns whether the address, such as 127.0.0.1:7070, is on a loopback
+// interface, so the server can be reached only from this machine. An address without a
+// host, such as :7070, is on every interface.
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// execHandler returns the handler of the exec server, which runs at most maxRunning
+// programs at a time
+func execHandler(maxRunning int) http.Handler {
+	running := make(chan struct{}, maxRunning)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/run", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST a program to run", http.StatusMethodNotAllowed)
+			return
+		}
+		var req execRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+			replyError(w, http.StatusBadRequest, err)
+			return
+		}
+		dir, err := ioutil.TempDir("", "jacobin-exec")
+		if err != nil {
+			replyError(w, http.StatusInternalServerError, err)
+			return
+		}
+		defer os.RemoveAll(dir)
+		vmArgs, err := req.vmArgs(dir)
+		if err != nil {
+			replyError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		select {
+		case running <- struct{}{}:
+			defer func() { <-running }()
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		runForRequest(r.Context(), w, &req, dir, vmArgs)
+	})
+	return mux
+}
+
+// replyError replies with the error as the only event
+func replyError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(execEvent{Error: err.Error()})
+}
+
+// vmArgs writes the request's classes and JARs to the directory and returns the args
+// with which Jacobin runs the program
+func (req *execRequest) vmArgs(dir string) ([]string, error) {
+	classDir := filepath.Join(dir, "classes")
+	classPath := []string{classDir}
+	mainFile := strings.ReplaceAll(req.MainClass, ".", "/") + ".class"
+	mainPath := ""
+	if req.MainClass == "" {
+		return nil, errors.New("no main class")
+	}
+
+	for name, class := range req.Classes {
+		if !fs.ValidPath(name) || !strings.HasSuffix(name, ".class") {
+			return nil, errors.New("invalid class file name: " + name)
+		}
+		path := filepath.Join(classDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(path, class, 0644); err != nil {
+			return nil, err
+		}
+		if name == mainFile {
+			mainPath = path
+		}
+	}
+
+	var jars []string
+	for name := range req.Jars {
+		jars = append(jars, name)
+	}
+	sort.Strings(jars) // the order they're on the class path
+	for _, name := range jars {
+		if !fs.ValidPath(name) || strings.Contains(name, "/") || !strings.HasSuffix(name, ".jar") {
+			return nil, errors.New("invalid JAR name: " + name)
+		}
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, req.Jars[name], 0644); err != nil {
+			return nil, err
+		}
+		classPath = append(classPath, path)
+		if mainPath == "" && jarContains(req.Jars[name], mainFile) {
+			mainPath = path + jarEntrySeparator + mainFile
+		}
+	}
+	if mainPath == "" {
+		return nil, errors.New("the main class " + req.MainClass + " is in none of the classes or JARs")
+	}
+
+	args := []string{"-XX:+DisableAttachMechanism", "-cp",
+		strings.Join(classPath, string(os.PathListSeparator)), mainPath}
+	return append(args, req.Args...), nil
+}
+
+// jarEntrySeparator separates a JAR from an entry in it, as in lib.jar!/com/example/Main.class
+const jarEntrySeparator = "!/"
+
+func jarContains(jar []byte, name string) bool {
+	z, err := zip.NewReader(bytes.NewReader(jar), int64(len(jar)))
+	if err != nil {
+		return false
+	}
+	for _, f := range z.File {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runForRequest runs the program in a new Jacobin process and streams its output and
+// exit status to w
+func runForRequest(ctx context.Context, w io.Writer, req *execRequest, dir string, vmArgs []string) {
+	limit := maxRunTime
+	if req.Timeout > 0 && req.Timeout < limit.Seconds() {
+		limit = time.Duration(req.Timeout * float64(time.Second))
+	}
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+
+	var mutex sync.Mutex // events are written by both streams
+	enc := json.NewEncoder(w)
+	send := func(event execEvent) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		_ = enc.Encode(event)
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	cmd := jacobinCommand(ctx, vmArgs...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(req.Stdin)
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		var stderr io.ReadCloser
+		if stderr, err = cmd.StderrPipe(); err == nil {
+			err = cmd.Start()
+		}
+		if err == nil {
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go streamLines(stdout, "stdout", send, &wg)
+			go streamLines(stderr, "stderr", send, &wg)
+			wg.Wait()
+			err = cmd.Wait()
+		}
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		send(execEvent{Error: "the program ran longer than " + limit.String()})
+	case err == nil || errors.As(err, &exitErr):
+		status := cmd.ProcessState.ExitCode()
+		send(execEvent{Exit: &status})
+	default:
+		send(execEvent{Error: err.Error()})
+	}
+}
+
+// streamLines sends each line read from r as an event of the stream. Jacobin's banner,
+// which the program didn't write, isn't sent.
+func streamLines(r io.Reader, stream string, send func(execEvent), wg *sync.WaitGroup) {
+	defer wg.Done()
+	br := bufio.NewReader(r)
+	for first := true; ; first = false {
+		line, err := br.ReadString('\n')
+		if line != "" && !(first && stream == "stdout" && isBanner(line)) {
+			send(execEvent{Stream: stream, Text: line})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
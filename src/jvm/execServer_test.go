@@ -0,0 +1,135 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestExecServerVM is the Jacobin that the exec server runs in the tests, with the
+// args in JACOBIN_EXEC_TEST
+func TestExecServerVM(t *testing.T) {
+	if args := os.Getenv("JACOBIN_EXEC_TEST"); args != "" {
+		Main(append([]string{"jacobin"}, strings.Split(args, "\n")...))
+	}
+}
+
+// postProgram posts the program to the exec server and returns the events of the reply
+func postProgram(t *testing.T, url string, req execRequest) (int, []execEvent) {
+	body, _ := json.Marshal(req)
+	resp, err := http.Post(url+"/run", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	var events []execEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var event execEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Invalid event %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	return resp.StatusCode, events
+}
+
+func TestExecServer(t *testing.T) {
+	class, err := os.ReadFile(filepath.Join("..", "..", "testdata", "Hello2.class"))
+	if err != nil {
+		t.Skip("testdata/Hello2.class not found")
+	}
+	defaultCommand := jacobinCommand
+	defer func() { jacobinCommand = defaultCommand }()
+	jacobinCommand = func(ctx context.Context, args ...string) *exec.Cmd {
+		cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=^TestExecServerVM$", "-test.count=1")
+		cmd.Env = append(os.Environ(), "JACOBIN_EXEC_TEST="+strings.Join(args, "\n"))
+		return cmd
+	}
+	server := httptest.NewServer(execHandler(2))
+	defer server.Close()
+
+	code, events := postProgram(t, server.URL,
+		execRequest{MainClass: "Hello2", Classes: map[string][]byte{"Hello2.class": class}})
+	if code != http.StatusOK || len(events) == 0 {
+		t.Fatalf("Expected the program's output, got %d: %+v", code, events)
+	}
+	var stdout strings.Builder
+	for _, event := range events[:len(events)-1] {
+		if event.Stream == "stdout" {
+			stdout.WriteString(event.Text)
+		}
+	}
+	if !strings.HasPrefix(stdout.String(), "-1\n1\n3\n") {
+		t.Errorf("Expected Hello2's output without the banner, got %q", stdout.String())
+	}
+	if last := events[len(events)-1]; last.Exit == nil || *last.Exit != exitOK {
+		t.Errorf("Expected the exit status last, got %+v", last)
+	}
+
+	code, events = postProgram(t, server.URL,
+		execRequest{MainClass: "Missing", Classes: map[string][]byte{"Hello2.class": class}})
+	if code != http.StatusBadRequest || len(events) != 1 || !strings.Contains(events[0].Error, "Missing") {
+		t.Errorf("Expected a missing main class to be refused, got %d: %+v", code, events)
+	}
+	code, events = postProgram(t, server.URL,
+		execRequest{MainClass: "Hello2", Classes: map[string][]byte{"../Hello2.class": class}})
+	if code != http.StatusBadRequest || len(events) != 1 || events[0].Error == "" {
+		t.Errorf("Expected a class file outside the directory to be refused, got %d: %+v", code, events)
+	}
+}
+
+func TestExecRequestInJar(t *testing.T) {
+	dir := t.TempDir()
+	var jar bytes.Buffer
+	z := zip.NewWriter(&jar)
+	_, _ = z.Create("com/example/Main.class")
+	_ = z.Close()
+	req := execRequest{MainClass: "com.example.Main", Args: []string{"a1"},
+		Jars: map[string][]byte{"app.jar": jar.Bytes()}}
+	args, err := req.vmArgs(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	classPath := filepath.Join(dir, "classes") + string(os.PathListSeparator) + filepath.Join(dir, "app.jar")
+	expected := []string{"-XX:+DisableAttachMechanism", "-cp", classPath,
+		filepath.Join(dir, "app.jar") + "!/com/example/Main.class", "a1"}
+	if strings.Join(args, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected args %q, got %q", expected, args)
+	}
+}
+
+func TestExecServerListensOnLoopbackOnly(t *testing.T) {
+	for address, loopback := range map[string]bool{"127.0.0.1:7070": true, "localhost:0": true,
+		"[::1]:7070": true, ":7070": false, "0.0.0.0:7070": false, "192.168.1.2:7070": false,
+		"example.com:7070": false, "127.0.0.1": false} {
+		if isLoopback(address) != loopback {
+			t.Errorf("Expected isLoopback(%q) to be %t", address, loopback)
+		}
+	}
+	if !isLoopback(defaultServerAddress) {
+		t.Errorf("Expected the default address %s to be a loopback address", defaultServerAddress)
+	}
+
+	var out bytes.Buffer
+	if status := execServer(&out, []string{"0.0.0.0:0"}); status != exitUsageError || out.Len() != 0 {
+		t.Errorf("Expected a non-loopback address to be refused, got %d: %q", status, out.String())
+	}
+	if status := execServer(&out, []string{"--allow-remote", "0.0.0.0:0", "extra"}); status != exitUsageError {
+		t.Errorf("Expected the usage error for an extra arg, got %d", status)
+	}
+}
@@ -4,7 +4,7 @@
  * Licensed under Mozilla Public License 2.0 (MPL 2.0)
  */
 
-package main
+package jvm
 
 // ParseIncomingParamsFromMethTypeString takes a type string from a CP
 // and parses its passed-in parameters, returning them in reduced form
@@ -33,6 +33,14 @@ func ParseIncomingParamsFromMethTypeString(s string) []byte {
 			params = append(params, 'D')
 		case 'L', '[': // objects and arrays -> object references
 			params = append(params, 'L')
+			for paramChars[i] == '[' {
+				i++
+			}
+			if paramChars[i] == 'L' { // skip the class name, which can contain any letter
+				for paramChars[i] != ';' {
+					i++
+				}
+			}
 		}
 	}
 	return params
@@ -0,0 +1,24 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import "testing"
+
+func TestParseIncomingParams(t *testing.T) {
+	for desc, expected := range map[string]string{
+		"()V":                     "",
+		"(IJ)V":                   "IJ",
+		"(Ljava/lang/String;Z)V":  "LI",
+		"([[Lsun/misc/Signal;D)V": "LD",
+		"([BLjava/lang/Object;)I": "LL",
+		"(CSBFLjava/util/Map;)V":  "IIIFL",
+	} {
+		if params := string(ParseIncomingParamsFromMethTypeString(desc)); params != expected {
+			t.Errorf("Expected %s to have params %q, got %q", desc, expected, params)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/agent"
+	"jacobin/classloader"
+	"jacobin/log"
+	"jacobin/messages"
+	"os"
+	"sync"
+)
+
+// VM termination. The VM exits when System.exit() or Runtime.exit() is called, or when
+// the last non-daemon thread ends. In both cases, the registered shutdown hooks are
+// first started, all at once, and the VM waits for them to finish. Runtime.halt()
+// terminates the VM immediately, without running the hooks. As in the JDK, the hooks
+// run only once: a second call to exit() (from a hook, say) waits for the hooks that
+// are already running, which means a hook that calls exit() blocks forever.
+
+// The exit statuses follow the java launcher's where it has only one: 0 when the program
+// completes normally (and for options such as -version and --help that just print
+// something), and 1 when it ends because of an uncaught exception. For the failures
+// that happen before the program starts, which java reports with 1 as well, Jacobin
+// returns a status of its own, so that a wrapper script can tell them apart without
+// parsing stderr. System.exit() and Runtime.exit() end the VM with exactly the status
+// they're passed. A crash of the VM itself ends it with the status of an abort, as in
+// the JDK.
+const (
+	exitOK                = 0
+	exitUncaughtException = 1   // the program ended because of an uncaught exception
+	exitUsageError        = 2   // bad options, or no main class or JAR specified
+	exitClassNotFound     = 3   // the main class couldn't be found, loaded, or run
+	exitCompilationFailed = 4   // the source file to run didn't compile
+	exitVMError           = 134 // the VM crashed, which the JDK signals by aborting (see crash.go)
+)
+
+var shutdownOnce sync.Once
+
+// osExit is the function that ends the process; tests replace it
+var osExit = os.Exit
+
+// runShutdownHooks starts each shutdown hook on its own thread and waits for them all
+func runShutdownHooks() {
+	shutdownOnce.Do(func() {
+		var hookThreads []*execThread
+		for _, hook := range classloader.BeginShutdown() {
+			if t := startMethodThread(hook, "run", "()V"); t != nil {
+				hookThreads = append(hookThreads, t)
+			}
+		}
+		for _, t := range hookThreads {
+			<-t.done
+		}
+	})
+}
+
+// exitVM implements System.exit() and Runtime.exit()
+func exitVM(status int) {
+	runShutdownHooks()
+	haltVM(status)
+}
+
+// haltVM implements Runtime.halt(). Mapped buffers are written back even so, because
+// with a real memory mapping, the changes would already be in the file.
+func haltVM(status int) {
+	agent.VMDeath()
+	stopDebugger()
+	stopDiagnosticServer()
+	stopFlightRecording()
+	stopProfiling()
+	stopSampler()
+	printMethodStatistics()
+	stopCoverage()
+	printVMSummary()
+	classloader.FlushMappedBuffers()
+	_ = log.Log("shutdown", log.INFO)
+	osExit(status)
+}
+
+// runSignalHandler implements classloader.RunSignalHandler: it calls handle() on the
+// Java signal handler on a new thread
+func runSignalHandler(handler int64, methodType string, sig int64) {
+	startMethodThread(handler, "handle", methodType, sig)
+}
+
+// startMethodThread starts a new thread that runs the named instance method on the
+// object, passing it args (which must be references), and returns the thread. If the
+// object's class has no such method in bytecode, the problem is logged and nil is returned.
+func startMethodThread(ref int64, methodName, methodType string, args ...int64) *execThread {
+	t := newMethodThread(ref, methodName, methodType, args...)
+	if t != nil {
+		startThread(t)
+	}
+	return t
+}
+
+// newMethodThread creates the thread that startMethodThread() starts, which is tied to
+// the object if it's a Thread
+func newMethodThread(ref int64, methodName, methodType string, args ...int64) *execThread {
+	obj := classloader.GetObject(ref)
+	if obj == nil {
+		return nil
+	}
+	me, err := classloader.FetchMethodAndCP(obj.Klass, methodName, methodType)
+	if err != nil || me.MType != 'J' {
+		_ = log.Log(messages.Text("JACOBIN-IN-0018", obj.Klass, methodName, methodType), log.WARNING)
+		return nil
+	}
+
+	t := CreateThread(newThreadID())
+	t.trace = MainThread.trace
+
+	// the method's frame is created as though the method were invoked from a frame
+	// whose operand stack holds only the object reference and the arguments
+	caller := createFrame(1 + len(args))
+	caller.thread = t.id
+	push(caller, ref)
+	for _, arg := range args {
+		push(caller, arg)
+	}
+	f := createJavaFrame(caller, me.Meth.(*classloader.JmEntry), obj.Klass, methodName, methodType, true)
+	if pushFrame(t.stack, f) != nil {
+		return nil
+	}
+	if classloader.IsSubclassOf(obj.Klass, "java/lang/Thread") { // the thread runs the Thread
+		tieThreadObject(ref, &t)
+	}
+	return &t
+}
@@ -0,0 +1,73 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"os"
+	"testing"
+)
+
+func TestExitRunsShutdownHooks(t *testing.T) {
+	// the hook's run() method enters the monitor of the hook object and never exits it,
+	// so the monitor's owner shows that the hook ran, and on which thread
+	classloader.MTable = make(classloader.MT)
+	classloader.MTable[classloader.MethodKey("test/Hook.run()V")] = classloader.MTentry{
+		Meth:  &classloader.JmEntry{MaxStack: 1, MaxLocals: 1, Code: []byte{ALOAD_0, MONITORENTER, RETURN}},
+		MType: 'J',
+	}
+	hook := classloader.NewObject("test/Hook", 0)
+	if err := classloader.AddShutdownHook(hook); err != nil {
+		t.Fatalf("Expected hook to be registered, got: %v", err)
+	}
+	if err := classloader.AddShutdownHook(hook); err == nil ||
+		err.Error() != "java.lang.IllegalArgumentException: Hook previously registered" {
+		t.Errorf("Expected second registration of the same hook to throw, got: %v", err)
+	}
+
+	exitStatus := -1
+	osExit = func(status int) { exitStatus = status }
+	defer func() { osExit = os.Exit }()
+	exitVM(3)
+
+	if exitStatus != 3 {
+		t.Errorf("Expected exit status 3, got: %d", exitStatus)
+	}
+	if owner := monitorOwner(hook); owner <= 0 {
+		t.Errorf("Expected hook to have run on its own thread, but monitor owner is: %d", owner)
+	}
+	if err := classloader.AddShutdownHook(classloader.NewObject("test/Hook", 0)); err == nil ||
+		err.Error() != "java.lang.IllegalStateException: Shutdown in progress" {
+		t.Errorf("Expected hooks not to be accepted once shutdown has begun, got: %v", err)
+	}
+
+	// hooks run only once, and halt() doesn't run them at all
+	exitStatus = -1
+	haltVM(4)
+	if exitStatus != 4 {
+		t.Errorf("Expected halt status 4, got: %d", exitStatus)
+	}
+}
+
+func TestSignalHandlerGetsSignal(t *testing.T) {
+	// the handler's handle() method enters the monitor of the signal it's passed
+	classloader.MTable = make(classloader.MT)
+	classloader.MTable[classloader.MethodKey("test/Handler.handle(Lsun/misc/Signal;)V")] = classloader.MTentry{
+		Meth:  &classloader.JmEntry{MaxStack: 1, MaxLocals: 2, Code: []byte{ALOAD_1, MONITORENTER, RETURN}},
+		MType: 'J',
+	}
+	handler := classloader.NewObject("test/Handler", 0)
+	sig := classloader.NewObject("sun/misc/Signal", 0)
+	th := startMethodThread(handler, "handle", "(Lsun/misc/Signal;)V", sig)
+	if th == nil {
+		t.Fatalf("Expected the handler thread to start")
+	}
+	<-th.done
+	if owner := monitorOwner(sig); owner <= 0 {
+		t.Errorf("Expected the handler to be passed the signal, but monitor owner is: %d", owner)
+	}
+}
@@ -0,0 +1,65 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"io"
+	"jacobin/globals"
+	"jacobin/jfr"
+	"jacobin/log"
+	"jacobin/messages"
+	"os"
+)
+
+// The flight recorder (see the jfr package) is started by
+//     -XX:StartFlightRecording=filename=<path>,allocation-interval=<n>
+// and stopped when the VM exits, and
+//     jacobin jfr <recording>
+// prints the recording as JSON.
+
+// startFlightRecording starts the recording if -XX:StartFlightRecording was given
+func startFlightRecording(gl *globals.Globals) error {
+	options := gl.Flags.String("StartFlightRecording")
+	if options == "" {
+		return nil
+	}
+	opts, err := jfr.ParseOptions(options)
+	if err != nil {
+		return messages.Print("JACOBIN-LA-0044", err.Error())
+	}
+	if err = jfr.Start(opts); err != nil {
+		return messages.Print("JACOBIN-LA-0045", err.Error())
+	}
+	log.Log("Recording flight events to "+opts.Filename, log.INFO)
+	return nil
+}
+
+// stopFlightRecording ends the recording, if there is one, and writes the rest of it
+func stopFlightRecording() {
+	if err := jfr.Stop(); err != nil {
+		_ = messages.Print("JACOBIN-LA-0046", err.Error())
+	}
+}
+
+// printFlightRecording carries out jacobin jfr <recording> and returns the exit status
+func printFlightRecording(out io.Writer, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: jacobin jfr <recording>")
+		return exitUsageError
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0047", args[0], err.Error())
+		return exitUsageError
+	}
+	defer f.Close()
+	if err = jfr.Convert(f, out); err != nil {
+		_ = messages.Print("JACOBIN-LA-0047", args[0], err.Error())
+		return exitUsageError
+	}
+	return exitOK
+}
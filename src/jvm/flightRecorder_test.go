@@ -0,0 +1,59 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"io"
+	"jacobin/globals"
+	"jacobin/jfr"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFlightRecording(t *testing.T) {
+	gl := globals.InitGlobals("test")
+	filename := filepath.Join(t.TempDir(), "test.jfr")
+	_ = gl.Flags.Set("StartFlightRecording=filename=" + filename)
+	if err := startFlightRecording(&gl); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	jfr.ClassLoaded("Hello", "bootstrap")
+	stopFlightRecording()
+
+	var out bytes.Buffer
+	if status := printFlightRecording(&out, []string{filename}); status != exitOK {
+		t.Fatalf("unexpected status %d", status)
+	}
+	if !strings.Contains(out.String(), `"type":"jacobin.ClassLoad"`) ||
+		!strings.Contains(out.String(), `"class":"Hello"`) {
+		t.Errorf("expected the class load event, got %s", out.String())
+	}
+}
+
+func TestFlightRecordingErrors(t *testing.T) {
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	gl := globals.InitGlobals("test")
+	_ = gl.Flags.Set("StartFlightRecording=duration=60s")
+	err := startFlightRecording(&gl)
+	status := printFlightRecording(io.Discard, []string{filepath.Join(t.TempDir(), "none.jfr")})
+
+	_ = w.Close()
+	msg, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if err == nil || !strings.Contains(string(msg), "invalid -XX:StartFlightRecording option: unknown option duration") {
+		t.Errorf("expected an invalid option, got %s", msg)
+	}
+	if status != exitUsageError || !strings.Contains(string(msg), "could not read the flight recording") {
+		t.Errorf("expected a missing recording, got %d, %s", status, msg)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2021 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"context"
+	"jacobin/classloader"
+	"jacobin/log"
+	"jacobin/messages"
+	"strings"
+)
+
+// The data structures and functions related to JVM frames
+
+// Note that the operand stack (opStack) is made up of int64 items, rather than the JVM-
+// prescribed 32-bit entries. The rationale is that longs and doubles can be stored
+// without manipulation at this width. (However, there will still be need for the dummy
+// second stack entry for these data items.
+type frame struct {
+	thread   int
+	methName string               // method name
+	methType string               // method descriptor
+	clName   string               // class name
+	meth     []byte               // bytecode of method
+	code     []instruction        // the decoded bytecode, indexed by pc (see decode.go)
+	method   *classloader.JmEntry // the method, if it's in the MTable, whose code is decoded once
+	cp       *classloader.CPool   // constant pool of class
+	locals   []int64              // local variables
+	opStack  []int64              // operand stack
+	tos      int                  // top of the operand stack
+	pc       int                  // program counter (index into the bytecode of the method)
+	ftype    byte                 // type of method in frame: 'J' = java, 'G' = Golang, 'N' = native
+	gmeth    classloader.GmEntry  // the Go function of a 'G' frame (else it's looked up in the MTable)
+	labels   context.Context      // the pprof labels of the method, while profiling (see profiling.go)
+	stats    *frameStats          // the statistics of the invocation, if they're recorded (see methodStats.go)
+	coverage []uint32             // the counts of the method's bytecodes, if they're recorded (see coverage.go)
+}
+
+// a stack of frames. Implemented as a list in which the current running
+// frame is always the frame at the head
+func createFrameStack() *list.List {
+	l := list.New()
+	return l
+}
+
+// creates a raw frame and allocates an opStack of the passed-in size.
+func createFrame(opStackSize int) *frame {
+	fram := frame{}
+
+	// allocate the operand stack
+	for j := 0; j < opStackSize; j++ {
+		fram.opStack = append(fram.opStack, int64(0))
+	}
+
+	// set top of stack to an empty stack
+	fram.tos = -1
+	fram.pc = 0
+	return &fram
+}
+
+// frameMethod returns the class, name, and descriptor of the frame's method. (The method
+// name of a 'G' frame is the fully qualified name runGmethod() gives it, which includes
+// the class and the descriptor.)
+func frameMethod(f *frame) (class, name, desc string) {
+	if f.ftype != 'G' {
+		return f.clName, f.methName, f.methType
+	}
+	name = f.methName
+	if paren := strings.Index(name, "("); paren >= 0 {
+		name, desc = name[:paren], name[paren:]
+	}
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		return name[:dot], name[dot+1:], desc
+	}
+	return f.clName, name, desc
+}
+
+// push a frame. This simply adds a frame to the head of the list.
+func pushFrame(fs *list.List, f *frame) error {
+	fs.PushFront(f)
+	// TODO: move this to instrumentation system
+	if log.Level == log.FINEST {
+		var s string
+		for e := fs.Front(); e != nil; e = e.Next() {
+			fr := e.Value.(*frame)
+			s = s + "\n" + "> " + fr.methName
+		}
+		_ = log.Log("Present stack frame:"+s, log.FINEST)
+	}
+	return nil
+}
+
+// deletes the frame at the head of the list.
+func popFrame(fs *list.List) error {
+	if fs.Len() == 0 {
+		return messages.New("JACOBIN-IN-0017")
+	}
+
+	fs.Remove(fs.Front())
+	return nil
+}
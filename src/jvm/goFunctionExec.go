@@ -4,13 +4,12 @@
  * Licensed under Mozilla Public License 2.0 (MPL 2.0)
  */
 
-package main
+package jvm
 
 import (
 	"container/list"
-	"errors"
 	"jacobin/classloader"
-	"jacobin/log"
+	"jacobin/messages"
 )
 
 // This function is called from main.run(). It execuates a frame whose
@@ -19,12 +18,18 @@ import (
 // as an array of interface{}, which can be nil if there are no arguments.
 // Any return value from the method is returned to run() as an interface{}
 // (which is nil in the case of a void function), where it is placed
-// by run() on the operand stack of the calling function.
+// by run() on the operand stack of the calling function. A function that
+// throws an exception returns an error, whose text begins with the
+// exception's class, which is returned to run() as the error.
 func runGframe(fr *frame) (interface{}, error) {
-	// get the go method from the MTable
-	me := classloader.MTable[fr.methName]
-	if me.Meth == nil {
-		return nil, errors.New("go method not found: " + fr.methName)
+	// get the go method from the frame or, failing that, from the MTable
+	gm := fr.gmeth
+	if gm.Fu == nil {
+		me := classloader.MTable[classloader.MethodKey(fr.methName)]
+		if me.Meth == nil {
+			return nil, messages.New("JACOBIN-IN-0015", fr.methName)
+		}
+		gm = me.Meth.(classloader.GmEntry)
 	}
 
 	// pull arguments for the function off the frame's operand stack and put them in a slice
@@ -34,7 +39,10 @@ func runGframe(fr *frame) (interface{}, error) {
 	}
 
 	// call the function passing a pointer to the slice of arguments
-	ret := me.Meth.(classloader.GmEntry).Fu(*params)
+	ret := gm.Fu(*params)
+	if err, ok := ret.(error); ok { // the function threw an exception
+		return nil, err
+	}
 	return ret, nil
 }
 
@@ -47,6 +55,11 @@ func runGmethod(mt classloader.MTentry, fs *list.List, className, methodName, me
 
 	// create a frame (gf for 'go frame') for this function
 	paramSlots := mt.Meth.(classloader.GmEntry).ParamSlots
+	if checkNatives {
+		if err := checkNativeCall(f, paramSlots, methodName+methodType); err != nil {
+			return nil, err
+		}
+	}
 	gf := createFrame(paramSlots)
 	gf.thread = f.thread
 	// gf.methName = className + "." + methodName + methodType
@@ -56,6 +69,7 @@ func runGmethod(mt classloader.MTentry, fs *list.List, className, methodName, me
 	gf.cp = nil
 	gf.locals = nil
 	gf.ftype = 'G' // a golang function
+	gf.gmeth = mt.Meth.(classloader.GmEntry)
 
 	// get the args (if any) from the operand stack of the current frame(f)
 	// then push them onto the stack of the go function
@@ -76,7 +90,6 @@ func runGmethod(mt classloader.MTentry, fs *list.List, className, methodName, me
 	// then run the frame, which will call run(), which will eventually call runGFrame()
 	err := runFrame(fs)
 	if err != nil {
-		log.Log("Error: "+err.Error(), log.SEVERE)
 		return nil, err
 	}
 
@@ -4,13 +4,12 @@
  * Licensed under Mozilla Public License 2.0 (MPL 2.0)
  */
 
-package main
+package jvm
 
 import (
-	"fmt"
 	"jacobin/classloader"
 	"jacobin/log"
-	"os"
+	"jacobin/messages"
 )
 
 func instantiateClass(classname string) (interface{}, error) {
@@ -21,7 +20,7 @@ recheck:
 		goto recheck // recheck the status until it changes (i.e., the class is loaded)
 	} else if !present { // the class has not yet been loaded
 		if classloader.LoadClassFromNameOnly(classname) != nil {
-			log.Log("Error loading class: "+classname+". Exiting.", log.SEVERE)
+			log.Log(messages.Text("JACOBIN-IN-0014", classname), log.SEVERE)
 		}
 	}
 
@@ -33,7 +32,7 @@ recheck:
 			initializeField(f, &k.Data.CP)
 		}
 	}
-	return nil, nil
+	return classloader.NewObject(classname, classloader.InstanceSize(classname)), nil
 }
 
 func initializeField(f classloader.Field, cp *classloader.CPool) {
@@ -52,8 +51,8 @@ func initializeField(f classloader.Field, cp *classloader.CPool) {
 			}
 		}
 	}
-	fmt.Fprintf(os.Stdout, "Field to initialize: %s, type: %s\n", name, desc)
+	_ = log.Log("Field to initialize: "+name+", type: "+desc, log.FINEST)
 	if attr != "" {
-		fmt.Fprintf(os.Stdout, "Attribute name: %s\n", attr)
+		_ = log.Log("Attribute name: "+attr, log.FINEST)
 	}
 }
@@ -0,0 +1,206 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"math"
+	"unicode/utf16"
+)
+
+// Intrinsics. The calls of a few small JDK methods that dominate the profiles of small
+// programs, such as Math.max() and String.charAt(), are replaced by Go code that works
+// on the caller's operand stack directly, without the frame a native gets (see
+// runGmethod()) or the lookup of the method. Since no frame is created, the calls of
+// intrinsics aren't counted by -XX:+PrintMethodStatistics or seen by profilers,
+// agents, and debuggers. With -XX:-UseIntrinsics, the methods are called as usual.
+
+// an intrinsic pops the method's arguments off the operand stack of f (one slot each,
+// longs and doubles included) and pushes its return value, if any. The error is the
+// exception the method throws.
+type intrinsic func(f *frame) error
+
+// useIntrinsics is whether calls are replaced by intrinsics. It's set before the
+// program starts.
+var useIntrinsics = true
+
+// startIntrinsics turns off the intrinsics if -XX:-UseIntrinsics was given
+func startIntrinsics(gl *globals.Globals) {
+	useIntrinsics = gl.Flags.Bool("UseIntrinsics")
+}
+
+// intrinsicFor returns the intrinsic for the method, or nil if it has none
+func intrinsicFor(key classloader.MTkey) intrinsic {
+	if !useIntrinsics {
+		return nil
+	}
+	return intrinsics[key]
+}
+
+// intrinsics are keyed by the method's class and symbol, as in the MTable
+var intrinsics = map[classloader.MTkey]intrinsic{}
+
+func init() {
+	for _, class := range []string{"java/lang/Math", "java/lang/StrictMath"} {
+		intrinsics[classloader.MethodKey(class+".max(II)I")] = intIntrinsic(func(a, b int32) int32 {
+			if a > b {
+				return a
+			}
+			return b
+		})
+		intrinsics[classloader.MethodKey(class+".min(II)I")] = intIntrinsic(func(a, b int32) int32 {
+			if a < b {
+				return a
+			}
+			return b
+		})
+		intrinsics[classloader.MethodKey(class+".abs(I)I")] = func(f *frame) error {
+			if a := int32(pop(f)); a < 0 {
+				push(f, int64(-a)) // as in Java, the absolute value of MinInt32 is itself
+			} else {
+				push(f, int64(a))
+			}
+			return nil
+		}
+		intrinsics[classloader.MethodKey(class+".max(JJ)J")] = longIntrinsic(func(a, b int64) int64 {
+			if a > b {
+				return a
+			}
+			return b
+		})
+		intrinsics[classloader.MethodKey(class+".min(JJ)J")] = longIntrinsic(func(a, b int64) int64 {
+			if a < b {
+				return a
+			}
+			return b
+		})
+		intrinsics[classloader.MethodKey(class+".abs(J)J")] = func(f *frame) error {
+			if a := pop(f); a < 0 {
+				push(f, -a)
+			} else {
+				push(f, a)
+			}
+			return nil
+		}
+		// Go's Max and Min treat NaN and -0.0 as Java does
+		intrinsics[classloader.MethodKey(class+".max(DD)D")] = doubleIntrinsic(math.Max)
+		intrinsics[classloader.MethodKey(class+".min(DD)D")] = doubleIntrinsic(math.Min)
+		intrinsics[classloader.MethodKey(class+".abs(D)D")] = func(f *frame) error {
+			push(f, int64(math.Float64bits(math.Abs(math.Float64frombits(uint64(pop(f)))))))
+			return nil
+		}
+	}
+
+	intrinsics[classloader.MethodKey("java/lang/String.length()I")] = func(f *frame) error {
+		s, ok := classloader.GoStringFromRef(pop(f))
+		if !ok {
+			return errors.New(errNPE)
+		}
+		push(f, int64(utf16Length(s)))
+		return nil
+	}
+	intrinsics[classloader.MethodKey("java/lang/String.charAt(I)C")] = func(f *frame) error {
+		index := int32(pop(f))
+		s, ok := classloader.GoStringFromRef(pop(f))
+		if !ok {
+			return errors.New(errNPE)
+		}
+		c, ok := charAt(s, int(index))
+		if !ok {
+			return fmt.Errorf("java.lang.StringIndexOutOfBoundsException: Index %d out of bounds for length %d",
+				index, utf16Length(s))
+		}
+		push(f, int64(c))
+		return nil
+	}
+
+	intrinsics[classloader.MethodKey("java/util/Objects.requireNonNull(Ljava/lang/Object;)Ljava/lang/Object;")] = func(f *frame) error {
+		if f.opStack[f.tos] == 0 { // the object is returned, so it stays on the stack
+			return errors.New(errNPE)
+		}
+		return nil
+	}
+	intrinsics[classloader.MethodKey("java/util/Objects.requireNonNull(Ljava/lang/Object;Ljava/lang/String;)Ljava/lang/Object;")] =
+		func(f *frame) error {
+			message := pop(f)
+			if f.opStack[f.tos] == 0 {
+				if s, ok := classloader.GoStringFromRef(message); ok {
+					return errors.New(errNPE + ": " + s)
+				}
+				return errors.New(errNPE)
+			}
+			return nil
+		}
+
+	intrinsics[classloader.MethodKey("java/lang/System.arraycopy(Ljava/lang/Object;ILjava/lang/Object;II)V")] = func(f *frame) error {
+		length, destPos := int(int32(pop(f))), int(int32(pop(f)))
+		dest := pop(f)
+		srcPos, src := int(int32(pop(f))), pop(f)
+		return classloader.ArrayCopy(src, srcPos, dest, destPos, length)
+	}
+}
+
+func intIntrinsic(fn func(a, b int32) int32) intrinsic {
+	return func(f *frame) error {
+		b, a := int32(pop(f)), int32(pop(f))
+		push(f, int64(fn(a, b)))
+		return nil
+	}
+}
+
+func longIntrinsic(fn func(a, b int64) int64) intrinsic {
+	return func(f *frame) error {
+		b, a := pop(f), pop(f)
+		push(f, fn(a, b))
+		return nil
+	}
+}
+
+func doubleIntrinsic(fn func(a, b float64) float64) intrinsic {
+	return func(f *frame) error {
+		b, a := math.Float64frombits(uint64(pop(f))), math.Float64frombits(uint64(pop(f)))
+		push(f, int64(math.Float64bits(fn(a, b))))
+		return nil
+	}
+}
+
+// isASCII reports whether the string has only ASCII chars, whose UTF-16 chars are
+// its bytes
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// utf16Length returns the length of the string in UTF-16 chars, as String.length() does
+func utf16Length(s string) int {
+	if isASCII(s) {
+		return len(s)
+	}
+	return len(utf16.Encode([]rune(s)))
+}
+
+// charAt returns the string's UTF-16 char at the index, or false if the index is out
+// of bounds
+func charAt(s string, index int) (uint16, bool) {
+	if isASCII(s) {
+		if index < 0 || index >= len(s) {
+			return 0, false
+		}
+		return uint16(s[index]), true
+	}
+	chars := utf16.Encode([]rune(s))
+	if index < 0 || index >= len(chars) {
+		return 0, false
+	}
+	return chars[index], true
+}
@@ -0,0 +1,142 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"testing"
+)
+
+// runCall runs the invoke instruction for the method in CP entry 1 with the arguments
+// on the operand stack, and returns the frame
+func runCall(t *testing.T, op byte, method string, args ...int64) (*frame, error) {
+	classloader.MTable = make(classloader.MT) // so only an intrinsic can run
+	f := newFrame(op)
+	f.meth = append(f.meth, 0x00, 0x01)
+	f.cp = testCP(method)
+	for _, arg := range args {
+		push(&f, arg)
+	}
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	return &f, runFrame(fs)
+}
+
+func TestMathIntrinsics(t *testing.T) {
+	f, err := runCall(t, INVOKESTATIC, "java/lang/Math.max(II)I", 3, -7)
+	if err != nil || f.tos != 0 || pop(f) != 3 {
+		t.Errorf("Expected Math.max(3, -7) to be 3, got error %v", err)
+	}
+	f, err = runCall(t, INVOKESTATIC, "java/lang/Math.abs(I)I", -5)
+	if err != nil || f.tos != 0 || pop(f) != 5 {
+		t.Errorf("Expected Math.abs(-5) to be 5, got error %v", err)
+	}
+	f, err = runCall(t, INVOKESTATIC, "java/lang/Math.min(JJ)J", 1<<40, -1<<40)
+	if err != nil || f.tos != 0 || pop(f) != -1<<40 {
+		t.Errorf("Expected Math.min() of the longs to be -2^40, got error %v", err)
+	}
+}
+
+func TestStringIntrinsics(t *testing.T) {
+	s := classloader.NewStringObject("héllo")
+	f, err := runCall(t, INVOKEVIRTUAL, "java/lang/String.charAt(I)C", s, 1)
+	if err != nil || f.tos != 0 || pop(f) != 'é' {
+		t.Errorf("Expected charAt(1) to be é, got error %v", err)
+	}
+	f, err = runCall(t, INVOKEVIRTUAL, "java/lang/String.length()I", s)
+	if err != nil || f.tos != 0 || pop(f) != 5 {
+		t.Errorf("Expected length() to be 5, got error %v", err)
+	}
+	_, err = runCall(t, INVOKEVIRTUAL, "java/lang/String.charAt(I)C", s, 5)
+	if err == nil || err.Error() != "java.lang.StringIndexOutOfBoundsException: Index 5 out of bounds for length 5" {
+		t.Errorf("Expected StringIndexOutOfBoundsException, got %v", err)
+	}
+}
+
+func TestRequireNonNullIntrinsic(t *testing.T) {
+	method := "java/util/Objects.requireNonNull(Ljava/lang/Object;)Ljava/lang/Object;"
+	obj := classloader.NewStringObject("x")
+	if f, err := runCall(t, INVOKESTATIC, method, obj); err != nil || f.tos != 0 || pop(f) != obj {
+		t.Errorf("Expected requireNonNull() to return the object, got error %v", err)
+	}
+	if _, err := runCall(t, INVOKESTATIC, method, 0); err == nil || err.Error() != errNPE {
+		t.Errorf("Expected NullPointerException for null, got %v", err)
+	}
+}
+
+func TestArraycopyIntrinsic(t *testing.T) {
+	method := "java/lang/System.arraycopy(Ljava/lang/Object;ILjava/lang/Object;II)V"
+	a := classloader.NewPrimitiveArray("I", []int64{1, 2, 3, 4, 5})
+	f, err := runCall(t, INVOKESTATIC, method, a, 0, a, 1, 4) // overlapping
+	if err != nil || f.tos != -1 {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if elements := classloader.GetObject(a).Native.([]int64); elements[1] != 1 || elements[4] != 4 {
+		t.Errorf("Expected the elements to be shifted by one, got %v", elements)
+	}
+
+	_, err = runCall(t, INVOKESTATIC, method, a, 3, a, 0, 3)
+	if err == nil || err.Error() !=
+		"java.lang.ArrayIndexOutOfBoundsException: arraycopy: last source index 6 out of bounds for int[5]" {
+		t.Errorf("Expected ArrayIndexOutOfBoundsException, got %v", err)
+	}
+	_, err = runCall(t, INVOKESTATIC, method, a, 0, classloader.NewByteArray([]byte{0}), 0, 1)
+	if err == nil || err.Error() != "java.lang.ArrayStoreException: arraycopy: type mismatch: can not copy int[] into byte[]" {
+		t.Errorf("Expected ArrayStoreException, got %v", err)
+	}
+}
+
+// with -XX:-UseIntrinsics, System.arraycopy() is the native, which checks each
+// reference it stores in an array of a narrower type
+func TestArraycopyNative(t *testing.T) {
+	useIntrinsics = false
+	defer func() { useIntrinsics = true }()
+	classloader.MTable = make(classloader.MT)
+	classloader.MTableLoadNatives()
+	defer func() { classloader.MTable = make(classloader.MT) }()
+
+	method := "java/lang/System.arraycopy(Ljava/lang/Object;ILjava/lang/Object;II)V"
+	call := func(args ...int64) error {
+		f := newFrame(INVOKESTATIC)
+		f.meth = append(f.meth, 0x00, 0x01)
+		f.cp = testCP(method)
+		for _, arg := range args {
+			push(&f, arg)
+		}
+		fs := createFrameStack()
+		fs.PushFront(&f)
+		return runFrame(fs)
+	}
+
+	s1, s2 := classloader.NewStringObject("a"), classloader.NewStringObject("b")
+	src := classloader.NewRefArray("java/lang/Object", []int64{s1, s2, 0})
+	dest := classloader.NewRefArray("java/lang/String", make([]int64, 3))
+	if err := call(src, 0, dest, 0, 3); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if elements, _ := classloader.RefArrayFromRef(dest); elements[0] != s1 || elements[1] != s2 {
+		t.Errorf("Expected the strings to be copied, got %v", elements)
+	}
+
+	ints := classloader.NewArray("[[I", 3)
+	err := call(src, 0, ints, 0, 3)
+	if err == nil || err.Error() != "java.lang.ArrayStoreException: arraycopy: element type mismatch: "+
+		"can not cast one of the elements of object array[] to the type of the destination array, [I" {
+		t.Errorf("Expected ArrayStoreException, got %v", err)
+	}
+	if elements, _ := classloader.RefArrayFromRef(ints); elements[0] != 0 {
+		t.Errorf("Expected nothing to be stored before the element that doesn't fit, got %v", elements)
+	}
+}
+
+// with -XX:-UseIntrinsics, the call is made as usual
+func TestIntrinsicsOff(t *testing.T) {
+	useIntrinsics = false
+	defer func() { useIntrinsics = true }()
+	if intrinsicFor(classloader.MethodKey("java/lang/Math.max(II)I")) != nil {
+		t.Error("Expected no intrinsic with -XX:-UseIntrinsics")
+	}
+}
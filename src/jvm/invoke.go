@@ -0,0 +1,53 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/messages"
+)
+
+// invokeMethod implements classloader.InvokeMethod, which natives (such as Method.invoke())
+// use to run a method. The method runs to completion on a frame stack of its own. Natives
+// don't know which thread called them, so the method runs as though on the main thread.
+func invokeMethod(className, methodName, methodType string, args []int64) (int64, error) {
+	me, err := classloader.FetchMethodAndCP(className, methodName, methodType)
+	if err != nil {
+		return 0, err
+	}
+
+	// the method is called from a frame whose operand stack holds the arguments, one
+	// slot for each (longs and doubles included)
+	params := ParseIncomingParamsFromMethTypeString(methodType)
+	hasThis := len(args) > len(params)
+	caller := createFrame(len(args) + 1)
+	for _, arg := range args {
+		push(caller, arg)
+	}
+	fs := createFrameStack()
+	fs.PushFront(caller)
+
+	switch me.MType {
+	case 'G':
+		if _, err = runGmethod(me, fs, className, className+"."+methodName, methodType); err != nil {
+			return 0, err
+		}
+	case 'J':
+		f := createJavaFrame(caller, me.Meth.(*classloader.JmEntry), className, methodName, methodType, hasThis)
+		fs.PushFront(f)
+		if err = runFrame(fs); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, messages.New("JACOBIN-IN-0016", className, methodName, methodType)
+	}
+
+	if methodType[len(methodType)-1] == 'V' || caller.tos < 0 {
+		return 0, nil
+	}
+	return pop(caller), nil
+}
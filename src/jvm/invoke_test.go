@@ -0,0 +1,58 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"testing"
+)
+
+func TestInvokeMethod(t *testing.T) {
+	classloader.MTable = make(classloader.MT)
+	// pick() returns its int argument and pickLong() returns its long argument, which
+	// follows an int, so the long's slot is checked too. The long takes locals 2 and 3.
+	classloader.MTable[classloader.MethodKey("test/Calc.pick(IJ)I")] = classloader.MTentry{
+		Meth:  &classloader.JmEntry{MaxStack: 1, MaxLocals: 4, Code: []byte{ILOAD_1, IRETURN}},
+		MType: 'J',
+	}
+	classloader.MTable[classloader.MethodKey("test/Calc.pickLong(IJ)I")] = classloader.MTentry{
+		Meth:  &classloader.JmEntry{MaxStack: 1, MaxLocals: 4, Code: []byte{LLOAD_2, IRETURN}},
+		MType: 'J',
+	}
+	classloader.MTable[classloader.MethodKey("test/Calc.after(JI)I")] = classloader.MTentry{
+		Meth:  &classloader.JmEntry{MaxStack: 1, MaxLocals: 4, Code: []byte{ILOAD_3, IRETURN}},
+		MType: 'J',
+	}
+	classloader.MTable[classloader.MethodKey("test/Calc.same(Ljava/lang/Object;)Ljava/lang/Object;")] = classloader.MTentry{
+		Meth:  &classloader.JmEntry{MaxStack: 1, MaxLocals: 1, Code: []byte{ALOAD_0, ARETURN}},
+		MType: 'J',
+	}
+	calc := classloader.NewObject("test/Calc", 0)
+
+	if v, err := invokeMethod("test/Calc", "pick", "(IJ)I", []int64{calc, 7, 9}); err != nil || v != 7 {
+		t.Errorf("Expected pick() to return 7, got %d (%v)", v, err)
+	}
+	if v, err := invokeMethod("test/Calc", "pickLong", "(IJ)I", []int64{calc, 7, 9}); err != nil || v != 9 {
+		t.Errorf("Expected pickLong() to return 9, got %d (%v)", v, err)
+	}
+	if v, err := invokeMethod("test/Calc", "after", "(JI)I", []int64{calc, 9, 7}); err != nil || v != 7 {
+		t.Errorf("Expected after() to find its int after the long's two locals, got %d (%v)", v, err)
+	}
+	if v, err := invokeMethod("test/Calc", "same", "(Ljava/lang/Object;)Ljava/lang/Object;", []int64{calc}); err != nil || v != calc {
+		t.Errorf("Expected static same() to return its argument, got %d (%v)", v, err)
+	}
+
+	classloader.MTable[classloader.MethodKey("test/Calc.twice(J)J")] = classloader.MTentry{
+		Meth: classloader.GmEntry{ParamSlots: 2, Fu: func(params []interface{}) interface{} {
+			return 2 * params[1].(int64)
+		}},
+		MType: 'G',
+	}
+	if v, err := invokeMethod("test/Calc", "twice", "(J)J", []int64{calc, 21}); err != nil || v != 42 {
+		t.Errorf("Expected the native twice() to return 42, got %d (%v)", v, err)
+	}
+}
@@ -0,0 +1,393 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2021-2 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"jacobin/classloader"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Creates a JVM program execution thread. These threads are extremely limited.
+// They basically hold a stack of frames. They push and popFrame frames as required.
+// They begin execution; they exit when execution ends; and they emit diagnostic
+// and performance data.
+//
+// The execThread is also the interpreter's per-thread context: everything the
+// interpreter needs while executing a thread (the frame stack, the trace setting,
+// the pending exception) is kept here rather than in package-level variables, so
+// that multiple Java threads can be interpreted concurrently. Each frame records
+// the ID of its thread, from which the interpreter finds the context.
+
+type execThread struct {
+	id        int        // the thread ID
+	stack     *list.List // the JVM stack for this thread
+	pc        int        // the program counter (the index to the instruction being executed)
+	trace     bool       // do we trace instructions?
+	exception error      // the pending exception, if any, propagating up the frame stack
+	daemon    bool       // daemon threads don't keep the VM alive (see waitForNonDaemonThreads())
+	started   bool       // has the thread been started? (daemon status can't change afterwards)
+
+	done        chan struct{} // closed when the thread terminates; used by join()
+	interrupted bool          // the interrupt status of the thread
+	interruptCh chan struct{} // signals an interrupt to a thread blocked in join(), etc.
+	permit      chan struct{} // the permit for LockSupport.park()/unpark(); see park.go
+	blockedOn   int64         // the object whose monitor the thread is waiting to enter (0 = none)
+	priority    int           // Java thread priority, 1-10; see threadPriority.go
+
+	sampleRequested int32       // 1 when the sampler wants a sample of the stack; accessed via sync/atomic
+	stackRequested  int32       // 1 when a thread dump wants the stack; accessed via sync/atomic
+	stackReply      chan string // the stack the thread sends in answer; see threadDump.go
+	stackState      int32       // whether the stack can be read by other threads; see threadDump.go
+	bytecodes       int64       // the bytecodes executed, counted for -XX:+PrintVMSummary; accessed via sync/atomic
+
+	threadLocals      map[int64]int64 // ThreadLocal values, keyed by ThreadLocal object reference
+	inheritableLocals map[int64]int64 // InheritableThreadLocal values, copied to child threads
+
+	object int64 // the Thread object that stands for the thread, or 0 until there is one
+}
+
+func CreateThread(threadNum int) execThread {
+	t := execThread{}
+	t.id = threadNum
+	t.pc = 0
+	t.stack = createFrameStack()
+	t.trace = false
+	t.daemon = false
+	t.started = false
+	t.done = make(chan struct{})
+	t.interrupted = false
+	t.interruptCh = make(chan struct{}, 1)
+	t.permit = make(chan struct{}, 1)
+	t.stackReply = make(chan string, 1)
+	t.priority = NORM_PRIORITY
+	t.threadLocals = make(map[int64]int64)
+	t.inheritableLocals = make(map[int64]int64)
+	return t
+}
+
+// Thread IDs are assigned in sequence. The main thread is thread 0.
+var lastThreadID int32
+
+func newThreadID() int {
+	return int(atomic.AddInt32(&lastThreadID, 1))
+}
+
+// As in the java launcher, the VM exits only when all non-daemon threads have
+// terminated (or System.exit() is called). The main thread is a non-daemon thread.
+// Live threads are tracked in the threads map, keyed by thread ID; nonDaemonWg
+// counts the non-daemon threads that are still running.
+var threads = make(map[int]*execThread)
+var threadsMutex sync.Mutex
+var nonDaemonWg sync.WaitGroup
+
+// the totals over all the threads, for the exit summary (see vmSummary.go)
+var (
+	threadsStarted int
+	peakThreads    int   // the most threads that were live at once
+	endedBytecodes int64 // the bytecodes executed by the threads that have ended
+)
+
+// setDaemon marks the thread as a daemon thread or a user thread. As in Java,
+// this must be done before the thread is started.
+func setDaemon(t *execThread, on bool) error {
+	threadsMutex.Lock()
+	defer threadsMutex.Unlock()
+	if t.started {
+		return errors.New("java.lang.IllegalThreadStateException: thread already started")
+	}
+	t.daemon = on
+	return nil
+}
+
+// registerThread adds the thread to the table of live threads. If the thread is
+// not a daemon, the VM will wait for it to end before exiting.
+func registerThread(t *execThread) {
+	threadsMutex.Lock()
+	t.started = true
+	threads[t.id] = t
+	threadsStarted++
+	if len(threads) > peakThreads {
+		peakThreads = len(threads)
+	}
+	if !t.daemon {
+		nonDaemonWg.Add(1)
+	}
+	threadsMutex.Unlock()
+}
+
+// threadEnded removes the thread from the table of live threads and, if it's a
+// non-daemon thread, signals the VM that one fewer thread is keeping it alive.
+func threadEnded(t *execThread) {
+	threadsMutex.Lock()
+	_, present := threads[t.id]
+	if present {
+		delete(threads, t.id)
+		endedBytecodes += atomic.LoadInt64(&t.bytecodes)
+		if !t.daemon {
+			nonDaemonWg.Done()
+		}
+		close(t.done) // wake up any threads that have join()ed this thread
+	}
+	threadsMutex.Unlock()
+}
+
+// startThread registers the thread and runs it on its own goroutine. When the
+// thread's frames have all been executed, the thread is removed from the table.
+func startThread(t *execThread) {
+	registerThread(t)
+	go func() {
+		defer threadEnded(t)
+		if debugger != nil {
+			debugger.ThreadStarted(threadIDBase + int64(t.id))
+			defer debugger.ThreadEnded(threadIDBase + int64(t.id))
+		}
+		_ = runThread(t)
+	}()
+}
+
+// The Java thread running on each goroutine, keyed by goroutine ID, so that code that
+// isn't passed its thread, such as the logger, can find out which thread it's on.
+var goroutineThreads sync.Map
+
+// goroutineID returns the ID of the calling goroutine, which is on the first line of
+// its stack trace ("goroutine 18 [running]:"). Go doesn't otherwise make it available.
+func goroutineID() int64 {
+	buf := make([]byte, 64)
+	buf = bytes.TrimPrefix(buf[:runtime.Stack(buf, false)], []byte("goroutine "))
+	if space := bytes.IndexByte(buf, ' '); space > 0 {
+		if id, err := strconv.ParseInt(string(buf[:space]), 10, 64); err == nil {
+			return id
+		}
+	}
+	return -1
+}
+
+// currentThreadID returns the ID of the Java thread running on the calling goroutine,
+// or -1 if the goroutine isn't running a Java thread
+func currentThreadID() int {
+	if id, ok := goroutineThreads.Load(goroutineID()); ok {
+		return id.(int)
+	}
+	return -1
+}
+
+// currentThread returns the Java thread running on the calling goroutine, which is the
+// main thread if the goroutine isn't running one (as when the VM runs Java code itself)
+func currentThread() *execThread {
+	threadsMutex.Lock()
+	t, present := threads[currentThreadID()]
+	threadsMutex.Unlock()
+	if !present {
+		return &MainThread
+	}
+	return t
+}
+
+// The natives of Thread are passed the Thread object, so the objects that stand for the
+// VM's threads are tied to them. A thread gets its object when it's started to run a
+// Thread (a shutdown hook, say) or when Thread.currentThread() is called on it. A Thread
+// object that isn't tied to a thread is one that hasn't been started.
+var threadObjects sync.Map // int64 -> *execThread
+
+// tieThreadObject makes the Thread object the one that stands for the thread
+func tieThreadObject(ref int64, t *execThread) {
+	threadsMutex.Lock()
+	t.object = ref
+	threadsMutex.Unlock()
+	threadObjects.Store(ref, t)
+}
+
+// threadOfObject returns the thread the Thread object stands for, or nil if it hasn't
+// been started
+func threadOfObject(ref int64) *execThread {
+	if t, ok := threadObjects.Load(ref); ok {
+		return t.(*execThread)
+	}
+	return nil
+}
+
+// objectOfThread returns the Thread object that stands for the thread, which it creates
+// the first time. It implements Thread.currentThread() for the current thread.
+func objectOfThread(t *execThread) int64 {
+	threadsMutex.Lock()
+	ref := t.object
+	threadsMutex.Unlock()
+	if ref == 0 {
+		ref = classloader.NewThreadObject(threadName(t))
+		tieThreadObject(ref, t)
+	}
+	return ref
+}
+
+// joinThreadObject implements classloader.JoinThread for Thread.join() and join(millis)
+func joinThreadObject(thread, millis int64) error {
+	target := threadOfObject(thread)
+	if target == nil {
+		target = &execThread{} // it was never started
+	}
+	return joinThread(currentThread(), target, millis)
+}
+
+// startThreadObject implements classloader.StartThread for Thread.start(): it starts a
+// thread that runs the Thread's run() method, which is a daemon thread if setDaemon()
+// made it one, and has the priority setPriority() gave it
+func startThreadObject(thread int64) error {
+	if threadOfObject(thread) != nil {
+		return errors.New("java.lang.IllegalThreadStateException")
+	}
+	daemon := threadObjectIsDaemon(thread) // before the thread is tied to the object
+	priority := threadObjectPriority(thread)
+	t := newMethodThread(thread, "run", "()V")
+	if t == nil {
+		return errors.New("java.lang.InternalError: the thread has no run() method")
+	}
+	t.daemon = daemon
+	t.priority = int(priority)
+	pendingDaemons.Delete(thread)
+	pendingPriorities.Delete(thread)
+	startThread(t)
+	return nil
+}
+
+// The daemon status set by Thread.setDaemon() on Thread objects that haven't been
+// started yet, which the thread gets when it's started
+var pendingDaemons sync.Map // int64 -> bool
+
+// setThreadObjectDaemon implements classloader.SetThreadDaemon for Thread.setDaemon()
+func setThreadObjectDaemon(thread int64, on bool) error {
+	if t := threadOfObject(thread); t != nil {
+		return setDaemon(t, on)
+	}
+	pendingDaemons.Store(thread, on)
+	return nil
+}
+
+// threadObjectIsDaemon implements classloader.ThreadIsDaemon for Thread.isDaemon(). As
+// in Java, a thread whose daemon status hasn't been set has that of the thread that
+// creates it, which is taken to be the current thread.
+func threadObjectIsDaemon(thread int64) bool {
+	t := threadOfObject(thread)
+	if t == nil {
+		if on, ok := pendingDaemons.Load(thread); ok {
+			return on.(bool)
+		}
+		t = currentThread()
+	}
+	threadsMutex.Lock()
+	defer threadsMutex.Unlock()
+	return t.daemon
+}
+
+// waitForNonDaemonThreads blocks until every non-daemon thread has ended.
+// Daemon threads still running at that point are simply abandoned when the VM exits.
+func waitForNonDaemonThreads() {
+	nonDaemonWg.Wait()
+}
+
+// errInterrupted is returned by blocking operations that were interrupted. It corresponds
+// to Java's InterruptedException, which the caller is expected to throw.
+var errInterrupted = errors.New("java.lang.InterruptedException")
+
+// interruptThread sets the interrupt status of the thread and wakes it up if it's
+// blocked in an interruptible operation, such as join().
+func interruptThread(t *execThread) {
+	threadsMutex.Lock()
+	t.interrupted = true
+	threadsMutex.Unlock()
+	select {
+	case t.interruptCh <- struct{}{}:
+	default: // an interrupt is already pending
+	}
+}
+
+// clearInterrupt clears the interrupt status of the thread and returns its previous
+// value. This is the behavior of Thread.interrupted().
+func clearInterrupt(t *execThread) bool {
+	threadsMutex.Lock()
+	wasInterrupted := t.interrupted
+	t.interrupted = false
+	threadsMutex.Unlock()
+	select {
+	case <-t.interruptCh:
+	default:
+	}
+	return wasInterrupted
+}
+
+// interruptThreadObject implements classloader.InterruptThread for Thread.interrupt().
+// As the JLS allows, interrupting a thread that hasn't been started has no effect.
+func interruptThreadObject(thread int64) {
+	if t := threadOfObject(thread); t != nil {
+		interruptThread(t)
+	}
+}
+
+// threadObjectIsInterrupted implements classloader.ThreadIsInterrupted for
+// Thread.isInterrupted(), which doesn't clear the interrupt status
+func threadObjectIsInterrupted(thread int64) bool {
+	t := threadOfObject(thread)
+	if t == nil {
+		return false
+	}
+	threadsMutex.Lock()
+	defer threadsMutex.Unlock()
+	return t.interrupted
+}
+
+// joinThread implements Thread.join() and Thread.join(millis): the calling thread
+// waits for the target thread to terminate. A millis of 0 means wait forever. The
+// wait is interruptible: if the caller is interrupted before or during the wait,
+// its interrupt status is cleared and errInterrupted is returned. As in the JDK,
+// joining a thread that hasn't been started returns at once.
+func joinThread(caller, target *execThread, millis int64) error {
+	if millis < 0 {
+		return errors.New("java.lang.IllegalArgumentException: timeout value is negative")
+	}
+	if !target.started {
+		return nil
+	}
+	if clearInterrupt(caller) {
+		return errInterrupted
+	}
+
+	var timeout <-chan time.Time
+	if millis > 0 {
+		timer := time.NewTimer(time.Duration(millis) * time.Millisecond)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-target.done:
+		return nil
+	case <-timeout: // a nil channel (millis == 0) blocks forever
+		return nil
+	case <-caller.interruptCh:
+		clearInterrupt(caller)
+		return errInterrupted
+	}
+}
+
+// isAlive reports whether the thread has been started and has not yet terminated.
+func isAlive(t *execThread) bool {
+	if !t.started {
+		return false
+	}
+	select {
+	case <-t.done:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,324 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"testing"
+	"time"
+)
+
+func TestSetDaemonAfterStartIsError(t *testing.T) {
+	th := CreateThread(101)
+	if setDaemon(&th, true) != nil {
+		t.Errorf("Expected no error setting daemon status on unstarted thread")
+	}
+	registerThread(&th)
+	if setDaemon(&th, false) == nil {
+		t.Errorf("Expected error setting daemon status on started thread, got none")
+	}
+	threadEnded(&th)
+}
+
+// Java code starts a thread with Thread.start0(), having made it a daemon thread with
+// setDaemon(), and the thread runs the Thread's run() method
+func TestStartThreadFromBytecode(t *testing.T) {
+	starter, worker := "test/Starter", "test/Worker"
+	starterData := &classloader.ClData{Name: starter, CP: *testCP("java/lang/Thread.setDaemon(Z)V",
+		"java/lang/Thread.start0()V", "java/lang/Thread.isDaemon()Z", starter+".record(I)V")}
+	starterData.CP.Utf8Refs = append(starterData.CP.Utf8Refs, "main", "(Ltest/Worker;)V")
+	n := uint16(len(starterData.CP.Utf8Refs))
+	starterData.Methods = []classloader.Method{{AccessFlags: 0x0008, Name: n - 2, Desc: n - 1,
+		CodeAttr: classloader.CodeAttrib{MaxStack: 2, MaxLocals: 1, Code: []byte{
+			ALOAD_0, ICONST_1, INVOKEVIRTUAL, 0, 1, // worker.setDaemon(true)
+			ALOAD_0, INVOKEVIRTUAL, 0, 2, // worker.start0()
+			ALOAD_0, INVOKEVIRTUAL, 0, 3, INVOKESTATIC, 0, 4, // record(worker.isDaemon())
+			RETURN}}}}
+	workerData := &classloader.ClData{Name: worker, Superclass: "java/lang/Thread", CP: *testCP(starter + ".record(I)V")}
+	workerData.CP.Utf8Refs = append(workerData.CP.Utf8Refs, "run", "()V")
+	n = uint16(len(workerData.CP.Utf8Refs))
+	workerData.Methods = []classloader.Method{{Name: n - 2, Desc: n - 1,
+		CodeAttr: classloader.CodeAttrib{MaxStack: 1, MaxLocals: 1, Code: []byte{ICONST_5, INVOKESTATIC, 0, 1, RETURN}}}}
+	classloader.Classes[starter] = classloader.Klass{Status: 'L', Loader: "app", Data: starterData}
+	classloader.Classes[worker] = classloader.Klass{Status: 'L', Loader: "app", Data: workerData}
+	defer delete(classloader.Classes, starter)
+	defer delete(classloader.Classes, worker)
+
+	classloader.MTable = make(classloader.MT)
+	classloader.MTableLoadNatives()
+	classloader.StartThread = startThreadObject
+	classloader.SetThreadDaemon = setThreadObjectDaemon
+	classloader.ThreadIsDaemon = threadObjectIsDaemon
+	recorded := make(chan int64, 2)
+	classloader.MTable[classloader.MethodKey(starter+".record(I)V")] = classloader.MTentry{MType: 'G',
+		Meth: classloader.GmEntry{ParamSlots: 1, Fu: func(p []interface{}) interface{} {
+			recorded <- p[0].(int64)
+			return nil
+		}}}
+
+	ref := classloader.NewObject(worker, 0)
+	if _, err := invokeMethod(starter, "main", "(Ltest/Worker;)V", []int64{ref}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	th := threadOfObject(ref)
+	if th == nil {
+		t.Fatal("Expected the Thread object to be tied to the started thread")
+	}
+	<-th.done
+	results := []int64{<-recorded, <-recorded}
+	if !(results[0] == 1 && results[1] == 5 || results[0] == 5 && results[1] == 1) {
+		t.Errorf("Expected isDaemon() to be true and run() to record 5, got %v", results)
+	}
+	if !th.daemon {
+		t.Error("Expected the started thread to be a daemon thread")
+	}
+
+	// a thread can't be started twice, or made a daemon once it's started
+	if err := startThreadObject(ref); err == nil {
+		t.Error("Expected an IllegalThreadStateException starting the thread again")
+	}
+	if err := setThreadObjectDaemon(ref, false); err == nil {
+		t.Error("Expected an IllegalThreadStateException for setDaemon() on a started thread")
+	}
+}
+
+// the VM waits for non-daemon threads, but not for daemon threads
+func TestWaitForNonDaemonThreads(t *testing.T) {
+	daemon := CreateThread(102)
+	_ = setDaemon(&daemon, true)
+	registerThread(&daemon) // never ends; must not block the wait
+
+	user := CreateThread(103)
+	registerThread(&user)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		threadEnded(&user)
+	}()
+
+	done := make(chan bool)
+	go func() {
+		waitForNonDaemonThreads()
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Errorf("waitForNonDaemonThreads() did not return after non-daemon thread ended")
+	}
+	threadEnded(&daemon)
+}
+
+func TestJoinWaitsForThreadEnd(t *testing.T) {
+	caller := CreateThread(110)
+	target := CreateThread(111)
+	registerThread(&target)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		threadEnded(&target)
+	}()
+
+	if err := joinThread(&caller, &target, 0); err != nil {
+		t.Errorf("Expected join() to return normally, got: %s", err.Error())
+	}
+	if isAlive(&target) {
+		t.Errorf("Expected joined thread to no longer be alive")
+	}
+}
+
+func TestJoinWithTimeout(t *testing.T) {
+	caller := CreateThread(112)
+	target := CreateThread(113)
+	registerThread(&target)
+
+	start := time.Now()
+	if err := joinThread(&caller, &target, 20); err != nil {
+		t.Errorf("Expected join(20) to time out normally, got: %s", err.Error())
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Errorf("join(20) returned before the timeout elapsed")
+	}
+	if !isAlive(&target) {
+		t.Errorf("Expected target thread to still be alive after join() timed out")
+	}
+	threadEnded(&target)
+}
+
+func TestJoinIsInterruptible(t *testing.T) {
+	caller := CreateThread(114)
+	target := CreateThread(115)
+	registerThread(&target)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		interruptThread(&caller)
+	}()
+
+	if joinThread(&caller, &target, 0) != errInterrupted {
+		t.Errorf("Expected interrupted join() to return InterruptedException")
+	}
+	if caller.interrupted {
+		t.Errorf("Expected interrupt status to be cleared after InterruptedException")
+	}
+	threadEnded(&target)
+}
+
+// Thread.interrupt0() wakes a thread blocked in join(), and isInterrupted() and
+// interrupted() report the interrupt status, which interrupted() clears
+func TestInterruptNatives(t *testing.T) {
+	classloader.InterruptThread = interruptThreadObject
+	classloader.ThreadIsInterrupted = threadObjectIsInterrupted
+	classloader.ClearInterrupt = func() bool { return clearInterrupt(currentThread()) }
+	classloader.Load_Lang_Thread()
+	native := func(fqn string) classloader.GMeth {
+		gm, ok := classloader.LookupNative(fqn)
+		if !ok {
+			t.Fatalf("Expected %s to be registered", fqn)
+		}
+		return gm
+	}
+	interrupt0 := native("java/lang/Thread.interrupt0()V")
+	isInterrupted := native("java/lang/Thread.isInterrupted()Z")
+	interrupted := native("java/lang/Thread.interrupted()Z")
+
+	caller := CreateThread(119)
+	callerObject := classloader.NewThreadObject("Thread-119")
+	tieThreadObject(callerObject, &caller)
+	target := CreateThread(120)
+	registerThread(&target)
+	defer threadEnded(&target)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		interrupt0.GFunction([]interface{}{callerObject})
+	}()
+	if joinThread(&caller, &target, 0) != errInterrupted {
+		t.Errorf("Expected join() to be interrupted by Thread.interrupt0()")
+	}
+
+	interrupt0.GFunction([]interface{}{callerObject})
+	if isInterrupted.GFunction([]interface{}{callerObject}) != int64(1) {
+		t.Errorf("Expected isInterrupted() to be true after interrupt0()")
+	}
+	if isInterrupted.GFunction([]interface{}{classloader.NewThreadObject("unstarted")}) != int64(0) {
+		t.Errorf("Expected isInterrupted() of an unstarted Thread to be false")
+	}
+
+	// interrupted() acts on the current thread, which here is the main thread
+	interruptThread(&MainThread)
+	if interrupted.GFunction(nil) != int64(1) || interrupted.GFunction(nil) != int64(0) {
+		t.Errorf("Expected interrupted() to return true and then, once it's cleared, false")
+	}
+}
+
+// as in the JDK, joining a thread that was never started returns at once
+func TestJoinUnstartedThread(t *testing.T) {
+	caller := CreateThread(116)
+	target := CreateThread(117)
+	done := make(chan error)
+	go func() { done <- joinThread(&caller, &target, 0) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected join() of an unstarted thread to return normally, got: %s", err.Error())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("join() of an unstarted thread did not return")
+	}
+
+	// and so does Thread.join() on a Thread object that isn't tied to a thread
+	classloader.JoinThread = joinThreadObject
+	classloader.Load_Lang_Thread()
+	join, _ := classloader.LookupNative("java/lang/Thread.join()V")
+	go func() {
+		ret, _ := join.GFunction([]interface{}{classloader.NewObject("java/lang/Thread", 0)}).(error)
+		done <- ret
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected Thread.join() of an unstarted Thread to return normally, got: %s", err.Error())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Thread.join() of an unstarted Thread did not return")
+	}
+}
+
+func TestJoinThreadObject(t *testing.T) {
+	target := CreateThread(118)
+	thread := classloader.NewThreadObject("Thread-118")
+	tieThreadObject(thread, &target)
+	registerThread(&target)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		threadEnded(&target)
+	}()
+
+	if err := joinThreadObject(thread, 0); err != nil {
+		t.Errorf("Expected join() to return normally, got: %s", err.Error())
+	}
+	if isAlive(&target) {
+		t.Errorf("Expected joined thread to no longer be alive")
+	}
+	if objectOfThread(&target) != thread || threadOfObject(thread) != &target {
+		t.Errorf("Expected the Thread object to stand for the thread")
+	}
+}
+
+// two threads interpreting at the same time each use their own context
+func TestThreadsInterpretConcurrently(t *testing.T) {
+	// counts locals[0] up to 100: i = 0; do { i++ } while (i < 100); return
+	code := []byte{ICONST_0, ISTORE_0, IINC, 0, 1, ILOAD_0, BIPUSH, 100, IF_ICMPLT, 0xFF, 0xFA, RETURN}
+
+	var frames []*frame
+	var ths []*execThread
+	for i := 0; i < 2; i++ {
+		th := CreateThread(170 + i)
+		th.trace = i == 0 // tracing is a per-thread setting
+		f := createFrame(4)
+		f.thread = th.id
+		f.ftype = 'J'
+		f.meth = code
+		f.locals = make([]int64, 1)
+		_ = pushFrame(th.stack, f)
+		frames = append(frames, f)
+		ths = append(ths, &th)
+	}
+
+	for _, th := range ths {
+		startThread(th)
+	}
+	for _, th := range ths {
+		<-th.done
+	}
+
+	for i, f := range frames {
+		if f.locals[0] != 100 {
+			t.Errorf("Thread %d: expected local of 100, got: %d", ths[i].id, f.locals[0])
+		}
+		if ths[i].exception != nil {
+			t.Errorf("Thread %d: unexpected exception: %s", ths[i].id, ths[i].exception.Error())
+		}
+	}
+}
+
+func TestCurrentThreadID(t *testing.T) {
+	if currentThreadID() != -1 {
+		t.Errorf("Expected no Java thread on the test's goroutine, got: %d", currentThreadID())
+	}
+	th := CreateThread(newThreadID())
+	goroutine := goroutineID()
+	goroutineThreads.Store(goroutine, th.id)
+	defer goroutineThreads.Delete(goroutine)
+	if currentThreadID() != th.id {
+		t.Errorf("Expected thread %d on the goroutine, got: %d", th.id, currentThreadID())
+	}
+
+	other := make(chan int)
+	go func() { other <- currentThreadID() }()
+	if id := <-other; id != -1 {
+		t.Errorf("Expected no Java thread on another goroutine, got: %d", id)
+	}
+}
@@ -0,0 +1,210 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2021-2 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/agent"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/messages"
+	"os"
+)
+
+var Global globals.Globals
+
+// Main runs the VM as the jacobin command, with the command-line args (of which args[0]
+// is the name of the executable). It ends the process when the program ends.
+func Main(args []string) {
+	defer reportCrash(nil) // a crash in a Java thread is reported by the thread (see crash.go)
+	Global = globals.InitGlobals(args[0])
+	log.Init()
+	classloader.InitConsole()
+
+	// during development, let's use the most verbose logging level
+	// log.Level = log.FINEST  // no longer needed
+	log.Log("running program: "+Global.JacobinName, log.FINE)
+
+	// jacobin cmd <pid> <command> sends a diagnostic command to a running VM
+	if len(args) > 1 && args[1] == "cmd" {
+		os.Exit(diagnosticClient(os.Stdout, args[2:]))
+	}
+	// jacobin stack <pid> prints the thread dump of a running VM
+	if len(args) > 1 && args[1] == "stack" {
+		os.Exit(threadDumpClient(os.Stdout, args[2:]))
+	}
+	// jacobin jfr <recording> prints a flight recording
+	if len(args) > 1 && args[1] == "jfr" {
+		os.Exit(printFlightRecording(os.Stdout, args[2:]))
+	}
+	// jacobin serve [--allow-remote] [<address>] runs the programs sent to it (see execServer.go)
+	if len(args) > 1 && args[1] == "serve" {
+		os.Exit(execServer(os.Stdout, args[2:]))
+	}
+	startVM(args, "", nil)
+}
+
+// startVM processes the command-line args and runs the program they specify. An
+// embedding program (see embed.go) names the main class, in the internal form, and its
+// args instead, and the class is found on the class path. Either way, the VM ends by
+// calling shutdown(), so startVM does not return.
+func startVM(args []string, className string, appArgs []string) {
+	// handle the command-line interface (cli) -- i.e., process the args
+	LoadOptionsTable(Global)
+	err := HandleCli(args, &Global)
+	if err != nil {
+		shutdown(exitUsageError)
+	}
+	if className != "" {
+		if Global.StartingClass, err = classOnClassPath(className); err != nil {
+			shutdown(exitClassNotFound)
+		}
+		Global.AppArgs = appArgs
+	}
+	// the messages are in the user's language, if there's a catalog for it
+	language, _ := globals.SystemProperties.Get("user.language")
+	messages.SetLocale(language)
+	if log.SetFormat(Global.Flags.String("LogFormat")) != nil {
+		_ = messages.Print("JACOBIN-LA-0009", Global.Flags.String("LogFormat"))
+	}
+	if Global.Flags.Bool("PrintFlagsFinal") {
+		printFlagsFinal(os.Stdout, &Global)
+	}
+	// some CLI options, like -version, show data and immediately exit. This tests for that.
+	if Global.ExitNow() {
+		shutdown(exitOK)
+	}
+
+	// with --diff, the program is run by Jacobin and java, and they're compared (see diff.go)
+	if Global.Diff {
+		shutdown(runDiff(args, &Global, os.Stdout))
+		return
+	}
+
+	// a source file is compiled, and then its main class is run
+	if Global.StartingSource != "" {
+		classFile, err := compileSourceFile(Global.StartingSource, &Global)
+		if err != nil {
+			shutdown(exitCompilationFailed)
+		}
+		Global.StartingClass = classFile
+	}
+
+	// with -m, the main class is in a module on the module path
+	if Global.StartingModule != "" {
+		classFile, err := startingModuleClass(&Global)
+		if err != nil {
+			shutdown(exitClassNotFound)
+		}
+		Global.StartingClass = classFile
+	}
+
+	// with -XX:CRaCRestoreFrom, the program carries on from a checkpoint (see checkpoint.go)
+	restoring := Global.Flags.String("CRaCRestoreFrom") != ""
+
+	if Global.StartingClass == "" && !restoring {
+		log.Log(messages.Text("JACOBIN-LA-0024"), log.INFO)
+		showUsage(os.Stderr, &Global)
+		shutdown(exitUsageError)
+	}
+
+	// with -agentlib:jdwp, a debugger can attach, and may need to before anything runs
+	if startDebugger(&Global) != nil {
+		shutdown(exitUsageError)
+	}
+
+	// jacobin cmd can send diagnostic commands while the program runs, and the metrics
+	// can be served over HTTP
+	startDiagnosticServer(&Global)
+	startMetricsServer(&Global)
+
+	// with -XX:StartFlightRecording, events are recorded from the start
+	if startFlightRecording(&Global) != nil {
+		shutdown(exitUsageError)
+	}
+	// and with the profiling flags, profiles are collected
+	if startProfiling(&Global) != nil {
+		shutdown(exitUsageError)
+	}
+	startSampler(&Global)
+	startDeadlockDetection(&Global)
+	startMethodStatistics(&Global)
+	startIntrinsics(&Global)
+	startSuperinstructions(&Global)
+	startDecoding(&Global)
+	startCoverage(&Global)
+	startVMSummary(&Global)
+	startVerifiedClasses(&Global)
+
+	if restoring {
+		status := restoreVM(&Global)
+		waitForNonDaemonThreads()
+		shutdown(status)
+		return
+	}
+
+	// load the starting class, classes it references, and some base classes
+	classloader.Init()
+	classloader.LoadBaseClasses(&Global)
+	mainClass, err := classloader.LoadClassFromFile(classloader.BootstrapCL, Global.StartingClass)
+	if err != nil { // the error message will already have been shown to user
+		shutdown(exitClassNotFound)
+	}
+	classloader.LoadReferencedClasses(classloader.BootstrapCL, mainClass)
+
+	if Global.DryRun {
+		if dryRun(mainClass, &Global, os.Stdout) != nil {
+			shutdown(exitClassNotFound)
+		}
+		shutdown(exitOK)
+	}
+
+	// begin execution
+	log.Log("Starting execution with: "+Global.StartingClass, log.INFO)
+	status := exitOK
+	if StartExec(mainClass, &Global) != nil {
+		status = exitUncaughtException
+	}
+
+	// as with the java launcher, main() ending does not end the program if other
+	// non-daemon threads are still running, even if main() ended with an exception.
+	// So, wait for them.
+	waitForNonDaemonThreads()
+	shutdown(status)
+}
+
+// the exit function. It runs the shutdown hooks and then exits with the given status
+// (see the exit statuses in exit.go). If the status would be exitOK but the final log
+// message can't be written, the status is 1.
+func shutdown(status int) int {
+	globals.LoaderWg.Wait()
+	runShutdownHooks()
+	agent.VMDeath()
+	stopDebugger()
+	stopDiagnosticServer()
+	stopFlightRecording()
+	stopProfiling()
+	stopSampler()
+	printMethodStatistics()
+	stopCoverage()
+	stopVerifiedClasses()
+	printVMSummary()
+	classloader.FlushMappedBuffers()
+	removeSourceLaunchDir()
+	classloader.RestoreConsole()
+	g := globals.GetGlobalRef()
+
+	if log.Log("shutdown", log.INFO) != nil && status == exitOK {
+		status = 1
+	}
+
+	if g.JacobinName == "test" {
+		return status
+	} else {
+		osExit(status)
+	}
+	return status // required by go
+}
@@ -4,7 +4,7 @@
  * Licensed under Mozilla Public License 2.0 (MPL 2.0)
  */
 
-package main
+package jvm
 
 import (
 	"io/ioutil"
@@ -31,7 +31,7 @@ func TestShutdownOK(t *testing.T) {
 	_, wout, _ := os.Pipe()
 	os.Stdout = wout
 
-	shutdown(false)
+	status := shutdown(exitOK)
 
 	// restore stderr and stdout to what they were before
 	_ = w.Close()
@@ -46,4 +46,24 @@ func TestShutdownOK(t *testing.T) {
 	if !strings.Contains(msg, "shutdown") {
 		t.Errorf("Expecting shutdown message, but got: %s", msg)
 	}
+	if status != exitOK {
+		t.Errorf("Expecting exit status 0, got: %d", status)
+	}
+}
+
+func TestShutdownReturnsStatus(t *testing.T) {
+	globals.InitGlobals("test")
+	gl := globals.GetGlobalRef()
+	gl.JacobinName = "test"
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	status := shutdown(exitClassNotFound)
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if status != 3 {
+		t.Errorf("Expecting the status of 3 for a missing main class, got: %d", status)
+	}
 }
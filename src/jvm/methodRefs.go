@@ -0,0 +1,98 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"jacobin/classloader"
+	"strings"
+)
+
+// Method references (JVMS 5.4.3.3 and 5.4.3.4). A Methodref in the CP refers to a
+// method of a class and an InterfaceMethodref to a method of an interface. Whether
+// the class a reference names is a class or an interface is known only when the class
+// is loaded, so it's checked when an invoke instruction resolves the reference, and a
+// mismatch throws an IncompatibleClassChangeError with HotSpot's message:
+//   - invokevirtual of a method of an interface:
+//     Found interface Shape, but class was expected
+//   - invokeinterface of a method of a class:
+//     Found class Circle, but interface was expected
+//   - invokestatic or invokespecial whose reference is of the wrong kind:
+//     Method 'double Shape.unit()' must be InterfaceMethodref constant
+//
+// invokestatic and invokespecial can refer to the static and private methods of
+// interfaces with an InterfaceMethodref from Java 8 on; that the class file is recent
+// enough for this is checked when it's loaded (see formatCheckInvokes()). The JDK's
+// classes aren't checked.
+
+// checkMethodRef checks that the class of the method that the invoke instruction op
+// refers to is of the kind the reference requires. isInterfaceRef is whether the
+// reference is an InterfaceMethodref.
+func checkMethodRef(op byte, isInterfaceRef bool, className, methodName, methodType string) error {
+	if isJDKClass(className) {
+		return nil
+	}
+	data := classloader.ClassData(className)
+	if data == nil { // a class that can't be loaded is reported when the method is looked up
+		return nil
+	}
+	isInterface := data.Access.ClassIsInterface
+	switch {
+	case op == INVOKEVIRTUAL && isInterface:
+		return errors.New("java.lang.IncompatibleClassChangeError: Found interface " + javaName(className) +
+			", but class was expected")
+	case op == INVOKEINTERFACE && !isInterface:
+		return errors.New("java.lang.IncompatibleClassChangeError: Found class " + javaName(className) +
+			", but interface was expected")
+	case isInterface && !isInterfaceRef:
+		return errors.New("java.lang.IncompatibleClassChangeError: Method '" +
+			externalMethodName(className, methodName, methodType) + "' must be InterfaceMethodref constant")
+	case !isInterface && isInterfaceRef:
+		return errors.New("java.lang.IncompatibleClassChangeError: Method '" +
+			externalMethodName(className, methodName, methodType) + "' must be Methodref constant")
+	}
+	return nil
+}
+
+// externalMethodName returns the method as HotSpot's messages give it, with its
+// return type and the types of its parameters: void java.io.PrintStream.println(int)
+func externalMethodName(className, methodName, methodType string) string {
+	var params []string
+	desc := methodType[1:]
+	for desc != "" && desc[0] != ')' {
+		name, rest := externalTypeName(desc)
+		params = append(params, name)
+		desc = rest
+	}
+	returnType, _ := externalTypeName(strings.TrimPrefix(desc, ")"))
+	return returnType + " " + javaName(className) + "." + methodName + "(" + strings.Join(params, ", ") + ")"
+}
+
+// externalTypeName returns the Java name of the type that begins the descriptor, such
+// as int[] for [I, and the rest of the descriptor
+func externalTypeName(desc string) (string, string) {
+	dims := 0
+	for dims < len(desc) && desc[dims] == '[' {
+		dims++
+	}
+	desc = desc[dims:]
+	var name string
+	switch {
+	case desc == "":
+		return "", ""
+	case desc[0] == 'L':
+		end := strings.IndexByte(desc, ';')
+		if end < 0 {
+			end = len(desc) - 1
+		}
+		name, desc = javaName(desc[1:end]), desc[end+1:]
+	case desc[0] == 'V':
+		name, desc = "void", desc[1:]
+	default:
+		name, desc = classloader.PrimitiveNames[desc[0]], desc[1:]
+	}
+	return name + strings.Repeat("[]", dims), desc
+}
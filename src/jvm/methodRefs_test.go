@@ -0,0 +1,74 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"testing"
+)
+
+// addKindTestClasses puts the interface test/Shape and the class test/Circle in the
+// method area
+func addKindTestClasses(t *testing.T) {
+	for _, class := range []string{"test/Shape", "test/Circle"} {
+		data := &classloader.ClData{Name: class, Access: classloader.AccessFlags{ClassIsInterface: class == "test/Shape"}}
+		classloader.Classes[class] = classloader.Klass{Status: 'L', Loader: "app", Data: data}
+		class := class
+		t.Cleanup(func() { delete(classloader.Classes, class) })
+	}
+}
+
+func TestCheckMethodRef(t *testing.T) {
+	addKindTestClasses(t)
+	for _, test := range []struct {
+		op             byte
+		isInterfaceRef bool
+		class, message string
+	}{
+		{INVOKEVIRTUAL, false, "test/Circle", ""},
+		{INVOKEVIRTUAL, false, "test/Shape", "Found interface test.Shape, but class was expected"},
+		{INVOKEINTERFACE, true, "test/Shape", ""},
+		{INVOKEINTERFACE, true, "test/Circle", "Found class test.Circle, but interface was expected"},
+		{INVOKESTATIC, true, "test/Shape", ""}, // a static method of an interface
+		{INVOKESTATIC, false, "test/Shape", "Method 'double[] test.Shape.area(int, java.lang.String)' " +
+			"must be InterfaceMethodref constant"},
+		{INVOKESPECIAL, true, "test/Circle", "Method 'double[] test.Circle.area(int, java.lang.String)' " +
+			"must be Methodref constant"},
+		{INVOKESTATIC, true, "java/util/List", ""}, // the JDK's classes aren't checked
+	} {
+		err := checkMethodRef(test.op, test.isInterfaceRef, test.class, "area", "(ILjava/lang/String;)[D")
+		if test.message == "" && err != nil {
+			t.Errorf("Unexpected error for %s: %v", test.class, err)
+		} else if test.message != "" &&
+			(err == nil || err.Error() != "java.lang.IncompatibleClassChangeError: "+test.message) {
+			t.Errorf("Expected IncompatibleClassChangeError: %s, got %v", test.message, err)
+		}
+	}
+}
+
+// invokestatic of a static method of an interface resolves the InterfaceMethodref
+func TestInvokestaticInterfaceMethodref(t *testing.T) {
+	addKindTestClasses(t)
+	classloader.MTable = make(classloader.MT)
+	called := false
+	classloader.MTable[classloader.MethodKey("test/Shape.unit()V")] = classloader.MTentry{MType: 'G',
+		Meth: classloader.GmEntry{Fu: func([]interface{}) interface{} {
+			called = true
+			return nil
+		}}}
+
+	f := newFrame(INVOKESTATIC)
+	f.meth = append(f.meth, 0x00, 0x01)
+	f.cp = testCP("test/Shape.unit()V")
+	method := f.cp.MethodRefs[0]
+	f.cp.InterfaceRefs = []classloader.InterfaceRefEntry{{ClassIndex: method.ClassIndex, NameAndType: method.NameAndType}}
+	f.cp.CpIndex[1] = classloader.CpEntry{Type: classloader.Interface, Slot: 0}
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil || !called {
+		t.Errorf("Expected the interface's static method to be called, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bufio"
+	"container/list"
+	"fmt"
+	"io"
+	"jacobin/globals"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Per-method statistics. With -XX:+PrintMethodStatistics, the number of times each
+// method is invoked, the number of bytecodes it executes, and its self time (the time
+// spent in the method itself, not counting the methods it calls) are recorded, and
+// printed at exit as a table, the methods with the most self time first. Native
+// methods are included, with no bytecodes.
+//
+// The time a method spends blocked or waiting counts as its self time, so the self
+// time of a method that calls Thread.sleep(), say, is the time it slept.
+
+// methodStatistics is whether the statistics are recorded. It's set before the
+// program starts.
+var methodStatistics bool
+
+// frameStats are the statistics of one invocation, kept in its frame
+type frameStats struct {
+	start     time.Time
+	childTime time.Duration // the time spent in the methods it called
+	bytecodes int64
+}
+
+// methodStat is the total for a method
+type methodStat struct {
+	method      string // as in java/lang/String.length()I
+	invocations int64
+	bytecodes   int64
+	selfTime    time.Duration
+}
+
+var (
+	methodStats      = make(map[string]*methodStat)
+	methodStatsMutex sync.Mutex
+)
+
+// startMethodStatistics starts recording if -XX:+PrintMethodStatistics was given
+func startMethodStatistics(gl *globals.Globals) {
+	methodStatistics = gl.Flags.Bool("PrintMethodStatistics")
+}
+
+// printMethodStatistics prints the statistics, if they were recorded
+func printMethodStatistics() {
+	if methodStatistics {
+		_ = writeMethodStatistics(os.Stdout)
+	}
+}
+
+// beginInvocation starts the statistics of the invocation in the frame
+func beginInvocation(f *frame) {
+	f.stats = &frameStats{start: time.Now()}
+}
+
+// endInvocation adds the statistics of the invocation in the frame at e to its
+// method's, and its time to the child time of its caller, which is the next frame.
+// It's called before the frame is popped.
+func endInvocation(e *list.Element) {
+	f := e.Value.(*frame)
+	if f.stats == nil {
+		return
+	}
+	elapsed := time.Since(f.stats.start)
+	if next := e.Next(); next != nil {
+		if caller := next.Value.(*frame); caller.stats != nil {
+			caller.stats.childTime += elapsed
+		}
+	}
+	class, name, desc := frameMethod(f)
+	method := class + "." + name + desc
+
+	methodStatsMutex.Lock()
+	stat := methodStats[method]
+	if stat == nil {
+		stat = &methodStat{method: method}
+		methodStats[method] = stat
+	}
+	stat.invocations++
+	stat.bytecodes += f.stats.bytecodes
+	stat.selfTime += elapsed - f.stats.childTime
+	methodStatsMutex.Unlock()
+}
+
+// writeMethodStatistics writes the table of the statistics, the methods with the most
+// self time first
+func writeMethodStatistics(out io.Writer) error {
+	methodStatsMutex.Lock()
+	stats := make([]methodStat, 0, len(methodStats))
+	for _, stat := range methodStats {
+		stats = append(stats, *stat)
+	}
+	methodStatsMutex.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].selfTime != stats[j].selfTime {
+			return stats[i].selfTime > stats[j].selfTime
+		}
+		return stats[i].method < stats[j].method
+	})
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "%14s %12s %14s  %s\n", "Self time (ms)", "Invocations", "Bytecodes", "Method")
+	for _, stat := range stats {
+		fmt.Fprintf(w, "%14.3f %12d %14d  %s\n", float64(stat.selfTime)/float64(time.Millisecond),
+			stat.invocations, stat.bytecodes, stat.method)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,75 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"jacobin/classloader"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMethodStatistics(t *testing.T) {
+	classloader.MTable = make(classloader.MT)
+	classloader.MTable[classloader.MethodKey("test/Calc.pick(IJ)I")] = classloader.MTentry{
+		Meth:  &classloader.JmEntry{MaxStack: 1, MaxLocals: 4, Code: []byte{ILOAD_1, IRETURN}},
+		MType: 'J',
+	}
+	calc := classloader.NewObject("test/Calc", 0)
+
+	methodStats = make(map[string]*methodStat)
+	methodStatistics = true
+	defer func() { methodStatistics = false }()
+	for i := 0; i < 3; i++ {
+		if _, err := invokeMethod("test/Calc", "pick", "(IJ)I", []int64{calc, 7, 9}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	stat := methodStats["test/Calc.pick(IJ)I"]
+	if stat == nil {
+		t.Fatalf("Expected statistics for pick(), got: %v", methodStats)
+	}
+	if stat.invocations != 3 || stat.bytecodes != 6 {
+		t.Errorf("Expected 3 invocations and 6 bytecodes, got %d and %d", stat.invocations, stat.bytecodes)
+	}
+}
+
+func TestSelfTimeExcludesCallees(t *testing.T) {
+	methodStats = make(map[string]*methodStat)
+	fs := createFrameStack()
+	caller := createFrame(0)
+	caller.clName, caller.methName, caller.methType, caller.ftype = "Hello", "main", "()V", 'J'
+	fs.PushFront(caller)
+	beginInvocation(caller)
+	callee := createFrame(0)
+	callee.clName, callee.methName, callee.methType, callee.ftype = "Hello", "work", "()V", 'J'
+	fs.PushFront(callee)
+	beginInvocation(callee)
+
+	time.Sleep(20 * time.Millisecond)
+	endInvocation(fs.Front())
+	fs.Remove(fs.Front())
+	endInvocation(fs.Front())
+
+	work, main := methodStats["Hello.work()V"], methodStats["Hello.main()V"]
+	if work.selfTime < 20*time.Millisecond {
+		t.Errorf("Expected work() to have at least 20ms of self time, got %v", work.selfTime)
+	}
+	if main.selfTime >= work.selfTime {
+		t.Errorf("Expected main()'s self time (%v) to exclude work()'s (%v)", main.selfTime, work.selfTime)
+	}
+
+	var out bytes.Buffer
+	if err := writeMethodStatistics(&out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[1], "Hello.work()V") || !strings.HasSuffix(lines[2], "Hello.main()V") {
+		t.Errorf("Expected the methods with the most self time first, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,141 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/messages"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The metrics endpoint. With -XX:MetricsPort=<port>, the VM serves its heap usage,
+// threads, loaded classes, and uptime over HTTP on localhost, so they can be monitored
+// without JMX:
+//     /metrics       in the Prometheus text format
+//     /metrics.json  as JSON
+
+// vmMetrics is a snapshot of the VM's state
+type vmMetrics struct {
+	UptimeSeconds float64        `json:"uptimeSeconds"`
+	Heap          heapMetrics    `json:"heap"`
+	Threads       threadMetrics  `json:"threads"`
+	Classes       classesMetrics `json:"classes"`
+}
+
+type heapMetrics struct {
+	Objects     int64  `json:"objects"`     // the objects allocated on the Java heap
+	ObjectBytes int64  `json:"objectBytes"` // their estimated size
+	GoHeapBytes uint64 `json:"goHeapBytes"` // the memory allocated by the Go runtime
+	GoSysBytes  uint64 `json:"goSysBytes"`  // the memory the Go runtime got from the OS
+}
+
+type threadMetrics struct {
+	Live   int            `json:"live"`
+	Daemon int            `json:"daemon"`
+	States map[string]int `json:"states"` // the number of threads in each Thread.State
+}
+
+type classesMetrics struct {
+	Loaded int `json:"loaded"`
+}
+
+// collectMetrics takes a snapshot of the VM's state
+func collectMetrics(gl *globals.Globals) vmMetrics {
+	m := vmMetrics{UptimeSeconds: time.Since(gl.StartTime).Seconds()}
+
+	m.Heap.Objects, m.Heap.ObjectBytes = classloader.HeapUsage()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	m.Heap.GoHeapBytes, m.Heap.GoSysBytes = mem.HeapAlloc, mem.Sys
+
+	m.Threads.States = map[string]int{"RUNNABLE": 0, "BLOCKED": 0}
+	threadsMutex.Lock()
+	for _, t := range threads {
+		m.Threads.Live++
+		if t.daemon {
+			m.Threads.Daemon++
+		}
+		m.Threads.States[threadState(t)]++
+	}
+	threadsMutex.Unlock()
+
+	classloader.MethAreaMutex.RLock()
+	m.Classes.Loaded = len(classloader.Classes)
+	classloader.MethAreaMutex.RUnlock()
+	return m
+}
+
+// writePrometheusMetrics writes the metrics in the Prometheus text format
+func writePrometheusMetrics(w io.Writer, m vmMetrics) {
+	metric := func(name, kind, help string, value interface{}) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+	metric("jacobin_uptime_seconds", "gauge", "How long the VM has been running.",
+		strconv.FormatFloat(m.UptimeSeconds, 'f', 3, 64))
+	metric("jacobin_heap_objects", "gauge", "The objects allocated on the Java heap.", m.Heap.Objects)
+	metric("jacobin_heap_object_bytes", "gauge", "The estimated size of the objects on the Java heap.",
+		m.Heap.ObjectBytes)
+	metric("jacobin_go_heap_bytes", "gauge", "The memory allocated by the Go runtime.", m.Heap.GoHeapBytes)
+	metric("jacobin_go_sys_bytes", "gauge", "The memory obtained from the OS by the Go runtime.",
+		m.Heap.GoSysBytes)
+	metric("jacobin_threads_live", "gauge", "The live threads.", m.Threads.Live)
+	metric("jacobin_threads_daemon", "gauge", "The live daemon threads.", m.Threads.Daemon)
+
+	fmt.Fprintln(w, "# HELP jacobin_threads The live threads in each state.")
+	fmt.Fprintln(w, "# TYPE jacobin_threads gauge")
+	var states []string
+	for state := range m.Threads.States {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+	for _, state := range states {
+		fmt.Fprintf(w, "jacobin_threads{state=%q} %d\n", state, m.Threads.States[state])
+	}
+	metric("jacobin_classes_loaded", "gauge", "The classes that have been loaded.", m.Classes.Loaded)
+}
+
+// metricsHandler returns the handler of the metrics endpoint
+func metricsHandler(gl *globals.Globals) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writePrometheusMetrics(w, collectMetrics(gl))
+	})
+	mux.HandleFunc("/metrics.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(collectMetrics(gl))
+	})
+	addPprofHandlers(mux, gl)
+	return mux
+}
+
+// startMetricsServer serves the metrics if -XX:MetricsPort was given. If it can't, the
+// user is warned and the program runs anyway.
+func startMetricsServer(gl *globals.Globals) {
+	port := gl.Flags.Int("MetricsPort")
+	if port == 0 {
+		return
+	}
+	listener, err := net.Listen("tcp", "localhost:"+strconv.FormatInt(port, 10))
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0043", port, err.Error())
+		return
+	}
+	log.Log("Serving metrics at http://"+listener.Addr().String()+"/metrics", log.INFO)
+	go func() {
+		_ = http.Serve(listener, metricsHandler(gl))
+	}()
+}
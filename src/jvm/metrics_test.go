@@ -0,0 +1,58 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"encoding/json"
+	"jacobin/globals"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCollectMetrics(t *testing.T) {
+	gl := globals.InitGlobals("test")
+	daemon := CreateThread(newThreadID())
+	daemon.daemon = true
+	registerThread(&daemon)
+	defer threadEnded(&daemon)
+
+	m := collectMetrics(&gl)
+	if m.Threads.Live < 1 || m.Threads.Daemon < 1 || m.Threads.States["RUNNABLE"] < 1 {
+		t.Errorf("expected the daemon thread to be counted, got %+v", m.Threads)
+	}
+	if m.UptimeSeconds < 0 || m.Heap.GoSysBytes == 0 {
+		t.Errorf("unexpected metrics: %+v", m)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	gl := globals.InitGlobals("test")
+	handler := metricsHandler(&gl)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{"# TYPE jacobin_uptime_seconds gauge\njacobin_uptime_seconds ",
+		"jacobin_heap_objects ", "jacobin_threads{state=\"BLOCKED\"} ", "jacobin_classes_loaded "} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in the Prometheus metrics, got:\n%s", want, body)
+		}
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("unexpected content type %s", rec.Header().Get("Content-Type"))
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics.json", nil))
+	var m vmMetrics
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+		t.Fatalf("expected JSON, got %s", rec.Body.String())
+	}
+	if _, present := m.Threads.States["RUNNABLE"]; !present {
+		t.Errorf("expected the thread states in the JSON, got %s", rec.Body.String())
+	}
+}
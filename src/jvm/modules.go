@@ -0,0 +1,146 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"io"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/messages"
+	"os"
+	"sort"
+	"strings"
+)
+
+// startingModuleClass resolves the module named by -m from the module path and returns
+// the class file of its main class: the one named by -m, or else the module's own main
+// class. Errors are shown to the user.
+func startingModuleClass(gl *globals.Globals) (string, error) {
+	name, mainClass := gl.StartingModule, ""
+	if slash := strings.Index(name, "/"); slash >= 0 {
+		name, mainClass = name[:slash], name[slash+1:]
+	}
+	modules, err := classloader.ResolveModules(gl.ModulePath, name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return "", err
+	}
+	if len(modules) == 0 { // only a module of the runtime image has that name
+		return "", messages.Print("JACOBIN-CL-0158", name)
+	}
+	root := modules[0]
+	if mainClass == "" {
+		mainClass = root.Descriptor.MainClass
+	}
+	if mainClass == "" {
+		return "", messages.Print("JACOBIN-LA-0035", name)
+	}
+	globals.SystemProperties.Set("jdk.module.main.class", mainClass)
+	log.Log("Module "+name+" resolved from "+root.Location, log.FINE)
+	return classloader.ClassFileInModule(root, mainClass), nil
+}
+
+// --list-modules and --describe-module show the modules of the runtime image in JAVA_HOME
+// (see classloader/runtimeImage.go) in the same format as java does, so users can check
+// what Jacobin sees.
+
+// listModules shows the name and version of each module, one per line
+func listModules(out io.Writer, gl *globals.Globals) error {
+	modules, err := classloader.RuntimeModules(gl.JavaHome)
+	if err != nil {
+		return err
+	}
+	for _, md := range modules {
+		fmt.Fprintln(out, nameAndVersion(&md))
+	}
+	return nil
+}
+
+// describeModule shows the module's descriptor
+func describeModule(out io.Writer, gl *globals.Globals, name string) error {
+	md, err := classloader.RuntimeModule(gl.JavaHome, name)
+	if err != nil {
+		return err
+	}
+	for _, line := range moduleDescription(md) {
+		fmt.Fprintln(out, line)
+	}
+	return nil
+}
+
+// moduleDescription returns the lines describing a module. As with java, the exports,
+// requires, uses, provides, qualified exports, opens, and qualified opens are listed in
+// that order, each sorted, followed by the packages that are neither exported nor opened.
+func moduleDescription(md *classloader.ModuleDescriptor) []string {
+	var lines []string
+	add := func(group []string) {
+		sort.Strings(group)
+		lines = append(lines, group...)
+	}
+
+	header := nameAndVersion(md)
+	if md.Open {
+		header += " open"
+	}
+	lines = append(lines, header)
+
+	visible := make(map[string]bool) // the exported and opened packages
+	var exports, qualifiedExports, opens, qualifiedOpens []string
+	for _, pkg := range md.Exports {
+		visible[pkg.Name] = true
+		if len(pkg.To) == 0 {
+			exports = append(exports, "exports "+pkg.Name)
+		} else {
+			qualifiedExports = append(qualifiedExports, "qualified exports "+pkg.Name+" to "+sortedList(pkg.To))
+		}
+	}
+	for _, pkg := range md.Opens {
+		visible[pkg.Name] = true
+		if len(pkg.To) == 0 {
+			opens = append(opens, "opens "+pkg.Name)
+		} else {
+			qualifiedOpens = append(qualifiedOpens, "qualified opens "+pkg.Name+" to "+sortedList(pkg.To))
+		}
+	}
+
+	var requires, uses, provides, contains []string
+	for _, req := range md.Requires {
+		requires = append(requires, strings.TrimSpace("requires "+req.Name+" "+strings.Join(req.Modifiers, " ")))
+	}
+	for _, service := range md.Uses {
+		uses = append(uses, "uses "+service)
+	}
+	for _, p := range md.Provides {
+		provides = append(provides, "provides "+p.Service+" with "+sortedList(p.With))
+	}
+	for _, pkg := range md.Packages {
+		if !visible[pkg] {
+			contains = append(contains, "contains "+pkg)
+		}
+	}
+
+	for _, group := range [][]string{exports, requires, uses, provides, qualifiedExports,
+		opens, qualifiedOpens, contains} {
+		add(group)
+	}
+	return lines
+}
+
+// nameAndVersion returns the module's name, followed by @ and its version if it has one
+func nameAndVersion(md *classloader.ModuleDescriptor) string {
+	if md.Version == "" {
+		return md.Name
+	}
+	return md.Name + "@" + md.Version
+}
+
+func sortedList(names []string) string {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, " ")
+}
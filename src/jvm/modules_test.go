@@ -0,0 +1,109 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/messages"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestModuleDescription(t *testing.T) {
+	md := &classloader.ModuleDescriptor{
+		Name:    "java.logging",
+		Version: "11.0.10",
+		Requires: []classloader.ModuleRequires{{Name: "java.base", Modifiers: []string{"mandated"}},
+			{Name: "java.a", Modifiers: []string{"transitive", "static"}}},
+		Exports: []classloader.ModulePackage{{Name: "java.util.logging"},
+			{Name: "sun.util.logging", To: []string{"java.prefs", "java.desktop"}}},
+		Opens: []classloader.ModulePackage{{Name: "sun.util.logging.internal", To: []string{"java.base"}}},
+		Uses:  []string{"java.util.logging.Filter"},
+		Provides: []classloader.ModuleProvides{{Service: "jdk.internal.logger.DefaultLoggerFinder",
+			With: []string{"sun.util.logging.internal.LoggingProviderImpl"}}},
+		Packages: []string{"sun.util.logging.resources", "java.util.logging", "sun.util.logging",
+			"sun.util.logging.internal"},
+	}
+	want := []string{
+		"java.logging@11.0.10",
+		"exports java.util.logging",
+		"requires java.a transitive static",
+		"requires java.base mandated",
+		"uses java.util.logging.Filter",
+		"provides jdk.internal.logger.DefaultLoggerFinder with sun.util.logging.internal.LoggingProviderImpl",
+		"qualified exports sun.util.logging to java.desktop java.prefs",
+		"qualified opens sun.util.logging.internal to java.base",
+		"contains sun.util.logging.resources",
+	}
+	if lines := moduleDescription(md); !reflect.DeepEqual(lines, want) {
+		t.Errorf("Expected:\n%q\ngot:\n%q", want, lines)
+	}
+}
+
+func TestListModules(t *testing.T) {
+	home, _ := ioutil.TempDir("", "jacobin-jre")
+	defer os.RemoveAll(home)
+	_ = ioutil.WriteFile(filepath.Join(home, "release"),
+		[]byte("JAVA_VERSION=\"11.0.10\"\nMODULES=\"java.base java.logging\"\n"), 0644)
+	global := globals.InitGlobals("test")
+	global.JavaHome = home
+
+	var out bytes.Buffer
+	if err := listModules(&out, &global); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out.String() != "java.base@11.0.10\njava.logging@11.0.10\n" {
+		t.Errorf("Unexpected module listing: %q", out.String())
+	}
+}
+
+// an automatic module's main class is its manifest's Main-Class
+func TestStartingModuleClass(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "jacobin-mods")
+	defer os.RemoveAll(dir)
+	writeJar := func(name, manifest string) {
+		var jar bytes.Buffer
+		z := zip.NewWriter(&jar)
+		w, _ := z.Create("META-INF/MANIFEST.MF")
+		_, _ = w.Write([]byte(manifest))
+		_ = z.Close()
+		_ = ioutil.WriteFile(filepath.Join(dir, name), jar.Bytes(), 0644)
+	}
+	writeJar("hello-1.0.jar", "Manifest-Version: 1.0\nMain-Class: com.example.Hello\n\n")
+	writeJar("tool.jar", "Manifest-Version: 1.0\n\n")
+
+	global := globals.InitGlobals("test")
+	global.ModulePath = dir
+	global.StartingModule = "hello"
+	classFile, err := startingModuleClass(&global)
+	want := filepath.Join(dir, "hello-1.0.jar") + "!/com/example/Hello.class"
+	if err != nil || classFile != want {
+		t.Errorf("Expected %s, got %s (%v)", want, classFile, err)
+	}
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	global.StartingModule = "tool"
+	_, errTool := startingModuleClass(&global)
+	global.StartingModule = "missing/com.example.Main"
+	_, errMissing := startingModuleClass(&global)
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if messages.CodeOf(errTool) != "JACOBIN-LA-0035" {
+		t.Errorf("Expected an error for a module without a main class, got: %v", errTool)
+	}
+	if messages.CodeOf(errMissing) != "JACOBIN-CL-0158" {
+		t.Errorf("Expected a module not found error, got: %v", errMissing)
+	}
+}
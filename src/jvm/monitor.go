@@ -0,0 +1,367 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"jacobin/classloader"
+	"jacobin/jfr"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Object monitors, as used by synchronized methods and blocks (the monitorenter
+// and monitorexit bytecodes) and by Object.wait() and notify(). Monitors are
+// re-entrant: the owning thread can enter the same monitor repeatedly, and must
+// exit it an equal number of times before another thread can enter it.
+//
+// Most monitors are never contended, and giving every object a full monitor (with
+// a mutex, condition variable, and wait set) is expensive. So locking is first done
+// with a thin lock: a lock word in the object header (Object.Mark) that is updated
+// with compare-and-swap. The lock word has this layout:
+//
+//     bit 0       1 = the lock is inflated (the monitor is in the monitors table)
+//     bits 1-15   the recursion count of a thin lock
+//     bits 16-63  the ID of the owning thread + 1 (0 = unlocked)
+//
+// When a second thread tries to enter a thin-locked monitor, or the owner calls
+// wait(), the lock is inflated: a full monitor is created and given the owner and
+// recursion count from the lock word, and the lock word is marked as inflated.
+// Inflated locks are never deflated. References that aren't to objects on the heap
+// always use full monitors.
+//
+// Full monitors are kept in a table keyed by the reference to the object they belong
+// to. The owner of each monitor and the monitor each thread is blocked on (that is,
+// execThread.blockedOn) form the owner/waiter graph that deadlock.go walks.
+
+type monitor struct {
+	mutex   sync.Mutex      // protects the following fields
+	cond    *sync.Cond      // signaled when the monitor is released
+	owner   int             // ID of the owning thread; -1 if the monitor is not owned
+	count   int             // number of times the owner has entered the monitor
+	waitSet []chan struct{} // threads in wait(), in order of arrival
+}
+
+const (
+	inflatedBit  = int64(1)
+	countShift   = 1
+	countMask    = int64(0x7FFF) << countShift
+	ownerShift   = 16
+	maxThinCount = 0x7FFF
+	unlockedWord = int64(0)
+)
+
+const errNPE = "java.lang.NullPointerException"
+const errIllegalMon = "java.lang.IllegalMonitorStateException"
+
+// thinWord returns the lock word for a thin lock owned by the thread, entered count times
+func thinWord(threadID int, count int) int64 {
+	return int64(threadID+1)<<ownerShift | int64(count)<<countShift
+}
+
+// thinOwner returns the ID of the thread that owns a thin lock word, or -1 if unlocked
+func thinOwner(word int64) int {
+	return int(word>>ownerShift) - 1
+}
+
+// thinCount returns the recursion count of a thin lock word
+func thinCount(word int64) int {
+	return int((word & countMask) >> countShift)
+}
+
+var monitors = make(map[int64]*monitor)
+var monitorsMutex sync.Mutex
+
+// getMonitor returns the full monitor for the referenced object, creating it if need be
+func getMonitor(ref int64) *monitor {
+	monitorsMutex.Lock()
+	defer monitorsMutex.Unlock()
+	m, present := monitors[ref]
+	if !present {
+		m = &monitor{owner: -1}
+		m.cond = sync.NewCond(&m.mutex)
+		monitors[ref] = m
+	}
+	return m
+}
+
+// monitorEnter acquires the monitor of the referenced object for the thread,
+// blocking until the monitor is available.
+func monitorEnter(t *execThread, ref int64) error {
+	if ref == 0 {
+		return errors.New(errNPE)
+	}
+
+	obj := classloader.GetObject(ref)
+	if obj == nil { // not a heap object, so it always uses a full monitor
+		enterInflated(t, ref)
+		return nil
+	}
+
+	for {
+		word := atomic.LoadInt64(&obj.Mark)
+		switch {
+		case word == unlockedWord: // the fast path: uncontended lock
+			if atomic.CompareAndSwapInt64(&obj.Mark, unlockedWord, thinWord(t.id, 1)) {
+				return nil
+			}
+		case word&inflatedBit != 0:
+			enterInflated(t, ref)
+			return nil
+		case thinOwner(word) == t.id && thinCount(word) < maxThinCount: // re-entry
+			if atomic.CompareAndSwapInt64(&obj.Mark, word, thinWord(t.id, thinCount(word)+1)) {
+				return nil
+			}
+		default: // contended (or the recursion count is full), so inflate
+			inflate(obj, ref)
+		}
+	}
+}
+
+// monitorExit releases one entry of the monitor of the referenced object. When the
+// owner has exited as many times as it entered, the monitor is available to others.
+func monitorExit(t *execThread, ref int64) error {
+	if ref == 0 {
+		return errors.New(errNPE)
+	}
+
+	obj := classloader.GetObject(ref)
+	if obj != nil {
+		for {
+			word := atomic.LoadInt64(&obj.Mark)
+			if word&inflatedBit != 0 {
+				break // use the full monitor
+			}
+			if thinOwner(word) != t.id {
+				return errors.New(errIllegalMon)
+			}
+			newWord := unlockedWord
+			if thinCount(word) > 1 {
+				newWord = thinWord(t.id, thinCount(word)-1)
+			}
+			// the CAS fails only if another thread inflated the lock in the meantime
+			if atomic.CompareAndSwapInt64(&obj.Mark, word, newWord) {
+				return nil
+			}
+		}
+	}
+
+	m := getMonitor(ref)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.owner != t.id {
+		return errors.New(errIllegalMon)
+	}
+	m.count -= 1
+	if m.count == 0 {
+		m.owner = -1
+		m.cond.Signal()
+	}
+	return nil
+}
+
+// inflate converts the object's thin lock into a full monitor, which takes over the
+// owner and recursion count of the thin lock. If the lock word changes while this is
+// being done (because the owner released or re-entered the lock, say), nothing is
+// changed and the caller simply tries again.
+func inflate(obj *classloader.Object, ref int64) {
+	m := getMonitor(ref)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	word := atomic.LoadInt64(&obj.Mark)
+	if word&inflatedBit != 0 {
+		return // another thread inflated it first
+	}
+	if word == unlockedWord {
+		m.owner, m.count = -1, 0
+	} else {
+		m.owner, m.count = thinOwner(word), thinCount(word)
+	}
+	if !atomic.CompareAndSwapInt64(&obj.Mark, word, inflatedBit) {
+		m.owner, m.count = -1, 0
+	}
+}
+
+// enterInflated acquires the full monitor for the referenced object. While the thread
+// is blocked, its stack is parked for thread dumps, provided it's the thread running
+// on this goroutine (code run by invokeMethod() is given the main thread's frames).
+func enterInflated(t *execThread, ref int64) {
+	m := getMonitor(ref)
+	m.mutex.Lock()
+	var waitingSince time.Time // for the flight recorder
+	owner := m.owner
+	parked := false
+	for m.owner != -1 && m.owner != t.id {
+		if waitingSince.IsZero() {
+			waitingSince, owner = time.Now(), m.owner
+			if parked = currentThreadID() == t.id; parked {
+				parkStack(t)
+			}
+		}
+		setBlockedOn(t, ref)
+		m.cond.Wait()
+	}
+	setBlockedOn(t, 0)
+	if parked {
+		unparkStack(t)
+	}
+	m.owner = t.id
+	m.count += 1
+	m.mutex.Unlock()
+
+	if !waitingSince.IsZero() && jfr.Recording() {
+		class := ""
+		if obj := classloader.GetObject(ref); obj != nil {
+			class = obj.Klass
+		}
+		jfr.MonitorContended(class, ref, owner, time.Since(waitingSince))
+	}
+}
+
+// monitorOwner returns the ID of the thread that owns the monitor of the referenced
+// object, or -1 if the monitor is not owned.
+func monitorOwner(ref int64) int {
+	obj := classloader.GetObject(ref)
+	if obj != nil {
+		word := atomic.LoadInt64(&obj.Mark)
+		if word&inflatedBit == 0 {
+			return thinOwner(word)
+		}
+	}
+
+	m := getMonitor(ref)
+	m.mutex.Lock()
+	owner := m.owner
+	m.mutex.Unlock()
+	return owner
+}
+
+// monitorWait implements Object.wait() and wait(millis). The caller must own the
+// monitor. It fully releases the monitor, waits until notified, timed out (if millis
+// is > 0), or interrupted, and then re-acquires the monitor with its previous recursion
+// count. If interrupted, it clears the interrupt status and returns errInterrupted.
+func monitorWait(t *execThread, ref int64, millis int64) error {
+	if ref == 0 {
+		return errors.New(errNPE)
+	}
+	if monitorOwner(ref) != t.id {
+		return errors.New(errIllegalMon)
+	}
+	if millis < 0 {
+		return errors.New("java.lang.IllegalArgumentException: timeout value is negative")
+	}
+
+	// a wait set requires a full monitor. Only the owner can change the lock word
+	// of a thin lock it holds, so once inflated here, it stays that way.
+	obj := classloader.GetObject(ref)
+	if obj != nil {
+		for atomic.LoadInt64(&obj.Mark)&inflatedBit == 0 {
+			inflate(obj, ref)
+		}
+	}
+
+	if clearInterrupt(t) {
+		return errInterrupted
+	}
+
+	m := getMonitor(ref)
+	m.mutex.Lock()
+	savedCount := m.count
+	m.owner, m.count = -1, 0
+	notified := make(chan struct{})
+	m.waitSet = append(m.waitSet, notified)
+	m.cond.Signal()
+	m.mutex.Unlock()
+
+	var timeout <-chan time.Time
+	if millis > 0 {
+		timer := time.NewTimer(time.Duration(millis) * time.Millisecond)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-notified:
+	case <-timeout:
+	case <-t.interruptCh:
+		clearInterrupt(t)
+		err = errInterrupted
+	}
+
+	// re-acquire the monitor and restore the recursion count
+	m.mutex.Lock()
+	removeWaiter(m, notified)
+	for m.owner != -1 {
+		setBlockedOn(t, ref)
+		m.cond.Wait()
+	}
+	setBlockedOn(t, 0)
+	m.owner, m.count = t.id, savedCount
+	m.mutex.Unlock()
+	return err
+}
+
+// monitorNotify implements Object.notify() (all == false) and Object.notifyAll().
+// The caller must own the monitor.
+func monitorNotify(t *execThread, ref int64, all bool) error {
+	if ref == 0 {
+		return errors.New(errNPE)
+	}
+	if monitorOwner(ref) != t.id {
+		return errors.New(errIllegalMon)
+	}
+
+	obj := classloader.GetObject(ref)
+	if obj != nil && atomic.LoadInt64(&obj.Mark)&inflatedBit == 0 {
+		return nil // a thin lock has no waiters
+	}
+
+	m := getMonitor(ref)
+	m.mutex.Lock()
+	for len(m.waitSet) > 0 {
+		close(m.waitSet[0])
+		m.waitSet = m.waitSet[1:]
+		if !all {
+			break
+		}
+	}
+	m.mutex.Unlock()
+	return nil
+}
+
+// waitOnObject implements classloader.ObjectWait for Object.wait(millis), which waits
+// on the current thread
+func waitOnObject(ref, millis int64) error {
+	return monitorWait(currentThread(), ref, millis)
+}
+
+// notifyObject implements classloader.ObjectNotify for Object.notify() and notifyAll()
+func notifyObject(ref int64, all bool) error {
+	return monitorNotify(currentThread(), ref, all)
+}
+
+// removeWaiter removes the waiter from the wait set, if it's still there (it won't
+// be if it was notified). The monitor's mutex must be held.
+func removeWaiter(m *monitor, waiter chan struct{}) {
+	for i, w := range m.waitSet {
+		if w == waiter {
+			m.waitSet = append(m.waitSet[:i], m.waitSet[i+1:]...)
+			return
+		}
+	}
+}
+
+// setBlockedOn records the object whose monitor the thread is waiting to enter.
+// It's protected by threadsMutex, so the deadlock detector sees a consistent value.
+func setBlockedOn(t *execThread, ref int64) {
+	threadsMutex.Lock()
+	t.blockedOn = ref
+	threadsMutex.Unlock()
+}
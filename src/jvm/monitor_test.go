@@ -0,0 +1,231 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"testing"
+	"time"
+)
+
+func TestMonitorIsReentrant(t *testing.T) {
+	th := CreateThread(140)
+	_ = monitorEnter(&th, 0x1400)
+	_ = monitorEnter(&th, 0x1400)
+	if monitorOwner(0x1400) != 140 {
+		t.Errorf("Expected monitor owner to be thread 140, got: %d", monitorOwner(0x1400))
+	}
+	_ = monitorExit(&th, 0x1400)
+	if monitorOwner(0x1400) != 140 {
+		t.Errorf("Monitor released after only one of two exits")
+	}
+	_ = monitorExit(&th, 0x1400)
+	if monitorOwner(0x1400) != -1 {
+		t.Errorf("Expected monitor to be released, but owner is: %d", monitorOwner(0x1400))
+	}
+}
+
+func TestMonitorExitByNonOwner(t *testing.T) {
+	th1 := CreateThread(141)
+	th2 := CreateThread(142)
+	_ = monitorEnter(&th1, 0x1410)
+	if monitorExit(&th2, 0x1410) == nil {
+		t.Errorf("Expected IllegalMonitorStateException when non-owner exits monitor")
+	}
+	if monitorEnter(&th1, 0) == nil {
+		t.Errorf("Expected NullPointerException when entering monitor of null")
+	}
+	_ = monitorExit(&th1, 0x1410)
+}
+
+// a second thread blocks until the first releases the monitor
+func TestMonitorBlocksOtherThreads(t *testing.T) {
+	th1 := CreateThread(143)
+	th2 := CreateThread(144)
+	_ = monitorEnter(&th1, 0x1420)
+
+	entered := make(chan bool)
+	go func() {
+		_ = monitorEnter(&th2, 0x1420)
+		entered <- true
+	}()
+
+	select {
+	case <-entered:
+		t.Fatalf("Second thread entered a monitor owned by another thread")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	_ = monitorExit(&th1, 0x1420)
+	select {
+	case <-entered:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Second thread did not enter monitor after it was released")
+	}
+	if monitorOwner(0x1420) != 144 {
+		t.Errorf("Expected monitor owner to be thread 144, got: %d", monitorOwner(0x1420))
+	}
+}
+
+// an uncontended lock on a heap object stays thin; no full monitor is created
+func TestThinLockUncontended(t *testing.T) {
+	th := CreateThread(145)
+	ref := classloader.NewObject("test/Lock", 0)
+	_ = monitorEnter(&th, ref)
+	_ = monitorEnter(&th, ref)
+
+	word := classloader.GetObject(ref).Mark
+	if word&inflatedBit != 0 || thinOwner(word) != 145 || thinCount(word) != 2 {
+		t.Errorf("Expected thin lock owned by 145 with count 2, got lock word: 0x%x", word)
+	}
+	monitorsMutex.Lock()
+	_, inflated := monitors[ref]
+	monitorsMutex.Unlock()
+	if inflated {
+		t.Errorf("Full monitor created for uncontended lock")
+	}
+
+	_ = monitorExit(&th, ref)
+	_ = monitorExit(&th, ref)
+	if classloader.GetObject(ref).Mark != unlockedWord {
+		t.Errorf("Expected unlocked lock word, got: 0x%x", classloader.GetObject(ref).Mark)
+	}
+	if monitorExit(&th, ref) == nil {
+		t.Errorf("Expected IllegalMonitorStateException on exit of unowned thin lock")
+	}
+}
+
+// contention inflates the lock, and the owner's recursion count carries over
+func TestThinLockInflatesOnContention(t *testing.T) {
+	th1 := CreateThread(146)
+	th2 := CreateThread(147)
+	ref := classloader.NewObject("test/Lock", 0)
+	_ = monitorEnter(&th1, ref)
+	_ = monitorEnter(&th1, ref)
+
+	entered := make(chan bool)
+	go func() {
+		_ = monitorEnter(&th2, ref)
+		entered <- true
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	if classloader.GetObject(ref).Mark&inflatedBit == 0 {
+		t.Fatalf("Expected contended lock to be inflated")
+	}
+	if monitorOwner(ref) != 146 {
+		t.Errorf("Expected inflated monitor to be owned by 146, got: %d", monitorOwner(ref))
+	}
+
+	_ = monitorExit(&th1, ref)
+	select {
+	case <-entered:
+		t.Fatalf("Monitor released before owner exited all its entries")
+	case <-time.After(10 * time.Millisecond):
+	}
+	_ = monitorExit(&th1, ref)
+	select {
+	case <-entered:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Contending thread did not acquire the inflated monitor")
+	}
+}
+
+func TestWaitAndNotify(t *testing.T) {
+	th1 := CreateThread(148)
+	th2 := CreateThread(149)
+	ref := classloader.NewObject("test/Lock", 0)
+
+	if monitorWait(&th1, ref, 0) == nil {
+		t.Errorf("Expected IllegalMonitorStateException on wait() without owning monitor")
+	}
+
+	woke := make(chan error)
+	go func() {
+		_ = monitorEnter(&th1, ref)
+		err := monitorWait(&th1, ref, 0)
+		_ = monitorExit(&th1, ref)
+		woke <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// the waiting thread released the monitor, so it can be entered
+	_ = monitorEnter(&th2, ref)
+	_ = monitorNotify(&th2, ref, false)
+	_ = monitorExit(&th2, ref)
+
+	select {
+	case err := <-woke:
+		if err != nil {
+			t.Errorf("Expected normal return from wait(), got: %s", err.Error())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("notify() did not wake the waiting thread")
+	}
+}
+
+func TestWaitWithTimeoutAndInterrupt(t *testing.T) {
+	th := CreateThread(159)
+	ref := classloader.NewObject("test/Lock", 0)
+	_ = monitorEnter(&th, ref)
+	if err := monitorWait(&th, ref, 10); err != nil {
+		t.Errorf("Expected timed wait() to return normally, got: %s", err.Error())
+	}
+	if monitorOwner(ref) != 159 {
+		t.Errorf("Expected monitor to be re-acquired after wait(), owner is: %d", monitorOwner(ref))
+	}
+
+	interruptThread(&th)
+	if monitorWait(&th, ref, 0) != errInterrupted {
+		t.Errorf("Expected InterruptedException from wait() on interrupted thread")
+	}
+	_ = monitorExit(&th, ref)
+}
+
+// Object.wait(J), notify(), and notifyAll() act on the monitor of the current thread,
+// which must own it
+func TestObjectWaitAndNotifyNatives(t *testing.T) {
+	classloader.ObjectWait = waitOnObject
+	classloader.ObjectNotify = notifyObject
+	classloader.Load_Lang_Object()
+	wait, _ := classloader.LookupNative("java/lang/Object.wait(J)V")
+	notifyAll, _ := classloader.LookupNative("java/lang/Object.notifyAll()V")
+	ref := classloader.NewObject("test/Lock", 0)
+
+	err, _ := notifyAll.GFunction([]interface{}{ref}).(error)
+	if err == nil || err.Error() != errIllegalMon {
+		t.Errorf("Expected an IllegalMonitorStateException from notifyAll() without the monitor, got %v", err)
+	}
+
+	th := CreateThread(newThreadID())
+	registerThread(&th)
+	defer threadEnded(&th)
+	woke := make(chan interface{})
+	go func() {
+		goroutine := goroutineID()
+		goroutineThreads.Store(goroutine, th.id)
+		defer goroutineThreads.Delete(goroutine)
+		_ = monitorEnter(&th, ref)
+		ret := wait.GFunction([]interface{}{ref, int64(0)})
+		_ = monitorExit(&th, ref)
+		woke <- ret
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// the test runs on the main thread, which enters the monitor the waiter released
+	_ = monitorEnter(&MainThread, ref)
+	notifyAll.GFunction([]interface{}{ref})
+	_ = monitorExit(&MainThread, ref)
+	select {
+	case ret := <-woke:
+		if err, _ := ret.(error); err != nil {
+			t.Errorf("Expected wait() to return normally, got: %s", err.Error())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("notifyAll() did not wake the waiting thread")
+	}
+}
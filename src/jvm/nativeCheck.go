@@ -0,0 +1,144 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/messages"
+	"strings"
+)
+
+// -Xcheck:native, which checks the Go natives, in two ways. At start-up, the classes
+// that have natives are loaded, and each native is compared with the method it
+// implements (see classloader/nativeCheck.go); problems are shown as warnings. Then,
+// at each call of a native, the arguments on the caller's operand stack are checked
+// against the method's descriptor (their number, the ranges of the integral types, and
+// that references are null or refer to objects), as is the value the native returns.
+// A bad call ends the thread with an InternalError, before the native can misbehave.
+
+// checkNatives is whether -Xcheck:native was given. It's set before the program starts.
+var checkNatives bool
+
+// checkNativeRegistry checks the registered natives against the classes they're in
+func checkNativeRegistry() {
+	unchecked := 0
+	for _, class := range classloader.NativeClasses() {
+		if classloader.LoadClassFromNameOnly(class) != nil {
+			unchecked++
+			continue
+		}
+		for _, problem := range classloader.CheckNativeClass(class) {
+			_ = messages.Print("JACOBIN-LA-0053", problem)
+		}
+	}
+	if unchecked > 0 {
+		_ = messages.Print("JACOBIN-LA-0054", unchecked)
+	}
+}
+
+// nativeCheckError is the error that a failed check ends the thread with
+func nativeCheckError(methFQN, problem string) error {
+	return errors.New("java.lang.InternalError: -Xcheck:native: " + methFQN + ": " + problem)
+}
+
+// checkNativeCall checks the arguments of a call of the native, which are the top
+// slots of the operand stack of the calling frame
+func checkNativeCall(f *frame, slots int, methFQN string) error {
+	params, _, err := classloader.ParseDescriptor(methFQN[strings.Index(methFQN, "("):])
+	if err != nil {
+		return nativeCheckError(methFQN, err.Error())
+	}
+	if slots == len(params)+1 { // the first argument is 'this'
+		params = append([]byte{'T'}, params...)
+	} else if slots != len(params) {
+		return nativeCheckError(methFQN, fmt.Sprintf("the native takes %d slots, but the descriptor has %d parameters",
+			slots, len(params)))
+	}
+	if f.tos+1 < slots {
+		return nativeCheckError(methFQN, fmt.Sprintf("the caller's stack has %d values, but %d are needed",
+			f.tos+1, slots))
+	}
+	args := f.opStack[f.tos+1-slots : f.tos+1]
+	for i, p := range params {
+		if !slotFits(args[i], p) {
+			return nativeCheckError(methFQN, fmt.Sprintf("argument %d (%d) isn't a valid %s", i, args[i], javaTypeName(p)))
+		}
+	}
+	return nil
+}
+
+// checkNativeReturn checks the value the native returned
+func checkNativeReturn(methFQN string, retval interface{}) error {
+	_, ret, err := classloader.ParseDescriptor(methFQN[strings.Index(methFQN, "("):])
+	if err != nil {
+		return nativeCheckError(methFQN, err.Error())
+	}
+	if ret == 'V' {
+		if retval != nil {
+			return nativeCheckError(methFQN, fmt.Sprintf("the void method returned %v", retval))
+		}
+		return nil
+	}
+	slot, ok := retval.(int64)
+	if !ok {
+		return nativeCheckError(methFQN, fmt.Sprintf("the method returned %v (%T), not a %s", retval, retval, javaTypeName(ret)))
+	}
+	if !slotFits(slot, ret) {
+		return nativeCheckError(methFQN, fmt.Sprintf("the returned value (%d) isn't a valid %s", slot, javaTypeName(ret)))
+	}
+	return nil
+}
+
+// slotFits is whether the slot holds a valid value of the type, which is one of the
+// letters of classloader.ParseDescriptor(), or T for 'this'
+func slotFits(slot int64, javaType byte) bool {
+	switch javaType {
+	case 'B':
+		return slot == int64(int8(slot))
+	case 'C':
+		return slot == int64(uint16(slot))
+	case 'S':
+		return slot == int64(int16(slot))
+	case 'I':
+		return slot == int64(int32(slot))
+	case 'Z':
+		return slot == 0 || slot == 1
+	case 'L':
+		return slot == 0 || classloader.GetObject(slot) != nil
+	case 'T':
+		return classloader.GetObject(slot) != nil
+	default: // J, and F and D, whose slots hold the bits of a float64
+		return true
+	}
+}
+
+// javaTypeName returns the name of the type for the messages of the checks
+func javaTypeName(javaType byte) string {
+	switch javaType {
+	case 'B':
+		return "byte"
+	case 'C':
+		return "char"
+	case 'S':
+		return "short"
+	case 'I':
+		return "int"
+	case 'J':
+		return "long"
+	case 'F':
+		return "float"
+	case 'D':
+		return "double"
+	case 'Z':
+		return "boolean"
+	case 'T':
+		return "'this'"
+	default:
+		return "reference"
+	}
+}
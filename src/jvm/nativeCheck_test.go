@@ -0,0 +1,78 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/globals"
+	"strings"
+	"testing"
+)
+
+func TestNativeCallChecks(t *testing.T) {
+	classloader.MTable = make(classloader.MT)
+	classloader.MTable[classloader.MethodKey("test/Calc.half(I)I")] = classloader.MTentry{
+		Meth: classloader.GmEntry{ParamSlots: 2, Fu: func(params []interface{}) interface{} {
+			return params[1].(int64) / 2
+		}},
+		MType: 'G',
+	}
+	classloader.MTable[classloader.MethodKey("test/Calc.flag()Z")] = classloader.MTentry{
+		Meth: classloader.GmEntry{ParamSlots: 1, Fu: func(params []interface{}) interface{} {
+			return int64(2)
+		}},
+		MType: 'G',
+	}
+	classloader.MTable[classloader.MethodKey("test/Calc.run(Ljava/lang/Object;)V")] = classloader.MTentry{
+		Meth:  classloader.GmEntry{ParamSlots: 1, Fu: func(params []interface{}) interface{} { return nil }},
+		MType: 'G',
+	}
+	calc := classloader.NewObject("test/Calc", 0)
+
+	checkNatives = true
+	defer func() { checkNatives = false }()
+	if v, err := invokeMethod("test/Calc", "half", "(I)I", []int64{calc, 42}); err != nil || v != 21 {
+		t.Errorf("Expected half() to return 21, got %d (%v)", v, err)
+	}
+	if v, err := invokeMethod("test/Calc", "run", "(Ljava/lang/Object;)V", []int64{0}); err != nil {
+		t.Errorf("Expected the static run() to accept null, got %d (%v)", v, err)
+	}
+
+	checks := []struct {
+		method, desc string
+		args         []int64
+		problem      string
+	}{
+		{"half", "(I)I", []int64{calc, 1 << 40}, "argument 1 (1099511627776) isn't a valid int"},
+		{"half", "(I)I", []int64{0, 42}, "argument 0 (0) isn't a valid 'this'"},
+		{"run", "(Ljava/lang/Object;)V", []int64{1 << 40}, "isn't a valid reference"},
+		{"flag", "()Z", []int64{calc}, "the returned value (2) isn't a valid boolean"},
+	}
+	for _, check := range checks {
+		_, err := invokeMethod("test/Calc", check.method, check.desc, check.args)
+		if err == nil || !strings.HasPrefix(err.Error(), "java.lang.InternalError: -Xcheck:native") ||
+			!strings.Contains(err.Error(), check.problem) {
+			t.Errorf("Expected %s%s to fail the check with '%s', got: %v", check.method, check.desc, check.problem, err)
+		}
+	}
+}
+
+func TestXcheckOption(t *testing.T) {
+	gl := globals.InitGlobals("test")
+	if _, err := enableNativeChecks(0, "native", &gl); err != nil || !gl.CheckNatives {
+		t.Errorf("Expected -Xcheck:native to enable the checks, got: %v", err)
+	}
+
+	gl = globals.InitGlobals("test")
+	out := captureStderr(func() {
+		if _, err := enableNativeChecks(0, "jni", &gl); err == nil {
+			t.Errorf("Expected -Xcheck:jni to be rejected")
+		}
+	})
+	if gl.CheckNatives || !strings.Contains(out, "Invalid -Xcheck option '-Xcheck:jni'") {
+		t.Errorf("Expected -Xcheck:jni to be reported and ignored, got: %s", out)
+	}
+}
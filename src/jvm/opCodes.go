@@ -4,7 +4,7 @@
  * Licensed under Mozilla Public License 2.0 (MPL 2.0)
  */
 
-package main
+package jvm
 
 const AALOAD = 0x32
 const AASTORE = 0x53
@@ -17,7 +17,7 @@ const ALOAD_3 = 0x2D
 const ANEWARRAY = 0xBD
 const ARETURN = 0xB0
 const ARRAYLENGTH = 0xBE
-const ASTORE = 0x53
+const ASTORE = 0x3A
 const ASTORE_0 = 0x4B
 const ASTORE_1 = 0x4C
 const ASTORE_2 = 0x4D
@@ -169,6 +169,7 @@ const LCMP = 0x94
 const LCONST_0 = 0x09
 const LCONST_1 = 0x0A
 const LDC = 0x12
+const LDC_W = 0x13
 const LDC2_W = 0x14
 const LDIV = 0x6D
 const LLOAD = 0x16
@@ -0,0 +1,610 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2021 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/globals"
+	"jacobin/jdwp"
+	"jacobin/log"
+	"jacobin/messages"
+	"os"
+	"strings"
+)
+
+// This set of routines loads the Global.Options table with the various
+// JVM command-line options for use later by the CLI processing logic.
+//
+// The table is initially created in globals.go and its declaration contains a
+// key consisting of a string with the option as typed on the command line, and
+// a value concisting of an Option struct (also defined in global.go), having
+// this layout:
+//     type Option struct {
+//	        Supported bool      // is this option supported in Jacobin?
+//	        ArgStyle  int16     // what is the format for the argument values to this option?
+//                              // 0 = no argument      1 = value follows a :
+//                              // 2 = value follows =  4 = value follows a space
+//                              // 8 = value follows a space and is a list of paths separated by
+//                              //     the platform's separator: ; on Windows, : elsewhere (-cp)
+//                              // 16 = value immediately follows the option (such as -Dkey=value)
+//	        Action  func(position int, name string, gl pointer to globasl) error
+//                              // which is the action to perform when this option found.
+//	        Extra       bool    // is it a nonstandard option? (which -X lists)
+//	        Syntax      string  // the option as shown in the help listings
+//	        Description string  // the option's description in the help listings
+//	        Default     string  // the option's default, if any, shown in the help listings
+//      }
+//
+// Every option that Jacobin responds to (even if just to say it's not supported) requires
+// an entry in the Option table, except for these options:
+// 		-h, -help, --help, and -?
+// because these have been handled prior to the use of this table.
+//
+// Whether an option has been specified on the command line isn't in the table, which
+// is only read once the command line has been processed. It's recorded with
+// setOptionToSeen() and checked with gl.OptionSet().
+
+// ==== How to add new options to Jacobin:
+// 1) Create an entry in LoadOptionsTable:
+//    * x := globals.Option{Supported: true, ArgStyle: 0, Action: f, Syntax: "-x",
+//             Description: "what it does"}
+//             where Supported = is the option supported? s/be true.
+//							  Setting it to false avoids an error message to the
+//							  user that the option is unrecognized while still
+//							  having it be unsupported
+//					 ArgStyle = integer as explained in the previous paragraphs
+//                   Action = the function to perform
+//                   Extra = true for nonstandard options, which are listed by -X
+//                   Syntax, Description, and Default = how the option is shown
+//                        by -X and the usage message. The listings are generated
+//                        from the table, so they can't disagree with what's supported.
+//  2) Add x to the GlobalOptions table, using the string of the option as the key
+//     Note that in options with parameters after an : or an = (types 1 or 2 in
+//     ArgStyle in step 1), you enter only the root as the key. For example, see
+//     the -verbose entry below. The same is true of options whose values immediately
+//     follow the option (type 16), such as -D.
+//  3) create the function referred to in Action in step 1. This function accepts
+//     the position in the command line where the present option is located (first
+//     option is at position zero), a string which contains any parameters (if it has
+//     no parameters an empty string is passed in), and finally a pointer to the
+//     globals data structure, which contains the Options table.
+//
+
+// LoadOptionsTable loads the table with all the options Jacobin recognizes.
+func LoadOptionsTable(Global globals.Globals) {
+
+	addExports := globals.Option{Supported: true, ArgStyle: 4, Action: moduleOption, Extra: true,
+		Syntax: "--add-exports <module>/<package>=<target-module>(,<target-module>)*",
+		Description: "update <module> to export <package> to <target-module>, regardless\n" +
+			"of module declaration. <target-module> can be ALL-UNNAMED to export to\n" +
+			"all unnamed modules. (Recorded, but not yet enforced.)"}
+	Global.Options["--add-exports"] = addExports
+
+	addModules := globals.Option{Supported: true, ArgStyle: 4, Action: moduleOption,
+		Syntax: "--add-modules <module name>[,<module name>...]",
+		Description: "root modules to resolve in addition to the initial module.\n" +
+			"<module name> can also be ALL-DEFAULT, ALL-SYSTEM, or ALL-MODULE-PATH.\n" +
+			"(Recorded, but not yet enforced.)"}
+	Global.Options["--add-modules"] = addModules
+
+	addOpens := globals.Option{Supported: true, ArgStyle: 4, Action: moduleOption, Extra: true,
+		Syntax: "--add-opens <module>/<package>=<target-module>(,<target-module>)*",
+		Description: "update <module> to open <package> to <target-module>, regardless\n" +
+			"of module declaration. (Recorded, but not yet enforced.)"}
+	Global.Options["--add-opens"] = addOpens
+
+	agentLib := globals.Option{Supported: true, ArgStyle: 1, Action: loadAgentLib,
+		Syntax: "-agentlib:jdwp=<options>",
+		Description: "run the debugging agent, so a debugger can attach, as in\n" +
+			"-agentlib:jdwp=transport=dt_socket,server=y,suspend=n,address=5005"}
+	Global.Options["-agentlib"] = agentLib
+
+	client := globals.Option{Supported: true, ArgStyle: 0, Action: clientVM,
+		Syntax: "-client", Description: "to select the \"client\" VM", Default: "the \"server\" VM"}
+	Global.Options["-client"] = client
+
+	classPath := globals.Option{Supported: true, ArgStyle: 8, Action: setClassPath,
+		Syntax: "-cp -classpath --class-path <path>", Default: "the CLASSPATH environment variable, or .",
+		Description: "directories and JAR files to search for class files, separated\n" +
+			"by " + string(os.PathListSeparator)}
+	Global.Options["-cp"] = classPath
+	Global.Options["-classpath"] = classPath
+	Global.Options["--class-path"] = classPath
+
+	defineProperty := globals.Option{Supported: true, ArgStyle: 16, Action: defineSystemProperty,
+		Syntax: "-D<name>=<value>", Description: "set a system property"}
+	Global.Options["-D"] = defineProperty
+
+	describeMod := globals.Option{Supported: true, ArgStyle: 4, Action: describeModuleAndExit,
+		Syntax:      "-d <module name> --describe-module <module name>",
+		Description: "describe a module in the runtime image and exit"}
+	Global.Options["-d"] = describeMod
+	Global.Options["--describe-module"] = describeMod
+
+	diff := globals.Option{Supported: true, ArgStyle: 0, Action: enableDiff,
+		Syntax: "--diff", Description: "run the program with both Jacobin and the java in JAVA_HOME,\n" +
+			"and report where their output and exit statuses differ"}
+	Global.Options["--diff"] = diff
+
+	disableArgFiles := globals.Option{Supported: true, ArgStyle: 0, Action: disableArgFileExpansion,
+		Syntax: "--disable-@files", Description: "prevent further argument file expansion"}
+	Global.Options["--disable-@files"] = disableArgFiles
+
+	dryRun := globals.Option{Supported: true, ArgStyle: 0, Action: enableDryRun,
+		Syntax: "--dry-run", Description: "create VM and load main class but do not execute main method"}
+	Global.Options["--dry-run"] = dryRun
+
+	help := globals.Option{Supported: true, ArgStyle: 0, Action: showHelpStderrAndExit,
+		Syntax: "-? -h -help", Description: "print this help message to the error stream"}
+	Global.Options["-h"] = help
+	Global.Options["-help"] = help
+	Global.Options["-?"] = help
+
+	helpp := globals.Option{Supported: true, ArgStyle: 0, Action: showHelpStdoutAndExit,
+		Syntax: "--help", Description: "print this help message to the output stream"}
+	Global.Options["--help"] = helpp
+
+	helpX := globals.Option{Supported: true, ArgStyle: 0, Action: showExtraHelpStderrAndExit,
+		Syntax: "-X", Description: "print help on extra options to the error stream"}
+	Global.Options["-X"] = helpX
+
+	helpExtra := globals.Option{Supported: true, ArgStyle: 0, Action: showExtraHelpStdoutAndExit,
+		Syntax: "--help-extra", Description: "print help on extra options to the output stream"}
+	Global.Options["--help-extra"] = helpExtra
+
+	jarFile := globals.Option{Supported: true, ArgStyle: 4, Action: getJarFilename,
+		Syntax: "-jar <jarfile>", Description: "execute the main class of the JAR file"}
+	Global.Options["-jar"] = jarFile
+
+	listMods := globals.Option{Supported: true, ArgStyle: 0, Action: listModulesAndExit,
+		Syntax: "--list-modules", Description: "list the modules in the runtime image and exit"}
+	Global.Options["--list-modules"] = listMods
+
+	showversion := globals.Option{Supported: true, ArgStyle: 0, Action: showVersionStderr,
+		Syntax: "-showversion", Description: "print product version to the error stream and continue"}
+	Global.Options["-showversion"] = showversion
+
+	show_Version := globals.Option{Supported: true, ArgStyle: 0, Action: showVersionStdout,
+		Syntax: "--show-version", Description: "print product version to the output stream and continue"}
+	Global.Options["--show-version"] = show_Version
+
+	traceInstruction := globals.Option{Supported: true, ArgStyle: 1, Action: enableTraceInstructions,
+		Extra: true, Syntax: "-trace:inst", Description: "trace the execution of each bytecode instruction"}
+	Global.Options["-trace"] = traceInstruction
+
+	verboseClass := globals.Option{Supported: true, ArgStyle: 1, Action: verbosityLevel,
+		Syntax: "-verbose:[class|module|jni|info|fine|finest]", Default: "warnings only",
+		Description: "enable verbose output. module and jni select their messages\n" +
+			"independently of the others. info, fine, and finest are Jacobin-specific\n" +
+			"levels giving increasing amounts of detail. The finest level is\n" +
+			"used primarily for performance analysis."}
+	Global.Options["-verbose"] = verboseClass
+
+	version := globals.Option{Supported: true, ArgStyle: 1, Action: versionStderrThenExit,
+		Syntax: "-version", Description: "print product version to the error stream and exit"}
+	Global.Options["-version"] = version
+
+	vversion := globals.Option{Supported: true, ArgStyle: 1, Action: versionStdoutThenExit,
+		Syntax: "--version", Description: "print product version to the output stream and exit"}
+	Global.Options["--version"] = vversion
+
+	checkNatives := globals.Option{Supported: true, ArgStyle: 1, Action: enableNativeChecks, Extra: true,
+		Syntax: "-Xcheck:native",
+		Description: "check the Go natives against the JDK's classes at start-up, and\n" +
+			"their arguments and return values at each call"}
+	Global.Options["-Xcheck"] = checkNatives
+
+	logFile := globals.Option{Supported: true, ArgStyle: 1, Action: logToFile, Extra: true,
+		Syntax: "-Xlog:[<what>:]file=<path>[:filesize=<size>][:filecount=<n>]",
+		Description: "write log messages to a file. <what> is all (the default) or a\n" +
+			"comma-separated list of log, trace, jni, and module. The file is\n" +
+			"rotated when it reaches filesize (default: 20m; 0 never rotates it),\n" +
+			"keeping filecount old files (default: 5)"}
+	Global.Options["-Xlog"] = logFile
+
+	module := globals.Option{Supported: true, ArgStyle: 4, Action: getStartingModule,
+		Syntax: "-m --module <module>[/<mainclass>]",
+		Description: "execute the main class of the module, which is resolved from\n" +
+			"the module path. Arguments that follow are passed to the main class."}
+	Global.Options["-m"] = module
+	Global.Options["--module"] = module
+
+	modulePath := globals.Option{Supported: true, ArgStyle: 8, Action: setModulePath,
+		Syntax: "-p --module-path <module path>",
+		Description: "directories of modules, modular JAR files, and exploded modules,\n" +
+			"separated by " + string(os.PathListSeparator)}
+	Global.Options["-p"] = modulePath
+	Global.Options["--module-path"] = modulePath
+
+	noColor := globals.Option{Supported: true, ArgStyle: 0, Action: disableColor, Extra: true,
+		Syntax: "--no-color", Description: "don't color the log messages shown on a terminal"}
+	Global.Options["--no-color"] = noColor
+
+	xxOption := globals.Option{Supported: true, ArgStyle: 1, Action: handleXXoption,
+		Extra: true, Syntax: "-XX:[+|-]<flag> -XX:<flag>=<value>",
+		Description: "set a VM flag. -XX:+PrintFlagsFinal lists the flags and their values"}
+	Global.Options["-XX"] = xxOption
+}
+
+// ---- the functions for the supported CLI options, in alphabetic order ----
+
+// -agentlib:jdwp=<options> runs the JDWP agent (see jdwp/jdwp.go), which is started in
+// main.go. It's the only agent library there is.
+func loadAgentLib(pos int, argValue string, gl *globals.Globals) (int, error) {
+	library, options := argValue, ""
+	if eq := strings.Index(argValue, "="); eq >= 0 {
+		library, options = argValue[:eq], argValue[eq+1:]
+	}
+	if library != "jdwp" {
+		return pos, messages.Print("JACOBIN-LA-0036", library)
+	}
+	if _, err := jdwp.ParseOptions(options); err != nil {
+		return pos, messages.Print("JACOBIN-LA-0037", err.Error())
+	}
+	gl.JDWPOptions = options
+	setOptionToSeen("-agentlib", gl)
+	return pos, nil
+}
+
+// client VM function, simply changes the wording of the version
+// info. (This is the same behavior as the OpenJDK JVM.)
+func clientVM(pos int, name string, gl *globals.Globals) (int, error) {
+	gl.VmModel = "client"
+	setOptionToSeen("-client", gl)
+	return pos, nil
+}
+
+// -D options define system properties: -Dkey=value, or -Dkey for a property whose
+// value is empty. The argValue is everything after the -D, so values can contain
+// colons and equal signs (as in -Dhttp.proxy=http://host:8080).
+func defineSystemProperty(pos int, argValue string, gl *globals.Globals) (int, error) {
+	key, value := argValue, ""
+	if eq := strings.Index(argValue, "="); eq >= 0 {
+		key, value = argValue[:eq], argValue[eq+1:]
+	}
+	if key == "" {
+		return pos, messages.Print("JACOBIN-LA-0010", gl.Args[pos])
+	}
+	if key == "java.class.path" || key == "java.library.path" {
+		value = globals.NormalizePathList(value)
+	}
+	globals.SystemProperties.Set(key, value)
+	setOptionToSeen("-D", gl)
+	return pos, nil
+}
+
+// -d and --describe-module show the descriptor of the module named by the next arg
+// (see modules.go)
+func describeModuleAndExit(pos int, name string, gl *globals.Globals) (int, error) {
+	gl.SetExitNow(true)
+	if len(gl.Args) <= pos+1 {
+		return pos, messages.Print("JACOBIN-LA-0030", gl.Args[pos])
+	}
+	if err := describeModule(os.Stdout, gl, gl.Args[pos+1]); err != nil {
+		return pos + 1, messages.Print("JACOBIN-LA-0031", err.Error())
+	}
+	return pos + 1, nil
+}
+
+// --disable-@files stops the expansion of @argfiles, which has already been done
+// (see argfiles.go), so here it's only noted
+func disableArgFileExpansion(pos int, name string, gl *globals.Globals) (int, error) {
+	setOptionToSeen("--disable-@files", gl)
+	return pos, nil
+}
+
+// --no-color turns off the colored level prefixes of log messages on a terminal
+func disableColor(pos int, argValue string, gl *globals.Globals) (int, error) {
+	log.DisableColor()
+	setOptionToSeen("--no-color", gl)
+	return pos, nil
+}
+
+// -Xcheck:native checks the natives (see nativeCheck.go). It's the only check there is.
+func enableNativeChecks(pos int, argValue string, gl *globals.Globals) (int, error) {
+	if argValue != "native" {
+		return pos, messages.Print("JACOBIN-LA-0052", argValue)
+	}
+	gl.CheckNatives = true
+	setOptionToSeen("-Xcheck", gl)
+	return pos, nil
+}
+
+// --diff runs the program with both Jacobin and java, and compares them (see diff.go)
+func enableDiff(pos int, name string, gl *globals.Globals) (int, error) {
+	gl.Diff = true
+	setOptionToSeen("--diff", gl)
+	return pos, nil
+}
+
+// --dry-run loads the main class but doesn't run it (see dryRun.go)
+func enableDryRun(pos int, name string, gl *globals.Globals) (int, error) {
+	gl.DryRun = true
+	setOptionToSeen("--dry-run", gl)
+	return pos, nil
+}
+
+// for -jar option. Get the next arg, which must be the JAR filename, and then all remaining args
+// are app args, which are duly added to Global.appArgs
+func getJarFilename(pos int, name string, gl *globals.Globals) (int, error) {
+	setOptionToSeen("-jar", gl)
+	if len(gl.Args) > pos+1 {
+		gl.StartingJar = gl.Args[pos+1]
+		// as with java, the JAR is the class path. The JARs and directories listed
+		// in its manifest's Class-Path are added to it by the classloader.
+		globals.SystemProperties.Set("java.class.path", gl.StartingJar)
+		log.Log("Starting with JAR file: "+gl.StartingJar, log.FINE)
+		for i := pos + 2; i < len(gl.Args); i++ {
+			gl.AppArgs = append(gl.AppArgs, gl.Args[i])
+		}
+		return len(gl.Args), nil
+	} else {
+		return pos, os.ErrInvalid
+	}
+}
+
+// -m and --module name the module to run, and optionally its main class, in the next arg
+// (or after an =, as in --module=com.example/com.example.Main). As with -jar, all the
+// remaining args are app args. The module is resolved from the module path in main.go.
+func getStartingModule(pos int, argValue string, gl *globals.Globals) (int, error) {
+	option := strings.SplitN(gl.Args[pos], "=", 2)[0]
+	if argValue == "" {
+		if len(gl.Args) <= pos+1 {
+			return pos, messages.Print("JACOBIN-LA-0030", option)
+		}
+		pos++
+		argValue = gl.Args[pos]
+	}
+	gl.StartingModule = argValue
+	module, mainClass := argValue, ""
+	if slash := strings.Index(argValue, "/"); slash >= 0 {
+		module, mainClass = argValue[:slash], argValue[slash+1:]
+	}
+	globals.SystemProperties.Set("jdk.module.main", module)
+	if mainClass != "" {
+		globals.SystemProperties.Set("jdk.module.main.class", mainClass)
+	}
+	log.Log("Starting with module: "+argValue, log.FINE)
+	for i := pos + 1; i < len(gl.Args); i++ {
+		gl.AppArgs = append(gl.AppArgs, gl.Args[i])
+	}
+	setOptionToSeen("-m", gl)
+	return len(gl.Args), nil
+}
+
+// -Xlog:[<what>:]file=<path>[:filesize=<size>][:filecount=<n>] directs log messages to
+// a file, which is rotated when it reaches the given size (see log.RotatingFile). <what>
+// says which messages, by their tags in the log package. The path can contain colons
+// (as Windows paths do), so it runs to :filesize= or :filecount=, if present.
+func logToFile(pos int, argValue string, gl *globals.Globals) (int, error) {
+	invalid := func() (int, error) {
+		return pos, messages.Print("JACOBIN-LA-0011", argValue)
+	}
+
+	fileAt := strings.Index(argValue, "file=")
+	if fileAt < 0 || (fileAt > 0 && argValue[fileAt-1] != ':') {
+		return invalid()
+	}
+	tags := log.Tags()
+	if fileAt > 0 && argValue[:fileAt-1] != "all" {
+		tags = strings.Split(argValue[:fileAt-1], ",")
+	}
+
+	path, settings := argValue[fileAt+len("file="):], ""
+	if i := strings.Index(path, ":file"); i >= 0 {
+		path, settings = path[:i], path[i+1:]
+	}
+	size, count := int64(20*1024*1024), int64(5)
+	for _, setting := range strings.Split(settings, ":") {
+		var err error
+		switch {
+		case setting == "":
+		case strings.HasPrefix(setting, "filesize="):
+			size, err = globals.ParseSize(strings.TrimPrefix(setting, "filesize="))
+		case strings.HasPrefix(setting, "filecount="):
+			count, err = globals.ParseSize(strings.TrimPrefix(setting, "filecount="))
+		default:
+			return invalid()
+		}
+		if err != nil {
+			return invalid()
+		}
+	}
+	if path == "" || size < 0 || count < 0 {
+		return invalid()
+	}
+
+	file, err := log.OpenRotatingFile(path, size, int(count))
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0012", path, err.Error())
+		return pos, err
+	}
+	if err = log.SetOutput(tags, file); err != nil {
+		_ = file.Close()
+		return invalid()
+	}
+	setOptionToSeen("-Xlog", gl)
+	return pos, nil
+}
+
+// --list-modules lists the modules in the runtime image (see modules.go)
+func listModulesAndExit(pos int, name string, gl *globals.Globals) (int, error) {
+	gl.SetExitNow(true)
+	if err := listModules(os.Stdout, gl); err != nil {
+		return pos, messages.Print("JACOBIN-LA-0031", err.Error())
+	}
+	return pos, nil
+}
+
+// --add-exports, --add-opens, and --add-modules are recorded in gl.Modules. Their value
+// is the next arg or, as in --add-opens=java.base/java.lang=ALL-UNNAMED, follows an =.
+// Invalid values are reported and ignored.
+func moduleOption(pos int, argValue string, gl *globals.Globals) (int, error) {
+	option := strings.SplitN(gl.Args[pos], "=", 2)[0]
+	if argValue == "" {
+		if len(gl.Args) <= pos+1 {
+			return pos, messages.Print("JACOBIN-LA-0032", option)
+		}
+		pos++
+		argValue = gl.Args[pos]
+	}
+
+	switch option {
+	case "--add-modules":
+		for _, module := range strings.Split(argValue, ",") {
+			if module == "" {
+				return pos, messages.Print("JACOBIN-LA-0033", option, argValue, "empty module name")
+			}
+			gl.Modules.AddModules = append(gl.Modules.AddModules, module)
+		}
+	default:
+		access, err := globals.ParseModuleAccess(argValue)
+		if err != nil {
+			return pos, messages.Print("JACOBIN-LA-0033", option, argValue, err.Error())
+		}
+		if option == "--add-exports" {
+			gl.Modules.AddExports = append(gl.Modules.AddExports, access)
+		} else {
+			gl.Modules.AddOpens = append(gl.Modules.AddOpens, access)
+		}
+	}
+	log.Log(option+" "+argValue+" recorded (module options are not yet enforced)", log.FINE)
+	setOptionToSeen(option, gl)
+	return pos, nil
+}
+
+// generic notification function that an option is not supported
+func notSupported(pos int, arg string, gl *globals.Globals) (int, error) {
+	name := gl.Args[pos]
+	_ = messages.Print("JACOBIN-LA-0013", name)
+	return pos, nil
+}
+
+// -cp, -classpath, and --class-path set the class path, which is the next arg. Its
+// entries are separated by the platform's separator, and are stored with the platform's
+// file separators.
+func setClassPath(pos int, argValue string, gl *globals.Globals) (int, error) {
+	option := gl.Args[pos]
+	if len(gl.Args) <= pos+1 {
+		return pos, messages.Print("JACOBIN-LA-0025", option)
+	}
+	globals.SystemProperties.Set("java.class.path", globals.NormalizePathList(gl.Args[pos+1]))
+	setOptionToSeen(option, gl)
+	return pos + 1, nil
+}
+
+// -p and --module-path set the module path, which is the next arg, in the same form as
+// the class path
+func setModulePath(pos int, argValue string, gl *globals.Globals) (int, error) {
+	option := gl.Args[pos]
+	if len(gl.Args) <= pos+1 {
+		return pos, messages.Print("JACOBIN-LA-0034", option)
+	}
+	gl.ModulePath = globals.NormalizePathList(gl.Args[pos+1])
+	globals.SystemProperties.Set("jdk.module.path", gl.ModulePath)
+	setOptionToSeen("-p", gl)
+	return pos + 1, nil
+}
+
+func showHelpStderrAndExit(pos int, name string, gl *globals.Globals) (int, error) {
+	showUsage(os.Stderr, gl)
+	gl.SetExitNow(true)
+	return pos, nil
+}
+
+func showHelpStdoutAndExit(pos int, name string, gl *globals.Globals) (int, error) {
+	showUsage(os.Stdout, gl)
+	gl.SetExitNow(true)
+	return pos, nil
+}
+
+func showVersionStderr(pos int, name string, gl *globals.Globals) (int, error) {
+	showVersion(os.Stderr, gl, true)
+	setOptionToSeen("-showversion", gl)
+	return pos, nil
+}
+
+func showVersionStdout(pos int, name string, gl *globals.Globals) (int, error) {
+	showVersion(os.Stdout, gl, false)
+	setOptionToSeen("--show-version", gl)
+	return pos, nil
+}
+
+// -X and --help-extra list the nonstandard options (see showExtraOptions())
+func showExtraHelpStderrAndExit(pos int, name string, gl *globals.Globals) (int, error) {
+	showExtraOptions(os.Stderr, gl)
+	gl.SetExitNow(true)
+	return pos, nil
+}
+
+func showExtraHelpStdoutAndExit(pos int, name string, gl *globals.Globals) (int, error) {
+	showExtraOptions(os.Stdout, gl)
+	gl.SetExitNow(true)
+	return pos, nil
+}
+
+// note that the -version option prints the version then exits the VM
+func versionStderrThenExit(pos int, name string, gl *globals.Globals) (int, error) {
+	showVersion(os.Stderr, gl, true)
+	gl.SetExitNow(true)
+	return pos, nil
+}
+
+// note that the --version option prints the version info then exits the VM
+func versionStdoutThenExit(pos int, name string, gl *globals.Globals) (int, error) {
+	showVersion(os.Stdout, gl, false)
+	gl.SetExitNow(true)
+	return pos, nil
+}
+
+func enableTraceInstructions(pos int, argValue string, gl *globals.Globals) (int, error) {
+	setOptionToSeen("-trace", gl)
+	return pos, nil
+}
+
+// -XX options set the VM flags registered in globals/flags.go. They have the form
+// -XX:+Flag or -XX:-Flag for boolean flags and -XX:Flag=value for the others. The
+// argValue is what follows the colon.
+func handleXXoption(pos int, argValue string, gl *globals.Globals) (int, error) {
+	if err := gl.Flags.Set(argValue); err != nil {
+		_ = messages.Print("JACOBIN-LA-0014", err.Error())
+		return pos, err
+	}
+	setOptionToSeen("-XX", gl)
+	return pos, nil
+}
+
+// set verbosity level. Note Jacobin starts up at WARNING level, so there is no
+// need to set it to that level. You cannot set the level to coarser than WARNING
+// which is why there is no way to set the verbosity to SEVERE only.
+func verbosityLevel(pos int, argValue string, gl *globals.Globals) (int, error) {
+	switch argValue {
+	case "class":
+		log.Level = log.CLASS
+		log.Log("Logging level set to CLASS", log.INFO)
+	case "info":
+		log.Level = log.INFO
+		log.Log("Logging level set to log.INFO", log.INFO)
+	case "fine":
+		log.Level = log.FINE
+		log.Log("Logging level set to FINE", log.INFO)
+	case "finest":
+		log.Level = log.FINEST
+		log.Log("Logging level set to FINEST", log.INFO)
+	case "jni", "module": // channels, which are selected independently of the level
+		_ = log.EnableChannel(argValue)
+	default:
+		err := messages.New("JACOBIN-LA-0019", argValue)
+		log.Log(err.Msg, log.WARNING)
+		return pos, err
+	}
+	setOptionToSeen("-verbose", gl) // mark the -verbose option as having been specified
+	return pos, nil
+}
+
+// Marks the given option as having been 'set' that is, specified on the command line
+func setOptionToSeen(optionKey string, gl *globals.Globals) {
+	gl.MarkOptionSet(optionKey)
+}
@@ -0,0 +1,82 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import "time"
+
+// Support for LockSupport.park() and unpark(), which bottom out in the natives
+// Unsafe.park(boolean isAbsolute, long time) and Unsafe.unpark(Object thread).
+// Every lock and queue in java.util.concurrent blocks through these.
+//
+// Each thread has a single permit, implemented as a channel with a buffer of one.
+// unpark() makes the permit available (if it's already available, nothing changes:
+// permits don't accumulate); park() consumes the permit, blocking until it's
+// available. As in the JDK, park() also returns when the thread is interrupted
+// (without clearing the interrupt status or throwing an exception), when the time
+// limit is reached, or spuriously. Callers must therefore recheck their condition.
+// The interrupt is Thread.interrupt0()'s (see interruptThreadObject()), and the status
+// it leaves set is the one that Thread.isInterrupted() and Thread.interrupted() read,
+// so a lock that parks can tell that it was interrupted.
+
+// parkThread implements Unsafe.park(isAbsolute, time). If isAbsolute is false, time
+// is a relative wait in nanoseconds, with 0 meaning wait indefinitely; if isAbsolute
+// is true, time is a deadline in milliseconds since the epoch.
+func parkThread(t *execThread, isAbsolute bool, waitTime int64) {
+	// a pending interrupt means return at once, leaving the interrupt status set
+	threadsMutex.Lock()
+	interrupted := t.interrupted
+	threadsMutex.Unlock()
+	if interrupted {
+		return
+	}
+
+	var timeout <-chan time.Time
+	if isAbsolute {
+		wait := time.Until(time.Unix(0, waitTime*int64(time.Millisecond)))
+		if wait <= 0 {
+			return
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	} else if waitTime < 0 {
+		return
+	} else if waitTime > 0 {
+		timer := time.NewTimer(time.Duration(waitTime))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-t.permit:
+	case <-t.interruptCh:
+		// the interrupt status remains set, so a later join() or sleep() still sees it
+	case <-timeout: // nil when waiting indefinitely, so never selected
+	}
+}
+
+// unparkThread implements Unsafe.unpark(thread): it makes the thread's permit
+// available, unblocking the thread if it's parked.
+func unparkThread(t *execThread) {
+	select {
+	case t.permit <- struct{}{}:
+	default: // the permit is already available
+	}
+}
+
+// parkCurrentThread implements classloader.Park for Unsafe.park()
+func parkCurrentThread(isAbsolute bool, waitTime int64) {
+	parkThread(currentThread(), isAbsolute, waitTime)
+}
+
+// unparkThreadObject implements classloader.Unpark for Unsafe.unpark(). As in the JDK,
+// unparking a thread that hasn't been started (or null) does nothing.
+func unparkThreadObject(thread int64) {
+	if t := threadOfObject(thread); t != nil {
+		unparkThread(t)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"testing"
+	"time"
+)
+
+// unpark() before park() means park() returns immediately
+func TestUnparkBeforePark(t *testing.T) {
+	th := CreateThread(130)
+	unparkThread(&th)
+	unparkThread(&th) // permits don't accumulate
+
+	done := make(chan bool)
+	go func() {
+		parkThread(&th, false, 0)
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("park() did not consume the available permit")
+	}
+
+	if len(th.permit) != 0 {
+		t.Errorf("Expected no permit after park(), but one was still available")
+	}
+}
+
+func TestParkUntilUnpark(t *testing.T) {
+	th := CreateThread(131)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		unparkThread(&th)
+	}()
+
+	start := time.Now()
+	parkThread(&th, false, 0)
+	if time.Since(start) < 10*time.Millisecond {
+		t.Errorf("park() returned before unpark() was called")
+	}
+}
+
+func TestParkWithTimeout(t *testing.T) {
+	th := CreateThread(132)
+	start := time.Now()
+	parkThread(&th, false, int64(15*time.Millisecond))
+	if time.Since(start) < 15*time.Millisecond {
+		t.Errorf("parkNanos() returned before its timeout")
+	}
+
+	deadline := time.Now().Add(15*time.Millisecond).UnixNano() / int64(time.Millisecond)
+	parkThread(&th, true, deadline)
+	if time.Now().UnixNano()/int64(time.Millisecond) < deadline {
+		t.Errorf("parkUntil() returned before its deadline")
+	}
+}
+
+// an interrupt wakes a parked thread, and the interrupt status remains set
+func TestParkIsWokenByInterrupt(t *testing.T) {
+	th := CreateThread(133)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		interruptThread(&th)
+	}()
+
+	parkThread(&th, false, 0)
+	if !th.interrupted {
+		t.Errorf("Expected interrupt status to remain set after park() was interrupted")
+	}
+
+	// with the interrupt status set, park() returns immediately
+	done := make(chan bool)
+	go func() {
+		parkThread(&th, false, 0)
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Errorf("park() blocked even though the thread was interrupted")
+	}
+}
+
+// a thread parks through Unsafe.park() and another unparks it through Unsafe.unpark()
+func TestUnsafeParkAndUnpark(t *testing.T) {
+	classloader.Load_Misc_Unsafe()
+	classloader.Park = parkCurrentThread
+	classloader.Unpark = unparkThreadObject
+	park, _ := classloader.LookupNative("jdk/internal/misc/Unsafe.park(ZJ)V")
+	unpark, _ := classloader.LookupNative("jdk/internal/misc/Unsafe.unpark(Ljava/lang/Object;)V")
+
+	th := CreateThread(newThreadID())
+	registerThread(&th)
+	defer threadEnded(&th)
+	thread := objectOfThread(&th)
+
+	parked := make(chan time.Time)
+	go func() {
+		goroutine := goroutineID()
+		goroutineThreads.Store(goroutine, th.id)
+		defer goroutineThreads.Delete(goroutine)
+		park.GFunction([]interface{}{int64(0), int64(0), int64(0)}) // park(false, 0)
+		parked <- time.Now()
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	unparkedAt := time.Now()
+	go unpark.GFunction([]interface{}{int64(0), thread})
+	select {
+	case returned := <-parked:
+		if returned.Before(unparkedAt) {
+			t.Errorf("Unsafe.park() returned before Unsafe.unpark() was called")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Unsafe.unpark() did not unpark the thread")
+	}
+
+	// unparking a Thread that was never started does nothing
+	unpark.GFunction([]interface{}{int64(0), classloader.NewObject("java/lang/Thread", 0)})
+}
+
+// Thread.interrupt0() wakes a thread parked in Unsafe.park(), which then finds its
+// interrupt status set with Thread.interrupted(), as AbstractQueuedSynchronizer does
+func TestUnsafeParkIsWokenByThreadInterrupt(t *testing.T) {
+	classloader.Load_Misc_Unsafe()
+	classloader.Load_Lang_Thread()
+	classloader.Park = parkCurrentThread
+	classloader.InterruptThread = interruptThreadObject
+	classloader.ClearInterrupt = func() bool { return clearInterrupt(currentThread()) }
+	park, _ := classloader.LookupNative("jdk/internal/misc/Unsafe.park(ZJ)V")
+	interrupt0, _ := classloader.LookupNative("java/lang/Thread.interrupt0()V")
+	interrupted, _ := classloader.LookupNative("java/lang/Thread.interrupted()Z")
+
+	th := CreateThread(newThreadID())
+	registerThread(&th)
+	defer threadEnded(&th)
+	thread := objectOfThread(&th)
+
+	wasInterrupted := make(chan interface{})
+	go func() {
+		goroutine := goroutineID()
+		goroutineThreads.Store(goroutine, th.id)
+		defer goroutineThreads.Delete(goroutine)
+		park.GFunction([]interface{}{int64(0), int64(0), int64(0)}) // park(false, 0)
+		wasInterrupted <- interrupted.GFunction(nil)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	interrupt0.GFunction([]interface{}{thread})
+	select {
+	case status := <-wasInterrupted:
+		if status != int64(1) {
+			t.Errorf("Expected Thread.interrupted() to be true after the interrupt, got %v", status)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Thread.interrupt0() did not wake the parked thread")
+	}
+	if th.interrupted {
+		t.Errorf("Expected Thread.interrupted() to clear the interrupt status")
+	}
+}
@@ -0,0 +1,216 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"compress/gzip"
+	"io"
+	"time"
+)
+
+// Writing profiles in the pprof format, which go tool pprof and other tools read. The
+// format is a gzipped protocol buffer, defined in
+// https://github.com/google/pprof/blob/main/proto/profile.proto
+// Only the parts of it that Jacobin's profiles use are written: the sample types,
+// the samples with their stacks and labels, the locations, and the functions.
+
+// pprofProfile is a profile to be written
+type pprofProfile struct {
+	sampleTypes [][2]string // the type and unit of each value of a sample, as {"alloc_objects", "count"}
+	samples     []pprofSample
+	duration    time.Duration
+}
+
+// pprofSample is a sample: a stack and its values
+type pprofSample struct {
+	stack  []pprofFrame // the leaf first
+	values []int64
+	labels map[string]string
+}
+
+// pprofFrame is a frame of a stack: a function and the line in it
+type pprofFrame struct {
+	function   string // the name that's shown, as in java.lang.String.length
+	systemName string // the full name, as in java/lang/String.length()I
+	file       string
+	line       int64
+}
+
+// the field numbers of the messages of profile.proto
+const (
+	profileSampleType = 1
+	profileSample     = 2
+	profileLocation   = 4
+	profileFunction   = 5
+	profileStrings    = 6
+	profileTime       = 9
+	profileDuration   = 10
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocation = 1
+	sampleValue    = 2
+	sampleLabel    = 3
+
+	labelKey = 1
+	labelStr = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunction = 1
+	lineLine     = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+)
+
+// protoBuffer builds a protocol buffer message
+type protoBuffer struct {
+	b []byte
+}
+
+func (p *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		p.b = append(p.b, byte(x)|0x80)
+		x >>= 7
+	}
+	p.b = append(p.b, byte(x))
+}
+
+// int64 writes a varint field (wire type 0)
+func (p *protoBuffer) int64(field int, x int64) {
+	p.varint(uint64(field) << 3)
+	p.varint(uint64(x))
+}
+
+// bytes writes a length-delimited field (wire type 2)
+func (p *protoBuffer) bytes(field int, b []byte) {
+	p.varint(uint64(field)<<3 | 2)
+	p.varint(uint64(len(b)))
+	p.b = append(p.b, b...)
+}
+
+// packed writes a repeated varint field in its packed form
+func (p *protoBuffer) packed(field int, xs []uint64) {
+	var packed protoBuffer
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	p.bytes(field, packed.b)
+}
+
+// message writes an embedded message, which is built by build
+func (p *protoBuffer) message(field int, build func(m *protoBuffer)) {
+	var m protoBuffer
+	build(&m)
+	p.bytes(field, m.b)
+}
+
+// writePprof writes the profile in the pprof format
+func writePprof(w io.Writer, prof *pprofProfile) error {
+	var p protoBuffer
+
+	// the strings are written once, in the string table, and referred to by index.
+	// The first string is always the empty string.
+	stringTable := []string{""}
+	stringIndex := map[string]int64{"": 0}
+	str := func(s string) int64 {
+		i, present := stringIndex[s]
+		if !present {
+			i = int64(len(stringTable))
+			stringTable = append(stringTable, s)
+			stringIndex[s] = i
+		}
+		return i
+	}
+
+	for _, st := range prof.sampleTypes {
+		st := st
+		p.message(profileSampleType, func(m *protoBuffer) {
+			m.int64(valueTypeType, str(st[0]))
+			m.int64(valueTypeUnit, str(st[1]))
+		})
+	}
+
+	// each distinct frame is a location, whose ID is its index plus 1, and each
+	// distinct function is a function
+	var locations []pprofFrame
+	locationIDs := map[pprofFrame]uint64{}
+	functionIDs := map[pprofFrame]uint64{} // keyed by a frame without its line
+	var functions []pprofFrame
+	for _, sample := range prof.samples {
+		sample := sample
+		ids := make([]uint64, len(sample.stack))
+		for i, frame := range sample.stack {
+			id, present := locationIDs[frame]
+			if !present {
+				id = uint64(len(locations) + 1)
+				locations = append(locations, frame)
+				locationIDs[frame] = id
+			}
+			ids[i] = id
+		}
+		values := make([]uint64, len(sample.values))
+		for i, v := range sample.values {
+			values[i] = uint64(v)
+		}
+		p.message(profileSample, func(m *protoBuffer) {
+			m.packed(sampleLocation, ids)
+			m.packed(sampleValue, values)
+			for key, value := range sample.labels {
+				key, value := key, value
+				m.message(sampleLabel, func(l *protoBuffer) {
+					l.int64(labelKey, str(key))
+					l.int64(labelStr, str(value))
+				})
+			}
+		})
+	}
+
+	for i, frame := range locations {
+		function := frame
+		function.line = 0
+		id, present := functionIDs[function]
+		if !present {
+			id = uint64(len(functions) + 1)
+			functions = append(functions, function)
+			functionIDs[function] = id
+		}
+		line := frame.line
+		p.message(profileLocation, func(m *protoBuffer) {
+			m.int64(locationID, int64(i+1))
+			m.message(locationLine, func(l *protoBuffer) {
+				l.int64(lineFunction, int64(id))
+				l.int64(lineLine, line)
+			})
+		})
+	}
+	for i, function := range functions {
+		function := function
+		p.message(profileFunction, func(m *protoBuffer) {
+			m.int64(functionID, int64(i+1))
+			m.int64(functionName, str(function.function))
+			m.int64(functionSystemName, str(function.systemName))
+			m.int64(functionFilename, str(function.file))
+		})
+	}
+
+	p.int64(profileTime, time.Now().UnixNano())
+	p.int64(profileDuration, int64(prof.duration))
+	for _, s := range stringTable { // last, since the fields above add to it
+		p.bytes(profileStrings, []byte(s))
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(p.b); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,257 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/messages"
+	"net/http"
+	httppprof "net/http/pprof"
+	"os"
+	"path"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Profiling Java programs with Go's profiling tools. The interpreter's CPU time is
+// attributed to the Java methods being interpreted by pprof labels: each interpreted
+// frame labels its goroutine with java.method=<the method>, as in
+// java.method=java.lang.String.length, for as long as the frame runs. So,
+//     go tool pprof -tagroot=java.method jacobin cpu.prof
+// shows the time in each Java method, and -tagfocus narrows the profile to some of them.
+// Go's heap profiles can't be labeled, so the Java heap has its own profile, in the
+// same format, of the objects allocated at each Java stack.
+//     -XX:CPUProfile=<file>   writes a CPU profile of the whole run
+//     -XX:HeapProfile=<file>  writes the Java heap profile when the program exits
+//     -XX:+EnablePprof        serves net/http/pprof's profiles at /debug/pprof/ on the
+//                             metrics port (see metrics.go), and the Java heap profile
+//                             at /debug/pprof/javaheap
+// The labels and the heap profile cost time, so they're only kept up while one of
+// these is given.
+
+// profiling is whether the frames are labeled. It's set before the program starts.
+var profiling bool
+
+var (
+	cpuProfile      *os.File
+	heapProfilePath string
+	profilingStart  time.Time
+)
+
+// startProfiling starts what the profiling flags ask for
+func startProfiling(gl *globals.Globals) error {
+	cpuPath := gl.Flags.String("CPUProfile")
+	heapProfilePath = gl.Flags.String("HeapProfile")
+	profiling = cpuPath != "" || heapProfilePath != "" || gl.Flags.Bool("EnablePprof")
+	if !profiling {
+		return nil
+	}
+	profilingStart = time.Now()
+	classloader.ObjectAllocated = recordAllocation
+
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return messages.Print("JACOBIN-LA-0048", err.Error())
+		}
+		if err = pprof.StartCPUProfile(f); err != nil {
+			_ = f.Close()
+			return messages.Print("JACOBIN-LA-0048", err.Error())
+		}
+		cpuProfile = f
+	}
+	return nil
+}
+
+// stopProfiling writes the profiles that were asked for
+func stopProfiling() {
+	if cpuProfile != nil {
+		pprof.StopCPUProfile()
+		if err := cpuProfile.Close(); err != nil {
+			_ = messages.Print("JACOBIN-LA-0049", "CPU", err.Error())
+		}
+		cpuProfile = nil
+	}
+	if heapProfilePath != "" {
+		f, err := os.Create(heapProfilePath)
+		if err == nil {
+			err = writeJavaHeapProfile(f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			_ = messages.Print("JACOBIN-LA-0049", "heap", err.Error())
+		}
+		heapProfilePath = ""
+	}
+}
+
+// addPprofHandlers serves the profiles at /debug/pprof/ if -XX:+EnablePprof was given
+func addPprofHandlers(mux *http.ServeMux, gl *globals.Globals) {
+	if !gl.Flags.Bool("EnablePprof") {
+		return
+	}
+	mux.HandleFunc("/debug/pprof/", httppprof.Index) // which serves heap, goroutine, etc.
+	mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+	mux.HandleFunc("/debug/pprof/javaheap", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="javaheap"`)
+		_ = writeJavaHeapProfile(w)
+	})
+}
+
+// ---- the labels ----
+
+// the labels of each method, which are created when the method is first run
+var methodLabels sync.Map // class.method -> context.Context
+
+// labelFrame labels the goroutine with the frame's method
+func labelFrame(f *frame) {
+	name := f.clName + "." + f.methName
+	labels, present := methodLabels.Load(name)
+	if !present {
+		labels, _ = methodLabels.LoadOrStore(name, pprof.WithLabels(context.Background(),
+			pprof.Labels("java.method", strings.ReplaceAll(f.clName, "/", ".")+"."+f.methName)))
+	}
+	f.labels = labels.(context.Context)
+	pprof.SetGoroutineLabels(f.labels)
+}
+
+// restoreLabels labels the goroutine with the method of the first labeled frame from
+// e down, which is the frame that's running again once the frame above it has returned
+func restoreLabels(e *list.Element) {
+	for ; e != nil; e = e.Next() {
+		if f := e.Value.(*frame); f.labels != nil {
+			pprof.SetGoroutineLabels(f.labels)
+			return
+		}
+	}
+	pprof.SetGoroutineLabels(context.Background())
+}
+
+// ---- the Java heap profile ----
+
+// the deepest stack that's recorded for an allocation
+const maxAllocationDepth = 64
+
+// allocationSite is the objects of a class allocated at a Java stack
+type allocationSite struct {
+	class   string
+	frames  []siteFrame // the leaf first
+	objects int64
+	bytes   int64
+}
+
+// siteFrame is a frame of the stack of an allocation site
+type siteFrame struct {
+	class, method, desc string
+	pc                  int
+}
+
+var allocationSites = make(map[string]*allocationSite) // keyed by the class and stack
+var allocationSitesMutex sync.Mutex
+
+// recordAllocation implements classloader.ObjectAllocated: it adds the object to the
+// site at the current thread's stack. The size is the object's header and fields, as
+// in the heap histogram (so an array's elements aren't counted).
+func recordAllocation(className string, fieldCount int) {
+	var frames []siteFrame
+	if id := currentThreadID(); id >= 0 {
+		threadsMutex.Lock()
+		t, present := threads[id]
+		threadsMutex.Unlock()
+		if present && t.stack != nil { // the stack is the current thread's, so it can't change
+			for e := t.stack.Front(); e != nil && len(frames) < maxAllocationDepth; e = e.Next() {
+				f := e.Value.(*frame)
+				class, name, desc := frameMethod(f)
+				frames = append(frames, siteFrame{class, name, desc, f.pc})
+			}
+		}
+	}
+
+	var key strings.Builder
+	key.WriteString(className)
+	for _, f := range frames {
+		key.WriteString("\x00" + f.class + "." + f.method + f.desc + "@" + strconv.Itoa(f.pc))
+	}
+
+	allocationSitesMutex.Lock()
+	defer allocationSitesMutex.Unlock()
+	site, present := allocationSites[key.String()]
+	if !present {
+		site = &allocationSite{class: className, frames: frames}
+		allocationSites[key.String()] = site
+	}
+	site.objects++
+	site.bytes += 16 + 8*int64(fieldCount)
+}
+
+// writeJavaHeapProfile writes the allocations at each site, in the pprof format. The
+// leaf of each stack is a frame for the class, as in "new java.lang.String", which is
+// also the label object.class.
+func writeJavaHeapProfile(w io.Writer) error {
+	prof := &pprofProfile{
+		sampleTypes: [][2]string{{"alloc_objects", "count"}, {"alloc_space", "bytes"}},
+		duration:    time.Since(profilingStart),
+	}
+	allocationSitesMutex.Lock()
+	for _, site := range allocationSites {
+		class := strings.ReplaceAll(site.class, "/", ".")
+		stack := []pprofFrame{{function: "new " + class, systemName: site.class}}
+		for _, f := range site.frames {
+			file, line := sourceLine(f.class, f.method+f.desc, f.pc)
+			stack = append(stack, pprofFrame{function: strings.ReplaceAll(f.class, "/", ".") + "." + f.method,
+				systemName: f.class + "." + f.method + f.desc, file: file, line: line})
+		}
+		prof.samples = append(prof.samples, pprofSample{stack: stack,
+			values: []int64{site.objects, site.bytes},
+			labels: map[string]string{"object.class": class}})
+	}
+	allocationSitesMutex.Unlock()
+	return writePprof(w, prof)
+}
+
+// sourceLine returns the source file of the class and the source line of the bytecode
+// at pc in the method (whose name includes its descriptor). The file is "" if it's not
+// known, and the line is 0.
+func sourceLine(className, method string, pc int) (string, int64) {
+	classloader.MethAreaMutex.RLock()
+	k, present := classloader.Classes[className]
+	classloader.MethAreaMutex.RUnlock()
+	if !present || k.Data == nil {
+		return "", 0
+	}
+	file := ""
+	if k.Data.SourceFile != "" { // in the directory of the package, as a debugger expects
+		file = path.Join(path.Dir(className), k.Data.SourceFile)
+	}
+	for i := range k.Data.Methods {
+		m := &k.Data.Methods[i]
+		if k.Data.CP.Utf8Refs[m.Name]+k.Data.CP.Utf8Refs[m.Desc] != method {
+			continue
+		}
+		lines, _ := classloader.LineNumbers(&k.Data.CP, &m.CodeAttr)
+		line, start := int64(0), -1
+		for _, l := range lines { // the entries aren't necessarily in order
+			if l.StartPc <= pc && l.StartPc > start {
+				line, start = int64(l.Line), l.StartPc
+			}
+		}
+		return file, line
+	}
+	return file, 0
+}
@@ -0,0 +1,145 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"jacobin/globals"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"testing"
+)
+
+// protoFields returns the top-level fields of a protocol buffer message: for each field
+// number, its varint values or its length-delimited contents
+func protoFields(t *testing.T, b []byte) map[int][][]byte {
+	fields := make(map[int][][]byte)
+	varint := func() uint64 {
+		var x uint64
+		for shift := uint(0); ; shift += 7 {
+			if len(b) == 0 {
+				t.Fatal("truncated protocol buffer")
+			}
+			c := b[0]
+			b = b[1:]
+			x |= uint64(c&0x7f) << shift
+			if c < 0x80 {
+				return x
+			}
+		}
+	}
+	for len(b) > 0 {
+		key := varint()
+		switch key & 7 {
+		case 0:
+			fields[int(key>>3)] = append(fields[int(key>>3)], []byte{byte(varint())})
+		case 2:
+			n := varint()
+			fields[int(key>>3)] = append(fields[int(key>>3)], b[:n])
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return fields
+}
+
+func TestLabelFrame(t *testing.T) {
+	f := createFrame(0)
+	f.clName, f.methName = "java/lang/String", "length"
+	labelFrame(f)
+	defer restoreLabels(nil)
+	if value, _ := pprof.Label(f.labels, "java.method"); value != "java.lang.String.length" {
+		t.Errorf("expected the label java.lang.String.length, got %s", value)
+	}
+	g := createFrame(0)
+	g.clName, g.methName = "java/lang/String", "length"
+	labelFrame(g)
+	if g.labels != f.labels {
+		t.Error("expected the labels of a method to be reused")
+	}
+}
+
+func TestJavaHeapProfile(t *testing.T) {
+	allocationSites = make(map[string]*allocationSite)
+	defer func() { allocationSites = make(map[string]*allocationSite) }()
+
+	// a thread running Hello.main(), on this goroutine
+	th := CreateThread(newThreadID())
+	registerThread(&th)
+	defer threadEnded(&th)
+	goroutineThreads.Store(goroutineID(), th.id)
+	defer goroutineThreads.Delete(goroutineID())
+	f := createFrame(0)
+	f.clName, f.methName, f.methType, f.pc = "Hello", "main", "([Ljava/lang/String;)V", 7
+	th.stack.PushFront(f)
+
+	recordAllocation("java/lang/String", 2)
+	recordAllocation("java/lang/String", 2)
+	f.pc = 12
+	recordAllocation("[B", 0)
+
+	var out bytes.Buffer
+	if err := writeJavaHeapProfile(&out); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	gz, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatalf("expected a gzipped profile: %s", err.Error())
+	}
+	profile, _ := io.ReadAll(gz)
+	fields := protoFields(t, profile)
+	// Hello isn't loaded, so there are no line numbers, and both sites are at one location
+	// in Hello.main; the other locations are the classes
+	if len(fields[1]) != 2 || len(fields[2]) != 2 || len(fields[4]) != 3 || len(fields[5]) != 3 {
+		t.Errorf("expected 2 sample types, 2 samples, 3 locations, and 3 functions, got %d, %d, %d, %d",
+			len(fields[1]), len(fields[2]), len(fields[4]), len(fields[5]))
+	}
+	strings := make(map[string]bool)
+	for _, s := range fields[6] {
+		strings[string(s)] = true
+	}
+	for _, s := range []string{"", "alloc_objects", "alloc_space", "bytes", "Hello.main",
+		"Hello.main([Ljava/lang/String;)V", "object.class", "java.lang.String", "new java.lang.String", "[B", "new [B"} {
+		if !strings[s] {
+			t.Errorf("expected %q in the string table", s)
+		}
+	}
+}
+
+func TestProfilingFlags(t *testing.T) {
+	gl := globals.InitGlobals("test")
+	dir := t.TempDir()
+	_ = gl.Flags.Set("CPUProfile=" + filepath.Join(dir, "cpu.prof"))
+	_ = gl.Flags.Set("HeapProfile=" + filepath.Join(dir, "heap.prof"))
+	_ = gl.Flags.Set("+EnablePprof")
+	if err := startProfiling(&gl); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer func() { profiling = false }()
+
+	rec := httptest.NewRecorder()
+	metricsHandler(&gl).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pprof/javaheap", nil))
+	if _, err := gzip.NewReader(rec.Body); err != nil {
+		t.Errorf("expected the Java heap profile, got %v", err)
+	}
+	rec = httptest.NewRecorder()
+	metricsHandler(&gl).ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	if rec.Code != 200 || !bytes.Contains(rec.Body.Bytes(), []byte("goroutine")) {
+		t.Errorf("expected the index of the profiles, got %d", rec.Code)
+	}
+
+	stopProfiling()
+	for _, name := range []string{"cpu.prof", "heap.prof"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Size() == 0 {
+			t.Errorf("expected %s to be written", name)
+		}
+	}
+}
@@ -0,0 +1,1180 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2021-2 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"errors"
+	"jacobin/agent"
+	"jacobin/classloader"
+	"jacobin/foreign"
+	"jacobin/globals"
+	"jacobin/jfr"
+	"jacobin/jni"
+	"jacobin/log"
+	"jacobin/messages"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// MainThread is the context of the thread that runs main(). It's kept here only so
+// it can be found before the thread is registered; the interpreter itself always
+// gets the context of the thread it's running from the frame (see threadOfFrame()).
+var MainThread execThread
+
+// StartExec is where execution begins. It initializes various structures, such as
+// the MTable, then using the passed-in name of the starting class, finds its main() method
+// in the method area (it's guaranteed to already be loaded), grabs the executable
+// bytes, creates a thread of execution, pushes the main() frame onto the JVM stack
+// and begins execution.
+func StartExec(className string, globals *globals.Globals) error {
+	initExec(globals)
+
+	me, err := classloader.FetchMethodAndCP(className, "main", "([Ljava/lang/String;)V")
+	if err != nil {
+		return messages.New("JACOBIN-IN-0001", className)
+	}
+
+	m := me.Meth.(*classloader.JmEntry)
+	f := createFrame(m.MaxStack) // create a new frame
+	f.methName = "main"
+	f.methType = "([Ljava/lang/String;)V"
+	f.clName = className
+	f.cp = m.Cp                        // add its pointer to the class CP
+	for i := 0; i < len(m.Code); i++ { // copy the bytecodes over
+		f.meth = append(f.meth, m.Code[i])
+	}
+	f.method = m // its code is decoded when it's executed (see decode.go)
+
+	// allocate the local variables
+	for k := 0; k < m.MaxLocals; k++ {
+		f.locals = append(f.locals, 0)
+	}
+
+	// create the first thread and place its first frame on it
+	MainThread = CreateThread(0)
+	MainThread.trace = globals.OptionSet("-trace")
+	f.thread = MainThread.id
+
+	// the main thread is a non-daemon thread, so it's registered with the other
+	// live threads and removed when main() returns.
+	registerThread(&MainThread)
+
+	// the main class is initialized before main() is invoked (JVMS 5.5), so its
+	// <clinit> runs on the empty stack
+	if err = initializeClass(className, MainThread.stack); err != nil {
+		uncaughtException(&MainThread, err)
+		threadEnded(&MainThread)
+		return err
+	}
+
+	if pushFrame(MainThread.stack, f) != nil {
+		threadEnded(&MainThread)
+		err := messages.New("JACOBIN-IN-0002", MainThread.id)
+		_ = log.Log(err.Msg, log.SEVERE)
+		return err
+	}
+	err = runThread(&MainThread)
+	threadEnded(&MainThread)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// initExec initializes the MTable, the statics the natives rely on, and the hooks
+// that connect the natives to the interpreter, before any Java code runs
+func initExec(globals *globals.Globals) {
+	classloader.MTable = make(classloader.MT)
+	classloader.MTableLoadNatives()
+	if checkNatives = globals.CheckNatives; checkNatives {
+		checkNativeRegistry()
+	}
+	classloader.InitStdStreams()
+	classloader.InitNioStatics()
+	classloader.InitProcessStatics()
+	classloader.InitCharsetStatics()
+	classloader.InitForeignStatics()
+	classloader.InitSignalStatics() // which also begins handling SIGTERM, etc.
+	classloader.VMExit = exitVM
+	classloader.VMHalt = haltVM
+	classloader.RunSignalHandler = runSignalHandler
+	classloader.InvokeMethod = invokeMethod
+	classloader.Checkpoint = checkpointFromJava
+	classloader.CurrentThread = func() int64 { return objectOfThread(currentThread()) }
+	classloader.StartThread = startThreadObject
+	classloader.JoinThread = joinThreadObject
+	classloader.SetThreadDaemon = setThreadObjectDaemon
+	classloader.ThreadIsDaemon = threadObjectIsDaemon
+	classloader.InterruptThread = interruptThreadObject
+	classloader.ThreadIsInterrupted = threadObjectIsInterrupted
+	classloader.ClearInterrupt = func() bool { return clearInterrupt(currentThread()) }
+	classloader.ObjectWait = waitOnObject
+	classloader.ObjectNotify = notifyObject
+	classloader.ThreadLocalGet = getThreadLocal
+	classloader.ThreadLocalSet = setThreadLocal
+	classloader.ThreadLocalRemove = removeThreadLocal
+	classloader.Park = parkCurrentThread
+	classloader.Unpark = unparkThreadObject
+	classloader.SetThreadPriority = setThreadObjectPriority
+	classloader.ThreadPriority = threadObjectPriority
+	classloader.YieldThread = yieldCurrentThread
+	log.CurrentThread = currentThreadID
+	jfr.CurrentThread = currentThreadID
+	jni.Install()     // so System.loadLibrary() can load JNI libraries
+	foreign.Install() // and the FFM API can call C functions
+	agent.VMInit()
+
+	// a VM that restores a checkpoint creates the same objects up to here (see checkpoint.go)
+	bootObjects = classloader.HeapSize()
+}
+
+// Point the thread to the top of the frame stack and tell it to run from there.
+// An error that ends the thread is recorded as the thread's pending exception.
+func runThread(t *execThread) error {
+	defer reportCrash(t)
+	if agent.Active() {
+		agent.ThreadStarted(t.id, threadName(t))
+		defer agent.ThreadEnded(t.id, threadName(t))
+	}
+	goroutine := goroutineID()
+	goroutineThreads.Store(goroutine, t.id)
+	defer goroutineThreads.Delete(goroutine)
+
+	for t.stack.Len() > 0 {
+		err := runFrame(t.stack)
+		if err != nil {
+			t.exception = err
+			if jfr.Recording() { // the frame that threw the exception is still on the stack
+				class, name, desc := frameMethod(t.stack.Front().Value.(*frame))
+				jfr.ExceptionThrown(err.Error(), class+"."+name+desc)
+			}
+			uncaughtException(t, err)
+			return err
+		}
+
+		if t.stack.Len() == 1 { // true when the last executed frame was main()
+			return nil
+		}
+
+		// the frame was restored from a checkpoint, and has returned to its caller,
+		// which carries on after the call (see checkpoint.go)
+		t.stack.Remove(t.stack.Front())
+		t.stack.Front().Value.(*frame).pc++
+	}
+	return nil
+}
+
+// runFrame() runs the method in the frame at the head of the stack, which is done by
+// interpret(). When a Go agent is registered, the agent is told of the method's entry
+// and exit, and of an exception it throws (see agent/agent.go).
+func runFrame(fs *list.List) error {
+	if !agent.Active() {
+		return interpret(fs)
+	}
+	f := fs.Front().Value.(*frame)
+	t := threadOfFrame(f)
+	class, name, desc := frameMethod(f)
+	agent.MethodEntered(t.id, class, name, desc)
+	err := interpret(fs)
+	if err != nil && err != t.exception { // the exception was thrown by this method
+		t.exception = err
+		agent.ExceptionThrown(t.id, err.Error(), class, name, desc)
+	}
+	agent.MethodExited(t.id, class, name, desc, err != nil)
+	return err
+}
+
+// interpret() is the principal execution function in Jacobin. It first tests for a
+// golang function in the present frame. If it is a golang function, it's sent to
+// a different function for execution. Otherwise, bytecode interpretation takes
+// place through a giant switch statement.
+func interpret(fs *list.List) error {
+	// the current frame is always the head of the linked list of frames.
+	// the next statement converts the address of that frame to the more readable 'f'
+	f := fs.Front().Value.(*frame)
+
+	// the interpreter state for this thread (trace settings, etc.)
+	t := threadOfFrame(f)
+
+	// with -XX:+PrintMethodStatistics, the invocation is counted and timed
+	if methodStatistics {
+		beginInvocation(f)
+		defer endInvocation(fs.Front())
+	}
+
+	// if the frame contains a golang method, execute it using runGframe(),
+	// which returns a value (possibly nil) and an error code. Presuming no error,
+	// if the return value (here, retval) is not nil, it is placed on the stack
+	// of the calling frame.
+	if f.ftype == 'G' {
+		if debugger != nil { // the thread's Java frames can't change while it runs Go code
+			debugger.EnterNative(threadIDBase + int64(f.thread))
+		}
+		if fs == t.stack { // nor can a thread dump see them change (see threadDump.go)
+			parkStack(t)
+		}
+		retval, err := runGframe(f)
+		if fs == t.stack {
+			unparkStack(t)
+		}
+		if debugger != nil {
+			debugger.ExitNative(threadIDBase + int64(f.thread))
+		}
+		if checkNatives && err == nil {
+			if err = checkNativeReturn(f.methName, retval); err != nil {
+				return err
+			}
+		}
+
+		if retval != nil {
+			f = fs.Front().Next().Value.(*frame)
+			push(f, retval.(int64))
+		}
+		return err
+	}
+
+	// the frame's method is not a golang method, so it's Java bytecode, which
+	// is interpreted in the rest of this function.
+
+	// while profiling, the CPU time is attributed to the method (see profiling.go)
+	if profiling {
+		labelFrame(f)
+		defer restoreLabels(fs.Front().Next())
+	}
+	if coverage {
+		f.coverage = coverageOf(f)
+	}
+
+	// an exception that the method catches resumes execution at the handler
+	for {
+		err := execute(fs, f, t)
+		if err == nil || !catchException(fs, f, err) {
+			return err
+		}
+	}
+}
+
+// execute interprets the bytecodes of frame f, the head of the frame stack fs, from
+// f.pc until the method returns or throws an exception, which is returned as an error
+func execute(fs *list.List, f *frame, t *execThread) error {
+	if f.code == nil {
+		code, err := frameCode(f)
+		if err != nil {
+			return err
+		}
+		f.code = code
+	}
+	supers := superinstructionsFor(f, t)
+	var history opcodeHistory // of the sequences, while they're counted
+	for f.pc < len(f.meth) {
+		if embedded && atomic.LoadInt32(&halted) != 0 { // the run has ended (see embed.go)
+			runtime.Goexit()
+		}
+		if debugger != nil && debugger.Active() {
+			debugSafepoint(f)
+		}
+		if atomic.LoadInt32(&checkpointRequested) != 0 && fs == t.stack { // see checkpoint.go
+			checkpointAtSafepoint(t)
+		}
+		if sampling && atomic.LoadInt32(&t.sampleRequested) != 0 { // see sampler.go
+			takeSample(t, fs)
+		}
+		if atomic.LoadInt32(&stacksRequested) != 0 && fs == t.stack { // see threadDump.go
+			answerStackRequest(t)
+		}
+		if f.stats != nil {
+			f.stats.bytecodes++
+		}
+		if f.coverage != nil {
+			atomic.AddUint32(&f.coverage[f.pc], 1)
+		}
+		if vmSummary {
+			atomic.AddInt64(&t.bytecodes, 1)
+		}
+		if t.trace {
+			_ = log.Log("class: "+f.clName+
+				", meth: "+f.methName+
+				", pc: "+strconv.Itoa(f.pc)+
+				", inst: "+BytecodeNames[int(f.meth[f.pc])]+
+				", tos: "+strconv.Itoa(f.tos),
+				log.TRACE_INST)
+		}
+		if useSuperinstructions && atomic.LoadInt32(&countingSequences) != 0 {
+			countSequence(&history, f.meth[f.pc])
+		}
+		if supers != nil { // see superinstructions.go
+			if s := supers[f.pc]; s != nil {
+				if f.stats != nil {
+					f.stats.bytecodes += int64(len(s.opcodes) - 1)
+				}
+				if vmSummary {
+					atomic.AddInt64(&t.bytecodes, int64(len(s.opcodes)-1))
+				}
+				if err := s.run(f); err != nil {
+					return err
+				}
+				f.pc += 1
+				continue
+			}
+		}
+		switch f.meth[f.pc] { // cases listed in numerical value of opcode
+		case NOP:
+			break
+		case ACONST_NULL: // 0x01	(push null onto opStack)
+			push(f, 0)
+		case ICONST_N1: //	0x02	(push -1 onto opStack)
+			push(f, -1)
+		case ICONST_0: // 	0x03	(push 0 onto opStack)
+			push(f, 0)
+		case ICONST_1: //  	0x04	(push 1 onto opStack)
+			push(f, 1)
+		case ICONST_2: //   0x05	(push 2 onto opStack)
+			push(f, 2)
+		case ICONST_3: //   0x06	(push 3 onto opStack)
+			push(f, 3)
+		case ICONST_4: //   0x07	(push 4 onto opStack)
+			push(f, 4)
+		case ICONST_5: //   0x08	(push 5 onto opStack)
+			push(f, 5)
+		case BIPUSH: //	0x10	(push the following byte as an int onto the stack)
+			push(f, int64(f.code[f.pc].operand))
+			f.pc += 1
+		case SIPUSH: //	0x11	(push the following two bytes as a signed short onto the stack)
+			push(f, int64(f.code[f.pc].operand))
+			f.pc += 2
+		case LDC: // 	0x12   	(push constant from CP indexed by next byte)
+			push(f, loadConstant(f.cp, int(f.code[f.pc].operand)))
+			f.pc += 1
+		case LDC_W: //	0x13	(push constant from CP indexed by next two bytes)
+			push(f, loadConstant(f.cp, int(f.code[f.pc].operand)))
+			f.pc += 2
+		case ILOAD: //	0x15	(push the local variable indexed by the next byte)
+			push(f, f.locals[f.code[f.pc].operand])
+			f.pc += 1
+		case ALOAD: //	0x19	(push the reference in the local variable indexed by the next byte)
+			push(f, f.locals[f.code[f.pc].operand])
+			f.pc += 1
+		case ILOAD_0: // 	0x1A    (push local variable 0)
+			push(f, f.locals[0])
+		case ILOAD_1: //    OX1B    (push local variable 1)
+			push(f, f.locals[1])
+		case ILOAD_2: //    0X1C    (push local variable 2)
+			push(f, f.locals[2])
+		case ILOAD_3: //  	0x1D   	(push local variable 3)
+			push(f, f.locals[3])
+		case LLOAD_0: //	0x1E	(push local variable 0, as long)
+			push(f, f.locals[0])
+		case LLOAD_1: //	0x1F	(push local variable 1, as long)
+			push(f, f.locals[1])
+		case LLOAD_2: //	0x20	(push local variable 2, as long)
+			push(f, f.locals[2])
+		case LLOAD_3: //	0x21	(push local variable 3, as long)
+			push(f, f.locals[3])
+		case ALOAD_0: //	0x2A	(push reference stored in local variable 0)
+			push(f, f.locals[0])
+		case ALOAD_1: //	0x2B	(push reference stored in local variable 1)
+			push(f, f.locals[1])
+		case ALOAD_2: //	0x2C    (push reference stored in local variable 2)
+			push(f, f.locals[2])
+		case ALOAD_3: //	0x2D	(push reference stored in local variable 3)
+			push(f, f.locals[3])
+		case IALOAD, AALOAD: //	0x2E, 0x32	(push the int or reference in an array)
+			index := pop(f)
+			elements, err := arrayElements(pop(f), index)
+			if err != nil {
+				return err
+			}
+			push(f, elements[index])
+		case ISTORE: //	0x36	(store popped int into the local variable indexed by the next byte)
+			f.locals[f.code[f.pc].operand] = pop(f)
+			f.pc += 1
+		case ASTORE: //	0x3A	(store popped reference into the local variable indexed by the next byte)
+			f.locals[f.code[f.pc].operand] = pop(f)
+			f.pc += 1
+		case ISTORE_0: //   0x3B    (store popped top of stack int into local 0)
+			f.locals[0] = pop(f)
+		case ISTORE_1: //   0x3C   	(store popped top of stack int into local 1)
+			f.locals[1] = pop(f)
+		case ISTORE_2: //   0x3D   	(store popped top of stack int into local 2)
+			f.locals[2] = pop(f)
+		case ISTORE_3: //   0x3E    (store popped top of stack int into local 3)
+			f.locals[3] = pop(f)
+		case LSTORE_0: //   0x3F    (store long from top of stack into locals 0 and 1)
+			f.locals[0] = pop(f)
+			f.locals[1] = f.locals[0]
+		case LSTORE_1: //   0x40    (store long from top of stack into locals 1 and 2)
+			f.locals[1] = pop(f)
+			f.locals[2] = f.locals[1]
+		case LSTORE_2: //   0x41    (store long from top of stack into locals 2 and 3)
+			f.locals[2] = pop(f)
+			f.locals[3] = f.locals[2]
+		case LSTORE_3: //   0x42    (store long from top of stack into locals 3 and 4)
+			f.locals[3] = pop(f)
+			f.locals[4] = f.locals[3]
+		case ASTORE_0: //	0x4B	(pop reference into local variable 0)
+			f.locals[0] = pop(f)
+		case ASTORE_1: //   0x4C	(pop reference into local variable 1)
+			f.locals[1] = pop(f)
+		case ASTORE_2: // 	0x4D	(pop reference into local variable 2)
+			f.locals[2] = pop(f)
+		case ASTORE_3: //	0x4E	(pop reference into local variable 3)
+			f.locals[3] = pop(f)
+		case IASTORE, AASTORE: //	0x4F, 0x53	(store the popped int or reference in an array)
+			value := pop(f)
+			index := pop(f)
+			elements, err := arrayElements(pop(f), index)
+			if err != nil {
+				return err
+			}
+			elements[index] = value
+		case POP: //	0x57	(pop and discard the item at the top of the stack)
+			pop(f)
+		case DUP: //	0x59	(push a copy of the item at the top of the stack)
+			push(f, f.opStack[f.tos])
+		case IADD: //   0x60	(add top 2 items on operand stack, push result)
+			i2 := pop(f)
+			i1 := pop(f)
+			push(f, i1+i2)
+		case IMUL: //  0x68  	(multiply 2 items on operand stack, push result)
+			i2 := pop(f)
+			i1 := pop(f)
+			push(f, i1*i2)
+		case ISUB: //  0x64	(subtract top 2 items on operand stack, push result)
+			i2 := pop(f)
+			i1 := pop(f)
+			push(f, i1-i2)
+		case IINC: // 	0x84    (increment local variable by a constant)
+			localVarIndex := f.code[f.pc].operand
+			constAmount := f.code[f.pc].constant
+			f.pc += 2
+			f.locals[localVarIndex] += int64(constAmount)
+		case IFEQ: // 0x99	(jump if the popped int is 0, as when equals() returns false)
+			if pop(f) == 0 {
+				jumpTo := f.code[f.pc].operand
+				f.pc = f.pc + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
+			} else {
+				f.pc += 2
+			}
+		case IFNE: // 0x9A	(jump if the popped int is not 0)
+			if pop(f) != 0 {
+				jumpTo := f.code[f.pc].operand
+				f.pc = f.pc + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
+			} else {
+				f.pc += 2
+			}
+		case IF_ICMPLT: //  0xA1    (jump if popped val1 < popped val2)
+			val2 := pop(f)
+			val1 := pop(f)
+			if val1 < val2 { // if comp succeeds, next 2 bytes hold instruction index
+				jumpTo := f.code[f.pc].operand
+				f.pc = f.pc + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
+			} else {
+				f.pc += 2
+			}
+		case IF_ICMPGE: //  0xA2    (jump if popped val1 >= popped val2)
+			val2 := pop(f)
+			val1 := pop(f)
+			if val1 >= val2 { // if comp succeeds, next 2 bytes hold instruction index
+				jumpTo := f.code[f.pc].operand
+				f.pc = f.pc + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
+			} else {
+				f.pc += 2
+			}
+		case IF_ICMPLE: //	0xA4	(jump if popped val1 <= popped val2)
+			val2 := pop(f)
+			val1 := pop(f)
+			if val1 <= val2 { // if comp succeeds, next 2 bytes hold instruction index
+				jumpTo := f.code[f.pc].operand
+				f.pc = f.pc + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
+			} else {
+				f.pc += 2
+			}
+		case GOTO: // 0xA7     (goto an instruction)
+			jumpTo := f.code[f.pc].operand
+			f.pc = f.pc + int(jumpTo) - 1 // -1 because this loop will increment f.pc by 1
+		case TABLESWITCH: // 0xAA	(jump to the offset in a table indexed by the popped int)
+			// the operands begin at the next multiple of 4 from the start of the method
+			base := f.pc
+			at := (base + 4) &^ 3
+			index := int32(pop(f))
+			low, high := switchOperand(f.meth, at+4), switchOperand(f.meth, at+8)
+			jumpTo := switchOperand(f.meth, at) // the default
+			if index >= low && index <= high {
+				jumpTo = switchOperand(f.meth, at+12+4*int(index-low))
+			}
+			f.pc = base + int(jumpTo) - 1 // -1 because this loop will increment f.pc by 1
+		case LOOKUPSWITCH: // 0xAB	(jump to the offset paired with the popped int)
+			base := f.pc
+			at := (base + 4) &^ 3
+			key := int32(pop(f))
+			jumpTo := switchOperand(f.meth, at) // the default
+			pairs := int(switchOperand(f.meth, at+4))
+			for i := 0; i < pairs; i++ {
+				if switchOperand(f.meth, at+8+8*i) == key {
+					jumpTo = switchOperand(f.meth, at+12+8*i)
+					break
+				}
+			}
+			f.pc = base + int(jumpTo) - 1
+		case IRETURN: // 0xAC (return an int and exit current frame)
+			valToReturn := pop(f)
+			f = fs.Front().Next().Value.(*frame)
+			push(f, valToReturn) // TODO: check what happens when main() ends on IRETURN
+			return nil
+		case ARETURN: // 0xB0	(return a reference and exit current frame)
+			valToReturn := pop(f)
+			f = fs.Front().Next().Value.(*frame)
+			push(f, valToReturn)
+			return nil
+		case RETURN: // 0xB1    (return from void function)
+			f.tos = -1 // empty the stack
+			return nil
+		case GETSTATIC: // 0xB2		(get static field)
+			// the class is initialized first (see classInit.go). A static field that hasn't
+			// been set by putstatic (or preloaded, as System.out is) is added with its default value.
+			in := f.code[f.pc] // the field was resolved when the method was decoded (see decode.go)
+			f.pc += 2
+			if in.ref == nil { // the pointed-to CP entry must be a field reference
+				return refError(f, in, "JACOBIN-IN-0003")
+			}
+
+			className, fieldName, fieldType := in.ref.class, in.ref.sym.Name(), in.ref.sym.Desc()
+			if err := initializeClass(className, fs); err != nil {
+				return err
+			}
+			if declarer, ok := classloader.FieldDeclarer(className, fieldName); ok && declarer != className {
+				className = declarer // an inherited static is the declaring class's field
+				if err := initializeClass(className, fs); err != nil {
+					return err
+				}
+			}
+			fullFieldName := className + "." + fieldName
+
+			// was this static field previously loaded? If not, add it.
+			index, ok := classloader.FindStatic(fullFieldName)
+			if !ok {
+				index = classloader.AddStatic(fullFieldName, classloader.Static{
+					Class:    'L',
+					Type:     fieldType,
+					CP:       f.cp,
+					Volatile: classloader.FieldIsVolatile(className, fieldName),
+				})
+			}
+
+			// push the value of the field. Floats and doubles are pushed as their
+			// bit patterns; longs and doubles occupy a single slot, as with lload.
+			if fieldType == "D" || fieldType == "F" {
+				push(f, int64(math.Float64bits(classloader.LoadStaticFP(index))))
+			} else {
+				push(f, classloader.LoadStaticInt(index))
+			}
+
+		case PUTSTATIC: // 0xB3		(set static field to the value popped off the stack)
+			in := f.code[f.pc] // the field was resolved when the method was decoded (see decode.go)
+			f.pc += 2
+			if in.ref == nil { // the pointed-to CP entry must be a field reference
+				return refError(f, in, "JACOBIN-IN-0004")
+			}
+
+			className, fieldName, fieldType := in.ref.class, in.ref.sym.Name(), in.ref.sym.Desc()
+			if err := initializeClass(className, fs); err != nil {
+				return err
+			}
+			if declarer, ok := classloader.FieldDeclarer(className, fieldName); ok && declarer != className {
+				className = declarer // an inherited static is the declaring class's field
+				if err := initializeClass(className, fs); err != nil {
+					return err
+				}
+			}
+			index, ok := classloader.FindStatic(className + "." + fieldName)
+			if !ok {
+				index = classloader.AddStatic(className+"."+fieldName, classloader.Static{
+					Class:    'L',
+					Type:     fieldType,
+					CP:       f.cp,
+					Volatile: classloader.FieldIsVolatile(className, fieldName),
+				})
+			}
+
+			// longs and doubles occupy a single slot, as with lstore
+			value := pop(f)
+			if fieldType == "D" || fieldType == "F" {
+				classloader.StoreStaticFP(index, math.Float64frombits(uint64(value)))
+			} else {
+				classloader.StoreStaticInt(index, value)
+			}
+
+		case GETFIELD: // 0xB4	(push the value of a field of the object popped off the stack)
+			if err := getField(f, pop(f)); err != nil {
+				return err
+			}
+		case PUTFIELD: // 0xB5	(set a field of an object to the value popped off the stack)
+			in := f.code[f.pc]
+			f.pc += 2
+			if in.ref == nil {
+				return refError(f, in, "JACOBIN-IN-0004")
+			}
+			className, fieldName := in.ref.class, in.ref.sym.Name()
+			value := pop(f) // longs and doubles occupy a single slot, as with putstatic
+			slot, volatile, err := fieldSlot(pop(f), className, fieldName)
+			if err != nil {
+				return err
+			}
+			classloader.StoreField(slot, value, volatile)
+		case INVOKEVIRTUAL: // 	0xB6 invokevirtual (create new frame, invoke function)
+			in := f.code[f.pc] // the method it calls was resolved when its code was decoded (see decode.go)
+			f.pc += 2
+			if in.ref == nil || in.ref.isInterface { // the pointed-to CP entry must be a method reference
+				return refError(f, in, "JACOBIN-IN-0005")
+			}
+
+			className := in.ref.class
+			methodName := className + "." + in.ref.sym.Name()
+			methodType := in.ref.sym.Desc()
+			key := classloader.MTkey{Class: className, Sym: in.ref.sym}
+
+			// calls of some hot methods, such as String.charAt(), are done in Go (see intrinsics.go)
+			if intrinsic := intrinsicFor(key); intrinsic != nil {
+				if err := intrinsic(f); err != nil {
+					return err
+				}
+				break
+			}
+
+			if err := checkMethodRef(INVOKEVIRTUAL, false, className, methodName[len(className)+1:], methodType); err != nil {
+				return err
+			}
+
+			// the method is looked up in the class of the object (e.g., an enum constant
+			// with a body overrides its enum's method), then in its superclasses
+			receiver, isNull := receiverClass(f, methodType, className)
+			if isNull { // as when a switch on a String that's null calls hashCode()
+				return errors.New(errNPE)
+			}
+			v, declarer, err := classloader.FetchVirtualMethod(receiver, in.ref.sym)
+			if err == nil && v.MType == 'J' {
+				m := v.Meth.(*classloader.JmEntry)
+				fram := createJavaFrame(f, m, declarer, methodName[len(className)+1:], methodType, true)
+				fs.PushFront(fram)
+				if err = runFrame(fs); err != nil {
+					return err
+				}
+				fs.Remove(fs.Front()) // pop the frame off
+				f = fs.Front().Value.(*frame)
+				break
+			}
+			if v.Meth == nil {
+				v = classloader.MTable[key]
+			}
+			if v.Meth == nil { // MethodHandle.invokeExact(), etc., accept any descriptor
+				v, _ = classloader.SignaturePolymorphic(methodName + methodType)
+			}
+			if v.Meth == nil { // the object may be a proxy, such as an annotation
+				v, _ = proxyMethod(f, methodName[len(className)+1:], methodType)
+			}
+			if v.Meth != nil && v.MType == 'G' { // so we have a golang function
+				_, err := runGmethod(v, fs, className, methodName, methodType)
+				if err != nil {
+					return err // the exception ends the thread (see uncaught.go)
+				}
+				break
+			}
+		case INVOKESPECIAL: // 	0xB7 invokespecial (invoke constructors, private and super methods)
+			in := f.code[f.pc]
+			f.pc += 2
+			// the CP entry is a method reference, or an interface method reference for
+			// a private method of an interface, or a super call of a default method
+			if in.ref == nil {
+				return refError(f, in, "JACOBIN-IN-0006")
+			}
+			className, methodName, methodType := in.ref.class, in.ref.sym.Name(), in.ref.sym.Desc()
+
+			// java.lang.Object's constructor does nothing, so just discard the reference
+			if className == "java/lang/Object" && methodName == "<init>" {
+				pop(f)
+				break
+			}
+			if err := checkMethodRef(INVOKESPECIAL, in.ref.isInterface,
+				className, methodName, methodType); err != nil {
+				return err
+			}
+
+			mtEntry, err := classloader.FetchMethod(className, in.ref.sym)
+			if err != nil {
+				return messages.New("JACOBIN-IN-0008", className, methodName, methodType)
+			}
+			if mtEntry.MType == 'G' {
+				f, err = runGmethod(mtEntry, fs, className, className+"."+methodName, methodType)
+				if err != nil {
+					return err // the exception ends the thread (see uncaught.go)
+				}
+			} else if mtEntry.MType == 'J' {
+				m := mtEntry.Meth.(*classloader.JmEntry)
+				fram := createJavaFrame(f, m, className, methodName, methodType, true)
+				fs.PushFront(fram)
+				if err = runFrame(fs); err != nil {
+					return err
+				}
+				fs.Remove(fs.Front()) // pop the frame off
+				f = fs.Front().Value.(*frame)
+			}
+		case INVOKESTATIC: // 	0xB8 invokestatic (create new frame, invoke static function)
+			in := f.code[f.pc]
+			f.pc += 2
+			// the CP entry is a method reference, or an interface method reference for
+			// a static method of an interface
+			if in.ref == nil {
+				return refError(f, in, "JACOBIN-IN-0019")
+			}
+			className, methodName, methodType := in.ref.class, in.ref.sym.Name(), in.ref.sym.Desc()
+
+			// calls of some hot methods, such as Math.max(), are done in Go (see intrinsics.go)
+			if intrinsic := intrinsicFor(classloader.MTkey{Class: className, Sym: in.ref.sym}); intrinsic != nil {
+				if err := intrinsic(f); err != nil {
+					return err
+				}
+				break
+			}
+
+			if err := checkMethodRef(INVOKESTATIC, in.ref.isInterface,
+				className, methodName, methodType); err != nil {
+				return err
+			}
+			if err := initializeClass(className, fs); err != nil {
+				return err
+			}
+			// m, cpp, err := fetchMethodAndCP(className, methodName, methodType)
+			mtEntry, err := classloader.FetchMethod(className, in.ref.sym)
+			if err != nil {
+				return messages.New("JACOBIN-IN-0009", className, methodName)
+			}
+
+			if mtEntry.MType == 'G' {
+				f, err = runGmethod(mtEntry, fs, className, className+"."+methodName, methodType)
+				if err != nil {
+					return err // the exception ends the thread (see uncaught.go)
+				}
+			} else if mtEntry.MType == 'J' {
+				m := mtEntry.Meth.(*classloader.JmEntry)
+				fram := createJavaFrame(f, m, className, methodName, methodType, false)
+
+				fs.PushFront(fram)            // push the new frame
+				f = fs.Front().Value.(*frame) // point f to the new head
+				err = runFrame(fs)
+				if err != nil {
+					return err
+				}
+
+				// if the static method is main(), when we get here the
+				// frame stack will be empty to exit from here, otherwise
+				// there's still a frame on the stack, pop it off and continue.
+				if fs.Len() == 0 {
+					return nil
+				}
+				fs.Remove(fs.Front()) // pop the frame off
+
+				// the previous frame pop might have been main()
+				// if so, then we can't reset f to a non-existent frame
+				// so we test for this before resetting f.
+				if fs.Len() != 0 {
+					f = fs.Front().Value.(*frame)
+				} else {
+					return nil
+				}
+			}
+		case INVOKEINTERFACE: // 0xB9 invokeinterface (invoke a method of an interface)
+			// the next 2 bytes point to the CP entry; they're followed by a count and a zero byte
+			in := f.code[f.pc]
+			f.pc += 4
+			if in.ref == nil || !in.ref.isInterface {
+				return refError(f, in, "JACOBIN-IN-0007")
+			}
+			className, methodName, methodType := in.ref.class, in.ref.sym.Name(), in.ref.sym.Desc()
+			if err := checkMethodRef(INVOKEINTERFACE, true, className, methodName, methodType); err != nil {
+				return err
+			}
+
+			// a Java method is looked up in the class of the object, as by invokevirtual,
+			// as when try-with-resources calls AutoCloseable.close() on a resource
+			receiver, isNull := receiverClass(f, methodType, className)
+			if isNull {
+				return errors.New(errNPE)
+			}
+			if receiver != className {
+				jv, declarer, err := classloader.FetchVirtualMethod(receiver, in.ref.sym)
+				if err == nil && jv.MType == 'J' {
+					fram := createJavaFrame(f, jv.Meth.(*classloader.JmEntry), declarer, methodName, methodType, true)
+					fs.PushFront(fram)
+					if err = runFrame(fs); err != nil {
+						return err
+					}
+					fs.Remove(fs.Front()) // pop the frame off
+					f = fs.Front().Value.(*frame)
+					break
+				}
+			}
+
+			// otherwise, the method is implemented in Go: a native registered for the
+			// interface (as for Stream) or a method of a proxy object
+			v := classloader.MTable[classloader.MTkey{Class: className, Sym: in.ref.sym}]
+			if v.Meth == nil {
+				v, _ = proxyMethod(f, methodName, methodType)
+			}
+			if v.Meth == nil || v.MType != 'G' {
+				return messages.New("JACOBIN-IN-0010", className, methodName, methodType)
+			}
+			if _, err := runGmethod(v, fs, className, className+"."+methodName, methodType); err != nil {
+				return err // the exception ends the thread (see uncaught.go)
+			}
+		case NEW: // 0xBB 	new: create and instantiate a new object
+			in := f.code[f.pc] // the class was resolved when the method was decoded (see decode.go)
+			f.pc += 2
+			var className string
+			if in.ref != nil {
+				className = in.ref.class
+			} else if !inCP(f.cp, in.operand) {
+				return refError(f, in, "JACOBIN-IN-0011")
+			} else if f.cp.CpIndex[in.operand].Type != classloader.Interface {
+				_ = log.Log(messages.Text("JACOBIN-IN-0011"), log.SEVERE)
+			}
+
+			// objects of classes implemented in Go don't need their class loaded
+			if classloader.GoClasses[className] {
+				push(f, classloader.NewObject(className, 0))
+				break
+			}
+
+			if err := initializeClass(className, fs); err != nil {
+				return err
+			}
+			ref, err := instantiateClass(className)
+			if err != nil {
+				err = messages.New("JACOBIN-IN-0012", className)
+				_ = log.Log(err.Error(), log.SEVERE)
+				return err
+			}
+			push(f, ref.(int64))
+
+		case NEWARRAY: // 0xBC	(create an array of the primitive type in the next byte)
+			count := pop(f)
+			if count < 0 {
+				return errors.New("java.lang.NegativeArraySizeException: " + strconv.FormatInt(count, 10))
+			}
+			push(f, classloader.NewArray("["+newarrayTypes[byte(f.code[f.pc].operand)], int(count)))
+			f.pc += 1
+		case ANEWARRAY: // 0xBD	(create an array of references to the class in the CP entry)
+			in := f.code[f.pc]
+			f.pc += 2
+			count := pop(f)
+			if count < 0 {
+				return errors.New("java.lang.NegativeArraySizeException: " + strconv.FormatInt(count, 10))
+			}
+			elementClass := in.classOf(f.cp)
+			if !strings.HasPrefix(elementClass, "[") {
+				elementClass = "L" + elementClass + ";"
+			}
+			push(f, classloader.NewArray("["+elementClass, int(count)))
+		case ARRAYLENGTH: // 0xBE	(push the length of the array popped off the stack)
+			ref := pop(f)
+			if ref == 0 {
+				return errors.New(errNPE)
+			}
+			length, _ := classloader.ArrayLength(ref)
+			push(f, int64(length))
+		case ATHROW: // 0xBF	(throw the Throwable popped off the stack)
+			ref := pop(f)
+			if ref == 0 {
+				return errors.New(errNPE)
+			}
+			classloader.FillInStackTrace(ref, stackTrace(fs))
+			return &javaException{ref: ref}
+		case CHECKCAST: // 0xC0	(check that the object on the stack is of the class in the CP entry)
+			in := f.code[f.pc]
+			f.pc += 2
+			obj := classloader.GetObject(f.opStack[f.tos]) // null can be cast to any class
+			if target := in.classOf(f.cp); obj != nil && classloader.CannotCast(obj.Klass, target) {
+				return errors.New("java.lang.ClassCastException: class " + javaName(obj.Klass) +
+					" cannot be cast to class " + javaName(target))
+			}
+		case MONITORENTER: // 0xC2	(acquire the monitor of the object popped off the stack)
+			ref := pop(f)
+			if err := monitorEnter(threadOfFrame(f), ref); err != nil {
+				return err
+			}
+		case MONITOREXIT: // 0xC3	(release the monitor of the object popped off the stack)
+			ref := pop(f)
+			if err := monitorExit(threadOfFrame(f), ref); err != nil {
+				return err
+			}
+		case IFNULL: // 0xC6	(jump if the popped reference is null)
+			if pop(f) == 0 {
+				jumpTo := f.code[f.pc].operand
+				f.pc = f.pc + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
+			} else {
+				f.pc += 2
+			}
+		case IFNONNULL: // 0xC7	(jump if the popped reference is not null, as before close() is called)
+			if pop(f) != 0 {
+				jumpTo := f.code[f.pc].operand
+				f.pc = f.pc + int(jumpTo) - 1 // -1 b/c on the next iteration, pc is bumped by 1
+			} else {
+				f.pc += 2
+			}
+
+		default:
+			err := messages.New("JACOBIN-IN-0013", f.meth[f.pc], f.pc, f.methName, f.clName)
+			_ = log.Log(err.Msg, log.SEVERE)
+			return err
+		}
+		f.pc += 1
+	}
+	return nil
+}
+
+// createJavaFrame creates the frame for a call from frame f to a Java method, pops
+// the method's arguments off f's operand stack, and puts them into the new frame's
+// locals. If hasThis is true, the method is an instance method, and the reference to
+// the object (which is beneath the arguments on the stack) goes into locals[0].
+func createJavaFrame(f *frame, m *classloader.JmEntry, className, methodName, methodType string,
+	hasThis bool) *frame {
+	fram := createFrame(m.MaxStack)
+	fram.thread = f.thread
+	fram.clName = className
+	fram.methName = methodName
+	fram.methType = methodType
+	fram.cp = m.Cp                     // add its pointer to the class CP
+	for i := 0; i < len(m.Code); i++ { // copy the bytecodes over
+		fram.meth = append(fram.meth, m.Code[i])
+	}
+	fram.method = m // its code is decoded when it's executed (see decode.go)
+
+	// allocate the local variables
+	for k := 0; k < m.MaxLocals; k++ {
+		fram.locals = append(fram.locals, 0)
+	}
+
+	// pop the parameters off the present stack and put them in the new frame's locals.
+	// A double or long takes one slot on the operand stack but two local variables,
+	// both of which hold the value, as LSTORE leaves them.
+	var argList []int64
+	paramsToPass := ParseIncomingParamsFromMethTypeString(methodType)
+	for i := len(paramsToPass) - 1; i >= 0; i-- { // the last parameter is on top
+		argList = append(argList, pop(f))
+	}
+	if hasThis {
+		argList = append(argList, pop(f))
+		paramsToPass = append([]byte{'L'}, paramsToPass...)
+	}
+
+	destLocal := 0
+	for i, param := range paramsToPass {
+		arg := argList[len(argList)-1-i]
+		fram.locals[destLocal] = arg
+		destLocal += 1
+		if param == 'D' || param == 'J' {
+			fram.locals[destLocal] = arg
+			destLocal += 1
+		}
+	}
+	fram.tos = -1
+	return fram
+}
+
+// resolveMethodRef gets the name of the class, the name of the method, and the method's
+// descriptor from a method reference in the CP.
+func resolveMethodRef(cp *classloader.CPool, CPentry classloader.CpEntry) (string, string, string) {
+	method := cp.MethodRefs[CPentry.Slot]
+
+	// get the class entry from this method
+	classRef := method.ClassIndex
+	classNameIndex := cp.ClassRefs[cp.CpIndex[classRef].Slot]
+	classNameEntry := cp.CpIndex[classNameIndex]
+	className := cp.Utf8Refs[classNameEntry.Slot]
+
+	// get the method name and the signature for this method
+	nAndTentry := cp.CpIndex[method.NameAndType]
+	sym := cp.NameAndTypeSymbol(int(nAndTentry.Slot))
+	return className, sym.Name(), sym.Desc()
+}
+
+// resolveInterfaceMethodRef gets the names of the interface and method and the
+// method's type from an interface method reference in the CP
+func resolveInterfaceMethodRef(cp *classloader.CPool, CPentry classloader.CpEntry) (string, string, string) {
+	method := cp.InterfaceRefs[CPentry.Slot]
+	classNameIndex := cp.ClassRefs[cp.CpIndex[method.ClassIndex].Slot]
+	className := cp.Utf8Refs[cp.CpIndex[classNameIndex].Slot]
+	sym := cp.NameAndTypeSymbol(int(cp.CpIndex[method.NameAndType].Slot))
+	return className, sym.Name(), sym.Desc()
+}
+
+// proxyMethod returns the MTable entry for a call of the named method on the object
+// whose reference is on f's operand stack beneath the arguments, if it's a proxy
+func proxyMethod(f *frame, methodName, methodType string) (classloader.MTentry, bool) {
+	params := ParseIncomingParamsFromMethTypeString(methodType)
+	if f.tos-len(params) < 0 {
+		return classloader.MTentry{}, false
+	}
+	return classloader.ProxyMethod(f.opStack[f.tos-len(params)], methodName, methodType)
+}
+
+// loadConstant returns the value of the CP entry for the ldc instructions: ints are
+// pushed as is, floats as the bits of a float64, strings (which are converted into
+// UTF8 entries when the class is loaded) as the reference to an interned String, and
+// classes as the reference to their Class object.
+func loadConstant(cp *classloader.CPool, index int) int64 {
+	entry := cp.CpIndex[index]
+	switch entry.Type {
+	case classloader.IntConst:
+		return int64(cp.IntConsts[entry.Slot])
+	case classloader.FloatConst:
+		return classloader.SlotFromFloat(float64(cp.Floats[entry.Slot]))
+	case classloader.UTF8:
+		return classloader.InternString(cp.Utf8Refs[entry.Slot])
+	case classloader.ClassRef: // the Class object, as for Color.class
+		return classloader.ClassObject(resolveClassRef(cp, index))
+	default:
+		return int64(index)
+	}
+}
+
+// threadOfFrame returns the thread that the frame is executing on. Frames that
+// are not on a registered thread (as in some unit tests) run on the main thread.
+func threadOfFrame(f *frame) *execThread {
+	threadsMutex.Lock()
+	t, present := threads[f.thread]
+	threadsMutex.Unlock()
+	if !present {
+		return &MainThread
+	}
+	return t
+}
+
+// resolveFieldRef gets the name of the class, the name of the field, and the field's
+// type from a field reference in the CP.
+func resolveFieldRef(cp *classloader.CPool, CPentry classloader.CpEntry) (string, string, string) {
+	field := cp.FieldRefs[CPentry.Slot]
+
+	// get the class entry from the field entry for this field. It's the class name.
+	classRef := field.ClassIndex
+	classNameIndex := cp.ClassRefs[cp.CpIndex[classRef].Slot]
+	classNameEntry := cp.CpIndex[classNameIndex]
+	className := cp.Utf8Refs[classNameEntry.Slot]
+
+	// process the name and type entry for this field
+	sym := cp.NameAndTypeSymbol(int(cp.CpIndex[field.NameAndType].Slot))
+	return className, sym.Name(), sym.Desc()
+}
+
+// resolveClassRef gets the name of the class in the class reference at the CP index
+func resolveClassRef(cp *classloader.CPool, index int) string {
+	return classloader.FetchUTF8stringFromCPEntryNumber(cp, cp.ClassRefs[cp.CpIndex[index].Slot])
+}
+
+// receiverClass returns the class of the object that a method with the descriptor is
+// invoked on, whose reference is on f's operand stack beneath the arguments (which take
+// a slot each, longs and doubles included, as with lload). If the reference isn't to an
+// object, it returns the class named in the method reference; if it's null, the second
+// return value is true.
+func receiverClass(f *frame, methodType, className string) (string, bool) {
+	params := ParseIncomingParamsFromMethTypeString(methodType)
+	if f.tos-len(params) < 0 {
+		return className, false
+	}
+	ref := f.opStack[f.tos-len(params)]
+	if obj := classloader.GetObject(ref); obj != nil {
+		return obj.Klass, false
+	}
+	return className, ref == 0
+}
+
+// fieldSlot returns the slot that holds the named field of the referenced object, and
+// whether the field is volatile, so that it's read and written atomically. The field
+// may be inherited, so its slot is that of the field in the class that declares it,
+// which is found by looking up the superclass chain (see classloader.FieldSlot()).
+func fieldSlot(ref int64, className, fieldName string) (*int64, bool, error) {
+	obj := classloader.GetObject(ref)
+	if obj == nil {
+		return nil, false, errors.New(errNPE)
+	}
+	slot, volatile, ok := classloader.FieldSlot(className, fieldName)
+	if !ok || slot >= len(obj.Fields) {
+		return nil, false, errors.New("java.lang.NoSuchFieldError: " + fieldName)
+	}
+	return &obj.Fields[slot], volatile, nil
+}
+
+// getField runs the getfield at the frame's pc on the referenced object
+func getField(f *frame, ref int64) error {
+	in := f.code[f.pc]
+	f.pc += 2
+	if in.ref == nil {
+		return refError(f, in, "JACOBIN-IN-0003")
+	}
+	slot, volatile, err := fieldSlot(ref, in.ref.class, in.ref.sym.Name())
+	if err != nil {
+		return err
+	}
+	push(f, classloader.LoadField(slot, volatile)) // longs and doubles occupy a single slot, as with getstatic
+	return nil
+}
+
+// refError returns the error of the instruction at the frame's pc, whose CP entry isn't
+// the kind it uses, which is a VerifyError if its CP index is outside the CP
+func refError(f *frame, in instruction, msgID string) error {
+	if !inCP(f.cp, in.operand) {
+		return errors.New("java.lang.VerifyError: Illegal constant pool index " + strconv.Itoa(int(in.operand)) +
+			" in method " + f.methName + f.methType + " of class " + f.clName)
+	}
+	return messages.New(msgID, f.cp.CpIndex[in.operand].Type, f.pc, f.methName, f.clName)
+}
+
+// arrayElements returns the elements of the referenced array of ints or references,
+// or the exception that accessing the element at the index throws
+func arrayElements(ref, index int64) ([]int64, error) {
+	if ref == 0 {
+		return nil, errors.New(errNPE)
+	}
+	elements, _ := classloader.RefArrayFromRef(ref)
+	if index < 0 || index >= int64(len(elements)) {
+		return nil, errors.New("java.lang.ArrayIndexOutOfBoundsException: Index " +
+			strconv.FormatInt(index, 10) + " out of bounds for length " + strconv.Itoa(len(elements)))
+	}
+	return elements, nil
+}
+
+// switchOperand returns the 4-byte signed operand of tableswitch or lookupswitch at
+// the position in the bytecode
+func switchOperand(code []byte, at int) int32 {
+	return int32(code[at])<<24 | int32(code[at+1])<<16 | int32(code[at+2])<<8 | int32(code[at+3])
+}
+
+// newarrayTypes maps the atype operand of newarray to the descriptor of the type
+var newarrayTypes = map[byte]string{
+	4: "Z", 5: "C", 6: "F", 7: "D", 8: "B", 9: "S", 10: "I", 11: "J",
+}
+
+// javaName returns the Java name of the class, as in java.lang.String
+func javaName(className string) string {
+	return strings.ReplaceAll(className, "/", ".")
+}
+
+// pop from the operand stack. TODO: need to put in checks for invalid pops
+func pop(f *frame) int64 {
+	value := f.opStack[f.tos]
+	f.tos -= 1
+	return value
+}
+
+// push onto the operand stack
+func push(f *frame, i int64) {
+	f.tos += 1
+	f.opStack[f.tos] = i
+}
@@ -4,10 +4,11 @@
  * Licensed under Mozilla Public License 2.0 (MPL 2.0)
  */
 
-package main
+package jvm
 
 import (
 	"io/ioutil"
+	"jacobin/classloader"
 	"jacobin/globals"
 	"jacobin/log"
 	"os"
@@ -218,7 +219,8 @@ func TestGotoBackward(t *testing.T) {
 	f.meth = append(f.meth, GOTO)
 	f.meth = append(f.meth, 0xFF) // should be -1
 	f.meth = append(f.meth, 0xFF)
-	f.meth = append(f.meth, BIPUSH)
+	// a whole instruction, as code that's truncated isn't run
+	f.meth = append(f.meth, BIPUSH, 0)
 	f.pc = 1 // skip over the return instruction to start, catch it on the backward goto
 	fs := createFrameStack()
 	fs.PushFront(&f) // push the new frame
@@ -702,7 +704,10 @@ func TestIsub(t *testing.T) {
 
 func TestLdc(t *testing.T) {
 	f := newFrame(LDC)
-	f.meth = append(f.meth, 0x05)
+	f.meth = append(f.meth, 0x01)
+	f.cp = &classloader.CPool{}
+	f.cp.CpIndex = []classloader.CpEntry{{Type: 0, Slot: 0}, {Type: classloader.IntConst, Slot: 0}}
+	f.cp.IntConsts = []int32{5}
 	fs := createFrameStack()
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
@@ -715,6 +720,51 @@ func TestLdc(t *testing.T) {
 	}
 }
 
+// string constants are pushed as references to interned String objects
+func TestLdcString(t *testing.T) {
+	f := newFrame(LDC)
+	f.meth = append(f.meth, 0x01)
+	f.cp = &classloader.CPool{}
+	f.cp.CpIndex = []classloader.CpEntry{{Type: 0, Slot: 0}, {Type: classloader.UTF8, Slot: 0}}
+	f.cp.Utf8Refs = []string{"hello"}
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	_ = runFrame(fs)
+	ref := pop(&f)
+	s, ok := classloader.GoStringFromRef(ref)
+	if !ok || s != "hello" {
+		t.Errorf("LDC: Expected reference to String \"hello\", got: %q (is string: %t)", s, ok)
+	}
+	if ref != classloader.InternString("hello") {
+		t.Errorf("LDC: Expected string constant to be interned")
+	}
+}
+
+func TestAconstNull(t *testing.T) {
+	f := newFrame(ACONST_NULL)
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	_ = runFrame(fs)
+	if f.tos != 0 || pop(&f) != 0 {
+		t.Errorf("ACONST_NULL: Expected null (0) on the stack")
+	}
+}
+
+func TestDupAndPop(t *testing.T) {
+	f := newFrame(DUP)
+	f.meth = append(f.meth, POP)
+	push(&f, 0x42)
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	_ = runFrame(fs)
+	if f.tos != 0 {
+		t.Errorf("DUP/POP: Expected one item on the stack, tos is: %d", f.tos)
+	}
+	if pop(&f) != 0x42 {
+		t.Errorf("DUP/POP: Expected 0x42 on the stack")
+	}
+}
+
 func TestLload0(t *testing.T) {
 	f := newFrame(LLOAD_0)
 
@@ -956,3 +1006,380 @@ func TestInvalidInstruction(t *testing.T) {
 		t.Errorf("Error message for invalid bytecode not as expected, got: %s", msg)
 	}
 }
+
+// invokeinterface runs the Go method registered for the interface, passing the object
+// and the arguments, and skips the count and zero bytes that follow the CP index
+func TestInvokeinterface(t *testing.T) {
+	classloader.MTable = make(classloader.MT)
+	classloader.MTable[classloader.MethodKey("test/Counter.add(I)I")] = classloader.MTentry{
+		Meth: classloader.GmEntry{ParamSlots: 2, Fu: func(params []interface{}) interface{} {
+			return params[0].(int64) + params[1].(int64)
+		}},
+		MType: 'G',
+	}
+
+	f := newFrame(INVOKEINTERFACE)
+	f.meth = append(f.meth, 0x00, 0x01, 0x02, 0x00)
+	f.cp = &classloader.CPool{}
+	f.cp.CpIndex = []classloader.CpEntry{{Type: 0, Slot: 0},
+		{Type: classloader.Interface, Slot: 0}, {Type: classloader.ClassRef, Slot: 0},
+		{Type: classloader.NameAndType, Slot: 0}, {Type: classloader.UTF8, Slot: 0},
+		{Type: classloader.UTF8, Slot: 1}, {Type: classloader.UTF8, Slot: 2}}
+	f.cp.InterfaceRefs = []classloader.InterfaceRefEntry{{ClassIndex: 2, NameAndType: 3}}
+	f.cp.ClassRefs = []uint16{4}
+	f.cp.NameAndTypes = []classloader.NameAndTypeEntry{{NameIndex: 5, DescIndex: 6}}
+	f.cp.Utf8Refs = []string{"test/Counter", "add", "(I)I"}
+	push(&f, 40) // the object
+	push(&f, 2)  // the argument
+
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("INVOKEINTERFACE: unexpected error: %v", err)
+	}
+	if f.tos != 0 || pop(&f) != 42 {
+		t.Errorf("INVOKEINTERFACE: Expected 42 on the stack")
+	}
+}
+
+// invokevirtual runs the method of the object's class, which overrides the method
+// named in the method reference
+func TestInvokevirtualOverride(t *testing.T) {
+	classloader.MTable = make(classloader.MT)
+	for _, c := range []struct{ name, super string }{{"test/Shape", ""}, {"test/Square", "test/Shape"}} {
+		data := &classloader.ClData{Name: c.name, Superclass: c.super, CP: *testCP()}
+		corners := byte(ICONST_0)
+		if c.name == "test/Square" {
+			corners = ICONST_4
+		}
+		addTestMethod(data, "corners", "()I", 1, []byte{corners, IRETURN})
+		data.Methods[0].AccessFlags = 0 // an instance method, so it has this in locals[0]
+		data.Methods[0].CodeAttr.MaxLocals = 1
+		classloader.Classes[c.name] = classloader.Klass{Status: 'L', Loader: "app", Data: data}
+		defer delete(classloader.Classes, c.name)
+	}
+
+	f := newFrame(INVOKEVIRTUAL)
+	f.meth = append(f.meth, 0x00, 0x01)
+	f.cp = testCP("test/Shape.corners()I")
+	push(&f, classloader.NewObject("test/Square", 0))
+
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("INVOKEVIRTUAL: unexpected error: %v", err)
+	}
+	if f.tos != 0 || pop(&f) != 4 {
+		t.Errorf("INVOKEVIRTUAL: Expected Square's corners() to return 4")
+	}
+}
+
+// newarray, iastore, iaload, and arraylength, as in: int[] a = new int[3]; a[2] = 7;
+// and then a[2] and a.length are pushed
+func TestNewarrayIastoreIaload(t *testing.T) {
+	f := newFrame(ICONST_3)
+	f.meth = append(f.meth, NEWARRAY, 10, ASTORE_0, ALOAD_0, ICONST_2, BIPUSH, 7, IASTORE,
+		ALOAD_0, ICONST_2, IALOAD, ALOAD_0, ARRAYLENGTH)
+	f.locals = append(f.locals, 0)
+
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("NEWARRAY: unexpected error: %v", err)
+	}
+	if length, element := pop(&f), pop(&f); length != 3 || element != 7 {
+		t.Errorf("Expected a[2] = 7 and a.length = 3, got %d and %d", element, length)
+	}
+	if obj := classloader.GetObject(f.locals[0]); obj == nil || obj.Klass != "[I" {
+		t.Errorf("Expected an int[]")
+	}
+}
+
+func TestIaloadOutOfBounds(t *testing.T) {
+	f := newFrame(ALOAD_0)
+	f.meth = append(f.meth, ICONST_3, IALOAD)
+	f.locals = append(f.locals, classloader.NewArray("[I", 3))
+
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+	if err == nil || err.Error() != "java.lang.ArrayIndexOutOfBoundsException: Index 3 out of bounds for length 3" {
+		t.Errorf("IALOAD: Expected ArrayIndexOutOfBoundsException, got %v", err)
+	}
+
+	f = newFrame(ACONST_NULL)
+	f.meth = append(f.meth, ARRAYLENGTH)
+	fs = createFrameStack()
+	fs.PushFront(&f)
+	if err = runFrame(fs); err == nil || err.Error() != errNPE {
+		t.Errorf("ARRAYLENGTH: Expected NullPointerException, got %v", err)
+	}
+}
+
+// runSwitch runs the switch instruction, which is at pc 1, after the instruction that
+// pushes the key, and whose operands begin at pc 4. The cases store their values in
+// locals[0], which is returned.
+func runSwitch(t *testing.T, pushKey byte, switchOp byte, operands ...int32) int64 {
+	f := newFrame(pushKey)
+	f.meth = append(f.meth, switchOp, 0, 0)
+	for _, o := range operands {
+		f.meth = append(f.meth, byte(o>>24), byte(o>>16), byte(o>>8), byte(o))
+	}
+	// the cases: 1 at pc+len, 2 at pc+len+3, and the default, -1, at pc+len+6
+	f.meth = append(f.meth, ICONST_1, ISTORE_0, RETURN, ICONST_2, ISTORE_0, RETURN,
+		ICONST_N1, ISTORE_0, RETURN)
+	f.locals = append(f.locals, 0)
+
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return f.locals[0]
+}
+
+func TestTableswitch(t *testing.T) {
+	// the offsets are relative to the tableswitch at pc 1; the cases begin at pc 24
+	operands := []int32{29, 1, 2, 23, 26} // default, low, high, and the offsets of 1 and 2
+	for key, expected := range map[byte]int64{ICONST_1: 1, ICONST_2: 2, ICONST_0: -1, ICONST_3: -1} {
+		if v := runSwitch(t, key, TABLESWITCH, operands...); v != expected {
+			t.Errorf("TABLESWITCH: Expected %d for key %d, got %d", expected, key-ICONST_0, v)
+		}
+	}
+}
+
+func TestLookupswitch(t *testing.T) {
+	// the pairs are sorted by key; the cases begin at pc 28
+	operands := []int32{33, 2, -5, 30, 4, 27} // default, the count, and the pairs
+	for key, expected := range map[byte]int64{ICONST_4: 1, ICONST_N1: -1} {
+		if v := runSwitch(t, key, LOOKUPSWITCH, operands...); v != expected {
+			t.Errorf("LOOKUPSWITCH: Expected %d for key %d, got %d", expected, int(key)-ICONST_0, v)
+		}
+	}
+}
+
+// ifeq and ifne, as after a call of String.equals() in a switch on Strings
+func TestIfeqIfne(t *testing.T) {
+	for _, c := range []struct {
+		op       byte
+		value    byte
+		expected int64
+	}{{IFEQ, ICONST_0, 2}, {IFEQ, ICONST_1, 1}, {IFNE, ICONST_1, 2}, {IFNE, ICONST_0, 1}} {
+		f := newFrame(c.value)
+		f.meth = append(f.meth, c.op, 0x00, 0x07, ICONST_1, GOTO, 0x00, 0x04, ICONST_2)
+		fs := createFrameStack()
+		fs.PushFront(&f) // push the new frame
+		_ = runFrame(fs)
+		if f.tos != 0 || pop(&f) != c.expected {
+			t.Errorf("%s: Expected %d on the stack", BytecodeNames[c.op], c.expected)
+		}
+	}
+}
+
+// invokevirtual on null throws a NullPointerException, as in a switch on a null String
+func TestInvokevirtualNull(t *testing.T) {
+	classloader.MTable = make(classloader.MT)
+	classloader.MTableLoadNatives()
+	f := newFrame(ACONST_NULL)
+	f.meth = append(f.meth, INVOKEVIRTUAL, 0x00, 0x01)
+	f.cp = testCP("java/lang/String.hashCode()I")
+
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err == nil || err.Error() != errNPE {
+		t.Errorf("INVOKEVIRTUAL: Expected a NullPointerException, got %v", err)
+	}
+}
+
+// putstatic of a long pops the single slot it occupies, and getstatic pushes it back
+func TestPutstaticGetstaticLong(t *testing.T) {
+	f := newFrame(ICONST_2)
+	f.meth = append(f.meth, LLOAD_0, PUTSTATIC, 0x00, 0x01, GETSTATIC, 0x00, 0x01)
+	f.cp = testFieldCP("test/Statics.count", "J")
+	f.locals = append(f.locals, 1)
+
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if f.tos != 1 || f.opStack[1] != 1 || f.opStack[0] != 2 {
+		t.Errorf("PUTSTATIC: Expected the long to be stored and the 2 beneath it left, got %v with tos %d",
+			f.opStack[:f.tos+1], f.tos)
+	}
+}
+
+// getfield and putfield of a volatile field go through its atomic accessors
+func TestVolatileFieldAccess(t *testing.T) {
+	point := &classloader.ClData{Name: "test/Point", CP: classloader.CPool{Utf8Refs: []string{"x", "J"}}}
+	point.Fields = []classloader.Field{{AccessFlags: classloader.ACC_VOLATILE, Name: 0, Desc: 1}}
+	classloader.Classes["test/Point"] = classloader.Klass{Status: 'L', Loader: "test", Data: point}
+	defer delete(classloader.Classes, "test/Point")
+	if _, volatile, ok := classloader.FieldSlot("test/Point", "x"); !ok || !volatile {
+		t.Fatal("Expected test/Point.x to be volatile")
+	}
+
+	obj := classloader.NewObject("test/Point", 1)
+	f := newFrame(ALOAD_0)
+	f.meth = append(f.meth, LLOAD_1, PUTFIELD, 0x00, 0x01, ALOAD_0, GETFIELD, 0x00, 0x01)
+	f.cp = testFieldCP("test/Point.x", "J")
+	f.locals = append(f.locals, obj, 0x7FFFFFFFFFFFFFFF)
+
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if f.tos != 0 || f.opStack[0] != 0x7FFFFFFFFFFFFFFF || classloader.GetObject(obj).Fields[0] != 0x7FFFFFFFFFFFFFFF {
+		t.Errorf("Expected the volatile long to be stored and loaded, got %x with tos %d", f.opStack[0], f.tos)
+	}
+}
+
+// getfield, putfield, getstatic, and putstatic of a field inherited from a superclass
+// use the field of the class that declares it
+func TestInheritedFieldAccess(t *testing.T) {
+	shape := &classloader.ClData{Name: "test/Shape", CP: classloader.CPool{Utf8Refs: []string{"id", "J", "count"}}}
+	shape.Fields = []classloader.Field{{AccessFlags: classloader.ACC_VOLATILE, Name: 0, Desc: 1}, {AccessFlags: 0x0008, Name: 2, Desc: 1}}
+	circle := &classloader.ClData{Name: "test/Circle", Superclass: "test/Shape", CP: classloader.CPool{Utf8Refs: []string{"radius", "J"}}}
+	circle.Fields = []classloader.Field{{Name: 0, Desc: 1}}
+	classloader.Classes["test/Shape"] = classloader.Klass{Status: 'L', Loader: "test", Data: shape}
+	classloader.Classes["test/Circle"] = classloader.Klass{Status: 'L', Loader: "test", Data: circle}
+	defer delete(classloader.Classes, "test/Shape")
+	defer delete(classloader.Classes, "test/Circle")
+
+	if !classloader.FieldIsVolatile("test/Circle", "id") {
+		t.Error("Expected the inherited field test/Circle.id to be volatile")
+	}
+	if declarer, ok := classloader.FieldDeclarer("test/Circle", "count"); !ok || declarer != "test/Shape" {
+		t.Errorf("Expected test/Circle.count to be declared by test/Shape, got %q", declarer)
+	}
+
+	obj := classloader.NewObject("test/Circle", classloader.InstanceSize("test/Circle"))
+	f := newFrame(ALOAD_0)
+	f.meth = append(f.meth, LLOAD_1, PUTFIELD, 0x00, 0x01, ALOAD_0, GETFIELD, 0x00, 0x01)
+	f.cp = testFieldCP("test/Circle.id", "J")
+	f.locals = append(f.locals, obj, 42)
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fields := classloader.GetObject(obj).Fields; f.tos != 0 || f.opStack[0] != 42 || fields[0] != 42 || fields[2] != 0 {
+		t.Errorf("Expected the inherited field to be in slot 0, got %v with %d on the stack", fields, f.opStack[0])
+	}
+
+	f = newFrame(LLOAD_0)
+	f.meth = append(f.meth, PUTSTATIC, 0x00, 0x01)
+	f.cp = testFieldCP("test/Circle.count", "J")
+	f.locals = append(f.locals, 7)
+	fs = createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if index, ok := classloader.FindStatic("test/Shape.count"); !ok || classloader.LoadStaticInt(index) != 7 {
+		t.Error("Expected putstatic of test/Circle.count to set test/Shape.count to 7")
+	}
+}
+
+// ---- benchmarks ----
+
+// These benchmark the interpreter loop on small kernels, which are run through
+// invokeMethod() as the VM runs static methods. Kernels that need arrays, such as a
+// sieve, wait for the interpreter to run the array instructions.
+
+// benchMethods puts the kernels in the MTable:
+//
+//	static int fib(int n) { return n < 2 ? n : fib(n-1) + fib(n-2); }
+//	static int sum(int n) { int s = 0; for (int i = 0; i < n; i++) s += i; return s; }
+//	static int fields(Counter c, int n) { int s = 0; for (int i = 0; i < n; i++) s += c.step; return s; }
+func benchMethods() {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.MTable = make(classloader.MT)
+	cp := testCP("bench/Kernels.fib(I)I")
+	classloader.MTable[classloader.MethodKey("bench/Kernels.fib(I)I")] = classloader.MTentry{MType: 'J',
+		Meth: &classloader.JmEntry{MaxStack: 3, MaxLocals: 1, Cp: cp, Code: []byte{
+			ILOAD_0, ICONST_2, IF_ICMPGE, 0, 5, ILOAD_0, IRETURN,
+			ILOAD_0, ICONST_1, ISUB, INVOKESTATIC, 0, 1,
+			ILOAD_0, ICONST_2, ISUB, INVOKESTATIC, 0, 1,
+			IADD, IRETURN}}}
+	classloader.MTable[classloader.MethodKey("bench/Kernels.sum(I)I")] = classloader.MTentry{MType: 'J',
+		Meth: &classloader.JmEntry{MaxStack: 2, MaxLocals: 3, Cp: cp, Code: []byte{
+			ICONST_0, ISTORE_1, ICONST_0, ISTORE_2,
+			ILOAD_2, ILOAD_0, IF_ICMPGE, 0, 13,
+			ILOAD_1, ILOAD_2, IADD, ISTORE_1, IINC, 2, 1, GOTO, 0xFF, 0xF4,
+			ILOAD_1, IRETURN}}}
+
+	// the kernels' class, so it needn't be loaded when they're called
+	classloader.Classes["bench/Kernels"] = classloader.Klass{Status: 'L', Loader: "test",
+		Data: &classloader.ClData{Name: "bench/Kernels"}}
+	counter := &classloader.ClData{Name: "bench/Counter", CP: classloader.CPool{Utf8Refs: []string{"step", "I"}}}
+	counter.Fields = []classloader.Field{{Name: 0, Desc: 1}}
+	classloader.Classes["bench/Counter"] = classloader.Klass{Status: 'L', Loader: "test", Data: counter}
+	classloader.MTable[classloader.MethodKey("bench/Kernels.fields(Lbench/Counter;I)I")] = classloader.MTentry{MType: 'J',
+		Meth: &classloader.JmEntry{MaxStack: 2, MaxLocals: 4, Cp: testFieldCP("bench/Counter.step", "I"), Code: []byte{
+			ICONST_0, ISTORE_2, ICONST_0, ISTORE_3,
+			ILOAD_3, ILOAD_1, IF_ICMPGE, 0, 16,
+			ILOAD_2, ALOAD_0, GETFIELD, 0, 1, IADD, ISTORE_2, IINC, 3, 1, GOTO, 0xFF, 0xF1,
+			ILOAD_2, IRETURN}}}
+}
+
+// testFieldCP returns a CP whose entry 1 is a reference to the field, as in
+// testFieldCP("test/Point.x", "I")
+func testFieldCP(field, desc string) *classloader.CPool {
+	cp := testCP(field + "()V")
+	cp.Utf8Refs[len(cp.Utf8Refs)-1] = desc
+	ref := cp.MethodRefs[0]
+	cp.FieldRefs = []classloader.FieldRefEntry{{ClassIndex: ref.ClassIndex, NameAndType: ref.NameAndType}}
+	cp.CpIndex[1] = classloader.CpEntry{Type: classloader.FieldRef, Slot: 0}
+	return cp
+}
+
+func benchKernel(b *testing.B, method string, n, expected int64) {
+	benchMethods()
+	runKernel(b, method, n, expected)
+}
+
+// runKernel runs the kernel b.N times, as for benchKernel()
+func runKernel(b *testing.B, method string, n, expected int64) {
+	desc, args := "(I)I", []int64{n}
+	if method == "fields" { // on a Counter whose step is 3
+		counter := classloader.NewObject("bench/Counter", 1)
+		classloader.GetObject(counter).Fields[0] = 3
+		desc, args = "(Lbench/Counter;I)I", []int64{counter, n}
+	}
+	for i := 0; i < b.N; i++ {
+		if v, err := invokeMethod("bench/Kernels", method, desc, args); err != nil || v != expected {
+			b.Fatalf("Expected %s(%d) to return %d, got %d (%v)", method, n, expected, v, err)
+		}
+	}
+}
+
+// benchKernelFused runs the kernel with the superinstructions that its own sequences
+// form, as with -XX:+UseSuperinstructions once the program has warmed up
+func benchKernelFused(b *testing.B, method string, n, expected int64) {
+	benchMethods()
+	useSuperinstructions = true
+	defer func() {
+		useSuperinstructions = false
+		superinstructionsFormed.Store((*superinstructionTable)(nil))
+	}()
+	resetSequenceCounts()
+	runKernel(&testing.B{N: 1}, method, n, expected)
+	formSuperinstructions()
+	b.ResetTimer()
+	runKernel(b, method, n, expected)
+}
+
+func BenchmarkFib(b *testing.B) { benchKernel(b, "fib", 20, 6765) }
+
+func BenchmarkSumLoop(b *testing.B) { benchKernel(b, "sum", 10000, 49995000) }
+
+func BenchmarkFieldLoop(b *testing.B) { benchKernel(b, "fields", 10000, 30000) }
+
+func BenchmarkFibSuperinstructions(b *testing.B) { benchKernelFused(b, "fib", 20, 6765) }
+
+func BenchmarkSumLoopSuperinstructions(b *testing.B) { benchKernelFused(b, "sum", 10000, 49995000) }
+
+func BenchmarkFieldLoopSuperinstructions(b *testing.B) { benchKernelFused(b, "fields", 10000, 30000) }
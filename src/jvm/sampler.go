@@ -0,0 +1,148 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bufio"
+	"container/list"
+	"fmt"
+	"io"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/messages"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The sampling profiler. With -XX:+SampleProfiler, the Java stack of each running
+// thread is sampled every -XX:SampleProfilerInterval milliseconds (10 by default), and
+// when the program exits, the number of samples of each stack is written to
+// -XX:SampleProfilerFile (jacobin-pid<pid>.collapsed by default) in the collapsed-stack
+// format that flame graph tools, such as flamegraph.pl and speedscope, read:
+//     Hello.main;Hello.fib;Hello.fib 42
+// which is the stack from the root to the leaf and its count. Unlike the CPU profiles
+// in profiling.go, this doesn't involve Go's profiler, and the stacks are Java's.
+//
+// A thread's stack can change while another goroutine looks at it, so the sampler
+// doesn't read the stacks itself: it asks each live thread for a sample, and the
+// thread records its stack before it executes its next instruction. So, threads that
+// are blocked or waiting aren't sampled, which is what a CPU profile should show; but
+// a thread running a native method is sampled only when it returns to Java, with at
+// most one sample for the whole call.
+
+// sampling is whether the profiler is running. It's set before the program starts.
+var sampling bool
+
+var (
+	samplerTicker *time.Ticker
+	samplerDone   chan struct{}
+	samplesPath   string
+	samples       = make(map[string]int64) // the count of each collapsed stack
+	samplesMutex  sync.Mutex
+)
+
+// startSampler starts sampling if -XX:+SampleProfiler was given
+func startSampler(gl *globals.Globals) {
+	if !gl.Flags.Bool("SampleProfiler") {
+		return
+	}
+	samplesPath = gl.Flags.String("SampleProfilerFile")
+	if samplesPath == "" {
+		samplesPath = fmt.Sprintf("jacobin-pid%d.collapsed", os.Getpid())
+	}
+	interval := time.Duration(gl.Flags.Int("SampleProfilerInterval")) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+
+	sampling = true
+	samplerTicker = time.NewTicker(interval)
+	samplerDone = make(chan struct{})
+	go func(ticker *time.Ticker, done chan struct{}) {
+		for {
+			select {
+			case <-ticker.C:
+				requestSamples()
+			case <-done:
+				return
+			}
+		}
+	}(samplerTicker, samplerDone)
+	log.Log("Sampling the Java stacks every "+interval.String(), log.INFO)
+}
+
+// stopSampler stops sampling and writes the samples
+func stopSampler() {
+	if samplerTicker == nil {
+		return
+	}
+	samplerTicker.Stop()
+	close(samplerDone)
+	samplerTicker = nil
+
+	f, err := os.Create(samplesPath)
+	if err == nil {
+		err = writeCollapsedStacks(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0050", samplesPath, err.Error())
+	}
+}
+
+// requestSamples asks each live thread for a sample of its stack
+func requestSamples() {
+	threadsMutex.Lock()
+	for _, t := range threads {
+		atomic.StoreInt32(&t.sampleRequested, 1)
+	}
+	threadsMutex.Unlock()
+}
+
+// takeSample records the thread's stack, if a sample was requested. It's called by the
+// thread itself, before it executes an instruction.
+func takeSample(t *execThread, fs *list.List) {
+	if !atomic.CompareAndSwapInt32(&t.sampleRequested, 1, 0) {
+		return
+	}
+	stack := collapsedStack(fs)
+	samplesMutex.Lock()
+	samples[stack]++
+	samplesMutex.Unlock()
+}
+
+// collapsedStack returns the frames of the stack from the root to the leaf, separated
+// by semicolons. A method is shown as class.method, as in java.lang.String.length.
+func collapsedStack(fs *list.List) string {
+	var frames []string
+	for e := fs.Back(); e != nil; e = e.Prev() {
+		class, name, _ := frameMethod(e.Value.(*frame))
+		frames = append(frames, strings.ReplaceAll(class+"."+name, "/", "."))
+	}
+	return strings.Join(frames, ";")
+}
+
+// writeCollapsedStacks writes the count of each stack, in the order of the stacks
+func writeCollapsedStacks(out io.Writer) error {
+	samplesMutex.Lock()
+	stacks := make([]string, 0, len(samples))
+	for stack := range samples {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+	w := bufio.NewWriter(out)
+	for _, stack := range stacks {
+		fmt.Fprintf(w, "%s %d\n", stack, samples[stack])
+	}
+	samplesMutex.Unlock()
+	return w.Flush()
+}
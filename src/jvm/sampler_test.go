@@ -0,0 +1,104 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"container/list"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// sampleStack returns a stack of Hello.main() calling Hello.fib() calling a native method
+func sampleStack() *list.List {
+	fs := createFrameStack()
+	for _, name := range []string{"main", "fib"} {
+		f := createFrame(0)
+		f.clName, f.methName, f.ftype = "com/example/Hello", name, 'J'
+		fs.PushFront(f)
+	}
+	g := createFrame(0)
+	g.methName, g.ftype = "java/io/PrintStream.println(Ljava/lang/String;)V", 'G'
+	fs.PushFront(g)
+	return fs
+}
+
+func TestCollapsedStack(t *testing.T) {
+	stack := collapsedStack(sampleStack())
+	if stack != "com.example.Hello.main;com.example.Hello.fib;java.io.PrintStream.println" {
+		t.Errorf("unexpected stack: %s", stack)
+	}
+}
+
+func TestTakeSample(t *testing.T) {
+	samples = make(map[string]int64)
+	defer func() { samples = make(map[string]int64) }()
+
+	th := CreateThread(newThreadID())
+	registerThread(&th)
+	defer threadEnded(&th)
+	fs := sampleStack()
+
+	takeSample(&th, fs) // no sample was requested
+	requestSamples()
+	takeSample(&th, fs)
+	takeSample(&th, fs) // the request was taken
+	requestSamples()
+	fs.Remove(fs.Front())
+	takeSample(&th, fs)
+
+	var out bytes.Buffer
+	if err := writeCollapsedStacks(&out); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	expected := "com.example.Hello.main;com.example.Hello.fib 1\n" +
+		"com.example.Hello.main;com.example.Hello.fib;java.io.PrintStream.println 1\n"
+	if out.String() != expected {
+		t.Errorf("expected:\n%sgot:\n%s", expected, out.String())
+	}
+}
+
+func TestSamplerWritesFile(t *testing.T) {
+	samples = make(map[string]int64)
+	defer func() { samples = make(map[string]int64) }()
+	defer func() { sampling = false }()
+
+	gl := globals.InitGlobals("test")
+	path := filepath.Join(t.TempDir(), "samples.collapsed")
+	_ = gl.Flags.Set("+SampleProfiler")
+	_ = gl.Flags.Set("SampleProfilerFile=" + path)
+	_ = gl.Flags.Set("SampleProfilerInterval=1")
+
+	th := CreateThread(newThreadID())
+	registerThread(&th)
+	defer threadEnded(&th)
+	fs := sampleStack()
+
+	startSampler(&gl)
+	if !sampling {
+		t.Fatal("expected the sampler to be running")
+	}
+	// the thread runs until it has been sampled
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		takeSample(&th, fs)
+		samplesMutex.Lock()
+		n := len(samples)
+		samplesMutex.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stopSampler()
+
+	content, err := os.ReadFile(path)
+	if err != nil || !bytes.HasPrefix(content, []byte("com.example.Hello.main;com.example.Hello.fib;")) {
+		t.Errorf("expected the samples in %s, got %q, %v", path, content, err)
+	}
+}
@@ -0,0 +1,116 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"jacobin/globals"
+	"jacobin/messages"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Single-file source programs. As with java (see JEP 330), a program in a single source
+// file can be run without compiling it first: jacobin Hello.java arg1 arg2. The file is
+// compiled with javac, which is found in JAVA_HOME's bin directory or else on the PATH,
+// into a temporary directory that's removed when the VM shuts down. The directory is put
+// at the front of the class path, so that the other classes compiled from the file are
+// found before any of the same name on the class path. The first top-level class in the
+// file is the main class.
+
+// sourceLaunchDir is the temporary directory holding the compiled classes, if any
+var sourceLaunchDir string
+
+var (
+	// comments, text blocks, and string and char literals, which may hold anything that
+	// looks like a declaration; they're matched together, so a // in a string isn't taken
+	// for a comment, and a quote in a comment isn't taken for the start of a string
+	nonCodeRE = regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*|` + // comments
+		`"""(?:\\.|[^\\])*?"""|"(?:\\.|[^"\\\n])*"|'(?:\\.|[^'\\\n])*'`) // text blocks and literals
+	packageRE  = regexp.MustCompile(`(?m)^\s*package\s+([\w.]+)\s*;`)
+	typeDeclRE = regexp.MustCompile(`\b(?:class|interface|enum|record)\s+([A-Za-z_$][\w$]*)`)
+)
+
+// compileSourceFile compiles the source file and returns the path of the class file
+// of its main class. If the file can't be compiled, the user is told why.
+func compileSourceFile(sourceFile string, gl *globals.Globals) (string, error) {
+	source, err := os.ReadFile(sourceFile)
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0020", sourceFile)
+		return "", err
+	}
+	mainClass, err := sourceMainClass(string(source))
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0021", sourceFile)
+		return "", err
+	}
+
+	javac, err := findJDKTool("javac", gl)
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0022")
+		return "", err
+	}
+
+	sourceLaunchDir, err = os.MkdirTemp("", "jacobin-source")
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command(javac, "-d", sourceLaunchDir, sourceFile)
+	cmd.Stdout = os.Stderr // compiler messages go to stderr, as java's do
+	cmd.Stderr = os.Stderr
+	if err = cmd.Run(); err != nil {
+		_ = messages.Print("JACOBIN-LA-0023")
+		return "", err
+	}
+	classPath := sourceLaunchDir
+	if userPath, _ := globals.SystemProperties.Get("java.class.path"); userPath != "" {
+		classPath += string(os.PathListSeparator) + userPath
+	}
+	globals.SystemProperties.Set("java.class.path", classPath)
+	return filepath.Join(sourceLaunchDir, filepath.FromSlash(mainClass)+".class"), nil
+}
+
+// sourceMainClass returns the internal name (such as com/example/Hello) of the first
+// top-level class declared in the source, outside comments and literals
+func sourceMainClass(source string) (string, error) {
+	source = nonCodeRE.ReplaceAllString(source, " ")
+	decl := typeDeclRE.FindStringSubmatch(source)
+	if decl == nil {
+		return "", errors.New("no class declared in source file")
+	}
+	if pkg := packageRE.FindStringSubmatch(source); pkg != nil {
+		return strings.ReplaceAll(pkg[1], ".", "/") + "/" + decl[1], nil
+	}
+	return decl[1], nil
+}
+
+// findJDKTool returns the path of a JDK tool, such as javac, in JAVA_HOME or, if
+// JAVA_HOME isn't set, on the PATH
+func findJDKTool(tool string, gl *globals.Globals) (string, error) {
+	binary := tool
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+	if gl.JavaHome == "" {
+		return exec.LookPath(binary)
+	}
+	path := filepath.Join(gl.JavaHome, "bin", binary)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// removeSourceLaunchDir removes the classes compiled from a source file, if any
+func removeSourceLaunchDir() {
+	if sourceLaunchDir != "" {
+		_ = os.RemoveAll(sourceLaunchDir)
+	}
+}
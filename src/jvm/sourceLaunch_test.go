@@ -0,0 +1,86 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"io/ioutil"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSourceMainClass(t *testing.T) {
+	tests := map[string]string{
+		"public class Hello { public static void main(String[] a) {} }":                                 "Hello",
+		"package com.example;\n\n// class NotThis\nclass Greeter {}\nclass Other {}":                    "com/example/Greeter",
+		"/* a record Comment */ record Point(int x, int y) {}":                                          "Point",
+		"import a.B;\n@Note(\"class Fake\") class Real { char q = '\"'; String s = \"// class Not\"; }": "Real",
+		"@Doc(\"\"\"\n  enum Fake\n\"\"\"\") interface Shape {}":                                        "Shape",
+	}
+	for source, want := range tests {
+		if got, err := sourceMainClass(source); err != nil || got != want {
+			t.Errorf("Expected main class %s, got %s (error: %v)", want, got, err)
+		}
+	}
+	if _, err := sourceMainClass("// nothing here\n"); err == nil {
+		t.Error("Expected an error for a source file that declares no class")
+	}
+}
+
+func TestSourceFileIsStartingProgram(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	_ = HandleCli([]string{"jacobin", "-client", "Hello.java", "arg1"}, &global)
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if global.StartingSource != "Hello.java" || global.StartingClass != "" {
+		t.Errorf("Expected Hello.java to be the source file to run, got %q", global.StartingSource)
+	}
+	if len(global.AppArgs) != 1 || global.AppArgs[0] != "arg1" {
+		t.Errorf("Expected the args after the source file to be app args, got %v", global.AppArgs)
+	}
+}
+
+// the javac in this test's JAVA_HOME is a script that records its arguments
+func TestCompileSourceFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake javac is a shell script")
+	}
+	home, _ := ioutil.TempDir("", "jacobin-javahome")
+	defer os.RemoveAll(home)
+	_ = os.MkdirAll(filepath.Join(home, "bin"), 0755)
+	script := "#!/bin/sh\nmkdir -p \"$2/demo\" && echo \"$@\" > \"$2/demo/Hello.class\"\n"
+	_ = ioutil.WriteFile(filepath.Join(home, "bin", "javac"), []byte(script), 0755)
+	source := filepath.Join(home, "Hello.java")
+	_ = ioutil.WriteFile(source, []byte("package demo;\npublic class Hello {}\n"), 0644)
+
+	global := globals.InitGlobals("test")
+	global.JavaHome = home + "/"
+	globals.SystemProperties.Set("java.class.path", "lib")
+	defer globals.SystemProperties.Set("java.class.path", ".")
+	classFile, err := compileSourceFile(source, &global)
+	if err != nil {
+		t.Fatalf("Unexpected error compiling: %s", err.Error())
+	}
+	defer removeSourceLaunchDir()
+
+	if classFile != filepath.Join(sourceLaunchDir, "demo", "Hello.class") {
+		t.Errorf("Unexpected class file: %s", classFile)
+	}
+	if args, _ := ioutil.ReadFile(classFile); string(args) != "-d "+sourceLaunchDir+" "+source+"\n" {
+		t.Errorf("Unexpected javac arguments: %s", args)
+	}
+	if classPath, _ := globals.SystemProperties.Get("java.class.path"); classPath !=
+		sourceLaunchDir+string(os.PathListSeparator)+"lib" {
+		t.Errorf("Expected the compiled classes to be first on the class path, got %s", classPath)
+	}
+}
@@ -0,0 +1,365 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"io"
+	"jacobin/globals"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Superinstructions. With -XX:+UseSuperinstructions, the pairs and triples of opcodes
+// the program executes one after the other are counted while it warms up, and then the
+// most frequent of them that have a fused handler, such as aload_0+getfield and
+// iload_1+iload_2+iadd, become superinstructions. A fused handler does the work of all
+// the instructions of a superinstruction at once: iload_1+iload_2+iadd adds the two
+// locals and pushes the sum, without pushing and popping them, and aload_0+getfield
+// reads the field of the object in local 0. When the interpreter comes to the first
+// instruction of a superinstruction, it runs the handler in one step of its loop,
+// without the dispatch and the checks it makes before each instruction (for
+// safepoints, sampling, and the like), so the leading instructions of a superinstruction
+// are ones that always go on to the next one: loads, stores, constants, arithmetic, and
+// field accesses, but not branches, calls, or returns. With -XX:+PrintSuperinstructions,
+// the superinstructions are printed as they're formed.
+//
+// Superinstructions aren't used while tracing, measuring coverage, or debugging, which
+// need to see each instruction. The bytecodes they run are counted as usual.
+
+// useSuperinstructions is whether the sequences are counted and superinstructions
+// formed. It's set before the program starts, as is printSuperinstructions.
+var (
+	useSuperinstructions   bool
+	printSuperinstructions bool
+)
+
+// superinstructionWarmup is the number of instructions counted before the
+// superinstructions are formed, and maxSuperinstructions the number formed
+const (
+	superinstructionWarmup = 1 << 20
+	maxSuperinstructions   = 8
+)
+
+// leadingLength is the length of each opcode that can lead a superinstruction, and 0
+// for the others. leadingIndex numbers them from 1, for the counts of the sequences.
+var (
+	leadingLength  [256]int
+	leadingIndex   [256]int
+	leadingOpcodes []byte
+)
+
+func init() {
+	for length, opcodes := range [][]byte{
+		1: {ACONST_NULL, ICONST_N1, ICONST_0, ICONST_1, ICONST_2, ICONST_3, ICONST_4, ICONST_5,
+			ILOAD_0, ILOAD_1, ILOAD_2, ILOAD_3, LLOAD_0, LLOAD_1, LLOAD_2, LLOAD_3,
+			ALOAD_0, ALOAD_1, ALOAD_2, ALOAD_3, ISTORE_0, ISTORE_1, ISTORE_2, ISTORE_3,
+			ASTORE_0, ASTORE_1, ASTORE_2, ASTORE_3, IALOAD, AALOAD, POP, DUP, IADD, ISUB, IMUL},
+		2: {BIPUSH, LDC, ILOAD, ALOAD, ISTORE, ASTORE},
+		3: {SIPUSH, LDC_W, IINC, GETSTATIC, GETFIELD},
+	} {
+		for _, op := range opcodes {
+			leadingLength[op] = length
+			leadingOpcodes = append(leadingOpcodes, op)
+			leadingIndex[op] = len(leadingOpcodes)
+		}
+	}
+}
+
+// the counts of the sequences, by the leadingIndex of their leading opcodes, less 1
+type pairCounts [][256]uint32
+type tripleCounts [][][256]uint32
+
+var (
+	countingSequences int32 // 1 while the sequences are counted
+	opcodePairs       pairCounts
+	opcodeTriples     tripleCounts
+	opcodesCounted    int64
+)
+
+// opcodeHistory is the last two opcodes a frame executed, as their leadingIndex
+type opcodeHistory struct {
+	beforeLast int
+	last       int
+}
+
+// superinstruction is a sequence of opcodes that runs in one step
+type superinstruction struct {
+	opcodes []byte
+	count   uint32       // the times it was executed while the sequences were counted
+	run     fusedHandler // does the work of the instructions
+}
+
+// fusedHandler runs the instructions of a superinstruction that begins at the frame's
+// pc, and leaves the pc at the last byte of the last of them, as the interpreter
+// leaves it after an instruction
+type fusedHandler func(f *frame) error
+
+// superinstructionTable holds the superinstructions by their first opcode, the
+// longest first, and the superinstructions found in the code of each method, by the pc
+// they begin at, so they're matched once for each method rather than at each instruction
+type superinstructionTable struct {
+	byOpcode [256][]*superinstruction
+	methods  sync.Map // *instruction, the first of the method's decoded code -> []*superinstruction
+}
+
+var superinstructionsFormed atomic.Value // *superinstructionTable
+
+// startSuperinstructions starts counting the sequences if -XX:+UseSuperinstructions
+// was given
+func startSuperinstructions(gl *globals.Globals) {
+	useSuperinstructions = gl.Flags.Bool("UseSuperinstructions")
+	printSuperinstructions = gl.Flags.Bool("PrintSuperinstructions")
+	if useSuperinstructions {
+		resetSequenceCounts()
+	}
+}
+
+// resetSequenceCounts clears the counts, and starts counting the sequences again
+func resetSequenceCounts() {
+	opcodePairs = make(pairCounts, len(leadingOpcodes))
+	opcodeTriples = make(tripleCounts, len(leadingOpcodes))
+	for i := range opcodeTriples {
+		opcodeTriples[i] = make([][256]uint32, len(leadingOpcodes))
+	}
+	atomic.StoreInt64(&opcodesCounted, 0)
+	superinstructionsFormed.Store((*superinstructionTable)(nil))
+	atomic.StoreInt32(&countingSequences, 1)
+}
+
+// countSequence counts the sequences that end with op, the opcode the frame with the
+// history is about to execute. The instructions that lead superinstructions always go
+// on to the next instruction, so the history holds the opcodes just before op.
+func countSequence(h *opcodeHistory, op byte) {
+	if h.last > 0 {
+		atomic.AddUint32(&opcodePairs[h.last-1][op], 1)
+		if h.beforeLast > 0 {
+			atomic.AddUint32(&opcodeTriples[h.beforeLast-1][h.last-1][op], 1)
+		}
+	}
+	h.beforeLast, h.last = h.last, leadingIndex[op]
+
+	if atomic.AddInt64(&opcodesCounted, 1) == superinstructionWarmup {
+		table := formSuperinstructions()
+		if printSuperinstructions {
+			writeSuperinstructions(os.Stdout, table)
+		}
+	}
+}
+
+// formSuperinstructions stops the counting and makes the most frequent sequences
+// superinstructions
+func formSuperinstructions() *superinstructionTable {
+	atomic.StoreInt32(&countingSequences, 0)
+
+	var candidates []*superinstruction
+	candidate := func(opcodes []byte, n uint32) {
+		if run := fusedHandlerFor(opcodes); run != nil && n > 0 {
+			candidates = append(candidates, &superinstruction{opcodes, n, run})
+		}
+	}
+	for i, first := range leadingOpcodes {
+		for op := range opcodePairs[i] {
+			candidate([]byte{first, byte(op)}, atomic.LoadUint32(&opcodePairs[i][op]))
+		}
+		for j, second := range leadingOpcodes {
+			for op := range opcodeTriples[i][j] {
+				candidate([]byte{first, second, byte(op)}, atomic.LoadUint32(&opcodeTriples[i][j][op]))
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		if len(a.opcodes) != len(b.opcodes) {
+			return len(a.opcodes) > len(b.opcodes)
+		}
+		return string(a.opcodes) < string(b.opcodes)
+	})
+	if len(candidates) > maxSuperinstructions {
+		candidates = candidates[:maxSuperinstructions]
+	}
+
+	table := new(superinstructionTable)
+	for _, s := range candidates {
+		table.byOpcode[s.opcodes[0]] = append(table.byOpcode[s.opcodes[0]], s)
+	}
+	for _, ss := range table.byOpcode {
+		sort.SliceStable(ss, func(i, j int) bool { return len(ss[i].opcodes) > len(ss[j].opcodes) })
+	}
+	superinstructionsFormed.Store(table)
+	return table
+}
+
+// superinstructionsFor returns the superinstructions in the frame's code, by the pc
+// they begin at, which is nil if none have been formed, or if its instructions are
+// traced, covered, or debugged
+func superinstructionsFor(f *frame, t *execThread) []*superinstruction {
+	if !useSuperinstructions || t.trace || f.coverage != nil || debugger != nil || len(f.code) == 0 {
+		return nil
+	}
+	table, _ := superinstructionsFormed.Load().(*superinstructionTable)
+	if table == nil {
+		return nil
+	}
+	if found, present := table.methods.Load(&f.code[0]); present {
+		return found.([]*superinstruction)
+	}
+	at := make([]*superinstruction, len(f.meth))
+	for pc := 0; pc < len(f.meth); {
+		at[pc] = table.match(f.meth, pc)
+		length := instructionLength(f.meth, pc)
+		if length <= 0 {
+			break
+		}
+		pc += length
+	}
+	found, _ := table.methods.LoadOrStore(&f.code[0], at)
+	return found.([]*superinstruction)
+}
+
+// match returns the superinstruction that begins at the pc of the code, or nil if none
+// does
+func (table *superinstructionTable) match(code []byte, at int) *superinstruction {
+	for _, s := range table.byOpcode[code[at]] {
+		pc, matched := at, true
+		for _, op := range s.opcodes {
+			if pc >= len(code) || code[pc] != op {
+				matched = false
+				break
+			}
+			pc += leadingLength[op]
+		}
+		if matched {
+			return s
+		}
+	}
+	return nil
+}
+
+// fusedHandlerFor returns the fused handler of the sequence of opcodes, or nil if
+// there's none. These are the sequences that handlers are written for, in which a
+// value is an int constant or a load of a local:
+//
+//	value value iadd|isub|imul    the locals or constants are added, etc.
+//	value iadd|isub|imul          the value is added to the top of the stack, etc.
+//	value istore                  the value is stored in a local
+//	aload getfield                the field of the object in the local is pushed
+func fusedHandlerFor(opcodes []byte) fusedHandler {
+	switch {
+	case len(opcodes) == 3 && isIntValue(opcodes[0]) && isIntValue(opcodes[1]) && isIntArith(opcodes[2]):
+		return fusedArith
+	case len(opcodes) != 2:
+		return nil
+	case isIntValue(opcodes[0]) && isIntArith(opcodes[1]):
+		return fusedArithOnStack
+	case isIntValue(opcodes[0]) && (opcodes[1] == ISTORE || opcodes[1] >= ISTORE_0 && opcodes[1] <= ISTORE_3):
+		return fusedStore
+	case (opcodes[0] == ALOAD || opcodes[0] >= ALOAD_0 && opcodes[0] <= ALOAD_3) && opcodes[1] == GETFIELD:
+		return fusedGetField
+	}
+	return nil
+}
+
+// isIntValue reports whether the opcode pushes an int constant or the int in a local
+func isIntValue(op byte) bool {
+	return op >= ICONST_N1 && op <= ICONST_5 || op == BIPUSH || op == SIPUSH ||
+		op == ILOAD || op >= ILOAD_0 && op <= ILOAD_3
+}
+
+// isIntArith reports whether the opcode is arithmetic that a fused handler does
+func isIntArith(op byte) bool {
+	return op == IADD || op == ISUB || op == IMUL
+}
+
+// valueAt returns the value that the load or constant at the pc pushes, and the pc of
+// the next instruction
+func valueAt(f *frame, pc int) (int64, int) {
+	switch op := f.meth[pc]; {
+	case op >= ICONST_N1 && op <= ICONST_5:
+		return int64(op) - ICONST_0, pc + 1
+	case op >= ILOAD_0 && op <= ILOAD_3:
+		return f.locals[op-ILOAD_0], pc + 1
+	case op >= ALOAD_0 && op <= ALOAD_3:
+		return f.locals[op-ALOAD_0], pc + 1
+	case op == ILOAD || op == ALOAD:
+		return f.locals[f.code[pc].operand], pc + 2
+	default: // bipush and sipush
+		return int64(f.code[pc].operand), pc + leadingLength[op]
+	}
+}
+
+// arith does the arithmetic of the opcode, as the interpreter does it
+func arith(op byte, i1, i2 int64) int64 {
+	switch op {
+	case IADD:
+		return i1 + i2
+	case ISUB:
+		return i1 - i2
+	default:
+		return i1 * i2
+	}
+}
+
+// fusedArith runs value+value+iadd, and the like
+func fusedArith(f *frame) error {
+	i1, pc := valueAt(f, f.pc)
+	i2, pc := valueAt(f, pc)
+	push(f, arith(f.meth[pc], i1, i2))
+	f.pc = pc
+	return nil
+}
+
+// fusedArithOnStack runs value+iadd, and the like
+func fusedArithOnStack(f *frame) error {
+	i2, pc := valueAt(f, f.pc)
+	f.opStack[f.tos] = arith(f.meth[pc], f.opStack[f.tos], i2)
+	f.pc = pc
+	return nil
+}
+
+// fusedStore runs value+istore
+func fusedStore(f *frame) error {
+	value, pc := valueAt(f, f.pc)
+	if op := f.meth[pc]; op == ISTORE {
+		f.locals[f.code[pc].operand] = value
+		pc++
+	} else {
+		f.locals[op-ISTORE_0] = value
+	}
+	f.pc = pc
+	return nil
+}
+
+// fusedGetField runs aload+getfield, which leaves the pc where getfield does
+func fusedGetField(f *frame) error {
+	ref, pc := valueAt(f, f.pc)
+	f.pc = pc
+	return getField(f, ref)
+}
+
+// writeSuperinstructions writes the superinstructions, the most frequent first
+func writeSuperinstructions(w io.Writer, table *superinstructionTable) {
+	var formed []*superinstruction
+	for _, ss := range table.byOpcode {
+		formed = append(formed, ss...)
+	}
+	sort.SliceStable(formed, func(i, j int) bool { return formed[i].count > formed[j].count })
+
+	_, _ = fmt.Fprintf(w, "Superinstructions formed after %d instructions:\n", superinstructionWarmup)
+	for _, s := range formed {
+		names := make([]string, len(s.opcodes))
+		for i, op := range s.opcodes {
+			names[i] = strings.ToLower(BytecodeNames[op])
+		}
+		_, _ = fmt.Fprintf(w, "  %-32s %d\n", strings.Join(names, "+"), s.count)
+	}
+}
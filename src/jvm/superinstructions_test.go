@@ -0,0 +1,109 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// countSequences counts the opcodes as a frame that executes them one after the other
+func countSequences(times int, opcodes ...byte) {
+	for i := 0; i < times; i++ {
+		var h opcodeHistory
+		for _, op := range opcodes {
+			countSequence(&h, op)
+		}
+	}
+}
+
+func TestFormSuperinstructions(t *testing.T) {
+	resetSequenceCounts()
+	defer superinstructionsFormed.Store((*superinstructionTable)(nil))
+	countSequences(100, ILOAD_1, ILOAD_2, IADD, IRETURN)
+	countSequences(300, ALOAD_0, GETFIELD, ARETURN)
+	countSequences(50, GOTO, NOP) // a branch doesn't lead a superinstruction
+
+	table := formSuperinstructions()
+	var out bytes.Buffer
+	writeSuperinstructions(&out, table)
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(strings.TrimSpace(lines[1]), "aload_0+getfield") ||
+		!strings.HasSuffix(lines[1], " 300") {
+		t.Errorf("Expected aload_0+getfield to be the most frequent superinstruction, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "iload_1+iload_2+iadd ") {
+		t.Errorf("Expected iload_1+iload_2+iadd to be formed, got:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "goto+") {
+		t.Errorf("Expected no superinstruction to begin with goto, got:\n%s", out.String())
+	}
+	if len(table.byOpcode[ILOAD_1]) == 0 || len(table.byOpcode[ILOAD_1][0].opcodes) != 3 {
+		t.Error("Expected the longest superinstruction that begins with iload_1 to be tried first")
+	}
+	if countingSequences != 0 {
+		t.Error("Expected the sequences to no longer be counted")
+	}
+}
+
+func TestRunSuperinstruction(t *testing.T) {
+	table := new(superinstructionTable)
+	for _, opcodes := range [][]byte{{ILOAD_1, ILOAD_2, IADD}, {ILOAD, BIPUSH, IMUL},
+		{ICONST_3, ISUB}, {SIPUSH, ISTORE_3}} {
+		table.byOpcode[opcodes[0]] = append(table.byOpcode[opcodes[0]],
+			&superinstruction{opcodes: opcodes, run: fusedHandlerFor(opcodes)})
+	}
+	superinstructionsFormed.Store(table)
+	useSuperinstructions = true
+	defer func() {
+		useSuperinstructions = false
+		superinstructionsFormed.Store((*superinstructionTable)(nil))
+	}()
+
+	// locals[3] = 300; ((40 + 2) - 3) + 40 * -2 - 40, with 40 and 2 in locals 1 and 2
+	f := newFrame(SIPUSH)
+	f.meth = append(f.meth, 0x01, 0x2C, ISTORE_3, ILOAD_1, ILOAD_2, IADD, ICONST_3, ISUB,
+		ILOAD, 1, BIPUSH, 0xFE, IMUL, IADD, ILOAD_1, ISUB)
+	f.locals = append(f.locals, 0, 40, 2, 0)
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	beginInvocation(&f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if f.tos != 0 || pop(&f) != -81 {
+		t.Errorf("Expected ((40 + 2) - 3) + 40 * -2 - 40 on the stack, got a tos of %d", f.tos)
+	}
+	if f.locals[3] != 300 {
+		t.Errorf("Expected 300 to be stored in local 3, got %d", f.locals[3])
+	}
+	if f.stats.bytecodes != 13 {
+		t.Errorf("Expected the 13 bytecodes to be counted, got %d", f.stats.bytecodes)
+	}
+	if table.match([]byte{ILOAD_1, ILOAD_2, ISUB}, 0) != nil {
+		t.Error("Expected iload_1+iload_2+isub not to match iload_1+iload_2+iadd")
+	}
+}
+
+func TestFusedHandlers(t *testing.T) {
+	for _, opcodes := range [][]byte{{ILOAD_1, ILOAD_2}, {ALOAD_0, ALOAD_1, IADD},
+		{LLOAD_0, LLOAD_1, IADD}, {ISTORE_1, GETFIELD}, {IADD, ISUB, IMUL}} {
+		if fusedHandlerFor(opcodes) != nil {
+			t.Errorf("Expected no fused handler for %v", opcodes)
+		}
+	}
+
+	// aload_0+getfield of a method reference fails as getfield does
+	f := newFrame(ALOAD_0)
+	f.meth = append(f.meth, GETFIELD, 0, 1)
+	f.cp = testCP("test/Fused.run()V")
+	f.code, _ = decode(f.meth, f.cp)
+	f.locals = append(f.locals, 0)
+	if err := fusedGetField(&f); err == nil || f.pc != 3 {
+		t.Errorf("Expected getfield of a method reference to fail at pc 3, got %v at %d", err, f.pc)
+	}
+}
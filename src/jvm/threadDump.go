@@ -0,0 +1,222 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The thread-dump facility: formats the state and stack trace of live threads in
+// a format modeled on the one used by jstack and Ctrl-Break in the JDK.
+//
+// A thread's stack (and the pc of its frames) can change while another goroutine
+// looks at it, so, as with the sampler, the stack is read only when it's known to
+// be still. A thread that's blocked on a monitor or running a native method can't
+// change its Java frames, so it marks its stack as parked while it does, and the
+// dump reads a parked stack directly. A thread that's running Java code is asked
+// for its stack instead, which it sends before it executes its next instruction.
+// A thread that does neither in time (one that is just starting or ending, say) is
+// shown without its stack.
+
+// stackWait is how long a dump waits for the threads to send their stacks
+const stackWait = 500 * time.Millisecond
+
+// stackReading is set in a thread's stackState while a dump reads its parked stack.
+// The rest of stackState counts the nested calls to parkStack().
+const stackReading = 1 << 30
+
+var (
+	stacksRequested int32      // the threads asked for their stacks; accessed via sync/atomic
+	stackDumpMutex  sync.Mutex // one dump asks for the stacks at a time
+)
+
+// parkStack marks the thread's stack as one that won't change until unparkStack()
+// is called. It must be called by the thread itself, as it blocks or calls Go code.
+func parkStack(t *execThread) {
+	atomic.AddInt32(&t.stackState, 1)
+}
+
+// unparkStack undoes parkStack(), once any dump that's reading the stack is done
+func unparkStack(t *execThread) {
+	for {
+		state := atomic.LoadInt32(&t.stackState)
+		if state&stackReading == 0 && atomic.CompareAndSwapInt32(&t.stackState, state, state-1) {
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+// readParkedStack returns the thread's stack if it's parked
+func readParkedStack(t *execThread) (string, bool) {
+	state := atomic.LoadInt32(&t.stackState)
+	if state <= 0 || state&stackReading != 0 ||
+		!atomic.CompareAndSwapInt32(&t.stackState, state, state|stackReading) {
+		return "", false
+	}
+	stack := formatStack(t.stack)
+	atomic.AddInt32(&t.stackState, -stackReading)
+	return stack, true
+}
+
+// answerStackRequest sends the thread's stack, if a dump asked for it. It's called
+// by the thread itself, before it executes an instruction.
+func answerStackRequest(t *execThread) {
+	if atomic.CompareAndSwapInt32(&t.stackRequested, 1, 0) {
+		t.stackReply <- formatStack(t.stack)
+	}
+}
+
+// cancelStackRequest withdraws the request for the thread's stack. If the thread has
+// already taken it, the stack it sends is returned.
+func cancelStackRequest(t *execThread) (string, bool) {
+	defer atomic.AddInt32(&stacksRequested, -1)
+	if atomic.CompareAndSwapInt32(&t.stackRequested, 1, 0) {
+		return "", false
+	}
+	return <-t.stackReply, true
+}
+
+// threadStacks returns the stack trace of each of the threads, reading the parked
+// stacks and asking the other threads for theirs
+func threadStacks(ts []*execThread) []string {
+	stackDumpMutex.Lock()
+	defer stackDumpMutex.Unlock()
+
+	stacks := make([]string, len(ts))
+	current := currentThreadID()
+	var asked []int // the indexes of the threads asked for their stacks
+	for i, t := range ts {
+		if t.id == current { // the dump is being run by the thread itself (as after a crash)
+			stacks[i] = formatStack(t.stack)
+		} else if stack, parked := readParkedStack(t); parked {
+			stacks[i] = stack
+		} else {
+			atomic.AddInt32(&stacksRequested, 1)
+			atomic.StoreInt32(&t.stackRequested, 1)
+			asked = append(asked, i)
+		}
+	}
+
+	// a thread that's asked may park before it reaches an instruction, so the parked
+	// stacks are checked as well
+	deadline := time.Now().Add(stackWait)
+	for len(asked) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+		waiting := asked[:0]
+		for _, i := range asked {
+			t := ts[i]
+			select {
+			case stacks[i] = <-t.stackReply:
+				atomic.AddInt32(&stacksRequested, -1)
+				continue
+			default:
+			}
+			if stack, parked := readParkedStack(t); parked {
+				stacks[i] = stack
+				if sent, ok := cancelStackRequest(t); ok {
+					stacks[i] = sent
+				}
+				continue
+			}
+			waiting = append(waiting, i)
+		}
+		asked = waiting
+	}
+	for _, i := range asked {
+		if stack, ok := cancelStackRequest(ts[i]); ok {
+			stacks[i] = stack
+		} else {
+			stacks[i] = "\t(the stack isn't available: the thread didn't reach a safepoint)\n"
+		}
+	}
+	return stacks
+}
+
+// formatStack returns the frames of the stack, from the top
+func formatStack(fs *list.List) string {
+	var sb strings.Builder
+	if fs != nil {
+		for e := fs.Front(); e != nil; e = e.Next() {
+			fr := e.Value.(*frame)
+			fmt.Fprintf(&sb, "\tat %s.%s (pc: %d)\n",
+				strings.ReplaceAll(fr.clName, "/", "."), fr.methName, fr.pc)
+		}
+	}
+	return sb.String()
+}
+
+// threadState returns the thread's state, as named by java.lang.Thread.State
+func threadState(t *execThread) string {
+	if t.blockedOn != 0 {
+		return "BLOCKED"
+	}
+	return "RUNNABLE"
+}
+
+// formatThread returns the header line and the stack trace (from threadStacks()) for a
+// single thread. threadsMutex must be held.
+func formatThread(t *execThread, stack string) string {
+	var sb strings.Builder
+	daemon := ""
+	if t.daemon {
+		daemon = " daemon"
+	}
+	state := threadState(t)
+	if t.blockedOn != 0 {
+		state += fmt.Sprintf(" (on object monitor 0x%x)", t.blockedOn)
+	}
+	fmt.Fprintf(&sb, "\"Thread-%d\" #%d%s\n", t.id, t.id, daemon)
+	fmt.Fprintf(&sb, "   java.lang.Thread.State: %s\n", state)
+	sb.WriteString(stack)
+	return sb.String()
+}
+
+// dumpThreads writes the stack trace of every live thread to w, in order of thread ID
+func dumpThreads(w io.Writer) {
+	threadsMutex.Lock()
+	var ids []int
+	for id := range threads {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	ts := make([]*execThread, len(ids))
+	for i, id := range ids {
+		ts[i] = threads[id]
+	}
+	threadsMutex.Unlock()
+
+	// the stacks are collected without threadsMutex, which a thread takes as it blocks
+	stacks := threadStacks(ts)
+	threadsMutex.Lock()
+	var dumps []string
+	for i, t := range ts {
+		dumps = append(dumps, formatThread(t, stacks[i]))
+	}
+	threadsMutex.Unlock()
+
+	fmt.Fprintln(w, "Full thread dump Jacobin VM:")
+	for _, d := range dumps {
+		fmt.Fprintln(w)
+		fmt.Fprint(w, d)
+	}
+
+	// as with HotSpot, the deadlocks among the threads follow them
+	var deadlocks strings.Builder
+	if reportDeadlocks(&deadlocks) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprint(w, deadlocks.String())
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestThreadStacks(t *testing.T) {
+	running := CreateThread(160)
+	parked := CreateThread(161)
+	stuck := CreateThread(162)
+	for _, th := range []*execThread{&running, &parked, &stuck} {
+		f := createFrame(2)
+		f.clName = "test/Dump"
+		f.methName = "run"
+		_ = pushFrame(th.stack, f)
+	}
+	parkStack(&parked)
+	defer unparkStack(&parked)
+
+	// the running thread changes its frame and checks for a request, as the
+	// interpreter does before each instruction
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		f := running.stack.Front().Value.(*frame)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			f.pc = (f.pc + 1) % 100
+			if atomic.LoadInt32(&stacksRequested) != 0 {
+				answerStackRequest(&running)
+			}
+		}
+	}()
+
+	stacks := threadStacks([]*execThread{&running, &parked, &stuck})
+	if !strings.HasPrefix(stacks[0], "\tat test.Dump.run (pc: ") {
+		t.Errorf("Expected the running thread to send its stack, got: %q", stacks[0])
+	}
+	if stacks[1] != "\tat test.Dump.run (pc: 0)\n" {
+		t.Errorf("Expected the parked thread's stack to be read, got: %q", stacks[1])
+	}
+	if !strings.Contains(stacks[2], "the stack isn't available") {
+		t.Errorf("Expected no stack for a thread that doesn't answer, got: %q", stacks[2])
+	}
+	if atomic.LoadInt32(&stacksRequested) != 0 || atomic.LoadInt32(&stuck.stackRequested) != 0 {
+		t.Errorf("Expected the requests to be withdrawn")
+	}
+}
@@ -0,0 +1,103 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"jacobin/classloader"
+)
+
+// Support for java.lang.ThreadLocal and java.lang.InheritableThreadLocal. In the JDK,
+// each Thread holds two ThreadLocalMaps (threadLocals and inheritableThreadLocals) that
+// are keyed by the ThreadLocal object. Jacobin keeps the equivalent maps in execThread,
+// keyed by the reference to the ThreadLocal object, with the value being the reference
+// (or primitive value) that was stored. Because a map belongs to a single thread, and
+// only that thread reads or writes it, no locking is needed.
+
+// isInheritable reports whether the thread local is an InheritableThreadLocal
+func isInheritable(local int64) bool {
+	obj := classloader.GetObject(local)
+	return obj != nil && classloader.IsSubclassOf(obj.Klass, "java/lang/InheritableThreadLocal")
+}
+
+// localsMap returns the map that holds values for the given kind of thread local
+func localsMap(t *execThread, inheritable bool) map[int64]int64 {
+	if inheritable {
+		return t.inheritableLocals
+	}
+	return t.threadLocals
+}
+
+// threadLocalGet returns the value of the thread local in the current thread, and
+// whether a value was set. If not, the caller runs ThreadLocal.initialValue() and
+// stores the result via threadLocalSet(), just as ThreadLocal.get() does in the JDK.
+func threadLocalGet(t *execThread, key int64, inheritable bool) (int64, bool) {
+	val, present := localsMap(t, inheritable)[key]
+	return val, present
+}
+
+// getThreadLocal implements classloader.ThreadLocalGet for ThreadLocal.get() in the
+// current thread
+func getThreadLocal(local int64) (int64, error) {
+	t := currentThread()
+	inheritable := isInheritable(local)
+	if val, present := threadLocalGet(t, local, inheritable); present {
+		return val, nil
+	}
+	obj := classloader.GetObject(local)
+	if obj == nil {
+		return 0, errors.New("java.lang.NullPointerException")
+	}
+	// initialValue() is looked up in the class of the thread local, as invokevirtual does
+	_, declarer, err := classloader.FetchVirtualMethod(obj.Klass,
+		classloader.SymbolFor("initialValue", "()Ljava/lang/Object;"))
+	if err != nil {
+		return 0, err
+	}
+	val, err := invokeMethod(declarer, "initialValue", "()Ljava/lang/Object;", []int64{local})
+	if err != nil {
+		return 0, err
+	}
+	threadLocalSet(t, local, val, inheritable)
+	return val, nil
+}
+
+// setThreadLocal and removeThreadLocal implement classloader.ThreadLocalSet and
+// ThreadLocalRemove for ThreadLocal.set() and remove() in the current thread
+func setThreadLocal(local, value int64) {
+	threadLocalSet(currentThread(), local, value, isInheritable(local))
+}
+
+func removeThreadLocal(local int64) {
+	threadLocalRemove(currentThread(), local, isInheritable(local))
+}
+
+// threadLocalSet implements ThreadLocal.set()
+func threadLocalSet(t *execThread, key int64, value int64, inheritable bool) {
+	localsMap(t, inheritable)[key] = value
+}
+
+// threadLocalRemove implements ThreadLocal.remove()
+func threadLocalRemove(t *execThread, key int64, inheritable bool) {
+	delete(localsMap(t, inheritable), key)
+}
+
+// CreateChildThread creates a thread the way new Thread() does when called from the
+// parent thread: the child inherits the parent's daemon status, priority, and a copy of its
+// InheritableThreadLocal values. (Java's InheritableThreadLocal.childValue() is the
+// identity function by default, so the values are copied as is.) Regular ThreadLocal
+// values are never inherited.
+func CreateChildThread(parent *execThread, threadNum int) execThread {
+	t := CreateThread(threadNum)
+	t.daemon = parent.daemon
+	t.priority = getPriority(parent)
+	t.trace = parent.trace
+	for k, v := range parent.inheritableLocals {
+		t.inheritableLocals[k] = v
+	}
+	return t
+}
@@ -0,0 +1,108 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"testing"
+)
+
+func TestThreadLocalSetGetRemove(t *testing.T) {
+	th := CreateThread(120)
+	if _, present := threadLocalGet(&th, 0x100, false); present {
+		t.Errorf("Expected no value for unset thread local")
+	}
+
+	threadLocalSet(&th, 0x100, 42, false)
+	val, present := threadLocalGet(&th, 0x100, false)
+	if !present || val != 42 {
+		t.Errorf("Expected thread local value of 42, got: %d (present: %t)", val, present)
+	}
+
+	threadLocalRemove(&th, 0x100, false)
+	if _, present := threadLocalGet(&th, 0x100, false); present {
+		t.Errorf("Expected no value for removed thread local")
+	}
+}
+
+// thread local values are per thread
+func TestThreadLocalsAreNotShared(t *testing.T) {
+	th1 := CreateThread(121)
+	th2 := CreateThread(122)
+	threadLocalSet(&th1, 0x200, 1, false)
+	if _, present := threadLocalGet(&th2, 0x200, false); present {
+		t.Errorf("Thread local set in one thread was visible in another")
+	}
+}
+
+// child threads inherit InheritableThreadLocal values, but not ThreadLocal values
+func TestInheritableThreadLocals(t *testing.T) {
+	parent := CreateThread(123)
+	threadLocalSet(&parent, 0x300, 7, true)
+	threadLocalSet(&parent, 0x301, 8, false)
+	parent.daemon = true
+
+	child := CreateChildThread(&parent, 124)
+	if val, present := threadLocalGet(&child, 0x300, true); !present || val != 7 {
+		t.Errorf("Expected inherited value of 7, got: %d (present: %t)", val, present)
+	}
+	if _, present := threadLocalGet(&child, 0x301, false); present {
+		t.Errorf("Non-inheritable thread local was inherited by child thread")
+	}
+	if !child.daemon {
+		t.Errorf("Expected child of daemon thread to be a daemon")
+	}
+
+	// changes in the child don't affect the parent
+	threadLocalSet(&child, 0x300, 9, true)
+	if val, _ := threadLocalGet(&parent, 0x300, true); val != 7 {
+		t.Errorf("Change to child's inherited value changed parent's value to: %d", val)
+	}
+}
+
+// get(), set(), and remove() of a ThreadLocal subclass, called by the interpreter
+func TestThreadLocalNatives(t *testing.T) {
+	class := "test/Counter"
+	data := &classloader.ClData{Name: class, Superclass: "java/lang/ThreadLocal"}
+	data.CP.Utf8Refs = []string{"initialValue", "()Ljava/lang/Object;"}
+	data.Methods = []classloader.Method{{AccessFlags: 0x0001, Name: 0, Desc: 1, // returns this
+		CodeAttr: classloader.CodeAttrib{MaxStack: 1, MaxLocals: 1, Code: []byte{ALOAD_0, ARETURN}}}}
+	classloader.Classes[class] = classloader.Klass{Status: 'L', Loader: "app", Data: data}
+	defer delete(classloader.Classes, class)
+	classloader.MTable = make(classloader.MT)
+	classloader.MTableLoadNatives()
+	classloader.ThreadLocalGet = getThreadLocal
+	classloader.ThreadLocalSet = setThreadLocal
+	classloader.ThreadLocalRemove = removeThreadLocal
+
+	th := CreateThread(newThreadID())
+	registerThread(&th)
+	defer threadEnded(&th)
+	goroutine := goroutineID()
+	goroutineThreads.Store(goroutine, th.id)
+	defer goroutineThreads.Delete(goroutine)
+
+	// get(); set(value); get(); remove(); get()
+	counter, value := classloader.NewObject(class, 0), classloader.NewStringObject("set")
+	f := newFrame(ALOAD_0)
+	f.meth = append(f.meth, INVOKEVIRTUAL, 0, 1, ALOAD_0, ALOAD_1, INVOKEVIRTUAL, 0, 2,
+		ALOAD_0, INVOKEVIRTUAL, 0, 1, ALOAD_0, INVOKEVIRTUAL, 0, 3, ALOAD_0, INVOKEVIRTUAL, 0, 1)
+	f.cp = testCP(class+".get()Ljava/lang/Object;", class+".set(Ljava/lang/Object;)V", class+".remove()V")
+	f.locals = append(f.locals, counter, value)
+	f.thread = th.id
+	fs := createFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if f.tos != 2 || pop(&f) != counter || pop(&f) != value || pop(&f) != counter {
+		t.Errorf("Expected the initial value, the value set, and the initial value again")
+	}
+	if val, present := threadLocalGet(&th, counter, false); !present || val != counter {
+		t.Errorf("Expected get() to set the initial value, got: %d (present: %t)", val, present)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// Thread priorities and Thread.yield(). Java threads run on goroutines, and the Go
+// scheduler has no notion of priority, so priorities can only be hints. The mapping:
+//
+//  * setPriority() accepts any value from MIN_PRIORITY (1) to MAX_PRIORITY (10), and
+//    getPriority() returns whatever was set, so code that uses priorities runs unchanged.
+//    As with the daemon status, a thread's priority is protected by threadsMutex.
+//  * Thread.yield() calls runtime.Gosched(), which gives other goroutines a chance to run.
+//  * If priorities are used as hints (-XX:+UseThreadPriorities, the default), a thread
+//    below NORM_PRIORITY yields once more for each level it is below NORM_PRIORITY, so
+//    that on a yield, lower-priority threads give up more time than others do.
+//    With -XX:-UseThreadPriorities, priorities are accepted but otherwise ignored.
+
+const (
+	MIN_PRIORITY  = 1
+	NORM_PRIORITY = 5
+	MAX_PRIORITY  = 10
+)
+
+// setPriority implements Thread.setPriority()
+func setPriority(t *execThread, priority int) error {
+	if priority < MIN_PRIORITY || priority > MAX_PRIORITY {
+		return errors.New("java.lang.IllegalArgumentException: invalid thread priority")
+	}
+	threadsMutex.Lock()
+	t.priority = priority
+	threadsMutex.Unlock()
+	return nil
+}
+
+// getPriority implements Thread.getPriority()
+func getPriority(t *execThread) int {
+	threadsMutex.Lock()
+	defer threadsMutex.Unlock()
+	return t.priority
+}
+
+// The priorities set by Thread.setPriority() on Thread objects that haven't been
+// started yet, which the thread gets when it's started
+var pendingPriorities sync.Map // int64 -> int
+
+// setThreadObjectPriority implements classloader.SetThreadPriority for the native
+// Thread.setPriority0(), which Thread.setPriority() calls
+func setThreadObjectPriority(thread int64, priority int32) error {
+	if t := threadOfObject(thread); t != nil {
+		return setPriority(t, int(priority))
+	}
+	if priority < MIN_PRIORITY || priority > MAX_PRIORITY {
+		return errors.New("java.lang.IllegalArgumentException: invalid thread priority")
+	}
+	pendingPriorities.Store(thread, int(priority))
+	return nil
+}
+
+// threadObjectPriority implements classloader.ThreadPriority for Thread.getPriority().
+// As with the daemon status, a thread whose priority hasn't been set has that of the
+// thread that creates it, which is taken to be the current thread.
+func threadObjectPriority(thread int64) int32 {
+	t := threadOfObject(thread)
+	if t == nil {
+		if priority, ok := pendingPriorities.Load(thread); ok {
+			return int32(priority.(int))
+		}
+		t = currentThread()
+	}
+	return int32(getPriority(t))
+}
+
+// yieldCount returns the number of times a thread of the given priority gives up the
+// processor when it calls Thread.yield(). See the mapping described above.
+func yieldCount(priority int, usePriorities bool) int {
+	if !usePriorities || priority >= NORM_PRIORITY {
+		return 1
+	}
+	return 1 + NORM_PRIORITY - priority
+}
+
+// threadYield implements Thread.yield()
+func threadYield(t *execThread) {
+	for i := yieldCount(getPriority(t), Global.Flags.Bool("UseThreadPriorities")); i > 0; i-- {
+		runtime.Gosched()
+	}
+}
+
+// yieldCurrentThread implements classloader.YieldThread for Thread.yield()
+func yieldCurrentThread() {
+	threadYield(currentThread())
+}
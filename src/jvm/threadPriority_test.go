@@ -0,0 +1,103 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/globals"
+	"testing"
+)
+
+func TestSetAndGetPriority(t *testing.T) {
+	th := CreateThread(160)
+	if getPriority(&th) != NORM_PRIORITY {
+		t.Errorf("Expected new thread to have NORM_PRIORITY, got: %d", getPriority(&th))
+	}
+	if setPriority(&th, MAX_PRIORITY) != nil || getPriority(&th) != MAX_PRIORITY {
+		t.Errorf("Expected priority of MAX_PRIORITY, got: %d", getPriority(&th))
+	}
+	if setPriority(&th, 0) == nil || setPriority(&th, 11) == nil {
+		t.Errorf("Expected IllegalArgumentException on out-of-range priority")
+	}
+	if getPriority(&th) != MAX_PRIORITY {
+		t.Errorf("Invalid priority changed the thread's priority to: %d", getPriority(&th))
+	}
+
+	child := CreateChildThread(&th, 161)
+	if getPriority(&child) != MAX_PRIORITY {
+		t.Errorf("Expected child thread to inherit priority, got: %d", getPriority(&child))
+	}
+}
+
+// Thread.setPriority() calls setPriority0(), and Thread.getPriority() is a native
+func TestSetPriority0AndYieldNatives(t *testing.T) {
+	Global = globals.InitGlobals("test")
+	classloader.Load_Lang_Thread()
+	classloader.SetThreadPriority = setThreadObjectPriority
+	classloader.ThreadPriority = threadObjectPriority
+	classloader.YieldThread = yieldCurrentThread
+	setPriority0, _ := classloader.LookupNative("java/lang/Thread.setPriority0(I)V")
+	getPriority0, _ := classloader.LookupNative("java/lang/Thread.getPriority()I")
+	yield, _ := classloader.LookupNative("java/lang/Thread.yield()V")
+
+	th := CreateThread(162)
+	thread := classloader.NewThreadObject("Thread-162")
+	tieThreadObject(thread, &th)
+	if ret := setPriority0.GFunction([]interface{}{thread, int64(MIN_PRIORITY)}); ret != nil {
+		t.Errorf("Unexpected exception from setPriority0(): %v", ret)
+	}
+	if getPriority(&th) != MIN_PRIORITY {
+		t.Errorf("Expected setPriority0() to set MIN_PRIORITY, got: %d", getPriority(&th))
+	}
+	if _, ok := setPriority0.GFunction([]interface{}{thread, int64(11)}).(error); !ok {
+		t.Errorf("Expected IllegalArgumentException on out-of-range priority")
+	}
+	unstarted := classloader.NewObject("java/lang/Thread", 0)
+	if ret := setPriority0.GFunction([]interface{}{unstarted, int64(MAX_PRIORITY)}); ret != nil {
+		t.Errorf("Unexpected exception from setPriority0() of an unstarted thread: %v", ret)
+	}
+	if ret := getPriority0.GFunction([]interface{}{thread}); ret != int64(MIN_PRIORITY) {
+		t.Errorf("Expected getPriority() to return MIN_PRIORITY, got: %v", ret)
+	}
+	if ret := getPriority0.GFunction([]interface{}{unstarted}); ret != int64(MAX_PRIORITY) {
+		t.Errorf("Expected getPriority() of an unstarted thread to return what was set, got: %v", ret)
+	}
+	if _, ok := setPriority0.GFunction([]interface{}{unstarted, int64(0)}).(error); !ok {
+		t.Errorf("Expected IllegalArgumentException on out-of-range priority of an unstarted thread")
+	}
+	if ret := yield.GFunction(nil); ret != nil {
+		t.Errorf("Expected Thread.yield() to return nothing, got: %v", ret)
+	}
+}
+
+func TestYieldCountMapping(t *testing.T) {
+	if yieldCount(MIN_PRIORITY, true) != 5 {
+		t.Errorf("Expected MIN_PRIORITY thread to yield 5 times, got: %d", yieldCount(MIN_PRIORITY, true))
+	}
+	if yieldCount(NORM_PRIORITY, true) != 1 || yieldCount(MAX_PRIORITY, true) != 1 {
+		t.Errorf("Expected threads at or above NORM_PRIORITY to yield once")
+	}
+	if yieldCount(MIN_PRIORITY, false) != 1 {
+		t.Errorf("Expected priorities to be ignored with -XX:-UseThreadPriorities")
+	}
+}
+
+func TestUseThreadPrioritiesOption(t *testing.T) {
+	Global = globals.InitGlobals("test")
+	LoadOptionsTable(Global)
+	args := []string{"jacobin", "-XX:-UseThreadPriorities"}
+	_ = HandleCli(args, &Global)
+	if Global.Flags.Bool("UseThreadPriorities") {
+		t.Errorf("Expected -XX:-UseThreadPriorities to turn off thread priorities")
+	}
+
+	args = []string{"jacobin", "-XX:+UseThreadPriorities"}
+	_ = HandleCli(args, &Global)
+	if !Global.Flags.Bool("UseThreadPriorities") {
+		t.Errorf("Expected -XX:+UseThreadPriorities to turn on thread priorities")
+	}
+}
@@ -0,0 +1,110 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"jacobin/classloader"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Uncaught exceptions. An exception that reaches the top of a thread's stack ends the
+// thread, and is handed to the default UncaughtExceptionHandler, if one was set with
+// Thread.setDefaultUncaughtExceptionHandler(), or else printed, as the JDK does:
+//     Exception in thread "main" java.lang.IllegalMonitorStateException
+//         at com.example.Hello.unlock(Hello.java:12)
+//         at com.example.Hello.main(Hello.java:5)
+// An exception in the main thread makes the exit status 1 (see main.go).
+//
+// In the interpreter, an exception is a Go error whose text begins with the name of
+// the exception's class, as in "java.lang.NullPointerException" or
+// "java.lang.UnsatisfiedLinkError: Hello.f()V". Errors that are the VM's own (invalid
+// bytecode, say) are reported as java.lang.InternalError. An error that wraps another
+// (see errors.Unwrap) has it as its cause. An exception thrown by athrow is a
+// javaException, whose Throwable is the one that was thrown (see exceptions.go).
+
+// exceptionName matches the class name at the start of an error's text
+var exceptionName = regexp.MustCompile(`^[a-zA-Z_$][\w$]*(\.[a-zA-Z_$][\w$]*)+(Exception|Error)\b`)
+
+// uncaughtException reports the exception that ended the thread. The thread's stack
+// still holds the frames the exception passed through.
+func uncaughtException(t *execThread, err error) {
+	exception := throwableFromError(err, stackTrace(t.stack))
+	name := threadName(t)
+	notifyUncaught(t.id, name, err)
+
+	if handler := classloader.DefaultUncaughtExceptionHandler(); handler != 0 {
+		if obj := classloader.GetObject(handler); obj != nil {
+			_, handlerErr := invokeMethod(obj.Klass, "uncaughtException",
+				"(Ljava/lang/Thread;Ljava/lang/Throwable;)V",
+				[]int64{handler, objectOfThread(t), exception})
+			if handlerErr == nil {
+				return
+			}
+			// as in the JDK, an exception in the handler is reported and the original is lost
+			fmt.Fprintf(classloader.SystemErr(), "\nException: %s thrown from the UncaughtExceptionHandler in thread \"%s\"\n",
+				classloader.ThrowableString(throwableFromError(handlerErr, nil)), name)
+			return
+		}
+	}
+	fmt.Fprintf(classloader.SystemErr(), "Exception in thread \"%s\" ", name)
+	classloader.WriteStackTrace(classloader.SystemErr(), exception)
+}
+
+// throwableFromError creates the Throwable for an error, with the stack trace, and
+// the Throwables for the errors it wraps as its causes
+func throwableFromError(err error, trace []string) int64 {
+	if err == nil {
+		return 0
+	}
+	// an error that wraps a javaException is a different exception, whose cause it is
+	if je, ok := err.(*javaException); ok { // the Throwable was thrown by athrow
+		classloader.FillInStackTrace(je.ref, trace)
+		return je.ref
+	}
+	className, message, hasMessage := "java/lang/InternalError", err.Error(), true
+	if name := exceptionName.FindString(message); name != "" {
+		className = strings.ReplaceAll(name, ".", "/")
+		message = strings.TrimPrefix(strings.TrimPrefix(message, name), ":")
+		message = strings.TrimPrefix(message, " ")
+		hasMessage = message != ""
+	}
+	return classloader.NewThrowable(className, &classloader.Throwable{Message: message,
+		HasMessage: hasMessage, Cause: throwableFromError(errors.Unwrap(err), trace),
+		StackTrace: trace})
+}
+
+// stackTrace returns the frames of the stack as Java prints them, from the top down
+func stackTrace(fs *list.List) []string {
+	var trace []string
+	for e := fs.Front(); e != nil; e = e.Next() {
+		f := e.Value.(*frame)
+		class, name, desc := frameMethod(f)
+		location := "Unknown Source"
+		if f.ftype == 'G' {
+			location = "Native Method"
+		} else if file, line := sourceLine(class, name+desc, f.pc); file != "" {
+			location = path.Base(file)
+			if line > 0 {
+				location += fmt.Sprintf(":%d", line)
+			}
+		}
+		trace = append(trace, strings.ReplaceAll(class, "/", ".")+"."+name+"("+location+")")
+	}
+	return trace
+}
+
+// threadName returns the name of the thread, as Thread.getName() would
+func threadName(t *execThread) string {
+	if t.id == MainThread.id {
+		return "main"
+	}
+	return fmt.Sprintf("Thread-%d", t.id)
+}
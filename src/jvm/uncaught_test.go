@@ -0,0 +1,120 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"jacobin/classloader"
+	"os"
+	"testing"
+)
+
+func TestThrowableFromError(t *testing.T) {
+	trace := []string{"Hello.main(Hello.java:5)"}
+	ref := throwableFromError(errors.New("java.lang.IllegalStateException: not started"), trace)
+	if s := classloader.ThrowableString(ref); s != "java.lang.IllegalStateException: not started" {
+		t.Errorf("Expected the exception's class and message, got: %s", s)
+	}
+	if s := classloader.ThrowableString(throwableFromError(errors.New(errNPE), trace)); s != "java.lang.NullPointerException" {
+		t.Errorf("Expected an exception without a message, got: %s", s)
+	}
+	if s := classloader.ThrowableString(throwableFromError(errors.New("invalid bytecode"), nil)); s != "java.lang.InternalError: invalid bytecode" {
+		t.Errorf("Expected the VM's own error to be an InternalError, got: %s", s)
+	}
+
+	wrapped := fmt.Errorf("java.lang.RuntimeException: wrapped: %w", errors.New(errNPE))
+	var out bytes.Buffer
+	classloader.WriteStackTrace(&out, throwableFromError(wrapped, trace))
+	expected := "java.lang.RuntimeException: wrapped: java.lang.NullPointerException\n" +
+		"\tat Hello.main(Hello.java:5)\n" +
+		"Caused by: java.lang.NullPointerException\n" +
+		"\t... 1 more\n"
+	if out.String() != expected {
+		t.Errorf("Expected the wrapped error to be the cause:\n%s\ngot:\n%s", expected, out.String())
+	}
+
+	// a Throwable that was thrown by athrow is the cause of the error that wraps it
+	thrown := &javaException{throwableFromError(errors.New(errNPE), trace)}
+	wrapped = fmt.Errorf("java.lang.reflect.InvocationTargetException: %w", thrown)
+	if class := exceptionClass(wrapped); class != "java/lang/reflect/InvocationTargetException" {
+		t.Errorf("Expected the wrapping exception's class, got: %s", class)
+	}
+	ref = throwableFromError(wrapped, trace)
+	if cause := classloader.GetObject(ref).Native.(*classloader.Throwable).Cause; cause != thrown.ref {
+		t.Errorf("Expected the thrown Throwable to be the cause, got: %d", cause)
+	}
+}
+
+func TestStackTraceFrames(t *testing.T) {
+	fs := createFrameStack()
+	java := createFrame(0)
+	java.clName, java.methName, java.methType, java.ftype = "com/example/Hello", "main", "([Ljava/lang/String;)V", 'J'
+	fs.PushFront(java)
+	native := createFrame(0)
+	native.clName, native.methName, native.ftype = "java/io/PrintStream", "java/io/PrintStream.println(Ljava/lang/String;)V", 'G'
+	fs.PushFront(native)
+
+	trace := stackTrace(fs)
+	if len(trace) != 2 || trace[0] != "java.io.PrintStream.println(Native Method)" ||
+		trace[1] != "com.example.Hello.main(Unknown Source)" {
+		t.Errorf("Expected the native and Java frames, got: %v", trace)
+	}
+}
+
+// captureStderr returns what the function writes to stderr
+func captureStderr(fn func()) string {
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	fn()
+	_ = w.Close()
+	os.Stderr = normalStderr
+	out, _ := ioutil.ReadAll(r)
+	return string(out)
+}
+
+func TestUncaughtExceptionPrinted(t *testing.T) {
+	th := CreateThread(MainThread.id)
+	f := createFrame(0)
+	f.clName, f.methName, f.methType, f.ftype = "Hello", "main", "([Ljava/lang/String;)V", 'J'
+	th.stack.PushFront(f)
+
+	out := captureStderr(func() { uncaughtException(&th, errors.New(errNPE)) })
+	expected := "Exception in thread \"main\" java.lang.NullPointerException\n\tat Hello.main(Unknown Source)\n"
+	if out != expected {
+		t.Errorf("Expected the uncaught exception to be printed as:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestUncaughtExceptionHandler(t *testing.T) {
+	classloader.MTable = make(classloader.MT)
+	classloader.MTableLoadNatives()
+	var threadName, exception string
+	classloader.MTable[classloader.MethodKey("test/Handler.uncaughtException(Ljava/lang/Thread;Ljava/lang/Throwable;)V")] = classloader.MTentry{
+		Meth: classloader.GmEntry{ParamSlots: 3, Fu: func(params []interface{}) interface{} {
+			threadName = classloader.GetObject(params[1].(int64)).Native.(string)
+			exception = classloader.ThrowableString(params[2].(int64))
+			return nil
+		}},
+		MType: 'G',
+	}
+	handler := classloader.NewObject("test/Handler", 0)
+	setHandler := classloader.MTable[classloader.MethodKey("java/lang/Thread.setDefaultUncaughtExceptionHandler(Ljava/lang/Thread$UncaughtExceptionHandler;)V")]
+	setHandler.Meth.(classloader.GmEntry).Fu([]interface{}{handler})
+	defer setHandler.Meth.(classloader.GmEntry).Fu([]interface{}{int64(0)})
+
+	th := CreateThread(4)
+	out := captureStderr(func() { uncaughtException(&th, errors.New("java.lang.IllegalStateException: oops")) })
+	if out != "" {
+		t.Errorf("Expected nothing to be printed when the handler runs, got: %s", out)
+	}
+	if threadName != "Thread-4" || exception != "java.lang.IllegalStateException: oops" {
+		t.Errorf("Expected the handler to get the thread and exception, got: %s, %s", threadName, exception)
+	}
+}
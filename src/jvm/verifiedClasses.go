@@ -0,0 +1,40 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/messages"
+)
+
+// With -XX:VerifiedClassesFile=<file>, the classes that passed the format check in
+// earlier runs aren't checked again (see verifiedClasses.go in package classloader).
+
+var verifiedClassesPath string
+
+// startVerifiedClasses reads the classes verified in earlier runs, if the file was given
+func startVerifiedClasses(gl *globals.Globals) {
+	verifiedClassesPath = gl.Flags.String("VerifiedClassesFile")
+	if verifiedClassesPath == "" {
+		return
+	}
+	if err := classloader.LoadVerifiedClasses(verifiedClassesPath, gl.Version); err != nil {
+		_ = messages.Print("JACOBIN-LA-0062", verifiedClassesPath, err.Error())
+	}
+}
+
+// stopVerifiedClasses writes the classes verified so far for the next run
+func stopVerifiedClasses() {
+	if verifiedClassesPath == "" {
+		return
+	}
+	err := classloader.SaveVerifiedClasses(verifiedClassesPath, globals.GetGlobalRef().Version)
+	if err != nil {
+		_ = messages.Print("JACOBIN-LA-0063", verifiedClassesPath, err.Error())
+	}
+	verifiedClassesPath = ""
+}
@@ -0,0 +1,79 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"io"
+	"jacobin/classloader"
+	"jacobin/globals"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// The exit summary. With -XX:+PrintVMSummary, a few lines on the run are printed at
+// exit, for quick comparisons of one version of Jacobin with another:
+//     Jacobin VM summary:
+//       Wall time:           1.204 s
+//       Classes loaded:      431
+//       Bytecodes executed:  18364211
+//       Threads:             3 started, at most 2 live
+//       Java objects:        120345 allocated (about 4.1 MB)
+//       Go heap:             310.2 MB allocated in total, 12.5 MB live, 40.1 MB from the OS
+//       Go GC:               52 cycles, 3.1 ms paused
+// (Java objects aren't collected yet, so the number allocated is the number live.)
+
+// vmSummary is whether the summary is printed. It's set before the program starts.
+var vmSummary bool
+
+// startVMSummary starts counting the bytecodes if -XX:+PrintVMSummary was given
+func startVMSummary(gl *globals.Globals) {
+	vmSummary = gl.Flags.Bool("PrintVMSummary")
+}
+
+// printVMSummary prints the summary, if it was asked for
+func printVMSummary() {
+	if vmSummary {
+		writeVMSummary(os.Stdout, &Global)
+	}
+}
+
+// writeVMSummary writes the summary of the run so far
+func writeVMSummary(w io.Writer, gl *globals.Globals) {
+	threadsMutex.Lock()
+	bytecodes := endedBytecodes
+	for _, t := range threads {
+		bytecodes += atomic.LoadInt64(&t.bytecodes)
+	}
+	started, peak := threadsStarted, peakThreads
+	threadsMutex.Unlock()
+
+	classloader.MethAreaMutex.RLock()
+	classes := len(classloader.Classes)
+	classloader.MethAreaMutex.RUnlock()
+
+	objects, objectBytes := classloader.HeapUsage()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	fmt.Fprintln(w, "Jacobin VM summary:")
+	fmt.Fprintf(w, "  %-20s %.3f s\n", "Wall time:", time.Since(gl.StartTime).Seconds())
+	fmt.Fprintf(w, "  %-20s %d\n", "Classes loaded:", classes)
+	fmt.Fprintf(w, "  %-20s %d\n", "Bytecodes executed:", bytecodes)
+	fmt.Fprintf(w, "  %-20s %d started, at most %d live\n", "Threads:", started, peak)
+	fmt.Fprintf(w, "  %-20s %d allocated (about %s)\n", "Java objects:", objects, megabytes(uint64(objectBytes)))
+	fmt.Fprintf(w, "  %-20s %s allocated in total, %s live, %s from the OS\n", "Go heap:",
+		megabytes(mem.TotalAlloc), megabytes(mem.HeapAlloc), megabytes(mem.Sys))
+	fmt.Fprintf(w, "  %-20s %d cycles, %.1f ms paused\n", "Go GC:", mem.NumGC,
+		float64(mem.PauseTotalNs)/float64(time.Millisecond))
+}
+
+// megabytes formats the number of bytes in MB
+func megabytes(bytes uint64) string {
+	return fmt.Sprintf("%.1f MB", float64(bytes)/(1024*1024))
+}
@@ -0,0 +1,58 @@
+/* Jacobin VM -- A Java virtual machine
+ * © Copyright 2022 by Andrew Binstock. All rights reserved
+ * Licensed under Mozilla Public License 2.0 (MPL-2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"fmt"
+	"jacobin/globals"
+	"strings"
+	"testing"
+)
+
+func TestVMSummary(t *testing.T) {
+	vmSummary = true
+	defer func() { vmSummary = false }()
+
+	th := CreateThread(newThreadID())
+	f := createFrame(2)
+	f.clName, f.methName, f.methType, f.ftype = "Hello", "run", "()V", 'J'
+	f.thread = th.id
+	f.meth = []byte{ICONST_1, ICONST_2, IADD, POP, RETURN}
+	th.stack.PushFront(f)
+
+	threadsMutex.Lock()
+	before := endedBytecodes
+	threadsMutex.Unlock()
+	registerThread(&th)
+	if err := runThread(&th); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	threadEnded(&th)
+	threadsMutex.Lock()
+	counted := endedBytecodes - before
+	threadsMutex.Unlock()
+	if counted != 5 {
+		t.Errorf("Expected the thread's 5 bytecodes to be counted, got %d", counted)
+	}
+
+	gl := globals.InitGlobals("test")
+	var out bytes.Buffer
+	writeVMSummary(&out, &gl)
+	for _, want := range []string{
+		"Jacobin VM summary:",
+		"Wall time:",
+		"Classes loaded:",
+		fmt.Sprintf("Bytecodes executed:  %d\n", endedBytecodes),
+		"Threads:             ",
+		"Java objects:",
+		"Go GC:",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected the summary to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
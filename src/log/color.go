@@ -0,0 +1,73 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package log
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// Colorized console output. When text-format messages go to a terminal, each is
+// prefixed with its level (or, for channel messages, its channel) in a color that
+// depends on the level: red for SEVERE, yellow for WARNING, and so on, which makes
+// interactive debugging easier. Output that isn't to a terminal, such as a log file
+// or a pipe, is never colored, nor is any output if the NO_COLOR environment variable
+// is set, TERM is dumb, or --no-color was specified.
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiRed     = "\x1b[1;31m"
+	ansiYellow  = "\x1b[33m"
+	ansiCyan    = "\x1b[36m"
+	ansiGreen   = "\x1b[32m"
+	ansiGray    = "\x1b[90m"
+	ansiMagenta = "\x1b[35m"
+	ansiBlue    = "\x1b[34m"
+)
+
+var levelColors = map[int]string{
+	SEVERE: ansiRed, WARNING: ansiYellow, CLASS: ansiCyan, INFO: ansiGreen,
+	FINE: ansiGray, FINEST: ansiGray, TRACE_INST: ansiMagenta,
+}
+
+// is color disabled (by --no-color)? Guarded by mutex.
+var noColor = false
+
+// whether each file written to is a terminal. Guarded by mutex.
+var terminals = make(map[*os.File]bool)
+
+// DisableColor turns off colored output, as --no-color does
+func DisableColor() {
+	mutex.Lock()
+	noColor = true
+	mutex.Unlock()
+}
+
+// colorFor returns whether messages written to out are colored. The caller must
+// hold the mutex.
+func colorFor(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	if !ok || noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	terminal, known := terminals[f]
+	if !known {
+		info, err := f.Stat()
+		terminal = err == nil && info.Mode()&os.ModeCharDevice != 0
+		terminals[f] = terminal
+	}
+	return terminal
+}
+
+// colorPrefix returns the colored level or channel prefix of a message
+func colorPrefix(level int, tag string) string {
+	if _, isChannel := channels[tag]; isChannel {
+		return ansiBlue + strings.ToUpper(tag) + ":" + ansiReset + " "
+	}
+	return levelColors[level] + levelNames[level] + ":" + ansiReset + " "
+}
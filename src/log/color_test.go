@@ -0,0 +1,64 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package log
+
+import (
+	"io/ioutil"
+	"jacobin/globals"
+	"os"
+	"strings"
+	"testing"
+)
+
+// logToTerminal logs a warning to a pipe that the logger is told is a terminal and
+// returns what was written
+func logToTerminal(t *testing.T) string {
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	mutex.Lock()
+	terminals[w] = true
+	mutex.Unlock()
+
+	_ = Log("a warning", WARNING)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+	mutex.Lock()
+	delete(terminals, w)
+	mutex.Unlock()
+	out, _ := ioutil.ReadAll(r)
+	return string(out)
+}
+
+func TestColorOnTerminal(t *testing.T) {
+	globals.InitGlobals("test")
+	Init()
+	_ = os.Unsetenv("NO_COLOR")
+	if os.Getenv("TERM") == "dumb" {
+		t.Skip("colors are off when TERM is dumb")
+	}
+
+	if out := logToTerminal(t); out != ansiYellow+"WARNING:"+ansiReset+" a warning\n" {
+		t.Errorf("Expected a yellow WARNING prefix, got: %q", out)
+	}
+	DisableColor()
+	if out := logToTerminal(t); out != "a warning\n" {
+		t.Errorf("Expected no color with --no-color, got: %q", out)
+	}
+	Init()
+}
+
+func TestNoColorWhenNotTerminal(t *testing.T) {
+	globals.InitGlobals("test")
+	Init()
+	_, w, _ := os.Pipe()
+	defer w.Close()
+	if colorFor(w) || colorFor(&strings.Builder{}) {
+		t.Error("Expected no color for output that isn't to a terminal")
+	}
+}
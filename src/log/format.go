@@ -0,0 +1,70 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Log formats. Messages are logged as plain text by default. In the JSON format
+// (-XX:LogFormat=json), each message is a JSON object on a line of its own, so that
+// log aggregators can ingest the messages directly:
+//     {"timestamp":"2022-06-01T10:15:30.123456Z","level":"FINE","tag":"log","thread":0,"message":"..."}
+// The tag is the message's tag (see output.go), and the thread is the ID of the Java
+// thread that logged the message, or null if it wasn't logged by a Java thread.
+
+// the log formats
+const (
+	TEXT = "text"
+	JSON = "json"
+)
+
+// the current format. Guarded by mutex.
+var format = TEXT
+
+// CurrentThread returns the ID of the Java thread running on the calling goroutine,
+// or -1 if there's none. The interpreter replaces it when it starts.
+var CurrentThread = func() int { return -1 }
+
+var levelNames = map[int]string{
+	SEVERE: "SEVERE", WARNING: "WARNING", CLASS: "CLASS", INFO: "INFO",
+	FINE: "FINE", FINEST: "FINEST", TRACE_INST: "TRACE",
+}
+
+// SetFormat sets the format of the log messages: TEXT or JSON
+func SetFormat(newFormat string) error {
+	if newFormat != TEXT && newFormat != JSON {
+		return errors.New("invalid log format: " + newFormat)
+	}
+	mutex.Lock()
+	format = newFormat
+	mutex.Unlock()
+	return nil
+}
+
+// jsonRecord returns the message as a line of JSON
+func jsonRecord(level int, tag string, msg string) []byte {
+	record := struct {
+		Timestamp string `json:"timestamp"`
+		Level     string `json:"level"`
+		Tag       string `json:"tag"`
+		Thread    *int   `json:"thread"`
+		Message   string `json:"message"`
+	}{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Level:     levelNames[level],
+		Tag:       tag,
+		Message:   msg,
+	}
+	if thread := CurrentThread(); thread >= 0 {
+		record.Thread = &thread
+	}
+	line, _ := json.Marshal(record)
+	return append(line, '\n')
+}
@@ -0,0 +1,60 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"jacobin/globals"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestJSONFormat(t *testing.T) {
+	globals.InitGlobals("test")
+	Init()
+	_ = SetLogLevel(FINE)
+	if SetFormat("xml") == nil {
+		t.Error("Expected an error for an invalid format")
+	}
+	_ = SetFormat(JSON)
+	normalThread := CurrentThread
+	CurrentThread = func() int { return 3 }
+	defer func() { CurrentThread = normalThread }()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	_ = Log(`a "quoted" message`, FINE)
+	_ = EnableChannel(JNI)
+	_ = LogChannel(JNI, "a JNI message")
+	_ = w.Close()
+	os.Stderr = normalStderr
+	out, _ := ioutil.ReadAll(r)
+	Init()
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 JSON records, got: %s", out)
+	}
+	var record struct {
+		Timestamp, Level, Tag, Message string
+		Thread                         *int
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Expected a JSON record, got: %s", lines[0])
+	}
+	if record.Level != "FINE" || record.Tag != LEVELS || record.Message != `a "quoted" message` ||
+		record.Thread == nil || *record.Thread != 3 || record.Timestamp == "" {
+		t.Errorf("Unexpected JSON record: %s", lines[0])
+	}
+	_ = json.Unmarshal([]byte(lines[1]), &record)
+	if record.Tag != JNI || record.Message != "a JNI message" {
+		t.Errorf("Unexpected JSON record for a channel message: %s", lines[1])
+	}
+}
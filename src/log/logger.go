@@ -12,7 +12,6 @@ import (
 	"errors"
 	"fmt"
 	"jacobin/globals"
-	"os"
 	"sync"
 	"time"
 )
@@ -31,6 +30,16 @@ const (
 // Level is the level the logger currently supports. See the enums above.
 var Level int
 
+// Channels are categories of messages that are logged when they're selected, whatever
+// the logging level. Each is selected independently, by -verbose:<channel>.
+const (
+	JNI    = "jni"    // native methods being bound to their implementations
+	MODULE = "module" // modules being resolved. (Jacobin does not yet have modules.)
+)
+
+// the channels, and whether each is selected. Guarded by mutex.
+var channels = map[string]bool{JNI: false, MODULE: false}
+
 // Mutex for protecting the Log function during multithreading.
 var mutex = sync.Mutex{}
 
@@ -42,6 +51,14 @@ var StartTime time.Time
 func Init() {
 	Level = WARNING
 	StartTime = time.Now()
+	mutex.Lock()
+	for channel := range channels {
+		channels[channel] = false
+	}
+	closeOutputs()
+	format = TEXT
+	noColor = false
+	mutex.Unlock()
 }
 
 // Log is the principal logging function. Note that it currently
@@ -56,7 +73,7 @@ func Log(msg string, level int) (err error) {
 	}
 
 	// if the message is a trace and we're not tracing, then return.
-	if level == TRACE_INST && globals.GetGlobalRef().Options["-trace"].Set != true {
+	if level == TRACE_INST && !globals.GetGlobalRef().OptionSet("-trace") {
 		return
 	}
 
@@ -65,22 +82,76 @@ func Log(msg string, level int) (err error) {
 		return
 	}
 
-	// if the message is more low-level than a WARNING,
-	// prefix it with the elapsed time in millisecs.
-	duration := time.Since(StartTime)
-	var millis = duration.Milliseconds()
-
 	// lock the write to the logging stream to prevent overwrite issues
 	// if some other operation is also writing to the stream
-	mutex.Lock()
-	if level > WARNING { // show elapsed time only if messages are finer than warning
-		_, _ = fmt.Fprintf(os.Stderr, "[%3d.%03ds] ", millis/1000, millis%1000)
+	tag := LEVELS
+	if level == TRACE_INST {
+		tag = TRACE
 	}
-	_, _ = fmt.Fprintf(os.Stderr, "%s\n", msg)
+	mutex.Lock()
+	write(level, tag, msg)
 	mutex.Unlock()
 	return
 }
 
+// LogChannel logs the message if its channel has been selected
+func LogChannel(channel string, msg string) (err error) {
+	if len(msg) == 0 {
+		return errors.New("empty logging message")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	selected, present := channels[channel]
+	if !present {
+		return errors.New("invalid logging channel: " + channel)
+	}
+	if selected {
+		write(INFO, channel, msg)
+	}
+	return
+}
+
+// write writes the message to the output for its tag (see output.go) in the current
+// format. In the text format, messages finer than WARNING are prefixed with the elapsed
+// time in millisecs, and messages to a terminal are prefixed with their level in color.
+// The caller must hold the mutex.
+func write(level int, tag string, msg string) {
+	out := outputFor(tag)
+	if format == JSON {
+		_, _ = out.Write(jsonRecord(level, tag, msg))
+		return
+	}
+	if colorFor(out) { // see color.go
+		msg = colorPrefix(level, tag) + msg
+	}
+	if level > WARNING {
+		millis := time.Since(StartTime).Milliseconds()
+		_, _ = fmt.Fprintf(out, "[%3d.%03ds] %s\n", millis/1000, millis%1000, msg)
+	} else {
+		_, _ = fmt.Fprintf(out, "%s\n", msg)
+	}
+}
+
+// EnableChannel selects the channel, so that its messages are logged
+func EnableChannel(channel string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if _, present := channels[channel]; !present {
+		return errors.New("invalid logging channel: " + channel)
+	}
+	channels[channel] = true
+	return nil
+}
+
+// ChannelEnabled returns whether the channel has been selected, so callers can skip
+// building messages that won't be logged
+func ChannelEnabled(channel string) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return channels[channel]
+}
+
 // SetLogLevel seta the level of granularity.
 func SetLogLevel(level int) (err error) {
 	// SEVERE is here just to fill the hierarchy. You cannot actually set the logging
@@ -142,3 +142,39 @@ func TestLoggingMessageAtInvalidLoggingLevel(t *testing.T) {
 		t.Error("logging message at invalid logging level did not generate ane error")
 	}
 }
+
+// channel messages are logged when the channel is selected, whatever the logging level
+func TestLogChannels(t *testing.T) {
+	globals.InitGlobals("test")
+	Init()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_ = LogChannel(JNI, "not selected yet")
+	if err := EnableChannel(JNI); err != nil {
+		t.Errorf("Unexpected error selecting the JNI channel: %s", err.Error())
+	}
+	_ = LogChannel(JNI, "binding a native method")
+	_ = LogChannel(MODULE, "resolving a module")
+	badChannelErr := LogChannel("no-such-channel", "a message")
+
+	_ = w.Close()
+	out, _ := ioutil.ReadAll(r)
+	os.Stderr = normalStderr
+	msg := string(out)
+
+	if !strings.Contains(msg, "binding a native method") || Level != WARNING {
+		t.Errorf("Expected the JNI message to be logged at level WARNING, got: %s", msg)
+	}
+	if strings.Contains(msg, "not selected yet") || strings.Contains(msg, "resolving a module") {
+		t.Errorf("Expected messages of unselected channels not to be logged, got: %s", msg)
+	}
+	if badChannelErr == nil || EnableChannel("no-such-channel") == nil {
+		t.Error("Expected an error for an invalid channel")
+	}
+	if !ChannelEnabled(JNI) || ChannelEnabled(MODULE) {
+		t.Error("Expected only the JNI channel to be selected")
+	}
+}
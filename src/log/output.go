@@ -0,0 +1,134 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Log output. Every message has a tag, which says what kind of message it is: LEVELS
+// for the messages logged by level (SEVERE through FINEST), TRACE for the instruction
+// trace, and the name of its channel for channel messages. By default, all of them go
+// to stderr, but the messages with any tag can be directed to a file instead, which
+// is rotated when it reaches a given size, as -Xlog:...:file=... does. That way, long
+// runs with -verbose:finest or -trace:inst don't flood the console.
+const (
+	LEVELS = "log"
+	TRACE  = "trace"
+)
+
+// Tags returns all the tags
+func Tags() []string {
+	return []string{LEVELS, TRACE, JNI, MODULE}
+}
+
+// the outputs that are not stderr, by tag. Guarded by mutex.
+var outputs = make(map[string]io.Writer)
+
+// outputFor returns where messages with the tag go. The caller must hold the mutex.
+func outputFor(tag string) io.Writer {
+	if out, present := outputs[tag]; present {
+		return out
+	}
+	return os.Stderr // looked up each time, so tests can redirect it
+}
+
+// SetOutput directs the messages with the tags to the writer
+func SetOutput(tags []string, out io.Writer) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, tag := range tags {
+		if tag != LEVELS && tag != TRACE {
+			if _, present := channels[tag]; !present {
+				return errors.New("invalid logging tag: " + tag)
+			}
+		}
+	}
+	for _, tag := range tags {
+		outputs[tag] = out
+	}
+	return nil
+}
+
+// closeOutputs closes the log files and directs all messages to stderr again. The
+// caller must hold the mutex.
+func closeOutputs() {
+	for tag, out := range outputs {
+		if closer, ok := out.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		delete(outputs, tag)
+	}
+}
+
+// RotatingFile is a log file that's rotated when writing a message would make it
+// larger than its maximum size: the file is renamed path.1, after the older files
+// have each been renamed to the next number (path.1 to path.2, and so on), and a new
+// file is started. Only count old files are kept. A maximum size of 0 means the file
+// is never rotated. Each message is written whole to one file.
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	count   int
+	file    *os.File
+	size    int64
+}
+
+// OpenRotatingFile creates (or truncates) the log file
+func OpenRotatingFile(path string, maxSize int64, count int) (*RotatingFile, error) {
+	if maxSize < 0 || count < 0 {
+		return nil, errors.New("invalid log file size or count")
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &RotatingFile{path: path, maxSize: maxSize, count: count, file: file}, nil
+}
+
+// Write writes the message, rotating the file first, if it's full. Writes are
+// serialized by the logger's mutex.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	if rf.file == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *RotatingFile) rotate() error {
+	_ = rf.file.Close()
+	if rf.count > 0 {
+		_ = os.Remove(fmt.Sprintf("%s.%d", rf.path, rf.count))
+		for i := rf.count - 1; i >= 1; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+		}
+		_ = os.Rename(rf.path, rf.path+".1")
+	}
+	file, err := os.Create(rf.path)
+	rf.file, rf.size = file, 0
+	return err
+}
+
+// Close closes the file
+func (rf *RotatingFile) Close() error {
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
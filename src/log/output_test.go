@@ -0,0 +1,78 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package log
+
+import (
+	"io/ioutil"
+	"jacobin/globals"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFileKeepsCountOldFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "jacobin-log")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "vm.log")
+
+	rf, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error opening log file: %s", err.Error())
+	}
+	for _, msg := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := rf.Write([]byte(msg)); err != nil {
+			t.Fatalf("Unexpected error writing log file: %s", err.Error())
+		}
+	}
+	_ = rf.Close()
+
+	want := map[string]string{"vm.log": "fourth\n", "vm.log.1": "third\n", "vm.log.2": "second\n"}
+	for name, content := range want {
+		if got, _ := ioutil.ReadFile(filepath.Join(dir, name)); string(got) != content {
+			t.Errorf("Expected %s to contain %q, got %q", name, content, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "vm.log.3")); err == nil {
+		t.Error("Expected only 2 old log files to be kept")
+	}
+}
+
+func TestOutputByTag(t *testing.T) {
+	globals.InitGlobals("test")
+	Init()
+	_ = SetLogLevel(FINE)
+	dir, _ := ioutil.TempDir("", "jacobin-log")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jni.log")
+	rf, _ := OpenRotatingFile(path, 0, 0)
+	if err := SetOutput([]string{JNI}, rf); err != nil {
+		t.Fatalf("Unexpected error setting output: %s", err.Error())
+	}
+	if SetOutput([]string{"bogus"}, rf) == nil {
+		t.Error("Expected an error for an invalid tag")
+	}
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+	_ = EnableChannel(JNI)
+	_ = LogChannel(JNI, "a JNI message")
+	_ = Log("a FINE message", FINE)
+	_ = w.Close()
+	os.Stderr = normalStderr
+	stderr, _ := ioutil.ReadAll(r)
+	Init() // closes the file
+
+	file, _ := ioutil.ReadFile(path)
+	if !strings.Contains(string(file), "a JNI message") || strings.Contains(string(file), "FINE") {
+		t.Errorf("Expected only the JNI message in the file, got: %s", file)
+	}
+	if !strings.Contains(string(stderr), "a FINE message") || strings.Contains(string(stderr), "JNI") {
+		t.Errorf("Expected only the FINE message on stderr, got: %s", stderr)
+	}
+}
@@ -0,0 +1,527 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+// Package marshal converts between Go values and Java values, so that a program that
+// embeds the VM (see jvm/embed.go) can exchange data with Java code without converting
+// it field by field. Java values are handled as they're kept in operand-stack slots and
+// in fields: an int64 that is either a primitive value or an object reference.
+//
+// The conversions follow the type of the Java value, given by its descriptor:
+//
+//	Z                      bool
+//	B, C, S, I, J          the integer kinds, if the value fits
+//	F, D                   float32 and float64 (and the integer kinds, into Java)
+//	Ljava/lang/String;     string
+//	[...                   slices and Go arrays, element by element
+//	L<class>;              structs and maps with string keys
+//
+// An object's fields are found with the metadata of its class, which is loaded if need
+// be. A struct field is matched with the Java field of the same name, with or without
+// its first letter in lowercase (so that X matches x), unless its tag names the Java
+// field, as in `java:"count"`, or is `java:"-"`, which leaves it out. The entries of a
+// map are matched with the fields by their keys. Java fields that have no Go
+// counterpart are left at zero, and Go values that have no Java field are skipped.
+//
+// Converting to Go, an empty interface gets the natural Go type of the value: the
+// types above, with map[string]interface{} for objects. Nulls convert to and from nil
+// pointers, maps, slices, and interfaces, and a null String converts to "". Every
+// conversion to Java creates new objects, and every conversion to Go copies: changing
+// the result doesn't change the original.
+package marshal
+
+import (
+	"fmt"
+	"jacobin/classloader"
+	"math"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ToJava converts the Go value to a Java value of the type with the descriptor, as in
+// I, Ljava/lang/String;, or [Lcom/example/Point;
+func ToJava(v interface{}, desc string) (int64, error) {
+	return toJava(reflect.ValueOf(v), desc)
+}
+
+// FromJava converts the Java value of the type with the descriptor to the Go value
+// that out points to. For a reference, the type of the object itself is used, which
+// can be a subtype of the declared one.
+func FromJava(slot int64, desc string, out interface{}) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("marshal: FromJava needs a non-nil pointer, not %T", out)
+	}
+	return fromJava(slot, desc, v.Elem())
+}
+
+func isReference(desc string) bool {
+	return desc[0] == 'L' || desc[0] == '['
+}
+
+func toJava(v reflect.Value, desc string) (int64, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			v = reflect.Value{}
+			break
+		}
+		v = v.Elem()
+	}
+	if desc == "" {
+		return 0, fmt.Errorf("marshal: empty descriptor")
+	}
+	if !v.IsValid() || (v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.IsNil() {
+		if isReference(desc) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("marshal: nil can't be converted to %s", desc)
+	}
+
+	switch desc[0] {
+	case 'Z':
+		if v.Kind() != reflect.Bool {
+			break
+		}
+		if v.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	case 'B', 'C', 'S', 'I', 'J':
+		n, ok := integer(v)
+		if !ok {
+			break
+		}
+		if !fits(n, desc[0]) {
+			return 0, fmt.Errorf("marshal: %d overflows %s", n, desc)
+		}
+		return n, nil
+	case 'F', 'D':
+		if f, ok := float(v); ok {
+			return classloader.SlotFromFloat(f), nil
+		}
+	case '[':
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			return arrayToJava(v, desc)
+		}
+	case 'L':
+		className := desc[1 : len(desc)-1]
+		switch {
+		case v.Kind() == reflect.String && (className == "java/lang/String" || className == "java/lang/Object"):
+			return classloader.NewStringObject(v.String()), nil
+		case className == "java/lang/Object":
+			if inferred, ok := descriptorFor(v.Type()); ok && inferred[0] == '[' {
+				return arrayToJava(v, inferred)
+			}
+		case v.Kind() == reflect.Struct || v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+			return objectToJava(v, className)
+		}
+	}
+	return 0, fmt.Errorf("marshal: %s can't be converted to %s", v.Type(), desc)
+}
+
+// integer returns the value of an integer of any kind
+func integer(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v.Uint()), true
+	}
+	return 0, false
+}
+
+// float returns the value of a float or integer of any kind
+func float(v reflect.Value) (float64, bool) {
+	if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+		return v.Float(), true
+	}
+	n, ok := integer(v)
+	return float64(n), ok
+}
+
+// fits reports whether the integer is in the range of the Java integer type
+func fits(n int64, javaType byte) bool {
+	switch javaType {
+	case 'B':
+		return n >= math.MinInt8 && n <= math.MaxInt8
+	case 'C':
+		return n >= 0 && n <= math.MaxUint16
+	case 'S':
+		return n >= math.MinInt16 && n <= math.MaxInt16
+	case 'I':
+		return n >= math.MinInt32 && n <= math.MaxInt32
+	}
+	return true
+}
+
+// descriptorFor returns the descriptor of the Java type a Go type converts to when the
+// Java type isn't given, which is only known for primitives, strings, and slices of them
+func descriptorFor(t reflect.Type) (string, bool) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "Z", true
+	case reflect.Int8, reflect.Uint8: // bytes, whether signed or not
+		return "B", true
+	case reflect.Uint16:
+		return "C", true
+	case reflect.Int16:
+		return "S", true
+	case reflect.Int32:
+		return "I", true
+	case reflect.Int, reflect.Int64:
+		return "J", true
+	case reflect.Float32:
+		return "F", true
+	case reflect.Float64:
+		return "D", true
+	case reflect.String:
+		return "Ljava/lang/String;", true
+	case reflect.Slice, reflect.Array:
+		if elem, ok := descriptorFor(t.Elem()); ok {
+			return "[" + elem, true
+		}
+	}
+	return "", false
+}
+
+// arrayToJava converts a slice or Go array to a Java array of the descriptor
+func arrayToJava(v reflect.Value, desc string) (int64, error) {
+	elemDesc := desc[1:]
+	if elemDesc == "B" && v.Type().Elem().Kind() == reflect.Uint8 { // bytes keep their bits
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		return classloader.NewByteArray(b), nil
+	}
+	elems := make([]int64, v.Len())
+	for i := range elems {
+		elem, err := toJava(v.Index(i), elemDesc)
+		if err != nil {
+			return 0, err
+		}
+		elems[i] = elem
+	}
+
+	switch {
+	case elemDesc == "B":
+		b := make([]byte, len(elems))
+		for i, e := range elems {
+			b[i] = byte(e)
+		}
+		return classloader.NewByteArray(b), nil
+	case elemDesc == "C":
+		chars := make([]uint16, len(elems))
+		for i, e := range elems {
+			chars[i] = uint16(e)
+		}
+		return classloader.NewCharArray(chars), nil
+	case elemDesc[0] == 'L':
+		return classloader.NewRefArray(elemDesc[1:len(elemDesc)-1], elems), nil
+	case elemDesc[0] == '[': // an array of arrays, whose class is its descriptor
+		ref := classloader.NewObject(desc, 0)
+		classloader.GetObject(ref).Native = elems
+		return ref, nil
+	}
+	return classloader.NewPrimitiveArray(elemDesc, elems), nil
+}
+
+// objectToJava converts a struct or map to an object of the class
+func objectToJava(v reflect.Value, className string) (int64, error) {
+	layout, slots, ok := classloader.ObjectLayout(className)
+	if !ok {
+		return 0, fmt.Errorf("marshal: class %s can't be loaded", className)
+	}
+	fields := make([]int64, slots)
+	for _, f := range layout {
+		if f.Static {
+			continue
+		}
+		fv, present := goField(v, f.Name)
+		if !present {
+			continue
+		}
+		slot, err := toJava(fv, f.Desc)
+		if err != nil {
+			return 0, fmt.Errorf("%s (field %s.%s)", err.Error(), className, f.Name)
+		}
+		fields[f.Slot] = slot
+	}
+	ref := classloader.NewObject(className, slots)
+	copy(classloader.GetObject(ref).Fields, fields)
+	return ref, nil
+}
+
+// goField returns the value in the struct or map for the Java field
+func goField(v reflect.Value, name string) (reflect.Value, bool) {
+	if v.Kind() == reflect.Map {
+		fv := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		return fv, fv.IsValid()
+	}
+	if i, ok := structField(v.Type(), name); ok {
+		return v.Field(i), true
+	}
+	return reflect.Value{}, false
+}
+
+// structField returns the index of the struct field that matches the Java field
+func structField(t reflect.Type, name string) (int, bool) {
+	match := -1
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" { // unexported
+			continue
+		}
+		tag := sf.Tag.Get("java")
+		switch {
+		case tag == "-":
+			continue
+		case tag != "":
+			if tag == name {
+				return i, true
+			}
+		case sf.Name == name:
+			return i, true
+		case match < 0 && lowerFirst(sf.Name) == name:
+			match = i
+		}
+	}
+	return match, match >= 0
+}
+
+// lowerFirst returns the name with its first letter in lowercase
+func lowerFirst(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
+
+func fromJava(slot int64, desc string, out reflect.Value) error {
+	if desc == "" {
+		return fmt.Errorf("marshal: empty descriptor")
+	}
+	if isReference(desc) && slot != 0 {
+		if obj := classloader.GetObject(slot); obj != nil {
+			desc = descriptorOfClass(obj.Klass) // the object's own class
+		}
+	}
+
+	switch out.Kind() {
+	case reflect.Interface:
+		t, ok := naturalType(desc)
+		if !ok {
+			break
+		}
+		if isReference(desc) && slot == 0 {
+			out.Set(reflect.Zero(out.Type()))
+			return nil
+		}
+		v := reflect.New(t).Elem()
+		if err := fromJava(slot, desc, v); err != nil {
+			return err
+		}
+		if !v.Type().AssignableTo(out.Type()) {
+			break
+		}
+		out.Set(v)
+		return nil
+	case reflect.Ptr:
+		if isReference(desc) && slot == 0 {
+			out.Set(reflect.Zero(out.Type()))
+			return nil
+		}
+		v := reflect.New(out.Type().Elem())
+		if err := fromJava(slot, desc, v.Elem()); err != nil {
+			return err
+		}
+		out.Set(v)
+		return nil
+	}
+
+	switch desc[0] {
+	case 'Z':
+		if out.Kind() == reflect.Bool {
+			out.SetBool(slot != 0)
+			return nil
+		}
+	case 'B', 'C', 'S', 'I', 'J':
+		if setInteger(out, slot) {
+			return nil
+		}
+		if out.Kind() == reflect.Float32 || out.Kind() == reflect.Float64 {
+			out.SetFloat(float64(slot))
+			return nil
+		}
+	case 'F', 'D':
+		if out.Kind() == reflect.Float32 || out.Kind() == reflect.Float64 {
+			out.SetFloat(classloader.FloatFromSlot(slot))
+			return nil
+		}
+	case '[':
+		if out.Kind() == reflect.Slice || out.Kind() == reflect.Array {
+			return arrayFromJava(slot, desc, out)
+		}
+	case 'L':
+		if desc == "Ljava/lang/String;" && out.Kind() == reflect.String {
+			s, _ := classloader.GoStringFromRef(slot)
+			out.SetString(s)
+			return nil
+		}
+		if out.Kind() == reflect.Struct || out.Kind() == reflect.Map && out.Type().Key().Kind() == reflect.String {
+			return objectFromJava(slot, desc[1:len(desc)-1], out)
+		}
+	}
+	return fmt.Errorf("marshal: %s can't be converted to %s", desc, out.Type())
+}
+
+// descriptorOfClass returns the descriptor of the class, whose name is in the internal form
+func descriptorOfClass(className string) string {
+	if strings.HasPrefix(className, "[") {
+		return className
+	}
+	return "L" + className + ";"
+}
+
+// setInteger sets the integer of any kind to the value, if it's in the integer's range
+func setInteger(out reflect.Value, n int64) bool {
+	switch out.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if out.OverflowInt(n) {
+			return false
+		}
+		out.SetInt(n)
+		return true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n < 0 || out.OverflowUint(uint64(n)) {
+			return false
+		}
+		out.SetUint(uint64(n))
+		return true
+	}
+	return false
+}
+
+// naturalType returns the Go type a Java value of the descriptor converts to when the
+// Go type isn't given
+func naturalType(desc string) (reflect.Type, bool) {
+	switch desc[0] {
+	case 'Z':
+		return reflect.TypeOf(false), true
+	case 'B':
+		return reflect.TypeOf(int8(0)), true
+	case 'C':
+		return reflect.TypeOf(uint16(0)), true
+	case 'S':
+		return reflect.TypeOf(int16(0)), true
+	case 'I':
+		return reflect.TypeOf(int32(0)), true
+	case 'J':
+		return reflect.TypeOf(int64(0)), true
+	case 'F':
+		return reflect.TypeOf(float32(0)), true
+	case 'D':
+		return reflect.TypeOf(float64(0)), true
+	case '[':
+		if desc == "[B" { // byte arrays are []byte in Go, as in the VM
+			return reflect.TypeOf([]byte(nil)), true
+		}
+		elem, ok := naturalType(desc[1:])
+		return reflect.SliceOf(elem), ok
+	case 'L':
+		if desc == "Ljava/lang/String;" {
+			return reflect.TypeOf(""), true
+		}
+		return reflect.TypeOf(map[string]interface{}(nil)), true
+	}
+	return nil, false
+}
+
+// arrayFromJava converts the Java array of the descriptor to a slice or Go array
+func arrayFromJava(ref int64, desc string, out reflect.Value) error {
+	obj := classloader.GetObject(ref)
+	if obj == nil {
+		if out.Kind() == reflect.Slice {
+			out.Set(reflect.Zero(out.Type()))
+			return nil
+		}
+		return fmt.Errorf("marshal: null can't be converted to %s", out.Type())
+	}
+
+	var elems []int64
+	switch native := obj.Native.(type) {
+	case []byte:
+		elems = make([]int64, len(native))
+		for i, b := range native {
+			elems[i] = int64(int8(b))
+		}
+	case []uint16:
+		elems = make([]int64, len(native))
+		for i, c := range native {
+			elems[i] = int64(c)
+		}
+	case []int64:
+		elems = native
+	default:
+		return fmt.Errorf("marshal: %s is not an array", obj.Klass)
+	}
+
+	if out.Kind() == reflect.Slice {
+		out.Set(reflect.MakeSlice(out.Type(), len(elems), len(elems)))
+	} else if out.Len() != len(elems) {
+		return fmt.Errorf("marshal: an array of %d elements can't be converted to %s", len(elems), out.Type())
+	}
+	for i, elem := range elems {
+		target := out.Index(i)
+		if desc == "[B" && target.Kind() == reflect.Uint8 { // bytes keep their bits
+			target.SetUint(uint64(byte(elem)))
+			continue
+		}
+		if err := fromJava(elem, desc[1:], target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// objectFromJava converts the object of the class to a struct or map
+func objectFromJava(ref int64, className string, out reflect.Value) error {
+	obj := classloader.GetObject(ref)
+	if obj == nil {
+		if out.Kind() == reflect.Map {
+			out.Set(reflect.Zero(out.Type()))
+			return nil
+		}
+		return fmt.Errorf("marshal: null can't be converted to %s", out.Type())
+	}
+	layout, _, ok := classloader.ObjectLayout(className)
+	if !ok {
+		return fmt.Errorf("marshal: class %s can't be loaded", className)
+	}
+	if out.Kind() == reflect.Map && out.IsNil() {
+		out.Set(reflect.MakeMap(out.Type()))
+	}
+
+	for _, f := range layout {
+		if f.Static || f.Slot >= len(obj.Fields) {
+			continue
+		}
+		slot := obj.Fields[f.Slot]
+		var err error
+		if out.Kind() == reflect.Map {
+			v := reflect.New(out.Type().Elem()).Elem()
+			if err = fromJava(slot, f.Desc, v); err == nil {
+				out.SetMapIndex(reflect.ValueOf(f.Name).Convert(out.Type().Key()), v)
+			}
+		} else if i, present := structField(out.Type(), f.Name); present {
+			err = fromJava(slot, f.Desc, out.Field(i))
+		}
+		if err != nil {
+			return fmt.Errorf("%s (field %s.%s)", err.Error(), className, f.Name)
+		}
+	}
+	return nil
+}
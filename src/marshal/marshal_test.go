@@ -0,0 +1,121 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package marshal
+
+import (
+	"jacobin/classloader"
+	"reflect"
+	"testing"
+)
+
+// addClass adds a class with the fields, given as name and descriptor pairs, to the
+// method area
+func addClass(name string, fields ...string) {
+	data := classloader.ClData{Name: name}
+	for i := 0; i < len(fields); i += 2 {
+		data.CP.Utf8Refs = append(data.CP.Utf8Refs, fields[i], fields[i+1])
+		data.Fields = append(data.Fields, classloader.Field{Name: uint16(i), Desc: uint16(i + 1)})
+	}
+	classloader.MethAreaMutex.Lock()
+	classloader.Classes[name] = classloader.Klass{Status: 'F', Loader: "app", Data: &data}
+	classloader.MethAreaMutex.Unlock()
+}
+
+type point struct {
+	X, Y int32
+}
+
+type shape struct {
+	Name   string
+	Points []point `java:"vertices"`
+	Closed bool
+	Scale  float64
+	Notes  string `java:"-"`
+	Tags   []string
+}
+
+func init() {
+	classloader.Classes = make(map[string]classloader.Klass)
+	addClass("test/Point", "x", "I", "y", "I")
+	addClass("test/Shape", "name", "Ljava/lang/String;", "vertices", "[Ltest/Point;",
+		"closed", "Z", "scale", "D", "notes", "Ljava/lang/String;", "tags", "[Ljava/lang/String;")
+}
+
+func TestStructRoundTrip(t *testing.T) {
+	in := shape{Name: "triangle", Points: []point{{0, 0}, {4, 0}, {0, 3}}, Closed: true, Scale: 1.5,
+		Notes: "not converted", Tags: []string{"a", "b"}}
+	ref, err := ToJava(in, "Ltest/Shape;")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	obj := classloader.GetObject(ref)
+	if obj.Klass != "test/Shape" || len(obj.Fields) != 6 {
+		t.Fatalf("Expected a test/Shape with 6 fields, got %s with %d", obj.Klass, len(obj.Fields))
+	}
+	if name, _ := classloader.GoStringFromRef(obj.Fields[0]); name != "triangle" {
+		t.Errorf("Expected the name field to be \"triangle\", got %q", name)
+	}
+	if obj.Fields[4] != 0 {
+		t.Errorf("Expected the field tagged - to be left null")
+	}
+
+	var out shape
+	if err := FromJava(ref, "Ltest/Shape;", &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	in.Notes = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Expected %+v, got %+v", in, out)
+	}
+}
+
+func TestMapsAndInterfaces(t *testing.T) {
+	ref, err := ToJava(map[string]interface{}{"x": 7, "y": int8(-2), "z": "skipped"}, "Ltest/Point;")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var m map[string]interface{}
+	if err := FromJava(ref, "Ljava/lang/Object;", &m); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(m, map[string]interface{}{"x": int32(7), "y": int32(-2)}) {
+		t.Errorf("Unexpected map: %v", m)
+	}
+
+	var v interface{}
+	bytes, _ := ToJava([]byte{0xFF, 1}, "[B")
+	if err := FromJava(bytes, "[B", &v); err != nil || !reflect.DeepEqual(v, []byte{0xFF, 1}) {
+		t.Errorf("Expected []byte{0xFF, 1}, got %v (error: %v)", v, err)
+	}
+	grid, _ := ToJava([][]int32{{1, 2}, {3}}, "[[I")
+	if err := FromJava(grid, "[[I", &v); err != nil || !reflect.DeepEqual(v, [][]int32{{1, 2}, {3}}) {
+		t.Errorf("Expected [[1 2] [3]], got %v (error: %v)", v, err)
+	}
+	if err := FromJava(0, "Ltest/Point;", &v); err != nil || v != nil {
+		t.Errorf("Expected nil for null, got %v (error: %v)", v, err)
+	}
+}
+
+func TestConversionErrors(t *testing.T) {
+	if _, err := ToJava(300, "B"); err == nil {
+		t.Error("Expected an error for a value that overflows a byte")
+	}
+	if _, err := ToJava("text", "I"); err == nil {
+		t.Error("Expected an error for a string as an int")
+	}
+	if _, err := ToJava(point{}, "Ltest/Missing;"); err == nil {
+		t.Error("Expected an error for a class that can't be loaded")
+	}
+	var small int8
+	if err := FromJava(1000, "I", &small); err == nil {
+		t.Error("Expected an error for an int that overflows an int8")
+	}
+	var p point
+	if err := FromJava(0, "I", p); err == nil {
+		t.Error("Expected an error for a target that isn't a pointer")
+	}
+}
@@ -0,0 +1,271 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package messages
+
+// The English catalog, which has every message. New messages are added at the end of
+// their section, with the next unused code.
+var english = map[string]string{
+	// the launcher
+	"JACOBIN-LA-0001": "Error: could not open `%s'",
+	"JACOBIN-LA-0002": "Error: unmatched quote in argument file",
+	"JACOBIN-LA-0003": "%s is not a recognized option. Ignored.",
+	"JACOBIN-LA-0004": "NOTE: Picked up %s: %s",
+	"JACOBIN-LA-0005": "Picked up %s: %s",
+	"JACOBIN-LA-0006": "Error: Option %s is not allowed in environment variable %s",
+	"JACOBIN-LA-0007": "Error: Cannot specify main class in environment variable %s",
+	"JACOBIN-LA-0008": "Error: Main method not found in class %s, please define the main method as:\n" +
+		"   public static void main(String[] args)",
+	"JACOBIN-LA-0009": "Invalid log format: %s. Ignored.",
+	"JACOBIN-LA-0010": "Error: %s requires a property name",
+	"JACOBIN-LA-0011": "Invalid -Xlog option '-Xlog:%s'. Ignored.",
+	"JACOBIN-LA-0012": "Could not open log file %s: %s. Ignored.",
+	"JACOBIN-LA-0013": "%s is not currently supported in Jacobin",
+	"JACOBIN-LA-0014": "%s. Ignored.",
+	"JACOBIN-LA-0015": "Unrecognized VM option '%s'",
+	"JACOBIN-LA-0016": "Missing +/- setting for VM option '%s'",
+	"JACOBIN-LA-0017": "Unexpected +/- setting in VM option '%s'",
+	"JACOBIN-LA-0018": "Improperly specified VM option '%s'",
+	"JACOBIN-LA-0019": "Error: %s is not a valid verbosity option. Ignored.",
+	"JACOBIN-LA-0020": "error: can't read file: %s",
+	"JACOBIN-LA-0021": "error: no class declared in source file: %s",
+	"JACOBIN-LA-0022": "error: javac was not found. Set JAVA_HOME to a JDK to run source files.",
+	"JACOBIN-LA-0023": "error: compilation failed",
+	"JACOBIN-LA-0024": "Error: No executable program specified. Exiting.",
+	"JACOBIN-LA-0025": "Error: %s requires class path specification",
+	"JACOBIN-LA-0026": "%s, line %d: expected a setting of the form key = value. Ignored.",
+	"JACOBIN-LA-0027": "%s, line %d: %s must be true or false. Ignored.",
+	"JACOBIN-LA-0028": "%s, line %d: %s is not a recognized setting. Ignored.",
+	"JACOBIN-LA-0029": "%s, line %d: options can't include -jar or a main class. Ignored.",
+	"JACOBIN-LA-0030": "Error: %s requires module name",
+	"JACOBIN-LA-0031": "Error: %s",
+	"JACOBIN-LA-0032": "Error: %s requires modules to be specified",
+	"JACOBIN-LA-0033": "Invalid %s value '%s': %s. Ignored.",
+	"JACOBIN-LA-0034": "Error: %s requires module path specification",
+	"JACOBIN-LA-0035": "Error: module %s does not have a ModuleMainClass attribute, use -m <module>/<main-class>",
+	"JACOBIN-LA-0036": "Error: agent library %s is not supported; only jdwp is",
+	"JACOBIN-LA-0037": "Error: invalid -agentlib:jdwp option: %s",
+	"JACOBIN-LA-0038": "Error: the debugging agent could not start: %s",
+	"JACOBIN-LA-0039": "Listening for transport dt_socket at address: %s",
+	"JACOBIN-LA-0040": "Unknown diagnostic command: %s. Use help to list the commands.",
+	"JACOBIN-LA-0041": "Error: %s is not a process ID",
+	"JACOBIN-LA-0042": "Error: could not send the command to process %s: %s",
+	"JACOBIN-LA-0043": "Warning: the metrics can't be served on port %d: %s",
+	"JACOBIN-LA-0044": "Error: invalid -XX:StartFlightRecording option: %s",
+	"JACOBIN-LA-0045": "Error: the flight recording could not be started: %s",
+	"JACOBIN-LA-0046": "Warning: the flight recording could not be written: %s",
+	"JACOBIN-LA-0047": "Error: could not read the flight recording %s: %s",
+	"JACOBIN-LA-0048": "Error: the CPU profile could not be started: %s",
+	"JACOBIN-LA-0049": "Warning: the %s profile could not be written: %s",
+	"JACOBIN-LA-0050": "Warning: the samples could not be written to %s: %s",
+	"JACOBIN-LA-0051": "Warning: the coverage report could not be written to %s: %s",
+	"JACOBIN-LA-0052": "Invalid -Xcheck option '-Xcheck:%s'. Ignored.",
+	"JACOBIN-LA-0053": "Warning: -Xcheck:native: %s",
+	"JACOBIN-LA-0054": "Warning: -Xcheck:native: %d classes with natives could not be loaded, so their natives were not checked",
+	"JACOBIN-LA-0055": "Usage: jacobin stack <pid>",
+	"JACOBIN-LA-0056": "Checkpoint failed: %s",
+	"JACOBIN-LA-0057": "Could not restore from %s: %s",
+	"JACOBIN-LA-0058": "error: java was not found. Set JAVA_HOME to a JDK to compare with java.",
+	"JACOBIN-LA-0059": "Could not run %s: %s",
+	"JACOBIN-LA-0060": "Could not listen on %s: %s",
+	"JACOBIN-LA-0061": "Usage: jacobin serve [--allow-remote] [<address>]",
+	"JACOBIN-LA-0062": "Warning: the verified classes could not be read from %s: %s",
+	"JACOBIN-LA-0063": "Warning: the verified classes could not be written to %s: %s",
+	"JACOBIN-LA-0064": "Refusing to listen on %s, which isn't a loopback address: the programs sent to it would run with your privileges. Give --allow-remote to listen on it anyway.",
+
+	// class loading
+	"JACOBIN-CL-0001": "Class Format Error: %s",
+	"JACOBIN-CL-0002": "  detected by file: %s, line: %d",
+	"JACOBIN-CL-0003": "Error: Could not find or load main class %s\n" +
+		"Caused by: java.lang.ClassNotFoundException: %s",
+	"JACOBIN-CL-0004": "error parsing %s. Exiting.",
+	"JACOBIN-CL-0005": "error format-checking %s. Exiting.",
+	"JACOBIN-CL-0006": "Did not find baseclasslist.txt in JACOBIN_HOME (%s)",
+	"JACOBIN-CL-0007": "Could not find class: %s",
+	"JACOBIN-CL-0008": "Error: Main method not found in class %s, please define the main method as:\n" +
+		"   public static void main(String[] args)",
+	"JACOBIN-CL-0009": "Found class: %s, but it did not contain method: %s",
+	"JACOBIN-CL-0010": "Java module record requires Java 9 or later version",
+	"JACOBIN-CL-0011": "Class %s has two module names: %s and %s",
+	"JACOBIN-CL-0012": "Java package entry requires Java 9 or later version",
+	"JACOBIN-CL-0013": "Class %s has two package names: %s and %s",
+	"JACOBIN-CL-0014": "Error in size of constant pool discovered in format check. Expected: %s, got: %s",
+	"JACOBIN-CL-0015": "Missing dummy entry in first slot of constant pool",
+	"JACOBIN-CL-0016": "CP entry #%s points to invalid UTF8 entry: %s",
+	"JACOBIN-CL-0017": "UTF8 string for CP entry #%s contains an invalid character",
+	"JACOBIN-CL-0018": "Integer at CP entry #%s points to an invalid entry in CP intConsts",
+	"JACOBIN-CL-0019": "Float at CP entry #%s points to an invalid entry in CP floats",
+	"JACOBIN-CL-0020": "Long constant at CP entry #%s points to an invalid entry in CP longConsts",
+	"JACOBIN-CL-0021": "Missing dummy entry after long constant at CP entry#%s",
+	"JACOBIN-CL-0022": "Double constant at CP entry #%s points to an invalid entry in CP doubless",
+	"JACOBIN-CL-0023": "Missing dummy entry after double constant at CP entry#%s",
+	"JACOBIN-CL-0024": "ClassRef at CP entry #%s points to an invalid entry in CP utf8Refs",
+	"JACOBIN-CL-0025": "Constant String at CP entry #%s points to an invalid entry in CP utf8Refs",
+	"JACOBIN-CL-0026": "Field Ref at CP entry #%s points to an invalid entry in CP fieldRefs",
+	"JACOBIN-CL-0027": "Field Ref at CP entry #%s has a class index that points to an invalid entry in ClassRefs. %s",
+	"JACOBIN-CL-0028": "Field Ref at CP entry #%s has a nameAndType index that points to an invalid entry in nameAndTypes. %s",
+	"JACOBIN-CL-0029": "Method Ref at CP entry #%s holds an invalid class index: %s",
+	"JACOBIN-CL-0030": "Method Ref at CP entry #%s holds an invalid NameAndType index: %s",
+	"JACOBIN-CL-0031": "Method Ref (at CP entry #%s) has a Name and Type entry does not have a name that is a valid UTF8 entry",
+	"JACOBIN-CL-0032": "Method Ref at CP entry #%s holds an NameAndType index to an entry with an invalid method name %s",
+	"JACOBIN-CL-0033": "Interface Ref at CP entry #%s holds an invalid class index: %s",
+	"JACOBIN-CL-0034": "Interface Ref at CP entry #%s holds an invalid UTF8 index to the interface name: %s",
+	"JACOBIN-CL-0035": "Name and Type at CP entry #%s points to an invalid entry in CP nameAndTypes",
+	"JACOBIN-CL-0036": "Name and Type at CP entry #%s has a name index that points to an invalid UTF8 entry: %s",
+	"JACOBIN-CL-0037": "Name and Type at CP entry #%s has a description index that points to an invalid UTF8 entry: %s",
+	"JACOBIN-CL-0038": "Name and Type at CP entry #%s has an invalid description string: %s",
+	"JACOBIN-CL-0039": "MethodHandle at CP entry #%s has an invalid reference kind: %s",
+	"JACOBIN-CL-0040": "MethodHandle at CP entry #%s has an reference kind between 1-4 ( %s) which does not point to a FieldRef",
+	"JACOBIN-CL-0041": "MethodHandle at CP entry #%s has an reference kind between of 5 or 8 ( %s) which does not point to a MethodRef",
+	"JACOBIN-CL-0042": "MethodHandle at CP entry #%s has an reference kind between of 6 or 7 ( %s) which does not point to a MethodRef or in Java version 52 or later does not point to an Interface.",
+	"JACOBIN-CL-0043": "MethodHandle at CP entry #%s has an reference kind  of 9 which does not point to an interface",
+	"JACOBIN-CL-0044": "Reference index for MethodHandle at CP entry #%s points to an invalid MethodRef: %s",
+	"JACOBIN-CL-0045": "Invalid class name for MethodHandle at CP entry #%s : %s",
+	"JACOBIN-CL-0046": "Class name for MethodHandle at CP entry #%s should be <init>, but is: %s",
+	"JACOBIN-CL-0047": "MethodType at CP entry #%s has an invalid description index: %s",
+	"JACOBIN-CL-0048": "MethodType at CP entry #%s does not point to a type that starts with an open parenthesis. Got: %s",
+	"JACOBIN-CL-0049": "The dynamic entry at CP[%s] points to a non-existent dynamic slot: %s",
+	"JACOBIN-CL-0050": "The boostrap index in dynamic at CP[%s] is invalid: %s",
+	"JACOBIN-CL-0051": "Invalid methodRef in bootstrap method[%s]",
+	"JACOBIN-CL-0052": "The entry number into klass.dynamics[] at CP entry #%s is invalid: %s",
+	"JACOBIN-CL-0053": "NameAndType index at CP entry #%s (dynamic) points to an entry that's not NameAndType: %s",
+	"JACOBIN-CL-0054": "Descriptor in nameAndType entry of dynamic CP entry #%s is invalid: %s",
+	"JACOBIN-CL-0055": "Descriptor in nameAndType entry of dynamic CP entry #%s is an invalid field descriptor: %s",
+	"JACOBIN-CL-0056": "The invokeDynamic entry at CP[%s] points to a non-existent invokeDynamic slot: %s",
+	"JACOBIN-CL-0057": "The boostrap index in InvokeDynamic at CP[%s] is invalid: %s",
+	"JACOBIN-CL-0058": "The entry number into klass.InvokeDynamics[] at CP entry #%s is invalid: %s",
+	"JACOBIN-CL-0059": "NameAndType index at CP entry #%s (InvokeDynamic) points to an entry that's not NameAndType: %s",
+	"JACOBIN-CL-0060": "Descriptor in nameAndType entry of dynamic CP entry #%s is an invalid method descriptor: %s",
+	"JACOBIN-CL-0061": "Module CP entry must appear only in class with ACC_MODULE set.",
+	"JACOBIN-CL-0062": "Package CP entry must appear only in class with ACC_MODULE set.",
+	"JACOBIN-CL-0063": "Invalid index to UTF8 string for field name in field #%s",
+	"JACOBIN-CL-0064": "Invalid index for UTF8 string containing description of field %s",
+	"JACOBIN-CL-0065": "Invalid field name in format check (starts with a digit): %s",
+	"JACOBIN-CL-0066": "Invalid field name in format check (contains whitespace): %s",
+	"JACOBIN-CL-0067": "Field %s has an invalid description string: %s",
+	"JACOBIN-CL-0068": "Expected a module/package name, but none was found.",
+	"JACOBIN-CL-0069": "Module/Package name %s contains an illegal character",
+	"JACOBIN-CL-0070": "Module name %s contains an illegal character",
+	"JACOBIN-CL-0071": "Expected a package name, but none was found.",
+	"JACOBIN-CL-0072": "Package name %s contains an illegal character",
+	"JACOBIN-CL-0073": "MethodRef in bootstrapMethod[%s] in class %sshould but does not point to a MethodHandle",
+	"JACOBIN-CL-0074": "Boostrap method argument[%s] in class %s bootstrap method #[%s] should be but is not a loadable constant",
+	"JACOBIN-CL-0075": "CP count: %s is not equal to actual size of CP: %s",
+	"JACOBIN-CL-0076": "Expected %s interfaces. Got: %s",
+	"JACOBIN-CL-0077": "Expected %s methods. Got: %s",
+	"JACOBIN-CL-0078": "Expected %s class attributes. Got: %s",
+	"JACOBIN-CL-0079": "Expected %s bootstrap methods. Got: %s",
+	"JACOBIN-CL-0080": "Invalid fetch of method access flags in class: %s",
+	"JACOBIN-CL-0081": "Invalid fetch of method name index in class: %s",
+	"JACOBIN-CL-0082": "Invalid fetch of method description index in method: %s",
+	"JACOBIN-CL-0083": "Invalid fetch of method description slot in method: %s",
+	"JACOBIN-CL-0084": "Invalid fetch of method attribute count in method: %s",
+	"JACOBIN-CL-0085": "error parsing a method attribute",
+	"JACOBIN-CL-0086": "Error fetching method attribute in method: %s",
+	"JACOBIN-CL-0087": "Error getting maxStack value in Code attribute in %s",
+	"JACOBIN-CL-0088": "Error getting maxLocals value in Code attribute in %s",
+	"JACOBIN-CL-0089": "Error getting code length in Code attribute in %s",
+	"JACOBIN-CL-0090": "Error getting count of exceptions in Code attribute in %s",
+	"JACOBIN-CL-0091": "Error getting catch type for exception in %s() of %s\n at position: %s in the method (after parse of start/endPC, handlerPc, and catch type)",
+	"JACOBIN-CL-0092": "Invalid catchType in method %s in %s",
+	"JACOBIN-CL-0093": "Error getting attributes in Code attribute of %s() of %s",
+	"JACOBIN-CL-0094": "Error retrieving attributes in Code attribute of %s() of %s",
+	"JACOBIN-CL-0095": "Error retrieving exception count in method %s",
+	"JACOBIN-CL-0096": "Exception attribute #%s in method %s does not point to a ClassRef CP entry",
+	"JACOBIN-CL-0097": "Exception attribute #%s in method %s has a ClassRef CP entry that does not point to a UTF8 string",
+	"JACOBIN-CL-0098": "Error getting number of Parameter attributes in method: %s",
+	"JACOBIN-CL-0099": "Error getting name index for MethodParameters attribute #%s in %s",
+	"JACOBIN-CL-0100": "Error getting name of MethodParameters attribute #%s in %s",
+	"JACOBIN-CL-0101": "Error getting access flags of MethodParameters attribute #%s in %s",
+	"JACOBIN-CL-0102": "Invalid access flags of MethodParameters attribute #%s in %s",
+	"JACOBIN-CL-0103": "Unexpected bytes found at end of class file: %s",
+	"JACOBIN-CL-0104": "invalid magic number",
+	"JACOBIN-CL-0105": "java.lang.UnsupportedClassVersionError: %s has been compiled by a more recent version of the Java Runtime (class file version %s), this version of the Java Runtime only recognizes class file versions up to %s",
+	"JACOBIN-CL-0106": "Invalid number of entries in constant pool: %s",
+	"JACOBIN-CL-0107": "Invalid get of class access flags",
+	"JACOBIN-CL-0108": "error obtaining index for class name",
+	"JACOBIN-CL-0109": "invalid index into CP for class name: %s",
+	"JACOBIN-CL-0110": "invalid entry for class name",
+	"JACOBIN-CL-0111": "Class appears to have two names: %s and: %s",
+	"JACOBIN-CL-0112": "error obtaining index for superclass name",
+	"JACOBIN-CL-0113": "invaild index for superclass name. Got: 0, but class is not java/lang/Object",
+	"JACOBIN-CL-0114": "invalid index into CP for superclass name",
+	"JACOBIN-CL-0115": "invalid entry for superclass name",
+	"JACOBIN-CL-0116": "invalid empty string for superclass name",
+	"JACOBIN-CL-0117": "Class can only have 1 superclass, found two: %s and: %s",
+	"JACOBIN-CL-0118": "Invalid fetch of interface count",
+	"JACOBIN-CL-0119": "Invalid fetch of interface index",
+	"JACOBIN-CL-0120": "Interface index is out of range: %s",
+	"JACOBIN-CL-0121": "Interface index does not point to a class type. Got: %s",
+	"JACOBIN-CL-0122": "Invalid fetch of field count",
+	"JACOBIN-CL-0123": "error retrieving access flags for field %s",
+	"JACOBIN-CL-0124": "error retrieving name index for field",
+	"JACOBIN-CL-0125": "error fetching UTF-8 string for name of field",
+	"JACOBIN-CL-0126": "error retrieving description index for field: %s",
+	"JACOBIN-CL-0127": "error retrieving UTF8 slot for description of field: %s",
+	"JACOBIN-CL-0128": "error retrieving attribute count for field: %s",
+	"JACOBIN-CL-0129": "error: wrong type of constant value for byte %s",
+	"JACOBIN-CL-0130": "error: wrong type of constant value for char %s",
+	"JACOBIN-CL-0131": "error: wrong type of constant value for double %s",
+	"JACOBIN-CL-0132": "error: wrong type of constant value for float %s",
+	"JACOBIN-CL-0133": "error: wrong type of constant value for integer %s",
+	"JACOBIN-CL-0134": "error: wrong type of constant value for long %s",
+	"JACOBIN-CL-0135": "error: wrong type of constant value for short %s",
+	"JACOBIN-CL-0136": "Invalid fetch of method count",
+	"JACOBIN-CL-0137": "Invalid fetch of class attribute count",
+	"JACOBIN-CL-0138": "Error fetching class attribute in class: %s",
+	"JACOBIN-CL-0139": "Invalid method reference in Boostrap method #%s",
+	"JACOBIN-CL-0140": "invalid offset into file",
+	"JACOBIN-CL-0141": "attempt to fetch invalid UTF8 at CP entry #%s",
+	"JACOBIN-CL-0142": "attempt to fetch UTF8 string from non-UTF8 CP entry #%s",
+	"JACOBIN-CL-0143": "invalid index into UTF8 array of CP: %s",
+	"JACOBIN-CL-0144": "error fetching field attribute",
+	"JACOBIN-CL-0145": "error fetching name of field attribute",
+	"JACOBIN-CL-0146": "error fetching length of field attribute",
+	"JACOBIN-CL-0147": "Invalid index into CP: %s",
+	"JACOBIN-CL-0148": "Expecting MethodRef (10) at CP entry #%s but instead got CP type: %s",
+	"JACOBIN-CL-0149": "ClassRef entry in MethodRef CP entry #%s does not point to a valid string",
+	"JACOBIN-CL-0150": "Invalid nameAndType index into CP: %s",
+	"JACOBIN-CL-0151": "Name index in nameAndType entry (CP #%s) does not point to a UTF8 entry.",
+	"JACOBIN-CL-0152": "Desc index in nameAndType entry (CP #%s) does not point to a UTF8 entry.",
+	"JACOBIN-CL-0153": "no runtime image was found in %s",
+	"JACOBIN-CL-0154": "module %s can't be described: %s has no jmods directory, and the runtime image can't be read yet",
+	"JACOBIN-CL-0155": "module %s not found",
+	"JACOBIN-CL-0156": "%s is not a valid jmod file",
+	"JACOBIN-CL-0157": "invalid module-info class: %s",
+	"JACOBIN-CL-0158": "Error occurred during initialization of boot layer\njava.lang.module.FindException: Module %s not found",
+	"JACOBIN-CL-0159": "Error occurred during initialization of boot layer\njava.lang.module.FindException: Module %s not found, required by %s",
+	"JACOBIN-CL-0160": "Error reading module %s: %s",
+	"JACOBIN-CL-0161": "java.lang.UnsupportedClassVersionError: %s (class file version %s) was compiled with an invalid major version",
+	"JACOBIN-CL-0162": "java.lang.UnsupportedClassVersionError: %s (class file version %s) was compiled with an invalid non-zero minor version",
+	"JACOBIN-CL-0163": "java.lang.UnsupportedClassVersionError: Preview features are not enabled for %s (class file version %s). Try running with '--enable-preview'",
+	"JACOBIN-CL-0164": "Illegal type at constant pool entry %s in class %s, in method %s at location %s",
+	"JACOBIN-CL-0165": "%s attribute of %s has length %s, but its contents take %s bytes",
+	"JACOBIN-CL-0166": "%s attribute of %s has an item with an undefined tag",
+	"JACOBIN-CL-0167": "Invalid field name in format check (empty): %s",
+	"JACOBIN-CL-0168": "Invalid field name in format check (contains . ; [ / or (): %s",
+
+	// the interpreter
+	"JACOBIN-IN-0001": "Class not found: %s.main()",
+	"JACOBIN-IN-0002": "Memory error allocating frame on thread: %d",
+	"JACOBIN-IN-0003": "Expected a field ref on getstatic, but got %d in location %d in method %s of class %s",
+	"JACOBIN-IN-0004": "Expected a field ref on putstatic, but got %d in location %d in method %s of class %s",
+	"JACOBIN-IN-0005": "Expected a method ref for invokevirtual, but got %d in location %d in method %s of class %s",
+	"JACOBIN-IN-0006": "Expected a method ref for invokespecial, but got %d in location %d in method %s of class %s",
+	"JACOBIN-IN-0007": "Expected an interface method ref for invokeinterface, but got %d in location %d in method %s of class %s",
+	"JACOBIN-IN-0008": "Method not found: %s.%s%s",
+	"JACOBIN-IN-0009": "Class not found: %s%s",
+	"JACOBIN-IN-0010": "invokeinterface of %s.%s%s is not yet supported",
+	"JACOBIN-IN-0011": "Invalid type for new object",
+	"JACOBIN-IN-0012": "Error instantiating class: %s",
+	"JACOBIN-IN-0013": "Invalid bytecode found: %d at location %d in method %s() of class %s",
+	"JACOBIN-IN-0014": "Error loading class: %s. Exiting.",
+	"JACOBIN-IN-0015": "go method not found: %s",
+	"JACOBIN-IN-0016": "no code for %s.%s%s",
+	"JACOBIN-IN-0017": "invalid popFrame of empty JVM frame stack",
+	"JACOBIN-IN-0018": "Cannot run %s.%s%s on a new thread",
+	"JACOBIN-IN-0019": "Expected a method ref for invokestatic, but got %d in location %d in method %s of class %s",
+}
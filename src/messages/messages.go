@@ -0,0 +1,117 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+// Package messages is the catalog of Jacobin's user-facing diagnostics: the launcher's
+// errors, the class format errors found when loading classes, and the interpreter's
+// errors. Each message has a stable code, such as JACOBIN-CL-0042, whose middle part
+// says where the message comes from:
+//
+//	LA  the launcher: command-line processing and starting the program
+//	CL  class loading, parsing, and format checking
+//	IN  the interpreter
+//
+// The catalog maps the codes to the text of the messages, which are fmt formats whose
+// arguments are supplied when the message is issued. Since the code identifies a
+// message regardless of its wording, the text can be localized and reworded freely,
+// and tests can check which message was issued rather than comparing prose.
+//
+// Codes are never reused. A message that's no longer issued is removed from the
+// catalog, but its code isn't given to another message.
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// the catalogs, by language. The English catalog (catalog_en.go) is complete; a
+// message missing from the catalog of another language is shown in English.
+var catalogs = map[string]map[string]string{
+	"en": english,
+}
+
+var language = "en"
+var mutex sync.RWMutex
+
+// SetLocale selects the catalog for the language of the locale, such as fr or fr_CA.
+// If there's no catalog for the language, English is used.
+func SetLocale(locale string) {
+	lang := strings.ToLower(strings.SplitN(strings.ReplaceAll(locale, "-", "_"), "_", 2)[0])
+	mutex.Lock()
+	defer mutex.Unlock()
+	if _, present := catalogs[lang]; present {
+		language = lang
+	} else {
+		language = "en"
+	}
+}
+
+// Text returns the text of the message with the code, with the arguments substituted
+func Text(code string, args ...interface{}) string {
+	mutex.RLock()
+	format, present := catalogs[language][code]
+	mutex.RUnlock()
+	if !present {
+		format, present = english[code]
+	}
+	if !present {
+		return fmt.Sprintf("%s %v", code, args) // an unknown code is a bug, but say what we can
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Error is an error whose message is in the catalog
+type Error struct {
+	Code string        // the message's code
+	Args []interface{} // the arguments substituted in the message
+	Msg  string        // the message as shown to the user
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// New returns an error with the message with the code
+func New(code string, args ...interface{}) *Error {
+	return &Error{Code: code, Args: args, Msg: Text(code, args...)}
+}
+
+// the writer that Print shows messages on instead of stderr, if it's set. Guarded by mutex.
+var output io.Writer
+
+// SetOutput directs the messages that Print shows to the writer (nil: stderr)
+func SetOutput(out io.Writer) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	output = out
+}
+
+// Print shows the message with the code on stderr and returns it as an error
+func Print(code string, args ...interface{}) error {
+	err := New(code, args...)
+	mutex.RLock()
+	out := output
+	mutex.RUnlock()
+	if out == nil {
+		out = os.Stderr // looked up each time, so tests can redirect it
+	}
+	fmt.Fprintln(out, err.Msg)
+	return err
+}
+
+// CodeOf returns the code of the message in the error, or "" if the error isn't from
+// the catalog
+func CodeOf(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
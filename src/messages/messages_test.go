@@ -0,0 +1,125 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package messages
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var codeRE = regexp.MustCompile(`^JACOBIN-(LA|CL|IN)-\d{4}$`)
+
+func TestCatalogCodesAreWellFormed(t *testing.T) {
+	for code, text := range english {
+		if !codeRE.MatchString(code) {
+			t.Errorf("Invalid message code: %s", code)
+		}
+		if text == "" {
+			t.Errorf("Message %s has no text", code)
+		}
+	}
+}
+
+// every code used in the source must be in the catalog
+func TestCodesUsedAreInCatalog(t *testing.T) {
+	literalRE := regexp.MustCompile(`^JACOBIN-(LA|CL|IN)-\d+$`)
+	found := 0
+	err := filepath.Walk("..", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".go") {
+			return err
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			lit, ok := n.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			code, _ := strconv.Unquote(lit.Value)
+			if !literalRE.MatchString(code) || strings.HasSuffix(path, "catalog_en.go") {
+				return true
+			}
+			found++
+			if _, present := english[code]; !present {
+				t.Errorf("%s uses message %s, which is not in the catalog", path, code)
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error scanning the source: %s", err.Error())
+	}
+	if found == 0 {
+		t.Error("Found no message codes in the source")
+	}
+}
+
+func TestTextSubstitutesArguments(t *testing.T) {
+	text := Text("JACOBIN-LA-0005", "JDK_JAVA_OPTIONS", "-verbose")
+	if text != "Picked up JDK_JAVA_OPTIONS: -verbose" {
+		t.Errorf("Unexpected message text: %s", text)
+	}
+	if !strings.HasPrefix(Text("JACOBIN-XX-9999", 1), "JACOBIN-XX-9999") {
+		t.Error("The text of an unknown code should include the code")
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	err := New("JACOBIN-CL-0007", "a/B")
+	if err.Error() != "Could not find class: a/B" {
+		t.Errorf("Unexpected error message: %s", err.Error())
+	}
+	wrapped := fmt.Errorf("loading failed: %w", err)
+	if CodeOf(wrapped) != "JACOBIN-CL-0007" {
+		t.Errorf("Expected code JACOBIN-CL-0007, got: %s", CodeOf(wrapped))
+	}
+	if CodeOf(errors.New("plain")) != "" {
+		t.Error("An error that's not from the catalog should have no code")
+	}
+}
+
+func TestPrintToOutput(t *testing.T) {
+	var out bytes.Buffer
+	SetOutput(&out)
+	defer SetOutput(nil)
+	err := Print("JACOBIN-CL-0007", "a/B")
+	if out.String() != "Could not find class: a/B\n" || CodeOf(err) != "JACOBIN-CL-0007" {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+}
+
+func TestSetLocaleFallsBackToEnglish(t *testing.T) {
+	catalogs["xx"] = map[string]string{"JACOBIN-LA-0023": "xx: compilation failed"}
+	defer delete(catalogs, "xx")
+	defer SetLocale("en")
+
+	SetLocale("xx_YY")
+	if text := Text("JACOBIN-LA-0023"); text != "xx: compilation failed" {
+		t.Errorf("Expected the xx message, got: %s", text)
+	}
+	if text := Text("JACOBIN-LA-0002"); text != "Error: unmatched quote in argument file" {
+		t.Errorf("Expected the English message for a missing translation, got: %s", text)
+	}
+
+	SetLocale("zz")
+	if text := Text("JACOBIN-LA-0023"); text != "error: compilation failed" {
+		t.Errorf("Expected English for an unknown language, got: %s", text)
+	}
+}
@@ -6,15 +6,18 @@
 
 package util
 
-import "strings"
+import (
+	"path/filepath"
+	"strings"
+)
 
 // accepts a class name with the JVM's internal format and converts
-// it to a filename (with backslashes). Returns "" on error.
+// it to a filename (with the host's separators). Returns "" on error.
 func ConvertInternalClassNameToFilename(clName string) string {
-	name := strings.ReplaceAll(clName, "/", "\\")
-	name = strings.ReplaceAll(name, ".", "\\") + ".class"
+	name := strings.ReplaceAll(clName, "\\", "/")
+	name = strings.ReplaceAll(name, ".", "/") + ".class"
 
-	return name
+	return filepath.FromSlash(name)
 }
 
 func ConvertClassFilenameToInternalFormat(fName string) string {
@@ -6,7 +6,10 @@
 
 package util
 
-import "testing"
+import (
+	"path/filepath"
+	"testing"
+)
 
 func TestConvertInternalClassNameToFilename(t *testing.T) {
 	// var s string
@@ -15,12 +18,12 @@ func TestConvertInternalClassNameToFilename(t *testing.T) {
 	}
 
 	s := ConvertInternalClassNameToFilename("sponge/bob")
-	if s != "sponge\\bob.class" {
+	if s != filepath.FromSlash("sponge/bob.class") {
 		t.Error("Unexpected result in call ConvertInternalClassNameToFilename(): " + s)
 	}
 
 	s = ConvertInternalClassNameToFilename("sponge/bob\\square.pants")
-	if s != "sponge\\bob\\square\\pants.class" {
+	if s != filepath.FromSlash("sponge/bob/square/pants.class") {
 		t.Error("Unexpected result in call ConvertInternalClassNameToFilename(): " + s)
 	}
 }
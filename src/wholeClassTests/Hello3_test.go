@@ -40,9 +40,9 @@ import (
 // These tests check the output with various options for verbosity and features set on the command line.
 
 func initVarsHello3() {
-	_JACOBIN = "d:\\GoogleDrive\\Dev\\jacobin\\src\\jacobin.exe"
+	_JACOBIN = jacobinExe
 	_JVM_ARGS = ""
-	_TESTCLASS = "d:\\GoogleDrive\\Dev\\jacobin\\testdata\\Hello3.class" // the class to test
+	_TESTCLASS = testClass("Hello3") // the class to test
 	_APP_ARGS = ""
 }
 
@@ -54,6 +54,8 @@ func TestRunHello3(t *testing.T) {
 		t.Skip()
 	}
 
+	requireBaseClasses(t)
+
 	// test that executable exists
 	if _, err := os.Stat(_JACOBIN); err != nil {
 		t.Errorf("Missing Jacobin executable, which was specified as %s", _JACOBIN)
@@ -113,6 +115,8 @@ func TestRunHello3VerboseClass(t *testing.T) {
 		t.Skip()
 	}
 
+	requireBaseClasses(t)
+
 	// test that executable exists
 	if _, err := os.Stat(_JACOBIN); err != nil {
 		t.Errorf("Missing Jacobin executable, which was specified as %s", _JACOBIN)
@@ -173,6 +177,8 @@ func TestRunHello3VerboseFinest(t *testing.T) {
 		t.Skip()
 	}
 
+	requireBaseClasses(t)
+
 	// test that executable exists
 	if _, err := os.Stat(_JACOBIN); err != nil {
 		t.Errorf("Missing Jacobin executable, which was specified as %s", _JACOBIN)
@@ -233,6 +239,8 @@ func TestRunHello3TraceInst(t *testing.T) {
 		t.Skip()
 	}
 
+	requireBaseClasses(t)
+
 	// test that executable exists
 	if _, err := os.Stat(_JACOBIN); err != nil {
 		t.Errorf("Missing Jacobin executable, which was specified as %s", _JACOBIN)
@@ -34,9 +34,9 @@ var _TESTCLASS string
 var _APP_ARGS string
 
 func initArgs() {
-	_JACOBIN = "d:\\GoogleDrive\\Dev\\jacobin\\src\\jacobin.exe"
+	_JACOBIN = jacobinExe
 	_JVM_ARGS = ""
-	_TESTCLASS = "d:\\GoogleDrive\\Dev\\jacobin\\testdata\\Hello.class" // the class to test
+	_TESTCLASS = testClass("Hello") // the class to test
 	_APP_ARGS = ""
 }
 
@@ -48,6 +48,8 @@ func TestRunHello(t *testing.T) {
 		t.Skip()
 	}
 
+	requireBaseClasses(t)
+
 	// test that executable exists
 	if _, err := os.Stat((_JACOBIN)); err != nil {
 		t.Errorf("Missing Jacobin executable, which was specified as %s", (_JACOBIN))
@@ -105,6 +107,8 @@ func TestRunHelloVerboseClass(t *testing.T) {
 		t.Skip()
 	}
 
+	requireBaseClasses(t)
+
 	// test that executable exists
 	if _, err := os.Stat((_JACOBIN)); err != nil {
 		t.Errorf("Missing Jacobin executable, which was specified as %s", (_JACOBIN))
@@ -163,6 +167,8 @@ func TestRunHelloVerboseFinest(t *testing.T) {
 		t.Skip()
 	}
 
+	requireBaseClasses(t)
+
 	// test that executable exists
 	if _, err := os.Stat((_JACOBIN)); err != nil {
 		t.Errorf("Missing Jacobin executable, which was specified as %s", (_JACOBIN))
@@ -221,6 +227,8 @@ func TestRunHelloTraceInst(t *testing.T) {
 		t.Skip()
 	}
 
+	requireBaseClasses(t)
+
 	// test that executable exists
 	if _, err := os.Stat((_JACOBIN)); err != nil {
 		t.Errorf("Missing Jacobin executable, which was specified as %s", (_JACOBIN))
@@ -38,9 +38,9 @@ import (
 // These tests check the output with various options for verbosity and features set on the command line.
 
 func initVarsNanoPrint() {
-	_JACOBIN = "d:\\GoogleDrive\\Dev\\jacobin\\src\\jacobin.exe"
+	_JACOBIN = jacobinExe
 	_JVM_ARGS = ""
-	_TESTCLASS = "d:\\GoogleDrive\\Dev\\jacobin\\testdata\\NanoPrint.class" // the class to test
+	_TESTCLASS = testClass("NanoPrint") // the class to test
 	_APP_ARGS = ""
 }
 
@@ -52,6 +52,8 @@ func TestRunNanoprint(t *testing.T) {
 		t.Skip()
 	}
 
+	requireBaseClasses(t)
+
 	// test that executable exists
 	if _, err := os.Stat(_JACOBIN); err != nil {
 		t.Errorf("Missing Jacobin executable, which was specified as %s", _JACOBIN)
@@ -119,6 +121,8 @@ func TestRunNanoPrintVerboseClass(t *testing.T) {
 		t.Skip()
 	}
 
+	requireBaseClasses(t)
+
 	// test that executable exists
 	if _, err := os.Stat(_JACOBIN); err != nil {
 		t.Errorf("Missing Jacobin executable, which was specified as %s", _JACOBIN)
@@ -175,6 +179,8 @@ func TestRunNanoPrintVerboseFinest(t *testing.T) {
 		t.Skip()
 	}
 
+	requireBaseClasses(t)
+
 	// test that executable exists
 	if _, err := os.Stat(_JACOBIN); err != nil {
 		t.Errorf("Missing Jacobin executable, which was specified as %s", _JACOBIN)
@@ -230,6 +236,8 @@ func TestRunNanoPrintTraceInst(t *testing.T) {
 		t.Skip()
 	}
 
+	requireBaseClasses(t)
+
 	// test that executable exists
 	if _, err := os.Stat(_JACOBIN); err != nil {
 		t.Errorf("Missing Jacobin executable, which was specified as %s", _JACOBIN)
@@ -22,7 +22,7 @@ import (
  */
 
 func initVarsClientVersion() {
-	_JACOBIN = "d:\\GoogleDrive\\Dev\\jacobin\\src\\jacobin.exe"
+	_JACOBIN = jacobinExe
 }
 
 func TestRunClientVersion(t *testing.T) {
@@ -54,11 +54,15 @@ func TestRunClientVersion(t *testing.T) {
 	// Here begin the actual tests on the output to stderr and stdout
 
 	slurp, _ := io.ReadAll(stderr)
-	if !strings.HasPrefix(string(slurp), "Jacobin VM") {
-		t.Errorf("Stderr did not begin with Jacobin name, instead: %s", string(slurp))
+	if !strings.HasPrefix(string(slurp), "openjdk version") {
+		t.Errorf("Stderr did not begin with the Java version, instead: %s", string(slurp))
 	}
 
-	if !strings.Contains(string(slurp), "64-bit client VM") {
+	if !strings.Contains(string(slurp), "Jacobin VM") {
+		t.Errorf("Stderr did not contain the Jacobin name, instead: %s", string(slurp))
+	}
+
+	if !strings.Contains(string(slurp), "64-Bit Client VM") {
 		t.Errorf("Did not get expected output to stderr. Got: %s", string(slurp))
 	}
 }
@@ -0,0 +1,322 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package wholeClassTests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The conformance tests. testdata/conformance holds tests of the JVM's behavior in the
+// jtreg format that OpenJDK's tests use, but only ones that need no harness: a single
+// source file whose @run tags run its main(), which throws an exception if the VM
+// misbehaves. For example:
+//
+//	/*
+//	 * @test
+//	 * @summary the remainder of a negative int is negative
+//	 * @run main IntRemainder
+//	 */
+//
+// Each test is compiled with the JDK's javac, run with Jacobin, and tagged as:
+//
+//   - pass: every @run exited with status 0
+//   - fail: an @run threw an exception, exited with another status, or ran too long
+//   - unsupported: the test needs more of jtreg than @run main (such as @library or
+//     @run testng), or Jacobin stopped at something it doesn't implement yet
+//
+// The tags are compared with those in testdata/conformance/status.txt, which records
+// them as of the last release. A test that passed then and doesn't now fails this
+// test; the others are only reported, so that the number that pass measures how far
+// Jacobin has come. To record the tags as they are now, run
+//
+//	go test ./wholeClassTests -run TestConformance -update -v
+
+// the directory of the conformance tests and the file of their tags at the last release
+var (
+	conformanceDir    = filepath.Join(testdataDir, "conformance")
+	conformanceStatus = filepath.Join(conformanceDir, "status.txt")
+)
+
+// the most time a conformance test's @run can take
+const conformanceTimeout = 30 * time.Second
+
+const (
+	pass        = "pass"
+	fail        = "fail"
+	unsupported = "unsupported"
+)
+
+// jtregRun is an @run main tag: the class to run, the options for the VM, and the args
+type jtregRun struct {
+	class   string
+	vmArgs  []string
+	appArgs []string
+}
+
+// unsupportedOutput is what Jacobin writes when it stops at something it doesn't
+// implement yet, as opposed to something the program got wrong
+var unsupportedOutput = []string{
+	"Invalid bytecode found",
+	"is not yet supported",
+	"Method not found",
+	"go method not found",
+	"Class not found",
+}
+
+func TestConformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	requireBaseClasses(t)
+	if javacPath() == "" {
+		t.Skip("the conformance tests need a JDK to compile them")
+	}
+
+	sources, _ := filepath.Glob(filepath.Join(conformanceDir, "*.java"))
+	previous := readConformanceStatus()
+	tags := make(map[string]string)
+	counts := make(map[string]int)
+	for _, source := range sources {
+		name := strings.TrimSuffix(filepath.Base(source), ".java")
+		tag, reason := runConformanceTest(t, source)
+		tags[name] = tag
+		counts[tag]++
+		if reason != "" {
+			t.Logf("%-30s %-11s %s", name, tag, reason)
+		} else {
+			t.Logf("%-30s %s", name, tag)
+		}
+		if previous[name] == pass && tag != pass {
+			t.Errorf("%s passed at the last release, but is now %s: %s", name, tag, reason)
+		}
+	}
+	if len(sources) > 0 {
+		t.Logf("%d of %d conformance tests pass (%d%%): %d fail, %d unsupported",
+			counts[pass], len(sources), 100*counts[pass]/len(sources), counts[fail], counts[unsupported])
+	}
+
+	if *update {
+		writeConformanceStatus(t, tags)
+	}
+}
+
+// runConformanceTest compiles and runs the test and returns its tag and, unless it
+// passed, the reason why not
+func runConformanceTest(t *testing.T, source string) (string, string) {
+	runs, reason := parseJtregTags(source)
+	if reason != "" {
+		return unsupported, reason
+	}
+	classes := t.TempDir()
+	if err := compileJava(classes, source); err != nil {
+		return fail, "it doesn't compile: " + err.Error()
+	}
+
+	for _, run := range runs {
+		class := filepath.Join(classes, filepath.FromSlash(strings.ReplaceAll(run.class, ".", "/"))+".class")
+		args := append(append(run.vmArgs, class), run.appArgs...)
+		ctx, cancel := context.WithTimeout(context.Background(), conformanceTimeout)
+		var output bytes.Buffer
+		cmd := exec.CommandContext(ctx, jacobinExe, args...)
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		err := cmd.Run()
+		timedOut := ctx.Err() != nil
+		cancel()
+
+		if tag, why := conformanceTag(normalizeOutput(output.String()), err, timedOut); tag != pass {
+			return tag, "@run main " + run.class + ": " + why
+		}
+	}
+	return pass, ""
+}
+
+// conformanceTag tags a run of a test by how it ended and what it wrote
+func conformanceTag(output string, err error, timedOut bool) (string, string) {
+	for _, marker := range unsupportedOutput {
+		if i := strings.Index(output, marker); i >= 0 {
+			line := output[i:]
+			if end := strings.IndexByte(line, '\n'); end >= 0 {
+				line = line[:end]
+			}
+			return unsupported, line
+		}
+	}
+	switch {
+	case timedOut:
+		return fail, "it ran longer than " + conformanceTimeout.String()
+	case strings.Contains(output, "Exception in thread"):
+		return fail, strings.TrimSpace(output[strings.Index(output, "Exception in thread"):])
+	case err != nil:
+		return fail, err.Error()
+	}
+	return pass, ""
+}
+
+// parseJtregTags returns the test's @run main tags or, if it needs more of jtreg than
+// they are, why it's unsupported
+func parseJtregTags(source string) ([]jtregRun, string) {
+	content, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err.Error()
+	}
+	isTest := false
+	var runs []jtregRun
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() { // the tags are in the comment that begins with @test
+		raw := strings.TrimSpace(scanner.Text())
+		line := strings.TrimSpace(strings.TrimSuffix(strings.TrimLeft(raw, "/*"), "*/"))
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "@") || (!isTest && fields[0] != "@test") {
+			continue
+		}
+		switch fields[0] {
+		case "@test":
+			isTest = true
+		case "@summary", "@bug", "@author", "@key":
+		case "@run":
+			run, ok := parseJtregRun(fields[1:])
+			if !ok {
+				return nil, "it needs jtreg for " + line
+			}
+			runs = append(runs, run)
+		default:
+			return nil, "it needs jtreg for " + fields[0]
+		}
+		if isTest && strings.HasSuffix(raw, "*/") {
+			break
+		}
+	}
+	if !isTest {
+		return nil, "it has no @test tag"
+	}
+	if len(runs) == 0 { // as jtreg does, run the class named after the file
+		name := filepath.Base(source)
+		runs = append(runs, jtregRun{class: strings.TrimSuffix(name, filepath.Ext(name))})
+	}
+	return runs, ""
+}
+
+// parseJtregRun parses the fields of @run main (or main/othervm) [<vm options>] <class> [<args>]
+func parseJtregRun(fields []string) (jtregRun, bool) {
+	if len(fields) < 2 || (fields[0] != "main" && fields[0] != "main/othervm") {
+		return jtregRun{}, false
+	}
+	run := jtregRun{}
+	for i, field := range fields[1:] {
+		if run.class == "" && strings.HasPrefix(field, "-") {
+			run.vmArgs = append(run.vmArgs, field)
+		} else if run.class == "" {
+			run.class = field
+		} else {
+			run.appArgs = fields[i+1:]
+			break
+		}
+	}
+	return run, run.class != ""
+}
+
+// readConformanceStatus returns the tags of the tests at the last release
+func readConformanceStatus() map[string]string {
+	tags := make(map[string]string)
+	content, err := ioutil.ReadFile(conformanceStatus)
+	if err != nil {
+		return tags
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && !strings.HasPrefix(fields[0], "#") {
+			tags[fields[0]] = fields[1]
+		}
+	}
+	return tags
+}
+
+// writeConformanceStatus records the tags of the tests
+func writeConformanceStatus(t *testing.T, tags map[string]string) {
+	var names []string
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var status strings.Builder
+	status.WriteString("# The conformance tests' tags at the last release (see wholeClassTests/conformance_test.go)\n")
+	for _, name := range names {
+		fmt.Fprintf(&status, "%s %s\n", name, tags[name])
+	}
+	if err := ioutil.WriteFile(conformanceStatus, []byte(status.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseJtregTags(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, header string) string {
+		path := filepath.Join(dir, name+".java")
+		_ = ioutil.WriteFile(path, []byte(header+"\npublic class "+name+" {}\n"), 0644)
+		return path
+	}
+
+	runs, reason := parseJtregTags(write("Plain", "/*\n * @test\n * @bug 4242\n * @summary s\n"+
+		" * @run main Plain\n * @run main/othervm -Dp=v -Xss1m Plain a1 -a2\n */"))
+	if reason != "" || len(runs) != 2 {
+		t.Fatalf("Expected two runs, got %+v (%s)", runs, reason)
+	}
+	if runs[0].class != "Plain" || runs[0].vmArgs != nil || runs[0].appArgs != nil {
+		t.Errorf("Unexpected first run: %+v", runs[0])
+	}
+	if runs[1].class != "Plain" || strings.Join(runs[1].vmArgs, " ") != "-Dp=v -Xss1m" ||
+		strings.Join(runs[1].appArgs, " ") != "a1 -a2" {
+		t.Errorf("Unexpected second run: %+v", runs[1])
+	}
+
+	runs, _ = parseJtregTags(write("Default", "/* @test */\n@Deprecated"))
+	if len(runs) != 1 || runs[0].class != "Default" {
+		t.Errorf("Expected the class named after the file to be run, got %+v", runs)
+	}
+
+	for header, expected := range map[string]string{
+		"/* @test\n * @library /lib\n * @run main Lib */": "@library",
+		"/* @test\n * @run testng Lib */":                 "@run testng",
+		"// no tags":                                      "no @test",
+	} {
+		if _, reason = parseJtregTags(write("Lib", header)); !strings.Contains(reason, expected) {
+			t.Errorf("Expected %q to be unsupported for %s, got %q", header, expected, reason)
+		}
+	}
+}
+
+func TestConformanceTag(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+	for _, c := range []struct {
+		output   string
+		err      error
+		timedOut bool
+		tag      string
+	}{
+		{"done\n", nil, false, pass},
+		{"Exception in thread \"main\" java.lang.RuntimeException: 3 != 4\n", exitErr, false, fail},
+		{"", exitErr, false, fail},
+		{"", nil, true, fail},
+		{"Invalid bytecode found: 188 at location 4 in method main() of class T\n", exitErr, false, unsupported},
+	} {
+		if tag, why := conformanceTag(c.output, c.err, c.timedOut); tag != c.tag {
+			t.Errorf("Expected %q to be tagged %s, got %s (%s)", c.output, c.tag, tag, why)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package wholeClassTests
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The golden-file tests. Each directory in testdata/golden is a test case, which holds:
+//
+//   - the test class: Foo.java, which is compiled with the JDK's javac, or Foo.class,
+//     which is run as it is if there's no Foo.java or no JDK. If the case has several
+//     classes, the main class is the one named after the directory.
+//   - expected-stdout and expected-stderr: what Jacobin should write to stdout and to
+//     stderr. A stream without a file isn't checked.
+//   - jvm-args: the options to run Jacobin with, separated by white space, if any. The
+//     program's args, if any, go in app-args.
+//
+// The output is compared after it's normalized: line endings are \n, trailing white
+// space is dropped, and Jacobin's banner is removed. So a new end-to-end test is a new
+// directory. To write or rewrite the expected files from Jacobin's output, run
+//
+//	go test ./wholeClassTests -run TestGolden -update
+
+var update = flag.Bool("update", false, "write the golden tests' expected output from Jacobin's")
+
+// the directory of the golden-file test cases
+var goldenDir = filepath.Join(testdataDir, "golden")
+
+func TestGolden(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+	cases, _ := ioutil.ReadDir(goldenDir)
+	for _, c := range cases {
+		if c.IsDir() {
+			dir := filepath.Join(goldenDir, c.Name())
+			t.Run(c.Name(), func(t *testing.T) { runGoldenCase(t, dir) })
+		}
+	}
+}
+
+// runGoldenCase runs the test case in the directory and compares its output with the
+// expected output
+func runGoldenCase(t *testing.T, dir string) {
+	requireBaseClasses(t)
+	mainClass, err := goldenClass(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := append(readArgs(dir, "jvm-args"), mainClass)
+	args = append(args, readArgs(dir, "app-args")...)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(jacobinExe, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			t.Fatalf("Got error running Jacobin: %s", err.Error())
+		}
+	}
+
+	for _, stream := range []struct {
+		file   string
+		output string
+	}{{"expected-stdout", normalizeOutput(stdout.String())},
+		{"expected-stderr", normalizeOutput(stderr.String())}} {
+		path := filepath.Join(dir, stream.file)
+		if *update {
+			if err = ioutil.WriteFile(path, []byte(stream.output), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		expected, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue // the stream isn't checked
+		}
+		if want := normalizeOutput(string(expected)); stream.output != want {
+			t.Errorf("%s differs from the output of jacobin %s:\n%s",
+				stream.file, strings.Join(args, " "), diffLines(want, stream.output))
+		}
+	}
+}
+
+// goldenClass returns the class file of the case's main class, which it compiles if
+// it's a Java source and there's a JDK
+func goldenClass(t *testing.T, dir string) (string, error) {
+	name := filepath.Base(dir)
+	sources, _ := filepath.Glob(filepath.Join(dir, "*.java"))
+	if len(sources) > 0 && javacPath() != "" {
+		compiled := t.TempDir()
+		if err := compileJava(compiled, sources...); err != nil {
+			return "", err
+		}
+		return mainClassIn(compiled, name, sources)
+	}
+
+	classes, _ := filepath.Glob(filepath.Join(dir, "*.class"))
+	if len(classes) == 0 {
+		if len(sources) > 0 {
+			t.Skip("the test class needs a JDK to compile it")
+		}
+		t.Skip("the case has no test class")
+	}
+	return mainClassIn(dir, name, classes)
+}
+
+// mainClassIn returns the main class's file in the directory: the one named after the
+// case or, if the case has only one class, that one
+func mainClassIn(dir, name string, files []string) (string, error) {
+	if len(files) == 1 {
+		base := filepath.Base(files[0])
+		name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	path := filepath.Join(dir, name+".class")
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// readArgs returns the args in the case's file, if there is one
+func readArgs(dir, file string) []string {
+	content, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(content))
+}
+
+// normalizeOutput makes output comparable from one run, and one platform, to another
+func normalizeOutput(output string) string {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	var kept []string
+	for i, line := range lines {
+		if i == 0 && strings.HasPrefix(line, "Jacobin VM v.") {
+			continue // the banner
+		}
+		kept = append(kept, strings.TrimRight(line, " \t"))
+	}
+	return strings.TrimRight(strings.Join(kept, "\n"), "\n") + "\n"
+}
+
+// diffLines shows the first line at which the output differs from what was expected
+func diffLines(expected, output string) string {
+	want := strings.Split(expected, "\n")
+	got := strings.Split(output, "\n")
+	for i := 0; ; i++ {
+		if i >= len(want) || i >= len(got) || want[i] != got[i] {
+			return fmt.Sprintf("at line %d\n  expected: %s\n  got:      %s", i+1, lineAt(want, i), lineAt(got, i))
+		}
+	}
+}
+
+func lineAt(lines []string, i int) string {
+	if i >= len(lines) {
+		return "<end of output>"
+	}
+	return lines[i]
+}
@@ -0,0 +1,135 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2022 by Andrew Binstock. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package wholeClassTests
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// The harness that the tests run Jacobin with. It finds, or makes, what they need:
+//
+//   - the jacobin executable: the one named by JACOBIN_EXE, if it's set, or else one
+//     built from this source tree, with the go command that's running the tests.
+//   - the test classes: compiled from the sources in testdata/java with the javac of
+//     the JDK in JAVA_HOME (or on the PATH), or, if there's no JDK, the class files
+//     in testdata that were compiled from them.
+//
+// So the tests run as they are, with go test ./..., on any platform. The tests that run
+// a class also need the JDK's base classes, in JACOBIN_HOME/classes, as Jacobin does;
+// without them, those tests are skipped. With -short, nothing is built or compiled,
+// and all the tests are skipped.
+
+// the directory of the test classes' sources and of the class files compiled from them
+var testdataDir = filepath.Join("..", "..", "testdata")
+
+// the Jacobin executable and the directory of the test classes, which are set by TestMain
+var (
+	jacobinExe string
+	classDir   string
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	status := 0
+	if !testing.Short() {
+		tempDir, err := ioutil.TempDir("", "jacobin-wholeclass")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err = findJacobin(tempDir); err != nil {
+			fmt.Fprintln(os.Stderr, "wholeClassTests: the jacobin executable couldn't be built:", err)
+		}
+		findTestClasses(tempDir)
+		status = m.Run()
+		_ = os.RemoveAll(tempDir)
+	} else {
+		status = m.Run()
+	}
+	os.Exit(status)
+}
+
+// findJacobin sets jacobinExe to the executable named by JACOBIN_EXE or, if it's not
+// set, builds one in the directory
+func findJacobin(dir string) error {
+	if jacobinExe = os.Getenv("JACOBIN_EXE"); jacobinExe != "" {
+		return nil
+	}
+	jacobinExe = filepath.Join(dir, "jacobin")
+	if runtime.GOOS == "windows" {
+		jacobinExe += ".exe"
+	}
+	goCmd := filepath.Join(runtime.GOROOT(), "bin", "go")
+	if _, err := os.Stat(goCmd); err != nil {
+		goCmd = "go"
+	}
+	cmd := exec.Command(goCmd, "build", "-o", jacobinExe, "jacobin")
+	cmd.Dir = ".." // the module's root
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v\n%s", err, out)
+	}
+	return nil
+}
+
+// findTestClasses sets classDir to a directory in dir to which the test classes have
+// been compiled or, if they can't be, to the directory of the prebuilt class files
+func findTestClasses(dir string) {
+	classDir = testdataDir
+	sources, _ := filepath.Glob(filepath.Join(testdataDir, "java", "*.java"))
+	if len(sources) == 0 || javacPath() == "" {
+		return // there's no JDK
+	}
+	compiled := filepath.Join(dir, "classes")
+	if err := compileJava(compiled, sources...); err != nil {
+		fmt.Fprintln(os.Stderr, "wholeClassTests: using the prebuilt classes, since javac failed:", err)
+		return
+	}
+	classDir = compiled
+}
+
+// javacPath returns the javac of the JDK in JAVA_HOME or on the PATH, or "" if there's none
+func javacPath() string {
+	javac := "javac"
+	if javaHome := os.Getenv("JAVA_HOME"); javaHome != "" {
+		javac = filepath.Join(javaHome, "bin", "javac")
+	}
+	path, err := exec.LookPath(javac)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// compileJava compiles the sources to class files in dir
+func compileJava(dir string, sources ...string) error {
+	// the classes are compiled for the Java release that Jacobin supports
+	args := append([]string{"--release", "11", "-d", dir}, sources...)
+	if out, err := exec.Command(javacPath(), args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v\n%s", err, out)
+	}
+	return nil
+}
+
+// requireBaseClasses skips the test if Jacobin can't find the JDK's base classes
+func requireBaseClasses(t *testing.T) {
+	home := os.Getenv("JACOBIN_HOME")
+	if _, err := os.Stat(filepath.Join(home, "classes", "baseclasslist.txt")); home == "" || err != nil {
+		t.Skip("JACOBIN_HOME isn't set to a directory with the JDK's base classes")
+	}
+}
+
+// testClass returns the path of the class file of the test class
+func testClass(name string) string {
+	return filepath.Join(classDir, name+".class")
+}
@@ -22,7 +22,7 @@ import (
  */
 
 func initVarsHelp() {
-	_JACOBIN = "d:\\GoogleDrive\\Dev\\jacobin\\src\\jacobin.exe"
+	_JACOBIN = jacobinExe
 	_JVM_ARGS = "-help"
 	_TESTCLASS = ""
 	_APP_ARGS = ""
@@ -17,12 +17,12 @@ import (
 
 /*
  * Tests for: jacobin --show-version
- *     which should print the version info and and usage info to stdout
+ *     which should print the version info to stdout and, as no class is given, usage info to stderr
  * These tests check the output.
  */
 
 func initVarsShowVversion() {
-	_JACOBIN = "d:\\GoogleDrive\\Dev\\jacobin\\src\\jacobin.exe"
+	_JACOBIN = jacobinExe
 	_JVM_ARGS = "--show-version"
 	_TESTCLASS = ""
 	_APP_ARGS = ""
@@ -63,7 +63,7 @@ func TestRunShowVversion(t *testing.T) {
 	}
 
 	// get the stdout and stderr contents from the file execution
-	// stderr, err := cmd.StderrPipe()
+	stderr, err := cmd.StderrPipe()
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		log.Fatal(err)
@@ -75,15 +75,20 @@ func TestRunShowVversion(t *testing.T) {
 	}
 
 	slurp, _ := io.ReadAll(stdout)
-	if !strings.HasPrefix(string(slurp), "Jacobin VM") {
-		t.Errorf("Output did not begin with Jacobin name, instead: %s", string(slurp))
+	if !strings.HasPrefix(string(slurp), "openjdk ") {
+		t.Errorf("Output did not begin with the Java version, instead: %s", string(slurp))
 	}
 
-	if !strings.Contains(string(slurp), "64-bit server VM") {
+	if !strings.Contains(string(slurp), "Jacobin VM") {
+		t.Errorf("Output did not contain the Jacobin name, instead: %s", string(slurp))
+	}
+
+	if !strings.Contains(string(slurp), "64-Bit Server VM") {
 		t.Errorf("Output did contain expected literals, instead: %s", string(slurp))
 	}
 
-	if !strings.Contains(string(slurp), "Usage: jacobin [options] <mainclass> [args...]") {
-		t.Errorf("Did not get expected output to stderr. Got: %s", string(slurp))
+	serr, _ := io.ReadAll(stderr)
+	if !strings.Contains(string(serr), "Usage: jacobin [options] <mainclass> [args...]") {
+		t.Errorf("Did not get expected output to stderr. Got: %s", string(serr))
 	}
 }
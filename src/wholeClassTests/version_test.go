@@ -22,7 +22,7 @@ import (
  */
 
 func initVarsVersion() {
-	_JACOBIN = "d:\\GoogleDrive\\Dev\\jacobin\\src\\jacobin.exe"
+	_JACOBIN = jacobinExe
 	_JVM_ARGS = "-version"
 	_TESTCLASS = "" // the class to test
 	_APP_ARGS = ""
@@ -76,11 +76,15 @@ func TestRunVersion(t *testing.T) {
 	// Here begin the actual tests on the output to stderr and stdout
 
 	slurp, _ := io.ReadAll(stderr)
-	if !strings.HasPrefix(string(slurp), "Jacobin VM") {
-		t.Errorf("Stderr did not begin with Jacobin name, instead: %s", string(slurp))
+	if !strings.HasPrefix(string(slurp), "openjdk version") {
+		t.Errorf("Stderr did not begin with the Java version, instead: %s", string(slurp))
 	}
 
-	if !strings.Contains(string(slurp), "64-bit server VM") {
+	if !strings.Contains(string(slurp), "Jacobin VM") {
+		t.Errorf("Stderr did not contain the Jacobin name, instead: %s", string(slurp))
+	}
+
+	if !strings.Contains(string(slurp), "64-Bit Server VM") {
 		t.Errorf("Did not get expected output to stderr. Got: %s", string(slurp))
 	}
 }
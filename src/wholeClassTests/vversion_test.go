@@ -17,18 +17,18 @@ import (
 
 /*
  * Tests for: jacobin --version
- *     which should print info about the JVM to stdout (which go test reroutes to stderr (dang it!))
+ *     which should print info about the JVM to stdout
  */
 
 func initVarsVversion() {
-	_JACOBIN = "d:\\GoogleDrive\\Dev\\jacobin\\src\\jacobin.exe"
+	_JACOBIN = jacobinExe
 	_JVM_ARGS = "--version"
 	_TESTCLASS = "" // the class to test
 	_APP_ARGS = ""
 }
 
 func TestRunVversion(t *testing.T) {
-	initVarsVersion()
+	initVarsVversion()
 	var cmd *exec.Cmd
 
 	if testing.Short() { // don't run if running quick tests only. (Used primarily so GitHub doesn't run and bork)
@@ -60,7 +60,7 @@ func TestRunVversion(t *testing.T) {
 		}
 	}
 
-	stderr, err := cmd.StderrPipe()
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -70,17 +70,21 @@ func TestRunVversion(t *testing.T) {
 		t.Errorf("Got error running Jacobin: %s", err.Error())
 	}
 
-	slurpErr, _ := io.ReadAll(stderr) // the output is written to stderr, which go test reroutes to stderr
-	if len(slurpErr) == 0 {
-		t.Errorf("Expected output to stderr, but got none")
+	slurp, _ := io.ReadAll(stdout)
+	if len(slurp) == 0 {
+		t.Errorf("Expected output to stdout, but got none")
 	}
 
-	msg := string(slurpErr)
-	if !strings.HasPrefix(msg, "Jacobin VM") {
-		t.Errorf("Output did not begin with Jacobin name, instead: %s", string(msg))
+	msg := string(slurp)
+	if !strings.HasPrefix(msg, "openjdk ") {
+		t.Errorf("Output did not begin with the Java version, instead: %s", msg)
 	}
-	//
-	if !strings.Contains(msg, "64-bit server VM") {
-		t.Errorf("Did not get expected output to stderr. Got: %s", msg)
+
+	if !strings.Contains(msg, "Jacobin VM") {
+		t.Errorf("Output did not contain the Jacobin name, instead: %s", msg)
+	}
+
+	if !strings.Contains(msg, "64-Bit Server VM") {
+		t.Errorf("Did not get expected output to stdout. Got: %s", msg)
 	}
 }
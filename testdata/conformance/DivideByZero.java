@@ -0,0 +1,15 @@
+/*
+ * @test
+ * @summary int division by zero throws ArithmeticException, which can be caught
+ * @run main DivideByZero
+ */
+public class DivideByZero {
+	public static void main(String[] args) {
+		int zero = args.length;
+		try {
+			int result = 1 / zero;
+			throw new RuntimeException("no exception, but " + result);
+		} catch (ArithmeticException expected) {
+		}
+	}
+}
@@ -0,0 +1,20 @@
+/*
+ * @test
+ * @summary int addition, subtraction, and multiplication wrap around on overflow
+ * @run main IntArithmetic
+ */
+public class IntArithmetic {
+	public static void main(String[] args) {
+		int max = Integer.MAX_VALUE;
+		check(max + 1, Integer.MIN_VALUE);
+		check(Integer.MIN_VALUE - 1, max);
+		check(65536 * 65536, 0);
+		check(-7 * 6, -42);
+	}
+
+	static void check(int actual, int expected) {
+		if (actual != expected) {
+			throw new RuntimeException(actual + " != " + expected);
+		}
+	}
+}
@@ -0,0 +1,20 @@
+/*
+ * @test
+ * @summary int division truncates toward zero, and the remainder takes the dividend's sign
+ * @run main IntDivision
+ */
+public class IntDivision {
+	public static void main(String[] args) {
+		check(7 / 2, 3);
+		check(-7 / 2, -3);
+		check(7 % -2, 1);
+		check(-7 % 2, -1);
+		check(Integer.MIN_VALUE / -1, Integer.MIN_VALUE);
+	}
+
+	static void check(int actual, int expected) {
+		if (actual != expected) {
+			throw new RuntimeException(actual + " != " + expected);
+		}
+	}
+}
@@ -0,0 +1,18 @@
+/*
+ * @test
+ * @summary main() gets the args from the command line, in order
+ * @run main MainArgs one two three
+ */
+public class MainArgs {
+	public static void main(String[] args) {
+		String[] expected = { "one", "two", "three" };
+		if (args.length != expected.length) {
+			throw new RuntimeException("got " + args.length + " args");
+		}
+		for (int i = 0; i < args.length; i++) {
+			if (!args[i].equals(expected[i])) {
+				throw new RuntimeException("arg " + i + " is " + args[i]);
+			}
+		}
+	}
+}
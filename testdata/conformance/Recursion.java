@@ -0,0 +1,16 @@
+/*
+ * @test
+ * @summary static methods can call themselves, with their own locals in each call
+ * @run main Recursion
+ */
+public class Recursion {
+	public static void main(String[] args) {
+		if (fib(20) != 6765) {
+			throw new RuntimeException("fib(20) is " + fib(20));
+		}
+	}
+
+	static int fib(int n) {
+		return n < 2 ? n : fib(n - 1) + fib(n - 2);
+	}
+}
@@ -0,0 +1,26 @@
+/*
+ * @test
+ * @summary a class's static initializer runs before its static fields are first used
+ * @run main StaticInit
+ */
+public class StaticInit {
+	static int initialized;
+
+	static class Holder {
+		static int value = init();
+
+		static int init() {
+			initialized++;
+			return 42;
+		}
+	}
+
+	public static void main(String[] args) {
+		if (initialized != 0) {
+			throw new RuntimeException("initialized too early");
+		}
+		if (Holder.value != 42 || initialized != 1) {
+			throw new RuntimeException("not initialized once: " + initialized);
+		}
+	}
+}
@@ -0,0 +1,13 @@
+/*
+ * @test
+ * @summary a system property set with -D is seen by System.getProperty()
+ * @run main/othervm -Dconformance.value=42 SystemProperty
+ */
+public class SystemProperty {
+	public static void main(String[] args) {
+		String value = System.getProperty("conformance.value");
+		if (!"42".equals(value)) {
+			throw new RuntimeException("conformance.value is " + value);
+		}
+	}
+}
@@ -0,0 +1,7 @@
+class Echo {
+	public static void main( String[] args) {
+		for( int i = 0; i < args.length; i++) {
+			System.out.println( args[i] );
+		}
+	}
+}
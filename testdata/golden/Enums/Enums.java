@@ -0,0 +1,62 @@
+/*
+ * The constants of enums, values() and valueOf(), an enum with a constructor and a
+ * field, constants with bodies, and a switch over an enum, which javac compiles into
+ * a lookup in the $SwitchMap array of the synthetic class Enums$1.
+ */
+enum Color { RED, GREEN, BLUE }
+
+enum Coin {
+    PENNY(1), NICKEL(5), DIME(10);
+
+    private final int cents;
+
+    Coin(int cents) {
+        this.cents = cents;
+    }
+
+    int cents() {
+        return cents;
+    }
+}
+
+enum Op {
+    PLUS {
+        int apply(int a, int b) {
+            return a + b;
+        }
+    },
+    TIMES {
+        int apply(int a, int b) {
+            return a * b;
+        }
+    };
+
+    abstract int apply(int a, int b);
+}
+
+public class Enums {
+
+    public static void main(String[] args) {
+        for (Color c : Color.values()) {
+            System.out.println(c.name());
+            System.out.println(c.ordinal());
+            System.out.println(describe(c));
+        }
+        System.out.println(Color.valueOf("BLUE").ordinal());
+        System.out.println(Coin.DIME.cents());
+        System.out.println(Coin.NICKEL.compareTo(Coin.DIME));
+        System.out.println(Op.PLUS.apply(2, 3));
+        System.out.println(Op.TIMES.apply(2, 3));
+    }
+
+    static String describe(Color c) {
+        switch (c) {
+            case RED:
+                return "warm";
+            case GREEN:
+                return "natural";
+            default:
+                return "cool";
+        }
+    }
+}
@@ -0,0 +1,21 @@
+// A static initializer that throws: the first use of the class gets an
+// ExceptionInInitializerError, and later uses a NoClassDefFoundError.
+public class InitError {
+    static class Config {
+        static final String value;
+        static { if (true) throw new IllegalStateException("no config file"); value = ""; }
+
+        static String get() {
+            return value;
+        }
+    }
+
+    public static void main(String[] args) {
+        try { Config.get();
+        } catch (ExceptionInInitializerError e) {
+            System.out.println("caught ExceptionInInitializerError");
+            System.out.println(e.getCause().getMessage());
+        }
+        Config.get();
+    }
+}
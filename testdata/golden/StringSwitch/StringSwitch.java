@@ -0,0 +1,29 @@
+/*
+ * A switch on Strings, which javac compiles into a lookupswitch on the hash codes of
+ * the cases, then calls of equals() that tell apart the cases with the same hash code
+ * ("Aa" and "BB"), then a tableswitch on the index of the case that matched.
+ */
+public class StringSwitch {
+
+    public static void main(String[] args) {
+        String[] words = {"apple", "Aa", "BB", "cherry", "kiwi"};
+        for (String w : words) {
+            System.out.println(kind(w));
+        }
+        System.out.println(kind(new String("apple"))); // not the interned constant
+    }
+
+    static String kind(String w) {
+        switch (w) {
+            case "apple":
+            case "cherry":
+                return "fruit";
+            case "Aa":
+                return "first";
+            case "BB":
+                return "second";
+            default:
+                return "unknown";
+        }
+    }
+}
@@ -0,0 +1,22 @@
+/*
+ * ThreadLocal.get(), set(), and remove(), which Jacobin implements in Go. The subclass
+ * overrides initialValue(), which get() calls when the thread has no value.
+ */
+public class ThreadLocals {
+
+    static class Named extends ThreadLocal<String> {
+        @Override
+        protected String initialValue() {
+            return "initial";
+        }
+    }
+
+    public static void main(String[] args) {
+        ThreadLocal<String> local = new Named();
+        System.out.println(local.get());
+        local.set("changed");
+        System.out.println(local.get());
+        local.remove();
+        System.out.println(local.get());
+    }
+}
@@ -0,0 +1,59 @@
+/*
+ * try-with-resources, which javac compiles into a catch of Throwable that closes the
+ * resource, adds the exception that close() throws (if any) to the body's exception
+ * with addSuppressed(), and rethrows the body's exception. The suppressed exception is
+ * shown in the stack trace that printStackTrace() writes.
+ */
+public class TryWithResources {
+
+    static class Resource implements AutoCloseable {
+        private final String name;
+        private final boolean failOnClose;
+
+        Resource(String name, boolean failOnClose) {
+            this.name = name;
+            this.failOnClose = failOnClose;
+        }
+
+        public void close() {
+            System.out.println(name);
+            if (failOnClose) {
+                throw new IllegalStateException(name);
+            }
+        }
+    }
+
+    static void use(AutoCloseable r, boolean failInBody) throws Exception {
+        try (r) {
+            System.out.println("body");
+            if (failInBody) {
+                throw new IllegalArgumentException("failed in body");
+            }
+        }
+    }
+
+    public static void main(String[] args) throws Exception {
+        use(new Resource("closed a", false), false);
+        try {
+            use(new Resource("closed b", true), false);
+        } catch (IllegalStateException e) {
+            System.out.print("caught: ");
+            System.out.println(e.getMessage());
+        }
+        try {
+            use(new Resource("closed c", false), true);
+        } catch (IllegalArgumentException e) {
+            System.out.print("caught: ");
+            System.out.println(e.getMessage());
+            System.out.println(e.getSuppressed().length);
+        }
+        try {
+            use(new Resource("closed d", true), true);
+        } catch (IllegalArgumentException e) {
+            System.out.print("caught: ");
+            System.out.println(e.getMessage());
+            System.out.println(e.getSuppressed().length);
+            e.printStackTrace();
+        }
+    }
+}
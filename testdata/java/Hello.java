@@ -0,0 +1,6 @@
+class Hello {
+	public static void main( String[] args) {
+		for( int i = 0; i < 10; i++)
+			System.out.println( "Hello from Hello.main!" );
+	}
+}
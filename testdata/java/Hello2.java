@@ -0,0 +1,13 @@
+class Hello2 {
+	public static void main( String[] args) {
+		int x;
+		for( int i = 0; i < 10; i++) {
+			x = addTwo(i, i-1);
+			System.out.println( x );
+		}
+	}
+
+	static int addTwo(int j, int k) {
+		return j + k;
+	}
+}
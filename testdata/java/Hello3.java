@@ -0,0 +1,19 @@
+class Hello3 {
+
+	public static void main( String[] args) {
+		int x;
+		for( int i = 0; i < 10; i++) {
+			x = addTwo(i, i-1);
+			System.out.println( x );
+		}
+	}
+
+	static int addTwo(int j, int k) {
+		int m = multTwo(j, k);
+		return m+1;
+	}
+
+	static int multTwo(int m, int n){
+		return m*n;
+	}
+}
@@ -0,0 +1,11 @@
+import static java.lang.System.nanoTime;
+
+public class NanoPrint {
+
+	public static void main( String[] args) {
+		long nano1 = nanoTime();
+		long nano2 = nanoTime();
+		System.out.println( nano1 );
+		System.out.println( nano2 );
+	}
+}
@@ -0,0 +1,10 @@
+// The source of the Kotlin class that TestLoadCompiledKotlinAndScala in
+// src/classloader/kotlinScala_test.go loads. Compile it here with
+//
+//	kotlinc -Xlambdas=indy -jvm-target 11 -d . Greeter.kt
+//
+// to run that test; without Greeter.class, the test is skipped.
+class Greeter(private val name: String) : (String) -> String {
+    override fun invoke(greeting: String) = "$greeting, $name"
+    fun `greets the world`() = { invoke("Hello") }
+}
@@ -0,0 +1,10 @@
+// The source of the Scala object that TestLoadCompiledKotlinAndScala in
+// src/classloader/kotlinScala_test.go loads. Compile it here with
+//
+//	scalac -d . Scores.scala
+//
+// to run that test; without Scores$.class, the test is skipped.
+object Scores {
+    def ++(a: Int, b: Int): Int = a + b
+    def adder: (Int, Int) => Int = _ + _
+}